@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 
 	"github.com/iainlowe/capn/internal/cli"
@@ -8,8 +11,20 @@ import (
 
 func main() {
 	c := cli.NewCLI()
-	
-	if err := c.Parse(os.Args[1:]); err != nil {
-		os.Exit(1)
+
+	os.Exit(exitCode(c.Parse(os.Args[1:]), os.Stderr))
+}
+
+// exitCode returns the code capn exits with after err, printing err to stderr unless it is an
+// *cli.ExitError, whose command has already reported how it ended
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *cli.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
 	}
+	fmt.Fprintf(stderr, "capn: error: %v\n", err)
+	return 1
 }
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/cli"
 )
 
 func TestMain_HelpCommand(t *testing.T) {
@@ -27,4 +32,18 @@ func TestMain_Version(t *testing.T) {
 	// Just verify that main can be compiled and basic structure exists
 	// The actual main() function is hard to test due to os.Exit calls
 	assert.True(t, true, "main package compiles successfully")
-}
\ No newline at end of file
+}
+func TestExitCode(t *testing.T) {
+	var stderr bytes.Buffer
+	assert.Equal(t, 0, exitCode(nil, &stderr))
+	assert.Empty(t, stderr.String())
+
+	assert.Equal(t, 1, exitCode(errors.New("--attach cannot be combined with --plan-only, --simulate or --dry-run"), &stderr))
+	assert.Equal(t, "capn: error: --attach cannot be combined with --plan-only, --simulate or --dry-run\n", stderr.String())
+
+	// Commands returning an ExitError have reported how they ended already
+	stderr.Reset()
+	err := fmt.Errorf("attach: %w", &cli.ExitError{Code: cli.ExitTaskCancelled, Message: "task t-1 was cancelled"})
+	assert.Equal(t, cli.ExitTaskCancelled, exitCode(err, &stderr))
+	assert.Empty(t, stderr.String())
+}
@@ -0,0 +1,89 @@
+package agents
+
+import (
+	"sync"
+	"time"
+)
+
+// AgentActivity is the work a managed agent has done and the messages it has exchanged
+type AgentActivity struct {
+	Tasks    int           `json:"tasks"`
+	Failed   int           `json:"failed"`
+	BusyTime time.Duration `json:"busy_time"`
+	// AverageDuration is the busy time spread over the tasks executed
+	AverageDuration time.Duration `json:"average_duration"`
+	// ErrorRate is the fraction of executed tasks that failed, from 0 to 1
+	ErrorRate float64 `json:"error_rate"`
+
+	MessagesSent     int `json:"messages_sent"`
+	MessagesReceived int `json:"messages_received"`
+}
+
+// MessageCounter is implemented by agents that count the messages they send and receive
+type MessageCounter interface {
+	MessageCounts() (sent, received int)
+}
+
+// activityTracker holds the tasks each agent executed, which outlive restarts of the agent
+type activityTracker struct {
+	mu      sync.Mutex
+	byAgent map[string]*AgentActivity
+}
+
+func newActivityTracker() *activityTracker {
+	return &activityTracker{byAgent: make(map[string]*AgentActivity)}
+}
+
+func (a *activityTracker) record(agentID string, duration time.Duration, success bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	activity, ok := a.byAgent[agentID]
+	if !ok {
+		activity = &AgentActivity{}
+		a.byAgent[agentID] = activity
+	}
+	activity.Tasks++
+	activity.BusyTime += duration
+	if !success {
+		activity.Failed++
+	}
+}
+
+// of returns an agent's activity, adding the messages it counted itself
+func (a *activityTracker) of(agent Agent) AgentActivity {
+	a.mu.Lock()
+	var activity AgentActivity
+	if recorded, ok := a.byAgent[agent.ID()]; ok {
+		activity = *recorded
+	}
+	a.mu.Unlock()
+
+	if activity.Tasks > 0 {
+		activity.AverageDuration = activity.BusyTime / time.Duration(activity.Tasks)
+		activity.ErrorRate = float64(activity.Failed) / float64(activity.Tasks)
+	}
+	if counter, ok := agent.(MessageCounter); ok {
+		activity.MessagesSent, activity.MessagesReceived = counter.MessageCounts()
+	}
+	return activity
+}
+
+func (a *activityTracker) forget(agentID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.byAgent, agentID)
+}
+
+// RecordTask records that an agent finished executing a task, successfully or not
+func (m *AgentManager) RecordTask(agentID string, duration time.Duration, success bool) {
+	m.activity.record(agentID, duration, success)
+}
+
+// Activity returns the work a managed agent has done and the messages it has exchanged
+func (m *AgentManager) Activity(agentID string) (AgentActivity, bool) {
+	agent, ok := m.GetAgent(agentID)
+	if !ok {
+		return AgentActivity{}, false
+	}
+	return m.activity.of(agent), true
+}
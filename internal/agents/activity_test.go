@@ -0,0 +1,74 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentManager_Activity(t *testing.T) {
+	manager := NewAgentManager()
+	manager.SetRouter(NewMessageRouter())
+	file, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	_, err = manager.SpawnAgent("network-1", "NetworkAgent", AgentTypeNetwork)
+	require.NoError(t, err)
+
+	manager.RecordTask("file-1", 3*time.Second, true)
+	manager.RecordTask("file-1", time.Second, false)
+	require.NoError(t, file.SendMessage("network-1", Message{ID: "msg-1", Content: "scan", Type: MessageTypeText}))
+
+	activity, ok := manager.Activity("file-1")
+	require.True(t, ok)
+	assert.Equal(t, AgentActivity{
+		Tasks:           2,
+		Failed:          1,
+		BusyTime:        4 * time.Second,
+		AverageDuration: 2 * time.Second,
+		ErrorRate:       0.5,
+		MessagesSent:    1,
+	}, activity)
+
+	received, ok := manager.Activity("network-1")
+	require.True(t, ok)
+	assert.Equal(t, AgentActivity{MessagesReceived: 1}, received, "agents that executed nothing have no rates")
+
+	stats := manager.GetAgentStats()
+	assert.Equal(t, activity, stats.Activity["file-1"])
+	assert.Equal(t, received, stats.Activity["network-1"])
+
+	_, ok = manager.Activity("missing")
+	assert.False(t, ok)
+}
+
+func TestAgentManager_ActivityOutlivesRestart(t *testing.T) {
+	manager := NewAgentManager()
+	agent, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	manager.RecordTask("file-1", time.Second, true)
+
+	agent.(*BaseAgent).SetStatus(AgentStatusError)
+	_, err = manager.RestartAgent("file-1")
+	require.NoError(t, err)
+	activity, _ := manager.Activity("file-1")
+	assert.Equal(t, 1, activity.Tasks)
+
+	require.NoError(t, manager.TerminateAgent("file-1"))
+	_, err = manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	activity, _ = manager.Activity("file-1")
+	assert.Zero(t, activity.Tasks, "terminated agents are forgotten")
+}
+
+func TestBaseAgent_MessageCounts(t *testing.T) {
+	agent := NewBaseAgent("file-1", "FileAgent", AgentTypeFile)
+	assert.Error(t, agent.SendMessage("network-1", Message{ID: "msg-1", Content: "scan"}), "failed sends are not counted")
+	require.NoError(t, agent.ReceiveMessage(Message{ID: "msg-2", Content: "done"}))
+	agent.ClearReceivedMessages()
+
+	sent, received := agent.MessageCounts()
+	assert.Zero(t, sent)
+	assert.Equal(t, 1, received)
+}
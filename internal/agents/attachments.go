@@ -0,0 +1,94 @@
+package agents
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DefaultMaxMessageSize is the largest message content routed inline when the router sets no limit
+const DefaultMaxMessageSize = 64 << 10
+
+// maxMessagePreview bounds how much of a spilled message's content stays inline as a preview
+const maxMessagePreview = 1 << 10
+
+// Message data entries describing content moved out of a message
+const (
+	// MessageArtifactRef references the stored attachment holding the message's full content
+	MessageArtifactRef = "artifact_ref"
+	// MessageContentSize is the size in bytes of the message's full content
+	MessageContentSize = "content_size"
+	// MessageTruncated is set when content over the limit was cut because it could not be stored
+	MessageTruncated = "truncated"
+)
+
+// AttachmentStore keeps message content too large to route inline, so it does not bloat the
+// router, the communication log or agents' inboxes
+type AttachmentStore interface {
+	// StoreAttachment stores a message's content, returning the reference it is loaded by
+	StoreAttachment(message Message, content []byte) (string, error)
+	// LoadAttachment returns the content stored under a reference
+	LoadAttachment(ref string) ([]byte, error)
+}
+
+// SpillMessage returns the message with content over maxSize bytes moved to the store, leaving
+// a preview and the attachment's reference in Data[MessageArtifactRef]. A maxSize of zero uses
+// DefaultMaxMessageSize. Without a store, or when storing fails, the content is cut to the
+// limit and marked truncated; the error says why it was not stored.
+func SpillMessage(message Message, maxSize int, store AttachmentStore) (Message, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	size := len(message.Content)
+	if size <= maxSize {
+		return message, nil
+	}
+
+	data := make(map[string]interface{}, len(message.Data)+2)
+	for k, v := range message.Data {
+		data[k] = v
+	}
+	data[MessageContentSize] = size
+	content := message.Content
+	message.Data = data
+
+	var err error
+	if store != nil {
+		var ref string
+		if ref, err = store.StoreAttachment(message, []byte(content)); err == nil {
+			data[MessageArtifactRef] = ref
+			message.Content = fmt.Sprintf("%s… [%d bytes in attachment %s]", cutUTF8(content, min(maxSize, maxMessagePreview)), size, ref)
+			return message, nil
+		}
+		err = fmt.Errorf("failed to store content of message %s: %w", message.ID, err)
+	}
+	data[MessageTruncated] = true
+	message.Content = fmt.Sprintf("%s… [truncated from %d bytes]", cutUTF8(content, maxSize), size)
+	return message, err
+}
+
+// MessageContent returns a message's full content, loading it from the store when it was spilled
+func MessageContent(message Message, store AttachmentStore) (string, error) {
+	ref, _ := message.Data[MessageArtifactRef].(string)
+	if ref == "" {
+		return message.Content, nil
+	}
+	if store == nil {
+		return "", fmt.Errorf("message %s has an attachment but no store is available", message.ID)
+	}
+	content, err := store.LoadAttachment(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to load attachment of message %s: %w", message.ID, err)
+	}
+	return string(content), nil
+}
+
+// cutUTF8 returns at most n bytes of s without splitting a character
+func cutUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,77 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAttachments is an AttachmentStore keeping attachments in memory
+type memoryAttachments struct {
+	stored map[string][]byte
+	err    error
+}
+
+func (m *memoryAttachments) StoreAttachment(message Message, content []byte) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	if m.stored == nil {
+		m.stored = make(map[string][]byte)
+	}
+	ref := fmt.Sprintf("attachments/%s.txt", message.ID)
+	m.stored[ref] = content
+	return ref, nil
+}
+
+func (m *memoryAttachments) LoadAttachment(ref string) ([]byte, error) {
+	content, ok := m.stored[ref]
+	if !ok {
+		return nil, errors.New("no such attachment")
+	}
+	return content, nil
+}
+
+func TestSpillMessage(t *testing.T) {
+	message := Message{ID: "msg-1", From: "a", To: "b", Content: strings.Repeat("x", 100), Data: map[string]interface{}{"task_id": "task-1"}}
+
+	small, err := SpillMessage(message, 100, nil)
+	require.NoError(t, err)
+	assert.Equal(t, message, small, "content within the limit is routed as it is")
+
+	store := &memoryAttachments{}
+	spilled, err := SpillMessage(message, 10, store)
+	require.NoError(t, err)
+	assert.Equal(t, "xxxxxxxxxx… [100 bytes in attachment attachments/msg-1.txt]", spilled.Content)
+	assert.Equal(t, "attachments/msg-1.txt", spilled.Data[MessageArtifactRef])
+	assert.Equal(t, 100, spilled.Data[MessageContentSize])
+	assert.Equal(t, "task-1", spilled.Data["task_id"])
+	assert.NotContains(t, message.Data, MessageArtifactRef, "the original message is left alone")
+	content, err := MessageContent(spilled, store)
+	require.NoError(t, err)
+	assert.Equal(t, message.Content, content)
+
+	truncated, err := SpillMessage(message, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "xxxxxxxxxx… [truncated from 100 bytes]", truncated.Content)
+	assert.Equal(t, true, truncated.Data[MessageTruncated])
+
+	store.err = errors.New("disk full")
+	truncated, err = SpillMessage(message, 10, store)
+	assert.EqualError(t, err, "failed to store content of message msg-1: disk full")
+	assert.Equal(t, true, truncated.Data[MessageTruncated])
+
+	_, err = MessageContent(spilled, nil)
+	assert.Error(t, err)
+}
+
+func TestSpillMessage_KeepsCharactersWhole(t *testing.T) {
+	message := Message{ID: "msg-1", From: "a", To: "b", Content: "héllo wörld"}
+	truncated, err := SpillMessage(message, 2, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(truncated.Content, "h… "), truncated.Content)
+}
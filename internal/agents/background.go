@@ -0,0 +1,108 @@
+package agents
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// backgroundWaitDelay is how long a background command's output is still read once it has
+// exited, in case processes it started keep its output open
+const backgroundWaitDelay = time.Second
+
+// BackgroundCommand is a command started with StartCommand that keeps running, its output
+// captured, until it exits or is stopped
+type BackgroundCommand struct {
+	cmd     *exec.Cmd
+	capture *OutputCapture
+	done    chan struct{}
+	err     error
+}
+
+// StartCommand starts script the way Command builds it and returns without waiting for it to
+// finish. Its stdout and stderr are captured up to the task's MaxOutputSize and streamed to the
+// task's Output sink as they come. The command leads a process group of its own where the
+// platform allows, so stopping it also stops the processes it starts; cancelling ctx kills it
+// outright.
+func (t Task) StartCommand(ctx context.Context, script string) (*BackgroundCommand, error) {
+	cmd, err := t.Command(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	capture := NewOutputCapture(t.MaxOutputSize, t.Output)
+	cmd.Stdout, cmd.Stderr = capture.Stdout, capture.Stderr
+	cmd.WaitDelay = backgroundWaitDelay
+	startInGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	b := &BackgroundCommand{cmd: cmd, capture: capture, done: make(chan struct{})}
+	go func() {
+		b.err = cmd.Wait()
+		capture.Close()
+		close(b.done)
+	}()
+	return b, nil
+}
+
+// Pid returns the process ID of the command
+func (b *BackgroundCommand) Pid() int {
+	return b.cmd.Process.Pid
+}
+
+// Done is closed once the command has exited
+func (b *BackgroundCommand) Done() <-chan struct{} {
+	return b.done
+}
+
+// Exited reports whether the command has exited
+func (b *BackgroundCommand) Exited() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns how the command exited, or nil while it is running
+func (b *BackgroundCommand) Err() error {
+	if !b.Exited() {
+		return nil
+	}
+	return b.err
+}
+
+// Output returns what the command has written so far, with its resource usage once it has exited
+func (b *BackgroundCommand) Output() CommandOutput {
+	output := b.capture.Output()
+	if b.Exited() {
+		output.Usage = ProcessUsage(b.cmd.ProcessState)
+	}
+	return output
+}
+
+// Stop asks the command and the processes it started to exit and waits for them, killing them
+// once grace has passed. It reports whether they had to be killed; a command that already
+// exited is left as it is.
+func (b *BackgroundCommand) Stop(grace time.Duration) bool {
+	if b.Exited() {
+		return false
+	}
+	if err := interruptGroup(b.cmd); err != nil {
+		_ = killGroup(b.cmd)
+		<-b.done
+		return true
+	}
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-b.done:
+		return false
+	case <-timer.C:
+		_ = killGroup(b.cmd)
+		<-b.done
+		return true
+	}
+}
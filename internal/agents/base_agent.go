@@ -18,6 +18,8 @@ type BaseAgent struct {
 	receivedMsgs    []Message
 	router          *MessageRouter
 	startTime       time.Time
+	sent            int
+	received        int
 }
 
 // NewBaseAgent creates a new base agent
@@ -128,7 +130,13 @@ func (b *BaseAgent) SendMessage(to string, message Message) error {
 		message.Timestamp = time.Now()
 	}
 	
-	return router.RouteMessage(message)
+	if err := router.RouteMessage(message); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.sent++
+	b.mu.Unlock()
+	return nil
 }
 
 // ReceiveMessage receives a message from another agent
@@ -138,6 +146,7 @@ func (b *BaseAgent) ReceiveMessage(message Message) error {
 	
 	// Store the message for later processing/retrieval
 	b.receivedMsgs = append(b.receivedMsgs, message)
+	b.received++
 	
 	return nil
 }
@@ -171,6 +180,14 @@ func (b *BaseAgent) GetReceivedMessages() []Message {
 	return messages
 }
 
+// MessageCounts returns how many messages the agent has sent and received; clearing its
+// received messages does not reset them
+func (b *BaseAgent) MessageCounts() (sent, received int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sent, b.received
+}
+
 // SetStatus sets the agent status (for internal use)
 func (b *BaseAgent) SetStatus(status AgentStatus) {
 	b.mu.Lock()
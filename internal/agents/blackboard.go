@@ -0,0 +1,90 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrNoBlackboard is returned when an agent shares or reads a finding but the task has no blackboard
+var ErrNoBlackboard = errors.New("no blackboard is available for sharing findings")
+
+// Finding is an intermediate result an agent shares with later steps and other agents
+type Finding struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value,omitempty"`
+	Step      string      `json:"step,omitempty"`
+	Agent     string      `json:"agent,omitempty"`
+	Artifacts []Artifact  `json:"artifacts,omitempty"`
+}
+
+// Blackboard holds the findings agents working on a task share instead of passing them through
+// chat messages. Publishing a key again replaces its finding.
+type Blackboard interface {
+	Publish(ctx context.Context, finding Finding) error
+	Lookup(ctx context.Context, key string) (Finding, bool, error)
+	Findings(ctx context.Context) ([]Finding, error)
+}
+
+// Publish shares a finding from the agent working on the task, returning ErrNoBlackboard
+// when the task was not given a blackboard
+func (t Task) Publish(ctx context.Context, agentID, key string, value interface{}, artifacts ...Artifact) error {
+	if t.Blackboard == nil {
+		return ErrNoBlackboard
+	}
+	return t.Blackboard.Publish(ctx, Finding{Key: key, Value: value, Step: t.ID, Agent: agentID, Artifacts: artifacts})
+}
+
+// Lookup returns the finding published under key by an earlier step or another agent
+func (t Task) Lookup(ctx context.Context, key string) (Finding, bool, error) {
+	if t.Blackboard == nil {
+		return Finding{}, false, ErrNoBlackboard
+	}
+	return t.Blackboard.Lookup(ctx, key)
+}
+
+// MemoryBlackboard is a Blackboard that keeps findings in memory, in the order they were first published
+type MemoryBlackboard struct {
+	mu       sync.RWMutex
+	findings []Finding
+}
+
+// NewMemoryBlackboard creates an empty in-memory blackboard
+func NewMemoryBlackboard() *MemoryBlackboard {
+	return &MemoryBlackboard{}
+}
+
+// Publish stores a finding, replacing any finding with the same key
+func (b *MemoryBlackboard) Publish(ctx context.Context, finding Finding) error {
+	if finding.Key == "" {
+		return errors.New("finding key cannot be empty")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := slices.IndexFunc(b.findings, func(f Finding) bool { return f.Key == finding.Key }); i >= 0 {
+		b.findings[i] = finding
+		return nil
+	}
+	b.findings = append(b.findings, finding)
+	return nil
+}
+
+// Lookup returns the finding published under key
+func (b *MemoryBlackboard) Lookup(ctx context.Context, key string) (Finding, bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, finding := range b.findings {
+		if finding.Key == key {
+			return finding, true, nil
+		}
+	}
+	return Finding{}, false, nil
+}
+
+// Findings returns every finding on the blackboard
+func (b *MemoryBlackboard) Findings(ctx context.Context) ([]Finding, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return slices.Clone(b.findings), nil
+}
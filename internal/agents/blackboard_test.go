@@ -0,0 +1,44 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_PublishAndLookup(t *testing.T) {
+	ctx := context.Background()
+	task := Task{ID: "step-1"}
+	assert.ErrorIs(t, task.Publish(ctx, "research-001", "endpoints", []string{"/health"}), ErrNoBlackboard)
+	_, _, err := task.Lookup(ctx, "endpoints")
+	assert.ErrorIs(t, err, ErrNoBlackboard)
+
+	task.Blackboard = NewMemoryBlackboard()
+	report := Artifact{Name: "endpoints.md", Kind: ArtifactKindReport, Content: []byte("# Endpoints\n")}
+	require.NoError(t, task.Publish(ctx, "research-001", "endpoints", []string{"/health"}, report))
+
+	finding, ok, err := Task{ID: "step-2", Blackboard: task.Blackboard}.Lookup(ctx, "endpoints")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, Finding{Key: "endpoints", Value: []string{"/health"}, Step: "step-1", Agent: "research-001", Artifacts: []Artifact{report}}, finding)
+}
+
+func TestMemoryBlackboard(t *testing.T) {
+	ctx := context.Background()
+	board := NewMemoryBlackboard()
+	assert.EqualError(t, board.Publish(ctx, Finding{}), "finding key cannot be empty")
+
+	require.NoError(t, board.Publish(ctx, Finding{Key: "a", Value: 1}))
+	require.NoError(t, board.Publish(ctx, Finding{Key: "b", Value: 2}))
+	require.NoError(t, board.Publish(ctx, Finding{Key: "a", Value: 3}))
+
+	findings, err := board.Findings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{{Key: "a", Value: 3}, {Key: "b", Value: 2}}, findings)
+
+	_, ok, err := board.Lookup(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,125 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ResultCitations is the result data entry research steps list their sources under
+const ResultCitations = "citations"
+
+// trackingParams are query parameters that identify how a link was shared rather than what
+// it points to, so sources differing only in them are the same source
+var trackingParams = []string{"fbclid", "gclid", "mc_cid", "mc_eid", "ref", "ref_src", "source"}
+
+// Citation is a source a research finding rests on
+type Citation struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	RetrievedAt time.Time `json:"retrieved_at"`
+	// Confidence is how far the finding can be trusted on this source, from 0 to 1
+	Confidence float64 `json:"confidence"`
+}
+
+// Validate checks the citation names an http or https URL and a confidence between 0 and 1
+func (c Citation) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("source URL %q must be an http or https URL", c.URL)
+	}
+	if c.Confidence < 0 || c.Confidence > 1 {
+		return fmt.Errorf("confidence %v of %s must be between 0 and 1", c.Confidence, c.URL)
+	}
+	return nil
+}
+
+// String describes the citation as a line of a report
+func (c Citation) String() string {
+	s := c.URL
+	if c.Title != "" {
+		s = fmt.Sprintf("%s (%s)", c.Title, c.URL)
+	}
+	return fmt.Sprintf("%s, retrieved %s, confidence %.2f", s, c.RetrievedAt.UTC().Format(time.DateOnly), c.Confidence)
+}
+
+// SourceKey returns the form of a URL that near-identical sources share: without the scheme,
+// a leading www., the default port, the fragment, a trailing slash or tracking parameters,
+// and with the query sorted
+func SourceKey(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(strings.TrimSpace(rawURL))
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(key, "utm_") || slices.Contains(trackingParams, key) {
+			query.Del(key)
+		}
+	}
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
+
+// DedupeCitations merges near-identical sources, keeping the first-cited order. Of merged
+// sources the most confident is kept, taking the earlier retrieval time and a title from the
+// others when it has none.
+func DedupeCitations(citations []Citation) []Citation {
+	deduped := make([]Citation, 0, len(citations))
+	index := make(map[string]int, len(citations))
+	for _, citation := range citations {
+		key := SourceKey(citation.URL)
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(deduped)
+			deduped = append(deduped, citation)
+			continue
+		}
+		kept := deduped[i]
+		if citation.Confidence > kept.Confidence {
+			kept.URL, kept.Confidence = citation.URL, citation.Confidence
+			if citation.Title != "" {
+				kept.Title = citation.Title
+			}
+		}
+		if kept.Title == "" {
+			kept.Title = citation.Title
+		}
+		if !citation.RetrievedAt.IsZero() && (kept.RetrievedAt.IsZero() || citation.RetrievedAt.Before(kept.RetrievedAt)) {
+			kept.RetrievedAt = citation.RetrievedAt
+		}
+		deduped[i] = kept
+	}
+	return deduped
+}
+
+// CitationsFrom reads the citations a step listed in its result data, whether they are held
+// as citations or were decoded from a stored result
+func CitationsFrom(data map[string]interface{}) []Citation {
+	switch v := data[ResultCitations].(type) {
+	case nil:
+		return nil
+	case []Citation:
+		return v
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var citations []Citation
+		if json.Unmarshal(encoded, &citations) != nil {
+			return nil
+		}
+		return citations
+	}
+}
@@ -0,0 +1,43 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceKey(t *testing.T) {
+	same := []string{
+		"https://go.dev/blog/errors",
+		"http://www.go.dev/blog/errors/",
+		"https://GO.dev:443/blog/errors#wrapping",
+		"https://go.dev/blog/errors?utm_source=newsletter&ref=home",
+	}
+	for _, u := range same {
+		assert.Equal(t, "go.dev/blog/errors", SourceKey(u), u)
+	}
+	assert.Equal(t, "go.dev/search?a=1&q=errors", SourceKey("https://go.dev/search?q=errors&a=1"))
+	assert.NotEqual(t, SourceKey("https://go.dev/blog/errors"), SourceKey("https://go.dev:8080/blog/errors"))
+}
+
+func TestDedupeCitations(t *testing.T) {
+	early := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	citations := DedupeCitations([]Citation{
+		{URL: "https://go.dev/blog/errors", RetrievedAt: late, Confidence: 0.6},
+		{URL: "https://pkg.go.dev/errors", Title: "errors package", RetrievedAt: late, Confidence: 0.8},
+		{URL: "http://www.go.dev/blog/errors/", Title: "Working with Errors", RetrievedAt: early, Confidence: 0.9},
+		{URL: "https://go.dev/blog/errors?utm_medium=social", RetrievedAt: late, Confidence: 0.1},
+	})
+	assert.Equal(t, []Citation{
+		{URL: "http://www.go.dev/blog/errors/", Title: "Working with Errors", RetrievedAt: early, Confidence: 0.9},
+		{URL: "https://pkg.go.dev/errors", Title: "errors package", RetrievedAt: late, Confidence: 0.8},
+	}, citations)
+}
+
+func TestCitation_Validate(t *testing.T) {
+	assert.NoError(t, Citation{URL: "https://go.dev", Confidence: 1}.Validate())
+	assert.EqualError(t, Citation{URL: "go.dev/blog", Confidence: 0.5}.Validate(), `source URL "go.dev/blog" must be an http or https URL`)
+	assert.EqualError(t, Citation{URL: "https://go.dev", Confidence: 1.5}.Validate(), "confidence 1.5 of https://go.dev must be between 0 and 1")
+}
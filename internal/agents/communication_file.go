@@ -0,0 +1,232 @@
+package agents
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Retention bounds how many logged messages are kept and for how long; zero values keep everything
+type Retention struct {
+	MaxMessages int
+	MaxAge      time.Duration
+}
+
+// apply drops the messages retention does not keep, returning the rest
+func (r Retention) apply(messages []MessageLog, now time.Time) []MessageLog {
+	if r.MaxAge > 0 {
+		cutoff := now.Add(-r.MaxAge)
+		kept := messages[:0]
+		for _, log := range messages {
+			if !log.Indexed.Before(cutoff) {
+				kept = append(kept, log)
+			}
+		}
+		messages = kept
+	}
+	if r.MaxMessages > 0 && len(messages) > r.MaxMessages {
+		messages = messages[len(messages)-r.MaxMessages:]
+	}
+	return messages
+}
+
+// FileCommunicationLogger is a CommunicationLogger persisted as JSON lines, so the message log
+// survives restarts. Messages are appended as they are logged; when retention drops any, the
+// file is rewritten with the messages that remain.
+type FileCommunicationLogger struct {
+	*MemoryCommunicationLogger
+	path      string
+	retention Retention
+
+	fileMu  sync.Mutex
+	onError func(err error)
+}
+
+// NewFileCommunicationLogger opens the message log at path, loading the messages retention keeps
+func NewFileCommunicationLogger(path string, retention Retention) (*FileCommunicationLogger, error) {
+	messages, err := LoadMessageLog(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &FileCommunicationLogger{
+		MemoryCommunicationLogger: NewMemoryCommunicationLogger(),
+		path:                      path,
+		retention:                 retention,
+	}
+	kept := retention.apply(messages, time.Now())
+	l.messages = append(l.messages, kept...)
+	if len(kept) < len(messages) {
+		if err := l.rewrite(kept); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// SetErrorHandler sets the function told about messages that could not be persisted
+func (l *FileCommunicationLogger) SetErrorHandler(handler func(err error)) {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	l.onError = handler
+}
+
+// LogMessage logs the message, appends it to the file and applies retention
+func (l *FileCommunicationLogger) LogMessage(from, to string, message Message) {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+
+	l.MemoryCommunicationLogger.LogMessage(from, to, message)
+	l.mu.Lock()
+	logged := l.messages[len(l.messages)-1]
+	before := len(l.messages)
+	l.messages = l.retention.apply(l.messages, time.Now())
+	pruned := len(l.messages) < before
+	kept := append([]MessageLog(nil), l.messages...)
+	l.mu.Unlock()
+
+	var err error
+	if pruned {
+		err = l.rewrite(kept)
+	} else {
+		err = l.append(logged)
+	}
+	if err != nil && l.onError != nil {
+		l.onError(err)
+	}
+}
+
+// Clear removes all logged messages, from memory and the file
+func (l *FileCommunicationLogger) Clear() error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if err := l.MemoryCommunicationLogger.Clear(); err != nil {
+		return err
+	}
+	return l.rewrite(nil)
+}
+
+// append adds one message to the end of the file
+func (l *FileCommunicationLogger) append(log MessageLog) error {
+	line, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create message log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open message log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write message log: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the file with the given messages, writing to a temporary file first
+func (l *FileCommunicationLogger) rewrite(messages []MessageLog) error {
+	var buf bytes.Buffer
+	for _, log := range messages {
+		line, err := json.Marshal(log)
+		if err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create message log directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write message log: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace message log: %w", err)
+	}
+	return nil
+}
+
+// LoadMessageLog reads the messages persisted at path; a missing file holds no messages
+func LoadMessageLog(path string) ([]MessageLog, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message log: %w", err)
+	}
+
+	var messages []MessageLog
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var log MessageLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			return nil, fmt.Errorf("failed to parse message log line %d: %w", line, err)
+		}
+		messages = append(messages, log)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read message log: %w", err)
+	}
+	return messages, nil
+}
+
+// PairVolume counts the messages one agent sent another during a period
+type PairVolume struct {
+	Period time.Time `json:"period"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Count  int       `json:"count"`
+}
+
+// MessageVolume counts messages per sender and recipient in periods of the given length,
+// ordered by period and then by volume
+func MessageVolume(messages []MessageLog, period time.Duration) []PairVolume {
+	if period <= 0 {
+		period = 24 * time.Hour
+	}
+	type key struct {
+		period   time.Time
+		from, to string
+	}
+	counts := make(map[key]int)
+	for _, log := range messages {
+		at := log.Message.Timestamp
+		if at.IsZero() {
+			at = log.Indexed
+		}
+		counts[key{at.UTC().Truncate(period), log.Message.From, log.Message.To}]++
+	}
+
+	volumes := make([]PairVolume, 0, len(counts))
+	for k, count := range counts {
+		volumes = append(volumes, PairVolume{Period: k.period, From: k.from, To: k.to, Count: count})
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		a, b := volumes[i], volumes[j]
+		switch {
+		case !a.Period.Equal(b.Period):
+			return a.Period.Before(b.Period)
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		case a.From != b.From:
+			return a.From < b.From
+		default:
+			return a.To < b.To
+		}
+	})
+	return volumes
+}
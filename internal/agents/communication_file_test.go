@@ -0,0 +1,114 @@
+package agents
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(id, from, to string, at time.Time) Message {
+	return Message{ID: id, From: from, To: to, Content: "hello " + id, Type: MessageTypeText, Timestamp: at}
+}
+
+func TestFileCommunicationLogger_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	logger, err := NewFileCommunicationLogger(path, Retention{})
+	require.NoError(t, err)
+
+	logger.LogMessage("agent-1", "agent-2", testMessage("msg-1", "agent-1", "agent-2", time.Now()))
+	logger.LogMessage("agent-2", "agent-1", testMessage("msg-2", "agent-2", "agent-1", time.Now()))
+
+	reopened, err := NewFileCommunicationLogger(path, Retention{})
+	require.NoError(t, err)
+	messages := reopened.GetAllMessages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "msg-1", messages[0].Message.ID)
+	assert.Equal(t, "msg-2", messages[1].Message.ID)
+	assert.Contains(t, messages[1].Formatted, "agent-2 -> agent-1:")
+
+	require.NoError(t, reopened.Clear())
+	loaded, err := LoadMessageLog(path)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+}
+
+func TestFileCommunicationLogger_MaxMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	logger, err := NewFileCommunicationLogger(path, Retention{MaxMessages: 2})
+	require.NoError(t, err)
+
+	for _, id := range []string{"msg-1", "msg-2", "msg-3"} {
+		logger.LogMessage("agent-1", "agent-2", testMessage(id, "agent-1", "agent-2", time.Now()))
+	}
+
+	messages := logger.GetAllMessages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "msg-2", messages[0].Message.ID)
+
+	loaded, err := LoadMessageLog(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2, "the file is rewritten when messages are pruned")
+	assert.Equal(t, "msg-3", loaded[1].Message.ID)
+}
+
+func TestFileCommunicationLogger_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	var data []byte
+	for _, log := range []MessageLog{
+		{Message: testMessage("old", "agent-1", "agent-2", time.Now().Add(-48*time.Hour)), Indexed: time.Now().Add(-48 * time.Hour)},
+		{Message: testMessage("new", "agent-1", "agent-2", time.Now()), Indexed: time.Now()},
+	} {
+		line, err := json.Marshal(log)
+		require.NoError(t, err)
+		data = append(append(data, line...), '\n')
+	}
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	logger, err := NewFileCommunicationLogger(path, Retention{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	messages := logger.GetAllMessages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "new", messages[0].Message.ID)
+
+	loaded, err := LoadMessageLog(path)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 1, "expired messages are removed from the file on open")
+}
+
+func TestLoadMessageLog(t *testing.T) {
+	dir := t.TempDir()
+	messages, err := LoadMessageLog(filepath.Join(dir, "missing.jsonl"))
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	path := filepath.Join(dir, "broken.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{not json}\n"), 0o644))
+	_, err = LoadMessageLog(path)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestMessageVolume(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	messages := []MessageLog{
+		{Message: testMessage("1", "captain", "file-001", day.Add(time.Hour))},
+		{Message: testMessage("2", "captain", "file-001", day.Add(2*time.Hour))},
+		{Message: testMessage("3", "file-001", "captain", day.Add(3*time.Hour))},
+		{Message: testMessage("4", "captain", "file-001", day.Add(25*time.Hour))},
+		{Message: Message{From: "net-001", To: "captain"}, Indexed: day.Add(26 * time.Hour)},
+	}
+
+	assert.Equal(t, []PairVolume{
+		{Period: day, From: "captain", To: "file-001", Count: 2},
+		{Period: day, From: "file-001", To: "captain", Count: 1},
+		{Period: day.Add(24 * time.Hour), From: "captain", To: "file-001", Count: 1},
+		{Period: day.Add(24 * time.Hour), From: "net-001", To: "captain", Count: 1},
+	}, MessageVolume(messages, 0))
+
+	hourly := MessageVolume(messages, time.Hour)
+	assert.Len(t, hourly, 5)
+}
@@ -0,0 +1,159 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultContainerMountPath is where the workspace is mounted inside the container
+const DefaultContainerMountPath = "/workspace"
+
+// DefaultContainerNetwork is the network policy of containers that do not set one
+const DefaultContainerNetwork = "none"
+
+// ContainerSpec describes the container a task's commands run in
+type ContainerSpec struct {
+	Runtime   string `json:"runtime,omitempty"`
+	Image     string `json:"image"`
+	Workspace string `json:"workspace,omitempty"`
+	MountPath string `json:"mount_path,omitempty"`
+	CPUs      string `json:"cpus,omitempty"`
+	Memory    string `json:"memory,omitempty"`
+	PidsLimit int    `json:"pids_limit,omitempty"`
+	Network   string `json:"network,omitempty"`
+}
+
+// DetectContainerRuntime returns the installed container runtime, preferring docker over podman
+func DetectContainerRuntime() (string, error) {
+	for _, runtime := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(runtime); err == nil {
+			return runtime, nil
+		}
+	}
+	return "", fmt.Errorf("no container runtime found (install docker or podman)")
+}
+
+// LookupContainerShell returns the shell with the given name as it runs inside a Linux
+// container, or sh when name is empty
+func LookupContainerShell(name string) (Shell, error) {
+	if name == "" {
+		return knownShells["sh"], nil
+	}
+	shell, ok := knownShells[strings.ToLower(name)]
+	if !ok {
+		return Shell{}, fmt.Errorf("unknown shell: %s", name)
+	}
+	if windowsOnlyShells[shell.Name] {
+		return Shell{}, fmt.Errorf("shell %s is not available in containers", shell.Name)
+	}
+	return shell, nil
+}
+
+// Args returns the runtime arguments that run script through shell in the container, in
+// workdir and with env set. A relative workdir is resolved against the mounted workspace.
+func (s ContainerSpec) Args(shell Shell, script, workdir string, env map[string]string) ([]string, error) {
+	if s.Image == "" {
+		return nil, fmt.Errorf("container execution requires an image")
+	}
+	workspace, err := s.workspace()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := s.containerDir(workspace, workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	network := s.Network
+	if network == "" {
+		network = DefaultContainerNetwork
+	}
+	args := []string{"run", "--rm", "-i", "--network", network}
+	if s.CPUs != "" {
+		args = append(args, "--cpus", s.CPUs)
+	}
+	if s.Memory != "" {
+		args = append(args, "--memory", s.Memory)
+	}
+	if s.PidsLimit > 0 {
+		args = append(args, "--pids-limit", fmt.Sprint(s.PidsLimit))
+	}
+	args = append(args, "-v", workspace+":"+s.mountPath(), "-w", dir)
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-e", name+"="+env[name])
+	}
+
+	args = append(append(append(args, s.Image, shell.Program), shell.Args...), script)
+	return args, nil
+}
+
+// Command builds a process that runs script through shell in the container
+func (s ContainerSpec) Command(ctx context.Context, shell Shell, script, workdir string, env map[string]string) (*exec.Cmd, error) {
+	runtime := s.Runtime
+	if runtime == "" {
+		detected, err := DetectContainerRuntime()
+		if err != nil {
+			return nil, err
+		}
+		runtime = detected
+	}
+	args, err := s.Args(shell, script, workdir, env)
+	if err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, runtime, args...), nil
+}
+
+// mountPath returns where the workspace is mounted in the container
+func (s ContainerSpec) mountPath() string {
+	if s.MountPath == "" {
+		return DefaultContainerMountPath
+	}
+	return s.MountPath
+}
+
+// workspace returns the absolute host directory mounted into the container
+func (s ContainerSpec) workspace() (string, error) {
+	workspace := s.Workspace
+	if workspace == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine the container workspace: %w", err)
+		}
+		workspace = dir
+	}
+	abs, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the container workspace: %w", err)
+	}
+	return abs, nil
+}
+
+// containerDir maps a task's working directory on the host to its path in the container;
+// directories outside the workspace are not visible to the container
+func (s ContainerSpec) containerDir(workspace, workdir string) (string, error) {
+	if workdir == "" {
+		return s.mountPath(), nil
+	}
+	hostDir := workdir
+	if !filepath.IsAbs(hostDir) {
+		hostDir = filepath.Join(workspace, hostDir)
+	}
+	rel, err := filepath.Rel(workspace, hostDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("workdir %s is outside the container workspace %s", workdir, workspace)
+	}
+	return path.Join(s.mountPath(), filepath.ToSlash(rel)), nil
+}
@@ -0,0 +1,99 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerSpec_Args(t *testing.T) {
+	workspace := t.TempDir()
+	spec := ContainerSpec{Image: "golang:1.23", Workspace: workspace, CPUs: "2", Memory: "1g", PidsLimit: 128}
+	shell, err := LookupContainerShell("bash")
+	require.NoError(t, err)
+
+	args, err := spec.Args(shell, "go test ./...", "cmd/capn", map[string]string{"STAGE": "test", "CGO_ENABLED": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"run", "--rm", "-i", "--network", "none",
+		"--cpus", "2", "--memory", "1g", "--pids-limit", "128",
+		"-v", workspace + ":/workspace", "-w", "/workspace/cmd/capn",
+		"-e", "CGO_ENABLED=0", "-e", "STAGE=test",
+		"golang:1.23", "bash", "-c", "go test ./...",
+	}, args)
+}
+
+func TestContainerSpec_Workdir(t *testing.T) {
+	workspace := t.TempDir()
+	spec := ContainerSpec{Image: "alpine", Workspace: workspace, MountPath: "/src", Network: "bridge"}
+	shell, err := LookupContainerShell("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		workdir string
+		want    string
+		wantErr string
+	}{
+		{name: "workspace root", workdir: "", want: "/src"},
+		{name: "absolute inside workspace", workdir: filepath.Join(workspace, "docs"), want: "/src/docs"},
+		{name: "relative", workdir: filepath.Join("a", "b"), want: "/src/a/b"},
+		{name: "outside workspace", workdir: filepath.Join(workspace, ".."), wantErr: "is outside the container workspace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := spec.Args(shell, "ls", tt.workdir, nil)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"run", "--rm", "-i", "--network", "bridge", "-v", workspace + ":/src", "-w", tt.want, "alpine", "sh", "-c", "ls"}, args)
+		})
+	}
+}
+
+func TestContainerSpec_DefaultWorkspace(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	args, err := ContainerSpec{Image: "alpine"}.Args(knownShells["sh"], "ls", "", nil)
+	require.NoError(t, err)
+	assert.Contains(t, args, dir+":/workspace")
+
+	_, err = ContainerSpec{}.Args(knownShells["sh"], "ls", "", nil)
+	assert.EqualError(t, err, "container execution requires an image")
+}
+
+func TestLookupContainerShell(t *testing.T) {
+	shell, err := LookupContainerShell("ZSH")
+	require.NoError(t, err)
+	assert.Equal(t, "zsh", shell.Program)
+
+	_, err = LookupContainerShell("cmd")
+	assert.EqualError(t, err, "shell cmd is not available in containers")
+	_, err = LookupContainerShell("fish-ish")
+	assert.EqualError(t, err, "unknown shell: fish-ish")
+}
+
+func TestTask_CommandInContainer(t *testing.T) {
+	workspace := t.TempDir()
+	task := Task{
+		Workdir:   "build",
+		Env:       map[string]string{"STAGE": "test"},
+		Container: &ContainerSpec{Runtime: "podman", Image: "alpine", Workspace: workspace},
+	}
+
+	cmd, err := task.Command(context.Background(), "make")
+	require.NoError(t, err)
+	assert.Equal(t, "podman", filepath.Base(cmd.Args[0]))
+	assert.Equal(t, []string{"run", "--rm", "-i", "--network", "none", "-v", workspace + ":/workspace", "-w", "/workspace/build",
+		"-e", "STAGE=test", "alpine", "sh", "-c", "make"}, cmd.Args[1:])
+	assert.Empty(t, cmd.Dir)
+	assert.Nil(t, cmd.Env)
+}
@@ -0,0 +1,218 @@
+package crew
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// scriptedProvider replies to every completion with the same content and records requests
+type scriptedProvider struct {
+	reply    string
+	err      error
+	requests []captain.CompletionRequest
+}
+
+func (p *scriptedProvider) GenerateCompletion(ctx context.Context, req captain.CompletionRequest) (*captain.CompletionResponse, error) {
+	p.requests = append(p.requests, req)
+	if p.err != nil {
+		return nil, p.err
+	}
+	model := req.Model
+	if model == "" {
+		model = "default-model"
+	}
+	return &captain.CompletionResponse{Content: p.reply, Model: model, TokensUsed: 42}, nil
+}
+
+func (p *scriptedProvider) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func TestNewBrain(t *testing.T) {
+	provider := &scriptedProvider{}
+
+	brain, err := NewBrain(provider, agents.AgentTypeFile, config.CrewBrainConfig{Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, Toolsets[agents.AgentTypeFile], brain.Tools())
+
+	brain, err = NewBrain(provider, agents.AgentTypeNetwork, config.CrewBrainConfig{Tools: []string{"api_call"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api_call"}, brain.Tools())
+
+	_, err = NewBrain(provider, agents.AgentTypeFile, config.CrewBrainConfig{Tools: []string{"api_call"}})
+	assert.EqualError(t, err, `unknown file agent tool "api_call" (must be one of: file_analysis, file_read, file_write, file_search, file_watch)`)
+
+	_, err = NewBrain(provider, agents.AgentTypeCaptain, config.CrewBrainConfig{})
+	assert.EqualError(t, err, "unsupported crew agent type: captain")
+
+	_, err = NewBrain(nil, agents.AgentTypeFile, config.CrewBrainConfig{})
+	assert.EqualError(t, err, "crew brain requires an LLM provider")
+}
+
+func TestBrain_Think(t *testing.T) {
+	readTask := agents.Task{ID: "task-1", Type: "file_read", Description: "Read the config", Data: map[string]interface{}{"path": "app.yaml"}}
+	genericTask := agents.Task{ID: "task-2", Type: "general", Description: "Look at the repository", Data: map[string]interface{}{"path": "."}}
+
+	tests := []struct {
+		name     string
+		tools    []string
+		reply    string
+		task     agents.Task
+		wantTool string
+		wantErr  string
+		calls    int
+	}{
+		{
+			name:     "assigned operation is kept",
+			reply:    `{"tool": "file_search", "reasoning": "Read app.yaml and report its keys."}`,
+			task:     readTask,
+			wantTool: "file_read",
+			calls:    1,
+		},
+		{
+			name:     "tool chosen for a generic step",
+			reply:    "```json\n{\"tool\": \"file_analysis\", \"reasoning\": \"Survey the files.\"}\n```",
+			task:     genericTask,
+			wantTool: "file_analysis",
+			calls:    1,
+		},
+		{
+			name:    "assigned operation outside the allowed tools",
+			tools:   []string{"file_analysis"},
+			task:    readTask,
+			wantErr: `tool "file_read" is not allowed for file agents`,
+		},
+		{
+			name:    "chosen tool outside the allowed tools",
+			tools:   []string{"file_read"},
+			reply:   `{"tool": "file_write", "reasoning": "Rewrite everything."}`,
+			task:    genericTask,
+			wantErr: `tool "file_write" is not allowed for file agents`,
+			calls:   1,
+		},
+		{
+			name:    "no tool chosen",
+			reply:   "I would look around first.",
+			task:    genericTask,
+			wantErr: `no tool chosen for operation "general"`,
+			calls:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &scriptedProvider{reply: tt.reply}
+			brain, err := NewBrain(provider, agents.AgentTypeFile, config.CrewBrainConfig{Enabled: true, Tools: tt.tools})
+			require.NoError(t, err)
+
+			thought, err := brain.Think(context.Background(), tt.task)
+			assert.Len(t, provider.requests, tt.calls)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTool, thought.Tool)
+			assert.NotEmpty(t, thought.Reasoning)
+		})
+	}
+}
+
+func TestBrain_ThinkPrompt(t *testing.T) {
+	provider := &scriptedProvider{reply: `{"tool": "api_call", "reasoning": "GET the health endpoint."}`}
+	brain, err := NewBrain(provider, agents.AgentTypeNetwork, config.CrewBrainConfig{
+		Enabled:   true,
+		Model:     "gpt-4o-mini",
+		Prompt:    "You check service health.",
+		Tools:     []string{"api_call"},
+		MaxTokens: 100,
+	})
+	require.NoError(t, err)
+
+	thought, err := brain.Think(context.Background(), agents.Task{ID: "task-1", Type: "api_call", Description: "Check health", Data: map[string]interface{}{"url": "https://example.com/health"}})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", thought.Model)
+	assert.Equal(t, 42, thought.TokensUsed)
+	assert.Equal(t, "GET the health endpoint.", thought.Reasoning)
+
+	require.Len(t, provider.requests, 1)
+	req := provider.requests[0]
+	assert.Equal(t, "gpt-4o-mini", req.Model)
+	assert.Equal(t, 100, req.MaxTokens)
+	assert.Contains(t, req.Messages[0].Content, "You check service health.")
+	assert.Contains(t, req.Messages[0].Content, "You may only use these tools: api_call.")
+	assert.Contains(t, req.Messages[1].Content, "https://example.com/health")
+}
+
+func TestBrain_Execute(t *testing.T) {
+	provider := &scriptedProvider{reply: `{"tool": "documentation", "reasoning": "Write up the findings."}`}
+	factory := NewCrewAgentFactory()
+	factory.SetBrains(provider, map[string]config.CrewBrainConfig{"research": {Enabled: true, Model: "small-model"}})
+
+	agent, err := factory.CreateAgent("research-1", "ResearchAgent-1", agents.AgentTypeResearch)
+	require.NoError(t, err)
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "summary", Description: "Summarize retries", Data: map[string]interface{}{"topic": "retries"}})
+	require.True(t, result.Success, result.Output)
+	assert.Equal(t, "documentation", result.Data["operation"])
+	assert.Equal(t, "Write up the findings.", result.Data["reasoning"])
+	assert.Equal(t, "small-model", result.Data["model"])
+	assert.Len(t, result.Artifacts, 1)
+	require.NotNil(t, result.LLM, "the reasoning call is recorded with the result")
+	assert.Equal(t, "small-model", result.LLM.Model)
+	assert.Equal(t, 0.2, result.LLM.Temperature)
+	assert.Equal(t, 42, result.LLM.Tokens)
+
+	provider.err = fmt.Errorf("provider unavailable")
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-2", Type: "research", Data: map[string]interface{}{"topic": "retries"}})
+	assert.False(t, result.Success)
+	assert.Equal(t, "ResearchAgent-1 refused step: failed to reason about step: provider unavailable", result.Error)
+
+	// Types without an enabled brain act directly
+	agent, err = factory.CreateAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-3", Type: "file_read", Data: map[string]interface{}{"path": "a.txt"}})
+	assert.True(t, result.Success)
+	assert.NotContains(t, result.Data, "reasoning")
+}
+
+func TestCrewAgentFactory_Install(t *testing.T) {
+	provider := &scriptedProvider{reply: `{"tool": "file_read", "reasoning": "Read it."}`}
+	manager := agents.NewAgentManager()
+
+	factory := NewCrewAgentFactory()
+	factory.SetBrains(provider, map[string]config.CrewBrainConfig{"file": {Enabled: true}, "network": {Model: "unused"}})
+	require.NoError(t, factory.Install(manager))
+
+	agent, err := manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	assert.IsType(t, &FileAgent{}, agent)
+	agent, err = manager.SpawnAgent("network-1", "NetworkAgent-1", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	assert.IsType(t, &agents.BaseAgent{}, agent)
+
+	factory.SetBrains(provider, map[string]config.CrewBrainConfig{"file": {Enabled: true, Tools: []string{"upload"}}})
+	assert.ErrorContains(t, factory.Install(manager), `failed to create file agent brain: unknown file agent tool "upload"`)
+}
+
+func TestCrewAgentFactory_InstallConfigured(t *testing.T) {
+	manager := agents.NewAgentManager()
+	factory := NewCrewAgentFactory()
+	factory.SetAgentConfigs(config.FileAgentConfig{}, config.NetworkAgentConfig{AllowedHosts: []string{"api.github.com"}}, config.ResearchAgentConfig{})
+	require.NoError(t, factory.Install(manager))
+
+	agent, err := manager.SpawnAgent("network-1", "NetworkAgent-1", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	require.IsType(t, &NetworkAgent{}, agent, "configured types get crew agents without a brain")
+	result := agent.Execute(context.Background(), apiTask("https://example.com", "GET", nil))
+	assert.Contains(t, result.Output, "is not in crew.network.allowed_hosts")
+	agent, err = manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	assert.IsType(t, &agents.BaseAgent{}, agent)
+}
@@ -3,45 +3,195 @@ package crew
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/transport"
 )
 
 // CrewAgentFactory creates crew agents
-type CrewAgentFactory struct{}
+type CrewAgentFactory struct {
+	limits   map[string]config.CrewLimits
+	logger   *zap.Logger
+	provider captain.LLMProvider
+	brains   map[string]config.CrewBrainConfig
+	http     config.HTTPConfig
+	file     config.FileAgentConfig
+	network  config.NetworkAgentConfig
+	research config.ResearchAgentConfig
+}
 
 // NewCrewAgentFactory creates a new crew agent factory
 func NewCrewAgentFactory() *CrewAgentFactory {
 	return &CrewAgentFactory{}
 }
 
+// SetLimits sets the per-agent-type resource limits applied to created agents
+func (f *CrewAgentFactory) SetLimits(limits map[string]config.CrewLimits) {
+	f.limits = limits
+}
+
+// SetLogger sets the logger created agents report quota violations to
+func (f *CrewAgentFactory) SetLogger(logger *zap.Logger) {
+	f.logger = logger
+}
+
+// SetHTTP sets the proxies and certificate authorities created network agents use
+func (f *CrewAgentFactory) SetHTTP(settings config.HTTPConfig) {
+	f.http = settings
+}
+
+// SetAgentConfigs sets the crew.file, crew.network and crew.research settings created agents
+// are configured with
+func (f *CrewAgentFactory) SetAgentConfigs(file config.FileAgentConfig, network config.NetworkAgentConfig, research config.ResearchAgentConfig) {
+	f.file = file
+	f.network = network
+	f.research = research
+}
+
+// SetBrains gives created agents of the types whose brain is enabled an LLM to reason about
+// their steps with
+func (f *CrewAgentFactory) SetBrains(provider captain.LLMProvider, brains map[string]config.CrewBrainConfig) {
+	f.provider = provider
+	f.brains = brains
+}
+
+// Install registers the factory as the creator of each agent type whose brain is enabled or
+// whose settings are configured, leaving other types to the manager's defaults. It returns an
+// error for a brain that cannot be created.
+func (f *CrewAgentFactory) Install(manager *agents.AgentManager) error {
+	install := map[agents.AgentType]bool{
+		agents.AgentTypeFile:     !f.file.IsZero(),
+		agents.AgentTypeNetwork:  !f.network.IsZero(),
+		agents.AgentTypeResearch: !f.research.IsZero(),
+	}
+	for agentType, brain := range f.brains {
+		if !brain.Enabled {
+			continue
+		}
+		agentType := agents.AgentType(agentType)
+		if _, err := NewBrain(f.provider, agentType, brain); err != nil {
+			return fmt.Errorf("failed to create %s agent brain: %w", agentType, err)
+		}
+		install[agentType] = true
+	}
+	for agentType, ok := range install {
+		if !ok {
+			continue
+		}
+		agentType := agentType
+		manager.RegisterAgentType(agentType, func(id, name string) (agents.Agent, error) {
+			return f.CreateAgent(id, name, agentType)
+		})
+	}
+	return nil
+}
+
 // CreateAgent creates a crew agent of the specified type
 func (f *CrewAgentFactory) CreateAgent(id, name string, agentType agents.AgentType) (agents.Agent, error) {
+	var agent interface {
+		agents.Agent
+		SetLimits(limits config.CrewLimits)
+		SetLogger(logger *zap.Logger)
+		SetBrain(brain *Brain)
+	}
 	switch agentType {
 	case agents.AgentTypeFile:
-		return NewFileAgent(id, name), nil
+		file := NewFileAgent(id, name)
+		file.SetSandboxRoot(f.file.SandboxRoot)
+		agent = file
 	case agents.AgentTypeNetwork:
-		return NewNetworkAgent(id, name), nil
+		network := NewNetworkAgent(id, name)
+		client, err := transport.NewHTTPClient(f.http)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create network agent HTTP client: %w", err)
+		}
+		network.SetHTTPClient(client)
+		network.SetAllowedHosts(f.network.AllowedHosts)
+		agent = network
 	case agents.AgentTypeResearch:
-		return NewResearchAgent(id, name), nil
+		research := NewResearchAgent(id, name)
+		if f.research.SearchProvider != "" {
+			client, err := transport.NewHTTPClient(f.http)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create research agent HTTP client: %w", err)
+			}
+			research.SetSearch(f.research, client)
+		}
+		agent = research
 	default:
 		return nil, fmt.Errorf("unsupported crew agent type: %s", agentType)
 	}
+	agent.SetLimits(f.limits[string(agentType)])
+	agent.SetLogger(f.logger)
+	if brain := f.brains[string(agentType)]; brain.Enabled && f.provider != nil {
+		b, err := NewBrain(f.provider, agentType, brain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s agent brain: %w", agentType, err)
+		}
+		agent.SetBrain(b)
+	}
+	return agent, nil
 }
 
 // FileAgent handles file system operations
 type FileAgent struct {
 	*agents.BaseAgent
+	quota *quota
+	brain *Brain
+	clock common.Clock
+	// root confines the agent's paths to a directory when set
+	root string
 }
 
 // NewFileAgent creates a new file agent
 func NewFileAgent(id, name string) *FileAgent {
 	return &FileAgent{
 		BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeFile),
+		quota:     newQuota(),
+		clock:     common.SystemClock,
 	}
 }
 
+// SetClock sets the clock watch operations poll and time out with
+func (f *FileAgent) SetClock(clock common.Clock) {
+	f.clock = clock
+	f.quota.setClock(clock)
+}
+
+// SetSandboxRoot confines the agent's paths to root: relative paths resolve inside it and
+// paths leaving it are refused. An empty root leaves paths unrestricted.
+func (f *FileAgent) SetSandboxRoot(root string) {
+	if root != "" {
+		root = filepath.Clean(resolvePath(root))
+	}
+	f.root = root
+}
+
+// SetLimits sets the resource limits enforced by this agent
+func (f *FileAgent) SetLimits(limits config.CrewLimits) {
+	f.quota.setLimits(limits)
+}
+
+// SetLogger sets the logger quota violations are reported to
+func (f *FileAgent) SetLogger(logger *zap.Logger) {
+	f.quota.setLogger(logger)
+}
+
+// SetBrain sets the brain the agent reasons about steps with before acting; nil acts directly
+func (f *FileAgent) SetBrain(brain *Brain) {
+	f.brain = brain
+}
+
 // SetRouter sets the message router for this agent
 func (f *FileAgent) SetRouter(router *agents.MessageRouter) {
 	f.BaseAgent.SetRouter(router)
@@ -52,20 +202,35 @@ func (f *FileAgent) GetReceivedMessages() []agents.Message {
 	return f.BaseAgent.GetReceivedMessages()
 }
 
-// Execute executes file-related tasks
+// Execute executes file-related tasks, reasoning about them first when the agent has a brain
 func (f *FileAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	return f.brain.execute(ctx, f, task, f.act)
+}
+
+// act performs a file operation
+func (f *FileAgent) act(ctx context.Context, task agents.Task) agents.Result {
+	limits := f.quota.current()
+	if !f.quota.acquire() {
+		return f.quota.exceeded(f, task, QuotaConcurrentTasks, int64(limits.MaxConcurrentTasks), 0)
+	}
+	defer f.quota.release()
+
 	// Set status to busy during execution
 	f.BaseAgent.SetStatus(agents.AgentStatusBusy)
 	defer f.BaseAgent.SetStatus(agents.AgentStatusIdle)
 
 	startTime := time.Now()
 	var output string
+	var artifacts []agents.Artifact
+	var usage agents.ResourceUsage
 	success := true
 
-	// Extract task data
-	pathVal, ok := task.Data["path"]
-	path, okStr := pathVal.(string)
-	if !ok || !okStr || path == "" {
+	// Extract task data, asking the user for a missing path
+	path, _ := task.Data["path"].(string)
+	if path == "" {
+		path = clarify(ctx, task, f.ID(), fmt.Sprintf("Which path should I use for %q?", task.Description))
+	}
+	if path == "" {
 		return agents.Result{
 			TaskID:    task.ID,
 			Success:   false,
@@ -78,6 +243,19 @@ func (f *FileAgent) Execute(ctx context.Context, task agents.Task) agents.Result
 			},
 		}
 	}
+	path, err := f.resolve(task.Workdir, path)
+	if err != nil {
+		return agents.Result{
+			TaskID:    task.ID,
+			Success:   false,
+			Output:    "FileAgent error: " + err.Error(),
+			Timestamp: time.Now(),
+			Data: map[string]interface{}{
+				"agent_type": "file",
+				"operation":  task.Type,
+			},
+		}
+	}
 	patternVal, ok := task.Data["pattern"].(string)
 	var pattern string
 	if ok {
@@ -100,12 +278,25 @@ func (f *FileAgent) Execute(ctx context.Context, task agents.Task) agents.Result
 		output = fmt.Sprintf("FileAgent executed file operation: reading file %s", path)
 
 	case "file_write":
+		if task.ReadOnly {
+			return agents.ReadOnlyResult(task, agents.AgentTypeFile, "writing to file "+path)
+		}
+		if size := writeSize(task.Data); limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+			return f.quota.exceeded(f, task, QuotaFileSize, limits.MaxFileSize, size)
+		}
 		output = fmt.Sprintf("FileAgent executed file operation: writing to file %s", path)
+		if content, ok := task.Data["content"].(string); ok {
+			artifacts = append(artifacts, agents.Artifact{Name: filepath.Base(path), Kind: agents.ArtifactKindFile, Content: []byte(content)})
+		}
+		usage.FilesWritten++
 
 	case "file_search":
 		query, _ := task.Data["query"].(string)
 		output = fmt.Sprintf("FileAgent executed file operation: searching for '%s' in %s", query, path)
 
+	case "file_watch":
+		return f.watch(ctx, task, path, pattern)
+
 	default:
 		output = fmt.Sprintf("FileAgent executed file operation: %s", task.Description)
 	}
@@ -120,21 +311,70 @@ func (f *FileAgent) Execute(ctx context.Context, task agents.Task) agents.Result
 			"agent_type": "file",
 			"operation":  task.Type,
 		},
+		Artifacts: artifacts,
+		Resources: &usage,
 	}
 }
 
+// writeSize returns the number of bytes a file_write task will write
+func writeSize(data map[string]interface{}) int64 {
+	if content, ok := data["content"].(string); ok {
+		return int64(len(content))
+	}
+	size, _ := dataSize(data, "size")
+	return size
+}
+
 // NetworkAgent handles API interactions and web operations
 type NetworkAgent struct {
 	*agents.BaseAgent
+	quota  *quota
+	brain  *Brain
+	client *http.Client
+	// allowedHosts are the hosts the agent may reach; empty allows every host
+	allowedHosts []string
 }
 
 // NewNetworkAgent creates a new network agent
 func NewNetworkAgent(id, name string) *NetworkAgent {
 	return &NetworkAgent{
 		BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeNetwork),
+		quota:     newQuota(),
+		client:    http.DefaultClient,
 	}
 }
 
+// SetHTTPClient sets the client the agent's requests go through
+func (n *NetworkAgent) SetHTTPClient(client *http.Client) {
+	n.client = client
+}
+
+// SetAllowedHosts restricts the hosts the agent's requests may reach; "*.example.com" matches
+// any subdomain and an empty list allows every host
+func (n *NetworkAgent) SetAllowedHosts(hosts []string) {
+	n.allowedHosts = hosts
+}
+
+// HTTPClient returns the client the agent's requests go through
+func (n *NetworkAgent) HTTPClient() *http.Client {
+	return n.client
+}
+
+// SetLimits sets the resource limits enforced by this agent
+func (n *NetworkAgent) SetLimits(limits config.CrewLimits) {
+	n.quota.setLimits(limits)
+}
+
+// SetLogger sets the logger quota violations are reported to
+func (n *NetworkAgent) SetLogger(logger *zap.Logger) {
+	n.quota.setLogger(logger)
+}
+
+// SetBrain sets the brain the agent reasons about steps with before acting; nil acts directly
+func (n *NetworkAgent) SetBrain(brain *Brain) {
+	n.brain = brain
+}
+
 // SetRouter sets the message router for this agent
 func (n *NetworkAgent) SetRouter(router *agents.MessageRouter) {
 	n.BaseAgent.SetRouter(router)
@@ -145,8 +385,19 @@ func (n *NetworkAgent) GetReceivedMessages() []agents.Message {
 	return n.BaseAgent.GetReceivedMessages()
 }
 
-// Execute executes network-related tasks
+// Execute executes network-related tasks, reasoning about them first when the agent has a brain
 func (n *NetworkAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	return n.brain.execute(ctx, n, task, n.act)
+}
+
+// act performs a network operation
+func (n *NetworkAgent) act(ctx context.Context, task agents.Task) agents.Result {
+	limits := n.quota.current()
+	if !n.quota.acquire() {
+		return n.quota.exceeded(n, task, QuotaConcurrentTasks, int64(limits.MaxConcurrentTasks), 0)
+	}
+	defer n.quota.release()
+
 	// Set status to busy during execution
 	n.BaseAgent.SetStatus(agents.AgentStatusBusy)
 	defer n.BaseAgent.SetStatus(agents.AgentStatusIdle)
@@ -172,9 +423,45 @@ func (n *NetworkAgent) Execute(ctx context.Context, task agents.Task) agents.Res
 		}
 	}
 
+	// Read-only mode lets requests read remote services but not change them
+	if task.ReadOnly && (task.Type == "upload" || writeMethods[strings.ToUpper(method)]) {
+		return agents.ReadOnlyResult(task, agents.AgentTypeNetwork, fmt.Sprintf("%s request to %s", strings.ToUpper(method), url))
+	}
+
+	if host, ok := n.hostAllowed(url); !ok {
+		return agents.Result{
+			TaskID:    task.ID,
+			Success:   false,
+			Output:    fmt.Sprintf("NetworkAgent: host %q is not in crew.network.allowed_hosts", host),
+			Duration:  time.Since(startTime),
+			Timestamp: time.Now(),
+			Data: map[string]interface{}{
+				"agent_type": "network",
+				"operation":  task.Type,
+			},
+		}
+	}
+
+	// Downloads are buffered in memory, so refuse ones larger than the configured budget
+	if task.Type == "download" {
+		if size, ok := dataSize(task.Data, "size"); ok && limits.MaxDownloadBytes > 0 && size > limits.MaxDownloadBytes {
+			return n.quota.exceeded(n, task, QuotaDownloadBytes, limits.MaxDownloadBytes, size)
+		}
+	}
+	if !n.quota.allowRequest() {
+		return n.quota.exceeded(n, task, QuotaRequestsPerMinute, int64(limits.MaxRequestsPerMinute), 0)
+	}
+
+	// Checking or extracting from a response needs one, so the request is sent
+	if task.Type == "api_call" && checksResponse(task.Data) {
+		return n.call(ctx, task, method, url, limits.MaxDownloadBytes)
+	}
+
 	// Simulate network operation based on task type
 	var output string
 	var success bool = true
+	var artifacts []agents.Artifact
+	var usage agents.ResourceUsage
 
 	switch task.Type {
 	case "api_call":
@@ -187,6 +474,10 @@ func (n *NetworkAgent) Execute(ctx context.Context, task agents.Task) agents.Res
 
 	case "download":
 		output = fmt.Sprintf("NetworkAgent executed network operation: downloading from %s", url)
+		if content, ok := task.Data["content"].(string); ok {
+			artifacts = append(artifacts, agents.Artifact{Name: downloadName(url), Kind: agents.ArtifactKindDownload, Content: []byte(content)})
+			usage.BytesDownloaded = int64(len(content))
+		}
 
 	case "upload":
 		output = fmt.Sprintf("NetworkAgent executed network operation: uploading to %s", url)
@@ -205,21 +496,67 @@ func (n *NetworkAgent) Execute(ctx context.Context, task agents.Task) agents.Res
 			"agent_type": "network",
 			"operation":  task.Type,
 		},
+		Artifacts: artifacts,
+		Resources: &usage,
+	}
+}
+
+// hostAllowed returns the host a request URL names and whether the agent may reach it
+func (n *NetworkAgent) hostAllowed(rawURL string) (string, bool) {
+	var host string
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	if len(n.allowedHosts) == 0 {
+		return host, true
+	}
+	for _, allowed := range n.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasSuffix(host, suffix) && host != suffix[1:] {
+			return host, true
+		}
+		if host == allowed {
+			return host, true
+		}
 	}
+	return host, false
 }
 
+// writeMethods are the HTTP methods that change remote state
+var writeMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+
 // ResearchAgent handles information gathering and analysis
 type ResearchAgent struct {
 	*agents.BaseAgent
+	quota *quota
+	brain *Brain
+	// search finds sources for research steps listing none, when a provider is configured
+	search *searcher
 }
 
 // NewResearchAgent creates a new research agent
 func NewResearchAgent(id, name string) *ResearchAgent {
 	return &ResearchAgent{
 		BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeResearch),
+		quota:     newQuota(),
 	}
 }
 
+// SetLimits sets the resource limits enforced by this agent
+func (r *ResearchAgent) SetLimits(limits config.CrewLimits) {
+	r.quota.setLimits(limits)
+}
+
+// SetLogger sets the logger quota violations are reported to
+func (r *ResearchAgent) SetLogger(logger *zap.Logger) {
+	r.quota.setLogger(logger)
+}
+
+// SetBrain sets the brain the agent reasons about steps with before acting; nil acts directly
+func (r *ResearchAgent) SetBrain(brain *Brain) {
+	r.brain = brain
+}
+
 // SetRouter sets the message router for this agent
 func (r *ResearchAgent) SetRouter(router *agents.MessageRouter) {
 	r.BaseAgent.SetRouter(router)
@@ -230,18 +567,31 @@ func (r *ResearchAgent) GetReceivedMessages() []agents.Message {
 	return r.BaseAgent.GetReceivedMessages()
 }
 
-// Execute executes research-related tasks
+// Execute executes research-related tasks, reasoning about them first when the agent has a brain
 func (r *ResearchAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	return r.brain.execute(ctx, r, task, r.act)
+}
+
+// act performs a research operation
+func (r *ResearchAgent) act(ctx context.Context, task agents.Task) agents.Result {
+	limits := r.quota.current()
+	if !r.quota.acquire() {
+		return r.quota.exceeded(r, task, QuotaConcurrentTasks, int64(limits.MaxConcurrentTasks), 0)
+	}
+	defer r.quota.release()
+
 	// Set status to busy during execution
 	r.BaseAgent.SetStatus(agents.AgentStatusBusy)
 	defer r.BaseAgent.SetStatus(agents.AgentStatusIdle)
 
 	startTime := time.Now()
 
-	// Extract task data
-	topicVal, ok := task.Data["topic"]
-	topic, okType := topicVal.(string)
-	if !ok || !okType || topic == "" {
+	// Extract task data, asking the user for a missing topic
+	topic, _ := task.Data["topic"].(string)
+	if topic == "" {
+		topic = clarify(ctx, task, r.ID(), fmt.Sprintf("What topic should I research for %q?", task.Description))
+	}
+	if topic == "" {
 		return agents.Result{
 			TaskID:    task.ID,
 			Success:   false,
@@ -263,9 +613,28 @@ func (r *ResearchAgent) Execute(ctx context.Context, task agents.Task) agents.Re
 		}
 	}
 
+	citations, err := researchSources(task.Data, startTime)
+	if _, listed := task.Data["sources"]; err == nil && !listed && task.Type == "research" && r.search != nil {
+		citations, err = r.search.find(ctx, topic, startTime)
+	}
+	if err != nil {
+		return agents.Result{
+			TaskID:    task.ID,
+			Success:   false,
+			Output:    "ResearchAgent error: " + err.Error(),
+			Duration:  time.Since(startTime),
+			Timestamp: time.Now(),
+			Data: map[string]interface{}{
+				"agent_type": "research",
+				"operation":  task.Type,
+			},
+		}
+	}
+
 	// Simulate research operation based on task type
 	var output string
 	var success bool = true
+	var artifacts []agents.Artifact
 
 	switch task.Type {
 	case "research":
@@ -281,6 +650,7 @@ func (r *ResearchAgent) Execute(ctx context.Context, task agents.Task) agents.Re
 
 	case "documentation":
 		output = fmt.Sprintf("ResearchAgent executed research operation: documenting %s", topic)
+		artifacts = append(artifacts, agents.Artifact{Name: slug(topic) + ".md", Kind: agents.ArtifactKindReport, Content: []byte(output + "\n")})
 
 	case "best_practices":
 		output = fmt.Sprintf("ResearchAgent executed research operation: finding best practices for %s", topic)
@@ -289,15 +659,25 @@ func (r *ResearchAgent) Execute(ctx context.Context, task agents.Task) agents.Re
 		output = fmt.Sprintf("ResearchAgent executed research operation: %s", task.Description)
 	}
 
+	data := map[string]interface{}{
+		"agent_type": "research",
+		"operation":  task.Type,
+	}
+	if len(citations) > 0 {
+		output += "\n\nSources:"
+		for _, citation := range citations {
+			output += "\n- " + citation.String()
+		}
+		data[agents.ResultCitations] = citations
+	}
+
 	return agents.Result{
 		TaskID:    task.ID,
 		Success:   success,
 		Output:    output,
 		Duration:  time.Since(startTime),
 		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"agent_type": "research",
-			"operation":  task.Type,
-		},
+		Data:      data,
+		Artifacts: artifacts,
 	}
 }
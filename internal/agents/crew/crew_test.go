@@ -2,12 +2,16 @@ package crew
 
 import (
 	"context"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestFileAgent_BasicProperties(t *testing.T) {
@@ -199,4 +203,210 @@ func TestCrewAgents_CommunicationScenario(t *testing.T) {
 	// Search functionality
 	searchResults := logger.SearchMessages("Go files")
 	assert.Len(t, searchResults, 2) // Should find messages mentioning "Go files"
-}
\ No newline at end of file
+}
+func TestCrewAgentFactory_AppliesLimits(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	factory := NewCrewAgentFactory()
+	factory.SetLimits(map[string]config.CrewLimits{"file": {MaxFileSize: 4}})
+	factory.SetLogger(zap.New(core))
+
+	agent, err := factory.CreateAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+
+	result := agent.Execute(context.Background(), agents.Task{
+		ID:   "task-1",
+		Type: "file_write",
+		Data: map[string]interface{}{"path": "out.txt", "content": "too long"},
+	})
+
+	assert.False(t, result.Success)
+	assert.Equal(t, QuotaFileSize, result.Data["quota_exceeded"])
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, "Crew agent quota exceeded", entry.Message)
+	assert.Equal(t, "task-1", entry.ContextMap()["task_id"])
+
+	research, err := factory.CreateAgent("research-1", "ResearchAgent-1", agents.AgentTypeResearch)
+	require.NoError(t, err)
+	assert.True(t, research.Execute(context.Background(), agents.Task{
+		ID:   "task-2",
+		Type: "research",
+		Data: map[string]interface{}{"topic": "limits"},
+	}).Success, "agent types without limits are unrestricted")
+}
+
+func TestCrewAgentFactory_AppliesHTTP(t *testing.T) {
+	factory := NewCrewAgentFactory()
+	agent, err := factory.CreateAgent("net-1", "NetworkAgent-1", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultClient, agent.(*NetworkAgent).HTTPClient())
+
+	factory.SetHTTP(config.HTTPConfig{HTTPProxy: "http://proxy.corp:3128"})
+	agent, err = factory.CreateAgent("net-2", "NetworkAgent-2", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	transport, ok := agent.(*NetworkAgent).HTTPClient().Transport.(*http.Transport)
+	require.True(t, ok)
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	require.NoError(t, err)
+	proxy, err := transport.Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.corp:3128", proxy.String())
+
+	factory.SetHTTP(config.HTTPConfig{CABundle: "/nonexistent/ca.pem"})
+	_, err = factory.CreateAgent("net-3", "NetworkAgent-3", agents.AgentTypeNetwork)
+	assert.ErrorContains(t, err, "failed to create network agent HTTP client")
+}
+
+func TestFileAgent_MaxFileSize(t *testing.T) {
+	agent := NewFileAgent("file-1", "FileAgent-1")
+	agent.SetLimits(config.CrewLimits{MaxFileSize: 10})
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		success bool
+	}{
+		{"content within limit", map[string]interface{}{"path": "a.txt", "content": "small"}, true},
+		{"content over limit", map[string]interface{}{"path": "a.txt", "content": "this is far too large"}, false},
+		{"declared size over limit", map[string]interface{}{"path": "a.txt", "size": 4096}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "file_write", Data: tt.data})
+			assert.Equal(t, tt.success, result.Success)
+			if !tt.success {
+				assert.Contains(t, result.Error, "max_file_size limit is 10")
+			}
+		})
+	}
+
+	read := agent.Execute(context.Background(), agents.Task{
+		ID:   "task-2",
+		Type: "file_read",
+		Data: map[string]interface{}{"path": "a.txt", "size": 4096},
+	})
+	assert.True(t, read.Success, "only writes are limited by max_file_size")
+}
+
+func TestNetworkAgent_Quotas(t *testing.T) {
+	t.Run("download size", func(t *testing.T) {
+		agent := NewNetworkAgent("net-1", "NetworkAgent-1")
+		agent.SetLimits(config.CrewLimits{MaxDownloadBytes: 1024})
+
+		result := agent.Execute(context.Background(), agents.Task{
+			ID:   "task-1",
+			Type: "download",
+			Data: map[string]interface{}{"url": "https://example.com/big.iso", "method": "GET", "size": float64(1 << 20)},
+		})
+		assert.False(t, result.Success)
+		assert.Equal(t, QuotaDownloadBytes, result.Data["quota_exceeded"])
+		assert.Equal(t, int64(1024), result.Data["quota_limit"])
+	})
+
+	t.Run("requests per minute", func(t *testing.T) {
+		agent := NewNetworkAgent("net-1", "NetworkAgent-1")
+		agent.SetLimits(config.CrewLimits{MaxRequestsPerMinute: 2})
+		task := agents.Task{
+			ID:   "task-1",
+			Type: "api_call",
+			Data: map[string]interface{}{"url": "https://api.example.com", "method": "GET"},
+		}
+
+		assert.True(t, agent.Execute(context.Background(), task).Success)
+		assert.True(t, agent.Execute(context.Background(), task).Success)
+		result := agent.Execute(context.Background(), task)
+		assert.False(t, result.Success)
+		assert.Equal(t, QuotaRequestsPerMinute, result.Data["quota_exceeded"])
+	})
+}
+
+func TestCrewAgents_Artifacts(t *testing.T) {
+	ctx := context.Background()
+
+	file := NewFileAgent("file-1", "FileAgent-1").Execute(ctx, agents.Task{
+		ID:   "task-1",
+		Type: "file_write",
+		Data: map[string]interface{}{"path": "out/notes.txt", "content": "hello"},
+	})
+	require.Len(t, file.Artifacts, 1)
+	assert.Equal(t, agents.Artifact{Name: "notes.txt", Kind: agents.ArtifactKindFile, Content: []byte("hello")}, file.Artifacts[0])
+	require.NotNil(t, file.Resources)
+	assert.Equal(t, 1, file.Resources.FilesWritten)
+
+	download := NewNetworkAgent("net-1", "NetworkAgent-1").Execute(ctx, agents.Task{
+		ID:   "task-2",
+		Type: "download",
+		Data: map[string]interface{}{"url": "https://example.com/files/data.json?v=2", "method": "GET", "content": "{}"},
+	})
+	require.Len(t, download.Artifacts, 1)
+	assert.Equal(t, "data.json", download.Artifacts[0].Name)
+	assert.Equal(t, agents.ArtifactKindDownload, download.Artifacts[0].Kind)
+	require.NotNil(t, download.Resources)
+	assert.Equal(t, int64(2), download.Resources.BytesDownloaded)
+
+	report := NewResearchAgent("research-1", "ResearchAgent-1").Execute(ctx, agents.Task{
+		ID:   "task-3",
+		Type: "documentation",
+		Data: map[string]interface{}{"topic": "Error Handling"},
+	})
+	require.Len(t, report.Artifacts, 1)
+	assert.Equal(t, "error-handling.md", report.Artifacts[0].Name)
+	assert.Contains(t, string(report.Artifacts[0].Content), "documenting Error Handling")
+
+	read := NewFileAgent("file-1", "FileAgent-1").Execute(ctx, agents.Task{
+		ID:   "task-4",
+		Type: "file_read",
+		Data: map[string]interface{}{"path": "out/notes.txt"},
+	})
+	assert.Empty(t, read.Artifacts, "reads produce no artifacts")
+}
+
+// fixedQuestioner answers every question with the same reply
+type fixedQuestioner struct {
+	reply string
+	err   error
+}
+
+func (q fixedQuestioner) Ask(ctx context.Context, step, agentID, question string) (string, error) {
+	return q.reply, q.err
+}
+
+func TestCrewAgents_AskForMissingData(t *testing.T) {
+	task := agents.Task{ID: "task-1", Type: "research", Description: "Research error handling", Data: map[string]interface{}{}}
+
+	// Without anyone to ask the step fails as before
+	result := NewResearchAgent("research-1", "ResearchAgent-1").Execute(context.Background(), task)
+	assert.False(t, result.Success)
+
+	task.Questioner = fixedQuestioner{reply: " Go error handling "}
+	result = NewResearchAgent("research-1", "ResearchAgent-1").Execute(context.Background(), task)
+	assert.True(t, result.Success)
+	assert.Contains(t, result.Output, "Go error handling")
+
+	task.Questioner = fixedQuestioner{err: context.Canceled}
+	result = NewFileAgent("file-1", "FileAgent-1").Execute(context.Background(), task)
+	assert.False(t, result.Success, "an abandoned question leaves the path missing")
+}
+
+func TestCrewAgents_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	file := NewFileAgent("file-1", "FileAgent-1")
+	network := NewNetworkAgent("net-1", "NetworkAgent-1")
+
+	write := file.Execute(ctx, agents.Task{ID: "task-1", Type: "file_write", ReadOnly: true, Data: map[string]interface{}{"path": "/tmp/out.txt", "content": "x"}})
+	assert.True(t, write.Success, "a blocked step is skipped, not failed")
+	assert.Equal(t, "skipped: read-only mode blocks writing to file /tmp/out.txt", write.Output)
+	assert.Equal(t, true, write.Data["skipped"])
+	assert.Empty(t, write.Artifacts)
+
+	read := file.Execute(ctx, agents.Task{ID: "task-2", Type: "file_read", ReadOnly: true, Data: map[string]interface{}{"path": "/tmp/out.txt"}})
+	assert.Nil(t, read.Data["skipped"], "reads still run")
+
+	post := network.Execute(ctx, agents.Task{ID: "task-3", Type: "api_call", ReadOnly: true, Data: map[string]interface{}{"url": "https://api.example.com/items", "method": "post"}})
+	assert.Equal(t, "skipped: read-only mode blocks POST request to https://api.example.com/items", post.Output)
+	assert.Equal(t, true, post.Data["read_only"])
+
+	get := network.Execute(ctx, agents.Task{ID: "task-4", Type: "api_call", ReadOnly: true, Data: map[string]interface{}{"url": "https://api.example.com/items", "method": "GET"}})
+	assert.Nil(t, get.Data["skipped"])
+}
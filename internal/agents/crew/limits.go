@@ -0,0 +1,162 @@
+package crew
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// Quota names reported in quota-exceeded results
+const (
+	QuotaConcurrentTasks   = "max_concurrent_tasks"
+	QuotaDownloadBytes     = "max_download_bytes"
+	QuotaFileSize          = "max_file_size"
+	QuotaRequestsPerMinute = "max_requests_per_minute"
+)
+
+// quota enforces the configured resource limits for a single crew agent
+type quota struct {
+	mu       sync.Mutex
+	limits   config.CrewLimits
+	logger   *zap.Logger
+	active   int
+	requests []time.Time
+	clock    common.Clock
+}
+
+// newQuota creates an unlimited quota
+func newQuota() *quota {
+	return &quota{
+		logger: zap.NewNop(),
+		clock:  common.SystemClock,
+	}
+}
+
+// setLimits replaces the enforced limits
+func (q *quota) setLimits(limits config.CrewLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits = limits
+}
+
+// current returns the enforced limits
+func (q *quota) current() config.CrewLimits {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.limits
+}
+
+// setClock sets the clock request rates are measured against
+func (q *quota) setClock(clock common.Clock) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.clock = clock
+}
+
+// setLogger sets the logger quota violations are reported to
+func (q *quota) setLogger(logger *zap.Logger) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	q.logger = logger
+}
+
+// acquire reserves a task slot, returning false when the agent is already at its concurrency limit
+func (q *quota) acquire() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limits.MaxConcurrentTasks > 0 && q.active >= q.limits.MaxConcurrentTasks {
+		return false
+	}
+	q.active++
+	return true
+}
+
+// release frees a task slot reserved by acquire
+func (q *quota) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active > 0 {
+		q.active--
+	}
+}
+
+// allowRequest records an outbound request, returning false when the last minute's budget is spent
+func (q *quota) allowRequest() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limits.MaxRequestsPerMinute <= 0 {
+		return true
+	}
+
+	now := q.clock.Now()
+	cutoff := now.Add(-time.Minute)
+	recent := q.requests[:0]
+	for _, at := range q.requests {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	q.requests = recent
+
+	if len(q.requests) >= q.limits.MaxRequestsPerMinute {
+		return false
+	}
+	q.requests = append(q.requests, now)
+	return true
+}
+
+// exceeded logs a quota violation and builds the failed result returned for the task
+func (q *quota) exceeded(agent agents.Agent, task agents.Task, name string, limit, requested int64) agents.Result {
+	q.mu.Lock()
+	logger, clock := q.logger, q.clock
+	q.mu.Unlock()
+
+	message := fmt.Sprintf("%s quota exceeded: %s limit is %d", agent.Name(), name, limit)
+	if requested > 0 {
+		message += fmt.Sprintf(", task requires %d", requested)
+	}
+	logger.Warn("Crew agent quota exceeded",
+		zap.String("agent_id", agent.ID()),
+		zap.String("agent_type", string(agent.Type())),
+		zap.String("task_id", task.ID),
+		zap.String("quota", name),
+		zap.Int64("limit", limit),
+		zap.Int64("requested", requested))
+
+	return agents.Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Output:    message,
+		Error:     message,
+		Timestamp: clock.Now(),
+		Data: map[string]interface{}{
+			"agent_type":     string(agent.Type()),
+			"operation":      task.Type,
+			"quota_exceeded": name,
+			"quota_limit":    limit,
+		},
+	}
+}
+
+// dataSize reads a byte count from task data, accepting the numeric types JSON and YAML decoding produce
+func dataSize(data map[string]interface{}, key string) (int64, bool) {
+	switch v := data[key].(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,71 @@
+package crew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestQuota_Concurrency(t *testing.T) {
+	q := newQuota()
+	q.setLimits(config.CrewLimits{MaxConcurrentTasks: 2})
+
+	assert.True(t, q.acquire())
+	assert.True(t, q.acquire())
+	assert.False(t, q.acquire(), "third task exceeds the limit")
+
+	q.release()
+	assert.True(t, q.acquire(), "a released slot can be reused")
+}
+
+func TestQuota_Unlimited(t *testing.T) {
+	q := newQuota()
+	for i := 0; i < 100; i++ {
+		assert.True(t, q.acquire())
+		assert.True(t, q.allowRequest())
+	}
+}
+
+func TestQuota_RequestsPerMinute(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	q := newQuota()
+	q.setClock(clock)
+	q.setLimits(config.CrewLimits{MaxRequestsPerMinute: 3})
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, q.allowRequest())
+		clock.Advance(10 * time.Second)
+	}
+	assert.False(t, q.allowRequest(), "budget is spent within the window")
+
+	clock.Advance(31 * time.Second)
+	assert.True(t, q.allowRequest(), "the oldest request has left the window")
+	assert.False(t, q.allowRequest())
+}
+
+func TestDataSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  int64
+		ok    bool
+	}{
+		{"int", 42, 42, true},
+		{"int64", int64(42), 42, true},
+		{"float64", float64(42), 42, true},
+		{"string", "42", 0, false},
+		{"missing", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, ok := dataSize(map[string]interface{}{"size": tt.value}, "size")
+			assert.Equal(t, tt.want, size)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
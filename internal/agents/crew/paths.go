@@ -0,0 +1,81 @@
+package crew
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// resolvePath converts a task path to the platform's form, expanding a leading ~ to the user's home directory.
+// Planner output uses forward slashes, so they are translated to the native separator.
+func resolvePath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	return filepath.FromSlash(path)
+}
+
+// resolveTaskPath resolves a path from task data, treating relative paths as relative to the task's workdir
+func resolveTaskPath(workdir, path string) string {
+	path = resolvePath(path)
+	if workdir != "" && !filepath.IsAbs(path) {
+		return filepath.Join(resolvePath(workdir), path)
+	}
+	return path
+}
+
+// resolve resolves a path from task data as resolveTaskPath does, confining it to the agent's
+// sandbox root when it has one. Relative paths of steps without a workdir resolve inside the root.
+func (f *FileAgent) resolve(workdir, path string) (string, error) {
+	if f.root == "" {
+		return resolveTaskPath(workdir, path), nil
+	}
+	if workdir == "" {
+		workdir = f.root
+	}
+	resolved := resolveTaskPath(workdir, path)
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(f.root, resolved)
+	}
+	resolved = filepath.Clean(resolved)
+	rel, err := filepath.Rel(f.root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside the sandbox root %s", resolved, f.root)
+	}
+	return resolved, nil
+}
+
+// downloadName derives an artifact name from the last segment of a URL path
+func downloadName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	return "download"
+}
+
+// slug turns free text into a lowercase, dash-separated file name stem
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	if out := strings.TrimSuffix(b.String(), "-"); out != "" {
+		return out
+	}
+	return "report"
+}
@@ -0,0 +1,87 @@
+package crew
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestResolvePath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"relative", "./src", filepath.FromSlash("./src")},
+		{"nested", "internal/agents/crew", filepath.Join("internal", "agents", "crew")},
+		{"home", "~", home},
+		{"under home", "~/projects/capn", filepath.Join(home, "projects", "capn")},
+		{"tilde in name", "~backup/file", filepath.FromSlash("~backup/file")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolvePath(tt.path))
+		})
+	}
+}
+
+func TestDownloadName(t *testing.T) {
+	assert.Equal(t, "archive.tar.gz", downloadName("https://example.com/releases/archive.tar.gz"))
+	assert.Equal(t, "download", downloadName("https://example.com/"))
+	assert.Equal(t, "download", downloadName("https://example.com"))
+}
+
+func TestSlug(t *testing.T) {
+	assert.Equal(t, "go-error-handling", slug("Go: error handling!"))
+	assert.Equal(t, "report", slug("!!!"))
+}
+
+func TestResolveTaskPath(t *testing.T) {
+	workdir := filepath.Join("srv", "app")
+	assert.Equal(t, filepath.Join("srv", "app", "config.yaml"), resolveTaskPath(workdir, "config.yaml"))
+	assert.Equal(t, filepath.FromSlash("config.yaml"), resolveTaskPath("", "config.yaml"), "no workdir keeps the path as is")
+
+	abs, err := filepath.Abs("notes.txt")
+	if assert.NoError(t, err) {
+		assert.Equal(t, abs, resolveTaskPath(workdir, abs), "absolute paths ignore the workdir")
+	}
+}
+
+func TestFileAgent_SandboxRoot(t *testing.T) {
+	root := t.TempDir()
+	agent := NewFileAgent("file-1", "FileAgent-1")
+	agent.SetSandboxRoot(root)
+
+	path, err := agent.resolve("", "notes/todo.txt")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "notes", "todo.txt"), path, "relative paths resolve inside the root")
+
+	path, err = agent.resolve(filepath.Join(root, "src"), "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "src", "main.go"), path)
+
+	_, err = agent.resolve("", "../secrets.txt")
+	assert.EqualError(t, err, "path "+filepath.Join(filepath.Dir(root), "secrets.txt")+" is outside the sandbox root "+root)
+	_, err = agent.resolve(root+"-other", "main.go")
+	assert.ErrorContains(t, err, "is outside the sandbox root")
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "file_read", Data: map[string]interface{}{"path": "/etc/passwd"}})
+	assert.False(t, result.Success)
+	assert.Equal(t, "FileAgent error: path "+filepath.FromSlash("/etc/passwd")+" is outside the sandbox root "+root, result.Output)
+
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-2", Type: "file_watch", Data: map[string]interface{}{
+		"path": "out", "paths": []interface{}{"/tmp"}, "until": WatchUntilExists,
+	}})
+	assert.False(t, result.Success, "extra watch paths are confined too")
+	assert.Contains(t, result.Output, "is outside the sandbox root")
+}
@@ -0,0 +1,18 @@
+package crew
+
+import (
+	"context"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// clarify asks the user for a value the task is missing. It returns an empty string when
+// no one can answer or the question was abandoned, leaving the agent to fail the step.
+func clarify(ctx context.Context, task agents.Task, agentID, question string) string {
+	answer, err := task.Ask(ctx, agentID, question)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(answer)
+}
@@ -0,0 +1,319 @@
+package crew
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// defaultMaxResponseBytes bounds how much of a checked response is read when the agent's
+// limits set no download budget
+const defaultMaxResponseBytes = 10 << 20
+
+// ResponseCheck is a failed expectation about an api_call response
+type ResponseCheck struct {
+	Check    string      `json:"check"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual,omitempty"`
+}
+
+// String describes the failed expectation as a line of the step's output
+func (c ResponseCheck) String() string {
+	return fmt.Sprintf("expected %s to be %s, got %s", c.Check, describeValue(c.Expected), describeValue(c.Actual))
+}
+
+// checksResponse reports whether an api_call step declares expectations about its response or
+// fields to extract from it, which need the request to be sent
+func checksResponse(data map[string]interface{}) bool {
+	_, expect := data["expect"]
+	_, extract := data["extract"]
+	return expect || extract
+}
+
+// call sends an api_call step's request and checks its response against the step's "expect"
+// entry: "status" is a code or a list of codes, 2xx when missing, and "json" maps JSONPath
+// expressions such as "$.data.items[0].id" to the values they must select. Each "extract"
+// entry publishes the value a JSONPath expression selects to the blackboard under its key.
+// The plan executor fills placeholders such as {{user_id}} in the URL, body and headers with
+// findings before the step is checked and dispatched, so a plan can chain calls.
+func (n *NetworkAgent) call(ctx context.Context, task agents.Task, method, url string, maxBytes int64) agents.Result {
+	startTime := time.Now()
+	var downloaded int64
+	result := func(success bool, output string, data map[string]interface{}) agents.Result {
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data["agent_type"] = "network"
+		data["operation"] = task.Type
+		return agents.Result{
+			TaskID:    task.ID,
+			Success:   success,
+			Output:    output,
+			Duration:  time.Since(startTime),
+			Timestamp: time.Now(),
+			Data:      data,
+			Resources: &agents.ResourceUsage{BytesDownloaded: downloaded},
+		}
+	}
+
+	var body io.Reader
+	if content, ok := task.Data["body"].(string); ok {
+		body = strings.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, body)
+	if err != nil {
+		return result(false, fmt.Sprintf("NetworkAgent error: invalid request: %v", err), nil)
+	}
+	if headers, ok := task.Data["headers"].(map[string]interface{}); ok {
+		for name, value := range headers {
+			req.Header.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return result(false, fmt.Sprintf("NetworkAgent error: %s request to %s failed: %v", req.Method, url, err), nil)
+	}
+	defer resp.Body.Close()
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxResponseBytes
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	downloaded = int64(len(content))
+	if err != nil {
+		return result(false, fmt.Sprintf("NetworkAgent error: failed to read response from %s: %v", url, err), nil)
+	}
+	if int64(len(content)) > maxBytes {
+		return result(false, fmt.Sprintf("NetworkAgent error: response from %s is larger than %d bytes", url, maxBytes), nil)
+	}
+
+	output := fmt.Sprintf("NetworkAgent executed network operation: %s request to %s - %s", req.Method, url, resp.Status)
+	data := map[string]interface{}{"status_code": resp.StatusCode}
+	expect, _ := task.Data["expect"].(map[string]interface{})
+	extract, _ := task.Data["extract"].(map[string]interface{})
+
+	var doc interface{}
+	var docErr error
+	if _, ok := expect["json"]; ok || len(extract) > 0 {
+		if docErr = json.Unmarshal(content, &doc); docErr != nil {
+			docErr = fmt.Errorf("response is not JSON: %w", docErr)
+		}
+	}
+
+	failed, err := checkResponse(expect, resp.StatusCode, doc, docErr)
+	if err != nil {
+		return result(false, "NetworkAgent error: "+err.Error(), data)
+	}
+	if len(failed) > 0 {
+		data["failed_checks"] = failed
+		for _, check := range failed {
+			output += "\n" + check.String()
+		}
+		return result(false, output, data)
+	}
+
+	if len(extract) > 0 {
+		if docErr != nil {
+			return result(false, output+"\n"+docErr.Error(), data)
+		}
+		extracted := make(map[string]interface{}, len(extract))
+		for _, key := range sortedKeys(extract) {
+			path, _ := extract[key].(string)
+			value, err := jsonPath(doc, path)
+			if err != nil {
+				return result(false, fmt.Sprintf("%s\nfailed to extract %s: %v", output, key, err), data)
+			}
+			if err := task.Publish(ctx, n.ID(), key, value); err != nil {
+				return result(false, fmt.Sprintf("%s\nfailed to publish %s: %v", output, key, err), data)
+			}
+			extracted[key] = value
+			output += fmt.Sprintf("\nextracted %s = %s", key, describeValue(value))
+		}
+		data["extracted"] = extracted
+	}
+	return result(true, output, data)
+}
+
+// checkResponse returns the expectations a response does not meet, or an error for an
+// expectation that cannot be checked
+func checkResponse(expect map[string]interface{}, status int, doc interface{}, docErr error) ([]ResponseCheck, error) {
+	var failed []ResponseCheck
+	codes, err := expectedStatus(expect["status"])
+	if err != nil {
+		return nil, err
+	}
+	if codes == nil {
+		if status < 200 || status > 299 {
+			failed = append(failed, ResponseCheck{Check: "status", Expected: "2xx", Actual: status})
+		}
+	} else if !slices.Contains(codes, status) {
+		var expected interface{} = codes
+		if len(codes) == 1 {
+			expected = codes[0]
+		}
+		failed = append(failed, ResponseCheck{Check: "status", Expected: expected, Actual: status})
+	}
+
+	checks, _ := expect["json"].(map[string]interface{})
+	if len(checks) > 0 && docErr != nil {
+		return append(failed, ResponseCheck{Check: "body", Expected: "JSON", Actual: docErr.Error()}), nil
+	}
+	for _, path := range sortedKeys(checks) {
+		actual, err := jsonPath(doc, path)
+		if err != nil {
+			failed = append(failed, ResponseCheck{Check: path, Expected: checks[path], Actual: err.Error()})
+			continue
+		}
+		if !sameJSON(checks[path], actual) {
+			failed = append(failed, ResponseCheck{Check: path, Expected: checks[path], Actual: actual})
+		}
+	}
+	return failed, nil
+}
+
+// expectedStatus reads the status codes an expectation allows; nil allows any 2xx code
+func expectedStatus(value interface{}) ([]int, error) {
+	code := func(v interface{}) (int, bool) {
+		switch c := v.(type) {
+		case int:
+			return c, true
+		case int64:
+			return int(c), true
+		case float64:
+			return int(c), c == float64(int(c))
+		case string:
+			n, err := strconv.Atoi(c)
+			return n, err == nil
+		}
+		return 0, false
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		codes := make([]int, 0, len(v))
+		for _, item := range v {
+			c, ok := code(item)
+			if !ok {
+				return nil, fmt.Errorf("invalid expected status %v", item)
+			}
+			codes = append(codes, c)
+		}
+		return codes, nil
+	default:
+		c, ok := code(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid expected status %v", v)
+		}
+		return []int{c}, nil
+	}
+}
+
+// jsonPath returns the value a JSONPath expression selects in a decoded JSON document. It
+// supports the dotted member and index form: $, $.name, $.items[0].name and $["odd key"].
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+	value := doc
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			}
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed [", path)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			if unquoted, err := strconv.Unquote(strings.ReplaceAll(selector, "'", `"`)); err == nil {
+				key = unquoted
+			} else if i, err := strconv.Atoi(selector); err == nil && i >= 0 {
+				index = i
+			} else {
+				return nil, fmt.Errorf("invalid JSONPath %q: unsupported selector [%s]", path, selector)
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q at %q", path, rest)
+		}
+
+		if index >= 0 {
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: not an array", path)
+			}
+			if index >= len(items) {
+				return nil, fmt.Errorf("%s: index %d out of range", path, index)
+			}
+			value = items[index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: not an object", path)
+		}
+		if value, ok = object[key]; !ok {
+			return nil, fmt.Errorf("%s: no member %q", path, key)
+		}
+	}
+	return value, nil
+}
+
+// sameJSON reports whether two values are equal once both are in their decoded JSON form, so
+// an expected 42 from a plan matches the 42.0 a response decodes to
+func sameJSON(expected, actual interface{}) bool {
+	normalize := func(v interface{}) interface{} {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var decoded interface{}
+		if json.Unmarshal(encoded, &decoded) != nil {
+			return v
+		}
+		return decoded
+	}
+	return reflect.DeepEqual(normalize(expected), normalize(actual))
+}
+
+// describeValue formats a value as compact JSON for a step's output
+func describeValue(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
+
+// sortedKeys returns a map's keys in order, so checks and extractions run and report in the
+// same order each time
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,185 @@
+package crew
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// apiServer serves a user record and echoes created users, recording the last request
+func apiServer(t *testing.T) (*httptest.Server, *http.Request) {
+	t.Helper()
+	last := &http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = *r.Clone(context.Background())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users/42":
+			_, _ = io.WriteString(w, `{"data": {"id": 42, "name": "Ada", "roles": ["admin", "dev"], "team id": "t-7"}}`)
+		case "/users":
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		case "/plain":
+			_, _ = io.WriteString(w, "ok")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error": "not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, last
+}
+
+func apiTask(url, method string, data map[string]interface{}) agents.Task {
+	task := agents.Task{
+		ID:          "call-1",
+		Type:        "api_call",
+		Description: "Fetch the user",
+		Data:        map[string]interface{}{"url": url, "method": method},
+	}
+	for k, v := range data {
+		task.Data[k] = v
+	}
+	return task
+}
+
+func TestNetworkAgent_ResponseChecks(t *testing.T) {
+	server, _ := apiServer(t)
+	agent := NewNetworkAgent("net-1", "NetworkAgent-1")
+
+	result := agent.Execute(context.Background(), apiTask(server.URL+"/users/42", "GET", map[string]interface{}{
+		"expect": map[string]interface{}{
+			"status": 200,
+			"json":   map[string]interface{}{"$.data.id": 42, "$.data.roles[0]": "admin", `$.data["team id"]`: "t-7"},
+		},
+	}))
+	require.True(t, result.Success, result.Output)
+	assert.Contains(t, result.Output, "GET request to "+server.URL+"/users/42 - 200 OK")
+	assert.Equal(t, 200, result.Data["status_code"])
+
+	result = agent.Execute(context.Background(), apiTask(server.URL+"/users/42", "GET", map[string]interface{}{
+		"expect": map[string]interface{}{
+			"status": []interface{}{200, 204},
+			"json":   map[string]interface{}{"$.data.name": "Grace", "$.data.email": "ada@example.com"},
+		},
+	}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, `expected $.data.name to be "Grace", got "Ada"`)
+	assert.Contains(t, result.Output, `$.data.email: no member \"email\"`)
+	assert.Len(t, result.Data["failed_checks"], 2)
+
+	result = agent.Execute(context.Background(), apiTask(server.URL+"/missing", "GET", map[string]interface{}{"expect": map[string]interface{}{}}))
+	assert.False(t, result.Success, "a response outside 2xx fails when no status is expected")
+	assert.Contains(t, result.Output, `expected status to be "2xx", got 404`)
+
+	result = agent.Execute(context.Background(), apiTask(server.URL+"/missing", "GET", map[string]interface{}{"expect": map[string]interface{}{"status": 404}}))
+	assert.True(t, result.Success, result.Output)
+
+	result = agent.Execute(context.Background(), apiTask(server.URL+"/plain", "GET", map[string]interface{}{
+		"expect": map[string]interface{}{"json": map[string]interface{}{"$.ok": true}},
+	}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, "response is not JSON")
+}
+
+func TestNetworkAgent_ExtractAndChain(t *testing.T) {
+	server, last := apiServer(t)
+	agent := NewNetworkAgent("net-1", "NetworkAgent-1")
+	blackboard := agents.NewMemoryBlackboard()
+
+	fetch := apiTask(server.URL+"/users/42", "GET", map[string]interface{}{
+		"extract": map[string]interface{}{"user_id": "$.data.id", "roles": "$.data.roles"},
+	})
+	fetch.Blackboard = blackboard
+	result := agent.Execute(context.Background(), fetch)
+	require.True(t, result.Success, result.Output)
+	assert.Contains(t, result.Output, "extracted roles = [\"admin\",\"dev\"]\nextracted user_id = 42")
+	finding, ok, err := blackboard.Lookup(context.Background(), "user_id")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, float64(42), finding.Value)
+	assert.Equal(t, "net-1", finding.Agent)
+
+	create := apiTask(server.URL+"/users", "POST", map[string]interface{}{
+		"body":    `{"manager": 42}`,
+		"headers": map[string]interface{}{"X-Requested-By": "user-42"},
+		"expect":  map[string]interface{}{"status": 201, "json": map[string]interface{}{"$.manager": 42}},
+	})
+	result = agent.Execute(context.Background(), create)
+	require.True(t, result.Success, result.Output)
+	assert.Equal(t, "user-42", last.Header.Get("X-Requested-By"))
+
+	result = agent.Execute(context.Background(), apiTask(server.URL+"/users/42", "GET", map[string]interface{}{
+		"extract": map[string]interface{}{"user_id": "$.data.id"},
+	}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, agents.ErrNoBlackboard.Error())
+}
+
+func TestNetworkAgent_ResponseLimit(t *testing.T) {
+	server, _ := apiServer(t)
+	agent := NewNetworkAgent("net-1", "NetworkAgent-1")
+	agent.SetLimits(config.CrewLimits{MaxDownloadBytes: 10})
+
+	result := agent.Execute(context.Background(), apiTask(server.URL+"/users/42", "GET", map[string]interface{}{"expect": map[string]interface{}{}}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, "larger than 10 bytes")
+}
+
+func TestJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"items":   []interface{}{map[string]interface{}{"name": "a"}},
+		"odd key": map[string]interface{}{"x": true},
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+		err  string
+	}{
+		{path: "$", want: doc},
+		{path: "$.items[0].name", want: "a"},
+		{path: `$["odd key"].x`, want: true},
+		{path: "$['odd key']", want: map[string]interface{}{"x": true}},
+		{path: "items", err: "must start with $"},
+		{path: "$.items[1]", err: "index 1 out of range"},
+		{path: "$.items.name", err: "not an object"},
+		{path: "$.items[*]", err: "unsupported selector [*]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := jsonPath(doc, tt.path)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNetworkAgent_AllowedHosts(t *testing.T) {
+	agent := NewNetworkAgent("net-1", "NetworkAgent-1")
+	agent.SetAllowedHosts([]string{"api.github.com", "*.example.com"})
+
+	for _, url := range []string{"https://api.github.com/repos", "https://API.GitHub.com", "https://docs.example.com/a", "https://a.b.example.com"} {
+		result := agent.Execute(context.Background(), apiTask(url, "GET", nil))
+		assert.True(t, result.Success, "%s: %s", url, result.Output)
+	}
+	for _, url := range []string{"https://github.com", "https://example.com", "https://evil-example.com", "https://api.github.com.evil.io"} {
+		result := agent.Execute(context.Background(), apiTask(url, "GET", nil))
+		assert.False(t, result.Success, url)
+		assert.Contains(t, result.Output, "is not in crew.network.allowed_hosts")
+	}
+}
@@ -0,0 +1,85 @@
+package crew
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+const (
+	// defaultSearchResults is how many search results a research step cites by default
+	defaultSearchResults = 5
+	// searchConfidence is the confidence given to search results, which are leads the step
+	// found rather than sources it checked
+	searchConfidence = 0.5
+)
+
+// searcher finds sources for research steps that list none
+type searcher struct {
+	config config.ResearchAgentConfig
+	client *http.Client
+}
+
+// SetSearch has research steps given no sources query the configured search provider for them
+func (r *ResearchAgent) SetSearch(cfg config.ResearchAgentConfig, client *http.Client) {
+	if cfg.MaxResults == 0 {
+		cfg.MaxResults = defaultSearchResults
+	}
+	r.search = &searcher{config: cfg, client: client}
+}
+
+// find searches for topic, returning the top results as citations retrieved at retrievedAt
+func (s *searcher) find(ctx context.Context, topic string, retrievedAt time.Time) ([]agents.Citation, error) {
+	switch s.config.SearchProvider {
+	case config.SearchProviderSearXNG:
+		return s.searxng(ctx, topic, retrievedAt)
+	default:
+		return nil, fmt.Errorf("unknown search provider %q", s.config.SearchProvider)
+	}
+}
+
+// searxng queries a SearXNG instance's JSON API
+func (s *searcher) searxng(ctx context.Context, topic string, retrievedAt time.Time) ([]agents.Citation, error) {
+	query := url.Values{"q": {topic}, "format": {"json"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.config.SearchURL, "/")+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("search: %s returned %s", s.config.SearchProvider, resp.Status)
+	}
+
+	var body struct {
+		Results []struct {
+			URL   string `json:"url"`
+			Title string `json:"title"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("search: invalid response: %w", err)
+	}
+	var citations []agents.Citation
+	for _, result := range body.Results {
+		citation := agents.Citation{URL: result.URL, Title: result.Title, RetrievedAt: retrievedAt, Confidence: searchConfidence}
+		if citation.Validate() != nil {
+			continue
+		}
+		citations = append(citations, citation)
+		if len(citations) == s.config.MaxResults {
+			break
+		}
+	}
+	return citations, nil
+}
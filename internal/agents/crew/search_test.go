@@ -0,0 +1,67 @@
+package crew
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestResearchAgent_Search(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		_, _ = io.WriteString(w, `{"results": [
+			{"url": "https://go.dev/blog/errors-are-values", "title": "Errors are values"},
+			{"url": "javascript:alert(1)", "title": "Not a source"},
+			{"url": "https://go.dev/doc/effective_go", "title": "Effective Go"},
+			{"url": "https://example.com/third", "title": "Third"}
+		]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	agent := NewResearchAgent("research-1", "ResearchAgent-1")
+	agent.SetSearch(config.ResearchAgentConfig{SearchProvider: config.SearchProviderSearXNG, SearchURL: server.URL + "/", MaxResults: 2}, server.Client())
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "research", Data: map[string]interface{}{"topic": "go error handling"}})
+	require.True(t, result.Success, result.Output)
+	assert.Equal(t, "go error handling", query)
+	citations, ok := result.Data[agents.ResultCitations].([]agents.Citation)
+	require.True(t, ok)
+	require.Len(t, citations, 2, "invalid results are skipped and the rest capped at max_results")
+	assert.Equal(t, "https://go.dev/blog/errors-are-values", citations[0].URL)
+	assert.Equal(t, "Effective Go", citations[1].Title)
+	assert.Equal(t, searchConfidence, citations[1].Confidence)
+	assert.Contains(t, result.Output, "Sources:")
+
+	query = ""
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-2", Type: "research", Data: map[string]interface{}{
+		"topic":   "go error handling",
+		"sources": []interface{}{map[string]interface{}{"url": "https://go.dev/ref/spec", "confidence": 0.9}},
+	}})
+	require.True(t, result.Success, result.Output)
+	assert.Empty(t, query, "steps listing sources are not searched for")
+}
+
+func TestResearchAgent_SearchFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	agent := NewResearchAgent("research-1", "ResearchAgent-1")
+	agent.SetSearch(config.ResearchAgentConfig{SearchProvider: config.SearchProviderSearXNG, SearchURL: server.URL}, server.Client())
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "research", Data: map[string]interface{}{"topic": "anything"}})
+	assert.False(t, result.Success)
+	assert.Equal(t, "ResearchAgent error: search: searxng returned 503 Service Unavailable", result.Output)
+}
@@ -0,0 +1,51 @@
+package crew
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// source is a source a research step gathered, as given in its "sources" task data
+type source struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	RetrievedAt time.Time `json:"retrieved_at"`
+	Confidence  *float64  `json:"confidence"`
+}
+
+// researchSources reads the sources a research step's findings rest on, which must each give
+// a URL and a confidence, and merges near-identical ones. Sources without a retrieval time
+// were retrieved when the step started.
+func researchSources(data map[string]interface{}, retrievedAt time.Time) ([]agents.Citation, error) {
+	raw, ok := data["sources"]
+	if !ok {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'sources' in task data: %w", err)
+	}
+	var sources []source
+	if err := json.Unmarshal(encoded, &sources); err != nil {
+		return nil, fmt.Errorf("invalid 'sources' in task data: %w", err)
+	}
+
+	citations := make([]agents.Citation, 0, len(sources))
+	for _, s := range sources {
+		if s.Confidence == nil {
+			return nil, fmt.Errorf("source %q has no confidence", s.URL)
+		}
+		citation := agents.Citation{URL: s.URL, Title: s.Title, RetrievedAt: s.RetrievedAt, Confidence: *s.Confidence}
+		if citation.RetrievedAt.IsZero() {
+			citation.RetrievedAt = retrievedAt
+		}
+		if err := citation.Validate(); err != nil {
+			return nil, err
+		}
+		citations = append(citations, citation)
+	}
+	return agents.DedupeCitations(citations), nil
+}
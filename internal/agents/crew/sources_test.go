@@ -0,0 +1,49 @@
+package crew
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestResearchAgent_Sources(t *testing.T) {
+	agent := NewResearchAgent("research-1", "ResearchAgent-1")
+	task := agents.Task{
+		ID:          "task-1",
+		Type:        "research",
+		Description: "Research Go error handling",
+		Data: map[string]interface{}{
+			"topic": "Go error handling",
+			"sources": []interface{}{
+				map[string]interface{}{"url": "https://go.dev/blog/go1.13-errors", "title": "Working with Errors in Go 1.13", "confidence": 0.9,
+					"retrieved_at": "2026-10-01T08:00:00Z"},
+				map[string]interface{}{"url": "https://www.go.dev/blog/go1.13-errors/?utm_source=feed", "confidence": 0.4},
+				map[string]interface{}{"url": "https://pkg.go.dev/errors", "confidence": 0.7},
+			},
+		},
+	}
+
+	result := agent.Execute(context.Background(), task)
+	require.True(t, result.Success, result.Output)
+	citations := result.Data[agents.ResultCitations].([]agents.Citation)
+	require.Len(t, citations, 2, "near-identical sources are merged")
+	assert.Equal(t, "Working with Errors in Go 1.13", citations[0].Title)
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), citations[0].RetrievedAt)
+	assert.False(t, citations[1].RetrievedAt.IsZero(), "sources without a retrieval time were retrieved by the step")
+	assert.Contains(t, result.Output, "\n\nSources:\n- Working with Errors in Go 1.13 (https://go.dev/blog/go1.13-errors), retrieved 2026-10-01, confidence 0.90\n- https://pkg.go.dev/errors")
+
+	task.Data["sources"] = []interface{}{map[string]interface{}{"url": "https://pkg.go.dev/errors"}}
+	result = agent.Execute(context.Background(), task)
+	assert.False(t, result.Success)
+	assert.Equal(t, `ResearchAgent error: source "https://pkg.go.dev/errors" has no confidence`, result.Output)
+
+	task.Data["sources"] = []interface{}{map[string]interface{}{"url": "file:///etc/passwd", "confidence": 1}}
+	result = agent.Execute(context.Background(), task)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, "must be an http or https URL")
+}
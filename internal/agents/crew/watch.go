@@ -0,0 +1,284 @@
+package crew
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+const (
+	// defaultWatchTimeout bounds a file_watch step that sets no timeout
+	defaultWatchTimeout = 5 * time.Minute
+	// defaultWatchInterval is how often a file_watch step looks at its paths
+	defaultWatchInterval = time.Second
+	// maxWatchHashSize is the largest file a watch hashes, so rewrites keeping its size and
+	// modification time are still seen; larger files are compared by those alone
+	maxWatchHashSize = 1 << 20
+	// maxWatchHashBytes caps how much a watch reads to hash files on each look
+	maxWatchHashBytes = 8 << 20
+)
+
+// Conditions ending a file_watch step, named by its "until" entry
+const (
+	// WatchUntilChange ends the watch at the first change
+	WatchUntilChange = "change"
+	// WatchUntilExists ends the watch once a watched path, or a file matching the pattern in
+	// a watched directory, exists
+	WatchUntilExists = "exists"
+	// WatchUntilTimeout watches for the whole timeout, reporting every change
+	WatchUntilTimeout = "timeout"
+)
+
+// WatchEvent is a change a file_watch step saw
+type WatchEvent struct {
+	Op   string    `json:"op"`
+	Path string    `json:"path"`
+	At   time.Time `json:"at"`
+}
+
+// String describes the event as the line it adds to the task log, such as "created dist/app.tar.gz"
+func (e WatchEvent) String() string {
+	return e.Op + " " + e.Path
+}
+
+// fileState is what a watch remembers of a path between looks
+type fileState struct {
+	modTime time.Time
+	size    int64
+	dir     bool
+	// hash is the content hash of small regular files, set when hashed is
+	hash   [sha256.Size]byte
+	hashed bool
+}
+
+// watchSpec is a file_watch step's task data
+type watchSpec struct {
+	paths    []string
+	pattern  string
+	until    string
+	timeout  time.Duration
+	interval time.Duration
+	// readFile reads the files hashed, defaulting to os.ReadFile
+	readFile func(name string) ([]byte, error)
+}
+
+// parseWatchSpec reads a file_watch step's paths, condition and timings from its task data
+func parseWatchSpec(task agents.Task, path, pattern string, resolve func(workdir, path string) (string, error)) (watchSpec, error) {
+	spec := watchSpec{paths: []string{path}, pattern: pattern, until: WatchUntilChange}
+	if extra, ok := task.Data["paths"].([]interface{}); ok {
+		for _, p := range extra {
+			if s, ok := p.(string); ok && s != "" {
+				resolved, err := resolve(task.Workdir, s)
+				if err != nil {
+					return spec, err
+				}
+				spec.paths = append(spec.paths, resolved)
+			}
+		}
+	}
+	if until, ok := task.Data["until"].(string); ok && until != "" {
+		spec.until = until
+	}
+	if !slices.Contains([]string{WatchUntilChange, WatchUntilExists, WatchUntilTimeout}, spec.until) {
+		return spec, fmt.Errorf("invalid 'until' %q (must be one of: %s, %s, %s)", spec.until, WatchUntilChange, WatchUntilExists, WatchUntilTimeout)
+	}
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return spec, fmt.Errorf("invalid 'pattern' %q: %w", pattern, err)
+		}
+	}
+	var err error
+	if spec.timeout, err = dataDuration(task.Data, "timeout", defaultWatchTimeout); err != nil {
+		return spec, err
+	}
+	if spec.interval, err = dataDuration(task.Data, "interval", defaultWatchInterval); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// dataDuration reads a duration from task data, given as a string such as "30s" or a number
+// of seconds, returning def when the key is missing
+func dataDuration(data map[string]interface{}, key string, def time.Duration) (time.Duration, error) {
+	var d time.Duration
+	switch v := data[key].(type) {
+	case nil:
+		return def, nil
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid '%s' %q: %w", key, v, err)
+		}
+		d = parsed
+	case int:
+		d = time.Duration(v) * time.Second
+	case int64:
+		d = time.Duration(v) * time.Second
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	default:
+		return 0, fmt.Errorf("invalid '%s' in task data", key)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("'%s' must be positive", key)
+	}
+	return d, nil
+}
+
+// snapshot records the state of each watched path at now, walking directories. Paths that
+// do not exist are left out, so their creation is seen as a change. Only small files modified
+// within the last two intervals are hashed, up to maxWatchHashBytes a look: a rewrite of an
+// older file changes its modification time, which is compared anyway.
+func (s watchSpec) snapshot(now time.Time) map[string]fileState {
+	readFile := s.readFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+	recent := now.Add(-2 * s.interval)
+	budget := int64(maxWatchHashBytes)
+	states := make(map[string]fileState)
+	for _, root := range s.paths {
+		_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// A path removed while walking is seen as removed on the next look
+				return nil
+			}
+			if path != root && s.pattern != "" && !entry.IsDir() {
+				if ok, _ := filepath.Match(s.pattern, entry.Name()); !ok {
+					return nil
+				}
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			state := fileState{modTime: info.ModTime(), size: info.Size(), dir: entry.IsDir()}
+			if info.Mode().IsRegular() && info.Size() <= maxWatchHashSize && info.Size() <= budget && info.ModTime().After(recent) {
+				if data, err := readFile(path); err == nil {
+					state.hash, state.hashed = sha256.Sum256(data), true
+					budget -= int64(len(data))
+				}
+			}
+			states[path] = state
+			return nil
+		})
+	}
+	return states
+}
+
+// exists reports whether the watch's "exists" condition holds in a snapshot: a watched path
+// exists, or with a pattern, a matching file in a watched directory does
+func (s watchSpec) exists(states map[string]fileState) bool {
+	for path, state := range states {
+		if s.pattern == "" && slices.Contains(s.paths, path) {
+			return true
+		}
+		if s.pattern != "" && !state.dir {
+			if ok, _ := filepath.Match(s.pattern, filepath.Base(path)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// diffSnapshots returns the changes between two snapshots, sorted by path. A file is modified
+// when its size, modification time or, for files hashed both times, content changed.
+// Directories whose modification time changed because their entries did are not reported
+// themselves.
+func diffSnapshots(before, after map[string]fileState, at time.Time) []WatchEvent {
+	var events []WatchEvent
+	for path, state := range after {
+		previous, existed := before[path]
+		switch {
+		case !existed:
+			events = append(events, WatchEvent{Op: "created", Path: path, At: at})
+		case !state.dir && (!state.modTime.Equal(previous.modTime) || state.size != previous.size ||
+			state.hashed && previous.hashed && state.hash != previous.hash):
+			events = append(events, WatchEvent{Op: "modified", Path: path, At: at})
+		}
+	}
+	for path := range before {
+		if _, exists := after[path]; !exists {
+			events = append(events, WatchEvent{Op: "removed", Path: path, At: at})
+		}
+	}
+	slices.SortFunc(events, func(a, b WatchEvent) int { return strings.Compare(a.Path, b.Path) })
+	return events
+}
+
+// watch polls a file_watch step's paths until its condition holds or its timeout passes,
+// streaming each change to the task log. The output lists the changes one per line, so
+// conditional follow-up steps can match on them.
+func (f *FileAgent) watch(ctx context.Context, task agents.Task, path, pattern string) agents.Result {
+	startTime := f.clock.Now()
+	result := func(success bool, output string, events []WatchEvent) agents.Result {
+		return agents.Result{
+			TaskID:    task.ID,
+			Success:   success,
+			Output:    output,
+			Duration:  f.clock.Since(startTime),
+			Timestamp: f.clock.Now(),
+			Data: map[string]interface{}{
+				"agent_type": "file",
+				"operation":  task.Type,
+				"events":     events,
+			},
+		}
+	}
+	spec, err := parseWatchSpec(task, path, pattern, f.resolve)
+	if err != nil {
+		return result(false, "FileAgent error: "+err.Error(), nil)
+	}
+
+	deadline := f.clock.After(spec.timeout)
+	ticker := f.clock.NewTicker(spec.interval)
+	defer ticker.Stop()
+
+	states := spec.snapshot(f.clock.Now())
+	var events []WatchEvent
+	report := func(output string) string {
+		for _, event := range events {
+			output += "\n" + event.String()
+		}
+		return output
+	}
+	for {
+		if spec.until == WatchUntilExists && spec.exists(states) {
+			return result(true, report(fmt.Sprintf("FileAgent executed file operation: %s exists", strings.Join(spec.paths, ", "))), events)
+		}
+		select {
+		case <-ctx.Done():
+			return result(false, report(fmt.Sprintf("FileAgent stopped watching %s: %v", strings.Join(spec.paths, ", "), ctx.Err())), events)
+		case <-deadline:
+			if spec.until == WatchUntilTimeout {
+				return result(true, report(fmt.Sprintf("FileAgent executed file operation: watched %s for %s, seeing %d changes",
+					strings.Join(spec.paths, ", "), spec.timeout, len(events))), events)
+			}
+			return result(false, report(fmt.Sprintf("FileAgent timed out after %s waiting for %s at %s",
+				spec.timeout, spec.until, strings.Join(spec.paths, ", "))), events)
+		case now := <-ticker.C():
+			next := spec.snapshot(now)
+			changes := diffSnapshots(states, next, now)
+			states = next
+			for _, event := range changes {
+				if task.Output != nil {
+					task.Output.OutputLine(agents.OutputStdout, event.String())
+				}
+			}
+			events = append(events, changes...)
+			if spec.until == WatchUntilChange && len(changes) > 0 {
+				return result(true, report(fmt.Sprintf("FileAgent executed file operation: saw %d changes at %s",
+					len(changes), strings.Join(spec.paths, ", "))), events)
+			}
+		}
+	}
+}
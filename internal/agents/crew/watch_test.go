@@ -0,0 +1,239 @@
+package crew
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// newWatchAgent returns a file agent watching on a fake clock
+func newWatchAgent() (*FileAgent, *testutil.FakeClock) {
+	agent := NewFileAgent("file-1", "FileAgent-1")
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	agent.SetClock(clock)
+	return agent, clock
+}
+
+// watchStarted waits until a watch has set its deadline and started polling
+func watchStarted(clock *testutil.FakeClock) {
+	clock.BlockUntil(2)
+}
+
+// watchTask returns a file_watch task polling dir quickly
+func watchTask(dir string, data map[string]interface{}) agents.Task {
+	task := agents.Task{
+		ID:          "watch-1",
+		Type:        "file_watch",
+		Description: "Wait for the build output",
+		Data:        map[string]interface{}{"path": dir, "interval": "5ms", "timeout": "2s"},
+	}
+	for k, v := range data {
+		task.Data[k] = v
+	}
+	return task
+}
+
+// lineRecorder collects the lines a task streams to its log
+type lineRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *lineRecorder) OutputLine(_ agents.OutputStream, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+}
+
+// lineChannel hands each line a task streams to the test as it is written
+type lineChannel chan string
+
+func (c lineChannel) OutputLine(_ agents.OutputStream, line string) {
+	c <- line
+}
+
+func TestFileAgent_WatchUntilChange(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+	agent, clock := newWatchAgent()
+	task := watchTask(dir, map[string]interface{}{"pattern": "*.tar.gz"})
+	recorder := &lineRecorder{}
+	task.Output = recorder
+
+	go func() {
+		watchStarted(clock)
+		_ = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644)
+		_ = os.WriteFile(filepath.Join(dir, "app.tar.gz"), []byte("archive"), 0o644)
+		clock.Advance(5 * time.Millisecond)
+	}()
+	result := agent.Execute(context.Background(), task)
+
+	require.True(t, result.Success, result.Output)
+	created := "created " + filepath.Join(dir, "app.tar.gz")
+	assert.Contains(t, result.Output, created)
+	assert.NotContains(t, result.Output, "notes.txt", "files not matching the pattern are not watched")
+	assert.Equal(t, []string{created}, recorder.lines)
+	events := result.Data["events"].([]WatchEvent)
+	require.Len(t, events, 1)
+	assert.Equal(t, "created", events[0].Op)
+	assert.Equal(t, agents.AgentStatusIdle, agent.Status())
+}
+
+func TestFileAgent_WatchSeesSameSizeRewrite(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte("mode: a"), 0o644))
+	info, err := os.Stat(config)
+	require.NoError(t, err)
+	agent, clock := newWatchAgent()
+
+	go func() {
+		watchStarted(clock)
+		// Rewritten within the modification time's resolution, keeping its size
+		_ = os.WriteFile(config, []byte("mode: b"), 0o644)
+		_ = os.Chtimes(config, info.ModTime(), info.ModTime())
+		clock.Advance(5 * time.Millisecond)
+	}()
+	result := agent.Execute(context.Background(), watchTask(config, nil))
+
+	require.True(t, result.Success, result.Output)
+	assert.Contains(t, result.Output, "modified "+config)
+}
+
+func TestFileAgent_WatchUntilExists(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "dist", "app")
+	agent, clock := newWatchAgent()
+
+	go func() {
+		watchStarted(clock)
+		_ = os.MkdirAll(filepath.Dir(output), 0o755)
+		_ = os.WriteFile(output, []byte("binary"), 0o755)
+		clock.Advance(5 * time.Millisecond)
+	}()
+	result := agent.Execute(context.Background(), watchTask(output, map[string]interface{}{"until": "exists"}))
+	require.True(t, result.Success, result.Output)
+	assert.Contains(t, result.Output, output+" exists")
+
+	result = agent.Execute(context.Background(), watchTask(output, map[string]interface{}{"until": "exists"}))
+	assert.True(t, result.Success, "a path that already exists ends the watch at once")
+	assert.Zero(t, result.Duration)
+}
+
+func TestFileAgent_WatchUntilTimeout(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "build.log")
+	require.NoError(t, os.WriteFile(log, []byte("start\n"), 0o644))
+	agent, clock := newWatchAgent()
+	task := watchTask(dir, map[string]interface{}{"until": "timeout", "timeout": 0.2, "pattern": "*.log"})
+	lines := make(lineChannel, 1)
+	task.Output = lines
+
+	go func() {
+		watchStarted(clock)
+		// Written in place, the file could be seen truncated before it is written
+		_ = os.WriteFile(log+".tmp", []byte("start\nlinking\n"), 0o644)
+		_ = os.Rename(log+".tmp", log)
+		clock.Advance(5 * time.Millisecond)
+		<-lines
+		_ = os.Remove(log)
+		clock.Advance(5 * time.Millisecond)
+		<-lines
+		clock.Advance(200 * time.Millisecond)
+	}()
+	result := agent.Execute(context.Background(), task)
+	require.True(t, result.Success, result.Output)
+	assert.Contains(t, result.Output, "seeing 2 changes")
+	assert.Contains(t, result.Output, "modified "+log)
+	assert.Contains(t, result.Output, "removed "+log)
+}
+
+func TestFileAgent_WatchFailures(t *testing.T) {
+	dir := t.TempDir()
+	agent, clock := newWatchAgent()
+
+	go func() {
+		watchStarted(clock)
+		clock.Advance(50 * time.Millisecond)
+	}()
+	result := agent.Execute(context.Background(), watchTask(dir, map[string]interface{}{"timeout": "50ms"}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, "timed out after 50ms waiting for change")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = agent.Execute(ctx, watchTask(dir, nil))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, "stopped watching")
+
+	result = agent.Execute(context.Background(), watchTask(dir, map[string]interface{}{"until": "forever"}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, `invalid 'until' "forever"`)
+
+	result = agent.Execute(context.Background(), watchTask(dir, map[string]interface{}{"interval": "soon"}))
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Output, `invalid 'interval' "soon"`)
+}
+
+func TestWatchSpec_SnapshotHashesRecentFiles(t *testing.T) {
+	dir := t.TempDir()
+	old, fresh := filepath.Join(dir, "old.txt"), filepath.Join(dir, "fresh.txt")
+	require.NoError(t, os.WriteFile(old, []byte("unchanged"), 0o644))
+	require.NoError(t, os.WriteFile(fresh, []byte("just written"), 0o644))
+	now := time.Now()
+	require.NoError(t, os.Chtimes(old, now.Add(-time.Hour), now.Add(-time.Hour)))
+
+	var read []string
+	spec := watchSpec{paths: []string{dir}, interval: time.Second, readFile: func(name string) ([]byte, error) {
+		read = append(read, name)
+		return os.ReadFile(name)
+	}}
+	states := spec.snapshot(now)
+	assert.Equal(t, []string{fresh}, read, "files not modified lately are not read")
+	assert.True(t, states[fresh].hashed)
+	assert.False(t, states[old].hashed)
+	assert.Equal(t, int64(len("unchanged")), states[old].size, "they are still compared by size and modification time")
+
+	// Once it is no longer recent, a file is not read again either
+	read = nil
+	spec.snapshot(now.Add(time.Minute))
+	assert.Empty(t, read)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+	before := map[string]fileState{
+		"dist":       {modTime: now, dir: true},
+		"dist/a.txt": {modTime: now, size: 1},
+		"dist/b.txt": {modTime: now, size: 1},
+		"dist/c.txt": {modTime: now, size: 1},
+		"dist/e.txt": {modTime: now, size: 1, hash: sha256.Sum256([]byte("a")), hashed: true},
+		"dist/f.bin": {modTime: now, size: 1, hash: sha256.Sum256([]byte("a")), hashed: true},
+	}
+	after := map[string]fileState{
+		"dist":       {modTime: now.Add(time.Second), dir: true},
+		"dist/a.txt": {modTime: now, size: 1},
+		"dist/b.txt": {modTime: now, size: 2},
+		"dist/d.txt": {modTime: now, size: 1},
+		// Rewritten within the modification time's resolution, keeping its size
+		"dist/e.txt": {modTime: now, size: 1, hash: sha256.Sum256([]byte("b")), hashed: true},
+		// Unreadable when last looked at, so only its size and modification time are compared
+		"dist/f.bin": {modTime: now, size: 1},
+	}
+	assert.Equal(t, []WatchEvent{
+		{Op: "modified", Path: "dist/b.txt", At: now},
+		{Op: "removed", Path: "dist/c.txt", At: now},
+		{Op: "created", Path: "dist/d.txt", At: now},
+		{Op: "modified", Path: "dist/e.txt", At: now},
+	}, diffSnapshots(before, after, now))
+}
@@ -0,0 +1,34 @@
+package agents
+
+import (
+	"github.com/iainlowe/capn/internal/events"
+)
+
+// PublishingCommunicationLogger wraps a CommunicationLogger and publishes every logged message on an event bus
+type PublishingCommunicationLogger struct {
+	CommunicationLogger
+	bus *events.Bus
+}
+
+// NewPublishingCommunicationLogger creates a communication logger that also publishes agent message events
+func NewPublishingCommunicationLogger(logger CommunicationLogger, bus *events.Bus) *PublishingCommunicationLogger {
+	return &PublishingCommunicationLogger{
+		CommunicationLogger: logger,
+		bus:                 bus,
+	}
+}
+
+// LogMessage logs the message and publishes it as an agent message event
+func (l *PublishingCommunicationLogger) LogMessage(from, to string, message Message) {
+	l.CommunicationLogger.LogMessage(from, to, message)
+
+	taskID, _ := message.Data["task_id"].(string)
+	l.bus.Publish(events.Event{
+		Type:      events.EventAgentMessage,
+		TaskID:    taskID,
+		Agent:     from,
+		Message:   message.Content,
+		Data:      map[string]any{"to": to, "message_type": string(message.Type), "message_id": message.ID},
+		Timestamp: message.Timestamp,
+	})
+}
@@ -0,0 +1,44 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/events"
+)
+
+func TestPublishingCommunicationLogger_LogMessage(t *testing.T) {
+	bus := events.NewBus()
+	sub, err := bus.Subscribe(events.SubscribeOptions{})
+	require.NoError(t, err)
+
+	inner := NewMemoryCommunicationLogger()
+	logger := NewPublishingCommunicationLogger(inner, bus)
+
+	timestamp := time.Now()
+	logger.LogMessage("captain", "file-001", Message{
+		ID:        "msg-1",
+		From:      "captain",
+		To:        "file-001",
+		Content:   "read config.yaml",
+		Type:      MessageTypeCommand,
+		Timestamp: timestamp,
+		Data:      map[string]interface{}{"task_id": "task-1"},
+	})
+
+	// The wrapped logger still records the message
+	assert.Len(t, logger.GetAllMessages(), 1)
+	assert.Len(t, inner.GetHistory("file-001"), 1)
+
+	event := <-sub.Events()
+	assert.Equal(t, events.EventAgentMessage, event.Type)
+	assert.Equal(t, "task-1", event.TaskID)
+	assert.Equal(t, "captain", event.Agent)
+	assert.Equal(t, "read config.yaml", event.Message)
+	assert.Equal(t, "file-001", event.Data["to"])
+	assert.Equal(t, "command", event.Data["message_type"])
+	assert.True(t, timestamp.Equal(event.Timestamp))
+}
@@ -0,0 +1,248 @@
+package agents
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// HealthPolicy decides how the manager reacts to agents that stop being healthy
+type HealthPolicy struct {
+	// RestartUnhealthy recreates unhealthy agents under the same ID
+	RestartUnhealthy bool
+	// MaxRestarts caps the restarts of one agent; zero means no limit
+	MaxRestarts int
+	// RestartBackoff is the minimum time between restarts of one agent
+	RestartBackoff time.Duration
+}
+
+// DefaultHealthPolicy returns the policy used unless another is set
+func DefaultHealthPolicy() HealthPolicy {
+	return HealthPolicy{
+		RestartUnhealthy: true,
+		MaxRestarts:      3,
+		RestartBackoff:   10 * time.Second,
+	}
+}
+
+// HealthAction is what the manager did about an agent's health
+type HealthAction string
+
+const (
+	// HealthActionDegraded means the agent was taken out of scheduling
+	HealthActionDegraded HealthAction = "degraded"
+	// HealthActionRecovered means the agent is healthy and schedulable again
+	HealthActionRecovered HealthAction = "recovered"
+	// HealthActionRestarted means the unhealthy agent was recreated
+	HealthActionRestarted HealthAction = "restarted"
+	// HealthActionRestartFailed means recreating the unhealthy agent failed
+	HealthActionRestartFailed HealthAction = "restart_failed"
+	// HealthActionGaveUp means the agent reached its restart limit and stays unhealthy
+	HealthActionGaveUp HealthAction = "gave_up"
+)
+
+// HealthEvent reports an action taken by the health policy
+type HealthEvent struct {
+	AgentID   string       `json:"agent_id"`
+	AgentType AgentType    `json:"agent_type"`
+	Action    HealthAction `json:"action"`
+	Health    HealthState  `json:"health"`
+	Restarts  int          `json:"restarts"`
+	Error     string       `json:"error,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// healthMonitor holds the health policy and what it has done to each agent
+type healthMonitor struct {
+	mu            sync.Mutex
+	policy        HealthPolicy
+	handler       func(HealthEvent)
+	restarts      map[string]int
+	lastRestart   map[string]time.Time
+	unschedulable map[string]bool
+	gaveUp        map[string]bool
+	clock         common.Clock
+}
+
+func newHealthMonitor() *healthMonitor {
+	return &healthMonitor{
+		policy:        DefaultHealthPolicy(),
+		restarts:      make(map[string]int),
+		lastRestart:   make(map[string]time.Time),
+		unschedulable: make(map[string]bool),
+		gaveUp:        make(map[string]bool),
+		clock:         common.SystemClock,
+	}
+}
+
+func (h *healthMonitor) schedulable(agentID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.unschedulable[agentID]
+}
+
+func (h *healthMonitor) totalRestarts() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	total := 0
+	for _, n := range h.restarts {
+		total += n
+	}
+	return total
+}
+
+func (h *healthMonitor) forget(agentID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.restarts, agentID)
+	delete(h.lastRestart, agentID)
+	delete(h.unschedulable, agentID)
+	delete(h.gaveUp, agentID)
+}
+
+// SetHealthPolicy sets how the manager reacts to unhealthy and degraded agents
+func (m *AgentManager) SetHealthPolicy(policy HealthPolicy) {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	m.health.policy = policy
+}
+
+// SetHealthHandler sets a function called for every action the health policy takes
+func (m *AgentManager) SetHealthHandler(handler func(HealthEvent)) {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	m.health.handler = handler
+}
+
+// IsSchedulable reports whether new work may be given to the agent. Agents are taken out
+// of scheduling while degraded or unhealthy.
+func (m *AgentManager) IsSchedulable(agentID string) bool {
+	return m.health.schedulable(agentID)
+}
+
+// Restarts returns how many times the health policy has restarted the agent
+func (m *AgentManager) Restarts(agentID string) int {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	return m.health.restarts[agentID]
+}
+
+// RestartAgent stops the agent and replaces it with a new one of the same ID, name and
+// type, registered with the router in its place
+func (m *AgentManager) RestartAgent(agentID string) (Agent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, exists := m.agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent with ID %s not found", agentID)
+	}
+
+	// The old agent may be the reason for the restart, so failing to stop it is not fatal
+	_ = old.Stop()
+	if m.router != nil {
+		_ = m.router.UnregisterAgent(agentID)
+	}
+
+	agent, err := m.createAgent(agentID, old.Name(), old.Type())
+	if err != nil {
+		return nil, fmt.Errorf("failed to restart agent %s: %w", agentID, err)
+	}
+	m.agents[agentID] = agent
+
+	m.health.mu.Lock()
+	m.health.restarts[agentID]++
+	m.health.lastRestart[agentID] = m.health.clock.Now()
+	m.health.mu.Unlock()
+	return agent, nil
+}
+
+// applyHealthPolicy acts on one agent's health: degraded and unhealthy agents leave
+// scheduling, unhealthy ones are restarted within the policy's limits, and agents that
+// become healthy again return to scheduling
+func (m *AgentManager) applyHealthPolicy(agent Agent, health HealthStatus) {
+	h := m.health
+	id := agent.ID()
+	event := HealthEvent{AgentID: id, AgentType: agent.Type(), Health: health.Status}
+
+	h.mu.Lock()
+	policy := h.policy
+	event.Restarts = h.restarts[id]
+	event.Timestamp = h.clock.Now()
+
+	switch health.Status {
+	case HealthStatusHealthy:
+		if !h.unschedulable[id] {
+			h.mu.Unlock()
+			return
+		}
+		delete(h.unschedulable, id)
+		delete(h.gaveUp, id)
+		event.Action = HealthActionRecovered
+		h.mu.Unlock()
+		m.emitHealthEvent(event)
+		return
+
+	case HealthStatusDegraded:
+		if h.unschedulable[id] {
+			h.mu.Unlock()
+			return
+		}
+		h.unschedulable[id] = true
+		event.Action = HealthActionDegraded
+		h.mu.Unlock()
+		m.emitHealthEvent(event)
+		return
+	}
+
+	// Unhealthy: keep the agent out of scheduling until a restart brings it back
+	newlyUnschedulable := !h.unschedulable[id]
+	h.unschedulable[id] = true
+	canRestart := policy.RestartUnhealthy && (policy.MaxRestarts == 0 || h.restarts[id] < policy.MaxRestarts)
+	if !canRestart {
+		reported := h.gaveUp[id]
+		h.gaveUp[id] = true
+		h.mu.Unlock()
+		switch {
+		case policy.RestartUnhealthy && !reported:
+			event.Action = HealthActionGaveUp
+			event.Error = fmt.Sprintf("restart limit of %d reached", policy.MaxRestarts)
+			m.emitHealthEvent(event)
+		case !policy.RestartUnhealthy && newlyUnschedulable:
+			event.Action = HealthActionDegraded
+			m.emitHealthEvent(event)
+		}
+		return
+	}
+	if last, restarted := h.lastRestart[id]; restarted && event.Timestamp.Sub(last) < policy.RestartBackoff {
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+
+	if _, err := m.RestartAgent(id); err != nil {
+		// The stopped agent stays managed but out of scheduling
+		event.Action = HealthActionRestartFailed
+		event.Error = err.Error()
+		m.emitHealthEvent(event)
+		return
+	}
+
+	h.mu.Lock()
+	delete(h.unschedulable, id)
+	event.Restarts = h.restarts[id]
+	h.mu.Unlock()
+	event.Action = HealthActionRestarted
+	m.emitHealthEvent(event)
+}
+
+func (m *AgentManager) emitHealthEvent(event HealthEvent) {
+	m.health.mu.Lock()
+	handler := m.health.handler
+	m.health.mu.Unlock()
+	if handler != nil {
+		handler(event)
+	}
+}
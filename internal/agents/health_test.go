@@ -0,0 +1,204 @@
+package agents
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthRecorder collects the health events a manager emits
+type healthRecorder struct {
+	mu     sync.Mutex
+	events []HealthEvent
+}
+
+func (r *healthRecorder) record(event HealthEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *healthRecorder) actions() []HealthAction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	actions := make([]HealthAction, len(r.events))
+	for i, event := range r.events {
+		actions[i] = event.Action
+	}
+	return actions
+}
+
+func newHealthTestManager(t *testing.T, policy HealthPolicy) (*AgentManager, *MessageRouter, *healthRecorder) {
+	t.Helper()
+	manager := NewAgentManager()
+	router := NewMessageRouter()
+	manager.SetRouter(router)
+	manager.SetHealthPolicy(policy)
+	recorder := &healthRecorder{}
+	manager.SetHealthHandler(recorder.record)
+	return manager, router, recorder
+}
+
+func failAgent(t *testing.T, manager *AgentManager, id string) {
+	t.Helper()
+	agent, ok := manager.GetAgent(id)
+	require.True(t, ok)
+	agent.(*BaseAgent).SetStatus(AgentStatusError)
+}
+
+func TestAgentManager_RestartsUnhealthyAgent(t *testing.T) {
+	manager, router, recorder := newHealthTestManager(t, HealthPolicy{RestartUnhealthy: true, MaxRestarts: 2})
+	original, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+
+	restarted, ok := manager.GetAgent("file-1")
+	require.True(t, ok)
+	assert.NotSame(t, original, restarted)
+	assert.Equal(t, AgentStatusIdle, restarted.Status())
+	assert.Equal(t, "FileAgent", restarted.Name())
+	assert.Equal(t, AgentStatusStopped, original.Status())
+	assert.True(t, manager.IsSchedulable("file-1"))
+	assert.Equal(t, 1, manager.Restarts("file-1"))
+
+	// The new agent replaces the old one in the router
+	routed, ok := router.GetAgent("file-1")
+	require.True(t, ok)
+	assert.Same(t, restarted, routed)
+	require.NoError(t, routed.(*BaseAgent).SendMessage("file-1", Message{ID: "m1", Content: "hi"}))
+
+	assert.Equal(t, []HealthAction{HealthActionRestarted}, recorder.actions())
+	stats := manager.GetAgentStats()
+	assert.Equal(t, 1, stats.Restarts)
+	assert.Equal(t, 0, stats.Unschedulable)
+}
+
+func TestAgentManager_RestartLimit(t *testing.T) {
+	manager, _, recorder := newHealthTestManager(t, HealthPolicy{RestartUnhealthy: true, MaxRestarts: 1})
+	_, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+	manager.checkAgentHealth()
+
+	assert.Equal(t, 1, manager.Restarts("file-1"))
+	assert.False(t, manager.IsSchedulable("file-1"))
+	assert.Equal(t, []HealthAction{HealthActionRestarted, HealthActionGaveUp}, recorder.actions())
+	assert.Equal(t, 1, manager.GetAgentStats().Unschedulable)
+}
+
+func TestAgentManager_RestartBackoff(t *testing.T) {
+	manager, _, _ := newHealthTestManager(t, HealthPolicy{RestartUnhealthy: true, RestartBackoff: time.Hour})
+	_, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+
+	assert.Equal(t, 1, manager.Restarts("file-1"))
+	assert.False(t, manager.IsSchedulable("file-1"))
+}
+
+func TestAgentManager_RestartDisabled(t *testing.T) {
+	manager, _, recorder := newHealthTestManager(t, HealthPolicy{})
+	original, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+	manager.checkAgentHealth()
+
+	current, _ := manager.GetAgent("file-1")
+	assert.Same(t, original, current)
+	assert.False(t, manager.IsSchedulable("file-1"))
+	assert.Equal(t, []HealthAction{HealthActionDegraded}, recorder.actions())
+
+	// An agent that recovers is scheduled again
+	original.(*BaseAgent).SetStatus(AgentStatusIdle)
+	manager.checkAgentHealth()
+	assert.True(t, manager.IsSchedulable("file-1"))
+	assert.Equal(t, []HealthAction{HealthActionDegraded, HealthActionRecovered}, recorder.actions())
+}
+
+func TestAgentManager_DegradedAgentUnschedulable(t *testing.T) {
+	manager, _, recorder := newHealthTestManager(t, DefaultHealthPolicy())
+	agent, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	agent.(*BaseAgent).SetStatus(AgentStatusStopped)
+	manager.checkAgentHealth()
+
+	assert.False(t, manager.IsSchedulable("file-1"))
+	assert.Equal(t, 0, manager.Restarts("file-1"))
+	assert.Equal(t, []HealthAction{HealthActionDegraded}, recorder.actions())
+}
+
+func TestAgentManager_RestartFailed(t *testing.T) {
+	manager, _, recorder := newHealthTestManager(t, DefaultHealthPolicy())
+	_, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	manager.RegisterAgentType(AgentTypeFile, func(id, name string) (Agent, error) {
+		return nil, fmt.Errorf("no capacity")
+	})
+
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+
+	assert.Equal(t, []HealthAction{HealthActionRestartFailed}, recorder.actions())
+	assert.Contains(t, recorder.events[0].Error, "no capacity")
+	assert.False(t, manager.IsSchedulable("file-1"))
+	_, ok := manager.GetAgent("file-1")
+	assert.True(t, ok)
+}
+
+func TestAgentManager_TerminateForgetsHealth(t *testing.T) {
+	manager, _, _ := newHealthTestManager(t, HealthPolicy{})
+	_, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	failAgent(t, manager, "file-1")
+	manager.checkAgentHealth()
+	require.False(t, manager.IsSchedulable("file-1"))
+
+	require.NoError(t, manager.TerminateAgent("file-1"))
+	_, err = manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	assert.True(t, manager.IsSchedulable("file-1"))
+}
+
+// panickingHealthAgent panics when its health is checked
+type panickingHealthAgent struct {
+	*BaseAgent
+}
+
+func (a *panickingHealthAgent) Health() HealthStatus {
+	panic("health check bug")
+}
+
+func TestAgentManager_HealthCheckPanic(t *testing.T) {
+	manager, _, recorder := newHealthTestManager(t, HealthPolicy{})
+	manager.RegisterAgentType(AgentType("flaky"), func(id, name string) (Agent, error) {
+		return &panickingHealthAgent{BaseAgent: NewBaseAgent(id, name, AgentType("flaky"))}, nil
+	})
+	_, err := manager.SpawnAgent("flaky-1", "Flaky", AgentType("flaky"))
+	require.NoError(t, err)
+	_, err = manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	require.NotPanics(t, manager.checkAgentHealth)
+	assert.False(t, manager.IsSchedulable("flaky-1"), "an agent whose health check panics is unhealthy")
+	assert.True(t, manager.IsSchedulable("file-1"))
+	require.NotEmpty(t, recorder.events)
+	assert.Equal(t, "flaky-1", recorder.events[0].AgentID)
+	assert.Equal(t, HealthStatusUnhealthy, recorder.events[0].Health)
+}
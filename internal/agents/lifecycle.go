@@ -0,0 +1,198 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// LifecyclePolicy decides when idle agents are terminated and how many are spawned ahead of demand
+type LifecyclePolicy struct {
+	// IdleTTL terminates agents idle for longer; zero keeps idle agents until they are terminated
+	IdleTTL time.Duration
+	// WarmPool is the number of idle agents kept ready for each type. The most recently used idle
+	// agents of a type fill its pool and are exempt from IdleTTL.
+	WarmPool map[AgentType]int
+}
+
+// lifecycle holds the lifecycle policy and when each agent was last in use
+type lifecycle struct {
+	mu         sync.Mutex
+	policy     LifecyclePolicy
+	lastActive map[string]time.Time
+	hits       int
+	misses     int
+	collected  int
+	pooled     int
+	clock      common.Clock
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		lastActive: make(map[string]time.Time),
+		clock:      common.SystemClock,
+	}
+}
+
+func (l *lifecycle) touch(agentID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastActive[agentID] = l.clock.Now()
+}
+
+func (l *lifecycle) forget(agentID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.lastActive, agentID)
+}
+
+// SetLifecyclePolicy sets when idle agents are terminated and how many are kept ready per type
+func (m *AgentManager) SetLifecyclePolicy(policy LifecyclePolicy) {
+	m.lifecycle.mu.Lock()
+	defer m.lifecycle.mu.Unlock()
+	m.lifecycle.policy = policy
+}
+
+// AcquireIdle returns an idle, schedulable agent of the type to run a task on, counting a warm
+// pool hit. It reports false, counting a miss, when the caller must spawn an agent instead.
+func (m *AgentManager) AcquireIdle(agentType AgentType) (Agent, bool) {
+	var found Agent
+	for _, agent := range m.GetManagedAgents() {
+		if agent.Type() == agentType && agent.Status() == AgentStatusIdle && m.IsSchedulable(agent.ID()) {
+			found = agent
+			break
+		}
+	}
+
+	m.lifecycle.mu.Lock()
+	defer m.lifecycle.mu.Unlock()
+	if found == nil {
+		m.lifecycle.misses++
+		return nil, false
+	}
+	m.lifecycle.hits++
+	m.lifecycle.lastActive[found.ID()] = m.lifecycle.clock.Now()
+	return found, true
+}
+
+// CollectIdle terminates agents idle for longer than the policy's IdleTTL, keeping each type's
+// warm pool, and returns the IDs of the agents it terminated
+func (m *AgentManager) CollectIdle() ([]string, error) {
+	m.lifecycle.mu.Lock()
+	policy := m.lifecycle.policy
+	now := m.lifecycle.clock.Now()
+	m.lifecycle.mu.Unlock()
+	if policy.IdleTTL <= 0 {
+		return nil, nil
+	}
+
+	idle := make(map[AgentType][]Agent)
+	for _, agent := range m.GetManagedAgents() {
+		switch agent.Status() {
+		case AgentStatusBusy:
+			// An agent is in use for as long as it is running a task
+			m.lifecycle.touch(agent.ID())
+		case AgentStatusIdle:
+			idle[agent.Type()] = append(idle[agent.Type()], agent)
+		}
+	}
+
+	var expired []string
+	m.lifecycle.mu.Lock()
+	for agentType, candidates := range idle {
+		// The most recently used agents stay to fill the pool
+		sort.Slice(candidates, func(i, j int) bool {
+			return m.lifecycle.lastActive[candidates[i].ID()].After(m.lifecycle.lastActive[candidates[j].ID()])
+		})
+		for _, agent := range candidates[min(policy.WarmPool[agentType], len(candidates)):] {
+			if now.Sub(m.lifecycle.lastActive[agent.ID()]) > policy.IdleTTL {
+				expired = append(expired, agent.ID())
+			}
+		}
+	}
+	m.lifecycle.mu.Unlock()
+	sort.Strings(expired)
+
+	var collected []string
+	var errs []error
+	for _, agentID := range expired {
+		if err := m.TerminateAgent(agentID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		collected = append(collected, agentID)
+	}
+	m.lifecycle.mu.Lock()
+	m.lifecycle.collected += len(collected)
+	m.lifecycle.mu.Unlock()
+	return collected, errors.Join(errs...)
+}
+
+// FillWarmPool spawns agents until every type has its warm pool of idle agents
+func (m *AgentManager) FillWarmPool() error {
+	m.lifecycle.mu.Lock()
+	pool := make(map[AgentType]int, len(m.lifecycle.policy.WarmPool))
+	for agentType, size := range m.lifecycle.policy.WarmPool {
+		pool[agentType] = size
+	}
+	m.lifecycle.mu.Unlock()
+
+	ready := make(map[AgentType]int)
+	for _, agent := range m.GetManagedAgents() {
+		if agent.Status() == AgentStatusIdle && m.IsSchedulable(agent.ID()) {
+			ready[agent.Type()]++
+		}
+	}
+
+	types := make([]AgentType, 0, len(pool))
+	for agentType := range pool {
+		types = append(types, agentType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	var errs []error
+	for _, agentType := range types {
+		for n := ready[agentType]; n < pool[agentType]; n++ {
+			m.lifecycle.mu.Lock()
+			m.lifecycle.pooled++
+			seq := m.lifecycle.pooled
+			m.lifecycle.mu.Unlock()
+
+			id := fmt.Sprintf("%s-pool-%03d", agentType, seq)
+			if _, err := m.SpawnAgent(id, fmt.Sprintf("%sAgent-pool-%d", agentType, seq), agentType); err != nil {
+				errs = append(errs, fmt.Errorf("failed to fill %s warm pool: %w", agentType, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ManageLifecycle fills the warm pools, then collects idle agents and refills the pools every
+// interval until the context is cancelled, reporting failures to onError
+func (m *AgentManager) ManageLifecycle(ctx context.Context, interval time.Duration, onError func(error)) {
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	report(m.FillWarmPool())
+
+	ticker := m.getClock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			_, err := m.CollectIdle()
+			report(err)
+			report(m.FillWarmPool())
+		}
+	}
+}
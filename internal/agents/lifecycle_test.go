@@ -0,0 +1,138 @@
+package agents
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// newLifecycleTestManager returns a manager whose lifecycle clock is moved with the returned function
+func newLifecycleTestManager(policy LifecyclePolicy) (*AgentManager, func(time.Duration)) {
+	manager := NewAgentManager()
+	manager.SetLifecyclePolicy(policy)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	manager.SetClock(clock)
+	return manager, clock.Advance
+}
+
+func TestAgentManager_AcquireIdle(t *testing.T) {
+	manager, _ := newLifecycleTestManager(LifecyclePolicy{})
+
+	_, ok := manager.AcquireIdle(AgentTypeFile)
+	assert.False(t, ok)
+
+	busy, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	busy.(*BaseAgent).SetStatus(AgentStatusBusy)
+	_, ok = manager.AcquireIdle(AgentTypeFile)
+	assert.False(t, ok, "busy agents are not handed out")
+
+	_, err = manager.SpawnAgent("file-2", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+	agent, ok := manager.AcquireIdle(AgentTypeFile)
+	require.True(t, ok)
+	assert.Equal(t, "file-2", agent.ID())
+
+	stats := manager.GetAgentStats()
+	assert.Equal(t, 1, stats.PoolHits)
+	assert.Equal(t, 2, stats.PoolMisses)
+}
+
+func TestAgentManager_CollectIdle(t *testing.T) {
+	manager, advance := newLifecycleTestManager(LifecyclePolicy{
+		IdleTTL:  time.Minute,
+		WarmPool: map[AgentType]int{AgentTypeFile: 1},
+	})
+	for _, id := range []string{"file-1", "file-2"} {
+		_, err := manager.SpawnAgent(id, "FileAgent", AgentTypeFile)
+		require.NoError(t, err)
+		advance(time.Second)
+	}
+	busy, err := manager.SpawnAgent("network-1", "NetworkAgent", AgentTypeNetwork)
+	require.NoError(t, err)
+	busy.(*BaseAgent).SetStatus(AgentStatusBusy)
+
+	collected, err := manager.CollectIdle()
+	require.NoError(t, err)
+	assert.Empty(t, collected, "no agent has been idle past the TTL")
+
+	advance(2 * time.Minute)
+	collected, err = manager.CollectIdle()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file-1"}, collected, "the most recently used file agent fills the pool")
+	_, ok := manager.GetAgent("file-2")
+	assert.True(t, ok)
+	_, ok = manager.GetAgent("network-1")
+	assert.True(t, ok, "busy agents are never collected")
+
+	// An agent counts as used until the sweep after it finishes its task
+	busy.(*BaseAgent).SetStatus(AgentStatusIdle)
+	advance(30 * time.Second)
+	collected, err = manager.CollectIdle()
+	require.NoError(t, err)
+	assert.Empty(t, collected)
+	advance(2 * time.Minute)
+	collected, err = manager.CollectIdle()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"network-1"}, collected)
+	assert.Equal(t, 2, manager.GetAgentStats().IdleCollected)
+
+	manager.SetLifecyclePolicy(LifecyclePolicy{})
+	advance(time.Hour)
+	collected, err = manager.CollectIdle()
+	require.NoError(t, err)
+	assert.Empty(t, collected, "without a TTL idle agents are kept")
+}
+
+func TestAgentManager_FillWarmPool(t *testing.T) {
+	manager, _ := newLifecycleTestManager(LifecyclePolicy{WarmPool: map[AgentType]int{AgentTypeFile: 2, AgentTypeResearch: 1}})
+	_, err := manager.SpawnAgent("file-1", "FileAgent", AgentTypeFile)
+	require.NoError(t, err)
+
+	require.NoError(t, manager.FillWarmPool())
+	stats := manager.GetAgentStats()
+	assert.Equal(t, 2, stats.ByType[AgentTypeFile])
+	assert.Equal(t, 1, stats.ByType[AgentTypeResearch])
+	_, ok := manager.GetAgent("file-pool-001")
+	assert.True(t, ok)
+
+	require.NoError(t, manager.FillWarmPool())
+	assert.Equal(t, 3, manager.GetAgentStats().Total, "a full pool is left alone")
+
+	agent, ok := manager.AcquireIdle(AgentTypeResearch)
+	require.True(t, ok)
+	agent.(*BaseAgent).SetStatus(AgentStatusBusy)
+	require.NoError(t, manager.FillWarmPool())
+	assert.Equal(t, 2, manager.GetAgentStats().ByType[AgentTypeResearch], "taking a pooled agent refills the pool")
+
+	manager.SetLifecyclePolicy(LifecyclePolicy{WarmPool: map[AgentType]int{"unknown": 1}})
+	assert.ErrorContains(t, manager.FillWarmPool(), "failed to fill unknown warm pool")
+}
+
+func TestAgentManager_ManageLifecycle(t *testing.T) {
+	manager := NewAgentManager()
+	manager.SetLifecyclePolicy(LifecyclePolicy{WarmPool: map[AgentType]int{AgentTypeFile: 1, "unknown": 1}})
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	manager.SetClock(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.ManageLifecycle(ctx, time.Hour, func(err error) { errs <- err })
+	}()
+
+	assert.ErrorContains(t, <-errs, "failed to fill unknown warm pool")
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	assert.ErrorContains(t, <-errs, "failed to fill unknown warm pool", "the pools are refilled every interval")
+	cancel()
+	<-done
+	assert.Equal(t, 1, manager.GetAgentStats().ByType[AgentTypeFile])
+}
@@ -0,0 +1,23 @@
+package agents
+
+import "time"
+
+// LLMUsage records the LLM call behind a plan or a step, so a change in their quality can be
+// traced to the model that produced them
+type LLMUsage struct {
+	Provider    string        `json:"provider,omitempty"`
+	Model       string        `json:"model,omitempty"`
+	Temperature float64       `json:"temperature"`
+	Tokens      int           `json:"tokens,omitempty"`
+	Latency     time.Duration `json:"latency"`
+}
+
+// Add adds the tokens and latency of another call to u, keeping u's provider, model and
+// temperature unless it has none
+func (u *LLMUsage) Add(other LLMUsage) {
+	if u.Provider == "" && u.Model == "" {
+		u.Provider, u.Model, u.Temperature = other.Provider, other.Model, other.Temperature
+	}
+	u.Tokens += other.Tokens
+	u.Latency += other.Latency
+}
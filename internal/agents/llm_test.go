@@ -0,0 +1,16 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLLMUsage_Add(t *testing.T) {
+	var usage LLMUsage
+	usage.Add(LLMUsage{Provider: "openai", Model: "gpt-4o-mini", Temperature: 0.2, Tokens: 120, Latency: time.Second})
+	usage.Add(LLMUsage{Provider: "ollama", Model: "llama3", Temperature: 0.7, Tokens: 80, Latency: 500 * time.Millisecond})
+
+	assert.Equal(t, LLMUsage{Provider: "openai", Model: "gpt-4o-mini", Temperature: 0.2, Tokens: 200, Latency: 1500 * time.Millisecond}, usage)
+}
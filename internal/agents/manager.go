@@ -3,6 +3,7 @@ package agents
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 	
@@ -17,6 +18,17 @@ type AgentStats struct {
 	Stopped int               `json:"stopped"`
 	Error   int               `json:"error"`
 	ByType  map[AgentType]int `json:"by_type"`
+
+	Unschedulable int `json:"unschedulable"`
+	Restarts      int `json:"restarts"`
+
+	// PoolHits and PoolMisses count tasks that found an idle agent and tasks that had to spawn one
+	PoolHits      int `json:"pool_hits"`
+	PoolMisses    int `json:"pool_misses"`
+	IdleCollected int `json:"idle_collected"`
+
+	// Activity holds what each agent has done, keyed by agent ID
+	Activity map[string]AgentActivity `json:"activity,omitempty"`
 }
 
 // AgentManager handles the lifecycle of agents
@@ -24,7 +36,11 @@ type AgentManager struct {
 	mu       sync.RWMutex
 	agents   map[string]Agent
 	router   *MessageRouter
-	registry *AgentRegistry
+	registry  *AgentRegistry
+	health    *healthMonitor
+	lifecycle *lifecycle
+	activity  *activityTracker
+	clock     common.Clock
 }
 
 // NewAgentManager creates a new agent manager
@@ -47,10 +63,39 @@ func NewAgentManager() *AgentManager {
 
 	return &AgentManager{
 		agents:   make(map[string]Agent),
-		registry: registry,
+		registry:  registry,
+		health:    newHealthMonitor(),
+		lifecycle: newLifecycle(),
+		activity:  newActivityTracker(),
+		clock:     common.SystemClock,
 	}
 }
 
+// SetClock sets the clock the manager polls, monitors and times agent activity with
+func (m *AgentManager) SetClock(clock common.Clock) {
+	m.mu.Lock()
+	m.clock = clock
+	m.mu.Unlock()
+	m.lifecycle.mu.Lock()
+	m.lifecycle.clock = clock
+	m.lifecycle.mu.Unlock()
+	m.health.mu.Lock()
+	m.health.clock = clock
+	m.health.mu.Unlock()
+}
+
+// getClock returns the manager's clock
+func (m *AgentManager) getClock() common.Clock {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clock
+}
+
+// RegisterAgentType registers (or replaces) the creator used to spawn agents of a type
+func (m *AgentManager) RegisterAgentType(agentType AgentType, creator AgentCreator) {
+	m.registry.Register(agentType, creator)
+}
+
 // SetRouter sets the message router for the manager
 func (m *AgentManager) SetRouter(router *MessageRouter) {
 	m.mu.Lock()
@@ -68,6 +113,21 @@ func (m *AgentManager) SpawnAgent(id, name string, agentType AgentType) (Agent,
 		return nil, fmt.Errorf("agent with ID %s already exists", id)
 	}
 
+	agent, err := m.createAgent(id, name, agentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store in manager
+	m.agents[id] = agent
+	m.lifecycle.touch(id)
+
+	return agent, nil
+}
+
+// createAgent creates an agent using the registry and connects it to the router.
+// The caller must hold m.mu.
+func (m *AgentManager) createAgent(id, name string, agentType AgentType) (Agent, error) {
 	// Create the appropriate agent type using registry
 	agent, err := m.registry.CreateAgent(id, name, agentType)
 	if err != nil {
@@ -76,8 +136,8 @@ func (m *AgentManager) SpawnAgent(id, name string, agentType AgentType) (Agent,
 
 	// Set router if available and agent supports it
 	if m.router != nil {
-		if baseAgent, ok := agent.(*BaseAgent); ok {
-			baseAgent.SetRouter(m.router)
+		if routed, ok := agent.(interface{ SetRouter(*MessageRouter) }); ok {
+			routed.SetRouter(m.router)
 		}
 
 		// Register with router
@@ -86,9 +146,6 @@ func (m *AgentManager) SpawnAgent(id, name string, agentType AgentType) (Agent,
 		}
 	}
 
-	// Store in manager
-	m.agents[id] = agent
-
 	return agent, nil
 }
 
@@ -117,6 +174,9 @@ func (m *AgentManager) TerminateAgent(agentID string) error {
 
 	// Remove from manager
 	delete(m.agents, agentID)
+	m.health.forget(agentID)
+	m.lifecycle.forget(agentID)
+	m.activity.forget(agentID)
 
 	return nil
 }
@@ -143,6 +203,11 @@ func (m *AgentManager) TerminateAll() error {
 	}
 
 	// Clear all agents
+	for agentID := range m.agents {
+		m.health.forget(agentID)
+		m.lifecycle.forget(agentID)
+		m.activity.forget(agentID)
+	}
 	m.agents = make(map[string]Agent)
 
 	if len(errors) > 0 {
@@ -152,6 +217,39 @@ func (m *AgentManager) TerminateAll() error {
 	return nil
 }
 
+// shutdownPollInterval is how often Shutdown checks whether busy agents have finished
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown waits for busy agents to finish their current task, then terminates all agents.
+// It returns the IDs of agents that were still busy when ctx expired.
+func (m *AgentManager) Shutdown(ctx context.Context) ([]string, error) {
+	ticker := m.getClock().NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	busy := m.busyAgents()
+	for len(busy) > 0 {
+		select {
+		case <-ctx.Done():
+			return busy, m.TerminateAll()
+		case <-ticker.C():
+			busy = m.busyAgents()
+		}
+	}
+	return nil, m.TerminateAll()
+}
+
+// busyAgents returns the sorted IDs of agents currently executing a task
+func (m *AgentManager) busyAgents() []string {
+	var busy []string
+	for _, agent := range m.GetManagedAgents() {
+		if agent.Status() == AgentStatusBusy {
+			busy = append(busy, agent.ID())
+		}
+	}
+	sort.Strings(busy)
+	return busy
+}
+
 // GetManagedAgents returns a copy of all managed agents
 func (m *AgentManager) GetManagedAgents() []Agent {
 	return common.CollectMapValues(&m.mu, m.agents)
@@ -168,14 +266,14 @@ func (m *AgentManager) GetAgent(agentID string) (Agent, bool) {
 
 // MonitorAgents periodically checks the health of all managed agents
 func (m *AgentManager) MonitorAgents(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
+	ticker := m.getClock().NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			m.checkAgentHealth()
 		}
 	}
@@ -191,15 +289,22 @@ func (m *AgentManager) checkAgentHealth() {
 	m.mu.RUnlock()
 
 	for _, agent := range agents {
-		health := agent.Health()
+		m.applyHealthPolicy(agent, probeHealth(agent, m.getClock()))
+	}
+}
 
-		// In a full implementation, we might take action based on health status
-		// For now, just check that the agent is responding
-		if health.Status == HealthStatusUnhealthy {
-			// Could implement recovery logic here
-			// For now, just continue monitoring
-		}
+// probeHealth returns an agent's health, reporting an agent whose health check panics as
+// unhealthy instead of stopping the monitor
+func probeHealth(agent Agent, clock common.Clock) HealthStatus {
+	var health HealthStatus
+	err := common.Recover("agent "+agent.ID()+" health check", func() error {
+		health = agent.Health()
+		return nil
+	})
+	if err != nil {
+		return HealthStatus{Status: HealthStatusUnhealthy, Message: err.Error(), Timestamp: clock.Now()}
 	}
+	return health
 }
 
 // GetAgentStats returns statistics about managed agents
@@ -208,7 +313,8 @@ func (m *AgentManager) GetAgentStats() AgentStats {
 	defer m.mu.RUnlock()
 
 	stats := AgentStats{
-		ByType: make(map[AgentType]int),
+		ByType:   make(map[AgentType]int),
+		Activity: make(map[string]AgentActivity),
 	}
 
 	for _, agent := range m.agents {
@@ -229,7 +335,19 @@ func (m *AgentManager) GetAgentStats() AgentStats {
 		// Count by type
 		agentType := agent.Type()
 		stats.ByType[agentType]++
+
+		if !m.health.schedulable(agent.ID()) {
+			stats.Unschedulable++
+		}
+		stats.Activity[agent.ID()] = m.activity.of(agent)
 	}
+	stats.Restarts = m.health.totalRestarts()
+
+	m.lifecycle.mu.Lock()
+	stats.PoolHits = m.lifecycle.hits
+	stats.PoolMisses = m.lifecycle.misses
+	stats.IdleCollected = m.lifecycle.collected
+	m.lifecycle.mu.Unlock()
 
 	return stats
 }
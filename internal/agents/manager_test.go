@@ -7,6 +7,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/testutil"
 )
 
 func TestAgentManager_SpawnAgent(t *testing.T) {
@@ -98,16 +100,28 @@ func TestAgentManager_MonitorAgents(t *testing.T) {
 	agent, err := manager.SpawnAgent("file-1", "FileAgent-1", AgentTypeFile)
 	require.NoError(t, err)
 	
-	// Start monitoring in background
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	
-	go manager.MonitorAgents(ctx, 100*time.Millisecond)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	manager.SetClock(clock)
+	var events []HealthEvent
+	manager.SetHealthHandler(func(event HealthEvent) { events = append(events, event) })
 	
-	// Let monitoring run for a bit
-	time.Sleep(200 * time.Millisecond)
+	// Start monitoring in background
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.MonitorAgents(ctx, 100*time.Millisecond)
+	}()
+	
+	// Let monitoring run for two checks
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	cancel()
+	<-done
 	
 	// Agent should still be healthy
+	assert.Empty(t, events)
 	assert.Equal(t, AgentStatusIdle, agent.Status())
 	health := agent.Health()
 	assert.Equal(t, HealthStatusHealthy, health.Status)
@@ -168,4 +182,63 @@ func TestAgentManager_GetAgentStats(t *testing.T) {
 	assert.Contains(t, stats.ByType, AgentTypeNetwork)
 	assert.Equal(t, 2, stats.ByType[AgentTypeFile])
 	assert.Equal(t, 1, stats.ByType[AgentTypeNetwork])
-}
\ No newline at end of file
+}
+func TestAgentManager_RegisterAgentType(t *testing.T) {
+	manager := NewAgentManager()
+
+	custom := AgentType("custom")
+	_, err := manager.SpawnAgent("custom-1", "Custom-1", custom)
+	assert.Error(t, err)
+
+	manager.RegisterAgentType(custom, func(id, name string) (Agent, error) {
+		return NewBaseAgent(id, name, custom), nil
+	})
+
+	agent, err := manager.SpawnAgent("custom-1", "Custom-1", custom)
+	require.NoError(t, err)
+	assert.Equal(t, custom, agent.Type())
+}
+
+func TestAgentManager_Shutdown(t *testing.T) {
+	t.Run("waits for busy agents", func(t *testing.T) {
+		manager := NewAgentManager()
+		agent, err := manager.SpawnAgent("file-1", "FileAgent-1", AgentTypeFile)
+		require.NoError(t, err)
+
+		clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+		manager.SetClock(clock)
+
+		base := agent.(*BaseAgent)
+		base.SetStatus(AgentStatusBusy)
+		go func() {
+			clock.BlockUntil(1)
+			base.SetStatus(AgentStatusIdle)
+			clock.Advance(shutdownPollInterval)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		interrupted, err := manager.Shutdown(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, interrupted)
+		assert.Empty(t, manager.GetManagedAgents())
+	})
+
+	t.Run("reports agents still busy at the deadline", func(t *testing.T) {
+		manager := NewAgentManager()
+		agent, err := manager.SpawnAgent("file-1", "FileAgent-1", AgentTypeFile)
+		require.NoError(t, err)
+		_, err = manager.SpawnAgent("net-1", "NetworkAgent-1", AgentTypeNetwork)
+		require.NoError(t, err)
+		agent.(*BaseAgent).SetStatus(AgentStatusBusy)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		interrupted, err := manager.Shutdown(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"file-1"}, interrupted)
+		assert.Empty(t, manager.GetManagedAgents())
+	})
+}
@@ -0,0 +1,191 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxOutputSize is how much of each of a command's output streams is kept when the
+// task sets no limit
+const DefaultMaxOutputSize = 1 << 20
+
+// OutputStream names one of a command's output streams
+type OutputStream string
+
+const (
+	OutputStdout OutputStream = "stdout"
+	OutputStderr OutputStream = "stderr"
+)
+
+// OutputSink receives a command's output line by line while it runs
+type OutputSink interface {
+	OutputLine(stream OutputStream, line string)
+}
+
+// OutputSinkFunc adapts a function to an OutputSink
+type OutputSinkFunc func(stream OutputStream, line string)
+
+// OutputLine calls f(stream, line)
+func (f OutputSinkFunc) OutputLine(stream OutputStream, line string) {
+	f(stream, line)
+}
+
+// CommandOutput is what a command wrote to its output streams, each cut to the size limit
+type CommandOutput struct {
+	Stdout string
+	Stderr string
+	// Truncated is set when either stream went over the limit and lost its end
+	Truncated bool
+	// Usage is the CPU time and peak memory of the command once it has exited
+	Usage ResourceUsage
+}
+
+// Combined returns stdout followed by stderr, as a step's result output
+func (o CommandOutput) Combined() string {
+	return strings.TrimSpace(strings.Join([]string{strings.TrimSpace(o.Stdout), strings.TrimSpace(o.Stderr)}, "\n"))
+}
+
+// OutputCapture collects a command's stdout and stderr separately, keeping up to a size limit
+// of each and streaming complete lines to a sink as they are written
+type OutputCapture struct {
+	Stdout *CapturedStream
+	Stderr *CapturedStream
+
+	mu sync.Mutex // Serializes lines from both streams into the sink
+}
+
+// NewOutputCapture creates a capture keeping up to maxSize bytes of each stream, or
+// DefaultMaxOutputSize when maxSize is not positive. The sink may be nil.
+func NewOutputCapture(maxSize int, sink OutputSink) *OutputCapture {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxOutputSize
+	}
+	c := &OutputCapture{}
+	c.Stdout = &CapturedStream{capture: c, stream: OutputStdout, sink: sink, max: maxSize}
+	c.Stderr = &CapturedStream{capture: c, stream: OutputStderr, sink: sink, max: maxSize}
+	return c
+}
+
+// Close sends any unfinished last lines to the sink
+func (c *OutputCapture) Close() {
+	c.Stdout.flush()
+	c.Stderr.flush()
+}
+
+// Output returns everything captured so far
+func (c *OutputCapture) Output() CommandOutput {
+	return CommandOutput{
+		Stdout:    c.Stdout.String(),
+		Stderr:    c.Stderr.String(),
+		Truncated: c.Stdout.Truncated() > 0 || c.Stderr.Truncated() > 0,
+	}
+}
+
+func (c *OutputCapture) emit(sink OutputSink, stream OutputStream, line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sink.OutputLine(stream, line)
+}
+
+// CapturedStream is one output stream of a command. Bytes past the size limit are counted
+// but neither kept nor streamed.
+type CapturedStream struct {
+	capture *OutputCapture
+	stream  OutputStream
+	sink    OutputSink
+	max     int
+
+	mu        sync.Mutex
+	kept      bytes.Buffer
+	partial   []byte // The unfinished line not yet sent to the sink
+	truncated int64
+}
+
+// Write keeps p up to the size limit and streams its complete lines. It never fails, so the
+// command is not stopped by a full buffer.
+func (s *CapturedStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(p)
+	keep := min(len(p), s.max-s.kept.Len())
+	if cut := len(p) - keep; cut > 0 {
+		if s.truncated == 0 {
+			defer s.emitTruncation()
+		}
+		s.truncated += int64(cut)
+		p = p[:keep]
+	}
+	s.kept.Write(p)
+
+	if s.sink != nil {
+		s.partial = append(s.partial, p...)
+		for {
+			i := bytes.IndexByte(s.partial, '\n')
+			if i < 0 {
+				break
+			}
+			s.capture.emit(s.sink, s.stream, strings.TrimSuffix(string(s.partial[:i]), "\r"))
+			s.partial = s.partial[i+1:]
+		}
+	}
+	return n, nil
+}
+
+// emitTruncation flushes the cut-off line and tells the sink the rest of the stream is dropped
+func (s *CapturedStream) emitTruncation() {
+	if s.sink == nil {
+		return
+	}
+	s.flushLocked()
+	s.capture.emit(s.sink, s.stream, fmt.Sprintf("[output truncated at %d bytes]", s.max))
+}
+
+// String returns the kept output, ending with a marker when the stream was truncated
+func (s *CapturedStream) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.truncated == 0 {
+		return s.kept.String()
+	}
+	return fmt.Sprintf("%s\n[... %d more bytes truncated]", s.kept.String(), s.truncated)
+}
+
+// Truncated returns how many bytes were dropped past the size limit
+func (s *CapturedStream) Truncated() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.truncated
+}
+
+func (s *CapturedStream) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+}
+
+func (s *CapturedStream) flushLocked() {
+	if s.sink != nil && len(s.partial) > 0 {
+		s.capture.emit(s.sink, s.stream, strings.TrimSuffix(string(s.partial), "\r"))
+	}
+	s.partial = nil
+}
+
+// RunCommand runs script the way Command builds it, capturing stdout and stderr separately up
+// to the task's MaxOutputSize and streaming their lines to the task's Output sink as they come.
+// The output captured before a failure is returned with the error.
+func (t Task) RunCommand(ctx context.Context, script string) (CommandOutput, error) {
+	cmd, err := t.Command(ctx, script)
+	if err != nil {
+		return CommandOutput{}, err
+	}
+	capture := NewOutputCapture(t.MaxOutputSize, t.Output)
+	cmd.Stdout, cmd.Stderr = capture.Stdout, capture.Stderr
+	err = cmd.Run()
+	capture.Close()
+	output := capture.Output()
+	output.Usage = ProcessUsage(cmd.ProcessState)
+	return output, err
+}
@@ -0,0 +1,67 @@
+package agents
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lineRecorder collects the lines streamed to it
+type lineRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *lineRecorder) OutputLine(stream OutputStream, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf("%s: %s", stream, line))
+}
+
+func TestOutputCapture(t *testing.T) {
+	sink := &lineRecorder{}
+	capture := NewOutputCapture(0, sink)
+
+	_, _ = io.WriteString(capture.Stdout, "compiling ")
+	assert.Empty(t, sink.lines, "unfinished lines are held back")
+	_, _ = io.WriteString(capture.Stdout, "pkg/a\r\ncompiling pkg/b\n")
+	_, _ = io.WriteString(capture.Stderr, "warning: unused import\n")
+	_, _ = io.WriteString(capture.Stdout, "done")
+	assert.Equal(t, []string{"stdout: compiling pkg/a", "stdout: compiling pkg/b", "stderr: warning: unused import"}, sink.lines)
+
+	capture.Close()
+	assert.Equal(t, "stdout: done", sink.lines[3], "closing sends the last unfinished line")
+	assert.Equal(t, CommandOutput{
+		Stdout: "compiling pkg/a\r\ncompiling pkg/b\ndone",
+		Stderr: "warning: unused import\n",
+	}, capture.Output())
+	assert.Equal(t, "compiling pkg/a\r\ncompiling pkg/b\ndone\nwarning: unused import", capture.Output().Combined())
+}
+
+func TestOutputCapture_Truncation(t *testing.T) {
+	sink := &lineRecorder{}
+	capture := NewOutputCapture(10, sink)
+
+	n, err := io.WriteString(capture.Stderr, "line one\nline two\n")
+	assert.NoError(t, err, "writes past the limit do not fail the command")
+	assert.Equal(t, 18, n)
+	_, _ = io.WriteString(capture.Stderr, "more\n")
+	_, _ = io.WriteString(capture.Stdout, "ok\n")
+	capture.Close()
+
+	assert.Equal(t, []string{"stderr: line one", "stderr: l", "stderr: [output truncated at 10 bytes]", "stdout: ok"}, sink.lines)
+	assert.Equal(t, "line one\nl\n[... 13 more bytes truncated]", capture.Stderr.String())
+	assert.Equal(t, int64(13), capture.Stderr.Truncated())
+	assert.Equal(t, "ok\n", capture.Stdout.String())
+	assert.True(t, capture.Output().Truncated)
+}
+
+func TestOutputCapture_WithoutSink(t *testing.T) {
+	capture := NewOutputCapture(4, nil)
+	_, _ = io.WriteString(capture.Stdout, "hello\n")
+	capture.Close()
+	assert.Equal(t, "hell\n[... 2 more bytes truncated]", capture.Stdout.String())
+}
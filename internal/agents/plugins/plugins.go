@@ -0,0 +1,128 @@
+// Package plugins lets agent types be added to capn without changing it: in-process Go
+// plugins register through a small interface, and external programs declared under
+// agents.plugins in the configuration are spawned and spoken to over stdio.
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// Plugin provides an agent type implemented in Go and compiled into capn
+type Plugin interface {
+	// Type is the agent type the plugin provides
+	Type() agents.AgentType
+	// NewAgent creates an agent of the plugin's type
+	NewAgent(id, name string) (agents.Agent, error)
+}
+
+// Registrar is where plugin agent types are installed, such as an AgentManager
+type Registrar interface {
+	RegisterAgentType(agentType agents.AgentType, creator agents.AgentCreator)
+}
+
+var (
+	mu         sync.RWMutex
+	registered = make(map[agents.AgentType]Plugin)
+)
+
+// Register makes a Go plugin available to every agent manager set up with Install.
+// It is meant to be called from an init function; registering a type twice panics.
+func Register(plugin Plugin) {
+	mu.Lock()
+	defer mu.Unlock()
+	agentType := plugin.Type()
+	if isBuiltin(agentType) {
+		panic(fmt.Sprintf("plugins: agent type %s is built in", agentType))
+	}
+	if _, exists := registered[agentType]; exists {
+		panic(fmt.Sprintf("plugins: agent type %s registered twice", agentType))
+	}
+	registered[agentType] = plugin
+}
+
+// Registered returns the registered Go plugins ordered by type
+func Registered() []Plugin {
+	mu.RLock()
+	defer mu.RUnlock()
+	plugins := make([]Plugin, 0, len(registered))
+	for _, plugin := range registered {
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Type() < plugins[j].Type() })
+	return plugins
+}
+
+func isBuiltin(agentType agents.AgentType) bool {
+	for _, builtin := range config.BuiltinAgentTypes {
+		if string(agentType) == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// Install registers the Go plugins and the external plugins declared in configs with the
+// registrar. External plugins are started when an agent of their type is created.
+func Install(registrar Registrar, configs []config.PluginConfig, logger *zap.Logger) error {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	for _, plugin := range Registered() {
+		registrar.RegisterAgentType(plugin.Type(), plugin.NewAgent)
+	}
+	for _, cfg := range configs {
+		agentType := agents.AgentType(cfg.Type)
+		if isBuiltin(agentType) {
+			return fmt.Errorf("plugin %s: type %q is built in", cfg.DisplayName(), cfg.Type)
+		}
+		cfg := cfg
+		pluginLogger := logger.With(zap.String("plugin", cfg.DisplayName()))
+		registrar.RegisterAgentType(agentType, func(id, name string) (agents.Agent, error) {
+			return StartProcessAgent(id, name, cfg, pluginLogger)
+		})
+	}
+	return nil
+}
+
+// ExecuteFunc runs a task for a FuncAgent
+type ExecuteFunc func(ctx context.Context, task agents.Task) agents.Result
+
+// FuncAgent is an agent whose work is done by a single function, which is usually all a
+// Go plugin needs
+type FuncAgent struct {
+	*agents.BaseAgent
+	execute ExecuteFunc
+}
+
+// NewFuncAgent creates an agent of the given type running tasks with execute
+func NewFuncAgent(id, name string, agentType agents.AgentType, execute ExecuteFunc) *FuncAgent {
+	return &FuncAgent{BaseAgent: agents.NewBaseAgent(id, name, agentType), execute: execute}
+}
+
+// Execute runs the task with the agent's function, marking the agent busy meanwhile
+func (a *FuncAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	a.SetStatus(agents.AgentStatusBusy)
+	defer a.SetStatus(agents.AgentStatusIdle)
+
+	start := time.Now()
+	result := a.execute(ctx, task)
+	if result.TaskID == "" {
+		result.TaskID = task.ID
+	}
+	if result.Duration == 0 {
+		result.Duration = time.Since(start)
+	}
+	if result.Timestamp.IsZero() {
+		result.Timestamp = time.Now()
+	}
+	return result
+}
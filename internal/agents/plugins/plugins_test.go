@@ -0,0 +1,108 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+type testPlugin struct {
+	agentType agents.AgentType
+}
+
+func (p testPlugin) Type() agents.AgentType { return p.agentType }
+
+func (p testPlugin) NewAgent(id, name string) (agents.Agent, error) {
+	return NewFuncAgent(id, name, p.agentType, func(ctx context.Context, task agents.Task) agents.Result {
+		return agents.Result{Success: true, Output: "ran " + task.ID}
+	}), nil
+}
+
+// withRegistry runs the test against an empty plugin registry
+func withRegistry(t *testing.T) {
+	t.Helper()
+	mu.Lock()
+	saved := registered
+	registered = make(map[agents.AgentType]Plugin)
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		registered = saved
+		mu.Unlock()
+	})
+}
+
+func TestRegister(t *testing.T) {
+	withRegistry(t)
+
+	Register(testPlugin{agentType: "lint"})
+	Register(testPlugin{agentType: "deploy"})
+
+	plugins := Registered()
+	require.Len(t, plugins, 2)
+	assert.Equal(t, agents.AgentType("deploy"), plugins[0].Type())
+	assert.Equal(t, agents.AgentType("lint"), plugins[1].Type())
+
+	assert.Panics(t, func() { Register(testPlugin{agentType: "lint"}) })
+	assert.Panics(t, func() { Register(testPlugin{agentType: "file"}) })
+}
+
+func TestInstall(t *testing.T) {
+	withRegistry(t)
+	Register(testPlugin{agentType: "lint"})
+
+	manager := agents.NewAgentManager()
+	router := agents.NewMessageRouter()
+	manager.SetRouter(router)
+	require.NoError(t, Install(manager, []config.PluginConfig{{
+		Type:    "helper",
+		Command: os.Args[0],
+		Env:     map[string]string{helperEnv: "1"},
+	}}, nil))
+
+	lint, err := manager.SpawnAgent("lint-1", "Linter", "lint")
+	require.NoError(t, err)
+	result := lint.Execute(context.Background(), agents.Task{ID: "task-1"})
+	assert.True(t, result.Success)
+	assert.Equal(t, "ran task-1", result.Output)
+	assert.Equal(t, "task-1", result.TaskID)
+
+	helper, err := manager.SpawnAgent("helper-1", "Helper", "helper")
+	require.NoError(t, err)
+	defer func() { _ = manager.TerminateAll() }()
+	_, routed := router.GetAgent("helper-1")
+	assert.True(t, routed)
+	result = helper.Execute(context.Background(), agents.Task{ID: "task-2", Type: "echo", Description: "hi"})
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, "helper-1: hi", result.Output)
+}
+
+func TestInstall_BuiltinType(t *testing.T) {
+	withRegistry(t)
+
+	err := Install(agents.NewAgentManager(), []config.PluginConfig{{Type: "network", Command: "net"}}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `type "network" is built in`)
+}
+
+func TestFuncAgent_Execute(t *testing.T) {
+	var status agents.AgentStatus
+	var agent *FuncAgent
+	agent = NewFuncAgent("f-1", "Func", "func", func(ctx context.Context, task agents.Task) agents.Result {
+		status = agent.Status()
+		return agents.Result{Success: true}
+	})
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1"})
+	assert.True(t, result.Success)
+	assert.Equal(t, "task-1", result.TaskID)
+	assert.False(t, result.Timestamp.IsZero())
+	assert.Equal(t, agents.AgentStatusBusy, status)
+	assert.Equal(t, agents.AgentStatusIdle, agent.Status())
+}
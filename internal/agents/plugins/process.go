@@ -0,0 +1,328 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// StopTimeout is how long a plugin process has to exit after being asked to shut down
+const StopTimeout = 5 * time.Second
+
+// maxFrameSize bounds a single protocol line
+const maxFrameSize = 16 << 20
+
+// ProcessAgent is an agent whose work is done by an external plugin process. Tasks and
+// routed messages are written to the process as frames; the results and messages it
+// writes back are returned from Execute and routed to other agents.
+type ProcessAgent struct {
+	*agents.BaseAgent
+	config config.PluginConfig
+	logger *zap.Logger
+
+	cmd     *exec.Cmd
+	writeMu sync.Mutex
+	stdin   io.WriteCloser
+
+	mu      sync.Mutex
+	pending map[string]chan agents.Result
+	nextID  int
+	exited  chan struct{}
+	exitErr error
+}
+
+// StartProcessAgent starts the plugin's command for a new agent. The process learns its
+// identity from the CAPN_AGENT_ID, CAPN_AGENT_NAME and CAPN_AGENT_TYPE environment variables.
+func StartProcessAgent(id, name string, cfg config.PluginConfig, logger *zap.Logger) (*ProcessAgent, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), "CAPN_AGENT_ID="+id, "CAPN_AGENT_NAME="+name, "CAPN_AGENT_TYPE="+cfg.Type)
+	for key, value := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", cfg.DisplayName(), err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", cfg.DisplayName(), err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", cfg.DisplayName(), err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", cfg.DisplayName(), err)
+	}
+
+	a := &ProcessAgent{
+		BaseAgent: agents.NewBaseAgent(id, name, agents.AgentType(cfg.Type)),
+		config:    cfg,
+		logger:    logger.With(zap.String("agent_id", id)),
+		cmd:       cmd,
+		stdin:     stdin,
+		pending:   make(map[string]chan agents.Result),
+		exited:    make(chan struct{}),
+	}
+
+	var pipes sync.WaitGroup
+	pipes.Add(2)
+	go func() {
+		defer pipes.Done()
+		a.readFrames(stdout)
+	}()
+	go func() {
+		defer pipes.Done()
+		a.logStderr(stderr)
+	}()
+	go func() {
+		// Wait must only be called once the pipes are drained
+		pipes.Wait()
+		a.finish(cmd.Wait())
+	}()
+	return a, nil
+}
+
+// Execute sends the task to the plugin process and waits for its result
+func (a *ProcessAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	start := time.Now()
+	a.SetStatus(agents.AgentStatusBusy)
+	defer a.restoreStatus()
+
+	a.mu.Lock()
+	if a.isExited() {
+		a.mu.Unlock()
+		return a.failed(task, start, a.exitError())
+	}
+	a.nextID++
+	requestID := strconv.Itoa(a.nextID)
+	reply := make(chan agents.Result, 1)
+	a.pending[requestID] = reply
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, requestID)
+		a.mu.Unlock()
+	}()
+
+	if err := a.write(Frame{Type: FrameExecute, ID: requestID, Task: &task}); err != nil {
+		return a.failed(task, start, err)
+	}
+
+	select {
+	case result := <-reply:
+		if result.TaskID == "" {
+			result.TaskID = task.ID
+		}
+		if result.Duration == 0 {
+			result.Duration = time.Since(start)
+		}
+		if result.Timestamp.IsZero() {
+			result.Timestamp = time.Now()
+		}
+		return result
+	case <-a.exited:
+		return a.failed(task, start, a.exitError())
+	case <-ctx.Done():
+		_ = a.write(Frame{Type: FrameCancel, ID: requestID})
+		return a.failed(task, start, fmt.Errorf("cancelled: %w", ctx.Err()))
+	}
+}
+
+// ReceiveMessage keeps the message and forwards it to the plugin process
+func (a *ProcessAgent) ReceiveMessage(message agents.Message) error {
+	if err := a.BaseAgent.ReceiveMessage(message); err != nil {
+		return err
+	}
+	if err := a.write(Frame{Type: FrameMessage, Message: &message}); err != nil {
+		return fmt.Errorf("failed to deliver message to plugin: %w", err)
+	}
+	return nil
+}
+
+// Stop asks the plugin process to shut down, killing it if it does not exit in time
+func (a *ProcessAgent) Stop() error {
+	_ = a.BaseAgent.Stop()
+	if a.isExited() {
+		return nil
+	}
+
+	_ = a.write(Frame{Type: FrameShutdown})
+	a.writeMu.Lock()
+	_ = a.stdin.Close()
+	a.writeMu.Unlock()
+
+	select {
+	case <-a.exited:
+	case <-time.After(StopTimeout):
+		if err := a.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return fmt.Errorf("failed to kill plugin %s: %w", a.config.DisplayName(), err)
+		}
+		<-a.exited
+	}
+	return nil
+}
+
+// Exited returns a channel closed once the plugin process has exited
+func (a *ProcessAgent) Exited() <-chan struct{} {
+	return a.exited
+}
+
+// write sends one frame to the plugin process
+func (a *ProcessAgent) write(frame Frame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s frame: %w", frame.Type, err)
+	}
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	if _, err := a.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to plugin %s: %w", a.config.DisplayName(), err)
+	}
+	return nil
+}
+
+// readFrames handles the frames the plugin process writes until it closes stdout
+func (a *ProcessAgent) readFrames(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFrameSize)
+	for scanner.Scan() {
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			a.logger.Warn("Ignoring malformed plugin output", zap.String("line", scanner.Text()), zap.Error(err))
+			continue
+		}
+		a.handle(frame)
+	}
+	if err := scanner.Err(); err != nil {
+		a.logger.Warn("Failed to read plugin output", zap.Error(err))
+	}
+}
+
+// handle acts on one frame from the plugin process
+func (a *ProcessAgent) handle(frame Frame) {
+	switch frame.Type {
+	case FrameResult:
+		if frame.Result == nil {
+			a.logger.Warn("Plugin result has no result", zap.String("id", frame.ID))
+			return
+		}
+		a.mu.Lock()
+		reply, ok := a.pending[frame.ID]
+		a.mu.Unlock()
+		if !ok {
+			a.logger.Debug("Ignoring result for unknown request", zap.String("id", frame.ID))
+			return
+		}
+		reply <- *frame.Result
+	case FrameSend:
+		if frame.Message == nil {
+			a.logger.Warn("Plugin send has no message")
+			return
+		}
+		message := *frame.Message
+		if message.ID == "" {
+			message.ID = fmt.Sprintf("%s-msg-%d", a.ID(), time.Now().UnixNano())
+		}
+		if err := a.SendMessage(message.To, message); err != nil {
+			a.logger.Warn("Failed to route plugin message", zap.String("to", frame.Message.To), zap.Error(err))
+		}
+	case FrameLog:
+		a.log(frame.Level, frame.Text)
+	default:
+		a.logger.Warn("Ignoring unknown plugin frame", zap.String("type", frame.Type))
+	}
+}
+
+// log records a plugin log line at its level
+func (a *ProcessAgent) log(level, text string) {
+	switch level {
+	case "debug":
+		a.logger.Debug(text)
+	case "warn":
+		a.logger.Warn(text)
+	case "error":
+		a.logger.Error(text)
+	default:
+		a.logger.Info(text)
+	}
+}
+
+// logStderr logs each line the plugin process writes to stderr
+func (a *ProcessAgent) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		a.logger.Info("Plugin stderr", zap.String("line", scanner.Text()))
+	}
+}
+
+// finish records the process exit and fails outstanding requests
+func (a *ProcessAgent) finish(err error) {
+	a.mu.Lock()
+	if err == nil {
+		err = fmt.Errorf("plugin %s exited", a.config.DisplayName())
+	} else {
+		err = fmt.Errorf("plugin %s exited: %w", a.config.DisplayName(), err)
+	}
+	a.exitErr = err
+	close(a.exited)
+	a.mu.Unlock()
+
+	if a.Status() != agents.AgentStatusStopped {
+		a.logger.Warn("Plugin process exited", zap.Error(err))
+		a.SetStatus(agents.AgentStatusError)
+	}
+}
+
+func (a *ProcessAgent) isExited() bool {
+	select {
+	case <-a.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *ProcessAgent) exitError() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.exitErr
+}
+
+// restoreStatus returns a busy agent to idle unless it stopped or failed meanwhile
+func (a *ProcessAgent) restoreStatus() {
+	if a.Status() == agents.AgentStatusBusy {
+		a.SetStatus(agents.AgentStatusIdle)
+	}
+}
+
+// failed builds the result of a task the plugin could not run
+func (a *ProcessAgent) failed(task agents.Task, start time.Time, err error) agents.Result {
+	return agents.Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     err.Error(),
+		Duration:  time.Since(start),
+		Timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,188 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+const helperEnv = "CAPN_PLUGIN_TEST_HELPER"
+
+// TestMain lets the test binary act as a plugin process when started by the tests below
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		if err := Serve(context.Background(), os.Stdin, os.Stdout, helperHandler{}); err != nil {
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// helperHandler runs test tasks according to their type
+type helperHandler struct{}
+
+func (helperHandler) Execute(ctx context.Context, task agents.Task, peer *Peer) agents.Result {
+	switch task.Type {
+	case "echo":
+		return agents.Result{Success: true, Output: os.Getenv("CAPN_AGENT_ID") + ": " + task.Description}
+	case "send":
+		if err := peer.Send(task.Description, "hello from plugin", nil); err != nil {
+			return agents.Result{Error: err.Error()}
+		}
+		return agents.Result{Success: true}
+	case "block":
+		<-ctx.Done()
+		return agents.Result{Error: "cancelled"}
+	case "exit":
+		os.Exit(3)
+	case "panic":
+		panic("handler bug")
+	}
+	return agents.Result{Error: "unknown task type " + task.Type}
+}
+
+func (helperHandler) HandleMessage(message agents.Message, peer *Peer) {
+	if message.Type == agents.MessageTypeCommand {
+		_ = peer.Reply(message, "done: "+message.Content)
+		return
+	}
+	_ = peer.Send(message.From, "ack: "+message.Content, nil)
+}
+
+func startHelper(t *testing.T, id string) *ProcessAgent {
+	t.Helper()
+	agent, err := StartProcessAgent(id, "Helper", config.PluginConfig{
+		Type:    "helper",
+		Command: os.Args[0],
+		Env:     map[string]string{helperEnv: "1"},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = agent.Stop() })
+	return agent
+}
+
+func TestProcessAgent_Execute(t *testing.T) {
+	agent := startHelper(t, "helper-1")
+
+	assert.Equal(t, agents.AgentType("helper"), agent.Type())
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "echo", Description: "hi"})
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, "task-1", result.TaskID)
+	assert.Equal(t, "helper-1: hi", result.Output)
+	assert.False(t, result.Timestamp.IsZero())
+	assert.Equal(t, agents.AgentStatusIdle, agent.Status())
+}
+
+func TestProcessAgent_HandlerPanic(t *testing.T) {
+	agent := startHelper(t, "helper-1")
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "panic"})
+	assert.False(t, result.Success)
+	assert.Equal(t, "task task-1 panicked: handler bug", result.Error)
+
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-2", Type: "echo", Description: "still here"})
+	assert.True(t, result.Success, "the plugin process survives a panicking task")
+	assert.Equal(t, "helper-1: still here", result.Output)
+}
+
+func TestProcessAgent_Cancel(t *testing.T) {
+	agent := startHelper(t, "helper-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result := agent.Execute(ctx, agents.Task{ID: "task-1", Type: "block"})
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "cancelled")
+
+	// The plugin keeps serving after a cancelled request
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-2", Type: "echo", Description: "again"})
+	assert.True(t, result.Success, result.Error)
+}
+
+func TestProcessAgent_Messages(t *testing.T) {
+	router := agents.NewMessageRouter()
+	peer := agents.NewBaseAgent("peer", "Peer", agents.AgentType("file"))
+	peer.SetRouter(router)
+	require.NoError(t, router.RegisterAgent(peer))
+
+	agent := startHelper(t, "helper-1")
+	agent.SetRouter(router)
+	require.NoError(t, router.RegisterAgent(agent))
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "send", Description: "peer"})
+	require.True(t, result.Success, result.Error)
+
+	require.NoError(t, peer.SendMessage("helper-1", agents.Message{ID: "m1", Content: "ping", Type: agents.MessageTypeText}))
+
+	assert.Eventually(t, func() bool { return len(peer.GetReceivedMessages()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	received := peer.GetReceivedMessages()
+	require.Len(t, received, 2)
+	contents := []string{received[0].Content, received[1].Content}
+	assert.ElementsMatch(t, []string{"hello from plugin", "ack: ping"}, contents)
+	for _, message := range received {
+		assert.Equal(t, "helper-1", message.From)
+		assert.NotEmpty(t, message.ID)
+	}
+	assert.Len(t, agent.GetReceivedMessages(), 1)
+}
+
+func TestProcessAgent_Reply(t *testing.T) {
+	router := agents.NewMessageRouter()
+	agent := startHelper(t, "helper-1")
+	agent.SetRouter(router)
+	require.NoError(t, router.RegisterAgent(agent))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := router.Request(ctx, agents.Message{ID: "m1", From: "operator", To: "helper-1", Content: "re-scan ./src",
+		Type: agents.MessageTypeCommand, Timestamp: time.Now()})
+	require.NoError(t, err)
+	require.NotNil(t, reply)
+	assert.Equal(t, "done: re-scan ./src", reply.Content)
+	assert.Equal(t, "m1", reply.Data[agents.MessageDataInReplyTo])
+}
+
+func TestProcessAgent_Exit(t *testing.T) {
+	agent := startHelper(t, "helper-1")
+
+	result := agent.Execute(context.Background(), agents.Task{ID: "task-1", Type: "exit"})
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "plugin helper exited")
+
+	select {
+	case <-agent.Exited():
+	case <-time.After(5 * time.Second):
+		t.Fatal("plugin process did not exit")
+	}
+	assert.Equal(t, agents.AgentStatusError, agent.Status())
+
+	result = agent.Execute(context.Background(), agents.Task{ID: "task-2", Type: "echo"})
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "plugin helper exited")
+}
+
+func TestProcessAgent_Stop(t *testing.T) {
+	agent := startHelper(t, "helper-1")
+
+	require.NoError(t, agent.Stop())
+	select {
+	case <-agent.Exited():
+	default:
+		t.Fatal("plugin process still running after Stop")
+	}
+	assert.Equal(t, agents.AgentStatusStopped, agent.Status())
+}
+
+func TestStartProcessAgent_MissingCommand(t *testing.T) {
+	_, err := StartProcessAgent("a-1", "A", config.PluginConfig{Type: "missing", Command: "capn-no-such-plugin"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to start plugin missing")
+}
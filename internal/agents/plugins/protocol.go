@@ -0,0 +1,36 @@
+package plugins
+
+import (
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// Frame types of the plugin protocol. A plugin process reads frames from stdin and writes
+// frames to stdout, one JSON object per line; anything it writes to stderr is logged.
+const (
+	// FrameExecute asks the plugin to run Task; the plugin answers with a result of the same ID
+	FrameExecute = "execute"
+	// FrameCancel tells the plugin to abandon the execute request with the given ID
+	FrameCancel = "cancel"
+	// FrameMessage delivers a Message routed to the plugin's agent
+	FrameMessage = "message"
+	// FrameShutdown asks the plugin to finish and exit
+	FrameShutdown = "shutdown"
+
+	// FrameResult carries the Result of the execute request with the given ID
+	FrameResult = "result"
+	// FrameSend asks capn to route Message from the plugin's agent to Message.To
+	FrameSend = "send"
+	// FrameLog records Text at Level in capn's log
+	FrameLog = "log"
+)
+
+// Frame is one line of the plugin protocol
+type Frame struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Task    *agents.Task    `json:"task,omitempty"`
+	Result  *agents.Result  `json:"result,omitempty"`
+	Message *agents.Message `json:"message,omitempty"`
+	Level   string          `json:"level,omitempty"`
+	Text    string          `json:"text,omitempty"`
+}
@@ -0,0 +1,178 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// Handler runs tasks inside a plugin process
+type Handler interface {
+	Execute(ctx context.Context, task agents.Task, peer *Peer) agents.Result
+}
+
+// MessageHandler is implemented by handlers that want the messages routed to their agent
+type MessageHandler interface {
+	HandleMessage(message agents.Message, peer *Peer)
+}
+
+// HandlerFunc adapts a function to Handler
+type HandlerFunc func(ctx context.Context, task agents.Task, peer *Peer) agents.Result
+
+// Execute calls f
+func (f HandlerFunc) Execute(ctx context.Context, task agents.Task, peer *Peer) agents.Result {
+	return f(ctx, task, peer)
+}
+
+// Peer is a plugin's connection back to capn
+type Peer struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	sent int
+}
+
+// Send routes a message from the plugin's agent to another agent
+func (p *Peer) Send(to, content string, data map[string]interface{}) error {
+	p.mu.Lock()
+	p.sent++
+	id := fmt.Sprintf("plugin-msg-%d-%d", time.Now().UnixNano(), p.sent)
+	p.mu.Unlock()
+	return p.write(Frame{Type: FrameSend, Message: &agents.Message{
+		ID:        id,
+		To:        to,
+		Type:      agents.MessageTypeText,
+		Content:   content,
+		Data:      data,
+		Timestamp: time.Now(),
+	}})
+}
+
+// Reply answers a message the plugin's agent received, so a sender waiting for the reply,
+// such as "capn agents send", receives it
+func (p *Peer) Reply(message agents.Message, content string) error {
+	return p.Send(message.From, content, map[string]interface{}{agents.MessageDataInReplyTo: message.ID})
+}
+
+// Log records text in capn's log at the given level: debug, info, warn or error
+func (p *Peer) Log(level, text string) error {
+	return p.write(Frame{Type: FrameLog, Level: level, Text: text})
+}
+
+func (p *Peer) write(frame Frame) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.enc.Encode(frame); err != nil {
+		return fmt.Errorf("failed to write %s frame: %w", frame.Type, err)
+	}
+	return nil
+}
+
+// Serve speaks the plugin protocol on r and w, running each execute request with handler
+// in its own goroutine. It returns once capn asks for shutdown, r is closed or ctx is
+// done, after the requests in flight have been answered.
+func Serve(ctx context.Context, r io.Reader, w io.Writer, handler Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	peer := &Peer{enc: json.NewEncoder(w)}
+	frames := make(chan Frame)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxFrameSize)
+		for scanner.Scan() {
+			var frame Frame
+			if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+				_ = peer.Log("warn", fmt.Sprintf("ignoring malformed frame: %v", err))
+				continue
+			}
+			select {
+			case frames <- frame:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		inFlight = make(map[string]context.CancelFunc)
+	)
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if err != nil {
+				return fmt.Errorf("failed to read frames: %w", err)
+			}
+			return nil
+		case frame := <-frames:
+			switch frame.Type {
+			case FrameExecute:
+				if frame.Task == nil {
+					_ = peer.Log("warn", "execute frame has no task")
+					continue
+				}
+				taskCtx, taskCancel := context.WithCancel(ctx)
+				mu.Lock()
+				inFlight[frame.ID] = taskCancel
+				mu.Unlock()
+
+				wg.Add(1)
+				go func(id string, task agents.Task) {
+					defer wg.Done()
+					defer func() {
+						mu.Lock()
+						delete(inFlight, id)
+						mu.Unlock()
+						taskCancel()
+					}()
+					result := executeSupervised(taskCtx, handler, task, peer)
+					_ = peer.write(Frame{Type: FrameResult, ID: id, Result: &result})
+				}(frame.ID, *frame.Task)
+			case FrameCancel:
+				mu.Lock()
+				if taskCancel, ok := inFlight[frame.ID]; ok {
+					taskCancel()
+				}
+				mu.Unlock()
+			case FrameMessage:
+				if messages, ok := handler.(MessageHandler); ok && frame.Message != nil {
+					messages.HandleMessage(*frame.Message, peer)
+				}
+			case FrameShutdown:
+				return nil
+			default:
+				_ = peer.Log("warn", fmt.Sprintf("ignoring unknown frame type %q", frame.Type))
+			}
+		}
+	}
+}
+
+// executeSupervised runs a task on the handler, failing only that task when the handler
+// panics and logging the panic with its stack trace to the host
+func executeSupervised(ctx context.Context, handler Handler, task agents.Task, peer *Peer) agents.Result {
+	var result agents.Result
+	err := common.Recover("task "+task.ID, func() error {
+		result = handler.Execute(ctx, task, peer)
+		return nil
+	})
+	if panicErr, ok := err.(*common.PanicError); ok {
+		_ = peer.Log("error", fmt.Sprintf("%s\n%s", panicErr, panicErr.Stack))
+		return agents.Result{TaskID: task.ID, Success: false, Error: panicErr.Error(), Timestamp: time.Now(),
+			Data: map[string]interface{}{"panicked": true}}
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package agents
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoQuestioner is returned when an agent asks a question but nobody can answer it
+var ErrNoQuestioner = errors.New("no one is available to answer questions")
+
+// Questioner delivers a question from an agent working on a step to the user and waits for the answer
+type Questioner interface {
+	Ask(ctx context.Context, step, agentID, question string) (string, error)
+}
+
+// Ask pauses the task until the user answers the question, returning ErrNoQuestioner when
+// the task was not given a way to reach the user
+func (t Task) Ask(ctx context.Context, agentID, question string) (string, error) {
+	if t.Questioner == nil {
+		return "", ErrNoQuestioner
+	}
+	return t.Questioner.Ask(ctx, t.ID, agentID, question)
+}
@@ -0,0 +1,33 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// answeringQuestioner answers every question with a fixed reply
+type answeringQuestioner struct {
+	reply string
+	asked []string
+}
+
+func (q *answeringQuestioner) Ask(ctx context.Context, step, agentID, question string) (string, error) {
+	q.asked = append(q.asked, step+"/"+agentID+": "+question)
+	return q.reply, nil
+}
+
+func TestTask_Ask(t *testing.T) {
+	task := Task{ID: "step-1"}
+	_, err := task.Ask(context.Background(), "file-001", "Which path?")
+	assert.ErrorIs(t, err, ErrNoQuestioner)
+
+	questioner := &answeringQuestioner{reply: "docs/"}
+	task.Questioner = questioner
+	answer, err := task.Ask(context.Background(), "file-001", "Which path?")
+	require.NoError(t, err)
+	assert.Equal(t, "docs/", answer)
+	assert.Equal(t, []string{"step-1/file-001: Which path?"}, questioner.asked)
+}
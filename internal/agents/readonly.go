@@ -0,0 +1,418 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrReadOnly is wrapped by the errors of operations refused in read-only mode
+var ErrReadOnly = errors.New("blocked in read-only mode")
+
+// commandSeparator splits a script into the simple commands it runs, treating command
+// substitutions and subshells as commands of their own
+var commandSeparator = regexp.MustCompile("\\|\\||&&|\\$\\(|[;|&\\n()`]")
+
+// descriptorRedirect matches redirections between file descriptors, such as 2>&1
+var descriptorRedirect = regexp.MustCompile(`[0-9]*[<>]&[0-9-]+`)
+
+// fileRedirect matches output redirected to a file, but not to /dev/null or another descriptor
+var fileRedirect = regexp.MustCompile(`>>?\s*([^\s&>][^\s]*)`)
+
+// readOnlyPrograms only read, whatever their arguments
+var readOnlyPrograms = []string{
+	"cat", "head", "tail", "less", "more", "grep", "egrep", "fgrep", "rg", "ag", "ls",
+	"wc", "cut", "tr", "jq", "diff", "cmp", "file", "stat", "du", "df",
+	"pwd", "echo", "printf", "true", "false", "test", "[", "which", "whoami", "id",
+	"uname", "printenv", "ps", "uptime", "free", "basename", "dirname", "realpath",
+}
+
+// wrapperOptions lists the programs that run the command following their own options and
+// arguments, with the options of each that take a separate value
+var wrapperOptions = map[string][]string{
+	"env":     {"-u", "--unset", "-C", "--chdir"},
+	"nice":    {"-n", "--adjustment"},
+	"nohup":   {},
+	"timeout": {"-s", "--signal", "-k", "--kill-after"},
+	"xargs":   {"-I", "-L", "-n", "-P", "-s", "-d", "-E", "-a", "--arg-file", "--delimiter", "--max-args", "--max-procs", "--max-lines", "--max-chars"},
+	"sudo":    {"-u", "--user", "-g", "--group", "-h", "--host", "-p", "--prompt", "-C", "--close-from", "-D", "--chdir", "-r", "--role", "-t", "--type", "-U", "--other-user"},
+}
+
+// readOnlySubcommands lists the subcommands of tools that only read
+var readOnlySubcommands = map[string][]string{
+	"git":     {"status", "log", "diff", "show", "blame", "grep", "ls-files", "ls-tree", "rev-parse", "describe", "shortlog", "cat-file"},
+	"go":      {"version", "list", "vet", "test", "doc"},
+	"kubectl": {"get", "describe", "logs", "top", "explain", "version", "api-resources"},
+	"docker":  {"ps", "images", "inspect", "logs", "version", "info"},
+	"podman":  {"ps", "images", "inspect", "logs", "version", "info"},
+	"npm":     {"ls", "list", "view", "outdated"},
+	"pip":     {"list", "show", "freeze"},
+}
+
+// MutatingCommand reports whether a shell script may change files, remote services or the
+// system, returning the first command that may. Commands are read-only only when they are
+// known to be: anything unrecognized counts as mutating.
+func MutatingCommand(script string) (string, bool) {
+	for _, command := range SplitCommands(script) {
+		for _, match := range fileRedirect.FindAllStringSubmatch(command, -1) {
+			if match[1] != "/dev/null" {
+				return command, true
+			}
+		}
+		if words, _ := UnwrapCommand(command); !readOnlyCommand(words) {
+			return command, true
+		}
+	}
+	return "", false
+}
+
+// SplitCommands splits a shell script into the simple commands it runs, treating command
+// substitutions and subshells as commands of their own
+func SplitCommands(script string) []string {
+	var commands []string
+	for _, command := range commandSeparator.Split(descriptorRedirect.ReplaceAllString(script, ""), -1) {
+		if command = strings.TrimSpace(command); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// UnwrapCommand splits a simple command into the words of the program it runs, dropping
+// leading variable assignments and the wrappers that run another command, such as sudo, env
+// and timeout, whose names it returns as wrappers. The program is reduced to its base name,
+// so "sudo /bin/rm -rf x" runs rm -rf x. A wrapper given no command is returned as the
+// program.
+func UnwrapCommand(command string) (words, wrappers []string) {
+	words = strings.Fields(command)
+	for {
+		// Leading variable assignments only set the command's environment
+		for len(words) > 0 && strings.Contains(words[0], "=") && !strings.HasPrefix(words[0], "-") {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			return words, wrappers
+		}
+		program := filepath.Base(words[0])
+		valued, ok := wrapperOptions[program]
+		if !ok {
+			break
+		}
+		args := words[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+			option := args[0]
+			args = args[1:]
+			if option == "--" {
+				break
+			}
+			if program == "env" && splitStringOption(option) {
+				// The string is the command line env runs, so unwrapping continues inside it
+				if value, ok := strings.CutPrefix(option, "--split-string="); ok {
+					args = append([]string{value}, args...)
+				} else if len(option) > 2 && !strings.HasPrefix(option, "--") {
+					args = append([]string{option[2:]}, args...)
+				}
+				if len(args) > 0 {
+					args[0] = strings.TrimLeft(args[0], `'"`)
+				}
+				break
+			}
+			if slices.Contains(valued, option) && len(args) > 0 {
+				args = args[1:]
+			}
+		}
+		if program == "env" {
+			for len(args) > 0 && strings.Contains(args[0], "=") {
+				args = args[1:]
+			}
+		}
+		if program == "timeout" && len(args) > 0 {
+			// The duration
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			break
+		}
+		wrappers = append(wrappers, program)
+		words = args
+	}
+	words = append([]string{filepath.Base(words[0])}, words[1:]...)
+	return words, wrappers
+}
+
+// readOnlyCommand reports whether a simple command, unwrapped into the words of the program it
+// runs, only reads
+func readOnlyCommand(words []string) bool {
+	if len(words) == 0 {
+		return true
+	}
+	program, args := words[0], words[1:]
+
+	switch program {
+	case "env", "nice", "xargs":
+		// Given no command these print the environment or niceness, or echo their input
+		return true
+	case "sort":
+		return !slices.ContainsFunc(args, func(arg string) bool {
+			return arg == "--output" || strings.HasPrefix(arg, "--output=") || shortOption(arg, 'o')
+		})
+	case "uniq":
+		// A second operand is the file uniq writes
+		return len(operands(args, "-f", "-s", "-w", "--skip-fields", "--skip-chars", "--check-chars")) < 2
+	case "yq":
+		return !slices.ContainsFunc(args, func(arg string) bool {
+			return arg == "--inplace" || strings.HasPrefix(arg, "--inplace=") || shortOption(arg, 'i')
+		})
+	case "tree":
+		return !slices.ContainsFunc(args, func(arg string) bool { return shortOption(arg, 'o') })
+	case "date":
+		// date sets the clock given --set or an operand that is not a +format
+		for _, operand := range operands(args, "-d", "--date", "-r", "--reference", "-f", "--file") {
+			if !strings.HasPrefix(operand, "+") {
+				return false
+			}
+		}
+		return !slices.ContainsFunc(args, func(arg string) bool {
+			return arg == "--set" || strings.HasPrefix(arg, "--set=") || strings.HasPrefix(arg, "-s")
+		})
+	case "hostname":
+		// hostname sets the name given an operand or a file to read it from
+		return len(operands(args)) == 0 && !slices.ContainsFunc(args, func(arg string) bool {
+			return arg == "--file" || arg == "--boot" || shortOption(arg, 'F') || shortOption(arg, 'b')
+		})
+	case "find":
+		return !slices.ContainsFunc(args, func(arg string) bool {
+			return slices.Contains([]string{"-delete", "-exec", "-execdir", "-ok", "-okdir", "-fprint", "-fprint0", "-fprintf", "-fls"}, arg)
+		})
+	case "sed":
+		return readOnlySed(args)
+	case "go":
+		if len(args) > 0 && args[0] == "env" {
+			return !slices.Contains(args, "-w") && !slices.Contains(args, "-u")
+		}
+		// These flags write files, run other programs or hand the rest to test binaries that may
+		if slices.ContainsFunc(args, func(arg string) bool {
+			name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			name = strings.TrimPrefix(name, "test.")
+			return strings.HasPrefix(arg, "-") && (slices.Contains(goWritingFlags, name) ||
+				len(args) > 0 && args[0] == "test" && slices.Contains(goTestWritingFlags, name))
+		}) {
+			return false
+		}
+	case "git":
+		// Skip the options that come before git's subcommand; configuration given with -c can
+		// name programs for git to run
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			if args[0] == "-c" || strings.HasPrefix(args[0], "--config-env") {
+				return false
+			}
+			if args[0] == "-C" {
+				args = args[1:]
+			}
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		}
+		// --output writes the subcommand's output to a file, and the others run external
+		// diff, text conversion and pager programs
+		if slices.ContainsFunc(args, func(arg string) bool {
+			name, _, _ := strings.Cut(arg, "=")
+			return slices.Contains([]string{"--output", "--ext-diff", "--textconv", "--open-files-in-pager"}, name) ||
+				len(args) > 0 && args[0] == "grep" && shortOption(arg, 'O')
+		}) {
+			return false
+		}
+	}
+	if slices.Contains(readOnlyPrograms, program) {
+		return true
+	}
+	if subcommands, ok := readOnlySubcommands[program]; ok && len(args) > 0 {
+		return slices.Contains(subcommands, args[0])
+	}
+	return false
+}
+
+var (
+	// goWritingFlags are the go command flags that write files or run other programs
+	goWritingFlags = []string{"o", "exec", "toolexec", "vettool"}
+	// goTestWritingFlags are the go test flags, with or without the test. prefix, that write
+	// files or pass what follows on to the test binary, which may do anything with it
+	goTestWritingFlags = []string{
+		"c", "args", "update", "fuzz", "json", "outputdir",
+		"coverprofile", "cpuprofile", "memprofile", "blockprofile", "mutexprofile", "trace",
+	}
+)
+
+// splitStringOption reports whether an env option is -S or --split-string, which give env
+// the command line to run as one string
+func splitStringOption(option string) bool {
+	return option == "--split-string" || strings.HasPrefix(option, "--split-string=") ||
+		strings.HasPrefix(option, "-S")
+}
+
+// readOnlySed reports whether sed, given args, only prints: it is not editing in place,
+// reading its script from a file, or running scripts that write files or run commands
+func readOnlySed(args []string) bool {
+	var scripts []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--in-place" || strings.HasPrefix(arg, "--in-place=") || strings.HasPrefix(arg, "-i"):
+			return false
+		case arg == "--file" || strings.HasPrefix(arg, "--file=") || shortOption(arg, 'f'):
+			return false
+		case (arg == "-e" || arg == "--expression") && i+1 < len(args):
+			i++
+			scripts = append(scripts, args[i])
+		case strings.HasPrefix(arg, "--expression="):
+			scripts = append(scripts, strings.TrimPrefix(arg, "--expression="))
+		case strings.HasPrefix(arg, "-e") && len(arg) > 2:
+			scripts = append(scripts, arg[2:])
+		}
+	}
+	if len(scripts) == 0 {
+		if rest := operands(args, "-l", "--line-length"); len(rest) > 0 {
+			scripts = rest[:1]
+		}
+	}
+	for _, script := range scripts {
+		if sedScriptWrites(strings.Trim(script, `'"`)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sedScriptWrites reports whether a sed script uses the w, W or e commands, or the w or e
+// flags of s, which write files and run shell commands
+func sedScriptWrites(script string) bool {
+	i := 0
+	// skipDelimited moves past text ending at delim, honouring backslash escapes
+	skipDelimited := func(delim byte) {
+		for i < len(script) && script[i] != delim {
+			if script[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i++
+	}
+	for i < len(script) {
+		// Skip separators, then the address and negation before the command
+		for i < len(script) && strings.IndexByte(" \t\n;{}", script[i]) >= 0 {
+			i++
+		}
+		for i < len(script) && strings.IndexByte("0123456789$,~+! \t/\\", script[i]) >= 0 {
+			switch script[i] {
+			case '/':
+				i++
+				skipDelimited('/')
+			case '\\':
+				if i+1 < len(script) {
+					delim := script[i+1]
+					i += 2
+					skipDelimited(delim)
+				} else {
+					i++
+				}
+			default:
+				i++
+			}
+		}
+		if i >= len(script) {
+			break
+		}
+		command := script[i]
+		i++
+		switch command {
+		case 'w', 'W', 'e':
+			return true
+		case 's', 'y':
+			if i >= len(script) {
+				return false
+			}
+			delim := script[i]
+			i++
+			skipDelimited(delim)
+			skipDelimited(delim)
+			if command == 's' {
+				start := i
+				for i < len(script) && strings.IndexByte(" \t\n;}", script[i]) < 0 {
+					i++
+				}
+				if strings.ContainsAny(script[start:i], "we") {
+					return true
+				}
+			}
+		case 'a', 'i', 'c':
+			// The rest of the line is text
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		default:
+			// Labels and arguments run to the next command
+			for i < len(script) && strings.IndexByte(";\n}", script[i]) < 0 {
+				i++
+			}
+		}
+	}
+	return false
+}
+
+// shortOption reports whether arg is a group of single-letter options that includes letter
+func shortOption(arg string, letter rune) bool {
+	if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+		return false
+	}
+	name, _, _ := strings.Cut(arg[1:], "=")
+	return strings.ContainsRune(name, letter)
+}
+
+// operands returns the arguments that are not options, skipping the values of the options
+// listed as taking one
+func operands(args []string, valued ...string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			return append(result, args[i+1:]...)
+		case slices.Contains(valued, arg):
+			i++
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// ReadOnlyResult records a task an agent skipped because read-only mode blocks what it does
+func ReadOnlyResult(task Task, agentType AgentType, blocked string) Result {
+	return Result{
+		TaskID:    task.ID,
+		Success:   true,
+		Output:    "skipped: read-only mode blocks " + blocked,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"agent_type": string(agentType),
+			"operation":  task.Type,
+			"skipped":    true,
+			"read_only":  true,
+			"blocked":    blocked,
+		},
+	}
+}
+
+// checkReadOnly refuses a script in read-only mode when it may change anything
+func (t Task) checkReadOnly(script string) error {
+	if !t.ReadOnly {
+		return nil
+	}
+	if command, mutating := MutatingCommand(script); mutating {
+		return fmt.Errorf("command %q may change files or the system: %w", command, ErrReadOnly)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutatingCommand(t *testing.T) {
+	tests := []struct {
+		script  string
+		blocked string
+	}{
+		{"ls -la && cat README.md | grep capn", ""},
+		{"git -C repo log --oneline 2>&1 | head", ""},
+		{"git status; go test ./... > /dev/null", ""},
+		{"FOO=1 go vet ./...", ""},
+		{"find . -name '*.go'", ""},
+		{"echo $(date)", ""},
+		{"rm -rf build", "rm -rf build"},
+		{"ls && git commit -m wip", "git commit -m wip"},
+		{"echo done > status.txt", "echo done > status.txt"},
+		{"find . -name '*.tmp' -delete", "find . -name '*.tmp' -delete"},
+		{"sed -i s/a/b/ main.go", "sed -i s/a/b/ main.go"},
+		{"go env -w GOFLAGS=-mod=mod", "go env -w GOFLAGS=-mod=mod"},
+		{"echo $(touch marker)", "touch marker"},
+		{"kubectl apply -f deploy.yaml", "kubectl apply -f deploy.yaml"},
+		{"make", "make"},
+		{"sudo cat /etc/hosts", ""},
+		{"env FOO=1 timeout 30s go test ./...", ""},
+		{"nice -n 10 nohup grep -r TODO .", ""},
+		{"find . -name '*.go' | xargs -n 1 wc -l", ""},
+		{"env", ""},
+		{"sudo -u root rm -rf /tmp/x", "sudo -u root rm -rf /tmp/x"},
+		{"env -i PATH=/bin /bin/rm x", "env -i PATH=/bin /bin/rm x"},
+		{"timeout -s KILL 5 git push", "timeout -s KILL 5 git push"},
+		{"nice -5 touch marker", "nice -5 touch marker"},
+		{"ls | xargs -I{} cp {} backup/", "xargs -I{} cp {} backup/"},
+		{"sudo -i", "sudo -i"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			command, mutating := MutatingCommand(tt.script)
+			assert.Equal(t, tt.blocked != "", mutating)
+			assert.Equal(t, tt.blocked, command)
+		})
+	}
+}
+
+func TestMutatingCommand_Arguments(t *testing.T) {
+	tests := []struct {
+		script   string
+		mutating bool
+	}{
+		{"sort -u names.txt", false},
+		{"sort -o names.txt names.txt", true},
+		{"sort -uo names.txt names.txt", true},
+		{"sort --output=names.txt names.txt", true},
+		{"uniq -c names.txt", false},
+		{"uniq -f 1 names.txt", false},
+		{"uniq names.txt unique.txt", true},
+		{"yq .version chart.yaml", false},
+		{"yq -i .version=2 chart.yaml", true},
+		{"yq --inplace .version=2 chart.yaml", true},
+		{"tree -L 2", false},
+		{"tree -o tree.txt", true},
+		{"date +%F", false},
+		{"date -d yesterday +%F", false},
+		{"date -Iseconds", false},
+		{"date -s 2020-01-01", true},
+		{"date --set=2020-01-01", true},
+		{"date 010100002020", true},
+		{"hostname -f", false},
+		{"hostname build-01", true},
+		{"hostname -F /etc/hostname", true},
+		{"git diff --stat", false},
+		{"git diff --output=patch.diff", true},
+		{"git -C repo log --output log.txt", true},
+		{"go test -v ./...", false},
+		{"go test -run TestX -count=1 ./internal/...", false},
+		{"go list -json ./...", false},
+		{"go test -exec 'rm -rf /tmp/x' ./...", true},
+		{"go test ./internal/cli -update", true},
+		{"go test ./... -args -update", true},
+		{"go test -test.update ./internal/cli", true},
+		{"go test -o cli.test ./internal/cli", true},
+		{"go test -c ./internal/cli", true},
+		{"go test -coverprofile=cover.out ./...", true},
+		{"go test -cpuprofile cpu.out ./...", true},
+		{"go test -json ./...", true},
+		{"go vet -vettool=/tmp/tool ./...", true},
+		{"go list -toolexec /tmp/tool -export ./...", true},
+		{"sed -n 1,5p main.go", false},
+		{"sed -e s/a/b/g -e /^#/d main.go", false},
+		{"sed -n 'w out.txt' in.txt", true},
+		{"sed -n '/TODO/W todos.txt' in.txt", true},
+		{"sed -n 1e date in.txt", true},
+		{"sed s/a/b/w out.txt in.txt", true},
+		{"sed s/a/b/ge in.txt", true},
+		{"sed -f edit.sed in.txt", true},
+		{"find . -name '*.go' -print0", false},
+		{"find . -fprint0 names.txt", true},
+		{"git diff HEAD~1", false},
+		{"git diff --ext-diff", true},
+		{"git log -p --textconv", true},
+		{"git grep -O TODO", true},
+		{"git -c diff.external=/tmp/x diff", true},
+		{"env -S 'rm -rf build'", true},
+		{"env --split-string='rm -rf build'", true},
+		{"env -S 'grep -r TODO .'", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			_, mutating := MutatingCommand(tt.script)
+			assert.Equal(t, tt.mutating, mutating)
+		})
+	}
+}
+
+func TestUnwrapCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		words    []string
+		wrappers []string
+	}{
+		{"/bin/rm -rf x", []string{"rm", "-rf", "x"}, nil},
+		{"FOO=1 go test", []string{"go", "test"}, nil},
+		{"sudo -u app env FOO=1 timeout 5m rm x", []string{"rm", "x"}, []string{"sudo", "env", "timeout"}},
+		{"xargs -0 -P 4 /usr/bin/gzip", []string{"gzip"}, []string{"xargs"}},
+		{"nice -n 5", []string{"nice", "-n", "5"}, nil},
+		{"env -S 'rm -rf build'", []string{"rm", "-rf", "build'"}, []string{"env"}},
+		{"env -Srm x", []string{"rm", "x"}, []string{"env"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			words, wrappers := UnwrapCommand(tt.command)
+			assert.Equal(t, tt.words, words)
+			assert.Equal(t, tt.wrappers, wrappers)
+		})
+	}
+}
+
+func TestTask_Command_ReadOnly(t *testing.T) {
+	task := Task{ReadOnly: true}
+	_, err := task.Command(context.Background(), "grep -r TODO . | wc -l")
+	require.NoError(t, err)
+
+	_, err = task.Command(context.Background(), "git push origin main")
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.EqualError(t, err, `command "git push origin main" may change files or the system: blocked in read-only mode`)
+
+	task.ReadOnly = false
+	_, err = task.Command(context.Background(), "git push origin main")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,39 @@
+package agents
+
+import (
+	"os"
+	"time"
+)
+
+// ResourceUsage is what a step consumed while it ran. CPU time and peak memory are only known
+// for steps that run commands; the downloads and files written are reported by the agents.
+type ResourceUsage struct {
+	WallTime time.Duration `json:"wall_time"`
+	CPUTime  time.Duration `json:"cpu_time,omitempty"`
+	// PeakMemory is the largest resident set, in bytes, of the commands the step ran
+	PeakMemory      int64 `json:"peak_memory,omitempty"`
+	BytesDownloaded int64 `json:"bytes_downloaded,omitempty"`
+	FilesWritten    int   `json:"files_written,omitempty"`
+}
+
+// Add adds other's usage to u. Peak memory is the larger of the two, as the commands did not
+// necessarily run at once.
+func (u *ResourceUsage) Add(other ResourceUsage) {
+	u.WallTime += other.WallTime
+	u.CPUTime += other.CPUTime
+	u.PeakMemory = max(u.PeakMemory, other.PeakMemory)
+	u.BytesDownloaded += other.BytesDownloaded
+	u.FilesWritten += other.FilesWritten
+}
+
+// ProcessUsage returns the CPU time and peak memory of an exited process, or nothing when it
+// never ran
+func ProcessUsage(state *os.ProcessState) ResourceUsage {
+	if state == nil {
+		return ResourceUsage{}
+	}
+	return ResourceUsage{
+		CPUTime:    state.UserTime() + state.SystemTime(),
+		PeakMemory: peakMemory(state),
+	}
+}
@@ -0,0 +1,25 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceUsage_Add(t *testing.T) {
+	usage := ResourceUsage{WallTime: time.Second, CPUTime: 200 * time.Millisecond, PeakMemory: 4096, FilesWritten: 1}
+	usage.Add(ResourceUsage{WallTime: 2 * time.Second, CPUTime: 300 * time.Millisecond, PeakMemory: 1024, BytesDownloaded: 512})
+
+	assert.Equal(t, ResourceUsage{
+		WallTime:        3 * time.Second,
+		CPUTime:         500 * time.Millisecond,
+		PeakMemory:      4096,
+		BytesDownloaded: 512,
+		FilesWritten:    1,
+	}, usage, "peak memory is the larger of the two")
+}
+
+func TestProcessUsage_NotStarted(t *testing.T) {
+	assert.Equal(t, ResourceUsage{}, ProcessUsage(nil))
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package agents
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakMemory returns the maximum resident set size of an exited process in bytes
+func peakMemory(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// macOS reports bytes, the other Unixes kilobytes
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
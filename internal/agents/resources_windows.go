@@ -0,0 +1,10 @@
+//go:build windows
+
+package agents
+
+import "os"
+
+// peakMemory is not measured on Windows, whose process state carries no memory counters
+func peakMemory(state *os.ProcessState) int64 {
+	return 0
+}
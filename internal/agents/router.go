@@ -1,17 +1,37 @@
 package agents
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	
 	"github.com/iainlowe/capn/internal/common"
 )
 
+// MessageDataInReplyTo is the message data key naming the ID of the message a reply answers,
+// so a sender waiting with MessageRouter.Request receives it
+const MessageDataInReplyTo = "in_reply_to"
+
 // MessageRouter handles routing messages between agents and logging communications
 type MessageRouter struct {
 	mu      sync.RWMutex
 	agents  map[string]Agent
 	logger  CommunicationLogger
+
+	// maxMessageSize bounds inline message content; larger content spills to attachments
+	maxMessageSize int
+	attachments    AttachmentStore
+	onSpillError   func(error)
+
+	// waiters are the senders waiting for replies, by the ID of the message they sent
+	waitMu  sync.Mutex
+	waiters map[string]replyWaiter
+}
+
+// replyWaiter receives the reply to a message sent by from
+type replyWaiter struct {
+	from  string
+	reply chan Message
 }
 
 // NewMessageRouter creates a new message router
@@ -28,6 +48,40 @@ func (r *MessageRouter) SetLogger(logger CommunicationLogger) {
 	r.logger = logger
 }
 
+// SetMessageLimit sets the largest message content routed inline, zero meaning
+// DefaultMaxMessageSize, and the store larger content is moved to. With no store larger
+// content is truncated.
+func (r *MessageRouter) SetMessageLimit(maxSize int, attachments AttachmentStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxMessageSize = maxSize
+	r.attachments = attachments
+}
+
+// SetSpillErrorHandler sets the function told about message content that could not be moved
+// to the attachment store and was truncated instead
+func (r *MessageRouter) SetSpillErrorHandler(handler func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onSpillError = handler
+}
+
+// Attachments returns the store large message content is moved to, or nil
+func (r *MessageRouter) Attachments() AttachmentStore {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.attachments
+}
+
+// spill moves oversized content out of a message before it is delivered and logged
+func (r *MessageRouter) spill(message Message) Message {
+	message, err := SpillMessage(message, r.maxMessageSize, r.attachments)
+	if err != nil && r.onSpillError != nil {
+		r.onSpillError(err)
+	}
+	return message
+}
+
 // RegisterAgent registers an agent with the router
 func (r *MessageRouter) RegisterAgent(agent Agent) error {
 	r.mu.Lock()
@@ -64,6 +118,15 @@ func (r *MessageRouter) RouteMessage(message Message) error {
 	if err := message.Validate(); err != nil {
 		return fmt.Errorf("invalid message: %w", err)
 	}
+	message = r.spill(message)
+	
+	// A reply to a waiting sender goes to it, whether or not the sender is an agent
+	if r.deliverReply(message) {
+		if r.logger != nil {
+			r.logger.LogMessage(message.From, message.To, message)
+		}
+		return nil
+	}
 	
 	// Find the recipient agent
 	recipient, exists := r.agents[message.To]
@@ -84,6 +147,51 @@ func (r *MessageRouter) RouteMessage(message Message) error {
 	return nil
 }
 
+// Request routes a message and waits until ctx ends for the reply correlated with it: a
+// message to its sender whose in_reply_to data names its ID. The sender need not be a
+// registered agent. It returns a nil reply without an error when none arrives in time.
+func (r *MessageRouter) Request(ctx context.Context, message Message) (*Message, error) {
+	reply := make(chan Message, 1)
+	r.waitMu.Lock()
+	if r.waiters == nil {
+		r.waiters = make(map[string]replyWaiter)
+	}
+	r.waiters[message.ID] = replyWaiter{from: message.From, reply: reply}
+	r.waitMu.Unlock()
+	defer func() {
+		r.waitMu.Lock()
+		delete(r.waiters, message.ID)
+		r.waitMu.Unlock()
+	}()
+
+	if err := r.RouteMessage(message); err != nil {
+		return nil, err
+	}
+	select {
+	case answer := <-reply:
+		return &answer, nil
+	case <-ctx.Done():
+		return nil, nil
+	}
+}
+
+// deliverReply hands a message to the sender waiting for it, reporting whether one was
+func (r *MessageRouter) deliverReply(message Message) bool {
+	id, _ := message.Data[MessageDataInReplyTo].(string)
+	if id == "" {
+		return false
+	}
+	r.waitMu.Lock()
+	defer r.waitMu.Unlock()
+	waiter, ok := r.waiters[id]
+	if !ok || waiter.from != message.To {
+		return false
+	}
+	delete(r.waiters, id)
+	waiter.reply <- message
+	return true
+}
+
 // BroadcastMessage sends a message to all registered agents except the sender
 func (r *MessageRouter) BroadcastMessage(message Message) error {
 	r.mu.RLock()
@@ -93,6 +201,7 @@ func (r *MessageRouter) BroadcastMessage(message Message) error {
 	if err := message.Validate(); err != nil {
 		return fmt.Errorf("invalid message: %w", err)
 	}
+	message = r.spill(message)
 	
 	var deliveryErrors []error
 	
@@ -2,6 +2,7 @@ package agents
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -251,4 +252,78 @@ func (m *MockAgent) Stop() error {
 	m.stopped = true
 	m.status = AgentStatusStopped
 	return nil
-}
\ No newline at end of file
+}
+func TestMessageRouter_SpillsLargeMessages(t *testing.T) {
+	router := NewMessageRouter()
+	logger := NewMemoryCommunicationLogger()
+	router.SetLogger(logger)
+	store := &memoryAttachments{}
+	router.SetMessageLimit(16, store)
+
+	sender := &MockAgent{id: "sender", status: AgentStatusIdle}
+	receiver := &MockAgent{id: "receiver", status: AgentStatusIdle}
+	require.NoError(t, router.RegisterAgent(sender))
+	require.NoError(t, router.RegisterAgent(receiver))
+
+	output := strings.Repeat("build log line\n", 1000)
+	require.NoError(t, router.RouteMessage(Message{ID: "msg-1", From: "sender", To: "receiver", Content: output, Type: MessageTypeText}))
+	require.Len(t, receiver.messages, 1)
+	delivered := receiver.messages[0]
+	assert.Less(t, len(delivered.Content), 100)
+	assert.Equal(t, "attachments/msg-1.txt", delivered.Data[MessageArtifactRef])
+	assert.Less(t, len(logger.GetAllMessages()[0].Message.Content), 100, "the log holds the preview")
+	content, err := MessageContent(delivered, router.Attachments())
+	require.NoError(t, err)
+	assert.Equal(t, output, content)
+
+	require.NoError(t, router.BroadcastMessage(Message{ID: "msg-2", From: "receiver", To: "all", Content: output, Type: MessageTypeText}))
+	require.Len(t, sender.messages, 1)
+	assert.Equal(t, "attachments/msg-2.txt", sender.messages[0].Data[MessageArtifactRef])
+}
+
+// echoAgent answers every message with a reply correlated to it, from another goroutine as
+// plugin agents do
+type echoAgent struct {
+	MockAgent
+	router *MessageRouter
+}
+
+func (e *echoAgent) ReceiveMessage(message Message) error {
+	go func() {
+		_ = e.router.RouteMessage(Message{
+			ID: "reply-" + message.ID, From: e.id, To: message.From, Content: "echo: " + message.Content,
+			Type: MessageTypeResult, Timestamp: time.Now(), Data: map[string]interface{}{MessageDataInReplyTo: message.ID},
+		})
+	}()
+	return nil
+}
+
+func TestMessageRouter_Request(t *testing.T) {
+	router := NewMessageRouter()
+	logger := NewMemoryCommunicationLogger()
+	router.SetLogger(logger)
+	require.NoError(t, router.RegisterAgent(&echoAgent{MockAgent: MockAgent{id: "plugin-001"}, router: router}))
+	require.NoError(t, router.RegisterAgent(&MockAgent{id: "file-001"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := router.Request(ctx, Message{ID: "msg-1", From: "operator", To: "plugin-001", Content: "re-scan ./src",
+		Type: MessageTypeCommand, Timestamp: time.Now()})
+	require.NoError(t, err)
+	require.NotNil(t, reply)
+	assert.Equal(t, "echo: re-scan ./src", reply.Content)
+	history := logger.GetAllMessages()
+	require.Len(t, history, 2, "the request and its reply are logged")
+	assert.Contains(t, history[1].Formatted, "plugin-001 -> operator:")
+
+	// Agents that never answer leave the sender without a reply once it stops waiting
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	reply, err = router.Request(ctx, Message{ID: "msg-2", From: "operator", To: "file-001", Content: "status?",
+		Type: MessageTypeText, Timestamp: time.Now()})
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+
+	_, err = router.Request(ctx, Message{ID: "msg-3", From: "operator", To: "nobody", Content: "hello", Timestamp: time.Now()})
+	assert.EqualError(t, err, "recipient agent not found: nobody")
+}
@@ -1,8 +1,11 @@
 package agents
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"time"
 )
@@ -23,13 +26,10 @@ func NewShellCommand(command string, timeout time.Duration, args ...string) *She
 	}
 }
 
-// Execute runs the shell command with timeout prefix
+// Execute runs the shell command, enforcing its timeout in the platform's way
 func (sc *ShellCommand) Execute() (string, error) {
-	// Build command with timeout prefix
-	timeoutStr := fmt.Sprintf("%.0fs", sc.Timeout.Seconds())
-	fullArgs := append([]string{timeoutStr, sc.Command}, sc.Args...)
-	
-	cmd := exec.Command("timeout", fullArgs...)
+	cmd, cancel := sc.command()
+	defer cancel()
 	output, err := cmd.CombinedOutput()
 	
 	return strings.TrimSpace(string(output)), err
@@ -37,7 +37,98 @@ func (sc *ShellCommand) Execute() (string, error) {
 
 // String returns the command as it would be executed
 func (sc *ShellCommand) String() string {
-	timeoutStr := fmt.Sprintf("%.0fs", sc.Timeout.Seconds())
-	allArgs := append([]string{"timeout", timeoutStr, sc.Command}, sc.Args...)
-	return strings.Join(allArgs, " ")
-}
\ No newline at end of file
+	return strings.Join(sc.argv(), " ")
+}
+
+// Shell describes a command interpreter and how scripts are passed to it
+type Shell struct {
+	Name    string
+	Program string
+	Args    []string
+}
+
+// knownShells maps shell names to their invocation
+var knownShells = map[string]Shell{
+	"sh":         {Name: "sh", Program: "sh", Args: []string{"-c"}},
+	"bash":       {Name: "bash", Program: "bash", Args: []string{"-c"}},
+	"zsh":        {Name: "zsh", Program: "zsh", Args: []string{"-c"}},
+	"pwsh":       {Name: "pwsh", Program: "pwsh", Args: []string{"-NoProfile", "-NonInteractive", "-Command"}},
+	"powershell": {Name: "powershell", Program: "powershell.exe", Args: []string{"-NoProfile", "-NonInteractive", "-Command"}},
+	"cmd":        {Name: "cmd", Program: "cmd.exe", Args: []string{"/C"}},
+}
+
+// windowsOnlyShells are interpreters that only exist on Windows
+var windowsOnlyShells = map[string]bool{"powershell": true, "cmd": true}
+
+// DefaultShell returns the platform's command interpreter: sh on POSIX systems, PowerShell or cmd.exe on Windows
+func DefaultShell() Shell {
+	return defaultShell()
+}
+
+// LookupShell returns the shell with the given name, or the platform default when name is empty
+func LookupShell(name string) (Shell, error) {
+	if name == "" {
+		return DefaultShell(), nil
+	}
+	shell, ok := knownShells[strings.ToLower(name)]
+	if !ok {
+		return Shell{}, fmt.Errorf("unknown shell: %s", name)
+	}
+	if windowsOnlyShells[shell.Name] && !isWindows {
+		return Shell{}, fmt.Errorf("shell %s is only available on Windows", shell.Name)
+	}
+	return shell, nil
+}
+
+// Command builds a process that runs script through the shell
+func (s Shell) Command(ctx context.Context, script string) *exec.Cmd {
+	args := append(append([]string{}, s.Args...), script)
+	return exec.CommandContext(ctx, s.Program, args...)
+}
+
+// Run runs script through the shell and returns its combined output
+func (s Shell) Run(ctx context.Context, script string) (string, error) {
+	output, err := s.Command(ctx, script).CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+// Command builds a process that runs script through the task's shell, in its working directory
+// and with its environment variables added to the agent's own environment.
+// When the task has a container, the script runs there with only the task's variables set.
+// In read-only mode a script that may change anything is refused with ErrReadOnly.
+func (t Task) Command(ctx context.Context, script string) (*exec.Cmd, error) {
+	if err := t.checkReadOnly(script); err != nil {
+		return nil, err
+	}
+	if t.Container != nil {
+		shell, err := LookupContainerShell(t.Shell)
+		if err != nil {
+			return nil, err
+		}
+		return t.Container.Command(ctx, shell, script, t.Workdir, t.Env)
+	}
+
+	shell, err := LookupShell(t.Shell)
+	if err != nil {
+		return nil, err
+	}
+	cmd := shell.Command(ctx, script)
+	cmd.Dir = t.Workdir
+	if len(t.Env) > 0 {
+		names := make([]string, 0, len(t.Env))
+		for name := range t.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cmd.Env = os.Environ()
+		for _, name := range names {
+			cmd.Env = append(cmd.Env, name+"="+t.Env[name])
+		}
+	}
+	return cmd, nil
+}
+
+// PlatformLimitations describes features that are degraded or unavailable on this platform
+func PlatformLimitations() []string {
+	return platformLimitations
+}
@@ -1,64 +1,54 @@
 package agents
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestShellCommand_String(t *testing.T) {
-	tests := []struct {
-		name     string
-		command  string
-		timeout  time.Duration
-		args     []string
-		expected string
-	}{
-		{
-			name:     "simple command",
-			command:  "ls",
-			timeout:  10 * time.Second,
-			args:     []string{"-la"},
-			expected: "timeout 10s ls -la",
-		},
-		{
-			name:     "test command",
-			command:  "go",
-			timeout:  300 * time.Second,
-			args:     []string{"test", "./..."},
-			expected: "timeout 300s go test ./...",
-		},
-		{
-			name:     "build command",
-			command:  "go",
-			timeout:  600 * time.Second,
-			args:     []string{"build", "./cmd/capn"},
-			expected: "timeout 600s go build ./cmd/capn",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			cmd := NewShellCommand(tt.command, tt.timeout, tt.args...)
-			assert.Equal(t, tt.expected, cmd.String())
-		})
-	}
-}
-
-func TestShellCommand_Execute(t *testing.T) {
-	// Test with a simple echo command
-	cmd := NewShellCommand("echo", 10*time.Second, "hello", "world")
-	output, err := cmd.Execute()
-	
-	assert.NoError(t, err)
-	assert.Equal(t, "hello world", output)
-}
-
 func TestShellCommand_NewShellCommand(t *testing.T) {
 	cmd := NewShellCommand("ls", 30*time.Second, "-la", "/tmp")
 	
 	assert.Equal(t, "ls", cmd.Command)
 	assert.Equal(t, 30*time.Second, cmd.Timeout)
 	assert.Equal(t, []string{"-la", "/tmp"}, cmd.Args)
-}
\ No newline at end of file
+}
+
+func TestLookupShell(t *testing.T) {
+	shell, err := LookupShell("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultShell(), shell)
+
+	shell, err = LookupShell("PWSH")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-NoProfile", "-NonInteractive", "-Command"}, shell.Args)
+
+	_, err = LookupShell("fish-ish")
+	assert.EqualError(t, err, "unknown shell: fish-ish")
+}
+
+func TestShell_Command(t *testing.T) {
+	shell := Shell{Name: "sh", Program: "sh", Args: []string{"-c"}}
+	cmd := shell.Command(context.Background(), "echo hi")
+	assert.Equal(t, []string{"sh", "-c", "echo hi"}, cmd.Args)
+}
+
+func TestTask_Command(t *testing.T) {
+	task := Task{Workdir: "/srv/app", Env: map[string]string{"STAGE": "test", "DEBUG": "1"}}
+	cmd, err := task.Command(context.Background(), "make test")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultShell().Program, cmd.Args[0])
+	assert.Equal(t, "make test", cmd.Args[len(cmd.Args)-1])
+	assert.Equal(t, "/srv/app", cmd.Dir)
+	assert.Equal(t, []string{"DEBUG=1", "STAGE=test"}, cmd.Env[len(cmd.Env)-2:], "task variables follow the inherited environment")
+
+	cmd, err = Task{}.Command(context.Background(), "true")
+	require.NoError(t, err)
+	assert.Nil(t, cmd.Env, "without task variables the environment is inherited unchanged")
+
+	_, err = Task{Shell: "fish-ish"}.Command(context.Background(), "true")
+	assert.EqualError(t, err, "unknown shell: fish-ish")
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package agents
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+const isWindows = false
+
+// platformLimitations is empty: every feature is available on POSIX systems
+var platformLimitations []string
+
+// command builds the process for the shell command, wrapped in timeout(1)
+func (sc *ShellCommand) command() (*exec.Cmd, context.CancelFunc) {
+	argv := sc.argv()
+	return exec.Command(argv[0], argv[1:]...), func() {}
+}
+
+// argv returns the command line with the timeout prefix
+func (sc *ShellCommand) argv() []string {
+	timeoutStr := fmt.Sprintf("%.0fs", sc.Timeout.Seconds())
+	return append([]string{"timeout", timeoutStr, sc.Command}, sc.Args...)
+}
+
+// defaultShell returns sh, which every POSIX system provides
+func defaultShell() Shell {
+	return knownShells["sh"]
+}
+
+// startInGroup makes the command lead a process group of its own, so the processes it starts
+// are stopped with it
+func startInGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptGroup asks the command's process group to terminate
+func interruptGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// killGroup kills the command's process group
+func killGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,138 @@
+//go:build !windows
+
+package agents
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellCommand_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		timeout  time.Duration
+		args     []string
+		expected string
+	}{
+		{
+			name:     "simple command",
+			command:  "ls",
+			timeout:  10 * time.Second,
+			args:     []string{"-la"},
+			expected: "timeout 10s ls -la",
+		},
+		{
+			name:     "test command",
+			command:  "go",
+			timeout:  300 * time.Second,
+			args:     []string{"test", "./..."},
+			expected: "timeout 300s go test ./...",
+		},
+		{
+			name:     "build command",
+			command:  "go",
+			timeout:  600 * time.Second,
+			args:     []string{"build", "./cmd/capn"},
+			expected: "timeout 600s go build ./cmd/capn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewShellCommand(tt.command, tt.timeout, tt.args...)
+			assert.Equal(t, tt.expected, cmd.String())
+		})
+	}
+}
+
+func TestShellCommand_Execute(t *testing.T) {
+	// Test with a simple echo command
+	cmd := NewShellCommand("echo", 10*time.Second, "hello", "world")
+	output, err := cmd.Execute()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", output)
+}
+
+func TestDefaultShell_Unix(t *testing.T) {
+	shell := DefaultShell()
+	assert.Equal(t, "sh", shell.Name)
+
+	output, err := shell.Run(context.Background(), "echo $((1 + 2))")
+	require.NoError(t, err)
+	assert.Equal(t, "3", output)
+	assert.Empty(t, PlatformLimitations())
+}
+
+func TestLookupShell_WindowsOnly(t *testing.T) {
+	for _, name := range []string{"cmd", "powershell"} {
+		_, err := LookupShell(name)
+		assert.EqualError(t, err, "shell "+name+" is only available on Windows")
+	}
+}
+
+func TestTask_CommandRunsInWorkdir(t *testing.T) {
+	dir := t.TempDir()
+	task := Task{Workdir: dir, Env: map[string]string{"CAPN_GREETING": "ahoy"}, Shell: "sh"}
+	cmd, err := task.Command(context.Background(), `echo "$CAPN_GREETING"; pwd -P`)
+	require.NoError(t, err)
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "ahoy\n"+resolved+"\n", string(out))
+}
+
+func TestTask_RunCommand(t *testing.T) {
+	// The streams come through separate pipes, so only the order within each is fixed
+	lines := make(map[OutputStream][]string)
+	task := Task{Shell: "sh", MaxOutputSize: 16, Output: OutputSinkFunc(func(stream OutputStream, line string) {
+		lines[stream] = append(lines[stream], line)
+	})}
+	output, err := task.RunCommand(context.Background(), `echo building; echo "warning: slow" >&2; echo 0123456789abcdef; exit 3`)
+	require.Error(t, err, "the command's exit status is returned")
+	assert.Equal(t, "building\n0123456\n[... 10 more bytes truncated]", output.Stdout)
+	assert.Equal(t, "warning: slow\n", output.Stderr)
+	assert.True(t, output.Truncated)
+	assert.Equal(t, []string{"building", "0123456", "[output truncated at 16 bytes]"}, lines[OutputStdout])
+	assert.Equal(t, []string{"warning: slow"}, lines[OutputStderr])
+	assert.Positive(t, output.Usage.PeakMemory, "the command's peak memory is measured")
+}
+
+func TestTask_StartCommand(t *testing.T) {
+	task := Task{Shell: "sh"}
+
+	t.Run("stops with its children", func(t *testing.T) {
+		command, err := task.StartCommand(context.Background(), `echo ready; sleep 30 & wait`)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return command.Output().Stdout == "ready\n" }, 5*time.Second, 5*time.Millisecond)
+		assert.False(t, command.Exited())
+		assert.NoError(t, command.Err(), "a running command has no exit status")
+
+		assert.False(t, command.Stop(5*time.Second), "the command exits when asked")
+		assert.True(t, command.Exited())
+	})
+
+	t.Run("killed after the grace period", func(t *testing.T) {
+		command, err := task.StartCommand(context.Background(), `trap "" TERM; echo ready; while true; do sleep 0.05; done`)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return command.Output().Stdout == "ready\n" }, 5*time.Second, 5*time.Millisecond)
+		assert.True(t, command.Stop(50*time.Millisecond))
+	})
+
+	t.Run("exits on its own", func(t *testing.T) {
+		command, err := task.StartCommand(context.Background(), `echo "no port" >&2; exit 2`)
+		require.NoError(t, err)
+		<-command.Done()
+		assert.Error(t, command.Err())
+		assert.Equal(t, "no port\n", command.Output().Stderr)
+		assert.False(t, command.Stop(time.Second))
+	})
+}
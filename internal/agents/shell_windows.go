@@ -0,0 +1,54 @@
+//go:build windows
+
+package agents
+
+import (
+	"context"
+	"os/exec"
+)
+
+const isWindows = true
+
+// platformLimitations lists POSIX-only features that are skipped on Windows
+var platformLimitations = []string{
+	"command timeouts terminate the process immediately; there is no SIGTERM grace period on Windows",
+	"stopping a service step kills its process immediately, leaving any processes it started running",
+	"file permission modes for task and template files are not enforced; access follows the directory ACLs",
+	"the peak memory of step commands is not measured",
+}
+
+// command builds the process for the shell command.
+// Windows has no timeout(1) (timeout.exe only waits), so the deadline kills the process instead.
+func (sc *ShellCommand) command() (*exec.Cmd, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), sc.Timeout)
+	return exec.CommandContext(ctx, sc.Command, sc.Args...), cancel
+}
+
+// argv returns the command line; the timeout is enforced by the process context
+func (sc *ShellCommand) argv() []string {
+	return append([]string{sc.Command}, sc.Args...)
+}
+
+// defaultShell prefers PowerShell and falls back to cmd.exe, which is always present
+func defaultShell() Shell {
+	for _, name := range []string{"pwsh", "powershell"} {
+		shell := knownShells[name]
+		if _, err := exec.LookPath(shell.Program); err == nil {
+			return shell
+		}
+	}
+	return knownShells["cmd"]
+}
+
+// startInGroup leaves the command as it is: Windows has no process groups to stop together
+func startInGroup(cmd *exec.Cmd) {}
+
+// interruptGroup kills the command, as Windows cannot ask a console process to terminate
+func interruptGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// killGroup kills the command
+func killGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
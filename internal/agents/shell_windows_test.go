@@ -0,0 +1,41 @@
+//go:build windows
+
+package agents
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellCommand_String_Windows(t *testing.T) {
+	cmd := NewShellCommand("go", 300*time.Second, "test", "./...")
+	assert.Equal(t, "go test ./...", cmd.String(), "Windows has no timeout(1) prefix")
+}
+
+func TestShellCommand_Execute_Windows(t *testing.T) {
+	cmd := NewShellCommand("cmd.exe", 10*time.Second, "/C", "echo hello world")
+	output, err := cmd.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", output)
+}
+
+func TestDefaultShell_Windows(t *testing.T) {
+	shell := DefaultShell()
+	assert.Contains(t, []string{"pwsh", "powershell", "cmd"}, shell.Name)
+
+	output, err := shell.Run(context.Background(), "echo capn")
+	require.NoError(t, err)
+	assert.Equal(t, "capn", output)
+	assert.NotEmpty(t, PlatformLimitations())
+}
+
+func TestLookupShell_Windows(t *testing.T) {
+	for _, name := range []string{"cmd", "powershell"} {
+		_, err := LookupShell(name)
+		assert.NoError(t, err)
+	}
+}
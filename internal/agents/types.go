@@ -91,6 +91,26 @@ type Task struct {
 	Priority    Priority               `json:"priority"`
 	Data        map[string]interface{} `json:"data,omitempty"`
 	Deadline    time.Time              `json:"deadline,omitempty"`
+	Workdir     string                 `json:"workdir,omitempty"`
+	Env         map[string]string      `json:"env,omitempty"`
+	Shell       string                 `json:"shell,omitempty"`
+
+	// Container is set when the task's commands run in a container instead of on the host
+	Container *ContainerSpec `json:"container,omitempty"`
+
+	// Questioner lets the agent ask the user for clarification; nil when no one can answer
+	Questioner Questioner `json:"-"`
+
+	// Blackboard holds the findings shared by the task's steps; nil when nothing is shared
+	Blackboard Blackboard `json:"-"`
+
+	// Output receives the task's command output line by line as it runs; nil when it is only captured
+	Output OutputSink `json:"-"`
+	// MaxOutputSize bounds how much of each command output stream is kept; 0 uses DefaultMaxOutputSize
+	MaxOutputSize int `json:"max_output_size,omitempty"`
+	// ReadOnly asks the agent to skip anything that would change files, remote services or the
+	// system, and makes Command refuse mutating scripts
+	ReadOnly bool `json:"read_only,omitempty"`
 }
 
 // Validate validates the task
@@ -107,6 +127,25 @@ func (t *Task) Validate() error {
 	return nil
 }
 
+// ArtifactKind classifies an output produced by an agent
+type ArtifactKind string
+
+const (
+	ArtifactKindFile     ArtifactKind = "file"
+	ArtifactKindReport   ArtifactKind = "report"
+	ArtifactKindDownload ArtifactKind = "download"
+	ArtifactKindDiff     ArtifactKind = "diff"
+)
+
+// Artifact is an output an agent produced while executing a task.
+// Either Content holds the data or Path points at a file the agent wrote.
+type Artifact struct {
+	Name    string       `json:"name"`
+	Kind    ArtifactKind `json:"kind"`
+	Path    string       `json:"path,omitempty"`
+	Content []byte       `json:"-"`
+}
+
 // Result represents the result of task execution
 type Result struct {
 	TaskID    string                 `json:"task_id"`
@@ -115,6 +154,9 @@ type Result struct {
 	Error     string                 `json:"error,omitempty"`
 	Duration  time.Duration          `json:"duration"`
 	Data      map[string]interface{} `json:"data,omitempty"`
+	Artifacts []Artifact             `json:"artifacts,omitempty"`
+	Resources *ResourceUsage         `json:"resources,omitempty"`
+	LLM       *LLMUsage              `json:"llm,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
 }
 
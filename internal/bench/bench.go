@@ -0,0 +1,347 @@
+// Package bench drives the task manager with synthetic load to measure throughput,
+// lock contention and memory use of the task storage and message router.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// Storage backends the harness can exercise
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// mutexWaitMetric is the runtime metric accumulating time goroutines spent blocked on mutexes
+const mutexWaitMetric = "/sync/mutex/wait/total:seconds"
+
+// Config describes the synthetic load to generate
+type Config struct {
+	Tasks       int    // number of tasks to run
+	Steps       int    // steps in each task's plan
+	Messages    int    // agent messages routed per task, spread over its steps
+	Concurrency int    // tasks run at the same time
+	Storage     string // "memory" or "file"
+	Dir         string // directory for file storage; a temporary one is used when empty
+}
+
+// DefaultConfig returns a small load suitable for a quick run
+func DefaultConfig() Config {
+	return Config{
+		Tasks:       1000,
+		Steps:       5,
+		Messages:    20,
+		Concurrency: runtime.GOMAXPROCS(0),
+		Storage:     StorageMemory,
+	}
+}
+
+// Validate checks the load is runnable
+func (c Config) Validate() error {
+	if c.Tasks <= 0 {
+		return fmt.Errorf("tasks must be positive")
+	}
+	if c.Steps <= 0 {
+		return fmt.Errorf("steps must be positive")
+	}
+	if c.Messages < 0 {
+		return fmt.Errorf("messages cannot be negative")
+	}
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+	if c.Storage != StorageMemory && c.Storage != StorageFile {
+		return fmt.Errorf("unknown storage %q, expected %s or %s", c.Storage, StorageMemory, StorageFile)
+	}
+	return nil
+}
+
+// Report holds the measurements of one run
+type Report struct {
+	Config   Config
+	Duration time.Duration
+
+	TasksCompleted int
+	Messages       int64
+	StorageOps     int64
+	Errors         int64
+	FirstError     error
+
+	// MutexWait is the time goroutines spent blocked on mutexes during the run
+	MutexWait time.Duration
+
+	TotalAlloc uint64 // bytes allocated during the run
+	Mallocs    uint64 // heap objects allocated during the run
+	NumGC      uint32 // garbage collections during the run
+	HeapInuse  uint64 // bytes in in-use heap spans at the end of the run
+}
+
+// TasksPerSecond returns the task throughput
+func (r *Report) TasksPerSecond() float64 {
+	return perSecond(int64(r.TasksCompleted), r.Duration)
+}
+
+// MessagesPerSecond returns the message throughput
+func (r *Report) MessagesPerSecond() float64 {
+	return perSecond(r.Messages, r.Duration)
+}
+
+// StorageOpsPerSecond returns the storage throughput
+func (r *Report) StorageOpsPerSecond() float64 {
+	return perSecond(r.StorageOps, r.Duration)
+}
+
+func perSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// countingStorage counts the operations performed against the wrapped storage
+type countingStorage struct {
+	task.TaskStorage
+	ops atomic.Int64
+}
+
+func (s *countingStorage) SaveTask(t *task.TaskExecution) error {
+	s.ops.Add(1)
+	return s.TaskStorage.SaveTask(t)
+}
+
+func (s *countingStorage) GetTask(id string) (*task.TaskExecution, error) {
+	s.ops.Add(1)
+	return s.TaskStorage.GetTask(id)
+}
+
+// sinkAgent accepts and discards messages so the router's own cost is measured
+type sinkAgent struct {
+	*agents.BaseAgent
+}
+
+func (a *sinkAgent) ReceiveMessage(agents.Message) error { return nil }
+
+// run holds the shared state of one benchmark run
+type run struct {
+	cfg     Config
+	storage *countingStorage
+	router  *agents.MessageRouter
+	report  *Report
+
+	messages atomic.Int64
+	mu       sync.Mutex
+}
+
+// Run generates the configured load and reports what it measured
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid bench config: %w", err)
+	}
+
+	storage, cleanup, err := openStorage(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	r := &run{
+		cfg:     cfg,
+		storage: &countingStorage{TaskStorage: storage},
+		router:  agents.NewMessageRouter(),
+		report:  &Report{Config: cfg},
+	}
+	recorder := task.NewMessageRecorder(agents.NewMemoryCommunicationLogger(), r.storage)
+	recorder.SetErrorHandler(func(taskID string, err error) { r.fail(err) })
+	r.router.SetLogger(recorder)
+	for i := 0; i < cfg.Concurrency; i++ {
+		agent := &sinkAgent{agents.NewBaseAgent(workerID(i), fmt.Sprintf("Bench Worker %d", i), agents.AgentTypeFile)}
+		if err := r.router.RegisterAgent(agent); err != nil {
+			return nil, fmt.Errorf("failed to register bench agent: %w", err)
+		}
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	waitBefore := mutexWait()
+	start := time.Now()
+
+	r.drive(ctx)
+
+	r.report.Duration = time.Since(start)
+	r.report.MutexWait = mutexWait() - waitBefore
+	runtime.ReadMemStats(&after)
+	r.report.TotalAlloc = after.TotalAlloc - before.TotalAlloc
+	r.report.Mallocs = after.Mallocs - before.Mallocs
+	r.report.NumGC = after.NumGC - before.NumGC
+	r.report.HeapInuse = after.HeapInuse
+	r.report.StorageOps = r.storage.ops.Load()
+	r.report.Messages = r.messages.Load()
+
+	if err := ctx.Err(); err != nil {
+		return r.report, fmt.Errorf("bench interrupted: %w", err)
+	}
+	return r.report, nil
+}
+
+// openStorage creates the storage under test and a function releasing it
+func openStorage(cfg Config) (task.TaskStorage, func(), error) {
+	if cfg.Storage == StorageMemory {
+		return task.NewMemoryTaskStorage(), func() {}, nil
+	}
+
+	dir, cleanup := cfg.Dir, func() {}
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "capn-bench-")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create bench directory: %w", err)
+		}
+		dir, cleanup = tmp, func() { os.RemoveAll(tmp) }
+	}
+	storage, err := task.NewFileTaskStorage(dir)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return storage, cleanup, nil
+}
+
+// drive runs every task across the configured number of workers
+func (r *run) drive(ctx context.Context) {
+	indexes := make(chan int)
+	// A panicking worker stops the run instead of crashing the process
+	group, ctx := common.NewGroup(ctx, nil)
+	for w := 0; w < r.cfg.Concurrency; w++ {
+		worker := workerID(w)
+		group.Go(worker, func() error {
+			for i := range indexes {
+				if err := r.runTask(worker, i); err != nil {
+					r.fail(err)
+				}
+			}
+			return nil
+		})
+	}
+
+	for i := 0; i < r.cfg.Tasks; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(indexes)
+	if err := group.Wait(); err != nil {
+		r.fail(err)
+	}
+}
+
+// runTask plays out the lifecycle of one synthetic task: save the plan, route its
+// messages through the recorder and store a result for every step
+func (r *run) runTask(agentID string, index int) error {
+	t := task.NewTaskExecution(fmt.Sprintf("synthetic goal %d", index))
+	// Sequential IDs avoid the collisions random short IDs would hit at scale
+	t.ID = fmt.Sprintf("task-bench-%07d", index)
+	t.Plan = syntheticPlan(index, t.Goal, r.cfg.Steps)
+	t.SetStatus(task.TaskStatusRunning)
+	if err := r.storage.SaveTask(t); err != nil {
+		return err
+	}
+
+	for s, step := range t.Plan.Tasks {
+		for m := s; m < r.cfg.Messages; m += r.cfg.Steps {
+			message := agents.Message{
+				ID:        fmt.Sprintf("%s-msg-%d", t.ID, m),
+				From:      "captain",
+				To:        agentID,
+				Content:   fmt.Sprintf("synthetic message %d for %s", m, step.ID),
+				Type:      agents.MessageTypeText,
+				Timestamp: time.Now(),
+				Data:      map[string]interface{}{"task_id": t.ID, "step": step.ID},
+			}
+			if err := r.router.RouteMessage(message); err != nil {
+				return err
+			}
+			r.messages.Add(1)
+		}
+
+		// Reload so the message logs written by the recorder are kept
+		current, err := r.storage.GetTask(t.ID)
+		if err != nil {
+			return err
+		}
+		current.Results = append(current.Results, captain.Result{
+			TaskID:    step.ID,
+			Success:   true,
+			Output:    fmt.Sprintf("step %s done", step.ID),
+			Metadata:  map[string]any{"agent_id": agentID},
+			Timestamp: time.Now(),
+		})
+		if s == len(t.Plan.Tasks)-1 {
+			current.SetStatus(task.TaskStatusCompleted)
+		}
+		if err := r.storage.SaveTask(current); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.report.TasksCompleted++
+	r.mu.Unlock()
+	return nil
+}
+
+// fail records an error from a worker
+func (r *run) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Errors++
+	if r.report.FirstError == nil {
+		r.report.FirstError = err
+	}
+}
+
+// syntheticPlan builds a linear plan with the given number of steps
+func syntheticPlan(index int, goal string, steps int) *captain.ExecutionPlan {
+	plan := &captain.ExecutionPlan{ID: fmt.Sprintf("plan-%d", index), Goal: goal}
+	for i := 0; i < steps; i++ {
+		step := captain.Task{
+			ID:      fmt.Sprintf("step-%d", i+1),
+			Type:    captain.TaskTypeAnalysis,
+			Payload: map[string]any{"description": fmt.Sprintf("Synthetic step %d", i+1)},
+		}
+		if i > 0 {
+			step.Dependencies = []string{fmt.Sprintf("step-%d", i)}
+		}
+		plan.Tasks = append(plan.Tasks, step)
+	}
+	return plan
+}
+
+func workerID(i int) string {
+	return fmt.Sprintf("bench-worker-%d", i)
+}
+
+// mutexWait reads the cumulative time goroutines have spent blocked on mutexes
+func mutexWait() time.Duration {
+	sample := []metrics.Sample{{Name: mutexWaitMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return time.Duration(sample[0].Value.Float64() * float64(time.Second))
+}
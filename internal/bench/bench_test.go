@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		errMsg string
+	}{
+		{"default", func(c *Config) {}, ""},
+		{"no tasks", func(c *Config) { c.Tasks = 0 }, "tasks must be positive"},
+		{"no steps", func(c *Config) { c.Steps = 0 }, "steps must be positive"},
+		{"negative messages", func(c *Config) { c.Messages = -1 }, "messages cannot be negative"},
+		{"no concurrency", func(c *Config) { c.Concurrency = 0 }, "concurrency must be positive"},
+		{"unknown storage", func(c *Config) { c.Storage = "redis" }, `unknown storage "redis"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, storage := range []string{StorageMemory, StorageFile} {
+		t.Run(storage, func(t *testing.T) {
+			cfg := Config{Tasks: 20, Steps: 3, Messages: 7, Concurrency: 4, Storage: storage, Dir: t.TempDir()}
+			report, err := Run(context.Background(), cfg)
+			require.NoError(t, err)
+
+			assert.Zero(t, report.Errors, "first error: %v", report.FirstError)
+			assert.Equal(t, 20, report.TasksCompleted)
+			assert.Equal(t, int64(20*7), report.Messages)
+			// One save for the plan, then a load and save per step, plus a load and save per message
+			assert.Equal(t, int64(20*(1+2*3+2*7)), report.StorageOps)
+			assert.Positive(t, report.Duration)
+			assert.Positive(t, report.TasksPerSecond())
+			assert.Positive(t, report.TotalAlloc)
+		})
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cfg := DefaultConfig()
+	report, err := Run(ctx, cfg)
+	assert.ErrorIs(t, err, context.Canceled)
+	require.NotNil(t, report)
+	assert.Less(t, report.TasksCompleted, cfg.Tasks)
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	_, err := Run(context.Background(), Config{})
+	assert.ErrorContains(t, err, "invalid bench config")
+}
+
+func BenchmarkRun(b *testing.B) {
+	cfg := Config{Tasks: 200, Steps: 5, Messages: 20, Concurrency: 8, Storage: StorageMemory}
+	for i := 0; i < b.N; i++ {
+		if _, err := Run(context.Background(), cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
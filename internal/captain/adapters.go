@@ -0,0 +1,118 @@
+package captain
+
+import (
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// StepStatus is the outcome of a single plan step, derived from its result
+type StepStatus string
+
+const (
+	StepStatusPending     StepStatus = "pending"
+	StepStatusSucceeded   StepStatus = "succeeded"
+	StepStatusFailed      StepStatus = "failed"
+	StepStatusInterrupted StepStatus = "interrupted"
+	StepStatusTimedOut    StepStatus = "timed_out"
+	StepStatusSkipped     StepStatus = "skipped"
+)
+
+// AgentTask converts a plan task into the task format understood by crew agents
+func (t Task) AgentTask() agents.Task {
+	data := make(map[string]interface{}, len(t.Payload))
+	for k, v := range t.Payload {
+		data[k] = v
+	}
+	var env map[string]string
+	if len(t.Env) > 0 {
+		env = make(map[string]string, len(t.Env))
+		for k, v := range t.Env {
+			env[k] = v
+		}
+	}
+	description, _ := t.Payload["description"].(string)
+	if description == "" {
+		description = t.ID
+	}
+
+	return agents.Task{
+		ID:          t.ID,
+		Type:        string(t.Type),
+		Description: description,
+		Priority:    t.Priority,
+		Data:        data,
+		Deadline:    t.Deadline,
+		Workdir:     t.Workdir,
+		Env:         env,
+		Shell:       t.Shell,
+	}
+}
+
+// ContainerSpecFromConfig converts the configured container into the spec crew agents run
+// commands with, or nil when no image is configured
+func ContainerSpecFromConfig(cfg config.ContainerConfig) *agents.ContainerSpec {
+	if cfg.Image == "" {
+		return nil
+	}
+	return &agents.ContainerSpec{
+		Runtime:   cfg.Runtime,
+		Image:     cfg.Image,
+		Workspace: cfg.Workspace,
+		MountPath: cfg.MountPath,
+		CPUs:      cfg.CPUs,
+		Memory:    cfg.Memory,
+		PidsLimit: cfg.PidsLimit,
+		Network:   cfg.Network,
+	}
+}
+
+// NewResultFromAgent converts a crew agent result into a plan result
+func NewResultFromAgent(result agents.Result) Result {
+	metadata := make(map[string]any, len(result.Data))
+	for k, v := range result.Data {
+		metadata[k] = v
+	}
+	return Result{
+		TaskID:    result.TaskID,
+		Success:   result.Success,
+		Output:    result.Output,
+		Error:     result.Error,
+		Duration:  result.Duration,
+		Metadata:  metadata,
+		Timestamp: result.Timestamp,
+		Artifacts: result.Artifacts,
+		Resources: result.Resources,
+		LLM:       result.LLM,
+	}
+}
+
+// Status returns the step status the result represents
+func (r Result) Status() StepStatus {
+	switch {
+	case r.Metadata["skipped"] == true:
+		return StepStatusSkipped
+	case r.Success:
+		return StepStatusSucceeded
+	case r.Metadata["interrupted"] == true:
+		return StepStatusInterrupted
+	case r.Metadata["timed_out"] == true:
+		return StepStatusTimedOut
+	default:
+		return StepStatusFailed
+	}
+}
+
+// StepStatuses returns the status of every step in the plan given the results recorded so far.
+// Steps without a result are pending; when a step ran more than once the last result wins.
+func (p *ExecutionPlan) StepStatuses(results []Result) map[string]StepStatus {
+	statuses := make(map[string]StepStatus, len(p.Tasks))
+	for _, task := range p.Tasks {
+		statuses[task.ID] = StepStatusPending
+	}
+	for _, result := range results {
+		if _, ok := statuses[result.TaskID]; ok {
+			statuses[result.TaskID] = result.Status()
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,95 @@
+package captain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestTask_AgentTask(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	task := Task{
+		ID:       "task-1",
+		Type:     TaskTypeAnalysis,
+		Priority: PriorityHigh,
+		Payload:  map[string]any{"description": "read the logs", "path": "/var/log"},
+		Deadline: deadline,
+	}
+
+	agentTask := task.AgentTask()
+	assert.Equal(t, "task-1", agentTask.ID)
+	assert.Equal(t, "analysis", agentTask.Type)
+	assert.Equal(t, "read the logs", agentTask.Description)
+	assert.Equal(t, agents.PriorityHigh, agentTask.Priority)
+	assert.Equal(t, "/var/log", agentTask.Data["path"])
+	assert.Equal(t, deadline, agentTask.Deadline)
+
+	agentTask.Data["path"] = "changed"
+	assert.Equal(t, "/var/log", task.Payload["path"], "payload is copied")
+
+	assert.Equal(t, "task-2", Task{ID: "task-2"}.AgentTask().Description, "falls back to the task ID")
+}
+
+func TestNewResultFromAgent(t *testing.T) {
+	artifact := agents.Artifact{Name: "report.md", Kind: agents.ArtifactKindReport, Content: []byte("done")}
+	result := NewResultFromAgent(agents.Result{
+		TaskID:    "task-1",
+		Success:   true,
+		Output:    "ok",
+		Data:      map[string]interface{}{"agent_id": "file-001"},
+		Artifacts: []agents.Artifact{artifact},
+	})
+	assert.Equal(t, "task-1", result.TaskID)
+	assert.True(t, result.Success)
+	assert.Equal(t, "ok", result.Output)
+	assert.Equal(t, map[string]any{"agent_id": "file-001"}, result.Metadata)
+	assert.Equal(t, []agents.Artifact{artifact}, result.Artifacts)
+}
+
+func TestResult_Status(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   StepStatus
+	}{
+		{"succeeded", Result{Success: true}, StepStatusSucceeded},
+		{"failed", Result{Error: "boom"}, StepStatusFailed},
+		{"interrupted", Result{Metadata: map[string]any{"interrupted": true}}, StepStatusInterrupted},
+		{"timed out", Result{Metadata: map[string]any{"timed_out": true}}, StepStatusTimedOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.result.Status())
+		})
+	}
+}
+
+func TestExecutionPlan_StepStatuses(t *testing.T) {
+	plan := &ExecutionPlan{Tasks: []Task{{ID: "task-1"}, {ID: "task-2"}, {ID: "task-3"}}}
+	statuses := plan.StepStatuses([]Result{
+		{TaskID: "task-1", Success: true},
+		{TaskID: "task-2", Error: "boom"},
+		{TaskID: "task-2", Success: true},
+		{TaskID: "unknown", Success: true},
+	})
+	assert.Equal(t, map[string]StepStatus{
+		"task-1": StepStatusSucceeded,
+		"task-2": StepStatusSucceeded,
+		"task-3": StepStatusPending,
+	}, statuses)
+}
+
+func TestTask_AgentTaskEnvironment(t *testing.T) {
+	task := Task{ID: "build", Workdir: "/srv/app", Shell: "bash", Env: map[string]string{"STAGE": "test"}}
+	agentTask := task.AgentTask()
+	assert.Equal(t, "/srv/app", agentTask.Workdir)
+	assert.Equal(t, "bash", agentTask.Shell)
+	assert.Equal(t, map[string]string{"STAGE": "test"}, agentTask.Env)
+
+	agentTask.Env["STAGE"] = "prod"
+	assert.Equal(t, "test", task.Env["STAGE"], "env is copied")
+	assert.Nil(t, Task{ID: "plain"}.AgentTask().Env)
+}
@@ -0,0 +1,156 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/transport"
+)
+
+// anthropicVersion is the Messages API version requested
+const anthropicVersion = "2023-06-01"
+
+// AnthropicConfig holds configuration for the Anthropic provider
+type AnthropicConfig struct {
+	APIKey      string  `yaml:"api_key"`
+	Model       string  `yaml:"model"`
+	BaseURL     string  `yaml:"base_url,omitempty"`
+	Temperature float64 `yaml:"temperature"`
+	// HTTP sets the proxies and certificate authorities used to reach the API
+	HTTP config.HTTPConfig `yaml:"http,omitempty"`
+}
+
+// Validate validates the Anthropic configuration
+func (c *AnthropicConfig) Validate() error {
+	validator := common.NewValidator()
+	validator.AddRule("api_key", common.Required("api_key"))
+	validator.AddRule("model", common.Required("model"))
+	validator.AddRule("temperature", common.Range("temperature", 0, 1))
+
+	return validator.Validate(map[string]interface{}{
+		"api_key":     c.APIKey,
+		"model":       c.Model,
+		"temperature": c.Temperature,
+	})
+}
+
+// AnthropicProvider implements LLMProvider using Anthropic's Messages API
+type AnthropicProvider struct {
+	config AnthropicConfig
+	client *http.Client
+}
+
+// NewAnthropicProvider creates a new Anthropic provider
+func NewAnthropicProvider(config AnthropicConfig) (*AnthropicProvider, error) {
+	validatedConfig, err := common.NewConfigBuilder(config).
+		With(func(c *AnthropicConfig) {
+			if c.BaseURL == "" {
+				c.BaseURL = "https://api.anthropic.com"
+			}
+			c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+		}).
+		Validate(func(c AnthropicConfig) error {
+			return c.Validate()
+		}).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Anthropic config: %w", err)
+	}
+
+	client, err := transport.NewHTTPClient(validatedConfig.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Anthropic config: http: %w", err)
+	}
+	return &AnthropicProvider{config: validatedConfig, client: client}, nil
+}
+
+// anthropicRequest is the Messages API request body
+type anthropicRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature"`
+}
+
+// anthropicResponse is the Messages API response body
+type anthropicResponse struct {
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Content    []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// GenerateCompletion generates a completion using the Messages API. System messages are sent
+// as the system prompt since the API does not accept them in the conversation.
+func (p *AnthropicProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid completion request: %w", err)
+	}
+
+	body := anthropicRequest{
+		Model:       p.config.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+	var system []string
+	for _, message := range req.Messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		body.Messages = append(body.Messages, message)
+	}
+	body.System = strings.Join(system, "\n\n")
+
+	headers := map[string]string{"x-api-key": p.config.APIKey, "anthropic-version": anthropicVersion}
+	var resp anthropicResponse
+	if err := postJSON(ctx, p.client, p.config.BaseURL+"/v1/messages", headers, body, &resp); err != nil {
+		return nil, fmt.Errorf("anthropic completion: %w", err)
+	}
+
+	var content strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	return &CompletionResponse{
+		Content:      content.String(),
+		TokensUsed:   resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		Model:        resp.Model,
+		FinishReason: resp.StopReason,
+	}, nil
+}
+
+// Ping lists the available models, which checks the API key without spending tokens
+func (p *AnthropicProvider) Ping(ctx context.Context) error {
+	headers := map[string]string{"x-api-key": p.config.APIKey, "anthropic-version": anthropicVersion}
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.client, p.config.BaseURL+"/v1/models", headers, &resp); err != nil {
+		return fmt.Errorf("anthropic ping: %w", err)
+	}
+	return nil
+}
+
+// GenerateEmbedding is not supported; Anthropic does not offer an embeddings API
+func (p *AnthropicProvider) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return nil, fmt.Errorf("anthropic provider does not support embeddings")
+}
@@ -0,0 +1,206 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RiskLevel classifies how dangerous a plan step is
+type RiskLevel string
+
+const (
+	RiskLow    RiskLevel = "low"
+	RiskMedium RiskLevel = "medium"
+	RiskHigh   RiskLevel = "high"
+)
+
+// ParseRiskLevel parses a risk level, reporting whether it was recognized
+func ParseRiskLevel(s string) (RiskLevel, bool) {
+	switch level := RiskLevel(strings.ToLower(strings.TrimSpace(s))); level {
+	case RiskLow, RiskMedium, RiskHigh:
+		return level, true
+	default:
+		return "", false
+	}
+}
+
+// rank orders risk levels from least to most dangerous
+func (l RiskLevel) rank() int {
+	switch l {
+	case RiskHigh:
+		return 2
+	case RiskMedium:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// RiskAssessment is the risk classification of a single plan step
+type RiskAssessment struct {
+	TaskID  string    `json:"task_id"`
+	Level   RiskLevel `json:"level"`
+	Reasons []string  `json:"reasons,omitempty"`
+}
+
+// RequiresApproval reports whether the step must be approved before it runs
+func (r RiskAssessment) RequiresApproval() bool {
+	return r.Level == RiskHigh
+}
+
+// raise lifts the assessment to level if it is more dangerous, recording why
+func (r *RiskAssessment) raise(level RiskLevel, reason string) {
+	if level.rank() > r.Level.rank() {
+		r.Level = level
+	}
+	if level != RiskLow {
+		r.Reasons = append(r.Reasons, reason)
+	}
+}
+
+var (
+	dangerousCommandPattern = regexp.MustCompile(`(?i)\b(rm\s+-[a-z]*[rf]|sudo|dd\s+if=|mkfs|chmod\s+-R|chown\s+-R|git\s+push\s+(-f|--force)|git\s+reset\s+--hard|drop\s+(table|database)|truncate\s+table|shutdown|reboot|kill\s+-9)\b`)
+	writeMethods            = []string{"POST", "PUT", "PATCH", "DELETE"}
+	networkWriteKeywords    = []string{"upload", "deploy", "publish", "push", "post to", "webhook", "notify"}
+)
+
+// AssessRisk classifies a plan step from static analysis of its payload and description,
+// combined with the risk the planner assigned to it; the more dangerous of the two wins.
+func AssessRisk(task Task) RiskAssessment {
+	assessment := RiskAssessment{TaskID: task.ID, Level: RiskLow}
+
+	description, _ := task.Payload["description"].(string)
+	lower := strings.ToLower(description)
+
+	for _, effect := range heuristicEffects(task) {
+		switch effect.Kind {
+		case EffectFileDelete:
+			assessment.raise(RiskHigh, describeEffect("deletes files", effect.Target))
+		case EffectPackageInstall:
+			assessment.raise(RiskHigh, describeEffect("installs packages", effect.Target))
+		case EffectCommand:
+			if dangerousCommandPattern.MatchString(effect.Target) {
+				assessment.raise(RiskHigh, describeEffect("runs a destructive command", effect.Target))
+			} else {
+				assessment.raise(RiskMedium, describeEffect("runs a shell command", effect.Target))
+			}
+		case EffectNetwork:
+			if isNetworkWrite(effect, lower) {
+				assessment.raise(RiskHigh, describeEffect("writes to a remote service", effect.Target))
+			} else {
+				assessment.raise(RiskMedium, describeEffect("contacts a remote endpoint", effect.Target))
+			}
+		case EffectFileWrite:
+			assessment.raise(RiskMedium, describeEffect("writes files", effect.Target))
+		}
+	}
+
+	// Commands are not always quoted, so scan the prose as well
+	if assessment.Level != RiskHigh && dangerousCommandPattern.MatchString(description) {
+		assessment.raise(RiskHigh, "description mentions a destructive command")
+	}
+
+	if planned, ok := ParseRiskLevel(task.Metadata["risk"]); ok {
+		assessment.raise(planned, fmt.Sprintf("planner rated this step %s risk", planned))
+	}
+
+	return assessment
+}
+
+// AssessPlanRisk classifies every step of a plan, keyed by task ID
+func AssessPlanRisk(plan *ExecutionPlan) map[string]RiskAssessment {
+	assessments := make(map[string]RiskAssessment, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		assessments[task.ID] = AssessRisk(task)
+	}
+	return assessments
+}
+
+// isNetworkWrite reports whether a network effect changes remote state
+func isNetworkWrite(effect Effect, description string) bool {
+	for _, method := range writeMethods {
+		if strings.HasPrefix(effect.Description, method+" ") {
+			return true
+		}
+	}
+	return containsAny(description, networkWriteKeywords)
+}
+
+// describeEffect appends the effect target to a reason when one is known
+func describeEffect(reason, target string) string {
+	if target == "" {
+		return reason
+	}
+	return fmt.Sprintf("%s (%s)", reason, target)
+}
+
+// ApprovalRequest asks for permission to run a high-risk or manually gated step
+type ApprovalRequest struct {
+	Task Task
+	Risk RiskAssessment
+	// Gate is set when the step is held by a manual gate, whatever its risk
+	Gate bool
+}
+
+// Approver decides whether high-risk steps may run
+type Approver interface {
+	Approve(ctx context.Context, request ApprovalRequest) (bool, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface
+type ApproverFunc func(ctx context.Context, request ApprovalRequest) (bool, error)
+
+// Approve calls the function
+func (f ApproverFunc) Approve(ctx context.Context, request ApprovalRequest) (bool, error) {
+	return f(ctx, request)
+}
+
+// ApproveAll is an Approver that approves every step
+var ApproveAll Approver = ApproverFunc(func(context.Context, ApprovalRequest) (bool, error) {
+	return true, nil
+})
+
+// checkApproval gates a step behind the approver when it is high risk or has a manual gate.
+// It returns nil when the step may run, or the failed result recorded in its place. A manual
+// gate with no approver to ask never opens.
+func checkApproval(ctx context.Context, approver Approver, task Task) *Result {
+	gated := task.Gate == GateManual
+	if approver == nil && !gated {
+		return nil
+	}
+	risk := AssessRisk(task)
+	if !risk.RequiresApproval() && !gated {
+		return nil
+	}
+
+	var approved bool
+	err := fmt.Errorf("manual gate has no approver")
+	if approver != nil {
+		approved, err = approver.Approve(ctx, ApprovalRequest{Task: task, Risk: risk, Gate: gated})
+	}
+	if err == nil && approved {
+		return nil
+	}
+
+	message := fmt.Sprintf("step %s was not approved", task.ID)
+	if err != nil {
+		message = fmt.Sprintf("step %s was not approved: %v", task.ID, err)
+	}
+	metadata := map[string]any{
+		"approval": "denied",
+		"risk":     string(risk.Level),
+	}
+	if gated {
+		metadata["gate"] = GateManual
+	}
+	return &Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     message,
+		Timestamp: time.Now(),
+		Metadata:  metadata,
+	}
+}
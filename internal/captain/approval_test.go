@@ -0,0 +1,169 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func describedTask(id string, taskType TaskType, description string) Task {
+	return Task{ID: id, Type: taskType, Payload: map[string]any{"description": description}}
+}
+
+func TestAssessRisk(t *testing.T) {
+	tests := []struct {
+		name   string
+		task   Task
+		want   RiskLevel
+		reason string
+	}{
+		{"read-only analysis", describedTask("t", TaskTypeAnalysis, "Review the error handling in internal/cli"), RiskLow, ""},
+		{"file write", describedTask("t", TaskTypeExecution, "Update config.yaml with the new port"), RiskMedium, "writes files (config.yaml)"},
+		{"file delete", describedTask("t", TaskTypeExecution, "Delete stale fixtures in testdata/old.json"), RiskHigh, "deletes files (testdata/old.json)"},
+		{"package install", describedTask("t", TaskTypeExecution, "Run npm install left-pad"), RiskHigh, "installs packages (left-pad)"},
+		{"network read", describedTask("t", TaskTypeAnalysis, "Fetch https://example.com/status"), RiskMedium, "contacts a remote endpoint (https://example.com/status)"},
+		{"network write", describedTask("t", TaskTypeExecution, "Deploy the site and notify https://hooks.example.com/ci"), RiskHigh, "writes to a remote service (https://hooks.example.com/ci)"},
+		{"post payload", Task{ID: "t", Type: TaskTypeExecution, Payload: map[string]any{"url": "https://api.example.com/items", "method": "post"}}, RiskHigh, "writes to a remote service (https://api.example.com/items)"},
+		{"quoted safe command", describedTask("t", TaskTypeValidation, "Run `go test ./...`"), RiskMedium, "runs a shell command (go test ./...)"},
+		{"quoted destructive command", describedTask("t", TaskTypeValidation, "Run `rm -rf build`"), RiskHigh, "runs a destructive command (rm -rf build)"},
+		{"unquoted destructive command", describedTask("t", TaskTypeValidation, "Discard local work with git reset --hard"), RiskHigh, "description mentions a destructive command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk := AssessRisk(tt.task)
+			assert.Equal(t, tt.want, risk.Level)
+			if tt.reason != "" {
+				assert.Contains(t, risk.Reasons, tt.reason)
+			} else {
+				assert.Empty(t, risk.Reasons)
+			}
+		})
+	}
+}
+
+func TestAssessRisk_PlannerRating(t *testing.T) {
+	task := describedTask("t", TaskTypeAnalysis, "Review the release checklist")
+	task.Metadata = map[string]string{"risk": "high"}
+
+	risk := AssessRisk(task)
+	assert.Equal(t, RiskHigh, risk.Level)
+	assert.Equal(t, []string{"planner rated this step high risk"}, risk.Reasons)
+
+	task = describedTask("t", TaskTypeExecution, "Delete old.json")
+	task.Metadata = map[string]string{"risk": "low"}
+	assert.Equal(t, RiskHigh, AssessRisk(task).Level, "static analysis cannot be lowered by the planner")
+}
+
+func TestParseRiskLevel(t *testing.T) {
+	level, ok := ParseRiskLevel(" High ")
+	assert.True(t, ok)
+	assert.Equal(t, RiskHigh, level)
+
+	_, ok = ParseRiskLevel("extreme")
+	assert.False(t, ok)
+}
+
+func TestCheckApproval(t *testing.T) {
+	risky := describedTask("task-1", TaskTypeExecution, "Delete old.json")
+	safe := describedTask("task-2", TaskTypeAnalysis, "Read the docs")
+
+	var asked []string
+	approver := ApproverFunc(func(ctx context.Context, request ApprovalRequest) (bool, error) {
+		asked = append(asked, request.Task.ID)
+		return false, nil
+	})
+
+	assert.Nil(t, checkApproval(context.Background(), approver, safe))
+	denied := checkApproval(context.Background(), approver, risky)
+	require.NotNil(t, denied)
+	assert.False(t, denied.Success)
+	assert.Equal(t, "step task-1 was not approved", denied.Error)
+	assert.Equal(t, "denied", denied.Metadata["approval"])
+	assert.Equal(t, []string{"task-1"}, asked, "only high-risk steps are gated")
+
+	failing := ApproverFunc(func(context.Context, ApprovalRequest) (bool, error) {
+		return false, fmt.Errorf("no terminal")
+	})
+	assert.Equal(t, "step task-1 was not approved: no terminal", checkApproval(context.Background(), failing, risky).Error)
+
+	assert.Nil(t, checkApproval(context.Background(), ApproveAll, risky))
+	assert.Nil(t, checkApproval(context.Background(), nil, risky), "no approver means no gate")
+}
+
+func TestCaptain_ExecutePlan_DeniedStep(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM, planner: NewPlanningEngine(mockLLM)}
+	captain.SetApprover(ApproverFunc(func(context.Context, ApprovalRequest) (bool, error) {
+		return false, nil
+	}))
+
+	plan := &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "clean up",
+		Tasks: []Task{
+			describedTask("task-1", TaskTypeAnalysis, "List stale files"),
+			describedTask("task-2", TaskTypeExecution, "Delete stale files"),
+			describedTask("task-3", TaskTypeReporting, "Summarize"),
+		},
+	}
+
+	result, err := captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "step task-2 was not approved", result.Error)
+	require.Len(t, result.TaskResults, 2, "execution stops at the rejected step")
+	assert.True(t, result.TaskResults[0].Success)
+	assert.False(t, result.TaskResults[1].Success)
+
+	dryRun, err := captain.ExecutePlan(context.Background(), plan, true)
+	require.NoError(t, err)
+	assert.True(t, dryRun.Success, "dry runs never ask for approval")
+}
+
+func TestPlanExecutor_DeniedStep(t *testing.T) {
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	executor.SetApprover(ApproverFunc(func(context.Context, ApprovalRequest) (bool, error) {
+		return false, nil
+	}))
+
+	plan := &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "install",
+		Tasks: []Task{
+			describedTask("task-1", TaskTypeExecution, "Run pip install requests"),
+			describedTask("task-2", TaskTypeValidation, "Check the import works"),
+		},
+	}
+
+	results, _, err := executor.Execute(context.Background(), plan)
+	assert.EqualError(t, err, "execution stopped: step task-1 was not approved")
+	require.Len(t, results, 1)
+	assert.Equal(t, "denied", results[0].Metadata["approval"])
+}
+
+func TestCheckApproval_ManualGate(t *testing.T) {
+	gated := describedTask("task-1", TaskTypeReporting, "Read the release notes")
+	gated.Gate = GateManual
+
+	var requests []ApprovalRequest
+	approver := ApproverFunc(func(ctx context.Context, request ApprovalRequest) (bool, error) {
+		requests = append(requests, request)
+		return len(requests) > 1, nil
+	})
+
+	denied := checkApproval(context.Background(), approver, gated)
+	require.NotNil(t, denied, "gated steps are held whatever their risk")
+	assert.Equal(t, "manual", denied.Metadata["gate"])
+	require.Len(t, requests, 1)
+	assert.True(t, requests[0].Gate)
+	assert.False(t, requests[0].Risk.RequiresApproval())
+
+	assert.Nil(t, checkApproval(context.Background(), approver, gated))
+	assert.Equal(t, "step task-1 was not approved: manual gate has no approver", checkApproval(context.Background(), nil, gated).Error)
+}
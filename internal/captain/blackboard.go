@@ -0,0 +1,83 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// Payload entries plan steps use to share findings through the task's blackboard
+const (
+	// PayloadPublish names the key a step's output is published under when it succeeds
+	PayloadPublish = "publish"
+	// PayloadInputs lists the keys of findings a step reads; their values are passed to the
+	// step's agent in its "findings" data
+	PayloadInputs = "inputs"
+)
+
+// InputKeys returns the keys of the findings a plan step reads from the blackboard
+func (t Task) InputKeys() []string {
+	switch inputs := t.Payload[PayloadInputs].(type) {
+	case []string:
+		return inputs
+	case []interface{}:
+		keys := make([]string, 0, len(inputs))
+		for _, input := range inputs {
+			if key, ok := input.(string); ok && key != "" {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	case string:
+		if inputs != "" {
+			return []string{inputs}
+		}
+	}
+	return nil
+}
+
+// withFindings returns a copy of the agent task carrying the values of the findings the plan
+// step reads, or an error when one of them has not been published
+func withFindings(ctx context.Context, blackboard agents.Blackboard, step Task, task agents.Task) (agents.Task, error) {
+	keys := step.InputKeys()
+	if len(keys) == 0 {
+		return task, nil
+	}
+	if blackboard == nil {
+		return task, fmt.Errorf("task %s reads findings: %w", step.ID, agents.ErrNoBlackboard)
+	}
+
+	findings := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		finding, ok, err := blackboard.Lookup(ctx, key)
+		if err != nil {
+			return task, fmt.Errorf("failed to read finding %q: %w", key, err)
+		}
+		if !ok {
+			return task, fmt.Errorf("task %s reads finding %q, which no step has published", step.ID, key)
+		}
+		findings[key] = finding.Value
+	}
+
+	data := make(map[string]interface{}, len(task.Data)+1)
+	for k, v := range task.Data {
+		data[k] = v
+	}
+	data["findings"] = findings
+	task.Data = data
+	return task, nil
+}
+
+// publishOutput publishes a successful step's output under the key its payload names
+func publishOutput(ctx context.Context, blackboard agents.Blackboard, step Task, result Result) error {
+	key, _ := step.Payload[PayloadPublish].(string)
+	if key == "" || !result.Success || blackboard == nil {
+		return nil
+	}
+	agent, _ := result.Metadata["agent_id"].(string)
+	if err := blackboard.Publish(ctx, agents.Finding{Key: key, Value: result.Output, Step: step.ID, Agent: agent}); err != nil {
+		return fmt.Errorf("failed to publish finding %q: %w", key, err)
+	}
+	return nil
+}
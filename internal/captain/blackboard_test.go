@@ -0,0 +1,105 @@
+package captain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestTask_InputKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs any
+		want   []string
+	}{
+		{"none", nil, nil},
+		{"strings", []string{"a", "b"}, []string{"a", "b"}},
+		{"decoded JSON", []interface{}{"a", 1, "", "b"}, []string{"a", "b"}},
+		{"single key", "a", []string{"a"}},
+		{"empty key", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := Task{Payload: map[string]any{PayloadInputs: tt.inputs}}
+			assert.Equal(t, tt.want, task.InputKeys())
+		})
+	}
+}
+
+func TestPlanExecutor_SharesFindings(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &capturingAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	blackboard := agents.NewMemoryBlackboard()
+	captain := &Captain{ID: "captain-1"}
+	captain.SetBlackboard(blackboard)
+	executor := NewPlanExecutor(manager)
+	captain.SetExecutor(executor)
+
+	plan := &ExecutionPlan{
+		ID: "plan-1",
+		Tasks: []Task{
+			{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "list endpoints", PayloadPublish: "endpoints"}},
+			{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}, Payload: map[string]any{"description": "check endpoints", PayloadInputs: []string{"endpoints"}}},
+		},
+	}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.True(t, results[1].Success, results[1].Error)
+
+	finding, ok, err := blackboard.Lookup(context.Background(), "endpoints")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "task-1", finding.Step)
+	assert.Equal(t, results[0].Output, finding.Value)
+	assert.Equal(t, results[0].Metadata["agent_id"], finding.Agent)
+
+	received := agent.last.Load().(agents.Task)
+	assert.Equal(t, map[string]interface{}{"endpoints": results[0].Output}, received.Data["findings"])
+	_, ok, err = received.Lookup(context.Background(), "endpoints")
+	require.NoError(t, err)
+	assert.True(t, ok, "agents can read the blackboard themselves")
+}
+
+func TestPlanExecutor_MissingFinding(t *testing.T) {
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	step := Task{ID: "task-2", Type: TaskTypeExecution, Payload: map[string]any{"description": "check", PayloadInputs: []string{"endpoints"}}}
+
+	result, _ := executor.ExecuteTask(context.Background(), step)
+	assert.False(t, result.Success)
+	assert.Equal(t, "task task-2 reads findings: no blackboard is available for sharing findings", result.Error)
+
+	executor.SetBlackboard(agents.NewMemoryBlackboard())
+	result, _ = executor.ExecuteTask(context.Background(), step)
+	assert.False(t, result.Success)
+	assert.Equal(t, `task task-2 reads finding "endpoints", which no step has published`, result.Error)
+}
+
+func TestCaptain_ExecutePlan_PublishesSimulatedOutput(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM, planner: NewPlanningEngine(mockLLM)}
+	blackboard := agents.NewMemoryBlackboard()
+	captain.SetBlackboard(blackboard)
+
+	plan := &ExecutionPlan{
+		ID:    "plan-1",
+		Goal:  "survey",
+		Tasks: []Task{{ID: "task-1", Type: TaskTypeAnalysis, Priority: PriorityHigh, Payload: map[string]any{"description": "survey", PayloadPublish: "survey"}}},
+	}
+	_, err := captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+
+	finding, ok, err := blackboard.Lookup(context.Background(), "survey")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Task task-1 executed successfully", finding.Value)
+}
@@ -2,21 +2,26 @@ package captain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
 	"github.com/iainlowe/capn/internal/config"
 )
 
-// AgentStatus represents the status of an agent
-type AgentStatus string
+// AgentStatus represents the status of an agent; the captain reports the same statuses as crew agents
+type AgentStatus = agents.AgentStatus
 
 const (
-	AgentStatusIdle    AgentStatus = "idle"
-	AgentStatusBusy    AgentStatus = "busy" 
-	AgentStatusStopped AgentStatus = "stopped"
-	AgentStatusError   AgentStatus = "error"
+	AgentStatusIdle    = agents.AgentStatusIdle
+	AgentStatusBusy    = agents.AgentStatusBusy
+	AgentStatusStopped = agents.AgentStatusStopped
+	AgentStatusError   = agents.AgentStatusError
 )
 
 // CaptainStatus represents the current status of the Captain
@@ -38,6 +43,13 @@ type ExecutionResult struct {
 	EndTime     time.Time `json:"end_time"`
 	Duration    time.Duration `json:"duration"`
 	Error       string   `json:"error,omitempty"`
+	Handoffs    []Handoff `json:"handoffs,omitempty"`
+	Interrupted bool     `json:"interrupted,omitempty"`
+	// TimedOut is set when the plan ran past its max runtime and its remaining steps were cancelled
+	TimedOut bool `json:"timed_out,omitempty"`
+	// RevisedPlan is set when a step stalled and the Captain replanned the rest of the goal: it
+	// is the plan extended with the revised steps, whose results follow the original ones
+	RevisedPlan *ExecutionPlan `json:"revised_plan,omitempty"`
 }
 
 // Captain is the main orchestrator agent that uses LLM for planning
@@ -45,7 +57,17 @@ type Captain struct {
 	ID          string
 	config      *config.Config
 	llmProvider LLMProvider
+	providers   *ProviderChain
 	planner     *PlanningEngine
+	executor    *PlanExecutor
+	approver    Approver
+	policy      *Policy
+	questioner  agents.Questioner
+	blackboard  agents.Blackboard
+	clarifier   Clarifier
+	observer    StepObserver
+	output      OutputObserver
+	fanOut      FanOutObserver
 	taskQueue   chan Task
 	resultChan  chan Result
 	
@@ -54,6 +76,10 @@ type Captain struct {
 	status     AgentStatus
 	activeTasks map[string]bool
 	startTime  time.Time
+
+	// Conversations about recent goals, oldest first in conversationOrder
+	conversations     map[string]*Conversation
+	conversationOrder []string
 	
 	// Shutdown management
 	ctx        context.Context
@@ -70,14 +96,45 @@ func NewCaptain(id string, config *config.Config, openaiConfig OpenAIConfig) (*C
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	// Create OpenAI provider
-	llmProvider, err := NewOpenAIProvider(openaiConfig)
+	// Create the LLM providers, tried in order when planning
+	llmProvider, err := NewProviderChainFromConfig(config, openaiConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create OpenAI provider: %w", err)
+		return nil, err
+	}
+	return newCaptain(id, config, llmProvider), nil
+}
+
+// NewOfflineCaptain creates a Captain without LLM providers, for running plans that were
+// made earlier. Planning, replanning and anything else asking a model fails with "no LLM
+// providers configured".
+func NewOfflineCaptain(id string, config *config.Config) (*Captain, error) {
+	if id == "" {
+		return nil, fmt.Errorf("captain ID cannot be empty")
+	}
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
 	}
+	return newCaptain(id, config, NewProviderChain()), nil
+}
 
+// newCaptain creates a Captain planning with llmProvider
+func newCaptain(id string, config *config.Config, llmProvider *ProviderChain) *Captain {
 	// Create planning engine
 	planner := NewPlanningEngine(llmProvider)
+	rules := NewRuleEngineFromConfig(config.Captain.Rules)
+	if !config.Crew.Sandbox.IsZero() {
+		rules.Add(NewSandboxRule(config.Crew.Sandbox))
+	}
+	if len(config.Crew.Code.AllowedRepos) > 0 {
+		rules.Add(NewRepoRule(config.Crew.Code))
+	}
+	planner.SetRules(rules)
+	planner.SetLanguage(config.Captain.Language)
+	if config.Planning.Context.Enabled {
+		if dir, err := os.Getwd(); err == nil {
+			planner.SetContextGatherer(NewContextGatherer(dir, config.Planning.Context))
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -85,6 +142,7 @@ func NewCaptain(id string, config *config.Config, openaiConfig OpenAIConfig) (*C
 		ID:          id,
 		config:      config,
 		llmProvider: llmProvider,
+		providers:   llmProvider,
 		planner:     planner,
 		taskQueue:   make(chan Task, 1000), // Buffered channel for tasks
 		resultChan:  make(chan Result, 1000), // Buffered channel for results
@@ -92,32 +150,316 @@ func NewCaptain(id string, config *config.Config, openaiConfig OpenAIConfig) (*C
 		status:      AgentStatusIdle,
 		activeTasks: make(map[string]bool),
 		startTime:   time.Now(),
+		conversations: make(map[string]*Conversation),
 		
 		ctx:     ctx,
 		cancel:  cancel,
 		stopped: make(chan struct{}),
 	}
 
-	return captain, nil
+	return captain
+}
+
+
+// SetExecutor sets the executor used to run plans on crew agents, applying the configured
+// per-crew-type timeouts with the global timeout as fallback and the execution mode.
+// Without an executor, non-dry-run execution only simulates task completion.
+func (c *Captain) SetExecutor(executor *PlanExecutor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if executor != nil && c.config != nil {
+		executor.SetTimeouts(c.config.Crew.Timeouts, c.config.Global.Timeout)
+		executor.SetExecution(c.config.Execution.Mode, ContainerSpecFromConfig(c.config.Execution.Container))
+		executor.SetMaxOutputSize(c.config.Execution.MaxOutputSize)
+		executor.SetReadOnly(c.config.Execution.ReadOnly)
+		executor.SetMaxParallel(c.config.Global.Parallel)
+		executor.SetWatchdog(c.config.Crew.Watchdog)
+		executor.SetMissingVariables(c.config.Captain.MissingVariables)
+	}
+	if executor != nil {
+		executor.SetStallHandler(c.decideStall)
+	}
+	if executor != nil && c.policy != nil {
+		executor.SetPolicy(c.policy)
+	}
+	if executor != nil && c.questioner != nil {
+		executor.SetQuestioner(c.questioner)
+	}
+	if executor != nil && c.blackboard != nil {
+		executor.SetBlackboard(c.blackboard)
+	}
+	if executor != nil && c.observer != nil {
+		executor.SetStepObserver(c.observer)
+	}
+	if executor != nil && c.output != nil {
+		executor.SetOutputObserver(c.output)
+	}
+	if executor != nil && c.fanOut != nil {
+		executor.SetFanOutObserver(c.fanOut)
+	}
+	c.executor = executor
+}
+
+// SetApprover sets the approver consulted before high-risk steps run.
+// Without one, steps run regardless of their risk.
+func (c *Captain) SetApprover(approver Approver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.approver = approver
+}
+
+// SetPolicy sets the workspace policy plans are validated against and each step is checked
+// against again before it runs
+func (c *Captain) SetPolicy(policy *Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+	c.planner.SetPolicy(policy)
+	if c.executor != nil {
+		c.executor.SetPolicy(policy)
+	}
+}
+
+// SetConstraints sets the limits plans must fit for this run
+func (c *Captain) SetConstraints(constraints PlanConstraints) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.planner.SetConstraints(constraints)
+}
+
+// SetQuestioner sets how crew agents reach the user when a step needs clarification
+func (c *Captain) SetQuestioner(questioner agents.Questioner) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.questioner = questioner
+	if c.executor != nil {
+		c.executor.SetQuestioner(questioner)
+	}
+}
+
+// SetBlackboard sets where crew agents share findings with later plan steps and each other
+func (c *Captain) SetBlackboard(blackboard agents.Blackboard) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blackboard = blackboard
+	if c.executor != nil {
+		c.executor.SetBlackboard(blackboard)
+	}
+}
+
+// SetStepObserver sets the function called after each executed plan step
+func (c *Captain) SetStepObserver(observer StepObserver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observer = observer
+	if c.executor != nil {
+		c.executor.SetStepObserver(observer)
+	}
+}
+
+// SetOutputObserver sets the function streamed each line of command output while a step runs
+func (c *Captain) SetOutputObserver(observer OutputObserver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.output = observer
+	if c.executor != nil {
+		c.executor.SetOutputObserver(observer)
+	}
+}
+
+// SetPlannerObserver sets the function told about the intermediate artifacts of planning each
+// goal: prompts, raw responses, extracted JSON and validation verdicts
+func (c *Captain) SetPlannerObserver(observer PlannerObserver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.planner.SetObserver(observer)
+}
+
+// SetFanOutObserver sets the function called as each item of a fan-out step finishes
+func (c *Captain) SetFanOutObserver(observer FanOutObserver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fanOut = observer
+	if c.executor != nil {
+		c.executor.SetFanOutObserver(observer)
+	}
+}
+
+// ProviderHealth returns the circuit breaker state of the Captain's LLM providers
+func (c *Captain) ProviderHealth() []ProviderHealth {
+	if c.providers == nil {
+		return nil
+	}
+	return c.providers.Health()
 }
 
+// SetProviderHealthHandler sets the function told about LLM provider health whenever a circuit opens or closes
+func (c *Captain) SetProviderHealthHandler(handler func([]ProviderHealth)) {
+	if c.providers != nil {
+		c.providers.SetHealthHandler(handler)
+	}
+}
 
 // CreatePlan creates an execution plan from a goal using LLM reasoning
 func (c *Captain) CreatePlan(ctx context.Context, goal string) (*ExecutionPlan, error) {
+	return c.CreatePlanWithBackground(ctx, goal, "")
+}
+
+// CreatePlanWithBackground creates an execution plan for a goal, giving the planner background
+// about it, such as the issue the goal comes from
+func (c *Captain) CreatePlanWithBackground(ctx context.Context, goal, background string) (*ExecutionPlan, error) {
 	if goal == "" {
 		return nil, fmt.Errorf("goal cannot be empty")
 	}
 
-	// Use the planning engine to create the plan
-	plan, err := c.planner.CreatePlan(ctx, goal)
+	// Use the planning engine to create the plan, starting a new conversation about the goal
+	// with its background and any clarifications of it
+	conversation := c.startConversation(goal)
+	if background = strings.TrimSpace(background); background != "" {
+		conversation.Add(PhaseBackground, "user", "Background for the goal:\n"+background)
+	}
+	assumptions := c.clarify(ctx, goal, conversation)
+	plan, err := c.planner.PlanWithConversation(ctx, goal, conversation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create plan: %w", err)
 	}
+	if len(assumptions) > 0 {
+		plan.Strategy.Description = withAssumptions(plan.Strategy.Description, assumptions)
+	}
 
 	return plan, nil
 }
 
-// ExecutePlan executes an execution plan, optionally in dry-run mode
+// Replan asks for a revised plan for the goal of a plan that has run, using the conversation
+// held since the goal was planned: earlier reasoning, rejections and the execution feedback
+func (c *Captain) Replan(ctx context.Context, plan *ExecutionPlan, result *ExecutionResult) (*ExecutionPlan, error) {
+	if plan == nil {
+		return nil, fmt.Errorf("plan cannot be nil")
+	}
+
+	conversation := c.Conversation(plan.Goal)
+	if conversation == nil {
+		conversation = c.startConversation(plan.Goal)
+		conversation.RecordExecution(plan, result)
+	}
+
+	revised, err := c.planner.PlanWithConversation(ctx, plan.Goal, conversation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replan: %w", err)
+	}
+
+	return revised, nil
+}
+
+// Conversation returns the conversation about a goal planned by the Captain, or nil if none is held
+func (c *Captain) Conversation(goal string) *Conversation {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conversations[goal]
+}
+
+// ForgetConversation drops the conversation about a goal once its lifecycle is over
+func (c *Captain) ForgetConversation(goal string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.conversations, goal)
+	for i, g := range c.conversationOrder {
+		if g == goal {
+			c.conversationOrder = append(c.conversationOrder[:i], c.conversationOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// Ask answers a question about a goal in the light of the Captain's conversation about it and
+// adds the exchange to that conversation. When no conversation is held one is started from
+// background, which should describe what is known about the goal so far.
+func (c *Captain) Ask(ctx context.Context, goal, background, question string) (string, error) {
+	if strings.TrimSpace(question) == "" {
+		return "", fmt.Errorf("question cannot be empty")
+	}
+	if c.llmProvider == nil {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+
+	conversation := c.Conversation(goal)
+	if conversation == nil {
+		conversation = c.startConversation(goal)
+		conversation.Add(PhaseDiscussion, "user", background)
+	}
+	conversation.Add(PhaseDiscussion, "user", question)
+
+	messages := append([]Message{{Role: "system", Content: fmt.Sprintf(`You are the Captain, coordinating a crew of agents working on the goal: %s
+Answer the operator's questions about the plan, its progress and its results using the discussion so far. Be concise and say so when the answer is not known.`, goal)}}, conversation.Messages()...)
+	resp, err := c.llmProvider.GenerateCompletion(ctx, CompletionRequest{
+		Messages:    messages,
+		MaxTokens:   800,
+		Temperature: 0.3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to answer question: %w", err)
+	}
+
+	answer := strings.TrimSpace(resp.Content)
+	conversation.Add(PhaseDiscussion, "assistant", answer)
+	conversation.Compact(ctx)
+	return answer, nil
+}
+
+// startConversation replaces the conversation about a goal with a new one, dropping the
+// oldest held conversation when there are too many
+func (c *Captain) startConversation(goal string) *Conversation {
+	conversation := NewConversation(goal)
+	conversation.SetSummarizer(c.llmProvider)
+	if c.config != nil && c.config.Captain.ConversationTokens > 0 {
+		conversation.SetTokenBudget(c.config.Captain.ConversationTokens)
+	}
+
+	c.ForgetConversation(goal)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conversations == nil {
+		c.conversations = make(map[string]*Conversation)
+	}
+	if len(c.conversationOrder) >= maxConversations {
+		delete(c.conversations, c.conversationOrder[0])
+		c.conversationOrder = c.conversationOrder[1:]
+	}
+	c.conversations[goal] = conversation
+	c.conversationOrder = append(c.conversationOrder, goal)
+	return conversation
+}
+
+// ValidatePlan checks a plan that was not created by the Captain, such as one loaded from a file,
+// against the structural checks and plan rules applied to generated plans
+func (c *Captain) ValidatePlan(plan *ExecutionPlan) error {
+	return c.planner.ValidatePlan(plan)
+}
+
+// AnalyzeEffects predicts the side effects of a plan's tasks for dry-run reporting
+func (c *Captain) AnalyzeEffects(ctx context.Context, plan *ExecutionPlan) (*EffectReport, error) {
+	analyzer := NewEffectAnalyzer(c.llmProvider)
+	if plan != nil {
+		analyzer.SetConversation(c.Conversation(plan.Goal))
+	}
+	return analyzer.Analyze(ctx, plan)
+}
+
+// maxRuntime returns how long a plan may execute: its own max runtime, else the configured
+// captain.max_task_runtime, with zero meaning unbounded
+func (c *Captain) maxRuntime(plan *ExecutionPlan) time.Duration {
+	if plan.Timeline.MaxRuntime > 0 {
+		return plan.Timeline.MaxRuntime
+	}
+	if c.config != nil {
+		return c.config.Captain.MaxTaskRuntime
+	}
+	return 0
+}
+
+// ExecutePlan executes an execution plan, optionally in dry-run mode. On crew agents the
+// execution is bounded by the plan's max runtime, after which the remaining steps are cancelled
+// and the result is marked as timed out.
 func (c *Captain) ExecutePlan(ctx context.Context, plan *ExecutionPlan, dryRun bool) (*ExecutionResult, error) {
 	if plan == nil {
 		return nil, fmt.Errorf("plan cannot be nil")
@@ -140,6 +482,11 @@ func (c *Captain) ExecutePlan(ctx context.Context, plan *ExecutionPlan, dryRun b
 	// Update status
 	c.mu.Lock()
 	c.status = AgentStatusBusy
+	executor := c.executor
+	approver := c.approver
+	policy := c.policy
+	observer := c.observer
+	blackboard := c.blackboard
 	c.mu.Unlock()
 
 	defer func() {
@@ -151,8 +498,59 @@ func (c *Captain) ExecutePlan(ctx context.Context, plan *ExecutionPlan, dryRun b
 		result.Duration = result.EndTime.Sub(result.StartTime)
 	}()
 
+	// Run tasks on crew agents when an executor is available
+	if executor != nil && !dryRun {
+		if limit := c.maxRuntime(plan); limit > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, limit, &MaxRuntimeError{Limit: limit})
+			defer cancel()
+		}
+		taskResults, handoffs, err := executor.Execute(ctx, plan)
+		// A stalled step the Captain chose to replan after gives way to the revised steps
+		stalled := replanRequested(taskResults)
+		if stalled != "" && ctx.Err() == nil {
+			taskResults, handoffs, result.RevisedPlan, err = c.replanStalled(ctx, executor, plan, stalled, taskResults, handoffs)
+		}
+		result.TaskResults = taskResults
+		result.Handoffs = handoffs
+		for _, taskResult := range taskResults {
+			if !taskResult.Success && (result.RevisedPlan == nil || taskResult.TaskID != stalled) {
+				result.Success = false
+			}
+		}
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+		}
+		// Running out of time fails the task, where other cancellations interrupt it
+		var timeout *MaxRuntimeError
+		if errors.As(context.Cause(ctx), &timeout) {
+			result.Success = false
+			result.TimedOut = true
+			result.Error = timeout.Error()
+		} else {
+			result.Interrupted = ctx.Err() != nil
+		}
+		if conversation := c.Conversation(plan.Goal); conversation != nil {
+			ran := plan
+			if result.RevisedPlan != nil {
+				ran = result.RevisedPlan
+			}
+			conversation.RecordExecution(ran, result)
+		}
+		return result, nil
+	}
+
 	// Execute tasks (in dry-run mode, just simulate)
 	for i, task := range plan.Tasks {
+		if err := ctx.Err(); err != nil {
+			result.TaskResults = result.TaskResults[:i]
+			result.Success = false
+			result.Error = fmt.Sprintf("execution cancelled: %v", err)
+			result.Interrupted = true
+			break
+		}
+
 		taskResult := Result{
 			TaskID:    task.ID,
 			Success:   true,
@@ -165,14 +563,38 @@ func (c *Captain) ExecutePlan(ctx context.Context, plan *ExecutionPlan, dryRun b
 				task.ID, task.Type, task.Priority)
 			taskResult.Duration = time.Millisecond * 100 // Simulate quick execution
 		} else {
+			if blocked := checkPolicy(policy, task, common.SystemClock); blocked != nil {
+				result.TaskResults[i] = *blocked
+				result.TaskResults = result.TaskResults[:i+1]
+				result.Success = false
+				result.Error = blocked.Error
+				break
+			}
+			if denied := checkApproval(ctx, approver, task); denied != nil {
+				result.TaskResults[i] = *denied
+				result.TaskResults = result.TaskResults[:i+1]
+				result.Success = false
+				result.Error = denied.Error
+				break
+			}
 			// TODO: Implement actual task execution with crew agents
 			taskResult.Output = fmt.Sprintf("Task %s executed successfully", task.ID)
 			taskResult.Duration = time.Second * 5 // Simulate longer execution
+			if err := publishOutput(ctx, blackboard, task, taskResult); err != nil {
+				taskResult.Metadata = map[string]any{"blackboard_error": err.Error()}
+			}
+			if observer != nil {
+				observer(task, &taskResult)
+			}
 		}
 
 		result.TaskResults[i] = taskResult
 	}
 
+	if conversation := c.Conversation(plan.Goal); conversation != nil && !dryRun {
+		conversation.RecordExecution(plan, result)
+	}
+
 	return result, nil
 }
 
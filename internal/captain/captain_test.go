@@ -34,6 +34,41 @@ func TestNewCaptain(t *testing.T) {
 	assert.NotNil(t, captain.resultChan)
 }
 
+func TestNewCaptain_ProviderChain(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Planning.Context.Enabled = false
+	cfg.LLM.Providers = []config.LLMProviderConfig{
+		{Type: config.ProviderOpenAI},
+		{Type: config.ProviderAnthropic, APIKey: "anthropic-key", Model: "claude-sonnet-4"},
+		{Name: "local", Type: config.ProviderOllama, Model: "llama3"},
+	}
+
+	captain, err := NewCaptain("captain-1", cfg, OpenAIConfig{APIKey: "test-key", Model: "gpt-4"})
+	require.NoError(t, err)
+
+	health := captain.ProviderHealth()
+	require.Len(t, health, 3)
+	assert.Equal(t, "openai", health[0].Name)
+	assert.Equal(t, "anthropic", health[1].Name)
+	assert.Equal(t, "local", health[2].Name)
+	assert.Equal(t, CircuitClosed, health[2].State)
+}
+
+func TestNewOfflineCaptain(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Planning.Context.Enabled = false
+
+	captain, err := NewOfflineCaptain("captain-1", cfg)
+	require.NoError(t, err)
+	assert.Empty(t, captain.ProviderHealth())
+
+	_, err = captain.CreatePlan(context.Background(), "analyze code quality")
+	assert.ErrorContains(t, err, "no LLM providers configured")
+
+	_, err = NewOfflineCaptain("", cfg)
+	assert.Error(t, err)
+}
+
 func TestNewCaptain_InvalidConfig(t *testing.T) {
 	cfg := &config.Config{
 		Captain: config.CaptainConfig{
@@ -0,0 +1,276 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// PhaseChat entries are the messages the operator and the Captain exchange in a chat
+	PhaseChat Phase = "chat"
+	// PhaseTool entries record the tools the Captain called during a chat and what they returned
+	PhaseTool Phase = "tool"
+)
+
+// maxChatToolCalls bounds the tools the Captain may call while answering one message
+const maxChatToolCalls = 6
+
+// maxChatToolOutput bounds how much of a tool's output is added to the chat
+const maxChatToolOutput = 8 * 1024
+
+// chatIDPattern matches the IDs NewChat generates, so stored chat IDs are safe file names
+var chatIDPattern = regexp.MustCompile(`^chat-[0-9a-f]{8}$`)
+
+// ChatTool is something the Captain may look up while chatting, such as a task's logs
+type ChatTool struct {
+	Name string
+	// Input describes the argument the tool takes, or is empty when it takes none
+	Input       string
+	Description string
+	Run         func(ctx context.Context, input string) (string, error)
+}
+
+// Chat is a conversation between the operator and the Captain about a workspace and its
+// recent tasks, kept so it can be continued later
+type Chat struct {
+	ID        string              `json:"id"`
+	Workspace string              `json:"workspace,omitempty"`
+	Entries   []ConversationEntry `json:"entries,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// NewChat starts an empty chat about a workspace
+func NewChat(workspace string) *Chat {
+	now := time.Now()
+	return &Chat{
+		ID:        fmt.Sprintf("chat-%s", uuid.New().String()[:8]),
+		Workspace: workspace,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Title returns the operator's first message, which names the chat in listings
+func (c *Chat) Title() string {
+	for _, entry := range c.Entries {
+		if entry.Phase == PhaseChat && entry.Role == "user" {
+			first, _, _ := strings.Cut(entry.Content, "\n")
+			return truncateText(first, 60)
+		}
+	}
+	return ""
+}
+
+// add appends an entry to the chat
+func (c *Chat) add(phase Phase, role, content string) {
+	now := time.Now()
+	c.Entries = append(c.Entries, ConversationEntry{Phase: phase, Role: role, Content: content, Timestamp: now})
+	c.UpdatedAt = now
+}
+
+// messages returns the latest entries as prompt messages, dropping the oldest once they
+// outgrow the token budget
+func (c *Chat) messages(budget int) []Message {
+	var messages []Message
+	tokens := 0
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		entry := c.Entries[i]
+		tokens += len(entry.Content) / 4
+		if budget > 0 && tokens > budget && len(messages) > 0 {
+			break
+		}
+		messages = append(messages, Message{Role: entry.Role, Content: entry.Content})
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages
+}
+
+// chatToolCall is a reply asking to run a tool instead of answering
+type chatToolCall struct {
+	Tool  string `json:"tool"`
+	Input string `json:"input"`
+}
+
+// parseToolCall returns the tool call a reply makes, if it is one
+func parseToolCall(content string) (chatToolCall, bool) {
+	content = strings.TrimSpace(extractJSON(content))
+	if !strings.HasPrefix(content, "{") {
+		return chatToolCall{}, false
+	}
+	var call chatToolCall
+	if err := json.Unmarshal([]byte(content), &call); err != nil || call.Tool == "" {
+		return chatToolCall{}, false
+	}
+	return call, true
+}
+
+// Chat answers the operator's message, continuing the chat. The Captain may call the given
+// tools to look things up before answering; their calls and output are kept in the chat.
+// Background describes the workspace and is given to the LLM with every message.
+func (c *Captain) Chat(ctx context.Context, chat *Chat, background string, tools []ChatTool, message string) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("message cannot be empty")
+	}
+	if c.llmProvider == nil {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+	budget := DefaultConversationTokens
+	if c.config != nil && c.config.Captain.ConversationTokens > 0 {
+		budget = c.config.Captain.ConversationTokens
+	}
+
+	byName := make(map[string]ChatTool, len(tools))
+	var toolList strings.Builder
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+		if tool.Input != "" {
+			fmt.Fprintf(&toolList, "- %s (input: %s): %s\n", tool.Name, tool.Input, tool.Description)
+		} else {
+			fmt.Fprintf(&toolList, "- %s: %s\n", tool.Name, tool.Description)
+		}
+	}
+	systemPrompt := fmt.Sprintf(`You are the Captain, coordinating a crew of agents that run goals as tasks. Chat with the operator about the workspace and its tasks: what ran, why steps failed and what to do next.
+
+To look something up, reply with only a JSON object such as {"tool": "task_logs", "input": "task-1234abcd"} and nothing else; the tool's output is then sent to you. Tools:
+%s
+Otherwise reply to the operator in plain text. Look things up rather than guess, be concise and say so when the answer is not known.
+
+%s`, toolList.String(), strings.TrimSpace(background))
+
+	chat.add(PhaseChat, "user", message)
+	for calls := 0; ; calls++ {
+		resp, err := c.llmProvider.GenerateCompletion(ctx, CompletionRequest{
+			Messages:    append([]Message{{Role: "system", Content: systemPrompt}}, chat.messages(budget)...),
+			MaxTokens:   800,
+			Temperature: 0.3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to answer message: %w", err)
+		}
+
+		reply := strings.TrimSpace(resp.Content)
+		call, ok := parseToolCall(reply)
+		if !ok {
+			chat.add(PhaseChat, "assistant", reply)
+			return reply, nil
+		}
+		chat.add(PhaseTool, "assistant", reply)
+
+		var output string
+		tool, found := byName[call.Tool]
+		switch {
+		case calls >= maxChatToolCalls:
+			output = "No more tools can be called for this message; answer with what you know."
+		case !found:
+			output = fmt.Sprintf("There is no tool named %q.", call.Tool)
+		default:
+			result, err := tool.Run(ctx, strings.TrimSpace(call.Input))
+			if err != nil {
+				output = "Error: " + err.Error()
+			} else if len(result) > maxChatToolOutput {
+				// Keep the end of long output, where the latest entries are
+				output = "[earlier output omitted]\n" + result[len(result)-maxChatToolOutput:]
+			} else {
+				output = result
+			}
+		}
+		chat.add(PhaseTool, "user", fmt.Sprintf("Output of %s:\n%s", call.Tool, output))
+		if calls > maxChatToolCalls {
+			return "", fmt.Errorf("failed to answer message: the Captain kept calling tools")
+		}
+	}
+}
+
+// ChatStore keeps chats on disk as <dir>/<id>.json
+type ChatStore struct {
+	dir string
+}
+
+// NewChatStore creates a chat store rooted at dir
+func NewChatStore(dir string) (*ChatStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("conversation directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create conversation directory %s: %w", dir, err)
+	}
+	return &ChatStore{dir: dir}, nil
+}
+
+// Save writes the chat, replacing any earlier copy
+func (s *ChatStore) Save(chat *Chat) error {
+	if !chatIDPattern.MatchString(chat.ID) {
+		return fmt.Errorf("invalid conversation ID: %s", chat.ID)
+	}
+	data, err := json.MarshalIndent(chat, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation %s: %w", chat.ID, err)
+	}
+	tmp := s.path(chat.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write conversation %s: %w", chat.ID, err)
+	}
+	if err := os.Rename(tmp, s.path(chat.ID)); err != nil {
+		return fmt.Errorf("failed to write conversation %s: %w", chat.ID, err)
+	}
+	return nil
+}
+
+// Get returns a stored chat
+func (s *ChatStore) Get(id string) (*Chat, error) {
+	if !chatIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("conversation not found: %s", id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("conversation not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to read conversation %s: %w", id, err)
+	}
+	var chat Chat
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return nil, fmt.Errorf("failed to parse conversation %s: %w", id, err)
+	}
+	return &chat, nil
+}
+
+// List returns the stored chats, most recently updated first
+func (s *ChatStore) List() ([]*Chat, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversation directory %s: %w", s.dir, err)
+	}
+
+	var chats []*Chat
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		chat, err := s.Get(id)
+		if err != nil {
+			continue
+		}
+		chats = append(chats, chat)
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].UpdatedAt.After(chats[j].UpdatedAt) })
+	return chats, nil
+}
+
+// path returns the file path of a chat
+func (s *ChatStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,123 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptain_Chat(t *testing.T) {
+	var looked []string
+	tools := []ChatTool{{
+		Name:        "task_logs",
+		Input:       "task ID",
+		Description: "show a task's logs",
+		Run: func(ctx context.Context, input string) (string, error) {
+			looked = append(looked, input)
+			return "step-3 failed: exit status 1", nil
+		},
+	}}
+
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 2 &&
+			strings.Contains(req.Messages[0].Content, "- task_logs (input: task ID): show a task's logs") &&
+			strings.Contains(req.Messages[0].Content, "Workspace: capn")
+	})).Return(&CompletionResponse{Content: "```json\n{\"tool\": \"task_logs\", \"input\": \"task-1\"}\n```"}, nil).Once()
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 4 && req.Messages[3].Content == "Output of task_logs:\nstep-3 failed: exit status 1"
+	})).Return(&CompletionResponse{Content: " Step 3's command exited with status 1. "}, nil).Once()
+
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM}
+	chat := NewChat("capn")
+	answer, err := captain.Chat(context.Background(), chat, "Workspace: capn", tools, "why did step 3 fail?")
+	require.NoError(t, err)
+	assert.Equal(t, "Step 3's command exited with status 1.", answer)
+	assert.Equal(t, []string{"task-1"}, looked)
+	mockLLM.AssertExpectations(t)
+
+	require.Len(t, chat.Entries, 4)
+	assert.Equal(t, PhaseChat, chat.Entries[0].Phase)
+	assert.Equal(t, PhaseTool, chat.Entries[1].Phase)
+	assert.Equal(t, PhaseTool, chat.Entries[2].Phase)
+	assert.Equal(t, PhaseChat, chat.Entries[3].Phase)
+	assert.Equal(t, "why did step 3 fail?", chat.Title())
+
+	_, err = captain.Chat(context.Background(), chat, "", tools, " ")
+	assert.EqualError(t, err, "message cannot be empty")
+	_, err = (&Captain{}).Chat(context.Background(), chat, "", tools, "hello?")
+	assert.EqualError(t, err, "no LLM provider configured")
+}
+
+func TestCaptain_Chat_ToolErrors(t *testing.T) {
+	tools := []ChatTool{{
+		Name: "show_task",
+		Run: func(ctx context.Context, input string) (string, error) {
+			return "", errors.New("task not found: " + input)
+		},
+	}}
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).
+		Return(&CompletionResponse{Content: `{"tool": "show_task", "input": "task-9"}`}, nil)
+
+	chat := NewChat("capn")
+	_, err := (&Captain{llmProvider: mockLLM}).Chat(context.Background(), chat, "", tools, "what is task-9?")
+	assert.EqualError(t, err, "failed to answer message: the Captain kept calling tools")
+	assert.Equal(t, "Output of show_task:\nError: task not found: task-9", chat.Entries[2].Content)
+	assert.Contains(t, chat.Entries[len(chat.Entries)-1].Content, "No more tools can be called")
+	mockLLM.AssertNumberOfCalls(t, "GenerateCompletion", maxChatToolCalls+2)
+}
+
+func TestChat_MessagesBudget(t *testing.T) {
+	chat := NewChat("capn")
+	chat.add(PhaseChat, "user", strings.Repeat("a", 400))
+	chat.add(PhaseChat, "assistant", strings.Repeat("b", 400))
+	chat.add(PhaseChat, "user", "latest")
+
+	assert.Len(t, chat.messages(0), 3)
+	messages := chat.messages(150)
+	require.Len(t, messages, 2, "the oldest entries are dropped once over budget")
+	assert.Equal(t, "latest", messages[1].Content)
+	assert.Len(t, chat.messages(1), 1, "the latest entry is always sent")
+}
+
+func TestChatStore(t *testing.T) {
+	store, err := NewChatStore(t.TempDir())
+	require.NoError(t, err)
+
+	chats, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, chats)
+
+	older := NewChat("capn")
+	older.add(PhaseChat, "user", "first question")
+	require.NoError(t, store.Save(older))
+	newer := NewChat("other")
+	newer.add(PhaseChat, "user", "second question")
+	require.NoError(t, store.Save(newer))
+
+	loaded, err := store.Get(older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "capn", loaded.Workspace)
+	require.Len(t, loaded.Entries, 1)
+	assert.Equal(t, "first question", loaded.Entries[0].Content)
+
+	chats, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, chats, 2)
+	assert.Equal(t, newer.ID, chats[0].ID, "most recently updated first")
+
+	_, err = store.Get("chat-00000000")
+	assert.EqualError(t, err, "conversation not found: chat-00000000")
+	_, err = store.Get("../tasks/task-1")
+	assert.EqualError(t, err, "conversation not found: ../tasks/task-1")
+	assert.Error(t, store.Save(&Chat{ID: "../escape"}))
+
+	_, err = NewChatStore("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ClarifyingQuestion is a question the Captain has about an ambiguous goal, with the
+// assumption it plans on when the question goes unanswered
+type ClarifyingQuestion struct {
+	Question   string `json:"question"`
+	Assumption string `json:"assumption"`
+}
+
+// Clarifier answers the Captain's clarifying questions about a goal before it is planned.
+// Answers are matched to questions by position; an empty answer keeps the assumption.
+type Clarifier interface {
+	Clarify(ctx context.Context, goal string, questions []ClarifyingQuestion) ([]string, error)
+}
+
+// clarificationResponse is the structured response to a clarification request
+type clarificationResponse struct {
+	Questions []ClarifyingQuestion `json:"questions"`
+}
+
+// SetClarifier sets who answers clarifying questions about ambiguous goals. Without one the
+// Captain plans on its assumptions and records them in the plan's reasoning.
+func (c *Captain) SetClarifier(clarifier Clarifier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clarifier = clarifier
+}
+
+// clarify asks up to captain.max_clarifying_questions questions about an ambiguous goal and
+// adds the answers to the goal's conversation. It returns the assumptions made for questions
+// left unanswered. Clarification is best effort: when it fails the goal is planned as given.
+func (c *Captain) clarify(ctx context.Context, goal string, conversation *Conversation) []string {
+	if c.config == nil || c.config.Captain.MaxClarifyingQuestions <= 0 || c.llmProvider == nil {
+		return nil
+	}
+	language := ResponseLanguage(c.languageSetting(), goal)
+	questions, err := askClarifyingQuestions(ctx, c.llmProvider, goal, c.config.Captain.MaxClarifyingQuestions, language)
+	if err != nil || len(questions) == 0 {
+		return nil
+	}
+
+	c.mu.RLock()
+	clarifier := c.clarifier
+	c.mu.RUnlock()
+	answers := make([]string, len(questions))
+	if clarifier != nil {
+		if given, err := clarifier.Clarify(ctx, goal, questions); err == nil {
+			copy(answers, given)
+		}
+	}
+
+	var assumptions []string
+	var transcript strings.Builder
+	transcript.WriteString("Clarifications about the goal:\n")
+	for i, question := range questions {
+		if answer := strings.TrimSpace(answers[i]); answer != "" {
+			fmt.Fprintf(&transcript, "- Q: %s\n  A: %s\n", question.Question, answer)
+			continue
+		}
+		assumptions = append(assumptions, question.Assumption)
+		fmt.Fprintf(&transcript, "- Q: %s\n  Unanswered; assume: %s\n", question.Question, question.Assumption)
+	}
+	conversation.Add(PhaseClarification, "user", transcript.String())
+	return assumptions
+}
+
+// askClarifyingQuestions asks the LLM whether a goal is too ambiguous to plan and, if so,
+// for at most max questions with the assumption it would otherwise make, asked in language
+// when one is set
+func askClarifyingQuestions(ctx context.Context, provider LLMProvider, goal string, max int, language string) ([]ClarifyingQuestion, error) {
+	systemPrompt := fmt.Sprintf(`You review goals before they are planned and executed by a crew of agents. Decide whether the goal is clear enough to plan. If it is, ask nothing. If it is ambiguous, ask at most %d questions whose answers would change the plan, most important first, each with the assumption you would plan on if it goes unanswered.
+
+Respond with a JSON object:
+{"questions": [{"question": "Which environment should be deployed to?", "assumption": "staging"}]}
+
+Respond with {"questions": []} when the goal is clear.`, max)
+	if instruction := languageInstruction(language); instruction != "" {
+		systemPrompt += "\n\n" + instruction
+	}
+	resp, err := provider.GenerateCompletion(ctx, CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: "Goal: " + goal},
+		},
+		MaxTokens:   500,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask clarifying questions: %w", err)
+	}
+
+	var parsed clarificationResponse
+	if err := json.Unmarshal([]byte(extractJSON(resp.Content)), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse clarifying questions: %w", err)
+	}
+	var questions []ClarifyingQuestion
+	for _, question := range parsed.Questions {
+		if strings.TrimSpace(question.Question) == "" {
+			continue
+		}
+		if len(questions) == max {
+			break
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// withAssumptions appends the assumptions a plan was made on to its reasoning
+func withAssumptions(reasoning string, assumptions []string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(reasoning))
+	if b.Len() > 0 {
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Assumptions:")
+	for _, assumption := range assumptions {
+		b.WriteString("\n- " + assumption)
+	}
+	return b.String()
+}
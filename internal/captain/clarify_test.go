@@ -0,0 +1,142 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+const clarificationJSON = `{"questions": [
+	{"question": "Which environment?", "assumption": "staging"},
+	{"question": "Run migrations?", "assumption": "no migrations"},
+	{"question": "Notify anyone?", "assumption": "nobody is notified"}
+]}`
+
+// stubClarifier answers clarifying questions with fixed answers
+type stubClarifier struct {
+	answers   []string
+	err       error
+	questions []ClarifyingQuestion
+}
+
+func (s *stubClarifier) Clarify(ctx context.Context, goal string, questions []ClarifyingQuestion) ([]string, error) {
+	s.questions = questions
+	return s.answers, s.err
+}
+
+// clarifyingCaptain returns a captain whose LLM first asks clarificationJSON, then plans
+// with a prompt containing the clarifications
+func clarifyingCaptain(t *testing.T, maxQuestions int, planned func(CompletionRequest) bool) (*Captain, *MockLLMProvider) {
+	t.Helper()
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return strings.HasPrefix(req.Messages[1].Content, "Goal: ")
+	})).Return(&CompletionResponse{Content: "```json\n" + clarificationJSON + "\n```"}, nil).Once()
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(planned)).Return(&CompletionResponse{Content: conversationPlanJSON}, nil).Once()
+
+	return &Captain{
+		ID:          "captain-1",
+		config:      &config.Config{Captain: config.CaptainConfig{MaxClarifyingQuestions: maxQuestions}},
+		llmProvider: mockLLM,
+		planner:     NewPlanningEngine(mockLLM),
+	}, mockLLM
+}
+
+func TestCaptain_CreatePlanWithClarifier(t *testing.T) {
+	captain, mockLLM := clarifyingCaptain(t, 2, func(req CompletionRequest) bool {
+		// Clarifications precede a fresh planning prompt rather than a revision
+		return len(req.Messages) == 3 &&
+			strings.Contains(req.Messages[1].Content, "Q: Which environment?\n  A: production") &&
+			strings.Contains(req.Messages[1].Content, "Unanswered; assume: no migrations") &&
+			strings.HasPrefix(req.Messages[2].Content, "Create an execution plan")
+	})
+	clarifier := &stubClarifier{answers: []string{"production", " "}}
+	captain.SetClarifier(clarifier)
+
+	plan, err := captain.CreatePlan(context.Background(), "deploy the service")
+	require.NoError(t, err)
+	mockLLM.AssertExpectations(t)
+
+	assert.Len(t, clarifier.questions, 2, "questions are capped at the configured maximum")
+	assert.Equal(t, "Start by measuring\n\nAssumptions:\n- no migrations", plan.Strategy.Description)
+
+	var phases []Phase
+	for _, entry := range captain.Conversation("deploy the service").Entries() {
+		phases = append(phases, entry.Phase)
+	}
+	assert.Equal(t, []Phase{PhaseClarification, PhasePlanning, PhasePlanning}, phases)
+}
+
+func TestCaptain_CreatePlanRecordsAssumptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		clarifier Clarifier
+	}{
+		{"non-interactive", nil},
+		{"clarifier fails", &stubClarifier{err: fmt.Errorf("no terminal")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captain, mockLLM := clarifyingCaptain(t, 5, func(req CompletionRequest) bool {
+				return len(req.Messages) == 3
+			})
+			if tt.clarifier != nil {
+				captain.SetClarifier(tt.clarifier)
+			}
+
+			plan, err := captain.CreatePlan(context.Background(), "deploy the service")
+			require.NoError(t, err)
+			mockLLM.AssertExpectations(t)
+			assert.Equal(t, "Start by measuring\n\nAssumptions:\n- staging\n- no migrations\n- nobody is notified", plan.Strategy.Description)
+		})
+	}
+}
+
+func TestCaptain_CreatePlanWithoutClarification(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *CompletionResponse
+		err      error
+		max      int
+	}{
+		{name: "clear goal", response: &CompletionResponse{Content: `{"questions": []}`}, max: 3},
+		{name: "unparseable response", response: &CompletionResponse{Content: "I have no questions."}, max: 3},
+		{name: "provider error", err: fmt.Errorf("rate limited"), max: 3},
+		{name: "disabled", max: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLLM := &MockLLMProvider{}
+			if tt.max > 0 {
+				mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+					return strings.HasPrefix(req.Messages[1].Content, "Goal: ")
+				})).Return(tt.response, tt.err).Once()
+			}
+			mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+				return len(req.Messages) == 2 && strings.HasPrefix(req.Messages[1].Content, "Create an execution plan")
+			})).Return(&CompletionResponse{Content: conversationPlanJSON}, nil).Once()
+
+			captain := &Captain{
+				ID:          "captain-1",
+				config:      &config.Config{Captain: config.CaptainConfig{MaxClarifyingQuestions: tt.max}},
+				llmProvider: mockLLM,
+				planner:     NewPlanningEngine(mockLLM),
+			}
+			clarifier := &stubClarifier{}
+			captain.SetClarifier(clarifier)
+
+			plan, err := captain.CreatePlan(context.Background(), "analyze code quality")
+			require.NoError(t, err)
+			mockLLM.AssertExpectations(t)
+			assert.Equal(t, "Start by measuring", plan.Strategy.Description)
+			assert.Nil(t, clarifier.questions, "nothing is asked")
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/transport"
+)
+
+// OpenAICompatibleConfig holds configuration for a server speaking the OpenAI API
+type OpenAICompatibleConfig struct {
+	// Name identifies the provider in errors, defaulting to its type
+	Name        string  `yaml:"name,omitempty"`
+	APIKey      string  `yaml:"api_key,omitempty"`
+	Model       string  `yaml:"model"`
+	BaseURL     string  `yaml:"base_url"`
+	Temperature float64 `yaml:"temperature"`
+	// Capabilities declares the optional API features the server offers
+	Capabilities config.ProviderCapabilities `yaml:"capabilities,omitempty"`
+	// HTTP sets the proxies and certificate authorities used to reach the server
+	HTTP config.HTTPConfig `yaml:"http,omitempty"`
+}
+
+// Validate validates the OpenAI-compatible configuration
+func (c *OpenAICompatibleConfig) Validate() error {
+	validator := common.NewValidator()
+	validator.AddRule("model", common.Required("model"))
+	validator.AddRule("base_url", common.Required("base_url"))
+
+	return validator.Validate(map[string]interface{}{
+		"model":    c.Model,
+		"base_url": c.BaseURL,
+	})
+}
+
+// Capabilities describes the optional API features a provider offers
+type Capabilities struct {
+	FunctionCalling bool
+	Embeddings      bool
+}
+
+// CapabilityReporter is implemented by providers whose features depend on the server they
+// are configured for
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// OpenAICompatibleProvider implements LLMProvider for any server speaking the OpenAI chat
+// completions API at a configured base URL. Nothing is assumed about the server beyond the
+// /chat/completions, /embeddings and /models endpoints; an API key is only sent when set.
+type OpenAICompatibleProvider struct {
+	config OpenAICompatibleConfig
+	client *http.Client
+}
+
+// NewOpenAICompatibleProvider creates a provider for the server at config.BaseURL
+func NewOpenAICompatibleProvider(config OpenAICompatibleConfig) (*OpenAICompatibleProvider, error) {
+	validatedConfig, err := common.NewConfigBuilder(config).
+		With(func(c *OpenAICompatibleConfig) {
+			if c.Name == "" {
+				c.Name = "openai-compatible"
+			}
+			c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+		}).
+		Validate(func(c OpenAICompatibleConfig) error {
+			return c.Validate()
+		}).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAI-compatible config: %w", err)
+	}
+
+	client, err := transport.NewHTTPClient(validatedConfig.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAI-compatible config: http: %w", err)
+	}
+	return &OpenAICompatibleProvider{config: validatedConfig, client: client}, nil
+}
+
+// Capabilities returns the features the server was configured as offering
+func (p *OpenAICompatibleProvider) Capabilities() Capabilities {
+	return Capabilities{
+		FunctionCalling: p.config.Capabilities.FunctionCalling,
+		Embeddings:      p.config.Capabilities.Embeddings,
+	}
+}
+
+// headers returns the headers sent with every request
+func (p *OpenAICompatibleProvider) headers() map[string]string {
+	if p.config.APIKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.config.APIKey}
+}
+
+// chatCompletionRequest is the /chat/completions request body
+type chatCompletionRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature float64   `json:"temperature"`
+	Stream      bool      `json:"stream"`
+}
+
+// chatCompletionResponse is the /chat/completions response body
+type chatCompletionResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+// GenerateCompletion generates a completion using the server's chat completions API
+func (p *OpenAICompatibleProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid completion request: %w", err)
+	}
+
+	body := chatCompletionRequest{
+		Model:       p.config.Model,
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+
+	var resp chatCompletionResponse
+	if err := postJSON(ctx, p.client, p.config.BaseURL+"/chat/completions", p.headers(), body, &resp); err != nil {
+		return nil, fmt.Errorf("%s completion: %w", p.config.Name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s completion: response has no choices", p.config.Name)
+	}
+	return &CompletionResponse{
+		Content:      resp.Choices[0].Message.Content,
+		TokensUsed:   resp.Usage.TotalTokens,
+		Model:        resp.Model,
+		FinishReason: resp.Choices[0].FinishReason,
+	}, nil
+}
+
+// GenerateEmbedding generates an embedding using the server's embeddings API, when it has one
+func (p *OpenAICompatibleProvider) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	if !p.config.Capabilities.Embeddings {
+		return nil, fmt.Errorf("%s provider does not support embeddings; set capabilities.embeddings if the server does", p.config.Name)
+	}
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+
+	model := p.config.Capabilities.EmbeddingModel
+	if model == "" {
+		model = p.config.Model
+	}
+	body := map[string]string{"model": model, "input": text}
+	var resp struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postJSON(ctx, p.client, p.config.BaseURL+"/embeddings", p.headers(), body, &resp); err != nil {
+		return nil, fmt.Errorf("%s embedding: %w", p.config.Name, err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("%s embedding: response has no data", p.config.Name)
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// Ping lists the server's models and checks the configured model is among them
+func (p *OpenAICompatibleProvider) Ping(ctx context.Context) error {
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.client, p.config.BaseURL+"/models", p.headers(), &resp); err != nil {
+		return fmt.Errorf("%s ping: %w", p.config.Name, err)
+	}
+	for _, model := range resp.Data {
+		if model.ID == p.config.Model {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s ping: %w: the server does not list %s", p.config.Name, ErrModelUnavailable, p.config.Model)
+}
@@ -0,0 +1,87 @@
+package captain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// PlanConstraints are operational limits a plan must fit, given for a single run. The planner
+// is told about them up front and plans breaking them are rejected, so plans are made to fit
+// rather than trimmed afterwards.
+type PlanConstraints struct {
+	// Deadline bounds the plan's estimated duration
+	Deadline time.Duration
+	// MaxSteps bounds the number of steps in the plan
+	MaxSteps int
+	// Tools lists the agent types the plan's steps may run on; empty allows any
+	Tools []agents.AgentType
+}
+
+// IsZero reports whether the constraints set no limit
+func (pc PlanConstraints) IsZero() bool {
+	return pc.Deadline <= 0 && pc.MaxSteps <= 0 && len(pc.Tools) == 0
+}
+
+// Rules returns the static rules enforcing the constraints
+func (pc PlanConstraints) Rules() []PlanRule {
+	var rules []PlanRule
+	if pc.MaxSteps > 0 {
+		rules = append(rules, MaxTasksRule{Limit: pc.MaxSteps})
+	}
+	if pc.Deadline > 0 {
+		rules = append(rules, MaxDurationRule{Limit: pc.Deadline})
+	}
+	if len(pc.Tools) > 0 {
+		rules = append(rules, AllowedToolsRule{Tools: pc.Tools})
+	}
+	return rules
+}
+
+// Prompt describes the constraints for the planning prompt
+func (pc PlanConstraints) Prompt() string {
+	if pc.IsZero() {
+		return ""
+	}
+	lines := []string{"## Constraints:", "The plan must fit these limits; plans that break them are rejected."}
+	if pc.Deadline > 0 {
+		lines = append(lines, fmt.Sprintf("- estimated_duration must be at most %s", pc.Deadline))
+	}
+	if pc.MaxSteps > 0 {
+		lines = append(lines, fmt.Sprintf("- use at most %d tasks", pc.MaxSteps))
+	}
+	if len(pc.Tools) > 0 {
+		tools := make([]string, len(pc.Tools))
+		for i, tool := range pc.Tools {
+			tools[i] = string(tool)
+		}
+		lines = append(lines, fmt.Sprintf(`- only these agents may run steps: %s. Analysis and reporting steps run on the research agent and other steps on the file agent, unless "agent" names another`, strings.Join(tools, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AllowedToolsRule limits the agent types a plan's steps may run on
+type AllowedToolsRule struct {
+	Tools []agents.AgentType
+}
+
+// Name returns the rule name
+func (r AllowedToolsRule) Name() string { return "allowed_tools" }
+
+// Check reports each step that would run on an agent type not in Tools
+func (r AllowedToolsRule) Check(plan *ExecutionPlan) []Violation {
+	var violations []Violation
+	for _, task := range plan.Tasks {
+		if agentType := AgentTypeFor(task); !slices.Contains(r.Tools, agentType) {
+			violations = append(violations, Violation{
+				Rule:    r.Name(),
+				TaskID:  task.ID,
+				Message: fmt.Sprintf("runs on the %s agent, which is not allowed", agentType),
+			})
+		}
+	}
+	return violations
+}
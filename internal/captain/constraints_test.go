@@ -0,0 +1,84 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestPlanConstraints_Prompt(t *testing.T) {
+	assert.Empty(t, PlanConstraints{}.Prompt())
+	assert.Empty(t, PlanConstraints{}.Rules())
+
+	constraints := PlanConstraints{
+		Deadline: 10 * time.Minute,
+		MaxSteps: 8,
+		Tools:    []agents.AgentType{agents.AgentTypeFile, agents.AgentTypeNetwork},
+	}
+	prompt := constraints.Prompt()
+	assert.Contains(t, prompt, "## Constraints:")
+	assert.Contains(t, prompt, "- estimated_duration must be at most 10m0s")
+	assert.Contains(t, prompt, "- use at most 8 tasks")
+	assert.Contains(t, prompt, "- only these agents may run steps: file, network.")
+
+	names := make([]string, 0, 3)
+	for _, rule := range constraints.Rules() {
+		names = append(names, rule.Name())
+	}
+	assert.Equal(t, []string{"max_tasks", "max_duration", "allowed_tools"}, names)
+}
+
+func TestAllowedToolsRule(t *testing.T) {
+	plan := rulesTestPlan()
+	plan.Tasks[2].Payload["agent"] = "network"
+
+	violations := AllowedToolsRule{Tools: []agents.AgentType{agents.AgentTypeFile}}.Check(plan)
+	assert.Equal(t, []Violation{
+		{Rule: "allowed_tools", TaskID: "task-1", Message: "runs on the research agent, which is not allowed"},
+		{Rule: "allowed_tools", TaskID: "task-3", Message: "runs on the network agent, which is not allowed"},
+	}, violations)
+
+	rule := AllowedToolsRule{Tools: []agents.AgentType{agents.AgentTypeFile, agents.AgentTypeNetwork, agents.AgentTypeResearch}}
+	assert.Empty(t, rule.Check(plan))
+}
+
+func TestPlanningEngine_Constraints(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	var prompt string
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		prompt = args.Get(1).(CompletionRequest).Messages[0].Content
+	}).Return(&CompletionResponse{Content: `{
+		"tasks": [
+			{"id": "task-1", "type": "analysis", "priority": "high", "description": "Read the docs", "dependencies": []},
+			{"id": "task-2", "type": "execution", "priority": "high", "description": "Call the API", "agent": "network", "dependencies": ["task-1"]}
+		],
+		"strategy": "sequential",
+		"estimated_duration": "20m"
+	}`}, nil)
+
+	engine := NewPlanningEngine(mockLLM)
+	engine.SetConstraints(PlanConstraints{Deadline: 10 * time.Minute, Tools: []agents.AgentType{agents.AgentTypeNetwork}})
+	_, err := engine.CreatePlan(context.Background(), "check the API")
+	require.Error(t, err)
+	assert.Contains(t, prompt, "- estimated_duration must be at most 10m0s")
+
+	var violationErr *RuleViolationError
+	require.True(t, errors.As(err, &violationErr))
+	assert.Equal(t, []Violation{
+		{Rule: "max_duration", Message: "plan is estimated to take 20m0s, limit is 10m0s"},
+		{Rule: "allowed_tools", TaskID: "task-1", Message: "runs on the research agent, which is not allowed"},
+	}, violationErr.Report.Violations)
+
+	engine.SetConstraints(PlanConstraints{})
+	plan, err := engine.CreatePlan(context.Background(), "check the API")
+	require.NoError(t, err)
+	assert.NotContains(t, prompt, "## Constraints:")
+	assert.Equal(t, agents.AgentTypeNetwork, AgentTypeFor(plan.Tasks[1]))
+}
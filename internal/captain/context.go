@@ -0,0 +1,150 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// maxContextChanges caps how many uncommitted files are listed in the planning context
+const maxContextChanges = 10
+
+// projectMarkers maps files found in the working directory to the project type they indicate
+var projectMarkers = []struct {
+	file    string
+	project string
+}{
+	{"go.mod", "go"},
+	{"package.json", "node"},
+	{"Cargo.toml", "rust"},
+	{"pyproject.toml", "python"},
+	{"requirements.txt", "python"},
+	{"setup.py", "python"},
+	{"pom.xml", "java"},
+	{"build.gradle", "java"},
+	{"Gemfile", "ruby"},
+	{"composer.json", "php"},
+	{"Makefile", "make"},
+	{"Dockerfile", "docker"},
+}
+
+// GitContext describes the repository the Captain is planning in
+type GitContext struct {
+	Branch  string   `json:"branch"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+// PlanningContext is a snapshot of the environment a goal will run in
+type PlanningContext struct {
+	WorkingDir   string      `json:"working_dir"`
+	OS           string      `json:"os"`
+	Arch         string      `json:"arch"`
+	Git          *GitContext `json:"git,omitempty"`
+	ProjectTypes []string    `json:"project_types,omitempty"`
+	Tools        []string    `json:"tools,omitempty"`
+}
+
+// Summary renders the context as a short block for the planning prompt
+func (pc *PlanningContext) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "- Working directory: %s\n", pc.WorkingDir)
+	fmt.Fprintf(&b, "- OS: %s/%s\n", pc.OS, pc.Arch)
+	if pc.Git != nil {
+		fmt.Fprintf(&b, "- Git branch: %s", pc.Git.Branch)
+		if len(pc.Git.Changes) == 0 {
+			b.WriteString(" (clean)\n")
+		} else {
+			fmt.Fprintf(&b, " (%d uncommitted changes)\n", len(pc.Git.Changes))
+			shown := pc.Git.Changes
+			if len(shown) > maxContextChanges {
+				shown = shown[:maxContextChanges]
+			}
+			for _, change := range shown {
+				fmt.Fprintf(&b, "  - %s\n", change)
+			}
+			if hidden := len(pc.Git.Changes) - len(shown); hidden > 0 {
+				fmt.Fprintf(&b, "  - ... and %d more\n", hidden)
+			}
+		}
+	}
+	if len(pc.ProjectTypes) > 0 {
+		fmt.Fprintf(&b, "- Project type: %s\n", strings.Join(pc.ProjectTypes, ", "))
+	}
+	if len(pc.Tools) > 0 {
+		fmt.Fprintf(&b, "- Available tools: %s\n", strings.Join(pc.Tools, ", "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ContextGatherer collects the planning context for a working directory
+type ContextGatherer struct {
+	dir   string
+	git   bool
+	tools []string
+
+	// run and lookPath are replaced in tests
+	run      func(ctx context.Context, dir, name string, args ...string) (string, error)
+	lookPath func(file string) (string, error)
+}
+
+// NewContextGatherer creates a gatherer for dir configured by the planning.context settings
+func NewContextGatherer(dir string, cfg config.PlanningContextConfig) *ContextGatherer {
+	tools := cfg.Tools
+	if len(tools) == 0 {
+		tools = config.DefaultPlanningTools
+	}
+	return &ContextGatherer{
+		dir:      dir,
+		git:      cfg.Git,
+		tools:    tools,
+		run:      runCommand,
+		lookPath: exec.LookPath,
+	}
+}
+
+// Gather collects the context. Probes that fail are left out rather than failing planning.
+func (g *ContextGatherer) Gather(ctx context.Context) *PlanningContext {
+	pc := &PlanningContext{WorkingDir: g.dir, OS: runtime.GOOS, Arch: runtime.GOARCH}
+
+	if g.git {
+		if branch, err := g.run(ctx, g.dir, "git", "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+			pc.Git = &GitContext{Branch: strings.TrimSpace(branch)}
+			if status, err := g.run(ctx, g.dir, "git", "status", "--porcelain"); err == nil {
+				for _, line := range strings.Split(status, "\n") {
+					if line = strings.TrimSpace(line); line != "" {
+						pc.Git.Changes = append(pc.Git.Changes, line)
+					}
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, marker := range projectMarkers {
+		if _, err := os.Stat(filepath.Join(g.dir, marker.file)); err == nil && !seen[marker.project] {
+			seen[marker.project] = true
+			pc.ProjectTypes = append(pc.ProjectTypes, marker.project)
+		}
+	}
+
+	for _, tool := range g.tools {
+		if _, err := g.lookPath(tool); err == nil {
+			pc.Tools = append(pc.Tools, tool)
+		}
+	}
+	return pc
+}
+
+// runCommand runs a command in dir and returns its standard output
+func runCommand(ctx context.Context, dir, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,106 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// fakeGatherer returns a gatherer for dir with canned git output and the given tools on the PATH
+func fakeGatherer(dir string, gitOutput map[string]string, tools ...string) *ContextGatherer {
+	g := NewContextGatherer(dir, config.PlanningContextConfig{Enabled: true, Git: true, Tools: []string{"go", "docker", "make"}})
+	g.run = func(ctx context.Context, dir, name string, args ...string) (string, error) {
+		out, ok := gitOutput[strings.Join(args, " ")]
+		if !ok {
+			return "", errors.New("not a git repository")
+		}
+		return out, nil
+	}
+	available := make(map[string]bool)
+	for _, tool := range tools {
+		available[tool] = true
+	}
+	g.lookPath = func(file string) (string, error) {
+		if available[file] {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+	return g
+}
+
+func TestContextGatherer_Gather(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Makefile"), []byte("all:\n"), 0o644))
+
+	pc := fakeGatherer(dir, map[string]string{
+		"rev-parse --abbrev-ref HEAD": "main\n",
+		"status --porcelain":          " M main.go\n?? notes.txt\n",
+	}, "go", "make").Gather(context.Background())
+
+	assert.Equal(t, dir, pc.WorkingDir)
+	assert.Equal(t, runtime.GOOS, pc.OS)
+	assert.Equal(t, &GitContext{Branch: "main", Changes: []string{"M main.go", "?? notes.txt"}}, pc.Git)
+	assert.Equal(t, []string{"go", "make"}, pc.ProjectTypes)
+	assert.Equal(t, []string{"go", "make"}, pc.Tools)
+}
+
+func TestContextGatherer_GatherOutsideGit(t *testing.T) {
+	pc := fakeGatherer(t.TempDir(), nil).Gather(context.Background())
+	assert.Nil(t, pc.Git)
+	assert.Empty(t, pc.ProjectTypes)
+	assert.Empty(t, pc.Tools)
+
+	g := fakeGatherer(t.TempDir(), map[string]string{"rev-parse --abbrev-ref HEAD": "main"})
+	g.git = false
+	assert.Nil(t, g.Gather(context.Background()).Git, "git probing can be disabled")
+}
+
+func TestPlanningContext_Summary(t *testing.T) {
+	pc := &PlanningContext{
+		WorkingDir:   "/src/app",
+		OS:           "linux",
+		Arch:         "amd64",
+		Git:          &GitContext{Branch: "feature"},
+		ProjectTypes: []string{"go"},
+		Tools:        []string{"git", "go"},
+	}
+	assert.Equal(t, `- Working directory: /src/app
+- OS: linux/amd64
+- Git branch: feature (clean)
+- Project type: go
+- Available tools: git, go`, pc.Summary())
+
+	for i := 0; i < maxContextChanges+2; i++ {
+		pc.Git.Changes = append(pc.Git.Changes, "M file.go")
+	}
+	summary := pc.Summary()
+	assert.Contains(t, summary, "- Git branch: feature (12 uncommitted changes)")
+	assert.Contains(t, summary, "  - ... and 2 more")
+}
+
+func TestPlanningEngine_CreatePlanWithContext(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return strings.Contains(req.Messages[1].Content, "## Environment:\n- Working directory: ")
+	})).Return(&CompletionResponse{
+		Content: `{"tasks": [{"id": "task-1", "type": "analysis", "priority": "medium", "description": "Look around"}], "strategy": "sequential", "estimated_duration": "1m"}`,
+	}, nil)
+
+	engine := NewPlanningEngine(provider)
+	engine.SetContextGatherer(fakeGatherer(t.TempDir(), nil))
+	_, err := engine.CreatePlan(context.Background(), "look around")
+	require.NoError(t, err)
+	provider.AssertExpectations(t)
+}
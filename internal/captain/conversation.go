@@ -0,0 +1,245 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConversationTokens is the token budget of a conversation before older entries
+// are summarized
+const DefaultConversationTokens = 6000
+
+// maxConversations bounds how many goals the Captain keeps conversations for
+const maxConversations = 32
+
+// conversationKeepRecent is how many of the latest entries survive a summarization verbatim
+const conversationKeepRecent = 4
+
+// Phase is the part of a goal's lifecycle a conversation entry comes from
+type Phase string
+
+const (
+	PhaseBackground    Phase = "background"
+	PhaseClarification Phase = "clarification"
+	PhasePlanning      Phase = "planning"
+	PhaseValidation    Phase = "validation"
+	PhaseEffects       Phase = "effects"
+	PhaseExecution     Phase = "execution"
+	PhaseReplanning    Phase = "replanning"
+	PhaseDiscussion    Phase = "discussion"
+)
+
+// ConversationEntry is one message exchanged about a goal
+type ConversationEntry struct {
+	Phase     Phase     `json:"phase"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Conversation keeps the Captain's reasoning and decisions about one goal across planning,
+// validation, execution feedback and replanning, so each LLM call sees what came before.
+// When the entries outgrow the token budget the oldest are folded into a summary.
+type Conversation struct {
+	mu         sync.Mutex
+	goal       string
+	entries    []ConversationEntry
+	summary    string
+	budget     int
+	summarizer LLMProvider
+}
+
+// NewConversation creates an empty conversation about a goal
+func NewConversation(goal string) *Conversation {
+	return &Conversation{goal: goal, budget: DefaultConversationTokens}
+}
+
+// SetTokenBudget sets the tokens the conversation may hold before it is summarized
+func (c *Conversation) SetTokenBudget(tokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = tokens
+}
+
+// SetSummarizer sets the provider used to summarize older entries. Without one, older
+// entries are condensed to their first lines.
+func (c *Conversation) SetSummarizer(provider LLMProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summarizer = provider
+}
+
+// Goal returns the goal the conversation is about
+func (c *Conversation) Goal() string {
+	return c.goal
+}
+
+// Add appends a message to the conversation
+func (c *Conversation) Add(phase Phase, role, content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, ConversationEntry{Phase: phase, Role: role, Content: content, Timestamp: time.Now()})
+}
+
+// RecordExecution adds the outcome of running a plan as execution feedback
+func (c *Conversation) RecordExecution(plan *ExecutionPlan, result *ExecutionResult) {
+	if result == nil {
+		return
+	}
+	succeeded := 0
+	var failures []string
+	for _, taskResult := range result.TaskResults {
+		if taskResult.Success {
+			succeeded++
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("- %s: %s", taskResult.TaskID, taskResult.Error))
+	}
+
+	total := len(result.TaskResults)
+	if plan != nil {
+		total = len(plan.Tasks)
+	}
+	feedback := fmt.Sprintf("Execution feedback for plan %s: %d of %d steps succeeded.", result.PlanID, succeeded, total)
+	if result.Interrupted {
+		feedback += " Execution was interrupted."
+	}
+	if result.Error != "" {
+		feedback += " Error: " + result.Error
+	}
+	if len(failures) > 0 {
+		feedback += "\nFailed steps:\n" + strings.Join(failures, "\n")
+	}
+	c.Add(PhaseExecution, "user", feedback)
+}
+
+// Entries returns a copy of the entries not yet summarized
+func (c *Conversation) Entries() []ConversationEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ConversationEntry(nil), c.entries...)
+}
+
+// Summary returns the summary of entries folded out of the conversation, if any
+func (c *Conversation) Summary() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.summary
+}
+
+// Messages returns the conversation as prompt messages: the summary of older entries,
+// then the recent entries in order
+func (c *Conversation) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var messages []Message
+	if c.summary != "" {
+		messages = append(messages, Message{Role: "user", Content: "Summary of earlier work on this goal:\n" + c.summary})
+	}
+	for _, entry := range c.entries {
+		messages = append(messages, Message{Role: entry.Role, Content: entry.Content})
+	}
+	return messages
+}
+
+// Planned reports whether the goal has been planned in this conversation, as opposed to only
+// given background or clarified
+func (c *Conversation) Planned() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.summary != "" {
+		return true
+	}
+	for _, entry := range c.entries {
+		if entry.Phase != PhaseBackground && entry.Phase != PhaseClarification {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokens estimates the tokens the conversation adds to a prompt
+func (c *Conversation) Tokens() int {
+	return estimateTokens(CompletionRequest{Messages: c.Messages()})
+}
+
+// Compact folds the oldest entries into the summary while the conversation is over its
+// token budget, keeping the latest entries verbatim
+func (c *Conversation) Compact(ctx context.Context) {
+	c.mu.Lock()
+	budget := c.budget
+	summarizer := c.summarizer
+	c.mu.Unlock()
+	if budget <= 0 || c.Tokens() <= budget {
+		return
+	}
+
+	c.mu.Lock()
+	if len(c.entries) <= conversationKeepRecent {
+		c.mu.Unlock()
+		return
+	}
+	split := len(c.entries) - conversationKeepRecent
+	older := append([]ConversationEntry(nil), c.entries[:split]...)
+	previous := c.summary
+	c.mu.Unlock()
+
+	summary := summarizeEntries(ctx, summarizer, c.goal, previous, older)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Entries added while summarizing stay after the ones folded in
+	c.entries = append([]ConversationEntry(nil), c.entries[len(older):]...)
+	c.summary = summary
+}
+
+// summarizeEntries summarizes older entries together with the previous summary, falling
+// back to the first line of each entry when the provider is missing or fails
+func summarizeEntries(ctx context.Context, provider LLMProvider, goal, previous string, entries []ConversationEntry) string {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Earlier summary:\n" + previous + "\n\n")
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(&transcript, "[%s] %s: %s\n\n", entry.Phase, entry.Role, entry.Content)
+	}
+
+	if provider != nil {
+		resp, err := provider.GenerateCompletion(ctx, CompletionRequest{
+			Messages: []Message{
+				{Role: "system", Content: `You condense a planning discussion so it can be continued later. Keep the decisions made, the reasons for them, constraints discovered, rejected plans with why, and execution failures. Drop restated prompts and JSON detail. Answer with the summary only.`},
+				{Role: "user", Content: fmt.Sprintf("Goal: %s\n\n%s", goal, transcript.String())},
+			},
+			MaxTokens:   500,
+			Temperature: 0,
+		})
+		if err == nil && strings.TrimSpace(resp.Content) != "" {
+			return strings.TrimSpace(resp.Content)
+		}
+	}
+
+	lines := make([]string, 0, len(entries)+1)
+	if previous != "" {
+		lines = append(lines, previous)
+	}
+	for _, entry := range entries {
+		first, _, _ := strings.Cut(strings.TrimSpace(entry.Content), "\n")
+		lines = append(lines, fmt.Sprintf("- [%s] %s: %s", entry.Phase, entry.Role, truncateText(first, 200)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateText shortens s to at most n runes, marking the cut with an ellipsis
+func truncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,242 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+const conversationPlanJSON = `{
+	"tasks": [
+		{"id": "task-1", "type": "analysis", "priority": "high", "description": "Analyze code quality", "dependencies": []}
+	],
+	"strategy": "sequential",
+	"estimated_duration": "15m",
+	"reasoning": "Start by measuring"
+}`
+
+func TestConversation_Messages(t *testing.T) {
+	conversation := NewConversation("improve tests")
+	conversation.Add(PhasePlanning, "user", "plan it")
+	conversation.Add(PhasePlanning, "assistant", "here is a plan")
+	conversation.Add(PhaseValidation, "user", "  ")
+
+	assert.Equal(t, "improve tests", conversation.Goal())
+	assert.Equal(t, []Message{
+		{Role: "user", Content: "plan it"},
+		{Role: "assistant", Content: "here is a plan"},
+	}, conversation.Messages())
+	assert.Equal(t, len("plan it"+"here is a plan")/4, conversation.Tokens())
+}
+
+func TestConversation_RecordExecution(t *testing.T) {
+	conversation := NewConversation("deploy")
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{{ID: "task-1"}, {ID: "task-2"}, {ID: "task-3"}}}
+	conversation.RecordExecution(plan, &ExecutionResult{
+		PlanID: "plan-1",
+		Error:  "step failed",
+		TaskResults: []Result{
+			{TaskID: "task-1", Success: true},
+			{TaskID: "task-2", Error: "permission denied"},
+		},
+	})
+	conversation.RecordExecution(plan, nil)
+
+	entries := conversation.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, PhaseExecution, entries[0].Phase)
+	assert.Equal(t, "Execution feedback for plan plan-1: 1 of 3 steps succeeded. Error: step failed\nFailed steps:\n- task-2: permission denied", entries[0].Content)
+}
+
+func TestConversation_CompactSummarizes(t *testing.T) {
+	summarizer := &MockLLMProvider{}
+	summarizer.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return strings.Contains(req.Messages[1].Content, "[planning] user: entry 0")
+	})).Return(&CompletionResponse{Content: "Chose plan A over B."}, nil).Once()
+
+	conversation := NewConversation("goal")
+	conversation.SetSummarizer(summarizer)
+	conversation.SetTokenBudget(20)
+	for i := 0; i < 6; i++ {
+		conversation.Add(PhasePlanning, "user", fmt.Sprintf("entry %d %s", i, strings.Repeat("x", 20)))
+	}
+
+	conversation.Compact(context.Background())
+
+	summarizer.AssertExpectations(t)
+	assert.Equal(t, "Chose plan A over B.", conversation.Summary())
+	entries := conversation.Entries()
+	require.Len(t, entries, conversationKeepRecent)
+	assert.True(t, strings.HasPrefix(entries[0].Content, "entry 2"))
+	messages := conversation.Messages()
+	assert.Equal(t, "Summary of earlier work on this goal:\nChose plan A over B.", messages[0].Content)
+
+	// Under budget nothing is summarized
+	conversation.SetTokenBudget(1000)
+	conversation.Compact(context.Background())
+	assert.Len(t, conversation.Entries(), conversationKeepRecent)
+}
+
+func TestConversation_CompactFallback(t *testing.T) {
+	summarizer := &MockLLMProvider{}
+	summarizer.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("offline"))
+
+	conversation := NewConversation("goal")
+	conversation.SetSummarizer(summarizer)
+	conversation.SetTokenBudget(10)
+	conversation.Add(PhasePlanning, "assistant", "first line\nsecond line")
+	for i := 0; i < conversationKeepRecent; i++ {
+		conversation.Add(PhaseExecution, "user", strings.Repeat("y", 40))
+	}
+
+	conversation.Compact(context.Background())
+
+	assert.Equal(t, "- [planning] assistant: first line", conversation.Summary())
+	assert.Len(t, conversation.Entries(), conversationKeepRecent)
+}
+
+func TestPlanningEngine_PlanWithConversationRecordsRejection(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: "not a plan"}, nil).Once()
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		last := req.Messages[len(req.Messages)-1].Content
+		return len(req.Messages) == 5 &&
+			strings.Contains(req.Messages[3].Content, "That plan was rejected") &&
+			strings.HasPrefix(last, "Revise the execution plan")
+	})).Return(&CompletionResponse{Content: conversationPlanJSON}, nil).Once()
+
+	planner := NewPlanningEngine(mockLLM)
+	conversation := NewConversation("improve code")
+
+	_, err := planner.PlanWithConversation(context.Background(), "improve code", conversation)
+	require.Error(t, err)
+	plan, err := planner.PlanWithConversation(context.Background(), "improve code", conversation)
+	require.NoError(t, err)
+	assert.Len(t, plan.Tasks, 1)
+	mockLLM.AssertExpectations(t)
+
+	var phases []Phase
+	for _, entry := range conversation.Entries() {
+		phases = append(phases, entry.Phase)
+	}
+	assert.Equal(t, []Phase{PhasePlanning, PhasePlanning, PhaseValidation, PhaseReplanning, PhaseReplanning}, phases)
+}
+
+func TestCaptain_ReplanUsesConversation(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 2
+	})).Return(&CompletionResponse{Content: conversationPlanJSON}, nil).Once()
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		var feedback bool
+		for _, message := range req.Messages {
+			if strings.HasPrefix(message.Content, "Execution feedback for plan") {
+				feedback = true
+			}
+		}
+		return feedback && strings.Contains(req.Messages[2].Content, "Start by measuring")
+	})).Return(&CompletionResponse{Content: conversationPlanJSON}, nil).Once()
+
+	captain := &Captain{
+		ID:          "captain-1",
+		config:      &config.Config{Captain: config.CaptainConfig{ConversationTokens: 4000}},
+		llmProvider: mockLLM,
+		planner:     NewPlanningEngine(mockLLM),
+	}
+
+	ctx := context.Background()
+	plan, err := captain.CreatePlan(ctx, "improve code")
+	require.NoError(t, err)
+	result, err := captain.ExecutePlan(ctx, plan, false)
+	require.NoError(t, err)
+
+	revised, err := captain.Replan(ctx, plan, result)
+	require.NoError(t, err)
+	assert.NotEmpty(t, revised.Tasks)
+	mockLLM.AssertExpectations(t)
+
+	// Planning, execution feedback and replanning are all held for the goal
+	conversation := captain.Conversation("improve code")
+	require.NotNil(t, conversation)
+	assert.Len(t, conversation.Entries(), 5)
+	captain.ForgetConversation("improve code")
+	assert.Nil(t, captain.Conversation("improve code"))
+}
+
+func TestCaptain_CreatePlanWithBackground(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		// The background comes before the planning prompt, which is still a first plan
+		return len(req.Messages) == 3 && req.Messages[1].Content == "Background for the goal:\nThe /health endpoint hangs under load." &&
+			!strings.HasPrefix(req.Messages[2].Content, "Revise the execution plan")
+	})).Return(&CompletionResponse{Content: conversationPlanJSON}, nil).Once()
+
+	captain := &Captain{
+		ID:          "captain-1",
+		config:      &config.Config{Captain: config.CaptainConfig{ConversationTokens: 4000}},
+		llmProvider: mockLLM,
+		planner:     NewPlanningEngine(mockLLM),
+	}
+
+	plan, err := captain.CreatePlanWithBackground(context.Background(), "fix health check", "  The /health endpoint hangs under load.\n")
+	require.NoError(t, err)
+	assert.NotEmpty(t, plan.Tasks)
+	mockLLM.AssertExpectations(t)
+
+	entries := captain.Conversation("fix health check").Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, PhaseBackground, entries[0].Phase)
+	assert.Equal(t, PhasePlanning, entries[1].Phase)
+}
+
+func TestCaptain_ConversationLimit(t *testing.T) {
+	captain := &Captain{}
+	first := captain.startConversation("goal-0")
+	for i := 1; i <= maxConversations; i++ {
+		captain.startConversation(fmt.Sprintf("goal-%d", i))
+	}
+
+	assert.NotNil(t, first)
+	assert.Nil(t, captain.Conversation("goal-0"))
+	assert.NotNil(t, captain.Conversation(fmt.Sprintf("goal-%d", maxConversations)))
+	assert.Len(t, captain.conversations, maxConversations)
+}
+
+func TestCaptain_Ask(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 3 && req.Messages[1].Content == "Step step-1 failed: exit 1" && req.Messages[2].Content == "why did it fail?"
+	})).Return(&CompletionResponse{Content: " The tests failed. "}, nil).Once()
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 5 && req.Messages[3].Content == "The tests failed."
+	})).Return(&CompletionResponse{Content: "Fix the assertion."}, nil).Once()
+
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM}
+	ctx := context.Background()
+
+	answer, err := captain.Ask(ctx, "ship it", "Step step-1 failed: exit 1", "why did it fail?")
+	require.NoError(t, err)
+	assert.Equal(t, "The tests failed.", answer)
+
+	// Follow-up questions continue the same conversation; background only seeds a new one
+	answer, err = captain.Ask(ctx, "ship it", "ignored", "what now?")
+	require.NoError(t, err)
+	assert.Equal(t, "Fix the assertion.", answer)
+	mockLLM.AssertExpectations(t)
+
+	entries := captain.Conversation("ship it").Entries()
+	require.Len(t, entries, 5)
+	assert.Equal(t, PhaseDiscussion, entries[4].Phase)
+
+	_, err = captain.Ask(ctx, "ship it", "", " ")
+	assert.EqualError(t, err, "question cannot be empty")
+	_, err = (&Captain{}).Ask(ctx, "ship it", "", "hello?")
+	assert.EqualError(t, err, "no LLM provider configured")
+}
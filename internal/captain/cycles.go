@@ -0,0 +1,93 @@
+package captain
+
+import "strings"
+
+// CycleError is returned for a plan whose task dependencies form a cycle, which would leave
+// every task on it waiting for another
+type CycleError struct {
+	// Path lists the task IDs along the cycle, each depending on the next; it starts and ends
+	// with the same task
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "circular dependency detected: " + strings.Join(e.Path, " -> ")
+}
+
+// FindDependencyCycle returns a dependency cycle among the tasks as the path of task IDs around
+// it, or nil when the dependencies can be ordered. The result is the same for the same plan.
+// Dependencies on tasks outside the list are ignored.
+func FindDependencyCycle(tasks []Task) []string {
+	known := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		known[task.ID] = true
+	}
+
+	// Kahn's algorithm: repeatedly take tasks whose dependencies have all been taken. Whatever
+	// is left is on a cycle or depends on one.
+	pending := make(map[string]int, len(tasks))
+	dependents := make(map[string][]string)
+	for _, task := range tasks {
+		pending[task.ID] += 0
+		for _, dep := range task.Dependencies {
+			if known[dep] {
+				pending[task.ID]++
+				dependents[dep] = append(dependents[dep], task.ID)
+			}
+		}
+	}
+	ready := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		if pending[task.ID] == 0 {
+			ready = append(ready, task.ID)
+		}
+	}
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		delete(pending, id)
+		for _, next := range dependents[id] {
+			pending[next]--
+			if pending[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// Every remaining task still waits on a remaining dependency, so following those from the
+	// first remaining task in plan order must come back to a task already on the path
+	deps := make(map[string][]string, len(tasks))
+	var start string
+	for _, task := range tasks {
+		deps[task.ID] = task.Dependencies
+		if _, ok := pending[task.ID]; ok && start == "" {
+			start = task.ID
+		}
+	}
+	position := make(map[string]int)
+	var path []string
+	for id := start; ; {
+		if at, seen := position[id]; seen {
+			return append(path[at:], id)
+		}
+		position[id] = len(path)
+		path = append(path, id)
+		for _, dep := range deps[id] {
+			if _, ok := pending[dep]; ok {
+				id = dep
+				break
+			}
+		}
+	}
+}
+
+// checkDependencyCycles returns a CycleError when the tasks' dependencies form a cycle
+func checkDependencyCycles(tasks []Task) error {
+	if path := FindDependencyCycle(tasks); path != nil {
+		return &CycleError{Path: path}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package captain
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dependsOn returns tasks with the given dependencies, in the order they are listed
+func dependsOn(deps ...[]string) []Task {
+	tasks := make([]Task, len(deps))
+	for i, d := range deps {
+		tasks[i] = Task{ID: fmt.Sprintf("task-%d", i+1), Dependencies: d}
+	}
+	return tasks
+}
+
+func TestFindDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []Task
+		want  []string
+	}{
+		{name: "no tasks"},
+		{name: "independent tasks", tasks: dependsOn(nil, nil)},
+		{name: "chain", tasks: dependsOn(nil, []string{"task-1"}, []string{"task-2"})},
+		{name: "diamond", tasks: dependsOn(nil, []string{"task-1"}, []string{"task-1"}, []string{"task-2", "task-3"})},
+		{name: "unknown dependencies are ignored", tasks: dependsOn([]string{"missing"})},
+		{name: "self dependency", tasks: dependsOn(nil, []string{"task-2"}), want: []string{"task-2", "task-2"}},
+		{name: "two tasks", tasks: dependsOn([]string{"task-2"}, []string{"task-1"}), want: []string{"task-1", "task-2", "task-1"}},
+		{
+			name:  "cycle reached through a task outside it",
+			tasks: dependsOn([]string{"task-2"}, []string{"task-3"}, []string{"task-4"}, []string{"task-2"}),
+			want:  []string{"task-2", "task-3", "task-4", "task-2"},
+		},
+		{
+			name:  "acyclic branches are skipped",
+			tasks: dependsOn(nil, []string{"task-1", "task-3"}, []string{"task-2"}),
+			want:  []string{"task-2", "task-3", "task-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FindDependencyCycle(tt.tasks))
+		})
+	}
+}
+
+func TestCycleError(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+	plan := &ExecutionPlan{ID: "plan-1", Goal: "deploy", Tasks: dependsOn([]string{"task-3"}, []string{"task-1"}, []string{"task-2"})}
+
+	err := engine.ValidatePlan(plan)
+	var cycle *CycleError
+	require.True(t, errors.As(err, &cycle))
+	assert.Equal(t, []string{"task-1", "task-3", "task-2", "task-1"}, cycle.Path)
+	assert.EqualError(t, err, "circular dependency detected: task-1 -> task-3 -> task-2 -> task-1")
+
+	_, err = executionOrder(plan.Tasks)
+	assert.ErrorAs(t, err, &cycle, "the executor reports the same cycle")
+}
+
+// randomDAG is a plan's tasks, listed in random order, whose dependencies only point to tasks
+// created before them, so they never form a cycle
+type randomDAG []Task
+
+// Generate implements quick.Generator
+func (randomDAG) Generate(r *rand.Rand, size int) reflect.Value {
+	n := r.Intn(size+1) + 1
+	dag := make(randomDAG, n)
+	for i := range dag {
+		dag[i].ID = fmt.Sprintf("task-%d", i+1)
+		for j := 0; j < i; j++ {
+			if r.Intn(3) == 0 {
+				dag[i].Dependencies = append(dag[i].Dependencies, dag[j].ID)
+			}
+		}
+	}
+	r.Shuffle(len(dag), func(i, j int) { dag[i], dag[j] = dag[j], dag[i] })
+	return reflect.ValueOf(dag)
+}
+
+// withBackEdge returns a copy of the DAG where the end of a dependency chain also depends on
+// the chain's first task, closing a cycle. It reports false when no task has a dependency.
+func (d randomDAG) withBackEdge() ([]Task, bool) {
+	byID := make(map[string]int, len(d))
+	for i, task := range d {
+		byID[task.ID] = i
+	}
+	for _, first := range d {
+		if len(first.Dependencies) == 0 {
+			continue
+		}
+		last := first
+		for len(last.Dependencies) > 0 {
+			last = d[byID[last.Dependencies[len(last.Dependencies)-1]]]
+		}
+		tasks := slices.Clone([]Task(d))
+		i := byID[last.ID]
+		tasks[i].Dependencies = append(slices.Clone(tasks[i].Dependencies), first.ID)
+		return tasks, true
+	}
+	return nil, false
+}
+
+// isCycle reports whether path is a closed walk along dependencies that visits no task twice
+func isCycle(tasks []Task, path []string) bool {
+	if len(path) < 2 || path[0] != path[len(path)-1] {
+		return false
+	}
+	deps := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		deps[task.ID] = task.Dependencies
+	}
+	seen := make(map[string]bool)
+	for i, id := range path[:len(path)-1] {
+		if seen[id] || !slices.Contains(deps[id], path[i+1]) {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+func TestFindDependencyCycle_RandomDAGs(t *testing.T) {
+	config := &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}
+
+	acyclic := func(dag randomDAG) bool {
+		order, err := executionOrder(dag)
+		return FindDependencyCycle(dag) == nil && err == nil && len(order) == len(dag)
+	}
+	require.NoError(t, quick.Check(acyclic, config), "a DAG has no cycle and can be ordered")
+
+	cyclic := func(dag randomDAG) bool {
+		tasks, ok := dag.withBackEdge()
+		if !ok {
+			return true
+		}
+		path := FindDependencyCycle(tasks)
+		_, err := executionOrder(tasks)
+		return isCycle(tasks, path) && err != nil && slices.Equal(path, FindDependencyCycle(tasks))
+	}
+	require.NoError(t, quick.Check(cyclic, config), "closing a cycle is detected and its exact path reported")
+}
@@ -0,0 +1,38 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SummarizeDigest asks the LLM for a one-paragraph summary of a digest of finished tasks
+func (c *Captain) SummarizeDigest(ctx context.Context, digest string) (string, error) {
+	if c.llmProvider == nil {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+
+	systemPrompt := `You summarize digests of tasks run by a captain agent coordinating a crew of agents.
+Write one plain-text paragraph of at most four sentences for the team following this work:
+what was accomplished, what failed and why, and anything that needs attention.
+Do not list every task and do not use Markdown.`
+	// Digests cover many goals, so only a configured language applies
+	if language := c.languageSetting(); language != LanguageAuto {
+		systemPrompt += "\n\n" + languageInstruction(language)
+	}
+
+	req := CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: digest},
+		},
+		MaxTokens:   300,
+		Temperature: 0.2,
+	}
+
+	resp, err := c.llmProvider.GenerateCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize digest: %w", err)
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
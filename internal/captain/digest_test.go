@@ -0,0 +1,35 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptain_SummarizeDigest(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 2 && req.Messages[1].Content == "✓ task-1 deploy"
+	})).Return(&CompletionResponse{Content: " The deploy went out. \n"}, nil)
+
+	captain := &Captain{ID: "captain-1", llmProvider: provider}
+	summary, err := captain.SummarizeDigest(context.Background(), "✓ task-1 deploy")
+	require.NoError(t, err)
+	assert.Equal(t, "The deploy went out.", summary)
+}
+
+func TestCaptain_SummarizeDigest_Error(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("rate limited"))
+
+	captain := &Captain{ID: "captain-1", llmProvider: provider}
+	_, err := captain.SummarizeDigest(context.Background(), "✓ task-1 deploy")
+	assert.EqualError(t, err, "failed to summarize digest: rate limited")
+
+	_, err = (&Captain{ID: "captain-2"}).SummarizeDigest(context.Background(), "✓ task-1 deploy")
+	assert.EqualError(t, err, "no LLM provider configured")
+}
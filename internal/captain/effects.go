@@ -0,0 +1,340 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EffectKind classifies a side effect a task is expected to have
+type EffectKind string
+
+const (
+	EffectFileWrite      EffectKind = "file_write"
+	EffectFileDelete     EffectKind = "file_delete"
+	EffectNetwork        EffectKind = "network"
+	EffectPackageInstall EffectKind = "package_install"
+	EffectCommand        EffectKind = "command"
+)
+
+// EffectKinds lists all effect kinds in reporting order
+var EffectKinds = []EffectKind{EffectFileWrite, EffectFileDelete, EffectNetwork, EffectPackageInstall, EffectCommand}
+
+// EffectSource records how an effect was inferred
+type EffectSource string
+
+const (
+	EffectSourceHeuristic EffectSource = "heuristic"
+	EffectSourceLLM       EffectSource = "llm"
+)
+
+// Effect is a single expected side effect of a plan task
+type Effect struct {
+	TaskID      string       `json:"task_id"`
+	Kind        EffectKind   `json:"kind"`
+	Target      string       `json:"target,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Source      EffectSource `json:"source"`
+}
+
+// EffectReport is the "what would change" analysis of a plan
+type EffectReport struct {
+	PlanID   string   `json:"plan_id"`
+	Effects  []Effect `json:"effects"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ForTask returns the effects expected from a single task
+func (r *EffectReport) ForTask(taskID string) []Effect {
+	var effects []Effect
+	for _, effect := range r.Effects {
+		if effect.TaskID == taskID {
+			effects = append(effects, effect)
+		}
+	}
+	return effects
+}
+
+// Counts returns the number of expected effects of each kind
+func (r *EffectReport) Counts() map[EffectKind]int {
+	counts := make(map[EffectKind]int, len(EffectKinds))
+	for _, effect := range r.Effects {
+		counts[effect.Kind]++
+	}
+	return counts
+}
+
+// EffectAnalyzer predicts the side effects of plan tasks without running them
+type EffectAnalyzer struct {
+	llmProvider  LLMProvider
+	conversation *Conversation
+}
+
+// NewEffectAnalyzer creates an effect analyzer; with a nil provider only heuristics are used
+func NewEffectAnalyzer(llmProvider LLMProvider) *EffectAnalyzer {
+	return &EffectAnalyzer{
+		llmProvider: llmProvider,
+	}
+}
+
+var (
+	urlPattern     = regexp.MustCompile(`https?://[^\s"'` + "`" + `)]+`)
+	pathPattern    = regexp.MustCompile(`(?:\.{0,2}/)?[\w.-]+(?:/[\w.-]+)*\.(?:go|mod|sum|ya?ml|json|toml|md|txt|sh|py|js|ts|html|css|sql|env|cfg|conf|ini|lock|xml|csv)\b`)
+	packagePattern = regexp.MustCompile(`\b(?:npm|pnpm|yarn|pip3?|go|apt(?:-get)?|brew|cargo|gem|dnf|yum|apk)\s+(?:install|add|get)\s+([\w@./:=-]+)`)
+	commandPattern = regexp.MustCompile("`([^`]+)`")
+
+	writeKeywords   = []string{"write", "create", "generate", "update", "modify", "edit", "save", "refactor", "fix", "implement", "add "}
+	deleteKeywords  = []string{"delete", "remove", "clean up", "cleanup", "purge", "drop "}
+	networkKeywords = []string{"download", "fetch", "upload", "deploy", "publish", "push", "api call", "http request", "webhook", "notify"}
+	installKeywords = []string{"install", "upgrade dependencies", "add dependency", "add a dependency"}
+)
+
+// SetConversation sets the conversation about the plan's goal; its history is included in the
+// prompt and the analysis is recorded in it
+func (ea *EffectAnalyzer) SetConversation(conversation *Conversation) {
+	ea.conversation = conversation
+}
+
+// Analyze returns the expected side effects of every task in the plan.
+// Heuristic effects are always included; LLM-inferred effects are merged in when a provider is set,
+// and a failing provider degrades to heuristics with a warning rather than an error.
+func (ea *EffectAnalyzer) Analyze(ctx context.Context, plan *ExecutionPlan) (*EffectReport, error) {
+	if plan == nil {
+		return nil, fmt.Errorf("plan cannot be nil")
+	}
+
+	report := &EffectReport{PlanID: plan.ID}
+	for _, task := range plan.Tasks {
+		report.Effects = append(report.Effects, heuristicEffects(task)...)
+	}
+
+	if ea.llmProvider != nil && len(plan.Tasks) > 0 {
+		llmEffects, err := ea.llmEffects(ctx, plan)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("LLM effect analysis unavailable, using heuristics only: %v", err))
+		} else {
+			report.Effects = append(report.Effects, llmEffects...)
+		}
+	}
+
+	report.Effects = dedupeEffects(plan, report.Effects)
+	return report, nil
+}
+
+// heuristicEffects infers effects from a task's payload and description
+func heuristicEffects(task Task) []Effect {
+	var effects []Effect
+	add := func(kind EffectKind, target, description string) {
+		effects = append(effects, Effect{
+			TaskID:      task.ID,
+			Kind:        kind,
+			Target:      target,
+			Description: description,
+			Source:      EffectSourceHeuristic,
+		})
+	}
+
+	description, _ := task.Payload["description"].(string)
+	lower := strings.ToLower(description)
+
+	// Explicit payload fields are the strongest signal
+	if command, ok := task.Payload["command"].(string); ok && command != "" {
+		add(EffectCommand, command, "runs a shell command")
+	}
+	if url, ok := task.Payload["url"].(string); ok && url != "" {
+		method, _ := task.Payload["method"].(string)
+		if method == "" {
+			method = "GET"
+		}
+		add(EffectNetwork, url, strings.ToUpper(method)+" request")
+	}
+	if path, ok := task.Payload["path"].(string); ok && path != "" && task.Type == TaskTypeExecution {
+		add(EffectFileWrite, path, "writes the task's target path")
+	}
+
+	for _, match := range packagePattern.FindAllStringSubmatch(description, -1) {
+		add(EffectPackageInstall, match[1], strings.TrimSpace(match[0]))
+	}
+	if len(packagePattern.FindAllString(description, -1)) == 0 && containsAny(lower, installKeywords) {
+		add(EffectPackageInstall, "", "installs packages")
+	}
+
+	for _, match := range commandPattern.FindAllStringSubmatch(description, -1) {
+		add(EffectCommand, match[1], "runs a shell command")
+	}
+
+	urls := urlPattern.FindAllString(description, -1)
+	for _, url := range urls {
+		add(EffectNetwork, url, "contacts a remote endpoint")
+	}
+	if len(urls) == 0 && containsAny(lower, networkKeywords) {
+		add(EffectNetwork, "", "makes network calls")
+	}
+
+	// Analysis and validation tasks only read, unless the description says otherwise
+	paths := pathPattern.FindAllString(description, -1)
+	switch {
+	case containsAny(lower, deleteKeywords):
+		addPathEffects(add, EffectFileDelete, paths, "deletes files")
+	case containsAny(lower, writeKeywords) || task.Type == TaskTypeExecution && len(paths) > 0:
+		addPathEffects(add, EffectFileWrite, paths, "writes files")
+	case task.Type == TaskTypeReporting:
+		addPathEffects(add, EffectFileWrite, paths, "writes a report")
+	}
+
+	return effects
+}
+
+// addPathEffects adds one effect per path, or a single untargeted effect when no path is known
+func addPathEffects(add func(EffectKind, string, string), kind EffectKind, paths []string, description string) {
+	if len(paths) == 0 {
+		add(kind, "", description)
+		return
+	}
+	for _, path := range paths {
+		add(kind, path, description)
+	}
+}
+
+// containsAny reports whether s contains any of the keywords
+func containsAny(s string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// effectResponse is the structured response expected from the LLM
+type effectResponse struct {
+	Effects []struct {
+		TaskID      string `json:"task_id"`
+		Kind        string `json:"kind"`
+		Target      string `json:"target"`
+		Description string `json:"description"`
+	} `json:"effects"`
+}
+
+// llmEffects asks the LLM to classify the expected side effects of the plan's tasks
+func (ea *EffectAnalyzer) llmEffects(ctx context.Context, plan *ExecutionPlan) ([]Effect, error) {
+	tasks, err := json.Marshal(plan.Tasks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan tasks: %w", err)
+	}
+
+	systemPrompt := `You predict the side effects of tasks in an execution plan before they run.
+For each task, list what it is expected to change. Only report effects that are likely.
+
+## Effect Kinds:
+- "file_write": files created or modified
+- "file_delete": files removed
+- "network": network calls to remote services
+- "package_install": packages or dependencies installed
+- "command": shell commands executed
+
+## Response Format:
+Respond with a JSON object:
+{
+  "effects": [
+    {"task_id": "task-1", "kind": "file_write", "target": "path, URL, package or command", "description": "short explanation"}
+  ]
+}`
+
+	messages := []Message{{Role: "system", Content: systemPrompt}}
+	if ea.conversation != nil {
+		ea.conversation.Compact(ctx)
+		messages = append(messages, ea.conversation.Messages()...)
+	}
+	messages = append(messages, Message{Role: "user", Content: fmt.Sprintf("Goal: %s\n\nTasks:\n%s", plan.Goal, tasks)})
+
+	req := CompletionRequest{
+		Messages:    messages,
+		MaxTokens:   1500,
+		Temperature: 0.1,
+	}
+
+	resp, err := ea.llmProvider.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate effect analysis: %w", err)
+	}
+	if ea.conversation != nil {
+		ea.conversation.Add(PhaseEffects, "user", fmt.Sprintf("Predict the side effects of plan %s.", plan.ID))
+		ea.conversation.Add(PhaseEffects, "assistant", resp.Content)
+	}
+
+	var parsed effectResponse
+	if err := json.Unmarshal([]byte(extractJSON(resp.Content)), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal effect response: %w", err)
+	}
+
+	known := make(map[string]bool, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		known[task.ID] = true
+	}
+	validKinds := make(map[EffectKind]bool, len(EffectKinds))
+	for _, kind := range EffectKinds {
+		validKinds[kind] = true
+	}
+
+	var effects []Effect
+	for _, e := range parsed.Effects {
+		kind := EffectKind(e.Kind)
+		if !known[e.TaskID] || !validKinds[kind] {
+			continue
+		}
+		effects = append(effects, Effect{
+			TaskID:      e.TaskID,
+			Kind:        kind,
+			Target:      e.Target,
+			Description: e.Description,
+			Source:      EffectSourceLLM,
+		})
+	}
+	return effects, nil
+}
+
+// dedupeEffects removes duplicate effects and orders them by plan task order and kind.
+// An untargeted effect is dropped when a targeted one of the same kind exists for the task.
+func dedupeEffects(plan *ExecutionPlan, effects []Effect) []Effect {
+	taskOrder := make(map[string]int, len(plan.Tasks))
+	for i, task := range plan.Tasks {
+		taskOrder[task.ID] = i
+	}
+	kindOrder := make(map[EffectKind]int, len(EffectKinds))
+	for i, kind := range EffectKinds {
+		kindOrder[kind] = i
+	}
+
+	targeted := make(map[string]bool)
+	for _, effect := range effects {
+		if effect.Target != "" {
+			targeted[effect.TaskID+"|"+string(effect.Kind)] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var unique []Effect
+	for _, effect := range effects {
+		if effect.Target == "" && targeted[effect.TaskID+"|"+string(effect.Kind)] {
+			continue
+		}
+		key := effect.TaskID + "|" + string(effect.Kind) + "|" + effect.Target
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, effect)
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool {
+		if taskOrder[unique[i].TaskID] != taskOrder[unique[j].TaskID] {
+			return taskOrder[unique[i].TaskID] < taskOrder[unique[j].TaskID]
+		}
+		return kindOrder[unique[i].Kind] < kindOrder[unique[j].Kind]
+	})
+	return unique
+}
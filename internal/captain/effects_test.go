@@ -0,0 +1,134 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func effectPlan() *ExecutionPlan {
+	return &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "ship the release",
+		Tasks: []Task{
+			{ID: "task-1", Type: TaskTypeAnalysis, Payload: map[string]any{"description": "Review open issues"}},
+			{ID: "task-2", Type: TaskTypeExecution, Payload: map[string]any{"description": "Run `npm install lodash` and update config.yaml"}},
+			{ID: "task-3", Type: TaskTypeExecution, Payload: map[string]any{"description": "Notify the team", "url": "https://hooks.example.com/release", "method": "post"}},
+		},
+	}
+}
+
+func TestHeuristicEffects(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want []Effect
+	}{
+		{
+			name: "read-only analysis",
+			task: Task{ID: "t", Type: TaskTypeAnalysis, Payload: map[string]any{"description": "Review code quality in internal/cli"}},
+			want: nil,
+		},
+		{
+			name: "file write with path",
+			task: Task{ID: "t", Type: TaskTypeExecution, Payload: map[string]any{"description": "Create docs/setup.md"}},
+			want: []Effect{{TaskID: "t", Kind: EffectFileWrite, Target: "docs/setup.md", Description: "writes files", Source: EffectSourceHeuristic}},
+		},
+		{
+			name: "delete",
+			task: Task{ID: "t", Type: TaskTypeExecution, Payload: map[string]any{"description": "Remove stale build artifacts"}},
+			want: []Effect{{TaskID: "t", Kind: EffectFileDelete, Description: "deletes files", Source: EffectSourceHeuristic}},
+		},
+		{
+			name: "package install",
+			task: Task{ID: "t", Type: TaskTypeAnalysis, Payload: map[string]any{"description": "pip install requests"}},
+			want: []Effect{{TaskID: "t", Kind: EffectPackageInstall, Target: "requests", Description: "pip install requests", Source: EffectSourceHeuristic}},
+		},
+		{
+			name: "command payload",
+			task: Task{ID: "t", Type: TaskTypeValidation, Payload: map[string]any{"description": "Check the build", "command": "go build ./..."}},
+			want: []Effect{{TaskID: "t", Kind: EffectCommand, Target: "go build ./...", Description: "runs a shell command", Source: EffectSourceHeuristic}},
+		},
+		{
+			name: "network url",
+			task: Task{ID: "t", Type: TaskTypeAnalysis, Payload: map[string]any{"description": "Read https://example.com/spec"}},
+			want: []Effect{{TaskID: "t", Kind: EffectNetwork, Target: "https://example.com/spec", Description: "contacts a remote endpoint", Source: EffectSourceHeuristic}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, heuristicEffects(tt.task))
+		})
+	}
+}
+
+func TestEffectAnalyzer_HeuristicsOnly(t *testing.T) {
+	analyzer := NewEffectAnalyzer(nil)
+
+	report, err := analyzer.Analyze(context.Background(), effectPlan())
+	require.NoError(t, err)
+	assert.Equal(t, "plan-1", report.PlanID)
+	assert.Empty(t, report.Warnings)
+	assert.Empty(t, report.ForTask("task-1"))
+
+	counts := report.Counts()
+	assert.Equal(t, 1, counts[EffectPackageInstall])
+	assert.Equal(t, 1, counts[EffectCommand])
+	assert.Equal(t, 1, counts[EffectFileWrite])
+	assert.Equal(t, 1, counts[EffectNetwork], "the untargeted notify effect is folded into the targeted url effect")
+
+	network := report.ForTask("task-3")
+	require.Len(t, network, 1)
+	assert.Equal(t, "POST request", network[0].Description)
+}
+
+func TestEffectAnalyzer_MergesLLMEffects(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{
+		Content: "```json\n" + `{"effects": [
+			{"task_id": "task-1", "kind": "network", "target": "https://api.github.com", "description": "lists issues"},
+			{"task_id": "task-2", "kind": "command", "target": "npm install lodash"},
+			{"task_id": "task-9", "kind": "file_write", "target": "ignored.txt"},
+			{"task_id": "task-1", "kind": "teleport", "target": "ignored"}
+		]}` + "\n```",
+	}, nil)
+
+	report, err := NewEffectAnalyzer(provider).Analyze(context.Background(), effectPlan())
+	require.NoError(t, err)
+
+	first := report.ForTask("task-1")
+	require.Len(t, first, 1)
+	assert.Equal(t, EffectSourceLLM, first[0].Source)
+	assert.Equal(t, "https://api.github.com", first[0].Target)
+
+	// The LLM's command duplicates the heuristic one and is dropped
+	commands := 0
+	for _, effect := range report.ForTask("task-2") {
+		if effect.Kind == EffectCommand {
+			commands++
+		}
+	}
+	assert.Equal(t, 1, commands)
+	provider.AssertExpectations(t)
+}
+
+func TestEffectAnalyzer_LLMFailureFallsBack(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("rate limited"))
+
+	report, err := NewEffectAnalyzer(provider).Analyze(context.Background(), effectPlan())
+	require.NoError(t, err)
+	require.Len(t, report.Warnings, 1)
+	assert.Contains(t, report.Warnings[0], "rate limited")
+	assert.NotEmpty(t, report.Effects)
+}
+
+func TestEffectAnalyzer_NilPlan(t *testing.T) {
+	_, err := NewEffectAnalyzer(nil).Analyze(context.Background(), nil)
+	assert.Error(t, err)
+}
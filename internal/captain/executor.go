@@ -0,0 +1,855 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often the executor probes the agent running a step
+	DefaultHeartbeatInterval = 500 * time.Millisecond
+	// DefaultMaxHandoffs is how many times a step may be reassigned before it fails
+	DefaultMaxHandoffs = 3
+	// DefaultShutdownGrace is how long an in-flight step may keep running after cancellation
+	DefaultShutdownGrace = 5 * time.Second
+)
+
+// Handoff records a step being reassigned from a lost agent to a fresh one
+type Handoff struct {
+	TaskID    string    `json:"task_id"`
+	FromAgent string    `json:"from_agent"`
+	ToAgent   string    `json:"to_agent,omitempty"`
+	Reason    string    `json:"reason"`
+	Attempt   int       `json:"attempt"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PlanExecutor runs plan tasks on crew agents owned by an AgentManager
+type PlanExecutor struct {
+	manager           *agents.AgentManager
+	heartbeatInterval time.Duration
+	maxHandoffs       int
+	shutdownGrace     time.Duration
+	approver          Approver
+	policy            *Policy
+	questioner        agents.Questioner
+	blackboard        agents.Blackboard
+	timeouts          map[agents.AgentType]time.Duration
+	defaultTimeout    time.Duration
+	executionMode     string
+	container         *agents.ContainerSpec
+	observer          StepObserver
+	output            OutputObserver
+	fanOut            FanOutObserver
+	maxOutputSize     int
+	maxParallel       int
+	readOnly          bool
+	missingVariables  string
+	watchdog          config.WatchdogConfigs
+	stallHandler      StallHandler
+	logger            *zap.Logger
+	clock             common.Clock
+
+	mu       sync.Mutex
+	spawned  int
+	claimed  map[string]bool
+	services []*runningService
+}
+
+// NewPlanExecutor creates a plan executor backed by the given agent manager
+func NewPlanExecutor(manager *agents.AgentManager) *PlanExecutor {
+	return &PlanExecutor{
+		manager:           manager,
+		heartbeatInterval: DefaultHeartbeatInterval,
+		maxHandoffs:       DefaultMaxHandoffs,
+		shutdownGrace:     DefaultShutdownGrace,
+		logger:            zap.NewNop(),
+		clock:             common.SystemClock,
+	}
+}
+
+// SetClock sets the clock steps are timed, probed and watched for stalls with
+func (e *PlanExecutor) SetClock(clock common.Clock) {
+	e.clock = clock
+}
+
+// SetLogger sets the logger agent panics are reported to, with their stack traces
+func (e *PlanExecutor) SetLogger(logger *zap.Logger) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	e.logger = logger
+}
+
+// SetHeartbeatInterval sets how often running steps are probed for agent liveness
+func (e *PlanExecutor) SetHeartbeatInterval(interval time.Duration) {
+	if interval > 0 {
+		e.heartbeatInterval = interval
+	}
+}
+
+// SetMaxHandoffs sets how many reassignments a step may go through before failing
+func (e *PlanExecutor) SetMaxHandoffs(max int) {
+	if max >= 0 {
+		e.maxHandoffs = max
+	}
+}
+
+// SetShutdownGrace sets how long an in-flight step may finish after execution is cancelled
+// before it is abandoned and checkpointed
+func (e *PlanExecutor) SetShutdownGrace(grace time.Duration) {
+	if grace >= 0 {
+		e.shutdownGrace = grace
+	}
+}
+
+// SetPolicy sets the workspace policy each step is checked against before it is dispatched
+func (e *PlanExecutor) SetPolicy(policy *Policy) {
+	e.policy = policy
+}
+
+// SetApprover sets the approver consulted before high-risk steps are dispatched
+func (e *PlanExecutor) SetApprover(approver Approver) {
+	e.approver = approver
+}
+
+// SetQuestioner sets how agents reach the user when a step needs clarification
+func (e *PlanExecutor) SetQuestioner(questioner agents.Questioner) {
+	e.questioner = questioner
+}
+
+// SetBlackboard sets where agents share findings with later steps and each other
+func (e *PlanExecutor) SetBlackboard(blackboard agents.Blackboard) {
+	e.blackboard = blackboard
+}
+
+// SetTimeouts sets how long a step may run on each crew agent type. Types without an entry,
+// or with a zero entry, use fallback; a zero fallback leaves those steps unbounded.
+func (e *PlanExecutor) SetTimeouts(timeouts map[string]time.Duration, fallback time.Duration) {
+	e.timeouts = make(map[agents.AgentType]time.Duration, len(timeouts))
+	for agentType, timeout := range timeouts {
+		if timeout > 0 {
+			e.timeouts[agents.AgentType(agentType)] = timeout
+		}
+	}
+	e.defaultTimeout = fallback
+}
+
+// SetStepObserver sets the function called after each step finishes, before the next starts
+func (e *PlanExecutor) SetStepObserver(observer StepObserver) {
+	e.observer = observer
+}
+
+// SetOutputObserver sets the function streamed each line of command output while a step runs
+func (e *PlanExecutor) SetOutputObserver(observer OutputObserver) {
+	e.output = observer
+}
+
+// SetFanOutObserver sets the function called as each item of a fan-out step finishes
+func (e *PlanExecutor) SetFanOutObserver(observer FanOutObserver) {
+	e.fanOut = observer
+}
+
+// SetMaxParallel sets how many items of a fan-out step run at once; 0 runs them all at once
+func (e *PlanExecutor) SetMaxParallel(max int) {
+	if max >= 0 {
+		e.maxParallel = max
+	}
+}
+
+// SetMaxOutputSize sets how much of each command output stream a step keeps; 0 uses
+// agents.DefaultMaxOutputSize
+func (e *PlanExecutor) SetMaxOutputSize(size int) {
+	e.maxOutputSize = size
+}
+
+// SetReadOnly makes steps that would change files, remote services or the system skip instead
+// of running
+func (e *PlanExecutor) SetReadOnly(readOnly bool) {
+	e.readOnly = readOnly
+}
+
+// SetMissingVariables sets how references to earlier steps' results that cannot be resolved
+// are handled: config.MissingVariablesLenient leaves them empty, anything else fails the step
+func (e *PlanExecutor) SetMissingVariables(mode string) {
+	e.missingVariables = mode
+}
+
+// SetExecution sets where task commands run by default, host or container, and the container
+// used by tasks that run in one; a nil container makes container tasks fail
+func (e *PlanExecutor) SetExecution(mode string, container *agents.ContainerSpec) {
+	e.executionMode = mode
+	e.container = container
+}
+
+// containerFor returns the container a task's commands run in, or nil when they run on the host
+func (e *PlanExecutor) containerFor(task Task) (*agents.ContainerSpec, error) {
+	mode := task.Execution
+	if mode == "" {
+		mode = e.executionMode
+	}
+	if mode != config.ExecutionModeContainer {
+		return nil, nil
+	}
+	if e.container == nil || e.container.Image == "" {
+		return nil, fmt.Errorf("task %s runs in a container but execution.container.image is not set", task.ID)
+	}
+	container := *e.container
+	return &container, nil
+}
+
+// TimeoutFor returns the step timeout for an agent type, or zero if steps are unbounded
+func (e *PlanExecutor) TimeoutFor(agentType agents.AgentType) time.Duration {
+	if timeout, ok := e.timeouts[agentType]; ok {
+		return timeout
+	}
+	return e.defaultTimeout
+}
+
+// AgentTypeFor returns the crew agent type responsible for a plan task.
+// An explicit "agent" payload entry wins over the mapping from task type.
+func AgentTypeFor(task Task) agents.AgentType {
+	if agentType, ok := task.Payload["agent"].(string); ok && agentType != "" {
+		return agents.AgentType(agentType)
+	}
+
+	switch task.Type {
+	case TaskTypeAnalysis, TaskTypeReporting:
+		return agents.AgentTypeResearch
+	case TaskTypeFileWatch:
+		return agents.AgentTypeFile
+	default:
+		return agents.AgentTypeFile
+	}
+}
+
+// Execute runs all plan tasks in dependency order, returning their results and any handoffs.
+// Steps of a parallel or hybrid plan whose dependencies have finished run at once, up to the
+// limit parallelSteps sets; a sequential plan runs one step at a time. Results are in the
+// order steps finish. Services started by the plan's service steps are stopped when it
+// returns, however it ends.
+func (e *PlanExecutor) Execute(ctx context.Context, plan *ExecutionPlan) ([]Result, []Handoff, error) {
+	order, err := executionOrder(plan.Tasks)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer e.stopServices()
+
+	type finishedStep struct {
+		task       Task
+		result     Result
+		handoffs   []Handoff
+		unresolved []string
+	}
+	limit := parallelSteps(plan, e.maxParallel)
+	// Steps and the items of fan-out steps share the agent slots, so no more agents than the
+	// parallel limit run at once however steps and items overlap
+	slots := agentSlots(plan, e.maxParallel)
+	// Steps run in supervised goroutines, each reporting on done even when it panics
+	group, _ := common.NewGroup(ctx, e.logger)
+	defer group.Wait()
+	results := make([]Result, 0, len(order))
+	byID := make(map[string]Result, len(order))
+	finished := make(map[string]bool, len(order))
+	started := make(map[string]bool, len(order))
+	done := make(chan finishedStep)
+	running := 0
+	var handoffs []Handoff
+	var stopped error
+	record := func(task Task, result *Result) {
+		if e.observer != nil {
+			e.observer(task, result)
+		}
+		results = append(results, *result)
+		byID[task.ID] = *result
+		finished[task.ID] = true
+	}
+
+	for {
+		// Start every ready step that fits, in execution order
+		for _, task := range order {
+			if stopped != nil || running >= limit {
+				break
+			}
+			if started[task.ID] || !dependenciesFinished(task, finished) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				stopped = fmt.Errorf("execution cancelled: %w", err)
+				break
+			}
+			started[task.ID] = true
+			task = withFanIn(task, byID)
+
+			if skipped := e.checkCondition(task, byID); skipped != nil {
+				record(task, skipped)
+				continue
+			}
+			resolved, unresolved, err := resolveReferences(ctx, task, byID, e.blackboard, e.missingVariables)
+			if err != nil {
+				failed := e.failedResult(task.ID, e.clock.Now(), err.Error())
+				record(task, &failed)
+				continue
+			}
+			task = resolved
+			if skipped := e.checkReadOnly(task); skipped != nil {
+				record(task, skipped)
+				continue
+			}
+			if blocked := checkPolicy(e.policy, task, e.clock); blocked != nil {
+				results = append(results, *blocked)
+				stopped = fmt.Errorf("execution stopped: %s", blocked.Error)
+				break
+			}
+			if denied := checkApproval(ctx, e.approver, task); denied != nil {
+				results = append(results, *denied)
+				stopped = fmt.Errorf("execution stopped: %s", denied.Error)
+				break
+			}
+
+			running++
+			group.Go("step "+task.ID, func() error {
+				result, taskHandoffs := e.runStep(ctx, task, slots)
+				done <- finishedStep{task: task, result: result, handoffs: taskHandoffs, unresolved: unresolved}
+				return nil
+			})
+		}
+		if running == 0 {
+			break
+		}
+
+		step := <-done
+		running--
+		task, result := step.task, step.result
+		handoffs = append(handoffs, step.handoffs...)
+		if len(step.unresolved) > 0 {
+			if result.Metadata == nil {
+				result.Metadata = make(map[string]any)
+			}
+			result.Metadata["unresolved"] = step.unresolved
+			e.logger.Warn("Step ran with references left empty", zap.String("task_id", task.ID), zap.Strings("unresolved", step.unresolved))
+		}
+		if stalled := replanRequested([]Result{result}); stalled != "" {
+			if e.observer != nil {
+				e.observer(task, &result)
+			}
+			results = append(results, result)
+			if stopped == nil {
+				stopped = fmt.Errorf("execution stopped: step %s %s", task.ID, result.Error)
+			}
+			continue
+		}
+		if result.Metadata["read_only"] == true {
+			e.logger.Info("Agent skipped step in read-only mode", zap.String("task_id", task.ID), zap.Any("blocked", result.Metadata["blocked"]))
+		}
+		record(task, &result)
+	}
+	return results, handoffs, stopped
+}
+
+// runStep runs a step, or an item of a fan-out step, on behalf of the scheduler. A step run
+// on an agent first takes one of the slots, if there are any; fan-out steps leave them to
+// their items and services hold none. A panic outside the step's agent, which runOnAgent
+// recovers itself, fails only the step.
+func (e *PlanExecutor) runStep(ctx context.Context, task Task, slots chan struct{}) (result Result, handoffs []Handoff) {
+	release, ok := takeSlot(ctx, slots, task)
+	if !ok {
+		return e.notStartedResult(task.ID, "step"), nil
+	}
+	defer release()
+	err := common.Recover("step "+task.ID, func() error {
+		result, handoffs = e.executeTask(ctx, task, slots)
+		return nil
+	})
+	if panicErr, ok := err.(*common.PanicError); ok {
+		common.LogPanic(e.logger, "Step panicked", panicErr, zap.String("task_id", task.ID))
+		result = e.failedResult(task.ID, e.clock.Now(), panicErr.Error())
+		result.Metadata = map[string]any{"panicked": true}
+	}
+	return result, handoffs
+}
+
+// takeSlot waits for one of slots for a step run on an agent, returning the function giving
+// it back. It reports false, holding no slot, when ctx ends first.
+func takeSlot(ctx context.Context, slots chan struct{}, task Task) (func(), bool) {
+	if slots == nil || task.Service || len(task.FanOut) > 0 {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false
+	}
+	if ctx.Err() != nil {
+		<-slots
+		return nil, false
+	}
+	return func() { <-slots }, true
+}
+
+// notStartedResult records a step, or an item of a fan-out step, that was still waiting for
+// an agent slot when execution stopped
+func (e *PlanExecutor) notStartedResult(taskID, what string) Result {
+	return Result{
+		TaskID:    taskID,
+		Error:     "interrupted: " + what + " did not start before shutdown",
+		Timestamp: e.clock.Now(),
+		Metadata:  map[string]any{"interrupted": true},
+	}
+}
+
+// agentSlots returns the slots bounding how many agents a plan's steps and fan-out items
+// use at once: maxParallel capped by the plan's max_agents, or nil when neither is set
+func agentSlots(plan *ExecutionPlan, maxParallel int) chan struct{} {
+	limit := maxParallel
+	if plan.Resources.MaxAgents > 0 && (limit <= 0 || plan.Resources.MaxAgents < limit) {
+		limit = plan.Resources.MaxAgents
+	}
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// parallelSteps returns how many of a plan's steps may run at once: maxParallel capped by the
+// plan's max_agents for a parallel or hybrid plan, with no limit when neither is set, and one
+// for any other plan
+func parallelSteps(plan *ExecutionPlan, maxParallel int) int {
+	if plan.Strategy.Type != StrategyParallel && plan.Strategy.Type != StrategyHybrid {
+		return 1
+	}
+	limit := maxParallel
+	if plan.Resources.MaxAgents > 0 && (limit <= 0 || plan.Resources.MaxAgents < limit) {
+		limit = plan.Resources.MaxAgents
+	}
+	if limit <= 0 {
+		limit = len(plan.Tasks)
+	}
+	return max(limit, 1)
+}
+
+// checkCondition skips a step whose condition the result of its step does not meet.
+// It returns nil when the step may run, or the skipped result recorded in its place.
+func (e *PlanExecutor) checkCondition(task Task, results map[string]Result) *Result {
+	if task.Condition == nil {
+		return nil
+	}
+	step := task.Condition.Step
+	dep, ran := results[step]
+	if task.Condition.Met(dep) {
+		return nil
+	}
+	reason := fmt.Sprintf("output of %s does not match %q", step, task.Condition.Matches)
+	switch {
+	case !ran:
+		reason = fmt.Sprintf("%s did not run", step)
+	case dep.Status() == StepStatusSkipped:
+		reason = fmt.Sprintf("%s was skipped", step)
+	case !dep.Success:
+		reason = fmt.Sprintf("%s failed", step)
+	}
+	return &Result{
+		TaskID:    task.ID,
+		Success:   true,
+		Output:    "skipped: " + reason,
+		Timestamp: e.clock.Now(),
+		Metadata: map[string]any{
+			"skipped":   true,
+			"condition": step,
+		},
+	}
+}
+
+// checkReadOnly skips a step in read-only mode when its command may change anything.
+// Agents skip the file writes and write requests they are given themselves.
+func (e *PlanExecutor) checkReadOnly(task Task) *Result {
+	if !e.readOnly {
+		return nil
+	}
+	script, _ := task.Payload["command"].(string)
+	command, mutating := agents.MutatingCommand(script)
+	if script == "" || !mutating {
+		return nil
+	}
+	blocked := fmt.Sprintf("command %q", command)
+	e.logger.Info("Skipping step in read-only mode", zap.String("task_id", task.ID), zap.String("command", command))
+	return &Result{
+		TaskID:    task.ID,
+		Success:   true,
+		Output:    "skipped: read-only mode blocks " + blocked,
+		Timestamp: e.clock.Now(),
+		Metadata: map[string]any{
+			"skipped":   true,
+			"read_only": true,
+			"blocked":   blocked,
+		},
+	}
+}
+
+// ExecuteTask runs a single plan task, reassigning it if its agent is lost mid-step. A fan-out
+// task runs once per item on agents of its own, and a service task is started by the executor
+// itself and left running.
+func (e *PlanExecutor) ExecuteTask(ctx context.Context, task Task) (Result, []Handoff) {
+	return e.executeTask(ctx, task, nil)
+}
+
+// executeTask runs a plan task, sharing slots with the items of a fan-out task; without
+// slots the items take their own up to the parallel limit
+func (e *PlanExecutor) executeTask(ctx context.Context, task Task, slots chan struct{}) (Result, []Handoff) {
+	if task.Service {
+		return e.startService(ctx, task), nil
+	}
+	if len(task.FanOut) > 0 {
+		result, handoffs := e.executeFanOut(ctx, task, slots)
+		if err := publishOutput(ctx, e.blackboard, task, result); err != nil {
+			result.Metadata["blackboard_error"] = err.Error()
+		}
+		return result, handoffs
+	}
+
+	start := e.clock.Now()
+	agentType := AgentTypeFor(task)
+	agentTask := task.AgentTask()
+	agentTask.Questioner = e.questioner
+	agentTask.Blackboard = e.blackboard
+	agentTask.MaxOutputSize = e.maxOutputSize
+	agentTask.ReadOnly = e.readOnly
+	container, err := e.containerFor(task)
+	if err != nil {
+		return e.failedResult(task.ID, start, err.Error()), nil
+	}
+	agentTask.Container = container
+	if agentTask, err = withFindings(ctx, e.blackboard, task, agentTask); err != nil {
+		return e.failedResult(task.ID, start, err.Error()), nil
+	}
+
+	var handoffs []Handoff
+	for attempt := 0; ; attempt++ {
+		agent, err := e.acquireAgent(agentType)
+		if err != nil {
+			return e.failedResult(task.ID, start, err.Error()), handoffs
+		}
+		if len(handoffs) > 0 {
+			handoffs[len(handoffs)-1].ToAgent = agent.ID()
+		}
+		agentTask.Output = e.outputSink(task, agent.ID())
+
+		stepStart := e.clock.Now()
+		result, lost, reason := e.runOnAgent(ctx, agent, agentTask)
+		e.release(agent)
+		e.manager.RecordTask(agent.ID(), e.clock.Since(stepStart), !lost && result.Success)
+		if !lost {
+			converted := NewResultFromAgent(result)
+			if converted.Metadata == nil {
+				converted.Metadata = make(map[string]any)
+			}
+			converted.Metadata["agent_id"] = agent.ID()
+			converted.Resources = withWallTime(converted.Resources, converted.Duration)
+			if len(handoffs) > 0 {
+				converted.Metadata["handoffs"] = len(handoffs)
+			}
+			if err := publishOutput(ctx, e.blackboard, task, converted); err != nil {
+				converted.Metadata["blackboard_error"] = err.Error()
+			}
+			return converted, handoffs
+		}
+
+		handoffs = append(handoffs, Handoff{
+			TaskID:    task.ID,
+			FromAgent: agent.ID(),
+			Reason:    reason,
+			Attempt:   attempt + 1,
+			Timestamp: e.clock.Now(),
+		})
+
+		if attempt >= e.maxHandoffs {
+			return e.failedResult(task.ID, start, fmt.Sprintf("agent lost %d times, giving up: %s", len(handoffs), reason)), handoffs
+		}
+
+		// Carry the partial context from the lost agent into the reassigned step
+		agentTask = withHandoffContext(agentTask, agent, reason, attempt+1)
+	}
+}
+
+// withWallTime returns a copy of the usage an agent reported, or an empty one, with the step's
+// wall time
+func withWallTime(usage *agents.ResourceUsage, wall time.Duration) *agents.ResourceUsage {
+	recorded := agents.ResourceUsage{}
+	if usage != nil {
+		recorded = *usage
+	}
+	recorded.WallTime = wall
+	return &recorded
+}
+
+// runOnAgent executes the task on an agent while probing its liveness and watching for stalls.
+// It reports lost=true if the agent died or was terminated before producing a result, or if
+// the step stalled and was killed to be retried.
+// On cancellation the step gets the shutdown grace period to finish before it is checkpointed.
+func (e *PlanExecutor) runOnAgent(ctx context.Context, agent agents.Agent, task agents.Task) (agents.Result, bool, string) {
+	stepCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	watch := e.watchStalls(agent, &task)
+	verdicts := make(chan stallVerdict, 1)
+
+	// The agent type's timeout becomes the step's deadline
+	timeout := e.TimeoutFor(agent.Type())
+	var deadline <-chan struct{}
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		stepCtx, cancelTimeout = context.WithTimeout(stepCtx, timeout)
+		defer cancelTimeout()
+		deadline = stepCtx.Done()
+	}
+
+	// A panicking agent fails only its own step
+	done := make(chan agents.Result, 1)
+	go func() {
+		var result agents.Result
+		err := common.Recover("agent "+agent.ID(), func() error {
+			result = agent.Execute(stepCtx, task)
+			return nil
+		})
+		if panicErr, ok := err.(*common.PanicError); ok {
+			common.LogPanic(e.logger, "Agent panicked", panicErr, zap.String("agent_id", agent.ID()), zap.String("task_id", task.ID))
+			result = e.panickedResult(task, agent, panicErr)
+		}
+		done <- result
+	}()
+
+	ticker := e.clock.NewTicker(e.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case result := <-done:
+			if !result.Success && errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+				return watch.annotate(e.timedOutResult(task, agent, timeout)), false, ""
+			}
+			return watch.annotate(result), false, ""
+		case <-deadline:
+			return watch.annotate(e.timedOutResult(task, agent, timeout)), false, ""
+		case <-ctx.Done():
+			select {
+			case result := <-done:
+				return result, false, ""
+			case <-e.clock.After(e.shutdownGrace):
+				cancel()
+				return e.interruptedResult(task, agent), false, ""
+			}
+		case <-ticker.C():
+			if reason, alive := e.probe(agent); !alive {
+				return agents.Result{}, true, reason
+			}
+			if stall, stalled := watch.check(e.clock.Now()); stalled {
+				e.flagStall(stepCtx, watch, stall, verdicts)
+			}
+		case verdict := <-verdicts:
+			watch.deciding = false
+			watch.decision = verdict.decision
+			e.logger.Warn("Decided on stalled step", zap.String("task_id", task.ID), zap.String("agent_id", agent.ID()),
+				zap.String("decision", string(verdict.decision)), zap.String("reason", verdict.reason))
+			switch verdict.decision {
+			case StallDecisionRetry:
+				e.stopStep(cancel, done)
+				return agents.Result{}, true, fmt.Sprintf("step stalled with no output for %s and was killed to be retried", verdict.stall.Quiet.Round(time.Second))
+			case StallDecisionReplan:
+				e.stopStep(cancel, done)
+				return watch.annotate(e.stalledResult(task, agent, verdict)), false, ""
+			default:
+				watch.activity.touch()
+			}
+		}
+	}
+}
+
+// outputSink returns the sink streaming a step's command output to the output observer, or
+// nil when there is none
+func (e *PlanExecutor) outputSink(task Task, agentID string) agents.OutputSink {
+	if e.output == nil {
+		return nil
+	}
+	return agents.OutputSinkFunc(func(stream agents.OutputStream, line string) {
+		e.output(task, agentID, stream, line)
+	})
+}
+
+// probe checks whether an agent is still alive and able to finish its step
+func (e *PlanExecutor) probe(agent agents.Agent) (string, bool) {
+	if _, exists := e.manager.GetAgent(agent.ID()); !exists {
+		return fmt.Sprintf("agent %s was terminated", agent.ID()), false
+	}
+
+	switch agent.Status() {
+	case agents.AgentStatusStopped:
+		return fmt.Sprintf("agent %s stopped", agent.ID()), false
+	case agents.AgentStatusError:
+		return fmt.Sprintf("agent %s entered error state", agent.ID()), false
+	}
+
+	if agent.Health().Status == agents.HealthStatusUnhealthy {
+		return fmt.Sprintf("agent %s is unhealthy", agent.ID()), false
+	}
+	return "", true
+}
+
+// acquireAgent returns an idle managed agent of the given type, spawning one if needed. The
+// agent is claimed until released, so the items of a fan-out step, which acquire agents at
+// the same time, never share one.
+func (e *PlanExecutor) acquireAgent(agentType agents.AgentType) (agents.Agent, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.claimed == nil {
+		e.claimed = make(map[string]bool)
+	}
+	if agent, ok := e.manager.AcquireIdle(agentType); ok && !e.claimed[agent.ID()] {
+		e.claimed[agent.ID()] = true
+		return agent, nil
+	}
+
+	e.spawned++
+	id := fmt.Sprintf("%s-%03d", agentType, e.spawned)
+	agent, err := e.manager.SpawnAgent(id, fmt.Sprintf("%sAgent-%d", agentType, e.spawned), agentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to spawn %s agent: %w", agentType, err)
+	}
+	e.claimed[agent.ID()] = true
+	return agent, nil
+}
+
+// release returns a claimed agent for other steps to acquire
+func (e *PlanExecutor) release(agent agents.Agent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.claimed, agent.ID())
+}
+
+// withHandoffContext returns a copy of the task carrying the lost agent's partial context
+func withHandoffContext(task agents.Task, lost agents.Agent, reason string, attempt int) agents.Task {
+	data := make(map[string]interface{}, len(task.Data)+1)
+	for k, v := range task.Data {
+		data[k] = v
+	}
+
+	handoff := map[string]interface{}{
+		"previous_agent": lost.ID(),
+		"reason":         reason,
+		"attempt":        attempt,
+	}
+	if inbox, ok := lost.(interface{ GetReceivedMessages() []agents.Message }); ok {
+		handoff["messages"] = inbox.GetReceivedMessages()
+	}
+	data["handoff"] = handoff
+
+	task.Data = data
+	return task
+}
+
+// interruptedResult checkpoints a step abandoned during shutdown so it can be resumed later
+func (e *PlanExecutor) interruptedResult(task agents.Task, agent agents.Agent) agents.Result {
+	return agents.Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     "interrupted: step did not finish before shutdown",
+		Timestamp: e.clock.Now(),
+		Data: map[string]interface{}{
+			"interrupted": true,
+			"agent_id":    agent.ID(),
+			"checkpoint":  task.Data,
+		},
+	}
+}
+
+// panickedResult records a step whose agent panicked
+func (e *PlanExecutor) panickedResult(task agents.Task, agent agents.Agent, err *common.PanicError) agents.Result {
+	return agents.Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     err.Error(),
+		Timestamp: e.clock.Now(),
+		Data: map[string]interface{}{
+			"panicked": true,
+			"agent_id": agent.ID(),
+		},
+	}
+}
+
+// timedOutResult records a step that ran past its agent type's timeout
+func (e *PlanExecutor) timedOutResult(task agents.Task, agent agents.Agent, timeout time.Duration) agents.Result {
+	return agents.Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     fmt.Sprintf("timed out after %s (%s agent timeout)", timeout, agent.Type()),
+		Duration:  timeout,
+		Timestamp: e.clock.Now(),
+		Data: map[string]interface{}{
+			"timed_out": true,
+			"timeout":   timeout.String(),
+			"agent_id":  agent.ID(),
+		},
+	}
+}
+
+// failedResult builds a failed plan result for a task
+func (e *PlanExecutor) failedResult(taskID string, start time.Time, message string) Result {
+	return Result{
+		TaskID:    taskID,
+		Success:   false,
+		Error:     message,
+		Duration:  e.clock.Since(start),
+		Timestamp: e.clock.Now(),
+	}
+}
+
+// executionOrder returns plan tasks ordered so every task follows its dependencies
+func executionOrder(tasks []Task) ([]Task, error) {
+	byID := make(map[string]Task, len(tasks))
+	inDegree := make(map[string]int, len(tasks))
+	dependents := make(map[string][]string)
+	for _, task := range tasks {
+		byID[task.ID] = task
+		inDegree[task.ID] += 0
+		for _, dep := range task.Dependencies {
+			inDegree[task.ID]++
+			dependents[dep] = append(dependents[dep], task.ID)
+		}
+	}
+
+	// Seed the queue in plan order so independent tasks keep their original ordering
+	queue := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		if inDegree[task.ID] == 0 {
+			queue = append(queue, task.ID)
+		}
+	}
+
+	order := make([]Task, 0, len(tasks))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, byID[id])
+		for _, next := range dependents[id] {
+			inDegree[next]--
+			if inDegree[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	if len(order) != len(tasks) {
+		if err := checkDependencyCycles(tasks); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("circular dependency detected")
+	}
+	return order, nil
+}
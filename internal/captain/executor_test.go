@@ -0,0 +1,785 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// hangingAgent never finishes a step on its own, simulating an agent that dies mid-step
+type hangingAgent struct {
+	*agents.BaseAgent
+	started chan struct{}
+}
+
+func (h *hangingAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	close(h.started)
+	<-ctx.Done()
+	return agents.Result{TaskID: task.ID, Success: false, Error: "abandoned"}
+}
+
+// capturingAgent records the last task it executed
+type capturingAgent struct {
+	*agents.BaseAgent
+	last atomic.Value
+}
+
+func (c *capturingAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	c.last.Store(task)
+	return c.BaseAgent.Execute(ctx, task)
+}
+
+func TestAgentTypeFor(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want agents.AgentType
+	}{
+		{"analysis", Task{Type: TaskTypeAnalysis}, agents.AgentTypeResearch},
+		{"reporting", Task{Type: TaskTypeReporting}, agents.AgentTypeResearch},
+		{"execution", Task{Type: TaskTypeExecution}, agents.AgentTypeFile},
+		{"validation", Task{Type: TaskTypeValidation}, agents.AgentTypeFile},
+		{"explicit agent", Task{Type: TaskTypeAnalysis, Payload: map[string]any{"agent": "network"}}, agents.AgentTypeNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AgentTypeFor(tt.task))
+		})
+	}
+}
+
+func TestExecutionOrder(t *testing.T) {
+	tasks := []Task{
+		{ID: "report", Dependencies: []string{"build", "test"}},
+		{ID: "build"},
+		{ID: "test", Dependencies: []string{"build"}},
+	}
+
+	order, err := executionOrder(tasks)
+	require.NoError(t, err)
+	require.Len(t, order, 3)
+	assert.Equal(t, "build", order[0].ID)
+	assert.Equal(t, "test", order[1].ID)
+	assert.Equal(t, "report", order[2].ID)
+
+	_, err = executionOrder([]Task{
+		{ID: "a", Dependencies: []string{"b"}},
+		{ID: "b", Dependencies: []string{"a"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestPlanExecutor_Execute(t *testing.T) {
+	manager := agents.NewAgentManager()
+	executor := NewPlanExecutor(manager)
+
+	plan := &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "analyze and fix",
+		Tasks: []Task{
+			{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}, Payload: map[string]any{"description": "fix"}},
+			{ID: "task-1", Type: TaskTypeAnalysis, Payload: map[string]any{"description": "analyze"}},
+		},
+	}
+
+	results, handoffs, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	assert.Empty(t, handoffs)
+	require.Len(t, results, 2)
+	assert.Equal(t, "task-1", results[0].TaskID)
+	assert.Equal(t, "task-2", results[1].TaskID)
+	for _, result := range results {
+		assert.True(t, result.Success)
+		assert.NotEmpty(t, result.Metadata["agent_id"])
+	}
+
+	// One research agent and one file agent should have been spawned
+	stats := manager.GetAgentStats()
+	assert.Equal(t, 1, stats.ByType[agents.AgentTypeResearch])
+	assert.Equal(t, 1, stats.ByType[agents.AgentTypeFile])
+
+	// Each agent's executed step is recorded in its activity
+	require.Len(t, stats.Activity, 2)
+	for _, result := range results {
+		activity := stats.Activity[result.Metadata["agent_id"].(string)]
+		assert.Equal(t, 1, activity.Tasks)
+		assert.Zero(t, activity.Failed)
+	}
+}
+
+// overlapCrew records the most steps its overlap agents ran at once. Each step waits until
+// target steps run together, so the peak does not depend on how quickly steps are started.
+type overlapCrew struct {
+	running, peak atomic.Int32
+	target        int32
+	reached       chan struct{}
+	once          sync.Once
+}
+
+// overlapAgent runs steps as one of an overlap crew
+type overlapAgent struct {
+	*agents.BaseAgent
+	crew *overlapCrew
+}
+
+func (o *overlapAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	crew := o.crew
+	n := crew.running.Add(1)
+	defer crew.running.Add(-1)
+	for peak := crew.peak.Load(); n > peak && !crew.peak.CompareAndSwap(peak, n); peak = crew.peak.Load() {
+	}
+	if n >= crew.target {
+		crew.once.Do(func() { close(crew.reached) })
+	}
+	select {
+	case <-crew.reached:
+	case <-time.After(5 * time.Second):
+		return agents.Result{TaskID: task.ID, Error: fmt.Sprintf("only %d steps ran at once", crew.peak.Load())}
+	}
+	return agents.Result{TaskID: task.ID, Success: true, Output: "done"}
+}
+
+func TestPlanExecutor_ParallelStrategy(t *testing.T) {
+	tests := []struct {
+		strategy    StrategyType
+		maxParallel int
+		peak        int32
+	}{
+		{StrategySequential, 5, 1},
+		{StrategyParallel, 2, 2},
+		{StrategyHybrid, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.strategy, tt.maxParallel), func(t *testing.T) {
+			crew := &overlapCrew{target: tt.peak, reached: make(chan struct{})}
+			manager := agents.NewAgentManager()
+			manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+				return &overlapAgent{BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeFile), crew: crew}, nil
+			})
+			executor := NewPlanExecutor(manager)
+			executor.SetMaxParallel(tt.maxParallel)
+
+			plan := &ExecutionPlan{ID: "plan-1", Strategy: ExecutionStrategy{Type: tt.strategy}, Tasks: []Task{
+				{ID: "lint", Type: TaskTypeExecution},
+				{ID: "test", Type: TaskTypeExecution},
+				{ID: "docs", Type: TaskTypeExecution},
+				{ID: "release", Type: TaskTypeExecution, Dependencies: []string{"lint", "test", "docs"}},
+			}}
+			results, _, err := executor.Execute(context.Background(), plan)
+			require.NoError(t, err)
+			require.Len(t, results, 4)
+			for _, result := range results {
+				assert.True(t, result.Success, result.Error)
+			}
+			assert.Equal(t, "release", results[3].TaskID, "a step waits for its dependencies")
+			assert.Equal(t, tt.peak, crew.peak.Load())
+		})
+	}
+}
+
+func TestPlanExecutor_HandoffOnTerminatedAgent(t *testing.T) {
+	manager := agents.NewAgentManager()
+
+	hanging := &hangingAgent{started: make(chan struct{})}
+	replacement := &capturingAgent{}
+	var spawns int32
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		if atomic.AddInt32(&spawns, 1) == 1 {
+			hanging.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+			return hanging, nil
+		}
+		replacement.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return replacement, nil
+	})
+
+	executor := NewPlanExecutor(manager)
+	executor.SetHeartbeatInterval(5 * time.Millisecond)
+
+	go func() {
+		<-hanging.started
+		_ = manager.TerminateAgent(hanging.ID())
+	}()
+
+	step := Task{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "write files"}}
+	result, handoffs := executor.ExecuteTask(context.Background(), step)
+
+	assert.True(t, result.Success)
+	require.Len(t, handoffs, 1)
+	assert.Equal(t, "task-1", handoffs[0].TaskID)
+	assert.Equal(t, hanging.ID(), handoffs[0].FromAgent)
+	assert.Equal(t, replacement.ID(), handoffs[0].ToAgent)
+	assert.Contains(t, handoffs[0].Reason, "terminated")
+	assert.Equal(t, 1, result.Metadata["handoffs"])
+
+	// The replacement agent receives the partial context of the lost agent
+	received, ok := replacement.last.Load().(agents.Task)
+	require.True(t, ok)
+	handoff, ok := received.Data["handoff"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, hanging.ID(), handoff["previous_agent"])
+	assert.Equal(t, "write files", received.Data["description"])
+}
+
+func TestPlanExecutor_GivesUpAfterMaxHandoffs(t *testing.T) {
+	manager := agents.NewAgentManager()
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent := &hangingAgent{BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeFile), started: make(chan struct{})}
+		go func() {
+			<-agent.started
+			agent.SetStatus(agents.AgentStatusError)
+		}()
+		return agent, nil
+	})
+
+	executor := NewPlanExecutor(manager)
+	executor.SetHeartbeatInterval(5 * time.Millisecond)
+	executor.SetMaxHandoffs(1)
+
+	result, handoffs := executor.ExecuteTask(context.Background(), Task{ID: "task-1", Type: TaskTypeExecution})
+
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "giving up")
+	assert.Len(t, handoffs, 2)
+}
+
+func TestCaptain_ExecutePlan_WithExecutor(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	captain := &Captain{
+		ID:          "captain-1",
+		llmProvider: mockLLM,
+		planner:     NewPlanningEngine(mockLLM),
+	}
+	captain.SetExecutor(NewPlanExecutor(agents.NewAgentManager()))
+
+	plan := &ExecutionPlan{
+		ID:    "plan-1",
+		Goal:  "test goal",
+		Tasks: []Task{{ID: "task-1", Type: TaskTypeAnalysis, Priority: PriorityHigh}},
+	}
+
+	result, err := captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	require.Len(t, result.TaskResults, 1)
+	assert.Contains(t, result.TaskResults[0].Output, "executed by")
+}
+
+// slowAgent finishes its step once finish is closed, regardless of cancellation
+type slowAgent struct {
+	*agents.BaseAgent
+	finish  chan struct{}
+	started chan struct{}
+}
+
+func (s *slowAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	close(s.started)
+	<-s.finish
+	return agents.Result{TaskID: task.ID, Success: true, Output: "finished"}
+}
+
+func TestPlanExecutor_FinishesInFlightStepOnCancel(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &slowAgent{finish: make(chan struct{}), started: make(chan struct{})}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	executor := NewPlanExecutor(manager)
+	executor.SetShutdownGrace(time.Second)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	executor.SetClock(clock)
+
+	// The step finishes while the executor waits out the grace period: the heartbeat ticker
+	// and the grace timer are both waiting on the clock
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-agent.started
+		cancel()
+		clock.BlockUntil(2)
+		close(agent.finish)
+	}()
+
+	result, _ := executor.ExecuteTask(ctx, Task{ID: "task-1", Type: TaskTypeExecution})
+	assert.True(t, result.Success)
+	assert.Equal(t, "finished", result.Output)
+}
+
+func TestPlanExecutor_CheckpointsStepAfterGrace(t *testing.T) {
+	manager := agents.NewAgentManager()
+	hanging := &hangingAgent{started: make(chan struct{})}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		hanging.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return hanging, nil
+	})
+
+	executor := NewPlanExecutor(manager)
+	executor.SetShutdownGrace(10 * time.Millisecond)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	executor.SetClock(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-hanging.started
+		cancel()
+		clock.BlockUntil(2)
+		clock.Advance(10 * time.Millisecond)
+	}()
+
+	plan := &ExecutionPlan{
+		ID: "plan-1",
+		Tasks: []Task{
+			{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "write files"}},
+			{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}},
+		},
+	}
+	results, _, err := executor.Execute(ctx, plan)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "execution cancelled")
+
+	// The in-flight step is checkpointed and the next step never starts
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Success)
+	assert.Equal(t, true, results[0].Metadata["interrupted"])
+	assert.Equal(t, hanging.ID(), results[0].Metadata["agent_id"])
+	checkpoint, ok := results[0].Metadata["checkpoint"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "write files", checkpoint["description"])
+}
+
+func TestCaptain_ExecutePlan_Cancelled(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	captain := &Captain{
+		ID:          "captain-1",
+		llmProvider: mockLLM,
+		planner:     NewPlanningEngine(mockLLM),
+	}
+
+	plan := &ExecutionPlan{
+		ID:    "plan-1",
+		Goal:  "test goal",
+		Tasks: []Task{{ID: "task-1", Type: TaskTypeAnalysis, Priority: PriorityHigh}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := captain.ExecutePlan(ctx, plan, false)
+	require.NoError(t, err)
+	assert.True(t, result.Interrupted)
+	assert.False(t, result.Success)
+	assert.Empty(t, result.TaskResults)
+	assert.Contains(t, result.Error, "execution cancelled")
+}
+
+func TestPlanExecutor_TimeoutFor(t *testing.T) {
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	assert.Zero(t, executor.TimeoutFor(agents.AgentTypeFile), "steps are unbounded by default")
+
+	executor.SetTimeouts(map[string]time.Duration{"file": 30 * time.Second, "network": 0}, 5*time.Minute)
+	assert.Equal(t, 30*time.Second, executor.TimeoutFor(agents.AgentTypeFile))
+	assert.Equal(t, 5*time.Minute, executor.TimeoutFor(agents.AgentTypeNetwork), "zero entries fall back")
+	assert.Equal(t, 5*time.Minute, executor.TimeoutFor(agents.AgentTypeResearch))
+}
+
+func TestPlanExecutor_StepTimeout(t *testing.T) {
+	manager := agents.NewAgentManager()
+	hanging := &hangingAgent{started: make(chan struct{})}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		hanging.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return hanging, nil
+	})
+
+	executor := NewPlanExecutor(manager)
+	executor.SetTimeouts(map[string]time.Duration{"file": 20 * time.Millisecond}, time.Minute)
+
+	result, handoffs := executor.ExecuteTask(context.Background(), Task{ID: "task-1", Type: TaskTypeExecution})
+	assert.Empty(t, handoffs, "a timeout is a failure, not a lost agent")
+	assert.False(t, result.Success)
+	assert.Equal(t, "timed out after 20ms (file agent timeout)", result.Error)
+	assert.Equal(t, true, result.Metadata["timed_out"])
+	assert.Equal(t, "20ms", result.Metadata["timeout"])
+	assert.Equal(t, hanging.ID(), result.Metadata["agent_id"])
+}
+
+// panickingAgent panics on steps whose ID is "boom" and runs the others normally
+type panickingAgent struct {
+	*agents.BaseAgent
+}
+
+func (p *panickingAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	if task.ID == "boom" {
+		panic("nil map write")
+	}
+	return p.BaseAgent.Execute(ctx, task)
+}
+
+func TestPlanExecutor_AgentPanic(t *testing.T) {
+	manager := agents.NewAgentManager()
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		return &panickingAgent{BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeFile)}, nil
+	})
+	core, logs := observer.New(zap.ErrorLevel)
+	executor := NewPlanExecutor(manager)
+	executor.SetLogger(zap.New(core))
+
+	plan := &ExecutionPlan{Tasks: []Task{
+		{ID: "boom", Type: TaskTypeExecution},
+		{ID: "after", Type: TaskTypeExecution, Dependencies: []string{"boom"}},
+	}}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.False(t, results[0].Success)
+	assert.Contains(t, results[0].Error, "panicked: nil map write")
+	assert.Equal(t, true, results[0].Metadata["panicked"])
+	assert.True(t, results[1].Success, "only the panicking step fails")
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, "Agent panicked", entry.Message)
+	assert.Equal(t, "boom", entry.ContextMap()["task_id"])
+	assert.Contains(t, entry.ContextMap()["stack"], "panickingAgent")
+}
+
+// panickingBlackboard panics when a step publishes its output
+type panickingBlackboard struct {
+	agents.Blackboard
+}
+
+func (panickingBlackboard) Publish(context.Context, agents.Finding) error {
+	panic("blackboard closed")
+}
+
+func TestPlanExecutor_StepPanic(t *testing.T) {
+	manager := agents.NewAgentManager()
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		return agents.NewBaseAgent(id, name, agents.AgentTypeFile), nil
+	})
+	core, logs := observer.New(zap.ErrorLevel)
+	executor := NewPlanExecutor(manager)
+	executor.SetLogger(zap.New(core))
+	executor.SetBlackboard(panickingBlackboard{})
+
+	// The panic comes after the agent finished, outside the recovery around it
+	plan := &ExecutionPlan{Strategy: ExecutionStrategy{Type: StrategyParallel}, Tasks: []Task{
+		{ID: "publish", Type: TaskTypeExecution, Payload: map[string]any{PayloadPublish: "summary"}},
+		{ID: "other", Type: TaskTypeExecution},
+	}}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	byID := map[string]Result{results[0].TaskID: results[0], results[1].TaskID: results[1]}
+	assert.False(t, byID["publish"].Success)
+	assert.Contains(t, byID["publish"].Error, "panicked: blackboard closed")
+	assert.Equal(t, true, byID["publish"].Metadata["panicked"])
+	assert.True(t, byID["other"].Success, "only the panicking step fails")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "Step panicked", logs.All()[0].Message)
+	assert.Equal(t, "publish", logs.All()[0].ContextMap()["task_id"])
+}
+
+func TestPlanExecutor_StepTimeoutIgnoresFastSteps(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &slowAgent{finish: make(chan struct{}), started: make(chan struct{})}
+	close(agent.finish)
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	executor := NewPlanExecutor(manager)
+	executor.SetTimeouts(nil, time.Second)
+
+	result, _ := executor.ExecuteTask(context.Background(), Task{ID: "task-1", Type: TaskTypeExecution})
+	assert.True(t, result.Success)
+	assert.Nil(t, result.Metadata["timed_out"])
+}
+
+func TestCaptain_SetExecutor_AppliesCrewTimeouts(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Crew.Timeouts["network"] = 10 * time.Second
+	captain := &Captain{ID: "captain-1", config: cfg}
+
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	captain.SetExecutor(executor)
+	assert.Equal(t, 10*time.Second, executor.TimeoutFor(agents.AgentTypeNetwork))
+	assert.Equal(t, cfg.Global.Timeout, executor.TimeoutFor(agents.AgentTypeFile))
+}
+
+// stubQuestioner answers every question with "yes"
+type stubQuestioner struct{}
+
+func (stubQuestioner) Ask(ctx context.Context, step, agentID, question string) (string, error) {
+	return "yes", nil
+}
+
+func TestCaptain_SetQuestioner_ReachesAgents(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &capturingAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	captain := &Captain{ID: "captain-1"}
+	captain.SetQuestioner(stubQuestioner{})
+	executor := NewPlanExecutor(manager)
+	captain.SetExecutor(executor)
+
+	result, _ := executor.ExecuteTask(context.Background(), Task{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "write files"}})
+	require.True(t, result.Success)
+
+	received := agent.last.Load().(agents.Task)
+	answer, err := received.Ask(context.Background(), agent.ID(), "Overwrite?")
+	require.NoError(t, err)
+	assert.Equal(t, "yes", answer)
+}
+
+func TestPlanExecutor_ContainerExecution(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &capturingAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	cfg := config.NewConfig()
+	cfg.Execution.Container = config.ContainerConfig{Image: "alpine", Memory: "512m"}
+	captain := &Captain{ID: "captain-1", config: cfg}
+	executor := NewPlanExecutor(manager)
+	captain.SetExecutor(executor)
+
+	step := Task{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "build"}}
+	result, _ := executor.ExecuteTask(context.Background(), step)
+	require.True(t, result.Success)
+	assert.Nil(t, agent.last.Load().(agents.Task).Container, "host is the default mode")
+
+	step.Execution = config.ExecutionModeContainer
+	result, _ = executor.ExecuteTask(context.Background(), step)
+	require.True(t, result.Success)
+	assert.Equal(t, &agents.ContainerSpec{Image: "alpine", Memory: "512m"}, agent.last.Load().(agents.Task).Container)
+
+	// Container mode applies to every task that does not choose host
+	executor.SetExecution(config.ExecutionModeContainer, ContainerSpecFromConfig(cfg.Execution.Container))
+	step.Execution = ""
+	result, _ = executor.ExecuteTask(context.Background(), step)
+	require.True(t, result.Success)
+	assert.NotNil(t, agent.last.Load().(agents.Task).Container)
+	step.Execution = config.ExecutionModeHost
+	result, _ = executor.ExecuteTask(context.Background(), step)
+	require.True(t, result.Success)
+	assert.Nil(t, agent.last.Load().(agents.Task).Container)
+
+	// Without an image a container task fails instead of running on the host
+	executor.SetExecution(config.ExecutionModeHost, nil)
+	step.Execution = config.ExecutionModeContainer
+	result, _ = executor.ExecuteTask(context.Background(), step)
+	assert.False(t, result.Success)
+	assert.Equal(t, "task task-1 runs in a container but execution.container.image is not set", result.Error)
+}
+
+func TestCaptain_StepObserver(t *testing.T) {
+	var observed []string
+	observer := func(task Task, result *Result) {
+		observed = append(observed, task.ID)
+		result.Artifacts = append(result.Artifacts, agents.Artifact{Name: task.ID + ".diff", Kind: agents.ArtifactKindDiff})
+	}
+	plan := &ExecutionPlan{ID: "plan-1", Goal: "goal", Tasks: []Task{
+		{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, Payload: map[string]any{"description": "one"}},
+		{ID: "task-2", Type: TaskTypeExecution, Priority: PriorityHigh, Dependencies: []string{"task-1"}, Payload: map[string]any{"description": "two"}},
+	}}
+
+	// Simulated execution without crew agents
+	captain := &Captain{ID: "captain-1", planner: NewPlanningEngine(nil)}
+	captain.SetStepObserver(observer)
+	result, err := captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-1", "task-2"}, observed)
+	assert.Equal(t, "task-2.diff", result.TaskResults[1].Artifacts[0].Name)
+
+	// Dry runs execute nothing, so there is nothing to observe
+	observed = nil
+	_, err = captain.ExecutePlan(context.Background(), plan, true)
+	require.NoError(t, err)
+	assert.Empty(t, observed)
+
+	// Crew agents through the executor
+	captain.SetExecutor(NewPlanExecutor(agents.NewAgentManager()))
+	result, err = captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-1", "task-2"}, observed)
+	assert.Equal(t, "task-1.diff", result.TaskResults[0].Artifacts[0].Name)
+}
+
+// chattyAgent streams a line of output for each step before finishing it
+type chattyAgent struct {
+	*agents.BaseAgent
+	maxOutput atomic.Int64
+}
+
+func (c *chattyAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	c.maxOutput.Store(int64(task.MaxOutputSize))
+	if task.Output != nil {
+		task.Output.OutputLine(agents.OutputStdout, "working on "+task.ID)
+		task.Output.OutputLine(agents.OutputStderr, "warning: "+task.ID)
+	}
+	return c.BaseAgent.Execute(ctx, task)
+}
+
+func TestCaptain_OutputObserver(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &chattyAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	var lines []string
+	cfg := config.NewConfig()
+	cfg.Execution.MaxOutputSize = 4096
+	captain := &Captain{ID: "captain-1", config: cfg}
+	captain.SetOutputObserver(func(task Task, agentID string, stream agents.OutputStream, line string) {
+		lines = append(lines, fmt.Sprintf("%s %s %s: %s", task.ID, agentID, stream, line))
+	})
+	executor := NewPlanExecutor(manager)
+	captain.SetExecutor(executor)
+
+	result, _ := executor.ExecuteTask(context.Background(), Task{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "build"}})
+	require.True(t, result.Success)
+	assert.Equal(t, []string{"task-1 file-001 stdout: working on task-1", "task-1 file-001 stderr: warning: task-1"}, lines)
+	assert.Equal(t, int64(4096), agent.maxOutput.Load())
+}
+
+func TestPlanExecutor_Conditions(t *testing.T) {
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	var observed []string
+	executor.SetStepObserver(func(task Task, result *Result) {
+		observed = append(observed, task.ID)
+	})
+
+	plan := &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "test and branch",
+		Tasks: []Task{
+			describedTask("test", TaskTypeValidation, "Run the tests"),
+			{ID: "on-pass", Type: TaskTypeReporting, Dependencies: []string{"test"}, Condition: &Condition{Step: "test", Matches: `^Task test executed`}},
+			{ID: "on-fail", Type: TaskTypeExecution, Dependencies: []string{"test"}, Condition: &Condition{Step: "test", Matches: `(?i)fail`}},
+			{ID: "after-fail", Type: TaskTypeReporting, Dependencies: []string{"on-fail"}, Condition: &Condition{Step: "on-fail", Matches: `.`}},
+		},
+	}
+
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, StepStatusSucceeded, results[1].Status(), "a matching condition runs the step")
+	assert.NotEmpty(t, results[1].Metadata["agent_id"])
+
+	assert.Equal(t, StepStatusSkipped, results[2].Status())
+	assert.True(t, results[2].Success, "a skipped step does not fail the plan")
+	assert.Equal(t, `skipped: output of test does not match "(?i)fail"`, results[2].Output)
+	assert.Nil(t, results[2].Metadata["agent_id"], "skipped steps never reach an agent")
+
+	assert.Equal(t, "skipped: on-fail was skipped", results[3].Output, "skips carry through conditions on skipped steps")
+	assert.Equal(t, []string{"test", "on-pass", "on-fail", "after-fail"}, observed)
+}
+
+func TestPlanExecutor_ReadOnly(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &capturingAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+
+	cfg := config.NewConfig()
+	cfg.Execution.ReadOnly = true
+	captain := &Captain{ID: "captain-1", config: cfg}
+	executor := NewPlanExecutor(manager)
+	captain.SetExecutor(executor)
+	var observed []string
+	executor.SetStepObserver(func(task Task, result *Result) {
+		observed = append(observed, task.ID)
+	})
+
+	plan := &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "inspect and deploy",
+		Tasks: []Task{
+			{ID: "inspect", Type: TaskTypeExecution, Payload: map[string]any{"command": "git status && git diff"}},
+			{ID: "deploy", Type: TaskTypeExecution, Dependencies: []string{"inspect"}, Payload: map[string]any{"command": "git push origin main"}},
+		},
+	}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, StepStatusSucceeded, results[0].Status())
+	assert.True(t, agent.last.Load().(agents.Task).ReadOnly, "agents are told to skip their own writes")
+
+	assert.Equal(t, StepStatusSkipped, results[1].Status())
+	assert.Equal(t, `skipped: read-only mode blocks command "git push origin main"`, results[1].Output)
+	assert.Equal(t, true, results[1].Metadata["read_only"])
+	assert.Nil(t, results[1].Metadata["agent_id"], "blocked commands never reach an agent")
+	assert.Equal(t, []string{"inspect", "deploy"}, observed)
+}
+
+func TestCaptain_ExecutePlan_MaxRuntime(t *testing.T) {
+	manager := agents.NewAgentManager()
+	hanging := &hangingAgent{started: make(chan struct{})}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		hanging.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return hanging, nil
+	})
+	manager.RegisterAgentType(agents.AgentTypeResearch, func(id, name string) (agents.Agent, error) {
+		return agents.NewBaseAgent(id, name, agents.AgentTypeResearch), nil
+	})
+
+	mockLLM := &MockLLMProvider{}
+	cfg := config.NewConfig()
+	cfg.Captain.MaxTaskRuntime = time.Hour
+	captain := &Captain{ID: "captain-1", config: cfg, llmProvider: mockLLM, planner: NewPlanningEngine(mockLLM)}
+	executor := NewPlanExecutor(manager)
+	executor.SetShutdownGrace(10 * time.Millisecond)
+	captain.SetExecutor(executor)
+
+	plan := &ExecutionPlan{
+		ID:       "plan-1",
+		Goal:     "analyze then build",
+		Timeline: ExecutionTimeline{MaxRuntime: 50 * time.Millisecond},
+		Tasks: []Task{
+			{ID: "task-1", Type: TaskTypeAnalysis},
+			{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}},
+			{ID: "task-3", Type: TaskTypeExecution, Dependencies: []string{"task-2"}},
+		},
+	}
+	assert.Equal(t, 50*time.Millisecond, captain.maxRuntime(plan), "the plan's limit wins over the config")
+
+	result, err := captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+	assert.True(t, result.TimedOut)
+	assert.False(t, result.Interrupted, "a timeout fails the task rather than cancelling it")
+	assert.False(t, result.Success)
+	assert.Equal(t, "task exceeded its max runtime of 50ms", result.Error)
+
+	// The finished step is kept, the in-flight one checkpointed and the last never started
+	require.Len(t, result.TaskResults, 2)
+	assert.Equal(t, StepStatusSucceeded, result.TaskResults[0].Status())
+	assert.Equal(t, StepStatusInterrupted, result.TaskResults[1].Status())
+
+	plan.Timeline.MaxRuntime = 0
+	assert.Equal(t, time.Hour, captain.maxRuntime(plan))
+}
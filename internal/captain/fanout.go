@@ -0,0 +1,217 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// Entries fan-out steps and the steps after them find in their payload
+const (
+	// PayloadItem holds the item one run of a fan-out step works on
+	PayloadItem = "item"
+	// PayloadFanIn holds the item results of the fan-out steps a step depends on, by step ID
+	PayloadFanIn = "fan_in"
+)
+
+// FanOutItemEnv is the environment variable a fan-out step's commands find their item in
+const FanOutItemEnv = "CAPN_ITEM"
+
+// FanOutItem is the result of one run of a fan-out step
+type FanOutItem struct {
+	Item     string        `json:"item"`
+	StepID   string        `json:"step_id"`
+	Status   StepStatus    `json:"status"`
+	Success  bool          `json:"success"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	AgentID  string        `json:"agent_id,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// FanOutStep returns the run of a fan-out step working on the item at index. The run publishes
+// nothing itself: the step's joined result is published once every item has finished.
+func (t Task) FanOutStep(index int, item string) Task {
+	step := t
+	step.ID = fmt.Sprintf("%s[%d]", t.ID, index)
+	step.FanOut = nil
+	step.Payload = make(map[string]any, len(t.Payload)+1)
+	for k, v := range t.Payload {
+		if k != PayloadPublish {
+			step.Payload[k] = v
+		}
+	}
+	step.Payload[PayloadItem] = item
+	if description, _ := t.Payload["description"].(string); description != "" {
+		step.Payload["description"] = fmt.Sprintf("%s (%s)", description, item)
+	}
+	step.Env = make(map[string]string, len(t.Env)+1)
+	for k, v := range t.Env {
+		step.Env[k] = v
+	}
+	step.Env[FanOutItemEnv] = item
+	return step
+}
+
+// ParentStep returns the step a fan-out run belongs to, or id itself when it is not a run
+func ParentStep(id string) string {
+	if open := strings.LastIndex(id, "["); open > 0 && strings.HasSuffix(id, "]") {
+		return id[:open]
+	}
+	return id
+}
+
+// FanOutItems returns the item results joined into a fan-out step's result, including results
+// decoded from a stored task, or nil for other steps
+func FanOutItems(result Result) []FanOutItem {
+	switch items := result.Metadata["fan_out"].(type) {
+	case []FanOutItem:
+		return items
+	case []any:
+		data, err := json.Marshal(items)
+		if err != nil {
+			return nil
+		}
+		var decoded []FanOutItem
+		if json.Unmarshal(data, &decoded) != nil {
+			return nil
+		}
+		return decoded
+	}
+	return nil
+}
+
+// executeFanOut runs a fan-out step once per item, each taking one of slots, and joins the
+// items' results into the step's result. Without slots the items run at most the executor's
+// parallel limit at a time. Items not started before ctx ends are interrupted, so the whole
+// step runs again when the task is resumed.
+func (e *PlanExecutor) executeFanOut(ctx context.Context, task Task, slots chan struct{}) (Result, []Handoff) {
+	start := e.clock.Now()
+	total := len(task.FanOut)
+	if slots == nil {
+		limit := e.maxParallel
+		if limit <= 0 || limit > total {
+			limit = total
+		}
+		slots = make(chan struct{}, limit)
+	}
+
+	items := make([]FanOutItem, total)
+	results := make([]Result, total)
+	handoffs := make([][]Handoff, total)
+	var mu sync.Mutex
+	done := 0
+	var wg sync.WaitGroup
+	for i, item := range task.FanOut {
+		wg.Add(1)
+		go func(i int, item string) {
+			defer wg.Done()
+			step := task.FanOutStep(i, item)
+			if release, ok := takeSlot(ctx, slots, step); ok {
+				defer release()
+				results[i], handoffs[i] = e.runStep(ctx, step, nil)
+			} else {
+				results[i] = e.notStartedResult(step.ID, "item")
+			}
+			items[i] = fanOutItem(item, results[i])
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if e.fanOut != nil {
+				e.fanOut(task, items[i], done, total)
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	var all []Handoff
+	for _, h := range handoffs {
+		all = append(all, h...)
+	}
+	return e.joinFanOut(task, items, results, start), all
+}
+
+// fanOutItem summarizes the result of one run of a fan-out step
+func fanOutItem(item string, result Result) FanOutItem {
+	agentID, _ := result.Metadata["agent_id"].(string)
+	return FanOutItem{
+		Item:     item,
+		StepID:   result.TaskID,
+		Status:   result.Status(),
+		Success:  result.Success,
+		Output:   result.Output,
+		Error:    result.Error,
+		AgentID:  agentID,
+		Duration: result.Duration,
+	}
+}
+
+// joinFanOut joins the results of a fan-out step's runs: the step succeeds when every item
+// did, its output lists each item's output in item order, and it keeps every item's artifacts
+func (e *PlanExecutor) joinFanOut(task Task, items []FanOutItem, results []Result, start time.Time) Result {
+	joined := Result{
+		TaskID:    task.ID,
+		Success:   true,
+		Duration:  e.clock.Since(start),
+		Timestamp: e.clock.Now(),
+		Metadata:  map[string]any{"fan_out": items},
+	}
+	var outputs, failures []string
+	var usage agents.ResourceUsage
+	for i, item := range items {
+		joined.Artifacts = append(joined.Artifacts, results[i].Artifacts...)
+		if results[i].Resources != nil {
+			usage.Add(*results[i].Resources)
+		}
+		if results[i].LLM != nil {
+			if joined.LLM == nil {
+				joined.LLM = &agents.LLMUsage{}
+			}
+			joined.LLM.Add(*results[i].LLM)
+		}
+		if item.Success {
+			outputs = append(outputs, fmt.Sprintf("[%s] %s", item.Item, item.Output))
+			continue
+		}
+		joined.Success = false
+		outputs = append(outputs, fmt.Sprintf("[%s] %s: %s", item.Item, item.Status, item.Error))
+		failures = append(failures, fmt.Sprintf("%s: %s", item.Item, item.Error))
+		if item.Status == StepStatusInterrupted {
+			joined.Metadata["interrupted"] = true
+		}
+	}
+	// Items run side by side, so the step took only its own wall time
+	joined.Resources = withWallTime(&usage, joined.Duration)
+	joined.Output = strings.Join(outputs, "\n")
+	if len(failures) > 0 {
+		joined.Error = fmt.Sprintf("%d of %d items failed: %s", len(failures), len(items), strings.Join(failures, "; "))
+	}
+	return joined
+}
+
+// withFanIn returns a copy of the step carrying, in its "fan_in" payload entry, the item
+// results of the fan-out steps it depends on
+func withFanIn(task Task, results map[string]Result) Task {
+	fanIn := make(map[string][]FanOutItem)
+	for _, dep := range task.Dependencies {
+		if items := FanOutItems(results[dep]); len(items) > 0 {
+			fanIn[dep] = items
+		}
+	}
+	if len(fanIn) == 0 {
+		return task
+	}
+	payload := make(map[string]any, len(task.Payload)+1)
+	for k, v := range task.Payload {
+		payload[k] = v
+	}
+	payload[PayloadFanIn] = fanIn
+	task.Payload = payload
+	return task
+}
@@ -0,0 +1,216 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// itemAgent works on the item of a fan-out run, failing the "broken" item, and records how
+// many runs the crew worked on at once and what the steps after the fan-out received
+type itemAgent struct {
+	*agents.BaseAgent
+	crew *itemCrew
+	busy atomic.Bool
+}
+
+// itemCrew is what the item agents of a test record together
+type itemCrew struct {
+	running  atomic.Int32
+	peak     atomic.Int32
+	overlaps atomic.Int32
+	mu       sync.Mutex
+	fanIn    map[string]any
+	// The first gated runs wait for each other, so they are known to have run at once
+	gated    int32
+	started  atomic.Int32
+	together sync.WaitGroup
+	// With a target, runs wait until that many run at once or a moment passes, so a limit
+	// letting more than it allows run is seen to
+	target  int32
+	reached chan struct{}
+	once    sync.Once
+}
+
+func (a *itemAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	crew := a.crew
+	if fanIn, ok := task.Data[PayloadFanIn]; ok {
+		crew.mu.Lock()
+		crew.fanIn[task.ID] = fanIn
+		crew.mu.Unlock()
+	}
+	item, _ := task.Data[PayloadItem].(string)
+	if item == "" {
+		return agents.Result{TaskID: task.ID, Success: true, Output: "joined"}
+	}
+
+	if !a.busy.CompareAndSwap(false, true) {
+		crew.overlaps.Add(1)
+	}
+	defer a.busy.Store(false)
+	now := crew.running.Add(1)
+	defer crew.running.Add(-1)
+	for peak := crew.peak.Load(); now > peak && !crew.peak.CompareAndSwap(peak, now); peak = crew.peak.Load() {
+	}
+	if crew.target > 0 {
+		if now >= crew.target {
+			crew.once.Do(func() { close(crew.reached) })
+		}
+		select {
+		case <-crew.reached:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if crew.started.Add(1) <= crew.gated {
+		crew.together.Done()
+		crew.together.Wait()
+	}
+	if item == "broken" {
+		return agents.Result{TaskID: task.ID, Error: "connection refused"}
+	}
+	return agents.Result{TaskID: task.ID, Success: true, Output: "deployed " + task.Env[FanOutItemEnv], Resources: &agents.ResourceUsage{FilesWritten: 1}}
+}
+
+// newItemManager returns an agent manager whose file agents are item agents of one crew
+func newItemManager() (*agents.AgentManager, *itemCrew) {
+	crew := &itemCrew{fanIn: make(map[string]any)}
+	manager := agents.NewAgentManager()
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		return &itemAgent{BaseAgent: agents.NewBaseAgent(id, name, agents.AgentTypeFile), crew: crew}, nil
+	})
+	return manager, crew
+}
+
+func TestTask_FanOutStep(t *testing.T) {
+	task := Task{
+		ID:      "deploy",
+		Payload: map[string]any{"description": "Deploy the app", PayloadPublish: "deployments"},
+		Env:     map[string]string{"REGION": "eu"},
+		FanOut:  []string{"web-1", "web-2"},
+	}
+
+	step := task.FanOutStep(1, "web-2")
+	assert.Equal(t, "deploy[1]", step.ID)
+	assert.Empty(t, step.FanOut)
+	assert.Equal(t, "web-2", step.Payload[PayloadItem])
+	assert.Equal(t, "Deploy the app (web-2)", step.Payload["description"])
+	assert.NotContains(t, step.Payload, PayloadPublish, "the joined result is published instead")
+	assert.Equal(t, map[string]string{"REGION": "eu", FanOutItemEnv: "web-2"}, step.Env)
+	assert.Equal(t, map[string]string{"REGION": "eu"}, task.Env, "the step itself is unchanged")
+
+	assert.Equal(t, "deploy", ParentStep(step.ID))
+	assert.Equal(t, "deploy", ParentStep("deploy"))
+}
+
+func TestPlanExecutor_FanOut(t *testing.T) {
+	manager, crew := newItemManager()
+	crew.gated = 2
+	crew.together.Add(2)
+	executor := NewPlanExecutor(manager)
+	executor.SetMaxParallel(2)
+	var progress []string
+	executor.SetFanOutObserver(func(task Task, item FanOutItem, done, total int) {
+		progress = append(progress, fmt.Sprintf("%s %d/%d", task.ID, done, total))
+	})
+
+	plan := &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "deploy everywhere",
+		Tasks: []Task{
+			{ID: "deploy", Type: TaskTypeExecution, FanOut: []string{"web-1", "web-2", "web-3", "web-4"}},
+			{ID: "report", Type: TaskTypeExecution, Dependencies: []string{"deploy"}},
+		},
+	}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2, "the items join into the step's single result")
+
+	deploy := results[0]
+	assert.True(t, deploy.Success)
+	assert.Equal(t, "[web-1] deployed web-1\n[web-2] deployed web-2\n[web-3] deployed web-3\n[web-4] deployed web-4", deploy.Output)
+	items := FanOutItems(deploy)
+	require.Len(t, items, 4)
+	assert.Equal(t, "deploy[2]", items[2].StepID)
+	assert.Equal(t, StepStatusSucceeded, items[2].Status)
+	assert.Equal(t, int32(2), crew.peak.Load(), "items run in parallel up to the limit")
+	assert.Zero(t, crew.overlaps.Load(), "items running at once never share an agent")
+
+	assert.Equal(t, []string{"deploy 1/4", "deploy 2/4", "deploy 3/4", "deploy 4/4"}, progress)
+	require.NotNil(t, deploy.Resources)
+	assert.Equal(t, 4, deploy.Resources.FilesWritten, "the items' usage is summed")
+	assert.Equal(t, deploy.Duration, deploy.Resources.WallTime)
+
+	fanIn, ok := crew.fanIn["report"].(map[string][]FanOutItem)
+	require.True(t, ok, "the next step receives the joined results")
+	assert.Equal(t, items, fanIn["deploy"])
+}
+
+func TestPlanExecutor_FanOutSharesParallelLimit(t *testing.T) {
+	manager, crew := newItemManager()
+	crew.gated = 2
+	crew.together.Add(2)
+	crew.target, crew.reached = 3, make(chan struct{})
+	executor := NewPlanExecutor(manager)
+	executor.SetMaxParallel(2)
+
+	// Two fan-out steps run side by side, but their items share the plan's two agents
+	plan := &ExecutionPlan{ID: "plan-1", Strategy: ExecutionStrategy{Type: StrategyParallel}, Tasks: []Task{
+		{ID: "web", Type: TaskTypeExecution, FanOut: []string{"web-1", "web-2", "web-3"}},
+		{ID: "api", Type: TaskTypeExecution, FanOut: []string{"api-1", "api-2", "api-3"}},
+	}}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		assert.True(t, result.Success, result.Error)
+	}
+	assert.Equal(t, int32(2), crew.peak.Load(), "no more agents run than --parallel allows")
+	assert.Zero(t, crew.overlaps.Load())
+}
+
+func TestPlanExecutor_FanOutFailure(t *testing.T) {
+	manager, _ := newItemManager()
+	executor := NewPlanExecutor(manager)
+
+	result, _ := executor.ExecuteTask(context.Background(), Task{ID: "deploy", Type: TaskTypeExecution, FanOut: []string{"web-1", "broken"}})
+	assert.False(t, result.Success)
+	assert.Equal(t, StepStatusFailed, result.Status())
+	assert.Equal(t, "1 of 2 items failed: broken: connection refused", result.Error)
+	assert.Equal(t, "[web-1] deployed web-1\n[broken] failed: connection refused", result.Output)
+}
+
+func TestPlanExecutor_FanOutCancelled(t *testing.T) {
+	manager, _ := newItemManager()
+	executor := NewPlanExecutor(manager)
+	executor.SetMaxParallel(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	executor.SetFanOutObserver(func(Task, FanOutItem, int, int) { cancel() })
+	result, _ := executor.ExecuteTask(ctx, Task{ID: "deploy", Type: TaskTypeExecution, FanOut: []string{"web-1", "web-2"}})
+	assert.Equal(t, StepStatusInterrupted, result.Status(), "the step runs again when the task is resumed")
+	var statuses []StepStatus
+	for _, item := range FanOutItems(result) {
+		statuses = append(statuses, item.Status)
+	}
+	assert.ElementsMatch(t, []StepStatus{StepStatusSucceeded, StepStatusInterrupted}, statuses, "items not started are interrupted")
+}
+
+func TestFanOutItems_Decoded(t *testing.T) {
+	result := NewPlanExecutor(nil).joinFanOut(Task{ID: "deploy"}, []FanOutItem{{Item: "web-1", StepID: "deploy[0]", Status: StepStatusSucceeded, Success: true}}, []Result{{}}, time.Now())
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	var decoded Result
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, FanOutItems(result), FanOutItems(decoded))
+	assert.Nil(t, FanOutItems(Result{TaskID: "plain"}))
+}
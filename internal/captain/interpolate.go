@@ -0,0 +1,374 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// referencePattern matches the ${steps.<id>.<field>} and ${findings.<key>} references a step's
+// command and description may make to earlier steps. Other ${...} are left for the shell.
+var referencePattern = regexp.MustCompile(`\$\{(steps|findings)\.([^}]*)\}`)
+
+// placeholderPattern also matches the {{key}} placeholders an api_call step's URL, body and
+// headers fill with findings, each read as ${findings.key}
+var placeholderPattern = regexp.MustCompile(`\$\{(steps|findings)\.([^}]*)\}|\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// templatedPayload lists the payload entries references are resolved in; headers is a map
+// whose values are resolved
+var templatedPayload = []string{"command", "description", "path", "url", "body", "headers"}
+
+// rawSuffix ends a reference whose value is put into a command as it is, rather than quoted
+const rawSuffix = "|raw"
+
+// placeholderPayload lists the templated entries {{key}} placeholders are filled in. Commands
+// are left alone, as their {{...}} are often Go templates for tools such as docker.
+var placeholderPayload = []string{"url", "body", "headers"}
+
+// stepFields are the fields of a step result a reference may read; output and metadata may be
+// followed by a path into the output's JSON or the metadata key
+var stepFields = []string{"output", "error", "status", "success", "duration", "metadata"}
+
+// Reference is a ${steps.<id>.<field>} or ${findings.<key>} reference in a plan step
+type Reference struct {
+	// Text is the reference as written, such as ${steps.task-1.output.version}
+	Text string
+	// Step is the step whose result is read; empty for a finding
+	Step  string
+	Field string
+	// Path is the JSON path into the output, or the metadata key
+	Path []string
+	// Finding is the blackboard key of a finding reference
+	Finding string
+	// Raw puts the value into a command as it is, written as ${steps.build.output|raw}
+	Raw bool
+}
+
+// parseReference parses the reference matched by referencePattern
+func parseReference(match []string) (Reference, error) {
+	ref := Reference{Text: match[0]}
+	if len(match) > 3 && match[3] != "" {
+		ref.Finding = match[3]
+		return ref, nil
+	}
+	name, raw := strings.CutSuffix(match[2], rawSuffix)
+	ref.Raw = raw
+	if match[1] == "findings" {
+		if name == "" {
+			return ref, fmt.Errorf("%s names no finding", ref.Text)
+		}
+		ref.Finding = name
+		return ref, nil
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 || parts[0] == "" {
+		return ref, fmt.Errorf("%s must name a step and a field, as in ${steps.task-1.output}", ref.Text)
+	}
+	ref.Step, ref.Field, ref.Path = parts[0], parts[1], parts[2:]
+	switch {
+	case !slices.Contains(stepFields, ref.Field):
+		return ref, fmt.Errorf("%s reads unknown field %q (must be one of: %s)", ref.Text, ref.Field, strings.Join(stepFields, ", "))
+	case len(ref.Path) > 0 && ref.Field != "output" && ref.Field != "metadata":
+		return ref, fmt.Errorf("%s: only output and metadata have fields", ref.Text)
+	case ref.Field == "metadata" && len(ref.Path) == 0:
+		return ref, fmt.Errorf("%s must name a metadata key", ref.Text)
+	}
+	return ref, nil
+}
+
+// References returns the references the step's templated payload entries make, in order
+func (t Task) References() ([]Reference, error) {
+	var refs []Reference
+	for _, key := range templatedPayload {
+		pattern := templatePattern(key)
+		for _, text := range templateTexts(t.Payload[key]) {
+			for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+				ref, err := parseReference(match)
+				if err != nil {
+					return nil, err
+				}
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// templatePattern returns the pattern matching the references a payload entry may make
+func templatePattern(key string) *regexp.Regexp {
+	if slices.Contains(placeholderPayload, key) {
+		return placeholderPattern
+	}
+	return referencePattern
+}
+
+// templateTexts returns the text of a payload entry: a string, or the values of a map in key
+// order
+func templateTexts(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[string]any:
+		var texts []string
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			if text, ok := v[key].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+		return texts
+	}
+	return nil
+}
+
+// validateReferences checks every step reference parses and reads a step the referring step
+// depends on, directly or through other steps, so the step has always finished before it
+func validateReferences(tasks []Task) error {
+	byID := make(map[string]Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+	var dependsOn func(task Task, step string, seen map[string]bool) bool
+	dependsOn = func(task Task, step string, seen map[string]bool) bool {
+		for _, dep := range task.Dependencies {
+			if dep == step {
+				return true
+			}
+			if !seen[dep] {
+				seen[dep] = true
+				if dependsOn(byID[dep], step, seen) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, task := range tasks {
+		refs, err := task.References()
+		if err != nil {
+			return fmt.Errorf("task %s: %w", task.ID, err)
+		}
+		for _, ref := range refs {
+			if ref.Step == "" {
+				continue
+			}
+			if _, ok := byID[ref.Step]; !ok {
+				return fmt.Errorf("task %s: %s refers to unknown step %s", task.ID, ref.Text, ref.Step)
+			}
+			if !dependsOn(task, ref.Step, map[string]bool{}) {
+				return fmt.Errorf("task %s: %s reads step %s, which it does not depend on", task.ID, ref.Text, ref.Step)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveReferences returns a copy of the step with the references in its templated payload
+// entries replaced by the results of earlier steps and the findings on the blackboard. Values
+// put into the command are quoted for the shell unless the reference ends in |raw.
+// In strict mode a reference that cannot be resolved is an error; in lenient mode it is
+// replaced with nothing and listed in the returned references.
+func resolveReferences(ctx context.Context, task Task, results map[string]Result, blackboard agents.Blackboard, mode string) (Task, []string, error) {
+	if _, err := task.References(); err != nil {
+		return task, nil, fmt.Errorf("task %s: %w", task.ID, err)
+	}
+
+	var unresolved []string
+	var failure error
+	resolved := make(map[string]any, len(task.Payload))
+	for k, v := range task.Payload {
+		resolved[k] = v
+	}
+	for _, key := range templatedPayload {
+		pattern := templatePattern(key)
+		replace := func(text string) string {
+			var filled strings.Builder
+			last := 0
+			for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+				filled.WriteString(text[last:loc[0]])
+				last = loc[1]
+				match := make([]string, len(loc)/2)
+				for i := range match {
+					if loc[2*i] >= 0 {
+						match[i] = text[loc[2*i]:loc[2*i+1]]
+					}
+				}
+				ref, _ := parseReference(match)
+				value, err := ref.resolve(ctx, results, blackboard)
+				switch {
+				case err == nil && key == "command" && !ref.Raw:
+					filled.WriteString(shellQuote(value, quoteContext(text[:loc[0]])))
+				case err == nil:
+					filled.WriteString(value)
+				case mode != config.MissingVariablesLenient:
+					if failure == nil {
+						failure = fmt.Errorf("task %s: cannot resolve %s: %w", task.ID, ref.Text, err)
+					}
+					filled.WriteString(ref.Text)
+				default:
+					unresolved = append(unresolved, ref.Text)
+				}
+			}
+			filled.WriteString(text[last:])
+			return filled.String()
+		}
+		switch value := task.Payload[key].(type) {
+		case string:
+			if pattern.MatchString(value) {
+				resolved[key] = replace(value)
+			}
+		case map[string]any:
+			entries := make(map[string]any, len(value))
+			for _, name := range slices.Sorted(maps.Keys(value)) {
+				entries[name] = value[name]
+				if text, ok := value[name].(string); ok {
+					entries[name] = replace(text)
+				}
+			}
+			resolved[key] = entries
+		}
+	}
+	if failure != nil {
+		return task, nil, failure
+	}
+	task.Payload = resolved
+	return task, unresolved, nil
+}
+
+// resolve returns the value a reference reads, formatted for a command or description
+func (r Reference) resolve(ctx context.Context, results map[string]Result, blackboard agents.Blackboard) (string, error) {
+	if r.Finding != "" {
+		if blackboard == nil {
+			return "", agents.ErrNoBlackboard
+		}
+		finding, ok, err := blackboard.Lookup(ctx, r.Finding)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("no step has published finding %q", r.Finding)
+		}
+		return formatValue(finding.Value), nil
+	}
+
+	result, ran := results[r.Step]
+	switch {
+	case !ran:
+		return "", fmt.Errorf("step %s has not run", r.Step)
+	case result.Status() == StepStatusSkipped && r.Field != "status":
+		return "", fmt.Errorf("step %s was skipped", r.Step)
+	}
+	switch r.Field {
+	case "output":
+		if len(r.Path) == 0 {
+			return result.Output, nil
+		}
+		var value any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(result.Output)), &value); err != nil {
+			return "", fmt.Errorf("output of %s is not JSON", r.Step)
+		}
+		value, err := lookupPath(value, r.Path)
+		if err != nil {
+			return "", fmt.Errorf("output of %s %w", r.Step, err)
+		}
+		return formatValue(value), nil
+	case "error":
+		return result.Error, nil
+	case "status":
+		return string(result.Status()), nil
+	case "success":
+		return strconv.FormatBool(result.Success), nil
+	case "duration":
+		return result.Duration.String(), nil
+	default:
+		key := strings.Join(r.Path, ".")
+		value, ok := result.Metadata[key]
+		if !ok {
+			return "", fmt.Errorf("step %s has no metadata %q", r.Step, key)
+		}
+		return formatValue(value), nil
+	}
+}
+
+// quoteContext returns the quote a shell is inside at the end of prefix: ', " or 0 for none
+func quoteContext(prefix string) rune {
+	var quote rune
+	escaped := false
+	for _, r := range prefix {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote = r
+		case r == quote:
+			quote = 0
+		}
+	}
+	return quote
+}
+
+// shellQuote makes a value a single word of a shell command where it is substituted inside
+// the given quote, so a step's output cannot add commands of its own
+func shellQuote(value string, quote rune) string {
+	switch quote {
+	case '\'':
+		return strings.ReplaceAll(value, "'", `'\''`)
+	case '"':
+		return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(value)
+	}
+	if value != "" && safeWord.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// safeWord matches values the shell reads as a single word without quoting
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// lookupPath walks object keys and array indexes into a decoded JSON value
+func lookupPath(value any, path []string) (any, error) {
+	for i, part := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return nil, fmt.Errorf("has no field %s", strings.Join(path[:i+1], "."))
+			}
+			value = next
+		case []any:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("has no element %s", strings.Join(path[:i+1], "."))
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("has no field %s", strings.Join(path[:i+1], "."))
+		}
+	}
+	return value, nil
+}
+
+// formatValue writes strings as they are and any other value as JSON
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
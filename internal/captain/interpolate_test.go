@@ -0,0 +1,212 @@
+package captain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestTask_References(t *testing.T) {
+	task := Task{ID: "task-3", Payload: map[string]any{
+		"command":     "deploy --version ${steps.task-1.output.version} --to ${findings.web.address} --home ${HOME}",
+		"description": "Deploy after ${steps.task-2.status} checks",
+	}}
+	refs, err := task.References()
+	require.NoError(t, err)
+	assert.Equal(t, []Reference{
+		{Text: "${steps.task-1.output.version}", Step: "task-1", Field: "output", Path: []string{"version"}},
+		{Text: "${findings.web.address}", Finding: "web.address"},
+		{Text: "${steps.task-2.status}", Step: "task-2", Field: "status", Path: []string{}},
+	}, refs, "other ${...} are left for the shell")
+
+	for text, want := range map[string]string{
+		"${steps.task-1}":             "${steps.task-1} must name a step and a field, as in ${steps.task-1.output}",
+		"${steps.task-1.stdout}":      `${steps.task-1.stdout} reads unknown field "stdout" (must be one of: output, error, status, success, duration, metadata)`,
+		"${steps.task-1.status.code}": "${steps.task-1.status.code}: only output and metadata have fields",
+		"${steps.task-1.metadata}":    "${steps.task-1.metadata} must name a metadata key",
+		"${findings.}":                "${findings.} names no finding",
+	} {
+		_, err := Task{Payload: map[string]any{"command": "echo " + text}}.References()
+		assert.EqualError(t, err, want)
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	tasks := []Task{
+		{ID: "task-1"},
+		{ID: "task-2", Dependencies: []string{"task-1"}},
+		{ID: "task-3", Dependencies: []string{"task-2"}, Payload: map[string]any{"command": "echo ${steps.task-1.output}"}},
+	}
+	assert.NoError(t, validateReferences(tasks), "steps may read steps they depend on through others")
+
+	tasks[2].Payload["command"] = "echo ${steps.task-4.output}"
+	assert.EqualError(t, validateReferences(tasks), "task task-3: ${steps.task-4.output} refers to unknown step task-4")
+
+	tasks[1].Payload = map[string]any{"description": "Check ${steps.task-3.output}"}
+	tasks[2].Payload["command"] = "echo"
+	assert.EqualError(t, validateReferences(tasks), "task task-2: ${steps.task-3.output} reads step task-3, which it does not depend on")
+}
+
+func TestResolveReferences(t *testing.T) {
+	results := map[string]Result{
+		"build": {TaskID: "build", Success: true, Output: `{"version": "1.4.2", "artifacts": [{"path": "dist/app.tar.gz"}], "size": 1024}`,
+			Duration: 90 * time.Second, Metadata: map[string]any{"agent_id": "file-001"}},
+		"lint": {TaskID: "lint", Error: "2 warnings"},
+		"docs": {TaskID: "docs", Success: true, Output: "skipped: build failed", Metadata: map[string]any{"skipped": true}},
+	}
+	blackboard := agents.NewMemoryBlackboard()
+	require.NoError(t, blackboard.Publish(context.Background(), agents.Finding{Key: "web.address", Value: "localhost:3000"}))
+
+	task := Task{ID: "deploy", Payload: map[string]any{
+		"command":     "upload ${steps.build.output.artifacts.0.path} --size ${steps.build.output.size} --to ${findings.web.address}",
+		"description": "Deploy ${steps.build.output.version} built in ${steps.build.duration} on ${steps.build.metadata.agent_id}; lint ${steps.lint.status}: ${steps.lint.error}, docs ${steps.docs.status}",
+		"path":        "${steps.build.output}",
+		"target":      "${steps.build.output}",
+	}}
+	resolved, unresolved, err := resolveReferences(context.Background(), task, results, blackboard, config.MissingVariablesStrict)
+	require.NoError(t, err)
+	assert.Empty(t, unresolved)
+	assert.Equal(t, "upload dist/app.tar.gz --size 1024 --to localhost:3000", resolved.Payload["command"])
+	assert.Equal(t, "Deploy 1.4.2 built in 1m30s on file-001; lint failed: 2 warnings, docs skipped", resolved.Payload["description"])
+	assert.Equal(t, results["build"].Output, resolved.Payload["path"])
+	assert.Equal(t, "${steps.build.output}", resolved.Payload["target"], "only templated entries are resolved")
+	assert.Contains(t, task.Payload["command"], "${", "the plan's step is left as it is")
+
+	call := Task{ID: "notify", Payload: map[string]any{
+		"url":     "https://{{web.address}}/builds/${steps.build.output.version}",
+		"body":    `{"size": {{ steps.size }}}`,
+		"headers": map[string]any{"X-Agent": "${steps.build.metadata.agent_id}", "X-Retries": 3},
+		"command": "docker ps --format '{{.Names}}'",
+	}}
+	require.NoError(t, blackboard.Publish(context.Background(), agents.Finding{Key: "steps.size", Value: 1024}))
+	resolved, _, err = resolveReferences(context.Background(), call, results, blackboard, config.MissingVariablesStrict)
+	require.NoError(t, err)
+	assert.Equal(t, "https://localhost:3000/builds/1.4.2", resolved.Payload["url"], "{{key}} reads the finding")
+	assert.Equal(t, `{"size": 1024}`, resolved.Payload["body"])
+	assert.Equal(t, map[string]any{"X-Agent": "file-001", "X-Retries": 3}, resolved.Payload["headers"])
+	assert.Equal(t, "docker ps --format '{{.Names}}'", resolved.Payload["command"], "commands keep their {{...}}")
+	assert.Equal(t, "${steps.build.metadata.agent_id}", call.Payload["headers"].(map[string]any)["X-Agent"], "the plan's headers are left as they are")
+
+	_, _, err = resolveReferences(context.Background(), Task{ID: "notify", Payload: map[string]any{"url": "https://x/{{user_id}}"}}, results, blackboard, config.MissingVariablesStrict)
+	assert.EqualError(t, err, `task notify: cannot resolve {{user_id}}: no step has published finding "user_id"`)
+
+	missing := Task{ID: "deploy", Payload: map[string]any{"command": "deploy ${steps.build.output.commit} ${steps.docs.output} ${findings.token}"}}
+	_, _, err = resolveReferences(context.Background(), missing, results, blackboard, "")
+	assert.EqualError(t, err, "task deploy: cannot resolve ${steps.build.output.commit}: output of build has no field commit")
+
+	resolved, unresolved, err = resolveReferences(context.Background(), missing, results, blackboard, config.MissingVariablesLenient)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy   ", resolved.Payload["command"])
+	assert.Equal(t, []string{"${steps.build.output.commit}", "${steps.docs.output}", "${findings.token}"}, unresolved)
+
+	for command, want := range map[string]string{
+		"${steps.lint.output.count}": "task deploy: cannot resolve ${steps.lint.output.count}: output of lint is not JSON",
+		"${steps.test.output}":       "task deploy: cannot resolve ${steps.test.output}: step test has not run",
+		"${steps.docs.output}":       "task deploy: cannot resolve ${steps.docs.output}: step docs was skipped",
+		"${findings.token}":          `task deploy: cannot resolve ${findings.token}: no step has published finding "token"`,
+	} {
+		_, _, err := resolveReferences(context.Background(), Task{ID: "deploy", Payload: map[string]any{"command": command}}, results, blackboard, config.MissingVariablesStrict)
+		assert.EqualError(t, err, want)
+	}
+}
+
+func TestResolveReferences_QuotesCommands(t *testing.T) {
+	results := map[string]Result{
+		"scan": {TaskID: "scan", Success: true, Output: "a.go; rm -rf / $(whoami) 'x' \"y\""},
+		"tag":  {TaskID: "tag", Success: true, Output: "v1.2.0"},
+	}
+	for command, want := range map[string]string{
+		"git tag ${steps.tag.output}":     "git tag v1.2.0",
+		"grep TODO ${steps.scan.output}":  `grep TODO 'a.go; rm -rf / $(whoami) '\''x'\'' "y"'`,
+		"echo '${steps.scan.output}'":     `echo 'a.go; rm -rf / $(whoami) '\''x'\'' "y"'`,
+		`echo "${steps.scan.output}"`:     `echo "a.go; rm -rf / \$(whoami) 'x' \"y\""`,
+		`echo \'${steps.tag.output}`:      `echo \'v1.2.0`,
+		"sh -c ${steps.scan.output|raw}":  `sh -c a.go; rm -rf / $(whoami) 'x' "y"`,
+		"echo ${steps.scan.error}":        "echo ''",
+		"docker ps --format '{{.Names}}'": "docker ps --format '{{.Names}}'",
+	} {
+		task := Task{ID: "t", Payload: map[string]any{"command": command, "description": "Check ${steps.scan.output}"}}
+		resolved, _, err := resolveReferences(context.Background(), task, results, nil, config.MissingVariablesStrict)
+		require.NoError(t, err)
+		assert.Equal(t, want, resolved.Payload["command"], command)
+		assert.Equal(t, "Check "+results["scan"].Output, resolved.Payload["description"], "only commands are quoted")
+	}
+
+	refs, err := Task{Payload: map[string]any{"command": "${findings.cmd|raw}"}}.References()
+	require.NoError(t, err)
+	assert.Equal(t, []Reference{{Text: "${findings.cmd|raw}", Finding: "cmd", Raw: true}}, refs)
+}
+
+func TestPlanExecutor_ResolvesReferences(t *testing.T) {
+	manager := agents.NewAgentManager()
+	agent := &capturingAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+	executor := NewPlanExecutor(manager)
+	var observed []Task
+	executor.SetStepObserver(func(task Task, result *Result) { observed = append(observed, task) })
+
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+		{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "build"}},
+		{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}, Payload: map[string]any{
+			"description": "ship", "command": "echo '${steps.task-1.output}'",
+		}},
+		{ID: "task-3", Type: TaskTypeExecution, Dependencies: []string{"task-1"}, Payload: map[string]any{
+			"description": "tag", "command": "git tag ${steps.task-1.output.version}",
+		}},
+	}}
+
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.True(t, results[1].Success, results[1].Error)
+	assert.Equal(t, "echo '"+results[0].Output+"'", observed[1].Payload["command"], "steps run and are observed resolved")
+	assert.False(t, results[2].Success)
+	assert.Equal(t, "task task-3: cannot resolve ${steps.task-1.output.version}: output of task-1 is not JSON", results[2].Error)
+
+	executor.SetMissingVariables(config.MissingVariablesLenient)
+	results, _, err = executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.True(t, results[2].Success, results[2].Error)
+	assert.Equal(t, []string{"${steps.task-1.output.version}"}, results[2].Metadata["unresolved"])
+	received := agent.last.Load().(agents.Task)
+	assert.Equal(t, "git tag ", received.Data["command"])
+}
+
+func TestPlanExecutor_PolicySeesResolvedCommand(t *testing.T) {
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	executor.SetPolicy(&Policy{Commands: PolicyList{Deny: []string{"rm"}}})
+	blackboard := agents.NewMemoryBlackboard()
+	require.NoError(t, blackboard.Publish(context.Background(), agents.Finding{Key: "cleanup", Value: "rm -rf /"}))
+	executor.SetBlackboard(blackboard)
+
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+		{ID: "task-1", Type: TaskTypeExecution, Payload: map[string]any{"command": "${findings.cleanup|raw}"}},
+	}}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.Error(t, err)
+	assert.Contains(t, results[0].Error, "rm")
+}
+
+func TestPlanExecutor_PolicySeesFilledURL(t *testing.T) {
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	executor.SetPolicy(&Policy{Hosts: PolicyList{Allow: []string{"api.example.com"}}})
+	blackboard := agents.NewMemoryBlackboard()
+	require.NoError(t, blackboard.Publish(context.Background(), agents.Finding{Key: "next", Value: "evil.example.net/steal"}))
+	executor.SetBlackboard(blackboard)
+
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+		{ID: "task-1", Type: TaskTypeAnalysis, Payload: map[string]any{"operation": "api_call", "url": "https://{{next}}", "inputs": []string{"next"}}},
+	}}
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.Error(t, err)
+	assert.Contains(t, results[0].Error, "host evil.example.net is not allowed")
+}
@@ -0,0 +1,183 @@
+package captain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MetadataLanguage is the plan metadata entry naming the language the plan's reasoning and
+// step descriptions were written in, when the Captain was told to write in one
+const MetadataLanguage = "language"
+
+// LanguageAuto has the Captain answer in the language a goal is written in
+const LanguageAuto = "auto"
+
+// defaultLanguage is the language the Captain writes in unless told otherwise
+const defaultLanguage = "en"
+
+// languageNames names the languages goals are detected in, by language tag
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"th": "Thai",
+	"uk": "Ukrainian",
+	"zh": "Chinese",
+}
+
+// latinLanguages are the languages written in Latin script that goals are detected in, in the
+// order ties are broken
+var latinLanguages = []string{"en", "fr", "es", "de", "pt", "it", "nl"}
+
+// languageWords are common short words of each Latin-script language, which goals mention
+// whatever they are about
+var languageWords = map[string][]string{
+	"en": {"the", "a", "an", "and", "or", "of", "to", "in", "for", "on", "with", "is", "are", "be", "this", "that", "it", "from", "by", "at", "as", "all", "my", "our", "your", "into"},
+	"fr": {"le", "la", "les", "un", "une", "des", "du", "de", "et", "ou", "est", "sont", "dans", "pour", "sur", "avec", "ce", "cette", "ces", "qui", "que", "aux", "au", "mon", "notre", "votre", "par", "pas", "en"},
+	"es": {"el", "la", "los", "las", "un", "una", "unos", "unas", "y", "o", "de", "del", "en", "para", "con", "por", "es", "son", "este", "esta", "que", "al", "mi", "nuestro", "su"},
+	"de": {"der", "die", "das", "ein", "eine", "und", "oder", "ist", "sind", "im", "in", "mit", "für", "auf", "von", "zu", "den", "dem", "des", "nicht", "alle", "unser", "mein"},
+	"pt": {"o", "a", "os", "as", "um", "uma", "e", "ou", "de", "do", "da", "dos", "das", "em", "no", "na", "para", "com", "por", "é", "são", "este", "esta", "que", "ao"},
+	"it": {"il", "lo", "la", "gli", "le", "un", "una", "e", "o", "di", "del", "della", "in", "per", "con", "su", "è", "sono", "questo", "questa", "che", "al", "nel"},
+	"nl": {"de", "het", "een", "en", "of", "is", "zijn", "in", "op", "met", "voor", "van", "te", "dit", "dat", "niet", "alle", "onze"},
+}
+
+// languageLetters are letters that, in a Latin-script goal, point to a language
+var languageLetters = map[rune]string{
+	'ç': "fr", 'è': "fr", 'ê': "fr", 'à': "fr", 'œ': "fr",
+	'ñ': "es", '¿': "es", '¡': "es", 'ó': "es", 'í': "es",
+	'ä': "de", 'ö': "de", 'ü': "de", 'ß': "de",
+	'ã': "pt", 'õ': "pt",
+	'ì': "it", 'ò': "it",
+}
+
+// DetectLanguage returns the tag of the language a text is most likely written in, or an
+// empty string when it cannot tell, as for a single word or a command
+func DetectLanguage(text string) string {
+	scripts := make(map[string]int)
+	latin, kana, ukrainian := 0, 0, 0
+	hints := make(map[string]int)
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+			scripts["zh"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+			scripts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			if language, ok := languageLetters[unicode.ToLower(r)]; ok {
+				hints[language]++
+			}
+		}
+	}
+
+	best, count := "", 0
+	for _, language := range []string{"zh", "ko", "ru", "ar", "he", "el", "th", "hi"} {
+		if scripts[language] > count {
+			best, count = language, scripts[language]
+		}
+	}
+	if count > 0 && count >= latin {
+		// Japanese mixes kana with Han characters, and Ukrainian has letters Russian lacks
+		switch {
+		case best == "zh" && kana > 0:
+			return "ja"
+		case best == "ru" && ukrainian > 0:
+			return "uk"
+		}
+		return best
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	scores := make(map[string]int, len(latinLanguages))
+	for _, word := range words {
+		for _, language := range latinLanguages {
+			for _, common := range languageWords[language] {
+				if word == common {
+					scores[language]++
+				}
+			}
+		}
+	}
+	best, score := "", 0
+	for _, language := range latinLanguages {
+		if total := scores[language] + hints[language]; total > score {
+			best, score = language, total
+		}
+	}
+	return best
+}
+
+// LanguageName returns the English name of a language tag, such as "French" for "fr" or
+// "Portuguese (pt-BR)" for "pt-BR"
+func LanguageName(tag string) string {
+	base, region, _ := strings.Cut(tag, "-")
+	name, ok := languageNames[strings.ToLower(base)]
+	switch {
+	case !ok:
+		return fmt.Sprintf("the language tagged %s", tag)
+	case region != "":
+		return fmt.Sprintf("%s (%s)", name, tag)
+	default:
+		return name
+	}
+}
+
+// ResponseLanguage returns the language the Captain writes in about a goal. A setting of ""
+// or "auto" follows the goal's language; a goal whose language cannot be told, or that is in
+// English, gets no instruction and resolves to "".
+func ResponseLanguage(setting, goal string) string {
+	if setting != "" && setting != LanguageAuto {
+		return setting
+	}
+	if detected := DetectLanguage(goal); detected != defaultLanguage {
+		return detected
+	}
+	return ""
+}
+
+// languageInstruction tells the LLM which language to write prose in while leaving everything
+// that is run or parsed as it is. It is empty when no language is set.
+func languageInstruction(language string) string {
+	if language == "" {
+		return ""
+	}
+	return fmt.Sprintf("## Language:\nWrite all reasoning, descriptions and other prose in %s. Keep commands, file paths, URLs, code, JSON keys and values such as types, priorities and strategies exactly as they are, untranslated.", LanguageName(language))
+}
+
+// languageSetting returns the captain.language setting, "auto" when it is not set
+func (c *Captain) languageSetting() string {
+	if c.config == nil || c.config.Captain.Language == "" {
+		return LanguageAuto
+	}
+	return c.config.Captain.Language
+}
@@ -0,0 +1,110 @@
+package captain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "analyze code quality in ./internal", want: "en"},
+		{text: "analyser la qualité du code dans ./internal", want: "fr"},
+		{text: "despliega el servicio en producción y ejecuta las pruebas", want: "es"},
+		{text: "Bereinige die alten Build-Artefakte im Repository", want: "de"},
+		{text: "verifique os logs do servidor e reinicie a aplicação", want: "pt"},
+		{text: "controlla lo stato del cluster e riavvia il servizio", want: "it"},
+		{text: "проверь логи сервера и перезапусти сервис", want: "ru"},
+		{text: "перевір журнали сервера і перезапусти сервіс", want: "uk"},
+		{text: "リポジトリのテストを実行する", want: "ja"},
+		{text: "运行仓库中的测试", want: "zh"},
+		{text: "저장소의 테스트를 실행", want: "ko"},
+		{text: "make build", want: ""},
+		{text: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectLanguage(tt.text))
+		})
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	assert.Equal(t, "French", LanguageName("fr"))
+	assert.Equal(t, "Portuguese (pt-BR)", LanguageName("pt-BR"))
+	assert.Equal(t, "the language tagged tlh", LanguageName("tlh"))
+}
+
+func TestResponseLanguage(t *testing.T) {
+	assert.Equal(t, "fr", ResponseLanguage("", "nettoyer les anciens artefacts de build"))
+	assert.Equal(t, "fr", ResponseLanguage(LanguageAuto, "nettoyer les anciens artefacts de build"))
+	assert.Empty(t, ResponseLanguage("", "clean up the old build artifacts"), "English goals need no instruction")
+	assert.Empty(t, ResponseLanguage("", "deploy"))
+	assert.Equal(t, "en", ResponseLanguage("en", "nettoyer les anciens artefacts de build"))
+	assert.Equal(t, "de", ResponseLanguage("de", "clean up the old build artifacts"))
+}
+
+func TestPlanningEngine_Language(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	var prompt string
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		prompt = args.Get(1).(CompletionRequest).Messages[0].Content
+	}).Return(&CompletionResponse{Content: `{
+		"tasks": [{"id": "task-1", "type": "execution", "priority": "high", "description": "Supprimer dist/", "dependencies": []}],
+		"strategy": "sequential",
+		"estimated_duration": "5m"
+	}`}, nil)
+	engine := NewPlanningEngine(mockLLM)
+
+	plan, err := engine.CreatePlan(context.Background(), "nettoyer les anciens artefacts de build")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "## Language:\nWrite all reasoning, descriptions and other prose in French. Keep commands")
+	assert.Equal(t, "fr", plan.Metadata[MetadataLanguage])
+
+	plan, err = engine.CreatePlan(context.Background(), "clean up the old build artifacts")
+	require.NoError(t, err)
+	assert.NotContains(t, prompt, "## Language:")
+	assert.NotContains(t, plan.Metadata, MetadataLanguage)
+
+	engine.SetLanguage("es")
+	plan, err = engine.CreatePlan(context.Background(), "clean up the old build artifacts")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "prose in Spanish.")
+	assert.Equal(t, "es", plan.Metadata[MetadataLanguage])
+}
+
+func TestCaptain_Language(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	var prompt string
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		prompt = args.Get(1).(CompletionRequest).Messages[0].Content
+	}).Return(&CompletionResponse{Content: `{"achieved": "Les binaires ont été construits."}`}, nil)
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM, config: &config.Config{}}
+
+	input := reportInput()
+	input.Plan.Metadata = map[string]string{MetadataLanguage: "fr"}
+	_, err := captain.GenerateReport(context.Background(), input)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "prose in French.", "reports follow the plan's language")
+
+	_, err = captain.GenerateReport(context.Background(), reportInput())
+	require.NoError(t, err)
+	assert.NotContains(t, prompt, "## Language:")
+
+	_, err = captain.SummarizeDigest(context.Background(), "✓ task-1 déployer le service")
+	require.NoError(t, err)
+	assert.NotContains(t, prompt, "## Language:", "digests cover many goals, so only a configured language applies")
+
+	captain.config.Captain.Language = "de"
+	_, err = captain.SummarizeDigest(context.Background(), "✓ task-1 deploy")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "prose in German.")
+}
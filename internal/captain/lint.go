@@ -0,0 +1,326 @@
+package captain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// LintSeverity ranks how much a lint finding matters
+type LintSeverity string
+
+const (
+	// LintError marks a problem that keeps the plan from doing what it says
+	LintError LintSeverity = "error"
+	// LintWarning marks a likely problem worth fixing before running the plan
+	LintWarning LintSeverity = "warning"
+	// LintInfo marks something worth knowing about the plan
+	LintInfo LintSeverity = "info"
+)
+
+// Lint checks
+const (
+	LintCheckUnreachable  = "unreachable"
+	LintCheckCriticalPath = "critical-path"
+	LintCheckValidation   = "validation"
+	LintCheckMissingFile  = "missing-file"
+)
+
+// DefaultMaxCriticalPath is how long the chain of steps that must run one after another may
+// take before the linter warns about it
+const DefaultMaxCriticalPath = 30 * time.Minute
+
+// LintFinding is one issue the linter found in a plan
+type LintFinding struct {
+	Severity LintSeverity `json:"severity"`
+	Check    string       `json:"check"`
+	TaskID   string       `json:"task_id,omitempty"`
+	Message  string       `json:"message"`
+}
+
+// String formats the finding for display
+func (f LintFinding) String() string {
+	if f.TaskID == "" {
+		return fmt.Sprintf("%s %s: %s", f.Severity, f.Check, f.Message)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", f.Severity, f.Check, f.TaskID, f.Message)
+}
+
+// LintReport lists the findings for a plan, errors first
+type LintReport struct {
+	PlanID string `json:"plan_id"`
+	// CriticalPath lists the steps of the longest chain that must run one after another
+	CriticalPath         []string      `json:"critical_path,omitempty"`
+	CriticalPathDuration time.Duration `json:"critical_path_duration"`
+	Findings             []LintFinding `json:"findings"`
+}
+
+// Count returns how many findings have the severity
+func (r *LintReport) Count(severity LintSeverity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// LintOptions tunes the checks LintPlan runs
+type LintOptions struct {
+	// Workspace is the directory relative paths in commands are looked up in; empty skips the
+	// missing-file check
+	Workspace string
+	// MaxCriticalPath is how long the critical path may take before it is reported, defaulting
+	// to DefaultMaxCriticalPath
+	MaxCriticalPath time.Duration
+}
+
+// LintPlan checks a plan for issues without running it: steps that can never run, a
+// critical path too long for the plan's max_runtime or the configured limit, execution
+// steps no validation step checks, and commands naming workspace files that do not exist.
+// Unlike ValidatePlan it reports every issue rather than stopping at the first, so it also
+// works on plans that would be rejected.
+func LintPlan(plan *ExecutionPlan, opts LintOptions) *LintReport {
+	if opts.MaxCriticalPath <= 0 {
+		opts.MaxCriticalPath = DefaultMaxCriticalPath
+	}
+	report := &LintReport{PlanID: plan.ID}
+	add := func(severity LintSeverity, check, taskID, format string, args ...any) {
+		report.Findings = append(report.Findings, LintFinding{Severity: severity, Check: check, TaskID: taskID, Message: fmt.Sprintf(format, args...)})
+	}
+
+	never := lintUnreachable(plan, add)
+	lintCriticalPath(plan, never, opts.MaxCriticalPath, report, add)
+	lintValidation(plan, never, add)
+	if opts.Workspace != "" {
+		lintMissingFiles(plan, opts.Workspace, add)
+	}
+
+	rank := map[LintSeverity]int{LintError: 0, LintWarning: 1, LintInfo: 2}
+	slices.SortStableFunc(report.Findings, func(a, b LintFinding) int {
+		return rank[a.Severity] - rank[b.Severity]
+	})
+	return report
+}
+
+// lintUnreachable reports the steps that can never run: those depending on steps missing
+// from the plan, on a dependency cycle or on another step that never runs, and those whose
+// condition reads a step they do not wait for. It returns the IDs of those steps.
+func lintUnreachable(plan *ExecutionPlan, add func(LintSeverity, string, string, string, ...any)) map[string]bool {
+	byID := make(map[string]Task, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		byID[task.ID] = task
+	}
+	never := make(map[string]bool)
+	cycle := FindDependencyCycle(plan.Tasks)
+	onCycle := make(map[string]bool, len(cycle))
+	for _, id := range cycle {
+		onCycle[id] = true
+	}
+
+	// Mark steps with a problem of their own, then everything waiting on them
+	for _, task := range plan.Tasks {
+		for _, dep := range task.Dependencies {
+			if _, ok := byID[dep]; !ok {
+				add(LintError, LintCheckUnreachable, task.ID, "never runs: depends on %s, which is not in the plan", dep)
+				never[task.ID] = true
+			}
+		}
+		if onCycle[task.ID] {
+			add(LintError, LintCheckUnreachable, task.ID, "never runs: on a dependency cycle (%s)", strings.Join(cycle, " -> "))
+			never[task.ID] = true
+		}
+		if task.Condition != nil && !slices.Contains(task.Dependencies, task.Condition.Step) {
+			add(LintError, LintCheckUnreachable, task.ID, "never runs: its condition reads %s, which it does not depend on", task.Condition.Step)
+			never[task.ID] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, task := range plan.Tasks {
+			if never[task.ID] {
+				continue
+			}
+			for _, dep := range task.Dependencies {
+				if never[dep] {
+					add(LintError, LintCheckUnreachable, task.ID, "never runs: depends on %s, which never runs", dep)
+					never[task.ID], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	return never
+}
+
+// lintCriticalPath records the longest chain of steps that must run one after another,
+// reporting it when it cannot finish within the plan's max_runtime or exceeds limit
+func lintCriticalPath(plan *ExecutionPlan, never map[string]bool, limit time.Duration, report *LintReport, add func(LintSeverity, string, string, string, ...any)) {
+	var runnable []Task
+	for _, task := range plan.Tasks {
+		if !never[task.ID] {
+			runnable = append(runnable, task)
+		}
+	}
+	order, err := executionOrder(runnable)
+	if err != nil {
+		return
+	}
+	finish := make(map[string]time.Duration, len(order))
+	previous := make(map[string]string, len(order))
+	var last string
+	for _, task := range order {
+		var start time.Duration
+		for _, dep := range task.Dependencies {
+			if done, ok := finish[dep]; ok && (previous[task.ID] == "" || done > start) {
+				start, previous[task.ID] = done, dep
+			}
+		}
+		finish[task.ID] = start + simulatedDuration(task, plan)
+		if last == "" || finish[task.ID] > finish[last] {
+			last = task.ID
+		}
+	}
+	if last == "" {
+		return
+	}
+	for id := last; id != ""; id = previous[id] {
+		report.CriticalPath = append([]string{id}, report.CriticalPath...)
+	}
+	report.CriticalPathDuration = finish[last]
+
+	path := strings.Join(report.CriticalPath, " -> ")
+	switch {
+	case plan.Timeline.MaxRuntime > 0 && report.CriticalPathDuration > plan.Timeline.MaxRuntime:
+		add(LintError, LintCheckCriticalPath, "", "critical path %s takes an estimated %s, longer than the plan's max_runtime of %s", path, report.CriticalPathDuration, plan.Timeline.MaxRuntime)
+	case report.CriticalPathDuration > limit:
+		add(LintWarning, LintCheckCriticalPath, "", "critical path %s takes an estimated %s, longer than %s; split it into steps that can run in parallel", path, report.CriticalPathDuration, limit)
+	}
+}
+
+// lintValidation reports a plan that changes things without any validation step, and the
+// execution steps no validation step runs after
+func lintValidation(plan *ExecutionPlan, never map[string]bool, add func(LintSeverity, string, string, string, ...any)) {
+	var executions, validations []Task
+	for _, task := range plan.Tasks {
+		switch task.Type {
+		case TaskTypeExecution:
+			executions = append(executions, task)
+		case TaskTypeValidation:
+			if !never[task.ID] {
+				validations = append(validations, task)
+			}
+		}
+	}
+	if len(executions) == 0 {
+		return
+	}
+	if len(validations) == 0 {
+		add(LintWarning, LintCheckValidation, "", "%d execution step(s) but no validation step checks their results", len(executions))
+		return
+	}
+
+	byID := make(map[string]Task, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		byID[task.ID] = task
+	}
+	checked := make(map[string]bool)
+	for _, validation := range validations {
+		queue := slices.Clone(validation.Dependencies)
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if checked[id] {
+				continue
+			}
+			checked[id] = true
+			queue = append(queue, byID[id].Dependencies...)
+		}
+	}
+	for _, task := range executions {
+		if !checked[task.ID] && !never[task.ID] {
+			add(LintInfo, LintCheckValidation, task.ID, "no validation step runs after it")
+		}
+	}
+}
+
+// lintMissingFiles reports relative paths in step commands and paths of steps that read
+// which do not exist in the workspace, unless an earlier step mentions them and so may
+// create them
+func lintMissingFiles(plan *ExecutionPlan, workspace string, add func(LintSeverity, string, string, string, ...any)) {
+	order, err := executionOrder(plan.Tasks)
+	if err != nil {
+		order = plan.Tasks
+	}
+	var mentioned []string
+	for _, task := range order {
+		command, _ := task.Payload["command"].(string)
+		candidates := commandPaths(command)
+		if path, _ := task.Payload["path"].(string); path != "" && task.Type != TaskTypeExecution && !filepath.IsAbs(path) {
+			candidates = append(candidates, path)
+		}
+		dir := workspace
+		if task.Workdir != "" {
+			if filepath.IsAbs(task.Workdir) {
+				dir = task.Workdir
+			} else {
+				dir = filepath.Join(workspace, task.Workdir)
+			}
+		}
+		for _, path := range candidates {
+			if slices.ContainsFunc(mentioned, func(earlier string) bool { return strings.Contains(earlier, path) }) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path))); os.IsNotExist(err) {
+				add(LintWarning, LintCheckMissingFile, task.ID, "%s does not exist in %s", path, dir)
+			}
+		}
+		mentioned = append(mentioned, command)
+		if path, _ := task.Payload["path"].(string); path != "" {
+			mentioned = append(mentioned, path)
+		}
+	}
+}
+
+// commandPaths returns the words of a command that name relative files it reads: those
+// starting with ./ or ../ and those ending in a known file extension. Words using shell
+// expansion, flags, URLs, the targets of redirections and output flags, and the arguments
+// of commands that create files are left out.
+func commandPaths(command string) []string {
+	var paths []string
+	for _, part := range agents.SplitCommands(command) {
+		words := strings.Fields(part)
+		if program, _ := agents.UnwrapCommand(part); len(program) == 0 || slices.Contains(creatingCommands, program[0]) {
+			continue
+		}
+		for i, word := range words {
+			word = strings.Trim(word, `"'()`)
+			if i > 0 && slices.Contains(outputMarkers, words[i-1]) {
+				continue
+			}
+			if word == "" || strings.HasPrefix(word, "-") || strings.HasPrefix(word, "~") || filepath.IsAbs(word) ||
+				strings.ContainsAny(word, "$*?[{`=<>") || strings.Contains(word, "://") || strings.Contains(word, "...") {
+				continue
+			}
+			if strings.HasPrefix(word, "./") || strings.HasPrefix(word, "../") || pathPattern.FindString(word) == word {
+				if !slices.Contains(paths, word) {
+					paths = append(paths, word)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+var (
+	// outputMarkers precede a word naming a file a command writes
+	outputMarkers = []string{">", ">>", "2>", "&>", "-o", "--output", "--out"}
+	// creatingCommands create the files they are given
+	creatingCommands = []string{"mkdir", "touch", "tee"}
+)
@@ -0,0 +1,114 @@
+package captain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintPlan_Clean(t *testing.T) {
+	tasks := dependsOn(nil, []string{"task-1"})
+	tasks[0].Type, tasks[0].Payload = TaskTypeExecution, map[string]any{"command": "go build ./..."}
+	tasks[1].Type, tasks[1].Payload = TaskTypeValidation, map[string]any{"command": "go test ./..."}
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: tasks, Timeline: ExecutionTimeline{EstimatedDuration: 10 * time.Minute}}
+
+	report := LintPlan(plan, LintOptions{Workspace: t.TempDir()})
+	assert.Empty(t, report.Findings)
+	assert.Equal(t, []string{"task-1", "task-2"}, report.CriticalPath)
+	assert.Equal(t, 10*time.Minute, report.CriticalPathDuration)
+}
+
+func TestLintPlan_Unreachable(t *testing.T) {
+	tasks := dependsOn([]string{"task-9"}, []string{"task-1"}, []string{"task-4"}, []string{"task-3"}, nil, []string{"task-5"})
+	tasks[5].Condition = &Condition{Step: "task-2", Matches: "ok"}
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: tasks}
+
+	report := LintPlan(plan, LintOptions{})
+	var messages []string
+	for _, finding := range report.Findings {
+		require.Equal(t, LintError, finding.Severity)
+		messages = append(messages, finding.TaskID+": "+finding.Message)
+	}
+	assert.Equal(t, []string{
+		"task-1: never runs: depends on task-9, which is not in the plan",
+		"task-3: never runs: on a dependency cycle (task-3 -> task-4 -> task-3)",
+		"task-4: never runs: on a dependency cycle (task-3 -> task-4 -> task-3)",
+		"task-6: never runs: its condition reads task-2, which it does not depend on",
+		"task-2: never runs: depends on task-1, which never runs",
+	}, messages)
+	assert.Equal(t, []string{"task-5"}, report.CriticalPath, "steps that never run are not on the critical path")
+}
+
+func TestLintPlan_CriticalPath(t *testing.T) {
+	tasks := dependsOn(nil, []string{"task-1"}, []string{"task-1"}, []string{"task-2", "task-3"})
+	for i, estimate := range []string{"10m", "20m", "5m", "10m"} {
+		tasks[i].Metadata = map[string]string{"estimated_duration": estimate}
+	}
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: tasks}
+
+	report := LintPlan(plan, LintOptions{MaxCriticalPath: 30 * time.Minute})
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, LintFinding{Severity: LintWarning, Check: LintCheckCriticalPath,
+		Message: "critical path task-1 -> task-2 -> task-4 takes an estimated 40m0s, longer than 30m0s; split it into steps that can run in parallel"}, report.Findings[0])
+
+	plan.Timeline.MaxRuntime = 30 * time.Minute
+	report = LintPlan(plan, LintOptions{MaxCriticalPath: time.Hour})
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, LintError, report.Findings[0].Severity)
+	assert.Contains(t, report.Findings[0].Message, "longer than the plan's max_runtime of 30m0s")
+}
+
+func TestLintPlan_Validation(t *testing.T) {
+	tasks := dependsOn(nil, nil, []string{"task-1"})
+	tasks[0].Type, tasks[1].Type, tasks[2].Type = TaskTypeExecution, TaskTypeExecution, TaskTypeExecution
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: tasks}
+
+	report := LintPlan(plan, LintOptions{})
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, LintFinding{Severity: LintWarning, Check: LintCheckValidation,
+		Message: "3 execution step(s) but no validation step checks their results"}, report.Findings[0])
+
+	plan.Tasks = append(plan.Tasks, Task{ID: "task-4", Type: TaskTypeValidation, Dependencies: []string{"task-3"}})
+	report = LintPlan(plan, LintOptions{})
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, LintFinding{Severity: LintInfo, Check: LintCheckValidation, TaskID: "task-2",
+		Message: "no validation step runs after it"}, report.Findings[0])
+}
+
+func TestLintPlan_MissingFiles(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "scripts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "scripts", "build.sh"), nil, 0o755))
+
+	tasks := dependsOn(nil, []string{"task-1"}, []string{"task-2"}, nil)
+	tasks[0].Payload = map[string]any{"command": "./scripts/build.sh -o ./bin/app && ./scripts/deploy.sh"}
+	tasks[1].Payload = map[string]any{"command": `./bin/app --config "config.yaml" https://example.com/a.json $OUT/log.txt`}
+	tasks[2].Type, tasks[2].Payload = TaskTypeAnalysis, map[string]any{"path": "docs/README.md"}
+	tasks[3].Workdir, tasks[3].Payload = "scripts", map[string]any{"command": "sh build.sh && go test ./..."}
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: tasks}
+
+	report := LintPlan(plan, LintOptions{Workspace: workspace})
+	var messages []string
+	for _, finding := range report.Findings {
+		if finding.Check == LintCheckMissingFile {
+			assert.Equal(t, LintWarning, finding.Severity)
+			messages = append(messages, finding.TaskID+": "+finding.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		"task-1: ./scripts/deploy.sh does not exist in " + workspace,
+		"task-2: config.yaml does not exist in " + workspace,
+		"task-3: docs/README.md does not exist in " + workspace,
+	}, messages, "./bin/app is written by task-1 and task-2 uses it after")
+}
+
+func TestCommandPaths(t *testing.T) {
+	assert.Equal(t, []string{"./run.sh", "../shared/env.sh", "go.mod"},
+		commandPaths(`./run.sh --flag=1 ../shared/env.sh; cat go.mod | grep -v origin/main ~/x.txt /etc/hosts "${HOME}/a.txt"`))
+	assert.Empty(t, commandPaths("go test ./... && make"))
+	assert.Empty(t, commandPaths("go build -o ./bin/app . > build.log && mkdir -p ./out/reports"), "files the command writes")
+}
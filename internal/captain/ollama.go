@@ -0,0 +1,142 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/transport"
+)
+
+// OllamaConfig holds configuration for the Ollama provider
+type OllamaConfig struct {
+	Model       string  `yaml:"model"`
+	BaseURL     string  `yaml:"base_url,omitempty"`
+	Temperature float64 `yaml:"temperature"`
+	// HTTP sets the proxies and certificate authorities used to reach the server
+	HTTP config.HTTPConfig `yaml:"http,omitempty"`
+}
+
+// Validate validates the Ollama configuration
+func (c *OllamaConfig) Validate() error {
+	validator := common.NewValidator()
+	validator.AddRule("model", common.Required("model"))
+	validator.AddRule("base_url", common.Required("base_url"))
+
+	return validator.Validate(map[string]interface{}{
+		"model":    c.Model,
+		"base_url": c.BaseURL,
+	})
+}
+
+// OllamaProvider implements LLMProvider using a local or remote Ollama server
+type OllamaProvider struct {
+	config OllamaConfig
+	client *http.Client
+}
+
+// NewOllamaProvider creates a new Ollama provider, defaulting to a server on localhost
+func NewOllamaProvider(config OllamaConfig) (*OllamaProvider, error) {
+	validatedConfig, err := common.NewConfigBuilder(config).
+		With(func(c *OllamaConfig) {
+			if c.BaseURL == "" {
+				c.BaseURL = "http://localhost:11434"
+			}
+			c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+		}).
+		Validate(func(c OllamaConfig) error {
+			return c.Validate()
+		}).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ollama config: %w", err)
+	}
+
+	client, err := transport.NewHTTPClient(validatedConfig.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ollama config: http: %w", err)
+	}
+	return &OllamaProvider{config: validatedConfig, client: client}, nil
+}
+
+// ollamaChatRequest is the /api/chat request body
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+// ollamaChatResponse is the /api/chat response body
+type ollamaChatResponse struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+}
+
+// GenerateCompletion generates a completion using Ollama's chat API
+func (p *OllamaProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid completion request: %w", err)
+	}
+
+	body := ollamaChatRequest{
+		Model:    p.config.Model,
+		Messages: req.Messages,
+		Options:  map[string]any{"temperature": req.Temperature, "num_predict": req.MaxTokens},
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+
+	var resp ollamaChatResponse
+	if err := postJSON(ctx, p.client, p.config.BaseURL+"/api/chat", nil, body, &resp); err != nil {
+		return nil, fmt.Errorf("ollama completion: %w", err)
+	}
+	return &CompletionResponse{
+		Content:      resp.Message.Content,
+		TokensUsed:   resp.PromptEvalCount + resp.EvalCount,
+		Model:        resp.Model,
+		FinishReason: resp.DoneReason,
+	}, nil
+}
+
+// GenerateEmbedding generates an embedding using Ollama's embeddings API
+func (p *OllamaProvider) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+
+	body := map[string]string{"model": p.config.Model, "prompt": text}
+	var resp struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := postJSON(ctx, p.client, p.config.BaseURL+"/api/embeddings", nil, body, &resp); err != nil {
+		return nil, fmt.Errorf("ollama embedding: %w", err)
+	}
+	return resp.Embedding, nil
+}
+
+// Ping lists the models pulled on the server and checks the configured model is among them
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getJSON(ctx, p.client, p.config.BaseURL+"/api/tags", nil, &resp); err != nil {
+		return fmt.Errorf("ollama ping: %w", err)
+	}
+	for _, model := range resp.Models {
+		// Models pulled without a tag are listed as "name:latest"
+		if model.Name == p.config.Model || model.Name == p.config.Model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("ollama ping: %w: %s has not been pulled", ErrModelUnavailable, p.config.Model)
+}
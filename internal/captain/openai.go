@@ -3,8 +3,12 @@ package captain
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/transport"
 )
 
 // OpenAIConfig holds configuration for the OpenAI provider
@@ -14,6 +18,8 @@ type OpenAIConfig struct {
 	BaseURL     string  `yaml:"base_url,omitempty"`
 	MaxRetries  int     `yaml:"max_retries"`
 	Temperature float64 `yaml:"temperature"`
+	// HTTP sets the proxies and certificate authorities used to reach the API
+	HTTP config.HTTPConfig `yaml:"http,omitempty"`
 }
 
 // Validate validates the OpenAI configuration using the validation framework
@@ -36,7 +42,8 @@ func (c *OpenAIConfig) Validate() error {
 // TODO: Update to work with the official openai-go library
 type OpenAIProvider struct {
 	// client *openai.Client  // Commented out until API compatibility is resolved
-	config OpenAIConfig
+	config     OpenAIConfig
+	httpClient *http.Client
 }
 
 // NewOpenAIProvider creates a new OpenAI provider using the configuration builder pattern
@@ -64,9 +71,15 @@ func NewOpenAIProvider(config OpenAIConfig) (*OpenAIProvider, error) {
 		return nil, fmt.Errorf("invalid OpenAI config: %w", err)
 	}
 
+	httpClient, err := transport.NewHTTPClient(validatedConfig.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAI config: http: %w", err)
+	}
+
 	// TODO: Create actual OpenAI client when API compatibility is resolved
 	return &OpenAIProvider{
-		config: validatedConfig,
+		config:     validatedConfig,
+		httpClient: httpClient,
 	}, nil
 }
 
@@ -89,3 +102,18 @@ func (p *OpenAIProvider) GenerateEmbedding(ctx context.Context, text string) ([]
 	// TODO: Implement actual OpenAI API call when library compatibility is resolved
 	return nil, fmt.Errorf("OpenAI embedding provider not yet implemented with official openai-go library")
 }
+
+// Ping lists the available models, which checks the API key without spending tokens
+func (p *OpenAIProvider) Ping(ctx context.Context) error {
+	headers := map[string]string{"Authorization": "Bearer " + p.config.APIKey}
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	url := strings.TrimSuffix(p.config.BaseURL, "/") + "/models"
+	if err := getJSON(ctx, p.httpClient, url, headers, &resp); err != nil {
+		return fmt.Errorf("openai ping: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package captain
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MetadataOptimization is the plan metadata key holding a summary of how an optimized plan
+// differs from the plan it was made from
+const MetadataOptimization = "optimization"
+
+// OptimizationStrategy selects what OptimizePlan optimizes for
+type OptimizationStrategy string
+
+const (
+	// OptimizeForCost merges duplicate steps and drops redundant dependencies, so fewer steps run
+	OptimizeForCost OptimizationStrategy = "cost"
+	// OptimizeForParallelism also runs a sequential plan in parallel, so independent steps
+	// start together
+	OptimizeForParallelism OptimizationStrategy = "parallelism"
+	// OptimizeForSafety never removes validation steps and keeps the plan's strategy
+	OptimizeForSafety OptimizationStrategy = "safety"
+)
+
+// OptimizationOptions controls how a plan is optimized
+type OptimizationOptions struct {
+	// Strategy is what to optimize for; empty means OptimizeForCost
+	Strategy OptimizationStrategy
+}
+
+// OptimizePlan returns a copy of the plan that does the same work with less: steps that repeat
+// an earlier step's command in the same environment after the same dependencies are merged
+// into it, and dependencies already implied through another dependency are dropped. The plan's
+// estimate is reduced by the share of the merged steps. The strategy adjusts this: safety keeps
+// every validation step and parallelism also makes a sequential plan parallel. The original
+// plan is not modified.
+func OptimizePlan(plan *ExecutionPlan, opts OptimizationOptions) (*ExecutionPlan, error) {
+	switch opts.Strategy {
+	case "", OptimizeForCost, OptimizeForParallelism, OptimizeForSafety:
+	default:
+		return nil, fmt.Errorf("unknown optimization strategy %q", opts.Strategy)
+	}
+	if _, err := executionOrder(plan.Tasks); err != nil {
+		return nil, err
+	}
+	optimized := *plan
+	optimized.Metadata = make(map[string]string, len(plan.Metadata)+1)
+	for k, v := range plan.Metadata {
+		optimized.Metadata[k] = v
+	}
+	if opts.Strategy == OptimizeForParallelism && plan.Strategy.Type == StrategySequential {
+		optimized.Strategy.Type = StrategyParallel
+	}
+
+	// Merge duplicate steps, pointing dependents of a merged step at the step it repeats
+	merged := make(map[string]string)
+	tasks := make([]Task, 0, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		task.Dependencies = resolveMerged(task.Dependencies, merged)
+		if opts.Strategy == OptimizeForSafety && task.Type == TaskTypeValidation {
+			tasks = append(tasks, task)
+			continue
+		}
+		if kept := findDuplicate(tasks, task); kept != "" {
+			merged[task.ID] = kept
+			optimized.Timeline.EstimatedDuration -= simulatedDuration(task, plan)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	if optimized.Timeline.EstimatedDuration < 0 {
+		optimized.Timeline.EstimatedDuration = 0
+	}
+
+	// Drop dependencies reachable through another dependency of the same step
+	ancestors := make(map[string]map[string]bool, len(tasks))
+	order, err := executionOrder(tasks)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range order {
+		reached := make(map[string]bool)
+		for _, dep := range task.Dependencies {
+			reached[dep] = true
+			for ancestor := range ancestors[dep] {
+				reached[ancestor] = true
+			}
+		}
+		ancestors[task.ID] = reached
+	}
+	for i, task := range tasks {
+		var deps []string
+		for _, dep := range task.Dependencies {
+			if !impliedBy(dep, task.Dependencies, ancestors) {
+				deps = append(deps, dep)
+			}
+		}
+		tasks[i].Dependencies = deps
+	}
+	optimized.Tasks = tasks
+	return &optimized, nil
+}
+
+// resolveMerged replaces merged steps in a dependency list with the steps they were merged
+// into, keeping the first occurrence of each
+func resolveMerged(deps []string, merged map[string]string) []string {
+	if len(deps) == 0 {
+		return nil
+	}
+	resolved := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		if kept, ok := merged[dep]; ok {
+			dep = kept
+		}
+		if !slices.Contains(resolved, dep) {
+			resolved = append(resolved, dep)
+		}
+	}
+	return resolved
+}
+
+// findDuplicate returns the ID of an earlier step that runs the same command as task in the
+// same environment after the same dependencies, or "" when there is none. Steps without a
+// command are never duplicates.
+func findDuplicate(tasks []Task, task Task) string {
+	command, _ := task.Payload["command"].(string)
+	if command == "" {
+		return ""
+	}
+	for _, other := range tasks {
+		if c, _ := other.Payload["command"].(string); c != command || other.Type != task.Type {
+			continue
+		}
+		if other.Workdir != task.Workdir || other.Shell != task.Shell || other.Execution != task.Execution ||
+			!reflect.DeepEqual(other.Env, task.Env) || !sameDependencies(other.Dependencies, task.Dependencies) {
+			continue
+		}
+		return other.ID
+	}
+	return ""
+}
+
+func sameDependencies(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, dep := range a {
+		if !slices.Contains(b, dep) {
+			return false
+		}
+	}
+	return true
+}
+
+// impliedBy reports whether dep is an ancestor of another of the dependencies
+func impliedBy(dep string, deps []string, ancestors map[string]map[string]bool) bool {
+	for _, other := range deps {
+		if other != dep && ancestors[other][dep] {
+			return true
+		}
+	}
+	return false
+}
+
+// DependencyChange is a dependency added to or removed from a step
+type DependencyChange struct {
+	TaskID     string
+	Dependency string
+}
+
+// CommandChange is a step whose command differs between two plans
+type CommandChange struct {
+	TaskID string
+	Before string
+	After  string
+}
+
+// PlanDiff describes how an optimized plan differs from the plan it was made from
+type PlanDiff struct {
+	OriginalTasks  int
+	OptimizedTasks int
+	// RemovedTasks and AddedTasks list step IDs present in only one of the plans
+	RemovedTasks        []string
+	AddedTasks          []string
+	RemovedDependencies []DependencyChange
+	AddedDependencies   []DependencyChange
+	ChangedCommands     []CommandChange
+	// OriginalStrategy and OptimizedStrategy are the plans' execution strategies
+	OriginalStrategy  StrategyType
+	OptimizedStrategy StrategyType
+
+	// OriginalEstimate and OptimizedEstimate are the plans' own estimates; OriginalDuration and
+	// OptimizedDuration are how long each takes when simulated
+	OriginalEstimate  time.Duration
+	OptimizedEstimate time.Duration
+	OriginalDuration  time.Duration
+	OptimizedDuration time.Duration
+}
+
+// ComparePlans reports how the optimized plan differs from the original: which steps,
+// dependencies and commands changed, and how the estimated and simulated durations compare
+func ComparePlans(original, optimized *ExecutionPlan, opts SimulationOptions) (*PlanDiff, error) {
+	before, err := Simulate(original, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate original plan: %w", err)
+	}
+	after, err := Simulate(optimized, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate optimized plan: %w", err)
+	}
+	diff := &PlanDiff{
+		OriginalTasks:     len(original.Tasks),
+		OptimizedTasks:    len(optimized.Tasks),
+		OriginalStrategy:  original.Strategy.Type,
+		OptimizedStrategy: optimized.Strategy.Type,
+		OriginalEstimate:  original.Timeline.EstimatedDuration,
+		OptimizedEstimate: optimized.Timeline.EstimatedDuration,
+		OriginalDuration:  before.Duration,
+		OptimizedDuration: after.Duration,
+	}
+
+	kept := make(map[string]Task, len(optimized.Tasks))
+	for _, task := range optimized.Tasks {
+		kept[task.ID] = task
+	}
+	previous := make(map[string]bool, len(original.Tasks))
+	for _, task := range original.Tasks {
+		previous[task.ID] = true
+		now, ok := kept[task.ID]
+		if !ok {
+			diff.RemovedTasks = append(diff.RemovedTasks, task.ID)
+			continue
+		}
+		for _, dep := range task.Dependencies {
+			if !slices.Contains(now.Dependencies, dep) {
+				diff.RemovedDependencies = append(diff.RemovedDependencies, DependencyChange{TaskID: task.ID, Dependency: dep})
+			}
+		}
+		for _, dep := range now.Dependencies {
+			if !slices.Contains(task.Dependencies, dep) {
+				diff.AddedDependencies = append(diff.AddedDependencies, DependencyChange{TaskID: task.ID, Dependency: dep})
+			}
+		}
+		beforeCommand, _ := task.Payload["command"].(string)
+		afterCommand, _ := now.Payload["command"].(string)
+		if beforeCommand != afterCommand {
+			diff.ChangedCommands = append(diff.ChangedCommands, CommandChange{TaskID: task.ID, Before: beforeCommand, After: afterCommand})
+		}
+	}
+	for _, task := range optimized.Tasks {
+		if !previous[task.ID] {
+			diff.AddedTasks = append(diff.AddedTasks, task.ID)
+		}
+	}
+	return diff, nil
+}
+
+// Changed reports whether the plans differ in their steps, dependencies, commands or strategy
+func (d *PlanDiff) Changed() bool {
+	return len(d.RemovedTasks)+len(d.AddedTasks)+len(d.RemovedDependencies)+len(d.AddedDependencies)+len(d.ChangedCommands) > 0 ||
+		d.OriginalStrategy != d.OptimizedStrategy
+}
+
+// Summary renders a one-line summary of the differences, as stored in plan metadata
+func (d *PlanDiff) Summary() string {
+	if !d.Changed() {
+		return "no changes"
+	}
+	parts := []string{fmt.Sprintf("tasks %d -> %d", d.OriginalTasks, d.OptimizedTasks)}
+	if n := len(d.RemovedDependencies); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d dependencies removed", n))
+	}
+	if n := len(d.AddedDependencies); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d dependencies added", n))
+	}
+	if n := len(d.ChangedCommands); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d commands changed", n))
+	}
+	if d.OriginalStrategy != d.OptimizedStrategy {
+		parts = append(parts, fmt.Sprintf("strategy %s -> %s", d.OriginalStrategy, d.OptimizedStrategy))
+	}
+	parts = append(parts,
+		fmt.Sprintf("estimate %s -> %s", d.OriginalEstimate, d.OptimizedEstimate),
+		fmt.Sprintf("simulated %s -> %s", d.OriginalDuration, d.OptimizedDuration))
+	return strings.Join(parts, ", ")
+}
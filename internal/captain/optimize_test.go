@@ -0,0 +1,115 @@
+package captain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// optimizablePlan runs the tests twice after the build, and the report depends on the build
+// both directly and through the tests
+func optimizablePlan() *ExecutionPlan {
+	tasks := dependsOn(nil, []string{"task-1"}, []string{"task-1"}, []string{"task-1", "task-2", "task-3"})
+	tasks[0].Payload = map[string]any{"command": "go build ./..."}
+	tasks[1].Payload = map[string]any{"command": "go test ./..."}
+	tasks[2].Payload = map[string]any{"command": "go test ./..."}
+	return &ExecutionPlan{
+		ID:       "plan-1",
+		Tasks:    tasks,
+		Timeline: ExecutionTimeline{EstimatedDuration: 20 * time.Minute},
+		Strategy: ExecutionStrategy{Type: StrategySequential},
+		Metadata: map[string]string{MetadataProvider: "openai"},
+	}
+}
+
+func TestOptimizePlan(t *testing.T) {
+	plan := optimizablePlan()
+	optimized, err := OptimizePlan(plan, OptimizationOptions{})
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(optimized.Tasks))
+	for _, task := range optimized.Tasks {
+		ids = append(ids, task.ID)
+	}
+	assert.Equal(t, []string{"task-1", "task-2", "task-4"}, ids, "task-3 repeats task-2")
+	assert.Equal(t, []string{"task-2"}, optimized.Tasks[2].Dependencies, "task-1 is implied through task-2")
+	assert.Equal(t, 15*time.Minute, optimized.Timeline.EstimatedDuration)
+	assert.Equal(t, "openai", optimized.Metadata[MetadataProvider])
+
+	assert.Len(t, plan.Tasks, 4, "the original plan is unchanged")
+	assert.Equal(t, []string{"task-1", "task-2", "task-3"}, plan.Tasks[3].Dependencies)
+}
+
+func TestOptimizePlan_KeepsDistinctSteps(t *testing.T) {
+	plan := optimizablePlan()
+	plan.Tasks[2].Workdir = "./sdk"
+	plan.Tasks[3].Dependencies = []string{"task-2", "task-3"}
+	optimized, err := OptimizePlan(plan, OptimizationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, plan.Tasks, optimized.Tasks)
+}
+
+func TestOptimizePlan_Strategies(t *testing.T) {
+	plan := optimizablePlan()
+	plan.Tasks[1].Type = TaskTypeValidation
+	plan.Tasks[2].Type = TaskTypeValidation
+
+	tests := []struct {
+		strategy OptimizationStrategy
+		tasks    int
+		type_    StrategyType
+	}{
+		{strategy: OptimizeForCost, tasks: 3, type_: StrategySequential},
+		{strategy: OptimizeForParallelism, tasks: 3, type_: StrategyParallel},
+		{strategy: OptimizeForSafety, tasks: 4, type_: StrategySequential},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			optimized, err := OptimizePlan(plan, OptimizationOptions{Strategy: tt.strategy})
+			require.NoError(t, err)
+			assert.Len(t, optimized.Tasks, tt.tasks)
+			assert.Equal(t, tt.type_, optimized.Strategy.Type)
+			assert.NotContains(t, optimized.Tasks[len(optimized.Tasks)-1].Dependencies, "task-1", "redundant dependencies are dropped")
+		})
+	}
+
+	_, err := OptimizePlan(plan, OptimizationOptions{Strategy: "speed"})
+	assert.EqualError(t, err, `unknown optimization strategy "speed"`)
+}
+
+func TestOptimizePlan_Cycle(t *testing.T) {
+	_, err := OptimizePlan(&ExecutionPlan{Tasks: dependsOn([]string{"task-2"}, []string{"task-1"})}, OptimizationOptions{})
+	var cycle *CycleError
+	assert.ErrorAs(t, err, &cycle)
+}
+
+func TestComparePlans(t *testing.T) {
+	plan := optimizablePlan()
+	optimized, err := OptimizePlan(plan, OptimizationOptions{})
+	require.NoError(t, err)
+	optimized.Tasks[0].Payload = map[string]any{"command": "go build -race ./..."}
+
+	diff, err := ComparePlans(plan, optimized, SimulationOptions{MaxParallel: 2})
+	require.NoError(t, err)
+	assert.True(t, diff.Changed())
+	assert.Equal(t, 4, diff.OriginalTasks)
+	assert.Equal(t, 3, diff.OptimizedTasks)
+	assert.Equal(t, []string{"task-3"}, diff.RemovedTasks)
+	assert.Empty(t, diff.AddedTasks)
+	assert.Equal(t, []DependencyChange{
+		{TaskID: "task-4", Dependency: "task-1"},
+		{TaskID: "task-4", Dependency: "task-3"},
+	}, diff.RemovedDependencies)
+	assert.Empty(t, diff.AddedDependencies)
+	assert.Equal(t, []CommandChange{{TaskID: "task-1", Before: "go build ./...", After: "go build -race ./..."}}, diff.ChangedCommands)
+	assert.Equal(t, 20*time.Minute, diff.OriginalDuration)
+	assert.Equal(t, 15*time.Minute, diff.OptimizedDuration)
+	assert.Equal(t, "tasks 4 -> 3, 2 dependencies removed, 1 commands changed, estimate 20m0s -> 15m0s, simulated 20m0s -> 15m0s", diff.Summary())
+
+	same, err := ComparePlans(plan, plan, SimulationOptions{})
+	require.NoError(t, err)
+	assert.False(t, same.Changed())
+	assert.Equal(t, "no changes", same.Summary())
+}
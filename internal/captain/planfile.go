@@ -0,0 +1,141 @@
+package captain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// PlanFormat is a file format plans can be exported to
+type PlanFormat string
+
+const (
+	PlanFormatYAML PlanFormat = "yaml"
+	PlanFormatJSON PlanFormat = "json"
+	PlanFormatDOT  PlanFormat = "dot"
+)
+
+// PlanFormatForPath infers a plan file's format from its extension, defaulting to YAML
+func PlanFormatForPath(path string) PlanFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return PlanFormatJSON
+	case ".dot", ".gv":
+		return PlanFormatDOT
+	default:
+		return PlanFormatYAML
+	}
+}
+
+// ExportPlan encodes a plan in the given format. DOT output renders the dependency graph only.
+func ExportPlan(plan *ExecutionPlan, format PlanFormat) ([]byte, error) {
+	switch format {
+	case PlanFormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(plan); err != nil {
+			return nil, fmt.Errorf("failed to encode plan: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode plan: %w", err)
+		}
+		return buf.Bytes(), nil
+	case PlanFormatJSON:
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode plan: %w", err)
+		}
+		return append(data, '\n'), nil
+	case PlanFormatDOT:
+		return []byte(PlanDOT(plan)), nil
+	default:
+		return nil, fmt.Errorf("unsupported plan format: %s", format)
+	}
+}
+
+// ImportPlan decodes a YAML or JSON plan, such as one written by hand or exported earlier.
+// Unknown fields are rejected, a missing plan ID is generated and steps without a priority get medium.
+func ImportPlan(data []byte, format PlanFormat) (*ExecutionPlan, error) {
+	plan := &ExecutionPlan{}
+	switch format {
+	case PlanFormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(plan); err != nil {
+			return nil, fmt.Errorf("failed to parse plan: %w", err)
+		}
+	case PlanFormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(plan); err != nil {
+			return nil, fmt.Errorf("failed to parse plan: %w", err)
+		}
+	case PlanFormatDOT:
+		return nil, fmt.Errorf("dot plans cannot be imported; use yaml or json")
+	default:
+		return nil, fmt.Errorf("unsupported plan format: %s", format)
+	}
+
+	if plan.ID == "" {
+		plan.ID = uuid.New().String()
+	}
+	if plan.Strategy.Type == "" {
+		plan.Strategy.Type = StrategySequential
+	}
+	for i := range plan.Tasks {
+		if plan.Tasks[i].Priority == "" {
+			plan.Tasks[i].Priority = PriorityMedium
+		}
+	}
+	return plan, nil
+}
+
+// PlanDOT renders the plan's dependency DAG in Graphviz DOT, with an edge from each step to the steps depending on it.
+// Edges a condition reads are dashed and labelled with its pattern, and manually gated steps are octagons.
+func PlanDOT(plan *ExecutionPlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(plan.ID))
+	fmt.Fprintf(&b, "  label=%s;\n", strconv.Quote(plan.Goal))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, task := range plan.Tasks {
+		label := fmt.Sprintf("%s\n[%s]", task.ID, task.Type)
+		if description, _ := task.Payload["description"].(string); description != "" {
+			label += "\n" + description
+		}
+		attrs := "label=" + strconv.Quote(label)
+		if risk := AssessRisk(task); risk.Level != RiskLow {
+			attrs += fmt.Sprintf(`, color=%s`, riskColor(risk.Level))
+		}
+		if task.Gate == GateManual {
+			attrs += ", shape=octagon"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", strconv.Quote(task.ID), attrs)
+	}
+	for _, task := range plan.Tasks {
+		for _, dep := range task.Dependencies {
+			if task.Condition != nil && task.Condition.Step == dep {
+				fmt.Fprintf(&b, "  %s -> %s [style=dashed, label=%s];\n", strconv.Quote(dep), strconv.Quote(task.ID), strconv.Quote(task.Condition.Matches))
+				continue
+			}
+			fmt.Fprintf(&b, "  %s -> %s;\n", strconv.Quote(dep), strconv.Quote(task.ID))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// riskColor returns the Graphviz color used to outline steps of a risk level
+func riskColor(level RiskLevel) string {
+	if level == RiskHigh {
+		return "red"
+	}
+	return "orange"
+}
@@ -0,0 +1,101 @@
+package captain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportablePlan() *ExecutionPlan {
+	return &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "clean up the build",
+		Tasks: []Task{
+			{ID: "task-1", Type: TaskTypeAnalysis, Priority: PriorityHigh, Payload: map[string]any{"description": "Find stale artifacts"}},
+			{ID: "task-2", Type: TaskTypeExecution, Priority: PriorityMedium, Dependencies: []string{"task-1"},
+				Payload: map[string]any{"description": "Remove them", "command": "rm -rf build/"}},
+		},
+		Timeline:  ExecutionTimeline{EstimatedDuration: 5 * time.Minute},
+		Resources: ResourceAllocation{MaxAgents: 2},
+		Strategy:  ExecutionStrategy{Type: StrategySequential},
+	}
+}
+
+func TestPlanFormatForPath(t *testing.T) {
+	assert.Equal(t, PlanFormatYAML, PlanFormatForPath("plan.yaml"))
+	assert.Equal(t, PlanFormatYAML, PlanFormatForPath("plan"))
+	assert.Equal(t, PlanFormatJSON, PlanFormatForPath("PLAN.JSON"))
+	assert.Equal(t, PlanFormatDOT, PlanFormatForPath("plan.gv"))
+}
+
+func TestExportImportPlan_RoundTrip(t *testing.T) {
+	for _, format := range []PlanFormat{PlanFormatYAML, PlanFormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := ExportPlan(exportablePlan(), format)
+			require.NoError(t, err)
+
+			plan, err := ImportPlan(data, format)
+			require.NoError(t, err)
+			assert.Equal(t, exportablePlan(), plan)
+		})
+	}
+}
+
+func TestExportPlan_YAMLIsReadable(t *testing.T) {
+	data, err := ExportPlan(exportablePlan(), PlanFormatYAML)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "estimated_duration: 5m0s")
+	assert.NotContains(t, string(data), "deadline", "zero times are omitted")
+}
+
+func TestImportPlan_HandWritten(t *testing.T) {
+	plan, err := ImportPlan([]byte(`
+goal: tidy up
+tasks:
+  - id: scan
+    type: analysis
+    payload:
+      description: List old files
+  - id: report
+    type: reporting
+    dependencies: [scan]
+`), PlanFormatYAML)
+	require.NoError(t, err)
+	assert.NotEmpty(t, plan.ID, "a plan ID is generated")
+	assert.Equal(t, StrategySequential, plan.Strategy.Type)
+	require.Len(t, plan.Tasks, 2)
+	assert.Equal(t, PriorityMedium, plan.Tasks[0].Priority)
+	assert.Equal(t, []string{"scan"}, plan.Tasks[1].Dependencies)
+}
+
+func TestImportPlan_Errors(t *testing.T) {
+	_, err := ImportPlan([]byte("goal: x\ntaks: []\n"), PlanFormatYAML)
+	assert.ErrorContains(t, err, "field taks not found")
+
+	_, err = ImportPlan([]byte(`{"goal": "x", "taks": []}`), PlanFormatJSON)
+	assert.ErrorContains(t, err, `unknown field "taks"`)
+
+	_, err = ImportPlan([]byte("digraph {}"), PlanFormatDOT)
+	assert.EqualError(t, err, "dot plans cannot be imported; use yaml or json")
+}
+
+func TestPlanDOT(t *testing.T) {
+	dot := PlanDOT(exportablePlan())
+	assert.Contains(t, dot, `digraph "plan-1" {`)
+	assert.Contains(t, dot, `label="clean up the build";`)
+	assert.Contains(t, dot, `"task-1" [label="task-1\n[analysis]\nFind stale artifacts"];`)
+	assert.Contains(t, dot, `"task-2" [label="task-2\n[execution]\nRemove them", color=red];`, "risky steps are outlined")
+	assert.Contains(t, dot, `"task-1" -> "task-2";`)
+
+	_, err := ExportPlan(exportablePlan(), "xml")
+	assert.EqualError(t, err, "unsupported plan format: xml")
+
+	branching := exportablePlan()
+	branching.Tasks[1].Condition = &Condition{Step: "task-1", Matches: "stale"}
+	branching.Tasks[1].Gate = GateManual
+	dot = PlanDOT(branching)
+	assert.Contains(t, dot, `color=red, shape=octagon];`, "gated steps stand out")
+	assert.Contains(t, dot, `"task-1" -> "task-2" [style=dashed, label="stale"];`)
+}
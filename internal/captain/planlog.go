@@ -0,0 +1,108 @@
+package captain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PlannerEventKind is the step of planning a goal that a planner event records
+type PlannerEventKind string
+
+const (
+	// PlannerEventPrompt records the messages sent to the LLM
+	PlannerEventPrompt PlannerEventKind = "prompt"
+	// PlannerEventRetry records a provider that failed before a later one in the chain answered
+	PlannerEventRetry PlannerEventKind = "retry"
+	// PlannerEventResponse records the LLM's raw response, or why the request failed
+	PlannerEventResponse PlannerEventKind = "response"
+	// PlannerEventExtraction records the JSON extracted from the response, or why it did not parse
+	PlannerEventExtraction PlannerEventKind = "extraction"
+	// PlannerEventValidation records whether the plan was accepted and why not
+	PlannerEventValidation PlannerEventKind = "validation"
+	// PlannerEventOptimization records the changes optimizing the plan made
+	PlannerEventOptimization PlannerEventKind = "optimization"
+)
+
+// PlannerEventKinds lists the kinds of planner event in the order planning produces them
+var PlannerEventKinds = []PlannerEventKind{
+	PlannerEventPrompt, PlannerEventRetry, PlannerEventResponse, PlannerEventExtraction,
+	PlannerEventValidation, PlannerEventOptimization,
+}
+
+// PlannerEvent is an intermediate artifact of planning a goal, kept so prompts can be tuned
+// and rejected plans understood
+type PlannerEvent struct {
+	Kind  PlannerEventKind `json:"kind"`
+	Goal  string           `json:"goal"`
+	Phase Phase            `json:"phase,omitempty"`
+	// PlanID is the plan the event is about, once the response has been converted to one
+	PlanID string `json:"plan_id,omitempty"`
+	// Summary describes the event on one line
+	Summary string `json:"summary"`
+	// Content is the artifact itself: the prompt, raw response, extracted JSON or diff
+	Content  string    `json:"content,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Tokens   int       `json:"tokens,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// PlannerObserver is told about each event recorded while planning a goal
+type PlannerObserver func(event PlannerEvent)
+
+// observe passes an event to the planner's observer, if it has one
+func (pe *PlanningEngine) observe(event PlannerEvent) {
+	if pe.observer == nil {
+		return
+	}
+	event.Time = time.Now()
+	pe.observer(event)
+}
+
+// promptEvent records the messages of a planning request
+func promptEvent(goal string, phase Phase, messages []Message) PlannerEvent {
+	var content strings.Builder
+	for i, message := range messages {
+		if i > 0 {
+			content.WriteString("\n\n")
+		}
+		fmt.Fprintf(&content, "--- %s ---\n%s", message.Role, message.Content)
+	}
+	return PlannerEvent{
+		Kind:    PlannerEventPrompt,
+		Goal:    goal,
+		Phase:   phase,
+		Summary: fmt.Sprintf("%d message(s), %d characters", len(messages), content.Len()),
+		Content: content.String(),
+	}
+}
+
+// responseEvents records a completion and the failures of the providers tried before it
+func responseEvents(goal string, phase Phase, resp *CompletionResponse) []PlannerEvent {
+	var events []PlannerEvent
+	if failovers := resp.Metadata[MetadataFailovers]; failovers != "" {
+		for _, failure := range strings.Split(failovers, "\n") {
+			events = append(events, PlannerEvent{Kind: PlannerEventRetry, Goal: goal, Phase: phase,
+				Summary: "tried the next provider after: " + failure, Error: failure})
+		}
+	}
+	summary := fmt.Sprintf("%d characters", len(resp.Content))
+	if resp.TokensUsed > 0 {
+		summary += fmt.Sprintf(", %d tokens", resp.TokensUsed)
+	}
+	if resp.FinishReason != "" {
+		summary += ", finished: " + resp.FinishReason
+	}
+	return append(events, PlannerEvent{
+		Kind:     PlannerEventResponse,
+		Goal:     goal,
+		Phase:    phase,
+		Summary:  summary,
+		Content:  resp.Content,
+		Provider: resp.Metadata[MetadataProvider],
+		Model:    resp.Model,
+		Tokens:   resp.TokensUsed,
+	})
+}
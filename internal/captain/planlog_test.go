@@ -0,0 +1,100 @@
+package captain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// observedKinds returns the kinds of the recorded events in order
+func observedKinds(events []PlannerEvent) []PlannerEventKind {
+	kinds := make([]PlannerEventKind, len(events))
+	for i, event := range events {
+		kinds[i] = event.Kind
+	}
+	return kinds
+}
+
+func TestPlanningEngine_Observer(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	secondary := &MockLLMProvider{}
+	secondary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{
+		Content:      "```json\n" + `{"tasks": [{"id": "task-1", "type": "analysis", "priority": "high", "description": "Analyze"}], "strategy": "sequential"}` + "\n```",
+		Model:        "llama3",
+		TokensUsed:   120,
+		FinishReason: "stop",
+	}, nil)
+	chain := NewProviderChain(
+		NewResilientProvider("openai", primary, config.LLMConfig{}),
+		NewResilientProvider("ollama", secondary, config.LLMConfig{}),
+	)
+
+	engine := NewPlanningEngine(chain)
+	var events []PlannerEvent
+	engine.SetObserver(func(event PlannerEvent) { events = append(events, event) })
+	plan, err := engine.CreatePlan(context.Background(), "analyze code")
+	require.NoError(t, err)
+
+	require.Equal(t, []PlannerEventKind{PlannerEventPrompt, PlannerEventRetry, PlannerEventResponse, PlannerEventExtraction, PlannerEventValidation}, observedKinds(events))
+	for _, event := range events {
+		assert.Equal(t, "analyze code", event.Goal)
+		assert.Equal(t, PhasePlanning, event.Phase)
+		assert.False(t, event.Time.IsZero())
+	}
+	assert.Contains(t, events[0].Content, "--- system ---\n")
+	assert.Contains(t, events[0].Content, "analyze code")
+	assert.Contains(t, events[1].Error, assert.AnError.Error())
+	assert.Equal(t, "ollama", events[2].Provider)
+	assert.Equal(t, "llama3", events[2].Model)
+	assert.Equal(t, 120, events[2].Tokens)
+	assert.Contains(t, events[2].Content, "```json")
+	assert.NotContains(t, events[3].Content, "```", "the extracted JSON has no code fences")
+	assert.Equal(t, "accepted: 1 task(s), sequential strategy", events[4].Summary)
+	assert.Equal(t, plan.ID, events[4].PlanID)
+}
+
+func TestPlanningEngine_ObserverRejections(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		kind    PlannerEventKind
+		planID  bool
+	}{
+		{name: "invalid JSON", content: "here is the plan: {tasks", kind: PlannerEventExtraction},
+		{name: "invalid plan", content: `{"tasks": [{"id": "task-1", "type": "analysis", "priority": "high", "description": "Analyze", "dependencies": ["task-9"]}], "strategy": "sequential"}`,
+			kind: PlannerEventValidation, planID: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &MockLLMProvider{}
+			llm.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: tt.content}, nil)
+			engine := NewPlanningEngine(llm)
+			var events []PlannerEvent
+			engine.SetObserver(func(event PlannerEvent) { events = append(events, event) })
+
+			_, err := engine.CreatePlan(context.Background(), "analyze code")
+			require.Error(t, err)
+			last := events[len(events)-1]
+			assert.Equal(t, tt.kind, last.Kind)
+			assert.NotEmpty(t, last.Error)
+			assert.Equal(t, "rejected: "+last.Error, last.Summary)
+			assert.Equal(t, tt.planID, last.PlanID != "", "rejected plans are identified once converted")
+		})
+	}
+
+	llm := &MockLLMProvider{}
+	llm.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	engine := NewPlanningEngine(llm)
+	var events []PlannerEvent
+	engine.SetObserver(func(event PlannerEvent) { events = append(events, event) })
+	_, err := engine.CreatePlan(context.Background(), "analyze code")
+	require.Error(t, err)
+	assert.Equal(t, []PlannerEventKind{PlannerEventPrompt, PlannerEventResponse}, observedKinds(events))
+	assert.Equal(t, assert.AnError.Error(), events[1].Error)
+}
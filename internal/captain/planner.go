@@ -4,24 +4,76 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
 )
 
 // PlanningEngine handles goal decomposition and execution planning
 type PlanningEngine struct {
 	llmProvider LLMProvider
+	rules       *RuleEngine
+	policy      *Policy
+	constraints PlanConstraints
+	language    string
+	gatherer    *ContextGatherer
+	observer    PlannerObserver
+	clock       common.Clock
 }
 
 // NewPlanningEngine creates a new planning engine
 func NewPlanningEngine(llmProvider LLMProvider) *PlanningEngine {
 	return &PlanningEngine{
 		llmProvider: llmProvider,
+		clock:       common.SystemClock,
 	}
 }
 
+// SetClock sets the clock the latency of planning requests is measured with
+func (pe *PlanningEngine) SetClock(clock common.Clock) {
+	pe.clock = clock
+}
+
+// SetRules sets the static rules every plan must pass before it is accepted
+func (pe *PlanningEngine) SetRules(rules *RuleEngine) {
+	pe.rules = rules
+}
+
+// SetPolicy sets the workspace policy every plan must pass, alongside the rules
+func (pe *PlanningEngine) SetPolicy(policy *Policy) {
+	pe.policy = policy
+}
+
+// SetConstraints sets the limits plans must fit, which are given to the LLM in the planning
+// prompt and enforced alongside the rules
+func (pe *PlanningEngine) SetConstraints(constraints PlanConstraints) {
+	pe.constraints = constraints
+}
+
+// SetLanguage sets the language plans' reasoning and step descriptions are written in, as a
+// language tag such as "fr". An empty language or "auto" follows the language of each goal.
+func (pe *PlanningEngine) SetLanguage(language string) {
+	pe.language = language
+}
+
+// SetContextGatherer sets the gatherer whose environment summary is added to planning prompts.
+// Without one, the planner sees only the goal.
+func (pe *PlanningEngine) SetContextGatherer(gatherer *ContextGatherer) {
+	pe.gatherer = gatherer
+}
+
+// SetObserver sets the function told about the prompt, response, extracted JSON and verdict
+// of each planning request
+func (pe *PlanningEngine) SetObserver(observer PlannerObserver) {
+	pe.observer = observer
+}
+
 // PlanResponse represents the structured response from the LLM for planning
 type PlanResponse struct {
 	Tasks             []TaskTemplate `json:"tasks"`
@@ -37,16 +89,85 @@ type TaskTemplate struct {
 	Priority     string   `json:"priority"`
 	Description  string   `json:"description"`
 	Dependencies []string `json:"dependencies"`
+	Risk         string   `json:"risk,omitempty"`
+	Agent        string   `json:"agent,omitempty"`
+
+	Workdir string            `json:"workdir,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Shell   string            `json:"shell,omitempty"`
+
+	Execution string `json:"execution,omitempty"`
+
+	Publish string   `json:"publish,omitempty"`
+	Inputs  []string `json:"inputs,omitempty"`
+
+	Condition *Condition `json:"condition,omitempty"`
+	Gate      string     `json:"gate,omitempty"`
+	FanOut    []string   `json:"fan_out,omitempty"`
+
+	Watch   *WatchTemplate   `json:"watch,omitempty"`
+	Service *ServiceTemplate `json:"service,omitempty"`
+}
+
+// WatchTemplate makes a planned step a file_watch step waiting on a file agent for paths to
+// change or appear
+type WatchTemplate struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern,omitempty"`
+	Until   string `json:"until,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// ServiceTemplate makes a planned step a service step, whose command keeps running in the
+// background while later steps use it
+type ServiceTemplate struct {
+	Command      string `json:"command"`
+	Address      string `json:"address,omitempty"`
+	HealthCheck  string `json:"health_check,omitempty"`
+	ReadyTimeout string `json:"ready_timeout,omitempty"`
 }
 
 // CreatePlan creates an execution plan from a goal using LLM-powered reasoning
 func (pe *PlanningEngine) CreatePlan(ctx context.Context, goal string) (*ExecutionPlan, error) {
+	return pe.PlanWithConversation(ctx, goal, nil)
+}
+
+// PlanWithConversation creates an execution plan for a goal, continuing a conversation about it.
+// Earlier reasoning, rejected plans and execution feedback in the conversation are included in
+// the prompt and, once the goal has been planned, the LLM is asked to revise the plan rather
+// than start over.
+// The exchange and any rejection of the result are recorded in the conversation.
+func (pe *PlanningEngine) PlanWithConversation(ctx context.Context, goal string, conversation *Conversation) (*ExecutionPlan, error) {
 	if goal == "" {
 		return nil, fmt.Errorf("goal cannot be empty")
 	}
 
 	// Build the planning prompt with chain-of-thought reasoning
-	messages := pe.buildPlanningPrompt(goal)
+	var planningContext *PlanningContext
+	if pe.gatherer != nil {
+		planningContext = pe.gatherer.Gather(ctx)
+	}
+	messages := pe.buildPlanningPrompt(goal, planningContext)
+
+	phase := PhasePlanning
+	if conversation != nil {
+		conversation.Compact(ctx)
+		if history := conversation.Messages(); len(history) > 0 {
+			if conversation.Planned() {
+				phase = PhaseReplanning
+				messages[1].Content = buildReplanningPrompt(goal, planningContext)
+			}
+			messages = append(append([]Message{messages[0]}, history...), messages[1])
+		}
+		conversation.Add(phase, "user", messages[len(messages)-1].Content)
+	}
+	reject := func(kind PlannerEventKind, planID, content string, err error) {
+		pe.observe(PlannerEvent{Kind: kind, Goal: goal, Phase: phase, PlanID: planID,
+			Summary: "rejected: " + err.Error(), Content: content, Error: err.Error()})
+		if conversation != nil {
+			conversation.Add(PhaseValidation, "user", "That plan was rejected: "+err.Error())
+		}
+	}
 
 	// Request completion from LLM
 	req := CompletionRequest{
@@ -55,27 +176,60 @@ func (pe *PlanningEngine) CreatePlan(ctx context.Context, goal string) (*Executi
 		Temperature: 0.3, // Lower temperature for more consistent planning
 	}
 
+	pe.observe(promptEvent(goal, phase, messages))
+	start := pe.clock.Now()
 	resp, err := pe.llmProvider.GenerateCompletion(ctx, req)
 	if err != nil {
+		pe.observe(PlannerEvent{Kind: PlannerEventResponse, Goal: goal, Phase: phase, Summary: "request failed: " + err.Error(), Error: err.Error()})
 		return nil, fmt.Errorf("failed to generate plan: %w", err)
 	}
+	for _, event := range responseEvents(goal, phase, resp) {
+		pe.observe(event)
+	}
+	if conversation != nil {
+		conversation.Add(phase, "assistant", resp.Content)
+	}
 
 	// Parse the LLM response
+	extracted := extractJSON(resp.Content)
 	planResp, err := pe.parsePlanResponse(resp.Content)
 	if err != nil {
+		reject(PlannerEventExtraction, "", extracted, err)
 		return nil, fmt.Errorf("failed to parse plan response: %w", err)
 	}
+	pe.observe(PlannerEvent{Kind: PlannerEventExtraction, Goal: goal, Phase: phase,
+		Summary: fmt.Sprintf("%d task(s), %d characters of JSON", len(planResp.Tasks), len(extracted)), Content: extracted})
 
 	// Convert to execution plan
 	plan, err := pe.convertToPlan(goal, planResp)
 	if err != nil {
+		reject(PlannerEventValidation, "", "", err)
 		return nil, fmt.Errorf("failed to convert to execution plan: %w", err)
 	}
 
 	// Validate the generated plan
 	if err := pe.ValidatePlan(plan); err != nil {
+		reject(PlannerEventValidation, plan.ID, "", err)
 		return nil, fmt.Errorf("generated plan is invalid: %w", err)
 	}
+	pe.observe(PlannerEvent{Kind: PlannerEventValidation, Goal: goal, Phase: phase, PlanID: plan.ID,
+		Summary: fmt.Sprintf("accepted: %d task(s), %s strategy", len(plan.Tasks), plan.Strategy.Type)})
+
+	// Record which provider and model produced the plan
+	setPlanLLM(plan, agents.LLMUsage{
+		Provider:    resp.Metadata[MetadataProvider],
+		Model:       resp.Model,
+		Temperature: req.Temperature,
+		Tokens:      resp.TokensUsed,
+		Latency:     pe.clock.Since(start),
+	})
+	// Reports and notifications about the plan's task are written in the same language
+	if language := ResponseLanguage(pe.language, goal); language != "" {
+		if plan.Metadata == nil {
+			plan.Metadata = map[string]string{}
+		}
+		plan.Metadata[MetadataLanguage] = language
+	}
 
 	return plan, nil
 }
@@ -98,6 +252,10 @@ func (pe *PlanningEngine) ValidatePlan(plan *ExecutionPlan) error {
 		return fmt.Errorf("plan must contain at least one task")
 	}
 
+	if plan.Timeline.MaxRuntime < 0 {
+		return fmt.Errorf("max_runtime cannot be negative")
+	}
+
 	// Check for duplicate task IDs
 	taskIDs := make(map[string]bool)
 	for _, task := range plan.Tasks {
@@ -119,16 +277,46 @@ func (pe *PlanningEngine) ValidatePlan(plan *ExecutionPlan) error {
 		}
 	}
 
-	// Check for circular dependencies
-	if pe.hasCircularDependencies(plan.Tasks) {
-		return fmt.Errorf("circular dependency detected")
+	// Check the execution environment, condition, gate and service of each task
+	for _, task := range plan.Tasks {
+		if err := validateTaskEnvironment(task); err != nil {
+			return fmt.Errorf("task %s: %w", task.ID, err)
+		}
+		if err := validateTaskFlow(task); err != nil {
+			return fmt.Errorf("task %s: %w", task.ID, err)
+		}
+		if err := validateService(task); err != nil {
+			return fmt.Errorf("task %s: %w", task.ID, err)
+		}
+	}
+	if err := validateReferences(plan.Tasks); err != nil {
+		return err
+	}
+
+	// Check for circular dependencies, which would leave the executor waiting forever
+	if err := checkDependencyCycles(plan.Tasks); err != nil {
+		return err
+	}
+
+	// Deterministic rules run before anything that spends tokens
+	rules := pe.rules
+	if pe.policy != nil {
+		rules = rules.With(PolicyRule{Policy: pe.policy})
+	}
+	if constraintRules := pe.constraints.Rules(); len(constraintRules) > 0 {
+		rules = rules.With(constraintRules...)
+	}
+	if rules != nil {
+		if err := rules.Validate(plan); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 // buildPlanningPrompt creates the prompt messages for planning
-func (pe *PlanningEngine) buildPlanningPrompt(goal string) []Message {
+func (pe *PlanningEngine) buildPlanningPrompt(goal string, planningContext *PlanningContext) []Message {
 	systemPrompt := `You are an expert AI planning agent specialized in task decomposition and execution planning. Your role is to analyze complex goals and create detailed, executable plans.
 
 ## Planning Principles:
@@ -150,6 +338,18 @@ func (pe *PlanningEngine) buildPlanningPrompt(goal string) []Message {
 - "medium": Standard priority, normal workflow
 - "low": Nice to have, can be delayed
 
+## Risk Levels:
+- "low": Read-only work with no side effects
+- "medium": Writes files or contacts remote services in a reversible way
+- "high": Deletes data, installs packages, changes remote systems or runs destructive commands
+
+## Branching and Gates:
+- "condition" runs a step only when the output of one of its dependencies matches a regular expression and skips it otherwise. Pair steps with complementary patterns to branch, for example one step for "(?i)tests? passed" and another for "(?i)fail".
+- "watch" makes a step wait for files instead of acting: it ends at the first change under "path" ("until": "change"), once the path or a file matching "pattern" exists ("until": "exists"), or after watching for the whole "timeout" ("until": "timeout"). Its output lists the changes it saw as "created PATH", "modified PATH" or "removed PATH", so later steps can depend on it with a condition, for example waiting for "dist/*.tar.gz" to exist before deploying.
+- "service" starts a long-running process, such as a dev server, that later steps use while it runs: the step ends once the process is healthy, and every service is stopped when the plan ends. "health_check" is a URL or command that must succeed first; "address" is published for later steps to read as "<step id>.address" in their inputs.
+- A step's "command", "description" and "path", and an api_call's "url", "body" and "headers", may use the results of steps it depends on: ${steps.<id>.output} for a step's output, ${steps.<id>.output.<field>} for a field of JSON output, ${steps.<id>.status} or ${steps.<id>.error}, and ${findings.<key>} for a published finding. They are filled in when the step runs, quoted for the shell in commands; do not quote them yourself.
+- "gate": "manual" stops the plan before a step until a person approves it. Use it where going on depends on human judgement of earlier results, such as before a release or an irreversible change.
+
 ## Response Format:
 Respond with a JSON object containing:
 {
@@ -159,7 +359,20 @@ Respond with a JSON object containing:
       "type": "analysis|execution|validation|reporting",
       "priority": "critical|high|medium|low",
       "description": "Clear description of what needs to be done",
-      "dependencies": ["task-id-1", "task-id-2"]
+      "dependencies": ["task-id-1", "task-id-2"],
+      "risk": "low|medium|high",
+      "agent": "optional agent to run the step on: file|network|research",
+      "workdir": "optional directory the step runs in",
+      "env": {"NAME": "optional environment variables for the step"},
+      "shell": "optional shell for the step's commands: sh|bash|zsh|pwsh",
+      "execution": "optional: container to isolate risky commands, host when they need the machine itself",
+      "publish": "optional key later steps can read this step's output under",
+      "inputs": ["optional keys published by earlier steps that this step reads"],
+      "condition": {"step": "optional: a dependency whose output decides whether this step runs", "matches": "regular expression"},
+      "gate": "optional: manual to stop and wait for approval before this step",
+      "fan_out": ["optional items to run this step once for each, in parallel; each run reads its item from $CAPN_ITEM and the steps depending on it receive every item's result"],
+      "watch": {"path": "optional: file or directory this step waits on", "pattern": "optional file name glob", "until": "change|exists|timeout", "timeout": "5m"},
+      "service": {"command": "optional: command to keep running for later steps", "address": "optional: localhost:3000", "health_check": "optional: URL or command", "ready_timeout": "30s"}
     }
   ],
   "strategy": "sequential|parallel|hybrid",
@@ -168,8 +381,17 @@ Respond with a JSON object containing:
 }
 
 Think step by step and create a comprehensive plan.`
+	if constraints := pe.constraints.Prompt(); constraints != "" {
+		systemPrompt += "\n\n" + constraints
+	}
+	if instruction := languageInstruction(ResponseLanguage(pe.language, goal)); instruction != "" {
+		systemPrompt += "\n\n" + instruction
+	}
 
 	userPrompt := fmt.Sprintf("Create an execution plan for the following goal:\n\n%s", goal)
+	if planningContext != nil {
+		userPrompt += "\n\n## Environment:\n" + planningContext.Summary()
+	}
 
 	return []Message{
 		{Role: "system", Content: systemPrompt},
@@ -177,8 +399,27 @@ Think step by step and create a comprehensive plan.`
 	}
 }
 
+// buildReplanningPrompt asks for a revised plan in light of the conversation so far
+func buildReplanningPrompt(goal string, planningContext *PlanningContext) string {
+	prompt := fmt.Sprintf("Revise the execution plan for the following goal. Take the earlier plans, rejections and execution feedback above into account, keep what worked and plan only the work that still needs doing:\n\n%s", goal)
+	if planningContext != nil {
+		prompt += "\n\n## Environment:\n" + planningContext.Summary()
+	}
+	return prompt
+}
+
 // parsePlanResponse parses the LLM response into a structured plan
 func (pe *PlanningEngine) parsePlanResponse(content string) (*PlanResponse, error) {
+	var planResp PlanResponse
+	if err := json.Unmarshal([]byte(extractJSON(content)), &planResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plan response: %w", err)
+	}
+
+	return &planResp, nil
+}
+
+// extractJSON strips surrounding whitespace and markdown code fences from an LLM response
+func extractJSON(content string) string {
 	// Clean up the response - sometimes LLMs add extra formatting
 	content = strings.TrimSpace(content)
 	
@@ -196,13 +437,7 @@ func (pe *PlanningEngine) parsePlanResponse(content string) (*PlanResponse, erro
 			content = content[start:end]
 		}
 	}
-
-	var planResp PlanResponse
-	if err := json.Unmarshal([]byte(content), &planResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal plan response: %w", err)
-	}
-
-	return &planResp, nil
+	return content
 }
 
 // convertToPlan converts a plan response to an ExecutionPlan
@@ -226,6 +461,44 @@ func (pe *PlanningEngine) convertToPlan(goal string, planResp *PlanResponse) (*E
 			Metadata: map[string]string{
 				"generated_by": "planning_engine",
 			},
+			Workdir: taskTemplate.Workdir,
+			Env:     taskTemplate.Env,
+			Shell:   taskTemplate.Shell,
+
+			Execution: taskTemplate.Execution,
+
+			Condition: taskTemplate.Condition,
+			Gate:      taskTemplate.Gate,
+			FanOut:    taskTemplate.FanOut,
+		}
+		if risk, ok := ParseRiskLevel(taskTemplate.Risk); ok {
+			tasks[i].Metadata["risk"] = string(risk)
+		}
+		if taskTemplate.Agent != "" {
+			tasks[i].Payload["agent"] = taskTemplate.Agent
+		}
+		if taskTemplate.Publish != "" {
+			tasks[i].Payload[PayloadPublish] = taskTemplate.Publish
+		}
+		if len(taskTemplate.Inputs) > 0 {
+			tasks[i].Payload[PayloadInputs] = taskTemplate.Inputs
+		}
+		if watch := taskTemplate.Watch; watch != nil {
+			tasks[i].Type = TaskTypeFileWatch
+			tasks[i].Payload["path"] = watch.Path
+			for key, value := range map[string]string{"pattern": watch.Pattern, "until": watch.Until, "timeout": watch.Timeout} {
+				if value != "" {
+					tasks[i].Payload[key] = value
+				}
+			}
+		}
+		if service := taskTemplate.Service; service != nil {
+			tasks[i].Service = true
+			for key, value := range map[string]string{"command": service.Command, PayloadAddress: service.Address, PayloadHealthCheck: service.HealthCheck, PayloadReadyTimeout: service.ReadyTimeout} {
+				if value != "" {
+					tasks[i].Payload[key] = value
+				}
+			}
 		}
 	}
 
@@ -289,43 +562,54 @@ func (pe *PlanningEngine) parseEstimatedDuration(durationStr string) (time.Durat
 	return duration, nil
 }
 
-// hasCircularDependencies checks if there are circular dependencies in the task list
-func (pe *PlanningEngine) hasCircularDependencies(tasks []Task) bool {
-	// Build adjacency list
-	graph := make(map[string][]string)
-	for _, task := range tasks {
-		graph[task.ID] = task.Dependencies
-	}
-
-	// Track visit states: 0 = unvisited, 1 = visiting, 2 = visited
-	visited := make(map[string]int)
-
-	// DFS to detect cycles
-	var hasCycle func(string) bool
-	hasCycle = func(taskID string) bool {
-		if visited[taskID] == 1 {
-			return true // Found a back edge (cycle)
+// validateTaskEnvironment checks a task's shell is available where it runs and its environment variable names are usable
+func validateTaskEnvironment(task Task) error {
+	switch task.Execution {
+	case "", config.ExecutionModeHost:
+		if task.Shell != "" {
+			if _, err := agents.LookupShell(task.Shell); err != nil {
+				return err
+			}
 		}
-		if visited[taskID] == 2 {
-			return false // Already processed
+	case config.ExecutionModeContainer:
+		if _, err := agents.LookupContainerShell(task.Shell); err != nil {
+			return err
 		}
-
-		visited[taskID] = 1 // Mark as visiting
-		for _, dep := range graph[taskID] {
-			if hasCycle(dep) {
-				return true
-			}
+	default:
+		return fmt.Errorf("invalid execution mode %q (must be host or container)", task.Execution)
+	}
+	for name := range task.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid environment variable name: %q", name)
 		}
-		visited[taskID] = 2 // Mark as visited
-		return false
 	}
+	return nil
+}
 
-	// Check each task
-	for _, task := range tasks {
-		if visited[task.ID] == 0 && hasCycle(task.ID) {
-			return true
+// validateTaskFlow checks a task's gate is known, its fan-out items are distinct and its
+// condition reads a dependency, which has always run by the time the condition is checked
+func validateTaskFlow(task Task) error {
+	if task.Gate != "" && task.Gate != GateManual {
+		return fmt.Errorf("invalid gate %q (must be manual)", task.Gate)
+	}
+	seen := make(map[string]bool, len(task.FanOut))
+	for _, item := range task.FanOut {
+		switch {
+		case item == "":
+			return fmt.Errorf("fan_out items cannot be empty")
+		case seen[item]:
+			return fmt.Errorf("duplicate fan_out item %q", item)
 		}
+		seen[item] = true
 	}
-
-	return false
-}
\ No newline at end of file
+	if task.Condition == nil {
+		return nil
+	}
+	if err := task.Condition.Validate(); err != nil {
+		return err
+	}
+	if !slices.Contains(task.Dependencies, task.Condition.Step) {
+		return fmt.Errorf("condition step %s must be one of its dependencies", task.Condition.Step)
+	}
+	return nil
+}
@@ -10,6 +10,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/testutil"
 )
 
 func TestNewPlanningEngine(t *testing.T) {
@@ -233,6 +237,17 @@ func TestPlanningEngine_ValidatePlan(t *testing.T) {
 			wantErr: true,
 			errMsg:  "plan must contain at least one task",
 		},
+		{
+			name: "negative max runtime",
+			plan: &ExecutionPlan{
+				ID:       "plan-1",
+				Goal:     "test goal",
+				Tasks:    []Task{{ID: "task-1", Type: TaskTypeAnalysis, Priority: PriorityHigh}},
+				Timeline: ExecutionTimeline{MaxRuntime: -time.Second},
+			},
+			wantErr: true,
+			errMsg:  "max_runtime cannot be negative",
+		},
 		{
 			name: "duplicate task IDs",
 			plan: &ExecutionPlan{
@@ -269,7 +284,117 @@ func TestPlanningEngine_ValidatePlan(t *testing.T) {
 				},
 			},
 			wantErr: true,
-			errMsg:  "circular dependency detected",
+			errMsg:  "circular dependency detected: task-1 -> task-2 -> task-1",
+		},
+		{
+			name: "unknown shell",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, Shell: "fish-ish"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "task task-1: unknown shell: fish-ish",
+		},
+		{
+			name: "invalid environment variable name",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, Env: map[string]string{"A=B": "c"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `task task-1: invalid environment variable name: "A=B"`,
+		},
+		{
+			name: "unknown execution mode",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, Execution: "vm"},
+				},
+			},
+			wantErr: true,
+			errMsg:  `task task-1: invalid execution mode "vm" (must be host or container)`,
+		},
+		{
+			name: "windows shell in a container",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, Execution: "container", Shell: "cmd"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "task task-1: shell cmd is not available in containers",
+		},
+		{
+			name: "condition and gate",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeValidation, Priority: PriorityHigh},
+					{ID: "task-2", Type: TaskTypeExecution, Priority: PriorityHigh, Dependencies: []string{"task-1"},
+						Condition: &Condition{Step: "task-1", Matches: "(?i)passed"}, Gate: GateManual},
+				},
+			},
+		},
+		{
+			name: "condition on a step that is not a dependency",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeValidation, Priority: PriorityHigh},
+					{ID: "task-2", Type: TaskTypeExecution, Priority: PriorityHigh, Condition: &Condition{Step: "task-1", Matches: "ok"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "task task-2: condition step task-1 must be one of its dependencies",
+		},
+		{
+			name: "invalid condition pattern",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeValidation, Priority: PriorityHigh},
+					{ID: "task-2", Type: TaskTypeExecution, Priority: PriorityHigh, Dependencies: []string{"task-1"}, Condition: &Condition{Step: "task-1", Matches: "(unclosed"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `task task-2: invalid condition pattern "(unclosed"`,
+		},
+		{
+			name: "unknown gate",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, Gate: "timer"},
+				},
+			},
+			wantErr: true,
+			errMsg:  `task task-1: invalid gate "timer" (must be manual)`,
+		},
+		{
+			name: "duplicate fan-out items",
+			plan: &ExecutionPlan{
+				ID:   "plan-1",
+				Goal: "test goal",
+				Tasks: []Task{
+					{ID: "task-1", Type: TaskTypeExecution, Priority: PriorityHigh, FanOut: []string{"web-1", "web-2", "web-1"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `task task-1: duplicate fan_out item "web-1"`,
 		},
 	}
 
@@ -292,7 +417,7 @@ func TestPlanningEngine_buildPlanningPrompt(t *testing.T) {
 	engine := NewPlanningEngine(&MockLLMProvider{})
 	
 	goal := "analyze code quality"
-	messages := engine.buildPlanningPrompt(goal)
+	messages := engine.buildPlanningPrompt(goal, nil)
 	
 	require.Len(t, messages, 2)
 	
@@ -441,4 +566,140 @@ func TestPlanningEngine_parseEstimatedDuration(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestPlanningEngine_convertToPlan_Risk(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+
+	plan, err := engine.convertToPlan("clean up", &PlanResponse{
+		Tasks: []TaskTemplate{
+			{ID: "task-1", Type: "execution", Priority: "high", Description: "Remove build output", Risk: "HIGH"},
+			{ID: "task-2", Type: "reporting", Priority: "low", Description: "Summarize", Risk: "unknown"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "high", plan.Tasks[0].Metadata["risk"])
+	assert.NotContains(t, plan.Tasks[1].Metadata, "risk", "unrecognized risk levels are ignored")
+}
+
+func TestPlanningEngine_convertToPlan_Environment(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+
+	planResp, err := engine.parsePlanResponse(`{"tasks": [{"id": "task-1", "type": "execution", "priority": "medium",
+		"description": "Run the tests", "workdir": "services/api", "env": {"GOFLAGS": "-race"}, "shell": "bash"}]}`)
+	require.NoError(t, err)
+	plan, err := engine.convertToPlan("test the api", planResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, "services/api", plan.Tasks[0].Workdir)
+	assert.Equal(t, map[string]string{"GOFLAGS": "-race"}, plan.Tasks[0].Env)
+	assert.Equal(t, "bash", plan.Tasks[0].Shell)
+}
+
+func TestPlanningEngine_convertToPlan_Findings(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+
+	planResp, err := engine.parsePlanResponse(`{"tasks": [
+		{"id": "task-1", "type": "analysis", "priority": "high", "description": "List endpoints", "publish": "endpoints"},
+		{"id": "task-2", "type": "validation", "priority": "high", "description": "Check endpoints", "dependencies": ["task-1"], "inputs": ["endpoints"]}]}`)
+	require.NoError(t, err)
+	plan, err := engine.convertToPlan("check the api", planResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, "endpoints", plan.Tasks[0].Payload[PayloadPublish])
+	assert.NotContains(t, plan.Tasks[0].Payload, PayloadInputs)
+	assert.Equal(t, []string{"endpoints"}, plan.Tasks[1].InputKeys())
+}
+
+func TestPlanningEngine_convertToPlan_ConditionsAndGates(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+	assert.Contains(t, engine.buildPlanningPrompt("release", nil)[0].Content, `"gate": "manual"`)
+
+	planResp, err := engine.parsePlanResponse(`{"tasks": [
+		{"id": "task-1", "type": "validation", "priority": "high", "description": "Run the tests"},
+		{"id": "task-2", "type": "execution", "priority": "high", "description": "Tag the release", "dependencies": ["task-1"],
+			"condition": {"step": "task-1", "matches": "PASS"}, "gate": "manual"}]}`)
+	require.NoError(t, err)
+	plan, err := engine.convertToPlan("release", planResp)
+	require.NoError(t, err)
+
+	assert.Nil(t, plan.Tasks[0].Condition)
+	assert.Equal(t, &Condition{Step: "task-1", Matches: "PASS"}, plan.Tasks[1].Condition)
+	assert.Equal(t, GateManual, plan.Tasks[1].Gate)
+	assert.NoError(t, engine.ValidatePlan(plan))
+}
+
+func TestPlanningEngine_convertToPlan_FanOut(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+	assert.Contains(t, engine.buildPlanningPrompt("deploy", nil)[0].Content, `"fan_out"`)
+
+	planResp, err := engine.parsePlanResponse(`{"tasks": [
+		{"id": "task-1", "type": "execution", "priority": "high", "description": "Deploy", "fan_out": ["web-1", "web-2"]},
+		{"id": "task-2", "type": "reporting", "priority": "medium", "description": "Summarize", "dependencies": ["task-1"]}]}`)
+	require.NoError(t, err)
+	plan, err := engine.convertToPlan("deploy", planResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"web-1", "web-2"}, plan.Tasks[0].FanOut)
+	assert.Empty(t, plan.Tasks[1].FanOut)
+	assert.NoError(t, engine.ValidatePlan(plan))
+}
+
+func TestPlanningEngine_convertToPlan_Watch(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+	assert.Contains(t, engine.buildPlanningPrompt("deploy the build", nil)[0].Content, `"watch"`)
+
+	planResp, err := engine.parsePlanResponse(`{"tasks": [
+		{"id": "task-1", "type": "execution", "priority": "high", "description": "Wait for the build",
+			"watch": {"path": "dist", "pattern": "*.tar.gz", "until": "exists", "timeout": "10m"}},
+		{"id": "task-2", "type": "execution", "priority": "high", "description": "Deploy", "dependencies": ["task-1"],
+			"condition": {"step": "task-1", "matches": "exists"}}]}`)
+	require.NoError(t, err)
+	plan, err := engine.convertToPlan("deploy the build", planResp)
+	require.NoError(t, err)
+
+	assert.Equal(t, TaskTypeFileWatch, plan.Tasks[0].Type)
+	assert.Equal(t, "dist", plan.Tasks[0].Payload["path"])
+	assert.Equal(t, "*.tar.gz", plan.Tasks[0].Payload["pattern"])
+	assert.Equal(t, "exists", plan.Tasks[0].Payload["until"])
+	assert.Equal(t, "10m", plan.Tasks[0].Payload["timeout"])
+	assert.Equal(t, agents.AgentTypeFile, AgentTypeFor(plan.Tasks[0]))
+	assert.Equal(t, TaskTypeExecution, plan.Tasks[1].Type)
+	assert.NoError(t, engine.ValidatePlan(plan))
+}
+
+func TestPlanningEngine_CreatePlan_RecordsProvider(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	clock := testutil.NewFakeClock(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	secondary := &MockLLMProvider{}
+	secondary.On("GenerateCompletion", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		clock.Advance(1200 * time.Millisecond)
+	}).Return(&CompletionResponse{
+		Content:    `{"tasks": [{"id": "task-1", "type": "analysis", "priority": "high", "description": "Analyze"}], "strategy": "sequential"}`,
+		Model:      "llama3",
+		TokensUsed: 850,
+	}, nil)
+
+	chain := NewProviderChain(
+		NewResilientProvider("openai", primary, config.LLMConfig{}),
+		NewResilientProvider("ollama", secondary, config.LLMConfig{}),
+	)
+	engine := NewPlanningEngine(chain)
+	engine.SetClock(clock)
+	plan, err := engine.CreatePlan(context.Background(), "analyze code")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		MetadataProvider:    "ollama",
+		MetadataModel:       "llama3",
+		MetadataTemperature: "0.3",
+		MetadataTokens:      "850",
+		MetadataLatency:     "1.2s",
+	}, plan.Metadata)
+
+	usage, ok := PlanLLM(plan)
+	require.True(t, ok)
+	assert.Equal(t, agents.LLMUsage{Provider: "ollama", Model: "llama3", Temperature: 0.3, Tokens: 850, Latency: 1200 * time.Millisecond}, usage)
+	_, ok = PlanLLM(&ExecutionPlan{})
+	assert.False(t, ok, "hand-written plans record no call")
+}
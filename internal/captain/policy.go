@@ -0,0 +1,254 @@
+package captain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// PolicyFile is the name of the execution policy file read from a workspace's root directory
+const PolicyFile = "capn-policy.yaml"
+
+// PolicyList allows and denies targets of one kind. A target is denied when it matches a deny
+// pattern, or when allow patterns are listed and it matches none of them. "*" matches anything.
+type PolicyList struct {
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// Policy is a workspace's execution policy: the agent types, commands, hosts and file paths
+// its tasks may touch. Commands match on leading words, so "git push" covers
+// "git push origin main"; hosts match exactly or, as "*.example.com", any subdomain; paths match
+// the path and everything beneath it, or as a glob.
+type Policy struct {
+	Agents   PolicyList `yaml:"agents,omitempty"`
+	Commands PolicyList `yaml:"commands,omitempty"`
+	Hosts    PolicyList `yaml:"hosts,omitempty"`
+	Paths    PolicyList `yaml:"paths,omitempty"`
+
+	// Dir is the directory relative paths in the policy and in steps resolve against
+	Dir string `yaml:"-"`
+}
+
+// LoadPolicy reads the policy file in a workspace directory, returning nil when there is none
+func LoadPolicy(dir string) (*Policy, error) {
+	path := filepath.Join(dir, PolicyFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	policy, err := ParsePolicy(data, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy parses a YAML policy whose relative paths resolve against dir
+func ParsePolicy(data []byte, dir string) (*Policy, error) {
+	policy := &Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	policy.Dir = absPath(dir)
+	return policy, nil
+}
+
+// CheckTask reports everything a step would touch that the policy does not allow: its agent
+// type, the commands it runs, the hosts it contacts and the paths it works in or changes.
+// Commands are matched with the wrappers that run them, such as sudo and env, stripped off and
+// their program reduced to its base name; the wrappers themselves are only checked against
+// the commands the policy denies.
+func (p *Policy) CheckTask(task Task) []Violation {
+	var violations []Violation
+	check := func(kind string, list PolicyList, target string, match func(pattern, target string) bool) {
+		if message := list.check(target, match); message != "" {
+			if kind == "command" {
+				target = fmt.Sprintf("%q", target)
+			}
+			violations = append(violations, Violation{
+				Rule:    PolicyRule{}.Name(),
+				TaskID:  task.ID,
+				Message: fmt.Sprintf("%s %s %s", kind, target, message),
+			})
+		}
+	}
+
+	check("agent type", p.Agents, string(AgentTypeFor(task)), matchName)
+	commands, wrappers, hosts, paths := p.targets(task)
+	for _, command := range commands {
+		check("command", p.Commands, command, matchCommand)
+	}
+	for _, wrapper := range wrappers {
+		check("command", PolicyList{Deny: p.Commands.Deny}, wrapper, matchCommand)
+	}
+	for _, host := range hosts {
+		check("host", p.Hosts, host, matchHost)
+	}
+	for _, path := range paths {
+		check("path", p.Paths, path, p.matchPath)
+	}
+	return violations
+}
+
+// targets returns the commands, the wrappers running them, and the hosts and absolute paths a
+// step is expected to touch, in the order they are first found
+func (p *Policy) targets(task Task) (commands, wrappers, hosts, paths []string) {
+	seen := make(map[string]bool)
+	add := func(list *[]string, kind, value string) {
+		if value != "" && !seen[kind+"\x00"+value] {
+			seen[kind+"\x00"+value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	base := p.Dir
+	if task.Workdir != "" {
+		base = p.resolve(p.Dir, task.Workdir)
+		add(&paths, "path", base)
+	}
+	if path, ok := task.Payload["path"].(string); ok && path != "" {
+		add(&paths, "path", p.resolve(base, path))
+	}
+	for _, effect := range heuristicEffects(task) {
+		switch effect.Kind {
+		case EffectCommand:
+			for _, command := range agents.SplitCommands(effect.Target) {
+				words, wrapped := agents.UnwrapCommand(command)
+				add(&commands, "command", strings.Join(words, " "))
+				for _, wrapper := range wrapped {
+					add(&wrappers, "wrapper", wrapper)
+				}
+			}
+		case EffectNetwork:
+			if u, err := url.Parse(effect.Target); err == nil && effect.Target != "" {
+				add(&hosts, "host", strings.ToLower(u.Hostname()))
+			}
+		case EffectFileWrite, EffectFileDelete:
+			if effect.Target != "" {
+				add(&paths, "path", p.resolve(base, effect.Target))
+			}
+		}
+	}
+	return commands, wrappers, hosts, paths
+}
+
+// resolve makes a path absolute against base
+func (p *Policy) resolve(base, path string) string {
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return filepath.Clean(path)
+}
+
+// check returns why the list rejects target, or "" when it is allowed
+func (l PolicyList) check(target string, match func(pattern, target string) bool) string {
+	for _, pattern := range l.Deny {
+		if match(pattern, target) {
+			return fmt.Sprintf("is denied by %q", pattern)
+		}
+	}
+	if len(l.Allow) == 0 {
+		return ""
+	}
+	for _, pattern := range l.Allow {
+		if match(pattern, target) {
+			return ""
+		}
+	}
+	return "is not allowed"
+}
+
+// matchName matches agent types, ignoring case
+func matchName(pattern, name string) bool {
+	return pattern == "*" || strings.EqualFold(pattern, name)
+}
+
+// matchCommand matches a command whose leading words are the pattern's
+func matchCommand(pattern, command string) bool {
+	pattern = strings.Join(strings.Fields(pattern), " ")
+	return pattern == "*" || command == pattern || strings.HasPrefix(command, pattern+" ")
+}
+
+// matchHost matches a host exactly or, for "*.example.com", any subdomain of example.com
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return suffix == "" || strings.HasSuffix(host, suffix)
+	}
+	return host == pattern
+}
+
+// matchPath matches a path that is the pattern or inside it, or that matches it as a glob
+func (p *Policy) matchPath(pattern, path string) bool {
+	if pattern == "*" {
+		return true
+	}
+	pattern = p.resolve(p.Dir, pattern)
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, err := filepath.Match(pattern, path)
+		return err == nil && matched
+	}
+	rel, err := filepath.Rel(pattern, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// PolicyRule checks every step of a plan against a workspace policy
+type PolicyRule struct {
+	Policy *Policy
+}
+
+// Name returns the rule name
+func (r PolicyRule) Name() string { return "policy" }
+
+// Check reports everything the plan's steps would touch that the policy does not allow
+func (r PolicyRule) Check(plan *ExecutionPlan) []Violation {
+	var violations []Violation
+	for _, task := range plan.Tasks {
+		violations = append(violations, r.Policy.CheckTask(task)...)
+	}
+	return violations
+}
+
+// checkPolicy returns a failed result for a step the policy does not allow, timestamped by
+// clock, or nil when the step may run
+func checkPolicy(policy *Policy, task Task, clock common.Clock) *Result {
+	if policy == nil {
+		return nil
+	}
+	violations := policy.CheckTask(task)
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	return &Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     fmt.Sprintf("step %s is blocked by policy: %s", task.ID, strings.Join(messages, "; ")),
+		Timestamp: clock.Now(),
+		Metadata: map[string]any{
+			"policy":            "denied",
+			"policy_violations": messages,
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+const testPolicy = `agents:
+  deny: [shell]
+commands:
+  allow: [go, git, make]
+  deny: [git push]
+hosts:
+  allow: [proxy.golang.org, "*.github.com"]
+paths:
+  deny: [secrets, "*.pem"]
+`
+
+func commandTask(id, command string) Task {
+	return Task{ID: id, Type: TaskTypeExecution, Payload: map[string]any{"command": command}}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := LoadPolicy(dir)
+	require.NoError(t, err)
+	assert.Nil(t, policy, "a workspace without a policy file has no policy")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, PolicyFile), []byte(testPolicy), 0o644))
+	policy, err = LoadPolicy(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shell"}, policy.Agents.Deny)
+	assert.Equal(t, []string{"go", "git", "make"}, policy.Commands.Allow)
+	assert.Equal(t, dir, policy.Dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, PolicyFile), []byte("command:\n  deny: [rm]\n"), 0o644))
+	_, err = LoadPolicy(dir)
+	assert.ErrorContains(t, err, "field command not found", "unknown keys are rejected")
+}
+
+func TestPolicy_CheckTask(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy), "/work")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		task Task
+		want []string
+	}{
+		{name: "allowed command", task: commandTask("t", "go test ./...")},
+		{name: "denied command", task: commandTask("t", "make build && git push origin main"),
+			want: []string{`command "git push origin main" is denied by "git push"`}},
+		{name: "command not allowed", task: commandTask("t", "go build | tee log; curl -s example.com"),
+			want: []string{`command "tee log" is not allowed`, `command "curl -s example.com" is not allowed`}},
+		{name: "denied agent type", task: Task{ID: "t", Payload: map[string]any{"agent": "shell"}},
+			want: []string{`agent type shell is denied by "shell"`}},
+		{name: "allowed host", task: describedTask("t", TaskTypeAnalysis, "Download https://api.github.com/repos")},
+		{name: "host not allowed", task: describedTask("t", TaskTypeAnalysis, "Fetch https://example.com/data.json"),
+			want: []string{"host example.com is not allowed"}},
+		{name: "denied workdir", task: Task{ID: "t", Workdir: "secrets/prod"},
+			want: []string{`path /work/secrets/prod is denied by "secrets"`}},
+		{name: "denied glob", task: Task{ID: "t", Type: TaskTypeExecution, Payload: map[string]any{"path": "/work/tls.pem"}},
+			want: []string{`path /work/tls.pem is denied by "*.pem"`}},
+		{name: "path outside denied dirs", task: describedTask("t", TaskTypeExecution, "Update config.yaml")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range policy.CheckTask(tt.task) {
+				assert.Equal(t, "policy", violation.Rule)
+				assert.Equal(t, "t", violation.TaskID)
+				got = append(got, violation.Message)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	empty, err := ParsePolicy(nil, "/work")
+	require.NoError(t, err)
+	assert.Empty(t, empty.CheckTask(commandTask("t", "rm -rf /")), "an empty policy allows everything")
+}
+
+func TestPolicy_CheckTask_ShellForms(t *testing.T) {
+	policy, err := ParsePolicy([]byte("commands:\n  deny: [rm, sudo]\n"), "/work")
+	require.NoError(t, err)
+
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"echo $(rm -rf x)", []string{`command "rm -rf x" is denied by "rm"`}},
+		{"echo `rm -rf x`", []string{`command "rm -rf x" is denied by "rm"`}},
+		{"(rm -rf x)", []string{`command "rm -rf x" is denied by "rm"`}},
+		{"true & rm -rf x", []string{`command "rm -rf x" is denied by "rm"`}},
+		{"/bin/rm -rf x", []string{`command "rm -rf x" is denied by "rm"`}},
+		{"sudo rm x", []string{`command "rm x" is denied by "rm"`, `command "sudo" is denied by "sudo"`}},
+		{"env FOO=1 rm x", []string{`command "rm x" is denied by "rm"`}},
+		{"timeout 5 nice -n 2 rm x", []string{`command "rm x" is denied by "rm"`}},
+		{"go test ./... 2>&1 | tee log", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			var got []string
+			for _, violation := range policy.CheckTask(commandTask("t", tt.command)) {
+				got = append(got, violation.Message)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	allowed, err := ParsePolicy([]byte(testPolicy), "/work")
+	require.NoError(t, err)
+	assert.Empty(t, allowed.CheckTask(commandTask("t", "timeout 10m go test ./...")), "wrappers only need to avoid the deny list")
+}
+
+func TestPlanningEngine_ValidatePlan_Policy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy), "/work")
+	require.NoError(t, err)
+	planner := NewPlanningEngine(&MockLLMProvider{})
+	planner.SetRules(NewRuleEngine(MaxTasksRule{Limit: 5}))
+	planner.SetPolicy(policy)
+
+	plan := &ExecutionPlan{ID: "plan-1", Goal: "release", Tasks: []Task{
+		commandTask("task-1", "go test ./..."),
+		commandTask("task-2", "git push origin main"),
+	}}
+	err = planner.ValidatePlan(plan)
+	var violationErr *RuleViolationError
+	require.True(t, errors.As(err, &violationErr))
+	assert.Equal(t, []string{"max_tasks", "policy"}, violationErr.Report.Rules)
+	assert.Equal(t, []Violation{{Rule: "policy", TaskID: "task-2", Message: `command "git push origin main" is denied by "git push"`}},
+		violationErr.Report.Violations)
+
+	plan.Tasks = plan.Tasks[:1]
+	assert.NoError(t, planner.ValidatePlan(plan))
+}
+
+func TestPlanExecutor_PolicyBlocksStep(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy), "/work")
+	require.NoError(t, err)
+	executor := NewPlanExecutor(agents.NewAgentManager())
+	executor.SetPolicy(policy)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	executor.SetClock(clock)
+
+	plan := &ExecutionPlan{ID: "plan-1", Goal: "release", Tasks: []Task{
+		commandTask("task-1", "git push origin main"),
+		commandTask("task-2", "go test ./..."),
+	}}
+	results, _, err := executor.Execute(context.Background(), plan)
+	assert.EqualError(t, err, `execution stopped: step task-1 is blocked by policy: command "git push origin main" is denied by "git push"`)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Success)
+	assert.Equal(t, "denied", results[0].Metadata["policy"])
+	assert.Equal(t, clock.Now(), results[0].Timestamp, "denials are timed by the executor's clock")
+}
@@ -0,0 +1,341 @@
+package captain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+const (
+	// MetadataProvider names the provider that produced a response or plan
+	MetadataProvider = "provider"
+	// MetadataModel names the model that produced a plan
+	MetadataModel = "model"
+	// MetadataTemperature, MetadataTokens and MetadataLatency record the sampling temperature,
+	// the tokens used and how long the request took for the response a plan came from
+	MetadataTemperature = "temperature"
+	MetadataTokens      = "tokens"
+	MetadataLatency     = "latency"
+	// MetadataFailovers holds the errors of the providers tried before the one that produced
+	// a response, one per line
+	MetadataFailovers = "failovers"
+)
+
+// PlanLLM returns the LLM call recorded as producing a plan, and false for plans with none
+// recorded, such as those written by hand
+func PlanLLM(plan *ExecutionPlan) (agents.LLMUsage, bool) {
+	usage := agents.LLMUsage{Provider: plan.Metadata[MetadataProvider], Model: plan.Metadata[MetadataModel]}
+	if usage.Provider == "" && usage.Model == "" {
+		return usage, false
+	}
+	usage.Temperature, _ = strconv.ParseFloat(plan.Metadata[MetadataTemperature], 64)
+	usage.Tokens, _ = strconv.Atoi(plan.Metadata[MetadataTokens])
+	usage.Latency, _ = time.ParseDuration(plan.Metadata[MetadataLatency])
+	return usage, true
+}
+
+// setPlanLLM records the LLM call a plan came from in its metadata
+func setPlanLLM(plan *ExecutionPlan, usage agents.LLMUsage) {
+	if usage.Provider == "" && usage.Model == "" {
+		return
+	}
+	if plan.Metadata == nil {
+		plan.Metadata = map[string]string{}
+	}
+	if usage.Provider != "" {
+		plan.Metadata[MetadataProvider] = usage.Provider
+	}
+	if usage.Model != "" {
+		plan.Metadata[MetadataModel] = usage.Model
+	}
+	plan.Metadata[MetadataTemperature] = strconv.FormatFloat(usage.Temperature, 'g', -1, 64)
+	if usage.Tokens > 0 {
+		plan.Metadata[MetadataTokens] = strconv.Itoa(usage.Tokens)
+	}
+	plan.Metadata[MetadataLatency] = usage.Latency.Round(time.Millisecond).String()
+}
+
+// ProviderChain tries LLM providers in order, moving on to the next when one fails, has its
+// circuit open or has spent its token budget
+type ProviderChain struct {
+	providers []*ResilientProvider
+}
+
+// NewProviderChain creates a chain trying the providers in the given order
+func NewProviderChain(providers ...*ResilientProvider) *ProviderChain {
+	return &ProviderChain{providers: providers}
+}
+
+// Providers returns the names of the providers in the order they are tried
+func (c *ProviderChain) Providers() []string {
+	names := make([]string, len(c.providers))
+	for i, provider := range c.providers {
+		names[i] = provider.Name()
+	}
+	return names
+}
+
+// Health returns the health of every provider in the chain
+func (c *ProviderChain) Health() []ProviderHealth {
+	var health []ProviderHealth
+	for _, provider := range c.providers {
+		health = append(health, provider.Health()...)
+	}
+	return health
+}
+
+// SetHealthHandler sets the function told about the chain's health whenever any provider's changes
+func (c *ProviderChain) SetHealthHandler(handler func([]ProviderHealth)) {
+	for _, provider := range c.providers {
+		if handler == nil {
+			provider.SetHealthHandler(nil)
+			continue
+		}
+		provider.SetHealthHandler(func([]ProviderHealth) { handler(c.Health()) })
+	}
+}
+
+// GenerateCompletion returns the first successful completion, tried on each provider in order.
+// Recorders tried before the provider that answered keep its response, and the response's
+// metadata lists the errors of the providers that failed before it.
+func (c *ProviderChain) GenerateCompletion(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	var errs []error
+	for i, provider := range c.providers {
+		resp, err := provider.GenerateCompletion(ctx, req)
+		if err == nil {
+			for _, recorder := range c.recorders(i) {
+				// A recording that cannot be written costs only the next replay
+				_ = recorder.RecordCompletion(req, resp)
+			}
+			if len(errs) > 0 {
+				failovers := make([]string, len(errs))
+				for j, failure := range errs {
+					failovers[j] = failure.Error()
+				}
+				resp.Metadata[MetadataFailovers] = strings.Join(failovers, "\n")
+			}
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, chainError(errs)
+}
+
+// GenerateEmbedding returns the first successful embedding, tried on each provider in order
+func (c *ProviderChain) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	var errs []error
+	for i, provider := range c.providers {
+		embedding, err := provider.GenerateEmbedding(ctx, text)
+		if err == nil {
+			for _, recorder := range c.recorders(i) {
+				_ = recorder.RecordEmbedding(text, embedding)
+			}
+			return embedding, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, chainError(errs)
+}
+
+// recorders returns the recorders among the providers tried before the one at index answered
+func (c *ProviderChain) recorders(index int) []Recorder {
+	var recorders []Recorder
+	for _, provider := range c.providers[:index] {
+		if recorder, ok := provider.provider.(Recorder); ok {
+			recorders = append(recorders, recorder)
+		}
+	}
+	return recorders
+}
+
+// chainError combines the errors of every provider tried
+func chainError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return fmt.Errorf("no LLM providers configured")
+	case 1:
+		return errs[0]
+	}
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Errorf("all %d LLM providers failed: %s: %w", len(errs), strings.Join(messages, "; "), errors.Join(errs...))
+}
+
+// NewProviderChainFromConfig builds the provider chain configured in cfg.LLM. Without configured
+// providers the chain holds the single OpenAI provider described by openaiConfig, which also
+// supplies the defaults for configured openai providers.
+func NewProviderChainFromConfig(cfg *config.Config, openaiConfig OpenAIConfig) (*ProviderChain, error) {
+	providers := cfg.LLM.Providers
+	if len(providers) == 0 {
+		providers = []config.LLMProviderConfig{{Type: config.ProviderOpenAI}}
+	}
+
+	chain := &ProviderChain{}
+	for _, providerConfig := range providers {
+		provider, err := NewLLMProvider(providerConfig, openaiConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s provider: %w", providerConfig.DisplayName(), err)
+		}
+		resilient := NewResilientProvider(providerConfig.DisplayName(), provider, cfg.LLM)
+		resilient.SetBudget(providerConfig.BudgetTokens)
+		chain.providers = append(chain.providers, resilient)
+	}
+	return chain, nil
+}
+
+// NewLLMProvider creates the provider described by providerConfig. Settings left empty on an
+// openai provider are taken from openaiConfig.
+func NewLLMProvider(providerConfig config.LLMProviderConfig, openaiConfig OpenAIConfig) (LLMProvider, error) {
+	switch providerConfig.Type {
+	case config.ProviderOpenAI:
+		merged := openaiConfig
+		if providerConfig.APIKey != "" {
+			merged.APIKey = providerConfig.APIKey
+		}
+		if providerConfig.Model != "" {
+			merged.Model = providerConfig.Model
+		}
+		if providerConfig.BaseURL != "" {
+			merged.BaseURL = providerConfig.BaseURL
+		}
+		if providerConfig.Temperature != 0 {
+			merged.Temperature = providerConfig.Temperature
+		}
+		if !providerConfig.HTTP.IsZero() {
+			merged.HTTP = providerConfig.HTTP
+		}
+		provider, err := NewOpenAIProvider(merged)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case config.ProviderAnthropic:
+		apiKey := providerConfig.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		return NewAnthropicProvider(AnthropicConfig{
+			APIKey:      apiKey,
+			Model:       providerConfig.Model,
+			BaseURL:     providerConfig.BaseURL,
+			Temperature: providerConfig.Temperature,
+			HTTP:        providerConfig.HTTP,
+		})
+	case config.ProviderOllama:
+		return NewOllamaProvider(OllamaConfig{
+			Model:       providerConfig.Model,
+			BaseURL:     providerConfig.BaseURL,
+			Temperature: providerConfig.Temperature,
+			HTTP:        providerConfig.HTTP,
+		})
+	case config.ProviderOpenAICompatible:
+		return NewOpenAICompatibleProvider(OpenAICompatibleConfig{
+			Name:         providerConfig.DisplayName(),
+			APIKey:       providerConfig.APIKey,
+			Model:        providerConfig.Model,
+			BaseURL:      providerConfig.BaseURL,
+			Temperature:  providerConfig.Temperature,
+			Capabilities: providerConfig.Capabilities,
+			HTTP:         providerConfig.HTTP,
+		})
+	case config.ProviderReplay:
+		dir := providerConfig.Cassette
+		if dir == "" {
+			dir = filepath.Join(config.HomeDir(), "cassettes")
+		}
+		return NewReplayProvider(dir, providerConfig.Record)
+	}
+	return nil, fmt.Errorf("unknown provider type: %s", providerConfig.Type)
+}
+
+// Pinger is implemented by providers that can check their endpoint is reachable and
+// accepts the configured credentials without spending tokens
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ErrModelUnavailable is returned by Ping when the provider does not offer the configured model
+var ErrModelUnavailable = errors.New("model is not available")
+
+// APIError is returned when a provider's API answers with an error status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Unauthorized reports whether the API rejected the credentials
+func (e *APIError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// maxErrorBody bounds how much of a failed response body is included in errors
+const maxErrorBody = 512
+
+// postJSON sends body as JSON to url and decodes the JSON response into out
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(client, req, headers, out)
+}
+
+// getJSON fetches url and decodes the JSON response into out
+func getJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return doJSON(client, req, headers, out)
+}
+
+// doJSON sends req with the extra headers and decodes the JSON response into out
+func doJSON(client *http.Client, req *http.Request, headers map[string]string, out any) error {
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(detail))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestProviderChain_FallsBack(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("503 service unavailable"))
+	secondary := &MockLLMProvider{}
+	secondary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: "from anthropic"}, nil)
+
+	cfg := config.LLMConfig{CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Hour}}
+	chain := NewProviderChain(NewResilientProvider("openai", primary, cfg), NewResilientProvider("anthropic", secondary, cfg))
+	assert.Equal(t, []string{"openai", "anthropic"}, chain.Providers())
+
+	var reports [][]ProviderHealth
+	chain.SetHealthHandler(func(health []ProviderHealth) { reports = append(reports, health) })
+
+	for i := 0; i < 3; i++ {
+		resp, err := chain.GenerateCompletion(context.Background(), completionRequest())
+		require.NoError(t, err)
+		assert.Equal(t, "from anthropic", resp.Content)
+		assert.Equal(t, "anthropic", resp.Metadata[MetadataProvider])
+		assert.NotEmpty(t, resp.Metadata[MetadataFailovers], "the failure of openai is kept in the response")
+	}
+
+	// The third call skipped openai because its circuit was open
+	primary.AssertNumberOfCalls(t, "GenerateCompletion", 2)
+	secondary.AssertNumberOfCalls(t, "GenerateCompletion", 3)
+
+	require.Len(t, reports, 1, "health is reported when the circuit opens")
+	require.Len(t, reports[0], 2)
+	assert.Equal(t, CircuitOpen, reports[0][0].State)
+	assert.Equal(t, "503 service unavailable", reports[0][0].LastError)
+	assert.Equal(t, CircuitClosed, reports[0][1].State)
+}
+
+func TestProviderChain_SkipsExhaustedBudget(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: "openai", TokensUsed: 500}, nil)
+	secondary := &MockLLMProvider{}
+	secondary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: "ollama"}, nil)
+
+	first := NewResilientProvider("openai", primary, config.LLMConfig{})
+	first.SetBudget(500)
+	chain := NewProviderChain(first, NewResilientProvider("ollama", secondary, config.LLMConfig{}))
+
+	resp, err := chain.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "openai", resp.Content)
+
+	resp, err = chain.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", resp.Content)
+	assert.Equal(t, "ollama", resp.Metadata[MetadataProvider])
+}
+
+func TestProviderChain_AllFail(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("boom"))
+	secondary := &MockLLMProvider{}
+	secondary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("also down"))
+
+	single := NewProviderChain(NewResilientProvider("openai", primary, config.LLMConfig{}))
+	_, err := single.GenerateCompletion(context.Background(), completionRequest())
+	assert.EqualError(t, err, "openai: boom", "a single provider's error is returned unchanged")
+
+	chain := NewProviderChain(NewResilientProvider("openai", primary, config.LLMConfig{}), NewResilientProvider("ollama", secondary, config.LLMConfig{}))
+	_, err = chain.GenerateCompletion(context.Background(), completionRequest())
+	assert.ErrorContains(t, err, "all 2 LLM providers failed: openai: boom; ollama: also down")
+
+	_, err = NewProviderChain().GenerateCompletion(context.Background(), completionRequest())
+	assert.EqualError(t, err, "no LLM providers configured")
+}
+
+func TestNewLLMProvider(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	openai := OpenAIConfig{APIKey: "sk-test", Model: "gpt-4"}
+
+	provider, err := NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderOpenAI, Model: "gpt-4o"}, openai)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", provider.(*OpenAIProvider).config.Model)
+	assert.Equal(t, "sk-test", provider.(*OpenAIProvider).config.APIKey, "openai settings are inherited")
+
+	_, err = NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderAnthropic, Model: "claude-sonnet-4"}, openai)
+	assert.ErrorContains(t, err, "api_key")
+
+	t.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+	provider, err = NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderAnthropic, Model: "claude-sonnet-4"}, openai)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.anthropic.com", provider.(*AnthropicProvider).config.BaseURL)
+
+	provider, err = NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderOllama, Model: "llama3"}, openai)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:11434", provider.(*OllamaProvider).config.BaseURL)
+
+	provider, err = NewLLMProvider(config.LLMProviderConfig{Name: "groq", Type: config.ProviderOpenAICompatible, Model: "llama-3.1-70b",
+		BaseURL: "https://api.groq.com/openai/v1/"}, openai)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.groq.com/openai/v1", provider.(*OpenAICompatibleProvider).config.BaseURL)
+	assert.Empty(t, provider.(*OpenAICompatibleProvider).config.APIKey, "openai settings are not inherited")
+
+	t.Setenv("CAPN_HOME", "/var/lib/capn")
+	provider, err = NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderReplay}, openai)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/var/lib/capn", "cassettes"), provider.(*ReplayProvider).dir)
+
+	_, err = NewLLMProvider(config.LLMProviderConfig{Type: "bard"}, openai)
+	assert.EqualError(t, err, "unknown provider type: bard")
+}
+
+func TestNewLLMProvider_HTTP(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"hello"}}`))
+	}))
+	defer proxy.Close()
+
+	provider, err := NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderOllama, Model: "llama3",
+		BaseURL: "http://ollama.corp.invalid:11434", HTTP: config.HTTPConfig{HTTPProxy: proxy.URL}}, OpenAIConfig{})
+	require.NoError(t, err)
+	_, err = provider.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://ollama.corp.invalid:11434/api/chat"}, proxied)
+
+	openai := OpenAIConfig{APIKey: "sk-test", Model: "gpt-4", HTTP: config.HTTPConfig{HTTPSProxy: "http://proxy.corp:3128"}}
+	provider, err = NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderOpenAI}, openai)
+	require.NoError(t, err)
+	assert.Equal(t, openai.HTTP, provider.(*OpenAIProvider).config.HTTP, "openai http settings are inherited")
+	assert.NotSame(t, http.DefaultClient, provider.(*OpenAIProvider).httpClient)
+
+	_, err = NewLLMProvider(config.LLMProviderConfig{Type: config.ProviderOpenAI, HTTP: config.HTTPConfig{CABundle: "/nonexistent/ca.pem"}}, openai)
+	assert.ErrorContains(t, err, "invalid OpenAI config: http: failed to read CA bundle")
+}
+
+func TestAnthropicProvider_GenerateCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "anthropic-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, anthropicVersion, r.Header.Get("anthropic-version"))
+
+		var body anthropicRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "claude-sonnet-4", body.Model)
+		assert.Equal(t, "You plan.", body.System, "system messages become the system prompt")
+		assert.Equal(t, []Message{{Role: "user", Content: "plan this"}}, body.Messages)
+
+		_, _ = w.Write([]byte(`{"model":"claude-sonnet-4","stop_reason":"end_turn","content":[{"type":"text","text":"{\"tasks\":[]}"}],"usage":{"input_tokens":12,"output_tokens":30}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewAnthropicProvider(AnthropicConfig{APIKey: "anthropic-key", Model: "claude-sonnet-4", BaseURL: server.URL + "/"})
+	require.NoError(t, err)
+
+	req := completionRequest()
+	req.Messages = append([]Message{{Role: "system", Content: "You plan."}}, req.Messages...)
+	resp, err := provider.GenerateCompletion(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, `{"tasks":[]}`, resp.Content)
+	assert.Equal(t, 42, resp.TokensUsed)
+	assert.Equal(t, "claude-sonnet-4", resp.Model)
+	assert.Equal(t, "end_turn", resp.FinishReason)
+
+	_, err = provider.GenerateEmbedding(context.Background(), "text")
+	assert.EqualError(t, err, "anthropic provider does not support embeddings")
+}
+
+func TestAnthropicProvider_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"type":"rate_limit_error"}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewAnthropicProvider(AnthropicConfig{APIKey: "anthropic-key", Model: "claude-sonnet-4", BaseURL: server.URL})
+	require.NoError(t, err)
+	_, err = provider.GenerateCompletion(context.Background(), completionRequest())
+	assert.EqualError(t, err, `anthropic completion: request failed with status 429: {"error":{"type":"rate_limit_error"}}`)
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat":
+			var body ollamaChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "llama3", body.Model)
+			assert.False(t, body.Stream)
+			assert.Equal(t, float64(100), body.Options["num_predict"])
+			_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"hello"},"done_reason":"stop","prompt_eval_count":5,"eval_count":7}`))
+		case "/api/embeddings":
+			_, _ = w.Write([]byte(`{"embedding":[0.5,0.25]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(OllamaConfig{Model: "llama3", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	resp, err := provider.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Content)
+	assert.Equal(t, 12, resp.TokensUsed)
+	assert.Equal(t, "stop", resp.FinishReason)
+
+	embedding, err := provider.GenerateEmbedding(context.Background(), "text")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 0.25}, embedding)
+}
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var authorization []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			var body chatCompletionRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "qwen2.5-coder", body.Model)
+			assert.Equal(t, 100, body.MaxTokens)
+			_, _ = w.Write([]byte(`{"model":"qwen2.5-coder","choices":[{"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}],"usage":{"total_tokens":12}}`))
+		case "/v1/embeddings":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "bge-small", body["model"])
+			_, _ = w.Write([]byte(`{"data":[{"embedding":[0.5,0.25]}]}`))
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"data":[{"id":"qwen2.5-coder"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAICompatibleProvider(OpenAICompatibleConfig{Name: "vllm", Model: "qwen2.5-coder", BaseURL: server.URL + "/v1",
+		Capabilities: config.ProviderCapabilities{Embeddings: true, EmbeddingModel: "bge-small"}})
+	require.NoError(t, err)
+	assert.Equal(t, Capabilities{Embeddings: true}, provider.Capabilities())
+
+	resp, err := provider.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Content)
+	assert.Equal(t, 12, resp.TokensUsed)
+	assert.Equal(t, "stop", resp.FinishReason)
+
+	embedding, err := provider.GenerateEmbedding(context.Background(), "text")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 0.25}, embedding)
+	assert.NoError(t, provider.Ping(context.Background()))
+	assert.Equal(t, []string{"", "", ""}, authorization, "no API key is sent unless one is configured")
+
+	keyed, err := NewOpenAICompatibleProvider(OpenAICompatibleConfig{Name: "together", APIKey: "tg-key", Model: "missing", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	_, err = keyed.GenerateEmbedding(context.Background(), "text")
+	assert.EqualError(t, err, "together provider does not support embeddings; set capabilities.embeddings if the server does")
+	assert.ErrorIs(t, keyed.Ping(context.Background()), ErrModelUnavailable)
+	assert.Equal(t, "Bearer tg-key", authorization[len(authorization)-1])
+
+	_, err = NewOpenAICompatibleProvider(OpenAICompatibleConfig{Model: "qwen"})
+	assert.ErrorContains(t, err, "base_url")
+}
+
+func TestProviders_Ping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		switch r.URL.Path {
+		case "/v1/models":
+			if r.Header.Get("x-api-key") != "good-key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":{"type":"authentication_error"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":[{"id":"claude-sonnet-4"}]}`))
+		case "/openai/models":
+			assert.Equal(t, "Bearer openai-key", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"}]}`))
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"llama3:latest"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	anthropic, err := NewAnthropicProvider(AnthropicConfig{APIKey: "good-key", Model: "claude-sonnet-4", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.NoError(t, anthropic.Ping(context.Background()))
+
+	rejected, err := NewAnthropicProvider(AnthropicConfig{APIKey: "bad-key", Model: "claude-sonnet-4", BaseURL: server.URL})
+	require.NoError(t, err)
+	err = rejected.Ping(context.Background())
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.True(t, apiErr.Unauthorized())
+
+	openai, err := NewOpenAIProvider(OpenAIConfig{APIKey: "openai-key", Model: "gpt-4o", BaseURL: server.URL + "/openai"})
+	require.NoError(t, err)
+	assert.NoError(t, openai.Ping(context.Background()))
+
+	ollama, err := NewOllamaProvider(OllamaConfig{Model: "llama3", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.NoError(t, ollama.Ping(context.Background()), "an untagged model matches its latest tag")
+
+	missing, err := NewOllamaProvider(OllamaConfig{Model: "mistral", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.ErrorIs(t, missing.Ping(context.Background()), ErrModelUnavailable)
+}
@@ -0,0 +1,145 @@
+package captain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoRecording is returned by a replay provider asked for a response it has not recorded
+var ErrNoRecording = errors.New("no recorded response")
+
+// Recorder is implemented by providers that keep the responses other providers in the chain
+// produced, so later identical requests need no API call
+type Recorder interface {
+	RecordCompletion(req CompletionRequest, resp *CompletionResponse) error
+	RecordEmbedding(text string, embedding []float64) error
+}
+
+// ReplayProvider answers requests with responses recorded on disk, one file per request named
+// by the hash of the request. Replaying alone makes no API calls and fails requests it has no
+// recording of; when recording, the chain's later providers answer those and their responses
+// are kept for next time.
+type ReplayProvider struct {
+	dir    string
+	record bool
+}
+
+// cassetteEntry is one recorded request and its response
+type cassetteEntry struct {
+	Request    *CompletionRequest  `json:"request,omitempty"`
+	Response   *CompletionResponse `json:"response,omitempty"`
+	Text       string              `json:"text,omitempty"`
+	Embedding  []float64           `json:"embedding,omitempty"`
+	RecordedAt time.Time           `json:"recorded_at"`
+}
+
+// NewReplayProvider creates a provider replaying the recordings in dir, recording new ones
+// when record is set
+func NewReplayProvider(dir string, record bool) (*ReplayProvider, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cassette directory is required")
+	}
+	return &ReplayProvider{dir: dir, record: record}, nil
+}
+
+// Recording reports whether the provider keeps responses it has no recording of
+func (p *ReplayProvider) Recording() bool {
+	return p.record
+}
+
+// GenerateCompletion returns the completion recorded for the request
+func (p *ReplayProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	var entry cassetteEntry
+	if err := p.load(completionKey(req), &entry); err != nil {
+		return nil, err
+	}
+	if entry.Response == nil {
+		return nil, fmt.Errorf("recording for request %s has no completion", completionKey(req))
+	}
+	return entry.Response, nil
+}
+
+// GenerateEmbedding returns the embedding recorded for the text
+func (p *ReplayProvider) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	var entry cassetteEntry
+	if err := p.load(embeddingKey(text), &entry); err != nil {
+		return nil, err
+	}
+	return entry.Embedding, nil
+}
+
+// RecordCompletion keeps a completion another provider returned, when recording
+func (p *ReplayProvider) RecordCompletion(req CompletionRequest, resp *CompletionResponse) error {
+	if !p.record || resp == nil {
+		return nil
+	}
+	return p.save(completionKey(req), cassetteEntry{Request: &req, Response: resp, RecordedAt: time.Now().UTC()})
+}
+
+// RecordEmbedding keeps an embedding another provider returned, when recording
+func (p *ReplayProvider) RecordEmbedding(text string, embedding []float64) error {
+	if !p.record {
+		return nil
+	}
+	return p.save(embeddingKey(text), cassetteEntry{Text: text, Embedding: embedding, RecordedAt: time.Now().UTC()})
+}
+
+func (p *ReplayProvider) load(key string, entry *cassetteEntry) error {
+	data, err := os.ReadFile(p.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w for request %s in %s", ErrNoRecording, key, p.dir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return fmt.Errorf("failed to decode recording %s: %w", p.path(key), err)
+	}
+	return nil
+}
+
+func (p *ReplayProvider) save(key string, entry cassetteEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	// Written aside and renamed so a concurrent replay never reads half a recording
+	tmp := p.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := os.Rename(tmp, p.path(key)); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+func (p *ReplayProvider) path(key string) string {
+	return filepath.Join(p.dir, key+".json")
+}
+
+// completionKey identifies a completion request by the hash of everything sent to the model
+func completionKey(req CompletionRequest) string {
+	data, _ := json.Marshal(req)
+	return requestHash("completion", data)
+}
+
+// embeddingKey identifies an embedding request by the hash of its text
+func embeddingKey(text string) string {
+	return requestHash("embedding", []byte(text))
+}
+
+func requestHash(kind string, data []byte) string {
+	sum := sha256.Sum256(append([]byte(kind+"\x00"), data...))
+	return kind + "-" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestReplayProvider_RecordsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	live := &MockLLMProvider{}
+	live.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: `{"tasks":[]}`, TokensUsed: 42}, nil).Once()
+	live.On("GenerateEmbedding", mock.Anything, "fleet").Return([]float64{0.1, 0.2}, nil).Once()
+
+	// The breaker would open on the first miss if misses counted as failures
+	cfg := config.LLMConfig{CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Hour}}
+	recorder, err := NewReplayProvider(dir, true)
+	require.NoError(t, err)
+	chain := NewProviderChain(NewResilientProvider("replay", recorder, cfg), NewResilientProvider("openai", live, cfg))
+
+	resp, err := chain.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "openai", resp.Metadata[MetadataProvider], "unrecorded requests reach the live provider")
+	embedding, err := chain.GenerateEmbedding(context.Background(), "fleet")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 0.2}, embedding)
+
+	resp, err = chain.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, `{"tasks":[]}`, resp.Content)
+	assert.Equal(t, 42, resp.TokensUsed)
+	assert.Equal(t, "replay", resp.Metadata[MetadataProvider])
+	_, err = chain.GenerateEmbedding(context.Background(), "fleet")
+	require.NoError(t, err)
+	live.AssertExpectations(t)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "one recording per request")
+
+	// Replaying alone answers from the recordings
+	player, err := NewReplayProvider(dir, false)
+	require.NoError(t, err)
+	resp, err = player.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, `{"tasks":[]}`, resp.Content)
+}
+
+func TestReplayProvider_Miss(t *testing.T) {
+	dir := t.TempDir()
+	player, err := NewReplayProvider(dir, false)
+	require.NoError(t, err)
+
+	other := completionRequest()
+	other.Messages[0].Content = "plan something else"
+	_, err = player.GenerateCompletion(context.Background(), other)
+	assert.True(t, errors.Is(err, ErrNoRecording))
+	assert.ErrorContains(t, err, "no recorded response for request completion-")
+	_, err = player.GenerateEmbedding(context.Background(), "fleet")
+	assert.True(t, errors.Is(err, ErrNoRecording))
+
+	require.NoError(t, player.RecordCompletion(other, &CompletionResponse{Content: "ignored"}))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a replay that is not recording keeps nothing")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, completionKey(other)+".json"), []byte("{"), 0o644))
+	_, err = player.GenerateCompletion(context.Background(), other)
+	assert.ErrorContains(t, err, "failed to decode recording")
+
+	_, err = NewReplayProvider("", false)
+	assert.EqualError(t, err, "cassette directory is required")
+}
+
+func TestCompletionKey(t *testing.T) {
+	req := completionRequest()
+	assert.Equal(t, completionKey(req), completionKey(completionRequest()))
+
+	warmer := completionRequest()
+	warmer.Temperature = 0.7
+	assert.NotEqual(t, completionKey(req), completionKey(warmer), "every setting sent to the model is part of the key")
+	assert.NotEqual(t, embeddingKey("plan this"), completionKey(req))
+}
@@ -0,0 +1,157 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// maxReportStepOutput bounds how much of each step's output is sent to the LLM for a report
+const maxReportStepOutput = 2000
+
+// OutcomeReport is the Captain's consolidated account of a finished task
+type OutcomeReport struct {
+	Achieved  string   `json:"achieved"`
+	Failed    []string `json:"failed,omitempty"`
+	Artifacts []string `json:"artifacts,omitempty"`
+	FollowUps []string `json:"follow_ups,omitempty"`
+	// Citations lists the sources the research steps relied on, near-identical ones merged
+	Citations []agents.Citation `json:"citations,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// ReportInput is the finished work an outcome report is written about
+type ReportInput struct {
+	Goal    string
+	Plan    *ExecutionPlan
+	Results []Result
+	// Artifacts names the artifacts the steps produced
+	Artifacts []string
+}
+
+// Text renders the report as plain text for terminals and notifications
+func (r *OutcomeReport) Text() string {
+	var b strings.Builder
+	b.WriteString(r.Achieved)
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n\n%s:", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "\n- %s", item)
+		}
+	}
+	section("Failed", r.Failed)
+	section("Artifacts", r.Artifacts)
+	section("Follow-up", r.FollowUps)
+	citations := make([]string, len(r.Citations))
+	for i, citation := range r.Citations {
+		citations[i] = citation.String()
+	}
+	section("Citations", citations)
+	return b.String()
+}
+
+// GenerateReport asks the LLM for an outcome report on a finished task: what was achieved,
+// what failed and what to do next. The artifacts and the steps' citations are listed as given
+// rather than by the LLM. The report is written in the language of the plan, or else of the
+// captain.language setting or the goal.
+func (c *Captain) GenerateReport(ctx context.Context, input ReportInput) (*OutcomeReport, error) {
+	if c.llmProvider == nil {
+		return nil, fmt.Errorf("no LLM provider configured")
+	}
+
+	systemPrompt := `You write outcome reports for tasks run by a captain agent coordinating a crew of agents.
+Given the goal, the plan's steps and how each one ended, respond with only a JSON object:
+{"achieved": "one to three plain sentences on what was accomplished and whether the goal was met",
+ "failed": ["one entry per step that failed or did not run, saying why"],
+ "follow_ups": ["concrete next actions for the person who submitted the goal"]}
+Leave a list empty when there is nothing to say. Do not repeat command output verbatim.`
+	language := ResponseLanguage(c.languageSetting(), input.Goal)
+	if input.Plan != nil && input.Plan.Metadata[MetadataLanguage] != "" {
+		language = input.Plan.Metadata[MetadataLanguage]
+	}
+	if instruction := languageInstruction(language); instruction != "" {
+		systemPrompt += "\n\n" + instruction
+	}
+
+	req := CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: reportPrompt(input)},
+		},
+		MaxTokens:   800,
+		Temperature: 0.2,
+	}
+
+	resp, err := c.llmProvider.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate report: %w", err)
+	}
+	report := &OutcomeReport{}
+	if err := json.Unmarshal([]byte(extractJSON(resp.Content)), report); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	if report.Achieved = strings.TrimSpace(report.Achieved); report.Achieved == "" {
+		return nil, fmt.Errorf("failed to parse report: no achievements described")
+	}
+	report.Artifacts = input.Artifacts
+	report.Citations = reportCitations(input.Results)
+	report.CreatedAt = time.Now()
+	return report, nil
+}
+
+// reportPrompt describes the goal, each planned step and its result for the LLM
+func reportPrompt(input ReportInput) string {
+	results := make(map[string]Result, len(input.Results))
+	for _, result := range input.Results {
+		results[result.TaskID] = result
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Goal: %s\n", input.Goal)
+	if input.Plan != nil {
+		b.WriteString("\nSteps:\n")
+		for _, step := range input.Plan.Tasks {
+			description, _ := step.Payload["description"].(string)
+			fmt.Fprintf(&b, "- %s [%s] %s: ", step.ID, step.Type, description)
+			result, ok := results[step.ID]
+			switch {
+			case !ok:
+				b.WriteString("not run\n")
+			case result.Success:
+				b.WriteString("succeeded\n")
+			default:
+				fmt.Fprintf(&b, "failed: %s\n", result.Error)
+			}
+			if output := strings.TrimSpace(result.Output); output != "" {
+				if len(output) > maxReportStepOutput {
+					output = output[:maxReportStepOutput] + "…"
+				}
+				fmt.Fprintf(&b, "  Output: %s\n", output)
+			}
+		}
+	}
+	if len(input.Artifacts) > 0 {
+		fmt.Fprintf(&b, "\nArtifacts: %s\n", strings.Join(input.Artifacts, ", "))
+	}
+	return b.String()
+}
+
+// reportCitations gathers the sources the steps cited, in step order, merging near-identical
+// sources cited by more than one step
+func reportCitations(results []Result) []agents.Citation {
+	var citations []agents.Citation
+	for _, result := range results {
+		citations = append(citations, agents.CitationsFrom(result.Metadata)...)
+	}
+	if len(citations) == 0 {
+		return nil
+	}
+	return agents.DedupeCitations(citations)
+}
@@ -0,0 +1,111 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func reportInput() ReportInput {
+	return ReportInput{
+		Goal: "ship the release",
+		Plan: &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+			describedTask("build", TaskTypeExecution, "Build the binaries"),
+			describedTask("test", TaskTypeValidation, "Run the tests"),
+			describedTask("publish", TaskTypeExecution, "Publish the release"),
+		}},
+		Results: []Result{
+			{TaskID: "build", Success: true, Output: "built 3 binaries"},
+			{TaskID: "test", Success: false, Error: "2 tests failed"},
+		},
+		Artifacts: []string{"capn-linux-amd64"},
+	}
+}
+
+func TestCaptain_GenerateReport(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		prompt := req.Messages[1].Content
+		return assert.Contains(t, prompt, "Goal: ship the release\n") &&
+			assert.Contains(t, prompt, "- build [execution] Build the binaries: succeeded\n  Output: built 3 binaries\n") &&
+			assert.Contains(t, prompt, "- test [validation] Run the tests: failed: 2 tests failed\n") &&
+			assert.Contains(t, prompt, "- publish [execution] Publish the release: not run\n") &&
+			assert.Contains(t, prompt, "Artifacts: capn-linux-amd64\n")
+	})).Return(&CompletionResponse{Content: "```json\n" + `{"achieved": "The binaries were built but the release was not published.",
+"failed": ["test: 2 tests failed", "publish: not run"], "follow_ups": ["Fix the failing tests"], "artifacts": ["invented"]}` + "\n```"}, nil)
+
+	report, err := captain.GenerateReport(context.Background(), reportInput())
+	require.NoError(t, err)
+	assert.Equal(t, "The binaries were built but the release was not published.", report.Achieved)
+	assert.Equal(t, []string{"test: 2 tests failed", "publish: not run"}, report.Failed)
+	assert.Equal(t, []string{"Fix the failing tests"}, report.FollowUps)
+	assert.Equal(t, []string{"capn-linux-amd64"}, report.Artifacts, "artifacts come from the task, not the LLM")
+	assert.False(t, report.CreatedAt.IsZero())
+
+	assert.Equal(t, `The binaries were built but the release was not published.
+
+Failed:
+- test: 2 tests failed
+- publish: not run
+
+Artifacts:
+- capn-linux-amd64
+
+Follow-up:
+- Fix the failing tests`, report.Text())
+}
+
+func TestCaptain_GenerateReport_Errors(t *testing.T) {
+	_, err := (&Captain{ID: "captain-1"}).GenerateReport(context.Background(), reportInput())
+	assert.EqualError(t, err, "no LLM provider configured")
+
+	failing := &MockLLMProvider{}
+	failing.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("rate limited"))
+	_, err = (&Captain{ID: "captain-1", llmProvider: failing}).GenerateReport(context.Background(), reportInput())
+	assert.EqualError(t, err, "failed to generate report: rate limited")
+
+	empty := &MockLLMProvider{}
+	empty.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: `{"failed": []}`}, nil)
+	_, err = (&Captain{ID: "captain-1", llmProvider: empty}).GenerateReport(context.Background(), reportInput())
+	assert.EqualError(t, err, "failed to parse report: no achievements described")
+}
+
+func TestCaptain_GenerateReport_Citations(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{
+		Content: `{"achieved": "Compared the queueing libraries."}`}, nil)
+
+	retrieved := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	input := reportInput()
+	input.Results = []Result{
+		{TaskID: "build", Success: true, Metadata: map[string]any{agents.ResultCitations: []agents.Citation{
+			{URL: "https://go.dev/doc/effective_go", Title: "Effective Go", RetrievedAt: retrieved, Confidence: 0.9},
+		}}},
+		// Results read back from storage hold their citations decoded from JSON
+		{TaskID: "test", Success: true, Metadata: map[string]any{agents.ResultCitations: []any{
+			map[string]any{"url": "http://www.go.dev/doc/effective_go/?utm_source=feed", "confidence": 0.5, "retrieved_at": "2026-10-02T09:00:00Z"},
+			map[string]any{"url": "https://pkg.go.dev/sync", "confidence": 0.7, "retrieved_at": "2026-10-02T09:00:00Z"},
+		}}},
+	}
+
+	report, err := captain.GenerateReport(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, []agents.Citation{
+		{URL: "https://go.dev/doc/effective_go", Title: "Effective Go", RetrievedAt: retrieved, Confidence: 0.9},
+		{URL: "https://pkg.go.dev/sync", RetrievedAt: retrieved.Add(24 * time.Hour), Confidence: 0.7},
+	}, report.Citations, "sources cited by several steps are listed once")
+	assert.Contains(t, report.Text(), `
+
+Citations:
+- Effective Go (https://go.dev/doc/effective_go), retrieved 2026-10-01, confidence 0.90
+- https://pkg.go.dev/sync, retrieved 2026-10-02, confidence 0.70`)
+}
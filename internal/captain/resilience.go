@@ -0,0 +1,449 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+var (
+	// ErrCircuitOpen is returned when a provider's circuit breaker is rejecting calls
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrBudgetExhausted is returned when a provider has spent its token budget
+	ErrBudgetExhausted = errors.New("token budget exhausted")
+)
+
+// CircuitState is the state of a provider's circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed lets every call through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects calls until the cooldown has passed
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single trial call through to test whether the provider recovered
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreaker stops calling a provider after consecutive failures. Once the cooldown has
+// passed a single trial call is allowed; success closes the circuit and failure reopens it.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	lastError string
+	trial     bool
+	clock     common.Clock
+}
+
+// NewCircuitBreaker creates a closed circuit breaker opening after threshold consecutive failures;
+// a zero threshold never opens
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+		clock:     common.SystemClock,
+	}
+}
+
+// SetClock sets the clock the cooldown is timed with
+func (b *CircuitBreaker) SetClock(clock common.Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+}
+
+// Allow reports whether a call may be made, returning ErrCircuitOpen when it may not
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.clock.Now().Before(b.openedAt.Add(b.cooldown)) {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.trial = true
+	case CircuitHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Success records a successful call, closing the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call, opening the circuit at the threshold or when a trial call fails
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == CircuitHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = CircuitOpen
+		b.openedAt = b.clock.Now()
+	}
+	b.trial = false
+}
+
+// release lets another trial call through after one ended without a verdict
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// State returns the current circuit state
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// health reports the breaker's state for the named provider
+func (b *CircuitBreaker) health(name string) ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := ProviderHealth{
+		Name:                name,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+		UpdatedAt:           b.clock.Now(),
+	}
+	if b.state != CircuitClosed {
+		h.RetryAt = b.openedAt.Add(b.cooldown)
+	}
+	return h
+}
+
+// RateLimiter spaces out provider calls to stay within requests and tokens per minute budgets.
+// Calls over budget wait for earlier calls to leave the one-minute window.
+type RateLimiter struct {
+	mu                sync.Mutex
+	requestsPerMinute int
+	tokensPerMinute   int
+	window            []*rateEntry
+	clock             common.Clock
+}
+
+// rateEntry is one call counted against the rate limits
+type rateEntry struct {
+	at     time.Time
+	tokens int
+}
+
+// NewRateLimiter creates a rate limiter; a zero limit is not enforced
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+		clock:             common.SystemClock,
+	}
+}
+
+// SetClock sets the clock the one-minute window is measured and waited out with
+func (l *RateLimiter) SetClock(clock common.Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock
+}
+
+// Wait blocks until a call using the given number of tokens fits within the limits or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	_, err := l.reserve(ctx, tokens)
+	return err
+}
+
+// reserve waits for room in the window and records the call, returning its entry so the
+// estimated tokens can be corrected once the provider reports actual usage
+func (l *RateLimiter) reserve(ctx context.Context, tokens int) (*rateEntry, error) {
+	for {
+		l.mu.Lock()
+		entry, wait := l.tryReserve(tokens)
+		clock := l.clock
+		l.mu.Unlock()
+		if entry != nil {
+			return entry, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("rate limited: %w", ctx.Err())
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// tryReserve records the call when it fits, otherwise returns how long until the oldest call expires
+func (l *RateLimiter) tryReserve(tokens int) (*rateEntry, time.Duration) {
+	now := l.clock.Now()
+	cutoff := now.Add(-time.Minute)
+	recent := l.window[:0]
+	used := 0
+	for _, entry := range l.window {
+		if entry.at.After(cutoff) {
+			recent = append(recent, entry)
+			used += entry.tokens
+		}
+	}
+	l.window = recent
+
+	overRequests := l.requestsPerMinute > 0 && len(l.window) >= l.requestsPerMinute
+	// A call larger than the whole token budget is let through once the window is empty
+	overTokens := l.tokensPerMinute > 0 && len(l.window) > 0 && used+tokens > l.tokensPerMinute
+	if overRequests || overTokens {
+		return nil, l.window[0].at.Add(time.Minute).Sub(now)
+	}
+
+	entry := &rateEntry{at: now, tokens: tokens}
+	l.window = append(l.window, entry)
+	return entry, 0
+}
+
+// settle replaces a reserved call's estimated tokens with the tokens actually used
+func (l *RateLimiter) settle(entry *rateEntry, tokens int) {
+	if entry == nil || tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.tokens = tokens
+}
+
+// ProviderHealth is a snapshot of an LLM provider's circuit breaker, shown by "capn status"
+type ProviderHealth struct {
+	Name                string       `json:"name"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	RetryAt             time.Time    `json:"retry_at,omitempty"`
+	TokensSpent         int          `json:"tokens_spent,omitempty"`
+	BudgetTokens        int          `json:"budget_tokens,omitempty"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+}
+
+// BudgetExhausted reports whether the provider has spent its whole token budget
+func (h ProviderHealth) BudgetExhausted() bool {
+	return h.BudgetTokens > 0 && h.TokensSpent >= h.BudgetTokens
+}
+
+// ResilientProvider wraps an LLMProvider with a rate limiter, a circuit breaker and an optional
+// token budget. Successful responses are tagged with the provider's name.
+type ResilientProvider struct {
+	name     string
+	provider LLMProvider
+	limiter  *RateLimiter
+	breaker  *CircuitBreaker
+
+	mu       sync.Mutex
+	budget   int
+	spent    int
+	onHealth func([]ProviderHealth)
+}
+
+// NewResilientProvider wraps provider with the rate limits and circuit breaker from cfg.
+// The name identifies the provider in health reports and response metadata.
+func NewResilientProvider(name string, provider LLMProvider, cfg config.LLMConfig) *ResilientProvider {
+	return &ResilientProvider{
+		name:     name,
+		provider: provider,
+		limiter:  NewRateLimiter(cfg.RequestsPerMinute, cfg.TokensPerMinute),
+		breaker:  NewCircuitBreaker(cfg.CircuitBreaker.FailureThreshold, cfg.CircuitBreaker.Cooldown),
+	}
+}
+
+// Name returns the name the provider reports under
+func (p *ResilientProvider) Name() string {
+	return p.name
+}
+
+// SetBudget limits the tokens the provider may spend; once spent, calls fail with ErrBudgetExhausted.
+// Zero means unlimited.
+func (p *ResilientProvider) SetBudget(tokens int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.budget = tokens
+}
+
+// SetHealthHandler sets the function told about provider health whenever its circuit changes
+// state or its budget runs out
+func (p *ResilientProvider) SetHealthHandler(handler func([]ProviderHealth)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onHealth = handler
+}
+
+// Health returns the provider's circuit breaker state and token spending
+func (p *ResilientProvider) Health() []ProviderHealth {
+	health := p.breaker.health(p.name)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	health.TokensSpent = p.spent
+	health.BudgetTokens = p.budget
+	return []ProviderHealth{health}
+}
+
+// GenerateCompletion generates a completion once the rate limits allow it
+func (p *ResilientProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	estimate := estimateTokens(req)
+	var resp *CompletionResponse
+	err := p.call(ctx, estimate, func() (int, error) {
+		var err error
+		resp, err = p.provider.GenerateCompletion(ctx, req)
+		if err != nil {
+			return 0, err
+		}
+		if resp.TokensUsed > 0 {
+			return resp.TokensUsed, nil
+		}
+		return estimate, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]string)
+	}
+	resp.Metadata[MetadataProvider] = p.name
+	return resp, nil
+}
+
+// GenerateEmbedding generates an embedding once the rate limits allow it
+func (p *ResilientProvider) GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	estimate := len(text)/4 + 1
+	var embedding []float64
+	err := p.call(ctx, estimate, func() (int, error) {
+		var err error
+		embedding, err = p.provider.GenerateEmbedding(ctx, text)
+		return estimate, err
+	})
+	return embedding, err
+}
+
+// call runs fn through the budget, circuit breaker and rate limiter. fn returns the tokens it used.
+func (p *ResilientProvider) call(ctx context.Context, tokens int, fn func() (int, error)) error {
+	if p.exhausted() {
+		return fmt.Errorf("%s: %w", p.name, ErrBudgetExhausted)
+	}
+	if err := p.breaker.Allow(); err != nil {
+		return fmt.Errorf("%s: %w", p.name, err)
+	}
+	before := p.breaker.State()
+
+	entry, err := p.limiter.reserve(ctx, tokens)
+	if err == nil {
+		var used int
+		if used, err = fn(); err == nil {
+			p.limiter.settle(entry, used)
+			p.spend(used)
+		}
+	}
+
+	switch {
+	case err == nil:
+		p.breaker.Success()
+	case ctx.Err() != nil || errors.Is(err, ErrNoRecording):
+		// Cancellation and requests missing from a replay's recordings are not the provider's
+		// fault, so the call is not counted either way
+		p.breaker.release()
+	default:
+		p.breaker.Failure(err)
+	}
+	if p.breaker.State() != before || (err == nil && p.exhausted()) {
+		p.reportHealth()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", p.name, err)
+	}
+	return nil
+}
+
+// spend records tokens used against the budget
+func (p *ResilientProvider) spend(tokens int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spent += tokens
+}
+
+// exhausted reports whether the provider has spent its whole budget
+func (p *ResilientProvider) exhausted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.budget > 0 && p.spent >= p.budget
+}
+
+// reportHealth passes the current health to the health handler
+func (p *ResilientProvider) reportHealth() {
+	p.mu.Lock()
+	handler := p.onHealth
+	p.mu.Unlock()
+	if handler != nil {
+		handler(p.Health())
+	}
+}
+
+// estimateTokens approximates the tokens a request uses: roughly four characters per prompt
+// token plus the completion budget
+func estimateTokens(req CompletionRequest) int {
+	chars := 0
+	for _, message := range req.Messages {
+		chars += len(message.Content)
+	}
+	return chars/4 + req.MaxTokens
+}
+
+// WriteProviderHealth records provider health in a file so other capn processes can show it
+func WriteProviderHealth(path string, health []ProviderHealth) error {
+	data, err := json.MarshalIndent(health, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provider health: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create provider health directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write provider health: %w", err)
+	}
+	return nil
+}
+
+// ReadProviderHealth reads provider health written by WriteProviderHealth; a missing file means none was recorded
+func ReadProviderHealth(path string) ([]ProviderHealth, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider health: %w", err)
+	}
+	var health []ProviderHealth
+	if err := json.Unmarshal(data, &health); err != nil {
+		return nil, fmt.Errorf("failed to parse provider health: %w", err)
+	}
+	return health, nil
+}
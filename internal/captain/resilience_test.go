@@ -0,0 +1,176 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// waitInBackground starts a limiter wait and checks it is still waiting once it blocks on the clock
+func waitInBackground(t *testing.T, clock *testutil.FakeClock, limiter *RateLimiter, tokens int) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- limiter.Wait(context.Background(), tokens) }()
+	clock.BlockUntil(1)
+	select {
+	case err := <-done:
+		t.Fatalf("the call did not wait: %v", err)
+	default:
+	}
+	return done
+}
+
+func completionRequest() CompletionRequest {
+	return CompletionRequest{Messages: []Message{{Role: "user", Content: "plan this"}}, MaxTokens: 100, Temperature: 0.1}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.SetClock(clock)
+
+	require.NoError(t, breaker.Allow())
+	breaker.Failure(errors.New("timeout"))
+	assert.Equal(t, CircuitClosed, breaker.State(), "one failure is below the threshold")
+	breaker.Failure(errors.New("timeout"))
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+	health := breaker.health("openai")
+	assert.Equal(t, 2, health.ConsecutiveFailures)
+	assert.Equal(t, "timeout", health.LastError)
+	assert.Equal(t, clock.Now().Add(time.Minute), health.RetryAt)
+
+	// After the cooldown a single trial call is let through
+	clock.Advance(time.Minute)
+	require.NoError(t, breaker.Allow())
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen, "only one trial at a time")
+
+	breaker.Failure(errors.New("still down"))
+	assert.Equal(t, CircuitOpen, breaker.State(), "a failed trial reopens the circuit")
+
+	clock.Advance(time.Minute)
+	require.NoError(t, breaker.Allow())
+	breaker.Success()
+	assert.Equal(t, CircuitClosed, breaker.State())
+	assert.Equal(t, 0, breaker.health("openai").ConsecutiveFailures)
+}
+
+func TestCircuitBreaker_ZeroThresholdNeverOpens(t *testing.T) {
+	breaker := NewCircuitBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		breaker.Failure(errors.New("boom"))
+	}
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestRateLimiter_Requests(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	limiter := NewRateLimiter(2, 0)
+	limiter.SetClock(clock)
+
+	// Calls within the budget return without waiting on the clock
+	require.NoError(t, limiter.Wait(context.Background(), 10))
+	require.NoError(t, limiter.Wait(context.Background(), 10))
+
+	done := waitInBackground(t, clock, limiter, 10)
+	clock.Advance(time.Minute)
+	require.NoError(t, <-done, "the third call waits for the first to leave the window")
+}
+
+func TestRateLimiter_Tokens(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	limiter := NewRateLimiter(0, 1000)
+	limiter.SetClock(clock)
+
+	entry, err := limiter.reserve(context.Background(), 900)
+	require.NoError(t, err)
+	limiter.settle(entry, 300)
+
+	require.NoError(t, limiter.Wait(context.Background(), 600), "settled usage frees the unused estimate")
+
+	done := waitInBackground(t, clock, limiter, 5000)
+	clock.Advance(time.Minute)
+	require.NoError(t, <-done, "an oversized call runs once the window is empty")
+}
+
+func TestRateLimiter_Cancelled(t *testing.T) {
+	limiter := NewRateLimiter(1, 0)
+	require.NoError(t, limiter.Wait(context.Background(), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := limiter.Wait(ctx, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "rate limited")
+}
+
+func TestResilientProvider_OpensCircuit(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("boom"))
+
+	cfg := config.LLMConfig{CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Hour}}
+	provider := NewResilientProvider("openai", primary, cfg)
+
+	_, err := provider.GenerateCompletion(context.Background(), completionRequest())
+	assert.EqualError(t, err, "openai: boom")
+
+	_, err = provider.GenerateCompletion(context.Background(), completionRequest())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	primary.AssertNumberOfCalls(t, "GenerateCompletion", 1)
+
+	health := provider.Health()
+	require.Len(t, health, 1)
+	assert.Equal(t, CircuitOpen, health[0].State)
+}
+
+func TestResilientProvider_Budget(t *testing.T) {
+	primary := &MockLLMProvider{}
+	primary.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: "plan", TokensUsed: 600}, nil)
+
+	provider := NewResilientProvider("openai", primary, config.LLMConfig{})
+	provider.SetBudget(1000)
+	var reports [][]ProviderHealth
+	provider.SetHealthHandler(func(health []ProviderHealth) { reports = append(reports, health) })
+
+	resp, err := provider.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "openai", resp.Metadata[MetadataProvider])
+	assert.Empty(t, reports)
+
+	_, err = provider.GenerateCompletion(context.Background(), completionRequest())
+	require.NoError(t, err, "the call that crosses the budget still completes")
+	require.Len(t, reports, 1)
+	assert.True(t, reports[0][0].BudgetExhausted())
+	assert.Equal(t, 1200, reports[0][0].TokensSpent)
+
+	_, err = provider.GenerateCompletion(context.Background(), completionRequest())
+	assert.ErrorIs(t, err, ErrBudgetExhausted)
+	primary.AssertNumberOfCalls(t, "GenerateCompletion", 2)
+	assert.Equal(t, CircuitClosed, provider.Health()[0].State, "an exhausted budget is not a provider failure")
+}
+
+func TestProviderHealthFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "provider-health.json")
+
+	health, err := ReadProviderHealth(path)
+	require.NoError(t, err)
+	assert.Nil(t, health, "a missing file means no health was recorded")
+
+	written := []ProviderHealth{{Name: "openai", State: CircuitOpen, ConsecutiveFailures: 5, LastError: "timeout"}}
+	require.NoError(t, WriteProviderHealth(path, written))
+
+	health, err = ReadProviderHealth(path)
+	require.NoError(t, err)
+	assert.Equal(t, written, health)
+}
@@ -0,0 +1,287 @@
+package captain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// Violation is a single breach of a plan rule
+type Violation struct {
+	Rule    string `json:"rule"`
+	TaskID  string `json:"task_id,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats the violation for display
+func (v Violation) String() string {
+	if v.TaskID == "" {
+		return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", v.Rule, v.TaskID, v.Message)
+}
+
+// ValidationReport collects the violations found by the rule engine
+type ValidationReport struct {
+	PlanID     string      `json:"plan_id"`
+	Rules      []string    `json:"rules"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Valid reports whether the plan passed every rule
+func (r *ValidationReport) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// RuleViolationError is returned when a plan breaks one or more rules
+type RuleViolationError struct {
+	Report *ValidationReport
+}
+
+func (e *RuleViolationError) Error() string {
+	messages := make([]string, len(e.Report.Violations))
+	for i, violation := range e.Report.Violations {
+		messages[i] = violation.String()
+	}
+	return fmt.Sprintf("plan violates %d rule(s): %s", len(messages), strings.Join(messages, "; "))
+}
+
+// PlanRule is a deterministic check run against a plan before it is accepted
+type PlanRule interface {
+	Name() string
+	Check(plan *ExecutionPlan) []Violation
+}
+
+// RuleEngine runs static plan rules
+type RuleEngine struct {
+	rules []PlanRule
+}
+
+// NewRuleEngine creates a rule engine running the given rules in order
+func NewRuleEngine(rules ...PlanRule) *RuleEngine {
+	return &RuleEngine{rules: rules}
+}
+
+// NewRuleEngineFromConfig creates a rule engine with a rule for each configured limit
+func NewRuleEngineFromConfig(cfg config.PlanRulesConfig) *RuleEngine {
+	var rules []PlanRule
+	if cfg.MaxTasks > 0 {
+		rules = append(rules, MaxTasksRule{Limit: cfg.MaxTasks})
+	}
+	if cfg.MaxDuration > 0 {
+		rules = append(rules, MaxDurationRule{Limit: cfg.MaxDuration})
+	}
+	if len(cfg.ForbiddenCommands) > 0 {
+		rules = append(rules, ForbiddenCommandsRule{Commands: cfg.ForbiddenCommands})
+	}
+	if len(cfg.RequiredSteps) > 0 {
+		rules = append(rules, RequiredStepsRule{Types: cfg.RequiredSteps})
+	}
+	if cfg.MaxDependencyDepth > 0 {
+		rules = append(rules, MaxDependencyDepthRule{Limit: cfg.MaxDependencyDepth})
+	}
+	return NewRuleEngine(rules...)
+}
+
+// Add appends rules to the engine
+func (re *RuleEngine) Add(rules ...PlanRule) {
+	re.rules = append(re.rules, rules...)
+}
+
+// With returns an engine running the engine's rules followed by the given ones, leaving the
+// engine itself unchanged. A nil engine runs only the given rules.
+func (re *RuleEngine) With(rules ...PlanRule) *RuleEngine {
+	if re == nil {
+		return NewRuleEngine(rules...)
+	}
+	return NewRuleEngine(append(append([]PlanRule(nil), re.rules...), rules...)...)
+}
+
+// Rules returns the names of the rules the engine runs
+func (re *RuleEngine) Rules() []string {
+	names := make([]string, len(re.rules))
+	for i, rule := range re.rules {
+		names[i] = rule.Name()
+	}
+	return names
+}
+
+// Check runs every rule against the plan and reports all violations
+func (re *RuleEngine) Check(plan *ExecutionPlan) *ValidationReport {
+	report := &ValidationReport{PlanID: plan.ID, Rules: re.Rules()}
+	for _, rule := range re.rules {
+		report.Violations = append(report.Violations, rule.Check(plan)...)
+	}
+	return report
+}
+
+// Validate checks the plan, returning a *RuleViolationError when any rule is broken
+func (re *RuleEngine) Validate(plan *ExecutionPlan) error {
+	if report := re.Check(plan); !report.Valid() {
+		return &RuleViolationError{Report: report}
+	}
+	return nil
+}
+
+// MaxTasksRule limits the number of steps in a plan
+type MaxTasksRule struct {
+	Limit int
+}
+
+// Name returns the rule name
+func (r MaxTasksRule) Name() string { return "max_tasks" }
+
+// Check reports plans with more than Limit steps
+func (r MaxTasksRule) Check(plan *ExecutionPlan) []Violation {
+	if len(plan.Tasks) <= r.Limit {
+		return nil
+	}
+	return []Violation{{
+		Rule:    r.Name(),
+		Message: fmt.Sprintf("plan has %d tasks, limit is %d", len(plan.Tasks), r.Limit),
+	}}
+}
+
+// MaxDurationRule limits a plan's estimated duration
+type MaxDurationRule struct {
+	Limit time.Duration
+}
+
+// Name returns the rule name
+func (r MaxDurationRule) Name() string { return "max_duration" }
+
+// Check reports plans estimated to run longer than Limit
+func (r MaxDurationRule) Check(plan *ExecutionPlan) []Violation {
+	if plan.Timeline.EstimatedDuration <= r.Limit {
+		return nil
+	}
+	return []Violation{{
+		Rule:    r.Name(),
+		Message: fmt.Sprintf("plan is estimated to take %s, limit is %s", plan.Timeline.EstimatedDuration, r.Limit),
+	}}
+}
+
+// ForbiddenCommandsRule rejects steps whose command or description mentions a forbidden command
+type ForbiddenCommandsRule struct {
+	Commands []string
+}
+
+// Name returns the rule name
+func (r ForbiddenCommandsRule) Name() string { return "forbidden_commands" }
+
+// Check reports each step that mentions a forbidden command, matched case-insensitively
+func (r ForbiddenCommandsRule) Check(plan *ExecutionPlan) []Violation {
+	var violations []Violation
+	for _, task := range plan.Tasks {
+		var texts []string
+		if command, ok := task.Payload["command"].(string); ok {
+			texts = append(texts, command)
+		}
+		if description, ok := task.Payload["description"].(string); ok {
+			texts = append(texts, description)
+		}
+		text := strings.ToLower(strings.Join(texts, "\n"))
+
+		for _, forbidden := range r.Commands {
+			if forbidden != "" && strings.Contains(text, strings.ToLower(forbidden)) {
+				violations = append(violations, Violation{
+					Rule:    r.Name(),
+					TaskID:  task.ID,
+					Message: fmt.Sprintf("uses forbidden command %q", forbidden),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// RequiredStepsRule requires the plan to contain at least one step of each listed type
+type RequiredStepsRule struct {
+	Types []string
+}
+
+// Name returns the rule name
+func (r RequiredStepsRule) Name() string { return "required_steps" }
+
+// Check reports each required step type missing from the plan
+func (r RequiredStepsRule) Check(plan *ExecutionPlan) []Violation {
+	present := make(map[TaskType]bool)
+	for _, task := range plan.Tasks {
+		present[task.Type] = true
+	}
+
+	var violations []Violation
+	for _, required := range r.Types {
+		if !present[TaskType(required)] {
+			violations = append(violations, Violation{
+				Rule:    r.Name(),
+				Message: fmt.Sprintf("plan has no %s step", required),
+			})
+		}
+	}
+	return violations
+}
+
+// MaxDependencyDepthRule limits the length of the longest dependency chain
+type MaxDependencyDepthRule struct {
+	Limit int
+}
+
+// Name returns the rule name
+func (r MaxDependencyDepthRule) Name() string { return "max_dependency_depth" }
+
+// Check reports steps at the end of a dependency chain deeper than Limit
+func (r MaxDependencyDepthRule) Check(plan *ExecutionPlan) []Violation {
+	var violations []Violation
+	depths := DependencyDepths(plan.Tasks)
+	for _, task := range plan.Tasks {
+		if depth := depths[task.ID]; depth > r.Limit {
+			violations = append(violations, Violation{
+				Rule:    r.Name(),
+				TaskID:  task.ID,
+				Message: fmt.Sprintf("dependency depth is %d, limit is %d", depth, r.Limit),
+			})
+		}
+	}
+	return violations
+}
+
+// DependencyDepths returns the number of steps in the longest dependency chain ending at each task.
+// A task without dependencies has depth 1. Unknown dependencies are ignored and cycles are cut.
+func DependencyDepths(tasks []Task) map[string]int {
+	deps := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		deps[task.ID] = task.Dependencies
+	}
+
+	depths := make(map[string]int, len(tasks))
+	visiting := make(map[string]bool)
+	var depth func(id string) int
+	depth = func(id string) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		if visiting[id] {
+			return 0
+		}
+		visiting[id] = true
+		longest := 0
+		for _, dep := range deps[id] {
+			if _, known := deps[dep]; known {
+				if d := depth(dep); d > longest {
+					longest = d
+				}
+			}
+		}
+		visiting[id] = false
+		depths[id] = longest + 1
+		return depths[id]
+	}
+
+	for _, task := range tasks {
+		depth(task.ID)
+	}
+	return depths
+}
@@ -0,0 +1,134 @@
+package captain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func rulesTestPlan() *ExecutionPlan {
+	return &ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "deploy the service",
+		Tasks: []Task{
+			{ID: "task-1", Type: TaskTypeAnalysis, Payload: map[string]any{"description": "Inspect the service"}},
+			{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}, Payload: map[string]any{
+				"description": "Build the release",
+				"command":     "make release",
+			}},
+			{ID: "task-3", Type: TaskTypeExecution, Dependencies: []string{"task-2"}, Payload: map[string]any{
+				"description": "Publish with `git push --force origin main`",
+			}},
+		},
+		Timeline: ExecutionTimeline{EstimatedDuration: 45 * time.Minute},
+	}
+}
+
+func TestPlanRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule PlanRule
+		want []Violation
+	}{
+		{
+			name: "max tasks within limit",
+			rule: MaxTasksRule{Limit: 3},
+		},
+		{
+			name: "max tasks exceeded",
+			rule: MaxTasksRule{Limit: 2},
+			want: []Violation{{Rule: "max_tasks", Message: "plan has 3 tasks, limit is 2"}},
+		},
+		{
+			name: "max duration exceeded",
+			rule: MaxDurationRule{Limit: 30 * time.Minute},
+			want: []Violation{{Rule: "max_duration", Message: "plan is estimated to take 45m0s, limit is 30m0s"}},
+		},
+		{
+			name: "forbidden command in payload and description",
+			rule: ForbiddenCommandsRule{Commands: []string{"MAKE RELEASE", "git push --force", "rm -rf"}},
+			want: []Violation{
+				{Rule: "forbidden_commands", TaskID: "task-2", Message: `uses forbidden command "MAKE RELEASE"`},
+				{Rule: "forbidden_commands", TaskID: "task-3", Message: `uses forbidden command "git push --force"`},
+			},
+		},
+		{
+			name: "required steps missing",
+			rule: RequiredStepsRule{Types: []string{"analysis", "validation"}},
+			want: []Violation{{Rule: "required_steps", Message: "plan has no validation step"}},
+		},
+		{
+			name: "dependency depth exceeded",
+			rule: MaxDependencyDepthRule{Limit: 2},
+			want: []Violation{{Rule: "max_dependency_depth", TaskID: "task-3", Message: "dependency depth is 3, limit is 2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.Check(rulesTestPlan()))
+		})
+	}
+}
+
+func TestDependencyDepths(t *testing.T) {
+	tasks := []Task{
+		{ID: "a"},
+		{ID: "b", Dependencies: []string{"a"}},
+		{ID: "c", Dependencies: []string{"a", "b", "missing"}},
+		{ID: "d"},
+	}
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3, "d": 1}, DependencyDepths(tasks))
+
+	cyclic := []Task{{ID: "x", Dependencies: []string{"y"}}, {ID: "y", Dependencies: []string{"x"}}}
+	assert.NotPanics(t, func() { DependencyDepths(cyclic) })
+}
+
+func TestNewRuleEngineFromConfig(t *testing.T) {
+	assert.Empty(t, NewRuleEngineFromConfig(config.PlanRulesConfig{}).Rules(), "zero values disable every rule")
+
+	engine := NewRuleEngineFromConfig(config.PlanRulesConfig{
+		MaxTasks:           10,
+		MaxDuration:        time.Hour,
+		ForbiddenCommands:  []string{"sudo"},
+		RequiredSteps:      []string{"validation"},
+		MaxDependencyDepth: 5,
+	})
+	assert.Equal(t, []string{"max_tasks", "max_duration", "forbidden_commands", "required_steps", "max_dependency_depth"}, engine.Rules())
+}
+
+func TestRuleEngine_Validate(t *testing.T) {
+	engine := NewRuleEngine(MaxTasksRule{Limit: 2}, RequiredStepsRule{Types: []string{"validation"}}, MaxDependencyDepthRule{Limit: 5})
+
+	report := engine.Check(rulesTestPlan())
+	assert.False(t, report.Valid())
+	assert.Equal(t, "plan-1", report.PlanID)
+	assert.Len(t, report.Violations, 2, "every rule runs even after one fails")
+
+	err := engine.Validate(rulesTestPlan())
+	var violationErr *RuleViolationError
+	require.True(t, errors.As(err, &violationErr))
+	assert.Equal(t, report, violationErr.Report)
+	assert.EqualError(t, err, "plan violates 2 rule(s): max_tasks: plan has 3 tasks, limit is 2; required_steps: plan has no validation step")
+
+	assert.NoError(t, NewRuleEngine(MaxTasksRule{Limit: 10}).Validate(rulesTestPlan()))
+}
+
+func TestPlanningEngine_ValidatePlan_Rules(t *testing.T) {
+	engine := NewPlanningEngine(&MockLLMProvider{})
+	require.NoError(t, engine.ValidatePlan(rulesTestPlan()))
+
+	engine.SetRules(NewRuleEngine(ForbiddenCommandsRule{Commands: []string{"git push --force"}}))
+	err := engine.ValidatePlan(rulesTestPlan())
+	assert.EqualError(t, err, `plan violates 1 rule(s): forbidden_commands: task-3: uses forbidden command "git push --force"`)
+
+	// Structural problems are reported before any rule runs
+	plan := rulesTestPlan()
+	plan.Tasks[0].Dependencies = []string{"task-3"}
+	assert.EqualError(t, engine.ValidatePlan(plan), "circular dependency detected: task-1 -> task-3 -> task-2 -> task-1")
+}
@@ -0,0 +1,135 @@
+package captain
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// SandboxRule checks the working directory, shell and environment each step requests
+// against the crew sandbox policy
+type SandboxRule struct {
+	AllowedDirs   []string
+	AllowedShells []string
+	ProtectedEnv  []string
+}
+
+// NewSandboxRule creates a sandbox rule from the crew.sandbox settings.
+// Allowed directories are made absolute so relative workdirs compare correctly.
+func NewSandboxRule(cfg config.SandboxConfig) SandboxRule {
+	dirs := make([]string, 0, len(cfg.AllowedDirs))
+	for _, dir := range cfg.AllowedDirs {
+		dirs = append(dirs, absPath(dir))
+	}
+	return SandboxRule{AllowedDirs: dirs, AllowedShells: cfg.AllowedShells, ProtectedEnv: cfg.ProtectedEnv}
+}
+
+// Name returns the rule name
+func (r SandboxRule) Name() string { return "sandbox" }
+
+// Check reports steps that leave the allowed directories, use a disallowed shell or set a protected variable
+func (r SandboxRule) Check(plan *ExecutionPlan) []Violation {
+	var violations []Violation
+	violate := func(task Task, format string, args ...any) {
+		violations = append(violations, Violation{Rule: r.Name(), TaskID: task.ID, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, task := range plan.Tasks {
+		if task.Workdir != "" && len(r.AllowedDirs) > 0 && !r.dirAllowed(task.Workdir) {
+			violate(task, "workdir %s is outside the allowed directories", task.Workdir)
+		}
+		if task.Shell != "" && len(r.AllowedShells) > 0 && !containsFold(r.AllowedShells, task.Shell) {
+			violate(task, "shell %s is not allowed", task.Shell)
+		}
+
+		names := make([]string, 0, len(task.Env))
+		for name := range task.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if containsFold(r.ProtectedEnv, name) {
+				violate(task, "environment variable %s is protected", name)
+			}
+		}
+	}
+	return violations
+}
+
+// dirAllowed reports whether dir is one of the allowed directories or inside one
+func (r SandboxRule) dirAllowed(dir string) bool {
+	return withinAny(r.AllowedDirs, dir)
+}
+
+// CodeAgentType is the agent type whose steps crew.code configures; plugins provide it
+const CodeAgentType agents.AgentType = "code"
+
+// RepoRule confines the steps of code agents to the repositories crew.code allows
+type RepoRule struct {
+	AllowedRepos []string
+}
+
+// NewRepoRule creates a repository rule from the crew.code settings, making the repositories
+// absolute so relative workdirs compare correctly
+func NewRepoRule(cfg config.CodeAgentConfig) RepoRule {
+	repos := make([]string, 0, len(cfg.AllowedRepos))
+	for _, repo := range cfg.AllowedRepos {
+		repos = append(repos, absPath(repo))
+	}
+	return RepoRule{AllowedRepos: repos}
+}
+
+// Name returns the rule name
+func (r RepoRule) Name() string { return "code_repos" }
+
+// Check reports code steps that set no workdir or one outside the allowed repositories
+func (r RepoRule) Check(plan *ExecutionPlan) []Violation {
+	var violations []Violation
+	for _, task := range plan.Tasks {
+		if AgentTypeFor(task) != CodeAgentType {
+			continue
+		}
+		switch {
+		case task.Workdir == "":
+			violations = append(violations, Violation{Rule: r.Name(), TaskID: task.ID, Message: "code steps must set a workdir inside an allowed repository"})
+		case !withinAny(r.AllowedRepos, task.Workdir):
+			violations = append(violations, Violation{Rule: r.Name(), TaskID: task.ID, Message: fmt.Sprintf("workdir %s is not inside an allowed repository", task.Workdir)})
+		}
+	}
+	return violations
+}
+
+// withinAny reports whether dir is one of dirs, which are absolute, or inside one
+func withinAny(dirs []string, dir string) bool {
+	dir = absPath(dir)
+	for _, allowed := range dirs {
+		rel, err := filepath.Rel(allowed, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath resolves a task path to a clean absolute path, falling back to the cleaned path
+func absPath(path string) string {
+	path = filepath.FromSlash(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package captain
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestSandboxRule_Check(t *testing.T) {
+	root := t.TempDir()
+	rule := NewSandboxRule(config.SandboxConfig{
+		AllowedDirs:   []string{root},
+		AllowedShells: []string{"sh", "bash"},
+		ProtectedEnv:  []string{"PATH", "HOME"},
+	})
+
+	plan := &ExecutionPlan{Tasks: []Task{
+		{ID: "inside", Workdir: filepath.Join(root, "src"), Shell: "BASH", Env: map[string]string{"STAGE": "test"}},
+		{ID: "root", Workdir: root},
+		{ID: "default"},
+		{ID: "escape", Workdir: filepath.Join(root, "..", "elsewhere")},
+		{ID: "sibling", Workdir: root + "-other"},
+		{ID: "shell", Shell: "zsh"},
+		{ID: "env", Env: map[string]string{"path": "/tmp", "HOME": "/tmp", "OK": "1"}},
+	}}
+
+	var got []string
+	for _, violation := range rule.Check(plan) {
+		got = append(got, violation.String())
+	}
+	assert.Equal(t, []string{
+		"sandbox: escape: workdir " + filepath.Join(root, "..", "elsewhere") + " is outside the allowed directories",
+		"sandbox: sibling: workdir " + root + "-other is outside the allowed directories",
+		"sandbox: shell: shell zsh is not allowed",
+		"sandbox: env: environment variable HOME is protected",
+		"sandbox: env: environment variable path is protected",
+	}, got)
+}
+
+func TestSandboxRule_Unrestricted(t *testing.T) {
+	rule := NewSandboxRule(config.SandboxConfig{})
+	plan := &ExecutionPlan{Tasks: []Task{{ID: "any", Workdir: "/", Shell: "zsh", Env: map[string]string{"PATH": "/bin"}}}}
+	assert.Empty(t, rule.Check(plan))
+}
+
+func TestSandboxRule_RelativeDirs(t *testing.T) {
+	rule := NewSandboxRule(config.SandboxConfig{AllowedDirs: []string{"."}})
+	plan := &ExecutionPlan{Tasks: []Task{{ID: "here", Workdir: "internal/captain"}, {ID: "up", Workdir: "../.."}}}
+	violations := rule.Check(plan)
+	if assert.Len(t, violations, 1) {
+		assert.Equal(t, "up", violations[0].TaskID)
+	}
+}
+
+func TestRepoRule_Check(t *testing.T) {
+	repo := t.TempDir()
+	rule := NewRepoRule(config.CodeAgentConfig{AllowedRepos: []string{repo}})
+	plan := &ExecutionPlan{Tasks: []Task{
+		{ID: "inside", Workdir: filepath.Join(repo, "cmd"), Payload: map[string]any{"agent": "code"}},
+		{ID: "elsewhere", Workdir: repo + "-fork", Payload: map[string]any{"agent": "code"}},
+		{ID: "nowhere", Payload: map[string]any{"agent": "code"}},
+		{ID: "file", Workdir: "/"},
+	}}
+
+	var got []string
+	for _, violation := range rule.Check(plan) {
+		got = append(got, violation.String())
+	}
+	assert.Equal(t, []string{
+		"code_repos: elsewhere: workdir " + repo + "-fork is not inside an allowed repository",
+		"code_repos: nowhere: code steps must set a workdir inside an allowed repository",
+	}, got, "only code steps are confined")
+}
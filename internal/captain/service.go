@@ -0,0 +1,292 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// Payload entries of service steps, whose command keeps running in the background while later
+// steps use it
+const (
+	// PayloadAddress is where the service listens, such as localhost:3000 or a URL. It is
+	// published on the blackboard once the service is healthy.
+	PayloadAddress = "address"
+	// PayloadHealthCheck is an http(s) URL that must answer without an error status, or a
+	// command that must succeed, before the service counts as healthy. Without one, a service
+	// with an address is healthy once it accepts connections.
+	PayloadHealthCheck = "health_check"
+	// PayloadReadyTimeout bounds how long the service may take to become healthy
+	PayloadReadyTimeout = "ready_timeout"
+)
+
+// DefaultServiceReadyTimeout is how long a service step may take to become healthy unless its
+// payload sets a ready_timeout
+const DefaultServiceReadyTimeout = 30 * time.Second
+
+// healthCheckTimeout bounds a single health check of a service
+const healthCheckTimeout = 5 * time.Second
+
+// ServiceFindingKey returns the blackboard key a service step's address is published under
+// when the step names no publish key
+func ServiceFindingKey(taskID string) string {
+	return taskID + ".address"
+}
+
+// serviceSpec is how a service step is started and checked
+type serviceSpec struct {
+	command      string
+	address      string
+	healthCheck  string
+	readyTimeout time.Duration
+}
+
+// parseServiceSpec reads a service step's command, address, health check and ready timeout
+// from its payload
+func parseServiceSpec(task Task) (serviceSpec, error) {
+	spec := serviceSpec{readyTimeout: DefaultServiceReadyTimeout}
+	spec.command, _ = task.Payload["command"].(string)
+	spec.address, _ = task.Payload[PayloadAddress].(string)
+	spec.healthCheck, _ = task.Payload[PayloadHealthCheck].(string)
+	if strings.TrimSpace(spec.command) == "" {
+		return spec, fmt.Errorf("service steps need a command")
+	}
+	switch timeout := task.Payload[PayloadReadyTimeout].(type) {
+	case nil:
+	case string:
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil || parsed <= 0 {
+			return spec, fmt.Errorf("invalid ready_timeout %q: must be a positive duration such as 30s", timeout)
+		}
+		spec.readyTimeout = parsed
+	default:
+		return spec, fmt.Errorf("invalid ready_timeout: must be a duration such as 30s")
+	}
+	return spec, nil
+}
+
+// validateService checks a service step has a command and a usable ready timeout, and does
+// not fan out, which would start the same service once per item
+func validateService(task Task) error {
+	if !task.Service {
+		return nil
+	}
+	if len(task.FanOut) > 0 {
+		return fmt.Errorf("service steps cannot fan out")
+	}
+	_, err := parseServiceSpec(task)
+	return err
+}
+
+// dialAddress returns the host:port a service address is reached at, taking the port from
+// the scheme of a URL without one
+func dialAddress(address string) string {
+	if !strings.Contains(address, "://") {
+		return address
+	}
+	u, err := url.Parse(address)
+	switch {
+	case err != nil:
+		return address
+	case u.Port() != "":
+		return u.Host
+	case u.Scheme == "https":
+		return net.JoinHostPort(u.Hostname(), "443")
+	default:
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+}
+
+// check runs one health check of the service, returning why it is not healthy yet
+func (s serviceSpec) check(ctx context.Context, task agents.Task) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	switch {
+	case strings.HasPrefix(s.healthCheck, "http://") || strings.HasPrefix(s.healthCheck, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.healthCheck, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("%s answered %s", s.healthCheck, resp.Status)
+		}
+		return nil
+	case s.healthCheck != "":
+		// Checks run often, so their output is kept out of the step's log
+		task.Output = nil
+		if output, err := task.RunCommand(ctx, s.healthCheck); err != nil {
+			if combined := output.Combined(); combined != "" {
+				return fmt.Errorf("%q failed: %w: %s", s.healthCheck, err, combined)
+			}
+			return fmt.Errorf("%q failed: %w", s.healthCheck, err)
+		}
+		return nil
+	case s.address != "":
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", dialAddress(s.address))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return nil
+}
+
+// runningService is a service step whose command is running
+type runningService struct {
+	task    Task
+	command *agents.BackgroundCommand
+	started time.Time
+}
+
+// startService runs a service step: it starts the step's command in the background, waits for
+// it to become healthy and publishes its address. The service keeps running until stopServices
+// is called at the end of the plan; one that exits or never becomes healthy fails the step.
+func (e *PlanExecutor) startService(ctx context.Context, task Task) Result {
+	start := e.clock.Now()
+	spec, err := parseServiceSpec(task)
+	if err != nil {
+		return e.failedResult(task.ID, start, err.Error())
+	}
+	agentTask := task.AgentTask()
+	agentTask.MaxOutputSize = e.maxOutputSize
+	agentTask.ReadOnly = e.readOnly
+	if agentTask.Container, err = e.containerFor(task); err != nil {
+		return e.failedResult(task.ID, start, err.Error())
+	}
+	agentTask.Output = e.outputSink(task, "")
+
+	// The service outlives the step, so it is stopped by stopServices rather than the step's context
+	command, err := agentTask.StartCommand(context.WithoutCancel(ctx), spec.command)
+	if err != nil {
+		return e.failedResult(task.ID, start, fmt.Sprintf("failed to start service: %v", err))
+	}
+	service := &runningService{task: task, command: command, started: e.clock.Now()}
+	e.mu.Lock()
+	e.services = append(e.services, service)
+	e.mu.Unlock()
+
+	if err := e.awaitHealthy(ctx, agentTask, spec, command); err != nil {
+		e.stopService(service)
+		result := e.failedResult(task.ID, start, err.Error())
+		output := command.Output()
+		result.Output = output.Combined()
+		result.Metadata = map[string]any{"service": true}
+		result.Resources = withWallTime(&output.Usage, result.Duration)
+		return result
+	}
+
+	output := fmt.Sprintf("service running (pid %d)", command.Pid())
+	if spec.address != "" {
+		output = fmt.Sprintf("service running at %s (pid %d)", spec.address, command.Pid())
+	}
+	result := Result{
+		TaskID:    task.ID,
+		Success:   true,
+		Output:    output,
+		Duration:  e.clock.Since(start),
+		Timestamp: e.clock.Now(),
+		Metadata: map[string]any{
+			"service": true,
+			"pid":     command.Pid(),
+		},
+	}
+	result.Resources = withWallTime(nil, result.Duration)
+	if spec.address != "" {
+		result.Metadata[PayloadAddress] = spec.address
+		if err := e.publishAddress(ctx, task, spec.address); err != nil {
+			result.Metadata["blackboard_error"] = err.Error()
+		}
+	}
+	e.logger.Info("Service started", zap.String("task_id", task.ID), zap.Int("pid", command.Pid()), zap.String("address", spec.address))
+	return result
+}
+
+// awaitHealthy checks the service at each heartbeat until it is healthy, failing if it exits,
+// the plan is cancelled or its ready timeout passes first
+func (e *PlanExecutor) awaitHealthy(ctx context.Context, task agents.Task, spec serviceSpec, command *agents.BackgroundCommand) error {
+	deadline := e.clock.After(spec.readyTimeout)
+	ticker := e.clock.NewTicker(e.heartbeatInterval)
+	defer ticker.Stop()
+
+	var unhealthy error
+	for {
+		select {
+		case <-command.Done():
+			return fmt.Errorf("service exited before it was healthy: %v", command.Err())
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled while waiting for the service to be healthy: %w", ctx.Err())
+		case <-deadline:
+			if unhealthy != nil {
+				return fmt.Errorf("service was not healthy after %s: %v", spec.readyTimeout, unhealthy)
+			}
+			return fmt.Errorf("service was not healthy after %s", spec.readyTimeout)
+		case <-ticker.C():
+		}
+		if command.Exited() {
+			continue
+		}
+		if unhealthy = spec.check(ctx, task); unhealthy == nil {
+			return nil
+		}
+	}
+}
+
+// publishAddress shares a healthy service's address with later steps, under the step's
+// publish key or ServiceFindingKey
+func (e *PlanExecutor) publishAddress(ctx context.Context, task Task, address string) error {
+	if e.blackboard == nil {
+		return nil
+	}
+	key, _ := task.Payload[PayloadPublish].(string)
+	if key == "" {
+		key = ServiceFindingKey(task.ID)
+	}
+	if err := e.blackboard.Publish(ctx, agents.Finding{Key: key, Value: address, Step: task.ID}); err != nil {
+		return fmt.Errorf("failed to publish finding %q: %w", key, err)
+	}
+	return nil
+}
+
+// stopService stops a service within the shutdown grace period and forgets it
+func (e *PlanExecutor) stopService(service *runningService) {
+	if service.command.Exited() {
+		e.logger.Warn("Service exited before the end of the plan", zap.String("task_id", service.task.ID),
+			zap.Error(service.command.Err()))
+	}
+	killed := service.command.Stop(e.shutdownGrace)
+	e.mu.Lock()
+	for i, running := range e.services {
+		if running == service {
+			e.services = append(e.services[:i], e.services[i+1:]...)
+			break
+		}
+	}
+	e.mu.Unlock()
+	e.logger.Info("Service stopped", zap.String("task_id", service.task.ID),
+		zap.Duration("uptime", e.clock.Since(service.started)), zap.Bool("killed", killed))
+}
+
+// stopServices stops every service still running, latest first so that a service is stopped
+// before the services it may use
+func (e *PlanExecutor) stopServices() {
+	e.mu.Lock()
+	services := append([]*runningService(nil), e.services...)
+	e.mu.Unlock()
+	for i := len(services) - 1; i >= 0; i-- {
+		e.stopService(services[i])
+	}
+}
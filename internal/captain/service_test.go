@@ -0,0 +1,198 @@
+//go:build !windows
+
+package captain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func serviceExecutor(t *testing.T) (*PlanExecutor, *capturingAgent, *agents.MemoryBlackboard) {
+	t.Helper()
+	manager := agents.NewAgentManager()
+	agent := &capturingAgent{}
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+	blackboard := agents.NewMemoryBlackboard()
+	executor := NewPlanExecutor(manager)
+	executor.SetBlackboard(blackboard)
+	executor.SetHeartbeatInterval(5 * time.Millisecond)
+	executor.SetShutdownGrace(time.Second)
+	return executor, agent, blackboard
+}
+
+// running reports whether a process is still alive
+func running(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+func TestPlanExecutor_Service(t *testing.T) {
+	executor, agent, blackboard := serviceExecutor(t)
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+		{ID: "web", Type: TaskTypeExecution, Service: true, Workdir: t.TempDir(), Shell: "sh", Payload: map[string]any{
+			"description":      "start the dev server",
+			"command":          "sleep 0.05; touch ready; exec sleep 30",
+			PayloadAddress:     "localhost:3000",
+			PayloadHealthCheck: "test -f ready",
+		}},
+		{ID: "test", Type: TaskTypeValidation, Dependencies: []string{"web"}, Payload: map[string]any{
+			"description": "run the tests", PayloadInputs: []string{ServiceFindingKey("web")},
+		}},
+	}}
+
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Success, results[0].Error)
+	assert.Contains(t, results[0].Output, "service running at localhost:3000")
+	assert.Equal(t, "localhost:3000", results[0].Metadata[PayloadAddress])
+	require.NotNil(t, results[0].Resources, "services record what their command used")
+	assert.Positive(t, results[0].Resources.WallTime)
+
+	received := agent.last.Load().(agents.Task)
+	assert.Equal(t, map[string]interface{}{"web.address": "localhost:3000"}, received.Data["findings"])
+	finding, ok, err := blackboard.Lookup(context.Background(), "web.address")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "web", finding.Step)
+
+	pid := results[0].Metadata["pid"].(int)
+	assert.False(t, running(pid), "services are stopped when the plan ends")
+	assert.Empty(t, executor.services)
+}
+
+func TestPlanExecutor_ServiceStoppedOnFailure(t *testing.T) {
+	executor, _, _ := serviceExecutor(t)
+	executor.SetPolicy(&Policy{Commands: PolicyList{Deny: []string{"rm"}}})
+	plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+		{ID: "web", Type: TaskTypeExecution, Service: true, Shell: "sh", Payload: map[string]any{"command": "exec sleep 30"}},
+		{ID: "clean", Type: TaskTypeExecution, Dependencies: []string{"web"}, Payload: map[string]any{"command": "rm -rf dist"}},
+	}}
+
+	results, _, err := executor.Execute(context.Background(), plan)
+	require.Error(t, err)
+	require.True(t, results[0].Success, results[0].Error)
+	assert.Contains(t, results[0].Output, "service running (pid ")
+	assert.False(t, running(results[0].Metadata["pid"].(int)), "services are stopped however the plan ends")
+}
+
+func TestPlanExecutor_ServiceHealthCheck(t *testing.T) {
+	var checks atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checks.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("url", func(t *testing.T) {
+		executor, _, _ := serviceExecutor(t)
+		task := Task{ID: "web", Service: true, Shell: "sh", Payload: map[string]any{
+			"command": "exec sleep 30", PayloadAddress: server.URL, PayloadHealthCheck: server.URL + "/health",
+		}}
+		result, _ := executor.ExecuteTask(context.Background(), task)
+		defer executor.stopServices()
+		require.True(t, result.Success, result.Error)
+		assert.Equal(t, int32(3), checks.Load(), "the service is checked until it answers")
+	})
+
+	t.Run("address", func(t *testing.T) {
+		executor, _, _ := serviceExecutor(t)
+		task := Task{ID: "web", Service: true, Shell: "sh", Payload: map[string]any{
+			"command": "exec sleep 30", PayloadAddress: server.URL,
+		}}
+		result, _ := executor.ExecuteTask(context.Background(), task)
+		defer executor.stopServices()
+		require.True(t, result.Success, result.Error)
+	})
+
+	t.Run("exits first", func(t *testing.T) {
+		executor, _, _ := serviceExecutor(t)
+		task := Task{ID: "web", Service: true, Shell: "sh", Payload: map[string]any{
+			"command": `echo "address already in use" >&2; exit 1`, PayloadHealthCheck: "false",
+		}}
+		result, _ := executor.ExecuteTask(context.Background(), task)
+		assert.False(t, result.Success)
+		assert.Equal(t, "service exited before it was healthy: exit status 1", result.Error)
+		assert.Equal(t, "address already in use", result.Output)
+	})
+
+	t.Run("never healthy", func(t *testing.T) {
+		executor, _, _ := serviceExecutor(t)
+		task := Task{ID: "web", Service: true, Shell: "sh", Payload: map[string]any{
+			"command": "exec sleep 30", PayloadHealthCheck: "echo not yet; false", PayloadReadyTimeout: "50ms",
+		}}
+		result, _ := executor.ExecuteTask(context.Background(), task)
+		assert.False(t, result.Success)
+		assert.Equal(t, `service was not healthy after 50ms: "echo not yet; false" failed: exit status 1: not yet`, result.Error)
+		assert.Empty(t, executor.services, "a service that never became healthy is stopped")
+	})
+}
+
+func TestValidateService(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr string
+	}{
+		{name: "not a service", task: Task{ID: "task-1"}},
+		{name: "service", task: Task{ID: "task-1", Service: true, Payload: map[string]any{"command": "npm run dev", PayloadReadyTimeout: "2m"}}},
+		{name: "no command", task: Task{ID: "task-1", Service: true}, wantErr: "service steps need a command"},
+		{name: "fan out", task: Task{ID: "task-1", Service: true, FanOut: []string{"a"}, Payload: map[string]any{"command": "npm run dev"}}, wantErr: "service steps cannot fan out"},
+		{name: "bad timeout", task: Task{ID: "task-1", Service: true, Payload: map[string]any{"command": "npm run dev", PayloadReadyTimeout: "soon"}},
+			wantErr: `invalid ready_timeout "soon": must be a positive duration such as 30s`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateService(tt.task)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDialAddress(t *testing.T) {
+	assert.Equal(t, "localhost:3000", dialAddress("localhost:3000"))
+	assert.Equal(t, "localhost:3000", dialAddress("http://localhost:3000/app"))
+	assert.Equal(t, "example.com:443", dialAddress("https://example.com"))
+	assert.Equal(t, "example.com:80", dialAddress("http://example.com"))
+}
+
+func TestPlanningEngine_ServiceStep(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: `{
+		"tasks": [
+			{"id": "task-1", "type": "execution", "priority": "high", "description": "Start the dev server", "dependencies": [],
+			 "service": {"command": "npm run dev", "address": "localhost:3000", "health_check": "http://localhost:3000/health"}},
+			{"id": "task-2", "type": "validation", "priority": "high", "description": "Run the end-to-end tests", "dependencies": ["task-1"], "inputs": ["task-1.address"]}
+		],
+		"strategy": "sequential",
+		"estimated_duration": "10m"
+	}`}, nil)
+
+	plan, err := NewPlanningEngine(mockLLM).CreatePlan(context.Background(), "run the end-to-end tests")
+	require.NoError(t, err)
+	service := plan.Tasks[0]
+	assert.True(t, service.Service)
+	assert.Equal(t, "npm run dev", service.Payload["command"])
+	assert.Equal(t, "localhost:3000", service.Payload[PayloadAddress])
+	assert.Equal(t, "http://localhost:3000/health", service.Payload[PayloadHealthCheck])
+	assert.NotContains(t, service.Payload, PayloadReadyTimeout)
+	assert.False(t, plan.Tasks[1].Service)
+}
@@ -0,0 +1,190 @@
+package captain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// DefaultSimulatedStepDuration is how long a simulated step takes when neither the step nor
+// its plan carries an estimate
+const DefaultSimulatedStepDuration = time.Minute
+
+// SimulationOptions controls how a plan is simulated
+type SimulationOptions struct {
+	// MaxParallel is the most steps that run at once, as set with --parallel; the plan's
+	// strategy and max_agents may lower it, as they do when the plan is executed
+	MaxParallel int
+}
+
+// SimulatedStep is one plan step in a simulated timeline. Start and End are offsets from the
+// start of the run.
+type SimulatedStep struct {
+	TaskID      string
+	Description string
+	AgentType   agents.AgentType
+	AgentID     string
+	Start       time.Duration
+	End         time.Duration
+	// Approval is set on steps that would stop to ask for approval before running
+	Approval bool
+}
+
+// SimulatedInterval is a span of the simulated run during which the same steps are running
+type SimulatedInterval struct {
+	Start   time.Duration
+	End     time.Duration
+	Running []string
+}
+
+// Simulation is the timeline of a simulated plan execution
+type Simulation struct {
+	Steps       []SimulatedStep // In the order they started
+	Intervals   []SimulatedInterval
+	Duration    time.Duration
+	Sequential  time.Duration // Total step time, what running every step one after another would take
+	MaxParallel int           // Limit on steps running at once
+	Peak        int           // Most steps that ran at once
+}
+
+// simClock is the fake clock a simulation runs on; it only moves when advanced
+type simClock struct {
+	now time.Duration
+}
+
+// Advance moves the clock forward to t
+func (c *simClock) Advance(t time.Duration) {
+	if t > c.now {
+		c.now = t
+	}
+}
+
+// simAgents hands out deterministic mock agents, reusing the lowest-numbered idle agent of a type
+type simAgents struct {
+	busy map[agents.AgentType][]bool
+}
+
+func (a *simAgents) acquire(agentType agents.AgentType) string {
+	slots := a.busy[agentType]
+	for i, busy := range slots {
+		if !busy {
+			slots[i] = true
+			return simAgentID(agentType, i)
+		}
+	}
+	a.busy[agentType] = append(slots, true)
+	return simAgentID(agentType, len(slots))
+}
+
+func (a *simAgents) release(agentType agents.AgentType, id string) {
+	for i := range a.busy[agentType] {
+		if simAgentID(agentType, i) == id {
+			a.busy[agentType][i] = false
+		}
+	}
+}
+
+func simAgentID(agentType agents.AgentType, i int) string {
+	return fmt.Sprintf("%s-sim-%d", agentType, i+1)
+}
+
+// Simulate schedules a plan on deterministic mock agents and a fake clock, without running
+// anything. Steps start the way PlanExecutor.Execute starts them: as soon as their
+// dependencies have finished and a slot is free, up to the limit parallelSteps sets, in
+// execution order. Each takes its estimated duration.
+func Simulate(plan *ExecutionPlan, opts SimulationOptions) (*Simulation, error) {
+	order, err := executionOrder(plan.Tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := parallelSteps(plan, opts.MaxParallel)
+	sim := &Simulation{MaxParallel: limit}
+	clock := &simClock{}
+	pool := &simAgents{busy: make(map[agents.AgentType][]bool)}
+	finished := make(map[string]bool, len(order))
+	started := make(map[string]bool, len(order))
+	var running []int // indexes into sim.Steps
+	for len(finished) < len(order) {
+		// Start every ready step that fits, in execution order
+		for _, task := range order {
+			if len(running) >= limit {
+				break
+			}
+			if started[task.ID] || !dependenciesFinished(task, finished) {
+				continue
+			}
+			agentType := AgentTypeFor(task)
+			description, _ := task.Payload["description"].(string)
+			duration := simulatedDuration(task, plan)
+			started[task.ID] = true
+			running = append(running, len(sim.Steps))
+			sim.Steps = append(sim.Steps, SimulatedStep{
+				TaskID:      task.ID,
+				Description: description,
+				AgentType:   agentType,
+				AgentID:     pool.acquire(agentType),
+				Start:       clock.now,
+				End:         clock.now + duration,
+				Approval:    AssessRisk(task).RequiresApproval(),
+			})
+			sim.Sequential += duration
+		}
+		if len(running) > sim.Peak {
+			sim.Peak = len(running)
+		}
+
+		// Advance to the next step to finish and release every step finishing then
+		next := sim.Steps[running[0]].End
+		for _, i := range running[1:] {
+			if sim.Steps[i].End < next {
+				next = sim.Steps[i].End
+			}
+		}
+		ids := make([]string, 0, len(running))
+		for _, i := range running {
+			ids = append(ids, sim.Steps[i].TaskID)
+		}
+		sim.Intervals = append(sim.Intervals, SimulatedInterval{Start: clock.now, End: next, Running: ids})
+		clock.Advance(next)
+
+		still := running[:0]
+		for _, i := range running {
+			step := sim.Steps[i]
+			if step.End > clock.now {
+				still = append(still, i)
+				continue
+			}
+			finished[step.TaskID] = true
+			pool.release(step.AgentType, step.AgentID)
+		}
+		running = still
+	}
+	sim.Duration = clock.now
+	return sim, nil
+}
+
+// dependenciesFinished reports whether all of a task's dependencies have finished
+func dependenciesFinished(task Task, finished map[string]bool) bool {
+	for _, dep := range task.Dependencies {
+		if !finished[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// simulatedDuration returns how long a step takes in a simulation: its estimated_duration
+// metadata, else an even share of the plan's estimate, else DefaultSimulatedStepDuration
+func simulatedDuration(task Task, plan *ExecutionPlan) time.Duration {
+	if estimate, err := time.ParseDuration(task.Metadata["estimated_duration"]); err == nil && estimate > 0 {
+		return estimate
+	}
+	if plan.Timeline.EstimatedDuration > 0 && len(plan.Tasks) > 0 {
+		if share := (plan.Timeline.EstimatedDuration / time.Duration(len(plan.Tasks))).Round(time.Second); share > 0 {
+			return share
+		}
+	}
+	return DefaultSimulatedStepDuration
+}
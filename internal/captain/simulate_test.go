@@ -0,0 +1,99 @@
+package captain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// simulationPlan is a diamond: task-1 fans out to task-2 and task-3, which task-4 joins
+func simulationPlan(strategy StrategyType) *ExecutionPlan {
+	tasks := dependsOn(nil, []string{"task-1"}, []string{"task-1"}, []string{"task-2", "task-3"})
+	tasks[0].Type = TaskTypeAnalysis
+	tasks[1].Metadata = map[string]string{"estimated_duration": "10m"}
+	tasks[2].Payload = map[string]any{"description": "delete stale build artifacts", "command": "rm -rf ./build"}
+	return &ExecutionPlan{
+		ID:       "plan-1",
+		Tasks:    tasks,
+		Timeline: ExecutionTimeline{EstimatedDuration: 20 * time.Minute},
+		Strategy: ExecutionStrategy{Type: strategy},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	sim, err := Simulate(simulationPlan(StrategyParallel), SimulationOptions{MaxParallel: 3})
+	require.NoError(t, err)
+
+	assert.Equal(t, []SimulatedStep{
+		{TaskID: "task-1", AgentType: agents.AgentTypeResearch, AgentID: "research-sim-1", End: 5 * time.Minute},
+		{TaskID: "task-2", AgentType: agents.AgentTypeFile, AgentID: "file-sim-1", Start: 5 * time.Minute, End: 15 * time.Minute},
+		{TaskID: "task-3", Description: "delete stale build artifacts", AgentType: agents.AgentTypeFile, AgentID: "file-sim-2",
+			Start: 5 * time.Minute, End: 10 * time.Minute, Approval: true},
+		{TaskID: "task-4", AgentType: agents.AgentTypeFile, AgentID: "file-sim-1", Start: 15 * time.Minute, End: 20 * time.Minute},
+	}, sim.Steps)
+	assert.Equal(t, []SimulatedInterval{
+		{End: 5 * time.Minute, Running: []string{"task-1"}},
+		{Start: 5 * time.Minute, End: 10 * time.Minute, Running: []string{"task-2", "task-3"}},
+		{Start: 10 * time.Minute, End: 15 * time.Minute, Running: []string{"task-2"}},
+		{Start: 15 * time.Minute, End: 20 * time.Minute, Running: []string{"task-4"}},
+	}, sim.Intervals)
+	assert.Equal(t, 20*time.Minute, sim.Duration)
+	assert.Equal(t, 25*time.Minute, sim.Sequential)
+	assert.Equal(t, 3, sim.MaxParallel)
+	assert.Equal(t, 2, sim.Peak)
+
+	again, err := Simulate(simulationPlan(StrategyParallel), SimulationOptions{MaxParallel: 3})
+	require.NoError(t, err)
+	assert.Equal(t, sim, again, "simulations are deterministic")
+}
+
+func TestSimulate_Limits(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy StrategyType
+		parallel int
+		agents   int
+		limit    int
+		duration time.Duration
+	}{
+		{name: "sequential strategy", strategy: StrategySequential, parallel: 5, limit: 1, duration: 25 * time.Minute},
+		{name: "one slot", strategy: StrategyHybrid, parallel: 1, limit: 1, duration: 25 * time.Minute},
+		{name: "plan max agents", strategy: StrategyParallel, parallel: 5, agents: 1, limit: 1, duration: 25 * time.Minute},
+		{name: "no limit given", strategy: StrategyParallel, agents: 2, limit: 2, duration: 20 * time.Minute},
+		{name: "no limit at all", strategy: StrategyParallel, limit: 4, duration: 20 * time.Minute},
+		{name: "unset strategy", parallel: 5, limit: 1, duration: 25 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := simulationPlan(tt.strategy)
+			plan.Resources.MaxAgents = tt.agents
+			sim, err := Simulate(plan, SimulationOptions{MaxParallel: tt.parallel})
+			require.NoError(t, err)
+			assert.Equal(t, tt.limit, sim.MaxParallel)
+			assert.LessOrEqual(t, sim.Peak, tt.limit)
+			assert.Equal(t, tt.duration, sim.Duration)
+		})
+	}
+}
+
+func TestSimulate_Errors(t *testing.T) {
+	_, err := Simulate(&ExecutionPlan{Tasks: dependsOn([]string{"task-2"}, []string{"task-1"})}, SimulationOptions{})
+	var cycle *CycleError
+	assert.ErrorAs(t, err, &cycle)
+
+	sim, err := Simulate(&ExecutionPlan{}, SimulationOptions{MaxParallel: 2})
+	require.NoError(t, err)
+	assert.Zero(t, sim.Duration)
+	assert.Empty(t, sim.Steps)
+}
+
+func TestSimulatedDuration(t *testing.T) {
+	plan := &ExecutionPlan{Tasks: make([]Task, 3), Timeline: ExecutionTimeline{EstimatedDuration: 10 * time.Minute}}
+	assert.Equal(t, 3*time.Minute+20*time.Second, simulatedDuration(Task{}, plan))
+	assert.Equal(t, 90*time.Second, simulatedDuration(Task{Metadata: map[string]string{"estimated_duration": "1m30s"}}, plan))
+	assert.Equal(t, DefaultSimulatedStepDuration, simulatedDuration(Task{Metadata: map[string]string{"estimated_duration": "soon"}}, &ExecutionPlan{}))
+}
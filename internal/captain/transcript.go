@@ -0,0 +1,43 @@
+package captain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxTranscriptPromptBytes bounds how much of a transcript is sent to the LLM for summarizing
+const maxTranscriptPromptBytes = 48 * 1024
+
+// SummarizeTranscript asks the LLM for a short Markdown summary of a task transcript
+func (c *Captain) SummarizeTranscript(ctx context.Context, transcript string) (string, error) {
+	if c.llmProvider == nil {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+
+	// Keep the end of long transcripts, where outcomes and errors are recorded
+	if len(transcript) > maxTranscriptPromptBytes {
+		transcript = "[earlier entries omitted]\n" + transcript[len(transcript)-maxTranscriptPromptBytes:]
+	}
+
+	systemPrompt := `You summarize transcripts of tasks run by a captain agent coordinating a crew of agents.
+Write a short Markdown summary for the person who submitted the goal:
+- One or two sentences on what was done and whether the goal was met
+- A bullet list of notable decisions, failures, handoffs and answers given to agents
+Do not repeat command output verbatim and do not use headings.`
+
+	req := CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: transcript},
+		},
+		MaxTokens:   800,
+		Temperature: 0.2,
+	}
+
+	resp, err := c.llmProvider.GenerateCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize transcript: %w", err)
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
@@ -0,0 +1,50 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptain_SummarizeTranscript(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return len(req.Messages) == 2 && req.Messages[1].Content == "# Task task-1"
+	})).Return(&CompletionResponse{Content: "\nThe goal was met.\n"}, nil)
+
+	captain := &Captain{ID: "captain-1", llmProvider: provider}
+	summary, err := captain.SummarizeTranscript(context.Background(), "# Task task-1")
+	require.NoError(t, err)
+	assert.Equal(t, "The goal was met.", summary)
+}
+
+func TestCaptain_SummarizeTranscript_TruncatesLongTranscripts(t *testing.T) {
+	provider := &MockLLMProvider{}
+	var sent string
+	provider.On("GenerateCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(1).(CompletionRequest).Messages[1].Content
+	}).Return(&CompletionResponse{Content: "summary"}, nil)
+
+	captain := &Captain{ID: "captain-1", llmProvider: provider}
+	_, err := captain.SummarizeTranscript(context.Background(), strings.Repeat("a", maxTranscriptPromptBytes)+"the end")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sent, "[earlier entries omitted]\n"))
+	assert.True(t, strings.HasSuffix(sent, "the end"), "the end of the transcript is kept")
+}
+
+func TestCaptain_SummarizeTranscript_Error(t *testing.T) {
+	provider := &MockLLMProvider{}
+	provider.On("GenerateCompletion", mock.Anything, mock.Anything).Return(nil, errors.New("rate limited"))
+
+	captain := &Captain{ID: "captain-1", llmProvider: provider}
+	_, err := captain.SummarizeTranscript(context.Background(), "# Task task-1")
+	assert.EqualError(t, err, "failed to summarize transcript: rate limited")
+
+	_, err = (&Captain{ID: "captain-2"}).SummarizeTranscript(context.Background(), "# Task task-1")
+	assert.EqualError(t, err, "no LLM provider configured")
+}
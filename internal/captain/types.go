@@ -1,7 +1,11 @@
 package captain
 
 import (
+	"fmt"
+	"regexp"
 	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
 )
 
 // TaskType represents the type of task to be executed
@@ -12,16 +16,19 @@ const (
 	TaskTypeExecution  TaskType = "execution"
 	TaskTypeValidation TaskType = "validation"
 	TaskTypeReporting  TaskType = "reporting"
+	// TaskTypeFileWatch waits on a file agent for paths to change or appear
+	TaskTypeFileWatch TaskType = "file_watch"
 )
 
-// Priority represents task priority levels
-type Priority string
+// Priority represents task priority levels. It is the agents package's priority so plan
+// tasks hand their priority to crew agents without conversion.
+type Priority = agents.Priority
 
 const (
-	PriorityLow      Priority = "low"
-	PriorityMedium   Priority = "medium"
-	PriorityHigh     Priority = "high"
-	PriorityCritical Priority = "critical"
+	PriorityLow      = agents.PriorityLow
+	PriorityMedium   = agents.PriorityMedium
+	PriorityHigh     = agents.PriorityHigh
+	PriorityCritical = agents.PriorityCritical
 )
 
 // StrategyType represents execution strategy types
@@ -35,44 +42,117 @@ const (
 
 // Task represents a single executable task
 type Task struct {
-	ID           string            `json:"id"`
-	Type         TaskType          `json:"type"`
-	Priority     Priority          `json:"priority"`
-	Dependencies []string          `json:"dependencies,omitempty"`
-	Payload      map[string]any    `json:"payload,omitempty"`
-	Deadline     time.Time         `json:"deadline,omitempty"`
-	Metadata     map[string]string `json:"metadata,omitempty"`
+	ID           string            `json:"id" yaml:"id"`
+	Type         TaskType          `json:"type" yaml:"type"`
+	Priority     Priority          `json:"priority" yaml:"priority"`
+	Dependencies []string          `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Payload      map[string]any    `json:"payload,omitempty" yaml:"payload,omitempty"`
+	Deadline     time.Time         `json:"deadline,omitempty" yaml:"deadline,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	// Workdir, Env and Shell scope the environment commands in the step run in
+	Workdir string            `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Shell   string            `json:"shell,omitempty" yaml:"shell,omitempty"`
+	// Execution is host or container; empty follows the execution.mode setting
+	Execution string `json:"execution,omitempty" yaml:"execution,omitempty"`
+
+	// Condition skips the step unless an earlier step's output matches
+	Condition *Condition `json:"condition,omitempty" yaml:"condition,omitempty"`
+	// Gate stops the plan before the step until it is approved; "manual" is the only gate
+	Gate string `json:"gate,omitempty" yaml:"gate,omitempty"`
+	// FanOut runs the step once per item, in parallel, joining the items' results into the
+	// step's result; each run finds its item in the "item" payload entry and $CAPN_ITEM
+	FanOut []string `json:"fan_out,omitempty" yaml:"fan_out,omitempty"`
+	// Service starts the step's command in the background and keeps it running for later
+	// steps until the plan ends; see PayloadAddress and PayloadHealthCheck
+	Service bool `json:"service,omitempty" yaml:"service,omitempty"`
+}
+
+// GateManual holds a step until someone approves it, whatever its risk
+const GateManual = "manual"
+
+// Condition makes a step run only when the output of one of its dependencies matches a
+// regular expression
+type Condition struct {
+	Step    string `json:"step" yaml:"step"`
+	Matches string `json:"matches" yaml:"matches"`
+}
+
+// Validate checks the condition names a step and its pattern compiles
+func (c Condition) Validate() error {
+	if c.Step == "" {
+		return fmt.Errorf("condition step cannot be empty")
+	}
+	if _, err := regexp.Compile(c.Matches); err != nil {
+		return fmt.Errorf("invalid condition pattern %q: %w", c.Matches, err)
+	}
+	return nil
+}
+
+// Met reports whether a result of the condition's step satisfies it. Failed and skipped
+// steps never do.
+func (c Condition) Met(result Result) bool {
+	if !result.Success || result.Status() == StepStatusSkipped {
+		return false
+	}
+	re, err := regexp.Compile(c.Matches)
+	return err == nil && re.MatchString(result.Output)
 }
 
+// StepObserver is called after a plan step has run with its result, which it may amend,
+// for example by attaching artifacts
+type StepObserver func(task Task, result *Result)
+
+// FanOutObserver is called as each item of a fan-out step finishes, with how many of the
+// step's items have finished. Calls for one step never overlap.
+type FanOutObserver func(task Task, item FanOutItem, done, total int)
+
+// OutputObserver is called with each line of command output a plan step writes while it runs
+type OutputObserver func(task Task, agentID string, stream agents.OutputStream, line string)
+
 // ExecutionTimeline represents the timeline for plan execution
 type ExecutionTimeline struct {
-	EstimatedDuration time.Duration `json:"estimated_duration"`
-	StartTime         time.Time     `json:"start_time,omitempty"`
-	EndTime           time.Time     `json:"end_time,omitempty"`
+	EstimatedDuration time.Duration `json:"estimated_duration" yaml:"estimated_duration"`
+	StartTime         time.Time     `json:"start_time,omitempty" yaml:"start_time,omitempty"`
+	EndTime           time.Time     `json:"end_time,omitempty" yaml:"end_time,omitempty"`
+	// MaxRuntime bounds how long the plan may execute before its remaining steps are
+	// cancelled; zero falls back to captain.max_task_runtime
+	MaxRuntime time.Duration `json:"max_runtime,omitempty" yaml:"max_runtime,omitempty"`
+}
+
+// MaxRuntimeError is the cause of a plan execution cancelled for running past its max runtime
+type MaxRuntimeError struct {
+	Limit time.Duration
+}
+
+func (e *MaxRuntimeError) Error() string {
+	return fmt.Sprintf("task exceeded its max runtime of %s", e.Limit)
 }
 
 // ResourceAllocation represents resource requirements for plan execution
 type ResourceAllocation struct {
-	MaxAgents     int      `json:"max_agents"`
-	RequiredTools []string `json:"required_tools,omitempty"`
-	EstimatedCost float64  `json:"estimated_cost,omitempty"`
+	MaxAgents     int      `json:"max_agents" yaml:"max_agents"`
+	RequiredTools []string `json:"required_tools,omitempty" yaml:"required_tools,omitempty"`
+	EstimatedCost float64  `json:"estimated_cost,omitempty" yaml:"estimated_cost,omitempty"`
 }
 
 // ExecutionStrategy represents the strategy for executing the plan
 type ExecutionStrategy struct {
-	Type        StrategyType   `json:"type"`
-	Description string         `json:"description,omitempty"`
-	Options     map[string]any `json:"options,omitempty"`
+	Type        StrategyType   `json:"type" yaml:"type"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Options     map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
 }
 
 // ExecutionPlan represents a complete execution plan
 type ExecutionPlan struct {
-	ID        string             `json:"id"`
-	Goal      string             `json:"goal"`
-	Tasks     []Task             `json:"tasks"`
-	Timeline  ExecutionTimeline  `json:"timeline"`
-	Resources ResourceAllocation `json:"resources"`
-	Strategy  ExecutionStrategy  `json:"strategy"`
+	ID        string             `json:"id" yaml:"id"`
+	Goal      string             `json:"goal" yaml:"goal"`
+	Tasks     []Task             `json:"tasks" yaml:"tasks"`
+	Timeline  ExecutionTimeline  `json:"timeline" yaml:"timeline"`
+	Resources ResourceAllocation `json:"resources" yaml:"resources"`
+	Strategy  ExecutionStrategy  `json:"strategy" yaml:"strategy"`
+	Metadata  map[string]string  `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
 
 // Result represents the result of a task execution
@@ -84,4 +164,11 @@ type Result struct {
 	Duration  time.Duration  `json:"duration"`
 	Metadata  map[string]any `json:"metadata,omitempty"`
 	Timestamp time.Time      `json:"timestamp"`
-}
\ No newline at end of file
+	// Resources is what the step consumed, recorded for the steps that ran on an agent or as a service
+	Resources *agents.ResourceUsage `json:"resources,omitempty"`
+	// LLM is the model call the step's agent reasoned with, for steps whose agent has a brain
+	LLM *agents.LLMUsage `json:"llm,omitempty"`
+
+	// Artifacts are outputs produced by the step; they are stored separately from the result
+	Artifacts []agents.Artifact `json:"-"`
+}
@@ -0,0 +1,381 @@
+package captain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// stallTailLines bounds how many of a stalled step's latest output lines are kept to describe it
+const stallTailLines = 20
+
+// MetadataReplannedAfter is the plan metadata entry naming the stalled step the rest of the
+// plan was replanned after
+const MetadataReplannedAfter = "replanned_after"
+
+// StallDecision is what the Captain chose to do about a stalled step
+type StallDecision string
+
+const (
+	// StallDecisionWait keeps the step running, flagging it again if it stays quiet
+	StallDecisionWait StallDecision = "wait"
+	// StallDecisionRetry kills the step and runs it again on a fresh agent
+	StallDecisionRetry StallDecision = "retry"
+	// StallDecisionReplan kills the step and has the Captain replan the rest of the goal
+	StallDecisionReplan StallDecision = "replan"
+)
+
+// Stall describes a step that has written no output for longer than its agent type's watchdog allows
+type Stall struct {
+	TaskID      string           `json:"task_id"`
+	Description string           `json:"description"`
+	AgentID     string           `json:"agent_id"`
+	AgentType   agents.AgentType `json:"agent_type"`
+	// Running is how long the step has been running
+	Running time.Duration `json:"running"`
+	// Quiet is how long ago the step last wrote output, or started if it wrote none
+	Quiet time.Duration `json:"quiet"`
+	// Output holds the step's latest output lines
+	Output []string `json:"output,omitempty"`
+	// Count is how many times the step has stalled, this time included
+	Count int `json:"count"`
+}
+
+// String describes the stall for the Captain
+func (s Stall) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Step %s on %s agent %s: %s\n", s.TaskID, s.AgentType, s.AgentID, s.Description)
+	fmt.Fprintf(&b, "Running for %s, no output for %s (stall %d)\n", s.Running.Round(time.Second), s.Quiet.Round(time.Second), s.Count)
+	if len(s.Output) == 0 {
+		b.WriteString("The step has written no output.\n")
+		return b.String()
+	}
+	b.WriteString("Latest output:\n")
+	for _, line := range s.Output {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	return b.String()
+}
+
+// StallHandler decides what to do about a stalled step, with the reason for its decision. It is
+// only consulted for agent types whose watchdog has decide set.
+type StallHandler func(ctx context.Context, stall Stall) (StallDecision, string)
+
+// SetWatchdog sets, per crew agent type, when a running step counts as stalled
+func (e *PlanExecutor) SetWatchdog(watchdog config.WatchdogConfigs) {
+	e.watchdog = watchdog
+}
+
+// SetStallHandler sets who decides what to do about stalled steps. Without one, stalls are
+// only logged.
+func (e *PlanExecutor) SetStallHandler(handler StallHandler) {
+	e.stallHandler = handler
+}
+
+// stepActivity tracks when a running step last wrote output and its latest lines
+type stepActivity struct {
+	mu    sync.Mutex
+	clock common.Clock
+	last  time.Time
+	lines []string
+}
+
+// sink returns an output sink recording the step's activity before passing each line on to next,
+// which may be nil
+func (a *stepActivity) sink(next agents.OutputSink) agents.OutputSink {
+	return agents.OutputSinkFunc(func(stream agents.OutputStream, line string) {
+		a.mu.Lock()
+		a.last = a.clock.Now()
+		a.lines = append(a.lines, line)
+		if len(a.lines) > stallTailLines {
+			a.lines = a.lines[len(a.lines)-stallTailLines:]
+		}
+		a.mu.Unlock()
+		if next != nil {
+			next.OutputLine(stream, line)
+		}
+	})
+}
+
+// touch restarts the quiet period, as after deciding to wait for the step
+func (a *stepActivity) touch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.last = a.clock.Now()
+}
+
+// state returns when the step last showed activity and its latest output lines
+func (a *stepActivity) state() (time.Time, []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last, append([]string(nil), a.lines...)
+}
+
+// stallWatch watches one run of a step for stalls
+type stallWatch struct {
+	config   config.WatchdogConfig
+	activity *stepActivity
+	start    time.Time
+	agent    agents.Agent
+	task     agents.Task
+	// flagged is the time of the activity the step went quiet after when it was last flagged,
+	// so each quiet period is flagged once
+	flagged  time.Time
+	count    int
+	deciding bool
+	decision StallDecision
+	quiet    time.Duration
+}
+
+// stallVerdict is a stall handler's decision about a stall
+type stallVerdict struct {
+	stall    Stall
+	decision StallDecision
+	reason   string
+}
+
+// watchStalls returns the stall watch for a step run on an agent, wrapping the task's output
+// sink to track its activity, or nil when the agent type's watchdog is off
+func (e *PlanExecutor) watchStalls(agent agents.Agent, task *agents.Task) *stallWatch {
+	watchdog := e.watchdog.For(string(agent.Type()))
+	if watchdog.StallAfter <= 0 {
+		return nil
+	}
+	now := e.clock.Now()
+	watch := &stallWatch{config: watchdog, activity: &stepActivity{clock: e.clock, last: now}, start: now, agent: agent, task: *task}
+	task.Output = watch.activity.sink(task.Output)
+	return watch
+}
+
+// check reports the stall when the step has been quiet for its threshold since it was last flagged
+func (w *stallWatch) check(now time.Time) (Stall, bool) {
+	if w == nil {
+		return Stall{}, false
+	}
+	last, lines := w.activity.state()
+	if now.Sub(last) < w.config.StallAfter || last.Equal(w.flagged) {
+		return Stall{}, false
+	}
+	w.flagged = last
+	w.count++
+	w.quiet = now.Sub(last)
+	return Stall{
+		TaskID:      w.task.ID,
+		Description: w.task.Description,
+		AgentID:     w.agent.ID(),
+		AgentType:   w.agent.Type(),
+		Running:     now.Sub(w.start),
+		Quiet:       w.quiet,
+		Output:      lines,
+		Count:       w.count,
+	}, true
+}
+
+// annotate records the step's stalls in its result
+func (w *stallWatch) annotate(result agents.Result) agents.Result {
+	if w == nil || w.count == 0 {
+		return result
+	}
+	data := make(map[string]interface{}, len(result.Data)+3)
+	for k, v := range result.Data {
+		data[k] = v
+	}
+	data["stalls"] = w.count
+	data["stalled_for"] = w.quiet.Round(time.Second).String()
+	if w.decision != "" {
+		data["stall_decision"] = string(w.decision)
+	}
+	result.Data = data
+	return result
+}
+
+// flagStall logs a stalled step and, when its watchdog has decide set, asks the stall handler
+// what to do about it, sending the verdict to verdicts
+func (e *PlanExecutor) flagStall(ctx context.Context, watch *stallWatch, stall Stall, verdicts chan<- stallVerdict) {
+	e.logger.Warn("Step stalled",
+		zap.String("task_id", stall.TaskID),
+		zap.String("agent_id", stall.AgentID),
+		zap.Duration("quiet", stall.Quiet),
+		zap.Int("stalls", stall.Count))
+	if !watch.config.Decide || e.stallHandler == nil || watch.deciding {
+		return
+	}
+	watch.deciding = true
+	go func() {
+		decision, reason := e.stallHandler(ctx, stall)
+		verdicts <- stallVerdict{stall: stall, decision: decision, reason: reason}
+	}()
+}
+
+// stopStep cancels a step and waits up to the shutdown grace period for it to finish
+func (e *PlanExecutor) stopStep(cancel context.CancelFunc, done <-chan agents.Result) {
+	cancel()
+	select {
+	case <-done:
+	case <-e.clock.After(e.shutdownGrace):
+	}
+}
+
+// stalledResult builds the result of a step stopped because it stalled
+func (e *PlanExecutor) stalledResult(task agents.Task, agent agents.Agent, verdict stallVerdict) agents.Result {
+	message := fmt.Sprintf("stalled with no output for %s; the Captain chose to %s", verdict.stall.Quiet.Round(time.Second), verdict.decision)
+	if verdict.reason != "" {
+		message += ": " + verdict.reason
+	}
+	return agents.Result{
+		TaskID:    task.ID,
+		Success:   false,
+		Error:     message,
+		Duration:  verdict.stall.Running,
+		Timestamp: e.clock.Now(),
+		Data: map[string]interface{}{
+			"agent_id": agent.ID(),
+		},
+	}
+}
+
+// replanRequested returns the step the Captain chose to replan after, or "" if none
+func replanRequested(results []Result) string {
+	for _, result := range results {
+		if result.Metadata["stall_decision"] == string(StallDecisionReplan) && !result.Success {
+			return result.TaskID
+		}
+	}
+	return ""
+}
+
+// DecideStall asks the LLM whether to keep waiting for a stalled step, kill and retry it, or
+// replan the rest of the goal, returning the decision and its reason
+func (c *Captain) DecideStall(ctx context.Context, stall Stall) (StallDecision, string, error) {
+	if c.llmProvider == nil {
+		return "", "", fmt.Errorf("no LLM provider configured")
+	}
+
+	systemPrompt := `You supervise steps run by a crew of agents. A step has written no output for a while.
+Decide whether to wait for it, kill it and retry it on a fresh agent, or stop it and replan the rest of the goal.
+Wait for steps that are plausibly still working, such as builds, downloads or file watches; retry steps that look hung;
+replan when the step cannot succeed as planned. Respond with only a JSON object:
+{"decision": "wait|retry|replan", "reason": "one sentence"}`
+
+	resp, err := c.llmProvider.GenerateCompletion(ctx, CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: stall.String()},
+		},
+		MaxTokens:   200,
+		Temperature: 0.1,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decide on stalled step: %w", err)
+	}
+	var parsed struct {
+		Decision StallDecision `json:"decision"`
+		Reason   string        `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(resp.Content)), &parsed); err != nil {
+		return "", "", fmt.Errorf("failed to parse stall decision: %w", err)
+	}
+	switch parsed.Decision {
+	case StallDecisionWait, StallDecisionRetry, StallDecisionReplan:
+		return parsed.Decision, strings.TrimSpace(parsed.Reason), nil
+	}
+	return "", "", fmt.Errorf("failed to parse stall decision: unknown decision %q", parsed.Decision)
+}
+
+// decideStall is the Captain's stall handler: it keeps waiting when no decision can be had
+func (c *Captain) decideStall(ctx context.Context, stall Stall) (StallDecision, string) {
+	decision, reason, err := c.DecideStall(ctx, stall)
+	if err != nil {
+		return StallDecisionWait, err.Error()
+	}
+	return decision, reason
+}
+
+// replanStalled revises the rest of a plan after the stalled step the Captain chose to replan
+// after and runs the revised steps, whose results follow those of the original plan. The
+// result's RevisedPlan is the original plan extended with the revised steps.
+func (c *Captain) replanStalled(ctx context.Context, executor *PlanExecutor, plan *ExecutionPlan, stalled string, results []Result, handoffs []Handoff) ([]Result, []Handoff, *ExecutionPlan, error) {
+	partial := &ExecutionResult{PlanID: plan.ID, TaskResults: results, Handoffs: handoffs,
+		Error: fmt.Sprintf("step %s stalled and the rest of the goal is being replanned", stalled)}
+	if conversation := c.Conversation(plan.Goal); conversation != nil {
+		conversation.RecordExecution(plan, partial)
+	}
+	revised, err := c.Replan(ctx, plan, partial)
+	if err != nil {
+		return results, handoffs, nil, fmt.Errorf("execution stopped: step %s stalled: %w", stalled, err)
+	}
+
+	revised = withDistinctTaskIDs(plan, revised)
+	revisedResults, revisedHandoffs, err := executor.Execute(ctx, revised)
+	extended := *plan
+	extended.Tasks = append(append([]Task(nil), plan.Tasks...), revised.Tasks...)
+	extended.Metadata = make(map[string]string, len(plan.Metadata)+1)
+	for k, v := range plan.Metadata {
+		extended.Metadata[k] = v
+	}
+	extended.Metadata[MetadataReplannedAfter] = stalled
+	return append(results, revisedResults...), append(handoffs, revisedHandoffs...), &extended, err
+}
+
+// withDistinctTaskIDs returns the revised plan with the IDs of its steps that the original
+// plan already uses suffixed, and the dependencies and conditions on them updated
+func withDistinctTaskIDs(original, revised *ExecutionPlan) *ExecutionPlan {
+	taken := make(map[string]bool, len(original.Tasks))
+	used := make(map[string]bool, len(original.Tasks)+len(revised.Tasks))
+	for _, task := range original.Tasks {
+		taken[task.ID] = true
+		used[task.ID] = true
+	}
+	for _, task := range revised.Tasks {
+		used[task.ID] = true
+	}
+	renamed := make(map[string]string)
+	for _, task := range revised.Tasks {
+		if !taken[task.ID] || renamed[task.ID] != "" {
+			continue
+		}
+		id := task.ID + "-revised"
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s-revised-%d", task.ID, n)
+		}
+		used[id] = true
+		renamed[task.ID] = id
+	}
+	if len(renamed) == 0 {
+		return revised
+	}
+
+	copied := *revised
+	copied.Tasks = make([]Task, len(revised.Tasks))
+	for i, task := range revised.Tasks {
+		if id, ok := renamed[task.ID]; ok {
+			task.ID = id
+		}
+		deps := make([]string, len(task.Dependencies))
+		for j, dep := range task.Dependencies {
+			if id, ok := renamed[dep]; ok {
+				dep = id
+			}
+			deps[j] = dep
+		}
+		task.Dependencies = deps
+		if task.Condition != nil {
+			if id, ok := renamed[task.Condition.Step]; ok {
+				condition := *task.Condition
+				condition.Step = id
+				task.Condition = &condition
+			}
+		}
+		copied.Tasks[i] = task
+	}
+	return &copied
+}
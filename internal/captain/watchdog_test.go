@@ -0,0 +1,207 @@
+package captain
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// quietAgent writes a line, then goes quiet until the step is cancelled or, when finishAfter is
+// set, until that long has passed. Steps whose ID does not start with "quiet" finish at once.
+type quietAgent struct {
+	*agents.BaseAgent
+	finishAfter time.Duration
+	runs        atomic.Int32
+}
+
+func (q *quietAgent) Execute(ctx context.Context, task agents.Task) agents.Result {
+	if !strings.HasPrefix(task.ID, "quiet") {
+		return agents.Result{TaskID: task.ID, Success: true, Output: "done " + task.ID}
+	}
+	if q.runs.Add(1) > 1 {
+		return agents.Result{TaskID: task.ID, Success: true, Output: "finished on retry"}
+	}
+	if task.Output != nil {
+		task.Output.OutputLine(agents.OutputStdout, "downloading dependencies")
+	}
+	var finished <-chan time.Time
+	if q.finishAfter > 0 {
+		finished = time.After(q.finishAfter)
+	}
+	select {
+	case <-ctx.Done():
+		return agents.Result{TaskID: task.ID, Success: false, Error: "killed"}
+	case <-finished:
+		return agents.Result{TaskID: task.ID, Success: true, Output: "finished"}
+	}
+}
+
+func watchdogExecutor(t *testing.T, agent *quietAgent, handler StallHandler, decide bool) (*PlanExecutor, *observer.ObservedLogs) {
+	t.Helper()
+	manager := agents.NewAgentManager()
+	manager.RegisterAgentType(agents.AgentTypeFile, func(id, name string) (agents.Agent, error) {
+		agent.BaseAgent = agents.NewBaseAgent(id, name, agents.AgentTypeFile)
+		return agent, nil
+	})
+	core, logs := observer.New(zap.WarnLevel)
+	executor := NewPlanExecutor(manager)
+	executor.SetLogger(zap.New(core))
+	executor.SetHeartbeatInterval(5 * time.Millisecond)
+	executor.SetShutdownGrace(time.Second)
+	executor.SetWatchdog(config.WatchdogConfigs{"file": {StallAfter: 20 * time.Millisecond, Decide: decide}})
+	executor.SetStallHandler(handler)
+	return executor, logs
+}
+
+func TestPlanExecutor_WatchdogLogsStall(t *testing.T) {
+	agent := &quietAgent{finishAfter: 100 * time.Millisecond}
+	executor, logs := watchdogExecutor(t, agent, func(ctx context.Context, stall Stall) (StallDecision, string) {
+		t.Error("the Captain is only asked with decide set")
+		return StallDecisionWait, ""
+	}, false)
+
+	result, handoffs := executor.ExecuteTask(context.Background(), Task{ID: "quiet-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "install"}})
+	require.True(t, result.Success, result.Error)
+	assert.Empty(t, handoffs)
+	assert.Equal(t, 1, result.Metadata["stalls"], "a quiet period is flagged once")
+	assert.NotContains(t, result.Metadata, "stall_decision")
+
+	stalls := logs.FilterMessage("Step stalled").All()
+	require.Len(t, stalls, 1)
+	assert.Equal(t, "quiet-1", stalls[0].ContextMap()["task_id"])
+}
+
+func TestPlanExecutor_WatchdogOff(t *testing.T) {
+	agent := &quietAgent{finishAfter: 40 * time.Millisecond}
+	executor, logs := watchdogExecutor(t, agent, nil, false)
+	executor.SetWatchdog(config.WatchdogConfigs{"network": {StallAfter: time.Millisecond}})
+
+	result, _ := executor.ExecuteTask(context.Background(), Task{ID: "quiet-1", Type: TaskTypeExecution})
+	require.True(t, result.Success)
+	assert.NotContains(t, result.Metadata, "stalls")
+	assert.Zero(t, logs.FilterMessage("Step stalled").Len())
+}
+
+func TestPlanExecutor_StallDecisions(t *testing.T) {
+	t.Run("wait", func(t *testing.T) {
+		agent := &quietAgent{finishAfter: 60 * time.Millisecond}
+		var asked []Stall
+		executor, _ := watchdogExecutor(t, agent, func(ctx context.Context, stall Stall) (StallDecision, string) {
+			asked = append(asked, stall)
+			return StallDecisionWait, "still downloading"
+		}, true)
+
+		result, handoffs := executor.ExecuteTask(context.Background(), Task{ID: "quiet-1", Type: TaskTypeExecution, Payload: map[string]any{"description": "install"}})
+		require.True(t, result.Success, result.Error)
+		assert.Empty(t, handoffs)
+		assert.Equal(t, "wait", result.Metadata["stall_decision"])
+		require.NotEmpty(t, asked)
+		assert.Equal(t, "install", asked[0].Description)
+		assert.Equal(t, []string{"downloading dependencies"}, asked[0].Output)
+		assert.GreaterOrEqual(t, asked[0].Quiet, 20*time.Millisecond)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		agent := &quietAgent{}
+		executor, _ := watchdogExecutor(t, agent, func(ctx context.Context, stall Stall) (StallDecision, string) {
+			return StallDecisionRetry, "looks hung"
+		}, true)
+
+		result, handoffs := executor.ExecuteTask(context.Background(), Task{ID: "quiet-1", Type: TaskTypeExecution})
+		require.True(t, result.Success, result.Error)
+		assert.Equal(t, "finished on retry", result.Output)
+		require.Len(t, handoffs, 1)
+		assert.Contains(t, handoffs[0].Reason, "was killed to be retried")
+	})
+
+	t.Run("replan", func(t *testing.T) {
+		agent := &quietAgent{}
+		executor, _ := watchdogExecutor(t, agent, func(ctx context.Context, stall Stall) (StallDecision, string) {
+			return StallDecisionReplan, "the mirror is down"
+		}, true)
+
+		plan := &ExecutionPlan{ID: "plan-1", Tasks: []Task{
+			{ID: "quiet-1", Type: TaskTypeExecution},
+			{ID: "task-2", Type: TaskTypeExecution, Dependencies: []string{"quiet-1"}},
+		}}
+		results, _, err := executor.Execute(context.Background(), plan)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "execution stopped: step quiet-1 stalled with no output")
+		require.Len(t, results, 1, "the steps after a stall to replan do not run")
+		assert.False(t, results[0].Success)
+		assert.Contains(t, results[0].Error, "the Captain chose to replan: the mirror is down")
+		assert.Equal(t, "replan", results[0].Metadata["stall_decision"])
+	})
+}
+
+func TestCaptain_ExecutePlan_ReplansStalledStep(t *testing.T) {
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: `{
+		"tasks": [
+			{"id": "task-2", "type": "execution", "priority": "high", "description": "Install from the vendored copy", "dependencies": []},
+			{"id": "task-3", "type": "validation", "priority": "high", "description": "Run the tests", "dependencies": ["task-2"]}
+		],
+		"strategy": "sequential",
+		"estimated_duration": "5m"
+	}`}, nil)
+	agent := &quietAgent{}
+	executor, _ := watchdogExecutor(t, agent, nil, true)
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM, planner: NewPlanningEngine(mockLLM)}
+	captain.SetExecutor(executor)
+	executor.SetStallHandler(func(ctx context.Context, stall Stall) (StallDecision, string) {
+		return StallDecisionReplan, "the mirror is down"
+	})
+
+	plan := &ExecutionPlan{ID: "plan-1", Goal: "install and test", Tasks: []Task{
+		{ID: "task-1", Type: TaskTypeAnalysis},
+		{ID: "quiet-2", Type: TaskTypeExecution, Dependencies: []string{"task-1"}},
+		{ID: "task-2", Type: TaskTypeValidation, Dependencies: []string{"quiet-2"}},
+	}}
+	result, err := captain.ExecutePlan(context.Background(), plan, false)
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+
+	var ids []string
+	for _, taskResult := range result.TaskResults {
+		ids = append(ids, taskResult.TaskID)
+	}
+	assert.Equal(t, []string{"task-1", "quiet-2", "task-2-revised", "task-3"}, ids)
+	require.NotNil(t, result.RevisedPlan)
+	assert.Equal(t, "quiet-2", result.RevisedPlan.Metadata[MetadataReplannedAfter])
+	require.Len(t, result.RevisedPlan.Tasks, 5)
+	assert.Equal(t, []string{"task-2-revised"}, result.RevisedPlan.Tasks[4].Dependencies)
+}
+
+func TestCaptain_DecideStall(t *testing.T) {
+	stall := Stall{TaskID: "task-1", Description: "install", AgentID: "file-001", AgentType: agents.AgentTypeFile,
+		Running: 6 * time.Minute, Quiet: 5 * time.Minute, Output: []string{"downloading dependencies"}, Count: 1}
+	assert.Equal(t, "Step task-1 on file agent file-001: install\nRunning for 6m0s, no output for 5m0s (stall 1)\nLatest output:\n  downloading dependencies\n", stall.String())
+
+	mockLLM := &MockLLMProvider{}
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.MatchedBy(func(req CompletionRequest) bool {
+		return req.Messages[1].Content == stall.String()
+	})).Return(&CompletionResponse{Content: `{"decision": "retry", "reason": "The download looks hung."}`}, nil).Once()
+	mockLLM.On("GenerateCompletion", mock.Anything, mock.Anything).Return(&CompletionResponse{Content: `{"decision": "abort"}`}, nil)
+	captain := &Captain{ID: "captain-1", llmProvider: mockLLM}
+
+	decision, reason, err := captain.DecideStall(context.Background(), stall)
+	require.NoError(t, err)
+	assert.Equal(t, StallDecisionRetry, decision)
+	assert.Equal(t, "The download looks hung.", reason)
+
+	_, _, err = captain.DecideStall(context.Background(), stall)
+	assert.EqualError(t, err, `failed to parse stall decision: unknown decision "abort"`)
+	decision, _ = captain.decideStall(context.Background(), stall)
+	assert.Equal(t, StallDecisionWait, decision, "the step keeps running when no decision can be had")
+}
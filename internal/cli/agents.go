@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents/plugins"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/ui"
+)
+
+// agentsStatsTimeout bounds the request for the daemon's agent statistics
+const agentsStatsTimeout = 5 * time.Second
+
+// AgentsCmd groups the agent commands
+type AgentsCmd struct {
+	List    AgentsListCmd    `cmd:"" default:"1" help:"List the agent types available to the crew"`
+	Stats   AgentsStatsCmd   `cmd:"" help:"Show the daemon's agents with their health and restart counts"`
+	Replay  AgentsReplayCmd  `cmd:"" help:"Replay a task's agent messages and step transitions on a timeline"`
+	History AgentsHistoryCmd `cmd:"" help:"Show the messages agents sent each other, or their volume with --stats"`
+	Send    AgentsSendCmd    `cmd:"" help:"Send a message to an agent of the running daemon and show its reply"`
+}
+
+// AgentsListCmd represents the agents list command
+type AgentsListCmd struct{}
+
+// Help returns detailed help for the agents list command
+func (l *AgentsListCmd) Help() string {
+	return `List the built-in agent types and those added by plugins, including external
+plugins declared under agents.plugins in the configuration.
+
+Examples:
+
+    capn agents
+    capn agents list`
+}
+
+func (l *AgentsListCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	listing := Listing{Columns: []string{"TYPE", "SOURCE"}}
+	for _, agentType := range builtinAgentTypes() {
+		listing.Rows = append(listing.Rows, []string{agentType, "built-in"})
+	}
+	for _, plugin := range plugins.Registered() {
+		listing.Rows = append(listing.Rows, []string{string(plugin.Type()), "go plugin"})
+	}
+	for _, plugin := range config.Agents.Plugins {
+		command := strings.TrimSpace(plugin.Command + " " + strings.Join(plugin.Args, " "))
+		listing.Rows = append(listing.Rows, []string{plugin.Type, fmt.Sprintf("plugin %s (%s)", plugin.DisplayName(), command)})
+	}
+	return newPresenter(out, globals).List(listing)
+}
+
+// builtinAgentTypes returns the built-in agent types, sorted
+func builtinAgentTypes() []string {
+	types := append([]string(nil), config.BuiltinAgentTypes...)
+	sort.Strings(types)
+	return types
+}
+
+// AgentsStatsCmd represents the agents stats command
+type AgentsStatsCmd struct {
+	Addr     string `help:"Dashboard address of the daemon (defaults to ui.listen)" placeholder:"HOST:PORT"`
+	Detailed bool   `help:"Also show each agent's task counts, busy time, error rate and messages"`
+}
+
+// Help returns detailed help for the agents stats command
+func (s *AgentsStatsCmd) Help() string {
+	return `Show the agents managed by a running daemon: their status and health, whether
+they can be given new work, and how often the health policy has restarted them.
+Warm pool hits count tasks that found an idle agent waiting (agents.lifecycle
+keeps idle agents per type ready and collects those idle past idle_ttl).
+With --detailed, each agent's executed and failed tasks, the time it spent
+busy, its average task duration and the messages it sent and received are
+shown too; the dashboard exports the same statistics for Prometheus at
+/metrics. The daemon must be serving its dashboard (ui.enabled).
+
+Examples:
+
+    capn agents stats
+    capn agents stats --detailed
+    capn agents stats --addr 127.0.0.1:7777`
+}
+
+func (s *AgentsStatsCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = config.UI.Listen
+	}
+
+	resp, err := fetchAgents(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(resp.Agents, func(i, j int) bool { return resp.Agents[i].ID < resp.Agents[j].ID })
+	presenter := newPresenter(out, globals)
+	return presenter.Show(resp, func(out io.Writer) error {
+		stats := resp.Stats
+		fmt.Fprintf(out, "Agents:        %d (%d idle, %d busy, %d stopped, %d error)\n",
+			stats.Total, stats.Idle, stats.Busy, stats.Stopped, stats.Error)
+		fmt.Fprintf(out, "Unschedulable: %d\n", stats.Unschedulable)
+		fmt.Fprintf(out, "Restarts:      %d\n", stats.Restarts)
+		fmt.Fprintf(out, "Warm pool:     %d hits, %d misses, %d idle agents collected\n", stats.PoolHits, stats.PoolMisses, stats.IdleCollected)
+		if len(resp.Agents) == 0 {
+			return nil
+		}
+
+		fmt.Fprintln(out)
+		listing := Listing{Columns: []string{"ID", "TYPE", "STATUS", "HEALTH", "SCHEDULABLE", "RESTARTS"}}
+		if s.Detailed {
+			listing.Columns = append(listing.Columns, "TASKS", "FAILED", "ERROR RATE", "BUSY", "AVG DURATION", "SENT", "RECEIVED")
+		}
+		for _, agent := range resp.Agents {
+			schedulable := "yes"
+			if !agent.Schedulable {
+				schedulable = "no"
+			}
+			row := []string{
+				agent.ID, string(agent.Type), string(agent.Status), string(agent.Health), schedulable, strconv.Itoa(agent.Restarts)}
+			if s.Detailed {
+				activity := stats.Activity[agent.ID]
+				row = append(row, strconv.Itoa(activity.Tasks), strconv.Itoa(activity.Failed),
+					fmt.Sprintf("%.0f%%", activity.ErrorRate*100), activity.BusyTime.Round(time.Millisecond).String(),
+					activity.AverageDuration.Round(time.Millisecond).String(),
+					strconv.Itoa(activity.MessagesSent), strconv.Itoa(activity.MessagesReceived))
+			}
+			listing.Rows = append(listing.Rows, row)
+		}
+		return presenter.List(listing)
+	})
+}
+
+// fetchAgents requests the agents endpoint of the daemon's dashboard
+func fetchAgents(ctx context.Context, addr string) (*ui.AgentsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, agentsStatsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/api/agents", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build agents request: %w", err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the daemon at %s (is `capn daemon` running with ui.enabled?): %w", addr, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon at %s returned status %d", addr, res.StatusCode)
+	}
+	var resp ui.AgentsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode agent statistics: %w", err)
+	}
+	return &resp, nil
+}
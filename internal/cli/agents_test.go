@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/ui"
+)
+
+func TestAgentsListCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+agents:
+  plugins:
+    - name: linter
+      type: lint
+      command: capn-lint
+      args: [--stdio]
+`), 0644))
+
+	for _, args := range [][]string{{"agents"}, {"agents", "list"}} {
+		output, err := runCLI(t, append([]string{"-c", configFile}, args...)...)
+		require.NoError(t, err)
+		assert.Contains(t, output, "file      built-in")
+		assert.Contains(t, output, "lint      plugin linter (capn-lint --stdio)")
+	}
+}
+
+func TestAgentsStatsCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	manager := agents.NewAgentManager()
+	manager.SetHealthPolicy(agents.HealthPolicy{RestartUnhealthy: true})
+	_, err := manager.SpawnAgent("file-1", "FileAgent", agents.AgentTypeFile)
+	require.NoError(t, err)
+	broken, err := manager.SpawnAgent("network-1", "NetworkAgent", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	broken.(*agents.BaseAgent).SetStatus(agents.AgentStatusError)
+	_, err = manager.RestartAgent("network-1")
+	require.NoError(t, err)
+	_, ok := manager.AcquireIdle(agents.AgentTypeFile)
+	require.True(t, ok)
+
+	server := httptest.NewServer(ui.NewServer(task.NewMemoryTaskStorage(), manager, nil, zap.NewNop()).Handler())
+	defer server.Close()
+
+	output, err := runCLI(t, "agents", "stats", "--addr", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	assert.Contains(t, output, "Agents:        2 (2 idle, 0 busy, 0 stopped, 0 error)")
+	assert.Contains(t, output, "Restarts:      1")
+	assert.Contains(t, output, "Warm pool:     1 hits, 0 misses, 0 idle agents collected")
+	assert.Contains(t, output, "ID         TYPE     STATUS  HEALTH   SCHEDULABLE  RESTARTS")
+	assert.Contains(t, output, "network-1  network  idle    healthy  yes          1")
+}
+
+func TestAgentsStatsCmd_Detailed(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	manager := agents.NewAgentManager()
+	_, err := manager.SpawnAgent("file-1", "FileAgent", agents.AgentTypeFile)
+	require.NoError(t, err)
+	manager.RecordTask("file-1", 3*time.Second, true)
+	manager.RecordTask("file-1", time.Second, false)
+
+	server := httptest.NewServer(ui.NewServer(task.NewMemoryTaskStorage(), manager, nil, zap.NewNop()).Handler())
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	output, err := runCLI(t, "agents", "stats", "--addr", addr, "--detailed")
+	require.NoError(t, err)
+	assert.Contains(t, output, "TASKS  FAILED  ERROR RATE  BUSY  AVG DURATION  SENT  RECEIVED")
+	assert.Regexp(t, `file-1 .* 2      1       50%         4s    2s            0     0`, output)
+
+	output, err = runCLI(t, "agents", "stats", "--addr", addr)
+	require.NoError(t, err)
+	assert.NotContains(t, output, "ERROR RATE")
+}
+
+func TestAgentsStatsCmd_DaemonUnavailable(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	_, err := runCLI(t, "agents", "stats", "--addr", addr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to reach the daemon at "+addr)
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// promptApprover asks on the terminal before each high-risk or gated step runs
+type promptApprover struct {
+	in          *bufio.Reader
+	out         io.Writer
+	approveRest bool
+}
+
+// newPromptApprover creates an approver that reads answers from in
+func newPromptApprover(in io.Reader, out io.Writer) *promptApprover {
+	return &promptApprover{in: bufio.NewReader(in), out: out}
+}
+
+// Approve shows the step and its risks and waits for a yes, no or approve-all answer
+func (p *promptApprover) Approve(ctx context.Context, request captain.ApprovalRequest) (bool, error) {
+	if p.approveRest {
+		return true, nil
+	}
+
+	switch {
+	case request.Gate && request.Risk.RequiresApproval():
+		fmt.Fprintf(p.out, "\nStep %s is held at a manual gate and is %s risk: %s\n", request.Task.ID, request.Risk.Level, strings.Join(request.Risk.Reasons, "; "))
+	case request.Gate:
+		fmt.Fprintf(p.out, "\nStep %s is held at a manual gate\n", request.Task.ID)
+	default:
+		fmt.Fprintf(p.out, "\nStep %s is %s risk: %s\n", request.Task.ID, request.Risk.Level, strings.Join(request.Risk.Reasons, "; "))
+	}
+	if description, ok := request.Task.Payload["description"].(string); ok && description != "" {
+		fmt.Fprintf(p.out, "  %s\n", description)
+	}
+	fmt.Fprintf(p.out, "Run this step? [y]es / [N]o / [a]ll remaining: ")
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		return false, fmt.Errorf("failed to read approval: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	case "a", "all":
+		p.approveRest = true
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// approverFor picks the approval policy for an execute run: --approve-all skips the gate,
+// a terminal gets an interactive prompt, and anything else refuses high-risk and gated steps.
+func approverFor(approveAll bool, in *os.File, out io.Writer) captain.Approver {
+	if approveAll {
+		return captain.ApproveAll
+	}
+	if !isTerminal(in) {
+		return captain.ApproverFunc(func(context.Context, captain.ApprovalRequest) (bool, error) {
+			return false, fmt.Errorf("approval required but stdin is not a terminal; rerun with --approve-all")
+		})
+	}
+	return newPromptApprover(in, out)
+}
+
+// auditApprover records every approval decision in the task log
+func auditApprover(approver captain.Approver, record *task.TaskExecution) captain.Approver {
+	return captain.ApproverFunc(func(ctx context.Context, request captain.ApprovalRequest) (bool, error) {
+		approved, err := approver.Approve(ctx, request)
+		kind := "High-risk step"
+		if request.Gate {
+			kind = "Gated step"
+		}
+		switch {
+		case err != nil:
+			record.AddStepLog(task.LogLevelWarn, request.Task.ID, "", fmt.Sprintf("%s not approved: %v", kind, err))
+		case approved && len(request.Risk.Reasons) == 0:
+			record.AddStepLog(task.LogLevelInfo, request.Task.ID, "", kind+" approved")
+		case approved:
+			record.AddStepLog(task.LogLevelInfo, request.Task.ID, "", fmt.Sprintf("%s approved (%s)", kind, strings.Join(request.Risk.Reasons, "; ")))
+		default:
+			record.AddStepLog(task.LogLevelWarn, request.Task.ID, "", kind+" rejected")
+		}
+		return approved, err
+	})
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func approvalRequest(id string) captain.ApprovalRequest {
+	step := captain.Task{ID: id, Type: captain.TaskTypeExecution, Payload: map[string]any{"description": "Delete old.json"}}
+	return captain.ApprovalRequest{Task: step, Risk: captain.AssessRisk(step)}
+}
+
+func TestPromptApprover(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers string
+		want    []bool
+	}{
+		{"yes", "y\n", []bool{true}},
+		{"default is no", "\n", []bool{false}},
+		{"explicit no", "no\n", []bool{false}},
+		{"all approves the rest without asking", "a\n", []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			approver := newPromptApprover(strings.NewReader(tt.answers), &out)
+			for i, want := range tt.want {
+				approved, err := approver.Approve(context.Background(), approvalRequest("task-1"))
+				require.NoError(t, err)
+				assert.Equal(t, want, approved, "answer %d", i)
+			}
+			assert.Equal(t, 1, strings.Count(out.String(), "Run this step?"))
+			assert.Contains(t, out.String(), "Step task-1 is high risk: deletes files (old.json)")
+		})
+	}
+
+	_, err := newPromptApprover(strings.NewReader(""), &bytes.Buffer{}).Approve(context.Background(), approvalRequest("task-1"))
+	assert.Error(t, err, "a closed input is not an approval")
+}
+
+func TestApproverFor(t *testing.T) {
+	approved, err := approverFor(true, os.Stdin, &bytes.Buffer{}).Approve(context.Background(), approvalRequest("task-1"))
+	require.NoError(t, err)
+	assert.True(t, approved)
+
+	pipe, err := os.CreateTemp(t.TempDir(), "stdin")
+	require.NoError(t, err)
+	defer pipe.Close()
+
+	approved, err = approverFor(false, pipe, &bytes.Buffer{}).Approve(context.Background(), approvalRequest("task-1"))
+	assert.False(t, approved)
+	assert.ErrorContains(t, err, "rerun with --approve-all")
+}
+
+func TestAuditApprover(t *testing.T) {
+	record := task.NewTaskExecution("clean up")
+	answers := []bool{true, false}
+	approver := auditApprover(captain.ApproverFunc(func(context.Context, captain.ApprovalRequest) (bool, error) {
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}), record)
+
+	_, _ = approver.Approve(context.Background(), approvalRequest("task-1"))
+	_, _ = approver.Approve(context.Background(), approvalRequest("task-2"))
+
+	require.Len(t, record.Logs, 2)
+	assert.Equal(t, "task-1", record.Logs[0].Step)
+	assert.Equal(t, "High-risk step approved (deletes files (old.json))", record.Logs[0].Message)
+	assert.Equal(t, task.LogLevelWarn, record.Logs[1].Level)
+	assert.Equal(t, "High-risk step rejected", record.Logs[1].Message)
+}
+
+func TestPromptApprover_ManualGate(t *testing.T) {
+	step := captain.Task{ID: "release", Type: captain.TaskTypeReporting, Gate: captain.GateManual, Payload: map[string]any{"description": "Summarize the test run"}}
+	request := captain.ApprovalRequest{Task: step, Risk: captain.AssessRisk(step), Gate: true}
+
+	var out bytes.Buffer
+	approved, err := newPromptApprover(strings.NewReader("y\n"), &out).Approve(context.Background(), request)
+	require.NoError(t, err)
+	assert.True(t, approved)
+	assert.Contains(t, out.String(), "Step release is held at a manual gate\n  Summarize the test run\n")
+
+	record := task.NewTaskExecution("ship it")
+	_, _ = auditApprover(captain.ApproveAll, record).Approve(context.Background(), request)
+	require.Len(t, record.Logs, 1)
+	assert.Equal(t, "Gated step approved (writes files)", record.Logs[0].Message)
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// TasksArtifactsCmd represents the tasks artifacts command
+type TasksArtifactsCmd struct {
+	TaskID string `arg:"" name:"task-id" help:"Task whose artifacts to show"`
+	Name   string `arg:"" optional:"" help:"Only this artifact"`
+	Open   bool   `help:"Open the artifact with the system's default application"`
+	CopyTo string `name:"copy-to" help:"Copy the artifacts into this directory" placeholder:"DIR" type:"path"`
+}
+
+// Help returns detailed help for the tasks artifacts command
+func (a *TasksArtifactsCmd) Help() string {
+	return `List the files, reports and downloads produced by a task's crew agents.
+Artifacts are kept under ~/.capn/artifacts/<task-id>/.
+
+Examples:
+
+    capn tasks artifacts task-1a2b3c4d
+    capn tasks artifacts task-1a2b3c4d report.md --open
+    capn tasks artifacts task-1a2b3c4d --copy-to ./out`
+}
+
+func (a *TasksArtifactsCmd) Run(out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(a.TaskID)
+	if err != nil {
+		return err
+	}
+
+	artifacts := t.Artifacts
+	if a.Name != "" {
+		artifact, ok := t.Artifact(a.Name)
+		if !ok {
+			return fmt.Errorf("artifact not found: %s", a.Name)
+		}
+		artifacts = []task.Artifact{artifact}
+	}
+	if len(artifacts) == 0 {
+		fmt.Fprintf(out, "No artifacts recorded for task %s.\n", t.ID)
+		return nil
+	}
+
+	if a.CopyTo != "" {
+		if err := os.MkdirAll(a.CopyTo, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", a.CopyTo, err)
+		}
+		for _, artifact := range artifacts {
+			if err := copyFile(artifact.Path, filepath.Join(a.CopyTo, artifact.Name)); err != nil {
+				return fmt.Errorf("failed to copy artifact %s: %w", artifact.Name, err)
+			}
+		}
+		fmt.Fprintf(out, "Copied %d artifact(s) to %s\n", len(artifacts), a.CopyTo)
+	}
+
+	if a.Open {
+		if len(artifacts) > 1 {
+			return fmt.Errorf("task %s has %d artifacts; name the one to open", t.ID, len(artifacts))
+		}
+		if err := openCommand(artifacts[0].Path).Start(); err != nil {
+			return fmt.Errorf("failed to open artifact %s: %w", artifacts[0].Name, err)
+		}
+		logger.Debug("Opened artifact", zap.String("path", artifacts[0].Path))
+	}
+
+	if a.CopyTo != "" || a.Open {
+		return nil
+	}
+
+	listing := Listing{Columns: []string{"NAME", "KIND", "SIZE", "STEP", "PATH"}, Records: artifacts}
+	for _, artifact := range artifacts {
+		listing.Rows = append(listing.Rows, []string{artifact.Name, string(artifact.Kind), strconv.FormatInt(artifact.Size, 10), artifact.Step, artifact.Path})
+	}
+	return newPresenter(out, globals).List(listing)
+}
+
+// collectArtifacts stores the artifacts produced by a run, logging rather than failing the command on errors
+func collectArtifacts(cfg *config.Config, record *task.TaskExecution, results []captain.Result, logger *zap.Logger) {
+	store, err := task.NewArtifactStore(cfg.ArtifactsDir())
+	if err != nil {
+		logger.Warn("Failed to open artifact store", zap.Error(err))
+		return
+	}
+	if err := store.Collect(record, results); err != nil {
+		logger.Warn("Failed to store some artifacts", zap.String("task_id", record.ID), zap.Error(err))
+	}
+}
+
+// copyFile copies the file at src to dst, replacing dst if it exists
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// openCommand returns the command that opens path with the platform's default application
+func openCommand(path string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("cmd", "/C", "start", "", path)
+	case "darwin":
+		return exec.Command("open", path)
+	default:
+		return exec.Command("xdg-open", path)
+	}
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func seedArtifacts(t *testing.T) *task.TaskExecution {
+	t.Helper()
+	te := seedTask(t, task.TaskStatusCompleted)
+	cfg := config.NewConfig()
+	collectArtifacts(cfg, te, []captain.Result{
+		{TaskID: "task-1", Metadata: map[string]any{"agent_id": "research-001"}, Artifacts: []agents.Artifact{
+			{Name: "report.md", Kind: agents.ArtifactKindReport, Content: []byte("# Report\n")},
+			{Name: "data.json", Kind: agents.ArtifactKindDownload, Content: []byte("{}")},
+		}},
+	}, zap.NewNop())
+
+	storage, err := task.NewFileTaskStorage(cfg.TasksDir())
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveTask(te))
+	return te
+}
+
+func TestTasksArtifactsCmd_List(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+	te := seedArtifacts(t)
+
+	out, err := runCLI(t, "tasks", "artifacts", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "NAME")
+	assert.Contains(t, out, "report.md")
+	assert.Contains(t, out, "download")
+	assert.Contains(t, out, filepath.Join(home, "artifacts", te.ID, "data.json"))
+
+	out, err = runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Artifacts (2):")
+	assert.Contains(t, out, "Registered report artifact report.md")
+
+	_, err = runCLI(t, "tasks", "artifacts", te.ID, "nope.txt")
+	assert.EqualError(t, err, "artifact not found: nope.txt")
+}
+
+func TestTasksArtifactsCmd_NoArtifacts(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "tasks", "artifacts", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "No artifacts recorded for task "+te.ID)
+}
+
+func TestTasksArtifactsCmd_CopyTo(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedArtifacts(t)
+	dest := filepath.Join(t.TempDir(), "out")
+
+	out, err := runCLI(t, "tasks", "artifacts", te.ID, "--copy-to", dest)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Copied 2 artifact(s) to "+dest)
+
+	data, err := os.ReadFile(filepath.Join(dest, "report.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Report\n", string(data))
+
+	single := filepath.Join(t.TempDir(), "single")
+	_, err = runCLI(t, "tasks", "artifacts", te.ID, "data.json", "--copy-to", single)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(single, "data.json"))
+	assert.NoFileExists(t, filepath.Join(single, "report.md"))
+}
+
+func TestTasksArtifactsCmd_OpenNeedsName(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedArtifacts(t)
+
+	_, err := runCLI(t, "tasks", "artifacts", te.ID, "--open")
+	assert.EqualError(t, err, "task "+te.ID+" has 2 artifacts; name the one to open")
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// Exit codes of "capn execute --attach" for the status its task finished with
+const (
+	ExitTaskFailed    = 1
+	ExitTaskCancelled = 2
+)
+
+// ExitError is returned by a command that should end the process with a particular code
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
+// commandLine holds the arguments capn was invoked with, so a command can start itself again
+type commandLine []string
+
+// startBackground starts capn in a separate process with the given arguments, writing its
+// output to the log file; it is replaced in tests
+var startBackground = func(args []string, log *os.File) (<-chan error, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the capn executable: %w", err)
+	}
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout, cmd.Stderr = log, log
+	// Interrupting the attached command detaches from the task rather than stopping it
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start background task: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	return exited, nil
+}
+
+// backgroundArgs returns the command line that runs an attached execute in the background,
+// recording it under the given task ID
+func backgroundArgs(args commandLine, id string) []string {
+	var background []string
+	for _, arg := range args {
+		if arg == "--attach" || strings.HasPrefix(arg, "--attach=") {
+			continue
+		}
+		background = append(background, arg)
+	}
+	// Everything after "--" is the goal, so the task ID goes before it
+	for i, arg := range background {
+		if arg == "--" {
+			return append(append(background[:i:i], "--task-id", id), background[i:]...)
+		}
+	}
+	return append(background, "--task-id", id)
+}
+
+// attach starts the goal as a background task and shows its log until it finishes, ending
+// with an *ExitError unless the task completed. Interrupting detaches, leaving it running.
+func (e *ExecuteCmd) attach(ctx context.Context, out io.Writer, globals *GlobalOptions, cfg *config.Config, line commandLine) error {
+	storage, err := openTaskStorage(cfg)
+	if err != nil {
+		return err
+	}
+	id := task.NewTaskID()
+	log, err := os.CreateTemp("", "capn-"+id+"-*.log")
+	if err != nil {
+		return fmt.Errorf("failed to create the background task's log: %w", err)
+	}
+	defer log.Close()
+
+	exited, err := startBackground(backgroundArgs(line, id), log)
+	if err != nil {
+		return err
+	}
+	if globals.quiet() {
+		fmt.Fprintln(out, id)
+	} else {
+		fmt.Fprintf(out, "Task %s started in the background; press Ctrl-C to detach\n", id)
+	}
+	return followTask(ctx, out, globals, storage, id, exited, log.Name())
+}
+
+// followTask shows a task's log as it is recorded until the task finishes, ending with an
+// *ExitError unless it completed. exited and logFile belong to the background process capn
+// started to run the task, if any. Interrupting detaches, leaving the task running.
+func followTask(ctx context.Context, out io.Writer, globals *GlobalOptions, storage task.TaskStorage, id string, exited <-chan error, logFile string) error {
+	ticker := clock.NewTicker(followInterval)
+	defer ticker.Stop()
+	shown := 0
+	for {
+		// The task is not recorded until the background process has loaded its configuration
+		if t, err := storage.GetTask(id); err == nil {
+			if !globals.quiet() {
+				if shown > len(t.Logs) {
+					shown = len(t.Logs)
+				}
+				for _, entry := range t.Logs[shown:] {
+					fmt.Fprintln(out, formatLogEntry(entry))
+				}
+			}
+			shown = len(t.Logs)
+			if t.Status.IsTerminal() {
+				return attachedOutcome(out, globals, t, logFile)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			notef(out, globals, "Detached from task %s; it keeps running. Follow it with \"capn tasks logs %s --follow\".", id, id)
+			return nil
+		case err := <-exited:
+			exited = nil
+			// The process may have exited just after its last save; look once more before failing
+			if _, getErr := storage.GetTask(id); getErr != nil {
+				return fmt.Errorf("background task %s exited before it was recorded (%v): %s", id, err, logTail(logFile))
+			}
+		case <-ticker.C():
+		}
+	}
+}
+
+// attachedOutcome reports how an attached task finished, returning an *ExitError unless it
+// completed. The background process's output, if any, is removed once the task is recorded.
+func attachedOutcome(out io.Writer, globals *GlobalOptions, t *task.TaskExecution, logFile string) error {
+	if logFile != "" {
+		os.Remove(logFile)
+	}
+	notef(out, globals, "Task %s %s after %s", t.ID, t.Status, t.Duration().Round(time.Millisecond))
+	switch t.Status {
+	case task.TaskStatusCompleted:
+		return nil
+	case task.TaskStatusCancelled:
+		return &ExitError{Code: ExitTaskCancelled, Message: fmt.Sprintf("task %s was cancelled", t.ID)}
+	default:
+		message := fmt.Sprintf("task %s %s", t.ID, t.Status)
+		if t.Error != "" {
+			message += ": " + t.Error
+		}
+		return &ExitError{Code: ExitTaskFailed, Message: message}
+	}
+}
+
+// logTail returns the last lines a background process wrote, for explaining why it stopped
+func logTail(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return "it wrote no output"
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestBackgroundArgs(t *testing.T) {
+	assert.Equal(t, []string{"--quiet", "execute", "--approve-all", "deploy", "--task-id", "task-1"},
+		backgroundArgs(commandLine{"--quiet", "execute", "--attach", "--approve-all", "deploy"}, "task-1"))
+	assert.Equal(t, []string{"execute", "--task-id", "task-1", "--", "--attach is part of the goal"},
+		backgroundArgs(commandLine{"execute", "--attach=true", "--", "--attach is part of the goal"}, "task-1"))
+}
+
+// fakeBackground replaces the background process with one that records the task the way
+// run would, ending with the given status. It returns the arguments it was started with and
+// the fake clock the CLI follows the task on.
+func fakeBackground(t *testing.T, status task.TaskStatus) (*[]string, *testutil.FakeClock) {
+	t.Helper()
+	clock := useFakeClock(t)
+	t.Cleanup(func(start func([]string, *os.File) (<-chan error, error)) func() {
+		return func() { startBackground = start }
+	}(startBackground))
+
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+	var started []string
+	startBackground = func(args []string, log *os.File) (<-chan error, error) {
+		started = args
+		exited := make(chan error, 1)
+		go func() {
+			record := task.NewTaskExecution("deploy")
+			record.ID = args[len(args)-1]
+			record.SetStatus(task.TaskStatusRunning)
+			record.AddStepLog(task.LogLevelInfo, "step-1", "shell-001", "Step step-1 started")
+			_ = storage.SaveTask(record)
+			// Let the follower poll the running task before it finishes
+			clock.BlockUntil(1)
+			clock.Advance(followInterval)
+			record.AddStepLog(task.LogLevelInfo, "step-1", "shell-001", "Step step-1 succeeded")
+			if status == task.TaskStatusFailed {
+				record.Error = "step-2 failed"
+			}
+			record.SetStatus(status)
+			_ = storage.SaveTask(record)
+			exited <- nil
+		}()
+		return exited, nil
+	}
+	return &started, clock
+}
+
+func TestExecuteCmd_Attach(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	started, _ := fakeBackground(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "execute", "--attach", "deploy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"execute", "deploy", "--task-id"}, (*started)[:3])
+	id := (*started)[3]
+	assert.Contains(t, out, "Task "+id+" started in the background; press Ctrl-C to detach")
+	assert.Contains(t, out, "[step-1] Step step-1 started")
+	assert.Contains(t, out, "[step-1] Step step-1 succeeded")
+	assert.Contains(t, out, "Task "+id+" completed after")
+	assert.Equal(t, 1, strings.Count(out, "Step step-1 started"), "entries are shown once")
+}
+
+func TestExecuteCmd_AttachFailed(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	started, _ := fakeBackground(t, task.TaskStatusFailed)
+
+	out, err := runCLI(t, "--quiet", "execute", "--attach", "deploy")
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitTaskFailed, exitErr.Code)
+	id := (*started)[len(*started)-1]
+	assert.Equal(t, "task "+id+" failed: step-2 failed", exitErr.Error())
+	assert.Equal(t, id+"\n", out, "quiet runs print only the task ID")
+}
+
+func TestExecuteCmd_AttachErrors(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Cleanup(func(start func([]string, *os.File) (<-chan error, error)) func() {
+		return func() { startBackground = start }
+	}(startBackground))
+
+	_, err := runCLI(t, "execute", "--attach", "--plan-only", "deploy")
+	assert.EqualError(t, err, "--attach cannot be combined with --plan-only, --simulate or --dry-run")
+
+	startBackground = func(args []string, log *os.File) (<-chan error, error) {
+		_, _ = log.WriteString("failed to load config: bad profile\n")
+		exited := make(chan error, 1)
+		exited <- errors.New("exit status 1")
+		return exited, nil
+	}
+	_, err = runCLI(t, "execute", "--attach", "deploy")
+	assert.ErrorContains(t, err, "exited before it was recorded (exit status 1): failed to load config: bad profile")
+}
+
+func TestAttachedOutcome(t *testing.T) {
+	record := task.NewTaskExecution("deploy")
+	record.SetStatus(task.TaskStatusCancelled)
+	err := attachedOutcome(&strings.Builder{}, &GlobalOptions{}, record, "")
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitTaskCancelled, exitErr.Code)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// createNewProcessGroup is the CREATE_NEW_PROCESS_GROUP process creation flag
+const createNewProcessGroup = 0x00000200
+
+// detachProcess starts the process in its own process group, out of reach of the console's
+// Ctrl-C
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup}
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	out, err := runCLI(t, "bench", "--tasks", "10", "--steps", "2", "--messages", "4", "--concurrency", "2")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Bench: 10 tasks x 2 steps, 4 messages per task, concurrency 2, memory storage")
+	assert.Contains(t, out, "10 completed")
+	assert.Contains(t, out, "40 routed")
+	assert.Contains(t, out, "Lock wait:")
+	assert.NotContains(t, out, "Errors:")
+}
+
+func TestBenchCmd_Hidden(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	assert.NotContains(t, completeLines(t, ""), "bench")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "3.0 MiB", formatBytes(3<<20))
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// maxFindingWidth bounds the value shown for each finding in the task summary
+const maxFindingWidth = 60
+
+// newBlackboard creates the blackboard agents share findings on while a task runs. Without
+// an artifact store, findings carrying artifacts are refused.
+func newBlackboard(cfg *config.Config, storage task.TaskStorage, record *task.TaskExecution, logger *zap.Logger) *task.TaskBlackboard {
+	store, err := task.NewArtifactStore(cfg.ArtifactsDir())
+	if err != nil {
+		logger.Warn("Failed to open artifact store", zap.Error(err))
+	}
+	return task.NewTaskBlackboard(storage, record, store)
+}
+
+// printBlackboardSummary lists the findings of a task with their values on one line
+func printBlackboardSummary(out io.Writer, t *task.TaskExecution) {
+	if len(t.Blackboard) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nBlackboard (%d):\n", len(t.Blackboard))
+	for _, entry := range t.Blackboard {
+		var value bytes.Buffer
+		if err := json.Compact(&value, entry.Value); err != nil {
+			value.Reset()
+			value.Write(entry.Value)
+		}
+		fmt.Fprintf(out, "  %s (%s): %s\n", entry.Key, findingSource(entry), truncate(value.String(), maxFindingWidth))
+	}
+}
+
+// printBlackboard shows every finding of a task in full, with the artifacts published with it
+func printBlackboard(out io.Writer, t *task.TaskExecution) {
+	if len(t.Blackboard) == 0 {
+		fmt.Fprintf(out, "Task %s has no findings on its blackboard.\n", t.ID)
+		return
+	}
+	for i, entry := range t.Blackboard {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s (%s, %s)\n", entry.Key, findingSource(entry), entry.UpdatedAt.Format("2006-01-02 15:04:05"))
+		if len(entry.Value) > 0 {
+			var value bytes.Buffer
+			if err := json.Indent(&value, entry.Value, "  ", "  "); err != nil {
+				value.Reset()
+				value.Write(entry.Value)
+			}
+			fmt.Fprintf(out, "  %s\n", value.String())
+		}
+		for _, artifact := range entry.Artifacts {
+			fmt.Fprintf(out, "  artifact %s [%s] %s\n", artifact.Name, artifact.Kind, artifact.Path)
+		}
+	}
+}
+
+// findingSource names the agent and step that published a finding
+func findingSource(entry task.BlackboardEntry) string {
+	return questionSource(task.Question{Step: entry.Step, Agent: entry.Agent})
+}
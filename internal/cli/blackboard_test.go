@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksShowCmd_Blackboard(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "tasks", "show", te.ID, "--blackboard")
+	require.NoError(t, err)
+	assert.Equal(t, "Task "+te.ID+" has no findings on its blackboard.\n", out)
+
+	cfg := config.NewConfig()
+	storage, err := task.NewFileTaskStorage(cfg.TasksDir())
+	require.NoError(t, err)
+	board := newBlackboard(cfg, storage, te, zap.NewNop())
+	require.NoError(t, board.Publish(context.Background(), agents.Finding{
+		Key:       "hotspots",
+		Value:     map[string]interface{}{"files": []string{"planner.go", "captain.go"}},
+		Step:      "task-1",
+		Agent:     "research-001",
+		Artifacts: []agents.Artifact{{Name: "hotspots.md", Kind: agents.ArtifactKindReport, Content: []byte("# Hotspots\n")}},
+	}))
+
+	out, err = runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Blackboard (1):\n  hotspots (research-001 on task-1): {\"files\":[\"planner.go\",\"captain.go\"]}\n")
+	assert.Contains(t, out, "Published finding hotspots")
+
+	out, err = runCLI(t, "tasks", "show", te.ID, "--blackboard")
+	require.NoError(t, err)
+	assert.Contains(t, out, "hotspots (research-001 on task-1, ")
+	assert.Contains(t, out, "  {\n    \"files\": [\n      \"planner.go\",")
+	assert.Contains(t, out, "  artifact hotspots.md [report] ")
+	assert.NotContains(t, out, "Goal:")
+}
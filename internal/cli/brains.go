@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/agents/crew"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// installCrewAgents has the manager spawn crew agents for each agent type whose brain is
+// enabled in crew.brains or whose crew.file, crew.network or crew.research block is set
+func installCrewAgents(manager *agents.AgentManager, cfg *config.Config, logger *zap.Logger) error {
+	factory := crew.NewCrewAgentFactory()
+	factory.SetLimits(cfg.Crew.Limits)
+	factory.SetLogger(logger)
+	factory.SetHTTP(cfg.Crew.HTTP)
+	factory.SetAgentConfigs(cfg.Crew.File, cfg.Crew.Network, cfg.Crew.Research)
+	if cfg.Crew.HasBrains() {
+		provider, err := captain.NewProviderChainFromConfig(cfg, openAIConfig(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to create crew LLM provider: %w", err)
+		}
+		factory.SetBrains(provider, cfg.Crew.Brains)
+	}
+	return factory.Install(manager)
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/agents/crew"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestInstallCrewAgents(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+
+	cfg := config.NewConfig()
+	manager := agents.NewAgentManager()
+	require.NoError(t, installCrewAgents(manager, cfg, zap.NewNop()))
+	agent, err := manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	assert.IsType(t, &agents.BaseAgent{}, agent, "without brains the default agents are kept")
+
+	cfg.Crew.Brains = map[string]config.CrewBrainConfig{"file": {Enabled: true, Model: "gpt-4o-mini"}}
+	manager = agents.NewAgentManager()
+	require.NoError(t, installCrewAgents(manager, cfg, zap.NewNop()))
+	agent, err = manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	assert.IsType(t, &crew.FileAgent{}, agent)
+
+	cfg.Crew.Brains = nil
+	cfg.Crew.File.SandboxRoot = t.TempDir()
+	manager = agents.NewAgentManager()
+	require.NoError(t, installCrewAgents(manager, cfg, zap.NewNop()))
+	agent, err = manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	assert.IsType(t, &crew.FileAgent{}, agent, "agent types with settings get crew agents")
+
+	cfg.Crew.Brains = map[string]config.CrewBrainConfig{"file": {Enabled: true, Tools: []string{"download"}}}
+	assert.ErrorContains(t, installCrewAgents(agents.NewAgentManager(), cfg, zap.NewNop()), `unknown file agent tool "download"`)
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// TasksExportCmd represents the tasks export command
+type TasksExportCmd struct {
+	TaskID string `arg:"" name:"task-id" help:"Task to export"`
+	Bundle bool   `help:"Write a compressed bundle with the task's logs, transcript and artifacts, for \"capn tasks import\""`
+	Output string `help:"Write to a file instead of stdout (bundles default to <task-id>.capn)" short:"o" type:"path" placeholder:"FILE"`
+}
+
+// Help returns detailed help for the tasks export command
+func (e *TasksExportCmd) Help() string {
+	return `Export a task's record as JSON. With --bundle, a finished task is written as a
+single compressed archive instead, holding its record, plan, logs, transcript,
+planner audit trail and the contents of its artifacts. Bundles can be loaded
+into another machine's task history with "capn tasks import", to share a run
+or attach it to a support case.
+
+Bundles are gzip-compressed tar archives and can be inspected with
+"tar -tzf".
+
+Examples:
+
+    capn tasks export task-1a2b3c4d > task.json
+    capn tasks export task-1a2b3c4d --bundle
+    capn tasks export task-1a2b3c4d --bundle -o support.capn`
+}
+
+func (e *TasksExportCmd) Run(out io.Writer, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(e.TaskID)
+	if err != nil {
+		return err
+	}
+
+	if !e.Bundle {
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode task %s: %w", t.ID, err)
+		}
+		data = append(data, '\n')
+		if e.Output == "" {
+			_, err = out.Write(data)
+			return err
+		}
+		if err := os.WriteFile(e.Output, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write task: %w", err)
+		}
+		fmt.Fprintf(out, "Task %s written to %s\n", t.ID, e.Output)
+		return nil
+	}
+
+	path := e.Output
+	if path == "" {
+		path = t.ID + ".capn"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := task.WriteBundle(f, t, clock.Now()); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	fmt.Fprintf(out, "Task %s bundled with %d artifacts in %s\n", t.ID, len(t.Artifacts), path)
+	return nil
+}
+
+// TasksImportCmd represents the tasks import command
+type TasksImportCmd struct {
+	Bundle string `arg:"" help:"Bundle written by \"capn tasks export --bundle\"" type:"existingfile"`
+}
+
+// Help returns detailed help for the tasks import command
+func (i *TasksImportCmd) Help() string {
+	return `Load a task bundle into the task history, copying its artifacts into the
+artifact store. The task keeps its ID and workspace; a task with the same ID
+that is already recorded is never replaced.
+
+Examples:
+
+    capn tasks import task-1a2b3c4d.capn
+    capn tasks show task-1a2b3c4d`
+}
+
+func (i *TasksImportCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	store, err := task.NewArtifactStore(config.ArtifactsDir())
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(i.Bundle)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	t, err := task.ReadBundle(f, storage, store)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Imported task %s (%s) with %d artifacts\n", t.ID, t.Status, len(t.Artifacts))
+	if workspace, err := currentWorkspace(globals); err == nil && t.Workspace != "" && t.Workspace != workspace {
+		fmt.Fprintf(out, "It belongs to workspace %s; list it with \"capn tasks list --all-workspaces\".\n", formatWorkspace(t.Workspace))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksExportCmd_JSON(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "tasks", "export", te.ID)
+	require.NoError(t, err)
+	var exported task.TaskExecution
+	require.NoError(t, json.Unmarshal([]byte(out), &exported))
+	assert.Equal(t, te.ID, exported.ID)
+	assert.Equal(t, te.Goal, exported.Goal)
+}
+
+func TestTasksExportCmd_BundleImport(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedArtifacts(t)
+	bundle := filepath.Join(t.TempDir(), "support.capn")
+
+	out, err := runCLI(t, "tasks", "export", te.ID, "--bundle", "-o", bundle)
+	require.NoError(t, err)
+	assert.Equal(t, "Task "+te.ID+" bundled with 2 artifacts in "+bundle+"\n", out)
+
+	// Another machine's history
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+	out, err = runCLI(t, "tasks", "import", bundle)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Imported task "+te.ID+" (completed) with 2 artifacts")
+
+	out, err = runCLI(t, "tasks", "artifacts", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, filepath.Join(home, "artifacts", te.ID, "report.md"))
+	data, err := os.ReadFile(filepath.Join(home, "artifacts", te.ID, "report.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Report\n", string(data))
+
+	_, err = runCLI(t, "tasks", "import", bundle)
+	assert.EqualError(t, err, "task "+te.ID+" already exists")
+}
+
+func TestTasksExportCmd_BundleRefusesRunningTasks(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusRunning)
+	bundle := filepath.Join(t.TempDir(), "task.capn")
+
+	_, err := runCLI(t, "tasks", "export", te.ID, "--bundle", "-o", bundle)
+	assert.EqualError(t, err, "task "+te.ID+" is running: only finished tasks can be bundled")
+	assert.NoFileExists(t, bundle)
+}
@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// maxChatLogEntries bounds the log entries the task_logs tool returns
+const maxChatLogEntries = 100
+
+// ChatCmd represents the chat command
+type ChatCmd struct {
+	Message []string `arg:"" optional:"" help:"Message to send; without one, chat interactively"`
+	Resume  string   `help:"Continue a saved conversation" placeholder:"CHAT-ID"`
+	List    bool     `help:"List saved conversations"`
+}
+
+// Help returns detailed help for the chat command
+func (c *ChatCmd) Help() string {
+	return `Talk with the Captain about the current workspace and its recent tasks. The
+Captain looks up task plans, results and logs as it needs them, so questions
+such as "why did step 3 fail?" are answered from what was recorded.
+
+Conversations are saved in the capn home directory after every message;
+continue one with --resume, or list them with --list. Without a message, the
+chat is interactive: enter "exit" or press Ctrl-D to leave.
+
+Examples:
+
+    capn chat
+    capn chat "why did the last task fail?"
+    capn chat --resume chat-1a2b3c4d "what should I change?"
+    capn chat --list`
+}
+
+func (c *ChatCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	store, err := openChatStore(config)
+	if err != nil {
+		return err
+	}
+	if c.List {
+		return listChats(out, globals, store)
+	}
+
+	chat, err := c.chat(store, globals)
+	if err != nil {
+		return err
+	}
+	if !llmConfigured(config) {
+		return fmt.Errorf("OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to chat with the Captain")
+	}
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	cap, err := newCaptain(config)
+	if err != nil {
+		return err
+	}
+	defer cap.Stop()
+
+	session := &chatSession{captain: cap, store: store, chat: chat, tools: chatTools(storage, chat.Workspace), out: out}
+	session.background = chatBackground(storage, chat.Workspace)
+
+	if message := strings.Join(c.Message, " "); message != "" {
+		if err := session.send(ctx, message); err != nil {
+			return err
+		}
+	} else {
+		if err := session.interactive(); err != nil {
+			return err
+		}
+	}
+	if len(chat.Entries) > 0 {
+		notef(out, globals, "Conversation %s saved; continue it with \"capn chat --resume %s\"", chat.ID, chat.ID)
+	}
+	return nil
+}
+
+// chat returns the conversation to continue, or a new one about the current workspace
+func (c *ChatCmd) chat(store *captain.ChatStore, globals *GlobalOptions) (*captain.Chat, error) {
+	if c.Resume != "" {
+		return store.Get(c.Resume)
+	}
+	workspace, err := currentWorkspace(globals)
+	if err != nil {
+		return nil, err
+	}
+	return captain.NewChat(workspace), nil
+}
+
+// listChats prints the saved conversations, most recent first
+func listChats(out io.Writer, globals *GlobalOptions, store *captain.ChatStore) error {
+	chats, err := store.List()
+	if err != nil {
+		return err
+	}
+	listing := Listing{Columns: []string{"ID", "UPDATED", "MESSAGES", "WORKSPACE", "TITLE"}, Empty: "No conversations saved."}
+	for _, chat := range chats {
+		messages := 0
+		for _, entry := range chat.Entries {
+			if entry.Phase == captain.PhaseChat {
+				messages++
+			}
+		}
+		listing.Rows = append(listing.Rows, []string{
+			chat.ID, chat.UpdatedAt.Format("2006-01-02 15:04"), strconv.Itoa(messages), chat.Workspace, chat.Title()})
+	}
+	return newPresenter(out, globals).List(listing)
+}
+
+// openChatStore opens the conversation store in the capn home directory
+func openChatStore(cfg *config.Config) (*captain.ChatStore, error) {
+	store, err := captain.NewChatStore(cfg.ConversationsDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open conversation store: %w", err)
+	}
+	return store, nil
+}
+
+// chatSession sends the operator's messages to the Captain, saving the conversation after each
+type chatSession struct {
+	captain    *captain.Captain
+	store      *captain.ChatStore
+	chat       *captain.Chat
+	tools      []captain.ChatTool
+	background string
+	out        io.Writer
+}
+
+// send answers one message and saves the conversation
+func (s *chatSession) send(ctx context.Context, message string) error {
+	answer, err := s.captain.Chat(ctx, s.chat, s.background, s.tools, message)
+	if saveErr := s.store.Save(s.chat); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, answer)
+	return nil
+}
+
+// interactive reads messages until the input ends or the chat is exited, showing the
+// conversation so far when one is resumed
+func (s *chatSession) interactive() error {
+	for _, entry := range s.chat.Entries {
+		if entry.Phase != captain.PhaseChat {
+			continue
+		}
+		speaker := "you"
+		if entry.Role == "assistant" {
+			speaker = "captain"
+		}
+		fmt.Fprintf(s.out, "%s> %s\n", speaker, entry.Content)
+	}
+	fmt.Fprintf(s.out, "capn chat: ask the Captain about this workspace's tasks, \"exit\" to leave\n")
+
+	var history []string
+	var input lineReader = &plainReader{scanner: bufio.NewScanner(os.Stdin), out: s.out, prompt: isTerminal(os.Stdin)}
+	if term := newSttyTerminal(os.Stdin); term != nil {
+		input = &lineEditor{in: bufio.NewReader(os.Stdin), out: s.out, term: term,
+			history:  func() []string { return history },
+			complete: func(string) []string { return nil },
+		}
+	}
+	for {
+		line, err := input.ReadLine("you> ")
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+		history = append(history, line)
+		// An interrupt stops waiting for this answer only; a failed message is reported and
+		// the chat goes on
+		messageCtx, stop := signalContext()
+		err = s.send(messageCtx, line)
+		stop()
+		if err != nil {
+			fmt.Fprintf(s.out, "Error: %v\n", err)
+		}
+	}
+}
+
+// chatBackground describes the workspace and its latest tasks for the Captain
+func chatBackground(storage task.TaskStorage, workspace string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Workspace: %s\nCurrent time: %s\n", workspace, clock.Now().Format(time.RFC3339))
+	tasks, err := storage.ListTasks(task.TaskFilter{Workspace: workspace, Limit: 5})
+	if err != nil || len(tasks) == 0 {
+		b.WriteString("No tasks have been recorded in this workspace.\n")
+		return b.String()
+	}
+	b.WriteString("Latest tasks, newest first:\n")
+	for _, t := range tasks {
+		fmt.Fprintf(&b, "- %s\n", chatTaskLine(t))
+	}
+	return b.String()
+}
+
+// chatTaskLine summarizes a task on one line
+func chatTaskLine(t *task.TaskExecution) string {
+	return fmt.Sprintf("%s [%s] created %s: %s", t.ID, t.Status, t.CreatedAt.Format(time.RFC3339), truncate(t.Goal, 120))
+}
+
+// chatTools are the lookups in task storage the Captain may make while chatting
+func chatTools(storage task.TaskStorage, workspace string) []captain.ChatTool {
+	return []captain.ChatTool{
+		{
+			Name:        "list_tasks",
+			Input:       "optional status such as failed or running",
+			Description: "list the latest 20 tasks in the workspace, newest first",
+			Run: func(ctx context.Context, input string) (string, error) {
+				filter := task.TaskFilter{Workspace: workspace, Limit: 20}
+				if input != "" {
+					filter.Status = []task.TaskStatus{task.TaskStatus(input)}
+				}
+				tasks, err := storage.ListTasks(filter)
+				if err != nil {
+					return "", err
+				}
+				if len(tasks) == 0 {
+					return "No tasks found.", nil
+				}
+				lines := make([]string, len(tasks))
+				for i, t := range tasks {
+					lines[i] = chatTaskLine(t)
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		},
+		{
+			Name:        "show_task",
+			Input:       "task ID",
+			Description: "show a task's goal, status, plan steps and step results",
+			Run: func(ctx context.Context, input string) (string, error) {
+				record, err := storage.GetTask(input)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Goal: %s\n%s", record.Goal, describeTaskState(record)), nil
+			},
+		},
+		{
+			Name:        "task_logs",
+			Input:       "task ID, optionally followed by a step ID",
+			Description: fmt.Sprintf("show the latest %d log entries of a task or one of its steps, including agent messages and command output", maxChatLogEntries),
+			Run: func(ctx context.Context, input string) (string, error) {
+				id, step, _ := strings.Cut(input, " ")
+				record, err := storage.GetTask(id)
+				if err != nil {
+					return "", err
+				}
+				logs := (&TasksLogsCmd{Step: strings.TrimSpace(step)}).filter(record.Logs)
+				if len(logs) == 0 {
+					return "No log entries recorded.", nil
+				}
+				if len(logs) > maxChatLogEntries {
+					logs = logs[len(logs)-maxChatLogEntries:]
+				}
+				lines := make([]string, len(logs))
+				for i, entry := range logs {
+					lines[i] = formatLogEntry(entry)
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		},
+	}
+}
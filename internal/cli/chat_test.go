@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestChatCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	out, err := runCLI(t, "chat", "--list")
+	require.NoError(t, err)
+	assert.Equal(t, "No conversations saved.\n", out)
+
+	_, err = runCLI(t, "chat", "why did it fail?")
+	assert.EqualError(t, err, "OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to chat with the Captain")
+	_, err = runCLI(t, "chat", "--resume", "chat-00000000", "hello")
+	assert.EqualError(t, err, "conversation not found: chat-00000000")
+
+	store, err := openChatStore(config.NewConfig())
+	require.NoError(t, err)
+	chat := captain.NewChat("capn")
+	chat.Entries = []captain.ConversationEntry{
+		{Phase: captain.PhaseChat, Role: "user", Content: "why did step 3 fail?"},
+		{Phase: captain.PhaseTool, Role: "assistant", Content: `{"tool": "task_logs"}`},
+		{Phase: captain.PhaseChat, Role: "assistant", Content: "It timed out."},
+	}
+	require.NoError(t, store.Save(chat))
+
+	out, err = runCLI(t, "chat", "--list")
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^ID\s+UPDATED\s+MESSAGES\s+WORKSPACE\s+TITLE$`, out)
+	assert.Regexp(t, `(?m)^`+chat.ID+`\s+\S+ \S+\s+2\s+capn\s+why did step 3 fail\?$`, out)
+}
+
+func TestChatTools(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusFailed)
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+
+	tools := make(map[string]captain.ChatTool)
+	for _, tool := range chatTools(storage, "") {
+		tools[tool.Name] = tool
+	}
+	ctx := context.Background()
+
+	output, err := tools["list_tasks"].Run(ctx, "")
+	require.NoError(t, err)
+	assert.Contains(t, output, te.ID+" [failed]")
+	assert.Contains(t, output, "analyze code quality")
+	output, err = tools["list_tasks"].Run(ctx, "running")
+	require.NoError(t, err)
+	assert.Equal(t, "No tasks found.", output)
+
+	output, err = tools["show_task"].Run(ctx, te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Goal: analyze code quality")
+	assert.Contains(t, output, "- task-2 [reporting]: Write report")
+	_, err = tools["show_task"].Run(ctx, "task-missing")
+	assert.EqualError(t, err, "task not found: task-missing")
+
+	output, err = tools["task_logs"].Run(ctx, te.ID+" task-1")
+	require.NoError(t, err)
+	assert.Contains(t, output, "[task-1] analysis finished")
+	output, err = tools["task_logs"].Run(ctx, te.ID+" task-2")
+	require.NoError(t, err)
+	assert.Equal(t, "No log entries recorded.", output)
+
+	assert.Contains(t, chatBackground(storage, ""), "- "+te.ID+" [failed]")
+}
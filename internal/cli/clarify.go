@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// promptClarifier asks the Captain's clarifying questions about a goal on the terminal
+type promptClarifier struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newPromptClarifier creates a clarifier that reads answers from in
+func newPromptClarifier(in io.Reader, out io.Writer) *promptClarifier {
+	return &promptClarifier{in: bufio.NewReader(in), out: out}
+}
+
+// Clarify asks each question in turn; an empty answer keeps the Captain's assumption
+func (p *promptClarifier) Clarify(ctx context.Context, goal string, questions []captain.ClarifyingQuestion) ([]string, error) {
+	fmt.Fprintf(p.out, "The Captain has %d question(s) about this goal. Press Enter to accept the suggested assumption.\n", len(questions))
+	answers := make([]string, 0, len(questions))
+	for _, question := range questions {
+		fmt.Fprintf(p.out, "  %s [%s]: ", question.Question, question.Assumption)
+		line, err := p.in.ReadString('\n')
+		if err != nil && line == "" {
+			return answers, fmt.Errorf("failed to read answer: %w", err)
+		}
+		answers = append(answers, strings.TrimSpace(line))
+	}
+	return answers, nil
+}
+
+// clarifierFor returns a terminal prompt for clarifying questions, or nil when in is not a
+// terminal so the Captain plans on its assumptions instead
+func clarifierFor(in *os.File, out io.Writer) captain.Clarifier {
+	if !isTerminal(in) {
+		return nil
+	}
+	return newPromptClarifier(in, out)
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+var clarifyingQuestions = []captain.ClarifyingQuestion{
+	{Question: "Which environment?", Assumption: "staging"},
+	{Question: "Run migrations?", Assumption: "no"},
+}
+
+func TestPromptClarifier(t *testing.T) {
+	var out bytes.Buffer
+	answers, err := newPromptClarifier(strings.NewReader("production\n\n"), &out).Clarify(context.Background(), "deploy", clarifyingQuestions)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"production", ""}, answers)
+	assert.Contains(t, out.String(), "The Captain has 2 question(s) about this goal.")
+	assert.Contains(t, out.String(), "  Which environment? [staging]: ")
+	assert.Contains(t, out.String(), "  Run migrations? [no]: ")
+
+	answers, err = newPromptClarifier(strings.NewReader("production"), &bytes.Buffer{}).Clarify(context.Background(), "deploy", clarifyingQuestions)
+	assert.Error(t, err, "a closed input ends the questions")
+	assert.Equal(t, []string{"production"}, answers)
+}
+
+func TestClarifierFor(t *testing.T) {
+	pipe, err := os.CreateTemp(t.TempDir(), "stdin")
+	require.NoError(t, err)
+	defer pipe.Close()
+
+	assert.Nil(t, clarifierFor(pipe, &bytes.Buffer{}), "without a terminal the Captain plans on assumptions")
+}
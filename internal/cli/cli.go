@@ -5,149 +5,748 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 
 	"github.com/iainlowe/capn/internal/captain"
 	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/daemon"
+	"github.com/iainlowe/capn/internal/github"
+	"github.com/iainlowe/capn/internal/hooks"
+	"github.com/iainlowe/capn/internal/task"
 )
 
 // GlobalOptions holds all global command-line options
 type GlobalOptions struct {
 	Config   string        `help:"Configuration file path" short:"c"`
+	NoInterpolate bool     `name:"no-interpolate" help:"Read the configuration file as written, without expanding $${VAR} and $${VAR:-default} references"`
 	Verbose  bool          `help:"Verbose logging" short:"v"`
 	DryRun   bool          `help:"Plan without execution"`
 	Parallel int           `help:"Maximum parallel agents" short:"p" default:"5"`
 	Timeout  time.Duration `help:"Global timeout duration" default:"5m"`
+	Profile  string        `help:"Configuration profile to apply (overrides the config's default profile)" env:"CAPN_PROFILE"`
+	Workspace string       `help:"Workspace to record and list tasks in (default: the project directory)" env:"CAPN_WORKSPACE"`
+	Quiet     bool         `help:"Print only essential output, such as the task ID from execute, with no headers or notes" env:"CAPN_QUIET"`
+	OutputFormat string    `name:"output-format" help:"Format of listings and other command output: table, json or yaml (commands that only write text refuse json and yaml)" enum:"table,json,yaml" default:"table" env:"CAPN_OUTPUT_FORMAT"`
+	ReadOnly  bool         `name:"read-only" help:"Skip steps that would change files, remote services or the system, running only those that read" env:"CAPN_READ_ONLY"`
+	LogLevel  string       `name:"log-level" help:"Minimum level of diagnostic logs: debug, info, warn or error (default: info, debug with --verbose, warn with --quiet)" env:"CAPN_LOG_LEVEL" placeholder:"LEVEL"`
+	LogFormat string       `name:"log-format" help:"Encoding of diagnostic logs: console or json (default: json, console with --verbose)" env:"CAPN_LOG_FORMAT" placeholder:"FORMAT"`
+	LogFile   string       `name:"log-file" help:"Append diagnostic logs to this file instead of standard error" env:"CAPN_LOG_FILE" type:"path"`
 }
 
 // ExecuteCmd represents the execute command (with optional planning mode)
 type ExecuteCmd struct {
 	PlanOnly bool   `help:"Plan only, don't execute" short:"n" name:"plan-only"`
-	Goal     string `arg:"" help:"Goal to execute"`
+	ApproveAll bool `help:"Run high-risk and gated steps without asking for approval" name:"approve-all"`
+	NoClarify bool  `help:"Plan the goal as given, without clarifying questions or assumptions" name:"no-clarify"`
+	Priority int    `help:"Queue priority when captain.max_concurrent_tasks is reached; higher runs first"`
+	Batch    string `help:"Batch ID to group this task under in status views"`
+	Pipeline string   `help:"Pipeline ID to record this task as a stage of"`
+	After    []string `help:"Start only once this task has completed, failing if it does not (repeatable)" placeholder:"TASK-ID" sep:"none"`
+	AfterAny []string `name:"after-any" help:"Start only once this task has finished, whatever its status (repeatable)" placeholder:"TASK-ID" sep:"none"`
+	AfterSuccess []string `name:"after-success" help:"Start only once this task has completed with every step succeeded (repeatable)" placeholder:"TASK-ID" sep:"none"`
+	MaxRuntime time.Duration `name:"max-runtime" help:"Cancel the remaining steps and fail the task once its plan has run this long (default: the plan's max_runtime, then captain.max_task_runtime)" placeholder:"DURATION"`
+	Deadline time.Duration `help:"Plan to finish within this long; plans estimated to take longer are rejected" placeholder:"DURATION"`
+	MaxSteps int           `name:"max-steps" help:"Plan at most this many steps" placeholder:"N"`
+	Tools    []string      `help:"Agents the plan's steps may run on, comma-separated: file, network, research or a plugin type" placeholder:"AGENT"`
+	Lang     string        `help:"Language tag such as fr for the plan's reasoning and step descriptions, the report and notifications; auto follows the goal (default: captain.language)" placeholder:"TAG"`
+	Tags     []string `name:"tag" help:"Tag to label the task with (repeatable)" placeholder:"TAG" sep:"none"`
+	Template string   `help:"Stored goal template to execute, as name or name@version" placeholder:"NAME"`
+	Vars     []string `name:"var" help:"Template variable as key=value (repeatable)" placeholder:"KEY=VALUE" sep:"none"`
+	FromPlan string   `name:"from-plan" help:"Execute a YAML or JSON plan file instead of asking the Captain to plan" type:"existingfile" placeholder:"FILE"`
+	FromIssue string  `name:"from-issue" help:"Plan a GitHub issue, using its title as the goal and its body and comments as context" placeholder:"OWNER/REPO#N"`
+	CommentPlan bool  `name:"comment-plan" help:"Post a summary of the plan as a comment on the --from-issue issue"`
+	Simulate bool     `help:"Schedule the plan on mock agents with a fake clock and print the timeline, without running any step"`
+	LogSample int     `name:"log-sample" help:"Keep one in N debug lines of the task's log; warnings and errors are always kept (default: execution.logs.debug_sample)" placeholder:"N"`
+	Optimize bool     `help:"Merge duplicate steps and drop redundant dependencies before running the plan"`
+	OptimizeFor string `name:"strategy" help:"What --optimize optimizes for: cost (default), parallelism or safety" enum:",cost,parallelism,safety" default:""`
+	Attach   bool     `help:"Run the task in the background and show its log until it finishes, exiting with its status; Ctrl-C detaches"`
+	Force    bool     `help:"Start the goal even when a task of the workspace is already working on the same goal"`
+	TaskID   string   `name:"task-id" hidden:"" help:"Record the task under this ID, as the background process of --attach does"`
+	Goal     string   `arg:"" optional:"" help:"Goal to execute"`
 }
 
-func (e *ExecuteCmd) Run(globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
-	// Check if we're in planning mode (plan-only or global dry-run)
-	planningMode := e.PlanOnly || globals.DryRun
-	
-	// Check if OpenAI is configured (either in config or environment)
-	openaiAPIKey := config.OpenAI.APIKey
-	if envKey := os.Getenv("OPENAI_API_KEY"); envKey != "" {
-		openaiAPIKey = envKey
+// Help returns detailed help for the execute command
+func (e *ExecuteCmd) Help() string {
+	return `Ask the Captain to plan a goal and run it with the crew. Each run is recorded
+as a task that can be inspected with "capn tasks". With --dry-run, the plan is
+followed by a report of the files, network calls, packages and commands each
+step is expected to touch.
+
+Steps classified as high risk (deleting files, installing packages, writing to
+remote services or running destructive commands) ask for approval before they
+run. Use --approve-all to skip the prompt in unattended runs.
+
+When a goal is ambiguous, the Captain first asks up to
+captain.max_clarifying_questions questions on the terminal. Without a terminal
+it plans on its own assumptions and records them in the plan's reasoning. Use
+--no-clarify to plan the goal exactly as given.
+
+With --from-plan, a plan written by hand or exported with "capn plans export"
+is validated and executed as is; the goal is taken from the plan. Such plans
+run without an LLM configured.
+
+With --from-issue, the goal comes from a GitHub issue: its title becomes the
+goal and its body and comments are given to the Captain as context. The task
+is linked to the issue in its metadata, and --comment-plan posts the plan on
+the issue. The token is read from GITHUB_TOKEN or github.token.
+
+With --simulate, the plan is scheduled on deterministic mock agents with a fake
+clock instead of being run: the timeline shows when each step would start and
+finish, on which agent, and how many steps run at once under --parallel and the
+plan's strategy. Steps take their estimated_duration metadata or a share of the
+plan's estimate. No step runs, and a --from-plan plan makes no LLM calls.
+
+With --optimize, steps that repeat an earlier step's command after the same
+dependencies are merged into it, and dependencies already implied by another
+dependency are dropped. A summary of the changes is stored in the plan's
+metadata; with --verbose, the task counts, dependencies, commands and estimated
+and simulated durations of both plans are compared on screen. --strategy picks
+what to optimize for: cost (the default) as described, parallelism which also
+switches a sequential plan to the parallel strategy, or safety which never
+removes a validation step.
+
+Steps of a parallel or hybrid plan start as soon as their dependencies finish,
+up to --parallel at once (or the plan's max_agents, when lower); a sequential
+plan runs one step at a time.
+
+With --after, the task waits in the queue until another task has completed,
+and fails if that task fails or is cancelled. --after-any waits for the other
+task to finish whatever its status, and --after-success also requires every one
+of its steps to have succeeded rather than been skipped. Dependencies that form
+a cycle are refused, and "capn tasks show" lists what a task waits for.
+
+With --max-runtime, or a plan's timeline.max_runtime or the
+captain.max_task_runtime setting, a plan that runs too long has its remaining
+steps cancelled: the task fails as timed out, keeping the results of the steps
+that finished, and notifications and hooks report the failure.
+
+With --deadline, --max-steps and --tools, the Captain is told the limits the
+plan must fit: an estimated duration within the deadline, at most that many
+steps, and only steps that run on the listed agents. Plans breaking them are
+rejected like plans breaking captain.rules, including --from-plan plans. The
+deadline also caps the task's runtime unless --max-runtime or the plan's
+max_runtime is set.
+
+Steps that print nothing for longer than crew.watchdog.<agent>.stall_after, or
+crew.watchdog.default.stall_after for agents without their own entry, are
+flagged as stalled with a warning in the task's log. With decide: true, the
+Captain is shown the step's latest output and chooses to wait, to kill and
+retry the step, or to replan the rest of the goal from what has finished.
+
+A plan step with "service": true starts its command, such as a dev server, and
+leaves it running for the steps after it. The step succeeds once its
+health_check URL or command succeeds or, without one, once its address accepts
+connections, within its ready_timeout (30s by default). The address is
+published on the blackboard as <step>.address for later steps' inputs. Every
+service is stopped when the plan ends, however it ends.
+
+A step's command, description and path and an api_call's url, body and headers
+may use the results of the steps it depends on: ${steps.<id>.output},
+${steps.<id>.output.<field>} for a field of JSON output, ${steps.<id>.status},
+${steps.<id>.error} and ${steps.<id>.metadata.<key>}, and ${findings.<key>}
+for a finding on the blackboard, which an api_call may also write as {{key}}.
+They are filled in just before the step runs, so approvals, read-only mode and
+the workspace policy see the final command and URL. Values are quoted for the
+shell where they land in a command, so output cannot add commands of its own;
+${steps.<id>.output|raw} puts a value in as it is. A reference that cannot be filled in
+fails the step, or with captain.missing_variables: lenient is left empty.
+Other ${...} are left for the shell.
+
+The Captain writes the plan's reasoning and step descriptions, the clarifying
+questions and the outcome report in the language of the goal, keeping commands,
+paths and code as they are. --lang, or the captain.language setting, names the
+language to write in instead, as a tag such as fr or pt-BR. The language is
+recorded in the plan's metadata, so retries and reports of the task use it too,
+and it is shown with the plan.
+
+With --attach, the task runs in a separate background process while its log
+is shown as it is recorded, like "capn tasks logs --follow". The command exits
+when the task finishes: with status 0 if it completed, 1 if it failed and 2 if
+it was cancelled. Ctrl-C detaches, leaving the task running. The background
+process has no terminal, so high-risk steps need --approve-all and agents'
+questions are answered with "capn tasks answer".
+
+When a task of the workspace is already pending, queued or running with the
+same goal, ignoring case, spacing and a trailing full stop, the command offers
+to follow that task instead of starting a duplicate. With --attach the task is
+followed without asking, and without a terminal the command fails. Use --force
+to start another task anyway.
+
+Step output is written to the task's log in batches, as execution.logs sets.
+Lines starting with a debug marker, such as "DEBUG" or "level=debug", are
+logged at the debug level, and --log-sample N keeps only one in N of them.
+Warnings and errors are always kept; when the log cannot be saved as fast as
+output arrives, debug and info lines are dropped instead of slowing the step,
+and the task's log and metadata record how many were dropped or sampled out.
+
+With --quiet, only the task ID is printed on stdout, so scripts can capture it;
+approval prompts and agent questions go to stderr.
+
+Examples:
+
+    capn execute "analyze code quality in ./internal"
+    capn execute --plan-only "set up CI for this repository"
+    capn execute --pipeline deploy "run integration tests"
+    capn execute --tag infra --tag weekly "rotate staging credentials"
+    capn execute --template deploy --var env=staging
+    capn execute --approve-all "clean up stale build artifacts"
+    capn execute --no-clarify "deploy the service"
+    capn execute --from-plan plan.yaml
+    capn execute --from-issue iainlowe/capn#42 --comment-plan
+    capn execute --after task-1a2b3c4d "deploy"
+    capn execute --lang fr "nettoyer les anciens artefacts de build"
+    capn execute --deadline 10m --max-steps 8 --tools file,network "check the API"
+    capn execute --attach --approve-all "run the nightly data export"
+    capn execute --force "run the nightly data export"
+    capn execute --log-sample 100 --from-plan verbose-build.yaml
+    capn --parallel 3 execute --simulate --from-plan plan.yaml
+    capn --verbose execute --optimize --from-plan plan.yaml
+    capn execute --optimize --strategy safety "release the service"
+    id=$(capn --quiet execute --approve-all "rotate staging credentials")
+    capn --dry-run --parallel 3 execute "audit dependencies"`
+}
+
+func (e *ExecuteCmd) Run(ctx context.Context, stdout io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config, line commandLine) error {
+	// Load the plan from a file if one was given, taking the goal from it
+	var filePlan *captain.ExecutionPlan
+	if e.FromPlan != "" {
+		if e.Goal != "" || e.Template != "" {
+			return fmt.Errorf("cannot combine a goal or --template with --from-plan")
+		}
+		plan, err := loadPlanFile(e.FromPlan)
+		if err != nil {
+			return err
+		}
+		filePlan, e.Goal = plan, plan.Goal
+	}
+
+	// Fetch the issue to plan if one was given, taking the goal from it
+	var issue *github.Issue
+	var issues *github.Client
+	if e.FromIssue != "" {
+		if e.Goal != "" || e.Template != "" || e.FromPlan != "" {
+			return fmt.Errorf("cannot combine a goal, --template or --from-plan with --from-issue")
+		}
+		issues = githubClient(config)
+		fetched, err := fetchIssue(ctx, issues, e.FromIssue)
+		if err != nil {
+			return err
+		}
+		issue, e.Goal = fetched, issueGoal(fetched)
+	} else if e.CommentPlan {
+		return fmt.Errorf("--comment-plan requires --from-issue")
+	}
+
+	// Resolve the goal from a template if one was given
+	var templateRef string
+	if e.Template != "" {
+		if e.Goal != "" {
+			return fmt.Errorf("cannot combine a goal with --template")
+		}
+		goal, ref, err := resolveTemplateGoal(config, e.Template, e.Vars)
+		if err != nil {
+			return err
+		}
+		e.Goal, templateRef = goal, ref
+	} else if len(e.Vars) > 0 {
+		return fmt.Errorf("--var requires --template")
+	}
+	if e.OptimizeFor != "" && !e.Optimize {
+		return fmt.Errorf("--strategy requires --optimize")
+	}
+	tags, err := task.NormalizeTags(e.Tags)
+	if err != nil {
+		return err
+	}
+	constraints, err := e.constraints(config)
+	if err != nil {
+		return err
+	}
+	if err := e.applyLanguage(config); err != nil {
+		return err
+	}
+	workspace, err := currentWorkspace(globals)
+	if err != nil {
+		return err
+	}
+
+	// Check if we're in planning mode (plan-only, simulation or global dry-run)
+	planningMode := e.PlanOnly || e.Simulate || globals.DryRun
+	// The background process of --attach was started after the check
+	if !planningMode && !e.Force && e.TaskID == "" && llmConfigured(config) {
+		if followed, err := e.followDuplicate(ctx, stdout, globals, config, workspace); followed || err != nil {
+			return err
+		}
+	}
+	if e.Attach {
+		if planningMode {
+			return fmt.Errorf("--attach cannot be combined with --plan-only, --simulate or --dry-run")
+		}
+		if !llmConfigured(config) {
+			return fmt.Errorf("OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to execute plans")
+		}
+		return e.attach(ctx, stdout, globals, config, line)
+	}
+
+	// Quiet runs print only the task ID; prompts still reach the terminal on stderr
+	out, prompts := stdout, stdout
+	if globals.Quiet {
+		out, prompts = io.Discard, os.Stderr
 	}
 	
-	if openaiAPIKey == "" {
+	// Check if an LLM provider is configured (either in config or environment); plans from
+	// a file were made already and run without one
+	if !llmConfigured(config) && filePlan == nil {
 		if planningMode {
 			logger.Info("Creating basic plan (OpenAI not configured)", zap.String("goal", e.Goal))
-			fmt.Printf("Planning: %s\n", e.Goal)
-			fmt.Printf("Note: Set OPENAI_API_KEY environment variable or configure OpenAI in config file for LLM-powered planning.\n")
+			fmt.Fprintf(out, "Planning: %s\n", e.Goal)
+			fmt.Fprintf(out, "Note: Set OPENAI_API_KEY environment variable or configure OpenAI in config file for LLM-powered planning.\n")
 			return nil
 		} else {
 			logger.Info("Basic execution (OpenAI not configured)", zap.String("goal", e.Goal))
-			fmt.Printf("Executing: %s\n", e.Goal)
-			fmt.Printf("Note: Set OPENAI_API_KEY environment variable or configure OpenAI in config file for intelligent planning.\n")
+			fmt.Fprintf(out, "Executing: %s\n", e.Goal)
+			fmt.Fprintf(out, "Note: Set OPENAI_API_KEY environment variable or configure OpenAI in config file for intelligent planning.\n")
 			return nil
 		}
 	}
 
-	// Create OpenAI config with proper priority (env > config)
-	openaiConfig := captain.OpenAIConfig{
-		APIKey:      config.OpenAI.APIKey,
-		Model:       config.OpenAI.Model,
-		BaseURL:     config.OpenAI.BaseURL,
-		MaxRetries:  config.OpenAI.MaxRetries,
-		Temperature: config.OpenAI.Temperature,
+	if e.NoClarify || filePlan != nil {
+		config.Captain.MaxClarifyingQuestions = 0
 	}
-	
-	if envKey := os.Getenv("OPENAI_API_KEY"); envKey != "" {
-		openaiConfig.APIKey = envKey
+	var cap *captain.Captain
+	if llmConfigured(config) {
+		cap, err = newCaptain(config)
+	} else {
+		cap, err = newOfflineCaptain(config)
 	}
-
-	// Create Captain
-	cap, err := captain.NewCaptain("main-captain", config, openaiConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create captain: %w", err)
+		return err
 	}
 	defer cap.Stop()
+	cap.SetConstraints(constraints)
+	if clarifier := clarifierFor(os.Stdin, prompts); clarifier != nil {
+		cap.SetClarifier(clarifier)
+	}
 
-	// Create execution plan
-	logger.Info("Creating execution plan", zap.String("goal", e.Goal))
-	ctx := context.Background()
-	plan, err := cap.CreatePlan(ctx, e.Goal)
+	// Record the run in task storage so it shows up in status views and the dashboard
+	storage, err := openTaskStorage(config)
 	if err != nil {
-		return fmt.Errorf("failed to create plan: %w", err)
+		return err
+	}
+	record := task.NewTaskExecution(e.Goal)
+	if e.TaskID != "" {
+		record.ID = e.TaskID
+	}
+	record.BatchID = e.Batch
+	record.PipelineID = e.Pipeline
+	if templateRef != "" {
+		record.Metadata["template"] = templateRef
+	}
+	if e.LogSample > 0 {
+		record.Metadata[task.MetadataLogDebugSample] = strconv.Itoa(e.LogSample)
+	}
+	if issue != nil {
+		linkIssue(record, issue)
+	}
+	record.Priority = e.Priority
+	record.After = e.dependencies()
+	record.Tags = tags
+	record.Workspace = workspace
+	run := &taskRun{captain: cap, storage: storage, record: record, config: config, logger: logger, out: out, prompts: prompts}
+	if issue != nil {
+		run.background = issue.Background()
+	}
+	if globals.Quiet {
+		defer fmt.Fprintln(stdout, record.ID)
+	}
+
+	if admitted, err := run.admit(ctx); !admitted {
+		return err
+	}
+	if !planningMode {
+		defer run.notify(ctx)
+	}
+	var plan *captain.ExecutionPlan
+	if filePlan != nil {
+		plan, err = run.usePlan(filePlan, e.FromPlan)
+	} else {
+		plan, err = run.plan(ctx)
+	}
+	if err != nil || plan == nil {
+		return err
+	}
+	if e.Optimize {
+		if plan, err = run.optimize(plan, captain.OptimizationStrategy(e.OptimizeFor), globals); err != nil {
+			return err
+		}
+	}
+	if e.CommentPlan {
+		run.commentPlan(ctx, issues, issue, plan)
+	}
+	if e.MaxRuntime > 0 {
+		plan.Timeline.MaxRuntime = e.MaxRuntime
+	} else if e.Deadline > 0 && plan.Timeline.MaxRuntime == 0 {
+		plan.Timeline.MaxRuntime = e.Deadline
 	}
 
 	if planningMode {
 		logger.Info("Plan created successfully", zap.String("plan_id", plan.ID))
-		fmt.Printf("=== Execution Plan ===\n")
-		fmt.Printf("Goal: %s\n", plan.Goal)
-		fmt.Printf("Strategy: %s\n", plan.Strategy.Type)
-		fmt.Printf("Estimated Duration: %s\n", plan.Timeline.EstimatedDuration)
-		fmt.Printf("Tasks (%d):\n", len(plan.Tasks))
+		fmt.Fprintf(out, "=== Execution Plan ===\n")
+		fmt.Fprintf(out, "Goal: %s\n", plan.Goal)
+		fmt.Fprintf(out, "Strategy: %s\n", plan.Strategy.Type)
+		if plan.Strategy.Description != "" {
+			fmt.Fprintf(out, "Reasoning: %s\n", plan.Strategy.Description)
+		}
+		if language := plan.Metadata[captain.MetadataLanguage]; language != "" {
+			fmt.Fprintf(out, "Language: %s\n", captain.LanguageName(language))
+		}
+		if estimate, ok := historicalEstimate(storage, plan); ok {
+			fmt.Fprintf(out, "Estimated Duration: %s (from task history: %s)\n", plan.Timeline.EstimatedDuration, estimate)
+		} else {
+			fmt.Fprintf(out, "Estimated Duration: %s\n", plan.Timeline.EstimatedDuration)
+		}
+		fmt.Fprintf(out, "Tasks (%d):\n", len(plan.Tasks))
 		
 		for _, task := range plan.Tasks {
-			fmt.Printf("  [%s] %s (Priority: %s)\n", 
+			fmt.Fprintf(out, "  [%s] %s (Priority: %s)\n", 
 				task.Type, task.Payload["description"], task.Priority)
 			if len(task.Dependencies) > 0 {
-				fmt.Printf("     Dependencies: %v\n", task.Dependencies)
+				fmt.Fprintf(out, "     Dependencies: %v\n", task.Dependencies)
+			}
+			if task.Workdir != "" || task.Shell != "" || task.Execution != "" || len(task.Env) > 0 {
+				fmt.Fprintf(out, "     Environment: %s\n", describeTaskEnvironment(task))
+			}
+			if task.Condition != nil {
+				fmt.Fprintf(out, "     Runs if: output of %s matches %q\n", task.Condition.Step, task.Condition.Matches)
+			}
+			if task.Gate == captain.GateManual {
+				fmt.Fprintf(out, "     Gate: waits for manual approval\n")
+			}
+			if task.Service {
+				fmt.Fprintf(out, "     Service: %s\n", describeService(task))
+			}
+			if risk := captain.AssessRisk(task); risk.Level != captain.RiskLow {
+				fmt.Fprintf(out, "     Risk: %s (%s)\n", risk.Level, strings.Join(risk.Reasons, "; "))
 			}
 		}
-		fmt.Printf("\nNote: This is a dry run. Use without --plan-only or --dry-run to execute.\n")
-	} else {
-		logger.Info("Executing plan", zap.String("plan_id", plan.ID))
-		fmt.Printf("Executing plan: %s\n", plan.Goal)
-		
-		result, err := cap.ExecutePlan(ctx, plan, false)
-		if err != nil {
-			return fmt.Errorf("failed to execute plan: %w", err)
-		}
-
-		fmt.Printf("=== Execution Results ===\n")
-		fmt.Printf("Plan: %s\n", result.PlanID)
-		fmt.Printf("Success: %t\n", result.Success)
-		fmt.Printf("Duration: %s\n", result.Duration)
-		fmt.Printf("Tasks completed: %d\n", len(result.TaskResults))
-		
-		for _, taskResult := range result.TaskResults {
-			status := "✓"
-			if !taskResult.Success {
-				status = "✗"
+		if globals.DryRun {
+			report, err := cap.AnalyzeEffects(ctx, plan)
+			if err != nil {
+				failTask(storage, record, err, logger)
+				return fmt.Errorf("failed to analyze side effects: %w", err)
 			}
-			fmt.Printf("  %s Task %s: %s\n", status, taskResult.TaskID, taskResult.Output)
+			printEffectReport(out, plan, report)
+			record.AddLog(task.LogLevelInfo, effectSummary(report))
 		}
-		
-		if !result.Success {
-			fmt.Printf("Execution completed with errors. Check logs for details.\n")
+		if e.Simulate {
+			sim, err := captain.Simulate(plan, captain.SimulationOptions{MaxParallel: globals.Parallel})
+			if err != nil {
+				failTask(storage, record, err, logger)
+				return fmt.Errorf("failed to simulate plan: %w", err)
+			}
+			printSimulation(out, sim)
+			record.AddLog(task.LogLevelInfo, simulationSummary(sim))
+			fmt.Fprintf(out, "\nNote: This is a simulation; no step was run. Use without --simulate to execute.\n")
+		} else {
+			fmt.Fprintf(out, "\nNote: This is a dry run. Use without --plan-only or --dry-run to execute.\n")
 		}
+		record.SetStatus(task.TaskStatusCompleted)
+		saveTask(storage, record, logger)
+	} else {
+		return run.execute(ctx, e.ApproveAll)
 	}
 	
 	return nil
 }
 
-// StatusCmd represents the status command
-type StatusCmd struct{}
+// dependencies returns the tasks named by --after, --after-any and --after-success
+func (e *ExecuteCmd) dependencies() []task.Dependency {
+	var deps []task.Dependency
+	for _, flag := range []struct {
+		ids       []string
+		condition task.DependencyCondition
+	}{
+		{e.After, task.DependencyCompleted},
+		{e.AfterAny, task.DependencyFinished},
+		{e.AfterSuccess, task.DependencySucceeded},
+	} {
+		for _, id := range flag.ids {
+			deps = append(deps, task.Dependency{TaskID: id, Condition: flag.condition})
+		}
+	}
+	return deps
+}
+
+// llmConfigured reports whether an OpenAI key or an llm.providers chain is configured
+func llmConfigured(cfg *config.Config) bool {
+	return cfg.OpenAI.APIKey != "" || os.Getenv("OPENAI_API_KEY") != "" || len(cfg.LLM.Providers) > 0
+}
 
-func (s *StatusCmd) Run(globals *GlobalOptions, logger *zap.Logger) error {
-	logger.Info("Checking status")
-	// TODO: Implement status logic
-	return nil
+// openAIConfig returns the OpenAI settings for the captain, preferring OPENAI_API_KEY over the configured key
+func openAIConfig(cfg *config.Config) captain.OpenAIConfig {
+	openaiConfig := captain.OpenAIConfig{
+		APIKey:      cfg.OpenAI.APIKey,
+		Model:       cfg.OpenAI.Model,
+		BaseURL:     cfg.OpenAI.BaseURL,
+		MaxRetries:  cfg.OpenAI.MaxRetries,
+		Temperature: cfg.OpenAI.Temperature,
+		HTTP:        cfg.OpenAI.HTTP,
+	}
+	if envKey := os.Getenv("OPENAI_API_KEY"); envKey != "" {
+		openaiConfig.APIKey = envKey
+	}
+	return openaiConfig
 }
 
-// AgentsCmd represents the agents command
-type AgentsCmd struct{}
+// newCaptain creates the Captain for a run
+func newCaptain(cfg *config.Config) (*captain.Captain, error) {
+	policy, err := loadWorkspacePolicy()
+	if err != nil {
+		return nil, err
+	}
+	cap, err := captain.NewCaptain("main-captain", cfg, openAIConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create captain: %w", err)
+	}
+	return withPolicy(cap, cfg, policy), nil
+}
 
-func (a *AgentsCmd) Run(globals *GlobalOptions, logger *zap.Logger) error {
-	logger.Info("Managing agents")
-	fmt.Println("Managing agents")
-	// TODO: Implement agents management logic
+// newOfflineCaptain creates the Captain for running a plan made earlier without any LLM
+// configured
+func newOfflineCaptain(cfg *config.Config) (*captain.Captain, error) {
+	policy, err := loadWorkspacePolicy()
+	if err != nil {
+		return nil, err
+	}
+	cap, err := captain.NewOfflineCaptain("main-captain", cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create captain: %w", err)
+	}
+	return withPolicy(cap, cfg, policy), nil
+}
+
+// withPolicy applies the workspace policy to a new Captain and records its provider health
+func withPolicy(cap *captain.Captain, cfg *config.Config, policy *captain.Policy) *captain.Captain {
+	if policy != nil {
+		cap.SetPolicy(policy)
+	}
+	// Record circuit changes for "capn status"; health reporting is best effort
+	cap.SetProviderHealthHandler(func(health []captain.ProviderHealth) {
+		_ = captain.WriteProviderHealth(cfg.ProviderHealthFile(), health)
+	})
+	return cap
+}
+
+// taskRun plans and executes one recorded task
+type taskRun struct {
+	captain *captain.Captain
+	storage task.TaskStorage
+	record  *task.TaskExecution
+	config  *config.Config
+	logger  *zap.Logger
+	out     io.Writer
+	prompts io.Writer            // Optional; where approvals and questions are asked, out if unset
+	onStep  captain.StepObserver // Optional; reports each finished step
+	background string           // Optional; context given to the Captain when planning the goal
+}
+
+// admit waits for the tasks the task runs after and then for a free slot under
+// captain.max_concurrent_tasks, queueing the task if needed. It reports false when the task
+// did not get a slot; the error is nil if it was cancelled while queued.
+func (r *taskRun) admit(ctx context.Context) (bool, error) {
+	// Tasks orphaned by exited capn processes would hold their slots forever
+	recovered, err := task.RecoverTasks(r.storage)
+	if err != nil {
+		r.logger.Warn("Failed to recover interrupted tasks", zap.Error(err))
+	}
+	for _, t := range recovered {
+		r.logger.Warn("Task was interrupted", zap.String("task_id", t.ID), zap.String("error", t.Error))
+	}
+	applyRetention(r.config, r.storage, r.logger)
+
+	queue := task.NewQueue(r.storage, r.config.Captain.MaxConcurrentTasks)
+	err = queue.WaitForDependencies(ctx, r.record, func(pending []task.Dependency) {
+		fmt.Fprintf(r.out, "Task %s waiting until %s\n", r.record.ID, task.DescribeDependencies(pending))
+	})
+	if err == nil {
+		err = queue.Acquire(ctx, r.record, func(position int) {
+			fmt.Fprintf(r.out, "Task %s queued at position %d (%d tasks may run at once)\n",
+				r.record.ID, position, r.config.Captain.MaxConcurrentTasks)
+		})
+	}
+	if err == nil {
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		cancelTask(r.storage, r.record, "Interrupted while queued", r.logger)
+		fmt.Fprintf(r.out, "Interrupted while queued. Task %s was cancelled.\n", r.record.ID)
+		return false, nil
+	}
+	failTask(r.storage, r.record, err, r.logger)
+	return false, err
+}
+
+// plan asks the Captain for a plan for the task's goal and records it.
+// It returns a nil plan and no error when planning was interrupted and the task cancelled.
+func (r *taskRun) plan(ctx context.Context) (*captain.ExecutionPlan, error) {
+	r.record.SetStatus(task.TaskStatusPlanning)
+	saveTask(r.storage, r.record, r.logger)
+
+	r.logger.Info("Creating execution plan", zap.String("goal", r.record.Goal), zap.String("task_id", r.record.ID))
+	r.captain.SetPlannerObserver(func(event captain.PlannerEvent) {
+		r.record.PlannerEvents = append(r.record.PlannerEvents, event)
+	})
+	defer r.captain.SetPlannerObserver(nil)
+	plan, err := r.captain.CreatePlanWithBackground(ctx, r.record.Goal, r.background)
+	if err != nil {
+		if ctx.Err() != nil {
+			cancelTask(r.storage, r.record, "Interrupted while planning", r.logger)
+			fmt.Fprintf(r.out, "Interrupted while planning. Task %s was cancelled.\n", r.record.ID)
+			return nil, nil
+		}
+		reportRuleViolations(r.out, r.record, err)
+		failTask(r.storage, r.record, err, r.logger)
+		return nil, fmt.Errorf("failed to create plan: %w", err)
+	}
+	r.record.Plan = plan
+	r.record.AddLog(task.LogLevelInfo, fmt.Sprintf("Plan %s created with %d tasks", plan.ID, len(plan.Tasks)))
+	return plan, nil
+}
+
+// usePlan validates a plan loaded from a file and records it for the task in place of planning
+func (r *taskRun) usePlan(plan *captain.ExecutionPlan, source string) (*captain.ExecutionPlan, error) {
+	if err := r.captain.ValidatePlan(plan); err != nil {
+		reportRuleViolations(r.out, r.record, err)
+		failTask(r.storage, r.record, err, r.logger)
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	r.record.Plan = plan
+	r.record.Metadata["plan_file"] = source
+	r.record.AddLog(task.LogLevelInfo, fmt.Sprintf("Plan %s loaded from %s with %d tasks", plan.ID, source, len(plan.Tasks)))
+	return plan, nil
+}
+
+// execute runs the plan steps the task has not yet completed and records the outcome
+func (r *taskRun) execute(ctx context.Context, approveAll bool) error {
+	record, storage, logger := r.record, r.storage, r.logger
+	plan := record.PendingPlan()
+
+	logger.Info("Executing plan", zap.String("plan_id", plan.ID))
+	fmt.Fprintf(r.out, "Executing plan: %s\n", plan.Goal)
+	record.SetStatus(task.TaskStatusRunning)
+	saveTask(storage, record, logger)
+	r.hook(ctx, hooks.EventTaskStart, nil)
+	if r.config.Execution.ReadOnly {
+		fmt.Fprintf(r.out, "Read-only mode: steps that would change files, remote services or the system are skipped\n")
+		record.AddLog(task.LogLevelInfo, "Executing in read-only mode")
+	}
+
+	prompts := r.prompts
+	if prompts == nil {
+		prompts = r.out
+	}
+	r.captain.SetApprover(auditApprover(approverFor(approveAll, os.Stdin, prompts), record))
+	r.captain.SetQuestioner(&announcingQuestioner{next: task.NewQuestionChannel(storage, record), out: prompts, taskID: record.ID})
+	r.captain.SetBlackboard(newBlackboard(r.config, storage, record, logger))
+	output := streamStepOutput(r.captain, r.config, storage, record, logger)
+	reportFanOut(r.captain, output, r.out)
+	tracker := startGitTracking(ctx, r.config, record, logger)
+	eta := startETATracking(storage, record, plan, logger)
+	r.captain.SetStepObserver(func(step captain.Task, result *captain.Result) {
+		output.Flush()
+		if tracker != nil {
+			tracker.afterStep(step, result)
+		}
+		record.CheckpointStep(*result)
+		saveTask(storage, record, logger)
+		eta.afterStep(step, result)
+		r.hook(ctx, hooks.EventStepComplete, hookStep(step, result))
+		if r.onStep != nil {
+			r.onStep(step, result)
+		}
+	})
+	result, err := r.captain.ExecutePlan(ctx, plan, false)
+	output.Close()
+	if tracker != nil {
+		tracker.finish()
+	}
+	if err != nil {
+		failTask(storage, record, err, logger)
+		return fmt.Errorf("failed to execute plan: %w", err)
+	}
+	record.RecordExecution(result)
+	recordPolicyBlocks(record, result.TaskResults)
+	collectArtifacts(r.config, record, result.TaskResults, logger)
+	if !result.Interrupted {
+		r.report(ctx)
+	}
+
+	if result.Interrupted {
+		printInterruption(r.out, record.ID, record.Plan, record.Results)
+		saveTask(storage, record, logger)
+		return nil
+	}
+
+	fmt.Fprintf(r.out, "=== Execution Results ===\n")
+	fmt.Fprintf(r.out, "Plan: %s\n", result.PlanID)
+	fmt.Fprintf(r.out, "Success: %t\n", result.Success)
+	fmt.Fprintf(r.out, "Duration: %s\n", result.Duration)
+	fmt.Fprintf(r.out, "Tasks completed: %d\n", len(result.TaskResults))
+
+	for _, taskResult := range result.TaskResults {
+		status := "✓"
+		if !taskResult.Success {
+			status = "✗"
+		}
+		fmt.Fprintf(r.out, "  %s Task %s: %s\n", status, taskResult.TaskID, taskResult.Output)
+	}
+
+	if result.TimedOut {
+		fmt.Fprintf(r.out, "Execution stopped: %s; the remaining steps were cancelled.\n", result.Error)
+	} else if !result.Success {
+		fmt.Fprintf(r.out, "Execution completed with errors. Check logs for details.\n")
+	}
+	saveTask(storage, record, logger)
 	return nil
 }
 
+// openTaskStorage opens the file-backed task storage configured for this invocation
+func openTaskStorage(cfg *config.Config) (task.TaskStorage, error) {
+	storage, err := task.NewFileTaskStorage(cfg.TasksDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task storage: %w", err)
+	}
+	return storage, nil
+}
+
+// saveTask persists a task record, logging rather than failing the command on errors
+func saveTask(storage task.TaskStorage, record *task.TaskExecution, logger *zap.Logger) {
+	if err := storage.SaveTask(record); err != nil {
+		logger.Warn("Failed to save task", zap.String("task_id", record.ID), zap.Error(err))
+	}
+}
+
+// cancelTask marks a task record as cancelled with the given reason and persists it
+func cancelTask(storage task.TaskStorage, record *task.TaskExecution, reason string, logger *zap.Logger) {
+	record.Error = reason
+	record.AddLog(task.LogLevelWarn, reason)
+	record.SetStatus(task.TaskStatusCancelled)
+	saveTask(storage, record, logger)
+}
+
+// failTask marks a task record as failed with the given error and persists it
+func failTask(storage task.TaskStorage, record *task.TaskExecution, err error, logger *zap.Logger) {
+	record.Error = err.Error()
+	record.AddLog(task.LogLevelError, err.Error())
+	record.SetStatus(task.TaskStatusFailed)
+	saveTask(storage, record, logger)
+}
+
 // MCPCmd represents the mcp command
 type MCPCmd struct{}
 
@@ -158,23 +757,108 @@ func (m *MCPCmd) Run(globals *GlobalOptions, logger *zap.Logger) error {
 	return nil
 }
 
+// DaemonCmd represents the daemon command
+type DaemonCmd struct{}
+
+func (d *DaemonCmd) Run(ctx context.Context, globals *GlobalOptions, logger *zap.Logger, level zap.AtomicLevel, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+
+	dmn, err := daemon.New(config, logger, storage)
+	if err != nil {
+		return fmt.Errorf("failed to create daemon: %w", err)
+	}
+	if err := installCrewAgents(dmn.Manager(), config, logger); err != nil {
+		return err
+	}
+	dispatcher, err := newDispatcher(config, nil, logger)
+	if err != nil {
+		return err
+	}
+	if dispatcher != nil {
+		dmn.SetNotifications(dispatcher)
+	}
+	workspace, err := currentWorkspace(globals)
+	if err != nil {
+		return err
+	}
+	if llmConfigured(config) {
+		resumer := &taskResumer{ctx: ctx, config: config, storage: dmn.Storage(), logger: logger}
+		dmn.SetResumer(resumer.Resume)
+		// Resumed tasks are cancelled with the daemon; let them record it
+		defer resumer.Wait()
+	} else if config.Captain.ResumeInterrupted {
+		logger.Warn("Interrupted tasks are not resumed because OpenAI is not configured")
+	}
+	bot, runner, err := newSlackBot(ctx, config, dmn.Storage(), workspace, logger)
+	if err != nil {
+		return err
+	}
+	if bot != nil {
+		runner.admit = dmn.Admit
+		dmn.SetSlack(bot)
+		// Tasks submitted from Slack are cancelled with the daemon; let them record it
+		defer runner.Wait()
+	}
+	reloader := &daemonReloader{
+		daemon:      dmn,
+		level:       level,
+		pinnedLevel: globals.LogLevel != "" && globals.LogLevel != config.Global.LogLevel,
+		globals:     globals,
+		runner:      runner,
+		logger:      logger,
+	}
+	dmn.SetReloader(daemonConfigLoader(globals))
+	dmn.OnReload(reloader.Apply)
+	reloadOnSignal(ctx, dmn, logger)
+
+	logger.Info("Starting daemon")
+	return dmn.Run(ctx)
+}
+
 // CLI represents the main CLI structure
 type CLI struct {
 	GlobalOptions
 
-	Execute ExecuteCmd `cmd:"" help:"Plan and execute goals (use --dry-run for planning only)"`
-	Status  StatusCmd  `cmd:"" help:"Show current operation status"`
-	Agents  AgentsCmd  `cmd:"" help:"Manage agent configurations"`
-	MCP     MCPCmd     `cmd:"" help:"Manage MCP server connections"`
+	Execute       ExecuteCmd       `cmd:"" group:"tasks" help:"Plan and execute goals (use --dry-run for planning only)"`
+	Status        StatusCmd        `cmd:"" group:"tasks" help:"Show current operation status"`
+	Tasks         TasksCmd         `cmd:"" group:"tasks" help:"Inspect task history"`
+	Search        SearchCmd        `cmd:"" group:"tasks" help:"Search goals, plans, logs and agent messages across task history"`
+	Plans         PlansCmd         `cmd:"" group:"tasks" help:"Export, debug and lint plans"`
+	Templates     TemplatesCmd     `cmd:"" group:"tasks" help:"Manage reusable goal templates"`
+	Policy        PolicyCmd        `cmd:"" group:"tasks" help:"Check plans against the workspace execution policy"`
+	Shell         ShellCmd         `cmd:"" group:"tasks" help:"Start an interactive session for running goals and querying tasks"`
+	Chat          ChatCmd          `cmd:"" group:"tasks" help:"Talk with the Captain about the workspace and its recent tasks"`
+	Agents        AgentsCmd        `cmd:"" group:"agents" help:"List agent types and show the daemon's agent statistics"`
+	MCP           MCPCmd           `cmd:"" group:"agents" help:"Manage MCP server connections"`
+	Secrets       SecretsCmd       `cmd:"" group:"system" help:"Manage API keys and credentials"`
+	Daemon        DaemonCmd        `cmd:"" group:"system" help:"Run the long-lived daemon (serves the web dashboard when ui.enabled is set and Slack when integrations.slack is)"`
+	Ctl           CtlCmd           `cmd:"" group:"system" help:"Pause, drain or reload a running daemon over its control socket"`
+	Completion    CompletionCmd    `cmd:"" group:"system" help:"Generate shell completion scripts"`
+	Doctor        DoctorCmd        `cmd:"" group:"system" help:"Check configuration, LLM providers and storage for problems"`
+	Version       VersionCmd       `cmd:"" group:"system" help:"Show the capn version and build details, and optionally check for a newer release"`
+	Notifications NotificationsCmd `cmd:"" group:"system" help:"List notification channels and send pending digests"`
+	Complete      CompleteCmd      `cmd:"" name:"__complete" hidden:"" help:"Produce completion candidates for shell scripts"`
+	Bench         BenchCmd         `cmd:"" hidden:"" help:"Load-test task storage and the message router with synthetic tasks"`
 
 	output       io.Writer
 	logger       *zap.Logger
+	logLevel     zap.AtomicLevel
 	config       *config.Config
 	callback     func(*GlobalOptions)
 	exitOverride bool
 	skipConfig   bool // Skip config loading for tests
 }
 
+// commandGroups organizes commands in help output
+var commandGroups = []kong.Group{
+	{Key: "tasks", Title: "Task Commands:"},
+	{Key: "agents", Title: "Agent Commands:"},
+	{Key: "system", Title: "System Commands:"},
+}
+
 // NewCLI creates a new CLI instance
 func NewCLI() *CLI {
 	return &CLI{
@@ -205,8 +889,9 @@ func (c *CLI) SetSkipConfigForTests(skip bool) {
 
 // Parse runs the CLI with the given arguments
 func (c *CLI) Parse(args []string) error {
-	// Initialize logger first (needed for binding)
-	c.logger = c.createLogger()
+	// Cancel the root context on interrupt so commands can wind down cleanly
+	rootCtx, stop := signalContext()
+	defer stop()
 	
 	// Create parser with bindings for command methods
 	options := []kong.Option{
@@ -215,7 +900,10 @@ func (c *CLI) Parse(args []string) error {
 		kong.UsageOnError(),
 		kong.Writers(c.output, c.output),
 		kong.Bind(&c.GlobalOptions), // Bind global options
-		kong.Bind(c.logger),         // Bind logger
+		kong.BindTo(c.output, (*io.Writer)(nil)), // Bind command output
+		kong.BindTo(rootCtx, (*context.Context)(nil)), // Bind root context
+		kong.Bind(commandLine(args)), // Bind the arguments for commands that start capn again
+		kong.ExplicitGroups(commandGroups),
 	}
 	
 	// Add exit override for tests
@@ -230,22 +918,31 @@ func (c *CLI) Parse(args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := checkOutputFormat(ctx, args, c.OutputFormat); err != nil {
+		return err
+	}
 	
-	// Load configuration if specified
-	if c.Config != "" && !c.skipConfig {
-		c.config, err = config.LoadConfig(c.Config)
-		if err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+	// Let doctor start from defaults when the configuration is broken so it can diagnose it
+	if err := c.loadConfig(); err != nil {
+		if ctx.Command() != "doctor" {
+			return err
 		}
-		
-		// Merge config file values with command line options
-		c.mergeConfigWithOptions()
-	} else {
-		// Use default configuration
 		c.config = config.NewConfig()
 		c.mergeOptionsWithConfig()
 	}
 	
+	// Diagnostics go to standard error or the log file, keeping standard output for command output
+	if c.logger, err = c.createLogger(); err != nil {
+		return err
+	}
+	defer func() { _ = c.logger.Sync() }()
+	ctx.Bind(c.logger)
+	ctx.Bind(c.logLevel)
+	for _, section := range c.config.InsecureHTTP() {
+		c.logger.Warn("TLS certificate verification is disabled; anyone on the network path can read and alter this traffic, credentials included",
+			zap.String("section", section))
+	}
+
 	// Bind config for commands that need it
 	ctx.Bind(c.config)
 	
@@ -258,21 +955,47 @@ func (c *CLI) Parse(args []string) error {
 	return ctx.Run()
 }
 
-// createLogger creates a zap logger based on verbose setting
-func (c *CLI) createLogger() *zap.Logger {
-	var logger *zap.Logger
+// loadConfig loads the configuration file, applies the selected profile and resolves secrets
+func (c *CLI) loadConfig() error {
+	var err error
 	
-	if c.Verbose {
-		config := zap.NewDevelopmentConfig()
-		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		logger, _ = config.Build()
+	// Load configuration if specified
+	if c.Config != "" && !c.skipConfig {
+		c.config, err = config.LoadConfigWith(c.Config, config.LoadOptions{NoInterpolate: c.NoInterpolate})
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		
+		// Merge config file values with command line options
+		c.mergeConfigWithOptions()
 	} else {
-		config := zap.NewProductionConfig()
-		config.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-		logger, _ = config.Build()
+		// Use default configuration
+		c.config = config.NewConfig()
+		c.mergeOptionsWithConfig()
 	}
 	
-	return logger
+	// Apply the selected profile, falling back to the config's default profile
+	if profile := c.Profile; profile != "" || c.config.Global.Profile != "" {
+		if profile == "" {
+			profile = c.config.Global.Profile
+		}
+		if err := c.config.ApplyProfile(profile); err != nil {
+			return err
+		}
+		c.Profile = profile
+	}
+
+	// The flag turns read-only mode on; it cannot turn off a config that asks for it
+	if c.ReadOnly {
+		c.config.Execution.ReadOnly = true
+	}
+	c.ReadOnly = c.config.Execution.ReadOnly
+	
+	// Resolve credentials kept out of the config file
+	if err := resolveSecrets(c.config); err != nil {
+		return err
+	}
+	return nil
 }
 
 // mergeConfigWithOptions merges configuration file values with command line options
@@ -292,6 +1015,16 @@ func (c *CLI) mergeConfigWithOptions() {
 	if !c.wasSetExplicitly("timeout") {
 		c.Timeout = c.config.Global.Timeout
 	}
+	// Logging flags left empty fall back to the config file
+	if c.LogLevel == "" {
+		c.LogLevel = c.config.Global.LogLevel
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = c.config.Global.LogFormat
+	}
+	if c.LogFile == "" {
+		c.LogFile = c.config.Global.LogFile
+	}
 }
 
 // mergeOptionsWithConfig updates config with command line options
@@ -301,6 +1034,9 @@ func (c *CLI) mergeOptionsWithConfig() {
 	c.config.Global.Parallel = c.Parallel
 	c.config.Global.Timeout = c.Timeout
 	c.config.Global.Config = c.Config
+	c.config.Global.LogLevel = c.LogLevel
+	c.config.Global.LogFormat = c.LogFormat
+	c.config.Global.LogFile = c.LogFile
 }
 
 // wasSetExplicitly checks if an option was explicitly set on command line
@@ -8,8 +8,30 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
 )
 
+func TestMain(m *testing.M) {
+	// Keep task records written by commands out of the real home directory
+	home, err := os.MkdirTemp("", "capn-cli-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("CAPN_HOME", home)
+	// Never consult the developer's real keychain
+	os.Setenv("CAPN_SECRET_PROVIDERS", "env,file")
+	// Never snapshot the checkout the tests run in: git stops looking for a repository here
+	if wd, err := os.Getwd(); err == nil {
+		os.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(wd))
+	}
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 func TestCLI_HelpCommand(t *testing.T) {
 	var buf bytes.Buffer
 	cli := NewCLI()
@@ -34,6 +56,7 @@ func TestCLI_HelpCommand(t *testing.T) {
 	assert.Contains(t, output, "status")
 	assert.Contains(t, output, "agents")
 	assert.Contains(t, output, "mcp")
+	assert.Contains(t, output, "daemon")
 }
 
 func TestCLI_GlobalOptions(t *testing.T) {
@@ -115,6 +138,11 @@ func TestCLI_SubCommands(t *testing.T) {
 			args:        []string{"execute", "test goal"},
 			expectError: false,
 		},
+		{
+			name:        "execute command with tags",
+			args:        []string{"execute", "--plan-only", "--tag", "infra", "--tag", "weekly", "test goal"},
+			expectError: false,
+		},
 		{
 			name:        "status command",
 			args:        []string{"status"},
@@ -316,3 +344,118 @@ func TestCLI_ExecuteCommand_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestCLI_ExecuteCommand_RecordsTask(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-env-key")
+
+	var buf bytes.Buffer
+	cli := NewCLI()
+	cli.SetOutput(&buf)
+
+	// Planning fails without real API access, which should be recorded on the task
+	err := cli.Parse([]string{"execute", "--plan-only", "record me"})
+	require.Error(t, err)
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "record me", tasks[0].Goal)
+	assert.Equal(t, task.TaskStatusFailed, tasks[0].Status)
+	assert.NotEmpty(t, tasks[0].Error)
+}
+
+func TestCLI_Profile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+global:
+  profile: thorough
+openai:
+  model: gpt-4
+profiles:
+  fast:
+    captain:
+      max_concurrent_agents: 3
+    openai:
+      model: gpt-4o-mini
+  thorough:
+    captain:
+      max_concurrent_agents: 10
+`
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0644))
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantErr   string
+		profile   string
+		model     string
+		maxAgents int
+	}{
+		{
+			name:      "explicit profile",
+			args:      []string{"--config", configFile, "--profile", "fast", "status"},
+			profile:   "fast",
+			model:     "gpt-4o-mini",
+			maxAgents: 3,
+		},
+		{
+			name:      "default profile from config",
+			args:      []string{"--config", configFile, "status"},
+			profile:   "thorough",
+			model:     "gpt-4",
+			maxAgents: 10,
+		},
+		{
+			name:    "unknown profile",
+			args:    []string{"--config", configFile, "--profile", "turbo", "status"},
+			wantErr: `unknown profile "turbo"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			cli := NewCLI()
+			cli.SetOutput(&buf)
+
+			err := cli.Parse(tt.args)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.profile, cli.Profile)
+			assert.Equal(t, tt.model, cli.config.OpenAI.Model)
+			assert.Equal(t, tt.maxAgents, cli.config.Captain.MaxConcurrentAgents)
+		})
+	}
+}
+
+func TestCLI_ReadOnly(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("execution:\n  read_only: true\n"), 0644))
+
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"off by default", []string{"status"}, false},
+		{"flag", []string{"--read-only", "status"}, true},
+		{"config file", []string{"--config", configFile, "status"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := NewCLI()
+			cli.SetOutput(&bytes.Buffer{})
+			require.NoError(t, cli.Parse(tt.args))
+			assert.Equal(t, tt.want, cli.ReadOnly)
+			assert.Equal(t, tt.want, cli.config.Execution.ReadOnly)
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// Argument and flag names that trigger dynamic completion from storage or config
+const (
+	completeTaskID   = "task-id"
+	completeAgentID  = "agent-id"
+	completeTemplate = "template"
+	completeProfile  = "profile"
+)
+
+const bashCompletion = `# bash completion for capn
+_capn_complete() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(capn __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" -- "$cur"))
+}
+complete -F _capn_complete capn
+`
+
+const zshCompletion = `#compdef capn
+# zsh completion for capn
+_capn() {
+    local -a candidates
+    candidates=("${(@f)$(capn __complete -- "${words[@]:1:$((CURRENT-1))}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _capn capn
+`
+
+const fishCompletion = `# fish completion for capn
+function __capn_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    capn __complete -- $tokens[2..-1] 2>/dev/null
+end
+complete -c capn -f -a '(__capn_complete)'
+`
+
+// CompletionCmd represents the completion command
+type CompletionCmd struct {
+	Shell string `arg:"" enum:"bash,zsh,fish" help:"Shell to generate completions for (bash, zsh, fish)"`
+}
+
+// Help returns detailed help for the completion command
+func (c *CompletionCmd) Help() string {
+	return `Generate a shell completion script. Completions cover commands, flags and
+enum values, and complete task and agent IDs from task storage and
+template names from the template store.
+
+Examples:
+
+    # bash (add to ~/.bashrc)
+    source <(capn completion bash)
+
+    # zsh (add to ~/.zshrc)
+    source <(capn completion zsh)
+
+    # fish
+    capn completion fish > ~/.config/fish/completions/capn.fish`
+}
+
+func (c *CompletionCmd) Run(out io.Writer) error {
+	scripts := map[string]string{
+		"bash": bashCompletion,
+		"zsh":  zshCompletion,
+		"fish": fishCompletion,
+	}
+
+	script, ok := scripts[c.Shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell: %s", c.Shell)
+	}
+	_, err := io.WriteString(out, script)
+	return err
+}
+
+// CompleteCmd is the hidden command invoked by completion scripts to produce candidates
+type CompleteCmd struct {
+	Words []string `arg:"" optional:"" passthrough:"" help:"Command line words, the last one being completed"`
+}
+
+func (c *CompleteCmd) Run(kctx *kong.Context, out io.Writer, logger *zap.Logger, config *config.Config) error {
+	words := c.Words
+	if len(words) > 0 && words[0] == "--" {
+		words = words[1:]
+	}
+
+	completer := &completer{model: kctx.Model.Node, config: config, logger: logger}
+	for _, candidate := range completer.Complete(words) {
+		fmt.Fprintln(out, candidate)
+	}
+	return nil
+}
+
+// completer computes completion candidates by walking the kong model
+type completer struct {
+	model  *kong.Node
+	config *config.Config
+	logger *zap.Logger
+}
+
+// Complete returns the candidates for the last word given the preceding words
+func (c *completer) Complete(words []string) []string {
+	current := ""
+	if len(words) > 0 {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	node := c.model
+	positional := 0
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if strings.HasPrefix(word, "-") {
+			// Skip the value of flags that take one, unless given inline with "="
+			if flag := findFlag(node, word); flag != nil && !flag.IsBool() && !strings.Contains(word, "=") {
+				i++
+			}
+			continue
+		}
+		if child := findChild(node, word); child != nil {
+			node = child
+			positional = 0
+			continue
+		}
+		positional++
+	}
+
+	// Complete a flag value if the previous word is a flag expecting one
+	if len(words) > 0 {
+		if flag := findFlag(node, words[len(words)-1]); flag != nil && !flag.IsBool() && !strings.Contains(words[len(words)-1], "=") {
+			return filterPrefix(c.valueCandidates(flag.Value), current)
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		return filterPrefix(flagCandidates(node), current)
+	}
+
+	var candidates []string
+	for _, child := range node.Children {
+		if !child.Hidden {
+			candidates = append(candidates, child.Name)
+			candidates = append(candidates, child.Aliases...)
+		}
+	}
+	if positional < len(node.Positional) {
+		candidates = append(candidates, c.valueCandidates(node.Positional[positional])...)
+	}
+	return filterPrefix(candidates, current)
+}
+
+// valueCandidates returns candidates for a flag or positional value
+func (c *completer) valueCandidates(value *kong.Value) []string {
+	if value.Enum != "" {
+		var candidates []string
+		for _, v := range strings.Split(value.Enum, ",") {
+			candidates = append(candidates, strings.TrimSpace(v))
+		}
+		return candidates
+	}
+
+	switch value.Name {
+	case completeTaskID:
+		return c.taskIDs()
+	case completeAgentID:
+		return c.agentIDs()
+	case completeTemplate:
+		return c.templateNames()
+	case completeProfile:
+		if c.config != nil {
+			return c.config.ProfileNames()
+		}
+	}
+	return nil
+}
+
+// storedTasks loads tasks from storage, returning nothing if storage is unavailable
+func (c *completer) storedTasks() []*task.TaskExecution {
+	if c.config == nil {
+		return nil
+	}
+	storage, err := openTaskStorage(c.config)
+	if err != nil {
+		return nil
+	}
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	if err != nil {
+		c.logger.Debug("Task completion unavailable", zap.Error(err))
+		return nil
+	}
+	return tasks
+}
+
+// taskIDs returns the IDs of stored tasks, newest first
+func (c *completer) taskIDs() []string {
+	var ids []string
+	for _, t := range c.storedTasks() {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+// agentIDs returns the IDs of agents that worked on stored tasks
+func (c *completer) agentIDs() []string {
+	seen := make(map[string]bool)
+	for _, t := range c.storedTasks() {
+		for _, result := range t.Results {
+			if id, ok := result.Metadata["agent_id"].(string); ok && id != "" {
+				seen[id] = true
+			}
+		}
+		for _, entry := range t.Logs {
+			if entry.Agent != "" {
+				seen[entry.Agent] = true
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// templateNames returns the names of stored templates
+func (c *completer) templateNames() []string {
+	if c.config == nil {
+		return nil
+	}
+	store, err := openTemplateStore(c.config)
+	if err != nil {
+		return nil
+	}
+	list, err := store.List()
+	if err != nil {
+		c.logger.Debug("Template completion unavailable", zap.Error(err))
+		return nil
+	}
+
+	names := make([]string, 0, len(list))
+	for _, t := range list {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// findChild returns the visible child command matching a name or alias
+func findChild(node *kong.Node, name string) *kong.Node {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+		for _, alias := range child.Aliases {
+			if alias == name {
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// findFlag returns the flag of node (or its ancestors) matching a command line word
+func findFlag(node *kong.Node, word string) *kong.Flag {
+	name := strings.SplitN(word, "=", 2)[0]
+	for n := node; n != nil; n = n.Parent {
+		for _, flag := range n.Flags {
+			if name == "--"+flag.Name || (flag.Short != 0 && name == "-"+string(flag.Short)) {
+				return flag
+			}
+		}
+	}
+	return nil
+}
+
+// flagCandidates returns the long flags available at a node, including inherited ones
+func flagCandidates(node *kong.Node) []string {
+	candidates := []string{"--help"}
+	for n := node; n != nil; n = n.Parent {
+		for _, flag := range n.Flags {
+			if !flag.Hidden && flag.Name != "help" {
+				candidates = append(candidates, "--"+flag.Name)
+			}
+		}
+	}
+	return candidates
+}
+
+// filterPrefix keeps the candidates starting with prefix
+func filterPrefix(candidates []string, prefix string) []string {
+	var out []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			out = append(out, candidate)
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	cli := NewCLI()
+	cli.SetOutput(&buf)
+	err := cli.Parse(args)
+	return buf.String(), err
+}
+
+func seedTask(t *testing.T, status task.TaskStatus) *task.TaskExecution {
+	t.Helper()
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := task.NewTaskExecution("analyze code quality")
+	te.Plan = &captain.ExecutionPlan{
+		ID:   "plan-1",
+		Goal: te.Goal,
+		Tasks: []captain.Task{
+			{ID: "task-1", Type: captain.TaskTypeAnalysis, Payload: map[string]any{"description": "Run analysis"}},
+			{ID: "task-2", Type: captain.TaskTypeReporting, Dependencies: []string{"task-1"}, Payload: map[string]any{"description": "Write report"}},
+		},
+	}
+	te.Results = []captain.Result{{TaskID: "task-1", Success: true, Metadata: map[string]any{"agent_id": "research-001"}}}
+	te.AddStepLog(task.LogLevelInfo, "task-1", "research-001", "analysis finished")
+	te.SetStatus(status)
+	require.NoError(t, storage.SaveTask(te))
+	return te
+}
+
+func completeLines(t *testing.T, words ...string) []string {
+	t.Helper()
+	out, err := runCLI(t, append([]string{"__complete", "--"}, words...)...)
+	require.NoError(t, err)
+	return strings.Fields(out)
+}
+
+func TestCompletionCmd_Scripts(t *testing.T) {
+	tests := []struct {
+		shell  string
+		marker string
+	}{
+		{"bash", "complete -F _capn_complete capn"},
+		{"zsh", "#compdef capn"},
+		{"fish", "complete -c capn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			out, err := runCLI(t, "completion", tt.shell)
+			require.NoError(t, err)
+			assert.Contains(t, out, tt.marker)
+			assert.Contains(t, out, "capn __complete")
+		})
+	}
+
+	_, err := runCLI(t, "completion", "powershell")
+	assert.Error(t, err)
+}
+
+func TestCompleteCmd_Commands(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	assert.Equal(t, []string{"tasks"}, completeLines(t, "ta"))
+	assert.Equal(t, []string{"list", "show", "logs", "artifacts", "retry", "bump", "tag", "answer", "transcript", "export", "import", "rollback", "prune"}, completeLines(t, "tasks", ""))
+	assert.Equal(t, []string{"bash", "zsh", "fish"}, completeLines(t, "completion", ""))
+	assert.NotContains(t, completeLines(t, ""), "__complete", "hidden commands must not be offered")
+}
+
+func TestCompleteCmd_Flags(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	assert.Equal(t, []string{"--status"}, completeLines(t, "tasks", "list", "--st"))
+	assert.Contains(t, completeLines(t, "tasks", "list", "--"), "--verbose", "global flags are inherited")
+	assert.Contains(t, completeLines(t, "tasks", "list", "--status", ""), "failed")
+}
+
+func TestCompleteCmd_DynamicIDs(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	assert.Equal(t, []string{te.ID}, completeLines(t, "tasks", "show", ""))
+	assert.Empty(t, completeLines(t, "tasks", "show", "nope"))
+
+	c := &completer{config: config.NewConfig()}
+	assert.Equal(t, []string{"research-001"}, c.agentIDs())
+}
+
+func TestCLI_HelpGroupsAndExamples(t *testing.T) {
+	out, _ := runCLI(t, "--help")
+	assert.Contains(t, out, "Task Commands:")
+	assert.Contains(t, out, "Agent Commands:")
+	assert.Contains(t, out, "System Commands:")
+	assert.NotContains(t, out, "__complete")
+
+	out, _ = runCLI(t, "execute", "--help")
+	assert.Contains(t, out, "Examples:")
+	assert.Contains(t, out, "capn execute --plan-only")
+}
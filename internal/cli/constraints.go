@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// constraints returns the plan constraints given by --deadline, --max-steps and --tools. Tools
+// must be crew agent types or the types of configured plugins.
+func (e *ExecuteCmd) constraints(cfg *config.Config) (captain.PlanConstraints, error) {
+	if e.Deadline < 0 {
+		return captain.PlanConstraints{}, fmt.Errorf("--deadline must not be negative")
+	}
+	if e.MaxSteps < 0 {
+		return captain.PlanConstraints{}, fmt.Errorf("--max-steps must not be negative")
+	}
+	known := []string{string(agents.AgentTypeFile), string(agents.AgentTypeNetwork), string(agents.AgentTypeResearch)}
+	for _, plugin := range cfg.Agents.Plugins {
+		known = append(known, plugin.Type)
+	}
+
+	constraints := captain.PlanConstraints{Deadline: e.Deadline, MaxSteps: e.MaxSteps}
+	for _, tool := range e.Tools {
+		tool = strings.TrimSpace(tool)
+		if tool == "" {
+			continue
+		}
+		if !slices.Contains(known, tool) {
+			return captain.PlanConstraints{}, fmt.Errorf("unknown tool %q for --tools; use one of %s", tool, strings.Join(known, ", "))
+		}
+		if !slices.Contains(constraints.Tools, agents.AgentType(tool)) {
+			constraints.Tools = append(constraints.Tools, agents.AgentType(tool))
+		}
+	}
+	return constraints, nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteCmd_Constraints(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(optimizablePlan), 0o644))
+
+	out, err := runCLI(t, "execute", "--simulate", "--deadline", "10m", "--max-steps", "3", "--tools", "file,network", "--from-plan", path)
+	require.Error(t, err)
+	assert.Contains(t, out, "[max_tasks] plan has 4 tasks, limit is 3")
+	assert.Contains(t, out, "[max_duration] plan is estimated to take 30m0s, limit is 10m0s")
+
+	out, err = runCLI(t, "execute", "--simulate", "--deadline", "1h", "--max-steps", "4", "--tools", "file", "--from-plan", path)
+	require.NoError(t, err, out)
+
+	_, err = runCLI(t, "execute", "--simulate", "--tools", "file,shell", "--from-plan", path)
+	assert.EqualError(t, err, `unknown tool "shell" for --tools; use one of file, network, research`)
+	_, err = runCLI(t, "execute", "--simulate", "--max-steps=-1", "--from-plan", path)
+	assert.EqualError(t, err, "--max-steps must not be negative")
+}
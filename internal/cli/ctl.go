@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/control"
+	"github.com/iainlowe/capn/internal/daemon"
+)
+
+// ctlTimeout bounds control requests other than drain, which waits for agents
+const ctlTimeout = 10 * time.Second
+
+// CtlCmd groups the commands that control a running daemon over its control socket
+type CtlCmd struct {
+	Status CtlStatusCmd `cmd:"" help:"Show whether the running daemon is paused, with its agent counts"`
+	Pause  CtlPauseCmd  `cmd:"" help:"Stop the daemon filling warm pools and starting new tasks"`
+	Resume CtlResumeCmd `cmd:"" help:"Let a paused daemon fill warm pools and start tasks again"`
+	Drain  CtlDrainCmd  `cmd:"" help:"Pause the daemon and terminate its agents once they finish their current task"`
+	Reload CtlReloadCmd `cmd:"" help:"Load the configuration again and apply the changes that are safe while the daemon runs"`
+}
+
+// CtlStatusCmd represents the ctl status command
+type CtlStatusCmd struct{}
+
+// Help returns detailed help for the ctl status command
+func (s *CtlStatusCmd) Help() string {
+	return `Show when the running daemon started, whether it is paused, the addresses of
+its dashboard and Slack integration, and its agent counts.
+
+The capn ctl commands talk to the daemon over a unix socket, daemon.sock in
+the capn data directory. Each request is one line of JSON naming a verb, and
+each reply one line holding "ok" and either "result" or "error", so scripts
+can use the socket directly:
+
+    echo '{"verb": "pause"}' | socat - UNIX-CONNECT:$HOME/.capn/daemon.sock
+
+The verbs are status, pause, resume, drain (with an optional "timeout"
+argument such as {"timeout": "2m"}), reload, and verbs, which lists them.
+
+Examples:
+
+    capn ctl status
+    capn --output-format json ctl status | jq .agents.busy`
+}
+
+func (s *CtlStatusCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	var status daemon.Status
+	if err := callDaemon(ctx, config, "status", nil, &status, ctlTimeout); err != nil {
+		return err
+	}
+	return newPresenter(out, globals).Show(status, func(w io.Writer) error {
+		state := "running"
+		if status.Paused {
+			state = "paused"
+		}
+		fmt.Fprintf(w, "State:     %s\n", state)
+		fmt.Fprintf(w, "Started:   %s (%s ago)\n", status.StartedAt.Format(time.RFC3339), clock.Since(status.StartedAt).Round(time.Second))
+		if status.UIAddr != "" {
+			fmt.Fprintf(w, "Dashboard: http://%s\n", status.UIAddr)
+		}
+		if status.SlackAddr != "" {
+			fmt.Fprintf(w, "Slack:     http://%s\n", status.SlackAddr)
+		}
+		stats := status.Agents
+		fmt.Fprintf(w, "Agents:    %d (%d idle, %d busy, %d stopped, %d error)\n",
+			stats.Total, stats.Idle, stats.Busy, stats.Stopped, stats.Error)
+		return nil
+	})
+}
+
+// CtlPauseCmd represents the ctl pause command
+type CtlPauseCmd struct{}
+
+// Help returns detailed help for the ctl pause command
+func (p *CtlPauseCmd) Help() string {
+	return `Pause the running daemon: it stops filling warm pools and collecting idle
+agents, and turns away goals submitted from Slack. Tasks already running
+carry on. Resume it with "capn ctl resume".
+
+Examples:
+
+    capn ctl pause`
+}
+
+func (p *CtlPauseCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	var status daemon.Status
+	if err := callDaemon(ctx, config, "pause", nil, &status, ctlTimeout); err != nil {
+		return err
+	}
+	return newPresenter(out, globals).Show(status, func(w io.Writer) error {
+		fmt.Fprintln(w, "Daemon paused")
+		return nil
+	})
+}
+
+// CtlResumeCmd represents the ctl resume command
+type CtlResumeCmd struct{}
+
+func (r *CtlResumeCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	var status daemon.Status
+	if err := callDaemon(ctx, config, "resume", nil, &status, ctlTimeout); err != nil {
+		return err
+	}
+	return newPresenter(out, globals).Show(status, func(w io.Writer) error {
+		fmt.Fprintln(w, "Daemon resumed")
+		return nil
+	})
+}
+
+// CtlDrainCmd represents the ctl drain command
+type CtlDrainCmd struct {
+	Wait time.Duration `help:"How long to wait for busy agents before interrupting them" default:"1m"`
+}
+
+// Help returns detailed help for the ctl drain command
+func (d *CtlDrainCmd) Help() string {
+	return `Pause the running daemon, wait for busy agents to finish their current task,
+then terminate every agent, for example before upgrading plugins. Agents still
+busy after --wait are interrupted. The daemon stays paused until
+"capn ctl resume".
+
+Examples:
+
+    capn ctl drain
+    capn ctl drain --wait 10m && capn ctl resume`
+}
+
+func (d *CtlDrainCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	if d.Wait <= 0 {
+		return fmt.Errorf("--wait must be positive")
+	}
+	var result daemon.DrainResult
+	args := map[string]string{"timeout": d.Wait.String()}
+	// The reply comes once the agents have drained, so allow for the whole wait
+	if err := callDaemon(ctx, config, "drain", args, &result, d.Wait+ctlTimeout); err != nil {
+		return err
+	}
+	return newPresenter(out, globals).Show(result, func(w io.Writer) error {
+		fmt.Fprintf(w, "Drained %d agents; the daemon is paused\n", len(result.Terminated))
+		if len(result.Interrupted) > 0 {
+			fmt.Fprintf(w, "Interrupted busy agents: %s\n", strings.Join(result.Interrupted, ", "))
+		}
+		return nil
+	})
+}
+
+// CtlReloadCmd represents the ctl reload command
+type CtlReloadCmd struct{}
+
+// Help returns detailed help for the ctl reload command
+func (r *CtlReloadCmd) Help() string {
+	return `Have the running daemon load its configuration file again, with the profile
+it was started with, and apply the changes that are safe while it runs,
+as sending it SIGHUP does. The log level, agents.health, agents.lifecycle
+and notification channels change at once; captain, llm, crew, execution,
+hooks and the other settings read when a task starts apply to tasks started
+afterwards. Changes to storage.path, ui, integrations, transport, plugins,
+crew brains and log output need a restart: they are reported and left as
+they were. A configuration that fails to load leaves the daemon unchanged.
+
+Examples:
+
+    capn ctl reload
+    kill -HUP $(pgrep -f "capn daemon")`
+}
+
+func (r *CtlReloadCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	var report daemon.ReloadReport
+	if err := callDaemon(ctx, config, "reload", nil, &report, ctlTimeout); err != nil {
+		return err
+	}
+	return newPresenter(out, globals).Show(report, func(w io.Writer) error {
+		_, err := io.WriteString(w, formatReloadReport(report))
+		return err
+	})
+}
+
+// callDaemon sends a request to the running daemon's control socket
+func callDaemon(ctx context.Context, config *config.Config, verb string, args map[string]string, result any, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := control.Call(ctx, config.ControlSocket(), verb, args, result)
+	if errors.Is(err, control.ErrNotRunning) {
+		return fmt.Errorf("%w; start it with \"capn daemon\"", err)
+	}
+	return err
+}
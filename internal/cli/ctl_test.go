@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/daemon"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// startDaemon runs a daemon answering on the control socket in a temporary CAPN_HOME
+func startDaemon(t *testing.T) *daemon.Daemon {
+	t.Helper()
+	t.Setenv("CAPN_HOME", t.TempDir())
+	dmn, err := daemon.New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, dmn.Start())
+	t.Cleanup(func() { _ = dmn.Stop() })
+	return dmn
+}
+
+func TestCtlCmd(t *testing.T) {
+	dmn := startDaemon(t)
+
+	out, err := runCLI(t, "ctl", "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "State:     running\n")
+	assert.Contains(t, out, "Agents:    0 (0 idle, 0 busy, 0 stopped, 0 error)\n")
+
+	out, err = runCLI(t, "ctl", "pause")
+	require.NoError(t, err)
+	assert.Equal(t, "Daemon paused\n", out)
+	assert.True(t, dmn.Paused())
+
+	out, err = runCLI(t, "--output-format", "json", "ctl", "status")
+	require.NoError(t, err)
+	var status daemon.Status
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	assert.True(t, status.Paused)
+
+	out, err = runCLI(t, "ctl", "resume")
+	require.NoError(t, err)
+	assert.Equal(t, "Daemon resumed\n", out)
+	assert.False(t, dmn.Paused())
+
+	busy, err := dmn.Manager().SpawnAgent("file-1", "FileAgent", agents.AgentTypeFile)
+	require.NoError(t, err)
+	busy.(*agents.BaseAgent).SetStatus(agents.AgentStatusBusy)
+	out, err = runCLI(t, "ctl", "drain", "--wait", "50ms")
+	require.NoError(t, err)
+	assert.Equal(t, "Drained 1 agents; the daemon is paused\nInterrupted busy agents: file-1\n", out)
+	assert.True(t, dmn.Paused())
+}
+
+func TestCtlReloadCmd(t *testing.T) {
+	dmn := startDaemon(t)
+	path := filepath.Join(t.TempDir(), "capn.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("global:\n  log_level: warn\n"), 0o644))
+	globals := &GlobalOptions{Config: path}
+	cfg, err := daemonConfigLoader(globals)()
+	require.NoError(t, err)
+	dmn.SetReloader(func() (*config.Config, error) { return cfg, nil })
+	_, err = dmn.Reload()
+	require.NoError(t, err)
+
+	level := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	reloader := &daemonReloader{daemon: dmn, level: level, globals: globals, logger: zap.NewNop()}
+	dmn.SetReloader(daemonConfigLoader(globals))
+	dmn.OnReload(reloader.Apply)
+
+	out, err := runCLI(t, "ctl", "reload")
+	require.NoError(t, err)
+	assert.Equal(t, "Configuration reloaded; nothing changed\n", out)
+
+	require.NoError(t, os.WriteFile(path, []byte("global:\n  log_level: debug\nstorage:\n  path: /srv/capn/tasks\n"+
+		"agents:\n  lifecycle:\n    interval: 10ms\n    warm_pool:\n      research: 1\n"), 0o644))
+	out, err = runCLI(t, "ctl", "reload")
+	require.NoError(t, err)
+	assert.Equal(t, "Configuration reloaded\nApplied:\n  global.log_level\n  agents.lifecycle\n"+
+		"Not applied; restart the daemon to change:\n  storage.path (task storage is opened when the daemon starts)\n", out)
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+	assert.Equal(t, config.NewConfig().TasksDir(), dmn.Config().TasksDir(), "rejected settings keep their running value")
+	require.Eventually(t, func() bool {
+		return dmn.Manager().GetAgentStats().ByType[agents.AgentTypeResearch] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	out, err = runCLI(t, "--output-format", "json", "ctl", "reload")
+	require.NoError(t, err)
+	var report daemon.ReloadReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, []daemon.RejectedSetting{{Setting: "storage.path", Reason: "task storage is opened when the daemon starts"}}, report.Rejected,
+		"a setting needing a restart is reported until the daemon restarts")
+
+	require.NoError(t, os.WriteFile(path, []byte("agents:\n  lifecycle:\n    idle_ttl: -1s\n"), 0o644))
+	_, err = runCLI(t, "ctl", "reload")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to reload config")
+}
+
+func TestDaemonConfigLoader(t *testing.T) {
+	cfg, err := daemonConfigLoader(&GlobalOptions{Parallel: 3, LogLevel: "warn", ReadOnly: true})()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Global.Parallel, "without a file the options are the configuration")
+	assert.Equal(t, "warn", cfg.Global.LogLevel)
+	assert.True(t, cfg.Execution.ReadOnly, "read-only mode survives a reload")
+}
+
+func TestCtlCmd_DaemonNotRunning(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	_, err := runCLI(t, "ctl", "status")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the daemon is not running")
+	assert.Contains(t, err.Error(), `start it with "capn daemon"`)
+}
+
+func TestAgentsSendCmd(t *testing.T) {
+	dmn := startDaemon(t)
+	agent, err := dmn.Manager().SpawnAgent("file-1", "FileAgent", agents.AgentTypeFile)
+	require.NoError(t, err)
+
+	out, err := runCLI(t, "agents", "send", "file-1", "--type", "command", "re-scan ./src", "--wait", "10ms")
+	require.NoError(t, err)
+	assert.Regexp(t, `^Sent command message [0-9a-f-]+ to file-1\nNo reply within 10ms\n$`, out)
+	received := agent.(*agents.BaseAgent).GetReceivedMessages()
+	require.Len(t, received, 1)
+	assert.Equal(t, "re-scan ./src", received[0].Content)
+	assert.Equal(t, daemon.OperatorID, received[0].From)
+
+	out, err = runCLI(t, "--output-format", "json", "agents", "send", "file-1", "status?", "--wait", "10ms")
+	require.NoError(t, err)
+	var result daemon.SendResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, agents.MessageTypeText, result.Message.Type)
+
+	_, err = runCLI(t, "agents", "send", "file-9", "hello", "--wait", "10ms")
+	assert.EqualError(t, err, "recipient agent not found: file-9")
+}
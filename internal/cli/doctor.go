@@ -0,0 +1,420 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/transport"
+	"github.com/iainlowe/capn/internal/version"
+)
+
+// DoctorCmd represents the doctor command
+type DoctorCmd struct {
+	Offline     bool          `help:"Skip checks that contact LLM providers"`
+	PingTimeout time.Duration `help:"Time allowed for each network check" default:"10s"`
+}
+
+// Help returns detailed help for the doctor command
+func (d *DoctorCmd) Help() string {
+	return `Check that capn is ready to run: the configuration file, profile and secrets
+load, each LLM provider is reachable and accepts its credentials, and the data
+directories exist and are writable. Every problem comes with a suggested fix.
+The command fails when any check fails; warnings do not fail it.
+
+Examples:
+
+    capn doctor
+    capn doctor --config ~/.capn/config.yaml --profile work
+    capn doctor --offline`
+}
+
+// checkStatus is the outcome of a doctor check
+type checkStatus string
+
+const (
+	checkOK   checkStatus = "ok"
+	checkWarn checkStatus = "warn"
+	checkFail checkStatus = "fail"
+	checkSkip checkStatus = "skip"
+)
+
+// doctorCheck is the result of one diagnostic
+type doctorCheck struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	Fix    string
+}
+
+// doctorSection groups related checks under a heading
+type doctorSection struct {
+	Title  string
+	Checks []doctorCheck
+}
+
+func (d *DoctorCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	sections := []doctorSection{
+		{Title: "Version", Checks: versionChecks()},
+		{Title: "Configuration", Checks: configChecks(globals)},
+		{Title: "LLM providers", Checks: d.providerChecks(ctx, config, logger)},
+		{Title: "Storage", Checks: storageChecks(config)},
+		{Title: "Execution", Checks: executionChecks(config)},
+		{Title: "Network", Checks: networkChecks(config)},
+		{Title: "MCP servers", Checks: mcpChecks(config)},
+	}
+
+	counts := make(map[checkStatus]int)
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, section.Title)
+		for _, check := range section.Checks {
+			counts[check.Status]++
+			fmt.Fprintf(out, "  %s %s: %s\n", checkMarker(check.Status), check.Name, check.Detail)
+			if check.Fix != "" {
+				fmt.Fprintf(out, "      fix: %s\n", check.Fix)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d ok, %d warning(s), %d problem(s), %d skipped\n",
+		counts[checkOK], counts[checkWarn], counts[checkFail], counts[checkSkip])
+	if counts[checkFail] > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", counts[checkFail])
+	}
+	return nil
+}
+
+// checkMarker returns the symbol printed before a check
+func checkMarker(status checkStatus) string {
+	switch status {
+	case checkOK:
+		return "✓"
+	case checkWarn:
+		return "!"
+	case checkFail:
+		return "✗"
+	default:
+		return "-"
+	}
+}
+
+// versionChecks reports the build and platform capn is running on
+func versionChecks() []doctorCheck {
+	build := version.Get()
+	checks := []doctorCheck{
+		{Name: "capn", Status: checkOK, Detail: build.String()},
+		{Name: "go", Status: checkOK, Detail: build.GoVersion},
+	}
+	platform := doctorCheck{Name: "platform", Status: checkOK, Detail: build.Platform}
+	switch runtime.GOOS {
+	case "linux", "darwin", "windows":
+	default:
+		platform.Status = checkWarn
+		platform.Detail += " is not a supported platform"
+		platform.Fix = "run capn on Linux, macOS or Windows"
+	}
+	return append(checks, platform)
+}
+
+// configChecks loads the configuration again step by step so the failing step can be reported
+func configChecks(globals *GlobalOptions) []doctorCheck {
+	cfg := config.NewConfig()
+	file := doctorCheck{Name: "file", Status: checkOK, Detail: "none given, using defaults"}
+	if globals.Config != "" {
+		loaded, err := config.LoadConfigWith(globals.Config, config.LoadOptions{NoInterpolate: globals.NoInterpolate})
+		var interpolationErr *config.InterpolationError
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return []doctorCheck{{Name: "file", Status: checkFail, Detail: err.Error(),
+				Fix: fmt.Sprintf("create %s or pass the path of an existing file to --config", globals.Config)}}
+		case errors.As(err, &interpolationErr):
+			return []doctorCheck{{Name: "file", Status: checkFail, Detail: err.Error(),
+				Fix: "export the variables, give them defaults with ${NAME:-default}, or pass --no-interpolate to read the file as written"}}
+		case err != nil:
+			return []doctorCheck{{Name: "file", Status: checkFail, Detail: err.Error(),
+				Fix: fmt.Sprintf("correct the setting reported in %s", globals.Config)}}
+		}
+		cfg = loaded
+		file.Detail = globals.Config + " is valid"
+	}
+	checks := []doctorCheck{file}
+
+	if profile := globals.Profile; profile != "" || cfg.Global.Profile != "" {
+		if profile == "" {
+			profile = cfg.Global.Profile
+		}
+		if err := cfg.ApplyProfile(profile); err != nil {
+			return append(checks, doctorCheck{Name: "profile", Status: checkFail, Detail: err.Error(),
+				Fix: "pass one of the available profiles to --profile or add it under profiles:"})
+		}
+		checks = append(checks, doctorCheck{Name: "profile", Status: checkOK, Detail: profile + " applied"})
+	}
+
+	if err := resolveSecrets(cfg); err != nil {
+		return append(checks, doctorCheck{Name: "secrets", Status: checkFail, Detail: err.Error(),
+			Fix: "check the secrets settings, then list them with \"capn secrets list\""})
+	}
+	detail := "no secret providers configured"
+	if providers := cfg.SecretProviders(); len(providers) > 0 {
+		detail = "resolved from " + strings.Join(providers, ", ")
+	}
+	return append(checks, doctorCheck{Name: "secrets", Status: checkOK, Detail: detail})
+}
+
+// providerChecks creates each configured LLM provider and pings the ones that support it
+func (d *DoctorCmd) providerChecks(ctx context.Context, cfg *config.Config, logger *zap.Logger) []doctorCheck {
+	if !llmConfigured(cfg) {
+		return []doctorCheck{{Name: "llm", Status: checkWarn, Detail: "no LLM provider configured; only --dry-run planning is possible",
+			Fix: "set OPENAI_API_KEY, run \"capn secrets set openai.api_key\" or add providers under llm.providers"}}
+	}
+
+	providers := cfg.LLM.Providers
+	if len(providers) == 0 {
+		providers = []config.LLMProviderConfig{{Type: config.ProviderOpenAI}}
+	}
+	recorded, _ := captain.ReadProviderHealth(cfg.ProviderHealthFile())
+
+	var checks []doctorCheck
+	for _, providerConfig := range providers {
+		name := providerConfig.DisplayName()
+		provider, err := captain.NewLLMProvider(providerConfig, openAIConfig(cfg))
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: name, Status: checkFail, Detail: err.Error(),
+				Fix: fmt.Sprintf("complete the %s settings; API keys can also come from the environment or \"capn secrets set\"", name)})
+			continue
+		}
+		checks = append(checks, d.pingProvider(ctx, name, providerConfig, provider, logger))
+
+		for _, health := range recorded {
+			if health.Name == name && health.State != captain.CircuitClosed {
+				checks = append(checks, doctorCheck{Name: name + " circuit", Status: checkWarn,
+					Detail: fmt.Sprintf("%s after %d failure(s): %s", health.State, health.ConsecutiveFailures, health.LastError),
+					Fix:    fmt.Sprintf("capn retries %s after %s; if the problem persists check the provider's status", name, health.RetryAt.Format(time.RFC3339))})
+			}
+		}
+	}
+	return checks
+}
+
+// pingProvider checks a provider's endpoint and credentials
+func (d *DoctorCmd) pingProvider(ctx context.Context, name string, providerConfig config.LLMProviderConfig, provider captain.LLMProvider, logger *zap.Logger) doctorCheck {
+	check := doctorCheck{Name: name}
+	pinger, ok := provider.(captain.Pinger)
+	switch {
+	case d.Offline:
+		check.Status, check.Detail = checkSkip, "not contacted (--offline)"
+		return check
+	case !ok:
+		check.Status, check.Detail = checkSkip, "provider cannot be checked without a completion"
+		return check
+	}
+
+	logger.Debug("Pinging LLM provider", zap.String("provider", name))
+	pingCtx, cancel := context.WithTimeout(ctx, d.PingTimeout)
+	defer cancel()
+	err := pinger.Ping(pingCtx)
+
+	var apiErr *captain.APIError
+	switch {
+	case err == nil:
+		check.Status, check.Detail = checkOK, "reachable, credentials accepted"
+		if reporter, ok := provider.(captain.CapabilityReporter); ok {
+			check.Detail += "; " + formatCapabilities(reporter.Capabilities())
+		}
+	case errors.As(err, &apiErr) && apiErr.Unauthorized():
+		check.Status, check.Detail = checkFail, "credentials rejected: "+err.Error()
+		check.Fix = fmt.Sprintf("check the API key for %s; it may be mistyped, revoked or lack access to the model", name)
+	case errors.Is(err, captain.ErrModelUnavailable):
+		check.Status, check.Detail = checkFail, err.Error()
+		check.Fix = "choose a model the server lists"
+		if providerConfig.Type == config.ProviderOllama {
+			check.Fix = fmt.Sprintf("run \"ollama pull %s\" on the server or choose a model it has", providerConfig.Model)
+		}
+	default:
+		check.Status, check.Detail = checkFail, "unreachable: "+err.Error()
+		check.Fix = "check the provider's base_url, your network and proxy settings, and that the server is running"
+	}
+	return check
+}
+
+// formatCapabilities lists the optional API features a provider offers
+func formatCapabilities(capabilities captain.Capabilities) string {
+	var features []string
+	if capabilities.FunctionCalling {
+		features = append(features, "function calling")
+	}
+	if capabilities.Embeddings {
+		features = append(features, "embeddings")
+	}
+	if len(features) == 0 {
+		return "no function calling or embeddings"
+	}
+	return strings.Join(features, ", ")
+}
+
+// storageChecks verifies the data directories can be written and the task history read
+func storageChecks(cfg *config.Config) []doctorCheck {
+	checks := []doctorCheck{
+		directoryCheck("home", config.HomeDir(), "set CAPN_HOME to a writable directory"),
+		directoryCheck("tasks", cfg.TasksDir(), "set storage.path or CAPN_HOME to a writable directory"),
+		directoryCheck("templates", cfg.TemplatesDir(), "set CAPN_HOME to a writable directory"),
+		directoryCheck("artifacts", cfg.ArtifactsDir(), "set CAPN_HOME to a writable directory"),
+	}
+	if checks[1].Status != checkOK {
+		return checks
+	}
+	if _, err := os.Stat(cfg.TasksDir()); err != nil {
+		return checks
+	}
+
+	history := doctorCheck{Name: "task history"}
+	if storage, err := task.NewFileTaskStorage(cfg.TasksDir()); err != nil {
+		history.Status, history.Detail = checkFail, err.Error()
+	} else if tasks, err := storage.ListTasks(task.TaskFilter{}); err != nil {
+		history.Status, history.Detail = checkFail, err.Error()
+		history.Fix = "move the unreadable task file named above out of " + cfg.TasksDir()
+	} else {
+		history.Status, history.Detail = checkOK, fmt.Sprintf("%d task(s) readable", len(tasks))
+	}
+	return append(checks, history)
+}
+
+// directoryCheck reports whether dir is a writable directory or can be created
+func directoryCheck(name, dir, fix string) doctorCheck {
+	check := doctorCheck{Name: name, Status: checkOK}
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// Directories are created on first use; check the nearest existing parent instead
+		parent := filepath.Dir(dir)
+		for parent != filepath.Dir(parent) {
+			if _, err := os.Stat(parent); err == nil {
+				break
+			}
+			parent = filepath.Dir(parent)
+		}
+		if err := writable(parent); err != nil {
+			check.Status, check.Detail, check.Fix = checkFail, fmt.Sprintf("%s cannot be created: %v", dir, err), fix
+			return check
+		}
+		check.Detail = dir + " (created on first use)"
+	case err != nil:
+		check.Status, check.Detail, check.Fix = checkFail, err.Error(), fix
+	case !info.IsDir():
+		check.Status, check.Detail = checkFail, dir+" is not a directory"
+		check.Fix = "remove or rename " + dir + ", or " + fix
+	default:
+		if err := writable(dir); err != nil {
+			check.Status, check.Detail = checkFail, fmt.Sprintf("%s is not writable: %v", dir, err)
+			check.Fix = "run \"chmod u+w " + dir + "\", or " + fix
+			return check
+		}
+		check.Detail = dir
+	}
+	return check
+}
+
+// writable checks dir accepts new files by creating and removing one
+func writable(dir string) error {
+	f, err := os.CreateTemp(dir, ".capn-doctor-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// executionChecks reports where plan commands run and, when they may run in a container,
+// whether the container runtime is installed
+func executionChecks(cfg *config.Config) []doctorCheck {
+	mode := cfg.Execution.Mode
+	if mode == "" {
+		mode = config.ExecutionModeHost
+	}
+	container := cfg.Execution.Container
+	if container.Image == "" {
+		return []doctorCheck{{Name: "mode", Status: checkOK, Detail: mode + " (no container image configured)"}}
+	}
+
+	checks := []doctorCheck{{Name: "mode", Status: checkOK, Detail: fmt.Sprintf("%s (container image %s)", mode, container.Image)}}
+	program := container.Runtime
+	if program == "" {
+		detected, err := agents.DetectContainerRuntime()
+		if err != nil {
+			return append(checks, doctorCheck{Name: "runtime", Status: checkFail, Detail: err.Error(),
+				Fix: "install docker or podman, or remove execution.container"})
+		}
+		program = detected
+	}
+	path, err := exec.LookPath(program)
+	if err != nil {
+		return append(checks, doctorCheck{Name: "runtime", Status: checkFail, Detail: program + " not found",
+			Fix: "install " + program + " or change execution.container.runtime"})
+	}
+	return append(checks, doctorCheck{Name: "runtime", Status: checkOK, Detail: path})
+}
+
+// networkChecks reports the proxies and certificate authorities each section's HTTP client
+// uses, failing on settings that cannot be loaded and warning when verification is disabled
+func networkChecks(cfg *config.Config) []doctorCheck {
+	sections := cfg.HTTPSections()
+	if len(sections) == 0 {
+		return []doctorCheck{{Name: "http", Status: checkOK, Detail: "no proxy or CA settings; HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply"}}
+	}
+
+	var checks []doctorCheck
+	for _, section := range sections {
+		settings := section.HTTP
+		if _, err := transport.NewHTTPClient(settings); err != nil {
+			checks = append(checks, doctorCheck{Name: section.Name, Status: checkFail, Detail: err.Error(),
+				Fix: "check the proxy URLs and that ca_bundle names a readable PEM file"})
+			continue
+		}
+		var details []string
+		switch {
+		case settings.HTTPSProxy != "":
+			details = append(details, "https via "+settings.HTTPSProxy)
+			if settings.HTTPProxy != "" {
+				details = append(details, "http via "+settings.HTTPProxy)
+			}
+		case settings.HTTPProxy != "":
+			details = append(details, "proxy "+settings.HTTPProxy)
+		default:
+			details = append(details, "proxy from environment")
+		}
+		if settings.CABundle != "" {
+			details = append(details, "trusting "+settings.CABundle)
+		}
+		if settings.InsecureSkipVerify {
+			checks = append(checks, doctorCheck{Name: section.Name, Status: checkWarn,
+				Detail: "TLS certificate verification is DISABLED; traffic and credentials can be intercepted (" + strings.Join(details, ", ") + ")",
+				Fix:    "remove insecure_skip_verify and add the proxy's CA certificate to ca_bundle"})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: section.Name, Status: checkOK, Detail: strings.Join(details, ", ")})
+	}
+	return checks
+}
+
+// mcpChecks reports the MCP settings; no MCP servers can be configured yet, so there is
+// nothing to connect to
+func mcpChecks(cfg *config.Config) []doctorCheck {
+	return []doctorCheck{{Name: "servers", Status: checkSkip,
+		Detail: fmt.Sprintf("none configured (timeout %s, %d retries)", cfg.MCP.Timeout, cfg.MCP.RetryCount)}}
+}
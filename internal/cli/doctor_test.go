@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func writeDoctorConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDoctorCmd_Healthy(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	seedTask(t, task.TaskStatusCompleted)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama3:latest"}]}`))
+	}))
+	defer server.Close()
+	path := writeDoctorConfig(t, "llm:\n  providers:\n    - type: ollama\n      model: llama3\n      base_url: "+server.URL+"\n")
+
+	out, err := runCLI(t, "doctor", "--config", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "✓ file: "+path+" is valid")
+	assert.Contains(t, out, "✓ ollama: reachable, credentials accepted")
+	assert.Contains(t, out, "✓ task history: 1 task(s) readable")
+	assert.Contains(t, out, "- servers: none configured")
+	assert.Contains(t, out, "0 problem(s)")
+}
+
+func TestDoctorCmd_OpenAICompatibleCapabilities(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"llama-3.1-70b"}]}`))
+	}))
+	defer server.Close()
+	path := writeDoctorConfig(t, "llm:\n  providers:\n    - name: groq\n      type: openai-compatible\n      model: llama-3.1-70b\n      base_url: "+server.URL+
+		"\n      capabilities:\n        function_calling: true\n")
+
+	out, err := runCLI(t, "doctor", "--config", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "✓ groq: reachable, credentials accepted; function calling")
+}
+
+func TestDoctorCmd_Problems(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"type":"authentication_error"}}`))
+	}))
+	defer server.Close()
+
+	// A file where the tasks directory should be
+	tasksPath := filepath.Join(home, "tasks-file")
+	require.NoError(t, os.WriteFile(tasksPath, nil, 0o600))
+	path := writeDoctorConfig(t, "storage:\n  path: "+tasksPath+"\nllm:\n  providers:\n    - type: anthropic\n      model: claude-sonnet-4\n      api_key: bad-key\n      base_url: "+server.URL+"\n")
+
+	out, err := runCLI(t, "doctor", "--config", path)
+	assert.EqualError(t, err, "doctor found 2 problem(s)")
+	assert.Contains(t, out, "✗ anthropic: credentials rejected")
+	assert.Contains(t, out, "fix: check the API key for anthropic")
+	assert.Contains(t, out, "✗ tasks: "+tasksPath+" is not a directory")
+}
+
+func TestDoctorCmd_BrokenConfig(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	path := writeDoctorConfig(t, "captain:\n  max_concurrent_agents: -1\n")
+
+	_, err := runCLI(t, "status", "--config", path)
+	require.Error(t, err, "other commands still refuse a broken config")
+
+	out, err := runCLI(t, "doctor", "--config", path, "--offline")
+	assert.EqualError(t, err, "doctor found 1 problem(s)")
+	assert.Contains(t, out, "✗ file: invalid configuration: max_concurrent_agents must be positive")
+	assert.Contains(t, out, "Storage", "the remaining checks still run")
+
+	out, err = runCLI(t, "doctor", "--config", filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, out, "fix: create ")
+}
+
+func TestDoctorCmd_MissingEnvVar(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	path := writeDoctorConfig(t, "storage:\n  path: ${CAPN_TEST_DATA_DIR}/tasks\n")
+
+	_, err := runCLI(t, "status", "--config", path)
+	assert.ErrorContains(t, err, "environment variable(s) not set: CAPN_TEST_DATA_DIR (line 2)")
+
+	out, err := runCLI(t, "doctor", "--config", path, "--offline")
+	assert.EqualError(t, err, "doctor found 1 problem(s)")
+	assert.Contains(t, out, "fix: export the variables, give them defaults with ${NAME:-default}")
+
+	out, err = runCLI(t, "doctor", "--config", path, "--offline", "--no-interpolate")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "✓ file: "+path+" is valid")
+	assert.Contains(t, out, "${CAPN_TEST_DATA_DIR}/tasks")
+
+	t.Setenv("CAPN_TEST_DATA_DIR", t.TempDir())
+	out, err = runCLI(t, "doctor", "--config", path, "--offline")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, os.Getenv("CAPN_TEST_DATA_DIR")+"/tasks")
+}
+
+func TestDoctorCmd_Offline(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+
+	out, err := runCLI(t, "doctor", "--offline")
+	require.NoError(t, err)
+	assert.Contains(t, out, "- openai: not contacted (--offline)")
+}
+
+func TestDirectoryCheck(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, checkOK, directoryCheck("home", dir, "").Status)
+
+	missing := directoryCheck("tasks", filepath.Join(dir, "a", "b"), "")
+	assert.Equal(t, checkOK, missing.Status)
+	assert.Contains(t, missing.Detail, "created on first use")
+}
+
+func TestExecutionChecks(t *testing.T) {
+	cfg := config.NewConfig()
+	checks := executionChecks(cfg)
+	require.Len(t, checks, 1)
+	assert.Equal(t, "host (no container image configured)", checks[0].Detail)
+
+	t.Setenv("PATH", t.TempDir())
+	cfg.Execution = config.ExecutionConfig{Mode: config.ExecutionModeContainer, Container: config.ContainerConfig{Image: "alpine", Runtime: "podman"}}
+	checks = executionChecks(cfg)
+	require.Len(t, checks, 2)
+	assert.Equal(t, "container (container image alpine)", checks[0].Detail)
+	assert.Equal(t, checkFail, checks[1].Status)
+	assert.Equal(t, "podman not found", checks[1].Detail)
+}
+
+func TestNetworkChecks(t *testing.T) {
+	cfg := config.NewConfig()
+	checks := networkChecks(cfg)
+	require.Len(t, checks, 1)
+	assert.Equal(t, checkOK, checks[0].Status)
+
+	cfg.OpenAI.HTTP = config.HTTPConfig{HTTPProxy: "http://proxy.corp:3128"}
+	cfg.Crew.HTTP = config.HTTPConfig{InsecureSkipVerify: true}
+	cfg.MCP.HTTP = config.HTTPConfig{CABundle: filepath.Join(t.TempDir(), "missing.pem")}
+	checks = networkChecks(cfg)
+	require.Len(t, checks, 3)
+	assert.Equal(t, doctorCheck{Name: "openai", Status: checkOK, Detail: "proxy http://proxy.corp:3128"}, checks[0])
+	assert.Equal(t, "mcp", checks[1].Name)
+	assert.Equal(t, checkFail, checks[1].Status)
+	assert.Contains(t, checks[1].Detail, "failed to read CA bundle")
+	assert.Equal(t, "crew", checks[2].Name)
+	assert.Equal(t, checkWarn, checks[2].Status)
+	assert.Contains(t, checks[2].Detail, "TLS certificate verification is DISABLED")
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// duplicateInput is where the question about a task with the same goal is answered; it is
+// replaced in tests
+var duplicateInput = os.Stdin
+
+// followDuplicate looks for a task of the workspace already in flight with the same goal and
+// offers to follow it instead of starting another: --attach follows it without asking, a
+// terminal is asked and anything else fails. It reports whether the existing task was
+// followed, err then being the outcome of following it.
+func (e *ExecuteCmd) followDuplicate(ctx context.Context, out io.Writer, globals *GlobalOptions, cfg *config.Config, workspace string) (bool, error) {
+	storage, err := openTaskStorage(cfg)
+	if err != nil {
+		return false, err
+	}
+	existing, err := task.FindDuplicate(storage, e.Goal, workspace)
+	if err != nil || existing == nil {
+		return false, err
+	}
+
+	switch {
+	case e.Attach:
+	case isTerminal(duplicateInput):
+		prompts := out
+		if globals.Quiet {
+			prompts = os.Stderr
+		}
+		if !askFollowDuplicate(duplicateInput, prompts, existing) {
+			return false, nil
+		}
+	default:
+		return true, fmt.Errorf("task %s (%s) already has this goal; follow it with \"capn tasks logs %s --follow\", or pass --force to start another",
+			existing.ID, existing.Status, existing.ID)
+	}
+
+	if globals.quiet() {
+		fmt.Fprintln(out, existing.ID)
+	} else {
+		fmt.Fprintf(out, "Following task %s, which already has this goal; press Ctrl-C to detach\n", existing.ID)
+	}
+	return true, followTask(ctx, out, globals, storage, existing.ID, nil, "")
+}
+
+// askFollowDuplicate asks whether to follow an existing task with the same goal rather than
+// start another; an empty answer follows it
+func askFollowDuplicate(in io.Reader, out io.Writer, existing *task.TaskExecution) bool {
+	fmt.Fprintf(out, "Task %s (%s, created %s) already has this goal: %s\n",
+		existing.ID, existing.Status, existing.CreatedAt.Format("2006-01-02 15:04"), truncate(existing.Goal, 60))
+	fmt.Fprintf(out, "Follow it instead of starting another task? [Y/n]: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "", "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// pipedInput replaces the terminal asked about duplicate goals with a pipe, which is not one
+func pipedInput(t *testing.T) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	previous := duplicateInput
+	duplicateInput = r
+	t.Cleanup(func() {
+		duplicateInput = previous
+		r.Close()
+		w.Close()
+	})
+}
+
+func TestExecuteCmd_Duplicate(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	pipedInput(t)
+	running := seedTask(t, task.TaskStatusRunning)
+
+	_, err := runCLI(t, "execute", "Analyze  code quality.")
+	assert.EqualError(t, err, `task `+running.ID+` (running) already has this goal; follow it with "capn tasks logs `+running.ID+` --follow", or pass --force to start another`)
+
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1, "no duplicate task is recorded")
+}
+
+func TestExecuteCmd_DuplicateAttach(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	running := seedTask(t, task.TaskStatusRunning)
+	started, clock := fakeBackground(t, task.TaskStatusCompleted)
+
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+	go func() {
+		clock.BlockUntil(1)
+		running.AddLog(task.LogLevelInfo, "Report written")
+		running.SetStatus(task.TaskStatusCompleted)
+		_ = storage.SaveTask(running)
+		clock.Advance(followInterval)
+	}()
+
+	out, err := runCLI(t, "execute", "--attach", "analyze code quality")
+	require.NoError(t, err)
+	assert.Nil(t, *started, "no background task is started")
+	assert.Contains(t, out, "Following task "+running.ID+", which already has this goal; press Ctrl-C to detach")
+	assert.Contains(t, out, "[task-1] analysis finished")
+	assert.Contains(t, out, "Report written")
+	assert.Contains(t, out, "Task "+running.ID+" completed after")
+
+	out, err = runCLI(t, "execute", "--attach", "--force", "analyze code quality")
+	require.NoError(t, err)
+	require.NotNil(t, *started, "--force starts another task")
+	assert.Contains(t, out, "started in the background")
+}
+
+func TestAskFollowDuplicate(t *testing.T) {
+	existing := task.NewTaskExecution("analyze code quality")
+	existing.SetStatus(task.TaskStatusQueued)
+
+	var out strings.Builder
+	assert.True(t, askFollowDuplicate(strings.NewReader("\n"), &out, existing))
+	assert.Contains(t, out.String(), "Task "+existing.ID+" (queued, created ")
+	assert.Contains(t, out.String(), "already has this goal: analyze code quality\nFollow it instead of starting another task? [Y/n]: ")
+
+	assert.True(t, askFollowDuplicate(strings.NewReader("yes\n"), &out, existing))
+	assert.False(t, askFollowDuplicate(strings.NewReader("n\n"), &out, existing))
+	assert.False(t, askFollowDuplicate(strings.NewReader(""), &out, existing), "no answer starts another task")
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// effectSymbols mark each effect kind in the dry-run report, in the style of terraform plan
+var effectSymbols = map[captain.EffectKind]string{
+	captain.EffectFileWrite:      "+",
+	captain.EffectFileDelete:     "-",
+	captain.EffectNetwork:        "~",
+	captain.EffectPackageInstall: "+",
+	captain.EffectCommand:        ">",
+}
+
+// effectNouns names each effect kind in the report summary as singular and plural
+var effectNouns = map[captain.EffectKind][2]string{
+	captain.EffectFileWrite:      {"file written", "files written"},
+	captain.EffectFileDelete:     {"file deleted", "files deleted"},
+	captain.EffectNetwork:        {"network call", "network calls"},
+	captain.EffectPackageInstall: {"package installed", "packages installed"},
+	captain.EffectCommand:        {"command run", "commands run"},
+}
+
+// printEffectReport writes the "what would change" report for a dry-run plan
+func printEffectReport(out io.Writer, plan *captain.ExecutionPlan, report *captain.EffectReport) {
+	fmt.Fprintf(out, "\n=== Expected Side Effects ===\n")
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(out, "Warning: %s\n", warning)
+	}
+
+	for _, task := range plan.Tasks {
+		effects := report.ForTask(task.ID)
+		fmt.Fprintf(out, "%s [%s] %v\n", task.ID, task.Type, task.Payload["description"])
+		if len(effects) == 0 {
+			fmt.Fprintf(out, "    (no changes)\n")
+			continue
+		}
+		for _, effect := range effects {
+			target := effect.Target
+			if target == "" {
+				target = "(unspecified)"
+			}
+			fmt.Fprintf(out, "  %s %-15s %s", effectSymbols[effect.Kind], effect.Kind, target)
+			if effect.Description != "" {
+				fmt.Fprintf(out, "  # %s", effect.Description)
+			}
+			fmt.Fprintln(out)
+		}
+	}
+
+	fmt.Fprintf(out, "\n%s\n", effectSummary(report))
+}
+
+// effectSummary renders a one-line count of expected effects by kind
+func effectSummary(report *captain.EffectReport) string {
+	if len(report.Effects) == 0 {
+		return "No changes. This plan is expected to be read-only."
+	}
+
+	counts := report.Counts()
+	var parts []string
+	for _, kind := range captain.EffectKinds {
+		count := counts[kind]
+		if count == 0 {
+			continue
+		}
+		noun := effectNouns[kind][1]
+		if count == 1 {
+			noun = effectNouns[kind][0]
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", count, noun))
+	}
+	return "Would change: " + strings.Join(parts, ", ") + "."
+}
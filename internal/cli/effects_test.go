@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func TestPrintEffectReport(t *testing.T) {
+	plan := &captain.ExecutionPlan{
+		ID: "plan-1",
+		Tasks: []captain.Task{
+			{ID: "task-1", Type: captain.TaskTypeAnalysis, Payload: map[string]any{"description": "Review"}},
+			{ID: "task-2", Type: captain.TaskTypeExecution, Payload: map[string]any{"description": "Deploy"}},
+		},
+	}
+	report := &captain.EffectReport{
+		PlanID:   "plan-1",
+		Warnings: []string{"LLM effect analysis unavailable"},
+		Effects: []captain.Effect{
+			{TaskID: "task-2", Kind: captain.EffectFileWrite, Target: "deploy.yaml", Description: "writes files"},
+			{TaskID: "task-2", Kind: captain.EffectNetwork},
+			{TaskID: "task-2", Kind: captain.EffectNetwork, Target: "https://example.com"},
+		},
+	}
+
+	var buf bytes.Buffer
+	printEffectReport(&buf, plan, report)
+	out := buf.String()
+
+	assert.Contains(t, out, "=== Expected Side Effects ===")
+	assert.Contains(t, out, "Warning: LLM effect analysis unavailable")
+	assert.Contains(t, out, "task-1 [analysis] Review\n    (no changes)")
+	assert.Contains(t, out, "+ file_write      deploy.yaml  # writes files")
+	assert.Contains(t, out, "~ network         (unspecified)")
+	assert.Contains(t, out, "Would change: 1 file written, 2 network calls.")
+}
+
+func TestEffectSummary_NoChanges(t *testing.T) {
+	assert.Equal(t, "No changes. This plan is expected to be read-only.", effectSummary(&captain.EffectReport{}))
+}
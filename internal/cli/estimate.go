@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// clock is the clock ETAs are set and shown against
+var clock common.Clock = common.SystemClock
+
+// historicalEstimate estimates how long the plan will take from the durations of
+// previously recorded steps, reporting false when there is no history to go on
+func historicalEstimate(storage task.TaskStorage, plan *captain.ExecutionPlan) (time.Duration, bool) {
+	history, err := storage.ListTasks(task.TaskFilter{})
+	if err != nil {
+		return 0, false
+	}
+	estimator := task.NewEstimator(history)
+	var total time.Duration
+	for _, step := range plan.Tasks {
+		d, ok := estimator.StepDuration(step)
+		if !ok {
+			return 0, false
+		}
+		total += d
+	}
+	return total.Round(time.Second), len(plan.Tasks) > 0
+}
+
+// etaTracker records a running task's estimated duration and keeps its ETA up to date as
+// steps finish, so "capn status" and "capn tasks show" can display it
+type etaTracker struct {
+	estimator *task.Estimator
+	storage   task.TaskStorage
+	record    *task.TaskExecution
+	plan      *captain.ExecutionPlan
+	logger    *zap.Logger
+	done      map[string]bool
+}
+
+// startETATracking estimates the plan from the durations of previously recorded steps and
+// saves the task's ETA
+func startETATracking(storage task.TaskStorage, record *task.TaskExecution, plan *captain.ExecutionPlan, logger *zap.Logger) *etaTracker {
+	history, err := storage.ListTasks(task.TaskFilter{})
+	if err != nil {
+		logger.Warn("Failed to load task history for estimates", zap.Error(err))
+	}
+	e := &etaTracker{
+		estimator: task.NewEstimator(history),
+		storage:   storage,
+		record:    record,
+		plan:      plan,
+		logger:    logger,
+		done:      make(map[string]bool),
+	}
+	e.update()
+	return e
+}
+
+// afterStep marks a step finished and saves the task's updated ETA
+func (e *etaTracker) afterStep(step captain.Task, result *captain.Result) {
+	e.done[step.ID] = true
+	e.update()
+}
+
+func (e *etaTracker) update() {
+	remaining, ok := e.estimator.Remaining(e.plan, e.done)
+	if !ok {
+		return
+	}
+	e.record.SetEstimate(remaining, clock.Now())
+	saveTask(e.storage, e.record, e.logger)
+}
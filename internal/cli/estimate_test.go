@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// useFakeClock makes the CLI tell time with a fake clock for the rest of the test
+func useFakeClock(t *testing.T) *testutil.FakeClock {
+	t.Helper()
+	fake := testutil.NewFakeClock(time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local))
+	previous := clock
+	t.Cleanup(func() { clock = previous })
+	clock = fake
+	return fake
+}
+
+func TestETATracker(t *testing.T) {
+	storage := task.NewMemoryTaskStorage()
+	past := task.NewTaskExecution("analyze code quality")
+	past.Plan = &captain.ExecutionPlan{
+		ID: "plan-1",
+		Tasks: []captain.Task{
+			{ID: "task-1", Type: captain.TaskTypeAnalysis},
+			{ID: "task-2", Type: captain.TaskTypeReporting},
+		},
+	}
+	past.Results = []captain.Result{{TaskID: "task-1", Success: true, Duration: 2 * time.Minute}}
+	past.SetStatus(task.TaskStatusFailed)
+	require.NoError(t, storage.SaveTask(past))
+
+	record := task.NewTaskExecution("analyze again")
+	record.Plan = past.Plan
+	record.SetStatus(task.TaskStatusRunning)
+	fake := useFakeClock(t)
+	eta := startETATracking(storage, record, record.Plan, zap.NewNop())
+
+	estimate, ok := record.EstimatedDuration()
+	require.True(t, ok)
+	assert.Equal(t, 4*time.Minute, estimate, "both steps are estimated from the one analysis step on record")
+	first, ok := record.ETA()
+	require.True(t, ok)
+	assert.WithinDuration(t, fake.Now().Add(4*time.Minute), first, 0)
+
+	fake.Advance(3 * time.Minute)
+	eta.afterStep(record.Plan.Tasks[0], &captain.Result{TaskID: "task-1", Success: true})
+	stored, err := storage.GetTask(record.ID)
+	require.NoError(t, err)
+	updated, ok := stored.ETA()
+	require.True(t, ok, "the updated ETA is saved")
+	assert.WithinDuration(t, fake.Now().Add(2*time.Minute), updated, 0, "the remaining step is estimated from history")
+	estimate, _ = stored.EstimatedDuration()
+	assert.Equal(t, 4*time.Minute, estimate)
+}
+
+func TestStatusCmd_Estimates(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	fake := useFakeClock(t)
+	running := seedTask(t, task.TaskStatusRunning)
+	running.SetEstimate(10*time.Minute, fake.Now())
+	require.NoError(t, storage.SaveTask(running))
+
+	done := seedTask(t, task.TaskStatusRunning)
+	done.Metadata[task.MetadataEstimatedDuration] = "3m0s"
+	done.StartedAt = time.Now().Add(-4 * time.Minute)
+	done.SetStatus(task.TaskStatusCompleted)
+	require.NoError(t, storage.SaveTask(done))
+
+	fake.Advance(3 * time.Minute)
+	out, err := runCLI(t, "status")
+	require.NoError(t, err)
+	assert.Regexp(t, running.ID+`\s+running, ETA 09:10:00 \(in 7m0s\)`, out)
+	assert.Contains(t, out, "Estimates: off by 25% on average over 1 completed task(s), tending to underestimate")
+
+	out, err = runCLI(t, "tasks", "show", running.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Estimate: 10m0s, ETA 09:10:00 (in 7m0s)")
+
+	out, err = runCLI(t, "tasks", "show", done.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Estimate: 3m0s (25% under the actual 4m0s)\n")
+}
+
+func TestFormatETA(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)
+	assert.Equal(t, "09:02:30 (in 2m30s)", formatETA(now.Add(150*time.Second), now))
+	assert.Equal(t, "08:59:00 (overdue)", formatETA(now.Add(-time.Minute), now))
+}
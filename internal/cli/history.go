@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// AgentsHistoryCmd represents the agents history command
+type AgentsHistoryCmd struct {
+	Agent  string        `help:"Only show messages to or from this agent" placeholder:"AGENT-ID"`
+	Limit  int           `help:"Maximum number of recent messages to show" default:"50"`
+	Stats  bool          `help:"Show message volume per agent pair over time instead of the messages"`
+	Period time.Duration `help:"Length of each period --stats counts messages in" default:"24h"`
+}
+
+// Help returns detailed help for the agents history command
+func (h *AgentsHistoryCmd) Help() string {
+	return `Show the messages agents sent each other, as persisted by the daemon. The log
+is trimmed to agents.communication.max_messages and max_age; messages about a
+task stay in that task's log (see "capn tasks logs") whatever the retention.
+Content larger than agents.communication.max_message_size (64 KiB by default)
+is shown as a preview; the full content is stored as an attachment among the
+task's artifacts, named in the message.
+
+With --stats, messages are counted per sender and recipient in each period, to
+show which agents talk most and how that changes over time.
+
+Examples:
+
+    capn agents history
+    capn agents history --agent file-001 --limit 20
+    capn agents history --stats --period 1h`
+}
+
+func (h *AgentsHistoryCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	messages, err := agents.LoadMessageLog(config.MessagesFile())
+	if err != nil {
+		return err
+	}
+	if h.Agent != "" {
+		var filtered []agents.MessageLog
+		for _, log := range messages {
+			if log.Message.From == h.Agent || log.Message.To == h.Agent {
+				filtered = append(filtered, log)
+			}
+		}
+		messages = filtered
+	}
+	if len(messages) == 0 && !h.Stats {
+		fmt.Fprintln(out, "No agent messages recorded.")
+		return nil
+	}
+
+	if h.Stats {
+		volumes := agents.MessageVolume(messages, h.Period)
+		listing := Listing{Columns: []string{"PERIOD", "FROM", "TO", "MESSAGES"}, Records: volumes, Empty: "No agent messages recorded."}
+		for _, volume := range volumes {
+			listing.Rows = append(listing.Rows, []string{volume.Period.Format(time.RFC3339), volume.From, volume.To, strconv.Itoa(volume.Count)})
+		}
+		return newPresenter(out, globals).List(listing)
+	}
+
+	if h.Limit > 0 && len(messages) > h.Limit {
+		messages = messages[len(messages)-h.Limit:]
+	}
+	for _, log := range messages {
+		fmt.Fprintln(out, log.Formatted)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestAgentsHistoryCmd(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+
+	out, err := runCLI(t, "agents", "history")
+	require.NoError(t, err)
+	assert.Equal(t, "No agent messages recorded.\n", out)
+
+	logger, err := agents.NewFileCommunicationLogger(filepath.Join(home, "messages.jsonl"), agents.Retention{})
+	require.NoError(t, err)
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, pair := range [][2]string{{"captain", "file-001"}, {"captain", "file-001"}, {"file-001", "captain"}, {"captain", "net-001"}} {
+		logger.LogMessage(pair[0], pair[1], agents.Message{
+			From: pair[0], To: pair[1], Content: "message " + string(rune('a'+i)), Timestamp: at.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	out, err = runCLI(t, "agents", "history", "--limit", "2")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `file-001 -> captain: "message c"`)
+	assert.Contains(t, lines[1], `captain -> net-001: "message d"`)
+
+	out, err = runCLI(t, "agents", "history", "--agent", "net-001")
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+
+	out, err = runCLI(t, "agents", "history", "--stats")
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	assert.Regexp(t, `^PERIOD\s+FROM\s+TO\s+MESSAGES$`, lines[0])
+	assert.Regexp(t, `^2026-03-01T00:00:00Z\s+captain\s+file-001\s+2$`, lines[1])
+}
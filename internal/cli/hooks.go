@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/hooks"
+)
+
+// hook runs the hooks configured for an event on the task. Failing hooks are logged by the
+// runner rather than failing the run.
+func (r *taskRun) hook(ctx context.Context, event string, step *hooks.Step) {
+	if r.config.Hooks.IsZero() {
+		return
+	}
+	payload := hooks.NewEvent(event, r.record)
+	payload.Step = step
+	_ = hooks.NewRunner(r.config.Hooks, r.logger).Run(ctx, payload)
+}
+
+// hookStep describes a finished plan step for a step_complete hook
+func hookStep(step captain.Task, result *captain.Result) *hooks.Step {
+	return &hooks.Step{
+		ID:       step.ID,
+		Type:     string(step.Type),
+		Success:  result.Success,
+		Output:   result.Output,
+		Error:    result.Error,
+		Duration: result.Duration,
+	}
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/hooks"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// readHookEvents reads the events hooks appended to path, one JSON object per line
+func readHookEvents(t *testing.T, path string) []hooks.Event {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var events []hooks.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event hooks.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestTaskRun_Hooks(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	original := seedTask(t, task.TaskStatusFailed)
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	record := "      - command: cat >> " + events + "; echo >> " + events + "\n"
+	path := writeDoctorConfig(t, "hooks:\n  on_task_start:\n"+record+"  on_step_complete:\n"+record+"  on_task_failed:\n"+record)
+
+	_, err := runCLI(t, "tasks", "retry", original.ID, "--failed-only", "--approve-all", "--config", path)
+	require.NoError(t, err)
+
+	got := readHookEvents(t, events)
+	require.Len(t, got, 2, "one step runs and the task does not fail")
+	assert.Equal(t, hooks.EventTaskStart, got[0].Event)
+	assert.Equal(t, "running", got[0].Status)
+	assert.Equal(t, hooks.EventStepComplete, got[1].Event)
+	require.NotNil(t, got[1].Step)
+	assert.Equal(t, "task-2", got[1].Step.ID)
+	assert.True(t, got[1].Step.Success)
+	assert.Equal(t, got[0].TaskID, got[1].TaskID)
+}
+
+func TestTaskRun_NotifyRunsFailedHooks(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	cfg := config.NewConfig()
+	cfg.Hooks.OnTaskFailed = []config.HookConfig{{Command: "cat >> " + events + "; echo >> " + events}}
+
+	completed := &taskRun{record: task.NewTaskExecution("goal"), config: cfg, logger: zap.NewNop()}
+	completed.record.SetStatus(task.TaskStatusCompleted)
+	completed.notify(context.Background())
+	assert.NoFileExists(t, events)
+
+	failed := &taskRun{record: task.NewTaskExecution("goal"), config: cfg, logger: zap.NewNop()}
+	failed.record.Error = "planning failed"
+	failed.record.SetStatus(task.TaskStatusFailed)
+	failed.notify(context.Background())
+	got := readHookEvents(t, events)
+	require.Len(t, got, 1)
+	assert.Equal(t, hooks.EventTaskFailed, got[0].Event)
+	assert.Equal(t, "failed", got[0].Status)
+	assert.Equal(t, "planning failed", got[0].Error)
+}
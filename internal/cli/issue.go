@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/github"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// Metadata keys linking a task to the GitHub issue it was planned from
+const (
+	metadataGitHubIssue      = "github_issue"
+	metadataGitHubIssueURL   = "github_issue_url"
+	metadataGitHubCommentURL = "github_comment_url"
+)
+
+// githubClient creates a GitHub API client, preferring GITHUB_TOKEN over the configured token
+func githubClient(cfg *config.Config) *github.Client {
+	token := cfg.GitHub.Token
+	if envToken := os.Getenv("GITHUB_TOKEN"); envToken != "" {
+		token = envToken
+	}
+	return github.NewClient(cfg.GitHub.BaseURL(), token)
+}
+
+// fetchIssue fetches the issue a goal is planned from
+func fetchIssue(ctx context.Context, client *github.Client, ref string) (*github.Issue, error) {
+	issueRef, err := github.ParseIssueRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	return client.Issue(ctx, issueRef)
+}
+
+// issueGoal returns the goal of a task planned from an issue
+func issueGoal(issue *github.Issue) string {
+	return fmt.Sprintf("%s: %s", issue.Ref, strings.TrimSpace(issue.Title))
+}
+
+// linkIssue records the issue a task was planned from in its metadata
+func linkIssue(record *task.TaskExecution, issue *github.Issue) {
+	record.Metadata[metadataGitHubIssue] = issue.Ref.String()
+	if issue.URL != "" {
+		record.Metadata[metadataGitHubIssueURL] = issue.URL
+	}
+}
+
+// commentPlan posts a summary of the task's plan on the issue it was planned from. Failing to
+// comment is logged and does not fail the task.
+func (r *taskRun) commentPlan(ctx context.Context, client *github.Client, issue *github.Issue, plan *captain.ExecutionPlan) {
+	url, err := client.Comment(ctx, issue.Ref, planComment(r.record.ID, plan))
+	if err != nil {
+		r.logger.Warn("Failed to comment plan on issue", zap.String("issue", issue.Ref.String()), zap.Error(err))
+		r.record.AddLog(task.LogLevelWarn, "Failed to comment plan on "+issue.Ref.String()+": "+err.Error())
+		fmt.Fprintf(r.out, "Warning: could not comment on %s: %v\n", issue.Ref, err)
+		return
+	}
+	r.record.Metadata[metadataGitHubCommentURL] = url
+	r.record.AddLog(task.LogLevelInfo, "Commented plan on "+issue.Ref.String())
+	saveTask(r.storage, r.record, r.logger)
+	fmt.Fprintf(r.out, "Commented plan on %s: %s\n", issue.Ref, url)
+}
+
+// planComment renders the Markdown comment summarizing a plan for its issue
+func planComment(taskID string, plan *captain.ExecutionPlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Planned as capn task `%s` (%d steps, %s strategy", taskID, len(plan.Tasks), plan.Strategy.Type)
+	if plan.Timeline.EstimatedDuration > 0 {
+		fmt.Fprintf(&b, ", estimated %s", plan.Timeline.EstimatedDuration)
+	}
+	b.WriteString(").\n\n")
+	for i, step := range plan.Tasks {
+		fmt.Fprintf(&b, "%d. **%s** %v", i+1, step.Type, step.Payload["description"])
+		if len(step.Dependencies) > 0 {
+			fmt.Fprintf(&b, " (after %s)", strings.Join(step.Dependencies, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if reasoning := strings.TrimSpace(plan.Strategy.Description); reasoning != "" {
+		fmt.Fprintf(&b, "\n%s\n", reasoning)
+	}
+	return b.String()
+}
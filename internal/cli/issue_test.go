@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// issuePlanJSON is the plan the fake Ollama server returns for every goal
+const issuePlanJSON = `{
+	"tasks": [
+		{"id": "task-1", "type": "analysis", "priority": "high", "description": "Profile the health endpoint", "dependencies": []},
+		{"id": "task-2", "type": "execution", "priority": "medium", "description": "Add a timeout to the health check", "dependencies": ["task-1"]}
+	],
+	"strategy": "sequential",
+	"estimated_duration": "20m",
+	"reasoning": "Find the slow dependency before changing the check"
+}`
+
+// issueServer fakes the GitHub API and an Ollama server, recording planning prompts and comments
+func issueServer(t *testing.T, commentStatus int) (*httptest.Server, *[]string, *[]string) {
+	t.Helper()
+	var prompts, comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/api/issues/12", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer ghp_env", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"title": "Health check times out", "body": "The /health endpoint hangs under load.",
+			"html_url": "https://github.com/acme/api/issues/12", "user": {"login": "sam"}}`))
+	})
+	mux.HandleFunc("GET /repos/acme/api/issues/12/comments", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"body": "Only on staging.", "user": {"login": "alex"}}]`))
+	})
+	mux.HandleFunc("POST /repos/acme/api/issues/12/comments", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Body string `json:"body"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		comments = append(comments, body.Body)
+		w.WriteHeader(commentStatus)
+		_, _ = w.Write([]byte(`{"html_url": "https://github.com/acme/api/issues/12#issuecomment-9", "message": "Resource not accessible"}`))
+	})
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		for _, message := range body.Messages {
+			prompts = append(prompts, message.Content)
+		}
+		reply, _ := json.Marshal(map[string]any{"message": map[string]string{"role": "assistant", "content": issuePlanJSON}, "done": true})
+		_, _ = w.Write(reply)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &prompts, &comments
+}
+
+func TestExecuteCmd_FromIssue(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("GITHUB_TOKEN", "ghp_env")
+	server, prompts, comments := issueServer(t, http.StatusCreated)
+	path := writeDoctorConfig(t, "llm:\n  providers:\n    - type: ollama\n      model: llama3\n      base_url: "+server.URL+
+		"\ngithub:\n  token: ghp_config\n  api_url: "+server.URL+"\n")
+
+	out, err := runCLI(t, "--config", path, "execute", "--plan-only", "--no-clarify", "--from-issue", "acme/api#12", "--comment-plan")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Goal: acme/api#12: Health check times out")
+	assert.Contains(t, out, "Commented plan on acme/api#12: https://github.com/acme/api/issues/12#issuecomment-9")
+
+	// The issue body and comments reach the planner as background
+	assert.Contains(t, strings.Join(*prompts, "\n"), "Background for the goal:\nGitHub issue acme/api#12: Health check times out")
+	assert.Contains(t, strings.Join(*prompts, "\n"), "alex wrote:\nOnly on staging.")
+
+	require.Len(t, *comments, 1)
+	assert.Contains(t, (*comments)[0], "(2 steps, sequential strategy, estimated 20m0s)")
+	assert.Contains(t, (*comments)[0], "2. **execution** Add a timeout to the health check (after task-1)")
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	records, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "acme/api#12", records[0].Metadata["github_issue"])
+	assert.Equal(t, "https://github.com/acme/api/issues/12", records[0].Metadata["github_issue_url"])
+	assert.Equal(t, "https://github.com/acme/api/issues/12#issuecomment-9", records[0].Metadata["github_comment_url"])
+}
+
+func TestExecuteCmd_FromIssueCommentFails(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("GITHUB_TOKEN", "ghp_env")
+	server, _, comments := issueServer(t, http.StatusForbidden)
+	path := writeDoctorConfig(t, "llm:\n  providers:\n    - type: ollama\n      model: llama3\n      base_url: "+server.URL+
+		"\ngithub:\n  api_url: "+server.URL+"\n")
+
+	out, err := runCLI(t, "--config", path, "execute", "--plan-only", "--no-clarify", "--from-issue", "https://github.com/acme/api/issues/12", "--comment-plan")
+	require.NoError(t, err, out)
+	assert.Len(t, *comments, 1)
+	assert.Contains(t, out, "Warning: could not comment on acme/api#12: failed to comment on issue acme/api#12: GitHub returned 403 Forbidden: Resource not accessible")
+	assert.Contains(t, out, "=== Execution Plan ===")
+}
+
+func TestExecuteCmd_FromIssueErrors(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	_, err := runCLI(t, "execute", "--from-issue", "acme/api#12", "a goal")
+	assert.EqualError(t, err, "cannot combine a goal, --template or --from-plan with --from-issue")
+
+	_, err = runCLI(t, "execute", "--comment-plan", "a goal")
+	assert.EqualError(t, err, "--comment-plan requires --from-issue")
+
+	_, err = runCLI(t, "execute", "--from-issue", "acme/api")
+	assert.EqualError(t, err, `invalid issue "acme/api" (expected owner/repo#number)`)
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// applyLanguage has the Captain write in the language given by --lang instead of the
+// captain.language setting
+func (e *ExecuteCmd) applyLanguage(cfg *config.Config) error {
+	if e.Lang == "" {
+		return nil
+	}
+	if err := config.ValidateLanguage(e.Lang); err != nil {
+		return fmt.Errorf("--lang: %w", err)
+	}
+	cfg.Captain.Language = e.Lang
+	return nil
+}
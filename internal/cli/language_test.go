@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCmd_Lang(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	_, err := runCLI(t, "execute", "--plan-only", "--lang", "French!", "deploy")
+	assert.EqualError(t, err, `--lang: invalid language "French!": use a language tag such as fr or pt-BR, or auto`)
+
+	out, err := runCLI(t, "execute", "--plan-only", "--lang", "fr", "deploy")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "Planning: deploy")
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// printLLMUsage lists the LLM calls behind a task's plan and steps, with the tokens they used
+// in total
+func printLLMUsage(out io.Writer, t *task.TaskExecution) {
+	type call struct {
+		source string
+		usage  agents.LLMUsage
+	}
+	var calls []call
+	if t.Plan != nil {
+		if usage, ok := captain.PlanLLM(t.Plan); ok {
+			calls = append(calls, call{"plan", usage})
+		}
+	}
+	for _, result := range t.Results {
+		if result.LLM != nil {
+			calls = append(calls, call{result.TaskID, *result.LLM})
+		}
+	}
+	if len(calls) == 0 {
+		fmt.Fprintf(out, "No LLM calls recorded for task %s.\n", t.ID)
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tPROVIDER\tMODEL\tTEMPERATURE\tTOKENS\tLATENCY")
+	var tokens int
+	var latency time.Duration
+	for _, c := range calls {
+		tokens += c.usage.Tokens
+		latency += c.usage.Latency
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.source, orDash(c.usage.Provider), orDash(c.usage.Model),
+			strconv.FormatFloat(c.usage.Temperature, 'g', -1, 64), formatTokens(c.usage.Tokens), c.usage.Latency.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "total\t\t\t\t%s\t%s\n", formatTokens(tokens), latency.Round(time.Millisecond))
+	w.Flush()
+}
+
+// formatTokens returns a token count, or "-" when none was reported
+func formatTokens(tokens int) string {
+	if tokens == 0 {
+		return "-"
+	}
+	return strconv.Itoa(tokens)
+}
+
+// orDash returns s, or "-" when it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
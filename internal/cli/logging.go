@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// createLogger creates the logger for capn's diagnostics from the logging options. Logs go to
+// standard error, or to the log file when one is set, never to standard output.
+func (c *CLI) createLogger() (*zap.Logger, error) {
+	settings := config.GlobalConfig{LogLevel: c.LogLevel, LogFormat: c.LogFormat, LogFile: c.LogFile}
+	if err := settings.ValidateLogging(); err != nil {
+		return nil, err
+	}
+
+	level, err := logLevel(&c.GlobalOptions, c.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	format := c.LogFormat
+	if format == "" {
+		format = "json"
+		if c.Verbose {
+			format = "console"
+		}
+	}
+	cfg := zap.NewProductionConfig()
+	if format == "console" {
+		cfg = zap.NewDevelopmentConfig()
+		// Colors only help on a terminal
+		if c.LogFile == "" {
+			cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+	}
+	cfg.Development = c.Verbose
+	cfg.Level = zap.NewAtomicLevelAt(level)
+	// The daemon changes the level when its configuration is reloaded
+	c.logLevel = cfg.Level
+	cfg.OutputPaths = []string{"stderr"}
+	if c.LogFile != "" {
+		cfg.OutputPaths = []string{c.LogFile}
+	}
+	cfg.ErrorOutputPaths = []string{"stderr"}
+
+	logger, err := cfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	return logger, nil
+}
+
+// logLevel returns the minimum level of diagnostic logs: the configured level, or the default
+// for the verbosity options
+func logLevel(globals *GlobalOptions, configured string) (zapcore.Level, error) {
+	level := zapcore.InfoLevel
+	switch {
+	case globals.Verbose:
+		level = zapcore.DebugLevel
+	case globals.Quiet:
+		level = zapcore.WarnLevel
+	}
+	if configured != "" {
+		if err := level.Set(configured); err != nil {
+			return level, fmt.Errorf("invalid log level %q: %w", configured, err)
+		}
+	}
+	return level, nil
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateLogger_File(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "json", want: `"level":"warn","ts":`},
+		{format: "console", want: "WARN\t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "capn.log")
+			c := NewCLI()
+			c.LogLevel, c.LogFormat, c.LogFile = "warn", tt.format, path
+
+			logger, err := c.createLogger()
+			require.NoError(t, err)
+			logger.Info("below the level")
+			logger.Warn("disk nearly full")
+			require.NoError(t, logger.Sync())
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), tt.want)
+			assert.Contains(t, string(data), "disk nearly full")
+			assert.NotContains(t, string(data), "below the level")
+		})
+	}
+}
+
+func TestCreateLogger_VerboseDefaultsToDebug(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capn.log")
+	c := NewCLI()
+	c.Verbose, c.LogFile = true, path
+
+	logger, err := c.createLogger()
+	require.NoError(t, err)
+	logger.Debug("planning details")
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "DEBUG\t")
+	assert.NotContains(t, string(data), "\x1b[", "log files are not colored")
+}
+
+func TestLoggingOptions(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	_, err := runCLI(t, "--log-level", "loud", "status")
+	assert.ErrorContains(t, err, `invalid log level "loud"`)
+
+	_, err = runCLI(t, "--log-file", "/dev/stdout", "status")
+	assert.ErrorContains(t, err, "log file cannot be standard output")
+
+	path := writeDoctorConfig(t, "global:\n  parallel: 5\n  log_format: yaml\n")
+	_, err = runCLI(t, "--config", path, "status")
+	assert.ErrorContains(t, err, `invalid log format "yaml"`)
+
+	// The config file supplies the log file when the flag is not given
+	logFile := filepath.Join(t.TempDir(), "capn.log")
+	path = writeDoctorConfig(t, "global:\n  parallel: 5\n  log_level: debug\n  log_file: "+logFile+"\n")
+	out, err := runCLI(t, "--config", path, "status")
+	require.NoError(t, err)
+	assert.NotContains(t, out, `"level"`, "diagnostics stay out of command output")
+	_, err = os.Stat(logFile)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksLogsCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+	te.AddMessageLog("task-1", "research-001", "file-001", "read go.mod", time.Now())
+	te.AddStepLog(task.LogLevelWarn, "task-2", "", "report is late")
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "tasks", "logs", te.ID)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "[task-1] analysis finished")
+	assert.Contains(t, lines[1], "[task-1] research-001 -> file-001: read go.mod")
+	assert.Contains(t, lines[2], "warn  [task-2] report is late")
+
+	out, err = runCLI(t, "tasks", "logs", te.ID, "--messages-only")
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+	assert.Contains(t, out, "research-001 -> file-001: read go.mod")
+
+	out, err = runCLI(t, "tasks", "logs", te.ID, "--step", "task-2")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "read go.mod")
+	assert.Contains(t, out, "report is late")
+
+	out, err = runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "research-001 -> file-001: read go.mod", "show interleaves messages too")
+}
+
+func TestTasksLogsCmd_NoMessages(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "tasks", "logs", te.ID, "--messages-only")
+	require.NoError(t, err)
+	assert.Equal(t, "No agent messages recorded for task "+te.ID+".\n", out)
+}
+
+func TestTasksLogsCmd_StepOutput(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusRunning)
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	recorder := task.NewOutputRecorder(storage, te)
+	for _, line := range []string{"ok  pkg/a", "ok  pkg/b", "ok  pkg/c"} {
+		recorder.Record("task-2", "file-001", agents.OutputStdout, line)
+	}
+	recorder.Record("task-2", "file-001", agents.OutputStderr, "warning: pkg/d has no tests")
+	recorder.Flush()
+
+	out, err := runCLI(t, "tasks", "logs", te.ID, "--step", "task-2", "--stream", "stderr")
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+	assert.Contains(t, out, "info  [task-2] stderr: warning: pkg/d has no tests")
+
+	// Following picks up output streamed until the task finishes
+	clock := useFakeClock(t)
+	go func() {
+		clock.BlockUntil(1)
+		recorder.Record("task-2", "file-001", agents.OutputStdout, "FAIL pkg/e")
+		te.SetStatus(task.TaskStatusFailed)
+		recorder.Flush()
+		clock.Advance(followInterval)
+	}()
+	out, err = runCLI(t, "tasks", "logs", te.ID, "--step", "task-2", "--stream", "stdout", "--tail", "2", "--follow")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3, out)
+	assert.Contains(t, lines[0], "stdout: ok  pkg/b")
+	assert.Contains(t, lines[1], "stdout: ok  pkg/c")
+	assert.Contains(t, lines[2], "stdout: FAIL pkg/e")
+}
+
+func TestOutputOptions(t *testing.T) {
+	logs := config.LogIngestConfig{BufferSize: 100, BatchSize: 10, FlushInterval: 2 * time.Second, DebugSample: 5}
+	te := task.NewTaskExecution("build")
+	assert.Equal(t, task.OutputOptions{BufferSize: 100, BatchSize: 10, SaveInterval: 2 * time.Second, DebugSample: 5}, outputOptions(logs, te))
+
+	te.Metadata[task.MetadataLogDebugSample] = "50"
+	assert.Equal(t, 50, outputOptions(logs, te).DebugSample, "--log-sample overrides the configured sampling")
+}
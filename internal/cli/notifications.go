@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/hooks"
+	"github.com/iainlowe/capn/internal/notify"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// NotificationsCmd groups the notification commands
+type NotificationsCmd struct {
+	List  NotificationsListCmd  `cmd:"" help:"List notification channels and the tasks waiting for their digests"`
+	Flush NotificationsFlushCmd `cmd:"" help:"Send digests whose window has passed"`
+}
+
+// NotificationsListCmd represents the notifications list command
+type NotificationsListCmd struct{}
+
+// Help returns detailed help for the notifications list command
+func (l *NotificationsListCmd) Help() string {
+	return `List the channels configured under notifications.channels. Channels without
+a digest window are told about each task as it finishes; digest channels
+collect finished tasks and send one summary per window.
+
+Examples:
+
+    capn notifications list`
+}
+
+func (l *NotificationsListCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	listing := Listing{Columns: []string{"CHANNEL", "TYPE", "ON", "DELIVERY"}, Empty: "No notification channels configured."}
+	if len(config.Notifications.Channels) == 0 {
+		return newPresenter(out, globals).List(listing)
+	}
+	dispatcher, err := notify.NewDispatcher(config.Notifications, config.NotificationsDir())
+	if err != nil {
+		return err
+	}
+	pending, err := dispatcher.Pending()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range config.Notifications.Channels {
+		delivery := "each task"
+		if c.Digest.Window > 0 {
+			delivery = fmt.Sprintf("digest every %s, %d pending", c.Digest.Window, pending[c.Name])
+			if c.Digest.Summarize {
+				delivery += ", summarized"
+			}
+		}
+		listing.Rows = append(listing.Rows, []string{c.Name, string(c.Type), strings.Join(channelOutcomes(c), ","), delivery})
+	}
+	return newPresenter(out, globals).List(listing)
+}
+
+// channelOutcomes returns the task outcomes a channel is notified of
+func channelOutcomes(c config.NotificationChannelConfig) []string {
+	if len(c.On) == 0 {
+		return config.DefaultNotificationOutcomes
+	}
+	return c.On
+}
+
+// NotificationsFlushCmd represents the notifications flush command
+type NotificationsFlushCmd struct {
+	All bool `help:"Send every pending digest, even if its window has not passed"`
+}
+
+// Help returns detailed help for the notifications flush command
+func (f *NotificationsFlushCmd) Help() string {
+	return `Send the digests whose window has passed. Digests are also flushed when a task
+finishes and, while it runs, by "capn daemon"; run this from cron on machines
+without a daemon.
+
+Examples:
+
+    capn notifications flush
+    capn notifications flush --all`
+}
+
+func (f *NotificationsFlushCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	dispatcher, err := newDispatcher(config, nil, logger)
+	if err != nil || dispatcher == nil {
+		return err
+	}
+	if err := dispatcher.Flush(ctx, f.All); err != nil {
+		return fmt.Errorf("failed to send digests: %w", err)
+	}
+	notef(out, globals, "Digests sent.")
+	return nil
+}
+
+// newDispatcher creates the notification dispatcher for the configured channels, or nil
+// when there are none. Digest summaries use cap, or a captain created when one is needed.
+func newDispatcher(cfg *config.Config, cap *captain.Captain, logger *zap.Logger) (*notify.Dispatcher, error) {
+	if len(cfg.Notifications.Channels) == 0 {
+		return nil, nil
+	}
+	dispatcher, err := notify.NewDispatcher(cfg.Notifications, cfg.NotificationsDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up notifications: %w", err)
+	}
+	if llmConfigured(cfg) {
+		dispatcher.SetSummarizer(func(ctx context.Context, digest string) (string, error) {
+			summarizer := cap
+			if summarizer == nil {
+				created, err := newCaptain(cfg)
+				if err != nil {
+					return "", err
+				}
+				defer created.Stop()
+				summarizer = created
+			}
+			summary, err := summarizer.SummarizeDigest(ctx, digest)
+			if err != nil {
+				logger.Warn("Failed to summarize digest", zap.Error(err))
+			}
+			return summary, err
+		})
+	}
+	return dispatcher, nil
+}
+
+// notify tells the notification channels the task finished, runs the on_task_failed hooks
+// if it failed and sends digests that are due. Notification failures are logged rather than
+// failing the run.
+func (r *taskRun) notify(ctx context.Context) {
+	// Interrupted runs still report that they were cancelled
+	ctx = context.WithoutCancel(ctx)
+	if r.record.Status == task.TaskStatusFailed {
+		r.hook(ctx, hooks.EventTaskFailed, nil)
+	}
+	dispatcher, err := newDispatcher(r.config, r.captain, r.logger)
+	if err != nil {
+		r.logger.Warn("Failed to send notifications", zap.Error(err))
+		return
+	}
+	if dispatcher == nil {
+		return
+	}
+	if err := dispatcher.TaskFinished(ctx, r.record); err != nil {
+		r.logger.Warn("Failed to send notifications", zap.String("task_id", r.record.ID), zap.Error(err))
+	}
+	if err := dispatcher.Flush(ctx, false); err != nil {
+		r.logger.Warn("Failed to send digests", zap.Error(err))
+	}
+}
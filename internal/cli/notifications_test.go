@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/notify"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestNotificationsCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+
+	var received []notify.Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notify.Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	out, err := runCLI(t, "notifications", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No notification channels configured.")
+
+	path := writeDoctorConfig(t, "notifications:\n  channels:\n"+
+		"    - name: ops\n      type: webhook\n      url: "+server.URL+"\n      on: [failed]\n"+
+		"    - name: daily\n      type: webhook\n      url: "+server.URL+"\n      digest:\n        window: 24h\n        summarize: true\n")
+
+	// Queue a finished task for the daily digest as a run would
+	channels := config.NotificationsConfig{Channels: []config.NotificationChannelConfig{
+		{Name: "daily", Type: config.NotificationChannelWebhook, URL: server.URL, Digest: config.DigestConfig{Window: 24 * time.Hour}},
+	}}
+	dispatcher, err := notify.NewDispatcher(channels, config.NewConfig().NotificationsDir())
+	require.NoError(t, err)
+	record := seedTask(t, task.TaskStatusCompleted)
+	require.NoError(t, dispatcher.TaskFinished(context.Background(), record))
+
+	out, err = runCLI(t, "notifications", "list", "--config", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "ops      webhook  failed            each task")
+	assert.Contains(t, out, "daily    webhook  completed,failed  digest every 24h0m0s, 1 pending, summarized")
+
+	_, err = runCLI(t, "notifications", "flush", "--config", path)
+	require.NoError(t, err)
+	assert.Empty(t, received, "the digest window has not passed")
+
+	_, err = runCLI(t, "notifications", "flush", "--all", "--config", path)
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, "daily", received[0].Channel)
+	assert.True(t, received[0].Digest)
+	assert.Equal(t, record.ID, received[0].Tasks[0].TaskID)
+
+	out, err = runCLI(t, "notifications", "list", "--config", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "digest every 24h0m0s, 0 pending")
+}
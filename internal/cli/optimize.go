@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// optimize replaces the task's plan with its form optimized for the strategy, recording a summary of the
+// changes in the plan's metadata, the full diff as a planner event and, with --verbose, printing how the
+// two plans compare
+func (r *taskRun) optimize(plan *captain.ExecutionPlan, strategy captain.OptimizationStrategy, globals *GlobalOptions) (*captain.ExecutionPlan, error) {
+	optimized, err := captain.OptimizePlan(plan, captain.OptimizationOptions{Strategy: strategy})
+	if err != nil {
+		failTask(r.storage, r.record, err, r.logger)
+		return nil, fmt.Errorf("failed to optimize plan: %w", err)
+	}
+	diff, err := captain.ComparePlans(plan, optimized, captain.SimulationOptions{MaxParallel: globals.Parallel})
+	if err != nil {
+		failTask(r.storage, r.record, err, r.logger)
+		return nil, fmt.Errorf("failed to compare optimized plan: %w", err)
+	}
+	optimized.Metadata[captain.MetadataOptimization] = diff.Summary()
+	r.record.Plan = optimized
+	r.record.AddLog(task.LogLevelInfo, "Plan optimized: "+diff.Summary())
+	var rendered strings.Builder
+	printPlanDiff(&rendered, diff)
+	r.record.PlannerEvents = append(r.record.PlannerEvents, captain.PlannerEvent{
+		Kind:    captain.PlannerEventOptimization,
+		Goal:    optimized.Goal,
+		PlanID:  optimized.ID,
+		Summary: diff.Summary(),
+		Content: strings.TrimSpace(strings.TrimPrefix(rendered.String(), "=== Plan Optimization ===\n")),
+		Time:    clock.Now(),
+	})
+	if globals.Verbose {
+		printPlanDiff(r.out, diff)
+	}
+	return optimized, nil
+}
+
+// printPlanDiff renders how an optimized plan differs from the original
+func printPlanDiff(out io.Writer, diff *captain.PlanDiff) {
+	fmt.Fprintf(out, "=== Plan Optimization ===\n")
+	if !diff.Changed() {
+		fmt.Fprintf(out, "No changes: the plan is already optimal.\n\n")
+		return
+	}
+	fmt.Fprintf(out, "Tasks: %d -> %d\n", diff.OriginalTasks, diff.OptimizedTasks)
+	if len(diff.RemovedTasks) > 0 {
+		fmt.Fprintf(out, "  Removed: %s\n", strings.Join(diff.RemovedTasks, ", "))
+	}
+	if len(diff.AddedTasks) > 0 {
+		fmt.Fprintf(out, "  Added: %s\n", strings.Join(diff.AddedTasks, ", "))
+	}
+	if len(diff.RemovedDependencies)+len(diff.AddedDependencies) > 0 {
+		fmt.Fprintf(out, "Dependencies:\n")
+		for _, change := range diff.RemovedDependencies {
+			fmt.Fprintf(out, "  - %s no longer depends on %s\n", change.TaskID, change.Dependency)
+		}
+		for _, change := range diff.AddedDependencies {
+			fmt.Fprintf(out, "  + %s depends on %s\n", change.TaskID, change.Dependency)
+		}
+	}
+	if len(diff.ChangedCommands) > 0 {
+		fmt.Fprintf(out, "Commands:\n")
+		for _, change := range diff.ChangedCommands {
+			fmt.Fprintf(out, "  %s: %q -> %q\n", change.TaskID, change.Before, change.After)
+		}
+	}
+	if diff.OriginalStrategy != diff.OptimizedStrategy {
+		fmt.Fprintf(out, "Strategy: %s -> %s\n", diff.OriginalStrategy, diff.OptimizedStrategy)
+	}
+	fmt.Fprintf(out, "Estimated Duration: %s -> %s\n", diff.OriginalEstimate, diff.OptimizedEstimate)
+	fmt.Fprintf(out, "Simulated Duration: %s -> %s\n\n", diff.OriginalDuration, diff.OptimizedDuration)
+}
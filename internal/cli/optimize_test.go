@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+const optimizablePlan = `goal: ship the release
+timeline:
+  estimated_duration: 30m
+tasks:
+  - id: build
+    type: execution
+    payload:
+      description: build the binaries
+      command: make build
+  - id: test
+    type: validation
+    dependencies: [build]
+    payload:
+      description: run the test suite
+      command: make test
+  - id: retest
+    type: validation
+    dependencies: [build]
+    payload:
+      description: run the test suite again
+      command: make test
+  - id: publish
+    type: execution
+    dependencies: [build, test, retest]
+    payload:
+      description: publish the release
+`
+
+func TestExecuteCmd_Optimize(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(optimizablePlan), 0o644))
+
+	out, err := runCLI(t, "--verbose", "execute", "--simulate", "--optimize", "--from-plan", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "=== Plan Optimization ===\nTasks: 4 -> 3\n  Removed: retest\n")
+	assert.Contains(t, out, "  - publish no longer depends on build\n  - publish no longer depends on retest\n")
+	assert.Contains(t, out, "Estimated Duration: 30m0s -> 22m30s\n")
+	assert.Contains(t, out, "Simulated 3 steps in 22m30s")
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.NotNil(t, tasks[0].Plan)
+	assert.Len(t, tasks[0].Plan.Tasks, 3)
+	assert.Contains(t, tasks[0].Plan.Metadata[captain.MetadataOptimization], "tasks 4 -> 3, 2 dependencies removed")
+	require.Len(t, tasks[0].PlannerEvents, 1)
+	assert.Equal(t, captain.PlannerEventOptimization, tasks[0].PlannerEvents[0].Kind)
+	assert.Contains(t, tasks[0].PlannerEvents[0].Content, "Tasks: 4 -> 3\n  Removed: retest\n")
+
+	out, err = runCLI(t, "execute", "--simulate", "--optimize", "--from-plan", path)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "=== Plan Optimization ===", "the comparison is only printed with --verbose")
+}
+
+func TestExecuteCmd_OptimizeStrategy(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(optimizablePlan), 0o644))
+
+	out, err := runCLI(t, "--verbose", "execute", "--simulate", "--optimize", "--strategy", "safety", "--from-plan", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Tasks: 4 -> 4\n", "validation steps are kept")
+	assert.Contains(t, out, "  - publish no longer depends on build\n")
+
+	out, err = runCLI(t, "--verbose", "execute", "--simulate", "--optimize", "--strategy", "parallelism", "--from-plan", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Strategy: sequential -> parallel\n")
+
+	_, err = runCLI(t, "execute", "--strategy", "cost", "--from-plan", path)
+	assert.EqualError(t, err, "--strategy requires --optimize")
+}
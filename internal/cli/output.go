@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// streamStepOutput records the command output of the task's steps in its log as they run, so
+// "capn tasks logs --follow" shows it before the steps finish, batched and sampled as
+// execution.logs and the task's --log-sample set. Failing to save is logged.
+func streamStepOutput(cap *captain.Captain, cfg *config.Config, storage task.TaskStorage, record *task.TaskExecution, logger *zap.Logger) *task.OutputRecorder {
+	recorder := task.NewOutputRecorder(storage, record)
+	recorder.SetOptions(outputOptions(cfg.Execution.Logs, record))
+	recorder.SetErrorHandler(func(err error) {
+		logger.Warn("Failed to save step output", zap.String("task_id", record.ID), zap.Error(err))
+	})
+	cap.SetOutputObserver(func(step captain.Task, agentID string, stream agents.OutputStream, line string) {
+		recorder.Record(step.ID, agentID, stream, line)
+	})
+	return recorder
+}
+
+// reportFanOut records each item of a fan-out step in the task's log as it finishes, and
+// prints it, so progress through the items shows while the step still runs
+func reportFanOut(cap *captain.Captain, recorder *task.OutputRecorder, out io.Writer) {
+	cap.SetFanOutObserver(func(step captain.Task, item captain.FanOutItem, done, total int) {
+		level := task.LogLevelInfo
+		message := fmt.Sprintf("Item %s %s (%d of %d finished)", item.Item, item.Status, done, total)
+		if !item.Success {
+			level = task.LogLevelWarn
+			message += ": " + item.Error
+		}
+		recorder.Log(level, step.ID, item.AgentID, message)
+		fmt.Fprintf(out, "  %s: %s\n", step.ID, message)
+	})
+}
+
+// outputOptions returns the log ingestion options of a task, whose recorded --log-sample
+// overrides execution.logs.debug_sample
+func outputOptions(logs config.LogIngestConfig, record *task.TaskExecution) task.OutputOptions {
+	options := task.OutputOptions{
+		BufferSize:   logs.BufferSize,
+		BatchSize:    logs.BatchSize,
+		SaveInterval: logs.FlushInterval,
+		DebugSample:  logs.DebugSample,
+	}
+	if sample, err := strconv.Atoi(record.Metadata[task.MetadataLogDebugSample]); err == nil {
+		options.DebugSample = sample
+	}
+	return options
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// PlansDebugCmd represents the plans debug command
+type PlansDebugCmd struct {
+	ID   string   `arg:"" name:"plan-id" help:"Plan whose planning to show, or the task it was planned for"`
+	Kind []string `help:"Only show events of these kinds" enum:"prompt,retry,response,extraction,validation,optimization" sep:","`
+	Full bool     `help:"Show each event's prompt, raw response, JSON or diff in full"`
+}
+
+// Help returns detailed help for the plans debug command
+func (d *PlansDebugCmd) Help() string {
+	return `Show how the Captain planned a task's goal, one event per step: the prompt
+sent to the LLM, providers that failed before another answered, the raw
+response, the JSON extracted from it, whether the plan was accepted or why it
+was rejected, and the changes --optimize made. Rejected plans are recorded too,
+so a task whose planning failed can be looked up by its task ID.
+
+Use --full to show the prompt, response, JSON and diff of each event, and
+--output-format json to process the events with other tools.
+
+Examples:
+
+    capn plans debug task-1a2b3c4d
+    capn plans debug 3563d1d9-adb4-4cc7-a3ee-5548ec17b6b8 --full
+    capn plans debug task-1a2b3c4d --kind response,validation --full
+    capn --output-format json plans debug task-1a2b3c4d | jq '.[].summary'`
+}
+
+func (d *PlansDebugCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	record, err := findPlanningTask(storage, d.ID)
+	if err != nil {
+		return err
+	}
+	events := make([]captain.PlannerEvent, 0, len(record.PlannerEvents))
+	for _, event := range record.PlannerEvents {
+		if len(d.Kind) == 0 || slices.Contains(d.Kind, string(event.Kind)) {
+			events = append(events, event)
+		}
+	}
+
+	return newPresenter(out, globals).Show(events, func(w io.Writer) error {
+		fmt.Fprintf(w, "Planning of task %s", record.ID)
+		if record.Plan != nil {
+			fmt.Fprintf(w, " (plan %s)", record.Plan.ID)
+		}
+		fmt.Fprintf(w, "\nGoal: %s\n\n", record.Goal)
+		if len(events) == 0 {
+			fmt.Fprintf(w, "No planner events recorded.\n")
+			return nil
+		}
+		for _, event := range events {
+			fmt.Fprintln(w, formatPlannerEvent(event))
+			if d.Full && event.Content != "" {
+				fmt.Fprintf(w, "%s\n\n", indentLines(event.Content, "    "))
+			}
+		}
+		return nil
+	})
+}
+
+// findPlanningTask returns the task a goal was planned for, looked up by task ID or by the ID
+// of a plan the planner produced for it, accepted or not
+func findPlanningTask(storage task.TaskStorage, id string) (*task.TaskExecution, error) {
+	if t, err := storage.GetTask(id); err == nil {
+		return t, nil
+	}
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		if t.Plan != nil && t.Plan.ID == id {
+			return t, nil
+		}
+		for _, event := range t.PlannerEvents {
+			if event.PlanID == id {
+				return t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no task or plan found: %s", id)
+}
+
+// formatPlannerEvent renders a planner event on one line, naming the provider and model of a response
+func formatPlannerEvent(event captain.PlannerEvent) string {
+	line := fmt.Sprintf("%s  %-12s  %-10s  %s", event.Time.Format("15:04:05.000"), event.Kind, event.Phase, event.Summary)
+	var source []string
+	for _, part := range []string{event.Provider, event.Model} {
+		if part != "" {
+			source = append(source, part)
+		}
+	}
+	if len(source) > 0 {
+		line += " (" + strings.Join(source, ", ") + ")"
+	}
+	return line
+}
+
+// indentLines prefixes every line of text with indent
+func indentLines(text, indent string) string {
+	return indent + strings.ReplaceAll(text, "\n", "\n"+indent)
+}
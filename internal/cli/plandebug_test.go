@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestPlansDebugCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	te.PlannerEvents = []captain.PlannerEvent{
+		{Kind: captain.PlannerEventPrompt, Goal: te.Goal, Phase: captain.PhasePlanning, Summary: "2 message(s), 40 characters",
+			Content: "--- system ---\nYou plan goals.", Time: at},
+		{Kind: captain.PlannerEventResponse, Goal: te.Goal, Phase: captain.PhasePlanning, Summary: "18 characters, 12 tokens",
+			Content: `{"tasks": [oops]}`, Provider: "openai", Model: "gpt-4o", Tokens: 12, Time: at},
+		{Kind: captain.PlannerEventValidation, Goal: te.Goal, Phase: captain.PhaseReplanning, PlanID: "plan-0",
+			Summary: "rejected: task task-2 depends on nonexistent task: task-9", Error: "task task-2 depends on nonexistent task: task-9", Time: at},
+	}
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "plans", "debug", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Planning of task "+te.ID+" (plan plan-1)\nGoal: analyze code quality\n\n")
+	assert.Contains(t, out, "09:30:00.000  prompt        planning    2 message(s), 40 characters\n")
+	assert.Contains(t, out, "09:30:00.000  response      planning    18 characters, 12 tokens (openai, gpt-4o)\n")
+	assert.Contains(t, out, "validation    replanning  rejected: task task-2 depends on nonexistent task: task-9\n")
+	assert.NotContains(t, out, "You plan goals.", "contents are shown with --full")
+
+	out, err = runCLI(t, "plans", "debug", "plan-0", "--kind", "response", "--full")
+	require.NoError(t, err)
+	assert.Contains(t, out, "(openai, gpt-4o)\n    {\"tasks\": [oops]}\n", "rejected plans find their task too")
+	assert.NotContains(t, out, "prompt")
+
+	out, err = runCLI(t, "--output-format", "json", "plans", "debug", te.ID)
+	require.NoError(t, err)
+	var events []captain.PlannerEvent
+	require.NoError(t, json.Unmarshal([]byte(out), &events))
+	require.Len(t, events, 3)
+	assert.Equal(t, "gpt-4o", events[1].Model)
+
+	bare := seedTask(t, task.TaskStatusFailed)
+	out, err = runCLI(t, "plans", "debug", bare.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "No planner events recorded.\n")
+
+	_, err = runCLI(t, "plans", "debug", "nope")
+	assert.EqualError(t, err, "no task or plan found: nope")
+}
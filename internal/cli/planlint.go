@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// PlansLintCmd represents the plans lint command
+type PlansLintCmd struct {
+	Plan            string        `arg:"" name:"plan" help:"YAML or JSON plan file, or the task or plan ID of a recorded plan"`
+	Dir             string        `help:"Directory the plan's relative paths are checked in (default: the working directory)" type:"existingdir" placeholder:"DIR"`
+	MaxCriticalPath time.Duration `name:"max-critical-path" help:"Warn when the steps that must run one after another are estimated to take longer than this" default:"30m" placeholder:"DURATION"`
+	Strict          bool          `help:"Fail on warnings as well as errors"`
+}
+
+// Help returns detailed help for the plans lint command
+func (l *PlansLintCmd) Help() string {
+	return `Check a plan for issues without running any of it. The plan is read from a
+YAML or JSON file, as "capn execute --from-plan" takes, or looked up among the
+recorded plans by task or plan ID. Each finding has a severity:
+
+    error    steps that can never run, because they depend on a step missing
+             from the plan, on a dependency cycle or on another step that
+             never runs, or because their condition reads a step they do not
+             wait for; and a critical path longer than the plan's max_runtime
+    warning  a critical path longer than --max-critical-path, execution steps
+             in a plan without any validation step, and relative paths in
+             commands that do not exist in --dir and that no earlier step
+             mentions
+    info     execution steps that no validation step runs after
+
+The critical path is the longest chain of steps that must run one after
+another, using each step's metadata.estimated_duration or its share of the
+plan's estimate. The command fails when errors are found, or with --strict
+when warnings are. Use --output-format json for the findings as data.
+
+Examples:
+
+    capn plans lint plan.yaml
+    capn plans lint task-1a2b3c4d --dir ~/src/service
+    capn plans lint plan.yaml --max-critical-path 10m --strict
+    capn --output-format json plans lint plan.yaml | jq '.findings[]'`
+}
+
+func (l *PlansLintCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	plan, err := l.load(config)
+	if err != nil {
+		return err
+	}
+	dir := l.Dir
+	if dir == "" {
+		if dir, err = os.Getwd(); err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+	}
+	report := captain.LintPlan(plan, captain.LintOptions{Workspace: dir, MaxCriticalPath: l.MaxCriticalPath})
+
+	err = newPresenter(out, globals).Show(report, func(w io.Writer) error {
+		printLintReport(w, plan, report)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	errors, warnings := report.Count(captain.LintError), report.Count(captain.LintWarning)
+	switch {
+	case errors > 0:
+		return fmt.Errorf("plan %s has %d lint error(s)", plan.ID, errors)
+	case l.Strict && warnings > 0:
+		return fmt.Errorf("plan %s has %d lint warning(s)", plan.ID, warnings)
+	}
+	return nil
+}
+
+// load reads the plan from a file when one exists at the argument, and otherwise from the
+// task history
+func (l *PlansLintCmd) load(config *config.Config) (*captain.ExecutionPlan, error) {
+	if info, err := os.Stat(l.Plan); err == nil && !info.IsDir() {
+		return loadPlanFile(l.Plan)
+	}
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return nil, err
+	}
+	return findPlan(storage, l.Plan)
+}
+
+// printLintReport lists the findings of a lint report, errors first
+func printLintReport(w io.Writer, plan *captain.ExecutionPlan, report *captain.LintReport) {
+	fmt.Fprintf(w, "Plan %s: %s\n", plan.ID, plan.Goal)
+	if len(report.CriticalPath) > 0 {
+		fmt.Fprintf(w, "Critical path: %s (%s)\n", strings.Join(report.CriticalPath, " -> "), report.CriticalPathDuration)
+	}
+	if len(report.Findings) == 0 {
+		fmt.Fprintf(w, "No issues found.\n")
+		return
+	}
+	fmt.Fprintln(w)
+	for _, finding := range report.Findings {
+		subject := finding.TaskID
+		if subject == "" {
+			subject = "plan"
+		}
+		fmt.Fprintf(w, "  %-8s %-14s %s: %s\n", finding.Severity, finding.Check, subject, finding.Message)
+	}
+	fmt.Fprintf(w, "\n%d error(s), %d warning(s), %d info\n",
+		report.Count(captain.LintError), report.Count(captain.LintWarning), report.Count(captain.LintInfo))
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestPlansLintCmd_File(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`id: plan-7
+goal: deploy the service
+tasks:
+  - id: build
+    type: execution
+    payload: {command: ./scripts/build.sh}
+  - id: deploy
+    type: execution
+    dependencies: [build, approve]
+`), 0o644))
+
+	out, err := runCLI(t, "plans", "lint", path, "--dir", dir)
+	assert.EqualError(t, err, "plan plan-7 has 1 lint error(s)")
+	assert.Contains(t, out, "Plan plan-7: deploy the service\nCritical path: build (1m0s)\n")
+	assert.Contains(t, out, "  error    unreachable    deploy: never runs: depends on approve, which is not in the plan\n")
+	assert.Contains(t, out, "  warning  validation     plan: 2 execution step(s) but no validation step checks their results\n")
+	assert.Contains(t, out, "  warning  missing-file   build: ./scripts/build.sh does not exist in "+dir+"\n")
+	assert.Contains(t, out, "1 error(s), 2 warning(s), 0 info\n")
+
+	out, err = runCLI(t, "--output-format", "json", "plans", "lint", path, "--dir", dir)
+	require.Error(t, err)
+	var report captain.LintReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, "plan-7", report.PlanID)
+	require.Len(t, report.Findings, 3)
+	assert.Equal(t, captain.LintError, report.Findings[0].Severity)
+	assert.Equal(t, "deploy", report.Findings[0].TaskID)
+}
+
+func TestPlansLintCmd_Recorded(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "plans", "lint", te.ID, "--dir", t.TempDir())
+	require.NoError(t, err)
+	assert.Contains(t, out, "Plan plan-1: analyze code quality\n")
+
+	_, err = runCLI(t, "plans", "lint", "nope")
+	assert.EqualError(t, err, "no task or plan found: nope")
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// PlansCmd groups the plan commands
+type PlansCmd struct {
+	Export PlansExportCmd `cmd:"" help:"Export a task's plan as YAML, JSON or a Graphviz graph"`
+	Debug  PlansDebugCmd  `cmd:"" help:"Show the prompts, responses and verdicts recorded while planning a task"`
+	Lint   PlansLintCmd   `cmd:"" help:"Check a plan file or recorded plan for issues without running it"`
+}
+
+// PlansExportCmd represents the plans export command
+type PlansExportCmd struct {
+	TaskID string `arg:"" name:"task-id" help:"Task whose plan to export, or the plan's own ID"`
+	Format string `help:"Export format: yaml, json or dot (default: json or yaml when --output-format is, otherwise yaml)" enum:",yaml,json,dot" default:"" short:"f"`
+	Output string `help:"Write to a file instead of stdout" short:"o" type:"path" placeholder:"FILE"`
+}
+
+// Help returns detailed help for the plans export command
+func (p *PlansExportCmd) Help() string {
+	return `Export the plan recorded for a task. YAML and JSON exports can be edited and
+run again with "capn execute --from-plan"; the dot format renders the
+dependency graph for Graphviz, outlining risky steps.
+
+Examples:
+
+    capn plans export task-1a2b3c4d > plan.yaml
+    capn execute --from-plan plan.yaml
+    capn plans export task-1a2b3c4d --format dot | dot -Tsvg -o plan.svg`
+}
+
+func (p *PlansExportCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	plan, err := findPlan(storage, p.TaskID)
+	if err != nil {
+		return err
+	}
+	format := p.Format
+	if format == "" {
+		format = string(captain.PlanFormatYAML)
+		if globals.OutputFormat == OutputJSON {
+			format = string(captain.PlanFormatJSON)
+		}
+	}
+	data, err := captain.ExportPlan(plan, captain.PlanFormat(format))
+	if err != nil {
+		return err
+	}
+
+	if p.Output == "" {
+		_, err = out.Write(data)
+		return err
+	}
+	if err := os.WriteFile(p.Output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	fmt.Fprintf(out, "Plan %s written to %s\n", plan.ID, p.Output)
+	return nil
+}
+
+// findPlan returns the plan recorded for a task, looked up by task ID or by plan ID
+func findPlan(storage task.TaskStorage, id string) (*captain.ExecutionPlan, error) {
+	if t, err := storage.GetTask(id); err == nil {
+		if t.Plan == nil {
+			return nil, fmt.Errorf("task %s has no plan", id)
+		}
+		return t.Plan, nil
+	}
+
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		if t.Plan != nil && t.Plan.ID == id {
+			return t.Plan, nil
+		}
+	}
+	return nil, fmt.Errorf("no task or plan found: %s", id)
+}
+
+// loadPlanFile reads a YAML or JSON plan file, choosing the format from its extension
+func loadPlanFile(path string) (*captain.ExecutionPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+	plan, err := captain.ImportPlan(data, captain.PlanFormatForPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// describeTaskEnvironment summarizes the workdir, shell, execution mode and environment variables a step requests
+func describeTaskEnvironment(step captain.Task) string {
+	var parts []string
+	if step.Execution != "" {
+		parts = append(parts, "runs on "+step.Execution)
+	}
+	if step.Workdir != "" {
+		parts = append(parts, "workdir "+step.Workdir)
+	}
+	if step.Shell != "" {
+		parts = append(parts, "shell "+step.Shell)
+	}
+	if len(step.Env) > 0 {
+		names := make([]string, 0, len(step.Env))
+		for name := range step.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts = append(parts, "env "+strings.Join(names, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeService summarizes the command, address and health check of a service step
+func describeService(step captain.Task) string {
+	command, _ := step.Payload["command"].(string)
+	description := fmt.Sprintf("keeps %q running until the plan ends", command)
+	if address, _ := step.Payload[captain.PayloadAddress].(string); address != "" {
+		description += "; at " + address
+	}
+	if check, _ := step.Payload[captain.PayloadHealthCheck].(string); check != "" {
+		description += "; healthy once " + check + " succeeds"
+	}
+	return description
+}
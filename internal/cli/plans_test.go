@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestPlansExportCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "plans", "export", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "id: plan-1\n")
+	assert.Contains(t, out, "- task-1\n")
+
+	out, err = runCLI(t, "plans", "export", "plan-1", "--format", "json")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"goal": "analyze code quality"`, "plans are also found by plan ID")
+
+	out, err = runCLI(t, "plans", "export", te.ID, "-f", "dot")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"task-1" -> "task-2";`)
+
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	out, err = runCLI(t, "plans", "export", te.ID, "-o", path)
+	require.NoError(t, err)
+	assert.Equal(t, "Plan plan-1 written to "+path+"\n", out)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "goal: analyze code quality")
+
+	_, err = runCLI(t, "plans", "export", "nope")
+	assert.EqualError(t, err, "no task or plan found: nope")
+}
+
+func TestExecuteCmd_FromPlan(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	original := seedTask(t, task.TaskStatusCompleted)
+
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	_, err := runCLI(t, "plans", "export", original.ID, "-o", path)
+	require.NoError(t, err)
+
+	_, err = runCLI(t, "execute", "--from-plan", path, "--approve-all")
+	require.NoError(t, err)
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	var record *task.TaskExecution
+	for _, t := range tasks {
+		if t.ID != original.ID {
+			record = t
+		}
+	}
+	require.NotNil(t, record)
+	assert.Equal(t, task.TaskStatusCompleted, record.Status)
+	assert.Equal(t, "analyze code quality", record.Goal, "the goal comes from the plan")
+	assert.Equal(t, "plan-1", record.Plan.ID)
+	assert.Equal(t, path, record.Metadata["plan_file"])
+	assert.Len(t, record.Results, 2)
+}
+
+func TestExecuteCmd_FromPlanWithoutLLM(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"goal": "ok", "tasks": [{"id": "a", "type": "analysis"}]}`), 0o644))
+
+	// The plan was made already, so running it needs no model
+	_, err := runCLI(t, "execute", "--from-plan", path, "--approve-all")
+	require.NoError(t, err)
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task.TaskStatusCompleted, tasks[0].Status)
+	assert.Len(t, tasks[0].Results, 1)
+}
+
+func TestExecuteCmd_FromPlanErrors(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	dir := t.TempDir()
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("goal: broken\ntasks:\n  - id: a\n    dependencies: [missing]\n"), 0o644))
+	valid := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"goal": "ok", "tasks": [{"id": "a", "type": "analysis"}]}`), 0o644))
+
+	_, err := runCLI(t, "execute", "--from-plan", valid, "a goal")
+	assert.EqualError(t, err, "cannot combine a goal or --template with --from-plan")
+
+	_, err = runCLI(t, "execute", "--from-plan", invalid)
+	assert.ErrorContains(t, err, "invalid plan: task a depends on nonexistent task: missing")
+}
+
+func TestExecuteCmd_FromPlanSandbox(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "workspace")
+
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("crew:\n  sandbox:\n    allowed_dirs: ["+allowed+"]\n    protected_env: [PATH]\n"), 0o644))
+	planFile := filepath.Join(dir, "plan.yaml")
+	require.NoError(t, os.WriteFile(planFile, []byte(`goal: build elsewhere
+tasks:
+  - id: build
+    type: execution
+    workdir: /etc
+    env:
+      PATH: /tmp/bin
+`), 0o644))
+
+	_, err := runCLI(t, "--config", configFile, "execute", "--from-plan", planFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sandbox: build: workdir /etc is outside the allowed directories")
+	assert.Contains(t, err.Error(), "sandbox: build: environment variable PATH is protected")
+}
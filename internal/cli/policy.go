@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// PolicyCmd groups the execution policy commands
+type PolicyCmd struct {
+	Test PolicyTestCmd `cmd:"" help:"Check a plan file against the workspace policy without running it"`
+}
+
+// PolicyTestCmd represents the policy test command
+type PolicyTestCmd struct {
+	Plan   string `arg:"" name:"plan" help:"YAML or JSON plan file to check" type:"existingfile"`
+	Policy string `help:"Policy file to check against instead of the workspace's capn-policy.yaml" type:"existingfile" placeholder:"FILE"`
+}
+
+// Help returns detailed help for the policy test command
+func (p *PolicyTestCmd) Help() string {
+	return `Check every step of a plan against the workspace's execution policy, the
+capn-policy.yaml file in the project root. The policy lists the agent types,
+commands, hosts and paths steps may touch:
+
+    agents:
+      deny: [shell]
+    commands:
+      allow: [go, git, make]
+      deny: [git push]
+    hosts:
+      allow: [proxy.golang.org, "*.github.com"]
+    paths:
+      deny: [/etc, .env]
+
+A target is rejected when it matches a deny entry, or when allow entries are
+listed and it matches none. Commands match on leading words, hosts exactly or
+by "*." suffix, and paths cover everything beneath them; relative paths are
+relative to the project root. Commands inside $(...), backticks and subshells
+are checked too, with wrappers such as sudo, env and timeout stripped and the
+program's directory dropped, so "deny: [rm]" also catches "sudo /bin/rm".
+
+"capn execute" applies the same policy before a plan is accepted and again
+before each step runs. This command exits non-zero when the plan breaks it.
+
+Examples:
+
+    capn policy test plan.json
+    capn policy test plan.yaml --policy ../shared/capn-policy.yaml`
+}
+
+func (p *PolicyTestCmd) Run(out io.Writer) error {
+	plan, err := loadPlanFile(p.Plan)
+	if err != nil {
+		return err
+	}
+	dir, err := workspaceDir()
+	if err != nil {
+		return err
+	}
+	source := filepath.Join(dir, captain.PolicyFile)
+	var policy *captain.Policy
+	if p.Policy != "" {
+		data, err := os.ReadFile(p.Policy)
+		if err != nil {
+			return fmt.Errorf("failed to read policy: %w", err)
+		}
+		if policy, err = captain.ParsePolicy(data, dir); err != nil {
+			return fmt.Errorf("%s: %w", p.Policy, err)
+		}
+		source = p.Policy
+	} else if policy, err = captain.LoadPolicy(dir); err != nil {
+		return err
+	}
+	if policy == nil {
+		fmt.Fprintf(out, "No policy found at %s; every plan is allowed.\n", source)
+		return nil
+	}
+
+	report := captain.NewRuleEngine(captain.PolicyRule{Policy: policy}).Check(plan)
+	if !report.Valid() {
+		printRuleViolations(out, report)
+		return fmt.Errorf("plan %s violates %s", plan.ID, source)
+	}
+	fmt.Fprintf(out, "Plan %s (%d steps) complies with %s\n", plan.ID, len(plan.Tasks), source)
+	return nil
+}
+
+// workspaceDir returns the project directory containing the working directory, where the
+// workspace policy lives
+func workspaceDir() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return task.DetectWorkspace(dir)
+}
+
+// loadWorkspacePolicy reads the workspace policy, returning nil when the workspace has none
+func loadWorkspacePolicy() (*captain.Policy, error) {
+	dir, err := workspaceDir()
+	if err != nil {
+		return nil, err
+	}
+	return captain.LoadPolicy(dir)
+}
+
+// recordPolicyBlocks records a step log for each step the workspace policy stopped from running
+func recordPolicyBlocks(record *task.TaskExecution, results []captain.Result) {
+	for _, result := range results {
+		if result.Metadata["policy"] == "denied" {
+			record.AddStepLog(task.LogLevelError, result.TaskID, "", "Policy violation: "+result.Error)
+		}
+	}
+}
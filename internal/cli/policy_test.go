@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+const policedPlan = `id: release-plan
+goal: ship the release
+tasks:
+  - id: test
+    type: validation
+    payload:
+      description: run the test suite
+      command: go test ./...
+  - id: publish
+    type: execution
+    dependencies: [test]
+    payload:
+      description: push the release tag
+      command: git push origin v1.0.0
+`
+
+// policyWorkspace changes into a new project directory holding the policy and plan files
+func policyWorkspace(t *testing.T, policy string) (dir, plan string) {
+	t.Helper()
+	dir = t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+	if policy != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "capn-policy.yaml"), []byte(policy), 0o644))
+	}
+	plan = filepath.Join(dir, "plan.yaml")
+	require.NoError(t, os.WriteFile(plan, []byte(policedPlan), 0o644))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir, plan
+}
+
+func TestPolicyTestCmd(t *testing.T) {
+	dir, plan := policyWorkspace(t, "commands:\n  deny: [git push]\n")
+
+	out, err := runCLI(t, "policy", "test", plan)
+	assert.EqualError(t, err, "plan release-plan violates "+filepath.Join(dir, "capn-policy.yaml"))
+	assert.Contains(t, out, "=== Plan Rule Violations ===\n")
+	assert.Contains(t, out, `  [policy] publish: command "git push origin v1.0.0" is denied by "git push"`)
+	assert.Contains(t, out, "adjust the config or capn-policy.yaml")
+
+	lenient := filepath.Join(t.TempDir(), "lenient.yaml")
+	require.NoError(t, os.WriteFile(lenient, []byte("commands:\n  allow: [go, git]\n"), 0o644))
+	out, err = runCLI(t, "policy", "test", plan, "--policy", lenient)
+	require.NoError(t, err)
+	assert.Contains(t, out, "(2 steps) complies with "+lenient)
+}
+
+func TestPolicyTestCmd_NoPolicy(t *testing.T) {
+	dir, plan := policyWorkspace(t, "")
+	out, err := runCLI(t, "policy", "test", plan)
+	require.NoError(t, err)
+	assert.Equal(t, "No policy found at "+filepath.Join(dir, "capn-policy.yaml")+"; every plan is allowed.\n", out)
+}
+
+func TestExecuteCmd_PolicyViolation(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	_, plan := policyWorkspace(t, "commands:\n  deny: [git push]\n")
+
+	out, err := runCLI(t, "execute", "--plan-only", "--from-plan", plan)
+	assert.ErrorContains(t, err, "invalid plan: plan violates 1 rule(s)")
+	assert.Contains(t, out, `[policy] publish: command "git push origin v1.0.0" is denied by "git push"`)
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task.TaskStatusFailed, tasks[0].Status)
+	var messages []string
+	for _, entry := range tasks[0].Logs {
+		messages = append(messages, entry.Message)
+	}
+	assert.Contains(t, messages, `Plan rule policy: publish: command "git push origin v1.0.0" is denied by "git push"`)
+}
@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kong"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Output formats chosen with --output-format
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// Presenter renders what a command shows in the output format chosen with --output-format,
+// so commands build their listings once and never format for a particular reader
+type Presenter interface {
+	// List renders a listing: aligned columns for people, or its records for scripts
+	List(listing Listing) error
+	// Show renders a single value: as text for people, or the value itself for scripts
+	Show(value any, text func(w io.Writer) error) error
+	// Notef writes a line meant for people, such as an empty-listing notice or a hint.
+	// Quiet, JSON and YAML output leave it out, so they hold only data.
+	Notef(format string, args ...any)
+}
+
+// Listing is what a command lists: rows of cells under column headers
+type Listing struct {
+	Columns []string
+	Rows    [][]string
+	// Records, when set, are encoded for JSON and YAML in place of the rows. Without them
+	// each row is encoded as an object keyed by its column names.
+	Records any
+	// Empty is noted in place of the table when there are no rows
+	Empty string
+}
+
+// newPresenter returns the presenter for the output format and verbosity of the invocation
+func newPresenter(out io.Writer, globals *GlobalOptions) Presenter {
+	format := OutputTable
+	if globals != nil && globals.OutputFormat != "" {
+		format = globals.OutputFormat
+	}
+	switch {
+	case format == OutputJSON:
+		return &jsonPresenter{out: out}
+	case format == OutputYAML:
+		return &yamlPresenter{out: out}
+	case globals.quiet():
+		return &quietPresenter{out: out}
+	default:
+		return &tablePresenter{out: out}
+	}
+}
+
+// structuredOutput is implemented by the commands that write what they show through a
+// Presenter, and so can show it as JSON or YAML
+type structuredOutput interface {
+	structuredOutput() bool
+}
+
+func (*StatusCmd) structuredOutput() bool            { return true }
+func (*TasksListCmd) structuredOutput() bool         { return true }
+func (*TasksShowCmd) structuredOutput() bool         { return true }
+func (*TasksArtifactsCmd) structuredOutput() bool    { return true }
+func (*PlansExportCmd) structuredOutput() bool       { return true }
+func (*PlansDebugCmd) structuredOutput() bool        { return true }
+func (*PlansLintCmd) structuredOutput() bool         { return true }
+func (*AgentsListCmd) structuredOutput() bool        { return true }
+func (*AgentsStatsCmd) structuredOutput() bool       { return true }
+func (*AgentsHistoryCmd) structuredOutput() bool     { return true }
+func (*AgentsSendCmd) structuredOutput() bool        { return true }
+func (*CtlStatusCmd) structuredOutput() bool         { return true }
+func (*CtlDrainCmd) structuredOutput() bool          { return true }
+func (*CtlPauseCmd) structuredOutput() bool          { return true }
+func (*CtlResumeCmd) structuredOutput() bool         { return true }
+func (*CtlReloadCmd) structuredOutput() bool         { return true }
+func (*TemplatesListCmd) structuredOutput() bool     { return true }
+func (*SecretsListCmd) structuredOutput() bool       { return true }
+func (*NotificationsListCmd) structuredOutput() bool { return true }
+func (*VersionCmd) structuredOutput() bool           { return true }
+func (c *ChatCmd) structuredOutput() bool            { return c.List }
+
+// The shell passes the format on to the commands it runs
+func (*ShellCmd) structuredOutput() bool { return true }
+
+// checkOutputFormat refuses an --output-format of json or yaml given on the command line to a
+// command that only writes text, rather than leave a script to parse that text. A format set
+// with CAPN_OUTPUT_FORMAT is a default, and is ignored by such commands.
+func checkOutputFormat(ctx *kong.Context, args []string, format string) error {
+	if format == "" || format == OutputTable || ctx.Selected() == nil {
+		return nil
+	}
+	explicit := slices.ContainsFunc(args, func(arg string) bool {
+		return arg == "--output-format" || strings.HasPrefix(arg, "--output-format=")
+	})
+	if !explicit {
+		return nil
+	}
+	if command, ok := ctx.Selected().Target.Addr().Interface().(structuredOutput); ok && command.structuredOutput() {
+		return nil
+	}
+	return fmt.Errorf("capn %s only writes text and cannot write --output-format %s", ctx.Selected().Path(), format)
+}
+
+// tablePresenter aligns listings in columns under a header row
+type tablePresenter struct {
+	out io.Writer
+}
+
+func (p *tablePresenter) List(listing Listing) error {
+	return writeTable(p.out, listing, true)
+}
+
+func (p *tablePresenter) Show(value any, text func(w io.Writer) error) error {
+	return text(p.out)
+}
+
+func (p *tablePresenter) Notef(format string, args ...any) {
+	fmt.Fprintf(p.out, format+"\n", args...)
+}
+
+// quietPresenter prints listings without their header and drops notes, for scripts
+// reading --quiet output line by line
+type quietPresenter struct {
+	out io.Writer
+}
+
+func (p *quietPresenter) List(listing Listing) error {
+	return writeTable(p.out, listing, false)
+}
+
+func (p *quietPresenter) Show(value any, text func(w io.Writer) error) error {
+	return text(p.out)
+}
+
+func (p *quietPresenter) Notef(string, ...any) {}
+
+// jsonPresenter encodes what commands show as indented JSON
+type jsonPresenter struct {
+	out io.Writer
+}
+
+func (p *jsonPresenter) List(listing Listing) error {
+	return p.encode(listing.records())
+}
+
+func (p *jsonPresenter) Show(value any, text func(w io.Writer) error) error {
+	return p.encode(value)
+}
+
+func (p *jsonPresenter) Notef(string, ...any) {}
+
+func (p *jsonPresenter) encode(value any) error {
+	encoder := json.NewEncoder(p.out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode output as JSON: %w", err)
+	}
+	return nil
+}
+
+// yamlPresenter encodes what commands show as YAML
+type yamlPresenter struct {
+	out io.Writer
+}
+
+func (p *yamlPresenter) List(listing Listing) error {
+	return p.encode(listing.records())
+}
+
+func (p *yamlPresenter) Show(value any, text func(w io.Writer) error) error {
+	return p.encode(value)
+}
+
+func (p *yamlPresenter) Notef(string, ...any) {}
+
+// encode writes the value as YAML with the field names and order it has in JSON, so both
+// formats describe it the same way
+func (p *yamlPresenter) encode(value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode output as YAML: %w", err)
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to encode output as YAML: %w", err)
+	}
+	blockStyle(&node)
+
+	encoder := yaml.NewEncoder(p.out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return fmt.Errorf("failed to encode output as YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+// blockStyle clears the JSON flow and quoting styles of a decoded document, leaving the
+// encoder to quote only the strings that need it
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// writeTable aligns a listing's rows in columns, starting with the header row if asked.
+// A listing with no rows is noted as empty instead, unless output is headerless.
+func writeTable(out io.Writer, listing Listing, header bool) error {
+	if len(listing.Rows) == 0 && listing.Empty != "" {
+		if header {
+			fmt.Fprintln(out, listing.Empty)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if header {
+		fmt.Fprintln(w, strings.Join(listing.Columns, "\t"))
+	}
+	for _, row := range listing.Rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// records returns what JSON and YAML encode for the listing, which is an empty list rather
+// than null when nothing is listed
+func (l Listing) records() any {
+	if l.Records != nil {
+		return l.Records
+	}
+	keys := make([]string, len(l.Columns))
+	for i, column := range l.Columns {
+		keys[i] = strings.ReplaceAll(strings.ToLower(column), " ", "_")
+	}
+	records := make([]listingRecord, len(l.Rows))
+	for i, row := range l.Rows {
+		records[i] = listingRecord{keys: keys, values: row}
+	}
+	return records
+}
+
+// listingRecord is a listing row keyed by column, keeping the columns' order when encoded
+type listingRecord struct {
+	keys   []string
+	values []string
+}
+
+func (r listingRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, _ := json.Marshal(r.value(i))
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// value returns the row's cell in column i, which is empty for a short row
+func (r listingRecord) value(i int) string {
+	if i < len(r.values) {
+		return r.values[i]
+	}
+	return ""
+}
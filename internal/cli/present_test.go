@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of renderer tests")
+
+// assertGolden compares output with testdata/<name>.golden, rewriting the file with -update
+func assertGolden(t *testing.T, name string, output []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, output, 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test with -update to create the golden file")
+	assert.Equal(t, string(want), string(output))
+}
+
+func TestPresenters(t *testing.T) {
+	type stats struct {
+		Total int      `json:"total"`
+		Busy  int      `json:"busy"`
+		IDs   []string `json:"ids,omitempty"`
+	}
+	listing := Listing{
+		Columns: []string{"ID", "STATUS", "STEP COUNT", "GOAL"},
+		Rows: [][]string{
+			{"task-1", "completed", "2", "fix the login page"},
+			{"task-22", "failed", "10", "yes: colons, and \"quotes\""},
+		},
+	}
+	typed := Listing{
+		Columns: []string{"TOTAL", "BUSY"},
+		Rows:    [][]string{{"3", "1"}},
+		Records: []stats{{Total: 3, Busy: 1, IDs: []string{"file-001"}}},
+	}
+
+	for _, format := range []string{"table", "quiet", "json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			globals := &GlobalOptions{OutputFormat: format}
+			if format == "quiet" {
+				globals = &GlobalOptions{OutputFormat: OutputTable, Quiet: true}
+			}
+			var out bytes.Buffer
+			for _, c := range []struct {
+				name   string
+				render func(p Presenter) error
+			}{
+				{"listing", func(p Presenter) error { return p.List(listing) }},
+				{"listing with records", func(p Presenter) error { return p.List(typed) }},
+				{"empty listing", func(p Presenter) error {
+					return p.List(Listing{Columns: []string{"ID"}, Empty: "No tasks found."})
+				}},
+				{"value", func(p Presenter) error {
+					return p.Show(stats{Total: 2}, func(w io.Writer) error {
+						_, err := fmt.Fprintln(w, "Agents: 2")
+						return err
+					})
+				}},
+				{"note", func(p Presenter) error {
+					p.Notef("More tasks may follow: capn tasks list --page-token %s", "abc")
+					return nil
+				}},
+			} {
+				fmt.Fprintf(&out, "# %s\n", c.name)
+				require.NoError(t, c.render(newPresenter(&out, globals)), c.name)
+			}
+
+			assertGolden(t, filepath.Join("present", format), out.Bytes())
+		})
+	}
+}
+
+func TestCLI_OutputFormat(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	seedTask(t, "completed")
+
+	out, err := runCLI(t, "--output-format", "json", "tasks", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"goal": "analyze code quality"`)
+	assert.Contains(t, out, `"steps_total": 2`)
+
+	out, err = runCLI(t, "--output-format", "yaml", "templates", "list")
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", out, "empty listings are empty lists, not notes")
+
+	_, err = runCLI(t, "--output-format", "xml", "tasks", "list")
+	assert.Error(t, err)
+}
+
+func TestCLI_OutputFormat_Show(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, "completed")
+
+	out, err := runCLI(t, "--output-format", "json", "status")
+	require.NoError(t, err)
+	var status statusView
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	require.Len(t, status.Groups, 1)
+	assert.Equal(t, "task", status.Groups[0].Kind)
+	assert.Equal(t, te.ID, status.Groups[0].Tasks[0].ID)
+	assert.Equal(t, "analyze code quality", status.Groups[0].Tasks[0].Goal)
+
+	out, err = runCLI(t, "--output-format", "json", "tasks", "show", te.ID)
+	require.NoError(t, err)
+	var shown task.TaskExecution
+	require.NoError(t, json.Unmarshal([]byte(out), &shown))
+	assert.Equal(t, te.ID, shown.ID)
+
+	out, err = runCLI(t, "--output-format=yaml", "version")
+	require.NoError(t, err)
+	assert.Contains(t, out, "go_version: go")
+
+	out, err = runCLI(t, "--output-format", "json", "plans", "export", te.ID)
+	require.NoError(t, err)
+	assert.True(t, json.Valid([]byte(out)), "plans export follows --output-format without --format")
+
+	_, err = runCLI(t, "--output-format", "json", "tasks", "logs", te.ID)
+	assert.EqualError(t, err, "capn tasks logs only writes text and cannot write --output-format json")
+
+	t.Setenv("CAPN_OUTPUT_FORMAT", "json")
+	out, err = runCLI(t, "tasks", "logs", te.ID)
+	require.NoError(t, err, "a format from the environment is only a default")
+	assert.False(t, json.Valid([]byte(out)))
+}
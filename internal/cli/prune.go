@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// TasksPruneCmd represents the tasks prune command
+type TasksPruneCmd struct {
+	Before        string   `help:"Only prune tasks that finished longer ago than this (e.g. 30d, 2w, 12h) or before this date (YYYY-MM-DD)" placeholder:"AGE|DATE"`
+	Status        []string `help:"Only prune tasks with these statuses (default: every finished status)" enum:"completed,failed,cancelled,interrupted" sep:","`
+	Artifacts     bool     `help:"Also delete the pruned tasks' artifacts"`
+	AllWorkspaces bool     `name:"all-workspaces" help:"Prune tasks from every workspace, not just the current one"`
+}
+
+// Help returns detailed help for the tasks prune command
+func (p *TasksPruneCmd) Help() string {
+	return `Delete finished tasks from the task history. Tasks still in flight are never
+pruned, nor are finished tasks that a queued task is waiting for with --after.
+
+The artifacts of pruned tasks are kept on disk unless --artifacts is given.
+With --dry-run, the tasks are listed only.
+
+History is also pruned automatically as tasks start, following the
+storage.retention settings:
+
+    storage:
+      retention:
+        max_tasks: 500          # keep only the most recent finished tasks
+        max_age: 720h           # prune tasks that finished more than 30 days ago
+        failed_max_age: 2160h   # keep failed, cancelled and interrupted tasks 90 days
+        artifacts: true         # delete pruned tasks' artifacts too
+
+Examples:
+
+    capn tasks prune --before 30d --status completed
+    capn tasks prune --before 2024-01-01 --artifacts
+    capn tasks prune --status failed,cancelled --all-workspaces
+    capn tasks prune --before 2w --dry-run`
+}
+
+func (p *TasksPruneCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	opts := task.PruneOptions{DryRun: globals.DryRun}
+	if p.Before != "" {
+		before, err := parseBefore(p.Before, clock.Now())
+		if err != nil {
+			return err
+		}
+		opts.Before = before
+	}
+	for _, status := range p.Status {
+		opts.Status = append(opts.Status, task.TaskStatus(status))
+	}
+	workspace, err := listingWorkspace(globals, p.AllWorkspaces)
+	if err != nil {
+		return err
+	}
+	opts.Workspace = workspace
+
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	if p.Artifacts && !globals.DryRun {
+		if opts.Artifacts, err = task.NewArtifactStore(config.ArtifactsDir()); err != nil {
+			return err
+		}
+	}
+
+	pruned, err := task.Prune(storage, opts)
+	verb := "Pruned"
+	if globals.DryRun {
+		verb = "Would prune"
+	}
+	for _, t := range pruned {
+		fmt.Fprintf(out, "  %s  %-11s  %s  %s\n", t.ID, t.Status, t.FinishedAt().Format("2006-01-02 15:04"), truncate(t.Goal, 60))
+	}
+	if err != nil {
+		return err
+	}
+	what := "tasks"
+	if p.Artifacts {
+		what = "tasks and their artifacts"
+	}
+	fmt.Fprintf(out, "%s %d %s\n", verb, len(pruned), what)
+	return nil
+}
+
+// parseBefore resolves the --before flag of tasks prune: an age such as 30d, 2w or 12h
+// counted back from now, or a date
+func parseBefore(value string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	age, err := parseAge(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --before %q: use an age such as 30d, 2w or 12h, or a date such as 2024-01-31", value)
+	}
+	return now.Add(-age), nil
+}
+
+// parseAge parses a duration, also accepting whole days (d) and weeks (w)
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, nil
+}
+
+// applyRetention prunes the task history as storage.retention configures, logging what it pruned
+func applyRetention(cfg *config.Config, storage task.TaskStorage, logger *zap.Logger) {
+	policy := cfg.Storage.Retention
+	retention := task.Retention{MaxTasks: policy.MaxTasks, MaxAge: policy.MaxAge, FailedMaxAge: policy.FailedMaxAge}
+	if !retention.Enabled() {
+		return
+	}
+	var artifacts *task.ArtifactStore
+	if policy.Artifacts {
+		store, err := task.NewArtifactStore(cfg.ArtifactsDir())
+		if err != nil {
+			logger.Warn("Failed to prune task history", zap.Error(err))
+			return
+		}
+		artifacts = store
+	}
+	pruned, err := task.ApplyRetention(storage, retention, artifacts)
+	if err != nil {
+		logger.Warn("Failed to prune task history", zap.Error(err))
+	}
+	if len(pruned) > 0 {
+		logger.Info("Pruned task history", zap.Int("tasks", len(pruned)), zap.Bool("artifacts", policy.Artifacts))
+	}
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// seedFinishedTask records a task that reached status the given time ago, with an artifact
+func seedFinishedTask(t *testing.T, status task.TaskStatus, ago time.Duration) *task.TaskExecution {
+	t.Helper()
+	te := seedTask(t, status)
+	te.CompletedAt = time.Now().Add(-ago)
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveTask(te))
+
+	store, err := task.NewArtifactStore(config.NewConfig().ArtifactsDir())
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(store.TaskDir(te.ID), 0o755))
+	return te
+}
+
+func TestTasksPruneCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	old := seedFinishedTask(t, task.TaskStatusCompleted, 40*24*time.Hour)
+	oldFailed := seedFinishedTask(t, task.TaskStatusFailed, 40*24*time.Hour)
+	recent := seedFinishedTask(t, task.TaskStatusCompleted, time.Hour)
+	running := seedTask(t, task.TaskStatusRunning)
+
+	out, err := runCLI(t, "tasks", "prune", "--before", "30d", "--status", "completed", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, old.ID)
+	assert.NotContains(t, out, oldFailed.ID)
+	assert.Contains(t, out, "Would prune 1 tasks\n")
+
+	out, err = runCLI(t, "tasks", "prune", "--before", "30d", "--status", "completed")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Pruned 1 tasks\n")
+
+	storage, err := openTaskStorage(config.NewConfig())
+	require.NoError(t, err)
+	_, err = storage.GetTask(old.ID)
+	assert.Error(t, err)
+	store, err := task.NewArtifactStore(config.NewConfig().ArtifactsDir())
+	require.NoError(t, err)
+	assert.DirExists(t, store.TaskDir(old.ID), "artifacts are kept without --artifacts")
+
+	out, err = runCLI(t, "tasks", "prune", "--before", "2w", "--artifacts")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Pruned 1 tasks and their artifacts\n")
+	assert.NoDirExists(t, store.TaskDir(oldFailed.ID))
+	assert.DirExists(t, store.TaskDir(recent.ID))
+
+	out, err = runCLI(t, "tasks", "prune")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Pruned 1 tasks\n")
+	_, err = storage.GetTask(running.ID)
+	assert.NoError(t, err, "unfinished tasks are never pruned")
+
+	_, err = runCLI(t, "tasks", "prune", "--before", "soon")
+	assert.EqualError(t, err, `invalid --before "soon": use an age such as 30d, 2w or 12h, or a date such as 2024-01-31`)
+}
+
+func TestParseBefore(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"30d", now.Add(-30 * 24 * time.Hour)},
+		{"2w", now.Add(-14 * 24 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"2024-01-15", time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := parseBefore(tt.value, now)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+	for _, value := range []string{"", "-3d", "xd", "yesterday"} {
+		_, err := parseBefore(value, now)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestTaskRun_AdmitAppliesRetention(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	old := seedFinishedTask(t, task.TaskStatusCompleted, 48*time.Hour)
+	recent := seedFinishedTask(t, task.TaskStatusCompleted, time.Hour)
+
+	cfg := config.NewConfig()
+	cfg.Storage.Retention = config.RetentionConfig{MaxAge: 24 * time.Hour, Artifacts: true}
+	storage, err := openTaskStorage(cfg)
+	require.NoError(t, err)
+	run := &taskRun{storage: storage, record: task.NewTaskExecution("next goal"), config: cfg, logger: zap.NewNop(), out: &bytes.Buffer{}}
+	admitted, err := run.admit(context.Background())
+	require.NoError(t, err)
+	assert.True(t, admitted)
+
+	_, err = storage.GetTask(old.ID)
+	assert.Error(t, err)
+	_, err = storage.GetTask(recent.ID)
+	assert.NoError(t, err)
+	store, err := task.NewArtifactStore(cfg.ArtifactsDir())
+	require.NoError(t, err)
+	assert.NoDirExists(t, store.TaskDir(old.ID))
+}
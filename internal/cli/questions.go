@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// TasksAnswerCmd represents the tasks answer command
+type TasksAnswerCmd struct {
+	TaskID   string `arg:"" name:"task-id" help:"Task whose agent asked the question"`
+	Response string `arg:"" help:"Answer to send to the agent"`
+	Question string `help:"ID of the question to answer (default: the oldest open question)" short:"q"`
+}
+
+// Help returns detailed help for the tasks answer command
+func (a *TasksAnswerCmd) Help() string {
+	return `Answer a question raised by an agent. The step that asked waits until it is
+answered, so this is usually run from another terminal while the task executes.
+Open questions are listed by "capn status" and "capn tasks show".
+
+Examples:
+
+    capn tasks answer task-1a2b3c4d "Use the staging database"
+    capn tasks answer task-1a2b3c4d --question q-2 "docs/"`
+}
+
+func (a *TasksAnswerCmd) Run(out io.Writer, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(a.TaskID)
+	if err != nil {
+		return err
+	}
+
+	q, err := t.AnswerQuestion(a.Question, strings.TrimSpace(a.Response))
+	if err != nil {
+		return err
+	}
+	if err := storage.SaveTask(t); err != nil {
+		return fmt.Errorf("failed to save answer: %w", err)
+	}
+
+	fmt.Fprintf(out, "Answered question %s of task %s\n", q.ID, t.ID)
+	return nil
+}
+
+// announcingQuestioner prints each question with how to answer it before waiting for the answer
+type announcingQuestioner struct {
+	next   agents.Questioner
+	out    io.Writer
+	taskID string
+}
+
+func (q *announcingQuestioner) Ask(ctx context.Context, step, agentID, question string) (string, error) {
+	fmt.Fprintf(q.out, "Question from %s on step %s: %s\n", agentID, step, question)
+	fmt.Fprintf(q.out, "  Waiting for: capn tasks answer %s \"response\"\n", q.taskID)
+	return q.next.Ask(ctx, step, agentID, question)
+}
+
+// questionSource describes who asked a question
+func questionSource(q task.Question) string {
+	switch {
+	case q.Agent != "" && q.Step != "":
+		return fmt.Sprintf("%s on %s", q.Agent, q.Step)
+	case q.Agent != "":
+		return q.Agent
+	case q.Step != "":
+		return q.Step
+	}
+	return "agent"
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksAnswerCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := seedTask(t, task.TaskStatusRunning)
+	te.Ask("task-2", "research-001", "Which report format?")
+	te.Ask("task-2", "research-001", "Who is the audience?")
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "status")
+	require.NoError(t, err)
+	assert.Regexp(t, te.ID+`\s+running, 2 question\(s\)`, out)
+	assert.Contains(t, out, "Questions awaiting an answer:")
+	assert.Contains(t, out, te.ID+" q-1 (research-001 on task-2): Which report format?")
+	assert.Contains(t, out, `capn tasks answer <task-id> "response"`)
+
+	out, err = runCLI(t, "tasks", "answer", te.ID, "--question", "q-2", "maintainers")
+	require.NoError(t, err)
+	assert.Equal(t, "Answered question q-2 of task "+te.ID+"\n", out)
+
+	out, err = runCLI(t, "tasks", "answer", te.ID, "markdown")
+	require.NoError(t, err)
+	assert.Equal(t, "Answered question q-1 of task "+te.ID+"\n", out)
+
+	_, err = runCLI(t, "tasks", "answer", te.ID, "again")
+	assert.EqualError(t, err, "task "+te.ID+" has no open questions")
+
+	out, err = runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Questions (2):")
+	assert.Contains(t, out, "answer: markdown")
+
+	out, err = runCLI(t, "status")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "Questions awaiting an answer:")
+}
+
+func TestAnnouncingQuestioner(t *testing.T) {
+	storage := task.NewMemoryTaskStorage()
+	record := task.NewTaskExecution("goal")
+	require.NoError(t, storage.SaveTask(record))
+
+	var out bytes.Buffer
+	questioner := &announcingQuestioner{next: task.NewQuestionChannel(storage, record), out: &out, taskID: record.ID}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := questioner.Ask(ctx, "step-1", "file-001", "Which path?")
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, out.String(), "Question from file-001 on step step-1: Which path?")
+	assert.Contains(t, out.String(), "capn tasks answer "+record.ID)
+}
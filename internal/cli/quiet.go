@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+)
+
+// quiet reports whether output is limited to what scripts consume
+func (g *GlobalOptions) quiet() bool {
+	return g != nil && g.Quiet
+}
+
+// notef writes a line meant for people, such as an empty-listing notice or a hint, unless
+// output is quiet
+func notef(out io.Writer, globals *GlobalOptions, format string, args ...any) {
+	if !globals.quiet() {
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestQuiet_ExecutePrintsTaskID(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	original := seedTask(t, task.TaskStatusCompleted)
+
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	_, err := runCLI(t, "plans", "export", original.ID, "-o", path)
+	require.NoError(t, err)
+
+	out, err := runCLI(t, "execute", "--from-plan", path, "--approve-all")
+	require.NoError(t, err)
+	assert.Contains(t, out, "=== Execution Results ===")
+
+	out, err = runCLI(t, "--quiet", "execute", "--from-plan", path, "--approve-all")
+	require.NoError(t, err)
+	id := strings.TrimSpace(out)
+	assert.Regexp(t, `^task-[0-9a-f]{8}$`, id)
+	assert.Equal(t, id+"\n", out, "only the task ID is printed")
+
+	out, err = runCLI(t, "--quiet", "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, id)
+}
+
+func TestQuiet_Listings(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	out, err := runCLI(t, "--quiet", "tasks", "list")
+	require.NoError(t, err)
+	assert.Empty(t, out, "empty listings print nothing")
+
+	seeded := seedTask(t, task.TaskStatusCompleted)
+	out, err = runCLI(t, "tasks", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "ID  ")
+
+	out, err = runCLI(t, "--quiet", "tasks", "list")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 1, "no header row")
+	assert.True(t, strings.HasPrefix(lines[0], seeded.ID))
+
+	out, err = runCLI(t, "--quiet", "search", "analysis")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "matching task(s)")
+}
+
+func TestQuiet_LogsWarningsOnly(t *testing.T) {
+	c := NewCLI()
+	c.Quiet = true
+	logger, err := c.createLogger()
+	require.NoError(t, err)
+	assert.False(t, logger.Core().Enabled(zapcore.InfoLevel), "info logs are dropped")
+	assert.True(t, logger.Core().Enabled(zapcore.WarnLevel))
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// taskResumer runs the interrupted tasks the daemon resumes, in the background until ctx ends.
+// Approval for a resumed step is asked for on the daemon's terminal, and refused when it has none.
+type taskResumer struct {
+	ctx     context.Context // ends when the daemon stops, cancelling resumed tasks
+	config  *config.Config
+	storage task.TaskStorage
+	logger  *zap.Logger
+
+	wg sync.WaitGroup
+}
+
+// Resume starts running a resumed task's remaining steps, planning it first if it was
+// interrupted before it had a plan
+func (r *taskResumer) Resume(record *task.TaskExecution) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		// A panic fails only this task rather than the daemon
+		err := common.Recover("resumed task "+record.ID, func() error {
+			r.run(record)
+			return nil
+		})
+		var panicErr *common.PanicError
+		if errors.As(err, &panicErr) {
+			common.LogPanic(r.logger, "Resumed task panicked", panicErr, zap.String("task_id", record.ID))
+			failTask(r.storage, record, err, r.logger)
+		}
+	}()
+}
+
+// Wait blocks until every resumed task has stopped
+func (r *taskResumer) Wait() {
+	r.wg.Wait()
+}
+
+func (r *taskResumer) run(record *task.TaskExecution) {
+	cap, err := newCaptain(r.config)
+	if err != nil {
+		failTask(r.storage, record, err, r.logger)
+		return
+	}
+	defer cap.Stop()
+
+	run := &taskRun{captain: cap, storage: r.storage, record: record, config: r.config, logger: r.logger, out: io.Discard}
+	if admitted, err := run.admit(r.ctx); !admitted {
+		if err != nil {
+			r.logger.Warn("Resumed task was not admitted", zap.String("task_id", record.ID), zap.Error(err))
+		}
+		return
+	}
+	defer run.notify(r.ctx)
+	if record.Plan == nil {
+		plan, err := run.plan(r.ctx)
+		if err != nil || plan == nil {
+			if err != nil {
+				r.logger.Warn("Failed to plan resumed task", zap.String("task_id", record.ID), zap.Error(err))
+			}
+			return
+		}
+	}
+	if err := run.execute(r.ctx, false); err != nil {
+		r.logger.Warn("Failed to execute resumed task", zap.String("task_id", record.ID), zap.Error(err))
+	}
+}
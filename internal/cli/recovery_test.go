@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTaskResumer_RunsRemainingSteps(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	record := seedTask(t, task.TaskStatusInterrupted)
+	require.NoError(t, record.Resume())
+
+	cfg := config.NewConfig()
+	storage, err := openTaskStorage(cfg)
+	require.NoError(t, err)
+	resumer := &taskResumer{ctx: context.Background(), config: cfg, storage: storage, logger: zap.NewNop()}
+	resumer.Resume(record)
+	resumer.Wait()
+
+	stored, err := storage.GetTask(record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.TaskStatusCompleted, stored.Status)
+	require.Len(t, stored.Results, 2, "the completed step keeps its result and is not run again")
+	assert.Equal(t, "task-1", stored.Results[0].TaskID)
+	assert.Equal(t, "task-2", stored.Results[1].TaskID)
+}
+
+func TestTaskRun_AdmitRecoversOrphanedTasks(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	orphan := seedTask(t, task.TaskStatusRunning)
+	orphan.Metadata[task.MetadataOwnerPID] = "999999999"
+
+	cfg := config.NewConfig()
+	cfg.Captain.MaxConcurrentTasks = 1
+	storage, err := openTaskStorage(cfg)
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveTask(orphan))
+
+	record := task.NewTaskExecution("next goal")
+	run := &taskRun{storage: storage, record: record, config: cfg, logger: zap.NewNop(), out: &bytes.Buffer{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	admitted, err := run.admit(ctx)
+	require.NoError(t, err)
+	assert.True(t, admitted, "the orphaned task no longer holds the only slot")
+
+	stored, err := storage.GetTask(orphan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.TaskStatusInterrupted, stored.Status)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/daemon"
+)
+
+// reloadSignals are the signals that have the daemon reload its configuration
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
+// daemonConfigLoader returns the function the daemon reloads its configuration with: the file,
+// profile and read-only mode it was started with, resolving secrets again. Without a file the
+// command-line options are the configuration, as when the daemon started.
+func daemonConfigLoader(globals *GlobalOptions) func() (*config.Config, error) {
+	options := *globals
+	return func() (*config.Config, error) {
+		cfg := config.NewConfig()
+		if options.Config != "" {
+			loaded, err := config.LoadConfigWith(options.Config, config.LoadOptions{NoInterpolate: options.NoInterpolate})
+			if err != nil {
+				return nil, err
+			}
+			cfg = loaded
+		} else {
+			cfg.Global.Verbose = options.Verbose
+			cfg.Global.DryRun = options.DryRun
+			cfg.Global.Parallel = options.Parallel
+			cfg.Global.Timeout = options.Timeout
+			cfg.Global.LogLevel = options.LogLevel
+			cfg.Global.LogFormat = options.LogFormat
+			cfg.Global.LogFile = options.LogFile
+		}
+		if options.Profile != "" {
+			if err := cfg.ApplyProfile(options.Profile); err != nil {
+				return nil, err
+			}
+		}
+		if options.ReadOnly {
+			cfg.Execution.ReadOnly = true
+		}
+		if err := resolveSecrets(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+}
+
+// daemonReloader applies the reloaded settings the daemon leaves to the command: the log level,
+// the notification channels digests are sent to, and the configuration goals from Slack are
+// planned and run with
+type daemonReloader struct {
+	daemon *daemon.Daemon
+	level  zap.AtomicLevel
+	// pinnedLevel is set when --log-level or CAPN_LOG_LEVEL chose the level, which the
+	// configuration file does not override
+	pinnedLevel bool
+	globals     *GlobalOptions
+	runner      *slackRunner
+	logger      *zap.Logger
+}
+
+// Apply is called by the daemon after each reload that changed the configuration
+func (r *daemonReloader) Apply(cfg *config.Config, report daemon.ReloadReport) error {
+	if slices.Contains(report.Applied, "global.log_level") {
+		if r.pinnedLevel {
+			r.logger.Info("Keeping the log level given on the command line", zap.String("level", r.level.String()))
+		} else {
+			level, err := logLevel(r.globals, cfg.Global.LogLevel)
+			if err != nil {
+				return err
+			}
+			r.level.SetLevel(level)
+		}
+	}
+	if slices.Contains(report.Applied, "notifications") {
+		dispatcher, err := newDispatcher(cfg, nil, r.logger)
+		if err != nil {
+			return err
+		}
+		r.daemon.SetNotifications(dispatcher)
+	}
+	if r.runner != nil {
+		r.runner.setConfig(cfg)
+	}
+	return nil
+}
+
+// reloadOnSignal reloads the daemon's configuration in the background each time a reload
+// signal arrives, until ctx ends. The signals are caught once it returns.
+func reloadOnSignal(ctx context.Context, dmn *daemon.Daemon, logger *zap.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, reloadSignals...)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				logger.Info("Reloading configuration on signal")
+				if _, err := dmn.Reload(); err != nil {
+					logger.Warn("Failed to reload configuration", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// formatReloadReport describes what a reload changed
+func formatReloadReport(report daemon.ReloadReport) string {
+	if !report.Changed() {
+		return "Configuration reloaded; nothing changed\n"
+	}
+	text := "Configuration reloaded\n"
+	if len(report.Applied) > 0 {
+		text += "Applied:\n"
+		for _, setting := range report.Applied {
+			text += "  " + setting + "\n"
+		}
+	}
+	if len(report.Rejected) > 0 {
+		text += "Not applied; restart the daemon to change:\n"
+		for _, rejected := range report.Rejected {
+			text += fmt.Sprintf("  %s (%s)\n", rejected.Setting, rejected.Reason)
+		}
+	}
+	return text
+}
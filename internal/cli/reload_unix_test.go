@@ -0,0 +1,34 @@
+//go:build !windows
+
+package cli
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestReloadOnSignal(t *testing.T) {
+	dmn := startDaemon(t)
+	reloaded := make(chan struct{}, 1)
+	dmn.SetReloader(func() (*config.Config, error) {
+		reloaded <- struct{}{}
+		return config.NewConfig(), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloadOnSignal(ctx, dmn, zap.NewNop())
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SIGHUP did not reload the configuration")
+	}
+}
@@ -0,0 +1,676 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// maxShellHistory bounds the lines kept in the shell history file
+const maxShellHistory = 1000
+
+// errExitShell is returned by a shell command that ends the session
+var errExitShell = errors.New("exit shell")
+
+// shellBuiltins are the commands the shell handles itself; everything else is a capn command or a goal
+var shellBuiltins = []struct {
+	name  string
+	usage string
+	help  string
+}{
+	{"run", "run <goal>", "Plan and execute a goal; a line that is not a command is run as a goal too"},
+	{"ask", "ask <question>", "Ask the Captain about the focused task's plan and results"},
+	{"focus", "focus <task-id>", "Choose the task questions are about (the last goal run by default)"},
+	{"history", "history", "Show the input history"},
+	{"help", "help", "Show this help"},
+	{"exit", "exit", "Leave the shell (also quit or Ctrl-D)"},
+	{"quit", "quit", ""},
+}
+
+// ShellCmd represents the interactive shell command
+type ShellCmd struct {
+	ApproveAll bool `help:"Run high-risk and gated steps of goals without asking for approval" name:"approve-all"`
+	NoHistory  bool `help:"Neither read nor write the history file" name:"no-history"`
+}
+
+// Help returns detailed help for the shell command
+func (s *ShellCmd) Help() string {
+	return `Start an interactive session. Goals typed at the prompt are planned and
+executed inline, showing each step as it finishes, while the Captain keeps its
+conversation about them for follow-up questions with "ask". Any capn command
+can be entered without the "capn" prefix, such as "tasks list" or
+"tasks show <task-id>".
+
+On a terminal, Tab completes commands, flags and task IDs, and the arrow keys
+recall history, which is kept across sessions in the capn home directory.
+
+Examples:
+
+    capn shell
+    capn --profile ci shell --approve-all`
+}
+
+func (s *ShellCmd) Run(ctx context.Context, kctx *kong.Context, out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	shell := &replShell{model: kctx.Model.Node, out: out, globals: globals, logger: logger, config: config, approveAll: s.ApproveAll}
+	if !s.NoHistory {
+		shell.loadHistory(config.ShellHistoryFile())
+	}
+	defer shell.close()
+
+	var input lineReader = &plainReader{scanner: bufio.NewScanner(os.Stdin), out: out, prompt: isTerminal(os.Stdin)}
+	if term := newSttyTerminal(os.Stdin); term != nil {
+		input = &lineEditor{in: bufio.NewReader(os.Stdin), out: out, term: term, history: shell.historyLines, complete: shell.complete}
+	}
+
+	// Interrupts stop the running goal or command only, never the session
+	return shell.run(context.WithoutCancel(ctx), input)
+}
+
+// replShell is an interactive session that keeps one Captain across the goals it runs
+type replShell struct {
+	model      *kong.Node
+	out        io.Writer
+	globals    *GlobalOptions
+	logger     *zap.Logger
+	config     *config.Config
+	approveAll bool
+
+	captain     *captain.Captain
+	focus       string
+	history     []string
+	historyFile string
+}
+
+// run reads and handles lines until the input ends or the session is exited
+func (s *replShell) run(ctx context.Context, input lineReader) error {
+	fmt.Fprintf(s.out, "capn shell: enter a goal to run it, \"help\" for commands, \"exit\" to leave\n")
+	for {
+		line, err := input.ReadLine(s.prompt())
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.remember(line)
+
+		if err := s.handle(ctx, line); errors.Is(err, errExitShell) {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(s.out, "Error: %v\n", err)
+		}
+	}
+}
+
+// prompt returns the prompt, naming the focused task
+func (s *replShell) prompt() string {
+	if s.focus != "" {
+		return fmt.Sprintf("capn [%s]> ", s.focus)
+	}
+	return "capn> "
+}
+
+// handle runs one line: a builtin, a capn command or a goal
+func (s *replShell) handle(ctx context.Context, line string) error {
+	words, err := splitWords(line)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	rest := strings.Join(words[1:], " ")
+	switch words[0] {
+	case "exit", "quit":
+		return errExitShell
+	case "help":
+		s.printHelp()
+		return nil
+	case "history":
+		for i, entry := range s.history {
+			fmt.Fprintf(s.out, "%5d  %s\n", i+1, entry)
+		}
+		return nil
+	case "run":
+		if rest == "" {
+			return fmt.Errorf("usage: run <goal>")
+		}
+		return s.runGoal(ctx, rest)
+	case "ask":
+		if rest == "" {
+			return fmt.Errorf("usage: ask <question>")
+		}
+		return s.ask(ctx, rest)
+	case "focus":
+		if len(words) != 2 {
+			return fmt.Errorf("usage: focus <task-id>")
+		}
+		return s.setFocus(words[1])
+	case "shell":
+		return fmt.Errorf("already in the shell")
+	}
+
+	if findChild(s.model, words[0]) != nil {
+		return s.runCommand(words)
+	}
+	return s.runGoal(ctx, line)
+}
+
+// printHelp lists the builtins
+func (s *replShell) printHelp() {
+	fmt.Fprintf(s.out, "Commands:\n")
+	for _, builtin := range shellBuiltins {
+		if builtin.help != "" {
+			fmt.Fprintf(s.out, "  %-18s %s\n", builtin.usage, builtin.help)
+		}
+	}
+	fmt.Fprintf(s.out, "\nAny capn command can be entered without the \"capn\" prefix, e.g. \"tasks list\".\n")
+}
+
+// runCommand runs a capn command line with the session's global options
+func (s *replShell) runCommand(words []string) error {
+	cli := NewCLI()
+	cli.SetOutput(s.out)
+	return cli.Parse(append(s.globalArgs(), words...))
+}
+
+// globalArgs returns the global flags the shell was started with
+func (s *replShell) globalArgs() []string {
+	g := s.globals
+	args := []string{"--parallel", strconv.Itoa(g.Parallel), "--timeout", g.Timeout.String()}
+	if g.Config != "" {
+		args = append(args, "--config", g.Config)
+	}
+	if g.Profile != "" {
+		args = append(args, "--profile", g.Profile)
+	}
+	if g.Workspace != "" {
+		args = append(args, "--workspace", g.Workspace)
+	}
+	if g.Quiet {
+		args = append(args, "--quiet")
+	}
+	if g.OutputFormat != "" {
+		args = append(args, "--output-format", g.OutputFormat)
+	}
+	if g.LogLevel != "" {
+		args = append(args, "--log-level", g.LogLevel)
+	}
+	if g.LogFormat != "" {
+		args = append(args, "--log-format", g.LogFormat)
+	}
+	if g.LogFile != "" {
+		args = append(args, "--log-file", g.LogFile)
+	}
+	if g.Verbose {
+		args = append(args, "--verbose")
+	}
+	if g.DryRun {
+		args = append(args, "--dry-run")
+	}
+	return args
+}
+
+// runGoal plans and executes a goal as a recorded task, reporting each step as it finishes,
+// and focuses the task. In dry-run sessions the goal is only planned.
+func (s *replShell) runGoal(ctx context.Context, goal string) error {
+	if s.globals.DryRun {
+		return s.runCommand([]string{"execute", goal})
+	}
+	cap, err := s.ensureCaptain()
+	if err != nil {
+		return err
+	}
+	storage, err := openTaskStorage(s.config)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := currentWorkspace(s.globals)
+	if err != nil {
+		return err
+	}
+	record := task.NewTaskExecution(goal)
+	record.Workspace = workspace
+	s.focus = record.ID
+	run := &taskRun{captain: cap, storage: storage, record: record, config: s.config, logger: s.logger, out: s.out,
+		onStep: func(step captain.Task, result *captain.Result) {
+			if result.Success {
+				fmt.Fprintf(s.out, "  ✓ %s finished in %s\n", step.ID, result.Duration)
+			} else {
+				fmt.Fprintf(s.out, "  ✗ %s failed: %s\n", step.ID, result.Error)
+			}
+		},
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	if admitted, err := run.admit(ctx); !admitted {
+		return err
+	}
+	defer run.notify(ctx)
+	plan, err := run.plan(ctx)
+	if err != nil || plan == nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Task %s: %d step(s) planned\n", record.ID, len(plan.Tasks))
+	return run.execute(ctx, s.approveAll)
+}
+
+// ask puts a question about the focused task to the Captain
+func (s *replShell) ask(ctx context.Context, question string) error {
+	if s.focus == "" {
+		return fmt.Errorf("no task to ask about; run a goal or choose one with focus <task-id>")
+	}
+	storage, err := openTaskStorage(s.config)
+	if err != nil {
+		return err
+	}
+	record, err := storage.GetTask(s.focus)
+	if err != nil {
+		return err
+	}
+	cap, err := s.ensureCaptain()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	answer, err := cap.Ask(ctx, record.Goal, describeTaskState(record), question)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, answer)
+	return nil
+}
+
+// setFocus makes a stored task the subject of questions
+func (s *replShell) setFocus(id string) error {
+	storage, err := openTaskStorage(s.config)
+	if err != nil {
+		return err
+	}
+	record, err := storage.GetTask(id)
+	if err != nil {
+		return err
+	}
+	s.focus = record.ID
+	fmt.Fprintf(s.out, "Questions are now about task %s: %s\n", record.ID, record.Goal)
+	return nil
+}
+
+// ensureCaptain creates the session's Captain on first use
+func (s *replShell) ensureCaptain() (*captain.Captain, error) {
+	if s.captain != nil {
+		return s.captain, nil
+	}
+	if !llmConfigured(s.config) {
+		return nil, fmt.Errorf("OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to work with the Captain")
+	}
+	cap, err := newCaptain(s.config)
+	if err != nil {
+		return nil, err
+	}
+	if clarifier := clarifierFor(os.Stdin, s.out); clarifier != nil {
+		cap.SetClarifier(clarifier)
+	}
+	s.captain = cap
+	return cap, nil
+}
+
+// close stops the session's Captain
+func (s *replShell) close() {
+	if s.captain != nil {
+		s.captain.Stop()
+	}
+}
+
+// describeTaskState summarizes a stored task for the Captain when it holds no conversation about it
+func describeTaskState(record *task.TaskExecution) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task %s is %s.\n", record.ID, record.Status)
+	if record.Plan != nil {
+		b.WriteString("Plan steps:\n")
+		for _, step := range record.Plan.Tasks {
+			fmt.Fprintf(&b, "- %s [%s]: %v\n", step.ID, step.Type, step.Payload["description"])
+		}
+	}
+	if len(record.Results) > 0 {
+		b.WriteString("Results:\n")
+		for _, result := range record.Results {
+			if result.Success {
+				fmt.Fprintf(&b, "- %s succeeded: %s\n", result.TaskID, truncate(result.Output, 500))
+			} else {
+				fmt.Fprintf(&b, "- %s failed: %s\n", result.TaskID, truncate(result.Error, 500))
+			}
+		}
+	}
+	if record.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", record.Error)
+	}
+	return b.String()
+}
+
+// complete returns the candidates for the last word of a partial line
+func (s *replShell) complete(line string) []string {
+	words := strings.Fields(line)
+	if line == "" || strings.HasSuffix(line, " ") {
+		words = append(words, "")
+	}
+	completer := &completer{model: s.model, config: s.config, logger: s.logger}
+	current := words[len(words)-1]
+
+	if len(words) == 1 {
+		var candidates []string
+		for _, builtin := range shellBuiltins {
+			candidates = append(candidates, builtin.name)
+		}
+		for _, name := range completer.Complete(words) {
+			if name != "shell" {
+				candidates = append(candidates, name)
+			}
+		}
+		return filterPrefix(candidates, current)
+	}
+	if words[0] == "focus" {
+		if len(words) == 2 {
+			return filterPrefix(completer.taskIDs(), current)
+		}
+		return nil
+	}
+	if findChild(s.model, words[0]) == nil {
+		return nil
+	}
+	return completer.Complete(words)
+}
+
+// loadHistory reads the history file, keeping its latest lines, and records new lines to it
+func (s *replShell) loadHistory(path string) {
+	s.historyFile = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			s.history = append(s.history, line)
+		}
+	}
+	if len(s.history) > maxShellHistory {
+		s.history = s.history[len(s.history)-maxShellHistory:]
+		if err := os.WriteFile(path, []byte(strings.Join(s.history, "\n")+"\n"), 0o600); err != nil {
+			s.logger.Debug("Failed to trim shell history", zap.Error(err))
+		}
+	}
+}
+
+// remember adds a line to the history unless it repeats the previous one
+func (s *replShell) remember(line string) {
+	if n := len(s.history); n > 0 && s.history[n-1] == line {
+		return
+	}
+	s.history = append(s.history, line)
+	if s.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(s.historyFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		s.logger.Debug("Failed to record shell history", zap.Error(err))
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// historyLines returns the input history, oldest first
+func (s *replShell) historyLines() []string {
+	return s.history
+}
+
+// splitWords splits a line into words like a shell: quotes group words and a backslash
+// escapes the next character
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// lineReader reads lines of input after showing a prompt
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader reads whole lines, as when input is piped or the terminal cannot be switched
+// to character mode
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+	prompt  bool
+}
+
+func (p *plainReader) ReadLine(prompt string) (string, error) {
+	if p.prompt {
+		fmt.Fprint(p.out, prompt)
+	}
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return p.scanner.Text(), nil
+}
+
+// rawTerminal switches a terminal into character mode for the line editor and back
+type rawTerminal interface {
+	raw() error
+	restore()
+}
+
+// sttyTerminal switches a Unix terminal between modes with stty
+type sttyTerminal struct {
+	file  *os.File
+	saved string
+}
+
+// newSttyTerminal returns the controller for f, or nil if f is not a terminal stty can drive
+func newSttyTerminal(f *os.File) *sttyTerminal {
+	if runtime.GOOS == "windows" || !isTerminal(f) {
+		return nil
+	}
+	t := &sttyTerminal{file: f}
+	saved, err := t.stty("-g")
+	if err != nil {
+		return nil
+	}
+	t.saved = strings.TrimSpace(saved)
+	return t
+}
+
+// raw disables line buffering, echo and signal keys so the editor sees every key
+func (t *sttyTerminal) raw() error {
+	_, err := t.stty("-icanon", "-echo", "-isig", "min", "1")
+	return err
+}
+
+// restore returns the terminal to the mode it was in when the shell started
+func (t *sttyTerminal) restore() {
+	_, _ = t.stty(t.saved)
+}
+
+func (t *sttyTerminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.file
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// lineEditor reads lines key by key on a terminal in character mode, completing the last
+// word on Tab and recalling history with the arrow keys
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	term     rawTerminal
+	history  func() []string
+	complete func(line string) []string
+}
+
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	if e.term != nil {
+		if err := e.term.raw(); err != nil {
+			return "", err
+		}
+		defer e.term.restore()
+	}
+
+	history := e.history()
+	position := len(history)
+	var line []rune
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r\x1b[K%s%s", prompt, string(line))
+	}
+	redraw()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 0x04: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case 0x03: // Ctrl-C abandons the line
+			fmt.Fprint(e.out, "^C\r\n")
+			line, position = nil, len(history)
+			redraw()
+		case 0x7f, 0x08: // Backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				redraw()
+			}
+		case 0x15: // Ctrl-U
+			line = nil
+			redraw()
+		case '\t':
+			line = e.completeLine(line, prompt)
+			redraw()
+		case 0x1b: // Arrow keys arrive as ESC [ A and ESC [ B
+			if next, _, err := e.in.ReadRune(); err != nil || next != '[' {
+				continue
+			}
+			key, _, err := e.in.ReadRune()
+			if err != nil {
+				continue
+			}
+			switch {
+			case key == 'A' && position > 0:
+				position--
+				line = []rune(history[position])
+			case key == 'B' && position < len(history)-1:
+				position++
+				line = []rune(history[position])
+			case key == 'B':
+				position, line = len(history), nil
+			}
+			redraw()
+		default:
+			if r >= ' ' {
+				line = append(line, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+// completeLine completes the last word of line: a single candidate is filled in, several
+// are narrowed to their common prefix or listed
+func (e *lineEditor) completeLine(line []rune, prompt string) []rune {
+	text := string(line)
+	candidates := e.complete(text)
+	start := strings.LastIndex(text, " ") + 1
+	current := text[start:]
+
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(e.out, "\a")
+		return line
+	case 1:
+		return []rune(text[:start] + candidates[0] + " ")
+	}
+	if prefix := commonPrefix(candidates); len(prefix) > len(current) {
+		return []rune(text[:start] + prefix)
+	}
+	fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	return line
+}
+
+// commonPrefix returns the longest prefix shared by all words
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
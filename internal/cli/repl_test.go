@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// withStdin replaces standard input with a file holding input for the rest of the test
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+// testShell returns a shell session over the real command model
+func testShell(t *testing.T) *replShell {
+	t.Helper()
+	parser, err := kong.New(NewCLI(), kong.Exit(func(int) {}))
+	require.NoError(t, err)
+	return &replShell{model: parser.Model.Node, out: io.Discard, globals: &GlobalOptions{}, logger: zap.NewNop(), config: config.NewConfig()}
+}
+
+func TestShellCmd_Session(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+	t.Setenv("OPENAI_API_KEY", "")
+	record := seedTask(t, task.TaskStatusCompleted)
+
+	withStdin(t, strings.Join([]string{
+		"help",
+		"tasks list",
+		"   ",
+		"ask what happened?",
+		"focus " + record.ID,
+		"tasks show " + record.ID,
+		"refactor the parser",
+		`run "unterminated`,
+		"shell",
+		"history",
+		"exit",
+		"tasks list",
+	}, "\n")+"\n")
+
+	output, err := runCLI(t, "shell")
+	require.NoError(t, err)
+	assert.Contains(t, output, "run <goal>")
+	assert.Contains(t, output, "Error: no task to ask about")
+	assert.Contains(t, output, "Questions are now about task "+record.ID+": analyze code quality")
+	assert.Contains(t, output, "Plan plan-1 (")
+	assert.Contains(t, output, "Error: OpenAI is not configured")
+	assert.Contains(t, output, "Error: unterminated \" quote")
+	assert.Contains(t, output, "Error: already in the shell")
+	assert.Contains(t, output, "    1  help\n")
+	assert.Equal(t, 1, strings.Count(output, record.ID+"  "), "nothing runs after exit")
+
+	history, err := os.ReadFile(filepath.Join(home, "shell_history"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(history), "help\ntasks list\nask what happened?\n"))
+	assert.True(t, strings.HasSuffix(string(history), "history\nexit\n"))
+}
+
+func TestShellCmd_NoHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+	withStdin(t, "help\n")
+
+	_, err := runCLI(t, "shell", "--no-history")
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(home, "shell_history"))
+}
+
+func TestReplShell_LoadHistoryTrims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shell_history")
+	var lines []string
+	for i := 0; i < maxShellHistory+5; i++ {
+		lines = append(lines, "goal")
+	}
+	lines[len(lines)-1] = "last"
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	shell := testShell(t)
+	shell.loadHistory(path)
+	assert.Len(t, shell.history, maxShellHistory)
+
+	shell.remember("last")
+	shell.remember("next")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, maxShellHistory+1, strings.Count(string(data), "\n"))
+	assert.True(t, strings.HasSuffix(string(data), "last\nnext\n"))
+}
+
+func TestReplShell_Complete(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	record := seedTask(t, task.TaskStatusCompleted)
+	shell := testShell(t)
+
+	top := shell.complete("")
+	assert.Contains(t, top, "run")
+	assert.Contains(t, top, "ask")
+	assert.Contains(t, top, "tasks")
+	assert.NotContains(t, top, "shell")
+
+	assert.Equal(t, []string{"tasks", "templates"}, shell.complete("t"))
+	assert.Equal(t, []string{"history", "help"}, shell.complete("h"))
+	assert.Equal(t, []string{"show"}, shell.complete("tasks sh"))
+	assert.Equal(t, []string{record.ID}, shell.complete("tasks show "))
+	assert.Equal(t, []string{record.ID}, shell.complete("focus "))
+	assert.Empty(t, shell.complete("focus "+record.ID+" "))
+	assert.Empty(t, shell.complete("ask why "))
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr string
+	}{
+		{line: "tasks list  --status running", want: []string{"tasks", "list", "--status", "running"}},
+		{line: `run "fix the build" now`, want: []string{"run", "fix the build", "now"}},
+		{line: `ask 'what is "this"?'`, want: []string{"ask", `what is "this"?`}},
+		{line: `run fix\ the\ build ""`, want: []string{"run", "fix the build", ""}},
+		{line: "   ", want: nil},
+		{line: `run 'oops`, wantErr: "unterminated ' quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			words, err := splitWords(tt.line)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, words)
+		})
+	}
+}
+
+// fakeTerminal records mode switches of the line editor
+type fakeTerminal struct {
+	switches []string
+}
+
+func (f *fakeTerminal) raw() error {
+	f.switches = append(f.switches, "raw")
+	return nil
+}
+
+func (f *fakeTerminal) restore() {
+	f.switches = append(f.switches, "restore")
+}
+
+func TestLineEditor(t *testing.T) {
+	history := []string{"tasks list", "status"}
+	complete := func(line string) []string {
+		switch line {
+		case "tasks sh":
+			return []string{"show"}
+		case "t":
+			return []string{"tasks", "templates"}
+		case "te":
+			return []string{"templates"}
+		case "ta", "tasks ":
+			return []string{"list", "logs"}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		shown string
+	}{
+		{name: "plain line", input: "run it\n", want: "run it"},
+		{name: "single candidate", input: "tasks sh\t\n", want: "tasks show "},
+		{name: "common prefix", input: "ta\t\n", want: "ta", shown: "list  logs"},
+		{name: "narrow then complete", input: "t\te\t\n", want: "templates "},
+		{name: "no candidate", input: "xyz\t\n", want: "xyz", shown: "\a"},
+		{name: "backspace", input: "runn\x7f it\n", want: "run it"},
+		{name: "clear line", input: "oops\x15status\n", want: "status"},
+		{name: "interrupt", input: "oops\x03status\n", want: "status", shown: "^C"},
+		{name: "history up", input: "\x1b[A\x1b[A\n", want: "tasks list"},
+		{name: "history up and down", input: "\x1b[A\x1b[A\x1b[B\n", want: "status"},
+		{name: "history past the end", input: "x\x1b[A\x1b[B\x1b[Bnew\n", want: "new"},
+		{name: "unicode", input: "ask ¿qué?\x7f!\n", want: "ask ¿qué!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			term := &fakeTerminal{}
+			editor := &lineEditor{in: bufio.NewReader(strings.NewReader(tt.input)), out: &out, term: term,
+				history: func() []string { return history }, complete: complete}
+
+			line, err := editor.ReadLine("capn> ")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, line)
+			assert.Contains(t, out.String(), tt.shown)
+			assert.Equal(t, []string{"raw", "restore"}, term.switches)
+		})
+	}
+}
+
+func TestLineEditor_EOF(t *testing.T) {
+	editor := &lineEditor{in: bufio.NewReader(strings.NewReader("\x04")), out: io.Discard,
+		history: func() []string { return nil }, complete: func(string) []string { return nil }}
+	_, err := editor.ReadLine("capn> ")
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Ctrl-D only ends input on an empty line
+	editor.in = bufio.NewReader(strings.NewReader("ab\x04c\n"))
+	line, err := editor.ReadLine("capn> ")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", line)
+}
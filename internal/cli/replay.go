@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// AgentsReplayCmd represents the agents replay command
+type AgentsReplayCmd struct {
+	TaskID   string        `arg:"" name:"task-id" help:"Task to replay"`
+	Speed    string        `help:"Playback speed, such as 2x or 0.5x; 0 prints the timeline without pausing" default:"1x"`
+	MaxPause time.Duration `name:"max-pause" help:"Longest pause between two events, so idle stretches do not stall the replay" default:"2s"`
+}
+
+// Help returns detailed help for the agents replay command
+func (r *AgentsReplayCmd) Help() string {
+	return `Replay a recorded task as it happened: the task starting and finishing, each
+plan step starting and finishing on its agent, and the messages agents sent
+each other, paced by the recorded timestamps. Nothing is re-run, so a failed
+task can be replayed to see the orchestration that led to the failure.
+
+Examples:
+
+    capn agents replay task-1a2b3c4d
+    capn agents replay task-1a2b3c4d --speed 2x
+    capn agents replay task-1a2b3c4d --speed 0`
+}
+
+func (r *AgentsReplayCmd) Run(ctx context.Context, out io.Writer, config *config.Config) error {
+	speed, err := parseReplaySpeed(r.Speed)
+	if err != nil {
+		return err
+	}
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(r.TaskID)
+	if err != nil {
+		return err
+	}
+
+	timeline := t.Timeline()
+	start := timeline[0].At
+	previous := start
+	for _, event := range timeline {
+		if speed > 0 {
+			pause := time.Duration(float64(event.At.Sub(previous)) / speed)
+			if r.MaxPause > 0 && pause > r.MaxPause {
+				pause = r.MaxPause
+			}
+			if err := sleepContext(ctx, pause); err != nil {
+				return err
+			}
+		}
+		previous = event.At
+		fmt.Fprintf(out, "%s  %-7s %s\n", replayOffset(event.At.Sub(start)), event.Kind, replayText(event))
+	}
+	return nil
+}
+
+// parseReplaySpeed parses a playback speed such as 2x, 0.5x or 3; 0 means no pauses
+func parseReplaySpeed(value string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "x"), 64)
+	if err != nil || speed < 0 {
+		return 0, fmt.Errorf("invalid speed %q (use a multiplier such as 2x or 0.5x, or 0 for no pauses)", value)
+	}
+	return speed, nil
+}
+
+// replayOffset formats how far into the task an event happened as +MM:SS.mmm
+func replayOffset(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("+%02d:%06.3f", int(d/time.Minute), (d % time.Minute).Seconds())
+}
+
+// replayText describes an event: messages as "from -> to: text", everything else attributed
+// to its agent
+func replayText(event task.TimelineEvent) string {
+	if event.Kind == task.TimelineMessage {
+		from, to := event.From, event.To
+		if from == "" {
+			from = "captain"
+		}
+		if to == "" {
+			to = "all"
+		}
+		return fmt.Sprintf("%s -> %s: %s", from, to, event.Message)
+	}
+	text := event.Message
+	if event.Level == task.LogLevelWarn || event.Level == task.LogLevelError {
+		text = strings.ToUpper(string(event.Level)) + ": " + text
+	}
+	if event.Agent != "" {
+		text += " [" + event.Agent + "]"
+	}
+	return text
+}
+
+// sleepContext waits for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
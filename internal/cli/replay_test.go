@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestAgentsReplayCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusFailed)
+
+	out, err := runCLI(t, "agents", "replay", te.ID, "--speed", "0")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Regexp(t, `^\+00:00\.000  task    Task created: analyze code quality$`, lines[0])
+	assert.Contains(t, out, "step    Step task-1 succeeded [research-001]")
+	assert.Contains(t, out, "log     analysis finished [research-001]")
+
+	_, err = runCLI(t, "agents", "replay", te.ID, "--speed", "fast")
+	assert.EqualError(t, err, `invalid speed "fast" (use a multiplier such as 2x or 0.5x, or 0 for no pauses)`)
+
+	_, err = runCLI(t, "agents", "replay", "task-missing")
+	assert.EqualError(t, err, "task not found: task-missing")
+}
+
+func TestParseReplaySpeed(t *testing.T) {
+	for value, want := range map[string]float64{"1x": 1, "2x": 2, "0.5x": 0.5, "3": 3, "0": 0} {
+		speed, err := parseReplaySpeed(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, speed, value)
+	}
+	_, err := parseReplaySpeed("-2x")
+	assert.Error(t, err)
+}
+
+func TestReplayOffset(t *testing.T) {
+	assert.Equal(t, "+00:00.000", replayOffset(-time.Second))
+	assert.Equal(t, "+01:05.250", replayOffset(65250*time.Millisecond))
+}
+
+func TestSleepContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sleepContext(ctx, time.Hour), context.Canceled)
+}
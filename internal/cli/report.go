@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// report asks the Captain for an outcome report on the finished steps and stores it on the
+// task. A report that cannot be written is logged rather than failing the run.
+func (r *taskRun) report(ctx context.Context) {
+	artifacts := make([]string, 0, len(r.record.Artifacts))
+	for _, artifact := range r.record.Artifacts {
+		artifacts = append(artifacts, artifact.Name)
+	}
+	report, err := r.captain.GenerateReport(ctx, captain.ReportInput{
+		Goal:      r.record.Goal,
+		Plan:      r.record.Plan,
+		Results:   r.record.Results,
+		Artifacts: artifacts,
+	})
+	if err != nil {
+		r.logger.Warn("Failed to generate outcome report", zap.String("task_id", r.record.ID), zap.Error(err))
+		r.record.AddLog(task.LogLevelWarn, fmt.Sprintf("Outcome report unavailable: %v", err))
+		return
+	}
+	r.record.Report = report
+	r.record.AddLog(task.LogLevelInfo, "Outcome report written")
+}
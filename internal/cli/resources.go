@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// printResources lists what each recorded step consumed and the task's total
+func printResources(out io.Writer, t *task.TaskExecution) {
+	var measured []captain.Result
+	for _, result := range t.Results {
+		if result.Resources != nil {
+			measured = append(measured, result)
+		}
+	}
+	if len(measured) == 0 {
+		fmt.Fprintf(out, "No resource usage recorded for task %s.\n", t.ID)
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tWALL\tCPU\tPEAK MEMORY\tDOWNLOADED\tFILES")
+	for _, result := range measured {
+		printResourceRow(w, result.TaskID, *result.Resources)
+	}
+	printResourceRow(w, "total", t.Resources())
+	w.Flush()
+}
+
+func printResourceRow(w io.Writer, step string, usage agents.ResourceUsage) {
+	cpu, memory := "-", "-"
+	if usage.CPUTime > 0 {
+		cpu = usage.CPUTime.Round(time.Millisecond).String()
+	}
+	if usage.PeakMemory > 0 {
+		memory = formatBytes(uint64(usage.PeakMemory))
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", step, usage.WallTime.Round(time.Millisecond), cpu, memory,
+		formatBytes(uint64(usage.BytesDownloaded)), usage.FilesWritten)
+}
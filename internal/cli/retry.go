@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// TasksRetryCmd represents the tasks retry command
+type TasksRetryCmd struct {
+	TaskID     string `arg:"" name:"task-id" help:"Failed, cancelled or interrupted task to retry"`
+	FailedOnly bool   `name:"failed-only" help:"Only re-run steps that did not succeed"`
+	ApproveAll bool   `name:"approve-all" help:"Run high-risk and gated steps without asking for approval"`
+}
+
+// Help returns detailed help for the tasks retry command
+func (r *TasksRetryCmd) Help() string {
+	return `Run a failed, cancelled or interrupted task again as a new task. The goal
+and plan are cloned from the original, and the new task records which task it
+retries so "capn tasks show" can display the retry chain. With --failed-only,
+steps that succeeded keep their results and only the remaining steps run.
+
+Examples:
+
+    capn tasks retry task-1a2b3c4d
+    capn tasks retry task-1a2b3c4d --failed-only`
+}
+
+func (r *TasksRetryCmd) Run(ctx context.Context, out io.Writer, logger *zap.Logger, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	original, err := storage.GetTask(r.TaskID)
+	if err != nil {
+		return err
+	}
+	record, err := task.NewRetry(original, r.FailedOnly)
+	if err != nil {
+		return err
+	}
+
+	if !llmConfigured(config) {
+		return fmt.Errorf("OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to retry tasks")
+	}
+	cap, err := newCaptain(config)
+	if err != nil {
+		return err
+	}
+	defer cap.Stop()
+
+	logger.Info("Retrying task", zap.String("task_id", original.ID), zap.String("retry_id", record.ID),
+		zap.String("mode", record.Metadata[task.MetadataRetryMode]))
+	fmt.Fprintf(out, "Retrying task %s as %s (%s)\n", original.ID, record.ID, record.Metadata[task.MetadataRetryMode])
+	saveTask(storage, record, logger)
+
+	run := &taskRun{captain: cap, storage: storage, record: record, config: config, logger: logger, out: out}
+	if admitted, err := run.admit(ctx); !admitted {
+		return err
+	}
+	defer run.notify(ctx)
+	if record.Plan == nil {
+		plan, err := run.plan(ctx)
+		if err != nil || plan == nil {
+			return err
+		}
+	}
+	return run.execute(ctx, r.ApproveAll)
+}
+
+// printRetryChain lists the tasks linked to current through retries, marking current
+func printRetryChain(out io.Writer, chain []*task.TaskExecution, current string) {
+	fmt.Fprintf(out, "\nRetry chain:\n")
+	for _, t := range chain {
+		marker := " "
+		if t.ID == current {
+			marker = "→"
+		}
+		origin := "original"
+		if attempt := t.Metadata[task.MetadataRetryAttempt]; attempt != "" {
+			origin = fmt.Sprintf("retry %s (%s)", attempt, t.Metadata[task.MetadataRetryMode])
+		}
+		fmt.Fprintf(out, "  %s %s  %-9s  %s\n", marker, t.ID, t.Status, origin)
+	}
+}
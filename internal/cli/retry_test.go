@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksRetryCmd_FailedOnly(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	original := seedTask(t, task.TaskStatusFailed)
+
+	out, err := runCLI(t, "tasks", "retry", original.ID, "--failed-only", "--approve-all")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Retrying task "+original.ID)
+	assert.Contains(t, out, "Tasks completed: 1", "only the step that did not succeed runs again")
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	chain, err := task.RetryChain(storage, original.ID)
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+	retry := chain[1]
+	assert.Equal(t, task.TaskStatusCompleted, retry.Status)
+	assert.Equal(t, original.ID, retry.Metadata[task.MetadataRetryOf])
+	require.Len(t, retry.Results, 2)
+	assert.Equal(t, "task-1", retry.Results[0].TaskID)
+	assert.Equal(t, "task-2", retry.Results[1].TaskID)
+
+	out, err = runCLI(t, "tasks", "show", retry.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Retry chain:")
+	assert.Regexp(t, `  `+original.ID+`\s+failed\s+original`, out)
+	assert.Regexp(t, `→ `+retry.ID+`\s+completed\s+retry 1 \(failed-only\)`, out)
+}
+
+func TestTasksRetryCmd_Errors(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	completed := seedTask(t, task.TaskStatusCompleted)
+	failed := seedTask(t, task.TaskStatusFailed)
+
+	_, err := runCLI(t, "tasks", "retry", completed.ID)
+	assert.ErrorContains(t, err, "only failed, cancelled or interrupted tasks can be retried")
+
+	_, err = runCLI(t, "tasks", "retry", failed.ID)
+	assert.ErrorContains(t, err, "OpenAI is not configured")
+
+	out, err := runCLI(t, "tasks", "show", failed.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "Retry chain:", "tasks without retries show no chain")
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/git"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// gitTracker snapshots the git repository a task runs in before it starts and after every
+// step, attaching each step's changes to its result as a diff artifact
+type gitTracker struct {
+	ctx    context.Context
+	repo   *git.Repo
+	record *task.TaskExecution
+	last   string
+}
+
+// startGitTracking snapshots the repository containing the working directory before a task
+// runs. It returns nil when snapshots are disabled, there is no repository or the snapshot fails.
+func startGitTracking(ctx context.Context, cfg *config.Config, record *task.TaskExecution, logger *zap.Logger) *gitTracker {
+	if !cfg.Execution.GitSnapshots {
+		return nil
+	}
+	// Snapshots are still taken when the run is interrupted, so record what happened until then
+	ctx = context.WithoutCancel(ctx)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	repo, err := git.Open(ctx, dir)
+	if err != nil {
+		if !errors.Is(err, git.ErrNotRepository) {
+			logger.Debug("Git change tracking unavailable", zap.Error(err))
+		}
+		return nil
+	}
+
+	snapshot, err := repo.Snapshot(ctx, "capn: before task "+record.ID)
+	if err != nil {
+		record.AddLog(task.LogLevelWarn, fmt.Sprintf("Changes to %s will not be tracked: %v", repo.Dir(), err))
+		return nil
+	}
+	tracker := &gitTracker{ctx: ctx, repo: repo, record: record, last: snapshot}
+
+	// A resumed task keeps its first snapshot so a rollback undoes all of its runs
+	if record.Metadata[task.MetadataGitBefore] != "" && record.Metadata[task.MetadataGitRepo] == repo.Dir() {
+		return tracker
+	}
+	if err := repo.SetRef(ctx, task.GitSnapshotRef(record.ID, "before"), snapshot); err != nil {
+		record.AddLog(task.LogLevelWarn, fmt.Sprintf("Changes to %s will not be tracked: %v", repo.Dir(), err))
+		return nil
+	}
+	record.Metadata[task.MetadataGitRepo] = repo.Dir()
+	record.Metadata[task.MetadataGitBefore] = snapshot
+	delete(record.Metadata, task.MetadataGitAfter)
+	record.AddLog(task.LogLevelInfo, fmt.Sprintf("Snapshot of %s taken before execution (%s)", repo.Dir(), shortCommit(snapshot)))
+	return tracker
+}
+
+// afterStep records the changes a step made since the previous snapshot as a diff artifact
+func (g *gitTracker) afterStep(step captain.Task, result *captain.Result) {
+	snapshot, err := g.repo.Snapshot(g.ctx, fmt.Sprintf("capn: task %s after step %s", g.record.ID, step.ID))
+	if err != nil {
+		g.record.AddStepLog(task.LogLevelWarn, step.ID, "", fmt.Sprintf("Failed to snapshot changes: %v", err))
+		return
+	}
+	patch, err := g.repo.Diff(g.ctx, g.last, snapshot)
+	g.last = snapshot
+	if err != nil {
+		g.record.AddStepLog(task.LogLevelWarn, step.ID, "", fmt.Sprintf("Failed to record changes: %v", err))
+		return
+	}
+	if patch != "" {
+		result.Artifacts = append(result.Artifacts, agents.Artifact{Name: step.ID + ".diff", Kind: agents.ArtifactKindDiff, Content: []byte(patch)})
+	}
+}
+
+// finish snapshots the repository after the task ran so its changes can be rolled back
+func (g *gitTracker) finish() {
+	snapshot, err := g.repo.Snapshot(g.ctx, "capn: after task "+g.record.ID)
+	if err == nil {
+		err = g.repo.SetRef(g.ctx, task.GitSnapshotRef(g.record.ID, "after"), snapshot)
+	}
+	if err != nil {
+		g.record.AddLog(task.LogLevelWarn, fmt.Sprintf("Failed to snapshot %s after execution; the task cannot be rolled back: %v", g.repo.Dir(), err))
+		return
+	}
+	g.record.Metadata[task.MetadataGitAfter] = snapshot
+
+	files, err := g.repo.ChangedFiles(g.ctx, g.record.Metadata[task.MetadataGitBefore], snapshot)
+	if err == nil {
+		g.record.AddLog(task.LogLevelInfo, fmt.Sprintf("Task changed %d file(s) in %s", len(files), g.repo.Dir()))
+	}
+}
+
+// shortCommit abbreviates a snapshot commit for display
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// TasksRollbackCmd represents the tasks rollback command
+type TasksRollbackCmd struct {
+	TaskID string `arg:"" name:"task-id" help:"Task whose changes to revert"`
+}
+
+// Help returns detailed help for the tasks rollback command
+func (r *TasksRollbackCmd) Help() string {
+	return `Revert the changes a task made to the git repository it ran in. Before a task
+runs, capn snapshots the working tree (including untracked files) under
+refs/capn/tasks/<task-id>/ without touching the index or branches, and records
+each step's changes as a diff artifact.
+
+Rolling back reverses exactly the task's changes, leaving later unrelated edits
+alone. If a file the task changed has since been edited, nothing is reverted
+and the conflict is reported. With --dry-run, the files are listed only.
+
+Examples:
+
+    capn tasks rollback task-1a2b3c4d
+    capn tasks rollback task-1a2b3c4d --dry-run`
+}
+
+func (r *TasksRollbackCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	record, err := storage.GetTask(r.TaskID)
+	if err != nil {
+		return err
+	}
+	dir, before, after, ok := record.GitChanges()
+	if !ok {
+		return fmt.Errorf("task %s has no recorded git changes to roll back", record.ID)
+	}
+	if at := record.Metadata[task.MetadataGitRolledBack]; at != "" {
+		return fmt.Errorf("task %s was already rolled back at %s", record.ID, at)
+	}
+
+	repo, err := git.Open(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("failed to open repository of task %s: %w", record.ID, err)
+	}
+	files, err := repo.ChangedFiles(ctx, before, after)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "Task %s made no changes to %s\n", record.ID, dir)
+		return nil
+	}
+	patch, err := repo.Diff(ctx, before, after)
+	if err != nil {
+		return err
+	}
+	if err := repo.ReverseApply(ctx, patch, true); err != nil {
+		return fmt.Errorf("cannot roll back task %s cleanly; files it changed were edited since: %w", record.ID, err)
+	}
+
+	verb := "Reverted"
+	if globals.DryRun {
+		verb = "Would revert"
+	} else {
+		if err := repo.ReverseApply(ctx, patch, false); err != nil {
+			return fmt.Errorf("failed to roll back task %s: %w", record.ID, err)
+		}
+		record.Metadata[task.MetadataGitRolledBack] = clock.Now().Format(time.RFC3339)
+		record.AddLog(task.LogLevelInfo, fmt.Sprintf("Rolled back changes to %d file(s) in %s", len(files), dir))
+		saveTask(storage, record, logger)
+	}
+
+	fmt.Fprintf(out, "%s changes to %d file(s) in %s made by task %s:\n", verb, len(files), dir, record.ID)
+	for _, file := range files {
+		fmt.Fprintf(out, "  %s\n", file)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// enterTestRepo creates a git repository with a committed README.md and makes it the working directory
+func enterTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0o644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "README.md"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	return resolved
+}
+
+// trackedTask runs a fake two-step task under git tracking: the first step edits README.md,
+// the second adds a file
+func trackedTask(t *testing.T, dir string) *task.TaskExecution {
+	t.Helper()
+	cfg := config.NewConfig()
+	storage, err := task.NewFileTaskStorage(cfg.TasksDir())
+	require.NoError(t, err)
+	record := task.NewTaskExecution("edit the readme")
+
+	tracker := startGitTracking(context.Background(), cfg, record, zap.NewNop())
+	require.NotNil(t, tracker)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\nworld\n"), 0o644))
+	first := captain.Result{TaskID: "step-1", Success: true}
+	tracker.afterStep(captain.Task{ID: "step-1"}, &first)
+	require.Len(t, first.Artifacts, 1)
+	assert.Equal(t, "step-1.diff", first.Artifacts[0].Name)
+	assert.Equal(t, agents.ArtifactKindDiff, first.Artifacts[0].Kind)
+	assert.Contains(t, string(first.Artifacts[0].Content), "+world")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "NOTES.md"), []byte("notes\n"), 0o644))
+	second := captain.Result{TaskID: "step-2", Success: true}
+	tracker.afterStep(captain.Task{ID: "step-2"}, &second)
+	require.Len(t, second.Artifacts, 1)
+	assert.NotContains(t, string(second.Artifacts[0].Content), "world", "each diff only holds its step's changes")
+
+	unchanged := captain.Result{TaskID: "step-3", Success: true}
+	tracker.afterStep(captain.Task{ID: "step-3"}, &unchanged)
+	assert.Empty(t, unchanged.Artifacts)
+
+	tracker.finish()
+	record.SetStatus(task.TaskStatusCompleted)
+	require.NoError(t, storage.SaveTask(record))
+	return record
+}
+
+func TestTasksRollbackCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	dir := enterTestRepo(t)
+	record := trackedTask(t, dir)
+
+	repo, before, after, ok := record.GitChanges()
+	require.True(t, ok)
+	assert.Equal(t, dir, repo)
+	assert.NotEqual(t, before, after)
+
+	output, err := runCLI(t, "--dry-run", "tasks", "rollback", record.ID)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Would revert changes to 2 file(s)")
+	assert.FileExists(t, filepath.Join(dir, "NOTES.md"))
+
+	// Unrelated edits made after the task are kept
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), []byte("mine\n"), 0o644))
+	output, err = runCLI(t, "tasks", "rollback", record.ID)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Reverted changes to 2 file(s)")
+	assert.Contains(t, output, "  NOTES.md\n  README.md\n")
+
+	readme, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(readme))
+	assert.NoFileExists(t, filepath.Join(dir, "NOTES.md"))
+	assert.FileExists(t, filepath.Join(dir, "other.txt"))
+
+	_, err = runCLI(t, "tasks", "rollback", record.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "was already rolled back")
+
+	output, err = runCLI(t, "tasks", "show", record.ID)
+	require.NoError(t, err)
+	assert.Contains(t, output, "(rolled back ")
+}
+
+func TestTasksRollbackCmd_Conflict(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	dir := enterTestRepo(t)
+	record := trackedTask(t, dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("rewritten by hand\n"), 0o644))
+	_, err := runCLI(t, "tasks", "rollback", record.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot roll back task "+record.ID+" cleanly")
+	assert.FileExists(t, filepath.Join(dir, "NOTES.md"), "nothing is reverted on conflict")
+}
+
+func TestTasksRollbackCmd_Untracked(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	record := seedTask(t, task.TaskStatusCompleted)
+
+	_, err := runCLI(t, "tasks", "rollback", record.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has no recorded git changes to roll back")
+}
+
+func TestStartGitTracking_Disabled(t *testing.T) {
+	enterTestRepo(t)
+	cfg := config.NewConfig()
+	cfg.Execution.GitSnapshots = false
+	assert.Nil(t, startGitTracking(context.Background(), cfg, task.NewTaskExecution("goal"), zap.NewNop()))
+
+	require.NoError(t, os.Chdir(t.TempDir()))
+	cfg.Execution.GitSnapshots = true
+	assert.Nil(t, startGitTracking(context.Background(), cfg, task.NewTaskExecution("goal"), zap.NewNop()), "no repository")
+}
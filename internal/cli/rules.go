@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// reportRuleViolations prints and records the violations when err is a plan rule failure
+func reportRuleViolations(out io.Writer, record *task.TaskExecution, err error) {
+	var violationErr *captain.RuleViolationError
+	if !errors.As(err, &violationErr) {
+		return
+	}
+	printRuleViolations(out, violationErr.Report)
+	for _, violation := range violationErr.Report.Violations {
+		record.AddStepLog(task.LogLevelError, violation.TaskID, "", "Plan rule "+violation.String())
+	}
+}
+
+// printRuleViolations lists the plan rules a plan broke
+func printRuleViolations(out io.Writer, report *captain.ValidationReport) {
+	fmt.Fprintf(out, "=== Plan Rule Violations ===\n")
+	for _, violation := range report.Violations {
+		if violation.TaskID != "" {
+			fmt.Fprintf(out, "  [%s] %s: %s\n", violation.Rule, violation.TaskID, violation.Message)
+		} else {
+			fmt.Fprintf(out, "  [%s] %s\n", violation.Rule, violation.Message)
+		}
+	}
+	settings := "captain.rules in the config"
+	for _, violation := range report.Violations {
+		if violation.Rule == (captain.SandboxRule{}).Name() {
+			settings = "captain.rules or crew.sandbox in the config"
+			break
+		}
+		if violation.Rule == (captain.RepoRule{}).Name() {
+			settings = "captain.rules or crew.code in the config"
+			break
+		}
+	}
+	for _, violation := range report.Violations {
+		if violation.Rule == (captain.AllowedToolsRule{}).Name() {
+			settings = "--tools"
+			break
+		}
+	}
+	for _, violation := range report.Violations {
+		if violation.Rule == (captain.PolicyRule{}).Name() {
+			settings = "the config or " + captain.PolicyFile
+			break
+		}
+	}
+	fmt.Fprintf(out, "%d violation(s) of %d rule(s); adjust %s or rephrase the goal.\n",
+		len(report.Violations), len(report.Rules), settings)
+}
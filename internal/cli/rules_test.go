@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestReportRuleViolations(t *testing.T) {
+	report := &captain.ValidationReport{
+		PlanID: "plan-1",
+		Rules:  []string{"max_tasks", "forbidden_commands"},
+		Violations: []captain.Violation{
+			{Rule: "max_tasks", Message: "plan has 12 tasks, limit is 10"},
+			{Rule: "forbidden_commands", TaskID: "task-4", Message: `uses forbidden command "sudo"`},
+		},
+	}
+	err := fmt.Errorf("failed to create plan: %w", &captain.RuleViolationError{Report: report})
+
+	var out bytes.Buffer
+	record := task.NewTaskExecution("goal")
+	reportRuleViolations(&out, record, err)
+
+	assert.Equal(t, `=== Plan Rule Violations ===
+  [max_tasks] plan has 12 tasks, limit is 10
+  [forbidden_commands] task-4: uses forbidden command "sudo"
+2 violation(s) of 2 rule(s); adjust captain.rules in the config or rephrase the goal.
+`, out.String())
+	require.Len(t, record.Logs, 2)
+	assert.Equal(t, "task-4", record.Logs[1].Step)
+	assert.Equal(t, task.LogLevelError, record.Logs[1].Level)
+
+	out.Reset()
+	reportRuleViolations(&out, record, fmt.Errorf("boom"))
+	assert.Empty(t, out.String(), "other errors are left to the caller")
+}
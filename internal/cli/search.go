@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// maxMatchesShown bounds the matches printed for each task
+const maxMatchesShown = 5
+
+// snippetContext is how many bytes of text are shown before a highlighted match
+const snippetContext = 40
+
+// snippetLength bounds the text shown for a match
+const snippetLength = 160
+
+// ANSI sequences that highlight matched text
+const (
+	highlightStart = "\x1b[1;31m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// SearchCmd represents the search command
+type SearchCmd struct {
+	Query         string        `arg:"" help:"Search query"`
+	Status        []string      `help:"Only search tasks with these statuses" enum:"pending,queued,planning,running,completed,failed,cancelled,interrupted" sep:","`
+	Since         time.Duration `help:"Only search tasks created within this long ago (e.g. 24h)"`
+	Limit         int           `help:"Maximum number of tasks to show" default:"20"`
+	Color         string        `help:"Highlight matches: auto highlights on a terminal" enum:"auto,always,never" default:"auto"`
+	AllWorkspaces bool          `name:"all-workspaces" help:"Search tasks from every workspace, not just the current one"`
+}
+
+// Help returns detailed help for the search command
+func (s *SearchCmd) Help() string {
+	return `Search goals, plans, results, questions, logs and agent messages of the
+tasks recorded in the current workspace, newest first, showing where each task
+matched. Use --all-workspaces to search every task.
+
+Every term of the query must match. Words and "quoted phrases" are matched
+case-insensitively and /slashes/ make a regular expression. A term can be
+scoped to a field as field:value:
+
+    id, status, tag, goal, plan  match the task
+    agent, level, step, from,    must all match the same log entry; other
+    to, log, message             terms are then searched in those entries
+
+id, status, tag, level and step must match whole values.
+
+Examples:
+
+    capn search timeout
+    capn search "agent:FileAgent level:error"
+    capn search 'goal:deploy /exit (status|code) [1-9]/'
+    capn search "from:captain staging" --status failed --since 24h`
+}
+
+func (s *SearchCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	query, err := task.ParseSearchQuery(s.Query)
+	if err != nil {
+		return err
+	}
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := listingWorkspace(globals, s.AllWorkspaces)
+	if err != nil {
+		return err
+	}
+	filter := task.TaskFilter{Limit: s.Limit, Workspace: workspace}
+	if s.Since > 0 {
+		filter.Since = clock.Now().Add(-s.Since)
+	}
+	for _, status := range s.Status {
+		filter.Status = append(filter.Status, task.TaskStatus(status))
+	}
+	results, err := task.Search(storage, query, filter)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		notef(out, globals, "No matching tasks.")
+		return nil
+	}
+
+	color := s.Color == "always"
+	if s.Color == "auto" {
+		f, ok := out.(*os.File)
+		color = ok && isTerminal(f)
+	}
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		t := result.Task
+		fmt.Fprintf(out, "%s  %s  %s  %s\n", t.ID, t.Status, t.CreatedAt.Format("2006-01-02 15:04"), truncate(t.Goal, 60))
+		for j, match := range result.Matches {
+			if j == maxMatchesShown {
+				fmt.Fprintf(out, "  … %d more match(es)\n", len(result.Matches)-maxMatchesShown)
+				break
+			}
+			fmt.Fprintf(out, "  %s: %s\n", matchLabel(match), snippet(match, color))
+		}
+	}
+	notef(out, globals, "\n%d matching task(s)", len(results))
+	return nil
+}
+
+// matchLabel names where a match was found, with its step and agent
+func matchLabel(match task.SearchMatch) string {
+	label := match.Kind
+	if match.Step != "" {
+		label += " [" + match.Step + "]"
+	}
+	if match.Agent != "" {
+		label += " " + match.Agent
+	}
+	return label
+}
+
+// snippet returns the text around a match's first highlighted range on one line, with the
+// ranges highlighted when color is set
+func snippet(match task.SearchMatch, color bool) string {
+	text := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, match.Text)
+
+	start := 0
+	if len(match.Spans) > 0 && match.Spans[0][0] > snippetContext {
+		start = match.Spans[0][0] - snippetContext
+	}
+	end := min(len(text), start+snippetLength)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	at := start
+	for _, span := range match.Spans {
+		from, to := max(span[0], at), min(span[1], end)
+		if from >= to {
+			continue
+		}
+		b.WriteString(text[at:from])
+		if color {
+			b.WriteString(highlightStart + text[from:to] + highlightEnd)
+		} else {
+			b.WriteString(text[from:to])
+		}
+		at = to
+	}
+	b.WriteString(text[at:end])
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestSearchCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	record := seedTask(t, task.TaskStatusFailed)
+	seedTask(t, task.TaskStatusCompleted)
+
+	output, err := runCLI(t, "search", "analysis")
+	require.NoError(t, err)
+	assert.Contains(t, output, record.ID+"  failed  ")
+	assert.Contains(t, output, "  plan [task-1]: Run analysis\n")
+	assert.Contains(t, output, "  log [task-1] research-001: analysis finished\n")
+	assert.Contains(t, output, "2 matching task(s)")
+	assert.NotContains(t, output, highlightStart, "output that is not a terminal is not highlighted")
+
+	output, err = runCLI(t, "search", "agent:research level:info", "--status", "failed", "--color", "always")
+	require.NoError(t, err)
+	assert.Contains(t, output, "  log [task-1] research-001: analysis finished\n")
+	assert.Contains(t, output, "1 matching task(s)")
+
+	output, err = runCLI(t, "search", "/analy[a-z]+/", "--color", "always", "--limit", "1")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Run "+highlightStart+"analysis"+highlightEnd)
+	assert.Contains(t, output, "1 matching task(s)")
+
+	output, err = runCLI(t, "search", "kubernetes")
+	require.NoError(t, err)
+	assert.Equal(t, "No matching tasks.\n", output)
+
+	_, err = runCLI(t, "search", "level:/(/")
+	assert.ErrorContains(t, err, "invalid regular expression")
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("x", 100) + " needle " + strings.Repeat("y", 200)
+	tests := []struct {
+		name  string
+		match task.SearchMatch
+		color bool
+		want  string
+	}{
+		{
+			name:  "highlighted",
+			match: task.SearchMatch{Text: "a timeout\nafter 30s", Spans: [][2]int{{2, 9}, {16, 19}}},
+			color: true,
+			want:  "a " + highlightStart + "timeout" + highlightEnd + " after " + highlightStart + "30s" + highlightEnd,
+		},
+		{
+			name:  "plain",
+			match: task.SearchMatch{Text: "a timeout", Spans: [][2]int{{2, 9}}},
+			want:  "a timeout",
+		},
+		{
+			name:  "windowed around the first match",
+			match: task.SearchMatch{Text: long, Spans: [][2]int{{101, 107}}},
+			want:  "…" + strings.Repeat("x", 39) + " needle " + strings.Repeat("y", 113) + "…",
+		},
+		{
+			name:  "cut on rune boundaries",
+			match: task.SearchMatch{Text: strings.Repeat("é", 30) + "needle", Spans: [][2]int{{60, 66}}},
+			want:  "…" + strings.Repeat("é", 20) + "needle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, snippet(tt.match, tt.color))
+		})
+	}
+}
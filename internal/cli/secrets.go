@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/secrets"
+)
+
+// secretInput is where secret values are read from when not given as an argument
+var secretInput io.Reader = os.Stdin
+
+// SecretsCmd groups the secret management commands
+type SecretsCmd struct {
+	Set    SecretsSetCmd    `cmd:"" help:"Store a secret"`
+	Delete SecretsDeleteCmd `cmd:"" help:"Delete a stored secret"`
+	List   SecretsListCmd   `cmd:"" help:"List secrets and where they resolve from"`
+}
+
+// SecretsSetCmd represents the secrets set command
+type SecretsSetCmd struct {
+	Key      string `arg:"" help:"Secret key, e.g. openai.api_key"`
+	Value    string `arg:"" optional:"" help:"Secret value (read from stdin when omitted)"`
+	Provider string `help:"Provider to store the secret in (default: keychain when available, else the encrypted file)" enum:",keychain,file" default:""`
+}
+
+// Help returns detailed help for the secrets set command
+func (s *SecretsSetCmd) Help() string {
+	return `Store a secret in the OS keychain or the encrypted secrets file. Secrets are
+resolved when the configuration loads: an empty openai.api_key is filled from
+the stored value, and any value written as "secret:<key>" must resolve.
+
+Lookup order is environment (CAPN_SECRET_<KEY>), keychain, then file, and can
+be changed with secrets.providers in the config or CAPN_SECRET_PROVIDERS.
+
+The secrets file is encrypted with a key derived from the passphrase in
+CAPN_SECRETS_PASSPHRASE, which must be set to read or change it; without it
+the file is skipped.
+
+Examples:
+
+    capn secrets set openai.api_key
+    echo "$KEY" | capn secrets set openai.api_key --provider file`
+}
+
+func (s *SecretsSetCmd) Run(out io.Writer, logger *zap.Logger, config *config.Config) error {
+	if err := secrets.ValidateKey(s.Key); err != nil {
+		return err
+	}
+	provider, err := secretProvider(config, s.Provider)
+	if err != nil {
+		return err
+	}
+
+	value := s.Value
+	if value == "" {
+		if f, ok := secretInput.(*os.File); ok && isTerminal(f) {
+			fmt.Fprintf(out, "Value for %s: ", s.Key)
+		}
+		line, err := bufio.NewReader(secretInput).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read secret value: %w", err)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	if value == "" {
+		return fmt.Errorf("secret value cannot be empty")
+	}
+
+	if err := provider.Set(s.Key, value); err != nil {
+		return fmt.Errorf("failed to store secret: %w", err)
+	}
+	logger.Debug("Secret stored", zap.String("key", s.Key), zap.String("provider", provider.Name()))
+	fmt.Fprintf(out, "Secret %s stored in %s.\n", s.Key, provider.Name())
+	return nil
+}
+
+// SecretsDeleteCmd represents the secrets delete command
+type SecretsDeleteCmd struct {
+	Key      string `arg:"" help:"Secret key to delete"`
+	Provider string `help:"Provider to delete the secret from (default: keychain when available, else the encrypted file)" enum:",keychain,file" default:""`
+}
+
+func (d *SecretsDeleteCmd) Run(out io.Writer, config *config.Config) error {
+	if err := secrets.ValidateKey(d.Key); err != nil {
+		return err
+	}
+	provider, err := secretProvider(config, d.Provider)
+	if err != nil {
+		return err
+	}
+	if err := provider.Delete(d.Key); err != nil {
+		if errors.Is(err, secrets.ErrNotFound) {
+			return fmt.Errorf("secret not found in %s: %s", provider.Name(), d.Key)
+		}
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+	fmt.Fprintf(out, "Secret %s deleted from %s.\n", d.Key, provider.Name())
+	return nil
+}
+
+// SecretsListCmd represents the secrets list command
+type SecretsListCmd struct{}
+
+func (l *SecretsListCmd) Run(out io.Writer, globals *GlobalOptions, cfg *config.Config) error {
+	chain, err := openSecrets(cfg)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for _, key := range config.SecretKeys() {
+		keys[key] = true
+	}
+	for _, provider := range chain {
+		if file, ok := provider.(*secrets.FileProvider); ok {
+			stored, err := file.Keys()
+			if err != nil {
+				return err
+			}
+			for _, key := range stored {
+				keys[key] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	listing := Listing{Columns: []string{"KEY", "SOURCE"}}
+	for _, key := range sorted {
+		source := "not set"
+		if _, provider, err := chain.Resolve(key); err == nil {
+			source = provider
+		} else if !errors.Is(err, secrets.ErrNotFound) {
+			source = "error: " + err.Error()
+		}
+		listing.Rows = append(listing.Rows, []string{key, source})
+	}
+	return newPresenter(out, globals).List(listing)
+}
+
+// openSecrets builds the secret provider chain configured for this invocation
+func openSecrets(cfg *config.Config) (secrets.Chain, error) {
+	chain, err := secrets.NewChain(cfg.SecretProviders(), config.HomeDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure secret providers: %w", err)
+	}
+	return chain, nil
+}
+
+// secretProvider returns the named provider, or the first writable one in the chain
+func secretProvider(cfg *config.Config, name string) (secrets.Provider, error) {
+	if name != "" {
+		return secrets.NewProvider(name, config.HomeDir())
+	}
+	chain, err := openSecrets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return chain.Writable()
+}
+
+// resolveSecrets fills secret-backed configuration fields and revalidates the result
+func resolveSecrets(cfg *config.Config) error {
+	chain, err := openSecrets(cfg)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ResolveSecrets(chain); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/secrets"
+)
+
+func TestSecretsCommands(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv(secrets.PassphraseEnv, "correct horse")
+
+	output, err := runCLI(t, "secrets", "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `openai\.api_key\s+not set`, output)
+
+	output, err = runCLI(t, "secrets", "set", "openai.api_key", "sk-test", "--provider", "file")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Secret openai.api_key stored in file.")
+
+	output, err = runCLI(t, "secrets", "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `openai\.api_key\s+file`, output)
+
+	cfg := config.NewConfig()
+	require.NoError(t, resolveSecrets(cfg))
+	assert.Equal(t, "sk-test", cfg.OpenAI.APIKey, "stored secrets fill the config at load time")
+
+	t.Setenv("CAPN_SECRET_OPENAI_API_KEY", "sk-env")
+	output, err = runCLI(t, "secrets", "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `openai\.api_key\s+env`, output, "the environment takes precedence")
+
+	output, err = runCLI(t, "secrets", "delete", "openai.api_key", "--provider", "file")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Secret openai.api_key deleted from file.")
+
+	_, err = runCLI(t, "secrets", "delete", "openai.api_key", "--provider", "file")
+	assert.EqualError(t, err, "secret not found in file: openai.api_key")
+}
+
+func TestSecretsSetCmd_Stdin(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv(secrets.PassphraseEnv, "correct horse")
+	defer func(r io.Reader) { secretInput = r }(secretInput)
+
+	secretInput = strings.NewReader("sk-from-stdin\n")
+	_, err := runCLI(t, "secrets", "set", "github.token")
+	require.NoError(t, err)
+
+	output, err := runCLI(t, "secrets", "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `github\.token\s+file`, output, "without a keychain the encrypted file is used")
+
+	secretInput = strings.NewReader("\n")
+	_, err = runCLI(t, "secrets", "set", "github.token")
+	assert.EqualError(t, err, "secret value cannot be empty")
+
+	_, err = runCLI(t, "secrets", "set", "Bad Key", "x")
+	assert.Error(t, err)
+}
+
+func TestResolveSecrets_MissingReference(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	cfg := config.NewConfig()
+	cfg.OpenAI.APIKey = "secret:openai.missing"
+	assert.ErrorContains(t, resolveSecrets(cfg), "failed to resolve openai.api_key")
+}
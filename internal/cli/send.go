@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/daemon"
+)
+
+// AgentsSendCmd represents the agents send command
+type AgentsSendCmd struct {
+	Agent   string        `arg:"" name:"agent-id" help:"Registered agent to send the message to"`
+	Content string        `arg:"" help:"Message content"`
+	Type    string        `help:"Message type" enum:"text,command,status,result" default:"text"`
+	Task    string        `help:"Record the exchange in this task's log too" placeholder:"TASK-ID"`
+	Wait    time.Duration `help:"How long to wait for the agent's reply" default:"5s"`
+}
+
+// Help returns detailed help for the agents send command
+func (s *AgentsSendCmd) Help() string {
+	return `Send a message to an agent registered with the running daemon, from the
+"operator", through the same message router the agents use. The message and
+any reply are logged like the agents' own messages: they appear in "capn
+agents history", on the dashboard's message feed and, with --task, in that
+task's log.
+
+A reply is a message back to the operator whose in_reply_to data names the
+ID of the message sent; it is printed when it arrives within --wait. Built-in
+agents keep the messages they receive without replying; plugin agents may
+answer with a send frame.
+
+Examples:
+
+    capn agents send file-001 --type command "re-scan ./src"
+    capn agents send plugin-lint-001 "status?" --wait 30s
+    capn agents send research-001 "prefer primary sources" --task task-1a2b3c4d`
+}
+
+func (s *AgentsSendCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	if s.Wait < 0 {
+		return fmt.Errorf("--wait cannot be negative")
+	}
+	var result daemon.SendResult
+	args := map[string]string{"agent": s.Agent, "content": s.Content, "type": s.Type, "task": s.Task, "wait": s.Wait.String()}
+	// The reply comes once the agent has answered or the wait is over
+	if err := callDaemon(ctx, config, "send", args, &result, s.Wait+ctlTimeout); err != nil {
+		return err
+	}
+	return newPresenter(out, globals).Show(result, func(w io.Writer) error {
+		fmt.Fprintf(w, "Sent %s message %s to %s\n", result.Message.Type, result.Message.ID, result.Message.To)
+		if result.Reply == nil {
+			fmt.Fprintf(w, "No reply within %s\n", s.Wait)
+			return nil
+		}
+		fmt.Fprintf(w, "Reply from %s (%s): %s\n", result.Reply.From, result.Reply.Type, result.Reply.Content)
+		return nil
+	})
+}
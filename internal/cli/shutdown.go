@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// shutdownSignals are the signals that trigger a graceful shutdown
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// signalContext returns a context cancelled by the first shutdown signal.
+// Default signal handling is restored once it fires, so a second signal exits immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// printInterruption reports which plan steps completed, were interrupted or never started
+func printInterruption(out io.Writer, taskID string, plan *captain.ExecutionPlan, results []captain.Result) {
+	reasons := make(map[string]string, len(results))
+	completed := 0
+	for _, result := range results {
+		reasons[result.TaskID] = result.Error
+		if result.Success {
+			completed++
+		}
+	}
+	statuses := plan.StepStatuses(results)
+
+	fmt.Fprintf(out, "=== Interrupted ===\n")
+	fmt.Fprintf(out, "Task %s was cancelled: %d/%d steps completed.\n", taskID, completed, len(plan.Tasks))
+	for _, step := range plan.Tasks {
+		switch statuses[step.ID] {
+		case captain.StepStatusPending:
+			fmt.Fprintf(out, "  - %s not started\n", step.ID)
+		case captain.StepStatusSucceeded:
+			fmt.Fprintf(out, "  ✓ %s completed\n", step.ID)
+		case captain.StepStatusInterrupted:
+			fmt.Fprintf(out, "  ! %s interrupted (checkpointed)\n", step.ID)
+		case captain.StepStatusSkipped:
+			fmt.Fprintf(out, "  ~ %s skipped\n", step.ID)
+		default:
+			fmt.Fprintf(out, "  ✗ %s failed: %s\n", step.ID, reasons[step.ID])
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func TestPrintInterruption(t *testing.T) {
+	plan := &captain.ExecutionPlan{
+		ID: "plan-1",
+		Tasks: []captain.Task{
+			{ID: "step-1"}, {ID: "step-2"}, {ID: "step-3"}, {ID: "step-4"},
+		},
+	}
+	results := []captain.Result{
+		{TaskID: "step-1", Success: true},
+		{TaskID: "step-2", Success: false, Error: "boom"},
+		{TaskID: "step-3", Success: false, Metadata: map[string]any{"interrupted": true}},
+	}
+
+	var buf bytes.Buffer
+	printInterruption(&buf, "task-1", plan, results)
+	out := buf.String()
+
+	assert.Contains(t, out, "Task task-1 was cancelled: 1/4 steps completed.")
+	assert.Contains(t, out, "✓ step-1 completed")
+	assert.Contains(t, out, "✗ step-2 failed: boom")
+	assert.Contains(t, out, "! step-3 interrupted (checkpointed)")
+	assert.Contains(t, out, "- step-4 not started")
+}
+
+func TestSignalContext_Stop(t *testing.T) {
+	ctx, stop := signalContext()
+	assert.NoError(t, ctx.Err())
+
+	stop()
+	<-ctx.Done()
+	assert.Error(t, ctx.Err())
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// printSimulation renders the timeline of a simulated run: when each step would start and end
+// on which mock agent, and how many steps run at once over time
+func printSimulation(out io.Writer, sim *captain.Simulation) {
+	fmt.Fprintf(out, "\n=== Simulated Timeline ===\n")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tSTEP\tAGENT\tDESCRIPTION")
+	for _, step := range sim.Steps {
+		description := step.Description
+		if step.Approval {
+			description += " (waits for approval)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", offset(step.Start), offset(step.End), step.TaskID, step.AgentID, description)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nParallelism (up to %d at once):\n", sim.MaxParallel)
+	for _, interval := range sim.Intervals {
+		fmt.Fprintf(out, "  %s to %s: %d running (%s)\n", offset(interval.Start), offset(interval.End),
+			len(interval.Running), strings.Join(interval.Running, ", "))
+	}
+	fmt.Fprintf(out, "\n%s\n", simulationSummary(sim))
+}
+
+// simulationSummary renders a one-line summary of a simulated run
+func simulationSummary(sim *captain.Simulation) string {
+	return fmt.Sprintf("Simulated %d steps in %s with up to %d running at once (%s one after another)",
+		len(sim.Steps), sim.Duration, sim.Peak, sim.Sequential)
+}
+
+// offset formats a time since the start of a simulated run, as "+5m0s"
+func offset(d time.Duration) string {
+	return "+" + d.String()
+}
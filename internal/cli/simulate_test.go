@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+const simulatedPlan = `goal: ship the release
+strategy:
+  type: parallel
+timeline:
+  estimated_duration: 30m
+tasks:
+  - id: build
+    type: execution
+    payload:
+      description: build the binaries
+  - id: test
+    type: validation
+    dependencies: [build]
+    payload:
+      description: run the test suite
+    metadata:
+      estimated_duration: 20m
+  - id: notes
+    type: reporting
+    dependencies: [build]
+    payload:
+      description: write the release notes
+  - id: publish
+    type: execution
+    dependencies: [test, notes]
+    payload:
+      description: publish the release
+`
+
+func TestExecuteCmd_Simulate(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(simulatedPlan), 0o644))
+
+	out, err := runCLI(t, "--parallel", "2", "execute", "--simulate", "--from-plan", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "=== Simulated Timeline ===")
+	assert.Regexp(t, `\+0s\s+\+7m30s\s+build\s+file-sim-1\s+build the binaries`, out)
+	assert.Regexp(t, `\+7m30s\s+\+27m30s\s+test\s+file-sim-1\s+run the test suite`, out)
+	assert.Regexp(t, `\+7m30s\s+\+15m0s\s+notes\s+research-sim-1\s+write the release notes`, out)
+	assert.Regexp(t, `\+27m30s\s+\+35m0s\s+publish\s+file-sim-1\s+publish the release \(waits for approval\)`, out)
+	assert.Contains(t, out, "Parallelism (up to 2 at once):\n  +0s to +7m30s: 1 running (build)\n")
+	assert.Contains(t, out, "  +7m30s to +15m0s: 2 running (test, notes)\n")
+	assert.Contains(t, out, "Simulated 4 steps in 35m0s with up to 2 running at once (42m30s one after another)")
+	assert.Contains(t, out, "Note: This is a simulation; no step was run.")
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task.TaskStatusCompleted, tasks[0].Status)
+	assert.Empty(t, tasks[0].Results, "no step was run")
+
+	out, err = runCLI(t, "--parallel", "1", "execute", "--simulate", "--from-plan", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Simulated 4 steps in 42m30s with up to 1 running at once")
+}
+
+func TestExecuteCmd_SimulateWithoutLLM(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(simulatedPlan), 0o644))
+
+	// Simulating uses mock agents on a fake clock, so it costs no LLM calls and needs no key
+	out, err := runCLI(t, "--parallel", "2", "execute", "--simulate", "--from-plan", path)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Simulated 4 steps in 35m0s with up to 2 running at once")
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/slack"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// maxSlackSummary keeps plan summaries under Slack's 3000 character limit for section text
+const maxSlackSummary = 2900
+
+// newSlackBot creates the Slack bot for the daemon when integrations.slack is enabled, with a
+// runner that plans and executes submitted goals until ctx ends. It returns nil when Slack is
+// not enabled.
+func newSlackBot(ctx context.Context, cfg *config.Config, storage task.TaskStorage, workspace string, logger *zap.Logger) (*slack.Bot, *slackRunner, error) {
+	settings := cfg.Integrations.Slack
+	if !settings.Enabled {
+		return nil, nil, nil
+	}
+	if settings.BotToken == "" {
+		return nil, nil, fmt.Errorf("integrations.slack.bot_token is required; set it in the config or with \"capn secrets set integrations.slack.bot_token\"")
+	}
+	if settings.SigningSecret == "" {
+		return nil, nil, fmt.Errorf("integrations.slack.signing_secret is required; set it in the config or with \"capn secrets set integrations.slack.signing_secret\"")
+	}
+
+	bot := slack.NewBot(slack.NewClient(settings.BaseURL(), settings.BotToken), settings.Channel, settings.SigningSecret, storage, logger)
+	runner := &slackRunner{
+		ctx:       ctx,
+		config:    cfg,
+		storage:   storage,
+		bot:       bot,
+		workspace: workspace,
+		logger:    logger,
+		cancels:   make(map[string]context.CancelFunc),
+	}
+	bot.SetRunner(runner)
+	return bot, runner, nil
+}
+
+// slackRunner plans and executes goals submitted from Slack in the daemon, asking for approval
+// of each plan in Slack before running it
+type slackRunner struct {
+	ctx       context.Context // ends when the daemon stops, cancelling running tasks
+	storage   task.TaskStorage
+	bot       *slack.Bot
+	workspace string
+	logger    *zap.Logger
+	// admit turns goals away while the daemon is paused; nil admits every goal
+	admit func() error
+
+	mu      sync.Mutex
+	config  *config.Config // replaced when the daemon reloads its configuration
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Submit starts planning and running a goal in the background
+func (r *slackRunner) Submit(goal, user string) (string, error) {
+	if r.ctx.Err() != nil {
+		return "", fmt.Errorf("the daemon is shutting down")
+	}
+	if r.admit != nil {
+		if err := r.admit(); err != nil {
+			return "", err
+		}
+	}
+	record := task.NewTaskExecution(goal)
+	record.Workspace = r.workspace
+	record.Metadata["slack_user"] = user
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.mu.Lock()
+	r.cancels[record.ID] = cancel
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.forget(record.ID)
+		// A panic fails only this task rather than the daemon
+		err := common.Recover("slack task "+record.ID, func() error {
+			r.run(ctx, record)
+			return nil
+		})
+		var panicErr *common.PanicError
+		if errors.As(err, &panicErr) {
+			common.LogPanic(r.logger, "Slack task panicked", panicErr, zap.String("task_id", record.ID))
+			failTask(r.storage, record, err, r.logger)
+		}
+	}()
+	return record.ID, nil
+}
+
+// Cancel stops a task submitted from Slack, as an interrupt stops "capn execute"
+func (r *slackRunner) Cancel(taskID string) error {
+	r.mu.Lock()
+	cancel, ok := r.cancels[taskID]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("task %s is not running", taskID)
+	}
+	cancel()
+	return nil
+}
+
+// setConfig sets the configuration goals submitted afterwards are planned and run with
+func (r *slackRunner) setConfig(cfg *config.Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = cfg
+}
+
+// currentConfig returns the configuration to plan and run a goal with
+func (r *slackRunner) currentConfig() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.config
+}
+
+// Wait blocks until every submitted task has stopped
+func (r *slackRunner) Wait() {
+	r.wg.Wait()
+}
+
+// forget releases a finished task's context
+func (r *slackRunner) forget(taskID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.cancels[taskID]; ok {
+		cancel()
+		delete(r.cancels, taskID)
+	}
+}
+
+// run plans a goal, waits for its plan to be approved unless integrations.slack.auto_approve is
+// set, and executes it. Approving the plan approves its high-risk steps, since nobody is at the
+// daemon's terminal to approve them one by one.
+func (r *slackRunner) run(ctx context.Context, record *task.TaskExecution) {
+	cfg := r.currentConfig()
+	cap, err := newCaptain(cfg)
+	if err != nil {
+		failTask(r.storage, record, err, r.logger)
+		return
+	}
+	defer cap.Stop()
+
+	run := &taskRun{captain: cap, storage: r.storage, record: record, config: cfg, logger: r.logger, out: io.Discard}
+	if admitted, err := run.admit(ctx); !admitted {
+		if err != nil {
+			r.logger.Warn("Slack task was not admitted", zap.String("task_id", record.ID), zap.Error(err))
+		}
+		return
+	}
+	defer run.notify(ctx)
+	plan, err := run.plan(ctx)
+	if err != nil || plan == nil {
+		if err != nil {
+			r.logger.Warn("Failed to plan Slack task", zap.String("task_id", record.ID), zap.Error(err))
+		}
+		return
+	}
+
+	if !cfg.Integrations.Slack.AutoApprove && !r.approve(ctx, record, plan) {
+		return
+	}
+	if err := run.execute(ctx, true); err != nil {
+		r.logger.Warn("Failed to execute Slack task", zap.String("task_id", record.ID), zap.Error(err))
+	}
+}
+
+// approve asks for the plan to be approved in Slack and records the decision. It reports false,
+// after cancelling or failing the task, unless the plan was approved.
+func (r *slackRunner) approve(ctx context.Context, record *task.TaskExecution, plan *captain.ExecutionPlan) bool {
+	timeout := r.currentConfig().Integrations.Slack.PlanApprovalTimeout()
+	approvalCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	approved, err := r.bot.RequestApproval(approvalCtx, record.ID, slackPlanSummary(record.ID, plan))
+	switch {
+	case ctx.Err() != nil:
+		cancelTask(r.storage, record, "Interrupted while waiting for plan approval", r.logger)
+	case errors.Is(err, context.DeadlineExceeded):
+		cancelTask(r.storage, record, fmt.Sprintf("Plan was not approved within %s", timeout), r.logger)
+	case err != nil:
+		failTask(r.storage, record, err, r.logger)
+	case !approved:
+		cancelTask(r.storage, record, "Plan cancelled in Slack", r.logger)
+	default:
+		record.AddLog(task.LogLevelInfo, "Plan approved in Slack")
+		return true
+	}
+	return false
+}
+
+// slackPlanSummary renders a plan for approval in Slack, marking its risky steps
+func slackPlanSummary(taskID string, plan *captain.ExecutionPlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Plan for task `%s`*: %s\n%d steps, %s strategy", taskID, plan.Goal, len(plan.Tasks), plan.Strategy.Type)
+	if plan.Timeline.EstimatedDuration > 0 {
+		fmt.Fprintf(&b, ", estimated %s", plan.Timeline.EstimatedDuration)
+	}
+	b.WriteString("\n")
+	for i, step := range plan.Tasks {
+		fmt.Fprintf(&b, "%d. [%s] %v", i+1, step.Type, step.Payload["description"])
+		if risk := captain.AssessRisk(step); risk.Level != captain.RiskLow {
+			fmt.Fprintf(&b, " (*%s risk*: %s)", risk.Level, strings.Join(risk.Reasons, "; "))
+		}
+		b.WriteString("\n")
+	}
+	return truncate(strings.TrimRight(b.String(), "\n"), maxSlackSummary)
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/daemon"
+	"github.com/iainlowe/capn/internal/slack"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// slackServer fakes the Slack Web API and an Ollama server planning every goal with issuePlanJSON
+func slackServer(t *testing.T) (*httptest.Server, func() []slack.Message) {
+	t.Helper()
+	var mu sync.Mutex
+	var posted []slack.Message
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		var message slack.Message
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		mu.Lock()
+		posted = append(posted, message)
+		_, _ = fmt.Fprintf(w, `{"ok": true, "channel": "C1", "ts": "%d.0"}`, len(posted))
+		mu.Unlock()
+	})
+	mux.HandleFunc("POST /chat.update", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	})
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		reply, _ := json.Marshal(map[string]any{"message": map[string]string{"role": "assistant", "content": issuePlanJSON}, "done": true})
+		_, _ = w.Write(reply)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, func() []slack.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]slack.Message(nil), posted...)
+	}
+}
+
+// slackConfig loads a configuration planning with the fake Ollama server and posting to the fake Slack
+func slackConfig(t *testing.T, server *httptest.Server, extra string) *config.Config {
+	t.Helper()
+	path := writeDoctorConfig(t, "captain:\n  max_clarifying_questions: 0\nllm:\n  providers:\n    - type: ollama\n      model: llama3\n      base_url: "+server.URL+
+		"\nintegrations:\n  slack:\n    enabled: true\n    channel: \"#ops\"\n    bot_token: xoxb-test\n    signing_secret: secret\n    api_url: "+server.URL+"\n"+extra)
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	return cfg
+}
+
+// clickSlack sends a signed button click for a task to the bot
+func clickSlack(t *testing.T, bot *slack.Bot, actionID, taskID string) {
+	t.Helper()
+	payload := fmt.Sprintf(`{"type": "block_actions", "user": {"id": "U1"}, "actions": [{"action_id": %q, "value": %q}]}`, actionID, taskID)
+	body := url.Values{"payload": {payload}}.Encode()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", slack.Sign("secret", timestamp, []byte(body)))
+	rec := httptest.NewRecorder()
+	bot.Handler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+// awaitApproval waits for the bot to post a plan with buttons and returns its text
+func awaitApproval(t *testing.T, messages func() []slack.Message) string {
+	t.Helper()
+	var summary string
+	require.Eventually(t, func() bool {
+		for _, message := range messages() {
+			if len(message.Blocks) > 0 {
+				summary = message.Blocks[0].Text.Text
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	return summary
+}
+
+func TestSlackRunner_ApprovedPlanRuns(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	server, messages := slackServer(t)
+	cfg := slackConfig(t, server, "")
+	storage := task.NewMemoryTaskStorage()
+
+	bot, runner, err := newSlackBot(context.Background(), cfg, storage, "/work", zap.NewNop())
+	require.NoError(t, err)
+	id, err := runner.Submit("fix the health check", "<@U1>")
+	require.NoError(t, err)
+
+	summary := awaitApproval(t, messages)
+	assert.Contains(t, summary, "*Plan for task `"+id+"`*: fix the health check\n2 steps, sequential strategy, estimated 20m0s")
+	assert.Contains(t, summary, "2. [execution] Add a timeout to the health check")
+	clickSlack(t, bot, slack.ActionApprove, id)
+	runner.Wait()
+
+	record, err := storage.GetTask(id)
+	require.NoError(t, err)
+	assert.Equal(t, task.TaskStatusCompleted, record.Status, record.Error)
+	assert.Equal(t, "<@U1>", record.Metadata["slack_user"])
+	assert.Equal(t, "/work", record.Workspace)
+	assert.Len(t, record.Results, 2)
+	var approved bool
+	for _, entry := range record.Logs {
+		approved = approved || entry.Message == "Plan approved in Slack"
+	}
+	assert.True(t, approved, "the approval is recorded in the task log")
+
+	assert.EqualError(t, runner.Cancel(id), "task "+id+" is not running")
+}
+
+func TestSlackRunner_CancelledPlan(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	server, messages := slackServer(t)
+	cfg := slackConfig(t, server, "")
+	storage := task.NewMemoryTaskStorage()
+
+	bot, runner, err := newSlackBot(context.Background(), cfg, storage, "", zap.NewNop())
+	require.NoError(t, err)
+	id, err := runner.Submit("drop the staging database", "<@U1>")
+	require.NoError(t, err)
+
+	awaitApproval(t, messages)
+	clickSlack(t, bot, slack.ActionCancel, id)
+	runner.Wait()
+
+	record, err := storage.GetTask(id)
+	require.NoError(t, err)
+	assert.Equal(t, task.TaskStatusCancelled, record.Status)
+	assert.Equal(t, "Plan cancelled in Slack", record.Error)
+	assert.Empty(t, record.Results)
+}
+
+func TestSlackRunner_ApprovalTimeout(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	server, _ := slackServer(t)
+	cfg := slackConfig(t, server, "    approval_timeout: 50ms\n")
+	storage := task.NewMemoryTaskStorage()
+
+	_, runner, err := newSlackBot(context.Background(), cfg, storage, "", zap.NewNop())
+	require.NoError(t, err)
+	id, err := runner.Submit("rotate credentials", "<@U1>")
+	require.NoError(t, err)
+	runner.Wait()
+
+	record, err := storage.GetTask(id)
+	require.NoError(t, err)
+	assert.Equal(t, task.TaskStatusCancelled, record.Status)
+	assert.Equal(t, "Plan was not approved within 50ms", record.Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, runner, err = newSlackBot(ctx, cfg, storage, "", zap.NewNop())
+	require.NoError(t, err)
+	_, err = runner.Submit("rotate credentials", "<@U1>")
+	assert.EqualError(t, err, "the daemon is shutting down")
+}
+
+func TestNewSlackBot(t *testing.T) {
+	cfg := config.NewConfig()
+	bot, runner, err := newSlackBot(context.Background(), cfg, task.NewMemoryTaskStorage(), "", zap.NewNop())
+	require.NoError(t, err)
+	assert.Nil(t, bot)
+	assert.Nil(t, runner)
+
+	cfg.Integrations.Slack = config.SlackConfig{Enabled: true, Channel: "#ops"}
+	_, _, err = newSlackBot(context.Background(), cfg, task.NewMemoryTaskStorage(), "", zap.NewNop())
+	assert.ErrorContains(t, err, "integrations.slack.bot_token is required")
+
+	cfg.Integrations.Slack.BotToken = "xoxb-test"
+	_, _, err = newSlackBot(context.Background(), cfg, task.NewMemoryTaskStorage(), "", zap.NewNop())
+	assert.ErrorContains(t, err, "integrations.slack.signing_secret is required")
+}
+
+func TestSlackRunner_PausedDaemon(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	server, _ := slackServer(t)
+	storage := task.NewMemoryTaskStorage()
+	_, runner, err := newSlackBot(context.Background(), slackConfig(t, server, ""), storage, "/work", zap.NewNop())
+	require.NoError(t, err)
+	runner.admit = func() error { return daemon.ErrPaused }
+
+	_, err = runner.Submit("fix the health check", "<@U1>")
+	assert.ErrorIs(t, err, daemon.ErrPaused)
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, tasks, "no task is recorded for a goal turned away")
+}
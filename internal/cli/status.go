@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// StatusCmd represents the status command
+type StatusCmd struct {
+	Expand        bool `help:"Show the individual tasks inside batches and pipelines" short:"e"`
+	Limit         int  `help:"Maximum number of tasks, batches and pipelines to show" default:"20"`
+	AllWorkspaces bool `name:"all-workspaces" help:"Show tasks from every workspace, not just the current one"`
+}
+
+// Help returns detailed help for the status command
+func (s *StatusCmd) Help() string {
+	return `Show recent tasks, newest first. Tasks started with --batch or --pipeline are
+collapsed into a single line with roll-up progress; use --expand to list them.
+Only the current workspace's tasks are shown unless --all-workspaces is given.
+
+Examples:
+
+    capn status
+    capn status --expand
+    capn status --all-workspaces`
+}
+
+func (s *StatusCmd) Run(out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	logger.Debug("Checking status")
+
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := listingWorkspace(globals, s.AllWorkspaces)
+	if err != nil {
+		return err
+	}
+
+	// Load every task so group roll-ups are complete, then limit the units shown
+	tasks, err := storage.ListTasks(task.TaskFilter{Workspace: workspace})
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	groups := task.GroupTasks(tasks)
+	if s.Limit > 0 && len(groups) > s.Limit {
+		groups = groups[:s.Limit]
+	}
+
+	health, err := captain.ReadProviderHealth(config.ProviderHealthFile())
+	if err != nil {
+		logger.Warn("Failed to read provider health", zap.Error(err))
+	}
+	positions := map[string]int{}
+	if len(groups) > 0 {
+		if positions, err = task.NewQueue(storage, config.Captain.MaxConcurrentTasks).Positions(); err != nil {
+			return err
+		}
+	}
+
+	view := newStatusView(groups, tasks, positions, health)
+	return newPresenter(out, globals).Show(view, func(out io.Writer) error {
+		if len(groups) == 0 {
+			if !globals.quiet() {
+				fmt.Fprintln(out, "No tasks found.")
+				printProviderHealth(out, health)
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, group := range groups {
+			if group.Kind == task.GroupKindNone {
+				writeStatusTask(w, "", group.Tasks[0], positions)
+				continue
+			}
+
+			fmt.Fprintf(w, "%s\n", groupSummary(group))
+			if s.Expand {
+				for _, t := range group.Tasks {
+					writeStatusTask(w, "  ", t, positions)
+				}
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		// Quiet output is just the task rows
+		if globals.quiet() {
+			return nil
+		}
+		printPendingQuestions(out, tasks)
+		printEstimateAccuracy(out, task.MeasureEstimates(tasks))
+		printProviderHealth(out, health)
+		return nil
+	})
+}
+
+// statusView is what status shows as JSON or YAML: every group with its tasks, whether or
+// not --expand is given, and the questions, estimates and provider health listed after them
+type statusView struct {
+	Groups    []statusGroup            `json:"groups"`
+	Questions []statusQuestion         `json:"questions,omitempty"`
+	Estimates *statusEstimates         `json:"estimates,omitempty"`
+	Providers []captain.ProviderHealth `json:"providers,omitempty"`
+}
+
+// statusGroup is a task, batch or pipeline in the status view
+type statusGroup struct {
+	Kind      string       `json:"kind"`
+	ID        string       `json:"id"`
+	Completed int          `json:"completed"`
+	Total     int          `json:"total"`
+	Tasks     []statusTask `json:"tasks"`
+}
+
+// statusTask is one task's row in the status view
+type statusTask struct {
+	ID            string     `json:"id"`
+	Status        string     `json:"status"`
+	QueuePosition int        `json:"queue_position,omitempty"`
+	StepsDone     int        `json:"steps_done"`
+	StepsTotal    int        `json:"steps_total"`
+	Questions     int        `json:"pending_questions,omitempty"`
+	ETA           *time.Time `json:"eta,omitempty"`
+	Goal          string     `json:"goal"`
+}
+
+// statusQuestion is a question an agent is waiting on an answer to
+type statusQuestion struct {
+	TaskID string `json:"task_id"`
+	task.Question
+}
+
+// statusEstimates is how closely the estimates of completed tasks matched their durations
+type statusEstimates struct {
+	Tasks     int     `json:"tasks"`
+	MeanError float64 `json:"mean_error"`
+	Bias      float64 `json:"bias"`
+}
+
+// newStatusView collects what status shows for JSON and YAML output
+func newStatusView(groups []*task.TaskGroup, tasks []*task.TaskExecution, positions map[string]int, health []captain.ProviderHealth) statusView {
+	view := statusView{Groups: make([]statusGroup, 0, len(groups)), Providers: health}
+	for _, group := range groups {
+		entry := statusGroup{Kind: string(group.Kind), ID: group.ID, Completed: group.Completed, Total: group.Total()}
+		if group.Kind == task.GroupKindNone {
+			entry.Kind, entry.ID = "task", group.Tasks[0].ID
+		}
+		for _, t := range group.Tasks {
+			done, total := t.Progress()
+			row := statusTask{ID: t.ID, Status: string(t.Status), QueuePosition: positions[t.ID], StepsDone: done, StepsTotal: total,
+				Questions: len(t.PendingQuestions()), Goal: t.Goal}
+			if eta, ok := t.ETA(); ok {
+				row.ETA = &eta
+			}
+			entry.Tasks = append(entry.Tasks, row)
+		}
+		view.Groups = append(view.Groups, entry)
+	}
+	for _, t := range tasks {
+		for _, q := range t.PendingQuestions() {
+			view.Questions = append(view.Questions, statusQuestion{TaskID: t.ID, Question: q})
+		}
+	}
+	if accuracy := task.MeasureEstimates(tasks); accuracy.Tasks > 0 {
+		view.Estimates = &statusEstimates{Tasks: accuracy.Tasks, MeanError: accuracy.MeanError, Bias: accuracy.Bias}
+	}
+	return view
+}
+
+// formatETA renders when a task is expected to finish and how long that is from now
+func formatETA(eta, now time.Time) string {
+	left := eta.Sub(now).Round(time.Second)
+	if left <= 0 {
+		return eta.Format("15:04:05") + " (overdue)"
+	}
+	return fmt.Sprintf("%s (in %s)", eta.Format("15:04:05"), left)
+}
+
+// printEstimateAccuracy reports how closely estimates matched the durations of completed tasks
+func printEstimateAccuracy(out io.Writer, accuracy task.EstimateAccuracy) {
+	if accuracy.Tasks == 0 {
+		return
+	}
+	direction := "over"
+	if accuracy.Bias < 0 {
+		direction = "under"
+	}
+	fmt.Fprintf(out, "\nEstimates: off by %.0f%% on average over %d completed task(s), tending to %sestimate\n",
+		accuracy.MeanError*100, accuracy.Tasks, direction)
+}
+
+// printProviderHealth lists the recorded circuit breaker state of each LLM provider
+func printProviderHealth(out io.Writer, health []captain.ProviderHealth) {
+	if len(health) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nLLM providers (as of %s):\n", health[0].UpdatedAt.Format("2006-01-02 15:04:05"))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, h := range health {
+		line := fmt.Sprintf("  %s\t%s", h.Name, h.State)
+		if h.BudgetExhausted() {
+			line += fmt.Sprintf("\tbudget exhausted (%d of %d tokens)", h.TokensSpent, h.BudgetTokens)
+		} else if h.State != captain.CircuitClosed {
+			line += fmt.Sprintf("\t%d consecutive failure(s), retry after %s", h.ConsecutiveFailures, h.RetryAt.Format("15:04:05"))
+			if h.LastError != "" {
+				line += ": " + truncate(h.LastError, 80)
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+}
+
+// printPendingQuestions lists questions agents are waiting on, with how to answer them
+func printPendingQuestions(out io.Writer, tasks []*task.TaskExecution) {
+	header := false
+	for _, t := range tasks {
+		for _, q := range t.PendingQuestions() {
+			if !header {
+				fmt.Fprintf(out, "\nQuestions awaiting an answer:\n")
+				header = true
+			}
+			fmt.Fprintf(out, "  %s %s (%s): %s\n", t.ID, q.ID, questionSource(q), q.Text)
+		}
+	}
+	if header {
+		fmt.Fprintf(out, "Answer with: capn tasks answer <task-id> \"response\"\n")
+	}
+}
+
+// groupSummary renders the roll-up line for a batch or pipeline
+func groupSummary(group *task.TaskGroup) string {
+	unit := "tasks"
+	if group.Kind == task.GroupKindPipeline {
+		unit = "stages"
+	}
+
+	summary := fmt.Sprintf("%s %s: %d/%d %s complete", group.Kind, group.ID, group.Completed, group.Total(), unit)
+
+	var details []string
+	if group.Running > 0 {
+		details = append(details, fmt.Sprintf("%d running", group.Running))
+	}
+	if group.Pending > 0 {
+		details = append(details, fmt.Sprintf("%d pending", group.Pending))
+	}
+	if group.Failed > 0 {
+		details = append(details, fmt.Sprintf("%d failed", group.Failed))
+	}
+	if len(details) > 0 {
+		summary += " (" + strings.Join(details, ", ") + ")"
+	}
+	return summary
+}
+
+// writeStatusTask writes a single task row to the status table, with the queue position of queued tasks
+func writeStatusTask(w io.Writer, indent string, t *task.TaskExecution, positions map[string]int) {
+	done, total := t.Progress()
+	status := string(t.Status)
+	if position, ok := positions[t.ID]; ok {
+		status = fmt.Sprintf("%s #%d", status, position)
+	}
+	if pending := len(t.PendingQuestions()); pending > 0 {
+		status = fmt.Sprintf("%s, %d question(s)", status, pending)
+	}
+	if eta, ok := t.ETA(); ok {
+		status = fmt.Sprintf("%s, ETA %s", status, formatETA(eta, clock.Now()))
+	}
+	fmt.Fprintf(w, "%s%s\t%s\t%d/%d\t%s\n", indent, t.ID, status, done, total, truncate(t.Goal, 60))
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func seedGroupedTask(t *testing.T, status task.TaskStatus, pipeline, batch string) *task.TaskExecution {
+	t.Helper()
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := task.NewTaskExecution("stage of " + pipeline + batch)
+	te.PipelineID = pipeline
+	te.BatchID = batch
+	te.SetStatus(status)
+	require.NoError(t, storage.SaveTask(te))
+	return te
+}
+
+func TestStatusCmd_Empty(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	out, err := runCLI(t, "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No tasks found.")
+}
+
+func TestStatusCmd_Groups(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	for _, status := range []task.TaskStatus{task.TaskStatusCompleted, task.TaskStatusCompleted, task.TaskStatusCompleted, task.TaskStatusRunning, task.TaskStatusFailed} {
+		seedGroupedTask(t, status, "deploy", "")
+	}
+	batched := seedGroupedTask(t, task.TaskStatusCompleted, "", "nightly")
+	single := seedTask(t, task.TaskStatusRunning)
+
+	t.Run("collapsed", func(t *testing.T) {
+		out, err := runCLI(t, "status")
+		require.NoError(t, err)
+		assert.Contains(t, out, "pipeline deploy: 3/5 stages complete (1 running, 1 failed)")
+		assert.Contains(t, out, "batch nightly: 1/1 tasks complete")
+		assert.Contains(t, out, single.ID)
+		assert.NotContains(t, out, batched.ID)
+	})
+
+	t.Run("expanded", func(t *testing.T) {
+		out, err := runCLI(t, "status", "--expand")
+		require.NoError(t, err)
+		assert.Contains(t, out, "  "+batched.ID)
+	})
+}
+
+func TestCLI_ExecuteCommand_RecordsGroup(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "test-env-key")
+
+	_, err := runCLI(t, "execute", "--plan-only", "--pipeline", "deploy", "--batch", "nightly", "record me")
+	require.Error(t, err)
+
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	tasks, err := storage.ListTasks(task.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "deploy", tasks[0].PipelineID)
+	assert.Equal(t, "nightly", tasks[0].BatchID)
+}
+
+func TestStatusCmd_QueuePositions(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	waiting := task.NewTaskExecution("waiting for a slot")
+	waiting.QueuedAt = time.Now()
+	waiting.SetStatus(task.TaskStatusQueued)
+	require.NoError(t, storage.SaveTask(waiting))
+
+	out, err := runCLI(t, "status")
+	require.NoError(t, err)
+	assert.Regexp(t, waiting.ID+`\s+queued #1`, out)
+
+	out, err = runCLI(t, "tasks", "bump", waiting.ID, "--priority", "4")
+	require.NoError(t, err)
+	assert.Equal(t, "Task "+waiting.ID+" priority set to 4 (queue position 1)\n", out)
+
+	stored, err := storage.GetTask(waiting.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stored.Priority)
+}
+
+func TestStatusCmd_ProviderHealth(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	cfg := config.NewConfig()
+
+	out, err := runCLI(t, "status")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "LLM providers")
+
+	retryAt := time.Date(2025, 8, 3, 10, 15, 0, 0, time.Local)
+	require.NoError(t, captain.WriteProviderHealth(cfg.ProviderHealthFile(), []captain.ProviderHealth{
+		{Name: "openai", State: captain.CircuitOpen, ConsecutiveFailures: 5, LastError: "503 service unavailable", RetryAt: retryAt, UpdatedAt: retryAt},
+		{Name: "ollama", State: captain.CircuitClosed, TokensSpent: 1200, BudgetTokens: 1000, UpdatedAt: retryAt},
+	}))
+
+	out, err = runCLI(t, "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "LLM providers (as of 2025-08-03 10:15:00):")
+	assert.Regexp(t, `openai\s+open\s+5 consecutive failure\(s\), retry after 10:15:00: 503 service unavailable`, out)
+	assert.Regexp(t, `ollama\s+closed\s+budget exhausted \(1200 of 1000 tokens\)`, out)
+}
@@ -0,0 +1,625 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// TasksCmd groups the task history commands
+type TasksCmd struct {
+	List       TasksListCmd       `cmd:"" help:"List recorded tasks"`
+	Show       TasksShowCmd       `cmd:"" help:"Show details of a task"`
+	Logs       TasksLogsCmd       `cmd:"" help:"Show a task's log, including agent communications"`
+	Artifacts  TasksArtifactsCmd  `cmd:"" help:"List and retrieve files produced by a task"`
+	Retry      TasksRetryCmd      `cmd:"" help:"Run a failed or cancelled task again"`
+	Bump       TasksBumpCmd       `cmd:"" help:"Raise the queue priority of a waiting task"`
+	Tag        TasksTagCmd        `cmd:"" help:"Add or remove a task's tags"`
+	Answer     TasksAnswerCmd     `cmd:"" help:"Answer a question an agent asked while running a task"`
+	Transcript TasksTranscriptCmd `cmd:"" help:"Render a task's conversation and outputs as Markdown"`
+	Export     TasksExportCmd     `cmd:"" help:"Export a task as JSON or as a bundle with its logs and artifacts"`
+	Import     TasksImportCmd     `cmd:"" help:"Load a task bundle into the task history"`
+	Rollback   TasksRollbackCmd   `cmd:"" help:"Revert the changes a task made to its git repository"`
+	Prune      TasksPruneCmd      `cmd:"" help:"Delete old finished tasks from the task history"`
+}
+
+// TasksListCmd represents the tasks list command
+type TasksListCmd struct {
+	Status        []string      `help:"Only show tasks with these statuses" enum:"pending,queued,planning,running,completed,failed,cancelled,interrupted" sep:","`
+	Limit         int           `help:"Maximum number of tasks to show per page" default:"20"`
+	Since         time.Duration `help:"Only show tasks created within this long ago (e.g. 24h)"`
+	Tags          []string      `name:"tag" help:"Only show tasks with this tag (repeatable; tasks must have every one)" placeholder:"TAG" sep:"none"`
+	PageToken     string        `help:"Continue from the page token printed by a previous listing" placeholder:"TOKEN"`
+	AllWorkspaces bool          `name:"all-workspaces" help:"List tasks from every workspace, not just the current one"`
+}
+
+// Help returns detailed help for the tasks list command
+func (l *TasksListCmd) Help() string {
+	return `List tasks recorded by previous runs, newest first. When more tasks remain a
+page token is printed; pass it with the same filters to see the next page.
+
+Only tasks of the current workspace are listed: the project directory the
+command runs in, or the workspace named with --workspace. Use --all-workspaces
+to list every task.
+
+Examples:
+
+    capn tasks list
+    capn tasks list --status failed,cancelled --limit 5
+    capn tasks list --since 24h
+    capn tasks list --tag infra --tag weekly
+    capn tasks list --all-workspaces
+    capn tasks list --limit 50 --page-token MTcwNDExMDQwMDAwMDAwMDAwMDp0YXNrLTAwMQ`
+}
+
+func (l *TasksListCmd) Run(out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+
+	tags, err := task.NormalizeTags(l.Tags)
+	if err != nil {
+		return err
+	}
+	workspace, err := listingWorkspace(globals, l.AllWorkspaces)
+	if err != nil {
+		return err
+	}
+	filter := task.TaskFilter{PageSize: l.Limit, PageToken: l.PageToken, Tags: tags, Workspace: workspace}
+	if l.Since > 0 {
+		filter.Since = clock.Now().Add(-l.Since)
+	}
+	for _, status := range l.Status {
+		filter.Status = append(filter.Status, task.TaskStatus(status))
+	}
+
+	tasks, err := storage.ListTasks(filter)
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	listing := Listing{Columns: []string{"ID", "STATUS", "PROGRESS", "CREATED", "TAGS", "GOAL"}, Empty: "No tasks found."}
+	if l.AllWorkspaces {
+		listing.Columns = append([]string{"WORKSPACE"}, listing.Columns...)
+	}
+	records := make([]taskListRecord, 0, len(tasks))
+	for _, t := range tasks {
+		done, total := t.Progress()
+		row := []string{t.ID, string(t.Status), fmt.Sprintf("%d/%d", done, total), t.CreatedAt.Format("2006-01-02 15:04"), formatTags(t.Tags), truncate(t.Goal, 60)}
+		if l.AllWorkspaces {
+			row = append([]string{formatWorkspace(t.Workspace)}, row...)
+		}
+		listing.Rows = append(listing.Rows, row)
+		records = append(records, taskListRecord{ID: t.ID, Workspace: t.Workspace, Status: t.Status, Done: done, Total: total,
+			CreatedAt: t.CreatedAt, Tags: t.Tags, Goal: t.Goal})
+	}
+	listing.Records = records
+
+	presenter := newPresenter(out, globals)
+	if err := presenter.List(listing); err != nil {
+		return err
+	}
+	if token := task.NextPageToken(filter, tasks); token != "" {
+		presenter.Notef("\nMore tasks may follow: capn tasks list --page-token %s", token)
+	}
+	return nil
+}
+
+// taskListRecord is a listed task as JSON and YAML output describe it
+type taskListRecord struct {
+	ID        string          `json:"id"`
+	Workspace string          `json:"workspace,omitempty"`
+	Status    task.TaskStatus `json:"status"`
+	Done      int             `json:"steps_done"`
+	Total     int             `json:"steps_total"`
+	CreatedAt time.Time       `json:"created_at"`
+	Tags      []string        `json:"tags,omitempty"`
+	Goal      string          `json:"goal"`
+}
+
+// TasksShowCmd represents the tasks show command
+type TasksShowCmd struct {
+	TaskID     string `arg:"" name:"task-id" help:"Task to show"`
+	Blackboard bool   `help:"Only show the findings agents shared on the task's blackboard, in full"`
+	Resources  bool   `help:"Only show the CPU time, memory, downloads and files written of each step"`
+	LLM        bool   `name:"llm" help:"Only show the provider, model, temperature, tokens and latency of the LLM calls behind the plan and steps"`
+}
+
+// Help returns detailed help for the tasks show command
+func (s *TasksShowCmd) Help() string {
+	return `Show a task's status, outcome report, plan, step results and log. The report
+is written by the Captain when the task's steps finish: what was achieved, what
+failed, the artifacts produced and suggested follow-ups.
+
+Steps share findings with later steps and other agents on the task's
+blackboard. Use --blackboard to show only the findings, with their full
+values and the artifacts published with them.
+
+Use --resources to show what each step consumed: its wall and CPU time, the
+peak memory of the commands it ran, the bytes it downloaded and the files it
+wrote, with the total for the task. CPU time and memory are only measured for
+steps that run commands. The same figures are kept with each step's result in
+"capn tasks export".
+
+Use --llm to show which provider and model produced the plan and the reasoning
+of steps whose agents have a brain, with the temperature, tokens and latency of
+each call. When the quality of plans changes, this tells whether the model did.
+Plan exports keep the same details in the plan's metadata.
+
+Examples:
+
+    capn tasks show task-1a2b3c4d
+    capn tasks show task-1a2b3c4d --blackboard
+    capn tasks show task-1a2b3c4d --resources
+    capn tasks show task-1a2b3c4d --llm`
+}
+
+func (s *TasksShowCmd) Run(out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+
+	t, err := storage.GetTask(s.TaskID)
+	if err != nil {
+		return err
+	}
+	// JSON and YAML show the whole task, as tasks export does, whichever section is asked for
+	return newPresenter(out, globals).Show(t, func(out io.Writer) error {
+		return s.print(out, storage, t)
+	})
+}
+
+// print writes the task's details, or the section asked for, as text
+func (s *TasksShowCmd) print(out io.Writer, storage task.TaskStorage, t *task.TaskExecution) error {
+	if s.Blackboard {
+		printBlackboard(out, t)
+		return nil
+	}
+	if s.Resources {
+		printResources(out, t)
+		return nil
+	}
+	if s.LLM {
+		printLLMUsage(out, t)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Task:     %s\n", t.ID)
+	fmt.Fprintf(out, "Goal:     %s\n", t.Goal)
+	fmt.Fprintf(out, "Status:   %s\n", t.Status)
+	if len(t.Tags) > 0 {
+		fmt.Fprintf(out, "Tags:     %s\n", strings.Join(t.Tags, ", "))
+	}
+	if t.Workspace != "" {
+		fmt.Fprintf(out, "Workspace: %s\n", t.Workspace)
+	}
+	for _, dep := range t.After {
+		fmt.Fprintf(out, "After:    %s (%s)\n", dep, dependencyStatus(storage, dep))
+	}
+	fmt.Fprintf(out, "Created:  %s\n", t.CreatedAt.Format(time.RFC3339))
+	if !t.StartedAt.IsZero() {
+		fmt.Fprintf(out, "Duration: %s\n", t.Duration().Round(time.Millisecond))
+	}
+	if estimate := describeEstimate(t); estimate != "" {
+		fmt.Fprintf(out, "Estimate: %s\n", estimate)
+	}
+	if t.Error != "" {
+		fmt.Fprintf(out, "Error:    %s\n", t.Error)
+	}
+	if repo, before, after, ok := t.GitChanges(); ok {
+		changes := fmt.Sprintf("%s..%s in %s", shortCommit(before), shortCommit(after), repo)
+		if at := t.Metadata[task.MetadataGitRolledBack]; at != "" {
+			changes += " (rolled back " + at + ")"
+		}
+		fmt.Fprintf(out, "Changes:  %s\n", changes)
+	}
+	if t.Report != nil {
+		fmt.Fprintf(out, "\nReport:\n")
+		for _, line := range strings.Split(t.Report.Text(), "\n") {
+			if line == "" {
+				fmt.Fprintln(out)
+				continue
+			}
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+
+	if t.Plan != nil {
+		statuses := t.StepStatuses()
+		fmt.Fprintf(out, "\nPlan %s (%s, %d steps):\n", t.Plan.ID, t.Plan.Strategy.Type, len(t.Plan.Tasks))
+		if provider := planProvider(t.Plan); provider != "" {
+			fmt.Fprintf(out, "  planned by %s\n", provider)
+		}
+		for _, step := range t.Plan.Tasks {
+			marker := stepMarker(statuses[step.ID])
+			fmt.Fprintf(out, "  %s %s [%s] %v\n", marker, step.ID, step.Type, step.Payload["description"])
+			if len(step.Dependencies) > 0 {
+				fmt.Fprintf(out, "      depends on: %s\n", strings.Join(step.Dependencies, ", "))
+			}
+			if step.Workdir != "" || step.Shell != "" || step.Execution != "" || len(step.Env) > 0 {
+				fmt.Fprintf(out, "      environment: %s\n", describeTaskEnvironment(step))
+			}
+			if step.Condition != nil {
+				fmt.Fprintf(out, "      runs if: output of %s matches %q\n", step.Condition.Step, step.Condition.Matches)
+			}
+			if step.Gate == captain.GateManual {
+				fmt.Fprintf(out, "      gate: manual\n")
+			}
+			if step.Service {
+				fmt.Fprintf(out, "      service: %s\n", describeService(step))
+			}
+			if len(step.FanOut) > 0 {
+				fmt.Fprintf(out, "      fans out over: %s%s\n", strings.Join(step.FanOut, ", "), describeFanOut(t, step.ID))
+			}
+		}
+	}
+
+	chain, err := task.RetryChain(storage, t.ID)
+	if err != nil {
+		return err
+	}
+	if len(chain) > 1 {
+		printRetryChain(out, chain, t.ID)
+	}
+
+	if len(t.Questions) > 0 {
+		fmt.Fprintf(out, "\nQuestions (%d):\n", len(t.Questions))
+		for _, q := range t.Questions {
+			fmt.Fprintf(out, "  %s (%s): %s\n", q.ID, questionSource(q), q.Text)
+			if q.Answered() {
+				fmt.Fprintf(out, "      answer: %s\n", q.Answer)
+			} else {
+				fmt.Fprintf(out, "      awaiting an answer\n")
+			}
+		}
+	}
+
+	printBlackboardSummary(out, t)
+
+	if len(t.Artifacts) > 0 {
+		fmt.Fprintf(out, "\nArtifacts (%d):\n", len(t.Artifacts))
+		for _, artifact := range t.Artifacts {
+			fmt.Fprintf(out, "  %s [%s] %d bytes\n", artifact.Name, artifact.Kind, artifact.Size)
+		}
+	}
+
+	if len(t.Logs) > 0 {
+		fmt.Fprintf(out, "\nLog:\n")
+		for _, entry := range t.Logs {
+			fmt.Fprintf(out, "  %s\n", formatLogEntry(entry))
+		}
+	}
+	return nil
+}
+
+// TasksLogsCmd represents the tasks logs command
+type TasksLogsCmd struct {
+	TaskID       string `arg:"" name:"task-id" help:"Task whose log to show"`
+	MessagesOnly bool   `name:"messages-only" help:"Only show messages routed between agents"`
+	Step         string `help:"Only show entries for this plan step"`
+	Stream       string `help:"Only show command output written to this stream" enum:",stdout,stderr" default:""`
+	Tail         int    `help:"Only show the last N entries" placeholder:"N"`
+	Follow       bool   `short:"f" help:"Keep showing new entries until the task finishes"`
+}
+
+// Help returns detailed help for the tasks logs command
+func (l *TasksLogsCmd) Help() string {
+	return `Show a task's log in time order. Step progress, warnings and the agent
+communications routed while working on the task are interleaved; messages are
+shown as "from -> to".
+
+The stdout and stderr of the commands a step runs are streamed into the log as
+they are written, marked with their stream and cut off after
+execution.max_output_size bytes per stream. Use --step and --stream to pick
+one step's output, --tail to start from its last lines and --follow to keep
+watching while the step runs. The output of a fan-out step's items, and its
+progress through them, is shown under the step.
+
+Examples:
+
+    capn tasks logs task-1a2b3c4d
+    capn tasks logs task-1a2b3c4d --messages-only
+    capn tasks logs task-1a2b3c4d --step task-2
+    capn tasks logs task-1a2b3c4d --step task-2 --stream stderr
+    capn tasks logs task-1a2b3c4d --step task-2 --tail 20 --follow`
+}
+
+// followInterval is how often a followed task log is checked for new entries
+var followInterval = 500 * time.Millisecond
+
+func (l *TasksLogsCmd) Run(ctx context.Context, out io.Writer, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(l.TaskID)
+	if err != nil {
+		return err
+	}
+
+	entries := l.filter(t.Logs)
+	if l.Tail > 0 && len(entries) > l.Tail {
+		entries = entries[len(entries)-l.Tail:]
+	}
+	for _, entry := range entries {
+		fmt.Fprintln(out, formatLogEntry(entry))
+	}
+	shown := len(entries)
+
+	if l.Follow {
+		ticker := clock.NewTicker(followInterval)
+		defer ticker.Stop()
+		for seen := len(t.Logs); !t.Status.IsTerminal(); seen = len(t.Logs) {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C():
+			}
+			if t, err = storage.GetTask(l.TaskID); err != nil {
+				return err
+			}
+			if seen > len(t.Logs) {
+				seen = len(t.Logs)
+			}
+			for _, entry := range l.filter(t.Logs[seen:]) {
+				fmt.Fprintln(out, formatLogEntry(entry))
+				shown++
+			}
+		}
+	}
+	if shown == 0 {
+		if l.MessagesOnly {
+			fmt.Fprintf(out, "No agent messages recorded for task %s.\n", t.ID)
+		} else {
+			fmt.Fprintf(out, "No log entries recorded for task %s.\n", t.ID)
+		}
+	}
+	return nil
+}
+
+// filter returns the log entries selected by the command's flags
+func (l *TasksLogsCmd) filter(logs []task.LogEntry) []task.LogEntry {
+	var entries []task.LogEntry
+	for _, entry := range logs {
+		if l.MessagesOnly && !entry.IsMessage() {
+			continue
+		}
+		if l.Step != "" && captain.ParentStep(entry.Step) != l.Step {
+			continue
+		}
+		if l.Stream != "" && entry.Stream != l.Stream {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// formatLogEntry renders a log entry on one line, showing routed messages as from -> to and
+// command output with its stream
+func formatLogEntry(entry task.LogEntry) string {
+	prefix := fmt.Sprintf("%s %-5s", entry.Timestamp.Format("15:04:05"), entry.Level)
+	if entry.Step != "" {
+		prefix += " [" + entry.Step + "]"
+	}
+	if entry.IsMessage() {
+		return fmt.Sprintf("%s %s -> %s: %s", prefix, entry.From, entry.To, entry.Message)
+	}
+	if entry.Stream != "" {
+		return fmt.Sprintf("%s %s: %s", prefix, entry.Stream, entry.Message)
+	}
+	return prefix + " " + entry.Message
+}
+
+// TasksBumpCmd represents the tasks bump command
+type TasksBumpCmd struct {
+	TaskID   string `arg:"" name:"task-id" help:"Queued task to bump"`
+	Priority *int   `help:"Priority to set (default: ahead of every other queued task)"`
+}
+
+// Help returns detailed help for the tasks bump command
+func (b *TasksBumpCmd) Help() string {
+	return `Raise the priority of a task waiting for a free slot. Tasks queue when
+captain.max_concurrent_tasks are already running; higher priorities are
+admitted first and equal priorities in arrival order.
+
+Examples:
+
+    capn tasks bump task-1a2b3c4d
+    capn tasks bump task-1a2b3c4d --priority 5`
+}
+
+func (b *TasksBumpCmd) Run(out io.Writer, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	queue := task.NewQueue(storage, config.Captain.MaxConcurrentTasks)
+	t, err := queue.Bump(b.TaskID, b.Priority)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Task %s priority set to %d", t.ID, t.Priority)
+	if position, err := queue.Position(t.ID); err == nil {
+		fmt.Fprintf(out, " (queue position %d)", position)
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+// TasksTagCmd represents the tasks tag command
+type TasksTagCmd struct {
+	TaskID  string   `arg:"" name:"task-id" help:"Task to tag"`
+	Changes []string `arg:"" help:"Tags to add as +tag (or just tag) and to remove as -tag, after --"`
+}
+
+// Help returns detailed help for the tasks tag command
+func (c *TasksTagCmd) Help() string {
+	return `Add tags to a task with +tag or a bare tag name and remove them with -tag.
+Removals look like flags, so they must follow "--". Tags are lowercased.
+Filter tasks by tag with "capn tasks list --tag".
+
+Examples:
+
+    capn tasks tag task-1a2b3c4d +urgent
+    capn tasks tag task-1a2b3c4d infra weekly
+    capn tasks tag task-1a2b3c4d -- -urgent +resolved`
+}
+
+func (c *TasksTagCmd) Run(out io.Writer, config *config.Config) error {
+	var add, remove []string
+	for _, change := range c.Changes {
+		switch {
+		case strings.HasPrefix(change, "-"):
+			remove = append(remove, change[1:])
+		case strings.HasPrefix(change, "+"):
+			add = append(add, change[1:])
+		default:
+			add = append(add, change)
+		}
+	}
+
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(c.TaskID)
+	if err != nil {
+		return err
+	}
+	if err := t.RemoveTags(remove...); err != nil {
+		return err
+	}
+	if err := t.AddTags(add...); err != nil {
+		return err
+	}
+	if err := storage.SaveTask(t); err != nil {
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+
+	fmt.Fprintf(out, "Task %s tags: %s\n", t.ID, formatTags(t.Tags))
+	return nil
+}
+
+// formatTags lists tags separated by commas, or "-" for none
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+	return strings.Join(tags, ",")
+}
+
+// describeEstimate describes the duration predicted for a task: with its ETA while it runs,
+// or against the actual duration once it completed
+func describeEstimate(t *task.TaskExecution) string {
+	predicted, ok := t.EstimatedDuration()
+	if !ok {
+		return ""
+	}
+	if eta, ok := t.ETA(); ok {
+		return fmt.Sprintf("%s, ETA %s", predicted, formatETA(eta, clock.Now()))
+	}
+	actual := t.Duration()
+	if t.Status != task.TaskStatusCompleted || actual <= 0 {
+		return predicted.String()
+	}
+	off := float64(predicted-actual) / float64(actual) * 100
+	direction := "over"
+	if off < 0 {
+		off, direction = -off, "under"
+	}
+	return fmt.Sprintf("%s (%.0f%% %s the actual %s)", predicted, off, direction, actual.Round(time.Second))
+}
+
+// formatWorkspace returns a workspace's short name, or "-" for tasks recorded without one
+func formatWorkspace(workspace string) string {
+	if workspace == "" {
+		return "-"
+	}
+	return task.WorkspaceName(workspace)
+}
+
+// planProvider describes the LLM provider and model recorded as producing a plan
+func planProvider(plan *captain.ExecutionPlan) string {
+	provider, model := plan.Metadata[captain.MetadataProvider], plan.Metadata[captain.MetadataModel]
+	switch {
+	case provider != "" && model != "":
+		return fmt.Sprintf("%s (%s)", provider, model)
+	case provider != "":
+		return provider
+	}
+	return model
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// dependencyStatus describes whether a dependency of a task is met yet
+func dependencyStatus(storage task.TaskStorage, dep task.Dependency) string {
+	other, err := storage.GetTask(dep.TaskID)
+	if err != nil {
+		return "task not found"
+	}
+	met, err := dep.Met(other)
+	switch {
+	case err != nil:
+		return "cannot be met: " + err.Error()
+	case met:
+		return "met"
+	default:
+		return fmt.Sprintf("waiting; %s is %s", other.ID, other.Status)
+	}
+}
+
+// describeFanOut summarizes how a fan-out step's items fared, or returns "" before it ran
+func describeFanOut(t *task.TaskExecution, stepID string) string {
+	var items []captain.FanOutItem
+	for _, result := range t.Results {
+		if result.TaskID == stepID {
+			items = captain.FanOutItems(result)
+		}
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	succeeded := 0
+	for _, item := range items {
+		if item.Success {
+			succeeded++
+		}
+	}
+	return fmt.Sprintf(" (%d of %d succeeded)", succeeded, len(items))
+}
+
+// stepMarker returns the symbol shown next to a plan step with the given status
+func stepMarker(status captain.StepStatus) string {
+	switch status {
+	case captain.StepStatusSucceeded:
+		return "✓"
+	case captain.StepStatusInterrupted:
+		return "!"
+	case captain.StepStatusSkipped:
+		return "~"
+	case captain.StepStatusFailed, captain.StepStatusTimedOut:
+		return "✗"
+	default:
+		return " "
+	}
+}
@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksListCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	out, err := runCLI(t, "tasks", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No tasks found.")
+
+	completed := seedTask(t, task.TaskStatusCompleted)
+	failed := seedTask(t, task.TaskStatusFailed)
+
+	out, err = runCLI(t, "tasks", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "ID")
+	assert.Contains(t, out, completed.ID)
+	assert.Contains(t, out, failed.ID)
+	assert.Contains(t, out, "1/2")
+
+	out, err = runCLI(t, "tasks", "list", "--status", "failed")
+	require.NoError(t, err)
+	assert.Contains(t, out, failed.ID)
+	assert.NotContains(t, out, completed.ID)
+}
+
+func TestTasksShowCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, te.ID)
+	assert.Contains(t, out, "analyze code quality")
+	assert.Contains(t, out, "✓ task-1")
+	assert.Contains(t, out, "depends on: task-1")
+	assert.Contains(t, out, "analysis finished")
+
+	_, err = runCLI(t, "tasks", "show", "task-missing")
+	assert.Error(t, err)
+}
+
+func TestTasksShowCmd_Dependencies(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	build := seedTask(t, task.TaskStatusRunning)
+	te := seedTask(t, task.TaskStatusQueued)
+	te.After = []task.Dependency{
+		{TaskID: build.ID, Condition: task.DependencyCompleted},
+		{TaskID: "task-gone", Condition: task.DependencyFinished},
+	}
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "After:    "+build.ID+" completes (waiting; "+build.ID+" is running)\n")
+	assert.Contains(t, out, "After:    task-gone finishes (task not found)\n")
+}
+
+func TestExecuteCmd_Dependencies(t *testing.T) {
+	cmd := &ExecuteCmd{After: []string{"task-a"}, AfterAny: []string{"task-b"}, AfterSuccess: []string{"task-c"}}
+	assert.Equal(t, []task.Dependency{
+		{TaskID: "task-a", Condition: task.DependencyCompleted},
+		{TaskID: "task-b", Condition: task.DependencyFinished},
+		{TaskID: "task-c", Condition: task.DependencySucceeded},
+	}, cmd.dependencies())
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "abcd…", truncate("abcdefgh", 5))
+}
+
+func TestTasksShowCmd_FanOut(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := seedTask(t, task.TaskStatusFailed)
+	te.Plan.Tasks[1].FanOut = []string{"web-1", "web-2"}
+	te.Results = append(te.Results, captain.Result{TaskID: "task-2", Metadata: map[string]any{"fan_out": []captain.FanOutItem{
+		{Item: "web-1", StepID: "task-2[0]", Status: captain.StepStatusSucceeded, Success: true},
+		{Item: "web-2", StepID: "task-2[1]", Status: captain.StepStatusFailed, Error: "connection refused"},
+	}}})
+	te.AddStepLog(task.LogLevelInfo, "task-2[1]", "file-002", "rolling out web-2")
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "      fans out over: web-1, web-2 (1 of 2 succeeded)\n")
+
+	out, err = runCLI(t, "tasks", "logs", te.ID, "--step", "task-2")
+	require.NoError(t, err)
+	assert.Contains(t, out, "rolling out web-2", "the items' entries show under their step")
+}
+
+func TestTasksShowCmd_PlanProvider(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := seedTask(t, task.TaskStatusCompleted)
+	te.Plan.Metadata = map[string]string{captain.MetadataProvider: "anthropic", captain.MetadataModel: "claude-sonnet-4"}
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "  planned by anthropic (claude-sonnet-4)\n")
+}
+
+func TestTasksShowCmd_Resources(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := seedTask(t, task.TaskStatusCompleted)
+	out, err := runCLI(t, "tasks", "show", te.ID, "--resources")
+	require.NoError(t, err)
+	assert.Equal(t, "No resource usage recorded for task "+te.ID+".\n", out)
+
+	te.Results[0].Resources = &agents.ResourceUsage{WallTime: 2 * time.Second, CPUTime: 1500 * time.Millisecond, PeakMemory: 8 << 20, FilesWritten: 3}
+	require.NoError(t, storage.SaveTask(te))
+	out, err = runCLI(t, "tasks", "show", te.ID, "--resources")
+	require.NoError(t, err)
+	assert.Contains(t, out, "STEP")
+	assert.Contains(t, out, "PEAK MEMORY")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3, "a row for the measured step and the total")
+	assert.Contains(t, lines[1], "task-1")
+	assert.Contains(t, lines[1], "8.0 MiB")
+	assert.Contains(t, lines[2], "total")
+}
+
+func TestTasksShowCmd_LLM(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := seedTask(t, task.TaskStatusCompleted)
+	out, err := runCLI(t, "tasks", "show", te.ID, "--llm")
+	require.NoError(t, err)
+	assert.Equal(t, "No LLM calls recorded for task "+te.ID+".\n", out)
+
+	te.Plan.Metadata = map[string]string{
+		captain.MetadataProvider: "anthropic", captain.MetadataModel: "claude-sonnet-4",
+		captain.MetadataTemperature: "0.3", captain.MetadataTokens: "1800", captain.MetadataLatency: "4.2s",
+	}
+	te.Results[0].LLM = &agents.LLMUsage{Provider: "ollama", Model: "llama3", Temperature: 0.2, Tokens: 200, Latency: 800 * time.Millisecond}
+	require.NoError(t, storage.SaveTask(te))
+	out, err = runCLI(t, "tasks", "show", te.ID, "--llm")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	assert.Regexp(t, `^SOURCE +PROVIDER +MODEL +TEMPERATURE +TOKENS +LATENCY$`, lines[0])
+	assert.Regexp(t, `^plan +anthropic +claude-sonnet-4 +0.3 +1800 +4.2s$`, lines[1])
+	assert.Regexp(t, `^task-1 +ollama +llama3 +0.2 +200 +800ms$`, lines[2])
+	assert.Regexp(t, `^total +2000 +5s$`, lines[3])
+}
+
+func TestTasksShowCmd_Report(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	te := seedTask(t, task.TaskStatusCompleted)
+	te.Report = &captain.OutcomeReport{
+		Achieved:  "Code quality was analyzed.",
+		Artifacts: []string{"report.md"},
+		FollowUps: []string{"Fix the lint warnings"},
+	}
+	require.NoError(t, storage.SaveTask(te))
+
+	out, err := runCLI(t, "tasks", "show", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "\nReport:\n  Code quality was analyzed.\n\n  Artifacts:\n  - report.md\n\n  Follow-up:\n  - Fix the lint warnings\n\nPlan ")
+}
+
+func TestTasksListCmd_Pages(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	older := seedTask(t, task.TaskStatusCompleted)
+	newer := seedTask(t, task.TaskStatusFailed)
+
+	out, err := runCLI(t, "tasks", "list", "--limit", "1")
+	require.NoError(t, err)
+	assert.Contains(t, out, newer.ID)
+	assert.NotContains(t, out, older.ID)
+	require.Contains(t, out, "capn tasks list --page-token ")
+
+	token := strings.TrimSpace(out[strings.LastIndex(out, "--page-token ")+len("--page-token "):])
+	out, err = runCLI(t, "tasks", "list", "--limit", "1", "--page-token", token)
+	require.NoError(t, err)
+	assert.Contains(t, out, older.ID)
+	assert.NotContains(t, out, newer.ID)
+
+	_, err = runCLI(t, "tasks", "list", "--page-token", "bogus!")
+	assert.ErrorContains(t, err, "invalid page token")
+
+	out, err = runCLI(t, "tasks", "list", "--since", "1h")
+	require.NoError(t, err)
+	assert.Contains(t, out, older.ID)
+	assert.NotContains(t, out, "--page-token", "a partial page is the last one")
+}
+
+func TestTasksTagCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	tagged := seedTask(t, task.TaskStatusCompleted)
+	other := seedTask(t, task.TaskStatusFailed)
+
+	out, err := runCLI(t, "tasks", "tag", tagged.ID, "+urgent", "Infra", "weekly")
+	require.NoError(t, err)
+	assert.Equal(t, "Task "+tagged.ID+" tags: infra,urgent,weekly\n", out)
+
+	out, err = runCLI(t, "tasks", "tag", tagged.ID, "--", "-urgent", "+weekly")
+	require.NoError(t, err)
+	assert.Contains(t, out, "tags: infra,weekly\n")
+
+	out, err = runCLI(t, "tasks", "list", "--tag", "infra")
+	require.NoError(t, err)
+	assert.Contains(t, out, "TAGS")
+	assert.Contains(t, out, "infra,weekly")
+	assert.Contains(t, out, tagged.ID)
+	assert.NotContains(t, out, other.ID)
+
+	out, err = runCLI(t, "tasks", "list", "--tag", "infra", "--tag", "urgent")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No tasks found.")
+
+	out, err = runCLI(t, "tasks", "show", tagged.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Tags:     infra, weekly\n")
+
+	_, err = runCLI(t, "tasks", "tag", tagged.ID, "+not valid")
+	assert.ErrorContains(t, err, "invalid character")
+	_, err = runCLI(t, "tasks", "tag", "task-missing", "+urgent")
+	assert.Error(t, err)
+	_, err = runCLI(t, "execute", "--tag", "not valid", "test goal")
+	assert.ErrorContains(t, err, "invalid character")
+}
+
+func TestTasksListCmd_Workspaces(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+	require.NoError(t, err)
+
+	alpha := seedTask(t, task.TaskStatusCompleted)
+	alpha.Workspace = "alpha"
+	require.NoError(t, storage.SaveTask(alpha))
+	beta := seedTask(t, task.TaskStatusFailed)
+	beta.Workspace = "/src/beta"
+	require.NoError(t, storage.SaveTask(beta))
+	unscoped := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "--workspace", "alpha", "tasks", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, alpha.ID)
+	assert.Contains(t, out, unscoped.ID)
+	assert.NotContains(t, out, beta.ID)
+	assert.NotContains(t, out, "WORKSPACE")
+
+	out, err = runCLI(t, "--workspace", "alpha", "tasks", "list", "--all-workspaces")
+	require.NoError(t, err)
+	assert.Contains(t, out, beta.ID)
+	assert.Contains(t, out, "WORKSPACE")
+	assert.Regexp(t, `beta\s+`+beta.ID, out)
+	assert.Regexp(t, `-\s+`+unscoped.ID, out)
+
+	// Without --workspace the project directory the command runs in is the workspace
+	out, err = runCLI(t, "tasks", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, unscoped.ID)
+	assert.NotContains(t, out, alpha.ID)
+
+	out, err = runCLI(t, "tasks", "show", beta.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Workspace: /src/beta\n")
+
+	out, err = runCLI(t, "--workspace", "alpha", "status")
+	require.NoError(t, err)
+	assert.Contains(t, out, alpha.ID)
+	assert.NotContains(t, out, beta.ID)
+
+	out, err = runCLI(t, "--workspace", "alpha", "search", "analyze")
+	require.NoError(t, err)
+	assert.Contains(t, out, "2 matching task(s)")
+	out, err = runCLI(t, "--workspace", "alpha", "search", "analyze", "--all-workspaces")
+	require.NoError(t, err)
+	assert.Contains(t, out, "3 matching task(s)")
+}
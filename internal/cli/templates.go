@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/templates"
+)
+
+// TemplatesCmd groups the goal template commands
+type TemplatesCmd struct {
+	Add  TemplatesAddCmd  `cmd:"" help:"Add a template, or a new version of one, from a YAML file"`
+	List TemplatesListCmd `cmd:"" help:"List stored templates"`
+	Show TemplatesShowCmd `cmd:"" help:"Show a template definition"`
+}
+
+// TemplatesAddCmd represents the templates add command
+type TemplatesAddCmd struct {
+	File string `arg:"" type:"existingfile" help:"Template definition file"`
+}
+
+// Help returns detailed help for the templates add command
+func (a *TemplatesAddCmd) Help() string {
+	return `Validate a template definition and store it. Adding a changed definition
+under an existing name creates a new version; older versions stay available
+as name@version.
+
+Example definition (deploy.yaml):
+
+    name: deploy
+    description: Deploy a service
+    goal: Deploy ${service} to ${env} and run smoke tests
+    variables:
+      - name: env
+        required: true
+      - name: service
+        default: api
+
+Examples:
+
+    capn templates add deploy.yaml
+    capn execute --template deploy --var env=staging`
+}
+
+func (a *TemplatesAddCmd) Run(out io.Writer, logger *zap.Logger, config *config.Config) error {
+	t, err := templates.LoadFile(a.File)
+	if err != nil {
+		return err
+	}
+
+	store, err := openTemplateStore(config)
+	if err != nil {
+		return err
+	}
+
+	stored, err := store.Add(t)
+	if err != nil {
+		return fmt.Errorf("failed to add template: %w", err)
+	}
+	logger.Debug("Template stored", zap.String("template", stored.Name), zap.Int("version", stored.Version))
+	fmt.Fprintf(out, "Template %s@%d saved.\n", stored.Name, stored.Version)
+	return nil
+}
+
+// TemplatesListCmd represents the templates list command
+type TemplatesListCmd struct{}
+
+func (l *TemplatesListCmd) Run(out io.Writer, globals *GlobalOptions, config *config.Config) error {
+	store, err := openTemplateStore(config)
+	if err != nil {
+		return err
+	}
+
+	list, err := store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list templates: %w", err)
+	}
+	listing := Listing{Columns: []string{"NAME", "VERSION", "VARIABLES", "DESCRIPTION"}, Empty: "No templates found."}
+	for _, t := range list {
+		names := make([]string, 0, len(t.Variables))
+		for _, v := range t.Variables {
+			names = append(names, v.Name)
+		}
+		listing.Rows = append(listing.Rows, []string{t.Name, strconv.Itoa(t.Version), strings.Join(names, ","), truncate(t.Description, 60)})
+	}
+	return newPresenter(out, globals).List(listing)
+}
+
+// TemplatesShowCmd represents the templates show command
+type TemplatesShowCmd struct {
+	Template string `arg:"" name:"template" help:"Template to show, as name or name@version"`
+}
+
+func (s *TemplatesShowCmd) Run(out io.Writer, config *config.Config) error {
+	store, err := openTemplateStore(config)
+	if err != nil {
+		return err
+	}
+
+	name, version, err := templates.ParseRef(s.Template)
+	if err != nil {
+		return err
+	}
+	t, err := store.Get(name, version)
+	if err != nil {
+		return err
+	}
+	versions, err := store.Versions(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Template:  %s@%d\n", t.Name, t.Version)
+	if t.Description != "" {
+		fmt.Fprintf(out, "About:     %s\n", t.Description)
+	}
+	fmt.Fprintf(out, "Goal:      %s\n", t.Goal)
+	fmt.Fprintf(out, "Versions:  %d\n", len(versions))
+	if len(t.Variables) > 0 {
+		fmt.Fprintf(out, "\nVariables:\n")
+		for _, v := range t.Variables {
+			detail := "optional"
+			switch {
+			case v.Required:
+				detail = "required"
+			case v.Default != "":
+				detail = fmt.Sprintf("default %q", v.Default)
+			}
+			fmt.Fprintf(out, "  %s (%s) %s\n", v.Name, detail, v.Description)
+		}
+	}
+	return nil
+}
+
+// openTemplateStore opens the template store in the capn home directory
+func openTemplateStore(cfg *config.Config) (*templates.Store, error) {
+	store, err := templates.NewStore(cfg.TemplatesDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open template store: %w", err)
+	}
+	return store, nil
+}
+
+// resolveTemplateGoal renders the goal of a stored template with the given key=value variables.
+// It returns the goal and the resolved name@version reference.
+func resolveTemplateGoal(cfg *config.Config, ref string, pairs []string) (string, string, error) {
+	name, version, err := templates.ParseRef(ref)
+	if err != nil {
+		return "", "", err
+	}
+	vars, err := templates.ParseVars(pairs)
+	if err != nil {
+		return "", "", err
+	}
+
+	store, err := openTemplateStore(cfg)
+	if err != nil {
+		return "", "", err
+	}
+	t, err := store.Get(name, version)
+	if err != nil {
+		return "", "", err
+	}
+
+	goal, err := t.Render(vars)
+	if err != nil {
+		return "", "", err
+	}
+	return goal, fmt.Sprintf("%s@%d", t.Name, t.Version), nil
+}
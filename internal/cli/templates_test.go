@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func writeTemplateFile(t *testing.T, goal string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deploy.yaml")
+	content := "name: deploy\ndescription: Deploy a service\ngoal: " + goal + "\nvariables:\n  - name: env\n    required: true\n  - name: service\n    default: api\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestTemplatesCmd_AddListShow(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	out, err := runCLI(t, "templates", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No templates found.")
+
+	out, err = runCLI(t, "templates", "add", writeTemplateFile(t, "Deploy ${service} to ${env}"))
+	require.NoError(t, err)
+	assert.Contains(t, out, "Template deploy@1 saved.")
+
+	out, err = runCLI(t, "templates", "add", writeTemplateFile(t, "Deploy ${service} to ${env} carefully"))
+	require.NoError(t, err)
+	assert.Contains(t, out, "Template deploy@2 saved.")
+
+	out, err = runCLI(t, "templates", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "deploy")
+	assert.Contains(t, out, "env,service")
+
+	out, err = runCLI(t, "templates", "show", "deploy@1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Template:  deploy@1")
+	assert.Contains(t, out, "Goal:      Deploy ${service} to ${env}\n")
+	assert.Contains(t, out, "Versions:  2")
+	assert.Contains(t, out, `service (default "api")`)
+
+	assert.Equal(t, []string{"deploy"}, completeLines(t, "templates", "show", ""))
+}
+
+func TestTemplatesCmd_AddInvalid(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+
+	_, err := runCLI(t, "templates", "add", writeTemplateFile(t, "Deploy ${app}"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "undeclared variable: app")
+}
+
+func TestExecuteCmd_Template(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	_, err := runCLI(t, "templates", "add", writeTemplateFile(t, "Deploy ${service} to ${env}"))
+	require.NoError(t, err)
+
+	t.Run("missing required variable", func(t *testing.T) {
+		_, err := runCLI(t, "execute", "--template", "deploy")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing required variables")
+	})
+
+	t.Run("goal and template", func(t *testing.T) {
+		_, err := runCLI(t, "execute", "--template", "deploy", "goal")
+		assert.Error(t, err)
+	})
+
+	t.Run("var without template", func(t *testing.T) {
+		_, err := runCLI(t, "execute", "--var", "env=prod", "goal")
+		assert.Error(t, err)
+	})
+
+	t.Run("records rendered goal", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "test-env-key")
+		_, err := runCLI(t, "execute", "--plan-only", "--template", "deploy", "--var", "env=staging")
+		require.Error(t, err) // planning fails without real API access
+
+		storage, err := task.NewFileTaskStorage(config.NewConfig().TasksDir())
+		require.NoError(t, err)
+		tasks, err := storage.ListTasks(task.TaskFilter{})
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, "Deploy api to staging", tasks[0].Goal)
+		assert.Equal(t, "deploy@1", tasks[0].Metadata["template"])
+		assert.False(t, strings.Contains(tasks[0].Goal, "${"))
+	})
+}
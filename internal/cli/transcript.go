@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// TasksTranscriptCmd represents the tasks transcript command
+type TasksTranscriptCmd struct {
+	TaskID    string `arg:"" name:"task-id" help:"Task to render"`
+	Summarize bool   `help:"Add an LLM-written summary to the transcript"`
+	Output    string `help:"Write to a file instead of stdout" short:"o" type:"path" placeholder:"FILE"`
+}
+
+// Help returns detailed help for the tasks transcript command
+func (tc *TasksTranscriptCmd) Help() string {
+	return `Render a task as a Markdown document: the captain's plan and reasoning, the
+conversation between the captain and crew agents, questions asked, and the
+output of every step. --summarize asks the LLM for a summary to put at the top.
+
+Examples:
+
+    capn tasks transcript task-1a2b3c4d
+    capn tasks transcript task-1a2b3c4d --summarize -o transcript.md`
+}
+
+func (tc *TasksTranscriptCmd) Run(ctx context.Context, out io.Writer, logger *zap.Logger, config *config.Config) error {
+	storage, err := openTaskStorage(config)
+	if err != nil {
+		return err
+	}
+	t, err := storage.GetTask(tc.TaskID)
+	if err != nil {
+		return err
+	}
+
+	summary := ""
+	if tc.Summarize {
+		if !llmConfigured(config) {
+			return fmt.Errorf("OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to summarize transcripts")
+		}
+		cap, err := newCaptain(config)
+		if err != nil {
+			return err
+		}
+		defer cap.Stop()
+
+		logger.Debug("Summarizing transcript", zap.String("task_id", t.ID))
+		if summary, err = cap.SummarizeTranscript(ctx, t.Transcript("")); err != nil {
+			return err
+		}
+	}
+
+	transcript := t.Transcript(summary)
+	if tc.Output == "" {
+		_, err := io.WriteString(out, transcript)
+		return err
+	}
+	if err := os.WriteFile(tc.Output, []byte(transcript), 0o644); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	fmt.Fprintf(out, "Transcript of task %s written to %s\n", t.ID, tc.Output)
+	return nil
+}
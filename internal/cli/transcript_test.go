@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestTasksTranscriptCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	out, err := runCLI(t, "tasks", "transcript", te.ID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "# Task "+te.ID)
+	assert.Contains(t, out, "## Conversation")
+	assert.Contains(t, out, "<research-001> analysis finished")
+	assert.Contains(t, out, "### task-1 (succeeded)")
+
+	path := filepath.Join(t.TempDir(), "transcript.md")
+	out, err = runCLI(t, "tasks", "transcript", te.ID, "-o", path)
+	require.NoError(t, err)
+	assert.Equal(t, "Transcript of task "+te.ID+" written to "+path+"\n", out)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# Task "+te.ID)
+
+	_, err = runCLI(t, "tasks", "transcript", "task-missing")
+	assert.EqualError(t, err, "task not found: task-missing")
+}
+
+func TestTasksTranscriptCmd_SummarizeRequiresOpenAI(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "")
+	te := seedTask(t, task.TaskStatusCompleted)
+
+	_, err := runCLI(t, "tasks", "transcript", te.ID, "--summarize")
+	assert.EqualError(t, err, "OpenAI is not configured; set OPENAI_API_KEY or openai.api_key to summarize transcripts")
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/version"
+)
+
+// VersionCmd represents the version command
+type VersionCmd struct {
+	Check bool `help:"Check GitHub for a newer release (the answer is cached for a day)" env:"CAPN_UPDATE_CHECK"`
+}
+
+// Help returns detailed help for the version command
+func (v *VersionCmd) Help() string {
+	return `Show the capn version with the commit and date it was built from, the Go
+version and the platform. Release builds set these with -ldflags; other builds
+report what the Go toolchain recorded.
+
+With --check (or CAPN_UPDATE_CHECK=1) capn asks GitHub for the latest release
+and says when a newer one exists. It never installs anything, and the answer is
+cached for a day so repeated runs stay offline.
+
+Examples:
+
+    capn version
+    capn version --check`
+}
+
+func (v *VersionCmd) Run(ctx context.Context, out io.Writer, globals *GlobalOptions, logger *zap.Logger, config *config.Config) error {
+	view := versionView{Info: version.Get()}
+	build := view.Info
+	if v.Check {
+		// A failed check is reported but never fails the command
+		checker := version.NewChecker(githubClient(config), config.UpdateCheckFile())
+		update, err := checker.Check(ctx, build.Version)
+		if err != nil {
+			logger.Warn("Failed to check for a newer release", zap.Error(err))
+			view.CheckError = err.Error()
+		} else {
+			view.Update = update
+		}
+	}
+
+	return newPresenter(out, globals).Show(view, func(out io.Writer) error {
+		fmt.Fprintf(out, "capn %s\n", build.Version)
+		if build.Commit != "" {
+			fmt.Fprintf(out, "  commit:   %s\n", build.Commit)
+		}
+		if build.Date != "" {
+			fmt.Fprintf(out, "  built:    %s\n", build.Date)
+		}
+		fmt.Fprintf(out, "  go:       %s\n", build.GoVersion)
+		fmt.Fprintf(out, "  platform: %s\n", build.Platform)
+
+		update := view.Update
+		switch {
+		case view.CheckError != "":
+			fmt.Fprintf(out, "\nWarning: could not check for a newer release: %s\n", view.CheckError)
+		case update == nil:
+		case !build.Release():
+			notef(out, globals, "\nThe latest release is %s; this development build cannot be compared with it.", update.Latest)
+		case update.Available:
+			fmt.Fprintf(out, "\nA newer release is available: %s (%s)\n", update.Latest, update.URL)
+			notef(out, globals, "capn does not update itself; install the new release the way you installed this one.")
+		default:
+			notef(out, globals, "\ncapn is up to date (latest release %s).", update.Latest)
+		}
+		return nil
+	})
+}
+
+// versionView is what version shows as JSON or YAML: the build, and with --check the latest
+// release or why it could not be checked
+type versionView struct {
+	version.Info
+	Update     *version.Update `json:"update,omitempty"`
+	CheckError string          `json:"check_error,omitempty"`
+}
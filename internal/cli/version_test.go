@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/version"
+)
+
+// releaseServer fakes GitHub's latest release endpoint, counting the lookups
+func releaseServer(t *testing.T, status int, tag string) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/iainlowe/capn/releases/latest", r.URL.Path)
+		calls++
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"tag_name": "` + tag + `", "html_url": "https://github.com/iainlowe/capn/releases/tag/` + tag + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestVersionCmd(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("CAPN_UPDATE_CHECK", "false")
+	defer func(v, c, d string) { version.Version, version.Commit, version.Date = v, c, d }(version.Version, version.Commit, version.Date)
+	version.Version, version.Commit, version.Date = "v1.2.0", "0123456789abcdef", "2026-10-01T10:00:00Z"
+	server, calls := releaseServer(t, http.StatusOK, "v1.3.0")
+	path := writeDoctorConfig(t, "github:\n  api_url: "+server.URL+"\n")
+
+	out, err := runCLI(t, "--config", path, "version")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "capn v1.2.0\n")
+	assert.Contains(t, out, "commit:   0123456789abcdef")
+	assert.Contains(t, out, "built:    2026-10-01T10:00:00Z")
+	assert.Contains(t, out, "go:       go")
+	assert.Zero(t, *calls, "the release check is opt-in")
+
+	out, err = runCLI(t, "--config", path, "version", "--check")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "A newer release is available: v1.3.0 (https://github.com/iainlowe/capn/releases/tag/v1.3.0)")
+	assert.Contains(t, out, "capn does not update itself")
+
+	version.Version = "v1.3.0"
+	out, err = runCLI(t, "--config", path, "version", "--check")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "capn is up to date (latest release v1.3.0)")
+	assert.Equal(t, 1, *calls, "the second check is answered from the cache")
+}
+
+func TestVersionCmd_CheckFails(t *testing.T) {
+	t.Setenv("CAPN_HOME", t.TempDir())
+	t.Setenv("GITHUB_TOKEN", "")
+	server, _ := releaseServer(t, http.StatusForbidden, "")
+	path := writeDoctorConfig(t, "github:\n  api_url: "+server.URL+"\n")
+
+	out, err := runCLI(t, "--config", path, "version", "--check")
+	require.NoError(t, err, "a failed check does not fail the command")
+	assert.Contains(t, out, "Warning: could not check for a newer release")
+}
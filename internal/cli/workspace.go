@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// currentWorkspace returns the workspace named with --workspace, or the project directory
+// containing the working directory
+func currentWorkspace(globals *GlobalOptions) (string, error) {
+	if globals != nil && globals.Workspace != "" {
+		return globals.Workspace, nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return task.DetectWorkspace(dir)
+}
+
+// listingWorkspace returns the workspace listings are limited to, or "" with --all-workspaces
+func listingWorkspace(globals *GlobalOptions, all bool) (string, error) {
+	if all {
+		return "", nil
+	}
+	return currentWorkspace(globals)
+}
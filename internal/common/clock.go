@@ -0,0 +1,36 @@
+package common
+
+import "time"
+
+// Clock tells the time and waits for it to pass. Code that polls, times out or records when
+// things happened takes a Clock instead of calling the time package, so tests can drive it with
+// testutil.FakeClock rather than sleeping.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// After returns a channel that receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+	// NewTicker returns a ticker that ticks every d, dropping ticks the reader misses
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a Clock until it is stopped
+type Ticker interface {
+	// C returns the channel ticks are delivered on
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the Clock of the time package
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTicker(d time.Duration) Ticker       { return systemTicker{time.NewTicker(d)} }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// PanicError is the error a supervised function fails with when it panics
+type PanicError struct {
+	// Name identifies the goroutine or call that panicked
+	Name  string
+	Value any
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("panic: %v", e.Value)
+	}
+	return fmt.Sprintf("%s panicked: %v", e.Name, e.Value)
+}
+
+// Unwrap returns the value the function panicked with when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// LogPanic logs a panic with its value and stack trace
+func LogPanic(logger *zap.Logger, message string, err *PanicError, fields ...zap.Field) {
+	if logger == nil {
+		return
+	}
+	fields = append(fields, zap.String("goroutine", err.Name), zap.Any("panic", err.Value), zap.ByteString("stack", err.Stack))
+	logger.Error(message, fields...)
+}
+
+// Recover calls fn, returning a *PanicError in place of a panic
+func Recover(name string, fn func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Name: name, Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// Go runs fn in a goroutine, logging a panic with its stack trace instead of crashing the
+// process
+func Go(logger *zap.Logger, name string, fn func()) {
+	go func() {
+		err := Recover(name, func() error {
+			fn()
+			return nil
+		})
+		if panicErr, ok := err.(*PanicError); ok {
+			LogPanic(logger, "Goroutine panicked", panicErr)
+		}
+	}()
+}
+
+// Group runs goroutines under supervision, like errgroup: Wait waits for all of them and
+// returns the first error, and the group's context is cancelled as soon as one fails. A
+// goroutine that panics fails with a *PanicError, which is logged with its stack trace,
+// rather than crashing the process.
+type Group struct {
+	cancel context.CancelCauseFunc
+	logger *zap.Logger
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	once sync.Once
+	err  error
+}
+
+// NewGroup returns a group and the context its goroutines run under, which is cancelled when
+// one of them fails or Wait returns
+func NewGroup(ctx context.Context, logger *zap.Logger) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel, logger: logger}, ctx
+}
+
+// SetLimit bounds how many goroutines run at once; Go blocks while the limit is reached. It
+// must be called before the first Go.
+func (g *Group) SetLimit(n int) {
+	if n > 0 {
+		g.sem = make(chan struct{}, n)
+	}
+}
+
+// Go runs fn in a supervised goroutine named for logs and panic errors
+func (g *Group) Go(name string, fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+		if err := Recover(name, fn); err != nil {
+			if panicErr, ok := err.(*PanicError); ok {
+				LogPanic(g.logger, "Goroutine panicked", panicErr)
+			}
+			g.once.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
+		}
+	}()
+}
+
+// Wait blocks until every goroutine has returned and returns the first error
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(context.Canceled)
+	return g.err
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecover(t *testing.T) {
+	assert.NoError(t, Recover("ok", func() error { return nil }))
+
+	failure := errors.New("failed")
+	assert.Same(t, failure, Recover("fails", func() error { return failure }))
+
+	err := Recover("worker-1", func() error { panic("boom") })
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "worker-1 panicked: boom", err.Error())
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestRecover")
+
+	cause := errors.New("closed channel")
+	err = Recover("", func() error { panic(cause) })
+	assert.ErrorIs(t, err, cause, "a panic with an error unwraps to it")
+	assert.Equal(t, "panic: closed channel", err.Error())
+}
+
+func TestGo_LogsPanic(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	done := make(chan struct{})
+	Go(zap.New(core), "monitor", func() {
+		defer close(done)
+		panic("monitor bug")
+	})
+	<-done
+
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, time.Millisecond)
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "monitor", fields["goroutine"])
+	assert.Equal(t, "monitor bug", fields["panic"])
+	assert.NotEmpty(t, fields["stack"])
+}
+
+func TestGroup(t *testing.T) {
+	group, ctx := NewGroup(context.Background(), nil)
+	var ran atomic.Int32
+	for i := 0; i < 3; i++ {
+		group.Go("worker", func() error {
+			ran.Add(1)
+			return nil
+		})
+	}
+	assert.NoError(t, group.Wait())
+	assert.Equal(t, int32(3), ran.Load())
+	assert.Error(t, ctx.Err(), "the context is cancelled once the group is done")
+}
+
+func TestGroup_PanicCancelsSiblings(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	group, ctx := NewGroup(context.Background(), zap.New(core))
+
+	group.Go("sibling", func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	group.Go("step-2", func() error { panic("agent bug") })
+
+	err := group.Wait()
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr, "the first failure is the panic, not the cancelled sibling")
+	assert.Equal(t, "step-2", panicErr.Name)
+	assert.ErrorIs(t, context.Cause(ctx), err)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "step-2", logs.All()[0].ContextMap()["goroutine"])
+}
+
+func TestGroup_SetLimit(t *testing.T) {
+	group, _ := NewGroup(context.Background(), nil)
+	group.SetLimit(2)
+	var running, peak, started atomic.Int32
+	// The first two workers wait for each other, so the limit is known to have been reached
+	var together sync.WaitGroup
+	together.Add(2)
+	for i := 0; i < 6; i++ {
+		group.Go("worker", func() error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			if started.Add(1) <= 2 {
+				together.Done()
+				together.Wait()
+			}
+			running.Add(-1)
+			return nil
+		})
+	}
+	require.NoError(t, group.Wait())
+	assert.Equal(t, int32(2), peak.Load())
+}
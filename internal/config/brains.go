@@ -0,0 +1,38 @@
+package config
+
+import "fmt"
+
+// CrewBrainConfig gives one crew agent type an LLM to reason about its steps before acting
+type CrewBrainConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Model overrides the provider's model, so crew can use a cheaper model than the Captain
+	Model string `yaml:"model,omitempty"`
+	// Prompt replaces the agent type's default description of its skills
+	Prompt string `yaml:"prompt,omitempty"`
+	// Tools restricts the operations the agent may perform; empty allows all of its own
+	Tools     []string `yaml:"tools,omitempty"`
+	MaxTokens int      `yaml:"max_tokens,omitempty"`
+}
+
+// Validate validates the crew brain
+func (b CrewBrainConfig) Validate() error {
+	if b.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens cannot be negative")
+	}
+	for _, tool := range b.Tools {
+		if tool == "" {
+			return fmt.Errorf("tools cannot contain an empty name")
+		}
+	}
+	return nil
+}
+
+// HasBrains reports whether any crew agent type has its brain enabled
+func (c CrewConfig) HasBrains() bool {
+	for _, brain := range c.Brains {
+		if brain.Enabled {
+			return true
+		}
+	}
+	return false
+}
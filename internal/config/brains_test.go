@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestCrewBrainConfig_Validate(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[CrewBrainConfig]{
+		{Name: "defaults", Input: CrewBrainConfig{}},
+		{Name: "all set", Input: CrewBrainConfig{Enabled: true, Model: "gpt-4o-mini", Prompt: "You read files.", Tools: []string{"file_read"}, MaxTokens: 200}},
+		{
+			Name:      "negative max tokens",
+			Input:     CrewBrainConfig{MaxTokens: -1},
+			WantError: true,
+			ErrorMsg:  "max_tokens cannot be negative",
+		},
+		{
+			Name:      "empty tool",
+			Input:     CrewBrainConfig{Tools: []string{"file_read", ""}},
+			WantError: true,
+			ErrorMsg:  "tools cannot contain an empty name",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, func(b CrewBrainConfig) error {
+		return b.Validate()
+	})
+}
+
+func TestCrewConfig_HasBrains(t *testing.T) {
+	assert.False(t, CrewConfig{}.HasBrains())
+	assert.False(t, CrewConfig{Brains: map[string]CrewBrainConfig{"file": {Model: "gpt-4o-mini"}}}.HasBrains())
+	assert.True(t, CrewConfig{Brains: map[string]CrewBrainConfig{"file": {}, "research": {Enabled: true}}}.HasBrains())
+}
+
+func TestConfig_ValidateCrewBrains(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Crew.Brains = map[string]CrewBrainConfig{"network": {Enabled: true, MaxTokens: -5}}
+	err := cfg.Validate()
+	assert.EqualError(t, err, "crew brain for network: max_tokens cannot be negative")
+}
@@ -3,6 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/iainlowe/capn/internal/common"
@@ -16,41 +20,581 @@ type GlobalConfig struct {
 	DryRun   bool          `yaml:"dry_run" kong:"help='Plan without execution'"`
 	Parallel int           `yaml:"parallel" kong:"help='Maximum parallel agents',short='p',default='5'"`
 	Timeout  time.Duration `yaml:"timeout" kong:"help='Global timeout duration',default='5m'"`
+	Profile  string        `yaml:"profile,omitempty" kong:"help='Configuration profile to apply'"`
+	// LogLevel, LogFormat and LogFile control capn's own diagnostic logs
+	LogLevel  string `yaml:"log_level,omitempty"`
+	LogFormat string `yaml:"log_format,omitempty"`
+	LogFile   string `yaml:"log_file,omitempty"`
 }
 
 // CaptainConfig holds Captain agent configuration
 type CaptainConfig struct {
-	MaxConcurrentAgents int           `yaml:"max_concurrent_agents"`
-	PlanningTimeout     time.Duration `yaml:"planning_timeout"`
+	MaxConcurrentAgents int             `yaml:"max_concurrent_agents"`
+	PlanningTimeout     time.Duration   `yaml:"planning_timeout"`
+	MaxConcurrentTasks  int             `yaml:"max_concurrent_tasks,omitempty"`
+	Rules               PlanRulesConfig `yaml:"rules,omitempty"`
+	// ConversationTokens is the token budget of the Captain's memory of a goal before older
+	// reasoning is summarized; zero uses the default
+	ConversationTokens int `yaml:"conversation_tokens,omitempty"`
+	// MaxClarifyingQuestions is how many questions the Captain may ask about an ambiguous goal
+	// before planning it; zero plans every goal as given
+	MaxClarifyingQuestions int `yaml:"max_clarifying_questions"`
+	// MaxTaskRuntime bounds how long a task's plan may execute before its remaining steps are
+	// cancelled and the task fails; zero lets tasks run until they finish
+	MaxTaskRuntime time.Duration `yaml:"max_task_runtime,omitempty"`
+	// ResumeInterrupted has the daemon resume the tasks it finds interrupted when it starts,
+	// running the steps they had not completed; otherwise they wait for "capn tasks retry"
+	ResumeInterrupted bool `yaml:"resume_interrupted,omitempty"`
+	// Language is the language tag, such as "fr", plans, reports and digest summaries are
+	// written in; empty or "auto" follows the language of each goal
+	Language string `yaml:"language,omitempty"`
+	// MissingVariables is how a step's ${steps.<id>.output} and ${findings.<key>} references
+	// that cannot be resolved are handled: strict, the default, fails the step and lenient
+	// leaves them empty
+	MissingVariables string `yaml:"missing_variables,omitempty"`
 }
 
+const (
+	// MissingVariablesStrict fails a step whose references cannot be resolved
+	MissingVariablesStrict = "strict"
+	// MissingVariablesLenient replaces references that cannot be resolved with nothing
+	MissingVariablesLenient = "lenient"
+)
+
+// languagePattern matches language tags such as "fr" and "pt-BR"
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// ValidateLanguage checks a language setting is "auto" or a language tag such as "fr" or
+// "pt-BR"; empty means "auto"
+func ValidateLanguage(language string) error {
+	if language == "" || language == "auto" || languagePattern.MatchString(language) {
+		return nil
+	}
+	return fmt.Errorf("invalid language %q: use a language tag such as fr or pt-BR, or auto", language)
+}
+
+// PlanRulesConfig holds the static rules every plan must pass; zero values disable a rule
+type PlanRulesConfig struct {
+	MaxTasks           int           `yaml:"max_tasks,omitempty"`
+	MaxDuration        time.Duration `yaml:"max_duration,omitempty"`
+	ForbiddenCommands  []string      `yaml:"forbidden_commands,omitempty"`
+	RequiredSteps      []string      `yaml:"required_steps,omitempty"`
+	MaxDependencyDepth int           `yaml:"max_dependency_depth,omitempty"`
+}
+
+// Validate validates the plan rules
+func (r PlanRulesConfig) Validate() error {
+	switch {
+	case r.MaxTasks < 0:
+		return fmt.Errorf("max_tasks cannot be negative")
+	case r.MaxDuration < 0:
+		return fmt.Errorf("max_duration cannot be negative")
+	case r.MaxDependencyDepth < 0:
+		return fmt.Errorf("max_dependency_depth cannot be negative")
+	}
+	for _, step := range r.RequiredSteps {
+		switch step {
+		case "analysis", "execution", "validation", "reporting":
+		default:
+			return fmt.Errorf("invalid required step type: %s, must be one of: analysis, execution, validation, reporting", step)
+		}
+	}
+	return nil
+}
+
+// PlanningConfig holds settings for how goals are planned
+type PlanningConfig struct {
+	Context PlanningContextConfig `yaml:"context"`
+}
+
+// PlanningContextConfig controls the environment summary injected into planning prompts
+type PlanningContextConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Git     bool     `yaml:"git"`
+	Tools   []string `yaml:"tools,omitempty"`
+}
+
+// DefaultPlanningTools are the executables probed for when planning.context.tools is not set
+var DefaultPlanningTools = []string{"git", "go", "node", "npm", "python3", "cargo", "make", "docker", "kubectl", "terraform"}
+
 // CrewConfig holds Crew agent configuration
 type CrewConfig struct {
-	Timeouts map[string]time.Duration `yaml:"timeouts"`
+	Timeouts map[string]time.Duration   `yaml:"timeouts"`
+	Limits   map[string]CrewLimits      `yaml:"limits,omitempty"`
+	Sandbox  SandboxConfig              `yaml:"sandbox,omitempty"`
+	Brains   map[string]CrewBrainConfig `yaml:"brains,omitempty"`
+	// HTTP configures the network agent's HTTP client
+	HTTP HTTPConfig `yaml:"http,omitempty"`
+	// Watchdog sets, per crew agent type, when a step counts as stalled; the "default" entry
+	// applies to agent types without one of their own
+	Watchdog WatchdogConfigs `yaml:"watchdog,omitempty"`
+	// File, Network, Research and Code hold the settings of each agent type
+	File     FileAgentConfig     `yaml:"file,omitempty"`
+	Network  NetworkAgentConfig  `yaml:"network,omitempty"`
+	Research ResearchAgentConfig `yaml:"research,omitempty"`
+	Code     CodeAgentConfig     `yaml:"code,omitempty"`
+}
+
+// WatchdogConfig sets when a step of one crew agent type counts as stalled and what is done then
+type WatchdogConfig struct {
+	// StallAfter is how long a step may run without writing output before it is flagged as
+	// stalled; zero never flags it
+	StallAfter time.Duration `yaml:"stall_after,omitempty"`
+	// Decide has the Captain choose whether to keep waiting, kill and retry the step or replan
+	// the rest of the goal; otherwise stalls are only logged
+	Decide bool `yaml:"decide,omitempty"`
+}
+
+// WatchdogConfigs holds the watchdog settings of each crew agent type
+type WatchdogConfigs map[string]WatchdogConfig
+
+// For returns the watchdog settings of a crew agent type, falling back to the "default" entry
+func (w WatchdogConfigs) For(agentType string) WatchdogConfig {
+	if watchdog, ok := w[agentType]; ok {
+		return watchdog
+	}
+	return w["default"]
+}
+
+// SandboxConfig restricts the working directories, shells and environment variables plan steps
+// may request; empty lists place no restriction
+type SandboxConfig struct {
+	AllowedDirs   []string `yaml:"allowed_dirs,omitempty"`
+	AllowedShells []string `yaml:"allowed_shells,omitempty"`
+	ProtectedEnv  []string `yaml:"protected_env,omitempty"`
+}
+
+// IsZero reports whether the sandbox places no restrictions
+func (s SandboxConfig) IsZero() bool {
+	return len(s.AllowedDirs) == 0 && len(s.AllowedShells) == 0 && len(s.ProtectedEnv) == 0
+}
+
+// Validate validates the sandbox policy
+func (s SandboxConfig) Validate() error {
+	for _, dir := range s.AllowedDirs {
+		if dir == "" {
+			return fmt.Errorf("allowed_dirs cannot contain an empty path")
+		}
+	}
+	for _, name := range s.ProtectedEnv {
+		if name == "" {
+			return fmt.Errorf("protected_env cannot contain an empty name")
+		}
+	}
+	return nil
+}
+
+// CrewLimits holds the resource quotas for one crew agent type; zero means unlimited
+type CrewLimits struct {
+	MaxConcurrentTasks   int   `yaml:"max_concurrent_tasks,omitempty"`
+	MaxDownloadBytes     int64 `yaml:"max_download_bytes,omitempty"`
+	MaxFileSize          int64 `yaml:"max_file_size,omitempty"`
+	MaxRequestsPerMinute int   `yaml:"max_requests_per_minute,omitempty"`
+}
+
+// Validate validates the crew limits
+func (l CrewLimits) Validate() error {
+	switch {
+	case l.MaxConcurrentTasks < 0:
+		return fmt.Errorf("max_concurrent_tasks cannot be negative")
+	case l.MaxDownloadBytes < 0:
+		return fmt.Errorf("max_download_bytes cannot be negative")
+	case l.MaxFileSize < 0:
+		return fmt.Errorf("max_file_size cannot be negative")
+	case l.MaxRequestsPerMinute < 0:
+		return fmt.Errorf("max_requests_per_minute cannot be negative")
+	}
+	return nil
 }
 
 // MCPConfig holds MCP server configuration
 type MCPConfig struct {
 	Timeout    time.Duration `yaml:"timeout"`
 	RetryCount int           `yaml:"retry_count"`
+	HTTP       HTTPConfig    `yaml:"http,omitempty"`
 }
 
 // OpenAIConfig holds OpenAI configuration
 type OpenAIConfig struct {
-	APIKey      string  `yaml:"api_key"`
-	Model       string  `yaml:"model"`
-	BaseURL     string  `yaml:"base_url,omitempty"`
-	MaxRetries  int     `yaml:"max_retries"`
-	Temperature float64 `yaml:"temperature"`
+	APIKey      string     `yaml:"api_key"`
+	Model       string     `yaml:"model"`
+	BaseURL     string     `yaml:"base_url,omitempty"`
+	MaxRetries  int        `yaml:"max_retries"`
+	Temperature float64    `yaml:"temperature"`
+	HTTP        HTTPConfig `yaml:"http,omitempty"`
+}
+
+// LLM provider types
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+	// ProviderOpenAICompatible is any server speaking the OpenAI chat completions API, such as
+	// Together, Groq, LM Studio or vLLM
+	ProviderOpenAICompatible = "openai-compatible"
+	// ProviderReplay answers from responses recorded on disk instead of calling an API
+	ProviderReplay = "replay"
+)
+
+// ProviderTypes lists the supported LLM provider types
+var ProviderTypes = []string{ProviderOpenAI, ProviderAnthropic, ProviderOllama, ProviderOpenAICompatible, ProviderReplay}
+
+// LLMConfig holds the LLM providers tried in order and the rate limits and circuit breaker
+// applied to each of them. Without providers only the openai section is used.
+type LLMConfig struct {
+	RequestsPerMinute int                  `yaml:"requests_per_minute,omitempty"`
+	TokensPerMinute   int                  `yaml:"tokens_per_minute,omitempty"`
+	CircuitBreaker    CircuitBreakerConfig `yaml:"circuit_breaker"`
+	Providers         []LLMProviderConfig  `yaml:"providers,omitempty"`
+}
+
+// LLMProviderConfig configures one provider in the fallback chain. Settings left empty on an
+// openai provider are taken from the openai section.
+type LLMProviderConfig struct {
+	Name         string     `yaml:"name,omitempty"`
+	Type         string     `yaml:"type"`
+	APIKey       string     `yaml:"api_key,omitempty"`
+	Model        string     `yaml:"model,omitempty"`
+	BaseURL      string     `yaml:"base_url,omitempty"`
+	Temperature  float64    `yaml:"temperature,omitempty"`
+	BudgetTokens int        `yaml:"budget_tokens,omitempty"`
+	HTTP         HTTPConfig `yaml:"http,omitempty"`
+	// Cassette is the directory a replay provider reads recorded responses from, defaulting to
+	// the capn home's cassettes directory
+	Cassette string `yaml:"cassette,omitempty"`
+	// Record has a replay provider keep the responses of the providers after it in the chain
+	// for requests it has no recording of
+	Record bool `yaml:"record,omitempty"`
+	// Capabilities declares the optional API features an openai-compatible server offers
+	Capabilities ProviderCapabilities `yaml:"capabilities,omitempty"`
+}
+
+// ProviderCapabilities lists the optional API features a provider's server implements
+type ProviderCapabilities struct {
+	FunctionCalling bool `yaml:"function_calling,omitempty"`
+	Embeddings      bool `yaml:"embeddings,omitempty"`
+	// EmbeddingModel is the model embeddings are requested from, defaulting to the provider's model
+	EmbeddingModel string `yaml:"embedding_model,omitempty"`
+}
+
+// DisplayName returns the provider's name, defaulting to its type
+func (p LLMProviderConfig) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Type
+}
+
+// CircuitBreakerConfig controls when a failing provider is taken out of service and for how long
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+}
+
+// Validate validates the LLM provider settings
+func (l LLMConfig) Validate() error {
+	switch {
+	case l.RequestsPerMinute < 0:
+		return fmt.Errorf("requests_per_minute cannot be negative")
+	case l.TokensPerMinute < 0:
+		return fmt.Errorf("tokens_per_minute cannot be negative")
+	case l.CircuitBreaker.FailureThreshold < 0:
+		return fmt.Errorf("circuit_breaker failure_threshold cannot be negative")
+	case l.CircuitBreaker.Cooldown < 0:
+		return fmt.Errorf("circuit_breaker cooldown cannot be negative")
+	}
+
+	names := make(map[string]bool, len(l.Providers))
+	for i, provider := range l.Providers {
+		valid := false
+		for _, providerType := range ProviderTypes {
+			valid = valid || provider.Type == providerType
+		}
+		switch {
+		case !valid:
+			return fmt.Errorf("provider %d: invalid type %q, must be one of: %s", i+1, provider.Type, strings.Join(ProviderTypes, ", "))
+		case provider.Type != ProviderOpenAI && provider.Type != ProviderReplay && provider.Model == "":
+			return fmt.Errorf("provider %s: model is required", provider.DisplayName())
+		case provider.Type == ProviderOpenAICompatible && provider.BaseURL == "":
+			return fmt.Errorf("provider %s: base_url is required", provider.DisplayName())
+		case provider.Capabilities.EmbeddingModel != "" && !provider.Capabilities.Embeddings:
+			return fmt.Errorf("provider %s: embedding_model is set but embeddings are not enabled", provider.DisplayName())
+		case provider.BudgetTokens < 0:
+			return fmt.Errorf("provider %s: budget_tokens cannot be negative", provider.DisplayName())
+		case names[provider.DisplayName()]:
+			return fmt.Errorf("duplicate provider name: %s", provider.DisplayName())
+		}
+		if err := provider.HTTP.Validate(); err != nil {
+			return fmt.Errorf("provider %s: http: %w", provider.DisplayName(), err)
+		}
+		names[provider.DisplayName()] = true
+	}
+	return nil
+}
+
+// BuiltinAgentTypes lists the agent types capn provides; plugins cannot replace them
+var BuiltinAgentTypes = []string{"captain", "file", "network", "research"}
+
+// AgentsConfig holds agent configuration beyond the built-in crew
+type AgentsConfig struct {
+	Plugins   []PluginConfig  `yaml:"plugins,omitempty"`
+	Health    HealthConfig    `yaml:"health,omitempty"`
+	Lifecycle LifecycleConfig `yaml:"lifecycle,omitempty"`
+	// Communication controls how long the daemon keeps the log of messages between agents
+	Communication CommunicationConfig `yaml:"communication,omitempty"`
+}
+
+// CommunicationConfig sets the retention of the persisted agent communication log and the size
+// of the messages it holds. Messages about a task are also kept in the task's own log, which
+// retention does not touch.
+type CommunicationConfig struct {
+	// MaxMessages keeps only the most recent messages; zero keeps them all
+	MaxMessages int `yaml:"max_messages,omitempty"`
+	// MaxAge drops messages older than this; zero keeps them however old
+	MaxAge time.Duration `yaml:"max_age,omitempty"`
+	// MaxMessageSize is the largest message content in bytes passed between agents inline;
+	// larger content is stored as an attachment among the task's artifacts and the message
+	// carries a preview and its reference. Zero uses 64 KiB.
+	MaxMessageSize int `yaml:"max_message_size,omitempty"`
+}
+
+// Validate checks the retention and size limits are not negative
+func (c CommunicationConfig) Validate() error {
+	switch {
+	case c.MaxMessages < 0:
+		return fmt.Errorf("max_messages cannot be negative")
+	case c.MaxAge < 0:
+		return fmt.Errorf("max_age cannot be negative")
+	case c.MaxMessageSize < 0:
+		return fmt.Errorf("max_message_size cannot be negative")
+	}
+	return nil
+}
+
+// HealthConfig controls how the daemon probes agent health and recovers unhealthy agents.
+// Degraded and unhealthy agents are not given new work; unhealthy ones are restarted.
+// A zero interval turns probing off.
+type HealthConfig struct {
+	Interval       time.Duration `yaml:"interval,omitempty"`
+	Restart        bool          `yaml:"restart"`
+	MaxRestarts    int           `yaml:"max_restarts,omitempty"`
+	RestartBackoff time.Duration `yaml:"restart_backoff,omitempty"`
+}
+
+// Validate checks the probe interval and restart limits are not negative
+func (h HealthConfig) Validate() error {
+	switch {
+	case h.Interval < 0:
+		return fmt.Errorf("interval cannot be negative")
+	case h.MaxRestarts < 0:
+		return fmt.Errorf("max_restarts cannot be negative")
+	case h.RestartBackoff < 0:
+		return fmt.Errorf("restart_backoff cannot be negative")
+	}
+	return nil
+}
+
+// LifecycleConfig controls how long the daemon keeps idle agents and how many it spawns ahead
+// of demand. Idle agents are collected and warm pools refilled every interval.
+type LifecycleConfig struct {
+	// IdleTTL terminates agents idle for longer; zero keeps them
+	IdleTTL time.Duration `yaml:"idle_ttl,omitempty"`
+	// WarmPool is the number of idle agents kept ready for each agent type
+	WarmPool map[string]int `yaml:"warm_pool,omitempty"`
+	Interval time.Duration  `yaml:"interval,omitempty"`
+}
+
+// Enabled reports whether idle agents are collected or warm pools kept
+func (l LifecycleConfig) Enabled() bool {
+	return l.IdleTTL > 0 || len(l.WarmPool) > 0
+}
+
+// Validate checks the durations and pool sizes are not negative
+func (l LifecycleConfig) Validate() error {
+	switch {
+	case l.IdleTTL < 0:
+		return fmt.Errorf("idle_ttl cannot be negative")
+	case l.Interval < 0:
+		return fmt.Errorf("interval cannot be negative")
+	}
+	for agentType, size := range l.WarmPool {
+		if size < 0 {
+			return fmt.Errorf("warm_pool for %s cannot be negative", agentType)
+		}
+	}
+	return nil
+}
+
+// PluginConfig declares an external agent process providing an agent type. capn starts the
+// command for every agent of that type and talks to it with JSON lines over stdin and stdout.
+type PluginConfig struct {
+	Name    string            `yaml:"name,omitempty"`
+	Type    string            `yaml:"type"`
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	Dir     string            `yaml:"dir,omitempty"`
+}
+
+// DisplayName returns the plugin's name, defaulting to its agent type
+func (p PluginConfig) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Type
+}
+
+// Validate checks each plugin names a command and a new agent type
+func (a AgentsConfig) Validate() error {
+	types := make(map[string]bool, len(a.Plugins))
+	for i, plugin := range a.Plugins {
+		switch {
+		case plugin.Type == "":
+			return fmt.Errorf("plugin %d: type is required", i+1)
+		case slices.Contains(BuiltinAgentTypes, plugin.Type):
+			return fmt.Errorf("plugin %s: type %q is built in", plugin.DisplayName(), plugin.Type)
+		case plugin.Command == "":
+			return fmt.Errorf("plugin %s: command is required", plugin.DisplayName())
+		case types[plugin.Type]:
+			return fmt.Errorf("duplicate plugin type: %s", plugin.Type)
+		}
+		types[plugin.Type] = true
+	}
+	if err := a.Health.Validate(); err != nil {
+		return fmt.Errorf("health: %w", err)
+	}
+	if err := a.Lifecycle.Validate(); err != nil {
+		return fmt.Errorf("lifecycle: %w", err)
+	}
+	if err := a.Communication.Validate(); err != nil {
+		return fmt.Errorf("communication: %w", err)
+	}
+	return nil
+}
+
+// StorageConfig holds task storage configuration
+type StorageConfig struct {
+	Path string `yaml:"path,omitempty"`
+	// Retention prunes finished tasks from the history as new tasks start
+	Retention RetentionConfig `yaml:"retention,omitempty"`
+}
+
+// RetentionConfig bounds the task history. Only finished tasks are pruned, and never while a
+// queued task still waits for them; zero values keep every task.
+type RetentionConfig struct {
+	// MaxTasks keeps only the most recent finished tasks
+	MaxTasks int `yaml:"max_tasks,omitempty"`
+	// MaxAge prunes tasks that finished longer ago than this
+	MaxAge time.Duration `yaml:"max_age,omitempty"`
+	// FailedMaxAge keeps failed, cancelled and interrupted tasks this long instead of MaxAge,
+	// leaving longer to look into what went wrong
+	FailedMaxAge time.Duration `yaml:"failed_max_age,omitempty"`
+	// Artifacts also deletes the artifacts of pruned tasks; otherwise they are kept on disk
+	Artifacts bool `yaml:"artifacts,omitempty"`
+}
+
+// Validate checks the retention limits are not negative
+func (c RetentionConfig) Validate() error {
+	switch {
+	case c.MaxTasks < 0:
+		return fmt.Errorf("max_tasks cannot be negative")
+	case c.MaxAge < 0:
+		return fmt.Errorf("max_age cannot be negative")
+	case c.FailedMaxAge < 0:
+		return fmt.Errorf("failed_max_age cannot be negative")
+	}
+	return nil
+}
+
+// UIConfig holds web dashboard configuration
+type UIConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
 }
 
 // Config is the main configuration structure
 type Config struct {
-	Global  GlobalConfig  `yaml:"global"`
-	Captain CaptainConfig `yaml:"captain"`
-	Crew    CrewConfig    `yaml:"crew"`
-	MCP     MCPConfig     `yaml:"mcp"`
-	OpenAI  OpenAIConfig  `yaml:"openai"`
+	Global    GlobalConfig    `yaml:"global"`
+	Captain   CaptainConfig   `yaml:"captain"`
+	Planning  PlanningConfig  `yaml:"planning"`
+	Crew      CrewConfig      `yaml:"crew"`
+	MCP       MCPConfig       `yaml:"mcp"`
+	Agents    AgentsConfig    `yaml:"agents,omitempty"`
+	Transport TransportConfig `yaml:"transport,omitempty"`
+	Execution ExecutionConfig `yaml:"execution,omitempty"`
+	OpenAI    OpenAIConfig    `yaml:"openai"`
+	LLM       LLMConfig       `yaml:"llm"`
+	Storage   StorageConfig   `yaml:"storage"`
+	UI        UIConfig        `yaml:"ui"`
+	Secrets   SecretsConfig   `yaml:"secrets,omitempty"`
+	GitHub    GitHubConfig    `yaml:"github,omitempty"`
+
+	Notifications NotificationsConfig `yaml:"notifications,omitempty"`
+	Hooks         HooksConfig         `yaml:"hooks,omitempty"`
+	Integrations  IntegrationsConfig  `yaml:"integrations,omitempty"`
+
+	Profiles map[string]ProfileConfig `yaml:"profiles,omitempty"`
+}
+
+// HomeDir returns the capn data directory, honoring the CAPN_HOME environment variable
+func HomeDir() string {
+	if dir := os.Getenv("CAPN_HOME"); dir != "" {
+		return dir
+	}
+	return defaultHomeDir()
+}
+
+// TasksDir returns the directory used for persisted task executions
+func (c *Config) TasksDir() string {
+	if c.Storage.Path != "" {
+		return c.Storage.Path
+	}
+	return filepath.Join(HomeDir(), "tasks")
+}
+
+// TemplatesDir returns the directory used for stored goal templates
+func (c *Config) TemplatesDir() string {
+	return filepath.Join(HomeDir(), "templates")
+}
+
+// ArtifactsDir returns the directory used for files produced by tasks
+func (c *Config) ArtifactsDir() string {
+	return filepath.Join(HomeDir(), "artifacts")
+}
+
+// ProviderHealthFile returns the file where the latest LLM provider health is recorded
+func (c *Config) ProviderHealthFile() string {
+	return filepath.Join(HomeDir(), "provider-health.json")
+}
+
+// UpdateCheckFile returns the file caching the latest check for a newer capn release
+func (c *Config) UpdateCheckFile() string {
+	return filepath.Join(HomeDir(), "update-check.json")
+}
+
+// NotificationsDir returns the directory holding notifications waiting for a digest
+func (c *Config) NotificationsDir() string {
+	return filepath.Join(HomeDir(), "notifications")
+}
+
+// MessagesFile returns the file where the daemon persists messages between agents
+func (c *Config) MessagesFile() string {
+	return filepath.Join(HomeDir(), "messages.jsonl")
+}
+
+// ConversationsDir returns the directory where "capn chat" keeps its conversations
+func (c *Config) ConversationsDir() string {
+	return filepath.Join(HomeDir(), "conversations")
+}
+
+// ControlSocket returns the unix socket the daemon answers "capn ctl" requests on
+func (c *Config) ControlSocket() string {
+	return filepath.Join(HomeDir(), "daemon.sock")
+}
+
+// ShellHistoryFile returns the file where "capn shell" keeps its input history
+func (c *Config) ShellHistoryFile() string {
+	return filepath.Join(HomeDir(), "shell_history")
 }
 
 // NewConfig creates a new Config with default values
@@ -63,8 +607,12 @@ func NewConfig() *Config {
 			Timeout:  5 * time.Minute,
 		},
 		Captain: CaptainConfig{
-			MaxConcurrentAgents: 5,
-			PlanningTimeout:     30 * time.Second,
+			MaxConcurrentAgents:    5,
+			PlanningTimeout:        30 * time.Second,
+			MaxClarifyingQuestions: 3,
+		},
+		Planning: PlanningConfig{
+			Context: PlanningContextConfig{Enabled: true, Git: true},
 		},
 		Crew: CrewConfig{
 			Timeouts: make(map[string]time.Duration),
@@ -73,23 +621,63 @@ func NewConfig() *Config {
 			Timeout:    10 * time.Second,
 			RetryCount: 3,
 		},
+		Execution: ExecutionConfig{
+			GitSnapshots: true,
+		},
+		Agents: AgentsConfig{
+			Health: HealthConfig{
+				Interval:       30 * time.Second,
+				Restart:        true,
+				MaxRestarts:    3,
+				RestartBackoff: 10 * time.Second,
+			},
+			Lifecycle: LifecycleConfig{Interval: 30 * time.Second},
+		},
 		OpenAI: OpenAIConfig{
 			Model:       "gpt-3.5-turbo",
 			MaxRetries:  3,
 			Temperature: 0.7,
 		},
+		LLM: LLMConfig{
+			CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 5, Cooldown: 30 * time.Second},
+		},
+		UI: UIConfig{
+			Enabled: false,
+			Listen:  "127.0.0.1:7777",
+		},
 	}
 }
 
-// LoadConfig loads configuration from a YAML file
+// LoadOptions controls how a config file is loaded
+type LoadOptions struct {
+	// NoInterpolate keeps "${NAME}" references in the file as written instead of expanding them
+	NoInterpolate bool
+}
+
+// LoadConfig loads configuration from a YAML file, expanding environment variable references
 func LoadConfig(filename string) (*Config, error) {
+	return LoadConfigWith(filename, LoadOptions{})
+}
+
+// LoadConfigWith loads configuration from a YAML file with the given options
+func LoadConfigWith(filename string, opts LoadOptions) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file %s: %w", filename, err)
 	}
 
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", filename, err)
+	}
+	if !opts.NoInterpolate {
+		if err := Interpolate(&doc, os.LookupEnv); err != nil {
+			return nil, fmt.Errorf("failed to interpolate config file %s: %w", filename, err)
+		}
+	}
+
 	config := NewConfig()
-	if err := yaml.Unmarshal(data, config); err != nil {
+	if err := doc.Decode(config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", filename, err)
 	}
 
@@ -118,6 +706,129 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if err := c.Global.ValidateLogging(); err != nil {
+		return err
+	}
+
+	if c.Captain.MaxConcurrentTasks < 0 {
+		return fmt.Errorf("max_concurrent_tasks cannot be negative")
+	}
+
+	if c.Captain.MaxTaskRuntime < 0 {
+		return fmt.Errorf("max_task_runtime cannot be negative")
+	}
+
+	if c.Captain.ConversationTokens < 0 {
+		return fmt.Errorf("conversation_tokens cannot be negative")
+	}
+
+	if c.Captain.MaxClarifyingQuestions < 0 {
+		return fmt.Errorf("max_clarifying_questions cannot be negative")
+	}
+
+	if err := ValidateLanguage(c.Captain.Language); err != nil {
+		return err
+	}
+
+	switch c.Captain.MissingVariables {
+	case "", MissingVariablesStrict, MissingVariablesLenient:
+	default:
+		return fmt.Errorf("invalid missing_variables %q (must be strict or lenient)", c.Captain.MissingVariables)
+	}
+
+	if err := c.Captain.Rules.Validate(); err != nil {
+		return fmt.Errorf("captain rules: %w", err)
+	}
+
+	for agentType, timeout := range c.Crew.Timeouts {
+		if timeout < 0 {
+			return fmt.Errorf("crew timeout for %s cannot be negative", agentType)
+		}
+	}
+
+	for agentType, watchdog := range c.Crew.Watchdog {
+		if watchdog.StallAfter < 0 {
+			return fmt.Errorf("crew watchdog stall_after for %s cannot be negative", agentType)
+		}
+	}
+
+	if err := c.Crew.Sandbox.Validate(); err != nil {
+		return fmt.Errorf("crew sandbox: %w", err)
+	}
+
+	if err := c.Crew.HTTP.Validate(); err != nil {
+		return fmt.Errorf("crew http: %w", err)
+	}
+
+	if err := c.Crew.validateAgents(); err != nil {
+		return err
+	}
+
+	if err := c.MCP.HTTP.Validate(); err != nil {
+		return fmt.Errorf("mcp http: %w", err)
+	}
+
+	if err := c.OpenAI.HTTP.Validate(); err != nil {
+		return fmt.Errorf("openai http: %w", err)
+	}
+
+	for agentType, limits := range c.Crew.Limits {
+		if err := limits.Validate(); err != nil {
+			return fmt.Errorf("crew limits for %s: %w", agentType, err)
+		}
+	}
+
+	for agentType, brain := range c.Crew.Brains {
+		if err := brain.Validate(); err != nil {
+			return fmt.Errorf("crew brain for %s: %w", agentType, err)
+		}
+	}
+
+	if err := c.LLM.Validate(); err != nil {
+		return fmt.Errorf("llm: %w", err)
+	}
+
+	if err := c.Agents.Validate(); err != nil {
+		return fmt.Errorf("agents: %w", err)
+	}
+
+	if err := c.Transport.Validate(); err != nil {
+		return fmt.Errorf("transport: %w", err)
+	}
+
+	if err := c.Execution.Validate(); err != nil {
+		return fmt.Errorf("execution: %w", err)
+	}
+
+	if err := c.Storage.Retention.Validate(); err != nil {
+		return fmt.Errorf("storage retention: %w", err)
+	}
+
+	if err := c.Notifications.Validate(); err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+
+	if err := c.Hooks.Validate(); err != nil {
+		return fmt.Errorf("hooks: %w", err)
+	}
+
+	if err := c.GitHub.Validate(); err != nil {
+		return fmt.Errorf("github: %w", err)
+	}
+
+	if err := c.Integrations.Slack.Validate(); err != nil {
+		return fmt.Errorf("integrations.slack: %w", err)
+	}
+
+	// Validate UI config if the dashboard is enabled
+	if c.UI.Enabled {
+		uiValidator := common.NewValidator()
+		uiValidator.AddRule("listen", common.Required("ui listen"))
+		if err := uiValidator.Validate(map[string]interface{}{"listen": c.UI.Listen}); err != nil {
+			return err
+		}
+	}
+
 	// Validate OpenAI config if API key is provided
 	if c.OpenAI.APIKey != "" {
 		openaiValidator := common.NewValidator()
@@ -17,12 +17,52 @@ func TestConfig_DefaultValues(t *testing.T) {
 	// Test default values are set correctly
 	assert.Equal(t, 5, cfg.Captain.MaxConcurrentAgents)
 	assert.Equal(t, 30*time.Second, cfg.Captain.PlanningTimeout)
+	assert.Equal(t, 3, cfg.Captain.MaxClarifyingQuestions)
 	assert.Equal(t, 3, cfg.MCP.RetryCount)
 	assert.Equal(t, 10*time.Second, cfg.MCP.Timeout)
 	assert.False(t, cfg.Global.Verbose)
 	assert.False(t, cfg.Global.DryRun)
 	assert.Equal(t, 5, cfg.Global.Parallel)
 	assert.Equal(t, 5*time.Minute, cfg.Global.Timeout)
+	assert.False(t, cfg.UI.Enabled)
+	assert.Equal(t, "127.0.0.1:7777", cfg.UI.Listen)
+	assert.Equal(t, PlanningContextConfig{Enabled: true, Git: true}, cfg.Planning.Context)
+	assert.Equal(t, CircuitBreakerConfig{FailureThreshold: 5, Cooldown: 30 * time.Second}, cfg.LLM.CircuitBreaker)
+	assert.Empty(t, cfg.LLM.Providers)
+}
+
+func TestConfig_DataDirectories(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", home)
+
+	cfg := NewConfig()
+	assert.Equal(t, home, HomeDir())
+	assert.Equal(t, filepath.Join(home, "tasks"), cfg.TasksDir())
+	assert.Equal(t, filepath.Join(home, "templates"), cfg.TemplatesDir())
+	assert.Equal(t, filepath.Join(home, "artifacts"), cfg.ArtifactsDir())
+	assert.Equal(t, filepath.Join(home, "provider-health.json"), cfg.ProviderHealthFile())
+	assert.Equal(t, filepath.Join(home, "shell_history"), cfg.ShellHistoryFile())
+	assert.Equal(t, filepath.Join(home, "daemon.sock"), cfg.ControlSocket())
+
+	cfg.Storage.Path = "/var/lib/capn/tasks"
+	assert.Equal(t, "/var/lib/capn/tasks", cfg.TasksDir())
+}
+
+func TestConfig_LoadUIConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+ui:
+  enabled: true
+  listen: "0.0.0.0:8080"
+`
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0644))
+
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.True(t, cfg.UI.Enabled)
+	assert.Equal(t, "0.0.0.0:8080", cfg.UI.Listen)
 }
 
 func TestConfig_LoadFromFile(t *testing.T) {
@@ -45,6 +85,18 @@ crew:
   timeouts:
     research: 300s
     code: 600s
+  limits:
+    file:
+      max_file_size: 1048576
+    network:
+      max_concurrent_tasks: 2
+      max_download_bytes: 10485760
+      max_requests_per_minute: 30
+
+planning:
+  context:
+    git: false
+    tools: [go, docker]
 
 mcp:
   timeout: 15s
@@ -67,6 +119,9 @@ mcp:
 	assert.Equal(t, 60*time.Second, cfg.Captain.PlanningTimeout)
 	assert.Equal(t, 15*time.Second, cfg.MCP.Timeout)
 	assert.Equal(t, 5, cfg.MCP.RetryCount)
+	assert.Equal(t, CrewLimits{MaxFileSize: 1 << 20}, cfg.Crew.Limits["file"])
+	assert.Equal(t, CrewLimits{MaxConcurrentTasks: 2, MaxDownloadBytes: 10 << 20, MaxRequestsPerMinute: 30}, cfg.Crew.Limits["network"])
+	assert.Equal(t, PlanningContextConfig{Enabled: true, Git: false, Tools: []string{"go", "docker"}}, cfg.Planning.Context, "unset keys keep their defaults")
 }
 
 func TestConfig_LoadNonExistentFile(t *testing.T) {
@@ -218,6 +273,460 @@ func TestConfig_Validate(t *testing.T) {
 			WantError: true,
 			ErrorMsg:  "openai max_retries cannot be negative",
 		},
+		{
+			Name: "negative crew limit",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Crew: CrewConfig{
+					Limits: map[string]CrewLimits{"network": {MaxRequestsPerMinute: -1}},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "crew limits for network: max_requests_per_minute cannot be negative",
+		},
+		{
+			Name: "negative crew timeout",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Crew: CrewConfig{
+					Timeouts: map[string]time.Duration{"file": -time.Second},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "crew timeout for file cannot be negative",
+		},
+		{
+			Name: "negative crew watchdog threshold",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Crew: CrewConfig{
+					Watchdog: WatchdogConfigs{"default": {StallAfter: -time.Minute}},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "crew watchdog stall_after for default cannot be negative",
+		},
+		{
+			Name: "unknown required plan step",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					Rules:               PlanRulesConfig{RequiredSteps: []string{"review"}},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "captain rules: invalid required step type: review, must be one of: analysis, execution, validation, reporting",
+		},
+		{
+			Name: "negative plan rule limit",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					Rules:               PlanRulesConfig{MaxTasks: -1},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "captain rules: max_tasks cannot be negative",
+		},
+		{
+			Name: "empty sandbox directory",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Crew: CrewConfig{
+					Sandbox: SandboxConfig{AllowedDirs: []string{""}},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "crew sandbox: allowed_dirs cannot contain an empty path",
+		},
+		{
+			Name: "negative LLM rate limit",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{TokensPerMinute: -1},
+			},
+			WantError: true,
+			ErrorMsg:  "llm: tokens_per_minute cannot be negative",
+		},
+		{
+			Name: "LLM provider without model",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{Providers: []LLMProviderConfig{{Type: ProviderOpenAI}, {Type: ProviderOllama}}},
+			},
+			WantError: true,
+			ErrorMsg:  "llm: provider ollama: model is required",
+		},
+		{
+			Name: "replay LLM provider without model",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{Providers: []LLMProviderConfig{{Type: ProviderReplay}, {Type: ProviderOpenAI}}},
+			},
+			WantError: false,
+		},
+		{
+			Name: "LLM provider with unknown type",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{Providers: []LLMProviderConfig{{Type: "bard", Model: "x"}}},
+			},
+			WantError: true,
+			ErrorMsg:  `llm: provider 1: invalid type "bard", must be one of: openai, anthropic, ollama, openai-compatible, replay`,
+		},
+		{
+			Name: "openai-compatible LLM provider without base URL",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{Providers: []LLMProviderConfig{{Name: "groq", Type: ProviderOpenAICompatible, Model: "llama-3.1-70b"}}},
+			},
+			WantError: true,
+			ErrorMsg:  "llm: provider groq: base_url is required",
+		},
+		{
+			Name: "LLM provider embedding model without embeddings",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{Providers: []LLMProviderConfig{{Name: "vllm", Type: ProviderOpenAICompatible, Model: "qwen",
+					BaseURL: "http://localhost:8000/v1", Capabilities: ProviderCapabilities{EmbeddingModel: "bge"}}}},
+			},
+			WantError: true,
+			ErrorMsg:  "llm: provider vllm: embedding_model is set but embeddings are not enabled",
+		},
+		{
+			Name: "duplicate LLM provider names",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				LLM: LLMConfig{Providers: []LLMProviderConfig{
+					{Type: ProviderOllama, Model: "llama3"},
+					{Type: ProviderOllama, Model: "mistral"},
+				}},
+			},
+			WantError: true,
+			ErrorMsg:  "llm: duplicate provider name: ollama",
+		},
+		{
+			Name: "negative max concurrent tasks",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					MaxConcurrentTasks:  -1,
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "max_concurrent_tasks cannot be negative",
+		},
+		{
+			Name: "negative conversation tokens",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					ConversationTokens:  -1,
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "conversation_tokens cannot be negative",
+		},
+		{
+			Name: "negative max clarifying questions",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents:    5,
+					PlanningTimeout:        30 * time.Second,
+					MaxClarifyingQuestions: -1,
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "max_clarifying_questions cannot be negative",
+		},
+		{
+			Name: "negative max task runtime",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					MaxTaskRuntime:      -time.Minute,
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "max_task_runtime cannot be negative",
+		},
+		{
+			Name: "invalid language",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					Language:            "French!",
+				},
+			},
+			WantError: true,
+			ErrorMsg:  `invalid language "French!": use a language tag such as fr or pt-BR, or auto`,
+		},
+		{
+			Name: "invalid missing variables mode",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+					MissingVariables:    "ignore",
+				},
+			},
+			WantError: true,
+			ErrorMsg:  `invalid missing_variables "ignore" (must be strict or lenient)`,
+		},
+		{
+			Name: "negative communication max age",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{
+					Communication: CommunicationConfig{MaxAge: -time.Hour},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "communication: max_age cannot be negative",
+		},
+		{
+			Name: "negative communication max message size",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{
+					Communication: CommunicationConfig{MaxMessageSize: -1},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "communication: max_message_size cannot be negative",
+		},
+		{
+			Name: "negative retention max tasks",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Storage: StorageConfig{
+					Retention: RetentionConfig{MaxTasks: -1},
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "storage retention: max_tasks cannot be negative",
+		},
+		{
+			Name: "plugin without type",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{Plugins: []PluginConfig{{Command: "capn-lint"}}},
+			},
+			WantError: true,
+			ErrorMsg:  "agents: plugin 1: type is required",
+		},
+		{
+			Name: "plugin with built-in type",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{Plugins: []PluginConfig{{Type: "file", Command: "capn-files"}}},
+			},
+			WantError: true,
+			ErrorMsg:  `agents: plugin file: type "file" is built in`,
+		},
+		{
+			Name: "plugin without command",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{Plugins: []PluginConfig{{Name: "linter", Type: "lint"}}},
+			},
+			WantError: true,
+			ErrorMsg:  "agents: plugin linter: command is required",
+		},
+		{
+			Name: "duplicate plugin types",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{Plugins: []PluginConfig{{Type: "lint", Command: "a"}, {Type: "lint", Command: "b"}}},
+			},
+			WantError: true,
+			ErrorMsg:  "agents: duplicate plugin type: lint",
+		},
+		{
+			Name: "negative agent health interval",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{Health: HealthConfig{Interval: -time.Second}},
+			},
+			WantError: true,
+			ErrorMsg:  "agents: health: interval cannot be negative",
+		},
+		{
+			Name: "negative warm pool",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				Agents: AgentsConfig{Lifecycle: LifecycleConfig{IdleTTL: time.Minute, WarmPool: map[string]int{"file": -1}}},
+			},
+			WantError: true,
+			ErrorMsg:  "agents: lifecycle: warm_pool for file cannot be negative",
+		},
+		{
+			Name: "UI enabled without listen address",
+			Input: &Config{
+				Global: GlobalConfig{
+					Parallel: 5,
+				},
+				Captain: CaptainConfig{
+					MaxConcurrentAgents: 5,
+					PlanningTimeout:     30 * time.Second,
+				},
+				UI: UIConfig{
+					Enabled: true,
+				},
+			},
+			WantError: true,
+			ErrorMsg:  "ui listen cannot be empty",
+		},
 		{
 			Name: "OpenAI config with empty API key",
 			Input: &Config{
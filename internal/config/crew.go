@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Research search providers
+const (
+	// SearchProviderSearXNG queries the JSON API of a SearXNG instance at search_url
+	SearchProviderSearXNG = "searxng"
+)
+
+// SearchProviders lists the search providers research agents can query
+var SearchProviders = []string{SearchProviderSearXNG}
+
+// FileAgentConfig configures the file agents
+type FileAgentConfig struct {
+	// SandboxRoot confines file steps to a directory: relative paths resolve inside it and
+	// paths leaving it are refused. Empty leaves paths unrestricted.
+	SandboxRoot string `yaml:"sandbox_root,omitempty"`
+}
+
+// IsZero reports whether the block leaves file agents at their defaults
+func (c FileAgentConfig) IsZero() bool {
+	return c.SandboxRoot == ""
+}
+
+// Validate validates the file agent configuration
+func (c FileAgentConfig) Validate() error {
+	if c.SandboxRoot != "" && !filepath.IsAbs(c.SandboxRoot) && c.SandboxRoot != "~" && !strings.HasPrefix(c.SandboxRoot, "~/") {
+		return fmt.Errorf("sandbox_root %q must be an absolute path", c.SandboxRoot)
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes the block, rejecting settings file agents do not have
+func (c *FileAgentConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain FileAgentConfig
+	return decodeKnownFields(node, (*plain)(c))
+}
+
+// NetworkAgentConfig configures the network agents
+type NetworkAgentConfig struct {
+	// AllowedHosts are the hosts network steps may reach; "*.example.com" matches any
+	// subdomain. Empty allows every host.
+	AllowedHosts []string `yaml:"allowed_hosts,omitempty"`
+}
+
+// IsZero reports whether the block leaves network agents at their defaults
+func (c NetworkAgentConfig) IsZero() bool {
+	return len(c.AllowedHosts) == 0
+}
+
+// Validate validates the network agent configuration
+func (c NetworkAgentConfig) Validate() error {
+	for _, host := range c.AllowedHosts {
+		switch {
+		case host == "":
+			return fmt.Errorf("allowed_hosts cannot contain an empty host")
+		case strings.ContainsAny(host, "/ "):
+			return fmt.Errorf("allowed_hosts entry %q must be a host name, not a URL", host)
+		case strings.Contains(strings.TrimPrefix(host, "*."), "*"):
+			return fmt.Errorf("allowed_hosts entry %q may only use a wildcard as its first label", host)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes the block, rejecting settings network agents do not have
+func (c *NetworkAgentConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain NetworkAgentConfig
+	return decodeKnownFields(node, (*plain)(c))
+}
+
+// ResearchAgentConfig configures the research agents
+type ResearchAgentConfig struct {
+	// SearchProvider has research steps given no sources search for them; empty researches
+	// only from the sources a step lists
+	SearchProvider string `yaml:"search_provider,omitempty"`
+	// SearchURL is the address of the search provider's instance
+	SearchURL string `yaml:"search_url,omitempty"`
+	// MaxResults caps the search results cited by a step, defaulting to 5
+	MaxResults int `yaml:"max_results,omitempty"`
+}
+
+// IsZero reports whether the block leaves research agents at their defaults
+func (c ResearchAgentConfig) IsZero() bool {
+	return c.SearchProvider == "" && c.SearchURL == "" && c.MaxResults == 0
+}
+
+// Validate validates the research agent configuration
+func (c ResearchAgentConfig) Validate() error {
+	if c.MaxResults < 0 {
+		return fmt.Errorf("max_results cannot be negative")
+	}
+	if c.SearchProvider == "" {
+		if c.SearchURL != "" {
+			return fmt.Errorf("search_url is set but no search_provider is")
+		}
+		return nil
+	}
+	if !slices.Contains(SearchProviders, c.SearchProvider) {
+		return fmt.Errorf("unknown search_provider %q (must be one of: %s)", c.SearchProvider, strings.Join(SearchProviders, ", "))
+	}
+	if c.SearchURL == "" {
+		return fmt.Errorf("search_url is required for the %s search provider", c.SearchProvider)
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes the block, rejecting settings research agents do not have
+func (c *ResearchAgentConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain ResearchAgentConfig
+	return decodeKnownFields(node, (*plain)(c))
+}
+
+// CodeAgentConfig configures the steps of code agents, which plugins provide
+type CodeAgentConfig struct {
+	// AllowedRepos are the repositories code steps may work in: each step must set a workdir
+	// inside one of them. Empty places no restriction.
+	AllowedRepos []string `yaml:"allowed_repos,omitempty"`
+}
+
+// Validate validates the code agent configuration
+func (c CodeAgentConfig) Validate() error {
+	for _, repo := range c.AllowedRepos {
+		if repo == "" {
+			return fmt.Errorf("allowed_repos cannot contain an empty path")
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes the block, rejecting settings code agents do not have
+func (c *CodeAgentConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain CodeAgentConfig
+	return decodeKnownFields(node, (*plain)(c))
+}
+
+// validateAgents validates the configuration blocks of each crew agent type
+func (c CrewConfig) validateAgents() error {
+	if err := c.File.Validate(); err != nil {
+		return fmt.Errorf("crew file: %w", err)
+	}
+	if err := c.Network.Validate(); err != nil {
+		return fmt.Errorf("crew network: %w", err)
+	}
+	if err := c.Research.Validate(); err != nil {
+		return fmt.Errorf("crew research: %w", err)
+	}
+	if err := c.Code.Validate(); err != nil {
+		return fmt.Errorf("crew code: %w", err)
+	}
+	return nil
+}
+
+// decodeKnownFields decodes a mapping into out, a pointer to a struct, failing on keys that
+// none of its fields' yaml tags name so misspelled settings are not silently ignored
+func decodeKnownFields(node *yaml.Node, out any) error {
+	if node.Kind == yaml.MappingNode {
+		var known []string
+		t := reflect.TypeOf(out).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); name != "" && name != "-" {
+				known = append(known, name)
+			}
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i].Value; !slices.Contains(known, key) {
+				return fmt.Errorf("line %d: unknown setting %q (must be one of: %s)", node.Content[i].Line, key, strings.Join(known, ", "))
+			}
+		}
+	}
+	return node.Decode(out)
+}
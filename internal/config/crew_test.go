@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_CrewAgentBlocks(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+crew:
+  file:
+    sandbox_root: /srv/work
+  network:
+    allowed_hosts: [api.github.com, "*.example.com"]
+  research:
+    search_provider: searxng
+    search_url: http://localhost:8888
+  code:
+    allowed_repos: [/src/capn]
+`), 0o644))
+
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, FileAgentConfig{SandboxRoot: "/srv/work"}, cfg.Crew.File)
+	assert.Equal(t, []string{"api.github.com", "*.example.com"}, cfg.Crew.Network.AllowedHosts)
+	assert.Equal(t, ResearchAgentConfig{SearchProvider: SearchProviderSearXNG, SearchURL: "http://localhost:8888"}, cfg.Crew.Research)
+	assert.Equal(t, []string{"/src/capn"}, cfg.Crew.Code.AllowedRepos)
+}
+
+func TestLoadConfig_CrewAgentBlockUnknownSetting(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("crew:\n  network:\n    allowed_host: [api.github.com]\n"), 0o644))
+
+	_, err := LoadConfig(configFile)
+	assert.ErrorContains(t, err, `line 3: unknown setting "allowed_host" (must be one of: allowed_hosts)`)
+}
+
+func TestCrewConfig_ValidateAgents(t *testing.T) {
+	tests := []struct {
+		name    string
+		crew    CrewConfig
+		wantErr string
+	}{
+		{"defaults", CrewConfig{}, ""},
+		{"home sandbox", CrewConfig{File: FileAgentConfig{SandboxRoot: "~/work"}}, ""},
+		{"relative sandbox", CrewConfig{File: FileAgentConfig{SandboxRoot: "work"}}, `crew file: sandbox_root "work" must be an absolute path`},
+		{"host URL", CrewConfig{Network: NetworkAgentConfig{AllowedHosts: []string{"https://api.github.com"}}}, `crew network: allowed_hosts entry "https://api.github.com" must be a host name, not a URL`},
+		{"inner wildcard", CrewConfig{Network: NetworkAgentConfig{AllowedHosts: []string{"api.*.com"}}}, `crew network: allowed_hosts entry "api.*.com" may only use a wildcard as its first label`},
+		{"empty host", CrewConfig{Network: NetworkAgentConfig{AllowedHosts: []string{""}}}, "crew network: allowed_hosts cannot contain an empty host"},
+		{"unknown provider", CrewConfig{Research: ResearchAgentConfig{SearchProvider: "altavista", SearchURL: "http://x"}}, `crew research: unknown search_provider "altavista" (must be one of: searxng)`},
+		{"provider without URL", CrewConfig{Research: ResearchAgentConfig{SearchProvider: SearchProviderSearXNG}}, "crew research: search_url is required for the searxng search provider"},
+		{"URL without provider", CrewConfig{Research: ResearchAgentConfig{SearchURL: "http://x"}}, "crew research: search_url is set but no search_provider is"},
+		{"empty repo", CrewConfig{Code: CodeAgentConfig{AllowedRepos: []string{""}}}, "crew code: allowed_repos cannot contain an empty path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.crew.validateAgents()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
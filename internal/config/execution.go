@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	// ExecutionModeHost runs plan commands directly on the host
+	ExecutionModeHost = "host"
+	// ExecutionModeContainer runs plan commands inside a container
+	ExecutionModeContainer = "container"
+)
+
+// ExecutionModes lists the supported execution modes
+var ExecutionModes = []string{ExecutionModeHost, ExecutionModeContainer}
+
+// ContainerRuntimes lists the supported container runtimes
+var ContainerRuntimes = []string{"docker", "podman"}
+
+// ContainerNetworks lists the network policies a container can run with
+var ContainerNetworks = []string{"none", "bridge", "host"}
+
+// memoryPattern matches container memory limits such as 512m or 2g
+var memoryPattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// ExecutionConfig selects where plan commands run; tasks may override the mode
+type ExecutionConfig struct {
+	Mode      string          `yaml:"mode,omitempty"`
+	Container ContainerConfig `yaml:"container,omitempty"`
+	// GitSnapshots records the changes a task makes to the git repository it runs in, so
+	// they can be reviewed per step and rolled back
+	GitSnapshots bool `yaml:"git_snapshots"`
+	// MaxOutputSize bounds how many bytes of each step command's stdout and stderr are kept and
+	// streamed to the task log; the rest is dropped behind a truncation marker
+	MaxOutputSize int `yaml:"max_output_size,omitempty"`
+	// ReadOnly skips every step that would change files, remote services or the system:
+	// file writes, write requests and shell commands not known to only read
+	ReadOnly bool `yaml:"read_only,omitempty"`
+	// Logs tunes how streamed step output is batched into the task log
+	Logs LogIngestConfig `yaml:"logs,omitempty"`
+}
+
+// LogIngestConfig tunes the batching and sampling of the entries written to task logs while
+// steps run. Warnings and errors are always kept; debug and info entries are dropped, and
+// counted in the task's metadata, when the log cannot be saved as fast as they arrive.
+type LogIngestConfig struct {
+	// BufferSize bounds the entries waiting to be saved, defaulting to 10000
+	BufferSize int `yaml:"buffer_size,omitempty"`
+	// BatchSize saves the task once this many entries wait, defaulting to 500
+	BatchSize int `yaml:"batch_size,omitempty"`
+	// FlushInterval is the longest an entry waits to be saved, defaulting to 1s
+	FlushInterval time.Duration `yaml:"flush_interval,omitempty"`
+	// DebugSample keeps one in this many debug entries; 0 or 1 keeps them all. Tasks may
+	// override it with "capn execute --log-sample".
+	DebugSample int `yaml:"debug_sample,omitempty"`
+}
+
+// Validate validates the log ingestion settings
+func (l LogIngestConfig) Validate() error {
+	switch {
+	case l.BufferSize < 0:
+		return fmt.Errorf("buffer_size cannot be negative")
+	case l.BatchSize < 0:
+		return fmt.Errorf("batch_size cannot be negative")
+	case l.FlushInterval < 0:
+		return fmt.Errorf("flush_interval cannot be negative")
+	case l.DebugSample < 0:
+		return fmt.Errorf("debug_sample cannot be negative")
+	case l.BufferSize > 0 && l.BatchSize > l.BufferSize:
+		return fmt.Errorf("batch_size %d cannot exceed buffer_size %d", l.BatchSize, l.BufferSize)
+	}
+	return nil
+}
+
+// ContainerConfig describes the container plan commands run in. The workspace is mounted
+// into the container and the network is disabled unless a policy is set.
+type ContainerConfig struct {
+	// Runtime is docker or podman; empty uses whichever is installed
+	Runtime string `yaml:"runtime,omitempty"`
+	Image   string `yaml:"image,omitempty"`
+	// Workspace is the host directory mounted into the container; empty uses the working directory
+	Workspace string `yaml:"workspace,omitempty"`
+	MountPath string `yaml:"mount_path,omitempty"`
+	CPUs      string `yaml:"cpus,omitempty"`
+	Memory    string `yaml:"memory,omitempty"`
+	PidsLimit int    `yaml:"pids_limit,omitempty"`
+	Network   string `yaml:"network,omitempty"`
+}
+
+// ContainerMode reports whether commands run in a container by default
+func (e ExecutionConfig) ContainerMode() bool {
+	return e.Mode == ExecutionModeContainer
+}
+
+// Validate validates the execution settings
+func (e ExecutionConfig) Validate() error {
+	if e.Mode != "" && !slices.Contains(ExecutionModes, e.Mode) {
+		return fmt.Errorf("invalid mode %q (must be one of: host, container)", e.Mode)
+	}
+	if e.MaxOutputSize < 0 {
+		return fmt.Errorf("max_output_size cannot be negative")
+	}
+	if e.ContainerMode() && e.Container.Image == "" {
+		return fmt.Errorf("container.image is required in container mode")
+	}
+	if err := e.Container.Validate(); err != nil {
+		return fmt.Errorf("container: %w", err)
+	}
+	if err := e.Logs.Validate(); err != nil {
+		return fmt.Errorf("logs: %w", err)
+	}
+	return nil
+}
+
+// Validate validates the container settings
+func (c ContainerConfig) Validate() error {
+	if c.Runtime != "" && !slices.Contains(ContainerRuntimes, c.Runtime) {
+		return fmt.Errorf("invalid runtime %q (must be one of: docker, podman)", c.Runtime)
+	}
+	if c.Network != "" && !slices.Contains(ContainerNetworks, c.Network) {
+		return fmt.Errorf("invalid network %q (must be one of: none, bridge, host)", c.Network)
+	}
+	if c.CPUs != "" {
+		if cpus, err := strconv.ParseFloat(c.CPUs, 64); err != nil || cpus <= 0 {
+			return fmt.Errorf("invalid cpus %q (must be a positive number)", c.CPUs)
+		}
+	}
+	if c.Memory != "" && !memoryPattern.MatchString(c.Memory) {
+		return fmt.Errorf("invalid memory %q (must be a size such as 512m or 2g)", c.Memory)
+	}
+	if c.PidsLimit < 0 {
+		return fmt.Errorf("pids_limit cannot be negative")
+	}
+	if c.MountPath != "" && c.MountPath[0] != '/' {
+		return fmt.Errorf("mount_path must be an absolute path")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestExecutionConfig_Validate(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[ExecutionConfig]{
+		{Name: "empty", Input: ExecutionConfig{}},
+		{Name: "host", Input: ExecutionConfig{Mode: ExecutionModeHost}},
+		{
+			Name: "container with limits",
+			Input: ExecutionConfig{Mode: ExecutionModeContainer, Container: ContainerConfig{
+				Runtime: "podman", Image: "golang:1.23", CPUs: "1.5", Memory: "2g", PidsLimit: 256, Network: "bridge", MountPath: "/src",
+			}},
+		},
+		{
+			Name:      "unknown mode",
+			Input:     ExecutionConfig{Mode: "vm"},
+			WantError: true,
+			ErrorMsg:  `invalid mode "vm"`,
+		},
+		{
+			Name:      "container mode without image",
+			Input:     ExecutionConfig{Mode: ExecutionModeContainer},
+			WantError: true,
+			ErrorMsg:  "container.image is required in container mode",
+		},
+		{
+			Name:      "unknown runtime",
+			Input:     ExecutionConfig{Container: ContainerConfig{Image: "alpine", Runtime: "lxc"}},
+			WantError: true,
+			ErrorMsg:  `container: invalid runtime "lxc"`,
+		},
+		{
+			Name:      "unknown network",
+			Input:     ExecutionConfig{Container: ContainerConfig{Image: "alpine", Network: "public"}},
+			WantError: true,
+			ErrorMsg:  `invalid network "public"`,
+		},
+		{
+			Name:      "zero cpus",
+			Input:     ExecutionConfig{Container: ContainerConfig{Image: "alpine", CPUs: "0"}},
+			WantError: true,
+			ErrorMsg:  `invalid cpus "0"`,
+		},
+		{
+			Name:      "bad memory",
+			Input:     ExecutionConfig{Container: ContainerConfig{Image: "alpine", Memory: "lots"}},
+			WantError: true,
+			ErrorMsg:  `invalid memory "lots"`,
+		},
+		{
+			Name:      "negative max output size",
+			Input:     ExecutionConfig{MaxOutputSize: -1},
+			WantError: true,
+			ErrorMsg:  "max_output_size cannot be negative",
+		},
+		{
+			Name:      "negative pids limit",
+			Input:     ExecutionConfig{Container: ContainerConfig{Image: "alpine", PidsLimit: -1}},
+			WantError: true,
+			ErrorMsg:  "pids_limit cannot be negative",
+		},
+		{
+			Name:      "relative mount path",
+			Input:     ExecutionConfig{Container: ContainerConfig{Image: "alpine", MountPath: "src"}},
+			WantError: true,
+			ErrorMsg:  "mount_path must be an absolute path",
+		},
+		{
+			Name:  "log ingestion",
+			Input: ExecutionConfig{Logs: LogIngestConfig{BufferSize: 1000, BatchSize: 100, FlushInterval: 2 * time.Second, DebugSample: 10}},
+		},
+		{
+			Name:      "negative debug sample",
+			Input:     ExecutionConfig{Logs: LogIngestConfig{DebugSample: -1}},
+			WantError: true,
+			ErrorMsg:  "logs: debug_sample cannot be negative",
+		},
+		{
+			Name:      "batch larger than buffer",
+			Input:     ExecutionConfig{Logs: LogIngestConfig{BufferSize: 10, BatchSize: 50}},
+			WantError: true,
+			ErrorMsg:  "logs: batch_size 50 cannot exceed buffer_size 10",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, ExecutionConfig.Validate)
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DefaultGitHubAPIURL is the GitHub REST API used unless github.api_url points at GitHub Enterprise
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubConfig holds the GitHub API settings used to plan goals from issues. An empty token is
+// looked up in the secret store as github.token.
+type GitHubConfig struct {
+	Token  string `yaml:"token,omitempty"`
+	APIURL string `yaml:"api_url,omitempty"`
+}
+
+// BaseURL returns the GitHub API URL, defaulting to api.github.com
+func (g GitHubConfig) BaseURL() string {
+	if g.APIURL == "" {
+		return DefaultGitHubAPIURL
+	}
+	return g.APIURL
+}
+
+// Validate checks the API URL is an absolute HTTP(S) URL
+func (g GitHubConfig) Validate() error {
+	if g.APIURL == "" {
+		return nil
+	}
+	u, err := url.Parse(g.APIURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("api_url must be an http or https URL, got %q", g.APIURL)
+	}
+	return nil
+}
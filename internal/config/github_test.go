@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestGitHubConfig_Validate(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[GitHubConfig]{
+		{Name: "defaults", Input: GitHubConfig{}},
+		{Name: "enterprise", Input: GitHubConfig{Token: "ghp_x", APIURL: "https://github.example.com/api/v3"}},
+		{
+			Name:      "relative URL",
+			Input:     GitHubConfig{APIURL: "github.example.com/api/v3"},
+			WantError: true,
+			ErrorMsg:  `api_url must be an http or https URL, got "github.example.com/api/v3"`,
+		},
+		{
+			Name:      "unsupported scheme",
+			Input:     GitHubConfig{APIURL: "ftp://github.example.com"},
+			WantError: true,
+			ErrorMsg:  "api_url must be an http or https URL",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, func(g GitHubConfig) error {
+		return g.Validate()
+	})
+}
+
+func TestGitHubConfig_BaseURL(t *testing.T) {
+	assert.Equal(t, DefaultGitHubAPIURL, GitHubConfig{}.BaseURL())
+	assert.Equal(t, "https://github.example.com/api/v3", GitHubConfig{APIURL: "https://github.example.com/api/v3"}.BaseURL())
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultHomeDir returns ~/.capn
+func defaultHomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".capn"
+	}
+	return filepath.Join(home, ".capn")
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHomeDir_Unix(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CAPN_HOME", "")
+	t.Setenv("HOME", home)
+
+	assert.Equal(t, filepath.Join(home, ".capn"), HomeDir())
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultHomeDir returns %AppData%\capn, falling back to %USERPROFILE%\.capn
+func defaultHomeDir() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "capn")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".capn"
+	}
+	return filepath.Join(home, ".capn")
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHomeDir_Windows(t *testing.T) {
+	appData := t.TempDir()
+	t.Setenv("CAPN_HOME", "")
+	t.Setenv("AppData", appData)
+
+	assert.Equal(t, filepath.Join(appData, "capn"), HomeDir())
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultHookTimeout is how long a hook may run when neither it nor the hooks section sets a timeout
+const DefaultHookTimeout = 10 * time.Second
+
+// HooksConfig lists scripts run on task lifecycle events. Each script receives the event as
+// JSON on stdin; a hook that fails or times out is logged and never fails the task.
+type HooksConfig struct {
+	OnTaskStart    []HookConfig `yaml:"on_task_start,omitempty"`
+	OnStepComplete []HookConfig `yaml:"on_step_complete,omitempty"`
+	OnTaskFailed   []HookConfig `yaml:"on_task_failed,omitempty"`
+	// Timeout applies to hooks that do not set their own
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// HookConfig is one script run on an event
+type HookConfig struct {
+	// Command is run through the platform's shell, sh on POSIX systems
+	Command string        `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// IsZero reports whether no hooks are configured
+func (h HooksConfig) IsZero() bool {
+	return len(h.OnTaskStart) == 0 && len(h.OnStepComplete) == 0 && len(h.OnTaskFailed) == 0
+}
+
+// TimeoutFor returns how long hook may run
+func (h HooksConfig) TimeoutFor(hook HookConfig) time.Duration {
+	switch {
+	case hook.Timeout > 0:
+		return hook.Timeout
+	case h.Timeout > 0:
+		return h.Timeout
+	default:
+		return DefaultHookTimeout
+	}
+}
+
+// Validate checks every hook has a command and no timeout is negative
+func (h HooksConfig) Validate() error {
+	if h.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	for _, event := range []struct {
+		name  string
+		hooks []HookConfig
+	}{
+		{"on_task_start", h.OnTaskStart},
+		{"on_step_complete", h.OnStepComplete},
+		{"on_task_failed", h.OnTaskFailed},
+	} {
+		for i, hook := range event.hooks {
+			if strings.TrimSpace(hook.Command) == "" {
+				return fmt.Errorf("%s[%d]: command cannot be empty", event.name, i)
+			}
+			if hook.Timeout < 0 {
+				return fmt.Errorf("%s[%d]: timeout cannot be negative", event.name, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestHooksConfig_Validate(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[HooksConfig]{
+		{Name: "empty", Input: HooksConfig{}},
+		{
+			Name: "hooks for each event",
+			Input: HooksConfig{
+				OnTaskStart:    []HookConfig{{Command: "notify-send capn started"}},
+				OnStepComplete: []HookConfig{{Command: "./dashboard.sh", Timeout: time.Second}},
+				OnTaskFailed:   []HookConfig{{Command: "notify-send -u critical capn failed"}},
+				Timeout:        30 * time.Second,
+			},
+		},
+		{
+			Name:      "empty command",
+			Input:     HooksConfig{OnStepComplete: []HookConfig{{Command: "ok"}, {Command: "  "}}},
+			WantError: true,
+			ErrorMsg:  "on_step_complete[1]: command cannot be empty",
+		},
+		{
+			Name:      "negative hook timeout",
+			Input:     HooksConfig{OnTaskFailed: []HookConfig{{Command: "ok", Timeout: -time.Second}}},
+			WantError: true,
+			ErrorMsg:  "on_task_failed[0]: timeout cannot be negative",
+		},
+		{
+			Name:      "negative timeout",
+			Input:     HooksConfig{Timeout: -time.Second},
+			WantError: true,
+			ErrorMsg:  "timeout cannot be negative",
+		},
+	}
+	testutil.RunValidationTests(t, testCases, func(h HooksConfig) error { return h.Validate() })
+}
+
+func TestHooksConfig_TimeoutFor(t *testing.T) {
+	own := HookConfig{Command: "ok", Timeout: time.Second}
+	inherited := HookConfig{Command: "ok"}
+
+	assert.Equal(t, DefaultHookTimeout, HooksConfig{}.TimeoutFor(inherited))
+	assert.Equal(t, time.Minute, HooksConfig{Timeout: time.Minute}.TimeoutFor(inherited))
+	assert.Equal(t, time.Second, HooksConfig{Timeout: time.Minute}.TimeoutFor(own))
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// HTTPConfig controls how a client reaches HTTP services: the proxies its requests go
+// through and the certificate authorities it trusts. Without proxies the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables apply.
+type HTTPConfig struct {
+	// HTTPProxy is the proxy for http:// requests, and for https:// ones when HTTPSProxy is empty
+	HTTPProxy  string `yaml:"http_proxy,omitempty"`
+	HTTPSProxy string `yaml:"https_proxy,omitempty"`
+	// NoProxy lists hosts reached directly; an entry covers the host and its subdomains
+	NoProxy []string `yaml:"no_proxy,omitempty"`
+	// CABundle is a PEM file of certificate authorities trusted alongside the system's
+	CABundle string `yaml:"ca_bundle,omitempty"`
+	// InsecureSkipVerify accepts any server certificate. Anyone on the network path can then
+	// read and alter the traffic, credentials included; use it only to diagnose a proxy.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// IsZero reports whether no HTTP settings are configured
+func (h HTTPConfig) IsZero() bool {
+	return h.HTTPProxy == "" && h.HTTPSProxy == "" && len(h.NoProxy) == 0 && h.CABundle == "" && !h.InsecureSkipVerify
+}
+
+// HasProxy reports whether proxies are configured rather than taken from the environment
+func (h HTTPConfig) HasProxy() bool {
+	return h.HTTPProxy != "" || h.HTTPSProxy != ""
+}
+
+// Validate checks the proxies are absolute http, https or socks5 URLs
+func (h HTTPConfig) Validate() error {
+	for _, setting := range [][2]string{{"http_proxy", h.HTTPProxy}, {"https_proxy", h.HTTPSProxy}} {
+		name, proxy := setting[0], setting[1]
+		if proxy == "" {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("%s must be a URL such as http://proxy.example.com:3128", name)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("%s: unsupported scheme %q, must be http, https or socks5", name, u.Scheme)
+		}
+	}
+	for _, host := range h.NoProxy {
+		if strings.TrimSpace(host) == "" {
+			return fmt.Errorf("no_proxy cannot contain an empty host")
+		}
+	}
+	return nil
+}
+
+// HTTPSection is the HTTP settings of one configuration section
+type HTTPSection struct {
+	Name string
+	HTTP HTTPConfig
+}
+
+// HTTPSections lists the configuration sections that have HTTP settings
+func (c *Config) HTTPSections() []HTTPSection {
+	sections := []HTTPSection{{Name: "openai", HTTP: c.OpenAI.HTTP}}
+	for _, provider := range c.LLM.Providers {
+		sections = append(sections, HTTPSection{Name: "llm provider " + provider.DisplayName(), HTTP: provider.HTTP})
+	}
+	sections = append(sections, HTTPSection{Name: "mcp", HTTP: c.MCP.HTTP}, HTTPSection{Name: "crew", HTTP: c.Crew.HTTP})
+
+	configured := sections[:0]
+	for _, section := range sections {
+		if !section.HTTP.IsZero() {
+			configured = append(configured, section)
+		}
+	}
+	return configured
+}
+
+// InsecureHTTP lists the sections whose HTTP clients skip TLS certificate verification
+func (c *Config) InsecureHTTP() []string {
+	var names []string
+	for _, section := range c.HTTPSections() {
+		if section.HTTP.InsecureSkipVerify {
+			names = append(names, section.Name)
+		}
+	}
+	return names
+}
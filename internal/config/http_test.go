@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestHTTPConfig_Validate(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[HTTPConfig]{
+		{Name: "empty", Input: HTTPConfig{}},
+		{
+			Name: "proxies and CA bundle",
+			Input: HTTPConfig{
+				HTTPProxy:  "http://proxy.corp:3128",
+				HTTPSProxy: "socks5://proxy.corp:1080",
+				NoProxy:    []string{"localhost", ".corp"},
+				CABundle:   "/etc/ssl/corp-ca.pem",
+			},
+		},
+		{
+			Name:      "proxy without scheme",
+			Input:     HTTPConfig{HTTPProxy: "proxy.corp:3128"},
+			WantError: true,
+			ErrorMsg:  "http_proxy must be a URL",
+		},
+		{
+			Name:      "unsupported proxy scheme",
+			Input:     HTTPConfig{HTTPSProxy: "ftp://proxy.corp"},
+			WantError: true,
+			ErrorMsg:  `https_proxy: unsupported scheme "ftp"`,
+		},
+		{
+			Name:      "empty no_proxy host",
+			Input:     HTTPConfig{NoProxy: []string{"localhost", " "}},
+			WantError: true,
+			ErrorMsg:  "no_proxy cannot contain an empty host",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, HTTPConfig.Validate)
+}
+
+func TestConfig_ValidateHTTP(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LLM.Providers = []LLMProviderConfig{{Name: "local", Type: ProviderOllama, Model: "llama3", HTTP: HTTPConfig{HTTPProxy: "proxy"}}}
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "llm: provider local: http: http_proxy must be a URL")
+
+	cfg = NewConfig()
+	cfg.Crew.HTTP.HTTPSProxy = "gopher://proxy.corp"
+	assert.ErrorContains(t, cfg.Validate(), "crew http: https_proxy")
+}
+
+func TestConfig_InsecureHTTP(t *testing.T) {
+	cfg := NewConfig()
+	assert.Empty(t, cfg.HTTPSections())
+	assert.Empty(t, cfg.InsecureHTTP())
+
+	cfg.OpenAI.HTTP.CABundle = "/etc/ssl/corp-ca.pem"
+	cfg.LLM.Providers = []LLMProviderConfig{
+		{Name: "claude", Type: ProviderAnthropic, Model: "claude", HTTP: HTTPConfig{InsecureSkipVerify: true}},
+		{Type: ProviderOpenAI},
+	}
+	cfg.Crew.HTTP.InsecureSkipVerify = true
+
+	var names []string
+	for _, section := range cfg.HTTPSections() {
+		names = append(names, section.Name)
+	}
+	assert.Equal(t, []string{"openai", "llm provider claude", "crew"}, names)
+	assert.Equal(t, []string{"llm provider claude", "crew"}, cfg.InsecureHTTP())
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envReference matches "${NAME}" and "${NAME:-default}", and "$$" escaping a literal "$"
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// MissingEnvVar is an environment variable a config file references without a default
+type MissingEnvVar struct {
+	Name string
+	Line int
+}
+
+// InterpolationError lists the environment variables a config file needs that are not set
+type InterpolationError struct {
+	Missing []MissingEnvVar
+}
+
+func (e *InterpolationError) Error() string {
+	refs := make([]string, len(e.Missing))
+	for i, missing := range e.Missing {
+		refs[i] = fmt.Sprintf("%s (line %d)", missing.Name, missing.Line)
+	}
+	return fmt.Sprintf("environment variable(s) not set: %s; set them or give a default with ${NAME:-default}",
+		strings.Join(refs, ", "))
+}
+
+// Interpolate replaces "${NAME}" in the document's values with the environment variable's
+// value and "${NAME:-default}" with the default when the variable is unset or empty. "$$"
+// stands for a literal "$". Keys and comments are left alone, and substituted values are
+// never parsed as YAML, so a variable cannot change the document's structure. Unquoted values
+// are typed by their result, so "parallel: ${CAPN_PARALLEL:-4}" is a number. References to
+// unset variables without a default are reported together in an *InterpolationError.
+func Interpolate(doc *yaml.Node, lookup func(string) (string, bool)) error {
+	var missing []MissingEnvVar
+	var walk func(node *yaml.Node, isKey bool)
+	walk = func(node *yaml.Node, isKey bool) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, false)
+			}
+		case yaml.MappingNode:
+			for i, child := range node.Content {
+				walk(child, i%2 == 0)
+			}
+		case yaml.ScalarNode:
+			if isKey || !strings.Contains(node.Value, "$") {
+				return
+			}
+			node.Value = envReference.ReplaceAllStringFunc(node.Value, func(ref string) string {
+				if ref == "$$" {
+					return "$"
+				}
+				match := envReference.FindStringSubmatch(ref)
+				name, fallback := match[1], match[2]
+				value, ok := lookup(name)
+				switch {
+				case fallback != "" && value == "":
+					return strings.TrimPrefix(fallback, ":-")
+				case !ok:
+					missing = append(missing, MissingEnvVar{Name: name, Line: node.Line})
+				}
+				return value
+			})
+			// Let unquoted values resolve to the type of what they now hold
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	walk(doc, false)
+
+	if len(missing) > 0 {
+		return &InterpolationError{Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOST": "proxy.corp", "EMPTY": "", "TRICKY": "a: [b"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	interpolate := func(source string) (map[string]any, error) {
+		var doc yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(source), &doc))
+		if err := Interpolate(&doc, lookup); err != nil {
+			return nil, err
+		}
+		out := map[string]any{}
+		require.NoError(t, doc.Decode(&out))
+		return out, nil
+	}
+
+	out, err := interpolate(`
+proxy: http://${HOST}:3128  # ${NOT_A_VALUE}
+port: ${PORT:-8080}
+quoted: "${PORT:-8080}"
+empty: ${EMPTY:-fallback}
+blank: ${EMPTY}
+literal: $${HOST}
+tricky: ${TRICKY}
+list:
+  - ${HOST}
+  - ${UNSET:-none}
+${HOST}: key
+`)
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.corp:3128", out["proxy"])
+	assert.Equal(t, 8080, out["port"], "unquoted values take the type of their result")
+	assert.Equal(t, "8080", out["quoted"])
+	assert.Equal(t, "fallback", out["empty"])
+	assert.Nil(t, out["blank"], "an empty unquoted value reads as if left blank")
+	assert.Equal(t, "${HOST}", out["literal"])
+	assert.Equal(t, "a: [b", out["tricky"], "values are not parsed as YAML")
+	assert.Equal(t, []any{"proxy.corp", "none"}, out["list"])
+	assert.Equal(t, "key", out["${HOST}"], "keys are not interpolated")
+
+	_, err = interpolate("openai:\n  api_key: ${OPENAI_KEY}\n  base_url: ${OPENAI_URL}\n")
+	var interpolationErr *InterpolationError
+	require.ErrorAs(t, err, &interpolationErr)
+	assert.Equal(t, []MissingEnvVar{{Name: "OPENAI_KEY", Line: 2}, {Name: "OPENAI_URL", Line: 3}}, interpolationErr.Missing)
+	assert.EqualError(t, err, "environment variable(s) not set: OPENAI_KEY (line 2), OPENAI_URL (line 3); set them or give a default with ${NAME:-default}")
+}
+
+func TestLoadConfig_Interpolation(t *testing.T) {
+	t.Setenv("CAPN_TEST_TIMEOUT", "90s")
+	t.Setenv("CAPN_TEST_STORAGE", "/var/lib/capn")
+	path := writeConfig(t, "global:\n  timeout: ${CAPN_TEST_TIMEOUT}\n  parallel: ${CAPN_TEST_PARALLEL:-3}\nstorage:\n  path: ${CAPN_TEST_STORAGE}/tasks\n")
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, cfg.Global.Timeout)
+	assert.Equal(t, 3, cfg.Global.Parallel)
+	assert.Equal(t, "/var/lib/capn/tasks", cfg.Storage.Path)
+
+	missing := writeConfig(t, "openai:\n  api_key: ${CAPN_TEST_UNSET_KEY}\n")
+	_, err = LoadConfig(missing)
+	assert.ErrorContains(t, err, "failed to interpolate config file")
+	assert.ErrorContains(t, err, "CAPN_TEST_UNSET_KEY (line 2)")
+
+	cfg, err = LoadConfigWith(missing, LoadOptions{NoInterpolate: true})
+	require.NoError(t, err)
+	assert.Equal(t, "${CAPN_TEST_UNSET_KEY}", cfg.OpenAI.APIKey)
+
+	cfg, err = LoadConfig(writeConfig(t, ""))
+	require.NoError(t, err)
+	assert.Equal(t, NewConfig().Global.Parallel, cfg.Global.Parallel, "an empty file keeps the defaults")
+}
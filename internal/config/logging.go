@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// LogLevels lists the levels capn's own logs can be limited to
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// LogFormats lists the encodings capn's own logs can be written in
+var LogFormats = []string{"console", "json"}
+
+// ValidateLogging checks the log level, format and file of the global config
+func (g GlobalConfig) ValidateLogging() error {
+	if g.LogLevel != "" && !slices.Contains(LogLevels, g.LogLevel) {
+		return fmt.Errorf("invalid log level %q (must be one of: %s)", g.LogLevel, strings.Join(LogLevels, ", "))
+	}
+	if g.LogFormat != "" && !slices.Contains(LogFormats, g.LogFormat) {
+		return fmt.Errorf("invalid log format %q (must be one of: %s)", g.LogFormat, strings.Join(LogFormats, ", "))
+	}
+	switch g.LogFile {
+	case "stdout", "-", "/dev/stdout":
+		// Standard output carries command output, which logs must not interleave with
+		return fmt.Errorf("log file cannot be standard output; leave it empty to log to standard error")
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestGlobalConfig_ValidateLogging(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[GlobalConfig]{
+		{Name: "defaults", Input: GlobalConfig{}},
+		{Name: "all set", Input: GlobalConfig{LogLevel: "warn", LogFormat: "json", LogFile: "/var/log/capn.log"}},
+		{
+			Name:      "unknown level",
+			Input:     GlobalConfig{LogLevel: "verbose"},
+			WantError: true,
+			ErrorMsg:  `invalid log level "verbose" (must be one of: debug, info, warn, error)`,
+		},
+		{
+			Name:      "unknown format",
+			Input:     GlobalConfig{LogFormat: "logfmt"},
+			WantError: true,
+			ErrorMsg:  `invalid log format "logfmt"`,
+		},
+		{
+			Name:      "standard output",
+			Input:     GlobalConfig{LogFile: "stdout"},
+			WantError: true,
+			ErrorMsg:  "log file cannot be standard output",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, func(g GlobalConfig) error {
+		return g.ValidateLogging()
+	})
+}
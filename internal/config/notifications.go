@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// NotificationChannelWebhook posts notifications as JSON to a URL
+	NotificationChannelWebhook = "webhook"
+	// NotificationChannelEmail sends notifications as HTML reports over SMTP
+	NotificationChannelEmail = "email"
+)
+
+// NotificationChannelTypes lists the supported notification channel types
+var NotificationChannelTypes = []string{NotificationChannelWebhook, NotificationChannelEmail}
+
+const (
+	// DefaultSMTPPort is the submission port email channels connect to
+	DefaultSMTPPort = 587
+	// DefaultMaxAttachmentSize is the largest artifact an email report attaches
+	DefaultMaxAttachmentSize = 1 << 20
+	// DefaultMaxAttachmentsSize caps the total size of the artifacts attached to one report
+	DefaultMaxAttachmentsSize = 5 << 20
+)
+
+// NotificationOutcomes lists the task outcomes a channel can be notified of
+var NotificationOutcomes = []string{"completed", "failed", "cancelled"}
+
+// DefaultNotificationOutcomes are notified when a channel does not choose its own
+var DefaultNotificationOutcomes = []string{"completed", "failed"}
+
+// channelNamePattern matches channel names, which also name the channel's digest spool file
+var channelNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// NotificationsConfig lists the channels told about finished tasks
+type NotificationsConfig struct {
+	Channels []NotificationChannelConfig `yaml:"channels,omitempty"`
+}
+
+// NotificationChannelConfig configures one notification channel
+type NotificationChannelConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	URL  string `yaml:"url,omitempty"`
+	// On lists the task outcomes to notify; empty means completed and failed
+	On     []string     `yaml:"on,omitempty"`
+	Digest DigestConfig `yaml:"digest,omitempty"`
+	Email  EmailConfig  `yaml:"email,omitempty"`
+}
+
+// SecretKey returns the secret store key holding an email channel's SMTP password
+func (c NotificationChannelConfig) SecretKey() string {
+	return "notifications." + c.Name + ".password"
+}
+
+// DigestConfig batches a channel's notifications into periodic summaries
+type DigestConfig struct {
+	// Window is how long outcomes are collected before one digest is sent; zero sends
+	// a notification for each task
+	Window time.Duration `yaml:"window,omitempty"`
+	// Summarize adds an LLM-written one-paragraph summary to each digest
+	Summarize bool `yaml:"summarize,omitempty"`
+}
+
+// EmailConfig configures how an email channel sends its reports. An empty password is looked
+// up in the secret store as notifications.<channel>.password.
+type EmailConfig struct {
+	Host     string   `yaml:"host,omitempty"`
+	Port     int      `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+	// Subject is a text/template for the subject line, executed with the same data as the report
+	Subject string `yaml:"subject,omitempty"`
+	// Template is the path of an html/template replacing the built-in report
+	Template string `yaml:"template,omitempty"`
+	// MaxAttachmentSize is the largest artifact attached, in bytes; larger ones are only listed
+	MaxAttachmentSize int64 `yaml:"max_attachment_size,omitempty"`
+	// MaxAttachmentsSize caps the bytes attached to one report; artifacts past it are only listed
+	MaxAttachmentsSize int64 `yaml:"max_attachments_size,omitempty"`
+}
+
+// Addr returns the SMTP server's host:port
+func (e EmailConfig) Addr() string {
+	port := e.Port
+	if port == 0 {
+		port = DefaultSMTPPort
+	}
+	return net.JoinHostPort(e.Host, strconv.Itoa(port))
+}
+
+// AttachmentLimits returns the largest attachment and the total attached to one report, in bytes
+func (e EmailConfig) AttachmentLimits() (each, total int64) {
+	each, total = e.MaxAttachmentSize, e.MaxAttachmentsSize
+	if each == 0 {
+		each = DefaultMaxAttachmentSize
+	}
+	if total == 0 {
+		total = DefaultMaxAttachmentsSize
+	}
+	return each, total
+}
+
+// Validate validates an email channel's settings
+func (e EmailConfig) Validate() error {
+	if e.Host == "" {
+		return fmt.Errorf("email host is required")
+	}
+	if e.Port < 0 || e.Port > 65535 {
+		return fmt.Errorf("invalid email port %d", e.Port)
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("invalid email from address %q", e.From)
+	}
+	if len(e.To) == 0 {
+		return fmt.Errorf("email to requires at least one address")
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid email to address %q", to)
+		}
+	}
+	if e.MaxAttachmentSize < 0 || e.MaxAttachmentsSize < 0 {
+		return fmt.Errorf("email attachment limits cannot be negative")
+	}
+	return nil
+}
+
+// Notifies reports whether the channel is notified of tasks finishing with the status
+func (c NotificationChannelConfig) Notifies(status string) bool {
+	if len(c.On) == 0 {
+		return slices.Contains(DefaultNotificationOutcomes, status)
+	}
+	return slices.Contains(c.On, status)
+}
+
+// Validate validates the notification channels
+func (n NotificationsConfig) Validate() error {
+	seen := make(map[string]bool, len(n.Channels))
+	for _, channel := range n.Channels {
+		if err := channel.Validate(); err != nil {
+			if channel.Name == "" {
+				return err
+			}
+			return fmt.Errorf("channel %s: %w", channel.Name, err)
+		}
+		if seen[channel.Name] {
+			return fmt.Errorf("duplicate channel %q", channel.Name)
+		}
+		seen[channel.Name] = true
+	}
+	return nil
+}
+
+// Validate validates a notification channel
+func (c NotificationChannelConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("channel name cannot be empty")
+	}
+	if !channelNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid name %q (use letters, digits, '-' and '_')", c.Name)
+	}
+	if !slices.Contains(NotificationChannelTypes, c.Type) {
+		return fmt.Errorf("invalid type %q (must be one of: %s)", c.Type, strings.Join(NotificationChannelTypes, ", "))
+	}
+	if c.Type == NotificationChannelWebhook {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook url must be an http or https URL")
+		}
+	}
+	if c.Type == NotificationChannelEmail {
+		if err := c.Email.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, outcome := range c.On {
+		if !slices.Contains(NotificationOutcomes, outcome) {
+			return fmt.Errorf("invalid outcome %q (must be one of: completed, failed, cancelled)", outcome)
+		}
+	}
+	if c.Digest.Window < 0 {
+		return fmt.Errorf("digest window cannot be negative")
+	}
+	if c.Digest.Summarize && c.Digest.Window == 0 {
+		return fmt.Errorf("digest summarize requires a digest window")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestNotificationsConfig_Validate(t *testing.T) {
+	webhook := func(name string) NotificationChannelConfig {
+		return NotificationChannelConfig{Name: name, Type: NotificationChannelWebhook, URL: "https://hooks.example.com/capn"}
+	}
+	withDigest := func(c NotificationChannelConfig, digest DigestConfig) NotificationChannelConfig {
+		c.Digest = digest
+		return c
+	}
+	withOn := func(c NotificationChannelConfig, on ...string) NotificationChannelConfig {
+		c.On = on
+		return c
+	}
+	email := func(settings func(*EmailConfig)) NotificationChannelConfig {
+		c := NotificationChannelConfig{Name: "reports", Type: NotificationChannelEmail, Email: EmailConfig{
+			Host: "smtp.example.com",
+			From: "capn <capn@example.com>",
+			To:   []string{"ops@example.com"},
+		}}
+		settings(&c.Email)
+		return c
+	}
+
+	testCases := []testutil.ValidationTestCase[NotificationsConfig]{
+		{Name: "empty", Input: NotificationsConfig{}},
+		{
+			Name: "immediate and digest channels",
+			Input: NotificationsConfig{Channels: []NotificationChannelConfig{
+				withOn(webhook("ops"), "failed"),
+				withDigest(webhook("team-digest"), DigestConfig{Window: 15 * time.Minute, Summarize: true}),
+			}},
+		},
+		{
+			Name:      "missing name",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{webhook("")}},
+			WantError: true,
+			ErrorMsg:  "channel name cannot be empty",
+		},
+		{
+			Name:      "name with path separator",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{webhook("../ops")}},
+			WantError: true,
+			ErrorMsg:  `invalid name "../ops"`,
+		},
+		{
+			Name:      "unknown type",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{{Name: "ops", Type: "pager"}}},
+			WantError: true,
+			ErrorMsg:  `channel ops: invalid type "pager"`,
+		},
+		{
+			Name:      "webhook without url",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{{Name: "ops", Type: NotificationChannelWebhook}}},
+			WantError: true,
+			ErrorMsg:  "webhook url must be an http or https URL",
+		},
+		{
+			Name:  "email channel",
+			Input: NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.Port = 465 })}},
+		},
+		{
+			Name:      "email without host",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.Host = "" })}},
+			WantError: true,
+			ErrorMsg:  "channel reports: email host is required",
+		},
+		{
+			Name:      "email with invalid port",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.Port = 70000 })}},
+			WantError: true,
+			ErrorMsg:  "invalid email port 70000",
+		},
+		{
+			Name:      "email with invalid sender",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.From = "capn" })}},
+			WantError: true,
+			ErrorMsg:  `invalid email from address "capn"`,
+		},
+		{
+			Name:      "email without recipients",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.To = nil })}},
+			WantError: true,
+			ErrorMsg:  "email to requires at least one address",
+		},
+		{
+			Name:      "email with invalid recipient",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.To = append(e.To, "ops") })}},
+			WantError: true,
+			ErrorMsg:  `invalid email to address "ops"`,
+		},
+		{
+			Name:      "email with negative attachment limit",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{email(func(e *EmailConfig) { e.MaxAttachmentSize = -1 })}},
+			WantError: true,
+			ErrorMsg:  "email attachment limits cannot be negative",
+		},
+		{
+			Name:      "unknown outcome",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{withOn(webhook("ops"), "running")}},
+			WantError: true,
+			ErrorMsg:  `invalid outcome "running"`,
+		},
+		{
+			Name:      "negative window",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{withDigest(webhook("ops"), DigestConfig{Window: -time.Minute})}},
+			WantError: true,
+			ErrorMsg:  "digest window cannot be negative",
+		},
+		{
+			Name:      "summarize without window",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{withDigest(webhook("ops"), DigestConfig{Summarize: true})}},
+			WantError: true,
+			ErrorMsg:  "digest summarize requires a digest window",
+		},
+		{
+			Name:      "duplicate name",
+			Input:     NotificationsConfig{Channels: []NotificationChannelConfig{webhook("ops"), webhook("ops")}},
+			WantError: true,
+			ErrorMsg:  `duplicate channel "ops"`,
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, func(n NotificationsConfig) error {
+		return n.Validate()
+	})
+}
+
+func TestEmailConfig_Defaults(t *testing.T) {
+	e := EmailConfig{Host: "smtp.example.com"}
+	assert.Equal(t, "smtp.example.com:587", e.Addr())
+	each, total := e.AttachmentLimits()
+	assert.Equal(t, int64(DefaultMaxAttachmentSize), each)
+	assert.Equal(t, int64(DefaultMaxAttachmentsSize), total)
+
+	e = EmailConfig{Host: "smtp.example.com", Port: 25, MaxAttachmentSize: 10, MaxAttachmentsSize: 20}
+	assert.Equal(t, "smtp.example.com:25", e.Addr())
+	each, total = e.AttachmentLimits()
+	assert.Equal(t, int64(10), each)
+	assert.Equal(t, int64(20), total)
+}
+
+func TestNotificationChannelConfig_Notifies(t *testing.T) {
+	defaults := NotificationChannelConfig{}
+	assert.True(t, defaults.Notifies("completed"))
+	assert.True(t, defaults.Notifies("failed"))
+	assert.False(t, defaults.Notifies("cancelled"))
+
+	failures := NotificationChannelConfig{On: []string{"failed", "cancelled"}}
+	assert.False(t, failures.Notifies("completed"))
+	assert.True(t, failures.Notifies("cancelled"))
+}
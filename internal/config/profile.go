@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ProfileConfig holds a named set of overrides for the captain, crew, execution and LLM settings.
+// Sections are kept as raw YAML so only the keys a profile sets replace the base configuration.
+type ProfileConfig struct {
+	Description string    `yaml:"description,omitempty"`
+	Captain     yaml.Node `yaml:"captain,omitempty"`
+	Crew        yaml.Node `yaml:"crew,omitempty"`
+	Execution   yaml.Node `yaml:"execution,omitempty"`
+	OpenAI      yaml.Node `yaml:"openai,omitempty"`
+}
+
+// ProfileNames returns the names of the configured profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile overlays the named profile onto the configuration and revalidates it
+func (c *Config) ApplyProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		available := "none configured"
+		if names := c.ProfileNames(); len(names) > 0 {
+			available = strings.Join(names, ", ")
+		}
+		return fmt.Errorf("unknown profile %q (available: %s)", name, available)
+	}
+
+	sections := []struct {
+		name   string
+		node   *yaml.Node
+		target interface{}
+	}{
+		{"captain", &profile.Captain, &c.Captain},
+		{"crew", &profile.Crew, &c.Crew},
+		{"execution", &profile.Execution, &c.Execution},
+		{"openai", &profile.OpenAI, &c.OpenAI},
+	}
+	for _, section := range sections {
+		if section.node.IsZero() {
+			continue
+		}
+		if err := section.node.Decode(section.target); err != nil {
+			return fmt.Errorf("failed to apply %s settings of profile %s: %w", section.name, name, err)
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration with profile %s: %w", name, err)
+	}
+	c.Global.Profile = name
+	return nil
+}
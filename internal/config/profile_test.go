@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profileConfig = `
+openai:
+  api_key: test-key
+  model: gpt-4
+  temperature: 0.5
+crew:
+  timeouts:
+    file: 30s
+    network: 1m
+profiles:
+  fast:
+    description: Quick and cheap
+    captain:
+      max_concurrent_agents: 3
+    openai:
+      model: gpt-4o-mini
+      temperature: 0
+    crew:
+      timeouts:
+        network: 10s
+  thorough:
+    captain:
+      max_concurrent_agents: 10
+      planning_timeout: 2m
+  broken:
+    captain:
+      max_concurrent_agents: -1
+`
+
+func loadProfileConfig(t *testing.T) *Config {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(profileConfig), 0644))
+
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestConfig_ProfileNames(t *testing.T) {
+	cfg := loadProfileConfig(t)
+	assert.Equal(t, []string{"broken", "fast", "thorough"}, cfg.ProfileNames())
+	assert.Empty(t, NewConfig().ProfileNames())
+}
+
+func TestConfig_ApplyProfile(t *testing.T) {
+	t.Run("overrides only the keys set", func(t *testing.T) {
+		cfg := loadProfileConfig(t)
+		require.NoError(t, cfg.ApplyProfile("fast"))
+
+		assert.Equal(t, "fast", cfg.Global.Profile)
+		assert.Equal(t, 3, cfg.Captain.MaxConcurrentAgents)
+		assert.Equal(t, 30*time.Second, cfg.Captain.PlanningTimeout)
+		assert.Equal(t, "gpt-4o-mini", cfg.OpenAI.Model)
+		assert.Equal(t, 0.0, cfg.OpenAI.Temperature)
+		assert.Equal(t, "test-key", cfg.OpenAI.APIKey)
+		assert.Equal(t, 30*time.Second, cfg.Crew.Timeouts["file"])
+		assert.Equal(t, 10*time.Second, cfg.Crew.Timeouts["network"])
+	})
+
+	t.Run("captain section only", func(t *testing.T) {
+		cfg := loadProfileConfig(t)
+		require.NoError(t, cfg.ApplyProfile("thorough"))
+
+		assert.Equal(t, 10, cfg.Captain.MaxConcurrentAgents)
+		assert.Equal(t, 2*time.Minute, cfg.Captain.PlanningTimeout)
+		assert.Equal(t, "gpt-4", cfg.OpenAI.Model)
+	})
+
+	t.Run("invalid result", func(t *testing.T) {
+		cfg := loadProfileConfig(t)
+		err := cfg.ApplyProfile("broken")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "profile broken")
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		cfg := loadProfileConfig(t)
+		err := cfg.ApplyProfile("turbo")
+		assert.EqualError(t, err, `unknown profile "turbo" (available: broken, fast, thorough)`)
+
+		err = NewConfig().ApplyProfile("turbo")
+		assert.EqualError(t, err, `unknown profile "turbo" (available: none configured)`)
+	})
+}
+
+func TestConfig_ApplyProfileExecution(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+openai:
+  api_key: test-key
+execution:
+  container:
+    image: golang:1.23
+    memory: 1g
+profiles:
+  sandboxed:
+    execution:
+      mode: container
+      container:
+        network: bridge
+`), 0644))
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "", cfg.Execution.Mode)
+
+	require.NoError(t, cfg.ApplyProfile("sandboxed"))
+	assert.Equal(t, ExecutionModeContainer, cfg.Execution.Mode)
+	assert.Equal(t, ContainerConfig{Image: "golang:1.23", Memory: "1g", Network: "bridge"}, cfg.Execution.Container)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/secrets"
+)
+
+// SecretRefPrefix marks a configuration value as a reference to a stored secret,
+// e.g. api_key: secret:openai.api_key
+const SecretRefPrefix = "secret:"
+
+// SecretsConfig holds secret provider configuration
+type SecretsConfig struct {
+	Providers []string `yaml:"providers,omitempty"`
+}
+
+// SecretResolver looks up secret values by key, reporting which provider held the value
+type SecretResolver interface {
+	Resolve(key string) (value, provider string, err error)
+}
+
+// secretFields maps secret keys to the configuration fields they fill
+func (c *Config) secretFields() map[string]*string {
+	fields := map[string]*string{
+		"openai.api_key": &c.OpenAI.APIKey,
+		"github.token":   &c.GitHub.Token,
+
+		"integrations.slack.bot_token":      &c.Integrations.Slack.BotToken,
+		"integrations.slack.signing_secret": &c.Integrations.Slack.SigningSecret,
+	}
+	for i := range c.Transport.Workers {
+		worker := &c.Transport.Workers[i]
+		fields[worker.SecretKey()] = &worker.Key
+	}
+	for i := range c.Notifications.Channels {
+		if channel := &c.Notifications.Channels[i]; channel.Type == NotificationChannelEmail {
+			fields[channel.SecretKey()] = &channel.Email.Password
+		}
+	}
+	return fields
+}
+
+// SecretKeys returns the secret keys capn resolves into its configuration
+func SecretKeys() []string {
+	keys := make([]string, 0)
+	for key := range NewConfig().secretFields() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SecretProviders returns the provider lookup order: CAPN_SECRET_PROVIDERS, then the config, then the default
+func (c *Config) SecretProviders() []string {
+	if env := os.Getenv("CAPN_SECRET_PROVIDERS"); env != "" {
+		return strings.Split(env, ",")
+	}
+	if len(c.Secrets.Providers) > 0 {
+		return c.Secrets.Providers
+	}
+	return secrets.DefaultProviders
+}
+
+// ResolveSecrets fills secret-backed fields. A "secret:<key>" reference must resolve;
+// an empty field is filled from its own key when a value is stored, and plaintext values are kept.
+func (c *Config) ResolveSecrets(resolver SecretResolver) error {
+	fields := c.secretFields()
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	// Resolve in a fixed order so the same misconfiguration always reports the same key
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := fields[key]
+		ref := key
+		required := false
+		switch {
+		case strings.HasPrefix(*field, SecretRefPrefix):
+			ref = strings.TrimPrefix(*field, SecretRefPrefix)
+			required = true
+		case *field != "":
+			continue
+		}
+
+		value, _, err := resolver.Resolve(ref)
+		if errors.Is(err, secrets.ErrNotFound) && !required {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		*field = value
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/secrets"
+)
+
+// staticResolver resolves secrets from a fixed map
+type staticResolver map[string]string
+
+func (r staticResolver) Resolve(key string) (string, string, error) {
+	if value, ok := r[key]; ok {
+		return value, "static", nil
+	}
+	return "", "", fmt.Errorf("%w: %s", secrets.ErrNotFound, key)
+}
+
+func TestConfig_ResolveSecrets(t *testing.T) {
+	resolver := staticResolver{"openai.api_key": "sk-stored", "team.openai": "sk-team"}
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		want    string
+		wantErr string
+	}{
+		{name: "empty field is filled", apiKey: "", want: "sk-stored"},
+		{name: "plaintext is kept", apiKey: "sk-plain", want: "sk-plain"},
+		{name: "reference resolves", apiKey: "secret:team.openai", want: "sk-team"},
+		{name: "missing reference fails", apiKey: "secret:missing", wantErr: "failed to resolve openai.api_key: secret not found: missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.OpenAI.APIKey = tt.apiKey
+			err := cfg.ResolveSecrets(resolver)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.OpenAI.APIKey)
+		})
+	}
+
+	t.Run("optional secret may be absent", func(t *testing.T) {
+		cfg := NewConfig()
+		require.NoError(t, cfg.ResolveSecrets(staticResolver{}))
+		assert.Empty(t, cfg.OpenAI.APIKey)
+	})
+
+	t.Run("provider errors are reported", func(t *testing.T) {
+		cfg := NewConfig()
+		err := cfg.ResolveSecrets(resolverFunc(func(string) (string, string, error) {
+			return "", "", errors.New("keychain locked")
+		}))
+		assert.EqualError(t, err, "failed to resolve github.token: keychain locked")
+	})
+
+	t.Run("email channel passwords", func(t *testing.T) {
+		cfg := NewConfig()
+		cfg.Notifications.Channels = []NotificationChannelConfig{
+			{Name: "reports", Type: NotificationChannelEmail},
+			{Name: "ops", Type: NotificationChannelWebhook},
+		}
+		require.NoError(t, cfg.ResolveSecrets(staticResolver{"notifications.reports.password": "smtp-pass"}))
+		assert.Equal(t, "smtp-pass", cfg.Notifications.Channels[0].Email.Password)
+		assert.Empty(t, cfg.Notifications.Channels[1].Email.Password)
+	})
+}
+
+// resolverFunc adapts a function to SecretResolver
+type resolverFunc func(key string) (string, string, error)
+
+func (f resolverFunc) Resolve(key string) (string, string, error) { return f(key) }
+
+func TestConfig_SecretProviders(t *testing.T) {
+	t.Setenv("CAPN_SECRET_PROVIDERS", "")
+	cfg := NewConfig()
+	assert.Equal(t, secrets.DefaultProviders, cfg.SecretProviders())
+
+	cfg.Secrets.Providers = []string{"file"}
+	assert.Equal(t, []string{"file"}, cfg.SecretProviders())
+
+	t.Setenv("CAPN_SECRET_PROVIDERS", "env,file")
+	assert.Equal(t, []string{"env", "file"}, cfg.SecretProviders())
+}
+
+func TestSecretKeys(t *testing.T) {
+	assert.Equal(t, []string{"github.token", "integrations.slack.bot_token", "integrations.slack.signing_secret", "openai.api_key"}, SecretKeys())
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultSlackAPIURL is the Slack Web API the integration posts messages to
+	DefaultSlackAPIURL = "https://slack.com/api"
+	// DefaultSlackListen is where the integration receives slash commands and button clicks
+	DefaultSlackListen = "127.0.0.1:7778"
+	// DefaultSlackApprovalTimeout is how long a plan waits for approval before its task is cancelled
+	DefaultSlackApprovalTimeout = time.Hour
+)
+
+// IntegrationsConfig holds the chat integrations run by the daemon
+type IntegrationsConfig struct {
+	Slack SlackConfig `yaml:"slack,omitempty"`
+}
+
+// SlackConfig configures the Slack integration. An empty bot token or signing secret is looked
+// up in the secret store as integrations.slack.bot_token or integrations.slack.signing_secret.
+type SlackConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	BotToken      string `yaml:"bot_token,omitempty"`
+	SigningSecret string `yaml:"signing_secret,omitempty"`
+	// Channel is where task start and completion messages are posted
+	Channel string `yaml:"channel,omitempty"`
+	// Listen is the address Slack sends slash commands and interactions to
+	Listen string `yaml:"listen,omitempty"`
+	APIURL string `yaml:"api_url,omitempty"`
+	// AutoApprove runs submitted goals, high-risk steps included, without waiting for their plan
+	// to be approved
+	AutoApprove     bool          `yaml:"auto_approve,omitempty"`
+	ApprovalTimeout time.Duration `yaml:"approval_timeout,omitempty"`
+}
+
+// ListenAddr returns the address to receive Slack requests on
+func (s SlackConfig) ListenAddr() string {
+	if s.Listen == "" {
+		return DefaultSlackListen
+	}
+	return s.Listen
+}
+
+// BaseURL returns the Slack Web API URL, defaulting to slack.com
+func (s SlackConfig) BaseURL() string {
+	if s.APIURL == "" {
+		return DefaultSlackAPIURL
+	}
+	return s.APIURL
+}
+
+// PlanApprovalTimeout returns how long a plan waits for approval
+func (s SlackConfig) PlanApprovalTimeout() time.Duration {
+	if s.ApprovalTimeout == 0 {
+		return DefaultSlackApprovalTimeout
+	}
+	return s.ApprovalTimeout
+}
+
+// Validate validates the Slack settings of an enabled integration. Credentials are checked when
+// the daemon starts, since they may come from the secret store.
+func (s SlackConfig) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.Channel == "" {
+		return fmt.Errorf("channel is required")
+	}
+	if s.Listen != "" {
+		if _, _, err := net.SplitHostPort(s.Listen); err != nil {
+			return fmt.Errorf("listen must be host:port, got %q", s.Listen)
+		}
+	}
+	if s.APIURL != "" {
+		u, err := url.Parse(s.APIURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("api_url must be an http or https URL, got %q", s.APIURL)
+		}
+	}
+	if s.ApprovalTimeout < 0 {
+		return fmt.Errorf("approval_timeout cannot be negative")
+	}
+	return nil
+}
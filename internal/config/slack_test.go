@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestSlackConfig_Validate(t *testing.T) {
+	testCases := []testutil.ValidationTestCase[SlackConfig]{
+		{Name: "disabled", Input: SlackConfig{Listen: "nonsense"}},
+		{Name: "enabled", Input: SlackConfig{Enabled: true, Channel: "#ops", Listen: ":7778", ApprovalTimeout: time.Minute}},
+		{
+			Name:      "missing channel",
+			Input:     SlackConfig{Enabled: true},
+			WantError: true,
+			ErrorMsg:  "channel is required",
+		},
+		{
+			Name:      "bad listen address",
+			Input:     SlackConfig{Enabled: true, Channel: "#ops", Listen: "7778"},
+			WantError: true,
+			ErrorMsg:  `listen must be host:port, got "7778"`,
+		},
+		{
+			Name:      "bad API URL",
+			Input:     SlackConfig{Enabled: true, Channel: "#ops", APIURL: "slack.example.com"},
+			WantError: true,
+			ErrorMsg:  `api_url must be an http or https URL, got "slack.example.com"`,
+		},
+		{
+			Name:      "negative approval timeout",
+			Input:     SlackConfig{Enabled: true, Channel: "#ops", ApprovalTimeout: -time.Second},
+			WantError: true,
+			ErrorMsg:  "approval_timeout cannot be negative",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, func(s SlackConfig) error {
+		return s.Validate()
+	})
+}
+
+func TestSlackConfig_Defaults(t *testing.T) {
+	assert.Equal(t, DefaultSlackListen, SlackConfig{}.ListenAddr())
+	assert.Equal(t, DefaultSlackAPIURL, SlackConfig{}.BaseURL())
+	assert.Equal(t, DefaultSlackApprovalTimeout, SlackConfig{}.PlanApprovalTimeout())
+
+	cfg := SlackConfig{Listen: ":9000", APIURL: "http://localhost:9001/api", ApprovalTimeout: time.Minute}
+	assert.Equal(t, ":9000", cfg.ListenAddr())
+	assert.Equal(t, "http://localhost:9001/api", cfg.BaseURL())
+	assert.Equal(t, time.Minute, cfg.PlanApprovalTimeout())
+}
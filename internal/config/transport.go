@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransportKeySize is the length in bytes of a worker key; generate one with
+// `openssl rand -base64 32`
+const TransportKeySize = 32
+
+// TransportConfig secures messages exchanged between the captain and remote crew workers
+type TransportConfig struct {
+	TLS     TLSConfig         `yaml:"tls,omitempty"`
+	Encrypt bool              `yaml:"encrypt,omitempty"`
+	MaxSkew time.Duration     `yaml:"max_skew,omitempty"`
+	Workers []WorkerKeyConfig `yaml:"workers,omitempty"`
+}
+
+// TLSConfig holds the certificates for mutual TLS. The CA verifies the other side's
+// certificate, so both ends must present one.
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file,omitempty"`
+	KeyFile    string `yaml:"key_file,omitempty"`
+	CAFile     string `yaml:"ca_file,omitempty"`
+	ServerName string `yaml:"server_name,omitempty"`
+}
+
+// Enabled reports whether any TLS settings are configured
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.CAFile != ""
+}
+
+// WorkerKeyConfig holds the key signing and encrypting messages exchanged with one worker.
+// An empty key is looked up in the secret store as transport.workers.<id>.key.
+type WorkerKeyConfig struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key,omitempty"`
+}
+
+// SecretKey returns the secret store key of the worker's key
+func (w WorkerKeyConfig) SecretKey() string {
+	return "transport.workers." + w.ID + ".key"
+}
+
+// DecodeTransportKey decodes a base64 worker key
+func DecodeTransportKey(key string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64")
+	}
+	if len(decoded) != TransportKeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", TransportKeySize, len(decoded))
+	}
+	return decoded, nil
+}
+
+// Validate checks the TLS settings are complete and each worker has a usable key
+func (t TransportConfig) Validate() error {
+	if t.MaxSkew < 0 {
+		return fmt.Errorf("max_skew cannot be negative")
+	}
+	if t.TLS.Enabled() {
+		if t.TLS.CertFile == "" || t.TLS.KeyFile == "" {
+			return fmt.Errorf("tls: cert_file and key_file are both required")
+		}
+		if t.TLS.CAFile == "" {
+			return fmt.Errorf("tls: ca_file is required for mutual TLS")
+		}
+	}
+
+	ids := make(map[string]bool, len(t.Workers))
+	for i, worker := range t.Workers {
+		if worker.ID == "" {
+			return fmt.Errorf("worker %d: id is required", i+1)
+		}
+		if ids[worker.ID] {
+			return fmt.Errorf("duplicate worker id: %s", worker.ID)
+		}
+		ids[worker.ID] = true
+		if worker.Key == "" || strings.HasPrefix(worker.Key, SecretRefPrefix) {
+			continue
+		}
+		if _, err := DecodeTransportKey(worker.Key); err != nil {
+			return fmt.Errorf("worker %s: %w", worker.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestTransportConfig_Validate(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", TransportKeySize)))
+
+	testCases := []testutil.ValidationTestCase[TransportConfig]{
+		{Name: "empty", Input: TransportConfig{}},
+		{
+			Name: "mutual TLS and keyed workers",
+			Input: TransportConfig{
+				TLS:     TLSConfig{CertFile: "captain.pem", KeyFile: "captain-key.pem", CAFile: "ca.pem"},
+				Encrypt: true,
+				Workers: []WorkerKeyConfig{{ID: "w1", Key: key}, {ID: "w2", Key: "secret:fleet.w2"}, {ID: "w3"}},
+			},
+		},
+		{
+			Name:      "certificate without key",
+			Input:     TransportConfig{TLS: TLSConfig{CertFile: "captain.pem", CAFile: "ca.pem"}},
+			WantError: true,
+			ErrorMsg:  "tls: cert_file and key_file are both required",
+		},
+		{
+			Name:      "certificate without CA",
+			Input:     TransportConfig{TLS: TLSConfig{CertFile: "captain.pem", KeyFile: "captain-key.pem"}},
+			WantError: true,
+			ErrorMsg:  "tls: ca_file is required for mutual TLS",
+		},
+		{
+			Name:      "negative skew",
+			Input:     TransportConfig{MaxSkew: -1},
+			WantError: true,
+			ErrorMsg:  "max_skew cannot be negative",
+		},
+		{
+			Name:      "worker without id",
+			Input:     TransportConfig{Workers: []WorkerKeyConfig{{Key: key}}},
+			WantError: true,
+			ErrorMsg:  "worker 1: id is required",
+		},
+		{
+			Name:      "duplicate worker",
+			Input:     TransportConfig{Workers: []WorkerKeyConfig{{ID: "w1", Key: key}, {ID: "w1", Key: key}}},
+			WantError: true,
+			ErrorMsg:  "duplicate worker id: w1",
+		},
+		{
+			Name:      "short key",
+			Input:     TransportConfig{Workers: []WorkerKeyConfig{{ID: "w1", Key: "c2hvcnQ="}}},
+			WantError: true,
+			ErrorMsg:  "worker w1: key must be 32 bytes, got 5",
+		},
+		{
+			Name:      "key not base64",
+			Input:     TransportConfig{Workers: []WorkerKeyConfig{{ID: "w1", Key: "not base64!"}}},
+			WantError: true,
+			ErrorMsg:  "worker w1: key is not valid base64",
+		},
+	}
+
+	testutil.RunValidationTests(t, testCases, TransportConfig.Validate)
+}
+
+func TestConfig_ResolveWorkerKeys(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Transport.Workers = []WorkerKeyConfig{{ID: "w1"}, {ID: "w2", Key: "secret:fleet.w2"}, {ID: "w3", Key: "plain"}}
+	resolver := staticResolver{"transport.workers.w1.key": "stored-w1", "fleet.w2": "stored-w2"}
+
+	require.NoError(t, cfg.ResolveSecrets(resolver))
+	assert.Equal(t, "stored-w1", cfg.Transport.Workers[0].Key)
+	assert.Equal(t, "stored-w2", cfg.Transport.Workers[1].Key)
+	assert.Equal(t, "plain", cfg.Transport.Workers[2].Key)
+}
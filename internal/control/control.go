@@ -0,0 +1,283 @@
+// Package control implements the daemon's control API: a line-based JSON protocol over a unix
+// socket. A client writes one Request per line and reads one Response per line in reply, so
+// scripts can drive the daemon with nothing more than a socket tool such as socat.
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// maxRequestSize bounds the length of a request line
+const maxRequestSize = 1 << 20
+
+// dialTimeout bounds how long a client waits to connect to the socket
+const dialTimeout = 2 * time.Second
+
+// ErrNotRunning is returned by Call when nothing is listening on the socket
+var ErrNotRunning = errors.New("the daemon is not running")
+
+// Request asks the daemon to perform a verb
+type Request struct {
+	Verb string            `json:"verb"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// Response is the daemon's reply to a request; Result holds the verb's output when OK is set
+type Response struct {
+	OK     bool            `json:"ok"`
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// Handler performs a verb with the request's arguments and returns its result, which is
+// encoded as JSON. ctx ends when the client disconnects or the server shuts down.
+type Handler func(ctx context.Context, args map[string]string) (any, error)
+
+// Server answers control requests on a unix socket
+type Server struct {
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	listener net.Listener
+	path     string
+	conns    map[net.Conn]struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a control server answering the built-in "verbs" verb, which lists the
+// verbs it handles
+func NewServer(logger *zap.Logger) *Server {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	s := &Server{
+		logger:   logger,
+		handlers: make(map[string]Handler),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.Handle("verbs", func(context.Context, map[string]string) (any, error) {
+		return s.Verbs(), nil
+	})
+	return s
+}
+
+// Handle registers (or replaces) the handler for a verb
+func (s *Server) Handle(verb string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[verb] = handler
+}
+
+// Verbs returns the sorted verbs the server handles
+func (s *Server) Verbs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	verbs := make([]string, 0, len(s.handlers))
+	for verb := range s.handlers {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+// Start listens on the socket at path without blocking. A socket left behind by a process
+// that exited is replaced; one another process still answers on is an error.
+func (s *Server) Start(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, dialTimeout); err == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// Anyone who can connect can stop agents, so only the daemon's user may
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.listener = listener
+	s.path = path
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	common.Go(s.logger, "control server", func() {
+		defer s.wg.Done()
+		s.serve(ctx, listener)
+	})
+	return nil
+}
+
+// Shutdown stops accepting connections, cancels requests in progress and waits for them to
+// finish, then removes the socket
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	listener, path, cancel := s.listener, s.path, s.cancel
+	s.listener = nil
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	if listener == nil {
+		return nil
+	}
+
+	cancel()
+	err := listener.Close()
+	s.wg.Wait()
+	if rmErr := os.Remove(path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve(ctx context.Context, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Warn("Control server stopped accepting connections", zap.Error(err))
+			}
+			return
+		}
+
+		s.mu.Lock()
+		if s.listener == nil {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		common.Go(s.logger, "control connection", func() {
+			defer s.wg.Done()
+			defer s.forget(conn)
+			s.serveConn(ctx, conn)
+		})
+	}
+}
+
+// forget closes a connection and stops tracking it
+func (s *Server) forget(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.Close()
+	delete(s.conns, conn)
+}
+
+// serveConn answers the requests on a connection one line at a time until the client hangs up
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestSize)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := encoder.Encode(s.answer(ctx, scanner.Bytes())); err != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		_ = encoder.Encode(Response{Error: fmt.Sprintf("failed to read request: %v", err)})
+	}
+}
+
+// answer performs one request line and returns its response
+func (s *Server) answer(ctx context.Context, line []byte) Response {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return Response{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	s.mu.Lock()
+	handler, ok := s.handlers[req.Verb]
+	s.mu.Unlock()
+	if !ok {
+		return Response{Error: fmt.Sprintf("unknown verb: %q", req.Verb)}
+	}
+
+	var result any
+	err := common.Recover("control verb "+req.Verb, func() error {
+		var err error
+		result, err = handler(ctx, req.Args)
+		return err
+	})
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("failed to encode result: %v", err)}
+	}
+	return Response{OK: true, Result: data}
+}
+
+// Call sends a request to the daemon listening on the socket at path and decodes its result
+// into result, which may be nil. It returns ErrNotRunning when no daemon is listening.
+func Call(ctx context.Context, path, verb string, args map[string]string, result any) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return fmt.Errorf("%w (no control socket at %s)", ErrNotRunning, path)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// Interrupting the caller abandons the request rather than waiting for its reply
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(Request{Verb: verb, Args: args}); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
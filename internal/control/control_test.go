@@ -0,0 +1,126 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer starts a server with an "echo" verb on a socket in a temporary directory
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	server := NewServer(nil)
+	server.Handle("echo", func(_ context.Context, args map[string]string) (any, error) {
+		if args["fail"] != "" {
+			return nil, errors.New(args["fail"])
+		}
+		if args["panic"] != "" {
+			panic(args["panic"])
+		}
+		return args, nil
+	})
+	require.NoError(t, server.Start(path))
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return server, path
+}
+
+func TestServer_Call(t *testing.T) {
+	_, path := startServer(t)
+	ctx := context.Background()
+
+	var echoed map[string]string
+	require.NoError(t, Call(ctx, path, "echo", map[string]string{"name": "capn"}, &echoed))
+	assert.Equal(t, map[string]string{"name": "capn"}, echoed)
+
+	var verbs []string
+	require.NoError(t, Call(ctx, path, "verbs", nil, &verbs))
+	assert.Equal(t, []string{"echo", "verbs"}, verbs)
+
+	assert.EqualError(t, Call(ctx, path, "launch", nil, nil), `unknown verb: "launch"`)
+	assert.EqualError(t, Call(ctx, path, "echo", map[string]string{"fail": "no agents"}, nil), "no agents")
+	err := Call(ctx, path, "echo", map[string]string{"panic": "boom"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom", "a panicking verb fails only its request")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestServer_LinePerRequest(t *testing.T) {
+	_, path := startServer(t)
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("{\"verb\": \"echo\", \"args\": {\"n\": \"1\"}}\n\nnot json\n{\"verb\": \"echo\"}\n"))
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	var responses []Response
+	for range 3 {
+		line, err := reader.ReadBytes('\n')
+		require.NoError(t, err)
+		var resp Response
+		require.NoError(t, json.Unmarshal(line, &resp))
+		responses = append(responses, resp)
+	}
+	assert.True(t, responses[0].OK)
+	assert.JSONEq(t, `{"n": "1"}`, string(responses[0].Result))
+	assert.False(t, responses[1].OK)
+	assert.Contains(t, responses[1].Error, "invalid request")
+	assert.True(t, responses[2].OK, "a bad line does not end the connection")
+}
+
+func TestServer_Socket(t *testing.T) {
+	server, path := startServer(t)
+	assert.EqualError(t, NewServer(nil).Start(path), "another daemon is listening on "+path)
+
+	require.NoError(t, server.Shutdown())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "shutting down removes the socket")
+	err = Call(context.Background(), path, "verbs", nil, nil)
+	assert.ErrorIs(t, err, ErrNotRunning)
+
+	// A socket left behind by a process that exited is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	restarted := NewServer(nil)
+	require.NoError(t, restarted.Start(path))
+	defer restarted.Shutdown()
+	assert.NoError(t, Call(context.Background(), path, "verbs", nil, nil))
+}
+
+func TestServer_ShutdownCancelsRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	server := NewServer(nil)
+	started := make(chan struct{})
+	server.Handle("wait", func(ctx context.Context, _ map[string]string) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	require.NoError(t, server.Start(path))
+
+	done := make(chan error, 1)
+	go func() { done <- Call(context.Background(), path, "wait", nil, nil) }()
+	<-started
+	require.NoError(t, server.Shutdown())
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("request was not cancelled by shutdown")
+	}
+}
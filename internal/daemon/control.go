@@ -0,0 +1,239 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/control"
+)
+
+// defaultDrainTimeout bounds how long a drain waits for busy agents when no timeout is given
+const defaultDrainTimeout = time.Minute
+
+// defaultReplyWait bounds how long a send waits for the agent's reply when no wait is given
+const defaultReplyWait = 5 * time.Second
+
+// OperatorID is the sender of the messages sent to agents over the control socket
+const OperatorID = "operator"
+
+// ErrPaused is returned by Admit while the daemon is paused
+var ErrPaused = errors.New(`the daemon is paused; resume it with "capn ctl resume"`)
+
+// Status is the daemon's state as reported by the "status" control verb
+type Status struct {
+	StartedAt time.Time         `json:"started_at"`
+	Paused    bool              `json:"paused"`
+	UIAddr    string            `json:"ui_addr,omitempty"`
+	SlackAddr string            `json:"slack_addr,omitempty"`
+	Agents    agents.AgentStats `json:"agents"`
+}
+
+// DrainResult lists the agents a drain terminated and those still busy when it timed out
+type DrainResult struct {
+	Terminated  []string `json:"terminated"`
+	Interrupted []string `json:"interrupted,omitempty"`
+}
+
+// SendResult is the message the "send" control verb routed to an agent, with the agent's
+// reply when one correlated with it arrived in time
+type SendResult struct {
+	Message agents.Message  `json:"message"`
+	Reply   *agents.Message `json:"reply,omitempty"`
+}
+
+// messageTypes are the types of message the operator may send
+var messageTypes = []agents.MessageType{agents.MessageTypeText, agents.MessageTypeCommand, agents.MessageTypeStatus, agents.MessageTypeResult}
+
+// Send routes a message from the operator to a registered agent through the message router,
+// so it is logged like the agents' own messages, and waits until ctx ends for the agent's
+// reply. A task ID records the exchange in that task's log too.
+func (d *Daemon) Send(ctx context.Context, to string, messageType agents.MessageType, content, taskID string) (SendResult, error) {
+	if !slices.Contains(messageTypes, messageType) {
+		return SendResult{}, fmt.Errorf("invalid message type %q (must be one of: text, command, status, result)", messageType)
+	}
+	message := agents.Message{
+		ID:        uuid.NewString(),
+		From:      OperatorID,
+		To:        to,
+		Content:   content,
+		Type:      messageType,
+		Timestamp: time.Now(),
+	}
+	if taskID != "" {
+		message.Data = map[string]interface{}{"task_id": taskID}
+	}
+	reply, err := d.router.Request(ctx, message)
+	if err != nil {
+		return SendResult{}, err
+	}
+	return SendResult{Message: message, Reply: reply}, nil
+}
+
+// Status returns the daemon's current state
+func (d *Daemon) Status() Status {
+	d.mu.RLock()
+	status := Status{
+		StartedAt: d.startedAt,
+		Paused:    d.paused,
+		UIAddr:    d.uiAddr,
+		SlackAddr: d.slackAddr,
+	}
+	d.mu.RUnlock()
+	status.Agents = d.manager.GetAgentStats()
+	return status
+}
+
+// Paused reports whether the daemon is paused
+func (d *Daemon) Paused() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.paused
+}
+
+// Admit returns ErrPaused while the daemon is paused; work submitted to the daemon checks it
+// before starting a task
+func (d *Daemon) Admit() error {
+	if d.Paused() {
+		return ErrPaused
+	}
+	return nil
+}
+
+// Pause stops filling warm pools and collecting idle agents and turns away new tasks. Tasks
+// already running carry on.
+func (d *Daemon) Pause() {
+	d.mu.Lock()
+	d.paused = true
+	stop := d.stopLifecycle
+	d.stopLifecycle = nil
+	d.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	d.logger.Info("Daemon paused")
+}
+
+// Resume undoes Pause
+func (d *Daemon) Resume() {
+	d.mu.Lock()
+	d.paused = false
+	d.mu.Unlock()
+
+	d.startLifecycle()
+	d.logger.Info("Daemon resumed")
+}
+
+// Drain pauses the daemon, waits for busy agents to finish their current task and terminates
+// every agent. Agents still busy when ctx ends are interrupted. The daemon stays paused.
+func (d *Daemon) Drain(ctx context.Context) (DrainResult, error) {
+	d.Pause()
+
+	var result DrainResult
+	for _, agent := range d.manager.GetManagedAgents() {
+		result.Terminated = append(result.Terminated, agent.ID())
+	}
+	sort.Strings(result.Terminated)
+
+	interrupted, err := d.manager.Shutdown(ctx)
+	result.Interrupted = interrupted
+	if len(interrupted) > 0 {
+		d.logger.Warn("Agents interrupted while draining", zap.Strings("agents", interrupted))
+	}
+	d.logger.Info("Agents drained", zap.Int("agents", len(result.Terminated)))
+	return result, err
+}
+
+// ControlSocket returns the path of the socket the daemon answers control requests on, or
+// empty if it is not listening
+func (d *Daemon) ControlSocket() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.control == nil {
+		return ""
+	}
+	return d.config.ControlSocket()
+}
+
+// startControl starts answering control requests on the daemon's control socket
+func (d *Daemon) startControl() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.control != nil {
+		return nil
+	}
+
+	server := control.NewServer(d.logger)
+	server.Handle("status", func(context.Context, map[string]string) (any, error) {
+		return d.Status(), nil
+	})
+	server.Handle("pause", func(context.Context, map[string]string) (any, error) {
+		d.Pause()
+		return d.Status(), nil
+	})
+	server.Handle("resume", func(context.Context, map[string]string) (any, error) {
+		d.Resume()
+		return d.Status(), nil
+	})
+	server.Handle("drain", func(ctx context.Context, args map[string]string) (any, error) {
+		timeout := defaultDrainTimeout
+		if value, ok := args["timeout"]; ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid drain timeout: %q", value)
+			}
+			timeout = parsed
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return d.Drain(ctx)
+	})
+	server.Handle("reload", func(context.Context, map[string]string) (any, error) {
+		return d.Reload()
+	})
+	server.Handle("send", func(ctx context.Context, args map[string]string) (any, error) {
+		wait := defaultReplyWait
+		if value, ok := args["wait"]; ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid reply wait: %q", value)
+			}
+			wait = parsed
+		}
+		messageType := agents.MessageType(args["type"])
+		if messageType == "" {
+			messageType = agents.MessageTypeText
+		}
+		ctx, cancel := context.WithTimeout(ctx, wait)
+		defer cancel()
+		return d.Send(ctx, args["agent"], messageType, args["content"], args["task"])
+	})
+
+	path := d.config.ControlSocket()
+	if err := server.Start(path); err != nil {
+		return fmt.Errorf("failed to start control socket: %w", err)
+	}
+	d.control = server
+	d.logger.Info("Control socket listening", zap.String("path", path))
+	return nil
+}
+
+// stopControl stops answering control requests and removes the socket
+func (d *Daemon) stopControl() error {
+	d.mu.Lock()
+	server := d.control
+	d.control = nil
+	d.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown()
+}
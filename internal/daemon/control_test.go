@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/control"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestDaemon_ControlSocket(t *testing.T) {
+	cfg := config.NewConfig()
+	d, err := New(cfg, nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	path := d.ControlSocket()
+	assert.Equal(t, cfg.ControlSocket(), path)
+
+	var status Status
+	require.NoError(t, control.Call(context.Background(), path, "status", nil, &status))
+	assert.False(t, status.Paused)
+	assert.False(t, status.StartedAt.IsZero())
+
+	require.NoError(t, d.Stop())
+	assert.Empty(t, d.ControlSocket())
+	assert.ErrorIs(t, control.Call(context.Background(), path, "status", nil, nil), control.ErrNotRunning)
+}
+
+func TestDaemon_PauseAndResume(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Agents.Lifecycle = config.LifecycleConfig{Interval: time.Minute, WarmPool: map[string]int{"file": 1}}
+	d, err := New(cfg, nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	d.Manager().SetClock(clock)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+	require.Eventually(t, func() bool { return d.Manager().GetAgentStats().Idle == 1 }, time.Second, 10*time.Millisecond)
+
+	var status Status
+	require.NoError(t, control.Call(context.Background(), d.ControlSocket(), "pause", nil, &status))
+	assert.True(t, status.Paused)
+	assert.ErrorIs(t, d.Admit(), ErrPaused)
+
+	// The warm pool is not refilled while paused
+	require.NoError(t, d.Manager().TerminateAll())
+	clock.Advance(time.Minute)
+	assert.Zero(t, d.Manager().GetAgentStats().Total)
+
+	require.NoError(t, control.Call(context.Background(), d.ControlSocket(), "resume", nil, &status))
+	assert.False(t, status.Paused)
+	assert.NoError(t, d.Admit())
+	require.Eventually(t, func() bool { return d.Manager().GetAgentStats().Idle == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestDaemon_Drain(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+	_, err = d.Manager().SpawnAgent("file-1", "FileAgent", agents.AgentTypeFile)
+	require.NoError(t, err)
+	busy, err := d.Manager().SpawnAgent("network-1", "NetworkAgent", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	busy.(*agents.BaseAgent).SetStatus(agents.AgentStatusBusy)
+
+	err = control.Call(context.Background(), d.ControlSocket(), "drain", map[string]string{"timeout": "soon"}, nil)
+	assert.EqualError(t, err, `invalid drain timeout: "soon"`)
+
+	var result DrainResult
+	require.NoError(t, control.Call(context.Background(), d.ControlSocket(), "drain", map[string]string{"timeout": "50ms"}, &result))
+	assert.Equal(t, []string{"file-1", "network-1"}, result.Terminated)
+	assert.Equal(t, []string{"network-1"}, result.Interrupted, "agents still busy at the timeout are interrupted")
+	assert.Zero(t, d.Manager().GetAgentStats().Total)
+	assert.True(t, d.Paused(), "a drained daemon stays paused")
+}
+
+func TestDaemon_Reload(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+
+	err = control.Call(context.Background(), d.ControlSocket(), "reload", nil, nil)
+	assert.EqualError(t, err, "this daemon cannot reload its configuration")
+
+	reloaded := config.NewConfig()
+	reloaded.Agents.Lifecycle = config.LifecycleConfig{Interval: 10 * time.Millisecond, WarmPool: map[string]int{"research": 2}}
+	d.SetReloader(func() (*config.Config, error) { return reloaded, nil })
+	require.NoError(t, control.Call(context.Background(), d.ControlSocket(), "reload", nil, nil))
+	require.Eventually(t, func() bool {
+		return d.Manager().GetAgentStats().ByType[agents.AgentTypeResearch] == 2
+	}, time.Second, 10*time.Millisecond, "the reloaded lifecycle settings apply")
+
+	d.SetReloader(func() (*config.Config, error) { return nil, errors.New("invalid log level") })
+	err = control.Call(context.Background(), d.ControlSocket(), "reload", nil, nil)
+	assert.EqualError(t, err, "failed to reload config: invalid log level")
+}
+
+// scanAgent answers command messages, as plugin agents may
+type scanAgent struct {
+	*agents.BaseAgent
+}
+
+func (a *scanAgent) ReceiveMessage(message agents.Message) error {
+	if err := a.BaseAgent.ReceiveMessage(message); err != nil {
+		return err
+	}
+	if message.Type == agents.MessageTypeCommand {
+		go func() {
+			_ = a.SendMessage(message.From, agents.Message{ID: "reply-1", Content: "scanned 12 files", Type: agents.MessageTypeResult,
+				Data: map[string]interface{}{agents.MessageDataInReplyTo: message.ID, "task_id": message.Data["task_id"]}})
+		}()
+	}
+	return nil
+}
+
+func TestDaemon_Send(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+	te := task.NewTaskExecution("scan the sources")
+	require.NoError(t, d.Storage().SaveTask(te))
+	agent := &scanAgent{agents.NewBaseAgent("file-001", "File", agents.AgentTypeFile)}
+	agent.SetRouter(d.router)
+	require.NoError(t, d.router.RegisterAgent(agent))
+
+	var result SendResult
+	args := map[string]string{"agent": "file-001", "type": "command", "content": "re-scan ./src", "task": te.ID}
+	require.NoError(t, control.Call(context.Background(), d.ControlSocket(), "send", args, &result))
+	assert.Equal(t, OperatorID, result.Message.From)
+	require.NotNil(t, result.Reply)
+	assert.Equal(t, "scanned 12 files", result.Reply.Content)
+	assert.Equal(t, "re-scan ./src", agent.GetReceivedMessages()[0].Content)
+
+	stored, err := d.Storage().GetTask(te.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Logs, 2, "the message and its reply are logged like agent messages")
+	assert.Equal(t, OperatorID, stored.Logs[0].From)
+	assert.Equal(t, "file-001", stored.Logs[1].From)
+
+	args = map[string]string{"agent": "file-001", "content": "hello", "wait": "10ms"}
+	var unanswered SendResult
+	require.NoError(t, control.Call(context.Background(), d.ControlSocket(), "send", args, &unanswered))
+	assert.Nil(t, unanswered.Reply, "text messages get no reply")
+
+	err = control.Call(context.Background(), d.ControlSocket(), "send", map[string]string{"agent": "file-001", "content": "x", "type": "shout"}, nil)
+	assert.EqualError(t, err, `invalid message type "shout" (must be one of: text, command, status, result)`)
+	err = control.Call(context.Background(), d.ControlSocket(), "send", map[string]string{"agent": "nobody", "content": "x"}, nil)
+	assert.EqualError(t, err, "recipient agent not found: nobody")
+}
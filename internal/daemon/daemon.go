@@ -0,0 +1,447 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/agents/plugins"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/control"
+	"github.com/iainlowe/capn/internal/events"
+	"github.com/iainlowe/capn/internal/notify"
+	"github.com/iainlowe/capn/internal/slack"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/ui"
+)
+
+// digestFlushInterval is how often the daemon sends notification digests that are due
+const digestFlushInterval = time.Minute
+
+// shutdownTimeout bounds how long the daemon waits for servers to drain on exit
+const shutdownTimeout = 5 * time.Second
+
+// Daemon is the long-running capn process hosting the agent system and its APIs
+type Daemon struct {
+	config  *config.Config
+	logger  *zap.Logger
+	storage task.TaskStorage
+	manager *agents.AgentManager
+	router  *agents.MessageRouter
+	commLog agents.CommunicationLogger
+	bus     *events.Bus
+
+	mu          sync.RWMutex
+	dashboard   *ui.Server
+	uiAddr      string
+	stopMonitor context.CancelFunc
+	// stopLifecycle ends agent collection and pool filling, then waits for them to stop
+	stopLifecycle func()
+	notifier      *notify.Dispatcher
+	stopNotify    context.CancelFunc
+	slack         *slack.Bot
+	slackAddr     string
+	stopSlack     context.CancelFunc
+	resume        func(*task.TaskExecution)
+	control       *control.Server
+	reload        func() (*config.Config, error)
+	reloadHooks   []func(*config.Config, ReloadReport) error
+	reloading     sync.Mutex
+	// paused stops warm pool filling and idle collection and turns away new tasks
+	paused    bool
+	running   bool
+	startedAt time.Time
+}
+
+// New creates a daemon using the given configuration and task storage
+func New(cfg *config.Config, logger *zap.Logger, storage task.TaskStorage) (*Daemon, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage cannot be nil")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// Task and message activity is published on the bus so consumers need not poll storage
+	bus := events.NewBus()
+	publishing := task.NewPublishingStorage(storage, bus)
+
+	// Messages are persisted for "capn agents history"; messages about a task are also
+	// recorded in its log, which outlives the message log's retention
+	messages, err := agents.NewFileCommunicationLogger(cfg.MessagesFile(), agents.Retention{
+		MaxMessages: cfg.Agents.Communication.MaxMessages,
+		MaxAge:      cfg.Agents.Communication.MaxAge,
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages.SetErrorHandler(func(err error) {
+		logger.Warn("Failed to persist agent message", zap.Error(err))
+	})
+	recorder := task.NewMessageRecorder(messages, publishing)
+	recorder.SetErrorHandler(func(taskID string, err error) {
+		logger.Warn("Failed to record agent message in task log", zap.String("task_id", taskID), zap.Error(err))
+	})
+	commLog := agents.NewPublishingCommunicationLogger(recorder, bus)
+
+	attachments, err := task.NewArtifactStore(cfg.ArtifactsDir())
+	if err != nil {
+		return nil, err
+	}
+	router := agents.NewMessageRouter()
+	router.SetLogger(commLog)
+	router.SetMessageLimit(cfg.Agents.Communication.MaxMessageSize, attachments)
+	router.SetSpillErrorHandler(func(err error) {
+		logger.Warn("Truncated agent message", zap.Error(err))
+	})
+
+	manager := agents.NewAgentManager()
+	manager.SetRouter(router)
+	if err := plugins.Install(manager, cfg.Agents.Plugins, logger); err != nil {
+		return nil, fmt.Errorf("failed to install agent plugins: %w", err)
+	}
+	manager.SetHealthPolicy(healthPolicy(cfg.Agents.Health))
+	manager.SetLifecyclePolicy(lifecyclePolicy(cfg.Agents.Lifecycle))
+	manager.SetHealthHandler(func(event agents.HealthEvent) {
+		fields := []zap.Field{
+			zap.String("agent_id", event.AgentID),
+			zap.String("action", string(event.Action)),
+			zap.String("health", string(event.Health)),
+			zap.Int("restarts", event.Restarts),
+		}
+		if event.Error != "" {
+			fields = append(fields, zap.String("error", event.Error))
+		}
+		logger.Warn("Agent health changed", fields...)
+		bus.Publish(events.Event{
+			Type:      events.EventAgentHealth,
+			Agent:     event.AgentID,
+			Status:    string(event.Action),
+			Message:   event.Error,
+			Data:      map[string]any{"health": string(event.Health), "restarts": event.Restarts, "agent_type": string(event.AgentType)},
+			Timestamp: event.Timestamp,
+		})
+	})
+
+	return &Daemon{
+		config:  cfg,
+		logger:  logger,
+		storage: publishing,
+		manager: manager,
+		router:  router,
+		commLog: commLog,
+		bus:     bus,
+	}, nil
+}
+
+// Manager returns the daemon's agent manager
+func (d *Daemon) Manager() *agents.AgentManager {
+	return d.manager
+}
+
+// Storage returns the daemon's task storage; saves through it publish task events
+func (d *Daemon) Storage() task.TaskStorage {
+	return d.storage
+}
+
+// Events returns the daemon's event bus
+func (d *Daemon) Events() *events.Bus {
+	return d.bus
+}
+
+// Config returns the daemon's configuration, as last reloaded
+func (d *Daemon) Config() *config.Config {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.config
+}
+
+// UIAddr returns the address the dashboard is bound to, or empty if it is not running
+func (d *Daemon) UIAddr() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.uiAddr
+}
+
+// SetNotifications sets the dispatcher whose notification digests the daemon sends as
+// their windows pass. Setting it while the daemon runs, as a configuration reload does,
+// replaces the dispatcher; nil stops sending digests.
+func (d *Daemon) SetNotifications(dispatcher *notify.Dispatcher) {
+	d.mu.Lock()
+	d.notifier = dispatcher
+	stop := d.stopNotify
+	d.stopNotify = nil
+	running := d.running
+	d.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	if running {
+		d.startDigestFlusher()
+	}
+}
+
+// SetSlack sets the Slack bot the daemon serves on integrations.slack.listen and posts task
+// updates through; it must be called before Start
+func (d *Daemon) SetSlack(bot *slack.Bot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.slack = bot
+}
+
+// SetResumer sets the function the daemon resumes the tasks it finds interrupted with when
+// captain.resume_interrupted is set; it must be called before Start
+func (d *Daemon) SetResumer(resume func(*task.TaskExecution)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resume = resume
+}
+
+// SlackAddr returns the address the Slack integration is bound to, or empty if it is not running
+func (d *Daemon) SlackAddr() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.slackAddr
+}
+
+// Start starts the daemon's servers without blocking
+func (d *Daemon) Start() error {
+	for _, limitation := range agents.PlatformLimitations() {
+		d.logger.Warn("Platform limitation", zap.String("detail", limitation))
+	}
+	d.mu.Lock()
+	d.startedAt = time.Now()
+	d.running = true
+	d.mu.Unlock()
+	if err := d.startControl(); err != nil {
+		return err
+	}
+	d.recoverTasks()
+	d.startHealthMonitor()
+	d.startLifecycle()
+	d.startDigestFlusher()
+	if err := d.startSlack(); err != nil {
+		_ = d.stopControl()
+		return err
+	}
+
+	dashboard := d.Config().UI
+	if !dashboard.Enabled {
+		d.logger.Info("Web dashboard disabled (set ui.enabled to turn it on)")
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.dashboard = ui.NewServer(d.storage, d.manager, d.commLog, d.logger)
+	d.dashboard.SetEventBus(d.bus)
+	addr, err := d.dashboard.Start(dashboard.Listen)
+	if err != nil {
+		d.dashboard = nil
+		_ = d.stopControl()
+		return fmt.Errorf("failed to start dashboard: %w", err)
+	}
+	d.uiAddr = addr
+	d.logger.Info("Web dashboard listening", zap.String("addr", "http://"+addr))
+	return nil
+}
+
+// Stop shuts down the daemon's servers and agents, giving in-flight work time to finish
+func (d *Daemon) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Closing the bus first ends event streams so the dashboard can drain
+	d.bus.Close()
+	if err := d.stopControl(); err != nil {
+		d.logger.Warn("Failed to stop control socket", zap.Error(err))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.running = false
+	if d.stopMonitor != nil {
+		d.stopMonitor()
+		d.stopMonitor = nil
+	}
+	if d.stopLifecycle != nil {
+		d.stopLifecycle()
+		d.stopLifecycle = nil
+	}
+	if d.stopNotify != nil {
+		d.stopNotify()
+		d.stopNotify = nil
+	}
+	if d.stopSlack != nil {
+		d.stopSlack()
+		d.stopSlack = nil
+		if err := d.slack.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to stop Slack integration: %w", err)
+		}
+		d.slackAddr = ""
+	}
+
+	if d.dashboard != nil {
+		if err := d.dashboard.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to stop dashboard: %w", err)
+		}
+		d.dashboard = nil
+		d.uiAddr = ""
+	}
+
+	// Let busy agents finish their current task before terminating them
+	interrupted, err := d.manager.Shutdown(ctx)
+	if len(interrupted) > 0 {
+		d.logger.Warn("Agents interrupted during shutdown", zap.Strings("agents", interrupted))
+	}
+	return err
+}
+
+// recoverTasks marks the tasks left in flight by capn processes that exited, such as an
+// earlier daemon, as interrupted, resuming them when captain.resume_interrupted is set
+func (d *Daemon) recoverTasks() {
+	recovered, err := task.RecoverTasks(d.storage)
+	if err != nil {
+		d.logger.Warn("Failed to recover interrupted tasks", zap.Error(err))
+	}
+
+	d.mu.RLock()
+	resume := d.resume
+	d.mu.RUnlock()
+	for _, t := range recovered {
+		d.logger.Warn("Task was interrupted", zap.String("task_id", t.ID), zap.String("error", t.Error))
+		if !d.Config().Captain.ResumeInterrupted || resume == nil {
+			continue
+		}
+		if err := t.Resume(); err != nil {
+			d.logger.Warn("Not resuming interrupted task", zap.String("task_id", t.ID), zap.Error(err))
+			continue
+		}
+		if err := d.storage.SaveTask(t); err != nil {
+			d.logger.Warn("Failed to resume interrupted task", zap.String("task_id", t.ID), zap.Error(err))
+			continue
+		}
+		d.logger.Info("Resuming interrupted task", zap.String("task_id", t.ID))
+		resume(t)
+	}
+}
+
+// startHealthMonitor probes agent health in the background until the daemon stops
+func (d *Daemon) startHealthMonitor() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	interval := d.config.Agents.Health.Interval
+	if interval <= 0 || d.stopMonitor != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.stopMonitor = cancel
+	common.Go(d.logger, "agent health monitor", func() { d.manager.MonitorAgents(ctx, interval) })
+}
+
+// healthPolicy converts the configured agent health checks to the manager's policy
+func healthPolicy(cfg config.HealthConfig) agents.HealthPolicy {
+	return agents.HealthPolicy{
+		RestartUnhealthy: cfg.Restart,
+		MaxRestarts:      cfg.MaxRestarts,
+		RestartBackoff:   cfg.RestartBackoff,
+	}
+}
+
+// lifecyclePolicy converts the configured agent lifecycle to the manager's policy
+func lifecyclePolicy(cfg config.LifecycleConfig) agents.LifecyclePolicy {
+	policy := agents.LifecyclePolicy{IdleTTL: cfg.IdleTTL}
+	if len(cfg.WarmPool) > 0 {
+		policy.WarmPool = make(map[agents.AgentType]int, len(cfg.WarmPool))
+		for agentType, size := range cfg.WarmPool {
+			policy.WarmPool[agents.AgentType(agentType)] = size
+		}
+	}
+	return policy
+}
+
+// startLifecycle collects idle agents and keeps warm pools filled in the background until the
+// daemon stops or is paused
+func (d *Daemon) startLifecycle() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lifecycle := d.config.Agents.Lifecycle
+	if !lifecycle.Enabled() || lifecycle.Interval <= 0 || d.paused || d.stopLifecycle != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	// Stopping waits so no pool agent is spawned after the agents are shut down
+	d.stopLifecycle = func() {
+		cancel()
+		<-done
+	}
+	common.Go(d.logger, "agent lifecycle", func() {
+		defer close(done)
+		d.manager.ManageLifecycle(ctx, lifecycle.Interval, func(err error) {
+			d.logger.Warn("Failed to manage agent lifecycle", zap.Error(err))
+		})
+	})
+}
+
+// startDigestFlusher sends notification digests in the background until the daemon stops
+func (d *Daemon) startDigestFlusher() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.notifier == nil || d.stopNotify != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.stopNotify = cancel
+	notifier := d.notifier
+	common.Go(d.logger, "notification digests", func() {
+		notifier.Run(ctx, digestFlushInterval, func(err error) {
+			d.logger.Warn("Failed to send notification digests", zap.Error(err))
+		})
+	})
+}
+
+// startSlack starts receiving Slack requests and posting task updates until the daemon stops
+func (d *Daemon) startSlack() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.slack == nil || d.stopSlack != nil {
+		return nil
+	}
+	addr, err := d.slack.Start(d.config.Integrations.Slack.ListenAddr())
+	if err != nil {
+		return fmt.Errorf("failed to start Slack integration: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.slack.Watch(ctx, d.bus); err != nil {
+		cancel()
+		_ = d.slack.Shutdown(context.Background())
+		return fmt.Errorf("failed to start Slack integration: %w", err)
+	}
+	d.stopSlack = cancel
+	d.slackAddr = addr
+	d.logger.Info("Slack integration listening", zap.String("addr", "http://"+addr))
+	return nil
+}
+
+// Run starts the daemon and blocks until the context is cancelled
+func (d *Daemon) Run(ctx context.Context) error {
+	if err := d.Start(); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	d.logger.Info("Daemon shutting down")
+	return d.Stop()
+}
@@ -0,0 +1,276 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/events"
+	"github.com/iainlowe/capn/internal/notify"
+	"github.com/iainlowe/capn/internal/slack"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the message log daemons persist out of the real home directory
+	home, err := os.MkdirTemp("", "capn-daemon-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("CAPN_HOME", home)
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+func TestNew_Validation(t *testing.T) {
+	_, err := New(nil, nil, task.NewMemoryTaskStorage())
+	assert.Error(t, err)
+
+	_, err = New(config.NewConfig(), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestDaemon_UIDisabled(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+
+	require.NoError(t, d.Start())
+	assert.Empty(t, d.UIAddr())
+	assert.NoError(t, d.Stop())
+}
+
+func TestDaemon_FlushesNotificationDigests(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	dispatcher, err := notify.NewDispatcher(config.NotificationsConfig{}, t.TempDir())
+	require.NoError(t, err)
+	d.SetNotifications(dispatcher)
+
+	require.NoError(t, d.Start())
+	assert.NotNil(t, d.stopNotify, "the daemon flushes digests while it runs")
+	require.NoError(t, d.Stop())
+	assert.Nil(t, d.stopNotify)
+}
+
+func TestDaemon_RunServesDashboard(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.UI.Enabled = true
+	cfg.UI.Listen = "127.0.0.1:0"
+
+	d, err := New(cfg, nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return d.UIAddr() != "" }, time.Second, 10*time.Millisecond)
+
+	resp, err := http.Get("http://" + d.UIAddr() + "/api/agents")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("daemon did not stop after context cancellation")
+	}
+}
+
+func TestDaemon_PublishesTaskEvents(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+
+	sub, err := d.Events().Subscribe(events.SubscribeOptions{Name: "test"})
+	require.NoError(t, err)
+
+	te := task.NewTaskExecution("analyze code")
+	require.NoError(t, d.Storage().SaveTask(te))
+
+	event := <-sub.Events()
+	assert.Equal(t, events.EventTaskCreated, event.Type)
+	assert.Equal(t, te.ID, event.TaskID)
+
+	// Stopping the daemon closes subscriptions
+	require.NoError(t, d.Stop())
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+}
+
+func TestDaemon_RestartsUnhealthyAgents(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Agents.Health.Interval = 10 * time.Millisecond
+	cfg.Agents.Health.RestartBackoff = 0
+
+	d, err := New(cfg, nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	sub, err := d.Events().Subscribe(events.SubscribeOptions{Name: "test", Types: []events.EventType{events.EventAgentHealth}})
+	require.NoError(t, err)
+
+	agent, err := d.Manager().SpawnAgent("file-1", "FileAgent", agents.AgentTypeFile)
+	require.NoError(t, err)
+	agent.(*agents.BaseAgent).SetStatus(agents.AgentStatusError)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+
+	select {
+	case event := <-sub.Events():
+		assert.Equal(t, "file-1", event.Agent)
+		assert.Equal(t, string(agents.HealthActionRestarted), event.Status)
+		assert.Equal(t, 1, event.Data["restarts"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("no agent health event published")
+	}
+	assert.Equal(t, 1, d.Manager().Restarts("file-1"))
+}
+
+func TestDaemon_KeepsWarmPool(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Agents.Lifecycle = config.LifecycleConfig{
+		IdleTTL:  time.Millisecond,
+		WarmPool: map[string]int{"file": 2},
+		Interval: 10 * time.Millisecond,
+	}
+
+	d, err := New(cfg, nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	_, err = d.Manager().SpawnAgent("network-1", "NetworkAgent", agents.AgentTypeNetwork)
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+
+	require.Eventually(t, func() bool {
+		stats := d.Manager().GetAgentStats()
+		return stats.ByType[agents.AgentTypeFile] == 2 && stats.ByType[agents.AgentTypeNetwork] == 0
+	}, 2*time.Second, 5*time.Millisecond, "the pool is filled and idle agents outside it are collected")
+	_, ok := d.Manager().GetAgent("file-pool-001")
+	assert.True(t, ok)
+}
+
+func TestDaemon_RecordsAgentMessagesInTaskLog(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	defer d.Stop()
+
+	te := task.NewTaskExecution("analyze code")
+	require.NoError(t, d.Storage().SaveTask(te))
+
+	sender := agents.NewBaseAgent("research-001", "Research", agents.AgentTypeResearch)
+	recipient := agents.NewBaseAgent("file-001", "File", agents.AgentTypeFile)
+	sender.SetRouter(d.router)
+	require.NoError(t, d.router.RegisterAgent(recipient))
+
+	require.NoError(t, sender.SendMessage("file-001", agents.Message{
+		ID:      "msg-1",
+		Content: "read go.mod",
+		Type:    agents.MessageTypeCommand,
+		Data:    map[string]interface{}{"task_id": te.ID},
+	}))
+
+	stored, err := d.Storage().GetTask(te.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Logs, 1)
+	assert.Equal(t, "research-001", stored.Logs[0].From)
+	assert.Equal(t, "file-001", stored.Logs[0].To)
+	assert.Equal(t, "read go.mod", stored.Logs[0].Message)
+}
+
+func TestDaemon_ServesSlack(t *testing.T) {
+	var mu sync.Mutex
+	var posted []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message slack.Message
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		mu.Lock()
+		posted = append(posted, message.Text)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+	}))
+	defer api.Close()
+
+	cfg := config.NewConfig()
+	cfg.Integrations.Slack = config.SlackConfig{Enabled: true, Channel: "#ops", Listen: "127.0.0.1:0"}
+	d, err := New(cfg, nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	d.SetSlack(slack.NewBot(slack.NewClient(api.URL, "xoxb-test"), "#ops", "secret", d.Storage(), nil))
+	require.NoError(t, d.Start())
+	require.NotEmpty(t, d.SlackAddr())
+
+	// Unsigned requests are refused
+	resp, err := http.Post("http://"+d.SlackAddr()+"/slack/commands", "application/x-www-form-urlencoded", strings.NewReader("text=deploy"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	te := task.NewTaskExecution("analyze code")
+	require.NoError(t, d.Storage().SaveTask(te))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(posted) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Task `"+te.ID+"` started: analyze code", posted[0])
+
+	require.NoError(t, d.Stop())
+	assert.Empty(t, d.SlackAddr())
+}
+
+// exitedPID returns the ID of a process that has already exited
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	require.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestDaemon_RecoversInterruptedTasks(t *testing.T) {
+	for _, resume := range []bool{false, true} {
+		t.Run(fmt.Sprintf("resume=%t", resume), func(t *testing.T) {
+			storage := task.NewMemoryTaskStorage()
+			orphan := task.NewTaskExecution("deploy the fleet")
+			orphan.Metadata[task.MetadataOwnerPID] = strconv.Itoa(exitedPID(t))
+			orphan.SetStatus(task.TaskStatusRunning)
+			require.NoError(t, storage.SaveTask(orphan))
+
+			cfg := config.NewConfig()
+			cfg.Captain.ResumeInterrupted = resume
+			d, err := New(cfg, nil, storage)
+			require.NoError(t, err)
+			var resumed []*task.TaskExecution
+			d.SetResumer(func(te *task.TaskExecution) { resumed = append(resumed, te) })
+			require.NoError(t, d.Start())
+			defer d.Stop()
+
+			stored, err := storage.GetTask(orphan.ID)
+			require.NoError(t, err)
+			if !resume {
+				assert.Equal(t, task.TaskStatusInterrupted, stored.Status)
+				assert.Empty(t, resumed)
+				return
+			}
+			assert.Equal(t, task.TaskStatusPending, stored.Status)
+			assert.Equal(t, "1", stored.Metadata[task.MetadataResumes])
+			require.Len(t, resumed, 1)
+			assert.Equal(t, orphan.ID, resumed[0].ID)
+		})
+	}
+}
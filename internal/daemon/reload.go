@@ -0,0 +1,183 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// reloadSetting is a part of the configuration a reload compares
+type reloadSetting struct {
+	name string
+	// restart says why changing the setting needs a restart; empty if a reload applies it
+	restart string
+	// field returns a pointer to the setting in a configuration
+	field func(cfg *config.Config) any
+}
+
+// Reasons the settings a reload leaves alone need a restart
+const (
+	restartLogging   = "diagnostic logs are opened when the daemon starts"
+	restartOptions   = "command-line options are read when the daemon starts"
+	restartStorage   = "task storage is opened when the daemon starts"
+	restartDashboard = "the dashboard is bound when the daemon starts"
+	restartSlack     = "the Slack integration is set up when the daemon starts"
+	restartTransport = "remote workers are connected when the daemon starts"
+	restartPlugins   = "agent plugins and crew brains are installed when the daemon starts"
+	restartMessages  = "the agent message log is opened when the daemon starts"
+)
+
+// reloadSettings lists every part of the configuration a reload compares. Settings read when
+// a task starts apply to tasks started after the reload.
+var reloadSettings = []reloadSetting{
+	{name: "global.log_level", field: func(c *config.Config) any { return &c.Global.LogLevel }},
+	{name: "global.parallel", field: func(c *config.Config) any { return &c.Global.Parallel }},
+	{name: "global.timeout", field: func(c *config.Config) any { return &c.Global.Timeout }},
+	{name: "global.verbose", restart: restartLogging, field: func(c *config.Config) any { return &c.Global.Verbose }},
+	{name: "global.log_format", restart: restartLogging, field: func(c *config.Config) any { return &c.Global.LogFormat }},
+	{name: "global.log_file", restart: restartLogging, field: func(c *config.Config) any { return &c.Global.LogFile }},
+	{name: "global.dry_run", restart: restartOptions, field: func(c *config.Config) any { return &c.Global.DryRun }},
+	{name: "global.profile", restart: restartOptions, field: func(c *config.Config) any { return &c.Global.Profile }},
+	{name: "captain", field: func(c *config.Config) any { return &c.Captain }},
+	{name: "planning", field: func(c *config.Config) any { return &c.Planning }},
+	{name: "crew.timeouts", field: func(c *config.Config) any { return &c.Crew.Timeouts }},
+	{name: "crew.limits", field: func(c *config.Config) any { return &c.Crew.Limits }},
+	{name: "crew.sandbox", field: func(c *config.Config) any { return &c.Crew.Sandbox }},
+	{name: "crew.http", field: func(c *config.Config) any { return &c.Crew.HTTP }},
+	{name: "crew.brains", restart: restartPlugins, field: func(c *config.Config) any { return &c.Crew.Brains }},
+	{name: "mcp", field: func(c *config.Config) any { return &c.MCP }},
+	{name: "agents.health", field: func(c *config.Config) any { return &c.Agents.Health }},
+	{name: "agents.lifecycle", field: func(c *config.Config) any { return &c.Agents.Lifecycle }},
+	{name: "agents.plugins", restart: restartPlugins, field: func(c *config.Config) any { return &c.Agents.Plugins }},
+	{name: "agents.communication", restart: restartMessages, field: func(c *config.Config) any { return &c.Agents.Communication }},
+	{name: "transport", restart: restartTransport, field: func(c *config.Config) any { return &c.Transport }},
+	{name: "execution", field: func(c *config.Config) any { return &c.Execution }},
+	{name: "openai", field: func(c *config.Config) any { return &c.OpenAI }},
+	{name: "llm", field: func(c *config.Config) any { return &c.LLM }},
+	{name: "storage.path", restart: restartStorage, field: func(c *config.Config) any { return &c.Storage.Path }},
+	{name: "storage.retention", field: func(c *config.Config) any { return &c.Storage.Retention }},
+	{name: "ui", restart: restartDashboard, field: func(c *config.Config) any { return &c.UI }},
+	{name: "secrets", field: func(c *config.Config) any { return &c.Secrets }},
+	{name: "github", field: func(c *config.Config) any { return &c.GitHub }},
+	{name: "notifications", field: func(c *config.Config) any { return &c.Notifications }},
+	{name: "hooks", field: func(c *config.Config) any { return &c.Hooks }},
+	{name: "integrations", restart: restartSlack, field: func(c *config.Config) any { return &c.Integrations }},
+}
+
+// ReloadReport lists the settings a reload changed and those it left as they were because
+// changing them needs a restart
+type ReloadReport struct {
+	Applied  []string          `json:"applied"`
+	Rejected []RejectedSetting `json:"rejected,omitempty"`
+}
+
+// RejectedSetting is a changed setting a reload did not apply
+type RejectedSetting struct {
+	Setting string `json:"setting"`
+	Reason  string `json:"reason"`
+}
+
+// Changed reports whether the reloaded configuration differed from the running one
+func (r ReloadReport) Changed() bool {
+	return len(r.Applied) > 0 || len(r.Rejected) > 0
+}
+
+// compareConfig reports which settings differ between the running and the reloaded
+// configuration, and restores those that need a restart in the reloaded one so it holds
+// what the daemon runs with
+func compareConfig(running, reloaded *config.Config) ReloadReport {
+	report := ReloadReport{Applied: []string{}}
+	for _, setting := range reloadSettings {
+		current := reflect.ValueOf(setting.field(running)).Elem()
+		next := reflect.ValueOf(setting.field(reloaded)).Elem()
+		if reflect.DeepEqual(current.Interface(), next.Interface()) {
+			continue
+		}
+		if setting.restart == "" {
+			report.Applied = append(report.Applied, setting.name)
+			continue
+		}
+		next.Set(current)
+		report.Rejected = append(report.Rejected, RejectedSetting{Setting: setting.name, Reason: setting.restart})
+	}
+	return report
+}
+
+// SetReloader sets the function Reload loads the configuration with
+func (d *Daemon) SetReloader(reload func() (*config.Config, error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload = reload
+}
+
+// OnReload adds a function called with the configuration and the report of each reload that
+// changed it, to apply the settings the daemon does not apply itself
+func (d *Daemon) OnReload(apply func(cfg *config.Config, report ReloadReport) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reloadHooks = append(d.reloadHooks, apply)
+}
+
+// Reload loads the configuration again and applies the settings that are safe to change while
+// the daemon runs, keeping the rest as they were. The daemon applies the agent health and
+// lifecycle settings; functions added with OnReload apply the others. The report says what
+// changed; it is returned with any error applying the changes.
+func (d *Daemon) Reload() (ReloadReport, error) {
+	// A signal and a control request arriving together reload one after the other
+	d.reloading.Lock()
+	defer d.reloading.Unlock()
+
+	d.mu.RLock()
+	reload := d.reload
+	d.mu.RUnlock()
+	if reload == nil {
+		return ReloadReport{}, fmt.Errorf("this daemon cannot reload its configuration")
+	}
+	cfg, err := reload()
+	if err != nil {
+		return ReloadReport{}, fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	d.mu.Lock()
+	report := compareConfig(d.config, cfg)
+	if !report.Changed() {
+		d.mu.Unlock()
+		d.logger.Info("Configuration reloaded; nothing changed")
+		return report, nil
+	}
+	d.config = cfg
+	if d.stopMonitor != nil {
+		d.stopMonitor()
+		d.stopMonitor = nil
+	}
+	stopLifecycle := d.stopLifecycle
+	d.stopLifecycle = nil
+	hooks := append([]func(*config.Config, ReloadReport) error(nil), d.reloadHooks...)
+	d.mu.Unlock()
+	if stopLifecycle != nil {
+		stopLifecycle()
+	}
+
+	d.manager.SetHealthPolicy(healthPolicy(cfg.Agents.Health))
+	d.manager.SetLifecyclePolicy(lifecyclePolicy(cfg.Agents.Lifecycle))
+	d.startHealthMonitor()
+	d.startLifecycle()
+
+	var errs []error
+	for _, apply := range hooks {
+		errs = append(errs, apply(cfg, report))
+	}
+	for _, rejected := range report.Rejected {
+		d.logger.Warn("Setting not reloaded; restart the daemon to change it",
+			zap.String("setting", rejected.Setting), zap.String("reason", rejected.Reason))
+	}
+	d.logger.Info("Configuration reloaded", zap.Strings("applied", report.Applied))
+	if err := errors.Join(errs...); err != nil {
+		return report, fmt.Errorf("failed to apply reloaded config: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/notify"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestReloadSettings_CoverConfig(t *testing.T) {
+	names := make(map[string]bool, len(reloadSettings))
+	for _, setting := range reloadSettings {
+		names[setting.name] = true
+	}
+	covered := func(name string) bool {
+		for setting := range names {
+			if setting == name || strings.HasPrefix(setting, name+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	configType := reflect.TypeOf(config.Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("yaml"), ",")[0]
+		// Profiles are applied while loading, so only their result is compared
+		if name == "profiles" {
+			continue
+		}
+		assert.True(t, covered(name), "a reload compares %s", name)
+	}
+}
+
+func TestCompareConfig(t *testing.T) {
+	running := config.NewConfig()
+	reloaded := config.NewConfig()
+	assert.False(t, compareConfig(running, reloaded).Changed())
+
+	reloaded.Global.Parallel = 2
+	reloaded.Storage.Path = "/srv/capn/tasks"
+	reloaded.UI.Listen = "0.0.0.0:9000"
+	reloaded.Storage.Retention.MaxTasks = 100
+	report := compareConfig(running, reloaded)
+	assert.Equal(t, []string{"global.parallel", "storage.retention"}, report.Applied)
+	assert.Equal(t, []RejectedSetting{
+		{Setting: "storage.path", Reason: restartStorage},
+		{Setting: "ui", Reason: restartDashboard},
+	}, report.Rejected)
+	assert.Empty(t, reloaded.Storage.Path, "settings needing a restart keep their running value")
+	assert.Equal(t, running.UI, reloaded.UI)
+	assert.Equal(t, 100, reloaded.Storage.Retention.MaxTasks)
+}
+
+func TestDaemon_ReloadHooks(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+
+	reloaded := config.NewConfig()
+	reloaded.Captain.MaxTaskRuntime = time.Hour
+	d.SetReloader(func() (*config.Config, error) { return reloaded, nil })
+	var applied []ReloadReport
+	d.OnReload(func(cfg *config.Config, report ReloadReport) error {
+		assert.Same(t, reloaded, cfg)
+		applied = append(applied, report)
+		return nil
+	})
+
+	report, err := d.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"captain"}, report.Applied)
+	assert.Equal(t, time.Hour, d.Config().Captain.MaxTaskRuntime)
+	require.Len(t, applied, 1)
+
+	_, err = d.Reload()
+	require.NoError(t, err)
+	assert.Len(t, applied, 1, "hooks are not called when nothing changed")
+
+	d.OnReload(func(*config.Config, ReloadReport) error { return errors.New("no such channel") })
+	reloaded = config.NewConfig()
+	report, err = d.Reload()
+	assert.EqualError(t, err, "failed to apply reloaded config: no such channel")
+	assert.Equal(t, []string{"captain"}, report.Applied)
+}
+
+func TestDaemon_ReplacesNotifications(t *testing.T) {
+	d, err := New(config.NewConfig(), nil, task.NewMemoryTaskStorage())
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+	assert.Nil(t, d.stopNotify)
+
+	dispatcher, err := notify.NewDispatcher(config.NotificationsConfig{}, t.TempDir())
+	require.NoError(t, err)
+	d.SetNotifications(dispatcher)
+	d.mu.RLock()
+	assert.NotNil(t, d.stopNotify, "digests are sent for channels set while the daemon runs")
+	d.mu.RUnlock()
+
+	d.SetNotifications(nil)
+	d.mu.RLock()
+	assert.Nil(t, d.stopNotify)
+	d.mu.RUnlock()
+}
@@ -0,0 +1,242 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies what happened
+type EventType string
+
+const (
+	EventTaskCreated       EventType = "task.created"
+	EventTaskStatusChanged EventType = "task.status_changed"
+	EventStepCompleted     EventType = "step.completed"
+	EventAgentMessage      EventType = "agent.message"
+	EventQuestionAsked     EventType = "question.asked"
+	EventQuestionAnswered  EventType = "question.answered"
+	EventAgentHealth       EventType = "agent.health"
+)
+
+// Event is a notification published on the bus
+type Event struct {
+	Type      EventType      `json:"type"`
+	TaskID    string         `json:"task_id,omitempty"`
+	Step      string         `json:"step,omitempty"`
+	Agent     string         `json:"agent,omitempty"`
+	Status    string         `json:"status,omitempty"`
+	Message   string         `json:"message,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// OverflowPolicy decides what happens when a subscriber's buffer is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropNewest discards the event being published
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest discards the oldest buffered event to make room
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDisconnect closes the subscription
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// DefaultBufferSize is the subscription buffer used when none is given
+const DefaultBufferSize = 256
+
+// SubscribeOptions configures a subscription
+type SubscribeOptions struct {
+	// Name identifies the subscriber in diagnostics
+	Name string
+	// BufferSize is the number of events buffered before the overflow policy applies
+	BufferSize int
+	// Types limits delivery to these event types; empty means all
+	Types []EventType
+	// Overflow is the slow-consumer policy; defaults to OverflowDropNewest
+	Overflow OverflowPolicy
+}
+
+// Subscription receives events from a bus
+type Subscription struct {
+	name     string
+	types    map[EventType]bool
+	overflow OverflowPolicy
+	bus      *Bus
+
+	mu      sync.Mutex
+	ch      chan Event
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Events returns the channel events are delivered on; it is closed when the subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Name returns the subscriber name
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Dropped returns how many events were discarded because the subscriber fell behind
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes the events channel
+func (s *Subscription) Unsubscribe() {
+	s.bus.remove(s)
+	s.close()
+}
+
+// deliver hands an event to the subscriber without ever blocking the publisher.
+// It returns false once the subscription is closed.
+func (s *Subscription) deliver(event Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if len(s.types) > 0 && !s.types[event.Type] {
+		return true
+	}
+
+	select {
+	case s.ch <- event:
+		return true
+	default:
+	}
+
+	switch s.overflow {
+	case OverflowDropOldest:
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.ch <- event:
+		default:
+			s.dropped.Add(1)
+		}
+	case OverflowDisconnect:
+		s.dropped.Add(1)
+		s.closed = true
+		close(s.ch)
+	default:
+		s.dropped.Add(1)
+	}
+	return !s.closed
+}
+
+// close closes the events channel once
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// Bus fans events out to any number of subscribers.
+// Publishing never blocks: slow subscribers are handled by their overflow policy.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber
+func (b *Bus) Subscribe(opts SubscribeOptions) (*Subscription, error) {
+	if opts.BufferSize < 0 {
+		return nil, fmt.Errorf("buffer size cannot be negative")
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	switch opts.Overflow {
+	case "":
+		opts.Overflow = OverflowDropNewest
+	case OverflowDropNewest, OverflowDropOldest, OverflowDisconnect:
+	default:
+		return nil, fmt.Errorf("unknown overflow policy: %s", opts.Overflow)
+	}
+
+	sub := &Subscription{
+		name:     opts.Name,
+		overflow: opts.Overflow,
+		bus:      b,
+		ch:       make(chan Event, opts.BufferSize),
+	}
+	if len(opts.Types) > 0 {
+		sub.types = make(map[EventType]bool, len(opts.Types))
+		for _, t := range opts.Types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, fmt.Errorf("event bus is closed")
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub, nil
+}
+
+// Publish delivers an event to every matching subscriber, stamping it if needed
+func (b *Bus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	var disconnected []*Subscription
+	b.mu.RLock()
+	for sub := range b.subscribers {
+		if !sub.deliver(event) {
+			disconnected = append(disconnected, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range disconnected {
+		b.remove(sub)
+	}
+}
+
+// SubscriberCount returns the number of active subscribers
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Close ends all subscriptions; later publishes are ignored
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		sub.close()
+		delete(b.subscribers, sub)
+	}
+}
+
+// remove unregisters a subscription
+func (b *Bus) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, sub *Subscription, n int) []Event {
+	t.Helper()
+	var received []Event
+	for i := 0; i < n; i++ {
+		select {
+		case event, ok := <-sub.Events():
+			require.True(t, ok, "subscription closed early")
+			received = append(received, event)
+		default:
+			t.Fatalf("expected %d events, got %d", n, len(received))
+		}
+	}
+	return received
+}
+
+func TestBus_PublishToSubscribers(t *testing.T) {
+	bus := NewBus()
+	all, err := bus.Subscribe(SubscribeOptions{Name: "all"})
+	require.NoError(t, err)
+	steps, err := bus.Subscribe(SubscribeOptions{Name: "steps", Types: []EventType{EventStepCompleted}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, bus.SubscriberCount())
+
+	bus.Publish(Event{Type: EventTaskCreated, TaskID: "task-1"})
+	bus.Publish(Event{Type: EventStepCompleted, TaskID: "task-1", Step: "step-1"})
+
+	got := receive(t, all, 2)
+	assert.Equal(t, EventTaskCreated, got[0].Type)
+	assert.False(t, got[0].Timestamp.IsZero())
+
+	got = receive(t, steps, 1)
+	assert.Equal(t, "step-1", got[0].Step)
+	assert.Len(t, steps.Events(), 0)
+	assert.Equal(t, "steps", steps.Name())
+}
+
+func TestBus_SubscribeValidation(t *testing.T) {
+	bus := NewBus()
+
+	_, err := bus.Subscribe(SubscribeOptions{BufferSize: -1})
+	assert.Error(t, err)
+
+	_, err = bus.Subscribe(SubscribeOptions{Overflow: "explode"})
+	assert.Error(t, err)
+
+	bus.Close()
+	_, err = bus.Subscribe(SubscribeOptions{})
+	assert.EqualError(t, err, "event bus is closed")
+}
+
+func TestBus_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy      OverflowPolicy
+		wantSteps   []string
+		wantDropped uint64
+		wantClosed  bool
+	}{
+		{policy: OverflowDropNewest, wantSteps: []string{"1", "2"}, wantDropped: 2},
+		{policy: OverflowDropOldest, wantSteps: []string{"3", "4"}, wantDropped: 2},
+		{policy: OverflowDisconnect, wantSteps: []string{"1", "2"}, wantDropped: 1, wantClosed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			bus := NewBus()
+			sub, err := bus.Subscribe(SubscribeOptions{BufferSize: 2, Overflow: tt.policy})
+			require.NoError(t, err)
+
+			for _, step := range []string{"1", "2", "3", "4"} {
+				bus.Publish(Event{Type: EventStepCompleted, Step: step})
+			}
+
+			var steps []string
+			for event := range drain(sub) {
+				steps = append(steps, event.Step)
+			}
+			assert.Equal(t, tt.wantSteps, steps)
+			assert.Equal(t, tt.wantDropped, sub.Dropped())
+
+			if tt.wantClosed {
+				_, ok := <-sub.Events()
+				assert.False(t, ok)
+				assert.Equal(t, 0, bus.SubscriberCount())
+			} else {
+				assert.Equal(t, 1, bus.SubscriberCount())
+			}
+		})
+	}
+}
+
+// drain yields the currently buffered events without blocking
+func drain(sub *Subscription) chan Event {
+	out := make(chan Event, cap(sub.Events()))
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				close(out)
+				return out
+			}
+			out <- event
+		default:
+			close(out)
+			return out
+		}
+	}
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := NewBus()
+	sub, err := bus.Subscribe(SubscribeOptions{})
+	require.NoError(t, err)
+
+	sub.Unsubscribe()
+	sub.Unsubscribe() // idempotent
+	assert.Equal(t, 0, bus.SubscriberCount())
+
+	bus.Publish(Event{Type: EventTaskCreated})
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+}
+
+func TestBus_Close(t *testing.T) {
+	bus := NewBus()
+	sub, err := bus.Subscribe(SubscribeOptions{})
+	require.NoError(t, err)
+
+	bus.Close()
+	bus.Publish(Event{Type: EventTaskCreated})
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.Equal(t, 0, bus.SubscriberCount())
+}
+
+func TestBus_ConcurrentPublishAndSubscribe(t *testing.T) {
+	bus := NewBus()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bus.Publish(Event{Type: EventAgentMessage})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			sub, err := bus.Subscribe(SubscribeOptions{BufferSize: 8, Overflow: OverflowDropOldest})
+			if err != nil {
+				return
+			}
+			for j := 0; j < 5; j++ {
+				<-sub.Events()
+			}
+			sub.Unsubscribe()
+		}()
+	}
+
+	// Keep publishing until every subscriber has had its fill
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			bus.Close()
+			return
+		default:
+			bus.Publish(Event{Type: EventAgentMessage})
+		}
+	}
+}
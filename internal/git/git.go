@@ -0,0 +1,178 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when a directory is not inside a git working tree
+var ErrNotRepository = errors.New("not a git repository")
+
+// snapshotIdentity is the author and committer of snapshot commits, so snapshots work
+// without a configured git identity
+var snapshotIdentity = []string{
+	"GIT_AUTHOR_NAME=capn", "GIT_AUTHOR_EMAIL=capn@localhost",
+	"GIT_COMMITTER_NAME=capn", "GIT_COMMITTER_EMAIL=capn@localhost",
+}
+
+// Repo is a git working tree driven through the git command
+type Repo struct {
+	dir string
+}
+
+// Open returns the repository whose working tree contains dir
+func Open(ctx context.Context, dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+	out, err := (&Repo{dir: dir}).run(ctx, nil, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotRepository)
+	}
+	return &Repo{dir: filepath.Clean(strings.TrimSpace(out))}, nil
+}
+
+// Dir returns the top-level directory of the working tree
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// Snapshot records the working tree, including untracked files that are not ignored, as a
+// commit on top of HEAD and returns its hash. The index, HEAD and working tree are untouched.
+func (r *Repo) Snapshot(ctx context.Context, message string) (string, error) {
+	index, err := os.CreateTemp("", "capn-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+
+	// Starting from a copy of the real index keeps git's stat cache, so unchanged files are not
+	// rehashed; without one git starts from an empty index, which must not exist as an empty file
+	path, err := r.run(ctx, nil, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", fmt.Errorf("failed to locate index: %w", err)
+	}
+	if err := copyFile(r.abs(strings.TrimSpace(path)), index.Name()); errors.Is(err, os.ErrNotExist) {
+		os.Remove(index.Name())
+	} else if err != nil {
+		return "", fmt.Errorf("failed to copy index: %w", err)
+	}
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if _, err := r.run(ctx, env, nil, "add", "--all", "--", "."); err != nil {
+		return "", fmt.Errorf("failed to stage working tree: %w", err)
+	}
+	tree, err := r.run(ctx, env, nil, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %w", err)
+	}
+
+	args := []string{"commit-tree", strings.TrimSpace(tree), "-m", message}
+	if head, err := r.run(ctx, nil, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		args = append(args, "-p", strings.TrimSpace(head))
+	}
+	commit, err := r.run(ctx, snapshotIdentity, nil, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// SetRef points ref at commit, keeping the commit from being garbage collected
+func (r *Repo) SetRef(ctx context.Context, ref, commit string) error {
+	if _, err := r.run(ctx, nil, nil, "update-ref", ref, commit); err != nil {
+		return fmt.Errorf("failed to update %s: %w", ref, err)
+	}
+	return nil
+}
+
+// Diff returns the binary-safe patch that turns the tree of commit from into that of to
+func (r *Repo) Diff(ctx context.Context, from, to string) (string, error) {
+	out, err := r.run(ctx, nil, nil, "diff", "--binary", "--no-color", "--no-ext-diff", from, to)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s..%s: %w", short(from), short(to), err)
+	}
+	return out, nil
+}
+
+// ChangedFiles returns the paths that differ between the trees of two commits
+func (r *Repo) ChangedFiles(ctx context.Context, from, to string) ([]string, error) {
+	out, err := r.run(ctx, nil, nil, "diff", "--name-only", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes %s..%s: %w", short(from), short(to), err)
+	}
+	var files []string
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// ReverseApply undoes patch in the working tree. With check set nothing is changed; the
+// call only reports whether the patch can be undone cleanly.
+func (r *Repo) ReverseApply(ctx context.Context, patch string, check bool) error {
+	args := []string{"apply", "--reverse", "--binary"}
+	if check {
+		args = append(args, "--check")
+	}
+	if _, err := r.run(ctx, nil, strings.NewReader(patch), args...); err != nil {
+		return fmt.Errorf("failed to reverse changes: %w", err)
+	}
+	return nil
+}
+
+// run runs git in the working tree and returns its standard output; errors carry git's
+// standard error
+func (r *Repo) run(ctx context.Context, env []string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Stdin = stdin
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// abs resolves a path printed by git relative to the working tree
+func (r *Repo) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.dir, path)
+}
+
+// copyFile copies src to dst
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o600)
+}
+
+// short abbreviates a commit hash for messages
+func short(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
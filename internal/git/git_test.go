@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepo creates a repository with a committed README.md
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "README.md"), "hello\n")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "README.md"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	repo, err := Open(context.Background(), dir)
+	require.NoError(t, err)
+	return repo
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestOpen_NotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	_, err := Open(context.Background(), t.TempDir())
+	assert.True(t, errors.Is(err, ErrNotRepository))
+}
+
+func TestRepo_SnapshotDiffAndReverse(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	dir := repo.Dir()
+	writeFile(t, filepath.Join(dir, "notes.txt"), "untracked before\n")
+
+	before, err := repo.Snapshot(ctx, "before")
+	require.NoError(t, err)
+	require.NoError(t, repo.SetRef(ctx, "refs/capn/test/before", before))
+
+	// The snapshot leaves the index alone: the untracked file is still untracked
+	status := exec.Command("git", "status", "--porcelain")
+	status.Dir = dir
+	out, err := status.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "?? notes.txt\n", string(out))
+
+	writeFile(t, filepath.Join(dir, "README.md"), "hello\nworld\n")
+	writeFile(t, filepath.Join(dir, "src", "new file.go"), "package src\n")
+	require.NoError(t, os.Remove(filepath.Join(dir, "notes.txt")))
+
+	after, err := repo.Snapshot(ctx, "after")
+	require.NoError(t, err)
+
+	files, err := repo.ChangedFiles(ctx, before, after)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md", "notes.txt", "src/new file.go"}, files)
+
+	patch, err := repo.Diff(ctx, before, after)
+	require.NoError(t, err)
+	assert.Contains(t, patch, "+world")
+
+	require.NoError(t, repo.ReverseApply(ctx, patch, true))
+	assert.Equal(t, "hello\nworld\n", readFile(t, filepath.Join(dir, "README.md")), "check changes nothing")
+	require.NoError(t, repo.ReverseApply(ctx, patch, false))
+
+	assert.Equal(t, "hello\n", readFile(t, filepath.Join(dir, "README.md")))
+	assert.Equal(t, "untracked before\n", readFile(t, filepath.Join(dir, "notes.txt")))
+	assert.NoFileExists(t, filepath.Join(dir, "src", "new file.go"))
+}
+
+func TestRepo_ReverseApplyConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	readme := filepath.Join(repo.Dir(), "README.md")
+
+	before, err := repo.Snapshot(ctx, "before")
+	require.NoError(t, err)
+	writeFile(t, readme, "changed by task\n")
+	after, err := repo.Snapshot(ctx, "after")
+	require.NoError(t, err)
+	patch, err := repo.Diff(ctx, before, after)
+	require.NoError(t, err)
+
+	writeFile(t, readme, "changed again by hand\n")
+	err = repo.ReverseApply(ctx, patch, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to reverse changes")
+	assert.Equal(t, "changed again by hand\n", readFile(t, readme))
+}
+
+func TestRepo_SnapshotWithoutCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	require.NoError(t, cmd.Run())
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	repo, err := Open(context.Background(), dir)
+	require.NoError(t, err)
+	commit, err := repo.Snapshot(context.Background(), "snapshot")
+	require.NoError(t, err)
+	assert.Len(t, commit, 40)
+}
@@ -0,0 +1,263 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds how long a GitHub API request may take
+const requestTimeout = 15 * time.Second
+
+// maxComments bounds how many issue comments are fetched
+const maxComments = 100
+
+// maxErrorBody bounds how much of a failed response body is read for its error message
+const maxErrorBody = 4096
+
+var (
+	// issueRefPattern matches owner/repo#123
+	issueRefPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)#([0-9]+)$`)
+	// issueURLPattern matches https://github.com/owner/repo/issues/123
+	issueURLPattern = regexp.MustCompile(`^https?://[^/]+/([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)/issues/([0-9]+)/?$`)
+)
+
+// IssueRef identifies a GitHub issue
+type IssueRef struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// ParseIssueRef parses an issue given as owner/repo#123 or as its web URL
+func ParseIssueRef(s string) (IssueRef, error) {
+	s = strings.TrimSpace(s)
+	match := issueRefPattern.FindStringSubmatch(s)
+	if match == nil {
+		match = issueURLPattern.FindStringSubmatch(s)
+	}
+	if match == nil {
+		return IssueRef{}, fmt.Errorf("invalid issue %q (expected owner/repo#number)", s)
+	}
+	number, err := strconv.Atoi(match[3])
+	if err != nil || number <= 0 {
+		return IssueRef{}, fmt.Errorf("invalid issue number in %q", s)
+	}
+	return IssueRef{Owner: match[1], Repo: match[2], Number: number}, nil
+}
+
+// String returns the reference as owner/repo#number
+func (r IssueRef) String() string {
+	return fmt.Sprintf("%s/%s#%d", r.Owner, r.Repo, r.Number)
+}
+
+// Issue is a GitHub issue with its discussion
+type Issue struct {
+	Ref      IssueRef
+	Title    string
+	Body     string
+	State    string
+	Author   string
+	URL      string
+	Labels   []string
+	Comments []Comment
+}
+
+// Comment is a comment on an issue
+type Comment struct {
+	Author    string
+	Body      string
+	CreatedAt time.Time
+}
+
+// Background renders the issue and its comments as context for planning the issue
+func (i *Issue) Background() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "GitHub issue %s: %s\n", i.Ref, i.Title)
+	if i.URL != "" {
+		fmt.Fprintf(&b, "URL: %s\n", i.URL)
+	}
+	if i.Author != "" {
+		fmt.Fprintf(&b, "Opened by: %s\n", i.Author)
+	}
+	if len(i.Labels) > 0 {
+		fmt.Fprintf(&b, "Labels: %s\n", strings.Join(i.Labels, ", "))
+	}
+	if body := strings.TrimSpace(i.Body); body != "" {
+		fmt.Fprintf(&b, "\n%s\n", body)
+	}
+	if len(i.Comments) > 0 {
+		b.WriteString("\nComments:\n")
+		for _, comment := range i.Comments {
+			fmt.Fprintf(&b, "\n%s wrote:\n%s\n", comment.Author, strings.TrimSpace(comment.Body))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Client talks to the GitHub REST API
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewClient creates a client for the API at baseURL, authenticating with token when it is set
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// SetHTTPClient sets the HTTP client requests are sent with
+func (c *Client) SetHTTPClient(client *http.Client) {
+	if client != nil {
+		c.client = client
+	}
+}
+
+// user is the author of an issue or comment in API responses
+type user struct {
+	Login string `json:"login"`
+}
+
+// Issue fetches an issue with its comments
+func (c *Client) Issue(ctx context.Context, ref IssueRef) (*Issue, error) {
+	var issue struct {
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		State   string `json:"state"`
+		HTMLURL string `json:"html_url"`
+		User    user   `json:"user"`
+		Labels  []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		PullRequest *struct{} `json:"pull_request"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.issuePath(ref), nil, &issue); err != nil {
+		return nil, fmt.Errorf("failed to fetch issue %s: %w", ref, err)
+	}
+	if issue.PullRequest != nil {
+		return nil, fmt.Errorf("%s is a pull request, not an issue", ref)
+	}
+
+	var comments []struct {
+		Body      string    `json:"body"`
+		User      user      `json:"user"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	path := c.issuePath(ref) + "/comments?per_page=" + strconv.Itoa(maxComments)
+	if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+		return nil, fmt.Errorf("failed to fetch comments of issue %s: %w", ref, err)
+	}
+
+	result := &Issue{
+		Ref:    ref,
+		Title:  issue.Title,
+		Body:   issue.Body,
+		State:  issue.State,
+		Author: issue.User.Login,
+		URL:    issue.HTMLURL,
+	}
+	for _, label := range issue.Labels {
+		result.Labels = append(result.Labels, label.Name)
+	}
+	for _, comment := range comments {
+		result.Comments = append(result.Comments, Comment{Author: comment.User.Login, Body: comment.Body, CreatedAt: comment.CreatedAt})
+	}
+	return result, nil
+}
+
+// Comment posts a comment on an issue and returns its URL
+func (c *Client) Comment(ctx context.Context, ref IssueRef, body string) (string, error) {
+	var comment struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.issuePath(ref)+"/comments", map[string]string{"body": body}, &comment); err != nil {
+		return "", fmt.Errorf("failed to comment on issue %s: %w", ref, err)
+	}
+	return comment.HTMLURL, nil
+}
+
+// Release is a published release of a repository
+type Release struct {
+	Tag         string
+	Name        string
+	URL         string
+	PublishedAt time.Time
+}
+
+// LatestRelease fetches a repository's latest published release, ignoring drafts and prereleases
+func (c *Client) LatestRelease(ctx context.Context, owner, repo string) (*Release, error) {
+	var release struct {
+		TagName     string    `json:"tag_name"`
+		Name        string    `json:"name"`
+		HTMLURL     string    `json:"html_url"`
+		PublishedAt time.Time `json:"published_at"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/releases/latest", url.PathEscape(owner), url.PathEscape(repo))
+	if err := c.do(ctx, http.MethodGet, path, nil, &release); err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest release of %s/%s: %w", owner, repo, err)
+	}
+	return &Release{Tag: release.TagName, Name: release.Name, URL: release.HTMLURL, PublishedAt: release.PublishedAt}, nil
+}
+
+// issuePath returns the API path of an issue
+func (c *Client) issuePath(ref IssueRef) string {
+	return fmt.Sprintf("/repos/%s/%s/issues/%d", url.PathEscape(ref.Owner), url.PathEscape(ref.Repo), ref.Number)
+}
+
+// do sends a request to the API, encoding body as JSON when set, and decodes the response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(detail, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("GitHub returned %s: %s", resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("GitHub returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueRef(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    IssueRef
+		str     string
+		wantErr string
+	}{
+		{input: "iainlowe/capn#123", want: IssueRef{Owner: "iainlowe", Repo: "capn", Number: 123}, str: "iainlowe/capn#123"},
+		{input: " my-org/my.repo#7 ", want: IssueRef{Owner: "my-org", Repo: "my.repo", Number: 7}, str: "my-org/my.repo#7"},
+		{input: "https://github.com/iainlowe/capn/issues/42", want: IssueRef{Owner: "iainlowe", Repo: "capn", Number: 42}, str: "iainlowe/capn#42"},
+		{input: "iainlowe/capn", wantErr: `invalid issue "iainlowe/capn" (expected owner/repo#number)`},
+		{input: "capn#12", wantErr: "invalid issue"},
+		{input: "iainlowe/capn#0", wantErr: `invalid issue number in "iainlowe/capn#0"`},
+		{input: "https://github.com/iainlowe/capn/pull/42", wantErr: "invalid issue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ref, err := ParseIssueRef(tt.input)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+			assert.Equal(t, tt.str, ref.String())
+		})
+	}
+}
+
+// fakeGitHub serves one issue and records comments posted to it
+func fakeGitHub(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var posted []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/api/issues/12", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer ghp_test", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"title": "Health check times out", "body": "The /health endpoint hangs under load.",
+			"state": "open", "html_url": "https://github.com/acme/api/issues/12", "user": {"login": "sam"},
+			"labels": [{"name": "bug"}, {"name": "ops"}]}`))
+	})
+	mux.HandleFunc("GET /repos/acme/api/issues/12/comments", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		w.Write([]byte(`[{"body": "Seeing it on staging too.", "user": {"login": "alex"}, "created_at": "2026-10-01T10:00:00Z"}]`))
+	})
+	mux.HandleFunc("POST /repos/acme/api/issues/12/comments", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Body string `json:"body"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		posted = append(posted, body.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/acme/api/issues/12#issuecomment-1"}`))
+	})
+	mux.HandleFunc("GET /repos/acme/api/issues/13", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"title": "Add retries", "pull_request": {}}`))
+	})
+	mux.HandleFunc("GET /repos/acme/api/issues/404", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	})
+	mux.HandleFunc("GET /repos/acme/api/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.2.0", "name": "capn 1.2.0", "html_url": "https://github.com/acme/api/releases/tag/v1.2.0",
+			"published_at": "2026-10-01T10:00:00Z"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &posted
+}
+
+func TestClient_Issue(t *testing.T) {
+	server, _ := fakeGitHub(t)
+	client := NewClient(server.URL+"/", "ghp_test")
+
+	issue, err := client.Issue(context.Background(), IssueRef{Owner: "acme", Repo: "api", Number: 12})
+	require.NoError(t, err)
+	assert.Equal(t, "Health check times out", issue.Title)
+	assert.Equal(t, "sam", issue.Author)
+	assert.Equal(t, []string{"bug", "ops"}, issue.Labels)
+	require.Len(t, issue.Comments, 1)
+	assert.Equal(t, "alex", issue.Comments[0].Author)
+
+	assert.Equal(t, `GitHub issue acme/api#12: Health check times out
+URL: https://github.com/acme/api/issues/12
+Opened by: sam
+Labels: bug, ops
+
+The /health endpoint hangs under load.
+
+Comments:
+
+alex wrote:
+Seeing it on staging too.`, issue.Background())
+
+	_, err = client.Issue(context.Background(), IssueRef{Owner: "acme", Repo: "api", Number: 13})
+	assert.EqualError(t, err, "acme/api#13 is a pull request, not an issue")
+
+	_, err = client.Issue(context.Background(), IssueRef{Owner: "acme", Repo: "api", Number: 404})
+	assert.EqualError(t, err, "failed to fetch issue acme/api#404: GitHub returned 404 Not Found: Not Found")
+}
+
+func TestClient_Comment(t *testing.T) {
+	server, posted := fakeGitHub(t)
+	client := NewClient(server.URL, "ghp_test")
+
+	url, err := client.Comment(context.Background(), IssueRef{Owner: "acme", Repo: "api", Number: 12}, "Planned as task-1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/acme/api/issues/12#issuecomment-1", url)
+	assert.Equal(t, []string{"Planned as task-1"}, *posted)
+}
+
+func TestClient_LatestRelease(t *testing.T) {
+	server, _ := fakeGitHub(t)
+	client := NewClient(server.URL, "")
+
+	release, err := client.LatestRelease(context.Background(), "acme", "api")
+	require.NoError(t, err)
+	assert.Equal(t, &Release{
+		Tag:         "v1.2.0",
+		Name:        "capn 1.2.0",
+		URL:         "https://github.com/acme/api/releases/tag/v1.2.0",
+		PublishedAt: time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+	}, release)
+
+	_, err = client.LatestRelease(context.Background(), "acme", "missing")
+	assert.ErrorContains(t, err, "failed to fetch the latest release of acme/missing: GitHub returned 404")
+}
@@ -0,0 +1,160 @@
+// Package hooks runs user scripts on task lifecycle events, for local integrations such as
+// desktop notifications or custom dashboards. Each script receives the event as JSON on stdin.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+const (
+	// EventTaskStart is sent when a task's plan starts executing
+	EventTaskStart = "task_start"
+	// EventStepComplete is sent after each plan step has run, whether or not it succeeded
+	EventStepComplete = "step_complete"
+	// EventTaskFailed is sent when a task finishes as failed
+	EventTaskFailed = "task_failed"
+)
+
+// maxLoggedOutput caps how much of a failing hook's output is logged
+const maxLoggedOutput = 2048
+
+// Event is the payload a hook reads from stdin
+type Event struct {
+	Event     string    `json:"event"`
+	TaskID    string    `json:"task_id"`
+	Goal      string    `json:"goal"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Workspace string    `json:"workspace,omitempty"`
+	Step      *Step     `json:"step,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Step describes the finished plan step of a step_complete event
+type Step struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	Success  bool          `json:"success"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// NewEvent describes an event on the task as it now stands
+func NewEvent(event string, t *task.TaskExecution) Event {
+	return Event{
+		Event:     event,
+		TaskID:    t.ID,
+		Goal:      t.Goal,
+		Status:    string(t.Status),
+		Error:     t.Error,
+		Tags:      t.Tags,
+		Workspace: t.Workspace,
+		Timestamp: time.Now(),
+	}
+}
+
+// Runner runs the configured hooks for events
+type Runner struct {
+	config config.HooksConfig
+	shell  agents.Shell
+	logger *zap.Logger
+}
+
+// NewRunner creates a runner for the configured hooks, running them through the platform's shell
+func NewRunner(cfg config.HooksConfig, logger *zap.Logger) *Runner {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Runner{config: cfg, shell: agents.DefaultShell(), logger: logger}
+}
+
+// hooksFor returns the hooks configured for an event
+func (r *Runner) hooksFor(event string) []config.HookConfig {
+	switch event {
+	case EventTaskStart:
+		return r.config.OnTaskStart
+	case EventStepComplete:
+		return r.config.OnStepComplete
+	case EventTaskFailed:
+		return r.config.OnTaskFailed
+	default:
+		return nil
+	}
+}
+
+// Run runs the event's hooks one after another. Hooks that fail or time out are logged and
+// reported together in the returned error; they do not stop the hooks after them.
+func (r *Runner) Run(ctx context.Context, event Event) error {
+	hooks := r.hooksFor(event.Event)
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Event, err)
+	}
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := r.run(ctx, hook, event, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run runs one hook with the event on stdin, killing it when its timeout passes
+func (r *Runner) run(ctx context.Context, hook config.HookConfig, event Event, payload []byte) error {
+	timeout := r.config.TimeoutFor(hook)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := r.shell.Command(ctx, hook.Command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), "CAPN_EVENT="+event.Event, "CAPN_TASK_ID="+event.TaskID)
+	// Background processes the hook leaves holding its output must not outlive the timeout
+	cmd.WaitDelay = time.Second
+
+	started := time.Now()
+	err := cmd.Run()
+	fields := []zap.Field{
+		zap.String("event", event.Event),
+		zap.String("task_id", event.TaskID),
+		zap.String("command", hook.Command),
+		zap.Duration("duration", time.Since(started)),
+	}
+	if err == nil {
+		r.logger.Debug("Hook finished", append(fields, zap.String("output", strings.TrimSpace(output.String())))...)
+		return nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("hook %q timed out after %s", hook.Command, timeout)
+	} else {
+		err = fmt.Errorf("hook %q failed: %w", hook.Command, err)
+	}
+	logged := strings.TrimSpace(output.String())
+	if len(logged) > maxLoggedOutput {
+		logged = logged[:maxLoggedOutput] + "..."
+	}
+	r.logger.Warn("Hook failed", append(fields, zap.String("output", logged), zap.Error(err))...)
+	return err
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func TestRunner_PassesEventOnStdin(t *testing.T) {
+	dir := t.TempDir()
+	payload := filepath.Join(dir, "event.json")
+	env := filepath.Join(dir, "env")
+	runner := NewRunner(config.HooksConfig{
+		OnStepComplete: []config.HookConfig{
+			{Command: "cat > " + payload},
+			{Command: `echo "$CAPN_EVENT $CAPN_TASK_ID" > ` + env},
+		},
+	}, nil)
+
+	record := task.NewTaskExecution("refactor the parser")
+	record.SetStatus(task.TaskStatusRunning)
+	event := NewEvent(EventStepComplete, record)
+	event.Step = &Step{ID: "task-1", Type: "code", Success: false, Error: "tests failed", Duration: time.Second}
+	require.NoError(t, runner.Run(context.Background(), event))
+
+	data, err := os.ReadFile(payload)
+	require.NoError(t, err)
+	var got Event
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, EventStepComplete, got.Event)
+	assert.Equal(t, record.ID, got.TaskID)
+	assert.Equal(t, "refactor the parser", got.Goal)
+	assert.Equal(t, "running", got.Status)
+	require.NotNil(t, got.Step)
+	assert.Equal(t, "task-1", got.Step.ID)
+	assert.Equal(t, "tests failed", got.Step.Error)
+
+	data, err = os.ReadFile(env)
+	require.NoError(t, err)
+	assert.Equal(t, "step_complete "+record.ID+"\n", string(data))
+}
+
+func TestRunner_OnlyRunsTheEventsHooks(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	runner := NewRunner(config.HooksConfig{OnTaskFailed: []config.HookConfig{{Command: "touch " + marker}}}, nil)
+	record := task.NewTaskExecution("goal")
+
+	require.NoError(t, runner.Run(context.Background(), NewEvent(EventTaskStart, record)))
+	assert.NoFileExists(t, marker)
+	require.NoError(t, runner.Run(context.Background(), NewEvent(EventTaskFailed, record)))
+	assert.FileExists(t, marker)
+}
+
+func TestRunner_FailuresAreLoggedAndDoNotStopLaterHooks(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	marker := filepath.Join(t.TempDir(), "ran")
+	runner := NewRunner(config.HooksConfig{
+		OnTaskStart: []config.HookConfig{
+			{Command: "echo broken >&2; exit 3"},
+			{Command: "sleep 5", Timeout: 50 * time.Millisecond},
+			{Command: "touch " + marker},
+		},
+	}, zap.New(core))
+
+	started := time.Now()
+	err := runner.Run(context.Background(), NewEvent(EventTaskStart, task.NewTaskExecution("goal")))
+	assert.ErrorContains(t, err, `hook "echo broken >&2; exit 3" failed: exit status 3`)
+	assert.ErrorContains(t, err, `hook "sleep 5" timed out after 50ms`)
+	assert.Less(t, time.Since(started), 3*time.Second, "the timeout kills the hook")
+	assert.FileExists(t, marker)
+
+	require.Equal(t, 2, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "task_start", fields["event"])
+	assert.Equal(t, "broken", fields["output"])
+}
@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// channel is a configured channel and the notifier delivering to it
+type channel struct {
+	config   config.NotificationChannelConfig
+	notifier Notifier
+}
+
+// spool holds the items waiting for a channel's next digest. It is kept on disk so the
+// separate processes running tasks can contribute to the same digest.
+type spool struct {
+	Since time.Time `json:"since"`
+	Items []Item    `json:"items"`
+}
+
+// Dispatcher sends finished tasks to the channels that want them. Channels with a digest
+// window collect tasks in a spool until Flush finds the window has passed.
+type Dispatcher struct {
+	channels   []channel
+	spoolDir   string
+	summarizer Summarizer
+	clock      common.Clock
+	mu         sync.Mutex
+}
+
+// NewDispatcher creates a dispatcher for the configured channels, spooling digests in spoolDir
+func NewDispatcher(cfg config.NotificationsConfig, spoolDir string) (*Dispatcher, error) {
+	d := &Dispatcher{spoolDir: spoolDir, clock: common.SystemClock}
+	for _, c := range cfg.Channels {
+		notifier, err := newNotifier(c)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", c.Name, err)
+		}
+		d.channels = append(d.channels, channel{config: c, notifier: notifier})
+	}
+	return d, nil
+}
+
+// newNotifier creates the notifier for a channel's type
+func newNotifier(c config.NotificationChannelConfig) (Notifier, error) {
+	switch c.Type {
+	case config.NotificationChannelWebhook:
+		return NewWebhookNotifier(c.URL), nil
+	case config.NotificationChannelEmail:
+		return NewEmailNotifier(c.Email)
+	}
+	return nil, fmt.Errorf("unsupported channel type %q", c.Type)
+}
+
+// SetSummarizer sets the summarizer used for channels that ask for digest summaries
+func (d *Dispatcher) SetSummarizer(summarizer Summarizer) {
+	d.summarizer = summarizer
+}
+
+// Empty reports whether no channels are configured
+func (d *Dispatcher) Empty() bool {
+	return len(d.channels) == 0
+}
+
+// TaskFinished notifies channels of a finished task, or adds it to their next digest
+func (d *Dispatcher) TaskFinished(ctx context.Context, t *task.TaskExecution) error {
+	if !t.Status.IsTerminal() {
+		return nil
+	}
+	item := NewItem(t)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs []error
+	for _, c := range d.channels {
+		if !c.config.Notifies(item.Status) {
+			continue
+		}
+		if c.config.Digest.Window > 0 {
+			if err := d.addToSpool(c.config.Name, item); err != nil {
+				errs = append(errs, fmt.Errorf("channel %s: %w", c.config.Name, err))
+			}
+			continue
+		}
+		notification := taskNotification(c.config.Name, item)
+		notification.Record = t
+		if t.Report != nil {
+			notification.Summary = t.Report.Text()
+		}
+		if err := c.notifier.Notify(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", c.config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush sends the digests whose window has passed, or every pending digest when force is set
+func (d *Dispatcher) Flush(ctx context.Context, force bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs []error
+	for _, c := range d.channels {
+		if c.config.Digest.Window <= 0 {
+			continue
+		}
+		if err := d.flushChannel(ctx, c, force); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", c.config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run flushes due digests every interval until the context is cancelled, reporting
+// delivery errors to onError
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := d.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := d.Flush(ctx, false); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) flushChannel(ctx context.Context, c channel, force bool) error {
+	pending, err := d.readSpool(c.config.Name)
+	if err != nil || len(pending.Items) == 0 {
+		return err
+	}
+	window := c.config.Digest.Window
+	if !force && d.clock.Now().Before(pending.Since.Add(window)) {
+		return nil
+	}
+
+	notification := digestNotification(c.config.Name, pending.Items, window)
+	if c.config.Digest.Summarize && d.summarizer != nil {
+		// A digest without its summary is better than no digest
+		if summary, err := d.summarizer(ctx, notification.Title+"\n\n"+notification.Text); err == nil {
+			notification.Summary = summary
+		}
+	}
+	if err := c.notifier.Notify(ctx, notification); err != nil {
+		return err
+	}
+	if err := os.Remove(d.spoolPath(c.config.Name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear digest spool: %w", err)
+	}
+	return nil
+}
+
+func (d *Dispatcher) spoolPath(name string) string {
+	return filepath.Join(d.spoolDir, name+".json")
+}
+
+func (d *Dispatcher) readSpool(name string) (spool, error) {
+	var pending spool
+	data, err := os.ReadFile(d.spoolPath(name))
+	if os.IsNotExist(err) {
+		return pending, nil
+	}
+	if err != nil {
+		return pending, fmt.Errorf("failed to read digest spool: %w", err)
+	}
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return pending, fmt.Errorf("failed to parse digest spool: %w", err)
+	}
+	return pending, nil
+}
+
+func (d *Dispatcher) addToSpool(name string, item Item) error {
+	pending, err := d.readSpool(name)
+	if err != nil {
+		return err
+	}
+	if len(pending.Items) == 0 {
+		pending.Since = d.clock.Now()
+	}
+	pending.Items = append(pending.Items, item)
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to encode digest spool: %w", err)
+	}
+	if err := os.MkdirAll(d.spoolDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create digest spool directory: %w", err)
+	}
+	// Replace the spool atomically so a concurrent reader never sees a partial file
+	tmp := d.spoolPath(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write digest spool: %w", err)
+	}
+	if err := os.Rename(tmp, d.spoolPath(name)); err != nil {
+		return fmt.Errorf("failed to write digest spool: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the number of tasks waiting in each digest channel's spool
+func (d *Dispatcher) Pending() (map[string]int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := make(map[string]int)
+	for _, c := range d.channels {
+		if c.config.Digest.Window <= 0 {
+			continue
+		}
+		s, err := d.readSpool(c.config.Name)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", c.config.Name, err)
+		}
+		pending[c.config.Name] = len(s.Items)
+	}
+	return pending, nil
+}
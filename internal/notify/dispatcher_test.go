@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// webhookRecorder is a webhook endpoint collecting the notifications posted to it
+type webhookRecorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []Notification
+}
+
+func newWebhookRecorder(t *testing.T) *webhookRecorder {
+	r := &webhookRecorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var n Notification
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&n))
+		r.mu.Lock()
+		r.received = append(r.received, n)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookRecorder) notifications() []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Notification(nil), r.received...)
+}
+
+// finishedTask returns a task that ended with status
+func finishedTask(id, goal string, status task.TaskStatus) *task.TaskExecution {
+	t := task.NewTaskExecution(goal)
+	t.ID = id
+	t.SetStatus(status)
+	if status == task.TaskStatusFailed {
+		t.Error = "exit status 1"
+	}
+	return t
+}
+
+func TestDispatcher_Immediate(t *testing.T) {
+	hook := newWebhookRecorder(t)
+	d, err := NewDispatcher(config.NotificationsConfig{Channels: []config.NotificationChannelConfig{
+		{Name: "all", Type: config.NotificationChannelWebhook, URL: hook.URL, On: []string{"completed", "failed", "cancelled"}},
+		{Name: "failures", Type: config.NotificationChannelWebhook, URL: hook.URL, On: []string{"failed"}},
+	}}, t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	running := task.NewTaskExecution("still going")
+	running.SetStatus(task.TaskStatusRunning)
+	require.NoError(t, d.TaskFinished(ctx, running))
+	assert.Empty(t, hook.notifications(), "unfinished tasks are not notified")
+
+	require.NoError(t, d.TaskFinished(ctx, finishedTask("task-1", "build", task.TaskStatusCompleted)))
+	require.NoError(t, d.TaskFinished(ctx, finishedTask("task-2", "deploy", task.TaskStatusFailed)))
+
+	received := hook.notifications()
+	require.Len(t, received, 3)
+	assert.Equal(t, "all", received[0].Channel)
+	assert.Equal(t, "Task task-1 completed", received[0].Title)
+	assert.Equal(t, "✗ task-2 deploy: exit status 1", received[2].Text)
+	assert.False(t, received[2].Digest)
+}
+
+func TestDispatcher_ImmediateReport(t *testing.T) {
+	hook := newWebhookRecorder(t)
+	d, err := NewDispatcher(config.NotificationsConfig{Channels: []config.NotificationChannelConfig{
+		{Name: "all", Type: config.NotificationChannelWebhook, URL: hook.URL, On: []string{"completed"}},
+	}}, t.TempDir())
+	require.NoError(t, err)
+
+	finished := finishedTask("task-1", "build", task.TaskStatusCompleted)
+	finished.Report = &captain.OutcomeReport{Achieved: "The binaries were built.", FollowUps: []string{"Tag the release"}}
+	require.NoError(t, d.TaskFinished(context.Background(), finished))
+
+	received := hook.notifications()
+	require.Len(t, received, 1)
+	assert.Equal(t, "The binaries were built.\n\nFollow-up:\n- Tag the release", received[0].Summary)
+}
+
+func TestDispatcher_Digest(t *testing.T) {
+	hook := newWebhookRecorder(t)
+	dir := t.TempDir()
+	channels := config.NotificationsConfig{Channels: []config.NotificationChannelConfig{{
+		Name:   "digest",
+		Type:   config.NotificationChannelWebhook,
+		URL:    hook.URL,
+		Digest: config.DigestConfig{Window: 15 * time.Minute, Summarize: true},
+	}}}
+	d, err := NewDispatcher(channels, dir)
+	require.NoError(t, err)
+	clock := testutil.NewFakeClock(time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC))
+	d.clock = clock
+	var summarized string
+	d.SetSummarizer(func(ctx context.Context, digest string) (string, error) {
+		summarized = digest
+		return "One build passed and one deploy failed.", nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, d.TaskFinished(ctx, finishedTask("task-1", "build", task.TaskStatusCompleted)))
+	// A separate process shares the spool
+	other, err := NewDispatcher(channels, dir)
+	require.NoError(t, err)
+	require.NoError(t, other.TaskFinished(ctx, finishedTask("task-2", "deploy", task.TaskStatusFailed)))
+
+	pending, err := d.Pending()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"digest": 2}, pending)
+
+	clock.Advance(10 * time.Minute)
+	require.NoError(t, d.Flush(ctx, false))
+	assert.Empty(t, hook.notifications(), "the window has not passed")
+
+	clock.Advance(5 * time.Minute)
+	require.NoError(t, d.Flush(ctx, false))
+	received := hook.notifications()
+	require.Len(t, received, 1)
+	assert.True(t, received[0].Digest)
+	assert.Equal(t, "capn digest: 1 completed, 1 failed in the last 15m0s", received[0].Title)
+	assert.Equal(t, "✓ task-1 build\n✗ task-2 deploy: exit status 1", received[0].Text)
+	assert.Equal(t, "One build passed and one deploy failed.", received[0].Summary)
+	assert.Contains(t, summarized, "task-2 deploy")
+	assert.Len(t, received[0].Tasks, 2)
+
+	pending, err = d.Pending()
+	require.NoError(t, err)
+	assert.Equal(t, 0, pending["digest"])
+	require.NoError(t, d.Flush(ctx, true))
+	assert.Len(t, hook.notifications(), 1, "empty digests are not sent")
+}
+
+func TestDispatcher_FlushForce(t *testing.T) {
+	hook := newWebhookRecorder(t)
+	d, err := NewDispatcher(config.NotificationsConfig{Channels: []config.NotificationChannelConfig{{
+		Name:   "digest",
+		Type:   config.NotificationChannelWebhook,
+		URL:    hook.URL,
+		Digest: config.DigestConfig{Window: time.Hour, Summarize: true},
+	}}}, t.TempDir())
+	require.NoError(t, err)
+	d.SetSummarizer(func(ctx context.Context, digest string) (string, error) {
+		return "", fmt.Errorf("provider unavailable")
+	})
+
+	ctx := context.Background()
+	require.NoError(t, d.TaskFinished(ctx, finishedTask("task-1", "build", task.TaskStatusCompleted)))
+	require.NoError(t, d.Flush(ctx, true))
+
+	received := hook.notifications()
+	require.Len(t, received, 1, "a failed summary does not hold back the digest")
+	assert.Empty(t, received[0].Summary)
+}
+
+func TestDispatcher_KeepsSpoolWhenDeliveryFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	d, err := NewDispatcher(config.NotificationsConfig{Channels: []config.NotificationChannelConfig{{
+		Name:   "digest",
+		Type:   config.NotificationChannelWebhook,
+		URL:    server.URL,
+		Digest: config.DigestConfig{Window: time.Minute},
+	}}}, t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, d.TaskFinished(ctx, finishedTask("task-1", "build", task.TaskStatusCompleted)))
+	err = d.Flush(ctx, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "channel digest: webhook returned 503")
+
+	pending, err := d.Pending()
+	require.NoError(t, err)
+	assert.Equal(t, 1, pending["digest"], "undelivered tasks are kept for the next flush")
+}
@@ -0,0 +1,388 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+const (
+	// emailTimeout bounds how long delivering one email may take
+	emailTimeout = 30 * time.Second
+	// maxReportOutput is the number of characters of each step's output shown in a report
+	maxReportOutput = 4000
+	// smtpsPort is the port where SMTP servers expect TLS from the start rather than STARTTLS
+	smtpsPort = 465
+)
+
+//go:embed email.html
+var defaultEmailTemplate string
+
+// templateFuncs are available to email report and subject templates
+var templateFuncs = map[string]any{
+	"duration": func(d time.Duration) string { return d.Round(time.Second).String() },
+	"join":     strings.Join,
+	"inc":      func(i int) int { return i + 1 },
+}
+
+// Report is the data email report and subject templates are executed with. Task, Steps and
+// the artifacts are only set for a single finished task, not for digests.
+type Report struct {
+	Notification
+	Task          *task.TaskExecution
+	Steps         []ReportStep
+	Duration      time.Duration
+	EstimatedCost float64
+	TokensUsed    int
+	// Attached are the artifacts attached to the email; Omitted were too large or unreadable
+	Attached []task.Artifact
+	Omitted  []task.Artifact
+}
+
+// ReportStep is one plan step and its outcome in a report
+type ReportStep struct {
+	ID          string
+	Type        string
+	Description string
+	// Status is completed, failed or "not run"
+	Status   string
+	Duration time.Duration
+	Output   string
+	Error    string
+}
+
+// attachment is an artifact read to be attached to an email
+type attachment struct {
+	name string
+	data []byte
+}
+
+// EmailNotifier sends notifications as HTML reports over SMTP, attaching a finished task's
+// small artifacts
+type EmailNotifier struct {
+	config  config.EmailConfig
+	report  *template.Template
+	subject *texttemplate.Template
+	clock   common.Clock
+}
+
+// NewEmailNotifier creates a notifier sending with the channel's email settings, loading its
+// custom report and subject templates
+func NewEmailNotifier(cfg config.EmailConfig) (*EmailNotifier, error) {
+	source := defaultEmailTemplate
+	if cfg.Template != "" {
+		data, err := os.ReadFile(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read email template: %w", err)
+		}
+		source = string(data)
+	}
+	report, err := template.New("report").Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email template: %w", err)
+	}
+
+	e := &EmailNotifier{config: cfg, report: report, clock: common.SystemClock}
+	if cfg.Subject != "" {
+		if e.subject, err = texttemplate.New("subject").Funcs(templateFuncs).Parse(cfg.Subject); err != nil {
+			return nil, fmt.Errorf("failed to parse email subject: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// Notify renders the notification's report and mails it to the channel's recipients
+func (e *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	report, attachments := e.buildReport(notification)
+	msg, err := e.message(report, attachments)
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, msg)
+}
+
+// buildReport gathers the template data for a notification, reading the artifacts that fit
+// within the attachment limits
+func (e *EmailNotifier) buildReport(notification Notification) (Report, []attachment) {
+	report := Report{Notification: notification, Task: notification.Record}
+	record := notification.Record
+	if record == nil {
+		return report, nil
+	}
+	report.Duration = record.Duration()
+	report.Steps = reportSteps(record)
+	if record.Plan != nil {
+		report.EstimatedCost = record.Plan.Resources.EstimatedCost
+	}
+	for _, result := range record.Results {
+		report.TokensUsed += tokensUsed(result.Metadata["tokens_used"])
+	}
+
+	each, total := e.config.AttachmentLimits()
+	var attachments []attachment
+	var used int64
+	for _, artifact := range record.Artifacts {
+		if artifact.Size > each || used+artifact.Size > total {
+			report.Omitted = append(report.Omitted, artifact)
+			continue
+		}
+		data, err := os.ReadFile(artifact.Path)
+		if err != nil || int64(len(data)) > each || used+int64(len(data)) > total {
+			report.Omitted = append(report.Omitted, artifact)
+			continue
+		}
+		used += int64(len(data))
+		attachments = append(attachments, attachment{name: artifact.Name, data: data})
+		report.Attached = append(report.Attached, artifact)
+	}
+	return report, attachments
+}
+
+// reportSteps pairs the task's plan steps with their results, in plan order
+func reportSteps(record *task.TaskExecution) []ReportStep {
+	results := make(map[string]int, len(record.Results))
+	for i, result := range record.Results {
+		results[result.TaskID] = i
+	}
+	var steps []ReportStep
+	if record.Plan != nil {
+		for _, planned := range record.Plan.Tasks {
+			step := ReportStep{ID: planned.ID, Type: string(planned.Type), Description: planned.ID, Status: "not run"}
+			if description, ok := planned.Payload["description"].(string); ok && description != "" {
+				step.Description = description
+			}
+			if i, ok := results[planned.ID]; ok {
+				step.withResult(record.Results[i])
+				delete(results, planned.ID)
+			}
+			steps = append(steps, step)
+		}
+	}
+	// Results outside the plan, such as those of a task without one, follow the plan's steps
+	for _, result := range record.Results {
+		if _, ok := results[result.TaskID]; ok {
+			step := ReportStep{ID: result.TaskID, Description: result.TaskID}
+			step.withResult(result)
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// withResult records a step's outcome
+func (s *ReportStep) withResult(result captain.Result) {
+	s.Status = string(task.TaskStatusCompleted)
+	if !result.Success {
+		s.Status = string(task.TaskStatusFailed)
+	}
+	s.Duration = result.Duration
+	s.Output = truncateOutput(strings.TrimSpace(result.Output))
+	s.Error = result.Error
+}
+
+// truncateOutput shortens a step's output for a report, keeping whole characters
+func truncateOutput(output string) string {
+	runes := []rune(output)
+	if len(runes) <= maxReportOutput {
+		return output
+	}
+	return string(runes[:maxReportOutput]) + "\n…"
+}
+
+// tokensUsed reads a result's token count, which is a float after a round trip through JSON
+func tokensUsed(value any) int {
+	switch n := value.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+// message renders the report into a MIME message with an HTML body, a plain text alternative
+// and the attachments
+func (e *EmailNotifier) message(report Report, attachments []attachment) ([]byte, error) {
+	var html bytes.Buffer
+	if err := e.report.Execute(&html, report); err != nil {
+		return nil, fmt.Errorf("failed to render email report: %w", err)
+	}
+	subject := "[capn] " + report.Title
+	if e.subject != nil {
+		var b strings.Builder
+		if err := e.subject.Execute(&b, report); err != nil {
+			return nil, fmt.Errorf("failed to render email subject: %w", err)
+		}
+		subject = strings.Join(strings.Fields(b.String()), " ")
+	}
+	text := report.Title + "\n\n" + report.Text
+	if report.Summary != "" {
+		text += "\n\n" + report.Summary
+	}
+
+	var msg bytes.Buffer
+	mixed := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.clock.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	if err := writeTextPart(alternative, "text/plain; charset=utf-8", text); err != nil {
+		return nil, err
+	}
+	if err := writeTextPart(alternative, "text/html; charset=utf-8", html.String()); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	if _, err := part.Write(body.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+
+	for _, a := range attachments {
+		if err := writeAttachment(mixed, a); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	return msg.Bytes(), nil
+}
+
+// writeTextPart adds a quoted-printable text part
+func writeTextPart(w *multipart.Writer, contentType, text string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+	return qp.Close()
+}
+
+// writeAttachment adds a base64-encoded attachment, wrapping lines at 76 characters
+func writeAttachment(w *multipart.Writer, a attachment) error {
+	// Extension types may carry parameters, such as a charset, that the name joins
+	contentType, params, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(a.name)))
+	if err != nil {
+		contentType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = a.name
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, params)},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.name})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach %s: %w", a.name, err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(a.data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := io.WriteString(part, encoded[:n]+"\r\n"); err != nil {
+			return fmt.Errorf("failed to attach %s: %w", a.name, err)
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// send delivers a message over SMTP, upgrading the connection with STARTTLS when the server
+// offers it and authenticating when a username is configured
+func (e *EmailNotifier) send(ctx context.Context, msg []byte) error {
+	from, err := mail.ParseAddress(e.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid email from address: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.config.Addr())
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if e.config.Port == smtpsPort {
+		conn = tls.Client(conn, &tls.Config{ServerName: e.config.Host})
+	}
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && e.config.Port != smtpsPort {
+		if err := client.StartTLS(&tls.Config{ServerName: e.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if e.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	for _, to := range e.config.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid email to address: %w", err)
+		}
+		if err := client.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("failed to send email to %s: %w", addr.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
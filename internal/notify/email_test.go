@@ -0,0 +1,294 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// smtpRecorder is an SMTP server accepting every message and recording what it was sent
+type smtpRecorder struct {
+	listener   net.Listener
+	mu         sync.Mutex
+	recipients []string
+	messages   []string
+}
+
+func newSMTPRecorder(t *testing.T) *smtpRecorder {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	r := &smtpRecorder{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *smtpRecorder) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			r.mu.Lock()
+			r.recipients = append(r.recipients, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			r.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			r.mu.Lock()
+			r.messages = append(r.messages, data.String())
+			r.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (r *smtpRecorder) port() int {
+	return r.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (r *smtpRecorder) sent() ([]string, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.recipients...), append([]string(nil), r.messages...)
+}
+
+// parsedEmail is a sent message split into its bodies and attachments
+type parsedEmail struct {
+	header      mail.Header
+	subject     string
+	text        string
+	html        string
+	attachments map[string]string
+}
+
+func parseEmail(t *testing.T, raw string) parsedEmail {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	parsed := parsedEmail{header: msg.Header, subject: subject, attachments: make(map[string]string)}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mixed.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		mediaType, partParams, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		if mediaType != "multipart/alternative" {
+			assert.Equal(t, "base64", part.Header.Get("Content-Transfer-Encoding"))
+			data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+			require.NoError(t, err)
+			parsed.attachments[part.FileName()] = string(data)
+			continue
+		}
+		alternative := multipart.NewReader(part, partParams["boundary"])
+		for {
+			body, err := alternative.NextPart()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(quotedprintable.NewReader(body))
+			require.NoError(t, err)
+			if strings.HasPrefix(body.Header.Get("Content-Type"), "text/html") {
+				parsed.html = string(data)
+			} else {
+				parsed.text = string(data)
+			}
+		}
+	}
+	return parsed
+}
+
+// reportedTask returns a failed task partway through its plan, with a small and a large artifact
+func reportedTask(t *testing.T) *task.TaskExecution {
+	t.Helper()
+	store, err := task.NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+	record := finishedTask("task-1", "fix the health check", task.TaskStatusFailed)
+	record.StartedAt = record.CompletedAt.Add(-90 * time.Second)
+	record.Plan = &captain.ExecutionPlan{
+		Goal: record.Goal,
+		Tasks: []captain.Task{
+			{ID: "step-1", Type: "analysis", Payload: map[string]any{"description": "Find the failing check"}},
+			{ID: "step-2", Type: "execution", Payload: map[string]any{"description": "Add a timeout"}},
+			{ID: "step-3", Type: "execution", Payload: map[string]any{"description": "Deploy <the fix>"}},
+		},
+		Resources: captain.ResourceAllocation{EstimatedCost: 0.42},
+	}
+	record.Results = []captain.Result{
+		{TaskID: "step-1", Success: true, Output: "the check has no timeout", Duration: 2 * time.Second, Metadata: map[string]any{"tokens_used": 120}},
+		{TaskID: "step-2", Success: false, Error: "exit status 1", Duration: time.Second, Metadata: map[string]any{"tokens_used": float64(30)}},
+	}
+	for _, artifact := range []agents.Artifact{
+		{Name: "findings.md", Content: []byte("# Findings\n")},
+		{Name: "trace.log", Content: []byte(strings.Repeat("x", 2048))},
+	} {
+		saved, err := store.Save(record.ID, "step-1", "analyst", artifact)
+		require.NoError(t, err)
+		record.Artifacts = append(record.Artifacts, saved)
+	}
+	return record
+}
+
+func emailChannel(recorder *smtpRecorder) config.EmailConfig {
+	return config.EmailConfig{
+		Host:              "127.0.0.1",
+		Port:              recorder.port(),
+		From:              "capn <capn@example.com>",
+		To:                []string{"ops@example.com", "Sam <sam@example.com>"},
+		MaxAttachmentSize: 1024,
+	}
+}
+
+func TestEmailNotifier_Report(t *testing.T) {
+	recorder := newSMTPRecorder(t)
+	record := reportedTask(t)
+	d, err := NewDispatcher(config.NotificationsConfig{Channels: []config.NotificationChannelConfig{
+		{Name: "reports", Type: config.NotificationChannelEmail, Email: emailChannel(recorder)},
+	}}, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, d.TaskFinished(context.Background(), record))
+
+	recipients, messages := recorder.sent()
+	assert.Equal(t, []string{"ops@example.com", "sam@example.com"}, recipients)
+	require.Len(t, messages, 1)
+	email := parseEmail(t, messages[0])
+	assert.Equal(t, "[capn] Task task-1 failed", email.subject)
+	assert.Equal(t, "capn <capn@example.com>", email.header.Get("From"))
+	assert.Equal(t, "ops@example.com, Sam <sam@example.com>", email.header.Get("To"))
+	assert.Contains(t, email.text, "✗ task-1 fix the health check (1m30s): exit status 1")
+
+	assert.Contains(t, email.html, "<strong>failed</strong>")
+	assert.Contains(t, email.html, "1m30s")
+	assert.Contains(t, email.html, "$0.42")
+	assert.Contains(t, email.html, "<td>150</td>", "tokens are summed across steps")
+	assert.Contains(t, email.html, "1. Find the failing check")
+	assert.Contains(t, email.html, "the check has no timeout")
+	assert.Contains(t, email.html, "[execution] failed in 1s")
+	assert.Contains(t, email.html, "3. Deploy &lt;the fix&gt;</strong> <span style=\"color: #555;\">[execution] not run")
+	assert.Contains(t, email.html, "findings.md (11 bytes, attached)")
+	assert.Contains(t, email.html, "trace.log (2048 bytes, not attached)")
+
+	assert.Equal(t, map[string]string{"findings.md": "# Findings\n"}, email.attachments)
+}
+
+func TestEmailNotifier_CustomTemplates(t *testing.T) {
+	recorder := newSMTPRecorder(t)
+	path := filepath.Join(t.TempDir(), "report.html")
+	require.NoError(t, os.WriteFile(path, []byte(`<p>{{.Task.Goal}}: {{len .Steps}} steps, {{len .Attached}} attached</p>`), 0o644))
+	settings := emailChannel(recorder)
+	settings.Template = path
+	settings.Subject = "{{.Task.Status}}: {{.Task.Goal}}"
+	settings.MaxAttachmentSize = 4096
+	settings.MaxAttachmentsSize = 100
+
+	notifier, err := NewEmailNotifier(settings)
+	require.NoError(t, err)
+	record := reportedTask(t)
+	notification := taskNotification("reports", NewItem(record))
+	notification.Record = record
+	require.NoError(t, notifier.Notify(context.Background(), notification))
+
+	_, messages := recorder.sent()
+	require.Len(t, messages, 1)
+	email := parseEmail(t, messages[0])
+	assert.Equal(t, "failed: fix the health check", email.subject)
+	assert.Equal(t, "<p>fix the health check: 3 steps, 1 attached</p>", email.html, "the total limit leaves out the second artifact")
+}
+
+func TestEmailNotifier_Digest(t *testing.T) {
+	recorder := newSMTPRecorder(t)
+	notifier, err := NewEmailNotifier(emailChannel(recorder))
+	require.NoError(t, err)
+	items := []Item{
+		NewItem(finishedTask("task-1", "deploy", task.TaskStatusCompleted)),
+		NewItem(finishedTask("task-2", "migrate", task.TaskStatusFailed)),
+	}
+	notification := digestNotification("reports", items, time.Hour)
+	notification.Summary = "One deploy went out and a migration failed."
+	require.NoError(t, notifier.Notify(context.Background(), notification))
+
+	_, messages := recorder.sent()
+	require.Len(t, messages, 1)
+	email := parseEmail(t, messages[0])
+	assert.Equal(t, "[capn] capn digest: 1 completed, 1 failed in the last 1h0m0s", email.subject)
+	assert.Contains(t, email.html, "<strong>task-2</strong> failed: migrate")
+	assert.Contains(t, email.html, "One deploy went out and a migration failed.")
+	assert.Contains(t, email.text, "One deploy went out and a migration failed.")
+	assert.Empty(t, email.attachments)
+}
+
+func TestNewEmailNotifier_Errors(t *testing.T) {
+	_, err := NewEmailNotifier(config.EmailConfig{Template: filepath.Join(t.TempDir(), "missing.html")})
+	assert.ErrorContains(t, err, "failed to read email template")
+
+	_, err = NewEmailNotifier(config.EmailConfig{Subject: "{{.Task"})
+	assert.ErrorContains(t, err, "failed to parse email subject")
+
+	notifier, err := NewEmailNotifier(config.EmailConfig{Host: "127.0.0.1", Port: 1, From: "capn@example.com", To: []string{"ops@example.com"}})
+	require.NoError(t, err)
+	err = notifier.Notify(context.Background(), Notification{Title: "Task task-1 completed"})
+	assert.ErrorContains(t, err, "failed to connect to SMTP server")
+}
@@ -0,0 +1,117 @@
+// Package notify tells configured channels about finished tasks, either as each task
+// finishes or batched into periodic digests.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// Item describes a finished task in a notification
+type Item struct {
+	TaskID     string        `json:"task_id"`
+	Goal       string        `json:"goal"`
+	Status     string        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Tags       []string      `json:"tags,omitempty"`
+	Workspace  string        `json:"workspace,omitempty"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// NewItem describes a finished task
+func NewItem(t *task.TaskExecution) Item {
+	finished := t.CompletedAt
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+	return Item{
+		TaskID:     t.ID,
+		Goal:       t.Goal,
+		Status:     string(t.Status),
+		Error:      t.Error,
+		Duration:   t.Duration(),
+		Tags:       t.Tags,
+		Workspace:  t.Workspace,
+		FinishedAt: finished,
+	}
+}
+
+// Notification is a message for a channel about one or more finished tasks
+type Notification struct {
+	Channel string `json:"channel"`
+	Title   string `json:"title"`
+	Text    string `json:"text"`
+	// Summary is an LLM-written summary of a digest, when the channel asks for one, or the
+	// outcome report of a single task
+	Summary string `json:"summary,omitempty"`
+	Digest  bool   `json:"digest"`
+	Tasks   []Item `json:"tasks"`
+	// Record is the finished task of a single-task notification, for channels sending full reports
+	Record *task.TaskExecution `json:"-"`
+}
+
+// Notifier delivers notifications to a channel
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Summarizer writes a one-paragraph summary of a digest's text
+type Summarizer func(ctx context.Context, digest string) (string, error)
+
+// taskNotification builds the notification for a single finished task
+func taskNotification(channel string, item Item) Notification {
+	return Notification{
+		Channel: channel,
+		Title:   fmt.Sprintf("Task %s %s", item.TaskID, item.Status),
+		Text:    itemLine(item),
+		Tasks:   []Item{item},
+	}
+}
+
+// digestNotification builds the notification batching the items collected over a window
+func digestNotification(channel string, items []Item, window time.Duration) Notification {
+	counts := make(map[string]int)
+	var lines []string
+	for _, item := range items {
+		counts[item.Status]++
+		lines = append(lines, itemLine(item))
+	}
+
+	var parts []string
+	for _, status := range []string{"completed", "failed", "cancelled"} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	return Notification{
+		Channel: channel,
+		Title:   fmt.Sprintf("capn digest: %s in the last %s", strings.Join(parts, ", "), window),
+		Text:    strings.Join(lines, "\n"),
+		Digest:  true,
+		Tasks:   items,
+	}
+}
+
+// itemLine renders one finished task on a line
+func itemLine(item Item) string {
+	marker := "✓"
+	if item.Status != string(task.TaskStatusCompleted) {
+		marker = "✗"
+	}
+	line := fmt.Sprintf("%s %s %s", marker, item.TaskID, item.Goal)
+	if item.Duration > 0 {
+		line += fmt.Sprintf(" (%s)", item.Duration.Round(time.Second))
+	}
+	if len(item.Tags) > 0 {
+		line += " [" + strings.Join(item.Tags, ", ") + "]"
+	}
+	if item.Error != "" {
+		line += ": " + item.Error
+	}
+	return line
+}
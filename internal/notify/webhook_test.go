@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	var got Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notification := taskNotification("ops", Item{TaskID: "task-1", Goal: "deploy", Status: "completed"})
+	require.NoError(t, NewWebhookNotifier(server.URL).Notify(context.Background(), notification))
+	assert.Equal(t, "ops", got.Channel)
+	assert.Equal(t, "Task task-1 completed", got.Title)
+	require.Len(t, got.Tasks, 1)
+	assert.Equal(t, "deploy", got.Tasks[0].Goal)
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL).Notify(context.Background(), Notification{Title: "x"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "webhook returned 502 Bad Gateway")
+}
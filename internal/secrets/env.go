@@ -0,0 +1,46 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix is prepended to secret keys to form environment variable names
+const EnvPrefix = "CAPN_SECRET_"
+
+// EnvProvider reads secrets from environment variables such as CAPN_SECRET_OPENAI_API_KEY
+type EnvProvider struct{}
+
+// NewEnvProvider creates an environment variable provider
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+// EnvVar returns the environment variable that holds key
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// Name returns the provider name
+func (p *EnvProvider) Name() string {
+	return ProviderEnv
+}
+
+// Get reads the secret from its environment variable
+func (p *EnvProvider) Get(key string) (string, error) {
+	if value, ok := os.LookupEnv(EnvVar(key)); ok && value != "" {
+		return value, nil
+	}
+	return "", ErrNotFound
+}
+
+// Set always fails: environment variables must be exported by the caller
+func (p *EnvProvider) Set(key, value string) error {
+	return fmt.Errorf("%w: export %s instead", ErrReadOnly, EnvVar(key))
+}
+
+// Delete always fails: environment variables must be unset by the caller
+func (p *EnvProvider) Delete(key string) error {
+	return fmt.Errorf("%w: unset %s instead", ErrReadOnly, EnvVar(key))
+}
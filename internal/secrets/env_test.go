@@ -0,0 +1,30 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvVar(t *testing.T) {
+	assert.Equal(t, "CAPN_SECRET_OPENAI_API_KEY", EnvVar("openai.api_key"))
+	assert.Equal(t, "CAPN_SECRET_GITHUB_TOKEN", EnvVar("github-token"))
+}
+
+func TestEnvProvider(t *testing.T) {
+	provider := NewEnvProvider()
+
+	_, err := provider.Get("openai.api_key")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	t.Setenv("CAPN_SECRET_OPENAI_API_KEY", "sk-env")
+	value, err := provider.Get("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-env", value)
+
+	err = provider.Set("openai.api_key", "sk-new")
+	assert.True(t, errors.Is(err, ErrReadOnly))
+	assert.Contains(t, err.Error(), "export CAPN_SECRET_OPENAI_API_KEY")
+}
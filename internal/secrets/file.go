@@ -0,0 +1,271 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase the secrets file is
+// encrypted with
+const PassphraseEnv = "CAPN_SECRETS_PASSPHRASE"
+
+const (
+	// fileMagic starts secrets files whose key is derived from the passphrase
+	fileMagic = "CAPNSEC1"
+	// kdfIterations is how many PBKDF2-HMAC-SHA256 rounds new secrets files use
+	kdfIterations = 600_000
+	// saltSize is the length of the random salt stored in the file header
+	saltSize = 16
+	// headerSize is the length of the magic, iteration count and salt
+	headerSize = len(fileMagic) + 4 + saltSize
+)
+
+// FileProvider stores secrets in an AES-256-GCM encrypted file. The key is derived from
+// the passphrase in CAPN_SECRETS_PASSPHRASE, so the file alone does not reveal them.
+type FileProvider struct {
+	path string
+	// legacyKeyPath is where earlier versions kept a generated key; files encrypted with it
+	// are still read and are re-encrypted with the passphrase on the next change
+	legacyKeyPath string
+	mu            sync.Mutex
+	// derived caches the last key derived, keyed by the passphrase and file header
+	derived struct {
+		passphrase string
+		header     []byte
+		key        []byte
+	}
+}
+
+// NewFileProvider creates a file provider storing secrets.enc in dir
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{
+		path:          filepath.Join(dir, "secrets.enc"),
+		legacyKeyPath: filepath.Join(dir, "secrets.key"),
+	}
+}
+
+// Name returns the provider name
+func (p *FileProvider) Name() string {
+	return ProviderFile
+}
+
+// Available reports whether a passphrase is set to encrypt the file with
+func (p *FileProvider) Available() bool {
+	return os.Getenv(PassphraseEnv) != ""
+}
+
+// Get reads the secret from the encrypted file
+func (p *FileProvider) Get(key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	secrets, err := p.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// Set stores the secret in the encrypted file
+func (p *FileProvider) Set(key, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	secrets, err := p.load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = value
+	return p.save(secrets)
+}
+
+// Delete removes the secret from the encrypted file
+func (p *FileProvider) Delete(key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	secrets, err := p.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, key)
+	return p.save(secrets)
+}
+
+// Keys returns the stored secret keys in sorted order
+func (p *FileProvider) Keys() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	secrets, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// load decrypts the secrets file; a missing file holds no secrets
+func (p *FileProvider) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var gcm cipher.AEAD
+	var header []byte
+	if bytes.HasPrefix(data, []byte(fileMagic)) {
+		if len(data) < headerSize {
+			return nil, fmt.Errorf("secrets file %s is corrupt", p.path)
+		}
+		header, data = data[:headerSize], data[headerSize:]
+		if gcm, err = p.cipher(header); err != nil {
+			return nil, err
+		}
+	} else if gcm, err = p.legacyCipher(); err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secrets file %s is corrupt", p.path)
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], header)
+	if err != nil {
+		if header != nil {
+			return nil, fmt.Errorf("failed to decrypt secrets file %s: wrong %s?", p.path, PassphraseEnv)
+		}
+		return nil, fmt.Errorf("failed to decrypt secrets file %s: %w", p.path, err)
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets file: %w", err)
+	}
+	return secrets, nil
+}
+
+// save encrypts and atomically writes the secrets file with a key derived from the
+// passphrase, removing a legacy key file once nothing needs it
+func (p *FileProvider) save(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+	header := p.derived.header
+	if header == nil || p.derived.passphrase != os.Getenv(PassphraseEnv) {
+		header = make([]byte, headerSize)
+		copy(header, fileMagic)
+		binary.BigEndian.PutUint32(header[len(fileMagic):], kdfIterations)
+		if _, err := io.ReadFull(rand.Reader, header[len(fileMagic)+4:]); err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	gcm, err := p.cipher(header)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	data := append(append(bytes.Clone(header), nonce...), gcm.Seal(nil, nonce, plain, header)...)
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := os.Remove(p.legacyKeyPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove legacy secrets key: %w", err)
+	}
+	return nil
+}
+
+// cipher derives the encryption key for a file header from the passphrase
+func (p *FileProvider) cipher(header []byte) (cipher.AEAD, error) {
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("%w: set %s to use the encrypted secrets file", ErrUnavailable, PassphraseEnv)
+	}
+	if p.derived.passphrase != passphrase || !bytes.Equal(p.derived.header, header) {
+		iterations := binary.BigEndian.Uint32(header[len(fileMagic):])
+		// A damaged or hostile header must not make every read spin for minutes
+		if iterations == 0 || iterations > 16*kdfIterations {
+			return nil, fmt.Errorf("secrets file %s is corrupt", p.path)
+		}
+		p.derived.passphrase = passphrase
+		p.derived.header = bytes.Clone(header)
+		p.derived.key = deriveKey([]byte(passphrase), header[len(fileMagic)+4:], int(iterations))
+	}
+	return newGCM(p.derived.key)
+}
+
+// legacyCipher loads the key earlier versions generated beside the secrets file
+func (p *FileProvider) legacyCipher() (cipher.AEAD, error) {
+	key, err := os.ReadFile(p.legacyKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets key %s: %w", p.legacyKeyPath, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key %s is invalid", p.legacyKeyPath)
+	}
+	return newGCM(key)
+}
+
+// newGCM creates an AES-256-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives a 32-byte key from a passphrase with PBKDF2-HMAC-SHA256 (RFC 8018),
+// which needs only the one output block
+func deriveKey(passphrase, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, passphrase)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	key := bytes.Clone(u)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProvider_RoundTrip(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	dir := t.TempDir()
+	provider := NewFileProvider(dir)
+
+	_, err := provider.Get("openai.api_key")
+	assert.True(t, errors.Is(err, ErrNotFound), "a missing file holds no secrets")
+
+	require.NoError(t, provider.Set("openai.api_key", "sk-file"))
+	require.NoError(t, provider.Set("github.token", "ghp-file"))
+
+	value, err := NewFileProvider(dir).Get("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-file", value)
+
+	keys, err := provider.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.token", "openai.api_key"}, keys)
+
+	require.NoError(t, provider.Delete("github.token"))
+	assert.True(t, errors.Is(provider.Delete("github.token"), ErrNotFound))
+}
+
+func TestFileProvider_Encrypted(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	dir := t.TempDir()
+	provider := NewFileProvider(dir)
+	require.NoError(t, provider.Set("openai.api_key", "sk-very-secret"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-very-secret")
+	assert.NotContains(t, string(data), "openai.api_key")
+	assert.NoFileExists(t, filepath.Join(dir, "secrets.key"), "no key is stored beside the secrets")
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "secrets.enc"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+}
+
+func TestFileProvider_Passphrase(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(PassphraseEnv, "correct horse")
+	require.NoError(t, NewFileProvider(dir).Set("openai.api_key", "sk-file"))
+
+	t.Setenv(PassphraseEnv, "battery staple")
+	_, err := NewFileProvider(dir).Get("openai.api_key")
+	assert.EqualError(t, err, "failed to decrypt secrets file "+filepath.Join(dir, "secrets.enc")+": wrong CAPN_SECRETS_PASSPHRASE?")
+
+	t.Setenv(PassphraseEnv, "")
+	provider := NewFileProvider(dir)
+	assert.False(t, provider.Available())
+	_, err = provider.Get("openai.api_key")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, provider.Set("github.token", "ghp-file"), ErrUnavailable)
+}
+
+func TestFileProvider_Iterations(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(PassphraseEnv, "correct horse")
+	require.NoError(t, NewFileProvider(dir).Set("openai.api_key", "sk-file"))
+	path := filepath.Join(dir, "secrets.enc")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Headers asking for absurd key derivation work are refused before deriving anything
+	for _, iterations := range []uint32{0, 16*kdfIterations + 1, math.MaxUint32} {
+		binary.BigEndian.PutUint32(data[len(fileMagic):], iterations)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		_, err = NewFileProvider(dir).Get("openai.api_key")
+		assert.EqualError(t, err, "secrets file "+path+" is corrupt", "iterations %d", iterations)
+	}
+}
+
+func TestFileProvider_LegacyKey(t *testing.T) {
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secrets.key"), key, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secrets.enc"), gcm.Seal(nonce, nonce, []byte(`{"openai.api_key":"sk-old"}`), nil), 0o600))
+
+	// Files from before the passphrase are still read, and re-encrypted on the next change
+	t.Setenv(PassphraseEnv, "correct horse")
+	provider := NewFileProvider(dir)
+	value, err := provider.Get("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-old", value)
+
+	require.NoError(t, provider.Set("github.token", "ghp-file"))
+	assert.NoFileExists(t, filepath.Join(dir, "secrets.key"))
+	value, err = NewFileProvider(dir).Get("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-old", value)
+}
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 7914 section 11 and a vector computed with Python's hashlib.pbkdf2_hmac
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc",
+		hex.EncodeToString(deriveKey([]byte("passwd"), []byte("salt"), 1)))
+	assert.Equal(t, "2f8eee9d4baecf9dd91a3bf2c5d1bd9fb7e6d3bd702d1d5ac00adc1ff57af238",
+		hex.EncodeToString(deriveKey([]byte("correct horse"), []byte("0123456789abcdef"), 4096)))
+}
@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeychainService is the service name secrets are filed under in the OS keychain
+const KeychainService = "capn"
+
+// commandRunner runs a command with the given stdin and returns its trimmed stdout
+type commandRunner func(stdin, name string, args ...string) (string, error)
+
+// KeychainProvider stores secrets in the macOS Keychain or a freedesktop secret service
+type KeychainProvider struct {
+	goos     string
+	run      commandRunner
+	lookPath func(string) (string, error)
+}
+
+// NewKeychainProvider creates a keychain provider for the current platform
+func NewKeychainProvider() *KeychainProvider {
+	return &KeychainProvider{
+		goos:     runtime.GOOS,
+		run:      runCommand,
+		lookPath: exec.LookPath,
+	}
+}
+
+// Name returns the provider name
+func (p *KeychainProvider) Name() string {
+	return ProviderKeychain
+}
+
+// Available reports whether a keychain tool exists on this system
+func (p *KeychainProvider) Available() bool {
+	tool := p.tool()
+	if tool == "" {
+		return false
+	}
+	_, err := p.lookPath(tool)
+	return err == nil
+}
+
+// tool returns the keychain command line tool for the platform
+func (p *KeychainProvider) tool() string {
+	switch p.goos {
+	case "darwin":
+		return "security"
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return "secret-tool"
+	default:
+		return ""
+	}
+}
+
+// unavailable explains why the keychain cannot be used
+func (p *KeychainProvider) unavailable() error {
+	if p.tool() == "" {
+		return fmt.Errorf("%w: no OS keychain support on %s", ErrUnavailable, p.goos)
+	}
+	return fmt.Errorf("%w: %s not found in PATH", ErrUnavailable, p.tool())
+}
+
+// Get reads the secret from the keychain
+func (p *KeychainProvider) Get(key string) (string, error) {
+	if !p.Available() {
+		return "", p.unavailable()
+	}
+
+	var value string
+	var err error
+	if p.goos == "darwin" {
+		value, err = p.run("", "security", "find-generic-password", "-s", KeychainService, "-a", key, "-w")
+	} else {
+		value, err = p.run("", "secret-tool", "lookup", "service", KeychainService, "account", key)
+	}
+	// Both tools exit non-zero when the item does not exist
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) || err == nil && value == "" {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// Set stores the secret in the keychain, replacing any existing value
+func (p *KeychainProvider) Set(key, value string) error {
+	if !p.Available() {
+		return p.unavailable()
+	}
+
+	var err error
+	if p.goos == "darwin" {
+		// With -w last, security prompts for the value and its confirmation on stdin, keeping
+		// it out of the argument list other processes can read
+		_, err = p.run(value+"\n"+value+"\n", "security", "add-generic-password", "-U", "-s", KeychainService, "-a", key, "-w")
+	} else {
+		_, err = p.run(value, "secret-tool", "store", "--label", KeychainService+" "+key, "service", KeychainService, "account", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store secret %s in keychain: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the secret from the keychain
+func (p *KeychainProvider) Delete(key string) error {
+	if !p.Available() {
+		return p.unavailable()
+	}
+
+	var err error
+	if p.goos == "darwin" {
+		_, err = p.run("", "security", "delete-generic-password", "-s", KeychainService, "-a", key)
+	} else {
+		_, err = p.run("", "secret-tool", "clear", "service", KeychainService, "account", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete secret %s from keychain: %w", key, err)
+	}
+	return nil
+}
+
+// runCommand runs a command, feeding stdin and returning trimmed stdout
+func runCommand(stdin, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	return strings.TrimSpace(stdout.String()), err
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeychain records keychain tool invocations and serves values from memory
+type fakeKeychain struct {
+	calls  []string
+	stdin  []string
+	values map[string]string
+}
+
+func (f *fakeKeychain) run(stdin, name string, args ...string) (string, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+	f.stdin = append(f.stdin, stdin)
+	account := args[len(args)-1]
+	if args[len(args)-1] == "-w" {
+		account = args[len(args)-2]
+	}
+	switch args[0] {
+	case "find-generic-password", "lookup":
+		if value, ok := f.values[account]; ok {
+			return value, nil
+		}
+		return "", &exec.ExitError{}
+	}
+	return "", nil
+}
+
+func newFakeKeychain(goos string, fake *fakeKeychain) *KeychainProvider {
+	return &KeychainProvider{
+		goos:     goos,
+		run:      fake.run,
+		lookPath: func(file string) (string, error) { return "/usr/bin/" + file, nil },
+	}
+}
+
+func TestKeychainProvider_MacOS(t *testing.T) {
+	fake := &fakeKeychain{values: map[string]string{"openai.api_key": "sk-mac"}}
+	provider := newFakeKeychain("darwin", fake)
+
+	value, err := provider.Get("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-mac", value)
+
+	_, err = provider.Get("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	require.NoError(t, provider.Set("openai.api_key", "sk-new"))
+	require.NoError(t, provider.Delete("openai.api_key"))
+	assert.Equal(t, []string{
+		"security find-generic-password -s capn -a openai.api_key -w",
+		"security find-generic-password -s capn -a missing -w",
+		"security add-generic-password -U -s capn -a openai.api_key -w",
+		"security delete-generic-password -s capn -a openai.api_key",
+	}, fake.calls)
+	assert.Equal(t, "sk-new\nsk-new\n", fake.stdin[2], "the value is not passed as an argument")
+}
+
+func TestKeychainProvider_SecretService(t *testing.T) {
+	fake := &fakeKeychain{values: map[string]string{"openai.api_key": "sk-linux"}}
+	provider := newFakeKeychain("linux", fake)
+
+	value, err := provider.Get("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-linux", value)
+
+	require.NoError(t, provider.Set("openai.api_key", "sk-new"))
+	assert.Equal(t, "secret-tool store --label capn openai.api_key service capn account openai.api_key", fake.calls[1])
+	assert.Equal(t, "sk-new", fake.stdin[1], "the value is passed on stdin, not the command line")
+}
+
+func TestKeychainProvider_Unavailable(t *testing.T) {
+	provider := &KeychainProvider{goos: "windows", run: runCommand, lookPath: exec.LookPath}
+	assert.False(t, provider.Available())
+
+	_, err := provider.Get("openai.api_key")
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.Contains(t, err.Error(), "no OS keychain support on windows")
+
+	missingTool := &KeychainProvider{goos: "linux", run: runCommand, lookPath: func(string) (string, error) {
+		return "", errors.New("not found")
+	}}
+	err = missingTool.Set("openai.api_key", "x")
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.Contains(t, err.Error(), "secret-tool not found in PATH")
+}
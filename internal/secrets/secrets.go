@@ -0,0 +1,120 @@
+// Package secrets resolves credentials from environment variables, the OS keychain
+// or an encrypted file so they do not have to live in plaintext configuration.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when a provider has no value for a key
+	ErrNotFound = errors.New("secret not found")
+	// ErrReadOnly is returned when a provider cannot store values
+	ErrReadOnly = errors.New("secret provider is read-only")
+	// ErrUnavailable is returned when a provider cannot be used on this system
+	ErrUnavailable = errors.New("secret provider is unavailable")
+)
+
+// Provider names
+const (
+	ProviderEnv      = "env"
+	ProviderKeychain = "keychain"
+	ProviderFile     = "file"
+)
+
+// DefaultProviders is the lookup order used when none is configured
+var DefaultProviders = []string{ProviderEnv, ProviderKeychain, ProviderFile}
+
+// Provider stores and retrieves secret values by key
+type Provider interface {
+	Name() string
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ValidateKey checks that a secret key is a dotted lowercase name such as openai.api_key
+func ValidateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid secret key %q: use lowercase letters, digits, '.', '_' and '-'", key)
+	}
+	return nil
+}
+
+// NewProvider creates the named provider; dir is the capn data directory used by the file provider
+func NewProvider(name, dir string) (Provider, error) {
+	switch name {
+	case ProviderEnv:
+		return NewEnvProvider(), nil
+	case ProviderKeychain:
+		return NewKeychainProvider(), nil
+	case ProviderFile:
+		return NewFileProvider(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown secret provider: %s", name)
+	}
+}
+
+// Chain looks secrets up in several providers in order
+type Chain []Provider
+
+// NewChain creates a chain of the named providers
+func NewChain(names []string, dir string) (Chain, error) {
+	chain := make(Chain, 0, len(names))
+	for _, name := range names {
+		provider, err := NewProvider(strings.TrimSpace(name), dir)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, provider)
+	}
+	return chain, nil
+}
+
+// Resolve returns the first value found for key and the name of the provider holding it.
+// Providers that are unavailable on this system are skipped.
+func (c Chain) Resolve(key string) (string, string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", "", err
+	}
+	for _, provider := range c {
+		value, err := provider.Get(key)
+		switch {
+		case err == nil:
+			return value, provider.Name(), nil
+		case errors.Is(err, ErrNotFound), errors.Is(err, ErrUnavailable):
+			continue
+		default:
+			return "", "", fmt.Errorf("failed to read secret %s from %s: %w", key, provider.Name(), err)
+		}
+	}
+	return "", "", fmt.Errorf("%w: %s (checked %s)", ErrNotFound, key, strings.Join(c.Names(), ", "))
+}
+
+// Names returns the provider names in lookup order
+func (c Chain) Names() []string {
+	names := make([]string, len(c))
+	for i, provider := range c {
+		names[i] = provider.Name()
+	}
+	return names
+}
+
+// Writable returns the first provider in the chain that can store secrets on this system
+func (c Chain) Writable() (Provider, error) {
+	for _, provider := range c {
+		if available, ok := provider.(interface{ Available() bool }); ok && !available.Available() {
+			continue
+		}
+		if provider.Name() == ProviderEnv {
+			continue
+		}
+		return provider, nil
+	}
+	return nil, fmt.Errorf("no writable secret provider configured (have %s)", strings.Join(c.Names(), ", "))
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapProvider is an in-memory provider for chain tests
+type mapProvider struct {
+	name   string
+	values map[string]string
+	err    error
+}
+
+func (m *mapProvider) Name() string { return m.name }
+
+func (m *mapProvider) Get(key string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	if value, ok := m.values[key]; ok {
+		return value, nil
+	}
+	return "", ErrNotFound
+}
+
+func (m *mapProvider) Set(key, value string) error { m.values[key] = value; return nil }
+
+func (m *mapProvider) Delete(key string) error { delete(m.values, key); return nil }
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{"openai.api_key", false},
+		{"github-token", false},
+		{"", true},
+		{"OpenAI.Key", true},
+		{"../key", true},
+		{"key with spaces", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestChain_Resolve(t *testing.T) {
+	first := &mapProvider{name: "first", values: map[string]string{}}
+	second := &mapProvider{name: "second", values: map[string]string{"openai.api_key": "sk-second"}}
+	broken := &mapProvider{name: "broken", err: ErrUnavailable}
+	chain := Chain{broken, first, second}
+
+	value, provider, err := chain.Resolve("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-second", value)
+	assert.Equal(t, "second", provider, "unavailable providers are skipped")
+
+	first.values["openai.api_key"] = "sk-first"
+	_, provider, err = chain.Resolve("openai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "first", provider, "earlier providers win")
+
+	_, _, err = chain.Resolve("missing.key")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.EqualError(t, err, "secret not found: missing.key (checked broken, first, second)")
+
+	failing := Chain{&mapProvider{name: "failing", err: errors.New("permission denied")}, second}
+	_, _, err = failing.Resolve("openai.api_key")
+	assert.EqualError(t, err, "failed to read secret openai.api_key from failing: permission denied")
+}
+
+func TestNewChain(t *testing.T) {
+	chain, err := NewChain(DefaultProviders, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env", "keychain", "file"}, chain.Names())
+
+	_, err = NewChain([]string{"env", "vault"}, t.TempDir())
+	assert.EqualError(t, err, "unknown secret provider: vault")
+}
+
+func TestChain_Writable(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	dir := t.TempDir()
+	unavailable := &KeychainProvider{goos: "plan9", run: runCommand, lookPath: func(string) (string, error) { return "", errors.New("missing") }}
+	chain := Chain{NewEnvProvider(), unavailable, NewFileProvider(dir)}
+
+	provider, err := chain.Writable()
+	require.NoError(t, err)
+	assert.Equal(t, ProviderFile, provider.Name(), "env is read-only and the keychain is unavailable")
+
+	_, err = Chain{NewEnvProvider()}.Writable()
+	assert.EqualError(t, err, "no writable secret provider configured (have env)")
+
+	t.Setenv(PassphraseEnv, "")
+	_, err = chain.Writable()
+	assert.Error(t, err, "the file needs a passphrase")
+}
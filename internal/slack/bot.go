@@ -0,0 +1,403 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/events"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+// Button action IDs sent back by interactive messages
+const (
+	ActionApprove = "approve"
+	ActionCancel  = "cancel"
+)
+
+// maxRequestBody bounds the size of slash command and interaction requests
+const maxRequestBody = 1 << 20
+
+// commandUsage is the reply to a slash command without a goal
+const commandUsage = "Usage: `/capn <goal>` plans the goal and asks for approval here before running it."
+
+// Runner runs the goals submitted from Slack
+type Runner interface {
+	// Submit plans and runs a goal in the background and returns the ID of its task
+	Submit(goal, user string) (string, error)
+	// Cancel stops a task the runner is running
+	Cancel(taskID string) error
+}
+
+// Bot posts task start and completion messages to a channel, accepts goals from a slash command
+// and asks for plan approval with message buttons
+type Bot struct {
+	client  *Client
+	channel string
+	secret  string
+	storage task.TaskStorage
+	logger  *zap.Logger
+	clock   common.Clock
+
+	mu      sync.Mutex
+	runner  Runner
+	threads map[string]Message       // each task's start message, whose thread holds its updates
+	pending map[string]chan decision // plans waiting for a decision, by task ID
+	server  *http.Server
+}
+
+// decision is the button clicked on a plan and who clicked it
+type decision struct {
+	approved bool
+	user     string
+}
+
+// NewBot creates a bot posting to channel through client. Requests from Slack must be signed with
+// signingSecret; finished tasks are looked up in storage to report their outcome.
+func NewBot(client *Client, channel, signingSecret string, storage task.TaskStorage, logger *zap.Logger) *Bot {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Bot{
+		client:  client,
+		channel: channel,
+		secret:  signingSecret,
+		storage: storage,
+		logger:  logger,
+		clock:   common.SystemClock,
+		threads: make(map[string]Message),
+		pending: make(map[string]chan decision),
+	}
+}
+
+// SetRunner sets the runner goals from the slash command are submitted to
+func (b *Bot) SetRunner(runner Runner) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.runner = runner
+}
+
+// Handler returns the HTTP handler for Slack's slash command and interactivity requests
+func (b *Bot) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /slack/commands", b.handleCommand)
+	mux.HandleFunc("POST /slack/interactions", b.handleInteraction)
+	return mux
+}
+
+// Start begins receiving Slack requests on the given address in the background and returns the
+// bound address
+func (b *Bot) Start(listen string) (string, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	server := &http.Server{
+		Handler:           b.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	b.mu.Lock()
+	b.server = server
+	b.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.logger.Error("Slack server stopped", zap.Error(err))
+		}
+	}()
+
+	return listener.Addr().String(), nil
+}
+
+// Shutdown gracefully stops receiving Slack requests
+func (b *Bot) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	server := b.server
+	b.server = nil
+	b.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// Watch starts posting a message when a task starts and replying in its thread when it
+// finishes, in the background until the context is cancelled or the bus is closed
+func (b *Bot) Watch(ctx context.Context, bus *events.Bus) error {
+	sub, err := bus.Subscribe(events.SubscribeOptions{
+		Name:  "slack",
+		Types: []events.EventType{events.EventTaskCreated, events.EventTaskStatusChanged},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to task events: %w", err)
+	}
+
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				b.handleEvent(ctx, event)
+			}
+		}
+	}()
+	return nil
+}
+
+// handleEvent posts the message for a task event
+func (b *Bot) handleEvent(ctx context.Context, event events.Event) {
+	switch event.Type {
+	case events.EventTaskCreated:
+		posted, err := b.client.PostMessage(ctx, Message{
+			Channel: b.channel,
+			Text:    fmt.Sprintf("Task `%s` started: %s", event.TaskID, event.Message),
+		})
+		if err != nil {
+			b.logger.Warn("Failed to post task start to Slack", zap.String("task_id", event.TaskID), zap.Error(err))
+			return
+		}
+		b.mu.Lock()
+		b.threads[event.TaskID] = posted
+		b.mu.Unlock()
+	case events.EventTaskStatusChanged:
+		if !task.TaskStatus(event.Status).IsTerminal() {
+			return
+		}
+		record, err := b.storage.GetTask(event.TaskID)
+		if err != nil {
+			b.logger.Warn("Failed to load finished task for Slack", zap.String("task_id", event.TaskID), zap.Error(err))
+			return
+		}
+		b.mu.Lock()
+		thread, ok := b.threads[event.TaskID]
+		delete(b.threads, event.TaskID)
+		b.mu.Unlock()
+
+		message := Message{Channel: b.channel, Text: finishedText(record)}
+		if ok {
+			message.Channel, message.ThreadTS = thread.Channel, thread.TS
+		}
+		if _, err := b.client.PostMessage(ctx, message); err != nil {
+			b.logger.Warn("Failed to post task completion to Slack", zap.String("task_id", event.TaskID), zap.Error(err))
+		}
+	}
+}
+
+// finishedText describes a finished task
+func finishedText(t *task.TaskExecution) string {
+	marker := "✓"
+	if t.Status != task.TaskStatusCompleted {
+		marker = "✗"
+	}
+	text := fmt.Sprintf("%s Task `%s` %s", marker, t.ID, t.Status)
+	if duration := t.Duration(); duration > 0 {
+		text += fmt.Sprintf(" in %s", duration.Round(time.Second))
+	}
+	if t.Error != "" {
+		text += ": " + t.Error
+	}
+	return text
+}
+
+// RequestApproval posts a task's plan in its thread with Approve and Cancel buttons and waits
+// for one to be clicked. It returns the context's error if no decision is made before it ends.
+func (b *Bot) RequestApproval(ctx context.Context, taskID, summary string) (bool, error) {
+	message := Message{
+		Channel: b.channel,
+		Text:    fmt.Sprintf("The plan for task %s is waiting for approval", taskID),
+		Blocks: []Block{
+			Section(summary),
+			Actions(Button("Approve", ActionApprove, taskID, "primary"), Button("Cancel", ActionCancel, taskID, "danger")),
+		},
+	}
+	b.mu.Lock()
+	if thread, ok := b.threads[taskID]; ok {
+		message.Channel, message.ThreadTS = thread.Channel, thread.TS
+	}
+	b.mu.Unlock()
+
+	posted, err := b.client.PostMessage(ctx, message)
+	if err != nil {
+		return false, fmt.Errorf("failed to ask for plan approval: %w", err)
+	}
+	decided := make(chan decision, 1)
+	b.mu.Lock()
+	b.pending[taskID] = decided
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, taskID)
+		b.mu.Unlock()
+	}()
+
+	select {
+	case d := <-decided:
+		posted.Blocks = []Block{Section(summary)}
+		if d.approved {
+			posted.Text = fmt.Sprintf("Plan approved by %s", d.user)
+			posted.Blocks = append(posted.Blocks, Context(posted.Text), Actions(Button("Cancel", ActionCancel, taskID, "danger")))
+		} else {
+			posted.Text = fmt.Sprintf("Plan cancelled by %s", d.user)
+			posted.Blocks = append(posted.Blocks, Context(posted.Text))
+		}
+		b.update(ctx, taskID, posted)
+		return d.approved, nil
+	case <-ctx.Done():
+		posted.Text = "No decision was made; the task was cancelled"
+		posted.Blocks = []Block{Section(summary), Context(posted.Text)}
+		b.update(context.WithoutCancel(ctx), taskID, posted)
+		return false, ctx.Err()
+	}
+}
+
+// update replaces a posted message, logging failures
+func (b *Bot) update(ctx context.Context, taskID string, message Message) {
+	if err := b.client.UpdateMessage(ctx, message); err != nil {
+		b.logger.Warn("Failed to update Slack message", zap.String("task_id", taskID), zap.Error(err))
+	}
+}
+
+// handleCommand submits the goal of a slash command
+func (b *Bot) handleCommand(w http.ResponseWriter, r *http.Request) {
+	form, ok := b.verifiedForm(w, r)
+	if !ok {
+		return
+	}
+	goal := strings.TrimSpace(form.Get("text"))
+	if goal == "" || goal == "help" {
+		b.reply(w, commandUsage)
+		return
+	}
+
+	b.mu.Lock()
+	runner := b.runner
+	b.mu.Unlock()
+	if runner == nil {
+		b.reply(w, "capn is not accepting goals from Slack right now.")
+		return
+	}
+	id, err := runner.Submit(goal, mention(form.Get("user_id"), form.Get("user_name")))
+	if err != nil {
+		b.reply(w, fmt.Sprintf("Could not submit the goal: %v", err))
+		return
+	}
+	b.reply(w, fmt.Sprintf("Submitted task `%s`: %s\nUpdates and the plan to approve are posted in %s.", id, goal, b.channel))
+}
+
+// handleInteraction handles the buttons clicked on plan messages
+func (b *Bot) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	form, ok := b.verifiedForm(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Type string `json:"type"`
+		User struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Actions []struct {
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, "invalid interaction payload", http.StatusBadRequest)
+		return
+	}
+
+	user := mention(payload.User.ID, payload.User.Username)
+	for _, action := range payload.Actions {
+		b.act(r.Context(), action.ActionID, action.Value, user)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// act applies a button click: it decides a pending plan, or cancels a task already running
+func (b *Bot) act(ctx context.Context, actionID, taskID, user string) {
+	if actionID != ActionApprove && actionID != ActionCancel {
+		return
+	}
+	b.mu.Lock()
+	decided, waiting := b.pending[taskID]
+	runner := b.runner
+	thread, threaded := b.threads[taskID]
+	b.mu.Unlock()
+
+	if waiting {
+		select {
+		case decided <- decision{approved: actionID == ActionApprove, user: user}:
+		default:
+			// Someone else decided first
+		}
+		return
+	}
+	if actionID != ActionCancel || runner == nil {
+		return
+	}
+
+	text := fmt.Sprintf("Cancel requested by %s", user)
+	if err := runner.Cancel(taskID); err != nil {
+		text = fmt.Sprintf("Could not cancel task `%s`: %v", taskID, err)
+	}
+	message := Message{Channel: b.channel, Text: text}
+	if threaded {
+		message.Channel, message.ThreadTS = thread.Channel, thread.TS
+	}
+	if _, err := b.client.PostMessage(ctx, message); err != nil {
+		b.logger.Warn("Failed to post cancellation to Slack", zap.String("task_id", taskID), zap.Error(err))
+	}
+}
+
+// verifiedForm reads a request form after checking Slack signed it, replying with an error otherwise
+func (b *Bot) verifiedForm(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := VerifySignature(b.secret, r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), body, b.clock.Now()); err != nil {
+		b.logger.Warn("Rejected Slack request", zap.String("path", r.URL.Path), zap.Error(err))
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return nil, false
+	}
+	return form, true
+}
+
+// reply answers a slash command with a message only its sender sees
+func (b *Bot) reply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": text}); err != nil {
+		b.logger.Warn("Failed to reply to Slack command", zap.Error(err))
+	}
+}
+
+// mention returns how to refer to a Slack user in a message
+func mention(id, name string) string {
+	if id != "" {
+		return "<@" + id + ">"
+	}
+	return name
+}
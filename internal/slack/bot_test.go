@@ -0,0 +1,256 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/events"
+	"github.com/iainlowe/capn/internal/task"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// fakeAPI records the messages posted to and updated in a fake Slack Web API
+type fakeAPI struct {
+	mu      sync.Mutex
+	posted  []Message
+	updated []Message
+}
+
+func (f *fakeAPI) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.URL.Path == "/chat.update" {
+			f.updated = append(f.updated, message)
+			_, _ = w.Write([]byte(`{"ok": true}`))
+			return
+		}
+		f.posted = append(f.posted, message)
+		_, _ = fmt.Fprintf(w, `{"ok": true, "channel": "C1", "ts": "%d.0"}`, len(f.posted))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeAPI) messages() ([]Message, []Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.posted...), append([]Message(nil), f.updated...)
+}
+
+// fakeRunner records submitted goals and cancelled tasks
+type fakeRunner struct {
+	mu        sync.Mutex
+	goals     []string
+	cancelled []string
+	err       error
+}
+
+func (r *fakeRunner) Submit(goal, user string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return "", r.err
+	}
+	r.goals = append(r.goals, goal+" by "+user)
+	return "task-1", nil
+}
+
+func (r *fakeRunner) Cancel(taskID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if taskID != "task-1" {
+		return fmt.Errorf("task %s is not running", taskID)
+	}
+	r.cancelled = append(r.cancelled, taskID)
+	return nil
+}
+
+func newTestBot(t *testing.T, storage task.TaskStorage) (*Bot, *fakeAPI, *fakeRunner) {
+	t.Helper()
+	api := &fakeAPI{}
+	server := api.serve(t)
+	bot := NewBot(NewClient(server.URL, "xoxb-test"), "#ops", "secret", storage, nil)
+	bot.clock = testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	runner := &fakeRunner{}
+	bot.SetRunner(runner)
+	return bot, api, runner
+}
+
+// signedRequest sends a form signed as Slack would to the bot's handler
+func signedRequest(t *testing.T, bot *Bot, path string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	body := form.Encode()
+	timestamp := strconv.FormatInt(bot.clock.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", Sign("secret", timestamp, []byte(body)))
+	rec := httptest.NewRecorder()
+	bot.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+// click sends a button click on a plan message
+func click(t *testing.T, bot *Bot, actionID, taskID string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload := fmt.Sprintf(`{"type": "block_actions", "user": {"id": "U1", "username": "sam"}, "actions": [{"action_id": %q, "value": %q}]}`, actionID, taskID)
+	return signedRequest(t, bot, "/slack/interactions", url.Values{"payload": {payload}})
+}
+
+func TestBot_Command(t *testing.T) {
+	bot, _, runner := newTestBot(t, task.NewMemoryTaskStorage())
+
+	rec := signedRequest(t, bot, "/slack/commands", url.Values{"command": {"/capn"}, "text": {" deploy the service "}, "user_id": {"U1"}, "user_name": {"sam"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var reply map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reply))
+	assert.Equal(t, "ephemeral", reply["response_type"])
+	assert.Equal(t, "Submitted task `task-1`: deploy the service\nUpdates and the plan to approve are posted in #ops.", reply["text"])
+	assert.Equal(t, []string{"deploy the service by <@U1>"}, runner.goals)
+
+	rec = signedRequest(t, bot, "/slack/commands", url.Values{"text": {""}})
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reply))
+	assert.Equal(t, commandUsage, reply["text"])
+
+	runner.err = fmt.Errorf("the daemon is shutting down")
+	rec = signedRequest(t, bot, "/slack/commands", url.Values{"text": {"deploy"}})
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reply))
+	assert.Equal(t, "Could not submit the goal: the daemon is shutting down", reply["text"])
+
+	// Requests not signed with the signing secret are refused
+	req := httptest.NewRequest(http.MethodPost, "/slack/commands", strings.NewReader("text=deploy"))
+	rec = httptest.NewRecorder()
+	bot.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Len(t, runner.goals, 1)
+}
+
+func TestBot_RequestApproval(t *testing.T) {
+	for _, tt := range []struct {
+		action   string
+		approved bool
+		context  string
+	}{
+		{action: ActionApprove, approved: true, context: "Plan approved by <@U1>"},
+		{action: ActionCancel, approved: false, context: "Plan cancelled by <@U1>"},
+	} {
+		t.Run(tt.action, func(t *testing.T) {
+			bot, api, runner := newTestBot(t, task.NewMemoryTaskStorage())
+
+			decided := make(chan bool, 1)
+			go func() {
+				approved, err := bot.RequestApproval(context.Background(), "task-1", "*Plan for task `task-1`*")
+				assert.NoError(t, err)
+				decided <- approved
+			}()
+			require.Eventually(t, func() bool {
+				posted, _ := api.messages()
+				return len(posted) == 1
+			}, time.Second, 5*time.Millisecond)
+			require.Eventually(t, func() bool {
+				bot.mu.Lock()
+				defer bot.mu.Unlock()
+				return bot.pending["task-1"] != nil
+			}, time.Second, 5*time.Millisecond)
+
+			posted, _ := api.messages()
+			require.Len(t, posted[0].Blocks, 2)
+			assert.Equal(t, ActionApprove, posted[0].Blocks[1].Elements[0].ActionID)
+			assert.Equal(t, "task-1", posted[0].Blocks[1].Elements[0].Value)
+
+			require.Equal(t, http.StatusOK, click(t, bot, tt.action, "task-1").Code)
+			assert.Equal(t, tt.approved, <-decided)
+			assert.Empty(t, runner.cancelled, "deciding a plan does not cancel a running task")
+
+			_, updated := api.messages()
+			require.Len(t, updated, 1)
+			assert.Equal(t, "1.0", updated[0].TS)
+			assert.Equal(t, tt.context, updated[0].Text)
+		})
+	}
+}
+
+func TestBot_RequestApprovalTimesOut(t *testing.T) {
+	bot, api, _ := newTestBot(t, task.NewMemoryTaskStorage())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	approved, err := bot.RequestApproval(ctx, "task-1", "plan")
+	assert.False(t, approved)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, updated := api.messages()
+	require.Len(t, updated, 1)
+	assert.Equal(t, "No decision was made; the task was cancelled", updated[0].Text)
+}
+
+func TestBot_CancelRunningTask(t *testing.T) {
+	bot, api, runner := newTestBot(t, task.NewMemoryTaskStorage())
+
+	require.Equal(t, http.StatusOK, click(t, bot, ActionCancel, "task-1").Code)
+	assert.Equal(t, []string{"task-1"}, runner.cancelled)
+	require.Equal(t, http.StatusOK, click(t, bot, ActionCancel, "task-2").Code)
+
+	posted, _ := api.messages()
+	require.Len(t, posted, 2)
+	assert.Equal(t, "Cancel requested by <@U1>", posted[0].Text)
+	assert.Equal(t, "Could not cancel task `task-2`: task task-2 is not running", posted[1].Text)
+
+	// Approving a plan nobody is waiting on does nothing
+	require.Equal(t, http.StatusOK, click(t, bot, ActionApprove, "task-1").Code)
+	posted, _ = api.messages()
+	assert.Len(t, posted, 2)
+}
+
+func TestBot_Watch(t *testing.T) {
+	storage := task.NewMemoryTaskStorage()
+	bot, api, _ := newTestBot(t, storage)
+	bus := events.NewBus()
+	defer bus.Close()
+	publishing := task.NewPublishingStorage(storage, bus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, bot.Watch(ctx, bus))
+
+	record := task.NewTaskExecution("deploy the service")
+	record.SetStatus(task.TaskStatusPlanning)
+	require.NoError(t, publishing.SaveTask(record))
+	require.Eventually(t, func() bool {
+		posted, _ := api.messages()
+		return len(posted) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	record.SetStatus(task.TaskStatusRunning)
+	require.NoError(t, publishing.SaveTask(record))
+	record.Error = "step task-2 failed"
+	record.SetStatus(task.TaskStatusFailed)
+	require.NoError(t, publishing.SaveTask(record))
+	require.Eventually(t, func() bool {
+		posted, _ := api.messages()
+		return len(posted) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	posted, _ := api.messages()
+	assert.Equal(t, "Task `"+record.ID+"` started: deploy the service", posted[0].Text)
+	assert.Equal(t, "#ops", posted[0].Channel)
+	assert.Equal(t, "C1", posted[1].Channel)
+	assert.Equal(t, "1.0", posted[1].ThreadTS, "the outcome is posted in the task's thread")
+	assert.True(t, strings.HasPrefix(posted[1].Text, "✗ Task `"+record.ID+"` failed"), posted[1].Text)
+	assert.True(t, strings.HasSuffix(posted[1].Text, ": step task-2 failed"), posted[1].Text)
+}
@@ -0,0 +1,155 @@
+// Package slack connects the daemon to a Slack workspace: it posts task updates to a channel,
+// accepts goals from a slash command and takes plan approvals from message buttons.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds how long a Slack API request may take
+const requestTimeout = 10 * time.Second
+
+// Message is a message posted to or updated in a channel
+type Message struct {
+	Channel  string  `json:"channel"`
+	TS       string  `json:"ts,omitempty"`
+	ThreadTS string  `json:"thread_ts,omitempty"`
+	Text     string  `json:"text"`
+	Blocks   []Block `json:"blocks,omitempty"`
+}
+
+// Block is a Block Kit layout block
+type Block struct {
+	Type     string    `json:"type"`
+	BlockID  string    `json:"block_id,omitempty"`
+	Text     *Text     `json:"text,omitempty"`
+	Elements []Element `json:"elements,omitempty"`
+}
+
+// Text is a Block Kit text object
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Element is a Block Kit element; the integration only uses buttons and context text
+type Element struct {
+	Type     string `json:"type"`
+	Text     any    `json:"text,omitempty"`
+	ActionID string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Style    string `json:"style,omitempty"`
+}
+
+// Section returns a block showing Markdown text
+func Section(text string) Block {
+	return Block{Type: "section", Text: &Text{Type: "mrkdwn", Text: text}}
+}
+
+// Context returns a block showing small Markdown text
+func Context(text string) Block {
+	return Block{Type: "context", Elements: []Element{{Type: "mrkdwn", Text: text}}}
+}
+
+// Actions returns a block of buttons
+func Actions(buttons ...Element) Block {
+	return Block{Type: "actions", Elements: buttons}
+}
+
+// Button returns a button sending actionID and value when clicked; style is "", "primary" or "danger"
+func Button(label, actionID, value, style string) Element {
+	return Element{Type: "button", Text: Text{Type: "plain_text", Text: label}, ActionID: actionID, Value: value, Style: style}
+}
+
+// Client talks to the Slack Web API with a bot token
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewClient creates a client for the Web API at baseURL
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// PostMessage posts a message and returns it with the channel ID and timestamp Slack assigned
+func (c *Client) PostMessage(ctx context.Context, message Message) (Message, error) {
+	var resp struct {
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	if err := c.call(ctx, "chat.postMessage", message, &resp); err != nil {
+		return Message{}, fmt.Errorf("failed to post message: %w", err)
+	}
+	message.Channel, message.TS = resp.Channel, resp.TS
+	return message, nil
+}
+
+// UpdateMessage replaces the text and blocks of a posted message
+func (c *Client) UpdateMessage(ctx context.Context, message Message) error {
+	if message.TS == "" {
+		return fmt.Errorf("message to update has no timestamp")
+	}
+	if err := c.call(ctx, "chat.update", message, nil); err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
+	}
+	return nil
+}
+
+// call invokes a Web API method. Slack reports most failures with ok set to false rather than
+// an HTTP error status.
+func (c *Client) call(ctx context.Context, method string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !status.OK {
+		return fmt.Errorf("slack error: %s", status.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_PostMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat.postMessage", r.URL.Path)
+		assert.Equal(t, "Bearer xoxb-test", r.Header.Get("Authorization"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "#ops", body["channel"])
+		assert.Equal(t, "hello", body["text"])
+		blocks := body["blocks"].([]any)
+		button := blocks[1].(map[string]any)["elements"].([]any)[0].(map[string]any)
+		assert.Equal(t, map[string]any{"type": "plain_text", "text": "Approve"}, button["text"])
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C123", "ts": "1700000000.000100"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "xoxb-test")
+	posted, err := client.PostMessage(context.Background(), Message{
+		Channel: "#ops",
+		Text:    "hello",
+		Blocks:  []Block{Section("hello"), Actions(Button("Approve", ActionApprove, "task-1", "primary"))},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "C123", posted.Channel)
+	assert.Equal(t, "1700000000.000100", posted.TS)
+	assert.Equal(t, "hello", posted.Text)
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			_, _ = w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "xoxb-test")
+
+	_, err := client.PostMessage(context.Background(), Message{Channel: "#nope", Text: "hello"})
+	assert.EqualError(t, err, "failed to post message: slack error: channel_not_found")
+
+	err = client.UpdateMessage(context.Background(), Message{Channel: "C1", TS: "1.0", Text: "hello"})
+	assert.EqualError(t, err, "failed to update message: slack returned 429 Too Many Requests")
+
+	err = client.UpdateMessage(context.Background(), Message{Channel: "C1", Text: "hello"})
+	assert.EqualError(t, err, "message to update has no timestamp")
+}
@@ -0,0 +1,40 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// maxRequestAge is how old a signed request may be before it is rejected as a replay
+const maxRequestAge = 5 * time.Minute
+
+// VerifySignature checks that a request body was signed by Slack with the app's signing secret,
+// given the X-Slack-Request-Timestamp and X-Slack-Signature headers
+func VerifySignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("request is not signed")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return fmt.Errorf("request timestamp is too old")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("request signature does not match")
+	}
+	return nil
+}
+
+// Sign returns the v0 signature Slack sends for a request body sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
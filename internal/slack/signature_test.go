@@ -0,0 +1,49 @@
+package slack
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte("command=%2Fcapn&text=deploy")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   string
+	}{
+		{name: "valid", secret: "secret", timestamp: timestamp, signature: signature, body: body},
+		{name: "unsigned", secret: "secret", timestamp: timestamp, body: body, wantErr: "request is not signed"},
+		{name: "wrong secret", secret: "other", timestamp: timestamp, signature: signature, body: body, wantErr: "request signature does not match"},
+		{name: "tampered body", secret: "secret", timestamp: timestamp, signature: signature, body: []byte("text=rm"), wantErr: "request signature does not match"},
+		{name: "bad timestamp", secret: "secret", timestamp: "yesterday", signature: signature, body: body, wantErr: `invalid request timestamp "yesterday"`},
+		{
+			name:      "replayed",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			signature: Sign("secret", strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), body),
+			body:      body,
+			wantErr:   "request timestamp is too old",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.secret, tt.timestamp, tt.signature, tt.body, now)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
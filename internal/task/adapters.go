@@ -0,0 +1,79 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// StatusForExecution maps the outcome of executing a plan onto a task status
+func StatusForExecution(result *captain.ExecutionResult) TaskStatus {
+	switch {
+	case result.Interrupted:
+		return TaskStatusCancelled
+	case result.Success:
+		return TaskStatusCompleted
+	default:
+		return TaskStatusFailed
+	}
+}
+
+// RecordExecution stores the results of executing the task's plan, logs handoffs, quota stops,
+// read-only skips, stalls and timeouts against their steps and a run past the max runtime
+// against the task, and moves the task to the matching status. A plan revised after a stalled
+// step replaces the task's plan.
+func (t *TaskExecution) RecordExecution(result *captain.ExecutionResult) {
+	// The execution's results replace the checkpoints of the steps it finished
+	t.Results = append(t.Results[:len(t.Results)-t.checkpointed], result.TaskResults...)
+	t.checkpointed = 0
+	if result.RevisedPlan != nil {
+		t.Plan = result.RevisedPlan
+		t.AddLog(LogLevelWarn, fmt.Sprintf("Step %s stalled; the Captain replanned the rest of the goal",
+			result.RevisedPlan.Metadata[captain.MetadataReplannedAfter]))
+	}
+	for _, handoff := range result.Handoffs {
+		t.AddStepLog(LogLevelWarn, handoff.TaskID, handoff.FromAgent,
+			fmt.Sprintf("Step handed off from %s to %s: %s", handoff.FromAgent, handoff.ToAgent, handoff.Reason))
+	}
+	for _, stepResult := range result.TaskResults {
+		agentID, _ := stepResult.Metadata["agent_id"].(string)
+		if quota, ok := stepResult.Metadata["quota_exceeded"].(string); ok {
+			t.AddStepLog(LogLevelWarn, stepResult.TaskID, agentID,
+				fmt.Sprintf("Step stopped by crew quota %s: %s", quota, stepResult.Error))
+		}
+		if stepResult.Metadata["read_only"] == true {
+			t.AddStepLog(LogLevelWarn, stepResult.TaskID, agentID,
+				fmt.Sprintf("Step skipped in read-only mode, which blocks %v", stepResult.Metadata["blocked"]))
+		}
+		if stalls, ok := stepResult.Metadata["stalls"]; ok {
+			message := fmt.Sprintf("Step stalled %v time(s), last with no output for %v", stalls, stepResult.Metadata["stalled_for"])
+			if decision, ok := stepResult.Metadata["stall_decision"].(string); ok {
+				message += "; the Captain chose to " + decision
+			}
+			t.AddStepLog(LogLevelWarn, stepResult.TaskID, agentID, message)
+		}
+		if stepResult.Status() == captain.StepStatusTimedOut {
+			t.AddStepLog(LogLevelError, stepResult.TaskID, agentID, "Step "+stepResult.Error)
+		}
+	}
+
+	status := StatusForExecution(result)
+	if status != TaskStatusCompleted {
+		t.Error = result.Error
+	}
+	if result.TimedOut {
+		t.AddLog(LogLevelError, result.Error+"; the remaining steps were cancelled")
+	}
+	if status == TaskStatusCancelled {
+		t.AddLog(LogLevelWarn, result.Error)
+	}
+	t.SetStatus(status)
+}
+
+// StepStatuses returns the status of each step in the task's plan
+func (t *TaskExecution) StepStatuses() map[string]captain.StepStatus {
+	if t.Plan == nil {
+		return nil
+	}
+	return t.Plan.StepStatuses(t.Results)
+}
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func TestStatusForExecution(t *testing.T) {
+	tests := []struct {
+		name   string
+		result captain.ExecutionResult
+		want   TaskStatus
+	}{
+		{"success", captain.ExecutionResult{Success: true}, TaskStatusCompleted},
+		{"failure", captain.ExecutionResult{Success: false}, TaskStatusFailed},
+		{"interrupted", captain.ExecutionResult{Interrupted: true}, TaskStatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, StatusForExecution(&tt.result))
+		})
+	}
+}
+
+func TestTaskExecution_RecordExecution(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.Plan = &captain.ExecutionPlan{Tasks: []captain.Task{{ID: "task-1"}, {ID: "task-2"}, {ID: "task-3"}}}
+	te.SetStatus(TaskStatusRunning)
+
+	te.RecordExecution(&captain.ExecutionResult{
+		Success: false,
+		Error:   "1 task(s) failed",
+		TaskResults: []captain.Result{
+			{TaskID: "task-1", Success: true},
+			{TaskID: "task-2", Error: "timed out after 1s (file agent timeout)",
+				Metadata: map[string]any{"timed_out": true, "agent_id": "file-001"}},
+		},
+		Handoffs: []captain.Handoff{{TaskID: "task-1", FromAgent: "file-001", ToAgent: "file-002", Reason: "agent stopped"}},
+	})
+
+	assert.Equal(t, TaskStatusFailed, te.Status)
+	assert.Equal(t, "1 task(s) failed", te.Error)
+	assert.Len(t, te.Results, 2)
+	assert.Equal(t, map[string]captain.StepStatus{
+		"task-1": captain.StepStatusSucceeded,
+		"task-2": captain.StepStatusTimedOut,
+		"task-3": captain.StepStatusPending,
+	}, te.StepStatuses())
+
+	var messages []string
+	for _, entry := range te.Logs {
+		messages = append(messages, entry.Step+": "+entry.Message)
+	}
+	assert.Equal(t, []string{
+		"task-1: Step handed off from file-001 to file-002: agent stopped",
+		"task-2: Step timed out after 1s (file agent timeout)",
+	}, messages)
+}
+
+func TestTaskExecution_RecordExecutionInterrupted(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.RecordExecution(&captain.ExecutionResult{Interrupted: true, Error: "execution cancelled"})
+
+	assert.Equal(t, TaskStatusCancelled, te.Status)
+	assert.Equal(t, "execution cancelled", te.Error)
+	assert.Equal(t, LogLevelWarn, te.Logs[len(te.Logs)-1].Level)
+	assert.Nil(t, te.StepStatuses(), "no plan, no steps")
+}
+
+func TestTaskExecution_RecordExecutionReadOnly(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.RecordExecution(&captain.ExecutionResult{
+		Success: true,
+		TaskResults: []captain.Result{{TaskID: "task-1", Success: true, Output: "skipped: read-only mode blocks writing to file out.txt",
+			Metadata: map[string]any{"skipped": true, "read_only": true, "blocked": "writing to file out.txt", "agent_id": "file-001"}}},
+	})
+
+	assert.Equal(t, TaskStatusCompleted, te.Status)
+	entry := te.Logs[len(te.Logs)-1]
+	assert.Equal(t, LogLevelWarn, entry.Level)
+	assert.Equal(t, "task-1", entry.Step)
+	assert.Equal(t, "Step skipped in read-only mode, which blocks writing to file out.txt", entry.Message)
+}
+
+func TestTaskExecution_RecordExecutionStalled(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.Plan = &captain.ExecutionPlan{Tasks: []captain.Task{{ID: "task-1"}, {ID: "task-2"}}}
+	revised := &captain.ExecutionPlan{
+		Tasks:    []captain.Task{{ID: "task-1"}, {ID: "task-2"}, {ID: "task-3"}},
+		Metadata: map[string]string{captain.MetadataReplannedAfter: "task-1"},
+	}
+	te.RecordExecution(&captain.ExecutionResult{
+		Success:     true,
+		RevisedPlan: revised,
+		TaskResults: []captain.Result{
+			{TaskID: "task-1", Error: "stalled", Metadata: map[string]any{"stalls": 1, "stalled_for": "5m0s", "stall_decision": "replan", "agent_id": "file-001"}},
+			{TaskID: "task-3", Success: true, Metadata: map[string]any{"stalls": 2, "stalled_for": "1m0s"}},
+		},
+	})
+
+	assert.Same(t, revised, te.Plan, "the revised plan replaces the task's plan")
+	var messages []string
+	for _, entry := range te.Logs {
+		messages = append(messages, entry.Step+": "+entry.Message)
+	}
+	assert.Equal(t, []string{
+		": Step task-1 stalled; the Captain replanned the rest of the goal",
+		"task-1: Step stalled 1 time(s), last with no output for 5m0s; the Captain chose to replan",
+		"task-3: Step stalled 2 time(s), last with no output for 1m0s",
+	}, messages)
+}
+
+func TestTaskExecution_RecordExecutionTimedOut(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.RecordExecution(&captain.ExecutionResult{
+		TimedOut:    true,
+		Error:       "task exceeded its max runtime of 1m0s",
+		TaskResults: []captain.Result{{TaskID: "task-1", Success: true}},
+	})
+
+	assert.Equal(t, TaskStatusFailed, te.Status, "a timed-out task fails")
+	assert.Len(t, te.Results, 1, "finished steps are kept")
+	entry := te.Logs[len(te.Logs)-1]
+	assert.Equal(t, LogLevelError, entry.Level)
+	assert.Equal(t, "task exceeded its max runtime of 1m0s; the remaining steps were cancelled", entry.Message)
+}
@@ -0,0 +1,203 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// Artifact is an output of a task that has been copied into the artifact store
+type Artifact struct {
+	Name      string              `json:"name"`
+	Kind      agents.ArtifactKind `json:"kind"`
+	Path      string              `json:"path"`
+	Size      int64               `json:"size"`
+	Step      string              `json:"step,omitempty"`
+	Agent     string              `json:"agent,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// ArtifactStore keeps task artifacts on disk under <dir>/<task-id>/
+type ArtifactStore struct {
+	dir string
+}
+
+// NewArtifactStore creates an artifact store rooted at dir, creating it if needed
+func NewArtifactStore(dir string) (*ArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory %s: %w", dir, err)
+	}
+	return &ArtifactStore{dir: dir}, nil
+}
+
+// TaskDir returns the directory holding a task's artifacts
+func (s *ArtifactStore) TaskDir(taskID string) string {
+	return filepath.Join(s.dir, taskID)
+}
+
+// Delete removes every artifact stored for a task
+func (s *ArtifactStore) Delete(taskID string) error {
+	if err := os.RemoveAll(s.TaskDir(taskID)); err != nil {
+		return fmt.Errorf("failed to delete artifacts of task %s: %w", taskID, err)
+	}
+	return nil
+}
+
+// Save stores an agent artifact for a task step, renaming it if the name is already taken
+func (s *ArtifactStore) Save(taskID, step, agent string, artifact agents.Artifact) (Artifact, error) {
+	if taskID == "" || taskID == ".." || strings.ContainsAny(taskID, `/\`) {
+		return Artifact{}, fmt.Errorf("invalid task ID: %s", taskID)
+	}
+	name, err := artifactName(artifact.Name)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	dir := s.TaskDir(taskID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Artifact{}, fmt.Errorf("failed to create artifacts directory for %s: %w", taskID, err)
+	}
+
+	dst, path, err := createUnique(dir, name)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to store artifact %s: %w", name, err)
+	}
+	size, err := writeArtifact(dst, artifact)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return Artifact{}, fmt.Errorf("failed to store artifact %s: %w", name, err)
+	}
+
+	kind := artifact.Kind
+	if kind == "" {
+		kind = agents.ArtifactKindFile
+	}
+	return Artifact{
+		Name:      filepath.Base(path),
+		Kind:      kind,
+		Path:      path,
+		Size:      size,
+		Step:      step,
+		Agent:     agent,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Collect stores the artifacts produced by plan step results and registers them on the task.
+// Every artifact is attempted; failures are logged on the task and returned together.
+func (s *ArtifactStore) Collect(record *TaskExecution, results []captain.Result) error {
+	var errs []error
+	for _, result := range results {
+		agent, _ := result.Metadata["agent_id"].(string)
+		for _, artifact := range result.Artifacts {
+			stored, err := s.Save(record.ID, result.TaskID, agent, artifact)
+			if err != nil {
+				record.AddStepLog(LogLevelWarn, result.TaskID, agent, err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			record.Artifacts = append(record.Artifacts, stored)
+			record.AddStepLog(LogLevelInfo, result.TaskID, agent,
+				fmt.Sprintf("Registered %s artifact %s (%d bytes)", stored.Kind, stored.Name, stored.Size))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeArtifact copies the artifact's content, or the file it points at, into dst
+func writeArtifact(dst io.Writer, artifact agents.Artifact) (int64, error) {
+	if artifact.Path == "" {
+		n, err := dst.Write(artifact.Content)
+		return int64(n), err
+	}
+	src, err := os.Open(artifact.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return io.Copy(dst, src)
+}
+
+// artifactName reduces an artifact name to a single safe path element
+func artifactName(name string) (string, error) {
+	name = filepath.Base(filepath.Clean("/" + filepath.FromSlash(name)))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("artifact name cannot be empty")
+	}
+	return name, nil
+}
+
+// createUnique creates name in dir, appending -2, -3, ... before the extension if it already exists
+func createUnique(dir, name string) (*os.File, string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+		}
+		path := filepath.Join(dir, candidate)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return f, path, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, "", err
+		}
+	}
+}
+
+// messagesDir holds the attachments of agent messages that do not belong to a task
+const messagesDir = "messages"
+
+// StoreAttachment stores the content of an agent message too large to route inline, among
+// the artifacts of the message's task so it is deleted with them. It returns the attachment's
+// path relative to the store.
+func (s *ArtifactStore) StoreAttachment(message agents.Message, content []byte) (string, error) {
+	dir := messagesDir
+	if taskID, _ := message.Data["task_id"].(string); taskID != "" {
+		if taskID == ".." || strings.ContainsAny(taskID, `/\`) {
+			return "", fmt.Errorf("invalid task ID: %s", taskID)
+		}
+		dir = taskID
+	}
+	name, err := artifactName("message-" + message.ID + ".txt")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, dir), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create attachments directory: %w", err)
+	}
+	dst, path, err := createUnique(filepath.Join(s.dir, dir), name)
+	if err != nil {
+		return "", err
+	}
+	_, err = dst.Write(content)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return filepath.ToSlash(filepath.Join(dir, filepath.Base(path))), nil
+}
+
+// LoadAttachment returns the content of a message attachment stored by StoreAttachment
+func (s *ArtifactStore) LoadAttachment(ref string) ([]byte, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(ref))
+	if rel, err := filepath.Rel(s.dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("invalid attachment reference: %s", ref)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,120 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func TestArtifactStore_Save(t *testing.T) {
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	stored, err := store.Save("task-1", "step-1", "research-001", agents.Artifact{
+		Name:    "report.md",
+		Kind:    agents.ArtifactKindReport,
+		Content: []byte("# Findings\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "report.md", stored.Name)
+	assert.Equal(t, filepath.Join(store.TaskDir("task-1"), "report.md"), stored.Path)
+	assert.Equal(t, int64(11), stored.Size)
+	assert.Equal(t, "step-1", stored.Step)
+	assert.Equal(t, "research-001", stored.Agent)
+
+	data, err := os.ReadFile(stored.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Findings\n", string(data))
+
+	again, err := store.Save("task-1", "step-2", "", agents.Artifact{Name: "report.md", Content: []byte("v2")})
+	require.NoError(t, err)
+	assert.Equal(t, "report-2.md", again.Name, "existing artifacts are never overwritten")
+	assert.Equal(t, agents.ArtifactKindFile, again.Kind, "kind defaults to file")
+}
+
+func TestArtifactStore_SaveFromPath(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(src, []byte("a,b\n1,2\n"), 0o644))
+
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	stored, err := store.Save("task-1", "", "", agents.Artifact{Name: "data.csv", Path: src})
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), stored.Size)
+
+	_, err = store.Save("task-1", "", "", agents.Artifact{Name: "missing.csv", Path: filepath.Join(t.TempDir(), "missing.csv")})
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(store.TaskDir("task-1"), "missing.csv"), "failed saves leave nothing behind")
+}
+
+func TestArtifactStore_SaveRejectsUnsafeNames(t *testing.T) {
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	stored, err := store.Save("task-1", "", "", agents.Artifact{Name: "../../etc/passwd", Content: []byte("x")})
+	require.NoError(t, err)
+	assert.Equal(t, "passwd", stored.Name)
+	assert.Equal(t, store.TaskDir("task-1"), filepath.Dir(stored.Path))
+
+	_, err = store.Save("task-1", "", "", agents.Artifact{Name: "", Content: []byte("x")})
+	assert.EqualError(t, err, "artifact name cannot be empty")
+
+	_, err = store.Save("../task-1", "", "", agents.Artifact{Name: "a.txt"})
+	assert.EqualError(t, err, "invalid task ID: ../task-1")
+}
+
+func TestArtifactStore_Collect(t *testing.T) {
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	record := NewTaskExecution("document the API")
+	results := []captain.Result{
+		{TaskID: "step-1", Success: true, Metadata: map[string]any{"agent_id": "research-001"}, Artifacts: []agents.Artifact{
+			{Name: "api.md", Kind: agents.ArtifactKindReport, Content: []byte("docs")},
+		}},
+		{TaskID: "step-2", Success: true},
+		{TaskID: "step-3", Success: true, Artifacts: []agents.Artifact{{Name: "", Content: []byte("lost")}}},
+	}
+
+	err = store.Collect(record, results)
+	assert.EqualError(t, err, "artifact name cannot be empty")
+
+	require.Len(t, record.Artifacts, 1)
+	artifact, ok := record.Artifact("api.md")
+	require.True(t, ok)
+	assert.Equal(t, "research-001", artifact.Agent)
+
+	require.Len(t, record.Logs, 2)
+	assert.Equal(t, "Registered report artifact api.md (4 bytes)", record.Logs[0].Message)
+	assert.Equal(t, LogLevelWarn, record.Logs[1].Level)
+	assert.Equal(t, "step-3", record.Logs[1].Step)
+}
+
+func TestArtifactStore_Attachments(t *testing.T) {
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	message := agents.Message{ID: "msg-1", Data: map[string]interface{}{"task_id": "task-1"}}
+	ref, err := store.StoreAttachment(message, []byte("full output"))
+	require.NoError(t, err)
+	assert.Equal(t, "task-1/message-msg-1.txt", ref, "attachments are deleted with the task's artifacts")
+	content, err := store.LoadAttachment(ref)
+	require.NoError(t, err)
+	assert.Equal(t, "full output", string(content))
+
+	ref, err = store.StoreAttachment(agents.Message{ID: "msg-2"}, []byte("chatter"))
+	require.NoError(t, err)
+	assert.Equal(t, "messages/message-msg-2.txt", ref)
+
+	_, err = store.StoreAttachment(agents.Message{ID: "msg-3", Data: map[string]interface{}{"task_id": "../etc"}}, nil)
+	assert.Error(t, err)
+	_, err = store.LoadAttachment("../../etc/passwd")
+	assert.EqualError(t, err, "invalid attachment reference: ../../etc/passwd")
+}
@@ -0,0 +1,140 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// BlackboardEntry is a finding an agent published to its task's blackboard. Its value is kept
+// as JSON and its artifacts are stored with the task's other artifacts.
+type BlackboardEntry struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Step      string          `json:"step,omitempty"`
+	Agent     string          `json:"agent,omitempty"`
+	Artifacts []Artifact      `json:"artifacts,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Publish records an entry on the task's blackboard, replacing any entry with the same key
+func (t *TaskExecution) Publish(entry BlackboardEntry) {
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = time.Now()
+	}
+	t.AddStepLog(LogLevelInfo, entry.Step, entry.Agent, "Published finding "+entry.Key)
+	if i := slices.IndexFunc(t.Blackboard, func(e BlackboardEntry) bool { return e.Key == entry.Key }); i >= 0 {
+		t.Blackboard[i] = entry
+		return
+	}
+	t.Blackboard = append(t.Blackboard, entry)
+}
+
+// Finding returns the blackboard entry published under key
+func (t *TaskExecution) Finding(key string) (BlackboardEntry, bool) {
+	for _, entry := range t.Blackboard {
+		if entry.Key == key {
+			return entry, true
+		}
+	}
+	return BlackboardEntry{}, false
+}
+
+// finding converts the entry to the finding agents read, decoding its value
+func (e BlackboardEntry) finding() (agents.Finding, error) {
+	finding := agents.Finding{Key: e.Key, Step: e.Step, Agent: e.Agent}
+	if len(e.Value) > 0 {
+		if err := json.Unmarshal(e.Value, &finding.Value); err != nil {
+			return finding, fmt.Errorf("failed to decode finding %q: %w", e.Key, err)
+		}
+	}
+	for _, artifact := range e.Artifacts {
+		finding.Artifacts = append(finding.Artifacts, agents.Artifact{Name: artifact.Name, Kind: artifact.Kind, Path: artifact.Path})
+	}
+	return finding, nil
+}
+
+// TaskBlackboard is the blackboard of a task being executed. Findings are saved with the task
+// so later steps, other agents and "capn tasks show --blackboard" can read them, and their
+// artifacts are copied into the artifact store.
+type TaskBlackboard struct {
+	storage   TaskStorage
+	record    *TaskExecution
+	artifacts *ArtifactStore
+
+	mu sync.Mutex
+}
+
+// NewTaskBlackboard creates the blackboard of the task record being executed. Without an
+// artifact store, findings carrying artifacts are refused.
+func NewTaskBlackboard(storage TaskStorage, record *TaskExecution, artifacts *ArtifactStore) *TaskBlackboard {
+	return &TaskBlackboard{storage: storage, record: record, artifacts: artifacts}
+}
+
+// Publish records a finding on the task and saves it
+func (b *TaskBlackboard) Publish(ctx context.Context, finding agents.Finding) error {
+	if finding.Key == "" {
+		return fmt.Errorf("finding key cannot be empty")
+	}
+	entry := BlackboardEntry{Key: finding.Key, Step: finding.Step, Agent: finding.Agent}
+	if finding.Value != nil {
+		value, err := json.Marshal(finding.Value)
+		if err != nil {
+			return fmt.Errorf("failed to encode finding %q: %w", finding.Key, err)
+		}
+		entry.Value = value
+	}
+	if len(finding.Artifacts) > 0 && b.artifacts == nil {
+		return fmt.Errorf("finding %q has artifacts but no artifact store is available", finding.Key)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, artifact := range finding.Artifacts {
+		stored, err := b.artifacts.Save(b.record.ID, finding.Step, finding.Agent, artifact)
+		if err != nil {
+			return fmt.Errorf("failed to store artifact of finding %q: %w", finding.Key, err)
+		}
+		entry.Artifacts = append(entry.Artifacts, stored)
+		b.record.Artifacts = append(b.record.Artifacts, stored)
+	}
+	b.record.Publish(entry)
+	if err := b.storage.SaveTask(b.record); err != nil {
+		return fmt.Errorf("failed to save finding %q: %w", finding.Key, err)
+	}
+	return nil
+}
+
+// Lookup returns the finding published under key
+func (b *TaskBlackboard) Lookup(ctx context.Context, key string) (agents.Finding, bool, error) {
+	b.mu.Lock()
+	entry, ok := b.record.Finding(key)
+	b.mu.Unlock()
+	if !ok {
+		return agents.Finding{}, false, nil
+	}
+	finding, err := entry.finding()
+	return finding, err == nil, err
+}
+
+// Findings returns every finding published for the task, in the order they were first published
+func (b *TaskBlackboard) Findings(ctx context.Context) ([]agents.Finding, error) {
+	b.mu.Lock()
+	entries := slices.Clone(b.record.Blackboard)
+	b.mu.Unlock()
+
+	findings := make([]agents.Finding, 0, len(entries))
+	for _, entry := range entries {
+		finding, err := entry.finding()
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
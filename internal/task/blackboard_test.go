@@ -0,0 +1,89 @@
+package task
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestTaskExecution_Publish(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.Publish(BlackboardEntry{Key: "endpoints", Value: []byte(`["/health"]`), Step: "step-1", Agent: "research-001"})
+	te.Publish(BlackboardEntry{Key: "owner", Value: []byte(`"platform"`), Step: "step-1"})
+	te.Publish(BlackboardEntry{Key: "endpoints", Value: []byte(`["/health","/ready"]`), Step: "step-2"})
+
+	require.Len(t, te.Blackboard, 2)
+	entry, ok := te.Finding("endpoints")
+	require.True(t, ok)
+	assert.JSONEq(t, `["/health","/ready"]`, string(entry.Value))
+	assert.Equal(t, "step-2", entry.Step)
+	assert.False(t, entry.UpdatedAt.IsZero())
+	assert.Equal(t, "Published finding endpoints", te.Logs[len(te.Logs)-1].Message)
+
+	_, ok = te.Finding("missing")
+	assert.False(t, ok)
+}
+
+func TestTaskBlackboard(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryTaskStorage()
+	record := NewTaskExecution("goal")
+	require.NoError(t, storage.SaveTask(record))
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+	board := NewTaskBlackboard(storage, record, store)
+
+	assert.EqualError(t, board.Publish(ctx, agents.Finding{}), "finding key cannot be empty")
+
+	report := agents.Artifact{Name: "endpoints.md", Kind: agents.ArtifactKindReport, Content: []byte("# Endpoints\n")}
+	require.NoError(t, board.Publish(ctx, agents.Finding{
+		Key:       "endpoints",
+		Value:     map[string]interface{}{"count": 2, "paths": []string{"/health", "/ready"}},
+		Step:      "step-1",
+		Agent:     "research-001",
+		Artifacts: []agents.Artifact{report},
+	}))
+
+	// Findings are saved with the task
+	stored, err := storage.GetTask(record.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Blackboard, 1)
+	require.Len(t, stored.Artifacts, 1)
+	assert.Equal(t, stored.Artifacts, stored.Blackboard[0].Artifacts)
+
+	finding, ok, err := board.Lookup(ctx, "endpoints")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"count": float64(2), "paths": []interface{}{"/health", "/ready"}}, finding.Value)
+	assert.Equal(t, "research-001", finding.Agent)
+	require.Len(t, finding.Artifacts, 1)
+	content, err := os.ReadFile(finding.Artifacts[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Endpoints\n", string(content))
+
+	_, ok, err = board.Lookup(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, board.Publish(ctx, agents.Finding{Key: "done", Step: "step-2"}))
+	findings, err := board.Findings(ctx)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, "endpoints", findings[0].Key)
+	assert.Nil(t, findings[1].Value)
+}
+
+func TestTaskBlackboard_WithoutArtifactStore(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	record := NewTaskExecution("goal")
+	board := NewTaskBlackboard(storage, record, nil)
+
+	err := board.Publish(context.Background(), agents.Finding{Key: "report", Artifacts: []agents.Artifact{{Name: "r.md", Content: []byte("x")}}})
+	assert.EqualError(t, err, `finding "report" has artifacts but no artifact store is available`)
+	assert.Empty(t, record.Blackboard)
+}
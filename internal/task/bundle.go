@@ -0,0 +1,255 @@
+package task
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// BundleVersion is the bundle format written by WriteBundle
+const BundleVersion = 1
+
+// Files of a task bundle. The record is what ReadBundle loads; the plan, log, transcript and
+// audit files are there to read without capn.
+const (
+	bundleManifest   = "manifest.json"
+	bundleRecord     = "task.json"
+	bundlePlan       = "plan.yaml"
+	bundleLogs       = "logs.jsonl"
+	bundleTranscript = "transcript.md"
+	bundleAudit      = "audit.jsonl"
+	bundleArtifacts  = "artifacts/"
+)
+
+// BundleManifest describes the task a bundle holds
+type BundleManifest struct {
+	Version    int       `json:"version"`
+	TaskID     string    `json:"task_id"`
+	Goal       string    `json:"goal"`
+	Artifacts  int       `json:"artifacts"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// WriteBundle writes a finished task to w as a single compressed archive: its record with
+// the plan, logs, transcript and planner audit trail, and the content of each artifact
+func WriteBundle(w io.Writer, record *TaskExecution, exportedAt time.Time) error {
+	if !record.Status.IsTerminal() {
+		return fmt.Errorf("task %s is %s: only finished tasks can be bundled", record.ID, record.Status)
+	}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	if err := writeBundleFiles(tw, record, exportedAt); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+func writeBundleFiles(tw *tar.Writer, record *TaskExecution, exportedAt time.Time) error {
+	manifest, err := json.MarshalIndent(BundleManifest{
+		Version:    BundleVersion,
+		TaskID:     record.ID,
+		Goal:       record.Goal,
+		Artifacts:  len(record.Artifacts),
+		ExportedAt: exportedAt,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode task %s: %w", record.ID, err)
+	}
+	logs, err := jsonLines(record.Logs)
+	if err != nil {
+		return fmt.Errorf("failed to encode task log: %w", err)
+	}
+	audit, err := jsonLines(record.PlannerEvents)
+	if err != nil {
+		return fmt.Errorf("failed to encode planner events: %w", err)
+	}
+	files := map[string][]byte{
+		bundleManifest:   manifest,
+		bundleRecord:     data,
+		bundleLogs:       logs,
+		bundleTranscript: []byte(record.Transcript("")),
+		bundleAudit:      audit,
+	}
+	if record.Plan != nil {
+		if files[bundlePlan], err = captain.ExportPlan(record.Plan, captain.PlanFormatYAML); err != nil {
+			return err
+		}
+	}
+
+	// The manifest and record come first so a reader knows what it is loading before the artifacts
+	for _, name := range []string{bundleManifest, bundleRecord, bundlePlan, bundleLogs, bundleTranscript, bundleAudit} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := writeBundleFile(tw, name, exportedAt, int64(len(content)), bytes.NewReader(content)); err != nil {
+			return err
+		}
+	}
+	for _, artifact := range record.Artifacts {
+		if err := writeBundleArtifact(tw, artifact); err != nil {
+			return fmt.Errorf("artifact %s: %w", artifact.Name, err)
+		}
+	}
+	return nil
+}
+
+// jsonLines encodes each value on a line of its own
+func jsonLines[T any](values []T) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, value := range values {
+		if err := enc.Encode(value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeBundleArtifact(tw *tar.Writer, artifact Artifact) error {
+	f, err := os.Open(artifact.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeBundleFile(tw, bundleArtifacts+filepath.Base(artifact.Path), info.ModTime(), info.Size(), f)
+}
+
+func writeBundleFile(tw *tar.Writer, name string, modified time.Time, size int64, content io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: modified, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+	}
+	if _, err := io.Copy(tw, content); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+	}
+	return nil
+}
+
+// ReadBundle loads a bundle written by WriteBundle into storage, copying its artifacts into
+// store. A task that is already stored is not replaced.
+func ReadBundle(r io.Reader, storage TaskStorage, store *ArtifactStore) (*TaskExecution, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a task bundle: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	var manifest *BundleManifest
+	var record *TaskExecution
+	var staged []string
+	var stageDir string
+	defer func() {
+		if stageDir != "" {
+			os.RemoveAll(stageDir)
+		}
+	}()
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		switch name := header.Name; {
+		case name == bundleManifest:
+			manifest = &BundleManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+			}
+			if manifest.Version > BundleVersion {
+				return nil, fmt.Errorf("bundle format %d is newer than this capn supports (%d)", manifest.Version, BundleVersion)
+			}
+		case name == bundleRecord:
+			record = &TaskExecution{}
+			if err := json.NewDecoder(tr).Decode(record); err != nil {
+				return nil, fmt.Errorf("invalid task record in bundle: %w", err)
+			}
+		case strings.HasPrefix(name, bundleArtifacts):
+			// Artifacts are staged until the whole bundle has been read and the task found to be new
+			artifact, err := artifactName(strings.TrimPrefix(name, bundleArtifacts))
+			if err != nil {
+				return nil, err
+			}
+			if stageDir == "" {
+				if stageDir, err = os.MkdirTemp(store.dir, ".import-"); err != nil {
+					return nil, fmt.Errorf("failed to stage artifacts: %w", err)
+				}
+			}
+			if err := stageArtifact(filepath.Join(stageDir, artifact), tr); err != nil {
+				return nil, fmt.Errorf("failed to stage artifact %s: %w", artifact, err)
+			}
+			staged = append(staged, artifact)
+		}
+	}
+	if manifest == nil || record == nil {
+		return nil, errors.New("not a task bundle: manifest or task record missing")
+	}
+	if record.ID == "" || record.ID == ".." || strings.ContainsAny(record.ID, `/\`) {
+		return nil, fmt.Errorf("invalid task ID in bundle: %q", record.ID)
+	}
+	if record.ID != manifest.TaskID {
+		return nil, fmt.Errorf("bundle manifest names task %s but holds %s", manifest.TaskID, record.ID)
+	}
+	if _, err := storage.GetTask(record.ID); err == nil {
+		return nil, fmt.Errorf("task %s already exists", record.ID)
+	}
+
+	if len(staged) > 0 {
+		dir := store.TaskDir(record.ID)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create artifacts directory %s: %w", dir, err)
+		}
+		for _, name := range staged {
+			if err := os.Rename(filepath.Join(stageDir, name), filepath.Join(dir, name)); err != nil {
+				return nil, fmt.Errorf("failed to import artifact %s: %w", name, err)
+			}
+		}
+	}
+	// Artifact paths pointed into the exporting machine's store
+	for i, artifact := range record.Artifacts {
+		record.Artifacts[i].Path = filepath.Join(store.TaskDir(record.ID), filepath.Base(artifact.Path))
+	}
+	if err := storage.SaveTask(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func stageArtifact(path string, content io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
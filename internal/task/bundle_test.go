@@ -0,0 +1,110 @@
+package task
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// bundledTask returns a finished task with a plan, a log and one stored artifact
+func bundledTask(t *testing.T, store *ArtifactStore) *TaskExecution {
+	t.Helper()
+	record := NewTaskExecution("summarize the logs")
+	record.Plan = &captain.ExecutionPlan{ID: "plan-1", Goal: record.Goal, Strategy: captain.ExecutionStrategy{Type: captain.StrategySequential}}
+	record.AddStepLog(LogLevelInfo, "step-1", "", "High-risk step approved")
+	record.PlannerEvents = []captain.PlannerEvent{{Kind: captain.PlannerEventPrompt, Content: "plan this"}}
+	artifact, err := store.Save(record.ID, "step-1", "file-001", agents.Artifact{Name: "summary.md", Content: []byte("# Summary\n")})
+	require.NoError(t, err)
+	record.Artifacts = append(record.Artifacts, artifact)
+	record.SetStatus(TaskStatusCompleted)
+	return record
+}
+
+// bundleFiles lists the files of a bundle with their contents
+func bundleFiles(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+func TestBundle_RoundTrip(t *testing.T) {
+	exporting, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+	record := bundledTask(t, exporting)
+
+	var bundle bytes.Buffer
+	require.NoError(t, WriteBundle(&bundle, record, time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)))
+
+	files := bundleFiles(t, bundle.Bytes())
+	assert.Contains(t, files["manifest.json"], `"task_id": "`+record.ID+`"`)
+	assert.Contains(t, files["plan.yaml"], "id: plan-1")
+	assert.Contains(t, files["logs.jsonl"], "High-risk step approved")
+	assert.Contains(t, files["transcript.md"], "summarize the logs")
+	assert.Contains(t, files["audit.jsonl"], "plan this")
+	assert.Equal(t, "# Summary\n", files["artifacts/summary.md"])
+
+	storage := NewMemoryTaskStorage()
+	importing, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+	imported, err := ReadBundle(bytes.NewReader(bundle.Bytes()), storage, importing)
+	require.NoError(t, err)
+	assert.Equal(t, record.ID, imported.ID)
+	assert.Equal(t, TaskStatusCompleted, imported.Status)
+	require.Len(t, imported.Artifacts, 1)
+	assert.Equal(t, importing.TaskDir(record.ID), filepath.Dir(imported.Artifacts[0].Path), "artifacts point into the local store")
+	data, err := os.ReadFile(imported.Artifacts[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Summary\n", string(data))
+
+	stored, err := storage.GetTask(record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, record.Logs[0].Message, stored.Logs[0].Message)
+
+	_, err = ReadBundle(bytes.NewReader(bundle.Bytes()), storage, importing)
+	assert.EqualError(t, err, "task "+record.ID+" already exists")
+}
+
+func TestWriteBundle_RefusesUnfinishedTasks(t *testing.T) {
+	record := NewTaskExecution("still going")
+	record.SetStatus(TaskStatusRunning)
+	err := WriteBundle(io.Discard, record, time.Now())
+	assert.EqualError(t, err, "task "+record.ID+" is running: only finished tasks can be bundled")
+}
+
+func TestReadBundle_Invalid(t *testing.T) {
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = ReadBundle(bytes.NewReader([]byte("plain text")), NewMemoryTaskStorage(), store)
+	assert.ErrorContains(t, err, "not a task bundle")
+
+	var empty bytes.Buffer
+	zw := gzip.NewWriter(&empty)
+	require.NoError(t, tar.NewWriter(zw).Close())
+	require.NoError(t, zw.Close())
+	_, err = ReadBundle(&empty, NewMemoryTaskStorage(), store)
+	assert.EqualError(t, err, "not a task bundle: manifest or task record missing")
+}
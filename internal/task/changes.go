@@ -0,0 +1,27 @@
+package task
+
+const (
+	// MetadataGitRepo holds the top-level directory of the git repository a task ran in
+	MetadataGitRepo = "git_repo"
+	// MetadataGitBefore holds the snapshot commit of the working tree before the task ran
+	MetadataGitBefore = "git_before"
+	// MetadataGitAfter holds the snapshot commit of the working tree after the task ran
+	MetadataGitAfter = "git_after"
+	// MetadataGitRolledBack records when the task's changes were rolled back
+	MetadataGitRolledBack = "git_rolled_back"
+)
+
+// gitMetadata lists the metadata keys describing a task's own repository changes
+var gitMetadata = []string{MetadataGitRepo, MetadataGitBefore, MetadataGitAfter, MetadataGitRolledBack}
+
+// GitChanges returns the repository and the snapshots taken before and after the task ran.
+// ok is false unless both snapshots were recorded.
+func (t *TaskExecution) GitChanges() (repo, before, after string, ok bool) {
+	repo, before, after = t.Metadata[MetadataGitRepo], t.Metadata[MetadataGitBefore], t.Metadata[MetadataGitAfter]
+	return repo, before, after, repo != "" && before != "" && after != ""
+}
+
+// GitSnapshotRef returns the ref that keeps one of a task's snapshots, when is "before" or "after"
+func GitSnapshotRef(taskID, when string) string {
+	return "refs/capn/tasks/" + taskID + "/" + when
+}
@@ -0,0 +1,41 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskExecution_GitChanges(t *testing.T) {
+	record := NewTaskExecution("goal")
+	_, _, _, ok := record.GitChanges()
+	assert.False(t, ok)
+
+	record.Metadata[MetadataGitRepo] = "/src/app"
+	record.Metadata[MetadataGitBefore] = "abc"
+	_, _, _, ok = record.GitChanges()
+	assert.False(t, ok, "a task without an after snapshot cannot be rolled back")
+
+	record.Metadata[MetadataGitAfter] = "def"
+	repo, before, after, ok := record.GitChanges()
+	require.True(t, ok)
+	assert.Equal(t, []string{"/src/app", "abc", "def"}, []string{repo, before, after})
+	assert.Equal(t, "refs/capn/tasks/"+record.ID+"/before", GitSnapshotRef(record.ID, "before"))
+}
+
+func TestNewRetry_DropsGitChanges(t *testing.T) {
+	original := NewTaskExecution("goal")
+	original.Metadata[MetadataGitRepo] = "/src/app"
+	original.Metadata[MetadataGitBefore] = "abc"
+	original.Metadata[MetadataGitAfter] = "def"
+	original.Metadata["custom"] = "kept"
+	original.SetStatus(TaskStatusFailed)
+
+	retry, err := NewRetry(original, false)
+	require.NoError(t, err)
+	_, _, _, ok := retry.GitChanges()
+	assert.False(t, ok)
+	assert.Empty(t, retry.Metadata[MetadataGitBefore])
+	assert.Equal(t, "kept", retry.Metadata["custom"])
+}
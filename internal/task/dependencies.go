@@ -0,0 +1,187 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// DependencyCondition is what a task waits for another task to reach before it starts
+type DependencyCondition string
+
+const (
+	// DependencyCompleted waits for the other task to complete; its failing fails the dependent task
+	DependencyCompleted DependencyCondition = "completed"
+	// DependencyFinished waits for the other task to finish, whatever its status
+	DependencyFinished DependencyCondition = "any"
+	// DependencySucceeded waits for the other task to complete with every plan step succeeded,
+	// none skipped
+	DependencySucceeded DependencyCondition = "success"
+)
+
+// Dependency is another task a task waits for before it starts
+type Dependency struct {
+	TaskID    string              `json:"task_id"`
+	Condition DependencyCondition `json:"condition"`
+}
+
+// String describes the dependency, such as "task-1a2b3c4d completes"
+func (d Dependency) String() string {
+	switch d.Condition {
+	case DependencyFinished:
+		return d.TaskID + " finishes"
+	case DependencySucceeded:
+		return d.TaskID + " succeeds"
+	default:
+		return d.TaskID + " completes"
+	}
+}
+
+// Met reports whether the task the dependency names satisfies it. It returns an error when
+// the task finished in a way that never will.
+func (d Dependency) Met(t *TaskExecution) (bool, error) {
+	if d.Condition == DependencyFinished {
+		return t.Status.IsTerminal(), nil
+	}
+	if !t.Status.IsTerminal() {
+		return false, nil
+	}
+	if t.Status != TaskStatusCompleted {
+		return false, fmt.Errorf("dependency %s is %s", d.TaskID, t.Status)
+	}
+	if d.Condition == DependencySucceeded {
+		for step, status := range t.StepStatuses() {
+			if status != captain.StepStatusSucceeded {
+				return false, fmt.Errorf("dependency %s completed with step %s %s", d.TaskID, step, status)
+			}
+		}
+	}
+	return true, nil
+}
+
+// WaitForDependencies waits until every task the record runs after satisfies its dependency.
+// The record is saved as queued while it waits and fails fast when a dependency is missing,
+// forms a cycle or can no longer be met. onWaiting is called once with the dependencies still
+// pending, when it has to wait.
+func (q *Queue) WaitForDependencies(ctx context.Context, record *TaskExecution, onWaiting func(pending []Dependency)) error {
+	if len(record.After) == 0 {
+		return nil
+	}
+	if err := checkDependencyCycle(q.storage, record); err != nil {
+		return err
+	}
+
+	record.QueuedAt = q.clock.Now()
+	record.SetStatus(TaskStatusQueued)
+	if err := q.storage.SaveTask(record); err != nil {
+		return fmt.Errorf("failed to queue task: %w", err)
+	}
+
+	reported := false
+	ticker := q.clock.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		pending, err := q.pendingDependencies(record)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			if reported {
+				record.AddLog(LogLevelInfo, fmt.Sprintf("Dependencies met after waiting %s", q.clock.Since(record.QueuedAt).Round(time.Millisecond)))
+			}
+			return nil
+		}
+		if !reported {
+			record.AddLog(LogLevelInfo, "Waiting until "+DescribeDependencies(pending))
+			if onWaiting != nil {
+				onWaiting(pending)
+			}
+			reported = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled while waiting for dependencies: %w", ctx.Err())
+		case <-ticker.C():
+		}
+	}
+}
+
+// pendingDependencies returns the record's dependencies not yet met
+func (q *Queue) pendingDependencies(record *TaskExecution) ([]Dependency, error) {
+	var pending []Dependency
+	for _, dep := range record.After {
+		other, err := q.storage.GetTask(dep.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", dep.TaskID, err)
+		}
+		met, err := dep.Met(other)
+		if err != nil {
+			return nil, err
+		}
+		if !met {
+			pending = append(pending, dep)
+		}
+	}
+	return pending, nil
+}
+
+// dependenciesMet reports whether every dependency of a task is met, looking tasks up in byID.
+// A missing or unsatisfiable dependency counts as unmet.
+func dependenciesMet(t *TaskExecution, byID map[string]*TaskExecution) bool {
+	for _, dep := range t.After {
+		other, ok := byID[dep.TaskID]
+		if !ok {
+			return false
+		}
+		if met, err := dep.Met(other); !met || err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDependencyCycle fails when a dependency is missing or, directly or through other
+// tasks' dependencies, runs after the record itself
+func checkDependencyCycle(storage TaskStorage, record *TaskExecution) error {
+	visited := make(map[string]bool)
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		path = append(path, id)
+		if id == record.ID {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+		t, err := storage.GetTask(id)
+		if err != nil {
+			return fmt.Errorf("dependency %s: %w", id, err)
+		}
+		for _, dep := range t.After {
+			if err := visit(dep.TaskID, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, dep := range record.After {
+		if err := visit(dep.TaskID, []string{record.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DescribeDependencies joins dependencies into prose, such as "task-1 completes and task-2 finishes"
+func DescribeDependencies(deps []Dependency) string {
+	parts := make([]string, len(deps))
+	for i, dep := range deps {
+		parts[i] = dep.String()
+	}
+	return strings.Join(parts, " and ")
+}
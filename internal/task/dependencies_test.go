@@ -0,0 +1,105 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestDependency_Met(t *testing.T) {
+	withSteps := func(status TaskStatus, results ...captain.Result) *TaskExecution {
+		return &TaskExecution{ID: "task-1", Status: status, Plan: &captain.ExecutionPlan{Tasks: []captain.Task{{ID: "build"}}}, Results: results}
+	}
+	succeeded := captain.Result{TaskID: "build", Success: true}
+	skipped := captain.Result{TaskID: "build", Success: true, Metadata: map[string]any{"skipped": true}}
+
+	tests := []struct {
+		name      string
+		condition DependencyCondition
+		task      *TaskExecution
+		met       bool
+		err       string
+	}{
+		{"completed waits while running", DependencyCompleted, withSteps(TaskStatusRunning), false, ""},
+		{"completed", DependencyCompleted, withSteps(TaskStatusCompleted, skipped), true, ""},
+		{"completed fails with the task", DependencyCompleted, withSteps(TaskStatusFailed), false, "dependency task-1 is failed"},
+		{"any accepts failure", DependencyFinished, withSteps(TaskStatusCancelled), true, ""},
+		{"any waits while queued", DependencyFinished, withSteps(TaskStatusQueued), false, ""},
+		{"success", DependencySucceeded, withSteps(TaskStatusCompleted, succeeded), true, ""},
+		{"success refuses skipped steps", DependencySucceeded, withSteps(TaskStatusCompleted, skipped), false, "dependency task-1 completed with step build skipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			met, err := Dependency{TaskID: "task-1", Condition: tt.condition}.Met(tt.task)
+			assert.Equal(t, tt.met, met)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestQueue_WaitForDependencies(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	build := NewTaskExecution("build")
+	build.SetStatus(TaskStatusRunning)
+	require.NoError(t, storage.SaveTask(build))
+
+	queue := NewQueue(storage, 1)
+	queue.SetPollInterval(time.Minute)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	queue.SetClock(clock)
+	deploy := NewTaskExecution("deploy")
+	deploy.After = []Dependency{{TaskID: build.ID, Condition: DependencyCompleted}}
+
+	waiting := make(chan []Dependency, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- queue.WaitForDependencies(context.Background(), deploy, func(pending []Dependency) { waiting <- pending })
+	}()
+	assert.Equal(t, deploy.After, <-waiting)
+
+	positions, err := queue.Positions()
+	require.NoError(t, err)
+	assert.Empty(t, positions, "a task waiting for dependencies holds no queue position")
+
+	finished := *build
+	finished.SetStatus(TaskStatusCompleted)
+	require.NoError(t, storage.SaveTask(&finished))
+	clock.Advance(time.Minute)
+
+	require.NoError(t, <-done, "the dependency releases the task at the next poll")
+	assert.Equal(t, TaskStatusQueued, deploy.Status)
+	assert.Equal(t, "Dependencies met after waiting 1m0s", deploy.Logs[len(deploy.Logs)-1].Message)
+}
+
+func TestQueue_WaitForDependencies_Fails(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	queue := NewQueue(storage, 0)
+
+	failed := NewTaskExecution("build")
+	failed.SetStatus(TaskStatusFailed)
+	require.NoError(t, storage.SaveTask(failed))
+	te := NewTaskExecution("deploy")
+	te.After = []Dependency{{TaskID: failed.ID, Condition: DependencyCompleted}}
+	assert.EqualError(t, queue.WaitForDependencies(context.Background(), te, nil), "dependency "+failed.ID+" is failed")
+
+	te = NewTaskExecution("deploy")
+	te.After = []Dependency{{TaskID: "task-missing"}}
+	assert.EqualError(t, queue.WaitForDependencies(context.Background(), te, nil), "dependency task-missing: task not found: task-missing")
+
+	// a waits for b, which is retried to wait for a
+	a := &TaskExecution{ID: "task-a", Status: TaskStatusQueued, After: []Dependency{{TaskID: "task-b"}}}
+	require.NoError(t, storage.SaveTask(a))
+	b := &TaskExecution{ID: "task-b", Status: TaskStatusPending, After: []Dependency{{TaskID: "task-a"}}}
+	require.NoError(t, storage.SaveTask(b))
+	assert.EqualError(t, queue.WaitForDependencies(context.Background(), b, nil), "dependency cycle: task-b -> task-a -> task-b")
+}
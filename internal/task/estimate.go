@@ -0,0 +1,157 @@
+package task
+
+import (
+	"slices"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+const (
+	// MetadataEstimatedDuration holds the duration predicted for a task when it started running
+	MetadataEstimatedDuration = "estimated_duration"
+	// MetadataETA holds when a running task is expected to finish, updated as its steps complete
+	MetadataETA = "eta"
+)
+
+// Estimator predicts how long plan steps take from the durations of steps that ran before.
+// Steps of a type with no history fall back to the durations of all steps, then to the
+// plan's own estimate.
+type Estimator struct {
+	byType map[captain.TaskType][]time.Duration
+	all    []time.Duration
+}
+
+// NewEstimator creates an estimator from the successful steps of previously recorded tasks
+func NewEstimator(history []*TaskExecution) *Estimator {
+	e := &Estimator{byType: make(map[captain.TaskType][]time.Duration)}
+	for _, t := range history {
+		if t.Plan == nil {
+			continue
+		}
+		types := make(map[string]captain.TaskType, len(t.Plan.Tasks))
+		for _, step := range t.Plan.Tasks {
+			types[step.ID] = step.Type
+		}
+		for _, result := range t.Results {
+			stepType, ok := types[result.TaskID]
+			if !ok || !result.Success || result.Duration <= 0 {
+				continue
+			}
+			e.byType[stepType] = append(e.byType[stepType], result.Duration)
+			e.all = append(e.all, result.Duration)
+		}
+	}
+	return e
+}
+
+// StepDuration returns the median duration of earlier steps of the same type, or of all
+// earlier steps when none share the type. It reports false without any history.
+func (e *Estimator) StepDuration(step captain.Task) (time.Duration, bool) {
+	if durations := e.byType[step.Type]; len(durations) > 0 {
+		return median(durations), true
+	}
+	if len(e.all) > 0 {
+		return median(e.all), true
+	}
+	return 0, false
+}
+
+// Remaining estimates how long the steps of the plan that are not done will take to run one
+// after another. It reports false when neither history nor the plan gives an estimate.
+func (e *Estimator) Remaining(plan *captain.ExecutionPlan, done map[string]bool) (time.Duration, bool) {
+	if plan == nil || len(plan.Tasks) == 0 {
+		return 0, false
+	}
+	// The plan's estimate, spread evenly over its steps, covers steps without history
+	planned := plan.Timeline.EstimatedDuration / time.Duration(len(plan.Tasks))
+
+	var remaining time.Duration
+	known := true
+	for _, step := range plan.Tasks {
+		if done[step.ID] {
+			continue
+		}
+		if d, ok := e.StepDuration(step); ok {
+			remaining += d
+		} else if planned > 0 {
+			remaining += planned
+		} else {
+			known = false
+		}
+	}
+	return remaining, known
+}
+
+// median returns the middle of durations, averaging the two middle values of an even count
+func median(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// EstimatedDuration returns the duration predicted for the task when it started running
+func (t *TaskExecution) EstimatedDuration() (time.Duration, bool) {
+	d, err := time.ParseDuration(t.Metadata[MetadataEstimatedDuration])
+	return d, err == nil
+}
+
+// ETA returns when an unfinished task is expected to finish
+func (t *TaskExecution) ETA() (time.Time, bool) {
+	if t.Status.IsTerminal() {
+		return time.Time{}, false
+	}
+	eta, err := time.Parse(time.RFC3339, t.Metadata[MetadataETA])
+	return eta, err == nil
+}
+
+// SetEstimate records the predicted remaining duration of the task and the ETA it implies,
+// keeping the first prediction as the task's estimated duration
+func (t *TaskExecution) SetEstimate(remaining time.Duration, now time.Time) {
+	if t.Metadata == nil {
+		t.Metadata = make(map[string]string)
+	}
+	if _, ok := t.Metadata[MetadataEstimatedDuration]; !ok {
+		t.Metadata[MetadataEstimatedDuration] = remaining.Round(time.Second).String()
+	}
+	t.Metadata[MetadataETA] = now.Add(remaining).Format(time.RFC3339)
+}
+
+// EstimateAccuracy compares predicted and actual durations of completed tasks
+type EstimateAccuracy struct {
+	Tasks int
+	// MeanError is the mean absolute error as a fraction of the actual duration
+	MeanError float64
+	// Bias is the mean signed error as a fraction of the actual duration; negative when
+	// tasks take longer than predicted
+	Bias float64
+}
+
+// MeasureEstimates returns the accuracy of the estimates recorded for completed tasks
+func MeasureEstimates(tasks []*TaskExecution) EstimateAccuracy {
+	var accuracy EstimateAccuracy
+	var absolute, signed float64
+	for _, t := range tasks {
+		predicted, ok := t.EstimatedDuration()
+		actual := t.Duration()
+		if !ok || t.Status != TaskStatusCompleted || actual <= 0 {
+			continue
+		}
+		errorFraction := float64(predicted-actual) / float64(actual)
+		signed += errorFraction
+		if errorFraction < 0 {
+			errorFraction = -errorFraction
+		}
+		absolute += errorFraction
+		accuracy.Tasks++
+	}
+	if accuracy.Tasks > 0 {
+		accuracy.MeanError = absolute / float64(accuracy.Tasks)
+		accuracy.Bias = signed / float64(accuracy.Tasks)
+	}
+	return accuracy
+}
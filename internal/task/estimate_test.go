@@ -0,0 +1,131 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// timedTask returns a completed task whose build and test steps took the given durations
+func timedTask(build, test time.Duration) *TaskExecution {
+	te := NewTaskExecution("build and test")
+	te.Plan = &captain.ExecutionPlan{
+		ID: "plan-1",
+		Tasks: []captain.Task{
+			{ID: "build", Type: captain.TaskTypeExecution},
+			{ID: "test", Type: captain.TaskTypeValidation},
+		},
+	}
+	te.Results = []captain.Result{
+		{TaskID: "build", Success: true, Duration: build},
+		{TaskID: "test", Success: true, Duration: test},
+	}
+	te.SetStatus(TaskStatusCompleted)
+	return te
+}
+
+func TestEstimator_StepDuration(t *testing.T) {
+	failed := timedTask(time.Hour, time.Hour)
+	failed.Results[0].Success = false
+	estimator := NewEstimator([]*TaskExecution{
+		timedTask(10*time.Second, time.Minute),
+		timedTask(20*time.Second, 3*time.Minute),
+		timedTask(60*time.Second, 2*time.Minute),
+		failed,
+		NewTaskExecution("never planned"),
+	})
+
+	d, ok := estimator.StepDuration(captain.Task{Type: captain.TaskTypeExecution})
+	require.True(t, ok)
+	assert.Equal(t, 20*time.Second, d, "the median ignores the outlier")
+
+	d, ok = estimator.StepDuration(captain.Task{Type: captain.TaskTypeValidation})
+	require.True(t, ok)
+	assert.Equal(t, 150*time.Second, d, "successful steps of failed tasks count too")
+
+	d, ok = estimator.StepDuration(captain.Task{Type: captain.TaskTypeReporting})
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, d, "types without history use every step")
+
+	_, ok = NewEstimator(nil).StepDuration(captain.Task{Type: captain.TaskTypeReporting})
+	assert.False(t, ok)
+}
+
+func TestEstimator_Remaining(t *testing.T) {
+	plan := &captain.ExecutionPlan{
+		Tasks: []captain.Task{
+			{ID: "build", Type: captain.TaskTypeExecution},
+			{ID: "test", Type: captain.TaskTypeValidation},
+			{ID: "ship", Type: captain.TaskTypeExecution},
+		},
+		Timeline: captain.ExecutionTimeline{EstimatedDuration: 30 * time.Minute},
+	}
+
+	estimator := NewEstimator([]*TaskExecution{timedTask(time.Minute, 2*time.Minute)})
+	remaining, ok := estimator.Remaining(plan, nil)
+	require.True(t, ok)
+	assert.Equal(t, 4*time.Minute, remaining)
+
+	remaining, ok = estimator.Remaining(plan, map[string]bool{"build": true, "test": true})
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, remaining)
+
+	// Without history the plan's estimate is spread over its steps
+	remaining, ok = NewEstimator(nil).Remaining(plan, map[string]bool{"build": true})
+	require.True(t, ok)
+	assert.Equal(t, 20*time.Minute, remaining)
+
+	plan.Timeline.EstimatedDuration = 0
+	_, ok = NewEstimator(nil).Remaining(plan, nil)
+	assert.False(t, ok)
+	_, ok = estimator.Remaining(nil, nil)
+	assert.False(t, ok)
+}
+
+func TestTaskExecution_SetEstimate(t *testing.T) {
+	te := NewTaskExecution("build and test")
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	_, ok := te.ETA()
+	assert.False(t, ok)
+
+	te.SetEstimate(5*time.Minute, now)
+	te.SetEstimate(2*time.Minute, now.Add(4*time.Minute))
+
+	estimate, ok := te.EstimatedDuration()
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Minute, estimate, "the first prediction is kept")
+	eta, ok := te.ETA()
+	require.True(t, ok)
+	assert.Equal(t, now.Add(6*time.Minute), eta.UTC())
+
+	te.SetStatus(TaskStatusCompleted)
+	_, ok = te.ETA()
+	assert.False(t, ok, "finished tasks have no ETA")
+}
+
+func TestMeasureEstimates(t *testing.T) {
+	estimated := func(status TaskStatus, predicted, actual time.Duration) *TaskExecution {
+		te := NewTaskExecution("goal")
+		te.Metadata[MetadataEstimatedDuration] = predicted.String()
+		te.StartedAt = time.Now().Add(-actual)
+		te.Status = status
+		te.CompletedAt = te.StartedAt.Add(actual)
+		return te
+	}
+
+	accuracy := MeasureEstimates([]*TaskExecution{
+		estimated(TaskStatusCompleted, 90*time.Second, time.Minute),
+		estimated(TaskStatusCompleted, 3*time.Minute, 4*time.Minute),
+		estimated(TaskStatusFailed, time.Minute, time.Hour),
+		timedTask(time.Second, time.Second),
+	})
+	assert.Equal(t, 2, accuracy.Tasks)
+	assert.InDelta(t, 0.375, accuracy.MeanError, 0.0001)
+	assert.InDelta(t, 0.125, accuracy.Bias, 0.0001)
+
+	assert.Equal(t, EstimateAccuracy{}, MeasureEstimates(nil))
+}
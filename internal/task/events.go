@@ -0,0 +1,111 @@
+package task
+
+import (
+	"slices"
+
+	"github.com/iainlowe/capn/internal/events"
+)
+
+// PublishingStorage wraps a TaskStorage and publishes lifecycle events for every change it saves
+type PublishingStorage struct {
+	TaskStorage
+	bus *events.Bus
+}
+
+// NewPublishingStorage creates a storage that publishes task events on bus
+func NewPublishingStorage(storage TaskStorage, bus *events.Bus) *PublishingStorage {
+	return &PublishingStorage{
+		TaskStorage: storage,
+		bus:         bus,
+	}
+}
+
+// AppendLogs adds entries to a stored task's log; log entries publish no events
+func (s *PublishingStorage) AppendLogs(id string, entries ...LogEntry) error {
+	return AppendLogs(s.TaskStorage, id, entries...)
+}
+
+// SaveTask saves the task and publishes created, status changed, question and step completed events
+func (s *PublishingStorage) SaveTask(t *TaskExecution) error {
+	// A missing previous version means the task is new
+	previous, _ := s.TaskStorage.GetTask(t.ID)
+
+	if err := s.TaskStorage.SaveTask(t); err != nil {
+		return err
+	}
+
+	switch {
+	case previous == nil:
+		s.bus.Publish(events.Event{
+			Type:    events.EventTaskCreated,
+			TaskID:  t.ID,
+			Status:  string(t.Status),
+			Message: t.Goal,
+			Data:    withTags(nil, t),
+		})
+	case previous.Status != t.Status:
+		s.bus.Publish(events.Event{
+			Type:   events.EventTaskStatusChanged,
+			TaskID: t.ID,
+			Status: string(t.Status),
+			Data:   withTags(map[string]any{"previous": string(previous.Status)}, t),
+		})
+	}
+
+	for _, q := range t.Questions {
+		var before Question
+		known := false
+		if previous != nil {
+			before, known = previous.Question(q.ID)
+		}
+		switch {
+		case !known:
+			s.bus.Publish(events.Event{
+				Type:    events.EventQuestionAsked,
+				TaskID:  t.ID,
+				Step:    q.Step,
+				Agent:   q.Agent,
+				Message: q.Text,
+				Data:    map[string]any{"question_id": q.ID},
+			})
+		case q.Answered() && !before.Answered():
+			s.bus.Publish(events.Event{
+				Type:    events.EventQuestionAnswered,
+				TaskID:  t.ID,
+				Step:    q.Step,
+				Agent:   q.Agent,
+				Message: q.Answer,
+				Data:    map[string]any{"question_id": q.ID},
+			})
+		}
+	}
+
+	seen := 0
+	if previous != nil {
+		seen = len(previous.Results)
+	}
+	for _, result := range t.Results[min(seen, len(t.Results)):] {
+		agent, _ := result.Metadata["agent_id"].(string)
+		s.bus.Publish(events.Event{
+			Type:    events.EventStepCompleted,
+			TaskID:  t.ID,
+			Step:    result.TaskID,
+			Agent:   agent,
+			Message: result.Error,
+			Data:    map[string]any{"success": result.Success},
+		})
+	}
+	return nil
+}
+
+// withTags adds the task's tags to event data so subscribers can route notifications by tag
+func withTags(data map[string]any, t *TaskExecution) map[string]any {
+	if len(t.Tags) == 0 {
+		return data
+	}
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["tags"] = slices.Clone(t.Tags)
+	return data
+}
@@ -0,0 +1,99 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/events"
+)
+
+func TestPublishingStorage_SaveTask(t *testing.T) {
+	bus := events.NewBus()
+	sub, err := bus.Subscribe(events.SubscribeOptions{})
+	require.NoError(t, err)
+
+	storage := NewPublishingStorage(NewMemoryTaskStorage(), bus)
+
+	te := NewTaskExecution("analyze code")
+	te.Tags = []string{"infra"}
+	require.NoError(t, storage.SaveTask(te))
+
+	created := <-sub.Events()
+	assert.Equal(t, events.EventTaskCreated, created.Type)
+	assert.Equal(t, te.ID, created.TaskID)
+	assert.Equal(t, "analyze code", created.Message)
+	assert.Equal(t, []string{"infra"}, created.Data["tags"])
+
+	// Saving without changes publishes nothing
+	require.NoError(t, storage.SaveTask(te))
+	assert.Len(t, sub.Events(), 0)
+
+	te.SetStatus(TaskStatusRunning)
+	te.Results = []captain.Result{{TaskID: "step-1", Success: true, Metadata: map[string]any{"agent_id": "file-001"}}}
+	require.NoError(t, storage.SaveTask(te))
+
+	changed := <-sub.Events()
+	assert.Equal(t, events.EventTaskStatusChanged, changed.Type)
+	assert.Equal(t, "running", changed.Status)
+	assert.Equal(t, "pending", changed.Data["previous"])
+	assert.Equal(t, []string{"infra"}, changed.Data["tags"])
+
+	step := <-sub.Events()
+	assert.Equal(t, events.EventStepCompleted, step.Type)
+	assert.Equal(t, "step-1", step.Step)
+	assert.Equal(t, "file-001", step.Agent)
+	assert.Equal(t, true, step.Data["success"])
+
+	// Only new results are published
+	te.Results = append(te.Results, captain.Result{TaskID: "step-2", Success: false, Error: "boom"})
+	require.NoError(t, storage.SaveTask(te))
+	step = <-sub.Events()
+	assert.Equal(t, "step-2", step.Step)
+	assert.Equal(t, "boom", step.Message)
+	assert.Len(t, sub.Events(), 0)
+
+	// Reads pass through to the wrapped storage
+	got, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	assert.Len(t, got.Results, 2)
+}
+
+func TestPublishingStorage_SaveError(t *testing.T) {
+	bus := events.NewBus()
+	sub, err := bus.Subscribe(events.SubscribeOptions{})
+	require.NoError(t, err)
+
+	storage := NewPublishingStorage(NewMemoryTaskStorage(), bus)
+	assert.Error(t, storage.SaveTask(&TaskExecution{}))
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestPublishingStorage_Questions(t *testing.T) {
+	bus := events.NewBus()
+	storage := NewPublishingStorage(NewMemoryTaskStorage(), bus)
+	te := NewTaskExecution("goal")
+	require.NoError(t, storage.SaveTask(te))
+
+	sub, err := bus.Subscribe(events.SubscribeOptions{})
+	require.NoError(t, err)
+
+	te.Ask("step-1", "research-001", "Which topic?")
+	require.NoError(t, storage.SaveTask(te))
+	asked := <-sub.Events()
+	assert.Equal(t, events.EventQuestionAsked, asked.Type)
+	assert.Equal(t, "step-1", asked.Step)
+	assert.Equal(t, "research-001", asked.Agent)
+	assert.Equal(t, "Which topic?", asked.Message)
+	assert.Equal(t, "q-1", asked.Data["question_id"])
+
+	_, err = te.AnswerQuestion("q-1", "databases")
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveTask(te))
+	answered := <-sub.Events()
+	assert.Equal(t, events.EventQuestionAnswered, answered.Type)
+	assert.Equal(t, "databases", answered.Message)
+	assert.Len(t, sub.Events(), 0)
+}
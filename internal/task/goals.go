@@ -0,0 +1,36 @@
+package task
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// NormalizeGoal reduces a goal to the form identical goals share: lower case, with runs of
+// whitespace collapsed and trailing full stops removed
+func NormalizeGoal(goal string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(goal)), " ")
+	return strings.TrimRight(normalized, ".")
+}
+
+// GoalHash identifies a goal by the hash of its normalized form
+func GoalHash(goal string) string {
+	sum := sha256.Sum256([]byte(NormalizeGoal(goal)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// FindDuplicate returns the oldest in-flight task of the workspace working on the same goal,
+// or nil when there is none. Tasks orphaned by exited capn processes are not in flight.
+func FindDuplicate(storage TaskStorage, goal, workspace string) (*TaskExecution, error) {
+	tasks, err := storage.ListTasks(TaskFilter{Status: inFlightStatuses, Workspace: workspace, GoalHash: GoalHash(goal)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for tasks with the same goal: %w", err)
+	}
+	for i := len(tasks) - 1; i >= 0; i-- {
+		if !tasks[i].Orphaned() {
+			return tasks[i], nil
+		}
+	}
+	return nil, nil
+}
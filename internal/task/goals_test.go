@@ -0,0 +1,52 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeGoal(t *testing.T) {
+	assert.Equal(t, "deploy the api to staging", NormalizeGoal("  Deploy the\tAPI  to staging. "))
+	assert.Equal(t, GoalHash("Deploy the API to staging"), GoalHash("deploy the api to staging..."))
+	assert.NotEqual(t, GoalHash("deploy the api to staging"), GoalHash("deploy the api to production"))
+	assert.Len(t, GoalHash("goal"), 16)
+}
+
+func TestFindDuplicate(t *testing.T) {
+	fakeProcesses(t)
+	fileStorage, err := NewFileTaskStorage(t.TempDir())
+	require.NoError(t, err)
+	for name, storage := range map[string]TaskStorage{"memory": NewMemoryTaskStorage(), "file": fileStorage} {
+		t.Run(name, func(t *testing.T) {
+			record := func(goal, workspace string, status TaskStatus, age time.Duration) *TaskExecution {
+				te := NewTaskExecution(goal)
+				te.Workspace = workspace
+				te.CreatedAt = time.Now().Add(-age)
+				te.SetStatus(status)
+				require.NoError(t, storage.SaveTask(te))
+				return te
+			}
+			record("Deploy the API", "alpha", TaskStatusCompleted, 3*time.Hour)
+			record("Deploy the API", "beta", TaskStatusRunning, 3*time.Hour)
+			orphan := orphanedTask(TaskStatusRunning)
+			orphan.Goal = "deploy the api"
+			orphan.CreatedAt = time.Now().Add(-2 * time.Hour)
+			require.NoError(t, storage.SaveTask(orphan))
+			oldest := record("deploy the API.", "alpha", TaskStatusQueued, time.Hour)
+			record("Deploy the API", "alpha", TaskStatusRunning, time.Minute)
+			record("Deploy the database", "alpha", TaskStatusRunning, 0)
+
+			found, err := FindDuplicate(storage, "deploy  the api", "alpha")
+			require.NoError(t, err)
+			require.NotNil(t, found)
+			assert.Equal(t, oldest.ID, found.ID, "the oldest in-flight task with the goal is found, skipping orphans")
+
+			found, err = FindDuplicate(storage, "deploy the cache", "alpha")
+			require.NoError(t, err)
+			assert.Nil(t, found)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package task
+
+// GroupKind identifies how tasks in a TaskGroup are related
+type GroupKind string
+
+const (
+	GroupKindPipeline GroupKind = "pipeline"
+	GroupKindBatch    GroupKind = "batch"
+	GroupKindNone     GroupKind = ""
+)
+
+// TaskGroup is a set of tasks shown as a single unit with roll-up progress
+type TaskGroup struct {
+	Kind      GroupKind        `json:"kind"`
+	ID        string           `json:"id,omitempty"`
+	Tasks     []*TaskExecution `json:"tasks"`
+	Completed int              `json:"completed"`
+	Failed    int              `json:"failed"`
+	Running   int              `json:"running"`
+	Pending   int              `json:"pending"`
+}
+
+// Total returns the number of tasks in the group
+func (g *TaskGroup) Total() int {
+	return len(g.Tasks)
+}
+
+// Status returns the roll-up status of the group
+func (g *TaskGroup) Status() TaskStatus {
+	switch {
+	case g.Running > 0:
+		return TaskStatusRunning
+	case g.Pending > 0:
+		return TaskStatusPending
+	case g.Failed > 0:
+		return TaskStatusFailed
+	default:
+		return TaskStatusCompleted
+	}
+}
+
+// add adds a task to the group and updates the counters
+func (g *TaskGroup) add(t *TaskExecution) {
+	g.Tasks = append(g.Tasks, t)
+	switch t.Status {
+	case TaskStatusCompleted:
+		g.Completed++
+	case TaskStatusFailed, TaskStatusCancelled, TaskStatusInterrupted:
+		g.Failed++
+	case TaskStatusRunning, TaskStatusPlanning:
+		g.Running++
+	default:
+		g.Pending++
+	}
+}
+
+// GroupTasks groups tasks by pipeline, then batch; ungrouped tasks each form their own group.
+// Groups appear in the order their first task appears in the input.
+func GroupTasks(tasks []*TaskExecution) []*TaskGroup {
+	var groups []*TaskGroup
+	index := make(map[string]*TaskGroup)
+
+	for _, t := range tasks {
+		kind, id := GroupKindNone, ""
+		switch {
+		case t.PipelineID != "":
+			kind, id = GroupKindPipeline, t.PipelineID
+		case t.BatchID != "":
+			kind, id = GroupKindBatch, t.BatchID
+		}
+
+		if kind == GroupKindNone {
+			group := &TaskGroup{Kind: kind}
+			group.add(t)
+			groups = append(groups, group)
+			continue
+		}
+
+		key := string(kind) + ":" + id
+		group, exists := index[key]
+		if !exists {
+			group = &TaskGroup{Kind: kind, ID: id}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.add(t)
+	}
+	return groups
+}
@@ -0,0 +1,58 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGroupedTask(status TaskStatus, pipeline, batch string) *TaskExecution {
+	te := NewTaskExecution("goal")
+	te.Status = status
+	te.PipelineID = pipeline
+	te.BatchID = batch
+	return te
+}
+
+func TestGroupTasks(t *testing.T) {
+	tasks := []*TaskExecution{
+		newGroupedTask(TaskStatusCompleted, "deploy", ""),
+		newGroupedTask(TaskStatusRunning, "", ""),
+		newGroupedTask(TaskStatusCompleted, "deploy", "nightly"),
+		newGroupedTask(TaskStatusFailed, "", "nightly"),
+		newGroupedTask(TaskStatusRunning, "deploy", ""),
+		newGroupedTask(TaskStatusCompleted, "", "nightly"),
+	}
+
+	groups := GroupTasks(tasks)
+	require.Len(t, groups, 3)
+
+	pipeline := groups[0]
+	assert.Equal(t, GroupKindPipeline, pipeline.Kind)
+	assert.Equal(t, "deploy", pipeline.ID)
+	assert.Equal(t, 3, pipeline.Total())
+	assert.Equal(t, 2, pipeline.Completed)
+	assert.Equal(t, 1, pipeline.Running)
+	assert.Equal(t, TaskStatusRunning, pipeline.Status())
+
+	single := groups[1]
+	assert.Equal(t, GroupKindNone, single.Kind)
+	assert.Equal(t, 1, single.Total())
+
+	batch := groups[2]
+	assert.Equal(t, GroupKindBatch, batch.Kind)
+	assert.Equal(t, "nightly", batch.ID)
+	assert.Equal(t, 2, batch.Total(), "tasks in a pipeline are not counted in their batch")
+	assert.Equal(t, 1, batch.Failed)
+	assert.Equal(t, TaskStatusFailed, batch.Status())
+}
+
+func TestTaskGroup_StatusCompleted(t *testing.T) {
+	groups := GroupTasks([]*TaskExecution{
+		newGroupedTask(TaskStatusCompleted, "p", ""),
+		newGroupedTask(TaskStatusCompleted, "p", ""),
+	})
+	require.Len(t, groups, 1)
+	assert.Equal(t, TaskStatusCompleted, groups[0].Status())
+}
@@ -0,0 +1,261 @@
+package task
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexKey is a task's position in the listing order: newest first, ties broken by ID
+type indexKey struct {
+	createdAt time.Time
+	id        string
+}
+
+// before reports whether k is listed before o
+func (k indexKey) before(o indexKey) bool {
+	if !k.createdAt.Equal(o.createdAt) {
+		return k.createdAt.After(o.createdAt)
+	}
+	return k.id > o.id
+}
+
+// compare orders keys for slices.BinarySearchFunc
+func (k indexKey) compare(o indexKey) int {
+	switch {
+	case k.before(o):
+		return -1
+	case o.before(k):
+		return 1
+	}
+	return 0
+}
+
+// taskIndex keeps task keys in listing order, grouped by status and with their tags,
+// workspaces and goal hashes so filters do not have to load and sort every task. It is not safe for
+// concurrent use; storages guard it with their own lock.
+type taskIndex struct {
+	keys       map[string]indexKey
+	statuses   map[string]TaskStatus
+	order      []indexKey
+	byStatus   map[TaskStatus]map[string]struct{}
+	tags       map[string][]string
+	workspaces map[string]string
+	goals      map[string]string
+}
+
+func newTaskIndex() *taskIndex {
+	return &taskIndex{
+		keys:       make(map[string]indexKey),
+		statuses:   make(map[string]TaskStatus),
+		byStatus:   make(map[TaskStatus]map[string]struct{}),
+		tags:       make(map[string][]string),
+		workspaces: make(map[string]string),
+		goals:      make(map[string]string),
+	}
+}
+
+// put adds the task to the index or updates its entry
+func (ix *taskIndex) put(t *TaskExecution) {
+	key := indexKey{createdAt: t.CreatedAt, id: t.ID}
+	if old, exists := ix.keys[t.ID]; !exists || !old.createdAt.Equal(key.createdAt) {
+		if exists {
+			ix.removeOrder(old)
+		}
+		i, _ := slices.BinarySearchFunc(ix.order, key, indexKey.compare)
+		ix.order = slices.Insert(ix.order, i, key)
+		ix.keys[t.ID] = key
+	}
+
+	if len(t.Tags) > 0 {
+		ix.tags[t.ID] = slices.Clone(t.Tags)
+	} else {
+		delete(ix.tags, t.ID)
+	}
+	if t.Workspace != "" {
+		ix.workspaces[t.ID] = t.Workspace
+	} else {
+		delete(ix.workspaces, t.ID)
+	}
+	ix.goals[t.ID] = GoalHash(t.Goal)
+
+	if old, exists := ix.statuses[t.ID]; exists {
+		if old == t.Status {
+			return
+		}
+		delete(ix.byStatus[old], t.ID)
+	}
+	ix.statuses[t.ID] = t.Status
+	if ix.byStatus[t.Status] == nil {
+		ix.byStatus[t.Status] = make(map[string]struct{})
+	}
+	ix.byStatus[t.Status][t.ID] = struct{}{}
+}
+
+// remove drops a task from the index
+func (ix *taskIndex) remove(id string) {
+	key, exists := ix.keys[id]
+	if !exists {
+		return
+	}
+	ix.removeOrder(key)
+	delete(ix.byStatus[ix.statuses[id]], id)
+	delete(ix.keys, id)
+	delete(ix.statuses, id)
+	delete(ix.tags, id)
+	delete(ix.workspaces, id)
+	delete(ix.goals, id)
+}
+
+func (ix *taskIndex) removeOrder(key indexKey) {
+	if i, found := slices.BinarySearchFunc(ix.order, key, indexKey.compare); found {
+		ix.order = slices.Delete(ix.order, i, i+1)
+	}
+}
+
+// has reports whether the task is indexed
+func (ix *taskIndex) has(id string) bool {
+	_, exists := ix.keys[id]
+	return exists
+}
+
+// ids returns every indexed task ID
+func (ix *taskIndex) ids() []string {
+	ids := make([]string, 0, len(ix.keys))
+	for id := range ix.keys {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// query returns the IDs of the tasks on the page the filter selects, in listing order
+func (ix *taskIndex) query(filter TaskFilter) ([]string, error) {
+	start, end := 0, len(ix.order)
+	if filter.PageToken != "" {
+		cursor, err := decodePageToken(filter.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		i, found := slices.BinarySearchFunc(ix.order, cursor, indexKey.compare)
+		if found {
+			i++
+		}
+		start = max(start, i)
+	}
+	if !filter.Until.IsZero() {
+		start = max(start, ix.firstBefore(filter.Until))
+	}
+	if !filter.Since.IsZero() {
+		end = ix.firstBefore(filter.Since)
+	}
+	if start >= end {
+		return nil, nil
+	}
+	limit := filter.pageLimit()
+
+	// A status filter reads the status sets when that is cheaper than walking the
+	// ordered range, which for a limited page stops after about limit*range/candidates
+	if len(filter.Status) > 0 {
+		candidates := 0
+		for _, status := range filter.uniqueStatuses() {
+			candidates += len(ix.byStatus[status])
+		}
+		walk := end - start
+		if limit > 0 && candidates > 0 {
+			walk = min(walk, limit*(end-start)/candidates)
+		}
+		if candidates < walk {
+			return ix.queryStatuses(filter, start, end, limit), nil
+		}
+	}
+
+	var ids []string
+	for _, key := range ix.order[start:end] {
+		if len(filter.Status) > 0 && !slices.Contains(filter.Status, ix.statuses[key.id]) {
+			continue
+		}
+		if !ix.selects(key.id, filter) {
+			continue
+		}
+		ids = append(ids, key.id)
+		if limit > 0 && len(ids) == limit {
+			break
+		}
+	}
+	return ids, nil
+}
+
+// selects reports whether the task has the filter's tags and goal and is in its workspace
+func (ix *taskIndex) selects(id string, filter TaskFilter) bool {
+	if filter.GoalHash != "" && ix.goals[id] != filter.GoalHash {
+		return false
+	}
+	return hasTags(ix.tags[id], filter.Tags) && inWorkspace(ix.workspaces[id], filter.Workspace)
+}
+
+// queryStatuses selects the page from the status sets of the filter
+func (ix *taskIndex) queryStatuses(filter TaskFilter, start, end, limit int) []string {
+	lo, hi := ix.order[start], ix.order[end-1]
+	var keys []indexKey
+	for _, status := range filter.uniqueStatuses() {
+		for id := range ix.byStatus[status] {
+			key := ix.keys[id]
+			if !key.before(lo) && !hi.before(key) && ix.selects(id, filter) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.SortFunc(keys, indexKey.compare)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.id
+	}
+	return ids
+}
+
+// firstBefore returns the position of the first task created before t
+func (ix *taskIndex) firstBefore(t time.Time) int {
+	i, _ := slices.BinarySearchFunc(ix.order, t, func(key indexKey, t time.Time) int {
+		if key.createdAt.Before(t) {
+			return 1
+		}
+		return -1
+	})
+	return i
+}
+
+// NextPageToken returns the token for the page after tasks, or "" when tasks is the
+// last page. A full page always gets a token, so the following page may be empty.
+func NextPageToken(filter TaskFilter, tasks []*TaskExecution) string {
+	limit := filter.pageLimit()
+	if limit == 0 || len(tasks) < limit {
+		return ""
+	}
+	last := tasks[len(tasks)-1]
+	raw := strconv.FormatInt(last.CreatedAt.UnixNano(), 10) + ":" + last.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodePageToken parses a token produced by NextPageToken
+func decodePageToken(token string) (indexKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return indexKey{}, fmt.Errorf("invalid page token %q", token)
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return indexKey{}, fmt.Errorf("invalid page token %q", token)
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return indexKey{}, fmt.Errorf("invalid page token %q", token)
+	}
+	return indexKey{createdAt: time.Unix(0, n), id: id}, nil
+}
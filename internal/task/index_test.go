@@ -0,0 +1,256 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedOrdered saves n tasks, created a minute apart except for pairs sharing a timestamp
+func seedOrdered(t *testing.T, storage TaskStorage, n int) []string {
+	t.Helper()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	statuses := []TaskStatus{TaskStatusCompleted, TaskStatusFailed}
+	ids := make([]string, n)
+	for i := 0; i < n; i++ {
+		te := NewTaskExecution("goal")
+		te.ID = fmt.Sprintf("task-%03d", i)
+		te.CreatedAt = base.Add(time.Duration(i/2) * time.Minute)
+		te.Status = statuses[i%2]
+		require.NoError(t, storage.SaveTask(te))
+		ids[i] = te.ID
+	}
+	return ids
+}
+
+func taskIDs(tasks []*TaskExecution) []string {
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func TestTaskStorage_StableOrder(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 4)
+
+			tasks, err := storage.ListTasks(TaskFilter{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-003", "task-002", "task-001", "task-000"}, taskIDs(tasks),
+				"tasks created at the same time are ordered by descending ID")
+		})
+	}
+}
+
+func TestTaskStorage_Pagination(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 7)
+
+			var pages [][]string
+			filter := TaskFilter{PageSize: 3}
+			for {
+				tasks, err := storage.ListTasks(filter)
+				require.NoError(t, err)
+				pages = append(pages, taskIDs(tasks))
+				if filter.PageToken = NextPageToken(filter, tasks); filter.PageToken == "" {
+					break
+				}
+			}
+			assert.Equal(t, [][]string{
+				{"task-006", "task-005", "task-004"},
+				{"task-003", "task-002", "task-001"},
+				{"task-000"},
+			}, pages)
+		})
+	}
+}
+
+func TestTaskStorage_PaginationWithStatus(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 8)
+
+			filter := TaskFilter{Status: []TaskStatus{TaskStatusFailed}, PageSize: 2}
+			first, err := storage.ListTasks(filter)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-007", "task-005"}, taskIDs(first))
+
+			filter.PageToken = NextPageToken(filter, first)
+			second, err := storage.ListTasks(filter)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-003", "task-001"}, taskIDs(second))
+
+			filter.PageToken = NextPageToken(filter, second)
+			last, err := storage.ListTasks(filter)
+			require.NoError(t, err)
+			assert.Empty(t, last, "a full last page gets a token leading to an empty page")
+			assert.Empty(t, NextPageToken(filter, last))
+		})
+	}
+}
+
+func TestTaskStorage_TimeRange(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 8)
+			base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			tasks, err := storage.ListTasks(TaskFilter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-005", "task-004", "task-003", "task-002"}, taskIDs(tasks),
+				"since is inclusive and until exclusive")
+
+			tasks, err = storage.ListTasks(TaskFilter{Since: base.Add(2 * time.Minute), Status: []TaskStatus{TaskStatusCompleted}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-006", "task-004"}, taskIDs(tasks))
+		})
+	}
+}
+
+func TestTaskStorage_IndexFollowsUpdates(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 4)
+
+			te, err := storage.GetTask("task-000")
+			require.NoError(t, err)
+			te.Status = TaskStatusFailed
+			require.NoError(t, storage.SaveTask(te))
+			require.NoError(t, storage.DeleteTask("task-001"))
+
+			failed, err := storage.ListTasks(TaskFilter{Status: []TaskStatus{TaskStatusFailed}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-003", "task-000"}, taskIDs(failed))
+
+			completed, err := storage.ListTasks(TaskFilter{Status: []TaskStatus{TaskStatusCompleted}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-002"}, taskIDs(completed))
+		})
+	}
+}
+
+func TestTaskStorage_InvalidPageToken(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := storage.ListTasks(TaskFilter{PageToken: "not a token"})
+			assert.ErrorContains(t, err, "invalid page token")
+		})
+	}
+}
+
+func TestFileTaskStorage_SeesOtherWriters(t *testing.T) {
+	dir := t.TempDir()
+	reader, err := NewFileTaskStorage(dir)
+	require.NoError(t, err)
+	writer, err := NewFileTaskStorage(dir)
+	require.NoError(t, err)
+
+	seedOrdered(t, writer, 2)
+	tasks, err := reader.ListTasks(TaskFilter{Status: []TaskStatus{TaskStatusFailed}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-001"}, taskIDs(tasks))
+
+	// Another process finishes a task and removes another behind the reader's back
+	te, err := writer.GetTask("task-000")
+	require.NoError(t, err)
+	te.Status = TaskStatusFailed
+	te.Error = "step failed"
+	require.NoError(t, writer.SaveTask(te))
+	require.NoError(t, os.Remove(writer.path("task-001")))
+
+	tasks, err = reader.ListTasks(TaskFilter{Status: []TaskStatus{TaskStatusFailed}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-000"}, taskIDs(tasks))
+}
+
+func TestTaskFilter_Matches(t *testing.T) {
+	base := time.Now()
+	te := &TaskExecution{ID: "task-1", Status: TaskStatusRunning, CreatedAt: base}
+
+	assert.True(t, TaskFilter{}.Matches(te))
+	assert.True(t, TaskFilter{Status: []TaskStatus{TaskStatusRunning}, Since: base}.Matches(te))
+	assert.False(t, TaskFilter{Status: []TaskStatus{TaskStatusFailed}}.Matches(te))
+	assert.False(t, TaskFilter{Until: base}.Matches(te))
+	assert.False(t, TaskFilter{Since: base.Add(time.Second)}.Matches(te))
+
+	te.Tags = []string{"infra", "weekly"}
+	assert.True(t, TaskFilter{Tags: []string{"weekly"}}.Matches(te))
+	assert.False(t, TaskFilter{Tags: []string{"infra", "urgent"}}.Matches(te))
+
+	te.Workspace = "alpha"
+	assert.True(t, TaskFilter{Workspace: "alpha"}.Matches(te))
+	assert.False(t, TaskFilter{Workspace: "beta"}.Matches(te))
+}
+
+func TestTaskStorage_Workspace(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 6)
+			for i, workspace := range []string{"alpha", "beta", "alpha", "beta", "alpha"} {
+				te, err := storage.GetTask(fmt.Sprintf("task-%03d", i))
+				require.NoError(t, err)
+				te.Workspace = workspace
+				require.NoError(t, storage.SaveTask(te))
+			}
+
+			tasks, err := storage.ListTasks(TaskFilter{Workspace: "alpha"})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-005", "task-004", "task-002", "task-000"}, taskIDs(tasks),
+				"task-005 has no workspace and is listed in every one")
+
+			tasks, err = storage.ListTasks(TaskFilter{Workspace: "beta", Status: []TaskStatus{TaskStatusFailed}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-005", "task-003", "task-001"}, taskIDs(tasks))
+
+			tasks, err = storage.ListTasks(TaskFilter{})
+			require.NoError(t, err)
+			assert.Len(t, tasks, 6)
+		})
+	}
+}
+
+func TestTaskStorage_Tags(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			seedOrdered(t, storage, 6)
+			for _, id := range []string{"task-001", "task-002", "task-005"} {
+				te, err := storage.GetTask(id)
+				require.NoError(t, err)
+				require.NoError(t, te.AddTags("infra"))
+				if id != "task-002" {
+					require.NoError(t, te.AddTags("weekly"))
+				}
+				require.NoError(t, storage.SaveTask(te))
+			}
+
+			tasks, err := storage.ListTasks(TaskFilter{Tags: []string{"infra"}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-005", "task-002", "task-001"}, taskIDs(tasks))
+			assert.Equal(t, []string{"infra", "weekly"}, tasks[0].Tags)
+
+			tasks, err = storage.ListTasks(TaskFilter{Tags: []string{"infra", "weekly"}, PageSize: 1})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-005"}, taskIDs(tasks))
+
+			// Without a limit, failed tasks are read from the status sets rather than walking the order
+			tasks, err = storage.ListTasks(TaskFilter{Status: []TaskStatus{TaskStatusFailed}, Tags: []string{"weekly"}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-005", "task-001"}, taskIDs(tasks))
+
+			te, err := storage.GetTask("task-005")
+			require.NoError(t, err)
+			require.NoError(t, te.RemoveTags("infra", "weekly"))
+			require.NoError(t, storage.SaveTask(te))
+			tasks, err = storage.ListTasks(TaskFilter{Tags: []string{"weekly"}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"task-001"}, taskIDs(tasks))
+		})
+	}
+}
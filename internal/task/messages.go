@@ -0,0 +1,64 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// MessageRecorder wraps a CommunicationLogger and records every message tagged with a
+// "task_id" in its data in that task's log, so agent communications appear in task logs
+type MessageRecorder struct {
+	agents.CommunicationLogger
+	storage TaskStorage
+
+	mu      sync.Mutex
+	onError func(taskID string, err error)
+}
+
+// NewMessageRecorder creates a communication logger that also appends task messages to storage
+func NewMessageRecorder(logger agents.CommunicationLogger, storage TaskStorage) *MessageRecorder {
+	return &MessageRecorder{
+		CommunicationLogger: logger,
+		storage:             storage,
+	}
+}
+
+// SetErrorHandler sets the function told about messages that could not be recorded
+func (r *MessageRecorder) SetErrorHandler(handler func(taskID string, err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = handler
+}
+
+// LogMessage logs the message and, when it belongs to a task, appends it to the task's log
+func (r *MessageRecorder) LogMessage(from, to string, message agents.Message) {
+	r.CommunicationLogger.LogMessage(from, to, message)
+
+	taskID, _ := message.Data["task_id"].(string)
+	if taskID == "" {
+		return
+	}
+	step, _ := message.Data["step"].(string)
+
+	// Serialize read-modify-write cycles so concurrent messages are not lost
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record(taskID, step, from, to, message); err != nil && r.onError != nil {
+		r.onError(taskID, err)
+	}
+}
+
+// record appends one message to a stored task
+func (r *MessageRecorder) record(taskID, step, from, to string, message agents.Message) error {
+	at := message.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if err := AppendLogs(r.storage, taskID, messageLog(step, from, to, message.Content, at)); err != nil {
+		return fmt.Errorf("failed to record message for task %s: %w", taskID, err)
+	}
+	return nil
+}
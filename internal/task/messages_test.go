@@ -0,0 +1,98 @@
+package task
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func TestMessageRecorder_LogMessage(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := NewTaskExecution("analyze code")
+	te.AddStepLog(LogLevelInfo, "task-1", "research-001", "step started")
+	require.NoError(t, storage.SaveTask(te))
+
+	memory := agents.NewMemoryCommunicationLogger()
+	recorder := NewMessageRecorder(memory, storage)
+
+	sent := te.Logs[0].Timestamp.Add(time.Second)
+	recorder.LogMessage("research-001", "file-001", agents.Message{
+		ID:        "msg-1",
+		Content:   "please read go.mod",
+		Timestamp: sent,
+		Data:      map[string]interface{}{"task_id": te.ID, "step": "task-1"},
+	})
+	recorder.LogMessage("file-001", "research-001", agents.Message{ID: "msg-2", Content: "untracked"})
+
+	assert.Len(t, memory.GetAllMessages(), 2, "every message reaches the wrapped logger")
+
+	stored, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Logs, 2)
+	assert.True(t, sent.Equal(stored.Logs[1].Timestamp))
+	stored.Logs[1].Timestamp = time.Time{}
+	assert.Equal(t, LogEntry{
+		Level:   LogLevelInfo,
+		Message: "please read go.mod",
+		Step:    "task-1",
+		Agent:   "research-001",
+		From:    "research-001",
+		To:      "file-001",
+	}, stored.Logs[1])
+	assert.True(t, stored.Logs[1].IsMessage())
+	assert.False(t, stored.Logs[0].IsMessage())
+}
+
+func TestMessageRecorder_Errors(t *testing.T) {
+	recorder := NewMessageRecorder(agents.NewMemoryCommunicationLogger(), NewMemoryTaskStorage())
+
+	var failed []string
+	recorder.SetErrorHandler(func(taskID string, err error) {
+		failed = append(failed, taskID)
+		assert.Error(t, err)
+	})
+	recorder.LogMessage("a", "b", agents.Message{ID: "msg-1", Data: map[string]interface{}{"task_id": "task-missing"}})
+	assert.Equal(t, []string{"task-missing"}, failed)
+}
+
+func TestTaskExecution_AddMessageLog(t *testing.T) {
+	te := NewTaskExecution("goal")
+	start := time.Now()
+	te.Logs = []LogEntry{
+		{Timestamp: start, Message: "first"},
+		{Timestamp: start.Add(2 * time.Second), Message: "third"},
+	}
+
+	te.AddMessageLog("", "a", "b", "second", start.Add(time.Second))
+	te.AddMessageLog("", "b", "a", "fourth", start.Add(3*time.Second))
+
+	var messages []string
+	for _, entry := range te.Logs {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"first", "second", "third", "fourth"}, messages, "messages are interleaved by time")
+}
+
+// failingStorage fails every save
+type failingStorage struct {
+	TaskStorage
+}
+
+func (failingStorage) SaveTask(*TaskExecution) error { return errors.New("disk full") }
+
+func TestMessageRecorder_SaveError(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := NewTaskExecution("goal")
+	require.NoError(t, storage.SaveTask(te))
+
+	recorder := NewMessageRecorder(agents.NewMemoryCommunicationLogger(), failingStorage{storage})
+	var got error
+	recorder.SetErrorHandler(func(_ string, err error) { got = err })
+	recorder.LogMessage("a", "b", agents.Message{ID: "msg-1", Data: map[string]interface{}{"task_id": te.ID}})
+	assert.EqualError(t, got, "failed to record message for task "+te.ID+": disk full")
+}
@@ -0,0 +1,287 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// DefaultOutputSaveInterval is the longest streamed command output is held before the task
+// is saved with it, so "capn tasks logs" sees output while the step still runs
+const DefaultOutputSaveInterval = time.Second
+
+const (
+	// DefaultOutputBufferSize is how many entries may wait to be saved before debug and info
+	// entries are dropped
+	DefaultOutputBufferSize = 10000
+	// DefaultOutputBatchSize is how many waiting entries have the task saved before the save
+	// interval has passed
+	DefaultOutputBatchSize = 500
+)
+
+// Task metadata the recorder reads and writes
+const (
+	// MetadataLogDebugSample keeps one in this many debug entries of the task's log,
+	// overriding execution.logs.debug_sample
+	MetadataLogDebugSample = "log_debug_sample"
+	// MetadataLogDropped counts the log entries dropped because they could not be saved
+	// fast enough
+	MetadataLogDropped = "log_dropped"
+	// MetadataLogSampled counts the debug entries left out by sampling
+	MetadataLogSampled = "log_sampled"
+)
+
+// OutputOptions tunes how an OutputRecorder batches and thins the entries it records
+type OutputOptions struct {
+	// BufferSize bounds the entries waiting to be saved; once reached, debug and info entries
+	// are dropped while warnings and errors are still kept
+	BufferSize int
+	// BatchSize saves the task once this many entries are waiting
+	BatchSize int
+	// SaveInterval is the longest an entry waits before the task is saved with it
+	SaveInterval time.Duration
+	// DebugSample keeps one in this many debug entries; 0 or 1 keeps them all
+	DebugSample int
+}
+
+// DefaultOutputOptions returns the options recorders are created with
+func DefaultOutputOptions() OutputOptions {
+	return OutputOptions{BufferSize: DefaultOutputBufferSize, BatchSize: DefaultOutputBatchSize, SaveInterval: DefaultOutputSaveInterval}
+}
+
+// OutputStats counts what happened to the entries given to an OutputRecorder
+type OutputStats struct {
+	// Accepted entries were buffered to be saved
+	Accepted int
+	// Written entries were appended to the task log
+	Written int
+	// Sampled debug entries were left out by sampling
+	Sampled int
+	// Dropped debug and info entries were refused because the buffer was full
+	Dropped int
+}
+
+// OutputRecorder appends the command output plan steps stream while they run to the log of
+// the task record being executed. Entries are buffered and written in batches, saving the
+// task once a batch fills or the save interval passes. A step never waits on a slow save:
+// while one is in progress entries keep buffering, and once the buffer is full debug and
+// info entries are dropped and counted, while warnings and errors are always kept.
+type OutputRecorder struct {
+	storage TaskStorage
+	record  *TaskExecution
+	clock   common.Clock
+
+	// writing is held while a batch is appended to the record and saved
+	writing sync.Mutex
+
+	mu        sync.Mutex
+	options   OutputOptions
+	buffer    []LogEntry
+	saved     time.Time
+	debugSeen int
+	stats     OutputStats
+	onError   func(err error)
+}
+
+// NewOutputRecorder creates a recorder streaming step output into the task record's log
+func NewOutputRecorder(storage TaskStorage, record *TaskExecution) *OutputRecorder {
+	return &OutputRecorder{storage: storage, record: record, options: DefaultOutputOptions(), clock: common.SystemClock}
+}
+
+// SetClock sets the clock output is timestamped and saves are spaced with
+func (r *OutputRecorder) SetClock(clock common.Clock) {
+	r.clock = clock
+}
+
+// SetSaveInterval sets the longest recorded output waits before the task is saved
+func (r *OutputRecorder) SetSaveInterval(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.options.SaveInterval = interval
+}
+
+// SetOptions sets how entries are batched and sampled; zero sizes and intervals keep their
+// defaults
+func (r *OutputRecorder) SetOptions(options OutputOptions) {
+	defaults := DefaultOutputOptions()
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaults.BufferSize
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaults.BatchSize
+	}
+	if options.SaveInterval <= 0 {
+		options.SaveInterval = defaults.SaveInterval
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.options = options
+}
+
+// SetErrorHandler sets the function told about output that could not be saved
+func (r *OutputRecorder) SetErrorHandler(handler func(err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = handler
+}
+
+// Stats returns the counts of entries accepted, written, sampled out and dropped so far
+func (r *OutputRecorder) Stats() OutputStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Record appends a line of a step's output to the task log, saving the task when a batch
+// has filled or the save interval has passed since it was last saved. Lines marked as debug
+// output, such as "DEBUG ..." or "level=debug ...", are recorded at the debug level.
+func (r *OutputRecorder) Record(step, agent string, stream agents.OutputStream, line string) {
+	now := r.clock.Now()
+	entry := LogEntry{
+		Timestamp: now,
+		Level:     lineLevel(line),
+		Message:   line,
+		Step:      step,
+		Agent:     agent,
+		Stream:    string(stream),
+	}
+	if r.enqueue(entry) {
+		r.write(false)
+	}
+}
+
+// Log appends an entry about a step to the task log and saves the task, serialized with the
+// output being recorded, so steps running in parallel can report their progress
+func (r *OutputRecorder) Log(level LogLevel, step, agent, message string) {
+	r.enqueue(LogEntry{
+		Timestamp: r.clock.Now(),
+		Level:     level,
+		Message:   message,
+		Step:      step,
+		Agent:     agent,
+	})
+	r.write(true)
+}
+
+// Flush saves output recorded since the task was last saved
+func (r *OutputRecorder) Flush() {
+	r.write(true)
+}
+
+// Close saves the output still waiting and, when entries were sampled out or dropped,
+// records how many in the task log and metadata
+func (r *OutputRecorder) Close() {
+	r.Flush()
+	stats := r.Stats()
+	if stats.Sampled == 0 && stats.Dropped == 0 {
+		return
+	}
+	var parts []string
+	if stats.Dropped > 0 {
+		parts = append(parts, fmt.Sprintf("%d dropped because they could not be saved fast enough", stats.Dropped))
+	}
+	if stats.Sampled > 0 {
+		parts = append(parts, fmt.Sprintf("%d debug entries left out by sampling", stats.Sampled))
+	}
+	r.writing.Lock()
+	defer r.writing.Unlock()
+	if r.record.Metadata == nil {
+		r.record.Metadata = make(map[string]string)
+	}
+	r.record.Metadata[MetadataLogDropped] = strconv.Itoa(stats.Dropped)
+	r.record.Metadata[MetadataLogSampled] = strconv.Itoa(stats.Sampled)
+	r.record.Logs = append(r.record.Logs, LogEntry{
+		Timestamp: r.clock.Now(),
+		Level:     LogLevelWarn,
+		Message:   fmt.Sprintf("Log entries missing: %s", strings.Join(parts, "; ")),
+	})
+	r.save()
+}
+
+// enqueue buffers an entry unless sampling leaves it out or the buffer is full, reporting
+// whether the task is due to be saved
+func (r *OutputRecorder) enqueue(entry LogEntry) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry.Level == LogLevelDebug && r.options.DebugSample > 1 {
+		r.debugSeen++
+		if (r.debugSeen-1)%r.options.DebugSample != 0 {
+			r.stats.Sampled++
+			return false
+		}
+	}
+	urgent := entry.Level == LogLevelWarn || entry.Level == LogLevelError
+	if len(r.buffer) >= r.options.BufferSize && !urgent {
+		r.stats.Dropped++
+		return false
+	}
+	r.buffer = append(r.buffer, entry)
+	r.stats.Accepted++
+	return r.dueLocked(entry.Timestamp)
+}
+
+func (r *OutputRecorder) dueLocked(now time.Time) bool {
+	return len(r.buffer) >= r.options.BatchSize || now.Sub(r.saved) >= r.options.SaveInterval
+}
+
+// write appends the buffered entries to the record and saves it. Unless wait is set it
+// returns at once when another save is in progress, leaving the entries for that writer.
+func (r *OutputRecorder) write(wait bool) {
+	if wait {
+		r.writing.Lock()
+	} else if !r.writing.TryLock() {
+		return
+	}
+	defer r.writing.Unlock()
+	for {
+		r.mu.Lock()
+		batch := r.buffer
+		r.buffer = nil
+		r.saved = r.clock.Now()
+		r.stats.Written += len(batch)
+		r.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		r.record.Logs = append(r.record.Logs, batch...)
+		r.save()
+
+		// Entries buffered during a slow save may already make up the next batch
+		r.mu.Lock()
+		again := len(r.buffer) > 0 && r.dueLocked(r.clock.Now())
+		r.mu.Unlock()
+		if !again {
+			return
+		}
+	}
+}
+
+func (r *OutputRecorder) save() {
+	if err := r.storage.SaveTask(r.record); err != nil {
+		r.mu.Lock()
+		onError := r.onError
+		r.mu.Unlock()
+		if onError != nil {
+			onError(fmt.Errorf("failed to save output of task %s: %w", r.record.ID, err))
+		}
+	}
+}
+
+// debugMarkers are the prefixes marking a line of command output as debug output
+var debugMarkers = []string{"debug:", "debug ", "[debug]", "level=debug", "dbg "}
+
+// lineLevel returns the level a line of command output is recorded at
+func lineLevel(line string) LogLevel {
+	lower := strings.ToLower(strings.TrimSpace(line))
+	for _, marker := range debugMarkers {
+		if strings.HasPrefix(lower, marker) {
+			return LogLevelDebug
+		}
+	}
+	return LogLevelInfo
+}
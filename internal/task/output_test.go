@@ -0,0 +1,161 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestOutputRecorder(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := NewTaskExecution("build")
+	require.NoError(t, storage.SaveTask(te))
+
+	recorder := NewOutputRecorder(storage, te)
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(now)
+	recorder.SetClock(clock)
+	storedLogs := func() []LogEntry {
+		stored, err := storage.GetTask(te.ID)
+		require.NoError(t, err)
+		return stored.Logs
+	}
+
+	recorder.Record("task-2", "file-001", agents.OutputStdout, "compiling")
+	assert.Equal(t, []LogEntry{{
+		Timestamp: now, Level: LogLevelInfo, Message: "compiling", Step: "task-2", Agent: "file-001", Stream: "stdout",
+	}}, storedLogs(), "the first line is saved right away")
+
+	clock.Advance(100 * time.Millisecond)
+	recorder.Record("task-2", "file-001", agents.OutputStderr, "warning: slow test")
+	assert.Len(t, storedLogs(), 1, "lines within the save interval wait")
+	assert.Equal(t, OutputStats{Accepted: 2, Written: 1}, recorder.Stats())
+
+	clock.Advance(DefaultOutputSaveInterval)
+	recorder.Record("task-2", "file-001", agents.OutputStdout, "linking")
+	assert.Len(t, storedLogs(), 3)
+
+	recorder.Record("task-2", "file-001", agents.OutputStdout, "done")
+	recorder.Flush()
+	logs := storedLogs()
+	require.Len(t, logs, 4, "flushing saves the waiting lines")
+	assert.Equal(t, "stderr", logs[1].Stream)
+}
+
+func TestOutputRecorder_Log(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := NewTaskExecution("deploy")
+	recorder := NewOutputRecorder(storage, te)
+
+	recorder.Record("deploy[0]", "file-001", agents.OutputStdout, "rolling out")
+	recorder.Log(LogLevelInfo, "deploy", "file-001", "Item web-1 succeeded (1 of 2 finished)")
+	stored, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Logs, 2, "progress is saved right away, with the output before it")
+	assert.Equal(t, LogEntry{Timestamp: stored.Logs[1].Timestamp, Level: LogLevelInfo, Message: "Item web-1 succeeded (1 of 2 finished)",
+		Step: "deploy", Agent: "file-001"}, stored.Logs[1])
+}
+
+func TestOutputRecorder_SaveError(t *testing.T) {
+	te := NewTaskExecution("build")
+	recorder := NewOutputRecorder(failingStorage{NewMemoryTaskStorage()}, te)
+	var got error
+	recorder.SetErrorHandler(func(err error) { got = err })
+
+	recorder.Record("task-1", "file-001", agents.OutputStdout, "hello")
+	assert.EqualError(t, got, "failed to save output of task "+te.ID+": disk full")
+	assert.Len(t, te.Logs, 1, "the line stays on the record for the next save")
+}
+
+func TestOutputRecorder_SamplesDebugLines(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := NewTaskExecution("build")
+	recorder := NewOutputRecorder(storage, te)
+	recorder.SetOptions(OutputOptions{DebugSample: 3})
+
+	for i := 0; i < 7; i++ {
+		recorder.Record("task-1", "file-001", agents.OutputStdout, fmt.Sprintf("DEBUG cache probe %d", i))
+	}
+	recorder.Record("task-1", "file-001", agents.OutputStdout, "built in 2s")
+	recorder.Close()
+
+	var messages []string
+	for _, entry := range te.Logs {
+		messages = append(messages, string(entry.Level)+" "+entry.Message)
+	}
+	assert.Equal(t, []string{
+		"debug DEBUG cache probe 0",
+		"debug DEBUG cache probe 3",
+		"debug DEBUG cache probe 6",
+		"info built in 2s",
+		"warn Log entries missing: 4 debug entries left out by sampling",
+	}, messages)
+	assert.Equal(t, OutputStats{Accepted: 4, Written: 4, Sampled: 4}, recorder.Stats())
+	assert.Equal(t, "4", te.Metadata[MetadataLogSampled])
+	assert.Equal(t, "0", te.Metadata[MetadataLogDropped])
+}
+
+// slowStorage holds every save until it is released
+type slowStorage struct {
+	TaskStorage
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s slowStorage) SaveTask(te *TaskExecution) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.TaskStorage.SaveTask(te)
+}
+
+func TestOutputRecorder_DropsWhileSaving(t *testing.T) {
+	storage := slowStorage{TaskStorage: NewMemoryTaskStorage(), saving: make(chan struct{}), release: make(chan struct{})}
+	te := NewTaskExecution("build")
+	recorder := NewOutputRecorder(storage, te)
+	recorder.SetOptions(OutputOptions{BufferSize: 2, BatchSize: 2})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		recorder.Record("task-1", "file-001", agents.OutputStdout, "first")
+	}()
+	<-storage.saving
+
+	// The step keeps streaming while the first line is saved, without waiting for it
+	recorder.Record("task-1", "file-001", agents.OutputStdout, "second")
+	recorder.Record("task-1", "file-001", agents.OutputStdout, "third")
+	recorder.Record("task-1", "file-001", agents.OutputStdout, "fourth")
+	assert.Equal(t, OutputStats{Accepted: 3, Written: 1, Dropped: 1}, recorder.Stats())
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		recorder.Log(LogLevelError, "task-1", "file-001", "exit status 1")
+	}()
+	storage.release <- struct{}{}
+	go func() {
+		for range storage.saving {
+			storage.release <- struct{}{}
+		}
+	}()
+	wg.Wait()
+	recorder.Close()
+	close(storage.saving)
+
+	var messages []string
+	for _, entry := range te.Logs {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"first", "second", "third", "exit status 1",
+		"Log entries missing: 1 dropped because they could not be saved fast enough"}, messages, "errors are kept even with the buffer full")
+	assert.Equal(t, OutputStats{Accepted: 4, Written: 4, Dropped: 1}, recorder.Stats())
+	assert.Equal(t, "1", te.Metadata[MetadataLogDropped])
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package task
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isProcessRunning reports whether a process with the given ID exists; a process that
+// belongs to another user still counts as running
+func isProcessRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
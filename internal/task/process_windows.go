@@ -0,0 +1,16 @@
+//go:build windows
+
+package task
+
+import "os"
+
+// isProcessRunning reports whether a process with the given ID exists, which on Windows
+// succeeds in finding it only while it runs
+func isProcessRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,165 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// DefaultAnswerPollInterval is how often a waiting step checks storage for an answer
+const DefaultAnswerPollInterval = time.Second
+
+// Question is a request for clarification an agent raised while working on a step
+type Question struct {
+	ID         string    `json:"id"`
+	Step       string    `json:"step,omitempty"`
+	Agent      string    `json:"agent,omitempty"`
+	Text       string    `json:"text"`
+	Answer     string    `json:"answer,omitempty"`
+	AskedAt    time.Time `json:"asked_at"`
+	AnsweredAt time.Time `json:"answered_at,omitempty"`
+}
+
+// Answered reports whether the user has answered the question
+func (q Question) Answered() bool {
+	return !q.AnsweredAt.IsZero()
+}
+
+// Ask records a new open question on the task and returns its ID
+func (t *TaskExecution) Ask(step, agent, text string) string {
+	id := fmt.Sprintf("q-%d", len(t.Questions)+1)
+	t.Questions = append(t.Questions, Question{ID: id, Step: step, Agent: agent, Text: text, AskedAt: time.Now()})
+	t.AddStepLog(LogLevelWarn, step, agent, "Question: "+text)
+	return id
+}
+
+// PendingQuestions returns the questions still waiting for an answer, oldest first
+func (t *TaskExecution) PendingQuestions() []Question {
+	var pending []Question
+	for _, q := range t.Questions {
+		if !q.Answered() {
+			pending = append(pending, q)
+		}
+	}
+	return pending
+}
+
+// Question returns the question with the given ID
+func (t *TaskExecution) Question(id string) (Question, bool) {
+	for _, q := range t.Questions {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	return Question{}, false
+}
+
+// AnswerQuestion records the answer to a question. An empty id answers the oldest open question.
+func (t *TaskExecution) AnswerQuestion(id, answer string) (Question, error) {
+	if answer == "" {
+		return Question{}, fmt.Errorf("answer cannot be empty")
+	}
+	for i := range t.Questions {
+		q := &t.Questions[i]
+		if (id == "" && !q.Answered()) || q.ID == id {
+			if q.Answered() {
+				return Question{}, fmt.Errorf("question %s of task %s is already answered", q.ID, t.ID)
+			}
+			q.Answer = answer
+			q.AnsweredAt = time.Now()
+			t.AddStepLog(LogLevelInfo, q.Step, q.Agent, "Answer: "+answer)
+			return *q, nil
+		}
+	}
+	if id == "" {
+		return Question{}, fmt.Errorf("task %s has no open questions", t.ID)
+	}
+	return Question{}, fmt.Errorf("question not found: %s", id)
+}
+
+// mergeAnswers copies answers recorded in a stored copy of the task onto questions still open in t
+func (t *TaskExecution) mergeAnswers(stored *TaskExecution) {
+	for i := range t.Questions {
+		if t.Questions[i].Answered() {
+			continue
+		}
+		if answered, ok := stored.Question(t.Questions[i].ID); ok && answered.Answered() {
+			t.Questions[i] = answered
+			t.AddStepLog(LogLevelInfo, answered.Step, answered.Agent, "Answer: "+answered.Answer)
+		}
+	}
+}
+
+// QuestionChannel lets agents working on a task ask the user questions. Questions are saved with
+// the task so "capn status" can show them, and answers are read back from storage, so the user can
+// answer from another process with "capn tasks answer".
+type QuestionChannel struct {
+	storage      TaskStorage
+	record       *TaskExecution
+	pollInterval time.Duration
+	clock        common.Clock
+
+	mu sync.Mutex
+}
+
+// NewQuestionChannel creates a question channel for the task record being executed
+func NewQuestionChannel(storage TaskStorage, record *TaskExecution) *QuestionChannel {
+	return &QuestionChannel{
+		storage:      storage,
+		record:       record,
+		pollInterval: DefaultAnswerPollInterval,
+		clock:        common.SystemClock,
+	}
+}
+
+// SetPollInterval sets how often a waiting step checks for an answer
+func (c *QuestionChannel) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		c.pollInterval = interval
+	}
+}
+
+// SetClock sets the clock a waiting step polls for answers with
+func (c *QuestionChannel) SetClock(clock common.Clock) {
+	c.clock = clock
+}
+
+// Ask records the question on the task and blocks until it is answered or ctx is done
+func (c *QuestionChannel) Ask(ctx context.Context, step, agentID, question string) (string, error) {
+	c.mu.Lock()
+	c.sync()
+	id := c.record.Ask(step, agentID, question)
+	err := c.storage.SaveTask(c.record)
+	c.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to record question: %w", err)
+	}
+
+	ticker := c.clock.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("stopped waiting for an answer to %s: %w", id, ctx.Err())
+		case <-ticker.C():
+		}
+
+		c.mu.Lock()
+		c.sync()
+		answered, _ := c.record.Question(id)
+		c.mu.Unlock()
+		if answered.Answered() {
+			return answered.Answer, nil
+		}
+	}
+}
+
+// sync pulls answers saved by other processes into the in-memory record
+func (c *QuestionChannel) sync() {
+	if stored, err := c.storage.GetTask(c.record.ID); err == nil {
+		c.record.mergeAnswers(stored)
+	}
+}
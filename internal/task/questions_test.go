@@ -0,0 +1,95 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func TestTaskExecution_Questions(t *testing.T) {
+	te := NewTaskExecution("goal")
+	first := te.Ask("step-1", "research-001", "Which topic?")
+	second := te.Ask("step-2", "file-001", "Which path?")
+	assert.Equal(t, "q-1", first)
+	assert.Equal(t, "q-2", second)
+	assert.Len(t, te.PendingQuestions(), 2)
+	assert.Equal(t, "Question: Which topic?", te.Logs[len(te.Logs)-2].Message)
+
+	_, err := te.AnswerQuestion("", "")
+	assert.EqualError(t, err, "answer cannot be empty")
+
+	// Without an ID the oldest open question is answered
+	answered, err := te.AnswerQuestion("", "databases")
+	require.NoError(t, err)
+	assert.Equal(t, "q-1", answered.ID)
+	assert.Equal(t, "databases", answered.Answer)
+	assert.True(t, answered.Answered())
+
+	_, err = te.AnswerQuestion("q-1", "again")
+	assert.EqualError(t, err, "question q-1 of task "+te.ID+" is already answered")
+	_, err = te.AnswerQuestion("q-9", "docs/")
+	assert.EqualError(t, err, "question not found: q-9")
+
+	_, err = te.AnswerQuestion("q-2", "docs/")
+	require.NoError(t, err)
+	assert.Empty(t, te.PendingQuestions())
+	_, err = te.AnswerQuestion("", "more")
+	assert.EqualError(t, err, "task "+te.ID+" has no open questions")
+
+	q, ok := te.Question("q-2")
+	require.True(t, ok)
+	assert.Equal(t, "docs/", q.Answer)
+	assert.Equal(t, "step-2", q.Step)
+}
+
+func TestQuestionChannel_Ask(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	record := NewTaskExecution("goal")
+	require.NoError(t, storage.SaveTask(record))
+
+	channel := NewQuestionChannel(storage, record)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	channel.SetClock(clock)
+
+	// Answer from a separate copy of the task, as "capn tasks answer" would, once the step waits
+	go func() {
+		clock.BlockUntil(1)
+		stored, err := storage.GetTask(record.ID)
+		if err == nil {
+			_, _ = stored.AnswerQuestion("", "databases")
+			_ = storage.SaveTask(stored)
+		}
+		clock.Advance(DefaultAnswerPollInterval)
+	}()
+
+	answer, err := channel.Ask(context.Background(), "step-1", "research-001", "Which topic?")
+	require.NoError(t, err)
+	assert.Equal(t, "databases", answer)
+	assert.Empty(t, record.PendingQuestions(), "the answer is merged into the executing record")
+
+	stored, err := storage.GetTask(record.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.Questions, 1)
+}
+
+func TestQuestionChannel_Cancelled(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	record := NewTaskExecution("goal")
+	channel := NewQuestionChannel(storage, record)
+	channel.SetPollInterval(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := channel.Ask(ctx, "step-1", "file-001", "Which path?")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "stopped waiting for an answer to q-1")
+
+	stored, err := storage.GetTask(record.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.PendingQuestions(), 1, "the question stays open for status to show")
+}
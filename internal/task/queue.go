@@ -0,0 +1,200 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// DefaultQueuePollInterval is how often a queued task checks for a free execution slot
+const DefaultQueuePollInterval = time.Second
+
+// Queue limits how many tasks execute at once. Tasks over the limit wait in the queued
+// status, ordered by priority and then by arrival. The queue is shared through task storage,
+// so separate capn processes using the same storage cooperate on the limit.
+type Queue struct {
+	storage       TaskStorage
+	maxConcurrent int
+	pollInterval  time.Duration
+	clock         common.Clock
+}
+
+// NewQueue creates a queue admitting at most maxConcurrent tasks; zero means unlimited
+func NewQueue(storage TaskStorage, maxConcurrent int) *Queue {
+	return &Queue{
+		storage:       storage,
+		maxConcurrent: maxConcurrent,
+		pollInterval:  DefaultQueuePollInterval,
+		clock:         common.SystemClock,
+	}
+}
+
+// SetPollInterval sets how often queued tasks check for a free slot
+func (q *Queue) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		q.pollInterval = interval
+	}
+}
+
+// SetClock sets the clock queued tasks are timed and polled with
+func (q *Queue) SetClock(clock common.Clock) {
+	q.clock = clock
+}
+
+// Acquire waits until the task may execute. Over the limit the task is saved as queued and
+// polled until it reaches the front of the queue and a slot frees up.
+// onQueued is called with the task's position once, when it has to wait.
+func (q *Queue) Acquire(ctx context.Context, record *TaskExecution, onQueued func(position int)) error {
+	if q.maxConcurrent <= 0 {
+		return nil
+	}
+
+	record.QueuedAt = q.clock.Now()
+	record.SetStatus(TaskStatusQueued)
+	if err := q.storage.SaveTask(record); err != nil {
+		return fmt.Errorf("failed to queue task: %w", err)
+	}
+
+	reported := false
+	ticker := q.clock.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		position, ready, err := q.check(record.ID)
+		if err != nil {
+			return err
+		}
+		if ready {
+			if stored, err := q.storage.GetTask(record.ID); err == nil {
+				record.Priority = stored.Priority
+			}
+			record.AddLog(LogLevelInfo, fmt.Sprintf("Dequeued after waiting %s", q.clock.Since(record.QueuedAt).Round(time.Millisecond)))
+			return nil
+		}
+		if !reported && onQueued != nil {
+			onQueued(position)
+			reported = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled while queued: %w", ctx.Err())
+		case <-ticker.C():
+		}
+	}
+}
+
+// Position returns the 1-based queue position of a queued task
+func (q *Queue) Position(id string) (int, error) {
+	queued, _, err := q.snapshot()
+	if err != nil {
+		return 0, err
+	}
+	for i, t := range queued {
+		if t.ID == id {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("task %s is not queued", id)
+}
+
+// Positions returns the queue position of every queued task, keyed by task ID
+func (q *Queue) Positions() (map[string]int, error) {
+	queued, _, err := q.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[string]int, len(queued))
+	for i, t := range queued {
+		positions[t.ID] = i + 1
+	}
+	return positions, nil
+}
+
+// Bump raises a pending or queued task's priority. A nil priority moves it ahead of every other queued task.
+func (q *Queue) Bump(id string, priority *int) (*TaskExecution, error) {
+	t, err := q.storage.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != TaskStatusQueued && t.Status != TaskStatusPending {
+		return nil, fmt.Errorf("task %s is %s; only pending or queued tasks can be bumped", id, t.Status)
+	}
+
+	if priority != nil {
+		t.Priority = *priority
+	} else {
+		queued, _, err := q.snapshot()
+		if err != nil {
+			return nil, err
+		}
+		highest := t.Priority
+		for _, other := range queued {
+			if other.ID != id && other.Priority >= highest {
+				highest = other.Priority + 1
+			}
+		}
+		t.Priority = highest
+	}
+	t.AddLog(LogLevelInfo, fmt.Sprintf("Priority raised to %d", t.Priority))
+	if err := q.storage.SaveTask(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// check returns a queued task's position and whether a slot is free for it
+func (q *Queue) check(id string) (int, bool, error) {
+	queued, active, err := q.snapshot()
+	if err != nil {
+		return 0, false, err
+	}
+	position := len(queued) + 1
+	for i, t := range queued {
+		if t.ID == id {
+			position = i + 1
+			break
+		}
+	}
+	return position, position <= q.maxConcurrent-active, nil
+}
+
+// snapshot returns the queued tasks in queue order and the number of tasks executing.
+// Queued tasks still waiting for their dependencies are left out.
+func (q *Queue) snapshot() ([]*TaskExecution, int, error) {
+	tasks, err := q.storage.ListTasks(TaskFilter{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read task queue: %w", err)
+	}
+
+	byID := make(map[string]*TaskExecution, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	// Tasks still waiting for their dependencies hold no place in the queue
+	var queued []*TaskExecution
+	active := 0
+	for _, t := range tasks {
+		switch t.Status {
+		case TaskStatusQueued:
+			if dependenciesMet(t, byID) {
+				queued = append(queued, t)
+			}
+		case TaskStatusPlanning, TaskStatusRunning:
+			active++
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		if queued[i].Priority != queued[j].Priority {
+			return queued[i].Priority > queued[j].Priority
+		}
+		if !queued[i].QueuedAt.Equal(queued[j].QueuedAt) {
+			return queued[i].QueuedAt.Before(queued[j].QueuedAt)
+		}
+		return queued[i].ID < queued[j].ID
+	})
+	return queued, active, nil
+}
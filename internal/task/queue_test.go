@@ -0,0 +1,126 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+func queuedTask(t *testing.T, storage TaskStorage, priority int, queuedAt time.Time) *TaskExecution {
+	t.Helper()
+	te := NewTaskExecution("queued work")
+	te.Priority = priority
+	te.QueuedAt = queuedAt
+	te.SetStatus(TaskStatusQueued)
+	require.NoError(t, storage.SaveTask(te))
+	return te
+}
+
+func TestQueue_Unlimited(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := NewTaskExecution("goal")
+
+	require.NoError(t, NewQueue(storage, 0).Acquire(context.Background(), te, nil))
+	assert.Equal(t, TaskStatusPending, te.Status, "without a limit tasks are never queued")
+}
+
+func TestQueue_Positions(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	now := time.Now()
+	first := queuedTask(t, storage, 0, now.Add(-2*time.Minute))
+	second := queuedTask(t, storage, 0, now.Add(-time.Minute))
+	urgent := queuedTask(t, storage, 5, now)
+	running := NewTaskExecution("running")
+	running.SetStatus(TaskStatusRunning)
+	require.NoError(t, storage.SaveTask(running))
+
+	queue := NewQueue(storage, 1)
+	positions, err := queue.Positions()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{urgent.ID: 1, first.ID: 2, second.ID: 3}, positions, "priority first, then arrival")
+
+	position, err := queue.Position(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, position)
+
+	_, err = queue.Position(running.ID)
+	assert.EqualError(t, err, "task "+running.ID+" is not queued")
+}
+
+func TestQueue_Acquire(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	running := NewTaskExecution("running")
+	running.SetStatus(TaskStatusRunning)
+	require.NoError(t, storage.SaveTask(running))
+
+	queue := NewQueue(storage, 1)
+	clock := testutil.NewFakeClock(time.Unix(1_700_000_000, 0))
+	queue.SetClock(clock)
+
+	te := NewTaskExecution("waiting")
+	positions := make(chan int, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- queue.Acquire(context.Background(), te, func(position int) { positions <- position })
+	}()
+
+	assert.Equal(t, 1, <-positions)
+	stored, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TaskStatusQueued, stored.Status)
+
+	running.SetStatus(TaskStatusCompleted)
+	require.NoError(t, storage.SaveTask(running))
+	clock.Advance(DefaultQueuePollInterval)
+
+	require.NoError(t, <-done, "the task is admitted at the next poll after the slot frees up")
+	assert.Equal(t, clock.Now().Add(-DefaultQueuePollInterval), te.QueuedAt)
+	assert.Equal(t, "Dequeued after waiting 1s", te.Logs[len(te.Logs)-1].Message)
+}
+
+func TestQueue_AcquireCancelled(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	running := NewTaskExecution("running")
+	running.SetStatus(TaskStatusRunning)
+	require.NoError(t, storage.SaveTask(running))
+
+	queue := NewQueue(storage, 1)
+	queue.SetClock(testutil.NewFakeClock(time.Unix(1_700_000_000, 0)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	te := NewTaskExecution("waiting")
+	err := queue.Acquire(ctx, te, func(int) { cancel() })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "cancelled while queued")
+}
+
+func TestQueue_Bump(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	now := time.Now()
+	first := queuedTask(t, storage, 2, now.Add(-time.Minute))
+	last := queuedTask(t, storage, 0, now)
+	queue := NewQueue(storage, 1)
+
+	bumped, err := queue.Bump(last.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, bumped.Priority, "moves ahead of every other queued task")
+	position, err := queue.Position(last.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, position)
+
+	priority := 10
+	bumped, err = queue.Bump(first.ID, &priority)
+	require.NoError(t, err)
+	assert.Equal(t, 10, bumped.Priority)
+
+	done := NewTaskExecution("done")
+	done.SetStatus(TaskStatusCompleted)
+	require.NoError(t, storage.SaveTask(done))
+	_, err = queue.Bump(done.ID, nil)
+	assert.EqualError(t, err, "task "+done.ID+" is completed; only pending or queued tasks can be bumped")
+}
@@ -0,0 +1,125 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+const (
+	// MetadataOwnerHost holds the host of the capn process running a task
+	MetadataOwnerHost = "owner_host"
+	// MetadataOwnerPID holds the process ID of the capn process running a task
+	MetadataOwnerPID = "owner_pid"
+	// MetadataResumes counts how many times an interrupted task was resumed
+	MetadataResumes = "resumes"
+)
+
+// MaxResumes bounds how often an interrupted task is resumed, so a task that brings down the
+// process running it is not resumed forever
+const MaxResumes = 3
+
+// inFlightStatuses are the statuses of tasks a capn process is working on
+var inFlightStatuses = []TaskStatus{TaskStatusPending, TaskStatusQueued, TaskStatusPlanning, TaskStatusRunning}
+
+// processAlive reports whether a process with the given ID is running on this host; it is
+// replaced in tests
+var processAlive = isProcessRunning
+
+// Claim records the current process as the one running the task
+func (t *TaskExecution) Claim() {
+	host, _ := os.Hostname()
+	t.setMetadata(MetadataOwnerHost, host)
+	t.setMetadata(MetadataOwnerPID, strconv.Itoa(os.Getpid()))
+}
+
+// Orphaned reports whether the task was left in flight by a capn process that has exited.
+// Tasks claimed on another host, or recorded before tasks were claimed, cannot be checked
+// and are never orphaned.
+func (t *TaskExecution) Orphaned() bool {
+	if t.Status.IsTerminal() || t.Metadata[MetadataOwnerPID] == "" {
+		return false
+	}
+	if host, _ := os.Hostname(); t.Metadata[MetadataOwnerHost] != host {
+		return false
+	}
+	pid, err := strconv.Atoi(t.Metadata[MetadataOwnerPID])
+	if err != nil || pid <= 0 {
+		return false
+	}
+	return pid != os.Getpid() && !processAlive(pid)
+}
+
+// CheckpointStep records a step's result as soon as the step finishes, so a task interrupted
+// by its process exiting can resume after the steps it completed. RecordExecution later
+// replaces the checkpoints with the execution's results.
+func (t *TaskExecution) CheckpointStep(result captain.Result) {
+	t.Results = append(t.Results, result)
+	t.checkpointed++
+}
+
+// RecoverTasks marks the tasks orphaned by exited capn processes as interrupted, returning them
+func RecoverTasks(storage TaskStorage) ([]*TaskExecution, error) {
+	tasks, err := storage.ListTasks(TaskFilter{Status: inFlightStatuses})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find interrupted tasks: %w", err)
+	}
+	var recovered []*TaskExecution
+	for _, t := range tasks {
+		if !t.Orphaned() {
+			continue
+		}
+		t.Error = fmt.Sprintf("interrupted while %s: the capn process running it (pid %s) exited", t.Status, t.Metadata[MetadataOwnerPID])
+		t.AddLog(LogLevelWarn, "Task "+t.Error)
+		t.SetStatus(TaskStatusInterrupted)
+		if err := storage.SaveTask(t); err != nil {
+			return recovered, fmt.Errorf("failed to save interrupted task %s: %w", t.ID, err)
+		}
+		recovered = append(recovered, t)
+	}
+	return recovered, nil
+}
+
+// Resume returns an interrupted task to pending under the current process so it can run
+// again, keeping the results of the steps it completed; only its plan's remaining steps run
+func (t *TaskExecution) Resume() error {
+	if t.Status != TaskStatusInterrupted {
+		return fmt.Errorf("task %s is %s; only interrupted tasks can be resumed", t.ID, t.Status)
+	}
+	resumes, _ := strconv.Atoi(t.Metadata[MetadataResumes])
+	if resumes >= MaxResumes {
+		return fmt.Errorf("task %s was already resumed %d times; retry it with \"capn tasks retry\"", t.ID, resumes)
+	}
+
+	// Steps that did not succeed run again, so their earlier results are dropped
+	var completed []captain.Result
+	for _, result := range t.Results {
+		if result.Success {
+			completed = append(completed, result)
+		}
+	}
+	t.Results = completed
+	t.setMetadata(MetadataResumes, strconv.Itoa(resumes+1))
+	t.Claim()
+	t.Error = ""
+	t.CompletedAt = time.Time{}
+	t.SetStatus(TaskStatusPending)
+	if t.Plan != nil {
+		t.AddLog(LogLevelInfo, fmt.Sprintf("Resuming after interruption (resume %d of %d): keeping %d completed step(s), running %d",
+			resumes+1, MaxResumes, len(completed), len(t.Plan.Tasks)-len(completed)))
+	} else {
+		t.AddLog(LogLevelInfo, fmt.Sprintf("Resuming after interruption (resume %d of %d): planning again", resumes+1, MaxResumes))
+	}
+	return nil
+}
+
+// setMetadata sets a metadata value, creating the map of a task decoded without one
+func (t *TaskExecution) setMetadata(key, value string) {
+	if t.Metadata == nil {
+		t.Metadata = make(map[string]string)
+	}
+	t.Metadata[key] = value
+}
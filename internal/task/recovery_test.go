@@ -0,0 +1,147 @@
+package task
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// orphanedTask returns a task with the given status owned by a process that has exited
+func orphanedTask(status TaskStatus) *TaskExecution {
+	te := NewTaskExecution("deploy the fleet")
+	te.Metadata[MetadataOwnerPID] = "4242"
+	te.SetStatus(status)
+	return te
+}
+
+// fakeProcesses reports only the given process IDs as running until the test ends
+func fakeProcesses(t *testing.T, running ...int) {
+	t.Helper()
+	previous := processAlive
+	processAlive = func(pid int) bool {
+		for _, r := range running {
+			if r == pid {
+				return true
+			}
+		}
+		return false
+	}
+	t.Cleanup(func() { processAlive = previous })
+}
+
+func TestTaskExecution_Claim(t *testing.T) {
+	te := &TaskExecution{ID: "task-1"}
+	te.Claim()
+
+	host, _ := os.Hostname()
+	assert.Equal(t, host, te.Metadata[MetadataOwnerHost])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), te.Metadata[MetadataOwnerPID])
+	assert.Equal(t, te.Metadata, NewTaskExecution("goal").Metadata, "new tasks are claimed by the process creating them")
+}
+
+func TestTaskExecution_Orphaned(t *testing.T) {
+	fakeProcesses(t, 1717)
+
+	assert.True(t, orphanedTask(TaskStatusRunning).Orphaned())
+	assert.False(t, orphanedTask(TaskStatusFailed).Orphaned(), "finished tasks are not in flight")
+	assert.False(t, NewTaskExecution("goal").Orphaned(), "tasks owned by this process are not orphaned")
+
+	running := orphanedTask(TaskStatusRunning)
+	running.Metadata[MetadataOwnerPID] = "1717"
+	assert.False(t, running.Orphaned())
+
+	remote := orphanedTask(TaskStatusRunning)
+	remote.Metadata[MetadataOwnerHost] = "elsewhere"
+	assert.False(t, remote.Orphaned(), "processes on other hosts cannot be checked")
+
+	unclaimed := &TaskExecution{ID: "task-1", Status: TaskStatusRunning}
+	assert.False(t, unclaimed.Orphaned(), "tasks recorded before tasks were claimed are left alone")
+}
+
+func TestRecoverTasks(t *testing.T) {
+	fakeProcesses(t)
+	storage := NewMemoryTaskStorage()
+	running := orphanedTask(TaskStatusRunning)
+	queued := orphanedTask(TaskStatusQueued)
+	failed := orphanedTask(TaskStatusFailed)
+	live := NewTaskExecution("still running here")
+	live.SetStatus(TaskStatusRunning)
+	for _, te := range []*TaskExecution{running, queued, failed, live} {
+		require.NoError(t, storage.SaveTask(te))
+	}
+
+	recovered, err := RecoverTasks(storage)
+	require.NoError(t, err)
+	var ids []string
+	for _, te := range recovered {
+		ids = append(ids, te.ID)
+	}
+	assert.ElementsMatch(t, []string{running.ID, queued.ID}, ids)
+
+	stored, err := storage.GetTask(running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TaskStatusInterrupted, stored.Status)
+	assert.True(t, stored.Status.IsTerminal())
+	assert.False(t, stored.CompletedAt.IsZero())
+	assert.Equal(t, "interrupted while running: the capn process running it (pid 4242) exited", stored.Error)
+	assert.Equal(t, "Task "+stored.Error, stored.Logs[len(stored.Logs)-1].Message)
+
+	for _, te := range []*TaskExecution{failed, live} {
+		stored, err := storage.GetTask(te.ID)
+		require.NoError(t, err)
+		assert.Equal(t, te.Status, stored.Status)
+	}
+
+	recovered, err = RecoverTasks(storage)
+	require.NoError(t, err)
+	assert.Empty(t, recovered, "interrupted tasks are recovered once")
+}
+
+func TestTaskExecution_Resume(t *testing.T) {
+	te := orphanedTask(TaskStatusInterrupted)
+	te.Error = "interrupted while running"
+	te.Plan = &captain.ExecutionPlan{ID: "plan-1", Tasks: []captain.Task{{ID: "step-1"}, {ID: "step-2"}, {ID: "step-3"}}}
+	te.Results = []captain.Result{{TaskID: "step-1", Success: true}, {TaskID: "step-2", Error: "interrupted"}}
+
+	require.NoError(t, te.Resume())
+	assert.Equal(t, TaskStatusPending, te.Status)
+	assert.Empty(t, te.Error)
+	assert.True(t, te.CompletedAt.IsZero())
+	assert.Equal(t, strconv.Itoa(os.Getpid()), te.Metadata[MetadataOwnerPID], "the resuming process claims the task")
+	assert.Equal(t, "1", te.Metadata[MetadataResumes])
+	assert.Equal(t, []captain.Result{{TaskID: "step-1", Success: true}}, te.Results)
+	assert.Equal(t, "Resuming after interruption (resume 1 of 3): keeping 1 completed step(s), running 2", te.Logs[len(te.Logs)-1].Message)
+
+	pending := te.PendingPlan()
+	require.Len(t, pending.Tasks, 2)
+	assert.Equal(t, "step-2", pending.Tasks[0].ID)
+
+	assert.EqualError(t, te.Resume(), "task "+te.ID+" is pending; only interrupted tasks can be resumed")
+
+	te.SetStatus(TaskStatusInterrupted)
+	te.Metadata[MetadataResumes] = strconv.Itoa(MaxResumes)
+	assert.EqualError(t, te.Resume(), "task "+te.ID+" was already resumed 3 times; retry it with \"capn tasks retry\"")
+}
+
+func TestTaskExecution_CheckpointStep(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.Results = []captain.Result{{TaskID: "step-1", Success: true}}
+
+	te.CheckpointStep(captain.Result{TaskID: "step-2", Success: true})
+	assert.Len(t, te.Results, 2)
+
+	te.RecordExecution(&captain.ExecutionResult{
+		Success:     true,
+		TaskResults: []captain.Result{{TaskID: "step-2", Success: true}, {TaskID: "step-3", Success: true}},
+	})
+	var ids []string
+	for _, result := range te.Results {
+		ids = append(ids, result.TaskID)
+	}
+	assert.Equal(t, []string{"step-1", "step-2", "step-3"}, ids, "the execution's results replace its checkpoints")
+}
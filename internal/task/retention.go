@@ -0,0 +1,146 @@
+package task
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Retention bounds the task history kept in storage; zero values keep every task
+type Retention struct {
+	// MaxTasks keeps only the most recent finished tasks
+	MaxTasks int
+	// MaxAge prunes tasks that finished longer ago than this
+	MaxAge time.Duration
+	// FailedMaxAge keeps failed, cancelled and interrupted tasks this long instead of MaxAge
+	FailedMaxAge time.Duration
+}
+
+// Enabled reports whether the retention prunes any task at all
+func (r Retention) Enabled() bool {
+	return r.MaxTasks > 0 || r.MaxAge > 0 || r.FailedMaxAge > 0
+}
+
+// Expired returns the finished tasks the retention no longer keeps at now. Unfinished tasks
+// are never expired and do not count towards MaxTasks.
+func (r Retention) Expired(tasks []*TaskExecution, now time.Time) []*TaskExecution {
+	finished := finishedTasks(tasks)
+	var expired []*TaskExecution
+	for i, t := range finished {
+		maxAge := r.MaxAge
+		if t.Status != TaskStatusCompleted && r.FailedMaxAge > 0 {
+			maxAge = r.FailedMaxAge
+		}
+		tooMany := r.MaxTasks > 0 && i >= r.MaxTasks
+		tooOld := maxAge > 0 && now.Sub(t.FinishedAt()) > maxAge
+		if tooMany || tooOld {
+			expired = append(expired, t)
+		}
+	}
+	return expired
+}
+
+// FinishedAt returns when the task finished, falling back to when it was created for tasks
+// recorded without a completion time
+func (t *TaskExecution) FinishedAt() time.Time {
+	if t.CompletedAt.IsZero() {
+		return t.CreatedAt
+	}
+	return t.CompletedAt
+}
+
+// PruneOptions selects the finished tasks Prune deletes
+type PruneOptions struct {
+	// Before selects tasks that finished before it; zero selects them however recent
+	Before time.Time
+	// Status selects tasks with one of these statuses; empty selects every finished status
+	Status []TaskStatus
+	// Workspace selects the tasks recorded in it, along with tasks recorded without one
+	Workspace string
+	// Artifacts, when set, also deletes the pruned tasks' artifacts from the store
+	Artifacts *ArtifactStore
+	// DryRun returns the tasks that would be pruned without deleting them
+	DryRun bool
+}
+
+// Prune deletes the finished tasks selected by opts, returning them most recently finished
+// first. Tasks that unfinished tasks still wait for are kept, whatever opts selects.
+func Prune(storage TaskStorage, opts PruneOptions) ([]*TaskExecution, error) {
+	tasks, err := storage.ListTasks(TaskFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	var selected []*TaskExecution
+	for _, t := range finishedTasks(tasks) {
+		if !t.InWorkspace(opts.Workspace) {
+			continue
+		}
+		if len(opts.Status) > 0 && !slices.Contains(opts.Status, t.Status) {
+			continue
+		}
+		if !opts.Before.IsZero() && !t.FinishedAt().Before(opts.Before) {
+			continue
+		}
+		selected = append(selected, t)
+	}
+	return deleteTasks(storage, tasks, selected, opts.Artifacts, opts.DryRun)
+}
+
+// ApplyRetention deletes the finished tasks the retention no longer keeps, returning them
+// most recently finished first, and their artifacts too when artifacts is set
+func ApplyRetention(storage TaskStorage, retention Retention, artifacts *ArtifactStore) ([]*TaskExecution, error) {
+	if !retention.Enabled() {
+		return nil, nil
+	}
+	tasks, err := storage.ListTasks(TaskFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	return deleteTasks(storage, tasks, retention.Expired(tasks, time.Now()), artifacts, false)
+}
+
+// finishedTasks returns the finished tasks, most recently finished first
+func finishedTasks(tasks []*TaskExecution) []*TaskExecution {
+	var finished []*TaskExecution
+	for _, t := range tasks {
+		if t.Status.IsTerminal() {
+			finished = append(finished, t)
+		}
+	}
+	slices.SortStableFunc(finished, func(a, b *TaskExecution) int {
+		return b.FinishedAt().Compare(a.FinishedAt())
+	})
+	return finished
+}
+
+// deleteTasks deletes the candidates, skipping those an unfinished task among all waits for
+func deleteTasks(storage TaskStorage, all, candidates []*TaskExecution, artifacts *ArtifactStore, dryRun bool) ([]*TaskExecution, error) {
+	awaited := make(map[string]bool)
+	for _, t := range all {
+		if t.Status.IsTerminal() {
+			continue
+		}
+		for _, dep := range t.After {
+			awaited[dep.TaskID] = true
+		}
+	}
+
+	var pruned []*TaskExecution
+	for _, t := range candidates {
+		if awaited[t.ID] {
+			continue
+		}
+		if !dryRun {
+			if err := storage.DeleteTask(t.ID); err != nil {
+				return pruned, err
+			}
+			if artifacts != nil {
+				if err := artifacts.Delete(t.ID); err != nil {
+					return pruned, err
+				}
+			}
+		}
+		pruned = append(pruned, t)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,101 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// finishedTask returns a task that reached status the given time ago
+func finishedTask(id string, status TaskStatus, ago time.Duration) *TaskExecution {
+	finished := time.Now().Add(-ago)
+	return &TaskExecution{ID: id, Status: status, CreatedAt: finished.Add(-time.Minute), CompletedAt: finished}
+}
+
+func TestRetention_Expired(t *testing.T) {
+	tasks := []*TaskExecution{
+		finishedTask("old-completed", TaskStatusCompleted, 40*24*time.Hour),
+		finishedTask("old-failed", TaskStatusFailed, 41*24*time.Hour),
+		finishedTask("ancient-failed", TaskStatusFailed, 100*24*time.Hour),
+		finishedTask("recent-completed", TaskStatusCompleted, time.Hour),
+		{ID: "running", Status: TaskStatusRunning, CreatedAt: time.Now().Add(-200 * 24 * time.Hour)},
+	}
+	now := time.Now()
+
+	byAge := Retention{MaxAge: 30 * 24 * time.Hour}
+	assert.Equal(t, []string{"old-completed", "old-failed", "ancient-failed"}, taskIDs(byAge.Expired(tasks, now)))
+
+	failedLonger := Retention{MaxAge: 30 * 24 * time.Hour, FailedMaxAge: 90 * 24 * time.Hour}
+	assert.Equal(t, []string{"old-completed", "ancient-failed"}, taskIDs(failedLonger.Expired(tasks, now)))
+
+	byCount := Retention{MaxTasks: 2}
+	assert.Equal(t, []string{"old-failed", "ancient-failed"}, taskIDs(byCount.Expired(tasks, now)),
+		"the most recently finished tasks are kept and unfinished ones are not counted")
+
+	assert.False(t, Retention{}.Enabled())
+	assert.Empty(t, Retention{}.Expired(tasks, now))
+}
+
+func TestPrune(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	tasks := []*TaskExecution{
+		finishedTask("old-completed", TaskStatusCompleted, 40*24*time.Hour),
+		finishedTask("old-failed", TaskStatusFailed, 40*24*time.Hour),
+		finishedTask("awaited", TaskStatusCompleted, 40*24*time.Hour),
+		finishedTask("recent-completed", TaskStatusCompleted, time.Hour),
+		{ID: "waiting", Status: TaskStatusQueued, CreatedAt: time.Now(), After: []Dependency{{TaskID: "awaited", Condition: DependencyCompleted}}},
+	}
+	for _, te := range tasks {
+		require.NoError(t, storage.SaveTask(te))
+	}
+
+	opts := PruneOptions{Before: time.Now().Add(-30 * 24 * time.Hour), Status: []TaskStatus{TaskStatusCompleted}, DryRun: true}
+	pruned, err := Prune(storage, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old-completed"}, taskIDs(pruned), "tasks a queued task waits for are kept")
+	_, err = storage.GetTask("old-completed")
+	assert.NoError(t, err, "a dry run deletes nothing")
+
+	opts.DryRun = false
+	pruned, err = Prune(storage, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old-completed"}, taskIDs(pruned))
+	remaining, err := storage.ListTasks(TaskFilter{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"old-failed", "awaited", "recent-completed", "waiting"}, taskIDs(remaining))
+
+	pruned, err = Prune(storage, PruneOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"old-failed", "recent-completed"}, taskIDs(pruned))
+}
+
+func TestApplyRetention_Artifacts(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	store, err := NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+	for _, te := range []*TaskExecution{
+		finishedTask("old", TaskStatusCompleted, 48*time.Hour),
+		finishedTask("recent", TaskStatusCompleted, time.Hour),
+	} {
+		require.NoError(t, storage.SaveTask(te))
+		require.NoError(t, os.MkdirAll(store.TaskDir(te.ID), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(store.TaskDir(te.ID), "report.md"), []byte("# Report"), 0o644))
+	}
+
+	retention := Retention{MaxAge: 24 * time.Hour}
+	pruned, err := ApplyRetention(storage, retention, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, taskIDs(pruned))
+	assert.DirExists(t, store.TaskDir("old"), "artifacts are kept unless asked for")
+
+	require.NoError(t, storage.SaveTask(finishedTask("old", TaskStatusCompleted, 48*time.Hour)))
+	pruned, err = ApplyRetention(storage, retention, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, taskIDs(pruned))
+	assert.NoDirExists(t, store.TaskDir("old"))
+	assert.DirExists(t, store.TaskDir("recent"))
+}
@@ -0,0 +1,180 @@
+package task
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+const (
+	// MetadataRetryOf holds the ID of the task a retry was cloned from
+	MetadataRetryOf = "retry_of"
+	// MetadataRetryMode records whether a retry re-runs the whole plan or only failed steps
+	MetadataRetryMode = "retry_mode"
+	// MetadataRetryAttempt counts retries from the original task, starting at 1
+	MetadataRetryAttempt = "retry_attempt"
+
+	RetryModeFull       = "full"
+	RetryModeFailedOnly = "failed-only"
+)
+
+// NewRetry creates a pending task that retries a failed, cancelled or interrupted task. The goal and plan are
+// cloned; with failedOnly, results of steps that succeeded are carried over so only the rest run again.
+func NewRetry(original *TaskExecution, failedOnly bool) (*TaskExecution, error) {
+	switch original.Status {
+	case TaskStatusFailed, TaskStatusCancelled, TaskStatusInterrupted:
+	default:
+		return nil, fmt.Errorf("task %s is %s; only failed, cancelled or interrupted tasks can be retried", original.ID, original.Status)
+	}
+	if failedOnly && original.Plan == nil {
+		return nil, fmt.Errorf("task %s has no plan; retry without --failed-only to plan it again", original.ID)
+	}
+
+	retry := NewTaskExecution(original.Goal)
+	retry.BatchID = original.BatchID
+	retry.PipelineID = original.PipelineID
+	retry.Priority = original.Priority
+	retry.Tags = slices.Clone(original.Tags)
+	retry.Workspace = original.Workspace
+	for key, value := range original.Metadata {
+		retry.Metadata[key] = value
+	}
+	// The retry snapshots the repository itself and makes its own estimate; the original's
+	// changes and estimate stay with the original
+	for _, key := range gitMetadata {
+		delete(retry.Metadata, key)
+	}
+	delete(retry.Metadata, MetadataEstimatedDuration)
+	delete(retry.Metadata, MetadataETA)
+	// The retry runs in this process, however often the original was resumed
+	delete(retry.Metadata, MetadataResumes)
+	retry.Claim()
+	attempt, _ := strconv.Atoi(original.Metadata[MetadataRetryAttempt])
+	retry.Metadata[MetadataRetryOf] = original.ID
+	retry.Metadata[MetadataRetryAttempt] = strconv.Itoa(attempt + 1)
+	retry.Metadata[MetadataRetryMode] = RetryModeFull
+
+	if original.Plan != nil {
+		plan := *original.Plan
+		plan.Tasks = append([]captain.Task(nil), original.Plan.Tasks...)
+		retry.Plan = &plan
+	}
+
+	if failedOnly {
+		retry.Metadata[MetadataRetryMode] = RetryModeFailedOnly
+		for _, result := range original.Results {
+			if result.Success {
+				retry.Results = append(retry.Results, result)
+			}
+		}
+		retry.AddLog(LogLevelInfo, fmt.Sprintf("Retry of task %s: reusing %d completed step(s), re-running %d",
+			original.ID, len(retry.Results), len(retry.Plan.Tasks)-len(retry.Results)))
+	} else {
+		retry.AddLog(LogLevelInfo, fmt.Sprintf("Retry of task %s", original.ID))
+	}
+	return retry, nil
+}
+
+// PendingPlan returns the task's plan reduced to the steps that have not succeeded yet.
+// Dependencies on completed steps are dropped since they are already satisfied, and so are
+// conditions on them: a step runs unconditionally if the completed step met its condition
+// and is left out otherwise.
+func (t *TaskExecution) PendingPlan() *captain.ExecutionPlan {
+	if t.Plan == nil {
+		return nil
+	}
+	done := make(map[string]captain.Result, len(t.Results))
+	for _, result := range t.Results {
+		if result.Success {
+			done[result.TaskID] = result
+		}
+	}
+	if len(done) == 0 {
+		return t.Plan
+	}
+	// Steps skipped by the condition of a completed step are as good as done, and so are the
+	// steps conditioned on them in turn
+	for changed := true; changed; {
+		changed = false
+		for _, step := range t.Plan.Tasks {
+			if _, ok := done[step.ID]; ok || step.Condition == nil {
+				continue
+			}
+			if result, ok := done[step.Condition.Step]; ok && !step.Condition.Met(result) {
+				done[step.ID] = captain.Result{TaskID: step.ID, Success: true, Metadata: map[string]any{"skipped": true}}
+				changed = true
+			}
+		}
+	}
+
+	plan := *t.Plan
+	plan.Tasks = nil
+	for _, step := range t.Plan.Tasks {
+		if _, ok := done[step.ID]; ok {
+			continue
+		}
+		if step.Condition != nil {
+			if _, ok := done[step.Condition.Step]; ok {
+				step.Condition = nil
+			}
+		}
+		var deps []string
+		for _, dep := range step.Dependencies {
+			if _, ok := done[dep]; !ok {
+				deps = append(deps, dep)
+			}
+		}
+		step.Dependencies = deps
+		plan.Tasks = append(plan.Tasks, step)
+	}
+	return &plan
+}
+
+// RetryChain returns every task linked to id through retries, from the original task to the latest retry
+func RetryChain(storage TaskStorage, id string) ([]*TaskExecution, error) {
+	tasks, err := storage.ListTasks(TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*TaskExecution, len(tasks))
+	retries := make(map[string][]*TaskExecution)
+	for _, t := range tasks {
+		byID[t.ID] = t
+		if parent := t.Metadata[MetadataRetryOf]; parent != "" {
+			retries[parent] = append(retries[parent], t)
+		}
+	}
+	current, ok := byID[id]
+	if !ok {
+		return nil, fmt.Errorf("task not found: %s", id)
+	}
+
+	// Walk back to the original, guarding against corrupt cycles
+	root := current
+	seen := map[string]bool{root.ID: true}
+	for {
+		parent, ok := byID[root.Metadata[MetadataRetryOf]]
+		if !ok || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		root = parent
+	}
+
+	// Then collect every retry descending from it in creation order
+	chain := []*TaskExecution{root}
+	inChain := map[string]bool{root.ID: true}
+	for i := 0; i < len(chain); i++ {
+		for _, child := range retries[chain[i].ID] {
+			if !inChain[child.ID] {
+				inChain[child.ID] = true
+				chain = append(chain, child)
+			}
+		}
+	}
+	sort.SliceStable(chain, func(a, b int) bool { return chain[a].CreatedAt.Before(chain[b].CreatedAt) })
+	return chain, nil
+}
@@ -0,0 +1,172 @@
+package task
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func failedTask() *TaskExecution {
+	te := NewTaskExecution("build and ship")
+	te.BatchID = "batch-1"
+	te.Tags = []string{"release"}
+	te.Workspace = "/src/shipyard"
+	te.Metadata["template"] = "ship@2"
+	te.Metadata[MetadataEstimatedDuration] = "1m0s"
+	te.Plan = &captain.ExecutionPlan{
+		ID:   "plan-1",
+		Goal: te.Goal,
+		Tasks: []captain.Task{
+			{ID: "build", Type: captain.TaskTypeExecution},
+			{ID: "test", Type: captain.TaskTypeValidation, Dependencies: []string{"build"}},
+			{ID: "ship", Type: captain.TaskTypeExecution, Dependencies: []string{"build", "test"}},
+		},
+	}
+	te.Results = []captain.Result{
+		{TaskID: "build", Success: true},
+		{TaskID: "test", Success: false, Error: "tests failed"},
+	}
+	te.SetStatus(TaskStatusFailed)
+	return te
+}
+
+func TestNewRetry(t *testing.T) {
+	original := failedTask()
+	host, _ := os.Hostname()
+
+	retry, err := NewRetry(original, false)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, retry.ID)
+	assert.Equal(t, TaskStatusPending, retry.Status)
+	assert.Equal(t, original.Goal, retry.Goal)
+	assert.Equal(t, "batch-1", retry.BatchID)
+	assert.Equal(t, []string{"release"}, retry.Tags)
+	assert.Equal(t, "/src/shipyard", retry.Workspace)
+	assert.Equal(t, map[string]string{
+		"template":           "ship@2",
+		MetadataRetryOf:      original.ID,
+		MetadataRetryAttempt: "1",
+		MetadataRetryMode:    RetryModeFull,
+		MetadataOwnerHost:    host,
+		MetadataOwnerPID:     strconv.Itoa(os.Getpid()),
+	}, retry.Metadata)
+	assert.Empty(t, retry.Results)
+	assert.Equal(t, original.Plan.Tasks, retry.Plan.Tasks)
+
+	retry.Plan.Tasks[0].ID = "changed"
+	assert.Equal(t, "build", original.Plan.Tasks[0].ID, "the plan is cloned, not shared")
+
+	retry.SetStatus(TaskStatusFailed)
+	second, err := NewRetry(retry, true)
+	require.NoError(t, err)
+	assert.Equal(t, "2", second.Metadata[MetadataRetryAttempt])
+	assert.Equal(t, retry.ID, second.Metadata[MetadataRetryOf])
+}
+
+func TestNewRetry_FailedOnly(t *testing.T) {
+	original := failedTask()
+
+	retry, err := NewRetry(original, true)
+	require.NoError(t, err)
+	assert.Equal(t, RetryModeFailedOnly, retry.Metadata[MetadataRetryMode])
+	assert.Equal(t, []captain.Result{{TaskID: "build", Success: true}}, retry.Results)
+
+	pending := retry.PendingPlan()
+	assert.Equal(t, []captain.Task{
+		{ID: "test", Type: captain.TaskTypeValidation},
+		{ID: "ship", Type: captain.TaskTypeExecution, Dependencies: []string{"test"}},
+	}, pending.Tasks)
+	assert.Len(t, retry.Plan.Tasks, 3, "the full plan is kept on the record")
+	assert.Equal(t, []string{"build", "test"}, original.Plan.Tasks[2].Dependencies)
+}
+
+func TestNewRetry_Errors(t *testing.T) {
+	completed := failedTask()
+	completed.Status = TaskStatusCompleted
+	_, err := NewRetry(completed, false)
+	assert.EqualError(t, err, "task "+completed.ID+" is completed; only failed, cancelled or interrupted tasks can be retried")
+
+	unplanned := NewTaskExecution("goal")
+	unplanned.SetStatus(TaskStatusFailed)
+	_, err = NewRetry(unplanned, true)
+	assert.ErrorContains(t, err, "has no plan")
+
+	retry, err := NewRetry(unplanned, false)
+	require.NoError(t, err)
+	assert.Nil(t, retry.Plan, "a task that failed while planning is planned again")
+}
+
+func TestTaskExecution_PendingPlan(t *testing.T) {
+	assert.Nil(t, NewTaskExecution("goal").PendingPlan())
+
+	fresh := failedTask()
+	fresh.Results = nil
+	assert.Same(t, fresh.Plan, fresh.PendingPlan(), "nothing to skip")
+}
+
+func TestTaskExecution_PendingPlan_Conditions(t *testing.T) {
+	te := NewTaskExecution("test and release")
+	te.Plan = &captain.ExecutionPlan{
+		ID:   "plan-1",
+		Goal: te.Goal,
+		Tasks: []captain.Task{
+			{ID: "test", Type: captain.TaskTypeValidation},
+			{ID: "release", Type: captain.TaskTypeExecution, Dependencies: []string{"test"}, Condition: &captain.Condition{Step: "test", Matches: "PASS"}},
+			{ID: "triage", Type: captain.TaskTypeAnalysis, Dependencies: []string{"test"}, Condition: &captain.Condition{Step: "test", Matches: "FAIL"}},
+			{ID: "announce", Type: captain.TaskTypeReporting, Dependencies: []string{"triage"}, Condition: &captain.Condition{Step: "triage", Matches: "."}},
+			{ID: "summary", Type: captain.TaskTypeReporting, Dependencies: []string{"triage", "release"}},
+		},
+	}
+	te.Results = []captain.Result{{TaskID: "test", Success: true, Output: "ok: 12 PASS"}}
+
+	pending := te.PendingPlan()
+	require.Len(t, pending.Tasks, 2, "steps the completed test skips are left out")
+	assert.Equal(t, "release", pending.Tasks[0].ID)
+	assert.Nil(t, pending.Tasks[0].Condition, "conditions a completed step met no longer apply")
+	assert.Empty(t, pending.Tasks[0].Dependencies)
+	assert.Equal(t, "summary", pending.Tasks[1].ID)
+	assert.Equal(t, []string{"release"}, pending.Tasks[1].Dependencies, "left-out steps are satisfied dependencies")
+	require.NoError(t, captain.NewPlanningEngine(nil).ValidatePlan(pending))
+}
+
+func TestRetryChain(t *testing.T) {
+	storage, err := NewFileTaskStorage(t.TempDir())
+	require.NoError(t, err)
+
+	original := failedTask()
+	original.CreatedAt = time.Now().Add(-time.Hour)
+	first, err := NewRetry(original, true)
+	require.NoError(t, err)
+	first.CreatedAt = time.Now().Add(-30 * time.Minute)
+	first.SetStatus(TaskStatusFailed)
+	second, err := NewRetry(first, false)
+	require.NoError(t, err)
+	unrelated := NewTaskExecution("something else")
+
+	for _, te := range []*TaskExecution{original, first, second, unrelated} {
+		require.NoError(t, storage.SaveTask(te))
+	}
+
+	for _, id := range []string{original.ID, first.ID, second.ID} {
+		chain, err := RetryChain(storage, id)
+		require.NoError(t, err)
+		ids := make([]string, len(chain))
+		for i, te := range chain {
+			ids[i] = te.ID
+		}
+		assert.Equal(t, []string{original.ID, first.ID, second.ID}, ids)
+	}
+
+	chain, err := RetryChain(storage, unrelated.ID)
+	require.NoError(t, err)
+	assert.Len(t, chain, 1)
+
+	_, err = RetryChain(storage, "task-missing")
+	assert.EqualError(t, err, "task not found: task-missing")
+}
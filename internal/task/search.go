@@ -0,0 +1,373 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Fields a search term can be scoped to with field:value. Task fields match the task itself;
+// log fields must all match the same log entry.
+var (
+	taskSearchFields = map[string]bool{"id": true, "status": true, "tag": true, "goal": true, "plan": true}
+	logSearchFields  = map[string]bool{"agent": true, "level": true, "step": true, "from": true, "to": true, "log": true, "message": true}
+)
+
+// exactSearchFields compare whole values rather than finding the term within them
+var exactSearchFields = map[string]bool{"id": true, "status": true, "tag": true, "level": true, "step": true}
+
+// Where a search match was found
+const (
+	MatchGoal      = "goal"
+	MatchPlan      = "plan"
+	MatchReasoning = "reasoning"
+	MatchResult    = "result"
+	MatchLog       = "log"
+	MatchMessage   = "message"
+	MatchQuestion  = "question"
+)
+
+// SearchQuery is a parsed search over task history. Every term must match: plain words and
+// "quoted phrases" are found case-insensitively anywhere in a task, /regular expressions/
+// likewise, and field:value terms (such as agent:FileAgent or level:error) are scoped to a
+// field. Log fields narrow the log entries the other terms are searched in.
+type SearchQuery struct {
+	text    []searchTerm
+	task    []searchTerm
+	entries []searchTerm
+}
+
+// searchTerm is one term of a query
+type searchTerm struct {
+	field string
+	value string
+	re    *regexp.Regexp
+}
+
+// SearchMatch is a piece of a task that matched a query, with the byte ranges to highlight
+type SearchMatch struct {
+	Kind  string
+	Step  string
+	Agent string
+	Text  string
+	Spans [][2]int
+}
+
+// SearchResult is a task matching a query and where it matched
+type SearchResult struct {
+	Task    *TaskExecution
+	Matches []SearchMatch
+}
+
+// ParseSearchQuery parses a search query
+func ParseSearchQuery(query string) (*SearchQuery, error) {
+	tokens, err := searchTokens(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+
+	q := &SearchQuery{}
+	for _, token := range tokens {
+		field, value := "", token
+		if name, rest, ok := strings.Cut(token, ":"); ok && rest != "" && (taskSearchFields[name] || logSearchFields[name]) {
+			field, value = name, rest
+		}
+		term, err := newSearchTerm(field, value)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case taskSearchFields[field]:
+			q.task = append(q.task, term)
+		case logSearchFields[field]:
+			q.entries = append(q.entries, term)
+		default:
+			q.text = append(q.text, term)
+		}
+	}
+	return q, nil
+}
+
+// searchTokens splits a query on spaces, keeping "quoted phrases" and /regular expressions/
+// together; quotes are removed while regular expressions keep their slashes
+func searchTokens(query string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	var quote rune
+	escaped := false
+	flush := func() {
+		if token.Len() > 0 {
+			tokens = append(tokens, token.String())
+			token.Reset()
+		}
+	}
+	for _, r := range query {
+		switch {
+		case escaped:
+			token.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				if r == '/' {
+					token.WriteRune(r)
+				}
+				continue
+			}
+			// A regular expression keeps its escapes, so \/ does not end it
+			escaped = r == '\\' && quote == '/'
+			token.WriteRune(r)
+		case r == '"':
+			quote = r
+		case r == '/' && (token.Len() == 0 || strings.HasSuffix(token.String(), ":")):
+			quote = r
+			token.WriteRune(r)
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			token.WriteRune(r)
+		}
+	}
+	switch quote {
+	case '"':
+		return nil, fmt.Errorf("unterminated quote in search query")
+	case '/':
+		return nil, fmt.Errorf("unterminated regular expression in search query")
+	}
+	flush()
+	return tokens, nil
+}
+
+// newSearchTerm builds a term; a value in slashes is a regular expression, anything else is
+// matched literally and case-insensitively
+func newSearchTerm(field, value string) (searchTerm, error) {
+	term := searchTerm{field: field, value: value}
+	if len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		re, err := regexp.Compile(value[1 : len(value)-1])
+		if err != nil {
+			return term, fmt.Errorf("invalid regular expression %s: %w", value, err)
+		}
+		term.re = re
+		return term, nil
+	}
+	pattern := regexp.QuoteMeta(value)
+	if exactSearchFields[field] {
+		pattern = "^" + pattern + "$"
+	}
+	term.re = regexp.MustCompile("(?i)" + pattern)
+	return term, nil
+}
+
+// find returns the ranges of s the term matches
+func (t searchTerm) find(s string) [][2]int {
+	var spans [][2]int
+	for _, loc := range t.re.FindAllStringIndex(s, -1) {
+		if loc[1] > loc[0] {
+			spans = append(spans, [2]int{loc[0], loc[1]})
+		}
+	}
+	return spans
+}
+
+// matches reports whether the term matches s
+func (t searchTerm) matches(s string) bool {
+	return t.re.MatchString(s)
+}
+
+// matchesEntry reports whether a log field term matches a log entry
+func (t searchTerm) matchesEntry(entry LogEntry) bool {
+	switch t.field {
+	case "agent":
+		return t.matches(entry.Agent) || t.matches(entry.From) || t.matches(entry.To)
+	case "level":
+		return t.matches(string(entry.Level))
+	case "step":
+		return t.matches(entry.Step)
+	case "from":
+		return t.matches(entry.From)
+	case "to":
+		return t.matches(entry.To)
+	case "log":
+		return !entry.IsMessage() && t.matches(entry.Message)
+	case "message":
+		return entry.IsMessage() && t.matches(entry.Message)
+	}
+	return false
+}
+
+// highlights reports whether a term found in a match's text should be highlighted there
+func (t searchTerm) highlights(kind string) bool {
+	switch t.field {
+	case "":
+		return true
+	case "goal":
+		return kind == MatchGoal
+	case "plan":
+		return kind == MatchPlan || kind == MatchReasoning
+	case "log":
+		return kind == MatchLog
+	case "message":
+		return kind == MatchMessage
+	}
+	return false
+}
+
+// Match reports whether a task satisfies the query and returns the parts of it that matched
+func (q *SearchQuery) Match(t *TaskExecution) ([]SearchMatch, bool) {
+	parts, entries := taskParts(t), q.entryParts(t)
+	if len(q.entries) > 0 && len(entries) == 0 {
+		return nil, false
+	}
+	for _, term := range q.task {
+		switch term.field {
+		case "id":
+			if !term.matches(t.ID) {
+				return nil, false
+			}
+		case "status":
+			if !term.matches(string(t.Status)) {
+				return nil, false
+			}
+		case "tag":
+			if !slices.ContainsFunc(t.Tags, term.matches) {
+				return nil, false
+			}
+		default:
+			if !anyFound(term, parts) {
+				return nil, false
+			}
+		}
+	}
+
+	// Log field terms narrow the search to the entries matching them
+	candidates := slices.Concat(parts, entries)
+	if len(q.entries) > 0 {
+		candidates = entries
+	}
+	for _, term := range q.text {
+		if !anyFound(term, candidates) {
+			return nil, false
+		}
+	}
+
+	// Entries selected by log fields alone are matches even without anything to highlight
+	terms := slices.Concat(q.text, q.task, q.entries)
+	var matches []SearchMatch
+	for _, candidate := range candidates {
+		var spans [][2]int
+		for _, term := range terms {
+			if term.highlights(candidate.Kind) {
+				spans = append(spans, term.find(candidate.Text)...)
+			}
+		}
+		if len(spans) > 0 || (len(q.entries) > 0 && len(q.text) == 0) {
+			candidate.Spans = mergeSpans(spans)
+			matches = append(matches, candidate)
+		}
+	}
+	return matches, true
+}
+
+// taskParts returns the parts of a task other than its log that terms are searched in
+func taskParts(t *TaskExecution) []SearchMatch {
+	parts := []SearchMatch{{Kind: MatchGoal, Text: t.Goal}}
+	if t.Plan != nil {
+		if reasoning := strings.TrimSpace(t.Plan.Strategy.Description); reasoning != "" {
+			parts = append(parts, SearchMatch{Kind: MatchReasoning, Text: reasoning})
+		}
+		for _, step := range t.Plan.Tasks {
+			if description, ok := step.Payload["description"].(string); ok && description != "" {
+				parts = append(parts, SearchMatch{Kind: MatchPlan, Step: step.ID, Text: description})
+			}
+		}
+	}
+	for _, result := range t.Results {
+		agent, _ := result.Metadata["agent_id"].(string)
+		for _, text := range []string{result.Output, result.Error} {
+			if text != "" {
+				parts = append(parts, SearchMatch{Kind: MatchResult, Step: result.TaskID, Agent: agent, Text: text})
+			}
+		}
+	}
+	for _, question := range t.Questions {
+		parts = append(parts, SearchMatch{Kind: MatchQuestion, Step: question.Step, Agent: question.Agent, Text: question.Text})
+		if question.Answer != "" {
+			parts = append(parts, SearchMatch{Kind: MatchQuestion, Step: question.Step, Text: question.Answer})
+		}
+	}
+	return parts
+}
+
+// entryParts returns the log entries matching all of the query's log field terms
+func (q *SearchQuery) entryParts(t *TaskExecution) []SearchMatch {
+	var parts []SearchMatch
+entries:
+	for _, entry := range t.Logs {
+		for _, term := range q.entries {
+			if !term.matchesEntry(entry) {
+				continue entries
+			}
+		}
+		kind, agent := MatchLog, entry.Agent
+		if entry.IsMessage() {
+			kind, agent = MatchMessage, entry.From
+		}
+		parts = append(parts, SearchMatch{Kind: kind, Step: entry.Step, Agent: agent, Text: entry.Message})
+	}
+	return parts
+}
+
+// anyFound reports whether a term is found in any of the parts it applies to
+func anyFound(term searchTerm, parts []SearchMatch) bool {
+	for _, part := range parts {
+		if term.highlights(part.Kind) && term.matches(part.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSpans sorts ranges and joins overlapping ones
+func mergeSpans(spans [][2]int) [][2]int {
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := [][2]int{spans[0]}
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span[0] <= last[1] {
+			last[1] = max(last[1], span[1])
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// Search returns the tasks the filter selects that match the query, newest first. The
+// filter's Limit caps the number of results rather than the number of tasks searched.
+func Search(storage TaskStorage, query *SearchQuery, filter TaskFilter) ([]SearchResult, error) {
+	limit := filter.Limit
+	filter.Limit, filter.PageSize, filter.PageToken = 0, 0, ""
+	tasks, err := storage.ListTasks(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	var results []SearchResult
+	for _, t := range tasks {
+		if matches, ok := query.Match(t); ok {
+			results = append(results, SearchResult{Task: t, Matches: matches})
+			if limit > 0 && len(results) == limit {
+				break
+			}
+		}
+	}
+	return results, nil
+}
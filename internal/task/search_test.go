@@ -0,0 +1,161 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// searchFixture returns a failed deployment task with plan, results, logs and messages
+func searchFixture() *TaskExecution {
+	t := NewTaskExecution("Deploy the API service")
+	t.Tags = []string{"infra", "weekly"}
+	t.Plan = &captain.ExecutionPlan{
+		ID:   "plan-1",
+		Goal: t.Goal,
+		Tasks: []captain.Task{
+			{ID: "step-1", Payload: map[string]any{"description": "Build the container image"}},
+			{ID: "step-2", Payload: map[string]any{"description": "Roll out to staging"}},
+		},
+		Strategy: captain.ExecutionStrategy{Description: "Build first, then deploy"},
+	}
+	t.Results = []captain.Result{
+		{TaskID: "step-1", Success: true, Output: "image built", Metadata: map[string]any{"agent_id": "shell-001"}},
+		{TaskID: "step-2", Error: "connection timeout after 30s"},
+	}
+	t.AddStepLog(LogLevelInfo, "step-1", "FileAgent-001", "wrote deploy.yaml")
+	t.AddStepLog(LogLevelError, "step-2", "NetworkAgent-001", "request timeout talking to staging")
+	t.AddStepLog(LogLevelError, "step-2", "FileAgent-001", "could not read secrets.env")
+	t.AddMessageLog("step-2", "NetworkAgent-001", "captain", "Staging is unreachable", time.Now())
+	t.SetStatus(TaskStatusFailed)
+	return t
+}
+
+func TestParseSearchQuery_Errors(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{"", "search query cannot be empty"},
+		{"   ", "search query cannot be empty"},
+		{`"open phrase`, "unterminated quote in search query"},
+		{"/open regex", "unterminated regular expression in search query"},
+		{"level:/(/", "invalid regular expression /(/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseSearchQuery(tt.query)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	tokens, err := searchTokens(`deploy "roll out" agent:/File.*/ /a\/b c/ path/to max:3`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deploy", "roll out", "agent:/File.*/", `/a\/b c/`, "path/to", "max:3"}, tokens)
+}
+
+func TestSearchQuery_Match(t *testing.T) {
+	task := searchFixture()
+	tests := []struct {
+		query string
+		want  bool
+		kinds []string // Kinds of the matches returned, in order
+	}{
+		{query: "deploy", want: true, kinds: []string{MatchGoal, MatchReasoning, MatchLog}},
+		{query: "DEPLOY api", want: true, kinds: []string{MatchGoal, MatchReasoning, MatchLog}},
+		{query: "deploy kubernetes", want: false},
+		{query: `"roll out"`, want: true, kinds: []string{MatchPlan}},
+		{query: "timeout", want: true, kinds: []string{MatchResult, MatchLog}},
+		{query: "unreachable", want: true, kinds: []string{MatchMessage}},
+		{query: `/time(out|d)\s+after/`, want: true, kinds: []string{MatchResult}},
+		{query: "agent:FileAgent level:error", want: true, kinds: []string{MatchLog}},
+		{query: "agent:FileAgent level:warn", want: false},
+		{query: "agent:fileagent timeout", want: false},
+		{query: "agent:NetworkAgent timeout", want: true, kinds: []string{MatchLog}},
+		{query: "level:err", want: false},
+		{query: "step:step-2 from:networkagent", want: true, kinds: []string{MatchMessage}},
+		{query: "to:captain", want: true, kinds: []string{MatchMessage}},
+		{query: "log:unreachable", want: false},
+		{query: "message:unreachable", want: true, kinds: []string{MatchMessage}},
+		{query: "status:failed", want: true},
+		{query: "status:completed", want: false},
+		{query: "tag:INFRA", want: true},
+		{query: "tag:inf", want: false},
+		{query: "tag:weekly unreachable", want: true, kinds: []string{MatchMessage}},
+		{query: "id:" + task.ID + " goal:api", want: true, kinds: []string{MatchGoal}},
+		{query: "goal:staging", want: false},
+		{query: "plan:staging level:error", want: true, kinds: []string{MatchLog, MatchLog}},
+		{query: "status:/fail|cancel/ image", want: true, kinds: []string{MatchPlan, MatchResult}},
+		{query: "http://example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := ParseSearchQuery(tt.query)
+			require.NoError(t, err)
+			matches, ok := query.Match(task)
+			assert.Equal(t, tt.want, ok)
+
+			var kinds []string
+			for _, match := range matches {
+				kinds = append(kinds, match.Kind)
+			}
+			assert.Equal(t, tt.kinds, kinds)
+		})
+	}
+}
+
+func TestSearchQuery_Spans(t *testing.T) {
+	query, err := ParseSearchQuery(`timeout /\d+s/ "connection time"`)
+	require.NoError(t, err)
+	matches, ok := query.Match(searchFixture())
+	require.True(t, ok)
+
+	require.Len(t, matches, 2)
+	assert.Equal(t, MatchResult, matches[0].Kind)
+	assert.Equal(t, "step-2", matches[0].Step)
+	// "connection time" and "timeout" overlap and are merged
+	assert.Equal(t, [][2]int{{0, 18}, {25, 28}}, matches[0].Spans)
+	assert.Equal(t, "NetworkAgent-001", matches[1].Agent)
+	assert.Equal(t, [][2]int{{8, 15}}, matches[1].Spans)
+}
+
+func TestSearch(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		task := searchFixture()
+		task.ID = fmt.Sprintf("task-%d", i)
+		task.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if i%2 == 0 {
+			task.Goal = "Audit dependencies"
+			task.SetStatus(TaskStatusCompleted)
+		}
+		require.NoError(t, storage.SaveTask(task))
+	}
+
+	query, err := ParseSearchQuery("deploy")
+	require.NoError(t, err)
+	results, err := Search(storage, query, TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 5, "logs mention deploy.yaml in every task")
+
+	query, err = ParseSearchQuery("goal:audit")
+	require.NoError(t, err)
+	results, err = Search(storage, query, TaskFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2, "the limit caps results, not tasks searched")
+	assert.Equal(t, "task-4", results[0].Task.ID)
+	assert.Equal(t, "task-2", results[1].Task.ID)
+
+	results, err = Search(storage, query, TaskFilter{Status: []TaskStatus{TaskStatusFailed}})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
@@ -0,0 +1,122 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// LogAppender is implemented by storages that can add log entries to a task without
+// rewriting the rest of it
+type LogAppender interface {
+	AppendLogs(id string, entries ...LogEntry) error
+}
+
+// AppendLogs adds entries to the log of a stored task in time order, using the storage's
+// own AppendLogs when it has one and saving the whole task otherwise
+func AppendLogs(storage TaskStorage, id string, entries ...LogEntry) error {
+	if appender, ok := storage.(LogAppender); ok {
+		return appender.AppendLogs(id, entries...)
+	}
+	t, err := storage.GetTask(id)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		t.Logs = appendLog(t.Logs, entry)
+	}
+	return storage.SaveTask(t)
+}
+
+// appendLog adds an entry to logs after the entries not later than it. An entry that
+// belongs at the end is appended, which leaves the entries shorter slices of logs can see
+// untouched; one that belongs earlier is inserted into a new array.
+func appendLog(logs []LogEntry, entry LogEntry) []LogEntry {
+	i := sort.Search(len(logs), func(i int) bool { return logs[i].Timestamp.After(entry.Timestamp) })
+	if i == len(logs) {
+		return append(logs, entry)
+	}
+	return slices.Insert(slices.Clip(logs), i, entry)
+}
+
+// taskSnapshot is the immutable version of a task held by MemoryTaskStorage. Reads share
+// its append-only history (logs, results and artifacts) and its plan rather than copying
+// them, so reading a task costs the same however long it has been running.
+type taskSnapshot struct {
+	task TaskExecution
+}
+
+// newSnapshot copies a task into a snapshot, reusing the previous snapshot's plan when the
+// task still has it
+func newSnapshot(t *TaskExecution, previous *taskSnapshot) (*taskSnapshot, error) {
+	s := &taskSnapshot{task: *t}
+	s.task.Metadata = maps.Clone(t.Metadata)
+	s.task.Tags = slices.Clone(t.Tags)
+	s.task.Logs = slices.Clone(t.Logs)
+	s.task.Artifacts = slices.Clone(t.Artifacts)
+	s.task.Questions = slices.Clone(t.Questions)
+	s.task.Blackboard = slices.Clone(t.Blackboard)
+	s.task.PlannerEvents = slices.Clone(t.PlannerEvents)
+	s.task.Results = slices.Clone(t.Results)
+	for i := range s.task.Results {
+		s.task.Results[i].Metadata = maps.Clone(t.Results[i].Metadata)
+		// Step artifacts are not part of the stored task; see Artifacts instead
+		s.task.Results[i].Artifacts = nil
+	}
+
+	switch {
+	case t.Plan == nil:
+	case previous != nil && t.Plan == previous.task.Plan:
+	default:
+		plan, err := copyPlan(t.Plan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy task %s: %w", t.ID, err)
+		}
+		s.task.Plan = plan
+	}
+	return s, nil
+}
+
+// copyPlan returns a deep copy of a plan
+func copyPlan(plan *captain.ExecutionPlan) (*captain.ExecutionPlan, error) {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+	var clone captain.ExecutionPlan
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// view returns a task reading the snapshot. Its metadata, tags, questions and blackboard
+// are its own; its logs, results, artifacts and planner events are shared but capped so
+// appending to them copies, and its plan is shared, so callers replace plans rather than
+// modify them.
+func (s *taskSnapshot) view() *TaskExecution {
+	t := s.task
+	t.Metadata = maps.Clone(s.task.Metadata)
+	t.Tags = slices.Clone(s.task.Tags)
+	t.Questions = slices.Clone(s.task.Questions)
+	t.Blackboard = slices.Clone(s.task.Blackboard)
+	t.Logs = slices.Clip(s.task.Logs)
+	t.Results = slices.Clip(s.task.Results)
+	t.Artifacts = slices.Clip(s.task.Artifacts)
+	t.PlannerEvents = slices.Clip(s.task.PlannerEvents)
+	return &t
+}
+
+// withLogs returns a snapshot of the task with entries added to its log. The snapshot owns
+// its log's array, so entries appended past the end are invisible to earlier views.
+func (s *taskSnapshot) withLogs(entries []LogEntry) *taskSnapshot {
+	next := &taskSnapshot{task: s.task}
+	for _, entry := range entries {
+		next.task.Logs = appendLog(next.task.Logs, entry)
+	}
+	return next
+}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/events"
+)
+
+func TestMemoryTaskStorage_SnapshotIsolation(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := searchFixture()
+	te.Metadata["source"] = "test"
+	te.Questions = []Question{{ID: "q-1", Text: "Which region?"}}
+	require.NoError(t, storage.SaveTask(te))
+
+	// Changing the saved task afterwards does not reach storage
+	te.Logs[0].Message = "changed"
+	te.Plan.Tasks[0].ID = "changed"
+	te.Metadata["source"] = "changed"
+
+	first, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "wrote deploy.yaml", first.Logs[0].Message)
+	assert.Equal(t, "step-1", first.Plan.Tasks[0].ID)
+	assert.Equal(t, "test", first.Metadata["source"])
+
+	second, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	first.AddLog(LogLevelInfo, "first reader")
+	second.AddLog(LogLevelInfo, "second reader")
+	first.Results = append(first.Results, first.Results[0])
+	first.Metadata["source"] = "first reader"
+	first.Tags[0] = "changed"
+	_, err = first.AnswerQuestion("q-1", "eu-west-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "second reader", second.Logs[len(second.Logs)-1].Message, "readers append to their own copies")
+	stored, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.Logs, 4)
+	assert.Len(t, stored.Results, 2)
+	assert.Equal(t, "test", stored.Metadata["source"])
+	assert.Equal(t, []string{"infra", "weekly"}, stored.Tags)
+	assert.False(t, stored.Questions[0].Answered())
+}
+
+func TestMemoryTaskStorage_SharesUnchangedPlan(t *testing.T) {
+	storage := NewMemoryTaskStorage()
+	te := searchFixture()
+	require.NoError(t, storage.SaveTask(te))
+
+	loaded, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	loaded.SetStatus(TaskStatusCompleted)
+	require.NoError(t, storage.SaveTask(loaded))
+
+	again, err := storage.GetTask(te.ID)
+	require.NoError(t, err)
+	assert.Same(t, loaded.Plan, again.Plan, "saving a task back does not copy its plan")
+	assert.Equal(t, TaskStatusCompleted, again.Status)
+}
+
+func TestAppendLogs(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			te := NewTaskExecution("deploy")
+			base := time.Now()
+			te.AddMessageLog("", "a", "b", "first", base)
+			te.AddMessageLog("", "a", "b", "third", base.Add(2*time.Second))
+			require.NoError(t, storage.SaveTask(te))
+
+			view, err := storage.GetTask(te.ID)
+			require.NoError(t, err)
+
+			published := NewPublishingStorage(storage, bus)
+			require.NoError(t, AppendLogs(published, te.ID,
+				messageLog("", "a", "b", "fourth", base.Add(3*time.Second)),
+				messageLog("", "a", "b", "second", base.Add(time.Second)),
+			))
+
+			stored, err := storage.GetTask(te.ID)
+			require.NoError(t, err)
+			var messages []string
+			for _, entry := range stored.Logs {
+				messages = append(messages, entry.Message)
+			}
+			assert.Equal(t, []string{"first", "second", "third", "fourth"}, messages)
+			assert.Len(t, view.Logs, 2, "earlier reads do not see the new entries")
+
+			assert.ErrorContains(t, AppendLogs(storage, "task-missing", messageLog("", "a", "b", "x", base)), "task not found")
+		})
+	}
+}
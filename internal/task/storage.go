@@ -0,0 +1,348 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskFilter narrows down the tasks returned by ListTasks. Tasks are listed newest
+// first, with tasks created at the same instant ordered by descending ID, so pages
+// taken with PageToken neither skip nor repeat tasks.
+type TaskFilter struct {
+	Status []TaskStatus
+	Limit  int
+
+	// Tags selects tasks having every one of them
+	Tags []string
+
+	// Workspace selects the tasks recorded in it, along with tasks recorded without one
+	Workspace string
+
+	// GoalHash selects the tasks whose goal is the same once normalized, as GoalHash identifies it
+	GoalHash string
+
+	// Since and Until bound the creation time: Since is inclusive, Until exclusive
+	Since time.Time
+	Until time.Time
+
+	// PageToken resumes a listing after the last task of a previous page, as
+	// returned by NextPageToken; PageSize caps the page like Limit
+	PageToken string
+	PageSize  int
+}
+
+// Matches returns true if the task satisfies the filter's status, tags, workspace, goal and time bounds
+func (f TaskFilter) Matches(t *TaskExecution) bool {
+	if !f.Since.IsZero() && t.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !t.CreatedAt.Before(f.Until) {
+		return false
+	}
+	if !hasTags(t.Tags, f.Tags) || !t.InWorkspace(f.Workspace) {
+		return false
+	}
+	if f.GoalHash != "" && GoalHash(t.Goal) != f.GoalHash {
+		return false
+	}
+	return len(f.Status) == 0 || slices.Contains(f.Status, t.Status)
+}
+
+// pageLimit returns the maximum number of tasks to return, 0 meaning no limit
+func (f TaskFilter) pageLimit() int {
+	switch {
+	case f.Limit > 0 && f.PageSize > 0:
+		return min(f.Limit, f.PageSize)
+	case f.PageSize > 0:
+		return f.PageSize
+	}
+	return max(f.Limit, 0)
+}
+
+// uniqueStatuses returns the filter's statuses without duplicates
+func (f TaskFilter) uniqueStatuses() []TaskStatus {
+	statuses := slices.Clone(f.Status)
+	slices.Sort(statuses)
+	return slices.Compact(statuses)
+}
+
+// TaskStorage defines the contract for persisting task executions
+type TaskStorage interface {
+	SaveTask(t *TaskExecution) error
+	GetTask(id string) (*TaskExecution, error)
+	ListTasks(filter TaskFilter) ([]*TaskExecution, error)
+	DeleteTask(id string) error
+}
+
+// MemoryTaskStorage is an in-memory implementation of TaskStorage. It keeps an immutable
+// snapshot of each task; tasks it returns share the snapshot's logs, results, artifacts
+// and plan, which callers must append to or replace rather than modify in place.
+type MemoryTaskStorage struct {
+	mu    sync.RWMutex
+	tasks map[string]*taskSnapshot
+	index *taskIndex
+}
+
+// NewMemoryTaskStorage creates a new in-memory task storage
+func NewMemoryTaskStorage() *MemoryTaskStorage {
+	return &MemoryTaskStorage{
+		tasks: make(map[string]*taskSnapshot),
+		index: newTaskIndex(),
+	}
+}
+
+// SaveTask stores a snapshot of the task, replacing any previous version
+func (s *MemoryTaskStorage) SaveTask(t *TaskExecution) error {
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("invalid task: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, err := newSnapshot(t, s.tasks[t.ID])
+	if err != nil {
+		return err
+	}
+	s.tasks[t.ID] = snapshot
+	s.index.put(&snapshot.task)
+	return nil
+}
+
+// AppendLogs adds entries to the log of a stored task without copying the rest of it
+func (s *MemoryTaskStorage) AppendLogs(id string, entries ...LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, exists := s.tasks[id]
+	if !exists {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	s.tasks[id] = snapshot.withLogs(entries)
+	return nil
+}
+
+// GetTask returns the task with the given ID
+func (s *MemoryTaskStorage) GetTask(id string) (*TaskExecution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, exists := s.tasks[id]
+	if !exists {
+		return nil, fmt.Errorf("task not found: %s", id)
+	}
+	return snapshot.view(), nil
+}
+
+// ListTasks returns the tasks on the page the filter selects, newest first
+func (s *MemoryTaskStorage) ListTasks(filter TaskFilter) ([]*TaskExecution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, err := s.index.query(filter)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*TaskExecution, 0, len(ids))
+	for _, id := range ids {
+		tasks = append(tasks, s.tasks[id].view())
+	}
+	return tasks, nil
+}
+
+// DeleteTask removes a task from storage
+func (s *MemoryTaskStorage) DeleteTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[id]; !exists {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	delete(s.tasks, id)
+	s.index.remove(id)
+	return nil
+}
+
+// FileTaskStorage persists each task as a JSON document in a directory. It keeps an
+// index of the directory in memory and, since other processes write to the same
+// directory, refreshes it before listing by re-reading only files that changed.
+type FileTaskStorage struct {
+	mu     sync.RWMutex
+	dir    string
+	index  *taskIndex
+	stamps map[string]fileStamp
+}
+
+// fileStamp identifies the version of a task file the index was built from
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// NewFileTaskStorage creates a file-backed task storage rooted at dir
+func NewFileTaskStorage(dir string) (*FileTaskStorage, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("storage directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory %s: %w", dir, err)
+	}
+	return &FileTaskStorage{dir: dir, index: newTaskIndex(), stamps: make(map[string]fileStamp)}, nil
+}
+
+// Dir returns the directory the storage writes to
+func (s *FileTaskStorage) Dir() string {
+	return s.dir
+}
+
+// path returns the file path for a task ID
+func (s *FileTaskStorage) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// SaveTask writes the task to disk atomically
+func (s *FileTaskStorage) SaveTask(t *TaskExecution) error {
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("invalid task: %w", err)
+	}
+	if strings.ContainsAny(t.ID, `/\`) {
+		return fmt.Errorf("invalid task ID: %s", t.ID)
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode task %s: %w", t.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path(t.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write task %s: %w", t.ID, err)
+	}
+	if err := os.Rename(tmp, s.path(t.ID)); err != nil {
+		return fmt.Errorf("failed to write task %s: %w", t.ID, err)
+	}
+
+	// Index our own write so the next listing does not have to read it back
+	if info, err := os.Stat(s.path(t.ID)); err == nil {
+		s.index.put(t)
+		s.stamps[t.ID] = stampOf(info)
+	}
+	return nil
+}
+
+// GetTask reads the task with the given ID from disk
+func (s *FileTaskStorage) GetTask(id string) (*TaskExecution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readTask(s.path(id))
+}
+
+// readTask decodes a task file; callers must hold the lock
+func (s *FileTaskStorage) readTask(path string) (*TaskExecution, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("task not found: %s", strings.TrimSuffix(filepath.Base(path), ".json"))
+		}
+		return nil, fmt.Errorf("failed to read task file %s: %w", path, err)
+	}
+
+	var t TaskExecution
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse task file %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// refresh brings the index up to date with the directory; callers must hold the write lock
+func (s *FileTaskStorage) refresh() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read task file %s: %w", path, err)
+		}
+		seen[id] = true
+		if stamp, ok := s.stamps[id]; ok && stamp == stampOf(info) && s.index.has(id) {
+			continue
+		}
+
+		t, err := s.readTask(path)
+		if err != nil {
+			return err
+		}
+		// Index under the file name so lookups by ID find the file
+		t.ID = id
+		s.index.put(t)
+		s.stamps[id] = stampOf(info)
+	}
+
+	for _, id := range s.index.ids() {
+		if !seen[id] {
+			s.index.remove(id)
+			delete(s.stamps, id)
+		}
+	}
+	return nil
+}
+
+// ListTasks returns the stored tasks on the page the filter selects, newest first
+func (s *FileTaskStorage) ListTasks(filter TaskFilter) ([]*TaskExecution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	ids, err := s.index.query(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*TaskExecution, 0, len(ids))
+	for _, id := range ids {
+		t, err := s.readTask(s.path(id))
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// DeleteTask removes a task file from disk
+func (s *FileTaskStorage) DeleteTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("task not found: %s", id)
+		}
+		return fmt.Errorf("failed to delete task %s: %w", id, err)
+	}
+	s.index.remove(id)
+	delete(s.stamps, id)
+	return nil
+}
@@ -0,0 +1,185 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+func storages(t *testing.T) map[string]TaskStorage {
+	fileStorage, err := NewFileTaskStorage(t.TempDir())
+	require.NoError(t, err)
+
+	return map[string]TaskStorage{
+		"memory": NewMemoryTaskStorage(),
+		"file":   fileStorage,
+	}
+}
+
+func TestTaskStorage_SaveAndGet(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			te := NewTaskExecution("analyze code")
+			te.Metadata["source"] = "test"
+			require.NoError(t, storage.SaveTask(te))
+
+			loaded, err := storage.GetTask(te.ID)
+			require.NoError(t, err)
+			assert.Equal(t, te.ID, loaded.ID)
+			assert.Equal(t, "analyze code", loaded.Goal)
+			assert.Equal(t, "test", loaded.Metadata["source"])
+
+			// Mutating the loaded copy must not affect storage
+			loaded.Goal = "changed"
+			again, err := storage.GetTask(te.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "analyze code", again.Goal)
+		})
+	}
+}
+
+func TestTaskStorage_GetMissing(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := storage.GetTask("task-missing")
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "task not found")
+		})
+	}
+}
+
+func TestTaskStorage_SaveInvalid(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			err := storage.SaveTask(&TaskExecution{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTaskStorage_ListTasks(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			base := time.Now()
+			for i, status := range []TaskStatus{TaskStatusCompleted, TaskStatusFailed, TaskStatusRunning} {
+				te := NewTaskExecution("goal")
+				te.Status = status
+				te.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+				require.NoError(t, storage.SaveTask(te))
+			}
+
+			all, err := storage.ListTasks(TaskFilter{})
+			require.NoError(t, err)
+			require.Len(t, all, 3)
+			assert.Equal(t, TaskStatusRunning, all[0].Status, "newest task should come first")
+
+			failed, err := storage.ListTasks(TaskFilter{Status: []TaskStatus{TaskStatusFailed}})
+			require.NoError(t, err)
+			require.Len(t, failed, 1)
+			assert.Equal(t, TaskStatusFailed, failed[0].Status)
+
+			limited, err := storage.ListTasks(TaskFilter{Limit: 2})
+			require.NoError(t, err)
+			assert.Len(t, limited, 2)
+		})
+	}
+}
+
+func TestTaskStorage_DeleteTask(t *testing.T) {
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			te := NewTaskExecution("goal")
+			require.NoError(t, storage.SaveTask(te))
+			require.NoError(t, storage.DeleteTask(te.ID))
+
+			_, err := storage.GetTask(te.ID)
+			assert.Error(t, err)
+			assert.Error(t, storage.DeleteTask(te.ID))
+		})
+	}
+}
+
+func TestNewFileTaskStorage_EmptyDir(t *testing.T) {
+	_, err := NewFileTaskStorage("")
+	assert.Error(t, err)
+}
+
+// seedBenchStorage fills a memory storage with n tasks spread evenly across statuses
+func seedBenchStorage(b *testing.B, n int) *MemoryTaskStorage {
+	b.Helper()
+	statuses := []TaskStatus{TaskStatusPending, TaskStatusRunning, TaskStatusCompleted, TaskStatusFailed}
+	storage := NewMemoryTaskStorage()
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		te := NewTaskExecution("benchmark goal")
+		te.ID = fmt.Sprintf("task-%07d", i)
+		te.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		te.SetStatus(statuses[i%len(statuses)])
+		te.AddLog(LogLevelInfo, "seeded")
+		require.NoError(b, storage.SaveTask(te))
+	}
+	return storage
+}
+
+func BenchmarkMemoryTaskStorage_ListTasks100k(b *testing.B) {
+	storage := seedBenchStorage(b, 100_000)
+
+	filters := map[string]TaskFilter{
+		"all":          {},
+		"status":       {Status: []TaskStatus{TaskStatusFailed}},
+		"status_limit": {Status: []TaskStatus{TaskStatusRunning, TaskStatusPending}, Limit: 20},
+		"limit":        {Limit: 20},
+	}
+	for name, filter := range filters {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.ListTasks(filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// chattyTask returns a running task with a plan, results and a log of n agent messages
+func chattyTask(n int) *TaskExecution {
+	te := searchFixture()
+	te.SetStatus(TaskStatusRunning)
+	start := time.Now().Add(-time.Duration(n) * time.Millisecond)
+	for i := 0; i < n; i++ {
+		te.AddMessageLog("step-2", "NetworkAgent-001", "captain", fmt.Sprintf("progress update %d", i), start.Add(time.Duration(i)*time.Millisecond))
+	}
+	return te
+}
+
+func BenchmarkMemoryTaskStorage_ChattyTask(b *testing.B) {
+	for _, n := range []int{100, 10_000} {
+		storage := NewMemoryTaskStorage()
+		te := chattyTask(n)
+		require.NoError(b, storage.SaveTask(te))
+
+		b.Run(fmt.Sprintf("get/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.GetTask(te.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("record_message/%d", n), func(b *testing.B) {
+			recorder := NewMessageRecorder(agents.NewMemoryCommunicationLogger(), storage)
+			message := agents.Message{Content: "progress", Data: map[string]any{"task_id": te.ID, "step": "step-2"}}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				message.Timestamp = time.Now()
+				recorder.LogMessage("NetworkAgent-001", "captain", message)
+			}
+		})
+	}
+}
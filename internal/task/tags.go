@@ -0,0 +1,83 @@
+package task
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxTagLength bounds the length of a tag
+const maxTagLength = 64
+
+// NormalizeTag lowercases and trims a tag, returning an error if it is empty, too long or
+// contains characters other than letters, digits, '-', '_', '.', ':' and '/'
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", fmt.Errorf("tag cannot be empty")
+	}
+	if len(tag) > maxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:/", r) {
+			return "", fmt.Errorf("tag %q contains invalid character %q", tag, r)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes tags, returning them sorted and without duplicates
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// AddTags adds tags to the task, keeping its tags sorted and unique
+func (t *TaskExecution) AddTags(tags ...string) error {
+	tags, err := NormalizeTags(append(slices.Clone(t.Tags), tags...))
+	if err != nil {
+		return err
+	}
+	t.Tags = tags
+	return nil
+}
+
+// RemoveTags removes tags from the task; tags it does not have are ignored
+func (t *TaskExecution) RemoveTags(tags ...string) error {
+	remove, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	t.Tags = slices.DeleteFunc(slices.Clone(t.Tags), func(tag string) bool {
+		return slices.Contains(remove, tag)
+	})
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
+	return nil
+}
+
+// HasTags reports whether the task has every one of tags
+func (t *TaskExecution) HasTags(tags ...string) bool {
+	return hasTags(t.Tags, tags)
+}
+
+// hasTags reports whether have contains every one of want, compared case-insensitively
+func hasTags(have, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(have, strings.ToLower(strings.TrimSpace(tag))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr string
+	}{
+		{tag: "infra", want: "infra"},
+		{tag: " Weekly ", want: "weekly"},
+		{tag: "team/platform:q3", want: "team/platform:q3"},
+		{tag: "", wantErr: "tag cannot be empty"},
+		{tag: "two words", wantErr: "invalid character ' '"},
+		{tag: "urgent!", wantErr: "invalid character '!'"},
+		{tag: strings.Repeat("a", maxTagLength+1), wantErr: "longer than 64 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := NormalizeTag(tt.tag)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTaskExecution_Tags(t *testing.T) {
+	te := NewTaskExecution("rotate credentials")
+
+	require.NoError(t, te.AddTags("weekly", "Infra", "infra"))
+	assert.Equal(t, []string{"infra", "weekly"}, te.Tags)
+	assert.True(t, te.HasTags("INFRA", "weekly"))
+	assert.False(t, te.HasTags("infra", "urgent"))
+
+	require.ErrorContains(t, te.AddTags("bad tag"), "invalid character")
+	assert.Equal(t, []string{"infra", "weekly"}, te.Tags, "an invalid tag leaves the tags unchanged")
+
+	require.NoError(t, te.RemoveTags("weekly", "missing"))
+	assert.Equal(t, []string{"infra"}, te.Tags)
+	require.NoError(t, te.RemoveTags("infra"))
+	assert.Nil(t, te.Tags)
+	assert.True(t, te.HasTags())
+}
@@ -0,0 +1,123 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// TimelineKind classifies an event on a task's timeline
+type TimelineKind string
+
+const (
+	// TimelineTask marks the task being created, started and finished
+	TimelineTask TimelineKind = "task"
+	// TimelineStep marks a plan step starting or finishing
+	TimelineStep TimelineKind = "step"
+	// TimelineMessage is a message routed between agents
+	TimelineMessage TimelineKind = "message"
+	// TimelineLog is any other entry in the task log
+	TimelineLog TimelineKind = "log"
+)
+
+// TimelineEvent is one point on a task's timeline
+type TimelineEvent struct {
+	At      time.Time    `json:"at"`
+	Kind    TimelineKind `json:"kind"`
+	Level   LogLevel     `json:"level,omitempty"`
+	Step    string       `json:"step,omitempty"`
+	Agent   string       `json:"agent,omitempty"`
+	From    string       `json:"from,omitempty"`
+	To      string       `json:"to,omitempty"`
+	Message string       `json:"message"`
+}
+
+// Timeline orders what was recorded while the task ran: its lifecycle, each step starting and
+// finishing, agent messages and log entries. Command output lines are left out.
+func (t *TaskExecution) Timeline() []TimelineEvent {
+	events := []TimelineEvent{{At: t.CreatedAt, Kind: TimelineTask, Message: "Task created: " + t.Goal}}
+	if !t.StartedAt.IsZero() {
+		events = append(events, TimelineEvent{At: t.StartedAt, Kind: TimelineTask, Message: "Task started"})
+	}
+
+	for _, result := range t.Results {
+		agent, _ := result.Metadata["agent_id"].(string)
+		// Results recorded without a time are placed when the task started
+		if result.Timestamp.IsZero() {
+			result.Timestamp = t.StartedAt
+			if result.Timestamp.IsZero() {
+				result.Timestamp = t.CreatedAt
+			}
+		}
+		if result.Duration > 0 {
+			events = append(events, TimelineEvent{
+				At:      result.Timestamp.Add(-result.Duration),
+				Kind:    TimelineStep,
+				Step:    result.TaskID,
+				Agent:   agent,
+				Message: fmt.Sprintf("Step %s started", result.TaskID),
+			})
+		}
+		events = append(events, TimelineEvent{
+			At:      result.Timestamp,
+			Kind:    TimelineStep,
+			Level:   stepLevel(result),
+			Step:    result.TaskID,
+			Agent:   agent,
+			Message: stepOutcome(result),
+		})
+	}
+
+	for _, entry := range t.Logs {
+		if entry.Stream != "" {
+			continue
+		}
+		kind := TimelineLog
+		if entry.IsMessage() {
+			kind = TimelineMessage
+		}
+		events = append(events, TimelineEvent{
+			At:      entry.Timestamp,
+			Kind:    kind,
+			Level:   entry.Level,
+			Step:    entry.Step,
+			Agent:   entry.Agent,
+			From:    entry.From,
+			To:      entry.To,
+			Message: entry.Message,
+		})
+	}
+
+	if !t.CompletedAt.IsZero() {
+		message := fmt.Sprintf("Task %s", t.Status)
+		if t.Error != "" {
+			message += ": " + t.Error
+		}
+		events = append(events, TimelineEvent{At: t.CompletedAt, Kind: TimelineTask, Message: message})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	return events
+}
+
+// stepOutcome describes how a step finished
+func stepOutcome(result captain.Result) string {
+	message := fmt.Sprintf("Step %s %s", result.TaskID, result.Status())
+	if result.Duration > 0 {
+		message += fmt.Sprintf(" after %s", result.Duration.Round(time.Millisecond))
+	}
+	if result.Error != "" {
+		message += ": " + result.Error
+	}
+	return message
+}
+
+// stepLevel is the log level a step's outcome is shown at
+func stepLevel(result captain.Result) LogLevel {
+	if result.Success {
+		return LogLevelInfo
+	}
+	return LogLevelError
+}
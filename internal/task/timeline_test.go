@@ -0,0 +1,45 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func TestTaskExecution_Timeline(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	te := &TaskExecution{
+		ID:          "task-1",
+		Goal:        "deploy",
+		Status:      TaskStatusFailed,
+		Error:       "1 task(s) failed",
+		CreatedAt:   start,
+		StartedAt:   start.Add(time.Second),
+		CompletedAt: start.Add(5 * time.Second),
+		Results: []captain.Result{
+			{TaskID: "build", Success: true, Duration: time.Second, Timestamp: start.Add(2 * time.Second), Metadata: map[string]any{"agent_id": "file-001"}},
+			{TaskID: "push", Error: "rejected", Timestamp: start.Add(4 * time.Second)},
+		},
+		Logs: []LogEntry{
+			{Timestamp: start.Add(3 * time.Second), Level: LogLevelInfo, From: "file-001", To: "network-001", Message: "artifact ready", Step: "push"},
+			{Timestamp: start.Add(3500 * time.Millisecond), Level: LogLevelInfo, Step: "push", Stream: "stdout", Message: "pushing"},
+		},
+	}
+
+	var got []string
+	for _, event := range te.Timeline() {
+		got = append(got, event.At.Sub(start).String()+" "+string(event.Kind)+" "+event.Message)
+	}
+	assert.Equal(t, []string{
+		"0s task Task created: deploy",
+		"1s task Task started",
+		"1s step Step build started",
+		"2s step Step build succeeded after 1s",
+		"3s message artifact ready",
+		"4s step Step push failed: rejected",
+		"5s task Task failed: 1 task(s) failed",
+	}, got, "command output is left out")
+}
@@ -0,0 +1,133 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// captainSpeaker names entries logged by the captain rather than a crew agent in transcripts
+const captainSpeaker = "captain"
+
+// Transcript renders the task as a Markdown document: the captain's plan and reasoning, the
+// IRC-style conversation between agents and the captain, questions asked, and each step's output.
+// A non-empty summary is included as its own section after the task details.
+func (t *TaskExecution) Transcript(summary string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Task %s\n\n", t.ID)
+	fmt.Fprintf(&b, "- **Goal:** %s\n", t.Goal)
+	fmt.Fprintf(&b, "- **Status:** %s\n", t.Status)
+	if len(t.Tags) > 0 {
+		fmt.Fprintf(&b, "- **Tags:** %s\n", strings.Join(t.Tags, ", "))
+	}
+	fmt.Fprintf(&b, "- **Created:** %s\n", t.CreatedAt.Format(time.RFC3339))
+	if !t.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "- **Duration:** %s\n", t.Duration().Round(time.Millisecond))
+	}
+	if t.Error != "" {
+		fmt.Fprintf(&b, "- **Error:** %s\n", t.Error)
+	}
+	if summary = strings.TrimSpace(summary); summary != "" {
+		fmt.Fprintf(&b, "\n## Summary\n\n%s\n", summary)
+	}
+
+	if t.Plan != nil {
+		fmt.Fprintf(&b, "\n## Plan\n\n")
+		fmt.Fprintf(&b, "Plan `%s` runs %d step(s) with the %s strategy.\n", t.Plan.ID, len(t.Plan.Tasks), t.Plan.Strategy.Type)
+		if reasoning := strings.TrimSpace(t.Plan.Strategy.Description); reasoning != "" {
+			fmt.Fprintf(&b, "\n> %s\n", strings.ReplaceAll(reasoning, "\n", "\n> "))
+		}
+		b.WriteString("\n")
+		for i, step := range t.Plan.Tasks {
+			fmt.Fprintf(&b, "%d. `%s` [%s] %v", i+1, step.ID, step.Type, step.Payload["description"])
+			if len(step.Dependencies) > 0 {
+				fmt.Fprintf(&b, " (after %s)", strings.Join(step.Dependencies, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(t.Logs) > 0 {
+		fmt.Fprintf(&b, "\n## Conversation\n\n")
+		var lines []string
+		for _, entry := range t.Logs {
+			lines = append(lines, transcriptLine(entry))
+		}
+		writeFenced(&b, "text", strings.Join(lines, "\n"))
+	}
+
+	if len(t.Questions) > 0 {
+		fmt.Fprintf(&b, "\n## Questions\n\n")
+		for _, q := range t.Questions {
+			fmt.Fprintf(&b, "- **%s** (%s): %s\n", q.ID, orDefault(q.Agent, "agent"), q.Text)
+			if q.Answered() {
+				fmt.Fprintf(&b, "  - Answer: %s\n", q.Answer)
+			} else {
+				fmt.Fprintf(&b, "  - Unanswered\n")
+			}
+		}
+	}
+
+	if len(t.Results) > 0 {
+		fmt.Fprintf(&b, "\n## Step Outputs\n")
+		statuses := t.StepStatuses()
+		for _, result := range t.Results {
+			fmt.Fprintf(&b, "\n### %s (%s)\n\n", result.TaskID, transcriptStepStatus(statuses, result))
+			if agent, _ := result.Metadata["agent_id"].(string); agent != "" {
+				fmt.Fprintf(&b, "Run by `%s`", agent)
+				if result.Duration > 0 {
+					fmt.Fprintf(&b, " in %s", result.Duration.Round(time.Millisecond))
+				}
+				b.WriteString(".\n\n")
+			}
+			if output := strings.TrimSpace(result.Output); output != "" {
+				writeFenced(&b, "", output)
+			}
+			if result.Error != "" {
+				fmt.Fprintf(&b, "**Error:** %s\n", result.Error)
+			}
+		}
+	}
+	return b.String()
+}
+
+// transcriptLine renders a log entry as an IRC-style line: messages as "<from> to: text",
+// step progress as the agent speaking and everything else as the captain
+func transcriptLine(entry LogEntry) string {
+	at := entry.Timestamp.Format("15:04:05")
+	if entry.IsMessage() {
+		return fmt.Sprintf("[%s] <%s> %s: %s", at, orDefault(entry.From, captainSpeaker), orDefault(entry.To, "all"), entry.Message)
+	}
+	message := entry.Message
+	if entry.Level == LogLevelWarn || entry.Level == LogLevelError {
+		message = strings.ToUpper(string(entry.Level)) + ": " + message
+	}
+	return fmt.Sprintf("[%s] <%s> %s", at, orDefault(entry.Agent, captainSpeaker), message)
+}
+
+// transcriptStepStatus names a step's outcome for its heading
+func transcriptStepStatus(statuses map[string]captain.StepStatus, result captain.Result) string {
+	if status, ok := statuses[result.TaskID]; ok {
+		return string(status)
+	}
+	return string(result.Status())
+}
+
+// writeFenced writes content in a fenced code block, lengthening the fence when the content contains one
+func writeFenced(b *strings.Builder, lang, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n", fence, lang, content, fence)
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package task
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func transcriptTask() *TaskExecution {
+	te := NewTaskExecution("summarize the docs")
+	te.Tags = []string{"docs", "weekly"}
+	te.Plan = &captain.ExecutionPlan{
+		ID:       "plan-1",
+		Goal:     te.Goal,
+		Strategy: captain.ExecutionStrategy{Type: captain.StrategySequential, Description: "Read first,\nthen write."},
+		Tasks: []captain.Task{
+			{ID: "task-1", Type: captain.TaskTypeAnalysis, Payload: map[string]any{"description": "Read docs"}},
+			{ID: "task-2", Type: captain.TaskTypeReporting, Dependencies: []string{"task-1"}, Payload: map[string]any{"description": "Write summary"}},
+		},
+	}
+	at := time.Date(2025, 8, 3, 10, 15, 32, 0, time.Local)
+	te.AddLog(LogLevelInfo, "Plan plan-1 created with 2 tasks")
+	te.Logs[0].Timestamp = at
+	te.AddMessageLog("task-1", "research-001", "file-001", "Need docs/ contents", at.Add(time.Second))
+	te.AddStepLog(LogLevelWarn, "task-2", "file-001", "retrying write")
+	te.Logs[2].Timestamp = at.Add(2 * time.Second)
+	te.Ask("task-2", "file-001", "Where should the summary go?")
+	_, _ = te.AnswerQuestion("", "SUMMARY.md")
+	te.Results = []captain.Result{
+		{TaskID: "task-1", Success: true, Output: "found ```code``` in docs", Duration: 1500 * time.Millisecond, Metadata: map[string]any{"agent_id": "research-001"}},
+		{TaskID: "task-2", Success: false, Error: "disk full"},
+	}
+	te.SetStatus(TaskStatusFailed)
+	return te
+}
+
+func TestTaskExecution_Transcript(t *testing.T) {
+	transcript := transcriptTask().Transcript("")
+
+	assert.True(t, strings.HasPrefix(transcript, "# Task task-"))
+	assert.Contains(t, transcript, "- **Goal:** summarize the docs\n")
+	assert.Contains(t, transcript, "- **Status:** failed\n- **Tags:** docs, weekly\n")
+	assert.NotContains(t, transcript, "## Summary")
+
+	assert.Contains(t, transcript, "Plan `plan-1` runs 2 step(s) with the sequential strategy.")
+	assert.Contains(t, transcript, "> Read first,\n> then write.\n")
+	assert.Contains(t, transcript, "2. `task-2` [reporting] Write summary (after task-1)\n")
+
+	assert.Contains(t, transcript, "[10:15:32] <captain> Plan plan-1 created with 2 tasks\n")
+	assert.Contains(t, transcript, "[10:15:33] <research-001> file-001: Need docs/ contents\n")
+	assert.Contains(t, transcript, "[10:15:34] <file-001> WARN: retrying write\n")
+
+	assert.Contains(t, transcript, "- **q-1** (file-001): Where should the summary go?\n  - Answer: SUMMARY.md\n")
+
+	assert.Contains(t, transcript, "### task-1 (succeeded)\n\nRun by `research-001` in 1.5s.\n\n````\nfound ```code``` in docs\n````\n",
+		"outputs containing fences get a longer fence")
+	assert.Contains(t, transcript, "### task-2 (failed)\n\n**Error:** disk full\n")
+}
+
+func TestTaskExecution_TranscriptSummary(t *testing.T) {
+	transcript := transcriptTask().Transcript("  The summary could not be written.\n")
+	assert.Contains(t, transcript, "\n## Summary\n\nThe summary could not be written.\n\n## Plan\n")
+}
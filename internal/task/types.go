@@ -0,0 +1,215 @@
+package task
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+// TaskStatus represents the lifecycle state of a task execution
+type TaskStatus string
+
+const (
+	TaskStatusPending   TaskStatus = "pending"
+	TaskStatusQueued    TaskStatus = "queued"
+	TaskStatusPlanning  TaskStatus = "planning"
+	TaskStatusRunning   TaskStatus = "running"
+	TaskStatusCompleted TaskStatus = "completed"
+	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusCancelled TaskStatus = "cancelled"
+	// TaskStatusInterrupted tasks were left unfinished when the capn process running them exited
+	TaskStatusInterrupted TaskStatus = "interrupted"
+)
+
+// IsTerminal returns true if the status represents a finished task
+func (s TaskStatus) IsTerminal() bool {
+	switch s {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled, TaskStatusInterrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// LogLevel represents the severity of a task log entry
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+// LogEntry represents a single entry in a task's log
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     LogLevel  `json:"level"`
+	Message   string    `json:"message"`
+	Step      string    `json:"step,omitempty"`
+	Agent     string    `json:"agent,omitempty"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	// Stream is set on lines of command output, naming the stream they were written to
+	Stream string `json:"stream,omitempty"`
+}
+
+// IsMessage reports whether the entry records a message routed between agents
+func (e LogEntry) IsMessage() bool {
+	return e.From != "" || e.To != ""
+}
+
+// TaskExecution represents a goal submitted to capn and everything recorded while running it
+type TaskExecution struct {
+	ID          string                 `json:"id"`
+	Goal        string                 `json:"goal"`
+	Status      TaskStatus             `json:"status"`
+	Plan        *captain.ExecutionPlan `json:"plan,omitempty"`
+	Results     []captain.Result       `json:"results,omitempty"`
+	Logs        []LogEntry             `json:"logs,omitempty"`
+	Artifacts   []Artifact             `json:"artifacts,omitempty"`
+	Questions   []Question             `json:"questions,omitempty"`
+	Blackboard  []BlackboardEntry      `json:"blackboard,omitempty"`
+	Report      *captain.OutcomeReport `json:"report,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	BatchID     string                 `json:"batch_id,omitempty"`
+	PipelineID  string                 `json:"pipeline_id,omitempty"`
+	Metadata    map[string]string      `json:"metadata,omitempty"`
+	Priority    int                    `json:"priority,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Workspace   string                 `json:"workspace,omitempty"`
+	After       []Dependency           `json:"after,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	QueuedAt    time.Time              `json:"queued_at,omitempty"`
+	StartedAt   time.Time              `json:"started_at,omitempty"`
+	CompletedAt time.Time              `json:"completed_at,omitempty"`
+
+	// PlannerEvents are the intermediate artifacts of planning the goal, for "capn plans debug"
+	PlannerEvents []captain.PlannerEvent `json:"planner_events,omitempty"`
+
+	// checkpointed counts the results CheckpointStep recorded during the current execution
+	checkpointed int
+}
+
+// NewTaskExecution creates a new pending task execution for a goal
+func NewTaskExecution(goal string) *TaskExecution {
+	t := &TaskExecution{
+		ID:        NewTaskID(),
+		Goal:      goal,
+		Status:    TaskStatusPending,
+		Metadata:  make(map[string]string),
+		CreatedAt: time.Now(),
+	}
+	t.Claim()
+	return t
+}
+
+// NewTaskID generates a short, human-friendly task identifier
+func NewTaskID() string {
+	return fmt.Sprintf("task-%s", uuid.New().String()[:8])
+}
+
+// Validate validates the task execution
+func (t *TaskExecution) Validate() error {
+	if t.ID == "" {
+		return fmt.Errorf("task ID cannot be empty")
+	}
+	if t.Status == "" {
+		return fmt.Errorf("task status cannot be empty")
+	}
+	return nil
+}
+
+// AddLog appends a log entry to the task
+func (t *TaskExecution) AddLog(level LogLevel, message string) {
+	t.Logs = append(t.Logs, LogEntry{
+		Timestamp: time.Now(),
+		Level:     level,
+		Message:   message,
+	})
+}
+
+// AddStepLog appends a log entry attributed to a plan step and the agent working on it
+func (t *TaskExecution) AddStepLog(level LogLevel, step, agent, message string) {
+	t.Logs = append(t.Logs, LogEntry{
+		Timestamp: time.Now(),
+		Level:     level,
+		Message:   message,
+		Step:      step,
+		Agent:     agent,
+	})
+}
+
+// AddMessageLog records a message routed between agents working on the task, keeping the log in time order
+func (t *TaskExecution) AddMessageLog(step, from, to, content string, at time.Time) {
+	t.Logs = appendLog(t.Logs, messageLog(step, from, to, content, at))
+}
+
+// messageLog returns the log entry recording a message routed between agents
+func messageLog(step, from, to, content string, at time.Time) LogEntry {
+	return LogEntry{
+		Timestamp: at,
+		Level:     LogLevelInfo,
+		Message:   content,
+		Step:      step,
+		Agent:     from,
+		From:      from,
+		To:        to,
+	}
+}
+
+// Artifact returns the registered artifact with the given name
+func (t *TaskExecution) Artifact(name string) (Artifact, bool) {
+	for _, artifact := range t.Artifacts {
+		if artifact.Name == name {
+			return artifact, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// SetStatus transitions the task to a new status, recording start and completion times
+func (t *TaskExecution) SetStatus(status TaskStatus) {
+	now := time.Now()
+	if status == TaskStatusRunning && t.StartedAt.IsZero() {
+		t.StartedAt = now
+	}
+	if status.IsTerminal() && t.CompletedAt.IsZero() {
+		t.CompletedAt = now
+	}
+	t.Status = status
+}
+
+// Progress returns the number of finished steps and the total number of steps in the plan
+func (t *TaskExecution) Progress() (done, total int) {
+	if t.Plan == nil {
+		return 0, 0
+	}
+	return len(t.Results), len(t.Plan.Tasks)
+}
+
+// Resources returns what the task's steps consumed, summed over every recorded result
+func (t *TaskExecution) Resources() agents.ResourceUsage {
+	var usage agents.ResourceUsage
+	for _, result := range t.Results {
+		if result.Resources != nil {
+			usage.Add(*result.Resources)
+		}
+	}
+	return usage
+}
+
+// Duration returns how long the task has been running, or ran for if it finished
+func (t *TaskExecution) Duration() time.Duration {
+	if t.StartedAt.IsZero() {
+		return 0
+	}
+	if t.CompletedAt.IsZero() {
+		return time.Since(t.StartedAt)
+	}
+	return t.CompletedAt.Sub(t.StartedAt)
+}
@@ -0,0 +1,116 @@
+package task
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+)
+
+func TestNewTaskExecution(t *testing.T) {
+	te := NewTaskExecution("deploy the app")
+
+	assert.True(t, strings.HasPrefix(te.ID, "task-"))
+	assert.Equal(t, "deploy the app", te.Goal)
+	assert.Equal(t, TaskStatusPending, te.Status)
+	assert.NotNil(t, te.Metadata)
+	assert.False(t, te.CreatedAt.IsZero())
+	assert.NoError(t, te.Validate())
+}
+
+func TestTaskStatus_IsTerminal(t *testing.T) {
+	tests := []struct {
+		status   TaskStatus
+		terminal bool
+	}{
+		{TaskStatusPending, false},
+		{TaskStatusPlanning, false},
+		{TaskStatusRunning, false},
+		{TaskStatusCompleted, true},
+		{TaskStatusFailed, true},
+		{TaskStatusCancelled, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			assert.Equal(t, tt.terminal, tt.status.IsTerminal())
+		})
+	}
+}
+
+func TestTaskExecution_SetStatus(t *testing.T) {
+	te := NewTaskExecution("goal")
+
+	te.SetStatus(TaskStatusRunning)
+	assert.Equal(t, TaskStatusRunning, te.Status)
+	assert.False(t, te.StartedAt.IsZero())
+	assert.True(t, te.CompletedAt.IsZero())
+
+	te.SetStatus(TaskStatusCompleted)
+	assert.Equal(t, TaskStatusCompleted, te.Status)
+	assert.False(t, te.CompletedAt.IsZero())
+	assert.GreaterOrEqual(t, te.Duration(), time.Duration(0))
+}
+
+func TestTaskExecution_Progress(t *testing.T) {
+	te := NewTaskExecution("goal")
+	done, total := te.Progress()
+	assert.Equal(t, 0, done)
+	assert.Equal(t, 0, total)
+
+	te.Plan = &captain.ExecutionPlan{
+		ID:    "plan-1",
+		Goal:  "goal",
+		Tasks: []captain.Task{{ID: "task-1"}, {ID: "task-2"}},
+	}
+	te.Results = []captain.Result{{TaskID: "task-1", Success: true}}
+
+	done, total = te.Progress()
+	assert.Equal(t, 1, done)
+	assert.Equal(t, 2, total)
+}
+
+func TestTaskExecution_AddLog(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.AddLog(LogLevelInfo, "planning started")
+
+	assert.Len(t, te.Logs, 1)
+	assert.Equal(t, LogLevelInfo, te.Logs[0].Level)
+	assert.Equal(t, "planning started", te.Logs[0].Message)
+}
+
+func TestTaskExecution_AddStepLog(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.AddStepLog(LogLevelWarn, "task-2", "file-001", "agent lost")
+
+	assert.Len(t, te.Logs, 1)
+	assert.Equal(t, "task-2", te.Logs[0].Step)
+	assert.Equal(t, "file-001", te.Logs[0].Agent)
+	assert.Equal(t, LogLevelWarn, te.Logs[0].Level)
+}
+
+func TestTaskExecution_Validate(t *testing.T) {
+	assert.Error(t, (&TaskExecution{Status: TaskStatusPending}).Validate())
+	assert.Error(t, (&TaskExecution{ID: "task-1"}).Validate())
+}
+
+func TestTaskExecution_Resources(t *testing.T) {
+	te := NewTaskExecution("goal")
+	te.Results = []captain.Result{
+		{TaskID: "task-1", Resources: &agents.ResourceUsage{WallTime: time.Second, CPUTime: time.Second, PeakMemory: 2048}},
+		{TaskID: "task-2"},
+		{TaskID: "task-3", Resources: &agents.ResourceUsage{WallTime: time.Second, BytesDownloaded: 100, FilesWritten: 2}},
+	}
+
+	assert.Equal(t, agents.ResourceUsage{
+		WallTime:        2 * time.Second,
+		CPUTime:         time.Second,
+		PeakMemory:      2048,
+		BytesDownloaded: 100,
+		FilesWritten:    2,
+	}, te.Resources(), "steps without usage are skipped")
+}
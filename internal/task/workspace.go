@@ -0,0 +1,46 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DetectWorkspace returns the workspace for dir: the top-level directory of the project
+// containing it, found as the nearest directory with a .git entry, or dir itself when it
+// is not inside a repository
+func DetectWorkspace(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace directory: %w", err)
+	}
+	for current := abs; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs, nil
+		}
+		current = parent
+	}
+}
+
+// WorkspaceName returns a short name for a workspace: the base name of a project
+// directory, or the workspace itself when it was named explicitly
+func WorkspaceName(workspace string) string {
+	if filepath.IsAbs(workspace) {
+		return filepath.Base(workspace)
+	}
+	return workspace
+}
+
+// InWorkspace reports whether the task belongs to the workspace. Tasks recorded without
+// a workspace belong to every one, and an empty workspace includes every task.
+func (t *TaskExecution) InWorkspace(workspace string) bool {
+	return inWorkspace(t.Workspace, workspace)
+}
+
+func inWorkspace(have, want string) bool {
+	return want == "" || have == "" || have == want
+}
@@ -0,0 +1,45 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectWorkspace(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "internal", "pkg")
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(project, ".git"), 0o755))
+
+	workspace, err := DetectWorkspace(nested)
+	require.NoError(t, err)
+	assert.Equal(t, project, workspace, "the repository's top-level directory is the workspace")
+
+	// A worktree or submodule has a .git file rather than a directory
+	worktree := filepath.Join(root, "worktree")
+	require.NoError(t, os.Mkdir(worktree, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: ../project/.git\n"), 0o644))
+	workspace, err = DetectWorkspace(worktree)
+	require.NoError(t, err)
+	assert.Equal(t, worktree, workspace)
+}
+
+func TestWorkspaceName(t *testing.T) {
+	assert.Equal(t, "project", WorkspaceName(filepath.Join(t.TempDir(), "project")))
+	assert.Equal(t, "infra", WorkspaceName("infra"))
+}
+
+func TestTaskExecution_InWorkspace(t *testing.T) {
+	te := NewTaskExecution("goal")
+	assert.True(t, te.InWorkspace("alpha"), "tasks without a workspace belong to every one")
+
+	te.Workspace = "alpha"
+	assert.True(t, te.InWorkspace("alpha"))
+	assert.True(t, te.InWorkspace(""))
+	assert.False(t, te.InWorkspace("beta"))
+}
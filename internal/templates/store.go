@@ -0,0 +1,178 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Store keeps versioned templates on disk as <dir>/<name>/v<version>.yaml
+type Store struct {
+	dir string
+}
+
+// NewStore creates a template store rooted at dir
+func NewStore(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("template directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create template directory %s: %w", dir, err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// LoadFile reads and validates a template definition from a YAML file
+func LoadFile(filename string) (*Template, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file %s: %w", filename, err)
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse template file %s: %w", filename, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &t, nil
+}
+
+// Add stores the template as a new version of its name and returns the stored copy.
+// Adding a definition identical to the latest version does not create a new version.
+func (s *Store) Add(t *Template) (*Template, error) {
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	versions, err := s.Versions(t.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *t
+	if len(versions) > 0 {
+		latest, err := s.Get(t.Name, versions[len(versions)-1])
+		if err != nil {
+			return nil, err
+		}
+		if sameDefinition(latest, &stored) {
+			return latest, nil
+		}
+		stored.Version = latest.Version + 1
+	} else {
+		stored.Version = 1
+	}
+	stored.CreatedAt = time.Now()
+
+	data, err := yaml.Marshal(&stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template %s: %w", t.Name, err)
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, t.Name), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create template directory: %w", err)
+	}
+	if err := os.WriteFile(s.path(t.Name, stored.Version), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write template %s: %w", t.Name, err)
+	}
+	return &stored, nil
+}
+
+// Get returns a specific version of a template; version 0 returns the latest
+func (s *Store) Get(name string, version int) (*Template, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+
+	if version == 0 {
+		versions, err := s.Versions(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("template not found: %s", name)
+		}
+		version = versions[len(versions)-1]
+	}
+
+	data, err := os.ReadFile(s.path(name, version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("template not found: %s@%d", name, version)
+		}
+		return nil, fmt.Errorf("failed to read template %s@%d: %w", name, version, err)
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse template %s@%d: %w", name, version, err)
+	}
+	return &t, nil
+}
+
+// Versions returns the stored versions of a template in ascending order
+func (s *Store) Versions(name string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read template versions for %s: %w", name, err)
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		raw := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), "v"), ".yaml")
+		if version, err := strconv.Atoi(raw); err == nil && !entry.IsDir() {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// List returns the latest version of every stored template, sorted by name
+func (s *Store) List() ([]*Template, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory %s: %w", s.dir, err)
+	}
+
+	var list []*Template
+	for _, entry := range entries {
+		if !entry.IsDir() || !namePattern.MatchString(entry.Name()) {
+			continue
+		}
+		t, err := s.Get(entry.Name(), 0)
+		if err != nil {
+			continue
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// path returns the file path of a template version
+func (s *Store) path(name string, version int) string {
+	return filepath.Join(s.dir, name, fmt.Sprintf("v%d.yaml", version))
+}
+
+// sameDefinition reports whether two templates define the same goal and variables
+func sameDefinition(a, b *Template) bool {
+	if a.Description != b.Description || a.Goal != b.Goal || len(a.Variables) != len(b.Variables) {
+		return false
+	}
+	for i := range a.Variables {
+		if a.Variables[i] != b.Variables[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStore_EmptyDir(t *testing.T) {
+	_, err := NewStore("")
+	assert.Error(t, err)
+}
+
+func TestStore_AddVersions(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	first, err := store.Add(deployTemplate())
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Version)
+	assert.False(t, first.CreatedAt.IsZero())
+
+	// Re-adding an unchanged definition keeps the same version
+	same, err := store.Add(deployTemplate())
+	require.NoError(t, err)
+	assert.Equal(t, 1, same.Version)
+
+	changed := deployTemplate()
+	changed.Goal = "Deploy ${service} to ${env} and run smoke tests"
+	second, err := store.Add(changed)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.Version)
+
+	versions, err := store.Versions("deploy")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions)
+
+	latest, err := store.Get("deploy", 0)
+	require.NoError(t, err)
+	assert.Equal(t, changed.Goal, latest.Goal)
+
+	old, err := store.Get("deploy", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Deploy ${service} to ${env}", old.Goal)
+}
+
+func TestStore_AddInvalid(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Add(&Template{Name: "broken"})
+	assert.Error(t, err)
+}
+
+func TestStore_GetNotFound(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Get("missing", 0)
+	assert.EqualError(t, err, "template not found: missing")
+
+	_, err = store.Add(deployTemplate())
+	require.NoError(t, err)
+	_, err = store.Get("deploy", 7)
+	assert.EqualError(t, err, "template not found: deploy@7")
+}
+
+func TestStore_List(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	list, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	_, err = store.Add(deployTemplate())
+	require.NoError(t, err)
+	_, err = store.Add(&Template{Name: "audit", Goal: "Audit dependencies"})
+	require.NoError(t, err)
+
+	list, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "audit", list[0].Name)
+	assert.Equal(t, "deploy", list[1].Name)
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "deploy.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte(`name: deploy
+goal: Deploy ${service} to ${env}
+variables:
+  - name: env
+    required: true
+  - name: service
+    default: api
+`), 0o644))
+
+	tmpl, err := LoadFile(valid)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy", tmpl.Name)
+	assert.Len(t, tmpl.Variables, 2)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("name: bad\ngoal: Use ${missing}\n"), 0o644))
+	_, err = LoadFile(invalid)
+	assert.ErrorContains(t, err, "undeclared variable")
+
+	_, err = LoadFile(filepath.Join(dir, "nope.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+var (
+	// namePattern restricts template names to values that are safe as directory names
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	// varPattern matches ${name} placeholders in a template goal
+	varPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+	// varNamePattern restricts variable names
+	varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+// Variable describes a parameter of a goal template
+type Variable struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Default     string `yaml:"default,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+}
+
+// Template is a reusable, parameterized goal
+type Template struct {
+	Name        string     `yaml:"name"`
+	Version     int        `yaml:"version,omitempty"`
+	Description string     `yaml:"description,omitempty"`
+	Goal        string     `yaml:"goal"`
+	Variables   []Variable `yaml:"variables,omitempty"`
+	CreatedAt   time.Time  `yaml:"created_at,omitempty"`
+}
+
+// Validate validates the template definition and that its goal only references declared variables
+func (t *Template) Validate() error {
+	validator := common.NewValidator()
+	validator.AddRule("name", common.Required("template name"))
+	validator.AddRule("goal", common.Required("template goal"))
+
+	err := validator.Validate(map[string]interface{}{
+		"name": t.Name,
+		"goal": t.Goal,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid template name %q: use lowercase letters, digits, '-' and '_'", t.Name)
+	}
+
+	declared := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		if !varNamePattern.MatchString(v.Name) {
+			return fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if declared[v.Name] {
+			return fmt.Errorf("duplicate variable: %s", v.Name)
+		}
+		if v.Required && v.Default != "" {
+			return fmt.Errorf("variable %s cannot be required and have a default", v.Name)
+		}
+		declared[v.Name] = true
+	}
+
+	for _, name := range t.References() {
+		if !declared[name] {
+			return fmt.Errorf("goal references undeclared variable: %s", name)
+		}
+	}
+	return nil
+}
+
+// References returns the variable names used in the goal, in order of first use
+func (t *Template) References() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range varPattern.FindAllStringSubmatch(t.Goal, -1) {
+		if name := match[1]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Render substitutes variables into the goal, applying defaults.
+// Unknown variables and missing required variables are errors.
+func (t *Template) Render(vars map[string]string) (string, error) {
+	values := make(map[string]string, len(t.Variables))
+	var missing []string
+	for _, v := range t.Variables {
+		value, ok := vars[v.Name]
+		switch {
+		case ok:
+			values[v.Name] = value
+		case v.Required:
+			missing = append(missing, v.Name)
+		default:
+			values[v.Name] = v.Default
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing required variables for template %s: %s", t.Name, strings.Join(missing, ", "))
+	}
+
+	var unknown []string
+	for name := range vars {
+		if _, ok := values[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("unknown variables for template %s: %s", t.Name, strings.Join(unknown, ", "))
+	}
+
+	return varPattern.ReplaceAllStringFunc(t.Goal, func(placeholder string) string {
+		return values[placeholder[2:len(placeholder)-1]]
+	}), nil
+}
+
+// ParseVars parses "key=value" pairs as given on the command line
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q: expected key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// ParseRef splits a "name" or "name@version" template reference; version 0 means latest
+func ParseRef(ref string) (string, int, error) {
+	name, rawVersion, hasVersion := strings.Cut(ref, "@")
+	if !hasVersion {
+		return name, 0, nil
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(rawVersion, "v"), "%d", &version); err != nil || version <= 0 {
+		return "", 0, fmt.Errorf("invalid template version in %q", ref)
+	}
+	return name, version, nil
+}
@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deployTemplate() *Template {
+	return &Template{
+		Name: "deploy",
+		Goal: "Deploy ${service} to ${env}",
+		Variables: []Variable{
+			{Name: "env", Required: true},
+			{Name: "service", Default: "api"},
+		},
+	}
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Template)
+		wantErr string
+	}{
+		{name: "valid", modify: func(*Template) {}},
+		{name: "missing name", modify: func(t *Template) { t.Name = "" }, wantErr: "template name"},
+		{name: "missing goal", modify: func(t *Template) { t.Goal = "" }, wantErr: "template goal"},
+		{name: "invalid name", modify: func(t *Template) { t.Name = "../etc" }, wantErr: "invalid template name"},
+		{name: "undeclared variable", modify: func(t *Template) { t.Goal = "Deploy ${app}" }, wantErr: "undeclared variable: app"},
+		{name: "duplicate variable", modify: func(t *Template) { t.Variables = append(t.Variables, Variable{Name: "env"}) }, wantErr: "duplicate variable"},
+		{name: "required with default", modify: func(t *Template) { t.Variables[0].Default = "prod" }, wantErr: "cannot be required"},
+		{name: "invalid variable name", modify: func(t *Template) { t.Variables[1].Name = "1st" }, wantErr: "invalid variable name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := deployTemplate()
+			tt.modify(tmpl)
+			err := tmpl.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "defaults applied", vars: map[string]string{"env": "staging"}, want: "Deploy api to staging"},
+		{name: "override default", vars: map[string]string{"env": "prod", "service": "web"}, want: "Deploy web to prod"},
+		{name: "missing required", vars: map[string]string{}, wantErr: "missing required variables for template deploy: env"},
+		{name: "unknown variable", vars: map[string]string{"env": "prod", "region": "eu"}, wantErr: "unknown variables for template deploy: region"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deployTemplate().Render(tt.vars)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"env=staging", "query=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "staging", "query": "a=b", "empty": ""}, vars)
+
+	_, err = ParseVars([]string{"novalue"})
+	assert.Error(t, err)
+	_, err = ParseVars([]string{"=value"})
+	assert.Error(t, err)
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref         string
+		wantName    string
+		wantVersion int
+		wantErr     bool
+	}{
+		{ref: "deploy", wantName: "deploy"},
+		{ref: "deploy@2", wantName: "deploy", wantVersion: 2},
+		{ref: "deploy@v3", wantName: "deploy", wantVersion: 3},
+		{ref: "deploy@0", wantErr: true},
+		{ref: "deploy@latest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			name, version, err := ParseRef(tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantVersion, version)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package testutil
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+)
+
+// FakeClock is a common.Clock whose time only moves when Advance is called, firing the timers
+// and tickers that fall due. Tests use it to run polling and timeout code without sleeping.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After or an active ticker
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+// NewFakeClock returns a fake clock that reads start until it is advanced
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the fake time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns how much fake time has passed since t
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// After returns a channel that receives the fake time once the clock is advanced by d
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.add(d, 0)
+}
+
+// NewTicker returns a ticker that ticks each time the clock is advanced past another period
+func (c *FakeClock) NewTicker(d time.Duration) common.Ticker {
+	if d <= 0 {
+		panic("testutil: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{clock: c, ch: c.add(d, d)}
+}
+
+func (c *FakeClock) add(d, period time.Duration) chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- c.now
+		return w.ch
+	}
+	c.waiters = append(c.waiters, w)
+	c.changed.Broadcast()
+	return w.ch
+}
+
+// Advance moves the clock forward by d, firing due timers and tickers in the order they fall
+// due. Like a real ticker, a ticker whose last tick has not been read drops the next.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+	}
+	c.now = end
+	c.changed.Broadcast()
+}
+
+// BlockUntil waits until at least n timers and tickers are waiting on the clock, so a test can
+// advance it knowing the code under test has started to wait
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
+
+func (c *FakeClock) remove(ch chan time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.waiters {
+		if w.ch == ch {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			break
+		}
+	}
+	c.changed.Broadcast()
+}
+
+type fakeTicker struct {
+	clock *FakeClock
+	ch    chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+func (t *fakeTicker) Stop()               { t.clock.remove(t.ch) }
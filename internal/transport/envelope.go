@@ -0,0 +1,278 @@
+// Package transport secures agent messages exchanged with remote crew workers. Each
+// message travels in an Envelope signed, and optionally encrypted, with a key shared by
+// the captain and that worker; Open rejects tampered, stale and replayed envelopes, and
+// envelopes its own side sealed reflected back at it.
+// Connections themselves use mutual TLS configured by ServerTLSConfig and ClientTLSConfig.
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// EnvelopeVersion is the envelope format produced by Seal
+const EnvelopeVersion = 2
+
+// DefaultMaxSkew is how far an envelope's timestamp may be from the local clock
+const DefaultMaxSkew = 5 * time.Minute
+
+// nonceSize is the length of envelope nonces, which double as AES-GCM nonces
+const nonceSize = 12
+
+var (
+	// ErrUnknownWorker is returned for envelopes naming a worker without a key
+	ErrUnknownWorker = errors.New("unknown worker")
+	// ErrBadSignature is returned for envelopes whose signature does not verify
+	ErrBadSignature = errors.New("invalid signature")
+	// ErrStale is returned for envelopes whose timestamp is outside the allowed skew
+	ErrStale = errors.New("envelope timestamp outside allowed skew")
+	// ErrReplay is returned for envelopes that have already been opened
+	ErrReplay = errors.New("envelope replayed")
+	// ErrWrongDirection is returned for envelopes sealed by the side opening them
+	ErrWrongDirection = errors.New("envelope not addressed to this side")
+)
+
+// Side is the end of a captain-worker link a Sealer works for
+type Side string
+
+const (
+	// SideCaptain seals envelopes for workers and opens theirs
+	SideCaptain Side = "captain"
+	// SideWorker seals envelopes for the captain and opens the captain's
+	SideWorker Side = "worker"
+)
+
+// peer returns the other end of the link
+func (s Side) peer() Side {
+	if s == SideCaptain {
+		return SideWorker
+	}
+	return SideCaptain
+}
+
+// Envelope carries one agent message between the captain and a worker. Sender names the
+// side that sealed it, which is signed with the other fields so only the other side opens it.
+type Envelope struct {
+	Version   int    `json:"v"`
+	Worker    string `json:"worker"`
+	Sender    Side   `json:"sender"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Nonce     []byte `json:"nonce"`
+	Timestamp int64  `json:"ts"`
+	Encrypted bool   `json:"enc,omitempty"`
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"sig"`
+}
+
+// workerKeys are the keys derived from a worker's configured key for envelopes sent one
+// way along the link
+type workerKeys struct {
+	sign    []byte
+	encrypt cipher.AEAD
+}
+
+// linkKeys are the keys for both directions of a worker's link: envelopes this side seals
+// and envelopes it opens are signed with different keys, so neither passes for the other
+type linkKeys struct {
+	send, receive workerKeys
+}
+
+// Sealer seals and opens the envelopes exchanged with the configured workers. It is safe
+// for concurrent use.
+type Sealer struct {
+	side    Side
+	keys    map[string]linkKeys
+	encrypt bool
+	maxSkew time.Duration
+	replays *ReplayGuard
+	clock   common.Clock
+}
+
+// NewSealer creates a sealer for side of the links to the workers in the transport
+// configuration
+func NewSealer(cfg config.TransportConfig, side Side) (*Sealer, error) {
+	if side != SideCaptain && side != SideWorker {
+		return nil, fmt.Errorf("unknown transport side %q", side)
+	}
+	maxSkew := cfg.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	s := &Sealer{
+		side:    side,
+		keys:    make(map[string]linkKeys, len(cfg.Workers)),
+		encrypt: cfg.Encrypt,
+		maxSkew: maxSkew,
+		replays: NewReplayGuard(),
+		clock:   common.SystemClock,
+	}
+	for _, worker := range cfg.Workers {
+		if worker.Key == "" {
+			return nil, fmt.Errorf("no key for worker %s: set it in the config or store %s", worker.ID, worker.SecretKey())
+		}
+		key, err := config.DecodeTransportKey(worker.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for worker %s: %w", worker.ID, err)
+		}
+		send, err := deriveKeys(key, side)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for worker %s: %w", worker.ID, err)
+		}
+		receive, err := deriveKeys(key, side.peer())
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for worker %s: %w", worker.ID, err)
+		}
+		s.keys[worker.ID] = linkKeys{send: send, receive: receive}
+	}
+	return s, nil
+}
+
+// SetClock sets the clock envelopes are timestamped and checked for staleness against
+func (s *Sealer) SetClock(clock common.Clock) {
+	s.clock = clock
+}
+
+// deriveKeys derives separate signing and encryption keys from a worker key for the
+// envelopes sender seals
+func deriveKeys(key []byte, sender Side) (workerKeys, error) {
+	derive := func(purpose string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("capn transport " + string(sender) + " " + purpose))
+		return mac.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("encrypt"))
+	if err != nil {
+		return workerKeys{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return workerKeys{}, err
+	}
+	return workerKeys{sign: derive("sign"), encrypt: aead}, nil
+}
+
+// Seal wraps a message exchanged with the worker in a signed envelope, encrypting the
+// message when the transport is configured to
+func (s *Sealer) Seal(worker string, message agents.Message) (*Envelope, error) {
+	link, ok := s.keys[worker]
+	if !ok {
+		return nil, fmt.Errorf("failed to seal message for %s: %w", worker, ErrUnknownWorker)
+	}
+	keys := link.send
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	env := &Envelope{
+		Version:   EnvelopeVersion,
+		Worker:    worker,
+		Sender:    s.side,
+		From:      message.From,
+		To:        message.To,
+		Nonce:     make([]byte, nonceSize),
+		Timestamp: s.clock.Now().UnixNano(),
+		Encrypted: s.encrypt,
+	}
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	if env.Encrypted {
+		payload = keys.encrypt.Seal(nil, env.Nonce, payload, env.header())
+	}
+	env.Payload = payload
+	env.Signature = env.sign(keys.sign)
+	return env, nil
+}
+
+// Open verifies an envelope sealed by the other side and returns its message. Each
+// envelope opens once; a copy arriving later is rejected with ErrReplay, and one this side
+// sealed itself with ErrWrongDirection.
+func (s *Sealer) Open(env *Envelope) (agents.Message, error) {
+	if env.Version != EnvelopeVersion {
+		return agents.Message{}, fmt.Errorf("unsupported envelope version %d", env.Version)
+	}
+	link, ok := s.keys[env.Worker]
+	if !ok {
+		return agents.Message{}, fmt.Errorf("failed to open message from %s: %w", env.Worker, ErrUnknownWorker)
+	}
+	if env.Sender != s.side.peer() {
+		return agents.Message{}, fmt.Errorf("envelope from the %s side opened by the %s: %w", env.Sender, s.side, ErrWrongDirection)
+	}
+	keys := link.receive
+	if !hmac.Equal(env.Signature, env.sign(keys.sign)) {
+		return agents.Message{}, ErrBadSignature
+	}
+	if s.encrypt && !env.Encrypted {
+		return agents.Message{}, fmt.Errorf("unencrypted envelope from %s rejected", env.Worker)
+	}
+
+	now := s.clock.Now()
+	sent := time.Unix(0, env.Timestamp)
+	if sent.Before(now.Add(-s.maxSkew)) || sent.After(now.Add(s.maxSkew)) {
+		return agents.Message{}, ErrStale
+	}
+	if !s.replays.Check(env.Worker+":"+string(env.Nonce), sent.Add(s.maxSkew), now) {
+		return agents.Message{}, ErrReplay
+	}
+
+	payload := env.Payload
+	if env.Encrypted {
+		// AEAD.Open panics on a nonce of the wrong length, which a signed envelope may still carry
+		if len(env.Nonce) != keys.encrypt.NonceSize() {
+			return agents.Message{}, fmt.Errorf("invalid envelope nonce length %d", len(env.Nonce))
+		}
+		var err error
+		payload, err = keys.encrypt.Open(nil, env.Nonce, env.Payload, env.header())
+		if err != nil {
+			return agents.Message{}, fmt.Errorf("failed to decrypt message: %w", err)
+		}
+	}
+	var message agents.Message
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return agents.Message{}, fmt.Errorf("failed to decode message: %w", err)
+	}
+	if message.From != env.From || message.To != env.To {
+		return agents.Message{}, fmt.Errorf("message addressing does not match its envelope")
+	}
+	return message, nil
+}
+
+// header encodes the envelope fields other than the payload and signature, each
+// length-prefixed so no two envelopes share an encoding
+func (e *Envelope) header() []byte {
+	var buf []byte
+	buf = binary.BigEndian.AppendUint32(buf, uint32(e.Version))
+	for _, field := range [][]byte{[]byte(e.Worker), []byte(e.Sender), []byte(e.From), []byte(e.To), e.Nonce} {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+		buf = append(buf, field...)
+	}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(e.Timestamp))
+	if e.Encrypted {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// sign computes the envelope signature over its header and payload
+func (e *Envelope) sign(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(e.header())
+	mac.Write(e.Payload)
+	return mac.Sum(nil)
+}
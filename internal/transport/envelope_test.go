@@ -0,0 +1,202 @@
+package transport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/config"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+var (
+	workerKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", config.TransportKeySize)))
+	otherKey  = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", config.TransportKeySize)))
+)
+
+func newTestSealer(t *testing.T, side Side, encrypt bool, key string) *Sealer {
+	t.Helper()
+	sealer, err := NewSealer(config.TransportConfig{
+		Encrypt: encrypt,
+		Workers: []config.WorkerKeyConfig{{ID: "worker-1", Key: key}},
+	}, side)
+	require.NoError(t, err)
+	return sealer
+}
+
+func testMessage() agents.Message {
+	return agents.Message{
+		ID:      "msg-1",
+		From:    "captain",
+		To:      "file-1",
+		Content: "rm -rf build",
+		Type:    agents.MessageTypeCommand,
+	}
+}
+
+func TestSealer_RoundTrip(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		captain := newTestSealer(t, SideCaptain, encrypt, workerKey)
+		worker := newTestSealer(t, SideWorker, encrypt, workerKey)
+
+		env, err := captain.Seal("worker-1", testMessage())
+		require.NoError(t, err)
+		assert.Equal(t, encrypt, env.Encrypted)
+		assert.Equal(t, encrypt, !strings.Contains(string(env.Payload), "rm -rf build"))
+
+		// Envelopes survive the wire encoding
+		data, err := json.Marshal(env)
+		require.NoError(t, err)
+		var received Envelope
+		require.NoError(t, json.Unmarshal(data, &received))
+
+		message, err := worker.Open(&received)
+		require.NoError(t, err)
+		assert.Equal(t, testMessage(), message)
+	}
+}
+
+func TestSealer_Tampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(*Envelope)
+	}{
+		{"payload", func(e *Envelope) { e.Payload = []byte(strings.Replace(string(e.Payload), "build", "/", 1)) }},
+		{"recipient", func(e *Envelope) { e.To = "network-1" }},
+		{"timestamp", func(e *Envelope) { e.Timestamp++ }},
+		{"nonce", func(e *Envelope) { e.Nonce[0] ^= 1 }},
+		{"signature", func(e *Envelope) { e.Signature[0] ^= 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captain := newTestSealer(t, SideCaptain, false, workerKey)
+			worker := newTestSealer(t, SideWorker, false, workerKey)
+
+			env, err := captain.Seal("worker-1", testMessage())
+			require.NoError(t, err)
+			tt.tamper(env)
+
+			_, err = worker.Open(env)
+			assert.ErrorIs(t, err, ErrBadSignature)
+		})
+	}
+}
+
+func TestSealer_WrongKey(t *testing.T) {
+	env, err := newTestSealer(t, SideCaptain, true, workerKey).Seal("worker-1", testMessage())
+	require.NoError(t, err)
+
+	_, err = newTestSealer(t, SideWorker, true, otherKey).Open(env)
+	assert.ErrorIs(t, err, ErrBadSignature)
+}
+
+func TestSealer_NonceLength(t *testing.T) {
+	captain := newTestSealer(t, SideCaptain, true, workerKey)
+	worker := newTestSealer(t, SideWorker, true, workerKey)
+
+	// A correctly signed envelope with a short nonce is refused rather than panicking
+	env, err := captain.Seal("worker-1", testMessage())
+	require.NoError(t, err)
+	env.Nonce = env.Nonce[:4]
+	env.Signature = env.sign(captain.keys["worker-1"].send.sign)
+
+	_, err = worker.Open(env)
+	assert.EqualError(t, err, "invalid envelope nonce length 4")
+}
+
+func TestSealer_Reflection(t *testing.T) {
+	captain := newTestSealer(t, SideCaptain, false, workerKey)
+	worker := newTestSealer(t, SideWorker, false, workerKey)
+
+	// An envelope bounced back to the side that sealed it is refused
+	env, err := captain.Seal("worker-1", testMessage())
+	require.NoError(t, err)
+	_, err = captain.Open(env)
+	assert.ErrorIs(t, err, ErrWrongDirection)
+
+	// Relabelling its sender breaks the signature, which depends on the direction
+	env.Sender = SideWorker
+	_, err = captain.Open(env)
+	assert.ErrorIs(t, err, ErrBadSignature)
+
+	// Replies flow the other way
+	env, err = worker.Seal("worker-1", agents.Message{ID: "msg-2", From: "file-1", To: "captain", Type: agents.MessageTypeResult})
+	require.NoError(t, err)
+	_, err = worker.Open(env)
+	assert.ErrorIs(t, err, ErrWrongDirection)
+	message, err := captain.Open(env)
+	require.NoError(t, err)
+	assert.Equal(t, "msg-2", message.ID)
+}
+
+func TestSealer_Replay(t *testing.T) {
+	captain := newTestSealer(t, SideCaptain, false, workerKey)
+	worker := newTestSealer(t, SideWorker, false, workerKey)
+
+	env, err := captain.Seal("worker-1", testMessage())
+	require.NoError(t, err)
+	_, err = worker.Open(env)
+	require.NoError(t, err)
+
+	_, err = worker.Open(env)
+	assert.ErrorIs(t, err, ErrReplay)
+}
+
+func TestSealer_Stale(t *testing.T) {
+	captain := newTestSealer(t, SideCaptain, false, workerKey)
+	worker := newTestSealer(t, SideWorker, false, workerKey)
+	now := time.Now()
+	captain.SetClock(testutil.NewFakeClock(now.Add(-DefaultMaxSkew - time.Second)))
+
+	env, err := captain.Seal("worker-1", testMessage())
+	require.NoError(t, err)
+	_, err = worker.Open(env)
+	assert.ErrorIs(t, err, ErrStale)
+
+	captain.SetClock(testutil.NewFakeClock(now.Add(DefaultMaxSkew + time.Second)))
+	env, err = captain.Seal("worker-1", testMessage())
+	require.NoError(t, err)
+	_, err = worker.Open(env)
+	assert.ErrorIs(t, err, ErrStale)
+}
+
+func TestSealer_RequiresEncryption(t *testing.T) {
+	env, err := newTestSealer(t, SideCaptain, false, workerKey).Seal("worker-1", testMessage())
+	require.NoError(t, err)
+
+	_, err = newTestSealer(t, SideWorker, true, workerKey).Open(env)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unencrypted envelope")
+}
+
+func TestSealer_UnknownWorker(t *testing.T) {
+	sealer := newTestSealer(t, SideCaptain, false, workerKey)
+
+	_, err := sealer.Seal("worker-2", testMessage())
+	assert.ErrorIs(t, err, ErrUnknownWorker)
+
+	env, err := sealer.Seal("worker-1", testMessage())
+	require.NoError(t, err)
+	env.Worker = "worker-2"
+	_, err = sealer.Open(env)
+	assert.ErrorIs(t, err, ErrUnknownWorker)
+}
+
+func TestNewSealer_Keys(t *testing.T) {
+	_, err := NewSealer(config.TransportConfig{Workers: []config.WorkerKeyConfig{{ID: "worker-1"}}}, SideCaptain)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "transport.workers.worker-1.key")
+
+	_, err = NewSealer(config.TransportConfig{Workers: []config.WorkerKeyConfig{{ID: "worker-1", Key: "c2hvcnQ="}}}, SideCaptain)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "key must be 32 bytes")
+
+	_, err = NewSealer(config.TransportConfig{}, "crew")
+	assert.EqualError(t, err, `unknown transport side "crew"`)
+}
@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// NewHTTPClient returns an HTTP client using the configured proxies and certificate
+// authorities. Without HTTP settings it returns http.DefaultClient.
+func NewHTTPClient(cfg config.HTTPConfig) (*http.Client, error) {
+	if cfg.IsZero() {
+		return http.DefaultClient, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.HasProxy() {
+		transport.Proxy = proxyFunc(cfg)
+	}
+	if cfg.CABundle != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+		if cfg.CABundle != "" {
+			pool, err := loadCABundle(cfg.CABundle)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// proxyFunc chooses the configured proxy for a request's scheme, or none for hosts listed in
+// no_proxy. HTTPS requests use the HTTP proxy when no HTTPS proxy is configured.
+func proxyFunc(cfg config.HTTPConfig) func(*http.Request) (*url.URL, error) {
+	httpsProxy := cfg.HTTPSProxy
+	if httpsProxy == "" {
+		httpsProxy = cfg.HTTPProxy
+	}
+	proxies := make(map[string]*url.URL)
+	for scheme, proxy := range map[string]string{"http": cfg.HTTPProxy, "https": httpsProxy} {
+		if u, err := url.Parse(proxy); err == nil && proxy != "" {
+			proxies[scheme] = u
+		}
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), cfg.NoProxy) {
+			return nil, nil
+		}
+		return proxies[req.URL.Scheme], nil
+	}
+}
+
+// bypassProxy reports whether host is covered by a no_proxy entry: "*", the host itself or a
+// domain it belongs to. Entries may carry a leading dot or a port, which are ignored.
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCABundle returns the system's certificate authorities together with those in a PEM file
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s: %w", path, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+func TestNewHTTPClient_Default(t *testing.T) {
+	client, err := NewHTTPClient(config.HTTPConfig{})
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultClient, client)
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the request
+		proxied = append(proxied, r.URL.String())
+		_, _ = io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(config.HTTPConfig{HTTPProxy: proxy.URL, NoProxy: []string{".direct.invalid"}})
+	require.NoError(t, err)
+
+	resp, err := client.Get("http://api.example.invalid/v1/models")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "via proxy", string(body))
+	assert.Equal(t, []string{"http://api.example.invalid/v1/models"}, proxied)
+
+	// Hosts listed in no_proxy are dialled directly, so this one cannot be resolved
+	_, err = client.Get("http://api.direct.invalid/")
+	assert.Error(t, err)
+	assert.Len(t, proxied, 1)
+}
+
+func TestProxyFunc(t *testing.T) {
+	proxyFor := func(cfg config.HTTPConfig, target string) string {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		u, err := proxyFunc(cfg)(req)
+		require.NoError(t, err)
+		if u == nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	both := config.HTTPConfig{HTTPProxy: "http://plain:3128", HTTPSProxy: "http://secure:3128", NoProxy: []string{"localhost", "corp.example:443"}}
+	assert.Equal(t, "http://plain:3128", proxyFor(both, "http://api.openai.com/v1"))
+	assert.Equal(t, "http://secure:3128", proxyFor(both, "https://api.openai.com/v1"))
+	assert.Equal(t, "", proxyFor(both, "http://localhost:11434/api/tags"))
+	assert.Equal(t, "", proxyFor(both, "https://git.corp.example/"))
+
+	httpOnly := config.HTTPConfig{HTTPProxy: "http://plain:3128"}
+	assert.Equal(t, "http://plain:3128", proxyFor(httpOnly, "https://api.anthropic.com"))
+
+	everything := config.HTTPConfig{HTTPProxy: "http://plain:3128", NoProxy: []string{"*"}}
+	assert.Equal(t, "", proxyFor(everything, "https://api.anthropic.com"))
+}
+
+func TestNewHTTPClient_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "trusted")
+	}))
+	defer server.Close()
+
+	// Without the server's CA the certificate is rejected
+	_, err := http.Get(server.URL)
+	require.Error(t, err)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	client, err := NewHTTPClient(config.HTTPConfig{CABundle: bundle})
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "trusted", string(body))
+
+	insecure, err := NewHTTPClient(config.HTTPConfig{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	resp, err = insecure.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestNewHTTPClient_Errors(t *testing.T) {
+	_, err := NewHTTPClient(config.HTTPConfig{HTTPProxy: "proxy.corp:3128"})
+	assert.ErrorContains(t, err, "http_proxy must be a URL")
+
+	_, err = NewHTTPClient(config.HTTPConfig{CABundle: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read CA bundle")
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0600))
+	_, err = NewHTTPClient(config.HTTPConfig{CABundle: notPEM})
+	assert.ErrorContains(t, err, "no certificates found in CA bundle")
+}
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayGuard remembers the envelopes already opened until they would be rejected as
+// stale anyway, so its memory is bounded by the message rate and the allowed skew
+type ReplayGuard struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// pruneInterval is how often expired entries are dropped
+const pruneInterval = time.Second
+
+// NewReplayGuard creates an empty replay guard
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{seen: make(map[string]time.Time)}
+}
+
+// Check records id as seen until expires and reports whether it was new
+func (g *ReplayGuard) Check(id string, expires, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.pruned) >= pruneInterval {
+		for seenID, expiry := range g.seen {
+			if !expiry.After(now) {
+				delete(g.seen, seenID)
+			}
+		}
+		g.pruned = now
+	}
+	if expiry, seen := g.seen[id]; seen && expiry.After(now) {
+		return false
+	}
+	g.seen[id] = expires
+	return true
+}
+
+// Len returns the number of envelopes remembered
+func (g *ReplayGuard) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.seen)
+}
@@ -0,0 +1,23 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayGuard_Check(t *testing.T) {
+	guard := NewReplayGuard()
+	now := time.Now()
+
+	assert.True(t, guard.Check("a", now.Add(time.Minute), now))
+	assert.False(t, guard.Check("a", now.Add(time.Minute), now))
+	assert.True(t, guard.Check("b", now.Add(time.Minute), now))
+	assert.Equal(t, 2, guard.Len())
+
+	// Expired entries are forgotten
+	later := now.Add(2 * time.Minute)
+	assert.True(t, guard.Check("c", later.Add(time.Minute), later))
+	assert.Equal(t, 1, guard.Len())
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// ServerTLSConfig returns the TLS configuration for the captain's side of the transport,
+// which only accepts workers presenting a certificate signed by the configured CA
+func ServerTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	cert, pool, err := loadTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// ClientTLSConfig returns the TLS configuration for a worker's side of the transport,
+// which presents the worker's certificate and verifies the captain's against the CA
+func ClientTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	cert, pool, err := loadTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   cfg.ServerName,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// loadTLS reads the certificate, key and CA named in the configuration
+func loadTLS(cfg config.TLSConfig) (tls.Certificate, *x509.CertPool, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.CAFile == "" {
+		return tls.Certificate{}, nil, fmt.Errorf("mutual TLS requires cert_file, key_file and ca_file")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to read CA file %s: %w", cfg.CAFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return tls.Certificate{}, nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+	}
+	return cert, pool, nil
+}
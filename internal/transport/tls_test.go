@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/config"
+)
+
+// testPKI writes a CA and certificates signed by it for the captain and a worker
+type testPKI struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{dir: t.TempDir()}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "capn test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	p.ca, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	p.caKey = key
+	p.serial = 1
+	p.write(t, "ca.pem", "CERTIFICATE", der)
+	return p
+}
+
+func (p *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(p.dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// issue creates a certificate for name and returns a TLS configuration using it
+func (p *testPKI) issue(t *testing.T, name string, usage x509.ExtKeyUsage) config.TLSConfig {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return config.TLSConfig{
+		CertFile:   p.write(t, name+".pem", "CERTIFICATE", der),
+		KeyFile:    p.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDER),
+		CAFile:     filepath.Join(p.dir, "ca.pem"),
+		ServerName: "captain",
+	}
+}
+
+// handshake connects a client to a server using the configurations and returns the
+// error seen by the client
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "ok")
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// TLS 1.3 reports a rejected client certificate on the first read
+	_, err = io.ReadAll(conn)
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCfg, err := ServerTLSConfig(pki.issue(t, "captain", x509.ExtKeyUsageServerAuth))
+	require.NoError(t, err)
+
+	t.Run("worker with certificate", func(t *testing.T) {
+		clientCfg, err := ClientTLSConfig(pki.issue(t, "worker-1", x509.ExtKeyUsageClientAuth))
+		require.NoError(t, err)
+		assert.NoError(t, handshake(t, serverCfg, clientCfg))
+	})
+
+	t.Run("worker without certificate", func(t *testing.T) {
+		clientCfg, err := ClientTLSConfig(pki.issue(t, "worker-2", x509.ExtKeyUsageClientAuth))
+		require.NoError(t, err)
+		clientCfg.Certificates = nil
+		assert.Error(t, handshake(t, serverCfg, clientCfg))
+	})
+
+	t.Run("worker from another CA", func(t *testing.T) {
+		clientCfg, err := ClientTLSConfig(newTestPKI(t).issue(t, "worker-3", x509.ExtKeyUsageClientAuth))
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, serverCfg, clientCfg))
+	})
+}
+
+func TestServerTLSConfig_Errors(t *testing.T) {
+	_, err := ServerTLSConfig(config.TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires cert_file, key_file and ca_file")
+
+	pki := newTestPKI(t)
+	cfg := pki.issue(t, "captain", x509.ExtKeyUsageServerAuth)
+	cfg.CAFile = cfg.KeyFile
+	_, err = ServerTLSConfig(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no certificates found in CA file")
+}
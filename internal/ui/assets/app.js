@@ -0,0 +1,226 @@
+// capn dashboard: polls the daemon API, refreshing early on streamed events, and
+// renders tasks, agents, the communication feed and the selected task's plan as
+// a dependency DAG.
+(function () {
+  "use strict";
+
+  const POLL_INTERVAL_MS = 2000;
+  const NODE_WIDTH = 160;
+  const NODE_HEIGHT = 36;
+  const COLUMN_GAP = 60;
+  const ROW_GAP = 14;
+  const SVG_NS = "http://www.w3.org/2000/svg";
+
+  let selectedTask = null;
+
+  async function fetchJSON(path) {
+    const resp = await fetch(path);
+    if (!resp.ok) {
+      throw new Error(path + ": " + resp.status);
+    }
+    return resp.json();
+  }
+
+  function formatDuration(ns) {
+    const seconds = Math.round(ns / 1e9);
+    if (seconds < 60) {
+      return seconds + "s";
+    }
+    return Math.floor(seconds / 60) + "m" + (seconds % 60) + "s";
+  }
+
+  function el(tag, attrs, text) {
+    const node = document.createElement(tag);
+    Object.entries(attrs || {}).forEach(([k, v]) => node.setAttribute(k, v));
+    if (text !== undefined) {
+      node.textContent = text;
+    }
+    return node;
+  }
+
+  function renderTasks(tasks) {
+    const body = document.querySelector("#tasks tbody");
+    body.replaceChildren();
+    tasks.forEach((t) => {
+      const row = el("tr", t.id === selectedTask ? { class: "selected" } : {});
+      row.appendChild(el("td", {}, t.id));
+      row.appendChild(el("td", {}, t.goal));
+      row.appendChild(el("td", { class: "status status-" + t.status }, t.status));
+      const progressCell = el("td");
+      if (t.total > 0) {
+        progressCell.appendChild(el("progress", { max: t.total, value: t.done }));
+        progressCell.appendChild(document.createTextNode(" " + t.done + "/" + t.total));
+      }
+      row.appendChild(progressCell);
+      row.appendChild(el("td", {}, formatDuration(t.duration)));
+      row.addEventListener("click", () => {
+        selectedTask = t.id;
+        refreshPlan();
+      });
+      body.appendChild(row);
+    });
+  }
+
+  function renderAgents(data) {
+    const stats = data.stats;
+    document.getElementById("agent-stats").textContent =
+      stats.total + " agents (" + stats.busy + " busy, " + stats.idle + " idle, " + stats.error + " error)";
+    const list = document.getElementById("agents");
+    list.replaceChildren();
+    data.agents.forEach((a) => {
+      list.appendChild(el("li", {}, a.id + " [" + a.type + "] " + a.status + " / " + a.health));
+    });
+  }
+
+  function renderFeed(messages) {
+    const feed = document.getElementById("feed");
+    feed.replaceChildren();
+    messages.forEach((m) => feed.appendChild(el("li", {}, m.formatted)));
+    feed.parentElement.scrollTop = feed.parentElement.scrollHeight;
+  }
+
+  // layoutPlan assigns each plan task a column equal to its dependency depth.
+  function layoutPlan(tasks) {
+    const byID = new Map(tasks.map((t) => [t.id, t]));
+    const depth = new Map();
+    const visit = (id, seen) => {
+      if (depth.has(id)) {
+        return depth.get(id);
+      }
+      if (seen.has(id)) {
+        return 0;
+      }
+      seen.add(id);
+      const deps = (byID.get(id) || {}).dependencies || [];
+      const d = deps.length === 0 ? 0 : 1 + Math.max(...deps.map((dep) => visit(dep, seen)));
+      depth.set(id, d);
+      return d;
+    };
+    tasks.forEach((t) => visit(t.id, new Set()));
+
+    const rows = new Map();
+    const positions = new Map();
+    tasks.forEach((t) => {
+      const col = depth.get(t.id);
+      const row = rows.get(col) || 0;
+      rows.set(col, row + 1);
+      positions.set(t.id, {
+        x: col * (NODE_WIDTH + COLUMN_GAP) + 10,
+        y: row * (NODE_HEIGHT + ROW_GAP) + 10,
+      });
+    });
+    return positions;
+  }
+
+  function svg(tag, attrs, text) {
+    const node = document.createElementNS(SVG_NS, tag);
+    Object.entries(attrs || {}).forEach(([k, v]) => node.setAttribute(k, v));
+    if (text !== undefined) {
+      node.textContent = text;
+    }
+    return node;
+  }
+
+  function renderPlan(task) {
+    const dag = document.getElementById("plan-dag");
+    const empty = document.getElementById("plan-empty");
+    dag.replaceChildren();
+    document.getElementById("plan-title").textContent = task ? task.id + ": " + task.goal : "";
+
+    if (!task || !task.plan || !task.plan.tasks || task.plan.tasks.length === 0) {
+      empty.style.display = "";
+      dag.setAttribute("height", 0);
+      return;
+    }
+    empty.style.display = "none";
+
+    const results = new Map((task.results || []).map((r) => [r.task_id, r]));
+    const steps = task.plan.tasks;
+    const positions = layoutPlan(steps);
+
+    const defs = svg("defs");
+    const marker = svg("marker", { id: "arrow", viewBox: "0 0 10 10", refX: 10, refY: 5, markerWidth: 6, markerHeight: 6, orient: "auto" });
+    marker.appendChild(svg("path", { d: "M 0 0 L 10 5 L 0 10 z", fill: "#8c959f" }));
+    defs.appendChild(marker);
+    dag.appendChild(defs);
+
+    let width = 0;
+    let height = 0;
+    steps.forEach((step) => {
+      const to = positions.get(step.id);
+      (step.dependencies || []).forEach((dep) => {
+        const from = positions.get(dep);
+        if (!from) {
+          return;
+        }
+        dag.appendChild(svg("line", {
+          x1: from.x + NODE_WIDTH, y1: from.y + NODE_HEIGHT / 2,
+          x2: to.x, y2: to.y + NODE_HEIGHT / 2,
+        }));
+      });
+    });
+    steps.forEach((step) => {
+      const pos = positions.get(step.id);
+      const result = results.get(step.id);
+      const cls = result ? (result.success ? "done" : "failed") : "";
+      dag.appendChild(svg("rect", { x: pos.x, y: pos.y, width: NODE_WIDTH, height: NODE_HEIGHT, class: cls }));
+      const label = svg("text", { x: pos.x + 6, y: pos.y + 15 }, step.id + " [" + step.type + "]");
+      dag.appendChild(label);
+      const description = ((step.payload || {}).description || "").slice(0, 24);
+      dag.appendChild(svg("text", { x: pos.x + 6, y: pos.y + 29, class: "muted" }, description));
+      width = Math.max(width, pos.x + NODE_WIDTH + 10);
+      height = Math.max(height, pos.y + NODE_HEIGHT + 10);
+    });
+    dag.setAttribute("viewBox", "0 0 " + width + " " + height);
+    dag.setAttribute("height", height);
+  }
+
+  async function refreshPlan() {
+    if (!selectedTask) {
+      renderPlan(null);
+      return;
+    }
+    try {
+      renderPlan(await fetchJSON("api/tasks/" + encodeURIComponent(selectedTask)));
+    } catch (err) {
+      console.error(err);
+    }
+  }
+
+  async function refresh() {
+    try {
+      const [tasks, agents, messages] = await Promise.all([
+        fetchJSON("api/tasks"),
+        fetchJSON("api/agents"),
+        fetchJSON("api/messages"),
+      ]);
+      renderTasks(tasks);
+      renderAgents(agents);
+      renderFeed(messages);
+      await refreshPlan();
+    } catch (err) {
+      console.error(err);
+    }
+  }
+
+  // Refresh as soon as the daemon publishes an event; polling remains as a fallback
+  function subscribe() {
+    if (!window.EventSource) {
+      return;
+    }
+    let pending = null;
+    const source = new EventSource("api/events");
+    source.onmessage = function () {
+      if (pending === null) {
+        pending = setTimeout(function () {
+          pending = null;
+          refresh();
+        }, 100);
+      }
+    };
+  }
+
+  refresh();
+  subscribe();
+  setInterval(refresh, POLL_INTERVAL_MS);
+})();
@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iainlowe/capn/internal/agents"
+)
+
+// metricsContentType is the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter renders metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family starts a metric family with its help text and type
+func (w *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one sample of the current family; labels alternate names and values
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+`="`+labelEscaper.Replace(labels[i+1])+`"`)
+		}
+		w.buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.buf.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+// handleMetrics exports agent statistics for Prometheus. Per-agent averages and error rates
+// are left to queries: divide the busy seconds or failures by the task count.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var m metricsWriter
+	stats := agents.AgentStats{}
+	var managed []agents.Agent
+	if s.manager != nil {
+		stats = s.manager.GetAgentStats()
+		managed = s.manager.GetManagedAgents()
+	}
+	sort.Slice(managed, func(i, j int) bool { return managed[i].ID() < managed[j].ID() })
+
+	m.family("capn_agents", "gauge", "Managed agents by status.")
+	for _, status := range []struct {
+		name  agents.AgentStatus
+		count int
+	}{
+		{agents.AgentStatusIdle, stats.Idle},
+		{agents.AgentStatusBusy, stats.Busy},
+		{agents.AgentStatusStopped, stats.Stopped},
+		{agents.AgentStatusError, stats.Error},
+	} {
+		m.sample("capn_agents", float64(status.count), "status", string(status.name))
+	}
+	m.family("capn_agents_unschedulable", "gauge", "Managed agents taken out of scheduling by the health policy.")
+	m.sample("capn_agents_unschedulable", float64(stats.Unschedulable))
+	m.family("capn_agent_restarts_total", "counter", "Restarts of unhealthy agents.")
+	m.sample("capn_agent_restarts_total", float64(stats.Restarts))
+	m.family("capn_agent_pool_hits_total", "counter", "Tasks that found an idle agent waiting.")
+	m.sample("capn_agent_pool_hits_total", float64(stats.PoolHits))
+	m.family("capn_agent_pool_misses_total", "counter", "Tasks that had to spawn an agent.")
+	m.sample("capn_agent_pool_misses_total", float64(stats.PoolMisses))
+
+	perAgent := []struct {
+		name, kind, help string
+		value            func(agents.AgentActivity) float64
+	}{
+		{"capn_agent_tasks_total", "counter", "Tasks executed by the agent.",
+			func(a agents.AgentActivity) float64 { return float64(a.Tasks) }},
+		{"capn_agent_task_failures_total", "counter", "Tasks the agent failed to execute.",
+			func(a agents.AgentActivity) float64 { return float64(a.Failed) }},
+		{"capn_agent_busy_seconds_total", "counter", "Time the agent spent executing tasks.",
+			func(a agents.AgentActivity) float64 { return a.BusyTime.Seconds() }},
+		{"capn_agent_messages_sent_total", "counter", "Messages the agent sent to other agents.",
+			func(a agents.AgentActivity) float64 { return float64(a.MessagesSent) }},
+		{"capn_agent_messages_received_total", "counter", "Messages the agent received.",
+			func(a agents.AgentActivity) float64 { return float64(a.MessagesReceived) }},
+	}
+	for _, metric := range perAgent {
+		m.family(metric.name, metric.kind, metric.help)
+		for _, agent := range managed {
+			activity := stats.Activity[agent.ID()]
+			m.sample(metric.name, metric.value(activity), "agent_id", agent.ID(), "agent_type", string(agent.Type()))
+		}
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	_, _ = w.Write(m.buf.Bytes())
+}
@@ -0,0 +1,276 @@
+package ui
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/events"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// defaultMessageLimit is the number of feed messages returned when no limit is requested
+const defaultMessageLimit = 100
+
+// TaskSummary is the condensed view of a task shown in the dashboard task list
+type TaskSummary struct {
+	ID        string          `json:"id"`
+	Goal      string          `json:"goal"`
+	Status    task.TaskStatus `json:"status"`
+	Batch     string          `json:"batch,omitempty"`
+	Pipeline  string          `json:"pipeline,omitempty"`
+	Done      int             `json:"done"`
+	Total     int             `json:"total"`
+	CreatedAt time.Time       `json:"created_at"`
+	Duration  time.Duration   `json:"duration"`
+}
+
+// AgentSummary is the condensed view of an agent shown in the dashboard
+type AgentSummary struct {
+	ID     string             `json:"id"`
+	Name   string             `json:"name"`
+	Type   agents.AgentType   `json:"type"`
+	Status agents.AgentStatus `json:"status"`
+	Health agents.HealthState `json:"health"`
+
+	Schedulable bool `json:"schedulable"`
+	Restarts    int  `json:"restarts"`
+}
+
+// AgentsResponse is the payload returned by the agents endpoint
+type AgentsResponse struct {
+	Stats  agents.AgentStats `json:"stats"`
+	Agents []AgentSummary    `json:"agents"`
+}
+
+// Server serves the embedded web dashboard and its JSON API
+type Server struct {
+	storage task.TaskStorage
+	manager *agents.AgentManager
+	commLog agents.CommunicationLogger
+	logger  *zap.Logger
+	bus     *events.Bus
+
+	httpServer *http.Server
+}
+
+// NewServer creates a dashboard server; manager and commLog may be nil
+func NewServer(storage task.TaskStorage, manager *agents.AgentManager, commLog agents.CommunicationLogger, logger *zap.Logger) *Server {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Server{
+		storage: storage,
+		manager: manager,
+		commLog: commLog,
+		logger:  logger,
+	}
+}
+
+// SetEventBus enables the live event stream endpoint backed by bus
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.bus = bus
+}
+
+// Handler returns the HTTP handler serving the dashboard and API
+func (s *Server) Handler() http.Handler {
+	static, err := fs.Sub(assets, "assets")
+	if err != nil {
+		// The embedded directory is part of the binary, so this cannot fail at runtime
+		panic(fmt.Sprintf("ui assets missing: %v", err))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks", s.handleTasks)
+	mux.HandleFunc("GET /api/tasks/{id}", s.handleTask)
+	mux.HandleFunc("GET /api/agents", s.handleAgents)
+	mux.HandleFunc("GET /api/messages", s.handleMessages)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	return mux
+}
+
+// Start begins serving on the given address in the background and returns the bound address
+func (s *Server) Start(listen string) (string, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Dashboard server stopped", zap.Error(err))
+		}
+	}()
+
+	return listener.Addr().String(), nil
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
+}
+
+// handleTasks lists all tasks with their progress
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.storage.ListTasks(task.TaskFilter{})
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	summaries := make([]TaskSummary, 0, len(tasks))
+	for _, t := range tasks {
+		done, total := t.Progress()
+		summaries = append(summaries, TaskSummary{
+			ID:        t.ID,
+			Goal:      t.Goal,
+			Status:    t.Status,
+			Batch:     t.BatchID,
+			Pipeline:  t.PipelineID,
+			Done:      done,
+			Total:     total,
+			CreatedAt: t.CreatedAt,
+			Duration:  t.Duration(),
+		})
+	}
+	s.writeJSON(w, summaries)
+}
+
+// handleTask returns a single task including its plan for DAG rendering
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	t, err := s.storage.GetTask(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, t)
+}
+
+// handleAgents returns agent statistics and the list of managed agents
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	resp := AgentsResponse{
+		Stats:  agents.AgentStats{ByType: make(map[agents.AgentType]int)},
+		Agents: make([]AgentSummary, 0),
+	}
+
+	if s.manager != nil {
+		resp.Stats = s.manager.GetAgentStats()
+		for _, agent := range s.manager.GetManagedAgents() {
+			resp.Agents = append(resp.Agents, AgentSummary{
+				ID:     agent.ID(),
+				Name:   agent.Name(),
+				Type:   agent.Type(),
+				Status: agent.Status(),
+				Health: agent.Health().Status,
+
+				Schedulable: s.manager.IsSchedulable(agent.ID()),
+				Restarts:    s.manager.Restarts(agent.ID()),
+			})
+		}
+	}
+	s.writeJSON(w, resp)
+}
+
+// handleMessages returns the most recent agent communications
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	limit := defaultMessageLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %s", raw))
+			return
+		}
+		limit = parsed
+	}
+
+	messages := make([]agents.MessageLog, 0)
+	if s.commLog != nil {
+		messages = s.commLog.GetAllMessages()
+		if len(messages) > limit {
+			messages = messages[len(messages)-limit:]
+		}
+	}
+	s.writeJSON(w, messages)
+}
+
+// handleEvents streams bus events to the client as server-sent events
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.bus == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("event stream not available"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	// Browsers reconnect on their own, so a slow dashboard is disconnected rather than stalling the bus
+	sub, err := s.bus.Subscribe(events.SubscribeOptions{Name: "dashboard", Overflow: events.OverflowDisconnect})
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.logger.Warn("Failed to encode event", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeJSON encodes a value as the JSON response body
+func (s *Server) writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		s.logger.Warn("Failed to encode dashboard response", zap.Error(err))
+	}
+}
+
+// writeError writes a JSON error response
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
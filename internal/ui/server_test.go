@@ -0,0 +1,207 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/captain"
+	"github.com/iainlowe/capn/internal/events"
+	"github.com/iainlowe/capn/internal/task"
+)
+
+func newTestServer(t *testing.T) (*Server, *task.MemoryTaskStorage, *agents.AgentManager, *agents.MemoryCommunicationLogger) {
+	storage := task.NewMemoryTaskStorage()
+	manager := agents.NewAgentManager()
+	commLog := agents.NewMemoryCommunicationLogger()
+	return NewServer(storage, manager, commLog, nil), storage, manager, commLog
+}
+
+func get(t *testing.T, handler http.Handler, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Index(t *testing.T) {
+	server, _, _, _ := newTestServer(t)
+
+	rec := get(t, server.Handler(), "/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "capn dashboard")
+
+	rec = get(t, server.Handler(), "/app.js")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "layoutPlan")
+}
+
+func TestServer_Tasks(t *testing.T) {
+	server, storage, _, _ := newTestServer(t)
+
+	te := task.NewTaskExecution("analyze code")
+	te.Plan = &captain.ExecutionPlan{
+		ID:   "plan-1",
+		Goal: "analyze code",
+		Tasks: []captain.Task{
+			{ID: "task-1", Type: captain.TaskTypeAnalysis},
+			{ID: "task-2", Type: captain.TaskTypeReporting, Dependencies: []string{"task-1"}},
+		},
+	}
+	te.Results = []captain.Result{{TaskID: "task-1", Success: true}}
+	te.SetStatus(task.TaskStatusRunning)
+	require.NoError(t, storage.SaveTask(te))
+
+	rec := get(t, server.Handler(), "/api/tasks")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var summaries []TaskSummary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summaries))
+	require.Len(t, summaries, 1)
+	assert.Equal(t, te.ID, summaries[0].ID)
+	assert.Equal(t, task.TaskStatusRunning, summaries[0].Status)
+	assert.Equal(t, 1, summaries[0].Done)
+	assert.Equal(t, 2, summaries[0].Total)
+
+	rec = get(t, server.Handler(), "/api/tasks/"+te.ID)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var loaded task.TaskExecution
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loaded))
+	require.NotNil(t, loaded.Plan)
+	assert.Equal(t, []string{"task-1"}, loaded.Plan.Tasks[1].Dependencies)
+
+	rec = get(t, server.Handler(), "/api/tasks/task-missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_Agents(t *testing.T) {
+	server, _, manager, _ := newTestServer(t)
+
+	_, err := manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+
+	rec := get(t, server.Handler(), "/api/agents")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp AgentsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Stats.Total)
+	require.Len(t, resp.Agents, 1)
+	assert.Equal(t, "file-1", resp.Agents[0].ID)
+	assert.Equal(t, agents.HealthStatusHealthy, resp.Agents[0].Health)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	server, _, manager, _ := newTestServer(t)
+	_, err := manager.SpawnAgent("file-1", "FileAgent-1", agents.AgentTypeFile)
+	require.NoError(t, err)
+	manager.RecordTask("file-1", 1500*time.Millisecond, true)
+	manager.RecordTask("file-1", 500*time.Millisecond, false)
+
+	rec := get(t, server.Handler(), "/metrics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, metricsContentType, rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE capn_agent_tasks_total counter\n")
+	assert.Contains(t, body, `capn_agents{status="idle"} 1`+"\n")
+	assert.Contains(t, body, `capn_agent_tasks_total{agent_id="file-1",agent_type="file"} 2`+"\n")
+	assert.Contains(t, body, `capn_agent_task_failures_total{agent_id="file-1",agent_type="file"} 1`+"\n")
+	assert.Contains(t, body, `capn_agent_busy_seconds_total{agent_id="file-1",agent_type="file"} 2`+"\n")
+	assert.Contains(t, body, `capn_agent_messages_received_total{agent_id="file-1",agent_type="file"} 0`+"\n")
+}
+
+func TestMetricsWriter_EscapesLabels(t *testing.T) {
+	var m metricsWriter
+	m.sample("capn_test", 0.25, "agent_id", "a\"b\\c\nd")
+	assert.Equal(t, `capn_test{agent_id="a\"b\\c\nd"} 0.25`+"\n", m.buf.String())
+}
+
+func TestServer_Messages(t *testing.T) {
+	server, _, _, commLog := newTestServer(t)
+
+	for i := 0; i < 3; i++ {
+		commLog.LogMessage("captain", "file-1", agents.Message{
+			ID:        "msg",
+			From:      "captain",
+			To:        "file-1",
+			Content:   "scan ./src",
+			Timestamp: time.Now(),
+		})
+	}
+
+	rec := get(t, server.Handler(), "/api/messages?limit=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var messages []agents.MessageLog
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &messages))
+	assert.Len(t, messages, 2)
+
+	rec = get(t, server.Handler(), "/api/messages?limit=abc")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_StartAndShutdown(t *testing.T) {
+	server, _, _, _ := newTestServer(t)
+
+	addr, err := server.Start("127.0.0.1:0")
+	require.NoError(t, err)
+
+	resp, err := http.Get("http://" + addr + "/api/tasks")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+}
+
+func TestServer_EventsUnavailable(t *testing.T) {
+	server, _, _, _ := newTestServer(t)
+
+	rec := get(t, server.Handler(), "/api/events")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_EventsStream(t *testing.T) {
+	server, _, _, _ := newTestServer(t)
+	bus := events.NewBus()
+	server.SetEventBus(bus)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+	bus.Publish(events.Event{Type: events.EventTaskCreated, TaskID: "task-1"})
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "data: "))
+
+	var event events.Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+	assert.Equal(t, events.EventTaskCreated, event.Type)
+	assert.Equal(t, "task-1", event.TaskID)
+
+	// Closing the bus ends the stream
+	bus.Close()
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,113 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iainlowe/capn/internal/common"
+	"github.com/iainlowe/capn/internal/github"
+)
+
+const (
+	// Owner and Repo name the GitHub repository capn is released from
+	Owner = "iainlowe"
+	Repo  = "capn"
+
+	// DefaultCheckInterval is how long a release check is reused before GitHub is asked again
+	DefaultCheckInterval = 24 * time.Hour
+)
+
+// ReleaseSource looks up a repository's latest release
+type ReleaseSource interface {
+	LatestRelease(ctx context.Context, owner, repo string) (*github.Release, error)
+}
+
+// Update is the outcome of checking for a newer release
+type Update struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	URL     string `json:"url,omitempty"`
+	// Available is set when the latest release is newer than the running build
+	Available bool      `json:"available"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// cachedRelease is the last release check, kept so repeated runs do not ask GitHub again
+type cachedRelease struct {
+	Latest    string    `json:"latest"`
+	URL       string    `json:"url,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checker finds out whether a newer capn release exists. It only informs; installing the
+// release is left to the user.
+type Checker struct {
+	source    ReleaseSource
+	cachePath string
+	interval  time.Duration
+	clock     common.Clock
+}
+
+// NewChecker creates a checker asking source for releases and caching the answer in cachePath
+func NewChecker(source ReleaseSource, cachePath string) *Checker {
+	return &Checker{source: source, cachePath: cachePath, interval: DefaultCheckInterval, clock: common.SystemClock}
+}
+
+// SetInterval sets how long a cached check is reused
+func (c *Checker) SetInterval(interval time.Duration) {
+	c.interval = interval
+}
+
+// Check compares the current version with the latest release, asking GitHub only when the
+// cached answer is older than the check interval
+func (c *Checker) Check(ctx context.Context, current string) (*Update, error) {
+	release, ok := c.readCache()
+	if !ok {
+		latest, err := c.source.LatestRelease(ctx, Owner, Repo)
+		if err != nil {
+			return nil, err
+		}
+		release = cachedRelease{Latest: latest.Tag, URL: latest.URL, CheckedAt: c.clock.Now()}
+		// The check still answers when its result cannot be cached
+		_ = c.writeCache(release)
+	}
+	return &Update{
+		Current:   current,
+		Latest:    release.Latest,
+		URL:       release.URL,
+		Available: Newer(release.Latest, current),
+		CheckedAt: release.CheckedAt,
+	}, nil
+}
+
+// readCache returns the cached check when it is recent enough to reuse
+func (c *Checker) readCache() (cachedRelease, bool) {
+	var release cachedRelease
+	data, err := os.ReadFile(c.cachePath)
+	if err != nil || json.Unmarshal(data, &release) != nil || release.Latest == "" {
+		return cachedRelease{}, false
+	}
+	age := c.clock.Now().Sub(release.CheckedAt)
+	if age < 0 || age >= c.interval {
+		return cachedRelease{}, false
+	}
+	return release, true
+}
+
+func (c *Checker) writeCache(release cachedRelease) error {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return fmt.Errorf("failed to encode release check: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cachePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create release check directory: %w", err)
+	}
+	if err := os.WriteFile(c.cachePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write release check: %w", err)
+	}
+	return nil
+}
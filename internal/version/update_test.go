@@ -0,0 +1,83 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlowe/capn/internal/github"
+	"github.com/iainlowe/capn/internal/testutil"
+)
+
+// fakeReleases answers release lookups with a fixed tag, counting the lookups
+type fakeReleases struct {
+	tag   string
+	err   error
+	calls int
+}
+
+func (f *fakeReleases) LatestRelease(ctx context.Context, owner, repo string) (*github.Release, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &github.Release{Tag: f.tag, URL: fmt.Sprintf("https://github.com/%s/%s/releases/tag/%s", owner, repo, f.tag)}, nil
+}
+
+func TestChecker_Check(t *testing.T) {
+	source := &fakeReleases{tag: "v1.3.0"}
+	checker := NewChecker(source, filepath.Join(t.TempDir(), "update-check.json"))
+	start := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(start)
+	checker.clock = clock
+
+	update, err := checker.Check(context.Background(), "v1.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, &Update{
+		Current:   "v1.2.0",
+		Latest:    "v1.3.0",
+		URL:       "https://github.com/iainlowe/capn/releases/tag/v1.3.0",
+		Available: true,
+		CheckedAt: start,
+	}, update)
+
+	// Within the interval the cached answer is reused
+	source.tag = "v1.4.0"
+	clock.Advance(time.Hour)
+	update, err = checker.Check(context.Background(), "v1.3.0")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.3.0", update.Latest)
+	assert.False(t, update.Available)
+	assert.Equal(t, 1, source.calls)
+
+	clock.Advance(DefaultCheckInterval)
+	update, err = checker.Check(context.Background(), "v1.3.0")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.0", update.Latest)
+	assert.True(t, update.Available)
+	assert.Equal(t, 2, source.calls)
+}
+
+func TestChecker_CheckErrors(t *testing.T) {
+	source := &fakeReleases{err: fmt.Errorf("GitHub returned 403 Forbidden")}
+	path := filepath.Join(t.TempDir(), "update-check.json")
+	checker := NewChecker(source, path)
+
+	_, err := checker.Check(context.Background(), "v1.2.0")
+	assert.EqualError(t, err, "GitHub returned 403 Forbidden")
+	assert.NoFileExists(t, path, "failed checks are not cached")
+
+	// A corrupt cache is ignored
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	source.err, source.tag = nil, "v1.2.0"
+	update, err := checker.Check(context.Background(), "v1.2.0")
+	require.NoError(t, err)
+	assert.False(t, update.Available)
+	assert.Equal(t, 2, source.calls)
+}
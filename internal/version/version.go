@@ -0,0 +1,142 @@
+// Package version describes the running capn build and checks GitHub for newer releases.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, set when releasing with
+//
+//	go build -ldflags "-X github.com/iainlowe/capn/internal/version.Version=v1.2.0 \
+//	  -X github.com/iainlowe/capn/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/iainlowe/capn/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/capn
+//
+// Builds without them fall back to what the Go toolchain recorded in the binary.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// devVersion is reported by builds that carry no release version
+const devVersion = "(devel)"
+
+// Info describes a capn build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the running build's metadata
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = devVersion
+	}
+	return info
+}
+
+// ShortCommit returns the first 12 characters of the build's commit
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
+
+// Release reports whether the build carries a release version that can be compared with others
+func (i Info) Release() bool {
+	_, ok := parseSemver(i.Version)
+	return ok
+}
+
+// String formats the version with its commit, as "v1.2.0 (0123456789ab)"
+func (i Info) String() string {
+	if commit := i.ShortCommit(); commit != "" {
+		return fmt.Sprintf("%s (%s)", i.Version, commit)
+	}
+	return i.Version
+}
+
+// semver is a parsed vMAJOR.MINOR.PATCH[-PRERELEASE] version
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+// parseSemver parses a semantic version, with or without its leading v. Build metadata after
+// a + is ignored.
+func parseSemver(v string) (semver, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, prerelease, _ := strings.Cut(v, "-")
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return semver{}, false
+	}
+	parsed := semver{prerelease: prerelease}
+	for i, field := range fields {
+		if field == "" {
+			return semver{}, false
+		}
+		n := 0
+		for _, c := range field {
+			if c < '0' || c > '9' {
+				return semver{}, false
+			}
+			n = n*10 + int(c-'0')
+		}
+		parsed.parts[i] = n
+	}
+	return parsed, true
+}
+
+// Newer reports whether version a is newer than version b. Versions that are not semantic
+// versions are never newer, nor older, than anything.
+func Newer(a, b string) bool {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			return va.parts[i] > vb.parts[i]
+		}
+	}
+	// A release is newer than its prereleases; prereleases compare by name
+	switch {
+	case va.prerelease == vb.prerelease:
+		return false
+	case va.prerelease == "":
+		return true
+	case vb.prerelease == "":
+		return false
+	}
+	return va.prerelease > vb.prerelease
+}
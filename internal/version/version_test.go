@@ -0,0 +1,57 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, Date = v, c, d }(Version, Commit, Date)
+
+	Version, Commit, Date = "v1.2.0", "0123456789abcdef0123", "2026-10-01T10:00:00Z"
+	info := Get()
+	assert.Equal(t, Info{
+		Version:   "v1.2.0",
+		Commit:    "0123456789abcdef0123",
+		Date:      "2026-10-01T10:00:00Z",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}, info)
+	assert.Equal(t, "0123456789ab", info.ShortCommit())
+	assert.Equal(t, "v1.2.0 (0123456789ab)", info.String())
+	assert.True(t, info.Release())
+
+	// Test binaries carry no release version
+	Version, Commit, Date = "", "", ""
+	info = Get()
+	assert.Equal(t, devVersion, info.Version)
+	assert.False(t, info.Release())
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "v1.3.0", b: "v1.2.9", want: true},
+		{a: "v1.10.0", b: "v1.9.0", want: true},
+		{a: "v2.0.0", b: "1.99.99", want: true},
+		{a: "v1.2.0", b: "v1.2.0"},
+		{a: "v1.2.0", b: "v1.3.0"},
+		{a: "v1.2.0", b: "v1.2.0-rc.1", want: true},
+		{a: "v1.2.0-rc.1", b: "v1.2.0"},
+		{a: "v1.2.0-rc.2", b: "v1.2.0-rc.1", want: true},
+		{a: "v1.2.0+build.5", b: "v1.2.0"},
+		{a: "v1.3.0", b: devVersion},
+		{a: "latest", b: "v1.2.0"},
+		{a: "v1.3", b: "v1.2.0"},
+		{a: "v1.x.0", b: "v1.2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, Newer(tt.a, tt.b))
+		})
+	}
+}
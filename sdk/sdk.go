@@ -0,0 +1,77 @@
+// Package sdk is the public interface for adding agent types to capn.
+//
+// A Go plugin implements Plugin, registers it from an init function and builds its own
+// capn binary by calling Main:
+//
+//	func init() { sdk.Register(myPlugin{}) }
+//	func main() { sdk.Main() }
+//
+// A plugin in any other process is declared under agents.plugins in the configuration
+// and speaks JSON lines over stdio; Go programs can use Serve for that:
+//
+//	func main() {
+//		sdk.Serve(sdk.HandlerFunc(func(ctx context.Context, task sdk.Task, peer *sdk.Peer) sdk.Result {
+//			return sdk.Result{Success: true, Output: "done"}
+//		}))
+//	}
+package sdk
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/iainlowe/capn/internal/agents"
+	"github.com/iainlowe/capn/internal/agents/plugins"
+	"github.com/iainlowe/capn/internal/cli"
+)
+
+type (
+	// Agent is a crew member that runs tasks and exchanges messages
+	Agent = agents.Agent
+	// AgentType names a kind of agent
+	AgentType = agents.AgentType
+	// Task is the work handed to an agent
+	Task = agents.Task
+	// Result is the outcome of a task
+	Result = agents.Result
+	// Message is a message routed between agents
+	Message = agents.Message
+	// Plugin provides an agent type compiled into capn
+	Plugin = plugins.Plugin
+	// ExecuteFunc runs a task for a function agent
+	ExecuteFunc = plugins.ExecuteFunc
+	// Handler runs tasks inside a plugin process
+	Handler = plugins.Handler
+	// HandlerFunc adapts a function to Handler
+	HandlerFunc = plugins.HandlerFunc
+	// MessageHandler is implemented by handlers that want routed messages
+	MessageHandler = plugins.MessageHandler
+	// Peer is a plugin process's connection back to capn
+	Peer = plugins.Peer
+)
+
+// Register makes a Go plugin's agent type available; call it from an init function
+func Register(plugin Plugin) {
+	plugins.Register(plugin)
+}
+
+// NewFuncAgent creates an agent whose tasks are run by execute
+func NewFuncAgent(id, name string, agentType AgentType, execute ExecuteFunc) Agent {
+	return plugins.NewFuncAgent(id, name, agentType, execute)
+}
+
+// Main runs capn with the registered plugins, exiting non-zero on failure
+func Main() {
+	if err := cli.NewCLI().Parse(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
+
+// Serve runs a plugin process on stdin and stdout until capn shuts it down or it is
+// interrupted
+func Serve(handler Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return plugins.Serve(ctx, os.Stdin, os.Stdout, handler)
+}